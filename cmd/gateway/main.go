@@ -9,11 +9,18 @@ import (
 	"time"
 
 	"github.com/joho/godotenv"
+	"github.com/selfhostly/internal/compress"
 	"github.com/selfhostly/internal/gateway"
+	"github.com/selfhostly/internal/initsystem"
 	"github.com/selfhostly/internal/logger"
+	"github.com/selfhostly/internal/timeouts"
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == initsystem.CommandName {
+		os.Exit(initsystem.RunCommand(initsystem.ComponentGateway, os.Args[2:], os.Stdout, os.Stderr))
+	}
+
 	envFile := os.Getenv("ENV_FILE")
 	if envFile == "" {
 		envFile = ".env"
@@ -38,6 +45,9 @@ func main() {
 	
 	// Initialize logger with configuration
 	appLogger := logger.InitLogger(environment, logJSON)
+	if err := logger.ApplyLevel(environment, os.Getenv("LOG_LEVEL")); err != nil {
+		appLogger.Warn("ignoring LOG_LEVEL", "error", err)
+	}
 
 	cfg, err := gateway.LoadConfig()
 	if err != nil {
@@ -50,19 +60,45 @@ func main() {
 		"listen_address", cfg.ListenAddress,
 		"auth_enabled", cfg.AuthEnabled,
 		"registry_ttl", cfg.RegistryTTL,
+		"registry_token", cfg.RegistryToken != "",
+		"node_max_inflight", cfg.NodeMaxInFlight,
+		"node_queue_size", cfg.NodeQueueSize,
+		"canary_backend_url", cfg.CanaryBackendURL,
+		"canary_percent", cfg.CanaryPercent,
+		"compression", cfg.Compression,
+		"error_pages_dir", cfg.ErrorPagesDir,
+		"drain_delay", cfg.DrainDelay,
+		"timeout_read", cfg.Timeouts.For(timeouts.Read),
+		"timeout_logs", cfg.Timeouts.For(timeouts.Logs),
+		"timeout_container_update", cfg.Timeouts.For(timeouts.ContainerUpdate),
+		"timeout_image_pull", cfg.Timeouts.For(timeouts.ImagePull),
+		"timeout_stream", cfg.Timeouts.For(timeouts.Stream),
 	)
 
 	registry := gateway.NewNodeRegistry(cfg.PrimaryBackendURL, cfg.GatewayAPIKey, cfg.RegistryTTL, appLogger)
+	if cfg.RegistryToken != "" {
+		registry.SetToken(cfg.RegistryToken, cfg.RegistryTokenFile)
+	}
 	registry.Start()
 
 	router := gateway.NewRouter(registry, appLogger)
 	proxy := gateway.NewProxy(router, registry, cfg, appLogger)
+	errorPages, err := gateway.LoadErrorPages(cfg.ErrorPagesDir)
+	if err != nil {
+		appLogger.Error("failed to load error pages", "error", err)
+		os.Exit(1)
+	}
+	proxy.SetErrorPages(errorPages)
 
+	var handler http.Handler = proxy
+	if cfg.Compression {
+		handler = compress.Handler(proxy)
+	}
 	server := &http.Server{
 		Addr:         cfg.ListenAddress,
-		Handler:      proxy,
+		Handler:      handler,
 		ReadTimeout:  30 * time.Second,
-		WriteTimeout: 120 * time.Second,
+		WriteTimeout: cfg.Timeouts.Max() + 30*time.Second, // backstop; the proxy sets a write deadline per request
 		IdleTimeout:  120 * time.Second,
 	}
 
@@ -74,9 +110,42 @@ func main() {
 		}
 	}()
 
+	// SIGHUP re-reads the env file and applies timeouts, per-node limits, the registry TTL and token,
+	// the canary and the log level; the listen address, backend URL and auth settings still need a restart
+	reload := make(chan os.Signal, 1)
+	signal.Notify(reload, syscall.SIGHUP)
+	go func() {
+		for range reload {
+			if err := godotenv.Overload(envFile); err != nil {
+				appLogger.Warn("config reload: env file not read", "file", envFile, "error", err)
+			}
+			next, err := gateway.LoadConfig()
+			if err != nil {
+				appLogger.Error("config reload failed", "error", err)
+				continue
+			}
+			level := logger.Level()
+			if err := logger.ApplyLevel(environment, os.Getenv("LOG_LEVEL")); err != nil {
+				appLogger.Error("config reload failed", "error", err)
+				continue
+			}
+			changed := proxy.Reload(next)
+			if logger.Level() != level {
+				changed = append(changed, "LOG_LEVEL")
+			}
+			appLogger.Info("gateway configuration reloaded", "changed", changed)
+		}
+	}()
+
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
+	// Answer 503 and report draining so load balancers stop sending traffic before the listener closes
+	proxy.Drain()
+	if cfg.DrainDelay > 0 {
+		appLogger.Info("draining gateway...", "delay", cfg.DrainDelay)
+		time.Sleep(cfg.DrainDelay)
+	}
 	appLogger.Info("shutting down gateway...")
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
@@ -10,20 +10,32 @@ import (
 
 	"github.com/joho/godotenv"
 	"github.com/selfhostly/internal/config"
+	"github.com/selfhostly/internal/constants"
 	"github.com/selfhostly/internal/db"
 	"github.com/selfhostly/internal/http"
+	"github.com/selfhostly/internal/initsystem"
 	"github.com/selfhostly/internal/logger"
+	"github.com/selfhostly/internal/node"
+	"github.com/selfhostly/internal/panelimport"
+	"github.com/selfhostly/internal/seed"
 )
 
 func main() {
+	if len(os.Args) > 1 && os.Args[1] == initsystem.CommandName {
+		os.Exit(initsystem.RunCommand(initsystem.ComponentServer, os.Args[2:], os.Stdout, os.Stderr))
+	}
+	if len(os.Args) > 1 && os.Args[1] == panelimport.CommandName {
+		os.Exit(panelimport.RunCommand(os.Args[2:], os.Stdout, os.Stderr))
+	}
+	if len(os.Args) > 1 && os.Args[1] == seed.CommandName {
+		os.Exit(seed.RunCommand(os.Args[2:], os.Stdout, os.Stderr))
+	}
+
 	// Show current working directory for debugging
 	cwd, _ := os.Getwd()
 	
 	// Load .env file - can be overridden with ENV_FILE environment variable
-	envFile := os.Getenv("ENV_FILE")
-	if envFile == "" {
-		envFile = ".env"
-	}
+	envFile := config.EnvFile()
 	
 	if err := godotenv.Load(envFile); err != nil {
 		// Use default logger temporarily before config is loaded
@@ -39,9 +51,15 @@ func main() {
 		os.Exit(1)
 	}
 
+	// Once every node checks signatures, node requests stop carrying the API key
+	node.SetKeylessAuth(cfg.Security.RequireSignedRequests)
+
 	// Initialize structured logger based on environment
 	// This sets slog as the default logger, so we can use slog directly throughout
 	logger.InitLogger(cfg.Environment, cfg.LogJSON)
+	if err := logger.ApplyLevel(cfg.Environment, cfg.LogLevel); err != nil {
+		slog.Warn("Ignoring LOG_LEVEL", "error", err)
+	}
 	
 	slog.Info("Application starting", "cwd", cwd, "environment", cfg.Environment)
 
@@ -76,6 +94,17 @@ func main() {
 		os.Exit(1)
 	}
 
+	// Chaos mode simulates docker and tunnels, so new tunnels go to the fake provider
+	if cfg.Chaos.Enabled {
+		slog.Warn("CHAOS MODE: docker and tunnel providers are simulated; use a throwaway DATABASE_PATH and APPS_DIR",
+			"latency", cfg.Chaos.Latency,
+			"jitter", cfg.Chaos.Jitter,
+			"failure_rate", cfg.Chaos.FailureRate,
+			"fail_operations", cfg.Chaos.FailOperations)
+		// Only for this process: the saved provider is back once chaos mode is off
+		db.OverrideTunnelProvider(constants.ProviderFake)
+	}
+
 	// Verify node setup
 	nodes, err := database.GetAllNodes()
 	if err != nil {
@@ -124,7 +153,7 @@ func main() {
 		}
 
 		// Verify all apps have node assignments (for migration verification)
-		apps, err := database.GetAllApps()
+		apps, err := database.GetAllAppsMetadata()
 		if err == nil {
 			unassignedCount := 0
 			for _, app := range apps {
@@ -148,6 +177,18 @@ func main() {
 	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 	defer stop()
 
+	// SIGHUP reloads the reload-safe settings without dropping tunnels or running jobs
+	reload := make(chan os.Signal, 1)
+	signal.Notify(reload, syscall.SIGHUP)
+	defer signal.Stop(reload)
+	go func() {
+		for range reload {
+			if _, err := server.ReloadConfig(context.Background()); err != nil {
+				slog.Error("Failed to reload configuration", "error", err)
+			}
+		}
+	}()
+
 	// Start server in goroutine
 	serverErr := make(chan error, 1)
 	go func() {
@@ -4,6 +4,7 @@ go 1.24.0
 
 require (
 	github.com/compose-spec/compose-go/v2 v2.10.1
+	github.com/distribution/reference v0.5.0
 	github.com/gin-gonic/gin v1.9.1
 	github.com/go-pkgz/auth v1.24.0
 	github.com/golang-jwt/jwt v3.2.2+incompatible
@@ -20,7 +21,6 @@ require (
 	github.com/bytedance/sonic v1.9.1 // indirect
 	github.com/chenzhuoyu/base64x v0.0.0-20221115062448-fe3a3abad311 // indirect
 	github.com/dghubble/oauth1 v0.7.3 // indirect
-	github.com/distribution/reference v0.5.0 // indirect
 	github.com/docker/go-connections v0.4.0 // indirect
 	github.com/docker/go-units v0.5.0 // indirect
 	github.com/dustin/go-humanize v1.0.1 // indirect
@@ -8,7 +8,14 @@ const (
 	Apps         = "/api/apps"
 	Settings     = "/api/settings"
 	SystemStats  = "/api/system/stats"
+	LogLevel     = "/api/system/log-level"
+	OpsLockSync  = "/api/system/operations-lock/sync"
+	QuotaCheck   = "/api/quotas/check"
+	TokenVerify  = "/api/tokens/verify"
+	LogsSearch   = "/api/logs/search"
+	Jobs         = "/api/jobs"
 	TunnelsList  = "/api/tunnels"
+	Nodes        = "/api/nodes"
 	NodeRegister = "/api/nodes/register"
 	Health       = "/api/health"
 )
@@ -31,7 +38,10 @@ func TunnelSwitchToCustom(appID string) string { return "/api/tunnels/apps/" + a
 func TunnelSync(appID string) string           { return "/api/tunnels/apps/" + appID + "/sync" }
 func TunnelIngress(appID string) string        { return "/api/tunnels/apps/" + appID + "/ingress" }
 func TunnelDNS(appID string) string            { return "/api/tunnels/apps/" + appID + "/dns" }
+func JobByID(jobID string) string              { return "/api/jobs/" + jobID }
 func NodeHeartbeat(nodeID string) string       { return "/api/nodes/" + nodeID + "/heartbeat" }
+func NodeAppInventory(nodeID string) string    { return "/api/nodes/" + nodeID + "/apps/sync" }
+func NodeServerLogs(nodeID string) string      { return "/api/nodes/" + nodeID + "/server-logs" }
 func ContainerRestart(containerID string) string { return "/api/system/containers/" + containerID + "/restart" }
 func ContainerStop(containerID string) string    { return "/api/system/containers/" + containerID + "/stop" }
 func Container(containerID string) string        { return "/api/system/containers/" + containerID }
@@ -0,0 +1,213 @@
+// Package appicon finds the icon of a web app: one its page links to, or its /favicon.ico. Apps
+// can also name an icon URL outright. Icons are downloaded so they can be cached and served by
+// the API.
+package appicon
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"regexp"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/selfhostly/internal/constants"
+)
+
+// maxPageSize bounds how much of an app's page is read looking for icon links
+const maxPageSize = 1 << 20
+
+// maxRedirects bounds how many redirects are followed for one icon or page
+const maxRedirects = 5
+
+// ErrInternalAddress is returned for icons and pages on loopback, private or link-local addresses
+var ErrInternalAddress = errors.New("refusing to fetch from an internal address")
+
+var (
+	linkTagPattern   = regexp.MustCompile(`(?is)<link\s[^>]*>`)
+	linkAttrPattern  = regexp.MustCompile(`(?is)\b(rel|href)\s*=\s*(?:"([^"]*)"|'([^']*)'|([^\s>]+))`)
+	headClosePattern = regexp.MustCompile(`(?i)</head\s*>`)
+)
+
+// NewClient returns an HTTP client for icons that only connects to public addresses. Each
+// address is checked as it is dialed, after the host is resolved, so hostnames resolving to an
+// internal address and redirects to one are refused too.
+func NewClient(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{Timeout: timeout, Control: refuseInternal}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil // the proxy, not the icon's host, would be dialed
+	transport.DialContext = dialer.DialContext
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: transport,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= maxRedirects {
+				return fmt.Errorf("stopped after %d redirects", maxRedirects)
+			}
+			if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+				return fmt.Errorf("refusing to follow a redirect to %s", req.URL.Scheme)
+			}
+			return nil
+		},
+	}
+}
+
+// refuseInternal is a net.Dialer Control function that refuses connections to internal addresses
+func refuseInternal(_, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	addr, err := netip.ParseAddr(host)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrInternalAddress, host)
+	}
+	if !IsPublicAddr(addr) {
+		return fmt.Errorf("%w: %s", ErrInternalAddress, addr)
+	}
+	return nil
+}
+
+// IsPublicAddr reports whether addr is neither loopback, private, link-local, multicast nor
+// unspecified. IPv4-mapped IPv6 addresses are checked as IPv4.
+func IsPublicAddr(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	return addr.IsValid() && !addr.IsLoopback() && !addr.IsPrivate() && !addr.IsUnspecified() &&
+		!addr.IsLinkLocalUnicast() && !addr.IsLinkLocalMulticast() && !addr.IsInterfaceLocalMulticast() &&
+		!addr.IsMulticast()
+}
+
+// Icon is a downloaded image
+type Icon struct {
+	URL         string
+	ContentType string
+	Data        []byte
+}
+
+// Detect returns the icon of the app served at pageURL. Icons the page links to are tried first,
+// apple-touch-icons (which are larger) before plain ones, then /favicon.ico.
+func Detect(ctx context.Context, client *http.Client, pageURL string) (*Icon, error) {
+	base, err := url.Parse(pageURL)
+	if err != nil || (base.Scheme != "http" && base.Scheme != "https") || base.Host == "" {
+		return nil, fmt.Errorf("invalid app URL %q", pageURL)
+	}
+
+	candidates, pageErr := iconLinks(ctx, client, base)
+	candidates = append(candidates, base.ResolveReference(&url.URL{Path: "/favicon.ico"}).String())
+
+	var lastErr error
+	for _, candidate := range candidates {
+		icon, err := Fetch(ctx, client, candidate)
+		if err == nil {
+			return icon, nil
+		}
+		lastErr = err
+	}
+	if pageErr != nil {
+		return nil, fmt.Errorf("no icon found: %w (page: %v)", lastErr, pageErr)
+	}
+	return nil, fmt.Errorf("no icon found: %w", lastErr)
+}
+
+// Fetch downloads the image at iconURL, refusing anything that isn't an image or is larger than
+// AppIconMaxSize
+func Fetch(ctx context.Context, client *http.Client, iconURL string) (*Icon, error) {
+	body, contentType, err := get(ctx, client, iconURL, constants.AppIconMaxSize)
+	if err != nil {
+		return nil, err
+	}
+	if len(body) == 0 {
+		return nil, fmt.Errorf("%s is empty", iconURL)
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+	if !strings.HasPrefix(mediaType, "image/") {
+		mediaType = http.DetectContentType(body)
+		if i := strings.Index(mediaType, ";"); i >= 0 {
+			mediaType = mediaType[:i]
+		}
+	}
+	if !strings.HasPrefix(mediaType, "image/") {
+		return nil, fmt.Errorf("%s isn't an image (%s)", iconURL, mediaType)
+	}
+	return &Icon{URL: iconURL, ContentType: mediaType, Data: body}, nil
+}
+
+// iconLinks returns the icons the page at base links to in its head
+func iconLinks(ctx context.Context, client *http.Client, base *url.URL) ([]string, error) {
+	page, _, err := get(ctx, client, base.String(), maxPageSize)
+	if err != nil && len(page) == 0 {
+		return nil, err
+	}
+	if loc := headClosePattern.FindIndex(page); loc != nil {
+		page = page[:loc[0]]
+	}
+
+	var touch, plain []string
+	for _, tag := range linkTagPattern.FindAll(page, -1) {
+		var rel, href string
+		for _, attr := range linkAttrPattern.FindAllSubmatch(tag, -1) {
+			value := string(bytes.Join(attr[2:], nil))
+			if strings.EqualFold(string(attr[1]), "rel") {
+				rel = strings.ToLower(value)
+			} else {
+				href = strings.TrimSpace(value)
+			}
+		}
+		if href == "" || strings.HasPrefix(href, "data:") {
+			continue
+		}
+		ref, err := url.Parse(href)
+		if err != nil {
+			continue
+		}
+		resolved := base.ResolveReference(ref).String()
+		for _, r := range strings.Fields(rel) {
+			if r == "apple-touch-icon" || r == "apple-touch-icon-precomposed" {
+				touch = append(touch, resolved)
+				break
+			}
+			if r == "icon" {
+				plain = append(plain, resolved)
+				break
+			}
+		}
+	}
+	return append(touch, plain...), nil
+}
+
+// get reads up to limit bytes of the resource at rawURL. A larger resource is an error, but what
+// was read is still returned, which is enough to find a page's icon links.
+func get(ctx context.Context, client *http.Client, rawURL string, limit int64) ([]byte, string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	req.Header.Set("User-Agent", "Selfhostly icon fetcher")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("%s returned %d", rawURL, resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, limit+1))
+	if err != nil {
+		return nil, "", err
+	}
+	if int64(len(body)) > limit {
+		return body[:limit], resp.Header.Get("Content-Type"), fmt.Errorf("%s is larger than %d bytes", rawURL, limit)
+	}
+	return body, resp.Header.Get("Content-Type"), nil
+}
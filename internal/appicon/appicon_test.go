@@ -0,0 +1,122 @@
+package appicon
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"testing"
+	"time"
+)
+
+// png is the start of a PNG file, enough for content sniffing
+var png = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+
+func TestDetect(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/app/", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<html><head>
+			<link rel="stylesheet" href="/style.css">
+			<link rel="icon" href="data:,">
+			<LINK REL='shortcut icon' HREF='icons/small.png'>
+			<link href="/missing-touch.png" rel="apple-touch-icon">
+			</head><body><link rel="icon" href="/body.png"></body></html>`))
+	})
+	mux.HandleFunc("/app/icons/small.png", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Write(png)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	icon, err := Detect(context.Background(), server.Client(), server.URL+"/app/")
+	if err != nil {
+		t.Fatalf("Detect: %v", err)
+	}
+	if icon.URL != server.URL+"/app/icons/small.png" || icon.ContentType != "image/png" {
+		t.Errorf("Expected the linked icon to be sniffed as a PNG, got %s (%s)", icon.URL, icon.ContentType)
+	}
+}
+
+func TestDetect_Favicon(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`<html><head><title>No icons</title></head></html>`))
+	})
+	mux.HandleFunc("/favicon.ico", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/x-icon")
+		w.Write([]byte{0, 0, 1, 0})
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	icon, err := Detect(context.Background(), server.Client(), server.URL+"/dashboard")
+	if err != nil {
+		t.Fatalf("Detect: %v", err)
+	}
+	if icon.URL != server.URL+"/favicon.ico" || icon.ContentType != "image/x-icon" {
+		t.Errorf("Expected the favicon, got %s (%s)", icon.URL, icon.ContentType)
+	}
+}
+
+func TestFetch_Refused(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/page.png", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("<html>not an image</html>"))
+	})
+	mux.HandleFunc("/huge.png", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		w.Write([]byte(strings.Repeat("x", 600<<10)))
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	for _, path := range []string{"/page.png", "/huge.png", "/missing.png"} {
+		if _, err := Fetch(context.Background(), server.Client(), server.URL+path); err == nil {
+			t.Errorf("Expected %s to be refused", path)
+		}
+	}
+	if _, err := Detect(context.Background(), server.Client(), "ftp://example.com"); err == nil {
+		t.Error("Expected a non-HTTP URL to be refused")
+	}
+}
+
+func TestNewClient_RefusesInternalAddresses(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		w.Write(png)
+	}))
+	defer server.Close()
+
+	client := NewClient(5 * time.Second)
+	// Hostnames are checked once resolved
+	for _, iconURL := range []string{server.URL + "/icon.png", strings.Replace(server.URL, "127.0.0.1", "localhost", 1) + "/icon.png"} {
+		if _, err := Fetch(context.Background(), client, iconURL); !errors.Is(err, ErrInternalAddress) {
+			t.Errorf("Expected %s to be refused as internal, got %v", iconURL, err)
+		}
+	}
+}
+
+func TestIsPublicAddr(t *testing.T) {
+	for addr, public := range map[string]bool{
+		"93.184.216.34":      true,
+		"2606:4700::1111":    true,
+		"127.0.0.1":          false,
+		"::1":                false,
+		"10.1.2.3":           false,
+		"172.16.0.1":         false,
+		"192.168.1.10":       false,
+		"169.254.169.254":    false,
+		"fe80::1":            false,
+		"fd00::1":            false,
+		"0.0.0.0":            false,
+		"::ffff:192.168.1.1": false,
+		"224.0.0.1":          false,
+	} {
+		if got := IsPublicAddr(netip.MustParseAddr(addr)); got != public {
+			t.Errorf("IsPublicAddr(%s) = %v, want %v", addr, got, public)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package applock
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/selfhostly/internal/constants"
+	"github.com/selfhostly/internal/db"
+	"github.com/selfhostly/internal/domain"
+)
+
+// heldLocksKey is the context key for the set of app IDs whose lease the caller already holds
+type heldLocksKey struct{}
+
+// Acquire takes the lease on an app for a synchronous operation, failing fast with a
+// conflict error when another operation holds it. The returned context marks the lease as
+// held, so nested operations on the same app (e.g. tunnel creation redeploying containers)
+// reuse it instead of conflicting with themselves. release must always be called.
+func Acquire(ctx context.Context, database *db.DB, appID, operation string) (context.Context, func(), error) {
+	if Held(ctx, appID) {
+		return ctx, func() {}, nil
+	}
+
+	holder := uuid.New().String()
+	lock, acquired, err := database.TryAcquireAppLock(appID, holder, operation, constants.AppLockTTL)
+	if err != nil {
+		return ctx, func() {}, domain.WrapDatabaseOperation("acquire app lock", err)
+	}
+	if !acquired {
+		return ctx, func() {}, domain.WrapAppLocked(appID, lock.Operation)
+	}
+
+	return withHeld(ctx, appID), releaseFunc(database, appID, holder), nil
+}
+
+// Wait takes the lease on an app for holder (typically a job ID), retrying until it is free,
+// the context is cancelled, or constants.AppLockWaitTimeout passes. onWait is called with the
+// blocking operation each time the lease is found busy; it may be nil.
+func Wait(ctx context.Context, database *db.DB, appID, holder, operation string, onWait func(blockedBy string)) (context.Context, func(), error) {
+	if Held(ctx, appID) {
+		return ctx, func() {}, nil
+	}
+
+	deadline := time.Now().Add(constants.AppLockWaitTimeout)
+	for {
+		lock, acquired, err := database.TryAcquireAppLock(appID, holder, operation, constants.AppLockTTL)
+		if err != nil {
+			return ctx, func() {}, domain.WrapDatabaseOperation("acquire app lock", err)
+		}
+		if acquired {
+			return withHeld(ctx, appID), releaseFunc(database, appID, holder), nil
+		}
+
+		if time.Now().After(deadline) {
+			return ctx, func() {}, fmt.Errorf("timed out waiting for %s to finish: %w", lock.Operation, domain.WrapAppLocked(appID, lock.Operation))
+		}
+		if onWait != nil {
+			onWait(lock.Operation)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx, func() {}, ctx.Err()
+		case <-time.After(constants.AppLockRetryInterval):
+		}
+	}
+}
+
+// Held reports whether ctx already carries the lease for appID
+func Held(ctx context.Context, appID string) bool {
+	held, _ := ctx.Value(heldLocksKey{}).(map[string]bool)
+	return held[appID]
+}
+
+// withHeld returns a context that records the lease for appID alongside any already held
+func withHeld(ctx context.Context, appID string) context.Context {
+	prev, _ := ctx.Value(heldLocksKey{}).(map[string]bool)
+	held := make(map[string]bool, len(prev)+1)
+	for id := range prev {
+		held[id] = true
+	}
+	held[appID] = true
+	return context.WithValue(ctx, heldLocksKey{}, held)
+}
+
+// releaseFunc returns a release callback; errors are ignored since the lease expires anyway
+func releaseFunc(database *db.DB, appID, holder string) func() {
+	return func() {
+		_ = database.ReleaseAppLock(appID, holder)
+	}
+}
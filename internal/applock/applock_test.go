@@ -0,0 +1,112 @@
+package applock
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/selfhostly/internal/db"
+	"github.com/selfhostly/internal/domain"
+)
+
+func newTestDB(t *testing.T) *db.DB {
+	t.Helper()
+	database, err := db.Init(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to initialize database: %v", err)
+	}
+	t.Cleanup(func() { database.Close() })
+	return database
+}
+
+func TestAcquireConflict(t *testing.T) {
+	database := newTestDB(t)
+	ctx := context.Background()
+
+	lockedCtx, release, err := Acquire(ctx, database, "app-1", "start")
+	if err != nil {
+		t.Fatalf("first Acquire: %v", err)
+	}
+
+	// A second, independent operation on the same app fails fast
+	if _, _, err := Acquire(ctx, database, "app-1", "stop"); !domain.IsConflictError(err) {
+		t.Fatalf("expected conflict error, got %v", err)
+	}
+
+	// Other apps are unaffected
+	_, releaseOther, err := Acquire(ctx, database, "app-2", "stop")
+	if err != nil {
+		t.Fatalf("Acquire on other app: %v", err)
+	}
+	releaseOther()
+
+	// Nested operations carrying the lease reuse it
+	_, releaseNested, err := Acquire(lockedCtx, database, "app-1", "container update")
+	if err != nil {
+		t.Fatalf("nested Acquire: %v", err)
+	}
+	releaseNested()
+
+	// The nested release must not drop the outer lease
+	if _, _, err := Acquire(ctx, database, "app-1", "stop"); !domain.IsConflictError(err) {
+		t.Fatalf("expected lease to survive nested release, got %v", err)
+	}
+
+	release()
+	_, release, err = Acquire(ctx, database, "app-1", "stop")
+	if err != nil {
+		t.Fatalf("Acquire after release: %v", err)
+	}
+	release()
+}
+
+func TestExpiredLeaseIsTakenOver(t *testing.T) {
+	database := newTestDB(t)
+
+	if _, acquired, err := database.TryAcquireAppLock("app-1", "crashed-job", "app_update", -time.Second); err != nil || !acquired {
+		t.Fatalf("TryAcquireAppLock: acquired=%v err=%v", acquired, err)
+	}
+
+	_, release, err := Acquire(context.Background(), database, "app-1", "start")
+	if err != nil {
+		t.Fatalf("expected expired lease to be taken over, got %v", err)
+	}
+	release()
+}
+
+func TestWaitQueuesBehindHolder(t *testing.T) {
+	database := newTestDB(t)
+	ctx := context.Background()
+
+	_, release, err := Acquire(ctx, database, "app-1", "rollback")
+	if err != nil {
+		t.Fatalf("Acquire: %v", err)
+	}
+
+	waited := make(chan string, 1)
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		release()
+	}()
+
+	_, releaseJob, err := Wait(ctx, database, "app-1", "job-1", "app_update", func(blockedBy string) {
+		select {
+		case waited <- blockedBy:
+		default:
+		}
+	})
+	if err != nil {
+		t.Fatalf("Wait: %v", err)
+	}
+	defer releaseJob()
+
+	select {
+	case blockedBy := <-waited:
+		if blockedBy != "rollback" {
+			t.Errorf("blockedBy = %q, want rollback", blockedBy)
+		}
+	default:
+		t.Error("expected Wait to report the blocking operation")
+	}
+}
@@ -0,0 +1,148 @@
+// Package appstate owns the lifecycle of an app's status. Every status change goes through a
+// Machine, which rejects transitions the lifecycle doesn't allow, persists the app, records the
+// change in the app's status history and emits an Event describing it.
+package appstate
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/selfhostly/internal/constants"
+	"github.com/selfhostly/internal/db"
+	"github.com/selfhostly/internal/events"
+)
+
+// transitions lists the statuses an app may move to from each status. Staying in the same status
+// is always allowed, e.g. to record a new error message.
+var transitions = map[string][]string{
+	constants.AppStatusPending: {
+		constants.AppStatusRunning, constants.AppStatusStopped, constants.AppStatusUpdating, constants.AppStatusError,
+	},
+	constants.AppStatusRunning: {
+		constants.AppStatusUpdating, constants.AppStatusStopped, constants.AppStatusDegraded, constants.AppStatusError,
+	},
+	constants.AppStatusStopped: {
+		constants.AppStatusPending, constants.AppStatusRunning, constants.AppStatusUpdating, constants.AppStatusError,
+	},
+	constants.AppStatusUpdating: {
+		constants.AppStatusRunning, constants.AppStatusStopped, constants.AppStatusError,
+	},
+	constants.AppStatusError: {
+		constants.AppStatusPending, constants.AppStatusRunning, constants.AppStatusStopped, constants.AppStatusUpdating,
+	},
+	constants.AppStatusDegraded: {
+		constants.AppStatusRunning, constants.AppStatusUpdating, constants.AppStatusStopped, constants.AppStatusError,
+	},
+}
+
+// CanTransition reports whether an app may move from one status to another
+func CanTransition(from, to string) bool {
+	next, ok := transitions[from]
+	if !ok {
+		return false
+	}
+	if _, known := transitions[to]; !known {
+		return false
+	}
+	return from == to || slices.Contains(next, to)
+}
+
+// TransitionError is returned for a status change the lifecycle doesn't allow
+type TransitionError struct {
+	AppID string
+	From  string
+	To    string
+}
+
+func (e *TransitionError) Error() string {
+	return fmt.Sprintf("app %s cannot go from %s to %s", e.AppID, e.From, e.To)
+}
+
+// Event describes one status change of an app
+type Event struct {
+	AppID   string    `json:"app_id"`
+	AppName string    `json:"app_name"`
+	From    string    `json:"from"`
+	To      string    `json:"to"`
+	Reason  string    `json:"reason,omitempty"`
+	At      time.Time `json:"at"`
+}
+
+// Store persists an app after its status changed, and its status history
+type Store interface {
+	UpdateApp(app *db.App) error
+	CreateAppStatusChange(change *db.AppStatusChange) error
+}
+
+// Machine validates and applies app status changes
+type Machine struct {
+	store  Store
+	logger *slog.Logger
+
+	mu        sync.RWMutex
+	listeners []func(context.Context, Event)
+}
+
+// New creates a Machine that saves apps to store
+func New(store Store, logger *slog.Logger) *Machine {
+	return &Machine{store: store, logger: logger}
+}
+
+// OnTransition registers fn to be called after every persisted status change
+func (m *Machine) OnTransition(fn func(context.Context, Event)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.listeners = append(m.listeners, fn)
+}
+
+// PublishStatusChanges is a transition listener sending each change of status, not the ones that
+// stay in the same status, to the process's event stream
+func PublishStatusChanges(_ context.Context, e Event) {
+	if e.From != e.To {
+		events.Publish(events.Event{Type: events.TypeAppStatus, AppID: e.AppID, At: e.At, Data: e})
+	}
+}
+
+// Transition moves app to status to and saves it, along with any other changes the caller made
+// to it. For the error and degraded statuses reason becomes the app's error message; any other
+// status clears it. An invalid transition returns a *TransitionError and leaves app untouched.
+func (m *Machine) Transition(ctx context.Context, app *db.App, to, reason string) error {
+	from := app.Status
+	if !CanTransition(from, to) {
+		err := &TransitionError{AppID: app.ID, From: from, To: to}
+		m.logger.WarnContext(ctx, "rejected app status change", "app", app.Name, "appID", app.ID, "from", from, "to", to)
+		return err
+	}
+
+	app.Status = to
+	if to == constants.AppStatusError || to == constants.AppStatusDegraded {
+		app.ErrorMessage = &reason
+	} else {
+		app.ErrorMessage = nil
+	}
+	app.UpdatedAt = time.Now()
+	if err := m.store.UpdateApp(app); err != nil {
+		return fmt.Errorf("failed to save app status: %w", err)
+	}
+
+	event := Event{AppID: app.ID, AppName: app.Name, From: from, To: to, Reason: reason, At: app.UpdatedAt}
+	if from != to {
+		m.logger.InfoContext(ctx, "app status changed", "app", app.Name, "appID", app.ID, "from", from, "to", to)
+		change := &db.AppStatusChange{AppID: app.ID, FromStatus: from, ToStatus: to, ChangedAt: app.UpdatedAt}
+		if err := m.store.CreateAppStatusChange(change); err != nil {
+			m.logger.WarnContext(ctx, "failed to record app status change", "app", app.Name, "appID", app.ID, "error", err)
+		}
+	}
+
+	m.mu.RLock()
+	listeners := slices.Clone(m.listeners)
+	m.mu.RUnlock()
+	for _, fn := range listeners {
+		fn(ctx, event)
+	}
+	return nil
+}
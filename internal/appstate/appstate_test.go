@@ -0,0 +1,135 @@
+package appstate
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/selfhostly/internal/constants"
+	"github.com/selfhostly/internal/db"
+	"github.com/selfhostly/internal/events"
+)
+
+func TestCanTransition(t *testing.T) {
+	for _, tc := range []struct {
+		from, to string
+		want     bool
+	}{
+		{constants.AppStatusPending, constants.AppStatusRunning, true},
+		{constants.AppStatusRunning, constants.AppStatusUpdating, true},
+		{constants.AppStatusUpdating, constants.AppStatusRunning, true},
+		{constants.AppStatusUpdating, constants.AppStatusError, true},
+		{constants.AppStatusRunning, constants.AppStatusDegraded, true},
+		{constants.AppStatusDegraded, constants.AppStatusRunning, true},
+		{constants.AppStatusError, constants.AppStatusError, true},
+		{constants.AppStatusStopped, constants.AppStatusDegraded, false},
+		{constants.AppStatusUpdating, constants.AppStatusPending, false},
+		{constants.AppStatusRunning, constants.AppStatusPending, false},
+		{constants.AppStatusRunning, "bogus", false},
+		{"bogus", constants.AppStatusRunning, false},
+	} {
+		if got := CanTransition(tc.from, tc.to); got != tc.want {
+			t.Errorf("CanTransition(%s, %s) = %v, want %v", tc.from, tc.to, got, tc.want)
+		}
+	}
+}
+
+func TestMachine_Transition(t *testing.T) {
+	database, err := db.Init(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to initialize database: %v", err)
+	}
+	defer database.Close()
+
+	app := db.NewApp("web", "", "services:\n  web:\n    image: nginx:latest\n")
+	app.Status = constants.AppStatusRunning
+	if err := database.CreateApp(app); err != nil {
+		t.Fatalf("Failed to create app: %v", err)
+	}
+
+	m := New(database, slog.Default())
+	var events []Event
+	m.OnTransition(func(_ context.Context, e Event) { events = append(events, e) })
+	ctx := context.Background()
+
+	if err := m.Transition(ctx, app, constants.AppStatusError, "pull failed"); err != nil {
+		t.Fatalf("Transition to error: %v", err)
+	}
+	stored, _ := database.GetApp(app.ID)
+	if stored.Status != constants.AppStatusError || stored.ErrorMessage == nil || *stored.ErrorMessage != "pull failed" {
+		t.Errorf("Expected the error to be saved, got %s (%v)", stored.Status, stored.ErrorMessage)
+	}
+
+	if err := m.Transition(ctx, app, constants.AppStatusRunning, ""); err != nil {
+		t.Fatalf("Transition to running: %v", err)
+	}
+	if app.ErrorMessage != nil {
+		t.Errorf("Expected the error message to be cleared, got %q", *app.ErrorMessage)
+	}
+
+	err = m.Transition(ctx, app, constants.AppStatusPending, "")
+	var transitionErr *TransitionError
+	if !errors.As(err, &transitionErr) || transitionErr.From != constants.AppStatusRunning {
+		t.Fatalf("Expected a TransitionError, got %v", err)
+	}
+	if stored, _ := database.GetApp(app.ID); stored.Status != constants.AppStatusRunning || app.Status != constants.AppStatusRunning {
+		t.Errorf("Expected a rejected transition to leave the app running, got %s", stored.Status)
+	}
+
+	if len(events) != 2 || events[0].From != constants.AppStatusRunning || events[0].To != constants.AppStatusError ||
+		events[0].Reason != "pull failed" || events[1].To != constants.AppStatusRunning {
+		t.Errorf("Unexpected events %+v", events)
+	}
+
+	history, err := database.GetAppStatusHistory(app.ID, time.Time{})
+	if err != nil {
+		t.Fatalf("GetAppStatusHistory: %v", err)
+	}
+	if len(history) != 2 || history[0].ToStatus != constants.AppStatusError || history[1].FromStatus != constants.AppStatusError {
+		t.Errorf("Expected both changes in the status history, got %+v", history)
+	}
+}
+
+func TestMachine_TransitionPublishesStatusChanges(t *testing.T) {
+	database, err := db.Init(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to initialize database: %v", err)
+	}
+	defer database.Close()
+
+	app := db.NewApp("web", "", "services:\n  web:\n    image: nginx:latest\n")
+	app.Status = constants.AppStatusRunning
+	if err := database.CreateApp(app); err != nil {
+		t.Fatalf("Failed to create app: %v", err)
+	}
+
+	sub := events.Default().Subscribe(events.Filter{Types: []string{events.TypeAppStatus}, AppID: app.ID})
+	defer sub.Close()
+	m := New(database, slog.Default())
+	m.OnTransition(PublishStatusChanges)
+	ctx := context.Background()
+	if err := m.Transition(ctx, app, constants.AppStatusStopped, ""); err != nil {
+		t.Fatalf("Transition to stopped: %v", err)
+	}
+	// Staying in the same status isn't a change
+	if err := m.Transition(ctx, app, constants.AppStatusStopped, ""); err != nil {
+		t.Fatalf("Transition to stopped again: %v", err)
+	}
+
+	select {
+	case e := <-sub.Events():
+		if change, ok := e.Data.(Event); !ok || change.From != constants.AppStatusRunning || change.To != constants.AppStatusStopped {
+			t.Errorf("Expected running -> stopped, got %+v", e.Data)
+		}
+	default:
+		t.Fatal("Expected an app_status event")
+	}
+	select {
+	case e := <-sub.Events():
+		t.Errorf("Expected a single event, also got %+v", e)
+	default:
+	}
+}
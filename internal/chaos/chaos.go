@@ -0,0 +1,144 @@
+// Package chaos simulates docker and tunnel provider operations with configurable latency and
+// failures, so the job system, routing and UI flows can be exercised without Docker or
+// Cloudflare credentials. It is a development and testing aid and is refused in production.
+package chaos
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrInjected is returned by operations the injector made fail
+var ErrInjected = errors.New("chaos: injected failure")
+
+// Config controls the simulated operations
+type Config struct {
+	Enabled bool // CHAOS_MODE
+
+	// Latency is added to every simulated operation, plus a random extra of up to Jitter
+	Latency time.Duration
+	Jitter  time.Duration
+
+	// FailureRate is the chance (0-1) that a simulated operation fails
+	FailureRate float64
+
+	// FailOperations limits failures to operations matching one of these patterns, e.g.
+	// "compose_up" or "tunnel_*"; empty means every operation may fail
+	FailOperations []string
+
+	// Seed makes the failures reproducible; 0 seeds from the clock
+	Seed int64
+}
+
+// LoadFromEnv reads CHAOS_MODE, CHAOS_LATENCY_MS, CHAOS_JITTER_MS, CHAOS_FAILURE_RATE,
+// CHAOS_FAIL_OPERATIONS and CHAOS_SEED, keeping the default for anything unset or invalid
+func LoadFromEnv() Config {
+	cfg := Config{
+		Enabled: os.Getenv("CHAOS_MODE") == "true",
+		Latency: 200 * time.Millisecond,
+	}
+	if ms, err := strconv.Atoi(os.Getenv("CHAOS_LATENCY_MS")); err == nil && ms >= 0 {
+		cfg.Latency = time.Duration(ms) * time.Millisecond
+	}
+	if ms, err := strconv.Atoi(os.Getenv("CHAOS_JITTER_MS")); err == nil && ms >= 0 {
+		cfg.Jitter = time.Duration(ms) * time.Millisecond
+	}
+	if rate, err := strconv.ParseFloat(os.Getenv("CHAOS_FAILURE_RATE"), 64); err == nil {
+		cfg.FailureRate = min(max(rate, 0), 1)
+	}
+	for _, op := range strings.Split(os.Getenv("CHAOS_FAIL_OPERATIONS"), ",") {
+		if op = strings.TrimSpace(op); op != "" {
+			cfg.FailOperations = append(cfg.FailOperations, op)
+		}
+	}
+	if seed, err := strconv.ParseInt(os.Getenv("CHAOS_SEED"), 10, 64); err == nil {
+		cfg.Seed = seed
+	}
+	return cfg
+}
+
+// Injector delays simulated operations and decides which of them fail
+type Injector struct {
+	config Config
+	mu     sync.Mutex
+	rand   *rand.Rand
+	sleep  func(ctx context.Context, d time.Duration) error
+}
+
+var (
+	sharedOnce sync.Once
+	shared     *Injector
+)
+
+// Shared returns the process's injector, created from cfg on first use, so simulated docker and
+// tunnel operations draw from one sequence and a run with CHAOS_SEED can be repeated
+func Shared(cfg Config) *Injector {
+	sharedOnce.Do(func() { shared = New(cfg) })
+	return shared
+}
+
+// New creates an injector for the given config
+func New(cfg Config) *Injector {
+	seed := cfg.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	return &Injector{config: cfg, rand: rand.New(rand.NewSource(seed)), sleep: sleepContext}
+}
+
+// Do simulates running the named operation: it waits the configured latency, then returns an
+// error wrapping ErrInjected if the operation was picked to fail, or ctx's error if ctx is done
+// first
+func (i *Injector) Do(ctx context.Context, operation string) error {
+	i.mu.Lock()
+	delay := i.config.Latency
+	if i.config.Jitter > 0 {
+		delay += time.Duration(i.rand.Int63n(int64(i.config.Jitter) + 1))
+	}
+	fail := i.config.FailureRate > 0 && i.matches(operation) && i.rand.Float64() < i.config.FailureRate
+	i.mu.Unlock()
+
+	if err := i.sleep(ctx, delay); err != nil {
+		return err
+	}
+	if fail {
+		return fmt.Errorf("%w: %s", ErrInjected, operation)
+	}
+	return nil
+}
+
+// matches reports whether operation may be made to fail
+func (i *Injector) matches(operation string) bool {
+	if len(i.config.FailOperations) == 0 {
+		return true
+	}
+	for _, pattern := range i.config.FailOperations {
+		if ok, _ := path.Match(pattern, operation); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// sleepContext waits for d or until ctx is done
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,58 @@
+package chaos
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestLoadFromEnv(t *testing.T) {
+	t.Setenv("CHAOS_MODE", "true")
+	t.Setenv("CHAOS_LATENCY_MS", "50")
+	t.Setenv("CHAOS_JITTER_MS", "10")
+	t.Setenv("CHAOS_FAILURE_RATE", "1.5")
+	t.Setenv("CHAOS_FAIL_OPERATIONS", "compose_up, tunnel_*")
+	t.Setenv("CHAOS_SEED", "42")
+
+	cfg := LoadFromEnv()
+	if !cfg.Enabled || cfg.Latency != 50*time.Millisecond || cfg.Jitter != 10*time.Millisecond || cfg.Seed != 42 {
+		t.Errorf("unexpected config %+v", cfg)
+	}
+	if cfg.FailureRate != 1 {
+		t.Errorf("expected the failure rate to be capped at 1, got %v", cfg.FailureRate)
+	}
+	if len(cfg.FailOperations) != 2 || cfg.FailOperations[1] != "tunnel_*" {
+		t.Errorf("unexpected fail operations %v", cfg.FailOperations)
+	}
+}
+
+func TestInjector_Do(t *testing.T) {
+	injector := New(Config{Latency: time.Second, FailureRate: 1, FailOperations: []string{"compose_up", "tunnel_*"}})
+	var slept time.Duration
+	injector.sleep = func(ctx context.Context, d time.Duration) error {
+		slept += d
+		return nil
+	}
+
+	for _, op := range []string{"compose_up", "tunnel_create"} {
+		if err := injector.Do(context.Background(), op); !errors.Is(err, ErrInjected) {
+			t.Errorf("expected %s to fail, got %v", op, err)
+		}
+	}
+	if err := injector.Do(context.Background(), "compose_ps"); err != nil {
+		t.Errorf("expected compose_ps not to fail, got %v", err)
+	}
+	if slept != 3*time.Second {
+		t.Errorf("expected the latency to be applied to every operation, slept %v", slept)
+	}
+}
+
+func TestInjector_Do_ContextDone(t *testing.T) {
+	injector := New(Config{Latency: time.Hour})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := injector.Do(ctx, "compose_up"); !errors.Is(err, context.Canceled) {
+		t.Errorf("expected the context's error, got %v", err)
+	}
+}
@@ -2,8 +2,10 @@ package cleanup
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
+	"slices"
 	"strings"
 	"time"
 
@@ -13,21 +15,93 @@ import (
 	"github.com/selfhostly/internal/domain"
 )
 
+// Cleanup step IDs, used to skip steps and to label results
+const (
+	StepContainers = "containers"
+	StepVolumes    = "volumes"
+	StepNetworks   = "networks"
+	StepTunnel     = "tunnel"
+	StepArchive    = "archive"
+	StepDirectory  = "directory"
+	StepDatabase   = "database"
+)
+
+// SkippableSteps are the steps a caller may skip. The database record is always removed,
+// archiving is controlled by CleanupOptions.ArchiveDir and volumes by CleanupOptions.RemoveVolumes.
+var SkippableSteps = []string{StepContainers, StepNetworks, StepTunnel, StepDirectory}
+
+// Resource kinds reported by a dry run
+const (
+	ResourceContainer = "container"
+	ResourceNetwork   = "network"
+	ResourceVolume    = "volume"
+	ResourceTunnel    = "tunnel"
+	ResourceDNSRecord = "dns_record"
+	ResourceArchive   = "archive"
+	ResourceDirectory = "directory"
+	ResourceAppRecord = "app_record"
+)
+
+// CleanupResource is something a cleanup step would remove (or, for archives, create)
+type CleanupResource struct {
+	Kind string `json:"kind"`
+	Name string `json:"name"`
+}
+
 // CleanupResult represents the result of a cleanup operation
 type CleanupResult struct {
-	Step         string        `json:"step"`
-	Success      bool          `json:"success"`
-	Error        string        `json:"error,omitempty"`
-	ErrorMessage string        `json:"error_message,omitempty"`
-	Duration     time.Duration `json:"duration"`
+	StepID       string            `json:"step_id"`
+	Step         string            `json:"step"`
+	Success      bool              `json:"success"`
+	Skipped      bool              `json:"skipped,omitempty"`
+	Error        string            `json:"error,omitempty"`
+	ErrorMessage string            `json:"error_message,omitempty"`
+	Detail       string            `json:"detail,omitempty"`    // Step-specific outcome, e.g. where an archive was written
+	Resources    []CleanupResource `json:"resources,omitempty"` // Dry run only
+	Duration     time.Duration     `json:"duration"`
 }
 
 // CleanupOperation represents a single cleanup step
 type CleanupOperation struct {
+	ID        string
 	Name      string
 	Executor  func() error
 	OnSuccess func()
 	OnError   func(error)
+	Detail    func() string                     // Optional; recorded on the step's result after it runs
+	Plan      func() ([]CleanupResource, error) // Lists what the step would remove, for dry runs
+}
+
+// CleanupOptions changes how CleanupApp removes an app
+type CleanupOptions struct {
+	// ArchiveDir, when set, receives a tarball of the app directory before the directory is
+	// deleted. If archiving fails the directory is kept rather than lost.
+	ArchiveDir string
+
+	// DryRun reports what each step would remove without changing anything
+	DryRun bool
+
+	// SkipSteps lists step IDs (see SkippableSteps) to leave out
+	SkipSteps []string
+
+	// RemoveVolumes also removes the app's named volumes. They hold the app's data, so they are
+	// kept unless asked for.
+	RemoveVolumes bool
+
+	// Force falls back to force-removing containers when compose can't stop them, and only reports
+	// an error from CleanupApp if the app record itself couldn't be deleted. Failed steps are still
+	// recorded in the results.
+	Force bool
+}
+
+// ValidateSkipSteps returns an error naming the first step that can't be skipped
+func ValidateSkipSteps(steps []string) error {
+	for _, step := range steps {
+		if !slices.Contains(SkippableSteps, step) {
+			return fmt.Errorf("unknown or required cleanup step %q (skippable: %s)", step, strings.Join(SkippableSteps, ", "))
+		}
+	}
+	return nil
 }
 
 // CleanupManager handles comprehensive cleanup operations
@@ -37,6 +111,8 @@ type CleanupManager struct {
 	settings       *db.Settings
 	tunnelManager  *cloudflare.TunnelManager // DEPRECATED: for backward compatibility
 	tunnelService  domain.TunnelService      // NEW: provider-agnostic
+	options        CleanupOptions
+	archivePath    string
 	startTime      time.Time
 	results        []CleanupResult
 	operationCount int
@@ -54,18 +130,37 @@ func NewCleanupManager(dockerManager *docker.Manager, database *db.DB, settings
 	}
 }
 
+// SetOptions sets the options used by later CleanupApp calls
+func (cm *CleanupManager) SetOptions(opts CleanupOptions) {
+	cm.options = opts
+}
+
+// ArchivePath returns where the last CleanupApp archived the app directory, or "" if it didn't
+func (cm *CleanupManager) ArchivePath() string {
+	return cm.archivePath
+}
+
 // CleanupApp performs comprehensive cleanup of an app and its dependencies
 func (cm *CleanupManager) CleanupApp(app *db.App) ([]CleanupResult, error) {
 	slog.Info("Starting comprehensive app cleanup", "app", app.Name, "appID", app.ID)
 	cm.startTime = time.Now()
 	cm.results = make([]CleanupResult, 0)
+	cm.archivePath = ""
+	var archiveErr error
+	var keptNetworks []string
 
 	// Define cleanup operations in the correct order (reverse dependency)
 	operations := []CleanupOperation{
 		{
+			ID:   StepContainers,
 			Name: "Stop Docker containers",
 			Executor: func() error {
-				return cm.dockerManager.StopApp(app.Name)
+				err := cm.dockerManager.StopApp(app.Name)
+				if err != nil && cm.options.Force {
+					slog.Warn("Failed to stop Docker containers, force-removing them", "app", app.Name, "error", err)
+					_, err = cm.dockerManager.ForceRemoveAppContainers(app.Name)
+				}
+				return err
 			},
 			OnSuccess: func() {
 				slog.Info("Successfully stopped Docker containers", "app", app.Name)
@@ -79,8 +174,63 @@ func (cm *CleanupManager) CleanupApp(app *db.App) ([]CleanupResult, error) {
 					slog.Warn("Failed to stop Docker containers, continuing anyway", "app", app.Name, "error", err)
 				}
 			},
+			Plan: func() ([]CleanupResource, error) {
+				containers, err := cm.dockerManager.ListAppContainers(app.Name)
+				return resourcesOf(ResourceContainer, containers), err
+			},
 		},
 		{
+			ID:   StepVolumes,
+			Name: "Remove Docker volumes",
+			Executor: func() error {
+				_, err := cm.dockerManager.RemoveAppVolumes(app.Name)
+				return err
+			},
+			OnSuccess: func() {
+				slog.Info("Successfully removed Docker volumes", "app", app.Name)
+			},
+			OnError: func(err error) {
+				slog.Warn("Failed to remove Docker volumes, continuing anyway", "app", app.Name, "error", err)
+			},
+			Plan: func() ([]CleanupResource, error) {
+				volumes, err := cm.dockerManager.ListAppVolumes(app.Name)
+				return resourcesOf(ResourceVolume, volumes), err
+			},
+		},
+		{
+			ID:   StepNetworks,
+			Name: "Remove Docker networks",
+			Executor: func() error {
+				networks, err := cm.appNetworks(app)
+				if err != nil {
+					return err
+				}
+				var gone []string
+				gone, keptNetworks, err = cm.dockerManager.RemoveUnusedNetworks(networks)
+				if untrackErr := cm.database.DeleteAppNetworks(app.Name, gone); untrackErr != nil {
+					slog.Warn("Failed to forget removed networks", "app", app.Name, "error", untrackErr)
+				}
+				return err
+			},
+			OnSuccess: func() {
+				slog.Info("Successfully removed Docker networks", "app", app.Name, "kept", keptNetworks)
+			},
+			OnError: func(err error) {
+				slog.Warn("Failed to remove Docker networks, continuing anyway", "app", app.Name, "error", err)
+			},
+			Detail: func() string {
+				if len(keptNetworks) == 0 {
+					return ""
+				}
+				return "kept networks still in use: " + strings.Join(keptNetworks, ", ")
+			},
+			Plan: func() ([]CleanupResource, error) {
+				networks, err := cm.appNetworks(app)
+				return resourcesOf(ResourceNetwork, networks), err
+			},
+		},
+		{
+			ID:   StepTunnel,
 			Name: "Delete tunnel (provider-agnostic)",
 			Executor: func() error {
 				if app.TunnelID == "" {
@@ -115,10 +265,45 @@ func (cm *CleanupManager) CleanupApp(app *db.App) ([]CleanupResult, error) {
 			OnError: func(err error) {
 				slog.Warn("Failed to delete tunnel, continuing anyway", "app", app.Name, "tunnelID", app.TunnelID, "error", err)
 			},
+			Plan: func() ([]CleanupResource, error) {
+				return cm.planTunnel(app), nil
+			},
 		},
 		{
+			ID:   StepArchive,
+			Name: "Archive app directory",
+			Executor: func() error {
+				if cm.options.ArchiveDir == "" {
+					return nil // Archiving not requested
+				}
+				cm.archivePath, archiveErr = cm.dockerManager.ArchiveAppDirectory(app.Name, cm.options.ArchiveDir)
+				return archiveErr
+			},
+			OnSuccess: func() {
+				if cm.archivePath != "" {
+					slog.Info("Successfully archived app directory", "app", app.Name, "archivePath", cm.archivePath)
+				}
+			},
+			OnError: func(err error) {
+				slog.Error("Failed to archive app directory, keeping it", "app", app.Name, "error", err)
+			},
+			Detail: func() string {
+				return cm.archivePath
+			},
+			Plan: func() ([]CleanupResource, error) {
+				if _, exists := cm.dockerManager.AppDirectory(app.Name); !exists || cm.options.ArchiveDir == "" {
+					return nil, nil
+				}
+				return []CleanupResource{{Kind: ResourceArchive, Name: cm.options.ArchiveDir}}, nil
+			},
+		},
+		{
+			ID:   StepDirectory,
 			Name: "Delete app directory",
 			Executor: func() error {
+				if archiveErr != nil {
+					return fmt.Errorf("kept app directory because archiving it failed")
+				}
 				return cm.dockerManager.DeleteAppDirectory(app.Name)
 			},
 			OnSuccess: func() {
@@ -127,10 +312,22 @@ func (cm *CleanupManager) CleanupApp(app *db.App) ([]CleanupResult, error) {
 			OnError: func(err error) {
 				slog.Error("Failed to delete app directory", "app", app.Name, "error", err)
 			},
+			Plan: func() ([]CleanupResource, error) {
+				appPath, exists := cm.dockerManager.AppDirectory(app.Name)
+				if !exists {
+					return nil, nil
+				}
+				return []CleanupResource{{Kind: ResourceDirectory, Name: appPath}}, nil
+			},
 		},
 		{
+			ID:   StepDatabase,
 			Name: "Delete app from database",
 			Executor: func() error {
+				// The record holds the compose file, the only copy left when the directory couldn't be archived
+				if archiveErr != nil {
+					return fmt.Errorf("kept app record because archiving its directory failed")
+				}
 				return cm.database.DeleteApp(app.ID)
 			},
 			OnSuccess: func() {
@@ -139,32 +336,66 @@ func (cm *CleanupManager) CleanupApp(app *db.App) ([]CleanupResult, error) {
 			OnError: func(err error) {
 				slog.Error("Failed to delete app from database", "app", app.Name, "appID", app.ID, "error", err)
 			},
+			Plan: func() ([]CleanupResource, error) {
+				return []CleanupResource{{Kind: ResourceAppRecord, Name: app.ID}}, nil
+			},
 		},
 	}
 
+	skip := make(map[string]bool, len(cm.options.SkipSteps))
+	for _, id := range cm.options.SkipSteps {
+		skip[id] = true
+	}
+	if !cm.options.RemoveVolumes {
+		skip[StepVolumes] = true
+	}
+
 	// Execute all cleanup operations
 	var lastError error
 	for _, operation := range operations {
 		start := time.Now()
 		result := CleanupResult{
+			StepID:   operation.ID,
 			Step:     operation.Name,
 			Duration: 0,
 		}
 
-		err := operation.Executor()
+		var err error
+		executed := false
+		switch {
+		case skip[operation.ID]:
+			result.Skipped = true
+			slog.Info("Skipping cleanup step", "app", app.Name, "step", operation.Name)
+		case cm.options.DryRun:
+			if operation.Plan != nil {
+				result.Resources, err = operation.Plan()
+			}
+		default:
+			err = operation.Executor()
+			executed = true
+		}
 		result.Duration = time.Since(start)
 		result.Success = err == nil
 
 		if err != nil {
 			result.Error = err.Error()
 			result.ErrorMessage = err.Error()
-			lastError = err
+			if !cm.options.Force || operation.ID == StepDatabase {
+				lastError = err
+			}
+		}
+
+		if executed && operation.Detail != nil {
+			result.Detail = operation.Detail()
 		}
 
 		cm.results = append(cm.results, result)
 		cm.operationCount++
 
 		// Execute callbacks
+		if !executed {
+			continue
+		}
 		if err == nil {
 			if operation.OnSuccess != nil {
 				operation.OnSuccess()
@@ -176,6 +407,13 @@ func (cm *CleanupManager) CleanupApp(app *db.App) ([]CleanupResult, error) {
 		}
 	}
 
+	// Networks kept earlier because they were in use may be free by now
+	if !cm.options.DryRun {
+		if _, err := cm.RemoveOrphanedNetworks(); err != nil {
+			slog.Warn("Failed to remove orphaned networks", "error", err)
+		}
+	}
+
 	// Log summary
 	successCount := 0
 	for _, result := range cm.results {
@@ -187,6 +425,7 @@ func (cm *CleanupManager) CleanupApp(app *db.App) ([]CleanupResult, error) {
 	totalDuration := time.Since(cm.startTime)
 	slog.Info("App cleanup completed",
 		"app", app.Name,
+		"dryRun", cm.options.DryRun,
 		"appID", app.ID,
 		"totalSteps", len(operations),
 		"successSteps", successCount,
@@ -214,6 +453,81 @@ func (cm *CleanupManager) CleanupApp(app *db.App) ([]CleanupResult, error) {
 	return cm.results, nil
 }
 
+// appNetworks returns the networks of the app's compose project together with those recorded for
+// the app, which are still known once compose no longer is
+func (cm *CleanupManager) appNetworks(app *db.App) ([]string, error) {
+	networks, err := cm.dockerManager.ListAppNetworks(app.Name)
+	if err != nil {
+		return nil, err
+	}
+	recorded, err := cm.database.GetAppNetworks(app.Name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get recorded networks: %w", err)
+	}
+	for _, network := range recorded {
+		if !slices.Contains(networks, network) {
+			networks = append(networks, network)
+		}
+	}
+	return networks, nil
+}
+
+// RemoveOrphanedNetworks removes the recorded networks of deleted apps that nothing uses any more
+// and returns their names. Networks still in use stay recorded, to be tried again later.
+func (cm *CleanupManager) RemoveOrphanedNetworks() ([]string, error) {
+	orphans, err := cm.database.GetAppNetworksWithoutApp()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list orphaned networks: %w", err)
+	}
+
+	var removed []string
+	var errs []error
+	for _, orphan := range orphans {
+		gone, _, err := cm.dockerManager.RemoveUnusedNetworks([]string{orphan.Network})
+		if err != nil {
+			errs = append(errs, err)
+		}
+		if len(gone) == 0 {
+			continue
+		}
+		if err := cm.database.DeleteAppNetworks(orphan.AppName, gone); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		slog.Info("Removed orphaned network", "app", orphan.AppName, "network", orphan.Network)
+		removed = append(removed, orphan.Network)
+	}
+	return removed, errors.Join(errs...)
+}
+
+// planTunnel lists the tunnel and the DNS records routed through it
+func (cm *CleanupManager) planTunnel(app *db.App) []CleanupResource {
+	if app.TunnelID == "" {
+		return nil
+	}
+	resources := []CleanupResource{{Kind: ResourceTunnel, Name: app.TunnelID}}
+
+	tunnel, err := cm.database.GetCloudflareTunnelByAppID(app.ID)
+	if err != nil || tunnel.IngressRules == nil {
+		return resources
+	}
+	for _, rule := range *tunnel.IngressRules {
+		if rule.Hostname != nil && *rule.Hostname != "" {
+			resources = append(resources, CleanupResource{Kind: ResourceDNSRecord, Name: *rule.Hostname})
+		}
+	}
+	return resources
+}
+
+// resourcesOf wraps names as resources of one kind
+func resourcesOf(kind string, names []string) []CleanupResource {
+	resources := make([]CleanupResource, 0, len(names))
+	for _, name := range names {
+		resources = append(resources, CleanupResource{Kind: kind, Name: name})
+	}
+	return resources
+}
+
 // GetResults returns the cleanup results
 func (cm *CleanupManager) GetResults() []CleanupResult {
 	return cm.results
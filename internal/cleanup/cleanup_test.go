@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"net/http"
 	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/selfhostly/internal/cloudflare"
@@ -364,3 +365,291 @@ func TestCleanupManager_GetResults(t *testing.T) {
 		}
 	}
 }
+
+func TestCleanupManager_CleanupApp_Archive(t *testing.T) {
+	mockExecutor := docker.NewMockCommandExecutor()
+	manager, database, cleanup := setupTestCleanupManager(t, mockExecutor, nil)
+	defer cleanup()
+
+	app := db.NewApp("archived-app", "Test application", "version: '3'\nservices:\n  web:\n    image: nginx:latest")
+	if err := database.CreateApp(app); err != nil {
+		t.Fatalf("Failed to create app: %v", err)
+	}
+	if err := manager.dockerManager.CreateAppDirectory(app.Name, app.ComposeContent); err != nil {
+		t.Fatalf("Failed to create app directory: %v", err)
+	}
+
+	trashDir := t.TempDir()
+	manager.SetOptions(CleanupOptions{ArchiveDir: trashDir})
+
+	results, err := manager.CleanupApp(app)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	archivePath := manager.ArchivePath()
+	if archivePath == "" || filepath.Dir(archivePath) != trashDir {
+		t.Fatalf("Expected an archive in %s, got %q", trashDir, archivePath)
+	}
+	if _, err := os.Stat(archivePath); err != nil {
+		t.Errorf("Expected archive to exist: %v", err)
+	}
+
+	var reported bool
+	for _, result := range results {
+		if result.Step == "Archive app directory" && result.Detail == archivePath {
+			reported = true
+		}
+	}
+	if !reported {
+		t.Errorf("Expected the archive step to report %s, got %+v", archivePath, results)
+	}
+
+	// Archiving returns no path once the directory is gone
+	if path, err := manager.dockerManager.ArchiveAppDirectory(app.Name, trashDir); err != nil || path != "" {
+		t.Errorf("Expected the app directory to be deleted after archiving, got path=%q err=%v", path, err)
+	}
+}
+
+func TestCleanupManager_CleanupApp_ArchiveFails(t *testing.T) {
+	mockExecutor := docker.NewMockCommandExecutor()
+	manager, database, cleanup := setupTestCleanupManager(t, mockExecutor, nil)
+	defer cleanup()
+
+	app := db.NewApp("unarchived-app", "Test application", "version: '3'\nservices:\n  web:\n    image: nginx:latest")
+	if err := database.CreateApp(app); err != nil {
+		t.Fatalf("Failed to create app: %v", err)
+	}
+	if err := manager.dockerManager.CreateAppDirectory(app.Name, app.ComposeContent); err != nil {
+		t.Fatalf("Failed to create app directory: %v", err)
+	}
+
+	// A file where the trash directory should be makes the archive fail
+	trashDir := filepath.Join(t.TempDir(), "trash")
+	if err := os.WriteFile(trashDir, nil, 0644); err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
+	manager.SetOptions(CleanupOptions{ArchiveDir: trashDir})
+
+	if _, err := manager.CleanupApp(app); err == nil {
+		t.Fatal("Expected an error when archiving fails")
+	}
+	if _, exists := manager.dockerManager.AppDirectory(app.Name); !exists {
+		t.Error("Expected the app directory to be kept")
+	}
+	if _, err := database.GetApp(app.ID); err != nil {
+		t.Errorf("Expected the app record to be kept, got %v", err)
+	}
+}
+
+func TestCleanupManager_CleanupApp_DryRun(t *testing.T) {
+	mockExecutor := docker.NewMockCommandExecutor()
+	manager, database, cleanup := setupTestCleanupManager(t, mockExecutor, nil)
+	defer cleanup()
+
+	app := db.NewApp("dry-run-app", "Test application", "version: '3'\nservices:\n  web:\n    image: nginx:latest")
+	if err := database.CreateApp(app); err != nil {
+		t.Fatalf("Failed to create app: %v", err)
+	}
+	if err := manager.dockerManager.CreateAppDirectory(app.Name, app.ComposeContent); err != nil {
+		t.Fatalf("Failed to create app directory: %v", err)
+	}
+
+	mockExecutor.SetMockOutput("docker", docker.DockerProjectContainersCommand("dry-run-app")[1:], []byte("dry-run-app-web-1\n"))
+	mockExecutor.SetMockOutput("docker", docker.DockerProjectNetworksCommand("dry-run-app")[1:], []byte("dry-run-app_default\n"))
+	mockExecutor.SetMockOutput("docker", docker.DockerProjectVolumesCommand("dry-run-app")[1:], []byte("dry-run-app_data\n"))
+	if err := database.RecordAppNetworks(app.Name, []string{"dry-run-app_default", "dry-run-app_old"}); err != nil {
+		t.Fatalf("Failed to record networks: %v", err)
+	}
+
+	manager.SetOptions(CleanupOptions{DryRun: true, SkipSteps: []string{StepTunnel}})
+	results, err := manager.CleanupApp(app)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	resources := make(map[string][]CleanupResource)
+	for _, result := range results {
+		if (result.StepID == StepVolumes || result.StepID == StepTunnel) && !result.Skipped {
+			t.Errorf("Expected the %s step to be skipped, got %+v", result.StepID, result)
+		}
+		resources[result.StepID] = result.Resources
+	}
+	wantContainers := []CleanupResource{{ResourceContainer, "dry-run-app-web-1"}}
+	if fmt.Sprint(resources[StepContainers]) != fmt.Sprint(wantContainers) {
+		t.Errorf("Expected container plan %v, got %v", wantContainers, resources[StepContainers])
+	}
+	wantNetworks := []CleanupResource{{ResourceNetwork, "dry-run-app_default"}, {ResourceNetwork, "dry-run-app_old"}}
+	if fmt.Sprint(resources[StepNetworks]) != fmt.Sprint(wantNetworks) {
+		t.Errorf("Expected network plan %v, got %v", wantNetworks, resources[StepNetworks])
+	}
+	if len(resources[StepVolumes]) != 0 {
+		t.Errorf("Expected no volumes listed unless they are removed, got %v", resources[StepVolumes])
+	}
+	if len(resources[StepDirectory]) != 1 || resources[StepDirectory][0].Kind != ResourceDirectory {
+		t.Errorf("Expected the app directory in the plan, got %v", resources[StepDirectory])
+	}
+
+	// Nothing may change in a dry run
+	if mockExecutor.AssertCommandExecuted("docker", []string{"compose", "-f", "docker-compose.yml", "down"}) {
+		t.Error("Expected docker compose down not to run in a dry run")
+	}
+	if _, exists := manager.dockerManager.AppDirectory(app.Name); !exists {
+		t.Error("Expected the app directory to be kept in a dry run")
+	}
+	if _, err := database.GetApp(app.ID); err != nil {
+		t.Errorf("Expected the app to be kept in a dry run: %v", err)
+	}
+}
+
+func TestCleanupManager_CleanupApp_KeepsVolumes(t *testing.T) {
+	mockExecutor := docker.NewMockCommandExecutor()
+	manager, database, cleanup := setupTestCleanupManager(t, mockExecutor, nil)
+	defer cleanup()
+
+	app := db.NewApp("keep-volumes", "Test application", "version: '3'\nservices:\n  web:\n    image: nginx:latest")
+	if err := database.CreateApp(app); err != nil {
+		t.Fatalf("Failed to create app: %v", err)
+	}
+
+	if _, err := manager.CleanupApp(app); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if mockExecutor.GetCommandCount("docker", docker.DockerProjectVolumesCommand("keep-volumes")[1:]) != 0 {
+		t.Error("Expected volumes not to be touched unless their removal is asked for")
+	}
+	if _, err := database.GetApp(app.ID); err == nil {
+		t.Error("Expected app to be deleted from database")
+	}
+}
+
+func TestCleanupManager_CleanupApp_RemoveVolumes(t *testing.T) {
+	mockExecutor := docker.NewMockCommandExecutor()
+	manager, database, cleanup := setupTestCleanupManager(t, mockExecutor, nil)
+	defer cleanup()
+
+	app := db.NewApp("remove-volumes", "Test application", "version: '3'\nservices:\n  web:\n    image: nginx:latest")
+	if err := database.CreateApp(app); err != nil {
+		t.Fatalf("Failed to create app: %v", err)
+	}
+
+	manager.SetOptions(CleanupOptions{RemoveVolumes: true})
+	results, err := manager.CleanupApp(app)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	for _, result := range results {
+		if result.StepID == StepVolumes && result.Skipped {
+			t.Errorf("Expected the volumes step to run, got %+v", result)
+		}
+	}
+	if mockExecutor.GetCommandCount("docker", docker.DockerProjectVolumesCommand("remove-volumes")[1:]) == 0 {
+		t.Error("Expected the app's volumes to be listed for removal")
+	}
+}
+
+func TestValidateSkipSteps(t *testing.T) {
+	if err := ValidateSkipSteps([]string{StepNetworks, StepTunnel}); err != nil {
+		t.Errorf("Expected skippable steps to be accepted, got %v", err)
+	}
+	for _, step := range []string{StepDatabase, StepVolumes, "bogus"} {
+		if err := ValidateSkipSteps([]string{step}); err == nil {
+			t.Errorf("Expected skipping %q to be rejected", step)
+		}
+	}
+}
+
+func TestCleanupManager_CleanupApp_Force(t *testing.T) {
+	mockExecutor := docker.NewMockCommandExecutor()
+	manager, database, cleanup := setupTestCleanupManager(t, mockExecutor, nil)
+	defer cleanup()
+
+	app := db.NewApp("stuck-app", "Test application", "version: '3'\nservices:\n  web:\n    image: nginx:latest")
+	if err := database.CreateApp(app); err != nil {
+		t.Fatalf("Failed to create app: %v", err)
+	}
+	if err := manager.dockerManager.CreateAppDirectory(app.Name, app.ComposeContent); err != nil {
+		t.Fatalf("Failed to create app directory: %v", err)
+	}
+
+	mockExecutor.SetMockError("docker", []string{"compose", "-f", "docker-compose.yml", "down"}, fmt.Errorf("container won't stop"))
+	mockExecutor.SetMockOutput("docker", docker.DockerProjectContainersCommand("stuck-app")[1:], []byte("stuck-app-web-1\n"))
+	mockExecutor.SetMockOutput("docker", docker.DockerProjectVolumesCommand("stuck-app")[1:], []byte("stuck-app_data\n"))
+	mockExecutor.SetMockError("docker", docker.DockerVolumeRmCommand("stuck-app_data")[1:], fmt.Errorf("volume is in use"))
+
+	manager.SetOptions(CleanupOptions{Force: true, RemoveVolumes: true})
+	results, err := manager.CleanupApp(app)
+	if err != nil {
+		t.Fatalf("Expected force cleanup to succeed once the app record is gone, got %v", err)
+	}
+
+	if !mockExecutor.AssertCommandExecuted("docker", docker.DockerRmCommand("stuck-app-web-1")[1:]) {
+		t.Error("Expected the stuck container to be force-removed")
+	}
+	var volumesFailed bool
+	for _, result := range results {
+		if result.StepID == StepVolumes && !result.Success {
+			volumesFailed = true
+		}
+	}
+	if !volumesFailed {
+		t.Errorf("Expected the failed volumes step to still be reported, got %+v", results)
+	}
+	if _, err := database.GetApp(app.ID); err == nil {
+		t.Error("Expected app to be deleted from database")
+	}
+}
+
+func TestCleanupManager_CleanupApp_Networks(t *testing.T) {
+	mockExecutor := docker.NewMockCommandExecutor()
+	manager, database, cleanup := setupTestCleanupManager(t, mockExecutor, nil)
+	defer cleanup()
+
+	app := db.NewApp("net-app", "Test application", "version: '3'\nservices:\n  web:\n    image: nginx:latest")
+	if err := database.CreateApp(app); err != nil {
+		t.Fatalf("Failed to create app: %v", err)
+	}
+	// The compose file is gone, so only the recorded networks are known; an app deleted earlier
+	// left one behind that is free by now
+	if err := database.RecordAppNetworks(app.Name, []string{"net-app_default", "net-app_shared"}); err != nil {
+		t.Fatalf("Failed to record networks: %v", err)
+	}
+	if err := database.RecordAppNetworks("old-app", []string{"old-app_default"}); err != nil {
+		t.Fatalf("Failed to record networks: %v", err)
+	}
+
+	mockExecutor.SetMockOutput("docker", docker.DockerProjectNetworksCommand("net-app")[1:], []byte(""))
+	mockExecutor.SetMockOutput("docker", docker.DockerNetworkContainersCommand("net-app_default")[1:], []byte(""))
+	mockExecutor.SetMockOutput("docker", docker.DockerNetworkContainersCommand("net-app_shared")[1:], []byte("other-web-1\n"))
+	mockExecutor.SetMockOutput("docker", docker.DockerNetworkContainersCommand("old-app_default")[1:], []byte(""))
+
+	results, err := manager.CleanupApp(app)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	for _, network := range []string{"net-app_default", "old-app_default"} {
+		if !mockExecutor.AssertCommandExecuted("docker", docker.DockerNetworkRmCommand(network)[1:]) {
+			t.Errorf("Expected %s to be removed", network)
+		}
+	}
+	if mockExecutor.AssertCommandExecuted("docker", docker.DockerNetworkRmCommand("net-app_shared")[1:]) {
+		t.Error("Expected the network still in use to be kept")
+	}
+	for _, result := range results {
+		if result.StepID == StepNetworks && result.Detail != "kept networks still in use: net-app_shared" {
+			t.Errorf("Expected the kept network in the step detail, got %+v", result)
+		}
+	}
+
+	// The kept network stays recorded as an orphan, to be removed once it is free
+	orphans, err := database.GetAppNetworksWithoutApp()
+	if err != nil {
+		t.Fatalf("GetAppNetworksWithoutApp: %v", err)
+	}
+	if len(orphans) != 1 || orphans[0].Network != "net-app_shared" {
+		t.Errorf("Expected only the kept network to be left, got %+v", orphans)
+	}
+}
@@ -0,0 +1,267 @@
+package cloudflare
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/selfhostly/internal/db"
+)
+
+// zoneTrafficQuery reads HTTP request analytics for a set of hostnames in one zone, by hostname
+// and by the top 10 client countries and response statuses
+const zoneTrafficQuery = `query ZoneTraffic($zoneTag: string, $filter: ZoneHttpRequestsAdaptiveGroupsFilter_InputObject) {
+  viewer {
+    zones(filter: {zoneTag: $zoneTag}) {
+      hosts: httpRequestsAdaptiveGroups(limit: 100, filter: $filter) {
+        count
+        sum { edgeResponseBytes visits }
+        dimensions { clientRequestHTTPHost }
+      }
+      countries: httpRequestsAdaptiveGroups(limit: 10, filter: $filter, orderBy: [count_DESC]) {
+        count
+        dimensions { clientCountryName }
+      }
+      statuses: httpRequestsAdaptiveGroups(limit: 10, filter: $filter, orderBy: [count_DESC]) {
+        count
+        dimensions { edgeResponseStatus }
+      }
+    }
+  }
+}`
+
+// maxTrafficGroups caps the countries and status codes GetIngressTraffic returns across zones
+const maxTrafficGroups = 10
+
+// Traffic is the HTTP traffic Cloudflare's edge served for a set of hostnames
+type Traffic struct {
+	Hosts       []HostTraffic
+	Countries   []TrafficGroup // Top client countries by requests
+	StatusCodes []TrafficGroup // Top response status codes by requests
+	Untracked   []string       // Hostnames without analytics: wildcards and hostnames in zones the token can't see
+}
+
+// HostTraffic is the traffic one hostname received
+type HostTraffic struct {
+	Hostname string
+	Requests int64
+	Bytes    int64 // Bytes served to clients
+	Visits   int64 // Requests that started a visit (no referer from the same site)
+}
+
+// TrafficGroup is the number of requests sharing one dimension value, e.g. a country
+type TrafficGroup struct {
+	Key      string
+	Requests int64
+}
+
+// GetZoneTraffic returns the requests, bandwidth and visits for hostnames in a zone between since
+// and until, with the top client countries and response statuses. The API token needs the Zone
+// Analytics read permission, and the zone's plan limits how far back the range may reach.
+func (m *Manager) GetZoneTraffic(ctx context.Context, zoneID string, hostnames []string, since, until time.Time) (*Traffic, error) {
+	variables := map[string]interface{}{
+		"zoneTag": zoneID,
+		"filter": map[string]interface{}{
+			"datetime_geq":             since.UTC().Format(time.RFC3339),
+			"datetime_lt":              until.UTC().Format(time.RFC3339),
+			"clientRequestHTTPHost_in": hostnames,
+		},
+	}
+	var data struct {
+		Viewer struct {
+			Zones []struct {
+				Hosts []struct {
+					Count int64 `json:"count"`
+					Sum   struct {
+						EdgeResponseBytes int64 `json:"edgeResponseBytes"`
+						Visits            int64 `json:"visits"`
+					} `json:"sum"`
+					Dimensions struct {
+						Host string `json:"clientRequestHTTPHost"`
+					} `json:"dimensions"`
+				} `json:"hosts"`
+				Countries []struct {
+					Count      int64 `json:"count"`
+					Dimensions struct {
+						Country string `json:"clientCountryName"`
+					} `json:"dimensions"`
+				} `json:"countries"`
+				Statuses []struct {
+					Count      int64 `json:"count"`
+					Dimensions struct {
+						Status int `json:"edgeResponseStatus"`
+					} `json:"dimensions"`
+				} `json:"statuses"`
+			} `json:"zones"`
+		} `json:"viewer"`
+	}
+	if err := m.postGraphQL(ctx, zoneTrafficQuery, variables, &data); err != nil {
+		return nil, fmt.Errorf("failed to get zone traffic: %w", err)
+	}
+
+	traffic := &Traffic{}
+	for _, zone := range data.Viewer.Zones {
+		for _, h := range zone.Hosts {
+			traffic.Hosts = append(traffic.Hosts, HostTraffic{Hostname: h.Dimensions.Host, Requests: h.Count, Bytes: h.Sum.EdgeResponseBytes, Visits: h.Sum.Visits})
+		}
+		for _, c := range zone.Countries {
+			traffic.Countries = append(traffic.Countries, TrafficGroup{Key: c.Dimensions.Country, Requests: c.Count})
+		}
+		for _, s := range zone.Statuses {
+			traffic.StatusCodes = append(traffic.StatusCodes, TrafficGroup{Key: strconv.Itoa(s.Dimensions.Status), Requests: s.Count})
+		}
+	}
+	return traffic, nil
+}
+
+// GetIngressTraffic returns the traffic Cloudflare served for the hostnames in a tunnel's ingress
+// rules between since and until, querying each zone they belong to. Hosts are sorted busiest first.
+func (m *Manager) GetIngressTraffic(ctx context.Context, rules []db.IngressRule, since, until time.Time) (*Traffic, error) {
+	traffic := &Traffic{Hosts: []HostTraffic{}, Countries: []TrafficGroup{}, StatusCodes: []TrafficGroup{}, Untracked: []string{}}
+	var hostnames []string
+	for _, rule := range rules {
+		if rule.Hostname == nil || *rule.Hostname == "" || slices.Contains(hostnames, *rule.Hostname) {
+			continue
+		}
+		hostnames = append(hostnames, *rule.Hostname)
+	}
+	if len(hostnames) == 0 {
+		return traffic, nil
+	}
+
+	zones, err := m.ListZones(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var zoneIDs []string
+	zoneHostnames := make(map[string][]string)
+	for _, hostname := range hostnames {
+		zone, ok := ZoneForHostname(zones, hostname)
+		if !ok || strings.Contains(hostname, "*") {
+			traffic.Untracked = append(traffic.Untracked, hostname)
+			continue
+		}
+		if _, seen := zoneHostnames[zone.ID]; !seen {
+			zoneIDs = append(zoneIDs, zone.ID)
+		}
+		zoneHostnames[zone.ID] = append(zoneHostnames[zone.ID], hostname)
+	}
+
+	countries := make(map[string]int64)
+	statusCodes := make(map[string]int64)
+	for _, zoneID := range zoneIDs {
+		zoneTraffic, err := m.GetZoneTraffic(ctx, zoneID, zoneHostnames[zoneID], since, until)
+		if err != nil {
+			return nil, err
+		}
+		traffic.Hosts = append(traffic.Hosts, zoneTraffic.Hosts...)
+		for _, group := range zoneTraffic.Countries {
+			countries[group.Key] += group.Requests
+		}
+		for _, group := range zoneTraffic.StatusCodes {
+			statusCodes[group.Key] += group.Requests
+		}
+	}
+	slices.SortFunc(traffic.Hosts, func(a, b HostTraffic) int {
+		return cmp.Compare(b.Requests, a.Requests)
+	})
+	traffic.Countries = topTrafficGroups(countries)
+	traffic.StatusCodes = topTrafficGroups(statusCodes)
+	return traffic, nil
+}
+
+// topTrafficGroups returns the keys with the most requests, busiest first
+func topTrafficGroups(requests map[string]int64) []TrafficGroup {
+	groups := make([]TrafficGroup, 0, len(requests))
+	for key, n := range requests {
+		groups = append(groups, TrafficGroup{Key: key, Requests: n})
+	}
+	slices.SortFunc(groups, func(a, b TrafficGroup) int {
+		if c := cmp.Compare(b.Requests, a.Requests); c != 0 {
+			return c
+		}
+		return strings.Compare(a.Key, b.Key)
+	})
+	if len(groups) > maxTrafficGroups {
+		groups = groups[:maxTrafficGroups]
+	}
+	return groups
+}
+
+// postGraphQL runs a query against Cloudflare's GraphQL analytics API and decodes its data into
+// out. GraphQL reports query errors with HTTP 200, so the response's errors are checked too.
+func (m *Manager) postGraphQL(ctx context.Context, query string, variables map[string]interface{}, out interface{}) error {
+	payload, err := json.Marshal(map[string]interface{}{"query": query, "variables": variables})
+	if err != nil {
+		return fmt.Errorf("failed to marshal query: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", apiBaseURL+"/graphql", bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+m.config.APIToken)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := m.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("cloudflare API unreachable: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+	var respData struct {
+		Data   json.RawMessage `json:"data"`
+		Errors []struct {
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+	if err := json.Unmarshal(body, &respData); err != nil {
+		return fmt.Errorf("failed to unmarshal response (HTTP %d): %w", resp.StatusCode, err)
+	}
+	if len(respData.Errors) > 0 {
+		messages := make([]string, 0, len(respData.Errors))
+		for _, e := range respData.Errors {
+			messages = append(messages, e.Message)
+		}
+		return fmt.Errorf("cloudflare GraphQL error (HTTP %d): %s", resp.StatusCode, strings.Join(messages, "; "))
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("cloudflare GraphQL error (HTTP %d)", resp.StatusCode)
+	}
+	if len(respData.Data) == 0 || string(respData.Data) == "null" {
+		return nil
+	}
+	if err := json.Unmarshal(respData.Data, out); err != nil {
+		return fmt.Errorf("failed to unmarshal data: %w", err)
+	}
+	return nil
+}
+
+// ZoneForHostname returns the zone a hostname belongs to: the zone with the longest name the
+// hostname equals or ends in
+func ZoneForHostname(zones []Zone, hostname string) (Zone, bool) {
+	hostname = strings.ToLower(strings.TrimSuffix(hostname, "."))
+	var best Zone
+	found := false
+	for _, zone := range zones {
+		name := strings.ToLower(zone.Name)
+		if hostname != name && !strings.HasSuffix(hostname, "."+name) {
+			continue
+		}
+		if !found || len(name) > len(best.Name) {
+			best, found = zone, true
+		}
+	}
+	return best, found
+}
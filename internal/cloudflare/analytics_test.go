@@ -0,0 +1,144 @@
+package cloudflare
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/selfhostly/internal/db"
+)
+
+const (
+	testZonesURL   = "https://api.cloudflare.com/client/v4/zones?per_page=50&page=1"
+	testGraphQLURL = "https://api.cloudflare.com/client/v4/graphql"
+)
+
+func TestZoneForHostname(t *testing.T) {
+	zones := []Zone{{ID: "z1", Name: "example.com"}, {ID: "z2", Name: "dev.example.com"}, {ID: "z3", Name: "example.org"}}
+	tests := map[string]string{
+		"example.com":          "z1",
+		"blog.example.com":     "z1",
+		"api.dev.example.com":  "z2",
+		"Shop.Example.ORG.":    "z3",
+		"notexample.com":       "",
+		"example.com.evil.net": "",
+	}
+	for hostname, want := range tests {
+		zone, ok := ZoneForHostname(zones, hostname)
+		if (want == "") == ok || zone.ID != want {
+			t.Errorf("ZoneForHostname(%q) = %q, %v; want %q", hostname, zone.ID, ok, want)
+		}
+	}
+}
+
+func TestGetIngressTraffic(t *testing.T) {
+	mockClient := NewMockHTTPClient()
+	manager := NewManagerWithClient("test-token", "test-account", mockClient)
+
+	mockClient.SetJSONMockResponse(testZonesURL, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"result":  []Zone{{ID: "zone-1", Name: "example.com"}},
+	})
+	mockClient.SetJSONMockResponse(testGraphQLURL, http.StatusOK, map[string]interface{}{
+		"data": map[string]interface{}{"viewer": map[string]interface{}{"zones": []interface{}{map[string]interface{}{
+			"hosts": []interface{}{
+				map[string]interface{}{"count": 40, "sum": map[string]interface{}{"edgeResponseBytes": 4096, "visits": 5}, "dimensions": map[string]interface{}{"clientRequestHTTPHost": "api.example.com"}},
+				map[string]interface{}{"count": 120, "sum": map[string]interface{}{"edgeResponseBytes": 20480, "visits": 30}, "dimensions": map[string]interface{}{"clientRequestHTTPHost": "blog.example.com"}},
+			},
+			"countries": []interface{}{
+				map[string]interface{}{"count": 100, "dimensions": map[string]interface{}{"clientCountryName": "DE"}},
+				map[string]interface{}{"count": 60, "dimensions": map[string]interface{}{"clientCountryName": "US"}},
+			},
+			"statuses": []interface{}{
+				map[string]interface{}{"count": 150, "dimensions": map[string]interface{}{"edgeResponseStatus": 200}},
+				map[string]interface{}{"count": 10, "dimensions": map[string]interface{}{"edgeResponseStatus": 404}},
+			},
+		}}}},
+		"errors": nil,
+	})
+
+	hostname := func(h string) *string { return &h }
+	rules := []db.IngressRule{
+		{Hostname: hostname("blog.example.com"), Service: "http://web:80"},
+		{Hostname: hostname("api.example.com"), Service: "http://api:8080"},
+		{Hostname: hostname("blog.example.com"), Path: hostname("/admin"), Service: "http://admin:80"},
+		{Hostname: hostname("*.example.com"), Service: "http://web:80"},
+		{Hostname: hostname("shop.example.org"), Service: "http://shop:80"},
+		{Service: "http_status:404"},
+	}
+	since := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	traffic, err := manager.GetIngressTraffic(context.Background(), rules, since, since.Add(24*time.Hour))
+	if err != nil {
+		t.Fatalf("GetIngressTraffic: %v", err)
+	}
+
+	if len(traffic.Hosts) != 2 || traffic.Hosts[0].Hostname != "blog.example.com" || traffic.Hosts[0].Bytes != 20480 {
+		t.Errorf("Expected blog.example.com first by requests, got %+v", traffic.Hosts)
+	}
+	if len(traffic.Countries) != 2 || traffic.Countries[0].Key != "DE" || traffic.Countries[0].Requests != 100 {
+		t.Errorf("Expected DE as the top country, got %+v", traffic.Countries)
+	}
+	if len(traffic.StatusCodes) != 2 || traffic.StatusCodes[0].Key != "200" {
+		t.Errorf("Expected 200 as the top status, got %+v", traffic.StatusCodes)
+	}
+	if strings.Join(traffic.Untracked, ",") != "*.example.com,shop.example.org" {
+		t.Errorf("Expected the wildcard and the unknown zone to be untracked, got %v", traffic.Untracked)
+	}
+
+	// The query is scoped to the zone, the time range and the zone's distinct hostnames
+	var req struct {
+		Variables struct {
+			ZoneTag string `json:"zoneTag"`
+			Filter  struct {
+				Since     string   `json:"datetime_geq"`
+				Until     string   `json:"datetime_lt"`
+				Hostnames []string `json:"clientRequestHTTPHost_in"`
+			} `json:"filter"`
+		} `json:"variables"`
+	}
+	if err := json.Unmarshal([]byte(mockClient.GetRequestBody("POST", testGraphQLURL)), &req); err != nil {
+		t.Fatalf("Failed to decode GraphQL request: %v", err)
+	}
+	if req.Variables.ZoneTag != "zone-1" || req.Variables.Filter.Since != "2026-01-01T00:00:00Z" || req.Variables.Filter.Until != "2026-01-02T00:00:00Z" {
+		t.Errorf("Unexpected query variables: %+v", req.Variables)
+	}
+	if strings.Join(req.Variables.Filter.Hostnames, ",") != "blog.example.com,api.example.com" {
+		t.Errorf("Expected the zone's distinct hostnames, got %v", req.Variables.Filter.Hostnames)
+	}
+}
+
+func TestGetIngressTraffic_GraphQLError(t *testing.T) {
+	mockClient := NewMockHTTPClient()
+	manager := NewManagerWithClient("test-token", "test-account", mockClient)
+
+	mockClient.SetJSONMockResponse(testZonesURL, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"result":  []Zone{{ID: "zone-1", Name: "example.com"}},
+	})
+	mockClient.SetJSONMockResponse(testGraphQLURL, http.StatusOK, map[string]interface{}{
+		"data":   nil,
+		"errors": []interface{}{map[string]interface{}{"message": "not authorized for that account"}},
+	})
+
+	hostname := "blog.example.com"
+	_, err := manager.GetIngressTraffic(context.Background(), []db.IngressRule{{Hostname: &hostname, Service: "http://web:80"}}, time.Now().Add(-time.Hour), time.Now())
+	if err == nil || !strings.Contains(err.Error(), "not authorized") {
+		t.Errorf("Expected the GraphQL error to be returned, got %v", err)
+	}
+}
+
+func TestGetIngressTraffic_NoHostnames(t *testing.T) {
+	mockClient := NewMockHTTPClient()
+	manager := NewManagerWithClient("test-token", "test-account", mockClient)
+
+	traffic, err := manager.GetIngressTraffic(context.Background(), []db.IngressRule{{Service: "http://web:80"}}, time.Now().Add(-time.Hour), time.Now())
+	if err != nil {
+		t.Fatalf("GetIngressTraffic: %v", err)
+	}
+	if len(traffic.Hosts) != 0 || len(mockClient.GetRecordedRequests()) != 0 {
+		t.Errorf("Expected no traffic and no API calls without hostnames, got %+v", traffic)
+	}
+}
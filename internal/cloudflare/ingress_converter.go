@@ -1,6 +1,8 @@
 package cloudflare
 
 import (
+	"strings"
+
 	"github.com/selfhostly/internal/db"
 )
 
@@ -57,3 +59,14 @@ func EnsureCatchAllRule(rules []IngressRule) []IngressRule {
 	// Append catch-all rule
 	return append(rules, IngressRule{Service: "http_status:404"})
 }
+
+// StripCatchAllRule removes a trailing catch-all 404 rule, the inverse of EnsureCatchAllRule
+func StripCatchAllRule(rules []IngressRule) []IngressRule {
+	if len(rules) > 0 {
+		last := rules[len(rules)-1]
+		if last.Hostname == "" && last.Path == "" && strings.HasPrefix(last.Service, "http_status:") {
+			return rules[:len(rules)-1]
+		}
+	}
+	return rules
+}
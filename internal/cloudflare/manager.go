@@ -1,6 +1,7 @@
 package cloudflare
 
 import (
+	"context"
 	"fmt"
 	"log/slog"
 	"strings"
@@ -139,6 +140,39 @@ func (tm *TunnelManager) DeleteTunnelByAppID(appID string) error {
 	return nil
 }
 
+// ImportTunnel records an existing account tunnel as appID's tunnel, fetching its connector token
+// and remotely managed ingress rules. The public URL is the first ingress hostname, falling back
+// to the tunnel's cfargotunnel.com address. Fails with ErrNotFound if the account has no such tunnel.
+func (tm *TunnelManager) ImportTunnel(ctx context.Context, tunnelID, appID string) (*db.CloudflareTunnel, error) {
+	accountTunnel, err := tm.ApiManager.GetAccountTunnel(ctx, tunnelID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tunnel: %w", err)
+	}
+	token, err := tm.ApiManager.FetchTunnelToken(ctx, tunnelID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tunnel token: %w", err)
+	}
+	cfRules, err := tm.ApiManager.GetIngressConfiguration(ctx, tunnelID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get tunnel ingress: %w", err)
+	}
+
+	ingressRules := ConvertFromCloudflareRules(StripCatchAllRule(cfRules))
+	publicURL := fmt.Sprintf("https://%s.cfargotunnel.com", tunnelID)
+	if len(ingressRules) > 0 && ingressRules[0].Hostname != nil && *ingressRules[0].Hostname != "" {
+		publicURL = fmt.Sprintf("https://%s", *ingressRules[0].Hostname)
+	}
+
+	cfTunnel := db.NewCloudflareTunnel(appID, tunnelID, accountTunnel.Name, token, tm.ApiManager.config.AccountID, publicURL)
+	if len(ingressRules) > 0 {
+		cfTunnel.IngressRules = &ingressRules
+	}
+	if err := tm.database.CreateCloudflareTunnel(cfTunnel); err != nil {
+		return nil, fmt.Errorf("failed to store tunnel metadata: %w", err)
+	}
+	return cfTunnel, nil
+}
+
 // GetAllActiveTunnels retrieves all active tunnels
 func (tm *TunnelManager) GetAllActiveTunnels() ([]*db.CloudflareTunnel, error) {
 	return tm.database.ListActiveCloudflareTunnels()
@@ -12,12 +12,13 @@ import (
 	"time"
 
 	"github.com/selfhostly/internal/constants"
+	"github.com/selfhostly/internal/platform"
 )
 
 // ExtractQuickTunnelURL fetches the cloudflared metrics endpoint and parses it
 // to find the generated trycloudflare.com URL. Retries until the URL appears or maxRetries is reached.
 // metricsEndpoint should be the full URL, e.g. "http://localhost:2000/metrics".
-// If the endpoint uses localhost and fails, it will try alternative hosts (host.docker.internal, 172.17.0.1).
+// If the endpoint uses localhost and fails, it will try the OS-appropriate Docker host addresses.
 // The context is used to cancel the operation and respect deadlines.
 func ExtractQuickTunnelURL(ctx context.Context, metricsEndpoint string, maxRetries int, interval time.Duration) (string, error) {
 	if maxRetries <= 0 {
@@ -38,10 +39,9 @@ func ExtractQuickTunnelURL(ctx context.Context, metricsEndpoint string, maxRetri
 				port = strings.Split(parts[2], "/")[0]
 			}
 		}
-		// Try host.docker.internal (Mac/Windows Docker) and Docker bridge gateway (Linux)
-		alternativeEndpoints = []string{
-			fmt.Sprintf("http://%s:%s/metrics", constants.DockerHostInternal, port),
-			fmt.Sprintf("http://%s:%s/metrics", constants.DockerBridgeGateway, port),
+		// Try host gateway addresses for this OS (bridge gateway on Linux, host.docker.internal on Docker Desktop)
+		for _, host := range platform.HostGatewayAddresses() {
+			alternativeEndpoints = append(alternativeEndpoints, fmt.Sprintf("http://%s:%s/metrics", host, port))
 		}
 	}
 
@@ -2,6 +2,7 @@ package cloudflare
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -9,12 +10,16 @@ import (
 	"log/slog"
 	"net/http"
 	"strings"
+	"time"
 )
 
 const (
 	apiBaseURL = "https://api.cloudflare.com/client/v4"
 )
 
+// ErrNotFound is returned when the Cloudflare API reports that a resource doesn't exist
+var ErrNotFound = errors.New("not found in cloudflare")
+
 // APICredentials holds Cloudflare API credentials
 type APICredentials struct {
 	APIToken  string
@@ -683,12 +688,279 @@ type ListTunnelsResponse struct {
 		Code    int    `json:"code"`
 		Message string `json:"message"`
 	} `json:"errors"`
-	Messages []string `json:"messages"`
-	Result   []struct {
-		ID     string `json:"id"`
-		Name   string `json:"name"`
-		Status string `json:"status"`
-	} `json:"result"`
+	Messages   []string        `json:"messages"`
+	Result     []AccountTunnel `json:"result"`
+	ResultInfo struct {
+		Page       int `json:"page"`
+		PerPage    int `json:"per_page"`
+		TotalPages int `json:"total_pages"`
+		Count      int `json:"count"`
+	} `json:"result_info"`
+}
+
+// AccountTunnel is a tunnel as listed in the Cloudflare account
+type AccountTunnel struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	Status    string    `json:"status"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// accountTunnelsPageSize is the page size used when listing every tunnel in the account
+const accountTunnelsPageSize = 100
+
+// ListAccountTunnels returns every tunnel in the account that hasn't been deleted,
+// following pagination until the last page
+func (m *Manager) ListAccountTunnels(ctx context.Context) ([]AccountTunnel, error) {
+	var tunnels []AccountTunnel
+	for page := 1; ; page++ {
+		url := fmt.Sprintf("%s/accounts/%s/cfd_tunnel?is_deleted=false&per_page=%d&page=%d", apiBaseURL, m.config.AccountID, accountTunnelsPageSize, page)
+
+		req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create request: %w", err)
+		}
+
+		req.Header.Set("Authorization", "Bearer "+m.config.APIToken)
+
+		resp, err := m.client.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list tunnels: %w", err)
+		}
+
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read response: %w", err)
+		}
+
+		var respData ListTunnelsResponse
+		if err := json.Unmarshal(body, &respData); err != nil {
+			return nil, fmt.Errorf("failed to unmarshal response (HTTP %d): %w", resp.StatusCode, err)
+		}
+
+		if !respData.Success {
+			return nil, fmt.Errorf("cloudflare API error (HTTP %d): %v", resp.StatusCode, respData.Errors)
+		}
+
+		tunnels = append(tunnels, respData.Result...)
+		if len(respData.Result) < accountTunnelsPageSize || page >= respData.ResultInfo.TotalPages {
+			return tunnels, nil
+		}
+	}
+}
+
+// getAPIResult GETs a Cloudflare API URL and decodes the response's result into out, failing
+// when the API reports an error
+func (m *Manager) getAPIResult(ctx context.Context, url string, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Authorization", "Bearer "+m.config.APIToken)
+
+	resp, err := m.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("cloudflare API unreachable: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+
+	var respData struct {
+		Success bool `json:"success"`
+		Errors  []struct {
+			Code    int    `json:"code"`
+			Message string `json:"message"`
+		} `json:"errors"`
+		Result json.RawMessage `json:"result"`
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		return fmt.Errorf("%w: %s", ErrNotFound, strings.TrimSpace(string(body)))
+	}
+	if err := json.Unmarshal(body, &respData); err != nil {
+		return fmt.Errorf("failed to unmarshal response (HTTP %d): %w", resp.StatusCode, err)
+	}
+	if !respData.Success {
+		return fmt.Errorf("cloudflare API error (HTTP %d): %v", resp.StatusCode, respData.Errors)
+	}
+	if len(respData.Result) == 0 || string(respData.Result) == "null" {
+		return nil
+	}
+	if err := json.Unmarshal(respData.Result, out); err != nil {
+		return fmt.Errorf("failed to unmarshal result: %w", err)
+	}
+	return nil
+}
+
+// GetAccountTunnel fetches a single tunnel from the account by ID
+func (m *Manager) GetAccountTunnel(ctx context.Context, tunnelID string) (*AccountTunnel, error) {
+	url := fmt.Sprintf("%s/accounts/%s/cfd_tunnel/%s", apiBaseURL, m.config.AccountID, tunnelID)
+	var t AccountTunnel
+	if err := m.getAPIResult(ctx, url, &t); err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// FetchTunnelToken fetches a tunnel's connector token from its token endpoint. Unlike
+// GetTunnelToken this also works for tunnels that weren't created through this API client.
+func (m *Manager) FetchTunnelToken(ctx context.Context, tunnelID string) (string, error) {
+	url := fmt.Sprintf("%s/accounts/%s/cfd_tunnel/%s/token", apiBaseURL, m.config.AccountID, tunnelID)
+	var token string
+	if err := m.getAPIResult(ctx, url, &token); err != nil {
+		return "", err
+	}
+	if token == "" {
+		return "", fmt.Errorf("tunnel token is empty in response")
+	}
+	return token, nil
+}
+
+// GetIngressConfiguration fetches a tunnel's remotely managed ingress rules. Tunnels configured
+// with a local config file have none.
+func (m *Manager) GetIngressConfiguration(ctx context.Context, tunnelID string) ([]IngressRule, error) {
+	url := fmt.Sprintf("%s/accounts/%s/cfd_tunnel/%s/configurations", apiBaseURL, m.config.AccountID, tunnelID)
+	var result struct {
+		Config *TunnelConfig `json:"config"`
+	}
+	if err := m.getAPIResult(ctx, url, &result); err != nil {
+		return nil, err
+	}
+	if result.Config == nil {
+		return nil, nil
+	}
+	return result.Config.Ingress, nil
+}
+
+// Zone is a DNS zone the API token can see
+type Zone struct {
+	ID     string `json:"id"`
+	Name   string `json:"name"`
+	Status string `json:"status"`
+}
+
+// DNSRecord is a DNS record in a zone
+type DNSRecord struct {
+	ID      string `json:"id"`
+	Type    string `json:"type"`
+	Name    string `json:"name"`
+	Content string `json:"content"`
+	Proxied bool   `json:"proxied"`
+}
+
+const (
+	zonesPageSize      = 50
+	dnsRecordsPageSize = 100
+)
+
+// ListZones returns every zone the API token can read
+func (m *Manager) ListZones(ctx context.Context) ([]Zone, error) {
+	var zones []Zone
+	for page := 1; ; page++ {
+		url := fmt.Sprintf("%s/zones?per_page=%d&page=%d", apiBaseURL, zonesPageSize, page)
+		var batch []Zone
+		if err := m.getAPIResult(ctx, url, &batch); err != nil {
+			return nil, fmt.Errorf("failed to list zones: %w", err)
+		}
+		zones = append(zones, batch...)
+		if len(batch) < zonesPageSize {
+			return zones, nil
+		}
+	}
+}
+
+// ListDNSRecords returns every DNS record in a zone
+func (m *Manager) ListDNSRecords(ctx context.Context, zoneID string) ([]DNSRecord, error) {
+	var records []DNSRecord
+	for page := 1; ; page++ {
+		url := fmt.Sprintf("%s/zones/%s/dns_records?per_page=%d&page=%d", apiBaseURL, zoneID, dnsRecordsPageSize, page)
+		var batch []DNSRecord
+		if err := m.getAPIResult(ctx, url, &batch); err != nil {
+			return nil, fmt.Errorf("failed to list DNS records: %w", err)
+		}
+		records = append(records, batch...)
+		if len(batch) < dnsRecordsPageSize {
+			return records, nil
+		}
+	}
+}
+
+// TunnelConnector is a cloudflared instance running a tunnel's token, as the connections API
+// reports it
+type TunnelConnector struct {
+	ID          string             `json:"id"`
+	Version     string             `json:"version"`
+	Arch        string             `json:"arch"`
+	RunAt       time.Time          `json:"run_at"`
+	Connections []TunnelConnection `json:"conns"`
+}
+
+// TunnelConnection is one of a connector's connections to a Cloudflare edge location
+type TunnelConnection struct {
+	ID                 string    `json:"id"`
+	ColoName           string    `json:"colo_name"`
+	OriginIP           string    `json:"origin_ip"`
+	OpenedAt           time.Time `json:"opened_at"`
+	IsPendingReconnect bool      `json:"is_pending_reconnect"`
+}
+
+// ListConnections returns the connectors of a tunnel that are connected to Cloudflare's edge, with
+// their connections. A tunnel no cloudflared runs has none.
+func (m *Manager) ListConnections(ctx context.Context, tunnelID string) ([]TunnelConnector, error) {
+	url := fmt.Sprintf("%s/accounts/%s/cfd_tunnel/%s/connections", apiBaseURL, m.config.AccountID, tunnelID)
+	var connectors []TunnelConnector
+	if err := m.getAPIResult(ctx, url, &connectors); err != nil {
+		return nil, fmt.Errorf("failed to list tunnel connections: %w", err)
+	}
+	return connectors, nil
+}
+
+// TunnelIDFromTarget returns the tunnel a CNAME target routes to, or "" when the target is not
+// a tunnel
+func TunnelIDFromTarget(target string) string {
+	if id, ok := strings.CutSuffix(target, ".cfargotunnel.com"); ok {
+		return id
+	}
+	return ""
+}
+
+// VerifyCredentials checks the API is reachable and the token can read the account's tunnels
+func (m *Manager) VerifyCredentials(ctx context.Context) error {
+	url := fmt.Sprintf("%s/accounts/%s/cfd_tunnel?per_page=1", apiBaseURL, m.config.AccountID)
+
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+
+	req.Header.Set("Authorization", "Bearer "+m.config.APIToken)
+
+	resp, err := m.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("cloudflare API unreachable: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read response: %w", err)
+	}
+
+	var respData ListTunnelsResponse
+	if err := json.Unmarshal(body, &respData); err != nil {
+		return fmt.Errorf("failed to unmarshal response (HTTP %d): %w", resp.StatusCode, err)
+	}
+
+	if !respData.Success {
+		return fmt.Errorf("cloudflare API error (HTTP %d): %v", resp.StatusCode, respData.Errors)
+	}
+
+	return nil
 }
 
 // findTunnelIDByName finds a tunnel ID by its name (internal helper)
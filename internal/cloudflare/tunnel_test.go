@@ -1,7 +1,10 @@
 package cloudflare
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
 	"testing"
 )
@@ -221,3 +224,122 @@ func TestDeleteTunnel(t *testing.T) {
 		t.Error("Expected DELETE request to delete tunnel")
 	}
 }
+
+func TestListAccountTunnels_Paginates(t *testing.T) {
+	mockClient := NewMockHTTPClient()
+	manager := NewManagerWithClient("test-token", "test-account", mockClient)
+
+	firstPage := make([]AccountTunnel, accountTunnelsPageSize)
+	for i := range firstPage {
+		firstPage[i] = AccountTunnel{ID: fmt.Sprintf("tunnel-%d", i), Name: fmt.Sprintf("app-%d", i), Status: "healthy"}
+	}
+	pages := map[int][]AccountTunnel{
+		1: firstPage,
+		2: {{ID: "tunnel-last", Name: "app-last", Status: "down"}},
+	}
+	for page, result := range pages {
+		mockClient.SetJSONMockResponse(
+			fmt.Sprintf("https://api.cloudflare.com/client/v4/accounts/test-account/cfd_tunnel?is_deleted=false&per_page=100&page=%d", page),
+			http.StatusOK,
+			map[string]interface{}{
+				"success":     true,
+				"result":      result,
+				"result_info": map[string]int{"page": page, "per_page": 100, "total_pages": 2},
+			},
+		)
+	}
+
+	tunnels, err := manager.ListAccountTunnels(context.Background())
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(tunnels) != accountTunnelsPageSize+1 || tunnels[len(tunnels)-1].ID != "tunnel-last" {
+		t.Errorf("Expected both pages of tunnels, got %d", len(tunnels))
+	}
+	if len(mockClient.GetRecordedRequests()) != 2 {
+		t.Errorf("Expected 2 list requests, got %d", len(mockClient.GetRecordedRequests()))
+	}
+}
+
+func TestListConnections(t *testing.T) {
+	mockClient := NewMockHTTPClient()
+	manager := NewManagerWithClient("test-token", "test-account", mockClient)
+	url := "https://api.cloudflare.com/client/v4/accounts/test-account/cfd_tunnel/tunnel-123/connections"
+
+	mockClient.SetJSONMockResponse(url, http.StatusOK, map[string]interface{}{
+		"success": true,
+		"result": []map[string]interface{}{{
+			"id":      "connector-1",
+			"version": "2024.6.1",
+			"run_at":  "2024-06-01T10:00:00Z",
+			"conns": []map[string]interface{}{
+				{"id": "conn-1", "colo_name": "ams01", "origin_ip": "203.0.113.5", "opened_at": "2024-06-01T10:00:02Z"},
+				{"id": "conn-2", "colo_name": "fra02", "is_pending_reconnect": true},
+			},
+		}},
+	})
+
+	connectors, err := manager.ListConnections(context.Background(), "tunnel-123")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(connectors) != 1 || len(connectors[0].Connections) != 2 {
+		t.Fatalf("Expected one connector with two connections, got %+v", connectors)
+	}
+	if conn := connectors[0].Connections[0]; conn.ColoName != "ams01" || conn.OpenedAt.IsZero() || conn.IsPendingReconnect {
+		t.Errorf("Unexpected first connection %+v", conn)
+	}
+	if !connectors[0].Connections[1].IsPendingReconnect {
+		t.Error("Expected the second connection to be pending reconnect")
+	}
+
+	// A tunnel nothing runs has no connectors
+	mockClient.SetJSONMockResponse(url, http.StatusOK, map[string]interface{}{"success": true, "result": []interface{}{}})
+	if connectors, err := manager.ListConnections(context.Background(), "tunnel-123"); err != nil || len(connectors) != 0 {
+		t.Errorf("Expected no connectors, got %+v (err %v)", connectors, err)
+	}
+}
+
+func TestImportedTunnelDetails(t *testing.T) {
+	mockClient := NewMockHTTPClient()
+	manager := NewManagerWithClient("test-token", "test-account", mockClient)
+	base := "https://api.cloudflare.com/client/v4/accounts/test-account/cfd_tunnel/existing-tunnel"
+
+	mockClient.SetJSONMockResponse(base+"/token", http.StatusOK, map[string]interface{}{
+		"success": true,
+		"result":  "connector-token",
+	})
+	mockClient.SetJSONMockResponse(base+"/configurations", http.StatusOK, map[string]interface{}{
+		"success": true,
+		"result": map[string]interface{}{
+			"config": map[string]interface{}{
+				"ingress": []map[string]string{
+					{"hostname": "blog.example.com", "service": "http://blog:80"},
+					{"service": "http_status:404"},
+				},
+			},
+		},
+	})
+
+	token, err := manager.FetchTunnelToken(context.Background(), "existing-tunnel")
+	if err != nil || token != "connector-token" {
+		t.Fatalf("Expected connector token, got %q (err %v)", token, err)
+	}
+
+	rules, err := manager.GetIngressConfiguration(context.Background(), "existing-tunnel")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	rules = StripCatchAllRule(rules)
+	if len(rules) != 1 || rules[0].Hostname != "blog.example.com" {
+		t.Errorf("Expected only the blog rule after stripping the catch-all, got %+v", rules)
+	}
+
+	mockClient.SetJSONMockResponse(base, http.StatusNotFound, map[string]interface{}{
+		"success": false,
+		"errors":  []map[string]interface{}{{"code": 1003, "message": "tunnel not found"}},
+	})
+	if _, err := manager.GetAccountTunnel(context.Background(), "existing-tunnel"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Expected ErrNotFound for a missing tunnel, got %v", err)
+	}
+}
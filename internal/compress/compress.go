@@ -0,0 +1,228 @@
+// Package compress gzips or deflates HTTP responses for clients that accept it. It is shared by
+// the server and the gateway, and only touches text-like responses (JSON, logs, the web UI)
+// large enough to gain from it.
+package compress
+
+import (
+	"bufio"
+	"compress/gzip"
+	"compress/zlib"
+	"errors"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// MinSize is the smallest response body worth compressing; smaller bodies are sent as they are
+const MinSize = 1024
+
+// Handler compresses next's responses with gzip or deflate, as negotiated with the request's
+// Accept-Encoding. Responses that are already encoded, event streams, and bodies under MinSize
+// pass through untouched.
+func Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		encoding := negotiate(req.Header.Get("Accept-Encoding"))
+		if encoding == "" || req.Method == http.MethodHead || req.Header.Get("Upgrade") != "" {
+			next.ServeHTTP(w, req)
+			return
+		}
+
+		cw := &responseWriter{ResponseWriter: w, encoding: encoding}
+		defer cw.close()
+		next.ServeHTTP(cw, req)
+	})
+}
+
+// negotiate picks gzip or deflate from an Accept-Encoding header, preferring gzip when both are
+// equally acceptable, or returns "" for neither
+func negotiate(header string) string {
+	best, bestQ := "", 0.0
+	for _, part := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name != "gzip" && name != "deflate" || q <= 0 {
+			continue
+		}
+		if q > bestQ || q == bestQ && name == "gzip" {
+			best, bestQ = name, q
+		}
+	}
+	return best
+}
+
+// compressible reports whether a response with this Content-Type is worth compressing
+func compressible(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	switch {
+	case mediaType == "text/event-stream":
+		// Events must reach the client as they are written
+		return false
+	case strings.HasPrefix(mediaType, "text/"),
+		mediaType == "application/json",
+		strings.HasSuffix(mediaType, "+json"),
+		mediaType == "application/x-ndjson",
+		mediaType == "application/javascript",
+		mediaType == "image/svg+xml":
+		return true
+	}
+	return false
+}
+
+// responseWriter holds back the start of the body until it knows whether to compress it
+type responseWriter struct {
+	http.ResponseWriter
+	encoding string
+
+	status  int
+	buf     []byte
+	decided bool           // whether the headers have been sent, compressed or not
+	enc     io.WriteCloser // set once the body is being compressed
+}
+
+func (w *responseWriter) WriteHeader(status int) {
+	if w.decided || w.status != 0 {
+		return
+	}
+	w.status = status
+	if !w.eligible() {
+		w.passThrough()
+	}
+}
+
+func (w *responseWriter) Write(p []byte) (int, error) {
+	if w.status == 0 {
+		w.WriteHeader(http.StatusOK)
+	}
+	if !w.decided {
+		if w.Header().Get("Content-Type") == "" {
+			w.Header().Set("Content-Type", http.DetectContentType(append(w.buf, p...)))
+			if !w.eligible() {
+				w.passThrough()
+				return w.ResponseWriter.Write(p)
+			}
+		}
+		w.buf = append(w.buf, p...)
+		if len(w.buf) < MinSize {
+			return len(p), nil
+		}
+		if err := w.startCompressing(); err != nil {
+			return 0, err
+		}
+		return len(p), nil
+	}
+	if w.enc != nil {
+		return w.enc.Write(p)
+	}
+	return w.ResponseWriter.Write(p)
+}
+
+// Flush sends what has been written so far, compressing it if the response qualifies
+func (w *responseWriter) Flush() {
+	if !w.decided && w.status != 0 {
+		if len(w.buf) > 0 && w.eligible() {
+			_ = w.startCompressing()
+		} else {
+			w.passThrough()
+		}
+	}
+	if f, ok := w.enc.(interface{ Flush() error }); ok {
+		_ = f.Flush()
+	}
+	_ = http.NewResponseController(w.ResponseWriter).Flush()
+}
+
+// Hijack hands over the connection when nothing has been sent yet
+func (w *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if w.decided || len(w.buf) > 0 {
+		return nil, nil, errors.New("compress: response already started")
+	}
+	return http.NewResponseController(w.ResponseWriter).Hijack()
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer, e.g. to set deadlines
+func (w *responseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// eligible reports whether the response headers allow compressing the body
+func (w *responseWriter) eligible() bool {
+	h := w.Header()
+	switch {
+	case w.status < http.StatusOK, w.status == http.StatusNoContent, w.status == http.StatusNotModified:
+		return false
+	case w.status == http.StatusPartialContent, h.Get("Content-Range") != "":
+		// A range of the original bytes can't be compressed on its own
+		return false
+	}
+	if h.Get("Content-Encoding") != "" {
+		return false
+	}
+	if n, err := strconv.Atoi(h.Get("Content-Length")); err == nil && n < MinSize {
+		return false
+	}
+	contentType := h.Get("Content-Type")
+	return contentType == "" || compressible(contentType)
+}
+
+// passThrough sends the headers and anything buffered as they are
+func (w *responseWriter) passThrough() {
+	if w.decided {
+		return
+	}
+	w.decided = true
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	w.ResponseWriter.WriteHeader(w.status)
+	if len(w.buf) > 0 {
+		_, _ = w.ResponseWriter.Write(w.buf)
+		w.buf = nil
+	}
+}
+
+func (w *responseWriter) startCompressing() error {
+	w.decided = true
+	h := w.Header()
+	h.Del("Content-Length")
+	h.Set("Content-Encoding", w.encoding)
+	if !slices.Contains(h.Values("Vary"), "Accept-Encoding") {
+		h.Add("Vary", "Accept-Encoding")
+	}
+	w.ResponseWriter.WriteHeader(w.status)
+
+	if w.encoding == "gzip" {
+		w.enc = gzip.NewWriter(w.ResponseWriter)
+	} else {
+		// HTTP's deflate coding is zlib-wrapped (RFC 9110), not a raw deflate stream
+		w.enc = zlib.NewWriter(w.ResponseWriter)
+	}
+	_, err := w.enc.Write(w.buf)
+	w.buf = nil
+	return err
+}
+
+// close finishes the response: a body that stayed under MinSize is sent uncompressed
+func (w *responseWriter) close() {
+	if w.enc != nil {
+		_ = w.enc.Close()
+		return
+	}
+	if !w.decided && w.status != 0 {
+		w.passThrough()
+	}
+}
@@ -0,0 +1,127 @@
+package compress
+
+import (
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func serve(t *testing.T, h http.Handler, acceptEncoding string) *httptest.ResponseRecorder {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodGet, "/api/apps", nil)
+	if acceptEncoding != "" {
+		req.Header.Set("Accept-Encoding", acceptEncoding)
+	}
+	w := httptest.NewRecorder()
+	Handler(h).ServeHTTP(w, req)
+	return w
+}
+
+func jsonHandler(body string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = io.WriteString(w, body)
+	})
+}
+
+func TestNegotiate(t *testing.T) {
+	for header, want := range map[string]string{
+		"":                          "",
+		"br":                        "",
+		"gzip, deflate, br":         "gzip",
+		"deflate":                   "deflate",
+		"gzip;q=0.5, deflate":       "deflate",
+		"gzip;q=0, deflate;q=0":     "",
+		"GZIP":                      "gzip",
+		"deflate;q=0.8, gzip;q=0.8": "gzip",
+	} {
+		if got := negotiate(header); got != want {
+			t.Errorf("negotiate(%q) = %q, want %q", header, got, want)
+		}
+	}
+}
+
+func TestHandler_Compresses(t *testing.T) {
+	body := `{"apps":"` + strings.Repeat("a", 4*MinSize) + `"}`
+
+	w := serve(t, jsonHandler(body), "gzip")
+	if w.Header().Get("Content-Encoding") != "gzip" || w.Header().Get("Vary") != "Accept-Encoding" {
+		t.Fatalf("expected a gzipped response, got headers %v", w.Header())
+	}
+	zr, err := gzip.NewReader(w.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := io.ReadAll(zr); string(got) != body {
+		t.Errorf("gzip round trip changed the body")
+	}
+
+	w = serve(t, jsonHandler(body), "deflate")
+	if w.Header().Get("Content-Encoding") != "deflate" {
+		t.Fatalf("expected a deflated response, got headers %v", w.Header())
+	}
+	zr2, err := zlib.NewReader(w.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := io.ReadAll(zr2); string(got) != body {
+		t.Errorf("deflate round trip changed the body")
+	}
+}
+
+func TestHandler_PassesThrough(t *testing.T) {
+	large := strings.Repeat("a", 4*MinSize)
+	for name, tc := range map[string]struct {
+		handler        http.Handler
+		acceptEncoding string
+		want           string
+	}{
+		"not accepted": {jsonHandler(large), "", large},
+		"small body":   {jsonHandler(`{"ok":true}`), "gzip", `{"ok":true}`},
+		"binary":       {typed("application/gzip", large), "gzip", large},
+		"event stream": {typed("text/event-stream", large), "gzip", large},
+		"already encoded": {http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("Content-Encoding", "br")
+			_, _ = io.WriteString(w, large)
+		}), "gzip", large},
+	} {
+		w := serve(t, tc.handler, tc.acceptEncoding)
+		if enc := w.Header().Get("Content-Encoding"); enc == "gzip" {
+			t.Errorf("%s: expected no compression", name)
+		}
+		if w.Body.String() != tc.want {
+			t.Errorf("%s: body changed", name)
+		}
+	}
+}
+
+func TestHandler_Flush(t *testing.T) {
+	w := serve(t, http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		_, _ = io.WriteString(w, "first line\n")
+		http.NewResponseController(w).Flush()
+		_, _ = io.WriteString(w, strings.Repeat("log line\n", MinSize))
+	}), "gzip")
+
+	// The flushed line is compressed too, so the whole body is one gzip stream
+	zr, err := gzip.NewReader(w.Body)
+	if err != nil {
+		t.Fatalf("expected a gzipped response: %v", err)
+	}
+	got, _ := io.ReadAll(zr)
+	if !strings.HasPrefix(string(got), "first line\nlog line\n") {
+		t.Errorf("unexpected body %.40q", got)
+	}
+}
+
+func typed(contentType, body string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", contentType)
+		_, _ = io.WriteString(w, body)
+	})
+}
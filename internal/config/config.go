@@ -5,10 +5,22 @@ import (
 	"encoding/base64"
 	"fmt"
 	"log/slog"
+	"net/netip"
 	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/google/uuid"
+	"github.com/joho/godotenv"
+	"github.com/selfhostly/internal/chaos"
+	"github.com/selfhostly/internal/constants"
+	"github.com/selfhostly/internal/diskguard"
+	"github.com/selfhostly/internal/features"
+	"github.com/selfhostly/internal/secretstore"
+	"github.com/selfhostly/internal/timeouts"
 )
 
 // Config holds the application configuration
@@ -18,12 +30,75 @@ type Config struct {
 	AppsDir       string
 	Environment   string // development, staging, production
 	LogJSON       bool   // Whether to use JSON logging format (defaults based on environment if not set)
+	LogLevel      string // debug, info, warn or error; empty uses the environment's default
 	Cloudflare    CloudflareConfig
 	Auth          AuthConfig
 	AutoStart     bool
 	CORS          CORSConfig
 	Node          NodeConfig
 	Security      SecurityConfig
+
+	// ReconcileStatusOnRead corrects stale running/stopped statuses against docker when apps are read
+	ReconcileStatusOnRead bool
+
+	// Timeouts bounds inter-node requests per operation class (reads, logs, container updates, image pulls).
+	// It is shared with every node client and replaced in place on a config reload.
+	Timeouts *timeouts.Live
+
+	// TelemetryEndpoint receives opt-in usage reports; nothing is sent while it is empty
+	TelemetryEndpoint string
+
+	// FeatureOverrides pins feature flags from FEATURE_<NAME> env vars, taking precedence over settings
+	FeatureOverrides map[features.Flag]bool
+
+	// DiskGuard is the free space below which image pulls and app creation warn or are refused
+	DiskGuard diskguard.Config
+
+	// SecretStores are where vault://, op:// and sops:// values in app .env templates are resolved
+	// on this node when the .env is written
+	SecretStores secretstore.Config
+
+	// BaseDomain is the domain app .env templates get as {{ .BaseDomain }}, e.g. example.com
+	BaseDomain string
+
+	// TrashDir holds archives of deleted app directories; TrashTTL is how long they are kept
+	TrashDir string
+	TrashTTL time.Duration
+
+	// ResponseCompression gzips or deflates JSON, log and web UI responses for clients that accept it
+	ResponseCompression bool
+
+	// LogShipping copies this node's own server logs to the primary or a Loki endpoint
+	LogShipping LogShippingConfig
+
+	// StorageRoots are extra directories apps can be created under, by name, e.g. an SSD and an
+	// HDD (STORAGE_ROOTS=ssd=/mnt/ssd/apps,hdd=/mnt/hdd/apps). AppsDir is always the "default" root.
+	StorageRoots map[string]string
+
+	// Approvals makes app deletion, tunnel deletion and node removal wait for a second confirmation
+	Approvals ApprovalConfig
+
+	// Chaos replaces docker and the tunnel provider with simulated ones (CHAOS_MODE); never in production
+	Chaos chaos.Config
+}
+
+// ApprovalConfig configures the two-step approval of destructive operations
+type ApprovalConfig struct {
+	Required bool // APPROVAL_REQUIRED; off by default
+
+	// SelfConfirmDelay is how long the admin who requested an operation waits before confirming it
+	// themselves; anyone else can confirm it straight away
+	SelfConfirmDelay time.Duration
+
+	// TTL is how long a request waits for confirmation before it expires
+	TTL time.Duration
+}
+
+// LogShippingConfig configures shipping of selfhostly's own logs (not app logs) off the node
+type LogShippingConfig struct {
+	Destination string // "primary" (secondaries only) or "loki"; empty disables shipping
+	LokiURL     string // Loki server the "loki" destination pushes to
+	Level       string // Lowest level shipped; info when empty
 }
 
 // NodeConfig holds node-specific configuration for multi-node support
@@ -57,6 +132,11 @@ type AuthConfig struct {
 	GitHub       GitHubOAuthConfig
 	SecureCookie bool
 	BaseURL      string // Base URL for OAuth callbacks (when behind gateway, primary rewrites redirects using X-Forwarded-Host)
+
+	// TrustedProxies are the addresses whose X-Forwarded-Host and X-Forwarded-Proto headers are
+	// believed when building links users open, such as invite and status page links. Links from
+	// requests of anyone else use BaseURL.
+	TrustedProxies []netip.Prefix
 }
 
 // GitHubOAuthConfig holds GitHub OAuth configuration
@@ -72,6 +152,28 @@ type SecurityConfig struct {
 	// This whitelist overrides the default security restrictions for specific trusted paths
 	// Example: /home/user/Documents/apps,/mnt/backup
 	AllowedVolumePaths []string
+
+	// BindMountPolicy is "denylist" (default): apps may bind-mount any host path except protected
+	// ones (the Docker socket, /, /etc, ...) and /home. "allowlist" only permits the app directory
+	// and paths under AllowedVolumePaths.
+	BindMountPolicy string
+
+	// AllowedProtectedMounts lists protected host paths apps may bind-mount anyway, each matched
+	// exactly, e.g. /var/run/docker.sock for a reverse proxy that watches containers
+	AllowedProtectedMounts []string
+
+	// PinImageDigests resolves image tags to digests after each deploy and records them
+	// on the current compose version so rollbacks can report exactly what was running
+	PinImageDigests bool
+
+	// RequireSignedRequests refuses requests authenticated with the gateway or a node API key
+	// unless they carry a valid signature. Signed requests are verified either way. It also makes
+	// this node's own requests leave the API key out, authenticated by their signature alone.
+	RequireSignedRequests bool
+
+	// RefuseModifiedCompose refuses to start or update an app whose docker-compose.yml was edited
+	// on disk outside of its compose versions, instead of only logging a warning
+	RefuseModifiedCompose bool
 }
 
 // Load loads configuration from environment variables with defaults
@@ -88,6 +190,21 @@ func Load() (*Config, error) {
 		return nil, fmt.Errorf("JWT_SECRET environment variable is required when AUTH_ENABLED is true")
 	}
 
+	trustedProxies, err := parseTrustedProxies(os.Getenv("TRUSTED_PROXIES"))
+	if err != nil {
+		return nil, err
+	}
+
+	storageRoots, err := parseStorageRoots(os.Getenv("STORAGE_ROOTS"))
+	if err != nil {
+		return nil, err
+	}
+
+	bindMountPolicy := strings.ToLower(getEnv("BIND_MOUNT_POLICY", constants.BindMountPolicyDenylist))
+	if bindMountPolicy != constants.BindMountPolicyDenylist && bindMountPolicy != constants.BindMountPolicyAllowlist {
+		return nil, fmt.Errorf("BIND_MOUNT_POLICY must be %s or %s", constants.BindMountPolicyDenylist, constants.BindMountPolicyAllowlist)
+	}
+
 	// Node configuration
 	nodeID := getEnv("NODE_ID", "")
 	if nodeID == "" || nodeID == "auto" {
@@ -129,7 +246,12 @@ func Load() (*Config, error) {
 	}
 
 	environment := getEnv("APP_ENV", "production")
-	
+
+	chaosConfig := chaos.LoadFromEnv()
+	if chaosConfig.Enabled && environment == "production" {
+		return nil, fmt.Errorf("CHAOS_MODE simulates docker and tunnels and cannot be used with APP_ENV=production")
+	}
+
 	// Determine JSON logging preference
 	// If LOG_JSON is explicitly set, use it; otherwise default based on environment
 	logJSONEnv := getEnv("LOG_JSON", "")
@@ -141,21 +263,25 @@ func Load() (*Config, error) {
 		logJSON = environment != "development"
 	}
 
+	databasePath := getEnv("DATABASE_PATH", "./data/selfhostly.db")
+
 	cfg := &Config{
 		ServerAddress: getEnv("SERVER_ADDRESS", ":8080"),
-		DatabasePath:  getEnv("DATABASE_PATH", "./data/selfhostly.db"),
+		DatabasePath:  databasePath,
 		AppsDir:       getEnv("APPS_DIR", "./apps"),
 		Environment:   environment,
 		LogJSON:       logJSON,
+		LogLevel:      os.Getenv("LOG_LEVEL"),
 		Cloudflare: CloudflareConfig{
 			APIToken:  os.Getenv("CLOUDFLARE_API_TOKEN"),
 			AccountID: os.Getenv("CLOUDFLARE_ACCOUNT_ID"),
 		},
 		Auth: AuthConfig{
-			Enabled:      authEnabled,
-			JWTSecret:    jwtSecret,
-			SecureCookie: getEnv("AUTH_SECURE_COOKIE", "false") == "true",
-			BaseURL:      authBaseURL,
+			Enabled:        authEnabled,
+			JWTSecret:      jwtSecret,
+			SecureCookie:   getEnv("AUTH_SECURE_COOKIE", "false") == "true",
+			BaseURL:        authBaseURL,
+			TrustedProxies: trustedProxies,
 			GitHub: GitHubOAuthConfig{
 				ClientID:     os.Getenv("GITHUB_CLIENT_ID"),
 				ClientSecret: os.Getenv("GITHUB_CLIENT_SECRET"),
@@ -179,12 +305,88 @@ func Load() (*Config, error) {
 		},
 		Security: SecurityConfig{
 			AllowedVolumePaths: parseCommaSeparatedList(os.Getenv("ALLOWED_VOLUME_PATHS")),
+			PinImageDigests:    getEnv("PIN_IMAGE_DIGESTS", "false") == "true",
+
+			BindMountPolicy:        bindMountPolicy,
+			AllowedProtectedMounts: parseCommaSeparatedList(os.Getenv("ALLOWED_PROTECTED_MOUNTS")),
+
+			RequireSignedRequests: getEnv("REQUIRE_SIGNED_REQUESTS", "false") == "true",
+			RefuseModifiedCompose: getEnv("REFUSE_MODIFIED_COMPOSE", "false") == "true",
+		},
+		ReconcileStatusOnRead: getEnv("RECONCILE_STATUS_ON_READ", "false") == "true",
+		Timeouts:              timeouts.NewLive(timeouts.LoadFromEnv()),
+		TelemetryEndpoint:     os.Getenv("TELEMETRY_ENDPOINT"),
+		FeatureOverrides:      features.LoadEnvOverrides(),
+		DiskGuard:             diskguard.LoadFromEnv(),
+		SecretStores:          secretstore.LoadFromEnv(),
+		BaseDomain:            os.Getenv("BASE_DOMAIN"),
+		TrashDir:              getEnv("TRASH_DIR", filepath.Join(filepath.Dir(databasePath), "trash")),
+		TrashTTL:              time.Duration(getEnvInt("TRASH_TTL_HOURS", 168)) * time.Hour,
+		ResponseCompression:   getEnv("RESPONSE_COMPRESSION", "true") == "true",
+		LogShipping: LogShippingConfig{
+			Destination: strings.ToLower(os.Getenv("LOG_SHIPPING")),
+			LokiURL:     os.Getenv("LOG_SHIPPING_LOKI_URL"),
+			Level:       os.Getenv("LOG_SHIPPING_LEVEL"),
+		},
+		StorageRoots: storageRoots,
+		Approvals: ApprovalConfig{
+			Required:         getEnv("APPROVAL_REQUIRED", "false") == "true",
+			SelfConfirmDelay: time.Duration(getEnvInt("APPROVAL_SELF_CONFIRM_DELAY_MINUTES", 15)) * time.Minute,
+			TTL:              time.Duration(getEnvInt("APPROVAL_TTL_HOURS", 24)) * time.Hour,
 		},
+		Chaos: chaosConfig,
 	}
 
 	return cfg, nil
 }
 
+// EnvFile returns the env file read at startup and on reload: ENV_FILE, or .env
+func EnvFile() string {
+	return getEnv("ENV_FILE", ".env")
+}
+
+// ReloadEnvFile re-reads the env file into the process environment. Unlike the startup load it
+// overrides variables that are already set, so edits to the file take effect.
+func ReloadEnvFile() error {
+	return godotenv.Overload(EnvFile())
+}
+
+// Reloadable holds the settings that can change while the server is running. Everything else in
+// Config is read once at startup and needs a restart.
+type Reloadable struct {
+	LogLevel string
+	Timeouts timeouts.Config
+}
+
+// LoadReloadable re-reads the reload-safe settings from the environment
+func LoadReloadable() Reloadable {
+	return Reloadable{
+		LogLevel: os.Getenv("LOG_LEVEL"),
+		Timeouts: timeouts.LoadFromEnv(),
+	}
+}
+
+// ApplyReloadable copies r into c and returns the environment variables whose values changed.
+// Callers serialize reloads; the timeouts are swapped atomically for clients already using them.
+func (c *Config) ApplyReloadable(r Reloadable) []string {
+	changed := []string{}
+	if r.LogLevel != c.LogLevel {
+		c.LogLevel = r.LogLevel
+		changed = append(changed, "LOG_LEVEL")
+	}
+
+	current := c.Timeouts.Load()
+	changed = append(changed, timeouts.ChangedEnv(current, r.Timeouts)...)
+	if current != r.Timeouts {
+		if c.Timeouts == nil {
+			c.Timeouts = timeouts.NewLive(r.Timeouts)
+		} else {
+			c.Timeouts.Store(r.Timeouts)
+		}
+	}
+	return changed
+}
+
 // parseCommaSeparatedList splits a comma-separated string into a slice
 func parseCommaSeparatedList(s string) []string {
 	if s == "" {
@@ -204,6 +406,49 @@ func parseCommaSeparatedList(s string) []string {
 	return result
 }
 
+// parseStorageRoots parses STORAGE_ROOTS, a comma-separated list of name=path pairs
+func parseStorageRoots(s string) (map[string]string, error) {
+	roots := make(map[string]string)
+	for _, item := range parseCommaSeparatedList(s) {
+		name, path, ok := strings.Cut(item, "=")
+		name, path = strings.TrimSpace(name), strings.TrimSpace(path)
+		if !ok || path == "" {
+			return nil, fmt.Errorf("STORAGE_ROOTS entry %q must be name=path", item)
+		}
+		if !storageRootNamePattern.MatchString(name) {
+			return nil, fmt.Errorf("STORAGE_ROOTS name %q must be lowercase letters, digits, - or _ (at most 32)", name)
+		}
+		if name == constants.DefaultStorageRoot {
+			return nil, fmt.Errorf("STORAGE_ROOTS can't name a root %q, that is APPS_DIR", constants.DefaultStorageRoot)
+		}
+		if _, exists := roots[name]; exists {
+			return nil, fmt.Errorf("STORAGE_ROOTS names %q twice", name)
+		}
+		roots[name] = filepath.Clean(path)
+	}
+	return roots, nil
+}
+
+// parseTrustedProxies parses TRUSTED_PROXIES, a comma-separated list of IP addresses and CIDR
+// ranges
+func parseTrustedProxies(s string) ([]netip.Prefix, error) {
+	var proxies []netip.Prefix
+	for _, item := range parseCommaSeparatedList(s) {
+		if addr, err := netip.ParseAddr(item); err == nil {
+			proxies = append(proxies, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+			continue
+		}
+		prefix, err := netip.ParsePrefix(item)
+		if err != nil {
+			return nil, fmt.Errorf("TRUSTED_PROXIES entry %q must be an IP address or CIDR range", item)
+		}
+		proxies = append(proxies, prefix.Masked())
+	}
+	return proxies, nil
+}
+
+var storageRootNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,31}$`)
+
 func getEnv(key, defaultValue string) string {
 	if value := os.Getenv(key); value != "" {
 		return value
@@ -211,6 +456,15 @@ func getEnv(key, defaultValue string) string {
 	return defaultValue
 }
 
+// getEnvInt parses key as a non-negative integer, falling back to defaultValue when unset or invalid
+func getEnvInt(key string, defaultValue int) int {
+	value, err := strconv.Atoi(os.Getenv(key))
+	if err != nil || value < 0 {
+		return defaultValue
+	}
+	return value
+}
+
 // generateSecureAPIKey generates a cryptographically secure random API key
 func generateSecureAPIKey() string {
 	bytes := make([]byte, 32)
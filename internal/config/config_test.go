@@ -3,6 +3,7 @@ package config
 import (
 	"os"
 	"testing"
+	"time"
 )
 
 func TestLoad(t *testing.T) {
@@ -334,3 +335,106 @@ func TestLoadWithAuthEnabledButNoJWTSecret(t *testing.T) {
 		t.Errorf("Expected error message '%s', got '%s'", expectedError, err.Error())
 	}
 }
+
+func TestLoadBindMountPolicy(t *testing.T) {
+	t.Setenv("AUTH_ENABLED", "false")
+	t.Setenv("BIND_MOUNT_POLICY", "Allowlist")
+	t.Setenv("ALLOWED_PROTECTED_MOUNTS", "/var/run/docker.sock")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if cfg.Security.BindMountPolicy != "allowlist" || len(cfg.Security.AllowedProtectedMounts) != 1 {
+		t.Errorf("Unexpected security config %+v", cfg.Security)
+	}
+
+	t.Setenv("BIND_MOUNT_POLICY", "open")
+	if _, err := Load(); err == nil {
+		t.Error("Expected an unknown bind mount policy to be refused")
+	}
+}
+
+func TestLoadChaosMode(t *testing.T) {
+	t.Setenv("AUTH_ENABLED", "false")
+	t.Setenv("CHAOS_MODE", "true")
+	t.Setenv("CHAOS_FAILURE_RATE", "0.25")
+
+	t.Setenv("APP_ENV", "production")
+	if _, err := Load(); err == nil {
+		t.Error("Expected chaos mode to be refused in production")
+	}
+
+	t.Setenv("APP_ENV", "development")
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if !cfg.Chaos.Enabled || cfg.Chaos.FailureRate != 0.25 {
+		t.Errorf("Unexpected chaos config %+v", cfg.Chaos)
+	}
+}
+
+func TestApplyReloadable(t *testing.T) {
+	t.Setenv("LOG_LEVEL", "")
+	t.Setenv("TIMEOUT_READ_SEC", "")
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	live := cfg.Timeouts
+
+	t.Setenv("LOG_LEVEL", "debug")
+	t.Setenv("TIMEOUT_READ_SEC", "5")
+	changed := cfg.ApplyReloadable(LoadReloadable())
+	if len(changed) != 2 || changed[0] != "LOG_LEVEL" || changed[1] != "TIMEOUT_READ_SEC" {
+		t.Errorf("expected LOG_LEVEL and TIMEOUT_READ_SEC to change, got %v", changed)
+	}
+	if cfg.LogLevel != "debug" {
+		t.Errorf("expected log level debug, got %q", cfg.LogLevel)
+	}
+	// Clients hold the same Live, so they see the new timeout
+	if cfg.Timeouts != live || live.Load().Read != 5*time.Second {
+		t.Errorf("expected the shared timeouts to be updated in place, got %+v", live.Load())
+	}
+
+	if changed := cfg.ApplyReloadable(LoadReloadable()); len(changed) != 0 {
+		t.Errorf("expected nothing to change on a repeat reload, got %v", changed)
+	}
+}
+
+func TestParseStorageRoots(t *testing.T) {
+	roots, err := parseStorageRoots("ssd=/mnt/ssd/apps, hdd = /mnt/hdd/apps/")
+	if err != nil {
+		t.Fatalf("parseStorageRoots: %v", err)
+	}
+	if len(roots) != 2 || roots["ssd"] != "/mnt/ssd/apps" || roots["hdd"] != "/mnt/hdd/apps" {
+		t.Errorf("Unexpected roots %v", roots)
+	}
+
+	if roots, err := parseStorageRoots(""); err != nil || len(roots) != 0 {
+		t.Errorf("Expected no roots for an empty value, got %v, %v", roots, err)
+	}
+
+	for _, value := range []string{"ssd", "ssd=", "SSD=/mnt/ssd", "default=/mnt/apps", "ssd=/a,ssd=/b"} {
+		if _, err := parseStorageRoots(value); err == nil {
+			t.Errorf("Expected %q to be refused", value)
+		}
+	}
+}
+
+func TestParseTrustedProxies(t *testing.T) {
+	proxies, err := parseTrustedProxies("10.0.0.5, 172.16.0.0/12,::ffff:192.168.1.1")
+	if err != nil {
+		t.Fatalf("parseTrustedProxies: %v", err)
+	}
+	if len(proxies) != 3 || proxies[0].String() != "10.0.0.5/32" || proxies[1].String() != "172.16.0.0/12" || proxies[2].String() != "192.168.1.1/32" {
+		t.Errorf("Unexpected proxies %v", proxies)
+	}
+
+	for _, value := range []string{"gateway", "10.0.0.0/33"} {
+		if _, err := parseTrustedProxies(value); err == nil {
+			t.Errorf("Expected %q to be refused", value)
+		}
+	}
+}
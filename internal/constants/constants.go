@@ -8,7 +8,8 @@ const (
 	AppStatusStopped  = "stopped"
 	AppStatusUpdating = "updating"
 	AppStatusError    = "error"
-	AppStatusPending  = "pending" // Used when app creation is queued
+	AppStatusPending  = "pending"  // Used when app creation is queued
+	AppStatusDegraded = "degraded" // Up, but a container was OOM-killed or is crash-looping
 )
 
 // Job status values
@@ -30,6 +31,16 @@ const (
 	JobTypeTunnelCreate       = "tunnel_create"
 	JobTypeTunnelDelete       = "tunnel_delete"
 	JobTypeQuickTunnel        = "quick_tunnel"
+	JobTypeAppRun             = "app_run"
+	JobTypeNodeRemove         = "node_remove" // app_id holds the node ID
+)
+
+// Job priority values. Workers claim the highest priority pending job first and the oldest among
+// equals, so an operation a user is waiting on doesn't queue behind automated work.
+const (
+	JobPriorityBackground  = -1 // Scheduled and recurring work no one is watching
+	JobPriorityNormal      = 0
+	JobPriorityInteractive = 1 // Quick operations a user clicked, such as start and stop
 )
 
 // Tunnel mode values
@@ -45,6 +56,17 @@ const (
 	TunnelStatusInactive = "inactive"
 	TunnelStatusError    = "error"
 	TunnelStatusDeleted  = "deleted"
+
+	// Connector health reported by the provider account; tunnels in these states have running connectors
+	TunnelStatusHealthy  = "healthy"
+	TunnelStatusDegraded = "degraded"
+)
+
+// Connector states of a named tunnel, as reported by GET /api/apps/:id/tunnel/status
+const (
+	TunnelConnectorStateHealthy      = "healthy"      // At least one connector has an active edge connection
+	TunnelConnectorStateReconnecting = "reconnecting" // Connectors are registered but every connection is reconnecting
+	TunnelConnectorStateNone         = "no_connector" // The tunnel exists but nothing is running its token
 )
 
 // Node status values
@@ -52,11 +74,37 @@ const (
 	NodeStatusOnline      = "online"
 	NodeStatusOffline     = "offline"
 	NodeStatusUnreachable = "unreachable"
+	// NodeStatusLost marks a node removed with the orphan strategy: its cached apps are kept for
+	// reference, but it is no longer health-checked or routed to
+	NodeStatusLost = "lost"
+)
+
+// Node removal strategies: what happens to a node's apps when it is removed
+const (
+	NodeRemoveStrategyOrphan  = "orphan"  // Keep the app records and mark the node lost
+	NodeRemoveStrategyMigrate = "migrate" // Recreate the apps on another node, then remove the node
+	NodeRemoveStrategyPurge   = "purge"   // Delete the apps and their tunnels, then remove the node
+)
+
+// Queued node operation status values (operations held on the primary while their node is offline)
+const (
+	QueuedOperationStatusPending   = "pending"
+	QueuedOperationStatusCompleted = "completed"
+	QueuedOperationStatusFailed    = "failed"
 )
 
 // Tunnel provider names
 const (
 	ProviderCloudflare = "cloudflare"
+
+	// ProviderNgrok serves apps through an ngrok agent sidecar, for users without a Cloudflare account
+	ProviderNgrok = "ngrok"
+
+	// ProviderFrp serves apps through an frpc sidecar connected to the user's own frp server
+	ProviderFrp = "frp"
+
+	// ProviderFake simulates tunnels in chaos mode (CHAOS_MODE=true)
+	ProviderFake = "fake"
 )
 
 // Port constants
@@ -94,6 +142,13 @@ const (
 	// Increased to 15s to account for container startup + cloudflare connection establishment
 	QuickTunnelStartupDelay = 15 * time.Second
 
+	// TunnelSwitchConnectTimeout is how long a switch from Quick Tunnel to a named tunnel waits for
+	// the named tunnel's connector before giving up and keeping the Quick Tunnel
+	TunnelSwitchConnectTimeout = 2 * time.Minute
+
+	// TunnelSwitchPollInterval is how often the provider is asked whether the named tunnel is connected
+	TunnelSwitchPollInterval = 5 * time.Second
+
 	// HTTPClientTimeout is the timeout for HTTP client requests
 	HTTPClientTimeout = 5 * time.Second
 
@@ -123,6 +178,18 @@ const (
 
 	// DatabaseLockTimeout is the timeout when database is locked
 	DatabaseLockTimeout = 5 * time.Second
+
+	// AppLockTTL is how long an app lease lives before another operation may take it over
+	AppLockTTL = 30 * time.Minute
+
+	// AppLockWaitTimeout is how long a job waits for a busy app before failing
+	AppLockWaitTimeout = 10 * time.Minute
+
+	// AppLockRetryInterval is the interval between attempts to take a busy app's lease
+	AppLockRetryInterval = 2 * time.Second
+
+	// AppStatusCacheTTL is how long a "docker compose ps" observation is reused when reconciling app status on read
+	AppStatusCacheTTL = 15 * time.Second
 )
 
 // Compose version change reasons
@@ -162,6 +229,20 @@ const (
 	CircuitBreakerHalfOpenSuccesses = 2
 )
 
+// Node clock skew constants
+const (
+	// NodeTimeHeader carries the sender's clock on heartbeats, so the primary can measure skew
+	NodeTimeHeader = "X-Node-Time"
+
+	// ClockSkewWarnThreshold is how far a node's clock may be from the primary's before it is
+	// warned about
+	ClockSkewWarnThreshold = 10 * time.Second
+
+	// ClockSkewMaxCompensation is the largest skew corrected for in the times a node reports (job
+	// and log timestamps); larger skews are only warned about, since the clock needs fixing
+	ClockSkewMaxCompensation = 2 * time.Minute
+)
+
 // Node health check constants
 const (
 	// NodeHealthCheckFailureThreshold marks node as offline after this many consecutive failures
@@ -178,6 +259,26 @@ const (
 
 	// NodeHealthCheckIntervalLong is the interval for nodes with 6+ failures
 	NodeHealthCheckIntervalLong = 5 * time.Minute
+
+	// AppInventorySyncInterval is how often a secondary pushes app inventory changes to the primary
+	AppInventorySyncInterval = 30 * time.Second
+
+	// NodeFanOutConcurrency is how many nodes a multi-node list asks at once
+	NodeFanOutConcurrency = 8
+
+	// NodeFanOutTimeout is how long a multi-node list waits for each node before leaving it out
+	NodeFanOutTimeout = 10 * time.Second
+
+	// QueuedOperationReplayTimeout bounds one replay pass over a node's queued operations
+	QueuedOperationReplayTimeout = 10 * time.Minute
+
+	// PrimaryReplicaSyncInterval is how often a secondary refreshes its copy of primary metadata
+	PrimaryReplicaSyncInterval = 5 * time.Minute
+)
+
+// Kinds of primary metadata a secondary keeps a read-only copy of
+const (
+	PrimaryReplicaNodes = "nodes"
 )
 
 // Backoff constants for retry logic
@@ -197,6 +298,10 @@ const (
 	// JobStaleThreshold is how long a job can be in "running" state before considered stale
 	JobStaleThreshold = 30 * time.Minute
 
+	// JobRecoveryMaxRequeues is how many times startup recovery queues an interrupted app job again
+	// before giving up and marking the app as errored, so a job that crashes the server can't loop
+	JobRecoveryMaxRequeues = 1
+
 	// JobGracefulShutdownTimeout is how long to wait for current job during shutdown
 	JobGracefulShutdownTimeout = 5 * time.Minute
 
@@ -205,6 +310,516 @@ const (
 
 	// JobHistoryCleanupInterval is how often to clean up old job records
 	JobHistoryCleanupInterval = 1 * time.Hour
+
+	// JobListDefaultLimit and JobListMaxLimit bound how many jobs a job listing returns
+	JobListDefaultLimit = 50
+	JobListMaxLimit     = 500
+)
+
+// Log search constants
+const (
+	// LogSearchDefaultSince is the default lookback window when no "since" is given
+	LogSearchDefaultSince = "1h"
+
+	// LogSearchTailLines caps how many lines are read per app when searching logs
+	LogSearchTailLines = 2000
+
+	// LogSearchMaxResults caps the number of matches returned per node
+	LogSearchMaxResults = 500
+
+	// LogSearchDefaultContextLines is the number of lines shown around each match by default
+	LogSearchDefaultContextLines = 2
+
+	// LogSearchMaxContextLines is the maximum number of context lines allowed around a match
+	LogSearchMaxContextLines = 10
+)
+
+// Trash constants
+const (
+	// TrashPurgeInterval is how often archives older than the trash TTL are removed
+	TrashPurgeInterval = time.Hour
+)
+
+// Database maintenance constants
+const (
+	// DBMaintenanceCheckInterval is how often a node checks whether database maintenance is due
+	DBMaintenanceCheckInterval = time.Hour
+
+	// DBMaintenanceInterval is how long after a maintenance pass the next one is due
+	DBMaintenanceInterval = 24 * time.Hour
+
+	// DBRebuildFragmentation is the share of free pages at which a database without incremental
+	// vacuum is rebuilt with VACUUM
+	DBRebuildFragmentation = 0.2
+)
+
+// Telemetry constants
+const (
+	// TelemetryReportInterval is how often the primary sends a usage report when telemetry is enabled
+	TelemetryReportInterval = 24 * time.Hour
+
+	// TelemetryReportTimeout bounds a single report upload
+	TelemetryReportTimeout = 10 * time.Second
+)
+
+// Webhook events fired for app lifecycle changes
+const (
+	WebhookEventStart      = "start"
+	WebhookEventStop       = "stop"
+	WebhookEventUpdate     = "update"
+	WebhookEventCrash      = "crash"
+	WebhookEventTaskFailed = "task_failed" // A recurring task's run failed or timed out
+	WebhookEventTest       = "test"        // Sent on demand to check a receiver; never subscribed to
+	WebhookEventDigest     = "digest"      // The non-critical events a webhook held back, sent together
+)
+
+// Webhook digest modes; a webhook without one sends events as they happen
+const (
+	WebhookDigestHourly = "hourly"
+	WebhookDigestDaily  = "daily"
+)
+
+// Webhook delivery constants
+const (
+	// WebhookDeliveryTimeout bounds a single delivery attempt
+	WebhookDeliveryTimeout = 10 * time.Second
+
+	// WebhookMaxAttempts is how many times a delivery is tried before it is recorded as failed
+	WebhookMaxAttempts = 3
+
+	// WebhookRetryBackoff is the wait before the first retry; it doubles for each later attempt
+	WebhookRetryBackoff = 2 * time.Second
+
+	// WebhookHeldFlushInterval is how often held events are checked for digests that are due and
+	// quiet hours that have ended
+	WebhookHeldFlushInterval = time.Minute
+)
+
+// Gateway registry token constants
+const (
+	// GatewayTokenDefaultTTL is how long a gateway token is valid when no TTL is requested
+	GatewayTokenDefaultTTL = 24 * time.Hour
+
+	// GatewayTokenMaxTTL caps the lifetime of a gateway token
+	GatewayTokenMaxTTL = 30 * 24 * time.Hour
+
+	// GatewayTokenSigningKey names the primary's signing key for gateway tokens
+	GatewayTokenSigningKey = "gateway_token"
+)
+
+// Platform health statuses, from best to worst
+const (
+	HealthStatusHealthy   = "healthy"
+	HealthStatusDegraded  = "degraded"
+	HealthStatusUnhealthy = "unhealthy"
+)
+
+// Platform health check constants
+const (
+	// HealthCheckTimeout bounds each individual check in the platform health report
+	HealthCheckTimeout = 5 * time.Second
+
+	// HealthJobBacklogThreshold is the number of pending jobs above which the job queue is degraded
+	HealthJobBacklogThreshold = 50
+
+	// HealthJobStaleAfter is how long a job may stay pending before the job queue is degraded
+	HealthJobStaleAfter = 10 * time.Minute
+)
+
+// Settings sections served by /api/settings/:section
+const (
+	SettingsSectionGeneral         = "general"
+	SettingsSectionTunnelProviders = "tunnel_providers"
+	SettingsSectionJobs            = "jobs"
+	SettingsSectionAlerts          = "alerts"
+	SettingsSectionLogForwarding   = "log_forwarding"
+	SettingsSectionComposePolicies = "compose_policies"
+	SettingsSectionSMTP            = "smtp"
+)
+
+// SettingsHistoryLimit is how many changes GET /api/settings/:section/history returns
+const SettingsHistoryLimit = 100
+
+// Consistency audit checks, as reported in each finding
+const (
+	AuditCheckAppNodeMissing       = "app_node_missing"
+	AuditCheckTunnelWithoutApp     = "tunnel_without_app"
+	AuditCheckComposeVersionFlag   = "compose_version_current"
+	AuditCheckJobStuckPending      = "job_stuck_pending"
+	AuditCheckDuplicateMetricsPort = "duplicate_metrics_port"
+	AuditCheckNetworkWithoutApp    = "network_without_app"
+)
+
+// Consistency audit constants
+const (
+	// ConsistencyAuditInterval is how often the consistency audit runs
+	ConsistencyAuditInterval = 7 * 24 * time.Hour
+
+	// AuditJobStuckAfter is how long a job may stay pending before the audit reports it
+	AuditJobStuckAfter = time.Hour
+
+	// AuditMaxPendingJobs caps how many pending jobs the audit inspects
+	AuditMaxPendingJobs = 200
+
+	// ConsistencyAuditsKept is how many audit reports are stored and GET /api/system/audit/history returns
+	ConsistencyAuditsKept = 12
+
+	// AuditEventFindings is the notification sent when an audit finds inconsistencies
+	AuditEventFindings = "consistency_audit.findings"
+)
+
+// Actions POST /api/apply plans for each app
+const (
+	ApplyActionCreate    = "create"
+	ApplyActionUpdate    = "update"
+	ApplyActionDelete    = "delete"
+	ApplyActionUnchanged = "unchanged"
+)
+
+// Database snapshot constants
+const (
+	// DBSnapshotDir is the directory next to the database file holding pre-migration snapshots
+	DBSnapshotDir = "snapshots"
+
+	// DBSnapshotKeepCount is how many pre-migration snapshots are kept
+	DBSnapshotKeepCount = 5
+)
+
+// Database query constants
+const (
+	// DBStatementCacheSize caps how many prepared statements the database keeps for reuse
+	DBStatementCacheSize = 256
+
+	// DBSlowQueryThreshold is how long a query runs before it is logged and counted as slow
+	DBSlowQueryThreshold = 200 * time.Millisecond
+
+	// DBQueryStatsLimit is how many queries the database stats list, those taking the most time
+	DBQueryStatsLimit = 20
+
+	// DBQueryStatsMaxQueries caps how many distinct queries are timed
+	DBQueryStatsMaxQueries = 1024
+)
+
+// Live app stats stream constants
+const (
+	// StatsStreamDefaultInterval is how often GET /api/apps/:id/stats/stream sends a sample
+	StatsStreamDefaultInterval = 2 * time.Second
+
+	// StatsStreamMaxInterval is the longest ?interval= a stats stream accepts
+	StatsStreamMaxInterval = time.Minute
+)
+
+// Event stream constants
+const (
+	// EventsSubscriberBuffer is how many events wait for a GET /api/events client before newer
+	// ones are dropped and the client is told to refetch
+	EventsSubscriberBuffer = 256
+
+	// EventsKeepaliveInterval is how often an idle event stream sends a comment, so proxies don't
+	// close it
+	EventsKeepaliveInterval = 15 * time.Second
+)
+
+// Crash detection constants
+const (
+	// CrashCheckInterval is how often each node inspects the containers of its running apps
+	CrashCheckInterval = 30 * time.Second
+
+	// CrashLoopRestarts restarts of one container within CrashLoopWindow make it crash-looping
+	CrashLoopRestarts = 3
+
+	// CrashLoopWindow is how far back restarts and OOM kills count; a degraded app recovers once
+	// none of its containers had either for this long
+	CrashLoopWindow = 10 * time.Minute
+
+	// CrashLogTailLines is how many log lines of the failing service are kept in the app's error details
+	CrashLogTailLines = 50
+
+	// FailureLogTailLines is how many lines of compose output and container logs are captured when
+	// starting or updating an app fails
+	FailureLogTailLines = 200
+)
+
+// One-off command constants
+const (
+	// RunModeRun starts a new container of the service for the command and removes it afterwards
+	RunModeRun = "run"
+	// RunModeExec runs the command in the service's running container
+	RunModeExec = "exec"
+
+	// RunOutputTailLines is how many lines of a one-off command's output are kept in its job result
+	RunOutputTailLines = 500
+
+	// RunDefaultTimeout bounds a one-off command or task run that doesn't set its own timeout
+	RunDefaultTimeout = 10 * time.Minute
+
+	// RunMaxTimeout is the longest timeout a command or task can ask for
+	RunMaxTimeout = 6 * time.Hour
+
+	// TaskRunHistoryLimit is how many runs of each recurring task are kept
+	TaskRunHistoryLimit = 50
+)
+
+// Node metrics history constants
+const (
+	// NodeMetricsInterval is how often the primary samples every node's system stats
+	NodeMetricsInterval = time.Minute
+
+	// NodeMetricsRetention is how long samples are kept
+	NodeMetricsRetention = 7 * 24 * time.Hour
+
+	// NodeMetricsDefaultRange is the window GET /api/nodes/:id/metrics returns without ?since=
+	NodeMetricsDefaultRange = 24 * time.Hour
+
+	// NodeAlertsListLimit is how many alerts GET /api/nodes/:id/alerts returns
+	NodeAlertsListLimit = 100
+)
+
+// Node metrics alerts fire on, with the default threshold of each
+const (
+	NodeMetricDisk        = "disk"        // Percent of the data disk used
+	NodeMetricMemory      = "memory"      // Percent of memory used
+	NodeMetricLoad        = "load"        // 5-minute load average as a percent of CPU cores
+	NodeMetricTemperature = "temperature" // Hottest sensor, in °C
+
+	DefaultAlertDiskPercent        = 90
+	DefaultAlertMemoryPercent      = 95
+	DefaultAlertLoadPercent        = 200
+	DefaultAlertTemperatureCelsius = 85
+
+	// DefaultAlertSustainedMinutes is how long memory, load and temperature must stay over their
+	// threshold before an alert fires; disk alerts fire on the first sample over it
+	DefaultAlertSustainedMinutes = 10
+)
+
+// Events sent to the alerts webhook
+const (
+	NodeAlertEventFiring   = "node_alert.firing"
+	NodeAlertEventResolved = "node_alert.resolved"
+)
+
+// Server log shipping (selfhostly's own logs, not app logs)
+const (
+	LogShippingPrimary = "primary" // Secondaries post their logs to the primary
+	LogShippingLoki    = "loki"    // Logs are pushed to a Loki endpoint
+
+	// LogShippingInterval is how often buffered lines are shipped
+	LogShippingInterval = 10 * time.Second
+
+	// LogShippingBufferSize is how many lines a node holds while it can't ship; the oldest are
+	// dropped beyond it
+	LogShippingBufferSize = 5000
+
+	// LogShippingBatchSize is the most lines sent in one push
+	LogShippingBatchSize = 500
+
+	// NodeLogsRetention is how long the primary keeps lines shipped by secondaries
+	NodeLogsRetention = 3 * 24 * time.Hour
+
+	// NodeLogsDefaultLimit and NodeLogsMaxLimit bound how many lines GET /api/nodes/:id/server-logs returns
+	NodeLogsDefaultLimit = 500
+	NodeLogsMaxLimit     = 5000
+)
+
+// App log forwarding destinations and the sidecar that ships to them
+const (
+	LogForwardingLoki          = "loki"
+	LogForwardingElasticsearch = "elasticsearch"
+
+	// LogForwarderImage is the Vector image run as each app's log forwarding sidecar
+	LogForwarderImage = "timberio/vector:0.41.1-alpine"
+
+	// LogForwarderService is the sidecar's service name in the generated compose file
+	LogForwarderService = "selfhostly-log-forwarder"
+
+	// DefaultLogForwardingIndex is the Elasticsearch index logs go to when none is set
+	DefaultLogForwardingIndex = "selfhostly-%Y.%m.%d"
+)
+
+// Per-user preferences
+const (
+	// LocalUserID holds the preferences while authentication is off and there is no user to key them by
+	LocalUserID = "local"
+
+	ThemeSystem = "system"
+	ThemeLight  = "light"
+	ThemeDark   = "dark"
+
+	// DefaultRefreshInterval is how often the UI refreshes, in seconds, until a user picks otherwise
+	DefaultRefreshInterval = 30
+
+	// MinRefreshInterval and MaxRefreshInterval bound a non-zero refresh interval, in seconds
+	MinRefreshInterval = 5
+	MaxRefreshInterval = 3600
+
+	// MaxPreferenceTables and MaxPreferenceColumns bound the table column layouts a user can save
+	MaxPreferenceTables  = 50
+	MaxPreferenceColumns = 50
+)
+
+// Bind mount policies (BIND_MOUNT_POLICY): which host paths apps may bind-mount
+const (
+	BindMountPolicyDenylist  = "denylist"  // Any host path except the protected ones and /home
+	BindMountPolicyAllowlist = "allowlist" // Only the app directory and paths under ALLOWED_VOLUME_PATHS
+)
+
+// Compose policies: settings refused in user compose files unless their policy says otherwise
+const (
+	ComposePolicyPrivileged  = "privileged"   // privileged: true
+	ComposePolicyHostNetwork = "host_network" // network_mode: host
+	ComposePolicyCapAdd      = "cap_add"      // cap_add with SYS_ADMIN or another dangerous capability
+	ComposePolicyDevices     = "devices"      // devices: mappings
+
+	ComposePolicyBlock    = "block"    // Always refused (the default)
+	ComposePolicyApproval = "approval" // Allowed when the change lists the policy in approve_policies
+	ComposePolicyAllow    = "allow"    // Always allowed
+)
+
+// Operations that wait for a second confirmation while APPROVAL_REQUIRED is on
+const (
+	ApprovalOperationDeleteApp            = "delete_app"
+	ApprovalOperationDeleteTunnel         = "delete_tunnel"          // An app's tunnel
+	ApprovalOperationDeleteOrphanedTunnel = "delete_orphaned_tunnel" // A tunnel no app uses
+	ApprovalOperationRemoveNode           = "remove_node"
+)
+
+// Approval statuses
+const (
+	ApprovalStatusPending   = "pending"
+	ApprovalStatusConfirmed = "confirmed" // The operation ran; error is set if it failed
+	ApprovalStatusRejected  = "rejected"
+	ApprovalStatusExpired   = "expired"
+)
+
+// ApprovalHistoryLimit is how many approvals GET /api/approvals returns
+const ApprovalHistoryLimit = 200
+
+// How the connection to the SMTP server is secured
+const (
+	SMTPSecurityStartTLS = "starttls" // Upgrade a plain connection, usually on port 587
+	SMTPSecurityTLS      = "tls"      // TLS from the start, usually on port 465
+	SMTPSecurityNone     = "none"
+)
+
+// DefaultSMTPPort is the submission port, used with STARTTLS
+const DefaultSMTPPort = 587
+
+// User roles. Users in GITHUB_ALLOWED_USERS are admins; invited users get the invitation's role.
+const (
+	UserRoleAdmin  = "admin"  // Everything an allowed user can do
+	UserRoleViewer = "viewer" // Read-only: may look at apps, logs and stats but not change anything
+)
+
+// Invitation constants
+const (
+	// InvitationDefaultTTL is how long an invite link is valid when no TTL is requested
+	InvitationDefaultTTL = 7 * 24 * time.Hour
+
+	// InvitationMaxTTL caps the lifetime of an invite link
+	InvitationMaxTTL = 30 * 24 * time.Hour
+
+	// InvitePath is where invite links point; opening one signs in with GitHub and accepts it. It
+	// is under /api so ingress routes it to the API rather than the frontend.
+	InvitePath = "/api/invite/"
+)
+
+// Invitation statuses
+const (
+	InvitationStatusPending  = "pending"
+	InvitationStatusAccepted = "accepted"
+	InvitationStatusExpired  = "expired"
+	InvitationStatusRevoked  = "revoked"
+)
+
+// Session constants
+const (
+	// SessionLifetime is how long a session lasts without being used: the lifetime of the session
+	// cookie, which refreshes the token while it is valid
+	SessionLifetime = 7 * 24 * time.Hour
+
+	// SessionTokenLifetime is how long a session's token is valid before it has to be refreshed
+	SessionTokenLifetime = 24 * time.Hour
+
+	// RevokedSessionRetention is how long a revoked session is remembered. Its token is refused
+	// before it can be refreshed, and a token that expired SessionLifetime ago can't be refreshed
+	// at all, so after this long none of the session's tokens can be used.
+	RevokedSessionRetention = SessionTokenLifetime + SessionLifetime
+
+	// SessionTouchInterval is how often a session's last-seen time and device are recorded
+	SessionTouchInterval = time.Minute
+)
+
+// API token constants
+const (
+	// APITokenPrefix starts every API token, telling it apart from a JWT in the Authorization header
+	APITokenPrefix = "shp_"
+
+	// APITokenMaxExpiresInDays caps the lifetime of an API token that expires
+	APITokenMaxExpiresInDays = 365
+
+	// APITokenTouchInterval is how often a token's last use is recorded
+	APITokenTouchInterval = time.Minute
+)
+
+// Status page constants
+const (
+	// StatusPagePath is where public app status pages are served; the token follows it
+	StatusPagePath = "/api/status/"
+
+	// StatusPageUptimeWindow is the period a status page reports uptime and incidents over
+	StatusPageUptimeWindow = 30 * 24 * time.Hour
+
+	// StatusHistoryPruneInterval is how often status history older than the uptime window is deleted
+	StatusHistoryPruneInterval = time.Hour
+)
+
+// App icon constants
+const (
+	// AppIconLabel is the compose service label naming an icon URL, used instead of detecting one
+	// from the app's public URL
+	AppIconLabel = "selfhostly.icon"
+
+	// AppIconMaxSize bounds a downloaded icon
+	AppIconMaxSize = 512 << 10
+
+	// AppIconFetchTimeout bounds detecting and downloading an app's icon
+	AppIconFetchTimeout = 10 * time.Second
+
+	// AppIconCacheTTL is how long a downloaded icon is served before it is fetched again
+	AppIconCacheTTL = 7 * 24 * time.Hour
+
+	// AppIconRetryInterval is how long an app whose icon couldn't be found waits before it is
+	// looked for again
+	AppIconRetryInterval = time.Hour
+)
+
+// Statuses shown on public status pages, which don't reveal the app's internal status
+const (
+	PublicStatusOperational = "operational" // running
+	PublicStatusDegraded    = "degraded"    // up, but a container was OOM-killed or is crash-looping
+	PublicStatusDown        = "down"        // failed
+	PublicStatusMaintenance = "maintenance" // being deployed or updated
+	PublicStatusStopped     = "stopped"
+)
+
+// DefaultQuotaUser names the quota that applies to every user without their own
+const DefaultQuotaUser = "*"
+
+// DefaultStorageRoot names APPS_DIR among a node's storage roots; it is used when an app picks none
+const DefaultStorageRoot = "default"
+
+// Where a host port reservation comes from. Compose and Quick Tunnel reservations follow the app's
+// compose; manual ones are made and released through the API.
+const (
+	PortReservationSourceCompose            = "compose"
+	PortReservationSourceQuickTunnelMetrics = "quick_tunnel_metrics"
+	PortReservationSourceManual             = "manual"
+)
+
+// Range a manual reservation is allocated from when it doesn't name a port
+const (
+	PortReservationAllocateMin = 20000
+	PortReservationAllocateMax = 29999
 )
 
 // Default provider name (for backward compatibility)
@@ -0,0 +1,92 @@
+package db
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/selfhostly/internal/constants"
+)
+
+// The panel should stay responsive at 500+ apps: run with
+// go test ./internal/db -run '^$' -bench . -benchmem
+// and compare against the budget in docs/DEVELOPMENT.md.
+
+var benchAppCounts = []int{100, 500, 2000}
+
+// seededDB returns a database holding apps fake apps with ten finished jobs each, and pending
+// pending jobs
+func seededDB(b *testing.B, apps, pending int) *DB {
+	b.Helper()
+	database, err := Init(filepath.Join(b.TempDir(), "bench.db"))
+	if err != nil {
+		b.Fatalf("Init: %v", err)
+	}
+	b.Cleanup(func() { database.Close() })
+	if err := database.SeedFakeData(SeedOptions{Apps: apps, Jobs: apps * 10, PendingJobs: pending}); err != nil {
+		b.Fatalf("SeedFakeData: %v", err)
+	}
+	return database
+}
+
+func BenchmarkGetAllApps(b *testing.B) {
+	for _, n := range benchAppCounts {
+		b.Run(fmt.Sprintf("apps=%d", n), func(b *testing.B) {
+			database := seededDB(b, n, 0)
+			b.ResetTimer()
+			for range b.N {
+				if _, err := database.GetAllApps(); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkGetAllAppsMetadata(b *testing.B) {
+	for _, n := range benchAppCounts {
+		b.Run(fmt.Sprintf("apps=%d", n), func(b *testing.B) {
+			database := seededDB(b, n, 0)
+			b.ResetTimer()
+			for range b.N {
+				if _, err := database.GetAllAppsMetadata(); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkClaimPendingJob measures the worker's poll when there is nothing to claim, which is
+// what it does most of the time, against a jobs table of ten finished jobs per app
+func BenchmarkClaimPendingJob(b *testing.B) {
+	for _, n := range benchAppCounts {
+		b.Run(fmt.Sprintf("apps=%d", n), func(b *testing.B) {
+			database := seededDB(b, n, 0)
+			b.ResetTimer()
+			for range b.N {
+				if _, err := database.ClaimPendingJob("bench"); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkGetPendingJobs(b *testing.B) {
+	for _, n := range benchAppCounts {
+		b.Run(fmt.Sprintf("apps=%d", n), func(b *testing.B) {
+			database := seededDB(b, n, 50)
+			b.ResetTimer()
+			for range b.N {
+				jobs, err := database.GetPendingJobs(10)
+				if err != nil {
+					b.Fatal(err)
+				}
+				if len(jobs) != 10 || jobs[0].Status != constants.JobStatusPending {
+					b.Fatalf("expected 10 pending jobs, got %d", len(jobs))
+				}
+			}
+		})
+	}
+}
@@ -8,7 +8,9 @@ import (
 	"log/slog"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/selfhostly/internal/config"
@@ -19,7 +21,8 @@ import (
 // DB wraps the database connection
 type DB struct {
 	*sql.DB
-	dbPath string
+	dbPath  string
+	queries *queryStats // Prepared statements and timings of the queries run through Exec, Query and QueryRow
 }
 
 // Tx wraps a database transaction
@@ -41,7 +44,7 @@ func Init(dbPath string) (*DB, error) {
 		return nil, err
 	}
 
-	db := &DB{sqlDB, dbPath}
+	db := &DB{DB: sqlDB, dbPath: dbPath, queries: newQueryStats()}
 
 	// Configure SQLite for reliability and performance
 	if err := db.configureSQLite(); err != nil {
@@ -67,6 +70,10 @@ func Init(dbPath string) (*DB, error) {
 // configureSQLite sets optimal SQLite pragmas for reliability and performance
 func (db *DB) configureSQLite() error {
 	pragmas := []string{
+		// Let free pages be handed back in small steps (see Maintain). Only takes effect on a new
+		// database; older ones switch over when maintenance rebuilds them.
+		"PRAGMA auto_vacuum=INCREMENTAL",
+
 		// Enable WAL mode for better concurrency and crash recovery
 		// WAL allows readers and writers to operate concurrently
 		"PRAGMA journal_mode=WAL",
@@ -105,6 +112,17 @@ func (db *DB) configureSQLite() error {
 	return nil
 }
 
+// appListColumns are the apps columns GetAllAppsMetadata reads: everything but the compose and
+// env files, which make up most of an app's row
+const appListColumns = "id, name, description, tunnel_token, tunnel_id, tunnel_domain, public_url, status, error_message, node_id, tunnel_mode, labels, storage_root, owner, created_at, updated_at"
+
+// pendingJobQuery finds the unclaimed pending job to run next: the oldest of the highest priority.
+// Served by idx_jobs_status_priority, so polling stays cheap however many finished jobs are kept.
+const pendingJobQuery = `SELECT id FROM jobs
+		 WHERE status = ? AND (claimed_by IS NULL OR claimed_by = '')
+		 ORDER BY priority DESC, created_at ASC
+		 LIMIT 1`
+
 // IntegrityCheck runs SQLite's integrity check
 func (db *DB) IntegrityCheck() error {
 	var result string
@@ -138,9 +156,18 @@ func (tx *Tx) CreateApp(app *App) error {
 		errorMessage = nil
 	}
 
-	_, err := tx.Exec(
-		"INSERT INTO apps (id, name, description, compose_content, tunnel_token, tunnel_id, tunnel_domain, public_url, status, error_message, node_id, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
-		app.ID, app.Name, app.Description, app.ComposeContent, app.TunnelToken, app.TunnelID, app.TunnelDomain, app.PublicURL, app.Status, errorMessage, app.NodeID, app.CreatedAt, time.Now(),
+	labels, err := encodeLabels(app.Labels)
+	if err != nil {
+		return err
+	}
+	composeFiles, err := encodeComposeFiles(app.ComposeFiles)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(
+		"INSERT INTO apps (id, name, description, compose_content, compose_override, tunnel_compose, tunnel_token, tunnel_id, tunnel_domain, public_url, status, error_message, node_id, labels, compose_files, env_template, env_content, storage_root, owner, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		app.ID, app.Name, app.Description, app.ComposeContent, app.ComposeOverride, app.TunnelCompose, app.TunnelToken, app.TunnelID, app.TunnelDomain, app.PublicURL, app.Status, errorMessage, app.NodeID, labels, composeFiles, app.EnvTemplate, app.EnvContent, app.StorageRoot, app.Owner, app.CreatedAt, time.Now(),
 	)
 	return err
 }
@@ -154,20 +181,32 @@ func (tx *Tx) UpdateApp(app *App) error {
 		errorMessage = nil
 	}
 
-	_, err := tx.Exec(
-		"UPDATE apps SET name = ?, description = ?, compose_content = ?, tunnel_token = ?, tunnel_id = ?, tunnel_domain = ?, public_url = ?, status = ?, error_message = ?, tunnel_mode = ?, updated_at = ? WHERE id = ?",
-		app.Name, app.Description, app.ComposeContent, app.TunnelToken, app.TunnelID, app.TunnelDomain, app.PublicURL, app.Status, errorMessage, app.TunnelMode, time.Now(), app.ID,
+	labels, err := encodeLabels(app.Labels)
+	if err != nil {
+		return err
+	}
+	composeFiles, err := encodeComposeFiles(app.ComposeFiles)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(
+		"UPDATE apps SET name = ?, description = ?, compose_content = ?, compose_override = ?, tunnel_compose = ?, tunnel_token = ?, tunnel_id = ?, tunnel_domain = ?, public_url = ?, status = ?, error_message = ?, tunnel_mode = ?, labels = ?, compose_files = ?, env_template = ?, env_content = ?, updated_at = ? WHERE id = ?",
+		app.Name, app.Description, app.ComposeContent, app.ComposeOverride, app.TunnelCompose, app.TunnelToken, app.TunnelID, app.TunnelDomain, app.PublicURL, app.Status, errorMessage, app.TunnelMode, labels, composeFiles, app.EnvTemplate, app.EnvContent, time.Now(), app.ID,
 	)
 	return err
 }
 
-// DeleteAppTx deletes an app within a transaction
+// DeleteAppTx deletes an app within a transaction, releasing its host ports
 func (tx *Tx) DeleteApp(id string) error {
+	if _, err := tx.Exec("DELETE FROM port_reservations WHERE app_id = ?", id); err != nil {
+		return err
+	}
 	_, err := tx.Exec("DELETE FROM apps WHERE id = ?", id)
 	return err
 }
 
-// migrate runs database migrations
+// migrate runs database migrations. Only append to the list: its length is the schema version.
 func (db *DB) migrate() error {
 	migrations := []string{
 		// Nodes table - must be created before apps table for foreign key
@@ -313,6 +352,442 @@ func (db *DB) migrate() error {
 			FOREIGN KEY (app_id) REFERENCES apps(id) ON DELETE CASCADE
 		)`,
 		`CREATE INDEX IF NOT EXISTS idx_app_schedules_enabled ON app_schedules(enabled)`,
+		// Optional docker-compose.override.yml content, merged on top of compose_content at deploy time
+		`ALTER TABLE apps ADD COLUMN compose_override TEXT DEFAULT ''`,
+		`ALTER TABLE compose_versions ADD COLUMN compose_override TEXT DEFAULT ''`,
+		`ALTER TABLE compose_versions ADD COLUMN image_digests TEXT DEFAULT ''`,
+		`ALTER TABLE apps ADD COLUMN tunnel_compose TEXT DEFAULT ''`,
+		// Per-app leases that serialize conflicting operations (sync requests and jobs)
+		`CREATE TABLE IF NOT EXISTS app_locks (
+			app_id TEXT PRIMARY KEY,
+			holder TEXT NOT NULL,
+			operation TEXT NOT NULL,
+			acquired_at INTEGER NOT NULL,
+			expires_at INTEGER NOT NULL
+		)`,
+		// Mutating requests held on the primary while their target node is offline, replayed in order when it returns
+		`CREATE TABLE IF NOT EXISTS queued_operations (
+			id TEXT PRIMARY KEY,
+			node_id TEXT NOT NULL,
+			method TEXT NOT NULL,
+			path TEXT NOT NULL,
+			raw_query TEXT NOT NULL DEFAULT '',
+			body TEXT NOT NULL DEFAULT '',
+			status TEXT NOT NULL DEFAULT 'pending',
+			response_status INTEGER NOT NULL DEFAULT 0,
+			error_message TEXT,
+			created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (node_id) REFERENCES nodes(id) ON DELETE CASCADE
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_queued_operations_node ON queued_operations(node_id, status, created_at)`,
+		// Primary-side copy of each secondary's app inventory, pushed by the secondary; lets listings
+		// show a node's apps (marked stale) while the node is unreachable
+		`CREATE TABLE IF NOT EXISTS node_app_cache (
+			node_id TEXT NOT NULL,
+			app_id TEXT NOT NULL,
+			name TEXT NOT NULL,
+			status TEXT NOT NULL,
+			public_url TEXT NOT NULL DEFAULT '',
+			updated_at DATETIME NOT NULL,
+			synced_at DATETIME NOT NULL,
+			PRIMARY KEY (node_id, app_id),
+			FOREIGN KEY (node_id) REFERENCES nodes(id) ON DELETE CASCADE
+		)`,
+		// Opt-in anonymous usage telemetry; telemetry_id is a random install ID unrelated to node IDs
+		`ALTER TABLE settings ADD COLUMN telemetry_enabled INTEGER NOT NULL DEFAULT 0`,
+		`ALTER TABLE settings ADD COLUMN telemetry_id TEXT`,
+		// Per-install feature flag values as a JSON object of flag name -> enabled
+		`ALTER TABLE settings ADD COLUMN feature_flags TEXT`,
+		// Per-app webhooks fired on lifecycle events; events is a JSON array of event names
+		`CREATE TABLE IF NOT EXISTS app_webhooks (
+			id TEXT PRIMARY KEY,
+			app_id TEXT NOT NULL,
+			url TEXT NOT NULL,
+			secret TEXT NOT NULL,
+			events TEXT NOT NULL,
+			enabled INTEGER NOT NULL DEFAULT 1,
+			last_status INTEGER NOT NULL DEFAULT 0,
+			last_error TEXT,
+			last_delivered_at DATETIME,
+			created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (app_id) REFERENCES apps(id) ON DELETE CASCADE
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_app_webhooks_app ON app_webhooks(app_id)`,
+		// Job queue tuning, editable through the jobs settings section
+		`ALTER TABLE settings ADD COLUMN job_history_keep_count INTEGER NOT NULL DEFAULT 20`,
+		`ALTER TABLE settings ADD COLUMN job_stale_threshold_minutes INTEGER NOT NULL DEFAULT 30`,
+		// Audit trail of settings section updates; changes is a JSON array of field changes
+		`CREATE TABLE IF NOT EXISTS settings_history (
+			id TEXT PRIMARY KEY,
+			section TEXT NOT NULL,
+			changes TEXT NOT NULL,
+			changed_by TEXT,
+			created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_settings_history_section ON settings_history(section, created_at)`,
+		// Build and API schema version each node last reported, for version skew detection
+		`ALTER TABLE nodes ADD COLUMN version TEXT NOT NULL DEFAULT ''`,
+		`ALTER TABLE nodes ADD COLUMN api_version INTEGER NOT NULL DEFAULT 0`,
+		// Read-only copies of primary metadata kept by secondaries; data is JSON, one row per kind
+		`CREATE TABLE IF NOT EXISTS primary_replica (
+			kind TEXT PRIMARY KEY,
+			data TEXT NOT NULL,
+			synced_at DATETIME NOT NULL
+		)`,
+		// App labels as a JSON object, matched by label selectors
+		`ALTER TABLE apps ADD COLUMN labels TEXT DEFAULT ''`,
+		// Per-node system metrics sampled by the primary every minute, pruned after a week
+		`CREATE TABLE IF NOT EXISTS node_metrics (
+			node_id TEXT NOT NULL,
+			cpu_percent REAL NOT NULL,
+			cpu_cores INTEGER NOT NULL,
+			memory_percent REAL NOT NULL,
+			disk_percent REAL NOT NULL,
+			load_1 REAL NOT NULL,
+			load_5 REAL NOT NULL,
+			load_15 REAL NOT NULL,
+			temperature_celsius REAL,
+			recorded_at DATETIME NOT NULL,
+			FOREIGN KEY (node_id) REFERENCES nodes(id) ON DELETE CASCADE
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_node_metrics_node ON node_metrics(node_id, recorded_at)`,
+		// Node metric threshold breaches; resolved_at is NULL while the alert is active
+		`CREATE TABLE IF NOT EXISTS node_alerts (
+			id TEXT PRIMARY KEY,
+			node_id TEXT NOT NULL,
+			metric TEXT NOT NULL,
+			value REAL NOT NULL,
+			threshold REAL NOT NULL,
+			started_at DATETIME NOT NULL,
+			resolved_at DATETIME,
+			FOREIGN KEY (node_id) REFERENCES nodes(id) ON DELETE CASCADE
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_node_alerts_node ON node_alerts(node_id, started_at)`,
+		// Node alert thresholds and receiver, editable through the alerts settings section
+		`ALTER TABLE settings ADD COLUMN alert_disk_percent INTEGER NOT NULL DEFAULT 90`,
+		`ALTER TABLE settings ADD COLUMN alert_memory_percent INTEGER NOT NULL DEFAULT 95`,
+		`ALTER TABLE settings ADD COLUMN alert_load_percent INTEGER NOT NULL DEFAULT 200`,
+		`ALTER TABLE settings ADD COLUMN alert_temperature_celsius INTEGER NOT NULL DEFAULT 85`,
+		`ALTER TABLE settings ADD COLUMN alert_sustained_minutes INTEGER NOT NULL DEFAULT 10`,
+		`ALTER TABLE settings ADD COLUMN alert_webhook_url TEXT NOT NULL DEFAULT ''`,
+		`ALTER TABLE settings ADD COLUMN alert_webhook_secret TEXT NOT NULL DEFAULT ''`,
+		// Extra compose files referenced with include: and extends: file:, as a JSON object of
+		// path -> content, kept with each compose version so rollbacks restore them
+		`ALTER TABLE apps ADD COLUMN compose_files TEXT DEFAULT ''`,
+		`ALTER TABLE compose_versions ADD COLUMN compose_files TEXT DEFAULT ''`,
+		// Managed .env: the template as written and the rendered file, whose generated secrets
+		// are kept across re-renders
+		`ALTER TABLE apps ADD COLUMN env_template TEXT DEFAULT ''`,
+		`ALTER TABLE apps ADD COLUMN env_content TEXT DEFAULT ''`,
+		// Recurring tasks: commands run in an app's services on a cron schedule; command is a JSON
+		// array. Runs keep their exit code and output; only the latest runs of each task are kept.
+		`CREATE TABLE IF NOT EXISTS app_tasks (
+			id TEXT PRIMARY KEY,
+			app_id TEXT NOT NULL,
+			name TEXT NOT NULL,
+			service TEXT NOT NULL,
+			command TEXT NOT NULL,
+			mode TEXT NOT NULL DEFAULT 'run',
+			cron TEXT NOT NULL,
+			timezone TEXT NOT NULL DEFAULT 'UTC',
+			timeout_seconds INTEGER NOT NULL DEFAULT 0,
+			enabled INTEGER NOT NULL DEFAULT 1,
+			last_status TEXT NOT NULL DEFAULT '',
+			last_run_at DATETIME,
+			created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (app_id) REFERENCES apps(id) ON DELETE CASCADE
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_app_tasks_app ON app_tasks(app_id)`,
+		`CREATE TABLE IF NOT EXISTS app_task_runs (
+			id TEXT PRIMARY KEY,
+			task_id TEXT NOT NULL,
+			app_id TEXT NOT NULL,
+			job_id TEXT NOT NULL,
+			status TEXT NOT NULL,
+			exit_code INTEGER NOT NULL DEFAULT 0,
+			timed_out INTEGER NOT NULL DEFAULT 0,
+			output TEXT NOT NULL DEFAULT '',
+			error_message TEXT,
+			started_at DATETIME NOT NULL,
+			finished_at DATETIME NOT NULL,
+			FOREIGN KEY (task_id) REFERENCES app_tasks(id) ON DELETE CASCADE
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_app_task_runs_task ON app_task_runs(task_id, started_at)`,
+		// HMAC keys generated once by the node and never replicated, one row per use
+		`CREATE TABLE IF NOT EXISTS signing_keys (
+			name TEXT PRIMARY KEY,
+			secret TEXT NOT NULL,
+			created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+		)`,
+		// Tokens the gateway uses to fetch the node registry. The token itself is signed and not
+		// stored; a row is what lets it be revoked before it expires.
+		`CREATE TABLE IF NOT EXISTS gateway_tokens (
+			id TEXT PRIMARY KEY,
+			name TEXT NOT NULL,
+			rotated_from TEXT,
+			expires_at DATETIME NOT NULL,
+			last_used_at DATETIME,
+			revoked_at DATETIME,
+			created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+		)`,
+		// Named snapshots of everything an app is deployed from: compose files, .env and the image
+		// digests it was running, so the exact deployment can be restored later
+		`CREATE TABLE IF NOT EXISTS app_snapshots (
+			id TEXT PRIMARY KEY,
+			app_id TEXT NOT NULL,
+			name TEXT NOT NULL,
+			compose_version INTEGER NOT NULL DEFAULT 0,
+			compose_content TEXT NOT NULL,
+			compose_override TEXT DEFAULT '',
+			compose_files TEXT DEFAULT '',
+			env_template TEXT DEFAULT '',
+			env_content TEXT DEFAULT '',
+			image_digests TEXT DEFAULT '',
+			created_by TEXT,
+			created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			UNIQUE (app_id, name),
+			FOREIGN KEY (app_id) REFERENCES apps(id) ON DELETE CASCADE
+		)`,
+		// Server log lines shipped to the primary by secondaries, pruned after a few days
+		`CREATE TABLE IF NOT EXISTS node_logs (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			node_id TEXT NOT NULL,
+			logged_at DATETIME NOT NULL,
+			level TEXT NOT NULL,
+			message TEXT NOT NULL,
+			line TEXT NOT NULL,
+			FOREIGN KEY (node_id) REFERENCES nodes(id) ON DELETE CASCADE
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_node_logs_node ON node_logs(node_id, logged_at)`,
+		// Where apps with log forwarding on ship their container logs, editable through the
+		// log_forwarding settings section
+		`ALTER TABLE settings ADD COLUMN log_forwarding_destination TEXT NOT NULL DEFAULT ''`,
+		`ALTER TABLE settings ADD COLUMN log_forwarding_url TEXT NOT NULL DEFAULT ''`,
+		`ALTER TABLE settings ADD COLUMN log_forwarding_username TEXT NOT NULL DEFAULT ''`,
+		`ALTER TABLE settings ADD COLUMN log_forwarding_password TEXT NOT NULL DEFAULT ''`,
+		`ALTER TABLE settings ADD COLUMN log_forwarding_index TEXT NOT NULL DEFAULT ''`,
+		// Apps whose container logs are forwarded by a log shipping sidecar
+		`CREATE TABLE IF NOT EXISTS app_log_forwarding (
+			app_id TEXT PRIMARY KEY,
+			enabled_at DATETIME NOT NULL,
+			FOREIGN KEY (app_id) REFERENCES apps(id) ON DELETE CASCADE
+		)`,
+		// Per-user UI and CLI preferences
+		`CREATE TABLE IF NOT EXISTS user_preferences (
+			user_id TEXT PRIMARY KEY,
+			default_node_id TEXT NOT NULL DEFAULT '',
+			table_columns TEXT NOT NULL DEFAULT '{}',
+			refresh_interval INTEGER NOT NULL,
+			theme TEXT NOT NULL,
+			updated_at DATETIME NOT NULL
+		)`,
+		// The admin operations lock; at most one row, present while app changes are blocked
+		`CREATE TABLE IF NOT EXISTS operations_lock (
+			id INTEGER PRIMARY KEY CHECK (id = 1),
+			reason TEXT NOT NULL,
+			locked_by TEXT NOT NULL DEFAULT '',
+			locked_at DATETIME NOT NULL
+		)`,
+		// Named storage root (STORAGE_ROOTS) the app directory was created under; '' is APPS_DIR
+		`ALTER TABLE apps ADD COLUMN storage_root TEXT NOT NULL DEFAULT ''`,
+		// What happens to privileged settings in user compose files (block, approval or allow),
+		// editable through the compose_policies settings section
+		`ALTER TABLE settings ADD COLUMN compose_policy_privileged TEXT NOT NULL DEFAULT 'block'`,
+		`ALTER TABLE settings ADD COLUMN compose_policy_host_network TEXT NOT NULL DEFAULT 'block'`,
+		`ALTER TABLE settings ADD COLUMN compose_policy_cap_add TEXT NOT NULL DEFAULT 'block'`,
+		`ALTER TABLE settings ADD COLUMN compose_policy_devices TEXT NOT NULL DEFAULT 'block'`,
+		// Destructive operations waiting for a second confirmation (APPROVAL_REQUIRED), kept once
+		// decided as the audit trail
+		`CREATE TABLE IF NOT EXISTS approvals (
+			id TEXT PRIMARY KEY,
+			operation TEXT NOT NULL,
+			resource_id TEXT NOT NULL,
+			params TEXT NOT NULL DEFAULT '{}',
+			status TEXT NOT NULL,
+			requested_by TEXT NOT NULL DEFAULT '',
+			requested_at DATETIME NOT NULL,
+			expires_at DATETIME NOT NULL,
+			decided_by TEXT NOT NULL DEFAULT '',
+			decided_at DATETIME,
+			error TEXT NOT NULL DEFAULT ''
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_approvals_status ON approvals(status, requested_at)`,
+		// Signed-in user who created the app, whose quota it counts against
+		`ALTER TABLE apps ADD COLUMN owner TEXT NOT NULL DEFAULT ''`,
+		// Per-user quotas, kept on the primary; user '*' is the default quota
+		`CREATE TABLE IF NOT EXISTS user_quotas (
+			user_name TEXT PRIMARY KEY,
+			max_apps INTEGER NOT NULL DEFAULT 0,
+			max_memory_mb INTEGER NOT NULL DEFAULT 0,
+			max_cpus REAL NOT NULL DEFAULT 0,
+			max_tunnels INTEGER NOT NULL DEFAULT 0,
+			updated_at DATETIME NOT NULL
+		)`,
+		// SMTP server email is sent through, editable through the smtp settings section
+		`ALTER TABLE settings ADD COLUMN smtp_host TEXT NOT NULL DEFAULT ''`,
+		`ALTER TABLE settings ADD COLUMN smtp_port INTEGER NOT NULL DEFAULT 587`,
+		`ALTER TABLE settings ADD COLUMN smtp_security TEXT NOT NULL DEFAULT 'starttls'`,
+		`ALTER TABLE settings ADD COLUMN smtp_username TEXT NOT NULL DEFAULT ''`,
+		`ALTER TABLE settings ADD COLUMN smtp_password TEXT NOT NULL DEFAULT ''`,
+		`ALTER TABLE settings ADD COLUMN smtp_from TEXT NOT NULL DEFAULT ''`,
+		// Accounts of invited users, and the invitations that create them; kept on the primary
+		`ALTER TABLE users ADD COLUMN role TEXT NOT NULL DEFAULT 'viewer'`,
+		`ALTER TABLE users ADD COLUMN email TEXT NOT NULL DEFAULT ''`,
+		`ALTER TABLE users ADD COLUMN invited_by TEXT NOT NULL DEFAULT ''`,
+		`CREATE TABLE IF NOT EXISTS invitations (
+			id TEXT PRIMARY KEY,
+			token_hash TEXT NOT NULL UNIQUE,
+			email TEXT NOT NULL DEFAULT '',
+			role TEXT NOT NULL,
+			created_by TEXT NOT NULL DEFAULT '',
+			expires_at DATETIME NOT NULL,
+			accepted_by TEXT NOT NULL DEFAULT '',
+			accepted_at DATETIME,
+			revoked_at DATETIME,
+			created_at DATETIME NOT NULL
+		)`,
+		// Signed-in devices; revoked sessions are refused, and synced to secondaries on heartbeats
+		`CREATE TABLE IF NOT EXISTS sessions (
+			id TEXT PRIMARY KEY,
+			user_name TEXT NOT NULL,
+			user_agent TEXT NOT NULL DEFAULT '',
+			ip TEXT NOT NULL DEFAULT '',
+			created_at DATETIME NOT NULL,
+			last_seen_at DATETIME NOT NULL,
+			revoked_at DATETIME
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_sessions_user ON sessions(user_name, last_seen_at)`,
+		// Every app status change, for uptime and incidents on status pages
+		`CREATE TABLE IF NOT EXISTS app_status_history (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			app_id TEXT NOT NULL,
+			from_status TEXT NOT NULL,
+			to_status TEXT NOT NULL,
+			changed_at DATETIME NOT NULL,
+			FOREIGN KEY (app_id) REFERENCES apps(id) ON DELETE CASCADE
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_app_status_history_app ON app_status_history(app_id, changed_at)`,
+		// Public, token-protected status pages; at most one per app
+		`CREATE TABLE IF NOT EXISTS app_status_pages (
+			app_id TEXT PRIMARY KEY,
+			token_hash TEXT NOT NULL UNIQUE,
+			created_by TEXT NOT NULL DEFAULT '',
+			created_at DATETIME NOT NULL,
+			FOREIGN KEY (app_id) REFERENCES apps(id) ON DELETE CASCADE
+		)`,
+		// Cached app icons, detected from each app's public URL or named by a compose label
+		`CREATE TABLE IF NOT EXISTS app_icons (
+			app_id TEXT PRIMARY KEY,
+			source_url TEXT NOT NULL,
+			icon_url TEXT NOT NULL DEFAULT '',
+			content_type TEXT NOT NULL DEFAULT '',
+			data BLOB,
+			error TEXT NOT NULL DEFAULT '',
+			fetched_at DATETIME NOT NULL,
+			FOREIGN KEY (app_id) REFERENCES apps(id) ON DELETE CASCADE
+		)`,
+		// Checksum of the compose file each version writes to disk, to notice out-of-band edits
+		`ALTER TABLE compose_versions ADD COLUMN checksum TEXT DEFAULT ''`,
+		// Host ports reserved on each node, by app (from its compose) or by hand
+		`CREATE TABLE IF NOT EXISTS port_reservations (
+			id TEXT PRIMARY KEY,
+			node_id TEXT NOT NULL,
+			port INTEGER NOT NULL,
+			protocol TEXT NOT NULL,
+			app_id TEXT,
+			source TEXT NOT NULL,
+			description TEXT NOT NULL DEFAULT '',
+			created_at DATETIME NOT NULL,
+			UNIQUE (node_id, port, protocol),
+			FOREIGN KEY (app_id) REFERENCES apps(id) ON DELETE CASCADE
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_port_reservations_app ON port_reservations(app_id)`,
+		// Per-tunnel cloudflared connector flags (protocol, edge IP version, post-quantum, log level) as JSON
+		`ALTER TABLE cloudflare_tunnels ADD COLUMN options TEXT`,
+		// Job polling and the app list, which otherwise sort the whole table on every call
+		`CREATE INDEX IF NOT EXISTS idx_jobs_status_created ON jobs(status, created_at)`,
+		`CREATE INDEX IF NOT EXISTS idx_apps_created_at ON apps(created_at DESC)`,
+		// Personal access tokens for scripts and CI; only a hash of each token is kept
+		`CREATE TABLE IF NOT EXISTS api_tokens (
+			id TEXT PRIMARY KEY,
+			name TEXT NOT NULL,
+			user_name TEXT NOT NULL,
+			token_hash TEXT NOT NULL UNIQUE,
+			token_prefix TEXT NOT NULL,
+			read_only INTEGER NOT NULL DEFAULT 0,
+			expires_at DATETIME,
+			last_used_at DATETIME,
+			revoked_at DATETIME,
+			created_at DATETIME NOT NULL
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_api_tokens_user ON api_tokens(user_name, created_at)`,
+		// How far a node's clock was from the primary's at its last heartbeat (NULL = never measured)
+		`ALTER TABLE nodes ADD COLUMN clock_skew_ms INTEGER`,
+		// ngrok endpoints, one per app: what the agent sidecar forwards to and the URL it serves
+		`CREATE TABLE IF NOT EXISTS ngrok_tunnels (
+			id TEXT PRIMARY KEY,
+			app_id TEXT NOT NULL UNIQUE,
+			tunnel_name TEXT NOT NULL,
+			upstream TEXT NOT NULL,
+			public_url TEXT NOT NULL DEFAULT '',
+			status TEXT NOT NULL DEFAULT 'active',
+			created_at DATETIME NOT NULL,
+			updated_at DATETIME NOT NULL,
+			FOREIGN KEY (app_id) REFERENCES apps(id) ON DELETE CASCADE
+		)`,
+		// Claim order of pending jobs, so user-initiated operations overtake background work
+		`ALTER TABLE jobs ADD COLUMN priority INTEGER NOT NULL DEFAULT 0`,
+		`CREATE INDEX IF NOT EXISTS idx_jobs_status_priority ON jobs(status, priority DESC, created_at)`,
+		// Tunnels through the user's own frp server, one per app, with the rules frpc registers
+		`CREATE TABLE IF NOT EXISTS frp_tunnels (
+			id TEXT PRIMARY KEY,
+			app_id TEXT NOT NULL UNIQUE,
+			tunnel_name TEXT NOT NULL,
+			ingress_rules TEXT NOT NULL DEFAULT '[]',
+			public_url TEXT NOT NULL DEFAULT '',
+			status TEXT NOT NULL DEFAULT 'active',
+			created_at DATETIME NOT NULL,
+			updated_at DATETIME NOT NULL,
+			FOREIGN KEY (app_id) REFERENCES apps(id) ON DELETE CASCADE
+		)`,
+		// Networks created for each app, by app name so they outlive the app until they are removed
+		`CREATE TABLE IF NOT EXISTS app_networks (
+			app_name TEXT NOT NULL,
+			network TEXT NOT NULL,
+			created_at DATETIME NOT NULL,
+			PRIMARY KEY (app_name, network)
+		)`,
+		// Who queued each operation for an offline node, so it is replayed on their behalf
+		`ALTER TABLE queued_operations ADD COLUMN user_name TEXT NOT NULL DEFAULT ''`,
+		`ALTER TABLE queued_operations ADD COLUMN user_role TEXT NOT NULL DEFAULT ''`,
+		// Reports of the consistency audit, with their findings as JSON
+		`CREATE TABLE IF NOT EXISTS consistency_audits (
+			id TEXT PRIMARY KEY,
+			node_id TEXT NOT NULL,
+			ran_at DATETIME NOT NULL,
+			findings TEXT NOT NULL DEFAULT '[]'
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_consistency_audits_ran_at ON consistency_audits(ran_at DESC)`,
+		// Webhook digest mode and quiet hours, and the non-critical events held back for them
+		`ALTER TABLE app_webhooks ADD COLUMN digest TEXT NOT NULL DEFAULT ''`,
+		`ALTER TABLE app_webhooks ADD COLUMN quiet_hours_start TEXT NOT NULL DEFAULT ''`,
+		`ALTER TABLE app_webhooks ADD COLUMN quiet_hours_end TEXT NOT NULL DEFAULT ''`,
+		`ALTER TABLE app_webhooks ADD COLUMN timezone TEXT NOT NULL DEFAULT 'UTC'`,
+		`CREATE TABLE IF NOT EXISTS webhook_held_events (
+			id TEXT PRIMARY KEY,
+			webhook_id TEXT NOT NULL,
+			payload TEXT NOT NULL,
+			created_at DATETIME NOT NULL,
+			FOREIGN KEY (webhook_id) REFERENCES app_webhooks(id) ON DELETE CASCADE
+		)`,
+		`CREATE INDEX IF NOT EXISTS idx_webhook_held_events_webhook ON webhook_held_events(webhook_id, created_at)`,
+	}
+
+	if err := db.prepareSchemaUpgrade(len(migrations)); err != nil {
+		return err
 	}
 
 	// Run migrations
@@ -329,7 +804,11 @@ func (db *DB) migrate() error {
 	}
 
 	// One-time migration: Check if jobs table needs to be recreated with new columns
-	return db.migrateJobsTableIfNeeded()
+	if err := db.migrateJobsTableIfNeeded(); err != nil {
+		return err
+	}
+
+	return db.setSchemaVersion(len(migrations))
 }
 
 // isDuplicateColumnError checks if error is about duplicate column
@@ -418,6 +897,7 @@ func (db *DB) migrateJobsTableIfNeeded() error {
 		`CREATE INDEX IF NOT EXISTS idx_jobs_app_status ON jobs(app_id, status) WHERE status IN ('pending', 'running')`,
 		`CREATE INDEX IF NOT EXISTS idx_jobs_hash ON jobs(job_hash, status) WHERE job_hash IS NOT NULL`,
 		`CREATE INDEX IF NOT EXISTS idx_jobs_claimed ON jobs(claimed_by, status) WHERE claimed_by IS NOT NULL`,
+		`CREATE INDEX IF NOT EXISTS idx_jobs_status_created ON jobs(status, created_at)`,
 	}
 
 	for _, indexSQL := range indexes {
@@ -430,9 +910,31 @@ func (db *DB) migrateJobsTableIfNeeded() error {
 	return nil
 }
 
+// tunnelProviderOverride, when set, is the active tunnel provider for this process regardless of
+// the saved setting
+var tunnelProviderOverride atomic.Pointer[string]
+
+// OverrideTunnelProvider makes providerName the active tunnel provider for this process, with an
+// empty config unless one is saved, e.g. the fake provider in chaos mode. Nothing is saved, so
+// the settings are as they were once the process runs without the override.
+func OverrideTunnelProvider(providerName string) {
+	tunnelProviderOverride.Store(&providerName)
+}
+
 // GetProviderConfig parses the tunnel_provider_config JSON and returns configuration
 // for the specified provider.
 func (settings *Settings) GetProviderConfig(providerName string) (map[string]interface{}, error) {
+	if override := tunnelProviderOverride.Load(); override != nil && *override == providerName {
+		if config, err := settings.savedProviderConfig(providerName); err == nil {
+			return config, nil
+		}
+		return map[string]interface{}{}, nil
+	}
+	return settings.savedProviderConfig(providerName)
+}
+
+// savedProviderConfig returns the saved configuration of the specified provider
+func (settings *Settings) savedProviderConfig(providerName string) (map[string]interface{}, error) {
 	if settings.TunnelProviderConfig == nil || *settings.TunnelProviderConfig == "" {
 		return nil, fmt.Errorf("provider %s not configured", providerName)
 	}
@@ -453,6 +955,9 @@ func (settings *Settings) GetProviderConfig(providerName string) (map[string]int
 
 // GetActiveProviderName returns the active tunnel provider name.
 func (settings *Settings) GetActiveProviderName() string {
+	if override := tunnelProviderOverride.Load(); override != nil {
+		return *override
+	}
 	if settings.ActiveTunnelProvider != nil && *settings.ActiveTunnelProvider != "" {
 		return *settings.ActiveTunnelProvider
 	}
@@ -460,6 +965,36 @@ func (settings *Settings) GetActiveProviderName() string {
 	return constants.DefaultProviderName // Default
 }
 
+// GetFeatureFlags parses the feature_flags JSON into flag name -> enabled. Flags that were never
+// set are absent.
+func (settings *Settings) GetFeatureFlags() (map[string]bool, error) {
+	flags := make(map[string]bool)
+	if settings.FeatureFlags == nil || *settings.FeatureFlags == "" {
+		return flags, nil
+	}
+	if err := json.Unmarshal([]byte(*settings.FeatureFlags), &flags); err != nil {
+		return nil, fmt.Errorf("failed to parse feature flags: %w", err)
+	}
+	return flags, nil
+}
+
+// SetFeatureFlag records a feature flag's value.
+func (settings *Settings) SetFeatureFlag(name string, enabled bool) error {
+	flags, err := settings.GetFeatureFlags()
+	if err != nil {
+		return err
+	}
+	flags[name] = enabled
+
+	flagsJSON, err := json.Marshal(flags)
+	if err != nil {
+		return fmt.Errorf("failed to marshal feature flags: %w", err)
+	}
+	flagsStr := string(flagsJSON)
+	settings.FeatureFlags = &flagsStr
+	return nil
+}
+
 // SetProviderConfig updates the configuration for a specific provider.
 func (settings *Settings) SetProviderConfig(providerName string, config map[string]interface{}) error {
 	var providerConfigs map[string]interface{}
@@ -514,9 +1049,18 @@ func (db *DB) CreateApp(app *App) error {
 		errorMessage = nil
 	}
 
-	_, err := db.Exec(
-		"INSERT INTO apps (id, name, description, compose_content, tunnel_token, tunnel_id, tunnel_domain, public_url, status, error_message, node_id, tunnel_mode, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
-		app.ID, app.Name, app.Description, app.ComposeContent, app.TunnelToken, app.TunnelID, app.TunnelDomain, app.PublicURL, app.Status, errorMessage, app.NodeID, app.TunnelMode, app.CreatedAt, time.Now(),
+	labels, err := encodeLabels(app.Labels)
+	if err != nil {
+		return err
+	}
+	composeFiles, err := encodeComposeFiles(app.ComposeFiles)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Exec(
+		"INSERT INTO apps (id, name, description, compose_content, compose_override, tunnel_compose, tunnel_token, tunnel_id, tunnel_domain, public_url, status, error_message, node_id, tunnel_mode, labels, compose_files, env_template, env_content, storage_root, owner, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		app.ID, app.Name, app.Description, app.ComposeContent, app.ComposeOverride, app.TunnelCompose, app.TunnelToken, app.TunnelID, app.TunnelDomain, app.PublicURL, app.Status, errorMessage, app.NodeID, app.TunnelMode, labels, composeFiles, app.EnvTemplate, app.EnvContent, app.StorageRoot, app.Owner, app.CreatedAt, time.Now(),
 	)
 	if err != nil {
 		return err
@@ -529,7 +1073,7 @@ func (db *DB) CreateApp(app *App) error {
 // SECURITY: Returns ALL apps without user filtering (single-user design)
 // For multi-user support, implement GetUserApps(userID string) instead
 func (db *DB) GetAllApps() ([]*App, error) {
-	rows, err := db.Query("SELECT id, name, description, compose_content, tunnel_token, tunnel_id, tunnel_domain, public_url, status, error_message, node_id, tunnel_mode, created_at, updated_at FROM apps ORDER BY created_at DESC")
+	rows, err := db.Query("SELECT id, name, description, compose_content, compose_override, tunnel_compose, tunnel_token, tunnel_id, tunnel_domain, public_url, status, error_message, node_id, tunnel_mode, labels, compose_files, env_template, env_content, storage_root, owner, created_at, updated_at FROM apps ORDER BY created_at DESC")
 	if err != nil {
 		return nil, err
 	}
@@ -540,10 +1084,17 @@ func (db *DB) GetAllApps() ([]*App, error) {
 		app := &App{}
 		var errorMessage sql.NullString
 		var nodeID sql.NullString
-		err := rows.Scan(&app.ID, &app.Name, &app.Description, &app.ComposeContent, &app.TunnelToken, &app.TunnelID, &app.TunnelDomain, &app.PublicURL, &app.Status, &errorMessage, &nodeID, &app.TunnelMode, &app.CreatedAt, &app.UpdatedAt)
+		var composeOverride, tunnelCompose, labels, composeFiles, envTemplate, envContent sql.NullString
+		err := rows.Scan(&app.ID, &app.Name, &app.Description, &app.ComposeContent, &composeOverride, &tunnelCompose, &app.TunnelToken, &app.TunnelID, &app.TunnelDomain, &app.PublicURL, &app.Status, &errorMessage, &nodeID, &app.TunnelMode, &labels, &composeFiles, &envTemplate, &envContent, &app.StorageRoot, &app.Owner, &app.CreatedAt, &app.UpdatedAt)
 		if err != nil {
 			return nil, err
 		}
+		if app.Labels, err = decodeLabels(app.ID, labels.String); err != nil {
+			return nil, err
+		}
+		if app.ComposeFiles, err = decodeComposeFiles(app.ID, composeFiles.String); err != nil {
+			return nil, err
+		}
 		if errorMessage.Valid {
 			app.ErrorMessage = &errorMessage.String
 		} else {
@@ -554,18 +1105,53 @@ func (db *DB) GetAllApps() ([]*App, error) {
 		} else {
 			app.NodeID = ""
 		}
+		app.ComposeOverride = composeOverride.String
+		app.TunnelCompose = tunnelCompose.String
+		app.EnvTemplate = envTemplate.String
+		app.EnvContent = envContent.String
 		apps = append(apps, app)
 	}
 
 	return apps, nil
 }
 
+// GetAllAppsMetadata retrieves all apps without their compose and env files, which is all that
+// status checks and inventories need; at hundreds of apps it reads a fraction of GetAllApps' data.
+// The rows are partial: load an app with GetApp before saving it with UpdateApp.
+func (db *DB) GetAllAppsMetadata() ([]*App, error) {
+	rows, err := db.Query("SELECT " + appListColumns + " FROM apps ORDER BY created_at DESC")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var apps []*App
+	for rows.Next() {
+		app := &App{}
+		var errorMessage, nodeID, labels sql.NullString
+		err := rows.Scan(&app.ID, &app.Name, &app.Description, &app.TunnelToken, &app.TunnelID, &app.TunnelDomain, &app.PublicURL, &app.Status, &errorMessage, &nodeID, &app.TunnelMode, &labels, &app.StorageRoot, &app.Owner, &app.CreatedAt, &app.UpdatedAt)
+		if err != nil {
+			return nil, err
+		}
+		if app.Labels, err = decodeLabels(app.ID, labels.String); err != nil {
+			return nil, err
+		}
+		if errorMessage.Valid {
+			app.ErrorMessage = &errorMessage.String
+		}
+		app.NodeID = nodeID.String
+		apps = append(apps, app)
+	}
+
+	return apps, rows.Err()
+}
+
 // GetAllAppsWithSchedules retrieves all apps with their schedules using a LEFT JOIN
 func (db *DB) GetAllAppsWithSchedules() ([]*App, error) {
 	query := `
 		SELECT 
-			a.id, a.name, a.description, a.compose_content, a.tunnel_token, a.tunnel_id, 
-			a.tunnel_domain, a.public_url, a.status, a.error_message, a.node_id, a.tunnel_mode, 
+			a.id, a.name, a.description, a.compose_content, a.compose_override, a.tunnel_compose, a.tunnel_token, a.tunnel_id, 
+			a.tunnel_domain, a.public_url, a.status, a.error_message, a.node_id, a.tunnel_mode, a.labels, a.compose_files, a.env_template, a.env_content, a.storage_root, a.owner,
 			a.created_at, a.updated_at,
 			s.id, s.app_id, s.start_cron, s.stop_cron, s.timezone, s.enabled, 
 			s.created_at, s.updated_at
@@ -585,6 +1171,7 @@ func (db *DB) GetAllAppsWithSchedules() ([]*App, error) {
 		app := &App{}
 		var errorMessage sql.NullString
 		var nodeID sql.NullString
+		var composeOverride, tunnelCompose, labels, composeFiles, envTemplate, envContent sql.NullString
 		
 		// Schedule fields (nullable since LEFT JOIN)
 		var scheduleID, scheduleAppID, startCron, stopCron, timezone sql.NullString
@@ -592,9 +1179,9 @@ func (db *DB) GetAllAppsWithSchedules() ([]*App, error) {
 		var scheduleCreatedAt, scheduleUpdatedAt sql.NullTime
 		
 		err := rows.Scan(
-			&app.ID, &app.Name, &app.Description, &app.ComposeContent, &app.TunnelToken, 
+			&app.ID, &app.Name, &app.Description, &app.ComposeContent, &composeOverride, &tunnelCompose, &app.TunnelToken, 
 			&app.TunnelID, &app.TunnelDomain, &app.PublicURL, &app.Status, &errorMessage, 
-			&nodeID, &app.TunnelMode, &app.CreatedAt, &app.UpdatedAt,
+			&nodeID, &app.TunnelMode, &labels, &composeFiles, &envTemplate, &envContent, &app.StorageRoot, &app.Owner, &app.CreatedAt, &app.UpdatedAt,
 			&scheduleID, &scheduleAppID, &startCron, &stopCron, &timezone, &scheduleEnabled,
 			&scheduleCreatedAt, &scheduleUpdatedAt,
 		)
@@ -608,6 +1195,16 @@ func (db *DB) GetAllAppsWithSchedules() ([]*App, error) {
 		if nodeID.Valid {
 			app.NodeID = nodeID.String
 		}
+		app.ComposeOverride = composeOverride.String
+		app.TunnelCompose = tunnelCompose.String
+		app.EnvTemplate = envTemplate.String
+		app.EnvContent = envContent.String
+		if app.Labels, err = decodeLabels(app.ID, labels.String); err != nil {
+			return nil, err
+		}
+		if app.ComposeFiles, err = decodeComposeFiles(app.ID, composeFiles.String); err != nil {
+			return nil, err
+		}
 		
 		// Construct schedule if it exists
 		if scheduleID.Valid {
@@ -634,10 +1231,11 @@ func (db *DB) GetApp(id string) (*App, error) {
 	app := &App{}
 	var errorMessage sql.NullString
 	var nodeID sql.NullString
+	var composeOverride, tunnelCompose, labels, composeFiles, envTemplate, envContent sql.NullString
 	err := db.QueryRow(
-		"SELECT id, name, description, compose_content, tunnel_token, tunnel_id, tunnel_domain, public_url, status, error_message, node_id, tunnel_mode, created_at, updated_at FROM apps WHERE id = ?",
+		"SELECT id, name, description, compose_content, compose_override, tunnel_compose, tunnel_token, tunnel_id, tunnel_domain, public_url, status, error_message, node_id, tunnel_mode, labels, compose_files, env_template, env_content, storage_root, owner, created_at, updated_at FROM apps WHERE id = ?",
 		id,
-	).Scan(&app.ID, &app.Name, &app.Description, &app.ComposeContent, &app.TunnelToken, &app.TunnelID, &app.TunnelDomain, &app.PublicURL, &app.Status, &errorMessage, &nodeID, &app.TunnelMode, &app.CreatedAt, &app.UpdatedAt)
+	).Scan(&app.ID, &app.Name, &app.Description, &app.ComposeContent, &composeOverride, &tunnelCompose, &app.TunnelToken, &app.TunnelID, &app.TunnelDomain, &app.PublicURL, &app.Status, &errorMessage, &nodeID, &app.TunnelMode, &labels, &composeFiles, &envTemplate, &envContent, &app.StorageRoot, &app.Owner, &app.CreatedAt, &app.UpdatedAt)
 
 	if err == nil {
 		if errorMessage.Valid {
@@ -650,6 +1248,14 @@ func (db *DB) GetApp(id string) (*App, error) {
 		} else {
 			app.NodeID = ""
 		}
+		app.ComposeOverride = composeOverride.String
+		app.TunnelCompose = tunnelCompose.String
+		app.EnvTemplate = envTemplate.String
+		app.EnvContent = envContent.String
+		app.Labels, err = decodeLabels(app.ID, labels.String)
+	}
+	if err == nil {
+		app.ComposeFiles, err = decodeComposeFiles(app.ID, composeFiles.String)
 	}
 	return app, err
 }
@@ -663,15 +1269,76 @@ func (db *DB) UpdateApp(app *App) error {
 		errorMessage = nil
 	}
 
-	_, err := db.Exec(
-		"UPDATE apps SET name = ?, description = ?, compose_content = ?, tunnel_token = ?, tunnel_id = ?, tunnel_domain = ?, public_url = ?, status = ?, error_message = ?, tunnel_mode = ?, updated_at = ? WHERE id = ?",
-		app.Name, app.Description, app.ComposeContent, app.TunnelToken, app.TunnelID, app.TunnelDomain, app.PublicURL, app.Status, errorMessage, app.TunnelMode, time.Now(), app.ID,
+	labels, err := encodeLabels(app.Labels)
+	if err != nil {
+		return err
+	}
+	composeFiles, err := encodeComposeFiles(app.ComposeFiles)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Exec(
+		"UPDATE apps SET name = ?, description = ?, compose_content = ?, compose_override = ?, tunnel_compose = ?, tunnel_token = ?, tunnel_id = ?, tunnel_domain = ?, public_url = ?, status = ?, error_message = ?, tunnel_mode = ?, labels = ?, compose_files = ?, env_template = ?, env_content = ?, updated_at = ? WHERE id = ?",
+		app.Name, app.Description, app.ComposeContent, app.ComposeOverride, app.TunnelCompose, app.TunnelToken, app.TunnelID, app.TunnelDomain, app.PublicURL, app.Status, errorMessage, app.TunnelMode, labels, composeFiles, app.EnvTemplate, app.EnvContent, time.Now(), app.ID,
 	)
 	return err
 }
 
-// DeleteApp deletes an app
+// encodeLabels stores app labels as a JSON object; apps without labels store ""
+func encodeLabels(labels map[string]string) (string, error) {
+	if len(labels) == 0 {
+		return "", nil
+	}
+	data, err := json.Marshal(labels)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// encodeComposeFiles stores an app's extra compose files as a JSON object of path -> content;
+// apps without any store ""
+func encodeComposeFiles(files map[string]string) (string, error) {
+	if len(files) == 0 {
+		return "", nil
+	}
+	data, err := json.Marshal(files)
+	if err != nil {
+		return "", fmt.Errorf("failed to encode compose files: %w", err)
+	}
+	return string(data), nil
+}
+
+// decodeComposeFiles reads compose files stored by encodeComposeFiles
+func decodeComposeFiles(appID, data string) (map[string]string, error) {
+	if data == "" {
+		return nil, nil
+	}
+	var files map[string]string
+	if err := json.Unmarshal([]byte(data), &files); err != nil {
+		return nil, fmt.Errorf("invalid compose files for app %s: %w", appID, err)
+	}
+	return files, nil
+}
+
+// decodeLabels reads labels stored by encodeLabels
+func decodeLabels(appID, data string) (map[string]string, error) {
+	if data == "" {
+		return nil, nil
+	}
+	var labels map[string]string
+	if err := json.Unmarshal([]byte(data), &labels); err != nil {
+		return nil, fmt.Errorf("invalid labels for app %s: %w", appID, err)
+	}
+	return labels, nil
+}
+
+// DeleteApp deletes an app, releasing its host ports
 func (db *DB) DeleteApp(id string) error {
+	if _, err := db.Exec("DELETE FROM port_reservations WHERE app_id = ?", id); err != nil {
+		return err
+	}
 	_, err := db.Exec("DELETE FROM apps WHERE id = ?", id)
 	return err
 }
@@ -679,16 +1346,20 @@ func (db *DB) DeleteApp(id string) error {
 // GetSettings retrieves the settings
 func (db *DB) GetSettings() (*Settings, error) {
 	settings := &Settings{}
-	var apiToken, accountID, activeTunnelProvider, tunnelProviderConfig sql.NullString
+	var apiToken, accountID, activeTunnelProvider, tunnelProviderConfig, telemetryID, featureFlags sql.NullString
 	err := db.QueryRow(
-		"SELECT id, cloudflare_api_token, cloudflare_account_id, auto_start_apps, active_tunnel_provider, tunnel_provider_config, updated_at FROM settings LIMIT 1",
-	).Scan(&settings.ID, &apiToken, &accountID, &settings.AutoStartApps, &activeTunnelProvider, &tunnelProviderConfig, &settings.UpdatedAt)
+		"SELECT id, cloudflare_api_token, cloudflare_account_id, auto_start_apps, active_tunnel_provider, tunnel_provider_config, telemetry_enabled, telemetry_id, feature_flags, job_history_keep_count, job_stale_threshold_minutes, alert_disk_percent, alert_memory_percent, alert_load_percent, alert_temperature_celsius, alert_sustained_minutes, alert_webhook_url, alert_webhook_secret, log_forwarding_destination, log_forwarding_url, log_forwarding_username, log_forwarding_password, log_forwarding_index, compose_policy_privileged, compose_policy_host_network, compose_policy_cap_add, compose_policy_devices, smtp_host, smtp_port, smtp_security, smtp_username, smtp_password, smtp_from, updated_at FROM settings LIMIT 1",
+	).Scan(&settings.ID, &apiToken, &accountID, &settings.AutoStartApps, &activeTunnelProvider, &tunnelProviderConfig, &settings.TelemetryEnabled, &telemetryID, &featureFlags, &settings.JobHistoryKeepCount, &settings.JobStaleThresholdMinutes,
+		&settings.AlertDiskPercent, &settings.AlertMemoryPercent, &settings.AlertLoadPercent, &settings.AlertTemperatureCelsius, &settings.AlertSustainedMinutes, &settings.AlertWebhookURL, &settings.AlertWebhookSecret,
+		&settings.LogForwardingDestination, &settings.LogForwardingURL, &settings.LogForwardingUsername, &settings.LogForwardingPassword, &settings.LogForwardingIndex,
+		&settings.ComposePolicyPrivileged, &settings.ComposePolicyHostNetwork, &settings.ComposePolicyCapAdd, &settings.ComposePolicyDevices,
+		&settings.SMTPHost, &settings.SMTPPort, &settings.SMTPSecurity, &settings.SMTPUsername, &settings.SMTPPassword, &settings.SMTPFrom, &settings.UpdatedAt)
 
 	if err != nil {
 		// If no settings exist, create default settings
 		if strings.Contains(err.Error(), "no rows in result set") {
 			settings = NewSettings()
-			if err := db.UpdateSettings(settings); err != nil {
+			if _, err := db.Exec("INSERT INTO settings (id, auto_start_apps, updated_at) VALUES (?, ?, ?)", settings.ID, settings.AutoStartApps, settings.UpdatedAt); err != nil {
 				return nil, err
 			}
 			return settings, nil
@@ -709,6 +1380,12 @@ func (db *DB) GetSettings() (*Settings, error) {
 	if tunnelProviderConfig.Valid {
 		settings.TunnelProviderConfig = &tunnelProviderConfig.String
 	}
+	if telemetryID.Valid {
+		settings.TelemetryID = telemetryID.String
+	}
+	if featureFlags.Valid {
+		settings.FeatureFlags = &featureFlags.String
+	}
 
 	return settings, nil
 }
@@ -736,9 +1413,20 @@ func (db *DB) UpdateSettings(settings *Settings) error {
 	} else {
 		tunnelProviderConfig = nil
 	}
+	var telemetryID, featureFlags interface{}
+	if settings.TelemetryID != "" {
+		telemetryID = settings.TelemetryID
+	}
+	if settings.FeatureFlags != nil {
+		featureFlags = *settings.FeatureFlags
+	}
 	_, err := db.Exec(
-		"UPDATE settings SET cloudflare_api_token = ?, cloudflare_account_id = ?, auto_start_apps = ?, active_tunnel_provider = ?, tunnel_provider_config = ?, updated_at = ? WHERE id = ?",
-		apiToken, accountID, settings.AutoStartApps, activeTunnelProvider, tunnelProviderConfig, time.Now(), settings.ID,
+		"UPDATE settings SET cloudflare_api_token = ?, cloudflare_account_id = ?, auto_start_apps = ?, active_tunnel_provider = ?, tunnel_provider_config = ?, telemetry_enabled = ?, telemetry_id = ?, feature_flags = ?, job_history_keep_count = ?, job_stale_threshold_minutes = ?, alert_disk_percent = ?, alert_memory_percent = ?, alert_load_percent = ?, alert_temperature_celsius = ?, alert_sustained_minutes = ?, alert_webhook_url = ?, alert_webhook_secret = ?, log_forwarding_destination = ?, log_forwarding_url = ?, log_forwarding_username = ?, log_forwarding_password = ?, log_forwarding_index = ?, compose_policy_privileged = ?, compose_policy_host_network = ?, compose_policy_cap_add = ?, compose_policy_devices = ?, smtp_host = ?, smtp_port = ?, smtp_security = ?, smtp_username = ?, smtp_password = ?, smtp_from = ?, updated_at = ? WHERE id = ?",
+		apiToken, accountID, settings.AutoStartApps, activeTunnelProvider, tunnelProviderConfig, settings.TelemetryEnabled, telemetryID, featureFlags, settings.JobHistoryKeepCount, settings.JobStaleThresholdMinutes,
+		settings.AlertDiskPercent, settings.AlertMemoryPercent, settings.AlertLoadPercent, settings.AlertTemperatureCelsius, settings.AlertSustainedMinutes, settings.AlertWebhookURL, settings.AlertWebhookSecret,
+		settings.LogForwardingDestination, settings.LogForwardingURL, settings.LogForwardingUsername, settings.LogForwardingPassword, settings.LogForwardingIndex,
+		settings.ComposePolicyPrivileged, settings.ComposePolicyHostNetwork, settings.ComposePolicyCapAdd, settings.ComposePolicyDevices,
+		settings.SMTPHost, settings.SMTPPort, settings.SMTPSecurity, settings.SMTPUsername, settings.SMTPPassword, settings.SMTPFrom, time.Now(), settings.ID,
 	)
 	return err
 }
@@ -746,8 +1434,8 @@ func (db *DB) UpdateSettings(settings *Settings) error {
 // CreateUser creates a new user
 func (db *DB) CreateUser(user *User) error {
 	_, err := db.Exec(
-		"INSERT INTO users (id, username, password, created_at) VALUES (?, ?, ?, ?)",
-		user.ID, user.Username, user.Password, user.CreatedAt,
+		"INSERT INTO users (id, username, password, role, email, invited_by, created_at) VALUES (?, ?, ?, ?, ?, ?, ?)",
+		user.ID, user.Username, user.Password, user.Role, user.Email, user.InvitedBy, user.CreatedAt,
 	)
 	if err != nil {
 		return err
@@ -758,12 +1446,7 @@ func (db *DB) CreateUser(user *User) error {
 
 // GetUser retrieves a user by username
 func (db *DB) GetUser(username string) (*User, error) {
-	user := &User{}
-	err := db.QueryRow(
-		"SELECT id, username, password, created_at FROM users WHERE username = ?",
-		username,
-	).Scan(&user.ID, &user.Username, &user.Password, &user.CreatedAt)
-	return user, err
+	return scanUser(db.QueryRow(`SELECT `+userColumns+` FROM users WHERE username = ?`, username))
 }
 
 // CreateCloudflareTunnel creates a new Cloudflare tunnel record
@@ -787,8 +1470,8 @@ func (db *DB) CreateCloudflareTunnel(tunnel *CloudflareTunnel) error {
 	}
 
 	_, err := db.Exec(
-		"INSERT INTO cloudflare_tunnels (id, app_id, tunnel_id, tunnel_name, tunnel_token, account_id, is_active, status, ingress_rules, created_at, updated_at, last_synced_at, error_details) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
-		tunnel.ID, tunnel.AppID, tunnel.TunnelID, tunnel.TunnelName, tunnel.TunnelToken, tunnel.AccountID, tunnel.IsActive, tunnel.Status, ingressRules, tunnel.CreatedAt, time.Now(), tunnel.LastSyncedAt, errorDetails,
+		"INSERT INTO cloudflare_tunnels (id, app_id, tunnel_id, tunnel_name, tunnel_token, account_id, is_active, status, ingress_rules, created_at, updated_at, last_synced_at, error_details, options) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		tunnel.ID, tunnel.AppID, tunnel.TunnelID, tunnel.TunnelName, tunnel.TunnelToken, tunnel.AccountID, tunnel.IsActive, tunnel.Status, ingressRules, tunnel.CreatedAt, time.Now(), tunnel.LastSyncedAt, errorDetails, encodeTunnelOptions(tunnel.Options),
 	)
 	if err != nil {
 		return err
@@ -802,14 +1485,17 @@ func (db *DB) GetCloudflareTunnelByAppID(appID string) (*CloudflareTunnel, error
 	tunnel := &CloudflareTunnel{}
 	var errorDetails sql.NullString
 	var lastSyncedAt, ingressRules interface{} // Use interface{} to handle NULL values
-	var publicURL sql.NullString
+	var publicURL, options sql.NullString
 	err := db.QueryRow(
-		"SELECT id, app_id, tunnel_id, tunnel_name, tunnel_token, account_id, is_active, status, ingress_rules, public_url, created_at, updated_at, last_synced_at, error_details FROM cloudflare_tunnels WHERE app_id = ?",
+		"SELECT id, app_id, tunnel_id, tunnel_name, tunnel_token, account_id, is_active, status, ingress_rules, public_url, created_at, updated_at, last_synced_at, error_details, options FROM cloudflare_tunnels WHERE app_id = ?",
 		appID,
-	).Scan(&tunnel.ID, &tunnel.AppID, &tunnel.TunnelID, &tunnel.TunnelName, &tunnel.TunnelToken, &tunnel.AccountID, &tunnel.IsActive, &tunnel.Status, &ingressRules, &publicURL, &tunnel.CreatedAt, &tunnel.UpdatedAt, &lastSyncedAt, &errorDetails)
+	).Scan(&tunnel.ID, &tunnel.AppID, &tunnel.TunnelID, &tunnel.TunnelName, &tunnel.TunnelToken, &tunnel.AccountID, &tunnel.IsActive, &tunnel.Status, &ingressRules, &publicURL, &tunnel.CreatedAt, &tunnel.UpdatedAt, &lastSyncedAt, &errorDetails, &options)
 	if err == nil && publicURL.Valid {
 		tunnel.PublicURL = publicURL.String
 	}
+	if err == nil {
+		tunnel.Options = decodeTunnelOptions(options)
+	}
 
 	// Handle NULL last_synced_at
 	if err == nil {
@@ -873,12 +1559,36 @@ func (db *DB) UpdateCloudflareTunnel(tunnel *CloudflareTunnel) error {
 	}
 
 	_, err := db.Exec(
-		"UPDATE cloudflare_tunnels SET tunnel_name = ?, is_active = ?, status = ?, ingress_rules = ?, public_url = ?, updated_at = ?, last_synced_at = ?, error_details = ? WHERE id = ?",
-		tunnel.TunnelName, tunnel.IsActive, tunnel.Status, ingressRules, tunnel.PublicURL, time.Now(), tunnel.LastSyncedAt, errorDetails, tunnel.ID,
+		"UPDATE cloudflare_tunnels SET tunnel_name = ?, is_active = ?, status = ?, ingress_rules = ?, public_url = ?, updated_at = ?, last_synced_at = ?, error_details = ?, options = ? WHERE id = ?",
+		tunnel.TunnelName, tunnel.IsActive, tunnel.Status, ingressRules, tunnel.PublicURL, time.Now(), tunnel.LastSyncedAt, errorDetails, encodeTunnelOptions(tunnel.Options), tunnel.ID,
 	)
 	return err
 }
 
+// encodeTunnelOptions serializes tunnel options for storage; nil or empty options are stored as NULL
+func encodeTunnelOptions(options *TunnelOptions) interface{} {
+	if options == nil || *options == (TunnelOptions{}) {
+		return nil
+	}
+	data, err := json.Marshal(options)
+	if err != nil {
+		return nil
+	}
+	return string(data)
+}
+
+// decodeTunnelOptions reads options stored by encodeTunnelOptions, ignoring unreadable values like ingress_rules does
+func decodeTunnelOptions(data sql.NullString) *TunnelOptions {
+	if !data.Valid || data.String == "" {
+		return nil
+	}
+	var options TunnelOptions
+	if err := json.Unmarshal([]byte(data.String), &options); err != nil {
+		return nil
+	}
+	return &options
+}
+
 // DeleteCloudflareTunnel deletes a Cloudflare tunnel record
 func (db *DB) DeleteCloudflareTunnel(appID string) error {
 	_, err := db.Exec("DELETE FROM cloudflare_tunnels WHERE app_id = ?", appID)
@@ -888,15 +1598,18 @@ func (db *DB) DeleteCloudflareTunnel(appID string) error {
 // GetCloudflareTunnelByTunnelID retrieves a Cloudflare tunnel by tunnel ID
 func (db *DB) GetCloudflareTunnelByTunnelID(tunnelID string) (*CloudflareTunnel, error) {
 	tunnel := &CloudflareTunnel{}
-	var errorDetails, publicURL sql.NullString
+	var errorDetails, publicURL, options sql.NullString
 	var lastSyncedAt, ingressRules interface{}
 	err := db.QueryRow(
-		"SELECT id, app_id, tunnel_id, tunnel_name, tunnel_token, account_id, is_active, status, ingress_rules, public_url, created_at, updated_at, last_synced_at, error_details FROM cloudflare_tunnels WHERE tunnel_id = ?",
+		"SELECT id, app_id, tunnel_id, tunnel_name, tunnel_token, account_id, is_active, status, ingress_rules, public_url, created_at, updated_at, last_synced_at, error_details, options FROM cloudflare_tunnels WHERE tunnel_id = ?",
 		tunnelID,
-	).Scan(&tunnel.ID, &tunnel.AppID, &tunnel.TunnelID, &tunnel.TunnelName, &tunnel.TunnelToken, &tunnel.AccountID, &tunnel.IsActive, &tunnel.Status, &ingressRules, &publicURL, &tunnel.CreatedAt, &tunnel.UpdatedAt, &lastSyncedAt, &errorDetails)
+	).Scan(&tunnel.ID, &tunnel.AppID, &tunnel.TunnelID, &tunnel.TunnelName, &tunnel.TunnelToken, &tunnel.AccountID, &tunnel.IsActive, &tunnel.Status, &ingressRules, &publicURL, &tunnel.CreatedAt, &tunnel.UpdatedAt, &lastSyncedAt, &errorDetails, &options)
 	if err == nil && publicURL.Valid {
 		tunnel.PublicURL = publicURL.String
 	}
+	if err == nil {
+		tunnel.Options = decodeTunnelOptions(options)
+	}
 
 	// Handle NULL last_synced_at
 	if err == nil {
@@ -941,7 +1654,7 @@ func (db *DB) GetCloudflareTunnelByTunnelID(tunnelID string) (*CloudflareTunnel,
 
 // ListActiveCloudflareTunnels retrieves all active Cloudflare tunnels
 func (db *DB) ListActiveCloudflareTunnels() ([]*CloudflareTunnel, error) {
-	rows, err := db.Query("SELECT id, app_id, tunnel_id, tunnel_name, tunnel_token, account_id, is_active, status, ingress_rules, public_url, created_at, updated_at, last_synced_at, error_details FROM cloudflare_tunnels WHERE is_active = 1 ORDER BY created_at DESC")
+	rows, err := db.Query("SELECT id, app_id, tunnel_id, tunnel_name, tunnel_token, account_id, is_active, status, ingress_rules, public_url, created_at, updated_at, last_synced_at, error_details, options FROM cloudflare_tunnels WHERE is_active = 1 ORDER BY created_at DESC")
 	if err != nil {
 		return nil, err
 	}
@@ -951,14 +1664,15 @@ func (db *DB) ListActiveCloudflareTunnels() ([]*CloudflareTunnel, error) {
 	for rows.Next() {
 		tunnel := &CloudflareTunnel{}
 		var lastSyncedAt, ingressRules interface{}
-		var errorDetails, publicURL sql.NullString
-		err := rows.Scan(&tunnel.ID, &tunnel.AppID, &tunnel.TunnelID, &tunnel.TunnelName, &tunnel.TunnelToken, &tunnel.AccountID, &tunnel.IsActive, &tunnel.Status, &ingressRules, &publicURL, &tunnel.CreatedAt, &tunnel.UpdatedAt, &lastSyncedAt, &errorDetails)
+		var errorDetails, publicURL, options sql.NullString
+		err := rows.Scan(&tunnel.ID, &tunnel.AppID, &tunnel.TunnelID, &tunnel.TunnelName, &tunnel.TunnelToken, &tunnel.AccountID, &tunnel.IsActive, &tunnel.Status, &ingressRules, &publicURL, &tunnel.CreatedAt, &tunnel.UpdatedAt, &lastSyncedAt, &errorDetails, &options)
 		if err != nil {
 			return nil, err
 		}
 		if publicURL.Valid {
 			tunnel.PublicURL = publicURL.String
 		}
+		tunnel.Options = decodeTunnelOptions(options)
 
 		// Handle NULL last_synced_at
 		if lastSyncedAt != nil {
@@ -1021,16 +1735,21 @@ func (db *DB) CreateComposeVersion(version *ComposeVersion) error {
 		rolledBackFrom = nil
 	}
 
-	_, err := db.Exec(
-		"INSERT INTO compose_versions (id, app_id, version, compose_content, change_reason, changed_by, is_current, created_at, rolled_back_from) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)",
-		version.ID, version.AppID, version.Version, version.ComposeContent, changeReason, changedBy, version.IsCurrent, version.CreatedAt, rolledBackFrom,
+	composeFiles, err := encodeComposeFiles(version.ComposeFiles)
+	if err != nil {
+		return err
+	}
+
+	_, err = db.Exec(
+		"INSERT INTO compose_versions (id, app_id, version, compose_content, compose_override, compose_files, image_digests, change_reason, changed_by, is_current, created_at, rolled_back_from, checksum) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		version.ID, version.AppID, version.Version, version.ComposeContent, version.ComposeOverride, composeFiles, encodeImageDigests(version.ImageDigests), changeReason, changedBy, version.IsCurrent, version.CreatedAt, rolledBackFrom, storedComposeChecksum(version.Checksum, version.ComposeContent),
 	)
 	return err
 }
 
 // GetComposeVersionsByAppID retrieves all compose versions for an app, ordered by version DESC
 func (db *DB) GetComposeVersionsByAppID(appID string) ([]*ComposeVersion, error) {
-	rows, err := db.Query("SELECT id, app_id, version, compose_content, change_reason, changed_by, is_current, created_at, rolled_back_from FROM compose_versions WHERE app_id = ? ORDER BY version DESC", appID)
+	rows, err := db.Query("SELECT id, app_id, version, compose_content, compose_override, compose_files, image_digests, change_reason, changed_by, is_current, created_at, rolled_back_from, checksum FROM compose_versions WHERE app_id = ? ORDER BY version DESC", appID)
 	if err != nil {
 		return nil, err
 	}
@@ -1039,9 +1758,9 @@ func (db *DB) GetComposeVersionsByAppID(appID string) ([]*ComposeVersion, error)
 	var versions []*ComposeVersion
 	for rows.Next() {
 		version := &ComposeVersion{}
-		var changeReason, changedBy sql.NullString
+		var changeReason, changedBy, composeOverride, composeFiles, imageDigests, checksum sql.NullString
 		var rolledBackFrom sql.NullInt64
-		err := rows.Scan(&version.ID, &version.AppID, &version.Version, &version.ComposeContent, &changeReason, &changedBy, &version.IsCurrent, &version.CreatedAt, &rolledBackFrom)
+		err := rows.Scan(&version.ID, &version.AppID, &version.Version, &version.ComposeContent, &composeOverride, &composeFiles, &imageDigests, &changeReason, &changedBy, &version.IsCurrent, &version.CreatedAt, &rolledBackFrom, &checksum)
 		if err != nil {
 			return nil, err
 		}
@@ -1056,6 +1775,12 @@ func (db *DB) GetComposeVersionsByAppID(appID string) ([]*ComposeVersion, error)
 			rbf := int(rolledBackFrom.Int64)
 			version.RolledBackFrom = &rbf
 		}
+		version.ComposeOverride = composeOverride.String
+		version.ImageDigests = decodeImageDigests(imageDigests.String)
+		version.Checksum = storedComposeChecksum(checksum.String, version.ComposeContent)
+		if version.ComposeFiles, err = decodeComposeFiles(appID, composeFiles.String); err != nil {
+			return nil, err
+		}
 
 		versions = append(versions, version)
 	}
@@ -1066,12 +1791,12 @@ func (db *DB) GetComposeVersionsByAppID(appID string) ([]*ComposeVersion, error)
 // GetComposeVersion retrieves a specific compose version by app ID and version number
 func (db *DB) GetComposeVersion(appID string, version int) (*ComposeVersion, error) {
 	v := &ComposeVersion{}
-	var changeReason, changedBy sql.NullString
+	var changeReason, changedBy, composeOverride, composeFiles, imageDigests, checksum sql.NullString
 	var rolledBackFrom sql.NullInt64
 	err := db.QueryRow(
-		"SELECT id, app_id, version, compose_content, change_reason, changed_by, is_current, created_at, rolled_back_from FROM compose_versions WHERE app_id = ? AND version = ?",
+		"SELECT id, app_id, version, compose_content, compose_override, compose_files, image_digests, change_reason, changed_by, is_current, created_at, rolled_back_from, checksum FROM compose_versions WHERE app_id = ? AND version = ?",
 		appID, version,
-	).Scan(&v.ID, &v.AppID, &v.Version, &v.ComposeContent, &changeReason, &changedBy, &v.IsCurrent, &v.CreatedAt, &rolledBackFrom)
+	).Scan(&v.ID, &v.AppID, &v.Version, &v.ComposeContent, &composeOverride, &composeFiles, &imageDigests, &changeReason, &changedBy, &v.IsCurrent, &v.CreatedAt, &rolledBackFrom, &checksum)
 
 	if err == nil {
 		if changeReason.Valid {
@@ -1084,6 +1809,10 @@ func (db *DB) GetComposeVersion(appID string, version int) (*ComposeVersion, err
 			rbf := int(rolledBackFrom.Int64)
 			v.RolledBackFrom = &rbf
 		}
+		v.ComposeOverride = composeOverride.String
+		v.ImageDigests = decodeImageDigests(imageDigests.String)
+		v.Checksum = storedComposeChecksum(checksum.String, v.ComposeContent)
+		v.ComposeFiles, err = decodeComposeFiles(appID, composeFiles.String)
 	}
 	return v, err
 }
@@ -1091,12 +1820,12 @@ func (db *DB) GetComposeVersion(appID string, version int) (*ComposeVersion, err
 // GetCurrentComposeVersion retrieves the current active compose version for an app
 func (db *DB) GetCurrentComposeVersion(appID string) (*ComposeVersion, error) {
 	v := &ComposeVersion{}
-	var changeReason, changedBy sql.NullString
+	var changeReason, changedBy, composeOverride, composeFiles, imageDigests, checksum sql.NullString
 	var rolledBackFrom sql.NullInt64
 	err := db.QueryRow(
-		"SELECT id, app_id, version, compose_content, change_reason, changed_by, is_current, created_at, rolled_back_from FROM compose_versions WHERE app_id = ? AND is_current = 1",
+		"SELECT id, app_id, version, compose_content, compose_override, compose_files, image_digests, change_reason, changed_by, is_current, created_at, rolled_back_from, checksum FROM compose_versions WHERE app_id = ? AND is_current = 1",
 		appID,
-	).Scan(&v.ID, &v.AppID, &v.Version, &v.ComposeContent, &changeReason, &changedBy, &v.IsCurrent, &v.CreatedAt, &rolledBackFrom)
+	).Scan(&v.ID, &v.AppID, &v.Version, &v.ComposeContent, &composeOverride, &composeFiles, &imageDigests, &changeReason, &changedBy, &v.IsCurrent, &v.CreatedAt, &rolledBackFrom, &checksum)
 
 	if err == nil {
 		if changeReason.Valid {
@@ -1109,6 +1838,10 @@ func (db *DB) GetCurrentComposeVersion(appID string) (*ComposeVersion, error) {
 			rbf := int(rolledBackFrom.Int64)
 			v.RolledBackFrom = &rbf
 		}
+		v.ComposeOverride = composeOverride.String
+		v.ImageDigests = decodeImageDigests(imageDigests.String)
+		v.Checksum = storedComposeChecksum(checksum.String, v.ComposeContent)
+		v.ComposeFiles, err = decodeComposeFiles(appID, composeFiles.String)
 	}
 	return v, err
 }
@@ -1126,6 +1859,45 @@ func (db *DB) GetLatestVersionNumber(appID string) (int, error) {
 	return int(version.Int64), nil
 }
 
+// SetCurrentVersionImageDigests records the image digests deployed for the app's current compose version
+func (db *DB) SetCurrentVersionImageDigests(appID string, digests map[string]string) error {
+	_, err := db.Exec("UPDATE compose_versions SET image_digests = ? WHERE app_id = ? AND is_current = 1", encodeImageDigests(digests), appID)
+	return err
+}
+
+// encodeImageDigests serializes an image -> digest map for storage; empty maps are stored as ''
+func encodeImageDigests(digests map[string]string) string {
+	if len(digests) == 0 {
+		return ""
+	}
+	data, err := json.Marshal(digests)
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+
+// storedComposeChecksum is a version's compose checksum; versions saved before checksums were
+// recorded get theirs from their content
+func storedComposeChecksum(checksum, content string) string {
+	if checksum == "" {
+		return ComposeChecksum(content)
+	}
+	return checksum
+}
+
+// decodeImageDigests parses a stored image -> digest map, ignoring empty or malformed values
+func decodeImageDigests(s string) map[string]string {
+	if s == "" {
+		return nil
+	}
+	var digests map[string]string
+	if err := json.Unmarshal([]byte(s), &digests); err != nil {
+		return nil
+	}
+	return digests
+}
+
 // MarkAllVersionsAsNotCurrent marks all versions for an app as not current
 func (db *DB) MarkAllVersionsAsNotCurrent(appID string) error {
 	_, err := db.Exec("UPDATE compose_versions SET is_current = 0 WHERE app_id = ?", appID)
@@ -1167,10 +1939,10 @@ func (db *DB) DeleteComposeVersionsByAppID(appID string) error {
 // CreateNode creates a new node
 func (db *DB) CreateNode(node *Node) error {
 	_, err := db.Exec(
-		`INSERT INTO nodes (id, name, api_endpoint, api_key, is_primary, status, last_seen, created_at, updated_at)
-		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		`INSERT INTO nodes (id, name, api_endpoint, api_key, is_primary, status, last_seen, version, api_version, created_at, updated_at)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
 		node.ID, node.Name, node.APIEndpoint, node.APIKey,
-		node.IsPrimary, node.Status, node.LastSeen,
+		node.IsPrimary, node.Status, node.LastSeen, node.Version, node.APIVersion,
 		node.CreatedAt, node.UpdatedAt,
 	)
 	return err
@@ -1181,13 +1953,14 @@ func (db *DB) GetNode(id string) (*Node, error) {
 	node := &Node{}
 	var lastSeen sql.NullTime
 	var lastHealthCheck sql.NullTime
+	var clockSkew sql.NullInt64
 	err := db.QueryRow(
-		`SELECT id, name, api_endpoint, api_key, is_primary, status, last_seen, consecutive_failures, last_health_check, created_at, updated_at 
+		`SELECT id, name, api_endpoint, api_key, is_primary, status, last_seen, consecutive_failures, last_health_check, version, api_version, clock_skew_ms, created_at, updated_at 
 		 FROM nodes WHERE id = ?`,
 		id,
 	).Scan(&node.ID, &node.Name, &node.APIEndpoint, &node.APIKey,
 		&node.IsPrimary, &node.Status, &lastSeen, &node.ConsecutiveFailures, &lastHealthCheck,
-		&node.CreatedAt, &node.UpdatedAt)
+		&node.Version, &node.APIVersion, &clockSkew, &node.CreatedAt, &node.UpdatedAt)
 
 	if err == nil {
 		if lastSeen.Valid {
@@ -1196,6 +1969,9 @@ func (db *DB) GetNode(id string) (*Node, error) {
 		if lastHealthCheck.Valid {
 			node.LastHealthCheck = &lastHealthCheck.Time
 		}
+		if clockSkew.Valid {
+			node.ClockSkewMS = &clockSkew.Int64
+		}
 	}
 
 	return node, err
@@ -1204,7 +1980,7 @@ func (db *DB) GetNode(id string) (*Node, error) {
 // GetAllNodes retrieves all nodes
 func (db *DB) GetAllNodes() ([]*Node, error) {
 	rows, err := db.Query(
-		`SELECT id, name, api_endpoint, api_key, is_primary, status, last_seen, consecutive_failures, last_health_check, created_at, updated_at 
+		`SELECT id, name, api_endpoint, api_key, is_primary, status, last_seen, consecutive_failures, last_health_check, version, api_version, clock_skew_ms, created_at, updated_at 
 		 FROM nodes ORDER BY created_at ASC`,
 	)
 	if err != nil {
@@ -1217,9 +1993,10 @@ func (db *DB) GetAllNodes() ([]*Node, error) {
 		node := &Node{}
 		var lastSeen sql.NullTime
 		var lastHealthCheck sql.NullTime
+		var clockSkew sql.NullInt64
 		err := rows.Scan(&node.ID, &node.Name, &node.APIEndpoint, &node.APIKey,
 			&node.IsPrimary, &node.Status, &lastSeen, &node.ConsecutiveFailures, &lastHealthCheck,
-			&node.CreatedAt, &node.UpdatedAt)
+			&node.Version, &node.APIVersion, &clockSkew, &node.CreatedAt, &node.UpdatedAt)
 		if err != nil {
 			return nil, err
 		}
@@ -1230,6 +2007,9 @@ func (db *DB) GetAllNodes() ([]*Node, error) {
 		if lastHealthCheck.Valid {
 			node.LastHealthCheck = &lastHealthCheck.Time
 		}
+		if clockSkew.Valid {
+			node.ClockSkewMS = &clockSkew.Int64
+		}
 
 		nodes = append(nodes, node)
 	}
@@ -1242,12 +2022,13 @@ func (db *DB) GetPrimaryNode() (*Node, error) {
 	node := &Node{}
 	var lastSeen sql.NullTime
 	var lastHealthCheck sql.NullTime
+	var clockSkew sql.NullInt64
 	err := db.QueryRow(
-		`SELECT id, name, api_endpoint, api_key, is_primary, status, last_seen, consecutive_failures, last_health_check, created_at, updated_at 
+		`SELECT id, name, api_endpoint, api_key, is_primary, status, last_seen, consecutive_failures, last_health_check, version, api_version, clock_skew_ms, created_at, updated_at 
 		 FROM nodes WHERE is_primary = 1 LIMIT 1`,
 	).Scan(&node.ID, &node.Name, &node.APIEndpoint, &node.APIKey,
 		&node.IsPrimary, &node.Status, &lastSeen, &node.ConsecutiveFailures, &lastHealthCheck,
-		&node.CreatedAt, &node.UpdatedAt)
+		&node.Version, &node.APIVersion, &clockSkew, &node.CreatedAt, &node.UpdatedAt)
 
 	if err == nil {
 		if lastSeen.Valid {
@@ -1256,6 +2037,9 @@ func (db *DB) GetPrimaryNode() (*Node, error) {
 		if lastHealthCheck.Valid {
 			node.LastHealthCheck = &lastHealthCheck.Time
 		}
+		if clockSkew.Valid {
+			node.ClockSkewMS = &clockSkew.Int64
+		}
 	}
 
 	return node, err
@@ -1264,10 +2048,10 @@ func (db *DB) GetPrimaryNode() (*Node, error) {
 // UpdateNode updates a node
 func (db *DB) UpdateNode(node *Node) error {
 	_, err := db.Exec(
-		`UPDATE nodes SET name = ?, api_endpoint = ?, api_key = ?, is_primary = ?, status = ?, last_seen = ?, consecutive_failures = ?, last_health_check = ?, updated_at = ? 
+		`UPDATE nodes SET name = ?, api_endpoint = ?, api_key = ?, is_primary = ?, status = ?, last_seen = ?, consecutive_failures = ?, last_health_check = ?, version = ?, api_version = ?, clock_skew_ms = ?, updated_at = ? 
 		 WHERE id = ?`,
 		node.Name, node.APIEndpoint, node.APIKey, node.IsPrimary,
-		node.Status, node.LastSeen, node.ConsecutiveFailures, node.LastHealthCheck, time.Now(), node.ID,
+		node.Status, node.LastSeen, node.ConsecutiveFailures, node.LastHealthCheck, node.Version, node.APIVersion, node.ClockSkewMS, time.Now(), node.ID,
 	)
 	return err
 }
@@ -1283,13 +2067,14 @@ func (db *DB) GetNodeByName(name string) (*Node, error) {
 	node := &Node{}
 	var lastSeen sql.NullTime
 	var lastHealthCheck sql.NullTime
+	var clockSkew sql.NullInt64
 	err := db.QueryRow(
-		`SELECT id, name, api_endpoint, api_key, is_primary, status, last_seen, consecutive_failures, last_health_check, created_at, updated_at 
+		`SELECT id, name, api_endpoint, api_key, is_primary, status, last_seen, consecutive_failures, last_health_check, version, api_version, clock_skew_ms, created_at, updated_at 
 		 FROM nodes WHERE name = ?`,
 		name,
 	).Scan(&node.ID, &node.Name, &node.APIEndpoint, &node.APIKey,
 		&node.IsPrimary, &node.Status, &lastSeen, &node.ConsecutiveFailures, &lastHealthCheck,
-		&node.CreatedAt, &node.UpdatedAt)
+		&node.Version, &node.APIVersion, &clockSkew, &node.CreatedAt, &node.UpdatedAt)
 
 	if err == nil {
 		if lastSeen.Valid {
@@ -1298,6 +2083,9 @@ func (db *DB) GetNodeByName(name string) (*Node, error) {
 		if lastHealthCheck.Valid {
 			node.LastHealthCheck = &lastHealthCheck.Time
 		}
+		if clockSkew.Valid {
+			node.ClockSkewMS = &clockSkew.Int64
+		}
 	}
 
 	return node, err
@@ -1320,7 +2108,7 @@ func scanJob(rows *sql.Rows) (*Job, error) {
 			&job.ID, &job.Type, &job.AppID, &job.Status, &payload, &job.Progress, &progressMessage,
 			&result, &errorMessage, &startedAt, &completedAt, &job.CreatedAt, &job.UpdatedAt,
 			&claimedBy, &claimedAt, &job.RetryCount, &job.MaxRetries, &retryAfter,
-			&cancelledAt, &timeoutSeconds, &jobHash,
+			&cancelledAt, &timeoutSeconds, &jobHash, &job.Priority,
 		)
 	} else {
 		return nil, fmt.Errorf("rows is nil")
@@ -1383,7 +2171,7 @@ func scanJobFromRow(row *sql.Row) (*Job, error) {
 		&job.ID, &job.Type, &job.AppID, &job.Status, &payload, &job.Progress, &progressMessage,
 		&result, &errorMessage, &startedAt, &completedAt, &job.CreatedAt, &job.UpdatedAt,
 		&claimedBy, &claimedAt, &job.RetryCount, &job.MaxRetries, &retryAfter,
-		&cancelledAt, &timeoutSeconds, &jobHash,
+		&cancelledAt, &timeoutSeconds, &jobHash, &job.Priority,
 	)
 
 	if err != nil {
@@ -1435,10 +2223,10 @@ func scanJobFromRow(row *sql.Row) (*Job, error) {
 // CreateJob creates a new job
 func (db *DB) CreateJob(job *Job) error {
 	_, err := db.Exec(
-		`INSERT INTO jobs (id, type, app_id, status, payload, progress, progress_message, created_at, updated_at)
-		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		`INSERT INTO jobs (id, type, app_id, status, payload, progress, progress_message, retry_count, priority, created_at, updated_at)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
 		job.ID, job.Type, job.AppID, job.Status, job.Payload, job.Progress, job.ProgressMessage,
-		job.CreatedAt, job.UpdatedAt,
+		job.RetryCount, job.Priority, job.CreatedAt, job.UpdatedAt,
 	)
 	return err
 }
@@ -1449,7 +2237,7 @@ func (db *DB) GetJob(id string) (*Job, error) {
 		`SELECT id, type, app_id, status, payload, progress, progress_message, result, error_message,
 		        started_at, completed_at, created_at, updated_at,
 		        claimed_by, claimed_at, retry_count, max_retries, retry_after,
-		        cancelled_at, timeout_seconds, job_hash
+		        cancelled_at, timeout_seconds, job_hash, priority
 		 FROM jobs WHERE id = ?`,
 		id,
 	)
@@ -1462,7 +2250,7 @@ func (db *DB) GetJobsByAppID(appID string, limit int) ([]*Job, error) {
 		`SELECT id, type, app_id, status, payload, progress, progress_message, result, error_message,
 		        started_at, completed_at, created_at, updated_at,
 		        claimed_by, claimed_at, retry_count, max_retries, retry_after,
-		        cancelled_at, timeout_seconds, job_hash
+		        cancelled_at, timeout_seconds, job_hash, priority
 		 FROM jobs
 		 WHERE app_id = ?
 		 ORDER BY created_at DESC
@@ -1486,13 +2274,44 @@ func (db *DB) GetJobsByAppID(appID string, limit int) ([]*Job, error) {
 	return jobs, nil
 }
 
-// GetActiveJobForApp retrieves any pending or running job for an app (for concurrency check)
-func (db *DB) GetActiveJobForApp(appID string) (*Job, error) {
-	row := db.QueryRow(
+// GetRecentJobs retrieves the most recent jobs of every app, newest first, optionally only those
+// with the given status and type
+func (db *DB) GetRecentJobs(status, jobType string, limit int) ([]*Job, error) {
+	rows, err := db.Query(
 		`SELECT id, type, app_id, status, payload, progress, progress_message, result, error_message,
 		        started_at, completed_at, created_at, updated_at,
 		        claimed_by, claimed_at, retry_count, max_retries, retry_after,
-		        cancelled_at, timeout_seconds, job_hash
+		        cancelled_at, timeout_seconds, job_hash, priority
+		 FROM jobs
+		 WHERE (? = '' OR status = ?) AND (? = '' OR type = ?)
+		 ORDER BY created_at DESC
+		 LIMIT ?`,
+		status, status, jobType, jobType, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var jobs []*Job
+	for rows.Next() {
+		job, err := scanJob(rows)
+		if err != nil {
+			return nil, err
+		}
+		jobs = append(jobs, job)
+	}
+
+	return jobs, rows.Err()
+}
+
+// GetActiveJobForApp retrieves any pending or running job for an app (for concurrency check)
+func (db *DB) GetActiveJobForApp(appID string) (*Job, error) {
+	row := db.QueryRow(
+		`SELECT id, type, app_id, status, payload, progress, progress_message, result, error_message,
+		        started_at, completed_at, created_at, updated_at,
+		        claimed_by, claimed_at, retry_count, max_retries, retry_after,
+		        cancelled_at, timeout_seconds, job_hash, priority
 		 FROM jobs
 		 WHERE app_id = ? AND status IN (?, ?)
 		 ORDER BY created_at DESC
@@ -1553,16 +2372,16 @@ func (db *DB) UpdateJobCompleted(id, status string, result *string, errorMsg *st
 	return err
 }
 
-// GetPendingJobs retrieves pending jobs, ordered by creation date (oldest first)
+// GetPendingJobs retrieves pending jobs in the order workers claim them: by priority, then oldest first
 func (db *DB) GetPendingJobs(limit int) ([]*Job, error) {
 	rows, err := db.Query(
 		`SELECT id, type, app_id, status, payload, progress, progress_message, result, error_message,
 		        started_at, completed_at, created_at, updated_at,
 		        claimed_by, claimed_at, retry_count, max_retries, retry_after,
-		        cancelled_at, timeout_seconds, job_hash
+		        cancelled_at, timeout_seconds, job_hash, priority
 		 FROM jobs
 		 WHERE status = ?
-		 ORDER BY created_at ASC
+		 ORDER BY priority DESC, created_at ASC
 		 LIMIT ?`,
 		constants.JobStatusPending, limit,
 	)
@@ -1600,15 +2419,13 @@ func (db *DB) ClaimPendingJob(workerID string) (*Job, error) {
 	}
 	defer tx.Rollback()
 
-	// Find a pending job that isn't claimed
+	// Find a pending job that isn't claimed, with the cached statement since workers poll often
+	stmt, err := db.prepared(pendingJobQuery)
+	if err != nil {
+		return nil, err
+	}
 	var jobID string
-	err = tx.QueryRow(
-		`SELECT id FROM jobs
-		 WHERE status = ? AND (claimed_by IS NULL OR claimed_by = '')
-		 ORDER BY created_at ASC
-		 LIMIT 1`,
-		constants.JobStatusPending,
-	).Scan(&jobID)
+	err = tx.Stmt(stmt).QueryRow(constants.JobStatusPending).Scan(&jobID)
 
 	if err == sql.ErrNoRows {
 		return nil, nil // No job available
@@ -1649,7 +2466,7 @@ func (db *DB) ClaimPendingJob(workerID string) (*Job, error) {
 		`SELECT id, type, app_id, status, payload, progress, progress_message, result, error_message,
 		        started_at, completed_at, created_at, updated_at,
 		        claimed_by, claimed_at, retry_count, max_retries, retry_after,
-		        cancelled_at, timeout_seconds, job_hash
+		        cancelled_at, timeout_seconds, job_hash, priority
 		 FROM jobs
 		 WHERE id = ?`,
 		jobID,
@@ -1657,7 +2474,7 @@ func (db *DB) ClaimPendingJob(workerID string) (*Job, error) {
 		&job.ID, &job.Type, &job.AppID, &job.Status, &payload, &job.Progress, &progressMessage,
 		&resultStr, &errorMessage, &startedAt, &completedAt, &job.CreatedAt, &job.UpdatedAt,
 		&claimedBy, &claimedAt, &job.RetryCount, &job.MaxRetries, &retryAfter,
-		&cancelledAt, &timeoutSeconds, &jobHash,
+		&cancelledAt, &timeoutSeconds, &jobHash, &job.Priority,
 	)
 	if err != nil {
 		return nil, err
@@ -1710,6 +2527,126 @@ func (db *DB) ClaimPendingJob(workerID string) (*Job, error) {
 	return job, nil
 }
 
+// GetJobBacklog returns the number of pending and running jobs and when the oldest pending job was queued
+func (db *DB) GetJobBacklog() (pending, running int, oldestPending *time.Time, err error) {
+	err = db.QueryRow(
+		`SELECT
+		     COALESCE(SUM(CASE WHEN status = ? THEN 1 ELSE 0 END), 0),
+		     COALESCE(SUM(CASE WHEN status = ? THEN 1 ELSE 0 END), 0)
+		 FROM jobs`,
+		constants.JobStatusPending, constants.JobStatusRunning,
+	).Scan(&pending, &running)
+	if err != nil {
+		return 0, 0, nil, err
+	}
+	if pending == 0 {
+		return pending, running, nil, nil
+	}
+
+	var oldest sql.NullTime
+	err = db.QueryRow(
+		`SELECT created_at FROM jobs WHERE status = ? ORDER BY created_at ASC LIMIT 1`,
+		constants.JobStatusPending,
+	).Scan(&oldest)
+	if err != nil && err != sql.ErrNoRows {
+		return 0, 0, nil, err
+	}
+	if oldest.Valid {
+		oldestPending = &oldest.Time
+	}
+	return pending, running, oldestPending, nil
+}
+
+// QuickCheck runs SQLite's quick_check and returns an error describing any corruption found
+func (db *DB) QuickCheck() error {
+	rows, err := db.Query(`PRAGMA quick_check`)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var problems []string
+	for rows.Next() {
+		var result string
+		if err := rows.Scan(&result); err != nil {
+			return err
+		}
+		if result != "ok" {
+			problems = append(problems, result)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("integrity check failed: %s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// GetTunnelsWithoutApp returns tunnel records whose app no longer exists, e.g. because an app
+// delete failed between removing the app and its tunnel record
+func (db *DB) GetTunnelsWithoutApp() ([]*CloudflareTunnel, error) {
+	rows, err := db.Query(
+		`SELECT t.id, t.app_id, t.tunnel_id, t.tunnel_name, t.created_at
+		 FROM cloudflare_tunnels t
+		 LEFT JOIN apps a ON a.id = t.app_id
+		 WHERE a.id IS NULL
+		 ORDER BY t.created_at ASC`,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tunnels []*CloudflareTunnel
+	for rows.Next() {
+		tunnel := &CloudflareTunnel{}
+		if err := rows.Scan(&tunnel.ID, &tunnel.AppID, &tunnel.TunnelID, &tunnel.TunnelName, &tunnel.CreatedAt); err != nil {
+			return nil, err
+		}
+		tunnels = append(tunnels, tunnel)
+	}
+	return tunnels, rows.Err()
+}
+
+// ComposeVersionFlags summarizes an app's compose history: how many versions it has, how many
+// are flagged current (exactly one should be) and the newest version number
+type ComposeVersionFlags struct {
+	AppID    string
+	AppName  string
+	Versions int
+	Current  int
+	Latest   int
+}
+
+// GetMisflaggedComposeVersions returns the apps with compose history whose number of versions
+// flagged current isn't exactly one
+func (db *DB) GetMisflaggedComposeVersions() ([]*ComposeVersionFlags, error) {
+	rows, err := db.Query(
+		`SELECT a.id, a.name, COUNT(*), SUM(v.is_current), MAX(v.version)
+		 FROM compose_versions v
+		 JOIN apps a ON a.id = v.app_id
+		 GROUP BY a.id, a.name
+		 HAVING SUM(v.is_current) != 1
+		 ORDER BY a.name ASC`,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var flags []*ComposeVersionFlags
+	for rows.Next() {
+		f := &ComposeVersionFlags{}
+		if err := rows.Scan(&f.AppID, &f.AppName, &f.Versions, &f.Current, &f.Latest); err != nil {
+			return nil, err
+		}
+		flags = append(flags, f)
+	}
+	return flags, rows.Err()
+}
+
 // ReleaseJobClaim releases a job claim (e.g., if worker crashes)
 func (db *DB) ReleaseJobClaim(jobID string) error {
 	_, err := db.Exec(
@@ -1773,18 +2710,16 @@ func (db *DB) MarkStaleJobsAsFailed(staleThreshold time.Duration) error {
 
 // CleanupOldCompletedJobs deletes old completed/failed jobs for an app, keeping only the most recent N
 func (db *DB) CleanupOldCompletedJobs(appID string, keepCount int) error {
-	// Delete all but the most recent N completed/failed jobs for this app
+	// Delete the completed/failed jobs past the most recent N, found through idx_jobs_app_id,
+	// rather than comparing every job against the list of those to keep
 	_, err := db.Exec(
 		`DELETE FROM jobs
-		 WHERE app_id = ?
-		 AND status IN (?, ?)
-		 AND id NOT IN (
+		 WHERE id IN (
 		     SELECT id FROM jobs
 		     WHERE app_id = ? AND status IN (?, ?)
 		     ORDER BY created_at DESC
-		     LIMIT ?
+		     LIMIT -1 OFFSET ?
 		 )`,
-		appID, constants.JobStatusCompleted, constants.JobStatusFailed,
 		appID, constants.JobStatusCompleted, constants.JobStatusFailed, keepCount,
 	)
 	return err
@@ -1793,20 +2728,20 @@ func (db *DB) CleanupOldCompletedJobs(appID string, keepCount int) error {
 // CleanupAllOldCompletedJobs deletes old completed/failed jobs for all apps in a single query
 // This is more efficient than calling CleanupOldCompletedJobs for each app
 func (db *DB) CleanupAllOldCompletedJobs(keepCount int) error {
-	// For each app, keep only the most recent N completed/failed jobs
+	// For each app, delete the completed/failed jobs past the most recent N. Selecting the jobs
+	// to delete keeps the IN list to the excess, where NOT IN over the jobs to keep grew with
+	// every app.
 	_, err := db.Exec(
 		`DELETE FROM jobs
-		 WHERE status IN (?, ?)
-		 AND id NOT IN (
+		 WHERE id IN (
 		     SELECT id FROM (
-		         SELECT id, app_id,
+		         SELECT id,
 		                ROW_NUMBER() OVER (PARTITION BY app_id ORDER BY created_at DESC) as rn
 		         FROM jobs
 		         WHERE status IN (?, ?)
 		     ) ranked
-		     WHERE rn <= ?
+		     WHERE rn > ?
 		 )`,
-		constants.JobStatusCompleted, constants.JobStatusFailed,
 		constants.JobStatusCompleted, constants.JobStatusFailed, keepCount,
 	)
 	return err
@@ -1893,3 +2828,2126 @@ func (db *DB) GetAllSchedules() ([]*AppSchedule, error) {
 
 	return schedules, nil
 }
+
+// TryAcquireAppLock takes the lease on an app for holder when it is free, expired, or already held
+// by the same holder. When another holder owns a live lease, that lease is returned with acquired=false.
+func (db *DB) TryAcquireAppLock(appID, holder, operation string, ttl time.Duration) (*AppLock, bool, error) {
+	now := time.Now()
+	result, err := db.Exec(
+		`INSERT INTO app_locks (app_id, holder, operation, acquired_at, expires_at) VALUES (?, ?, ?, ?, ?)
+		 ON CONFLICT(app_id) DO UPDATE SET
+		     holder = excluded.holder, operation = excluded.operation,
+		     acquired_at = excluded.acquired_at, expires_at = excluded.expires_at
+		 WHERE app_locks.expires_at <= ? OR app_locks.holder = excluded.holder`,
+		appID, holder, operation, now.Unix(), now.Add(ttl).Unix(), now.Unix(),
+	)
+	if err != nil {
+		return nil, false, err
+	}
+	if affected, err := result.RowsAffected(); err != nil {
+		return nil, false, err
+	} else if affected > 0 {
+		return nil, true, nil
+	}
+
+	lock, err := db.GetAppLock(appID)
+	if err == sql.ErrNoRows {
+		// Released between the insert and the read; try again
+		return db.TryAcquireAppLock(appID, holder, operation, ttl)
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return lock, false, nil
+}
+
+// GetAppLock retrieves the current lease on an app (sql.ErrNoRows when unlocked)
+func (db *DB) GetAppLock(appID string) (*AppLock, error) {
+	lock := &AppLock{}
+	var acquiredAt, expiresAt int64
+	err := db.QueryRow(
+		"SELECT app_id, holder, operation, acquired_at, expires_at FROM app_locks WHERE app_id = ?",
+		appID,
+	).Scan(&lock.AppID, &lock.Holder, &lock.Operation, &acquiredAt, &expiresAt)
+	if err != nil {
+		return nil, err
+	}
+	lock.AcquiredAt = time.Unix(acquiredAt, 0)
+	lock.ExpiresAt = time.Unix(expiresAt, 0)
+	return lock, nil
+}
+
+// ReleaseAppLock releases the lease on an app if holder still owns it
+func (db *DB) ReleaseAppLock(appID, holder string) error {
+	_, err := db.Exec("DELETE FROM app_locks WHERE app_id = ? AND holder = ?", appID, holder)
+	return err
+}
+
+// CreateQueuedOperation stores an operation to replay on a node once it is back online
+func (db *DB) CreateQueuedOperation(op *QueuedOperation) error {
+	_, err := db.Exec(
+		`INSERT INTO queued_operations (id, node_id, method, path, raw_query, body, user_name, user_role, status, response_status, error_message, created_at, updated_at)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		op.ID, op.NodeID, op.Method, op.Path, op.RawQuery, op.Body, op.UserName, op.UserRole, op.Status, op.ResponseStatus, op.ErrorMessage, op.CreatedAt, op.UpdatedAt,
+	)
+	return err
+}
+
+// GetQueuedOperationsByNodeID retrieves a node's queued operations in replay order;
+// an empty status returns all of them
+func (db *DB) GetQueuedOperationsByNodeID(nodeID, status string) ([]*QueuedOperation, error) {
+	query := `SELECT id, node_id, method, path, raw_query, body, user_name, user_role, status, response_status, error_message, created_at, updated_at
+		 FROM queued_operations WHERE node_id = ?`
+	args := []interface{}{nodeID}
+	if status != "" {
+		query += " AND status = ?"
+		args = append(args, status)
+	}
+	query += " ORDER BY created_at ASC"
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ops []*QueuedOperation
+	for rows.Next() {
+		op := &QueuedOperation{}
+		var errorMessage sql.NullString
+		if err := rows.Scan(&op.ID, &op.NodeID, &op.Method, &op.Path, &op.RawQuery, &op.Body, &op.UserName, &op.UserRole, &op.Status, &op.ResponseStatus, &errorMessage, &op.CreatedAt, &op.UpdatedAt); err != nil {
+			return nil, err
+		}
+		if errorMessage.Valid {
+			op.ErrorMessage = &errorMessage.String
+		}
+		ops = append(ops, op)
+	}
+	return ops, rows.Err()
+}
+
+// UpdateQueuedOperationResult records the outcome of replaying a queued operation
+func (db *DB) UpdateQueuedOperationResult(id, status string, responseStatus int, errorMessage *string) error {
+	_, err := db.Exec(
+		"UPDATE queued_operations SET status = ?, response_status = ?, error_message = ?, updated_at = ? WHERE id = ?",
+		status, responseStatus, errorMessage, time.Now(), id,
+	)
+	return err
+}
+
+// DeleteQueuedOperation removes a pending queued operation; returns sql.ErrNoRows if there was none
+func (db *DB) DeleteQueuedOperation(nodeID, id string) error {
+	result, err := db.Exec(
+		"DELETE FROM queued_operations WHERE id = ? AND node_id = ? AND status = ?",
+		id, nodeID, constants.QueuedOperationStatusPending,
+	)
+	if err != nil {
+		return err
+	}
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		return sql.ErrNoRows
+	}
+	return err
+}
+
+// SyncNodeAppCache applies an app inventory pushed by a node: changed apps are upserted, cached
+// apps missing from currentIDs are dropped, and every remaining entry is stamped as synced now.
+// Returns how many apps are cached for the node afterwards.
+func (db *DB) SyncNodeAppCache(nodeID string, changed []*CachedApp, currentIDs []string) (int, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	for _, app := range changed {
+		if _, err := tx.Exec(
+			`INSERT INTO node_app_cache (node_id, app_id, name, status, public_url, updated_at, synced_at)
+			 VALUES (?, ?, ?, ?, ?, ?, ?)
+			 ON CONFLICT(node_id, app_id) DO UPDATE SET
+				name = excluded.name, status = excluded.status, public_url = excluded.public_url,
+				updated_at = excluded.updated_at, synced_at = excluded.synced_at`,
+			nodeID, app.AppID, app.Name, app.Status, app.PublicURL, app.UpdatedAt, now,
+		); err != nil {
+			return 0, err
+		}
+	}
+
+	deleteQuery := "DELETE FROM node_app_cache WHERE node_id = ?"
+	args := []interface{}{nodeID}
+	if len(currentIDs) > 0 {
+		deleteQuery += " AND app_id NOT IN (?" + strings.Repeat(", ?", len(currentIDs)-1) + ")"
+		for _, id := range currentIDs {
+			args = append(args, id)
+		}
+	}
+	if _, err := tx.Exec(deleteQuery, args...); err != nil {
+		return 0, err
+	}
+
+	if _, err := tx.Exec("UPDATE node_app_cache SET synced_at = ? WHERE node_id = ?", now, nodeID); err != nil {
+		return 0, err
+	}
+
+	var count int
+	if err := tx.QueryRow("SELECT COUNT(*) FROM node_app_cache WHERE node_id = ?", nodeID).Scan(&count); err != nil {
+		return 0, err
+	}
+
+	return count, tx.Commit()
+}
+
+// GetNodeAppCache retrieves the cached app inventory of a node, ordered by name
+func (db *DB) GetNodeAppCache(nodeID string) ([]*CachedApp, error) {
+	rows, err := db.Query(
+		`SELECT node_id, app_id, name, status, public_url, updated_at, synced_at
+		 FROM node_app_cache WHERE node_id = ? ORDER BY name ASC`,
+		nodeID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var apps []*CachedApp
+	for rows.Next() {
+		app := &CachedApp{}
+		if err := rows.Scan(&app.NodeID, &app.AppID, &app.Name, &app.Status, &app.PublicURL, &app.UpdatedAt, &app.SyncedAt); err != nil {
+			return nil, err
+		}
+		apps = append(apps, app)
+	}
+	return apps, rows.Err()
+}
+
+// DeleteNodeAppCacheEntry drops one app from a node's cached inventory; returns sql.ErrNoRows if
+// the node had no such app cached
+func (db *DB) DeleteNodeAppCacheEntry(nodeID, appID string) error {
+	result, err := db.Exec("DELETE FROM node_app_cache WHERE node_id = ? AND app_id = ?", nodeID, appID)
+	if err != nil {
+		return err
+	}
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		return sql.ErrNoRows
+	}
+	return err
+}
+
+// webhookColumns lists app_webhooks columns in the order scanWebhook reads them
+const webhookColumns = `id, app_id, url, secret, events, enabled, digest, quiet_hours_start, quiet_hours_end, timezone, last_status, last_error, last_delivered_at, created_at, updated_at`
+
+// scanWebhook reads a webhook row selected with webhookColumns
+func scanWebhook(scanner interface{ Scan(dest ...interface{}) error }) (*AppWebhook, error) {
+	webhook := &AppWebhook{}
+	var events string
+	var lastError sql.NullString
+	var lastDeliveredAt sql.NullTime
+	if err := scanner.Scan(&webhook.ID, &webhook.AppID, &webhook.URL, &webhook.Secret, &events, &webhook.Enabled,
+		&webhook.Digest, &webhook.QuietHoursStart, &webhook.QuietHoursEnd, &webhook.Timezone, &webhook.LastStatus, &lastError, &lastDeliveredAt, &webhook.CreatedAt, &webhook.UpdatedAt); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(events), &webhook.Events); err != nil {
+		return nil, fmt.Errorf("invalid events for webhook %s: %w", webhook.ID, err)
+	}
+	if lastError.Valid {
+		webhook.LastError = &lastError.String
+	}
+	if lastDeliveredAt.Valid {
+		webhook.LastDeliveredAt = &lastDeliveredAt.Time
+	}
+	return webhook, nil
+}
+
+// GetWebhooksByAppID returns an app's webhooks, oldest first
+func (db *DB) GetWebhooksByAppID(appID string) ([]*AppWebhook, error) {
+	rows, err := db.Query(
+		`SELECT `+webhookColumns+` FROM app_webhooks WHERE app_id = ? ORDER BY created_at`,
+		appID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	webhooks := []*AppWebhook{}
+	for rows.Next() {
+		webhook, err := scanWebhook(rows)
+		if err != nil {
+			return nil, err
+		}
+		webhooks = append(webhooks, webhook)
+	}
+	return webhooks, rows.Err()
+}
+
+// GetWebhook returns one of an app's webhooks
+func (db *DB) GetWebhook(appID, id string) (*AppWebhook, error) {
+	return scanWebhook(db.QueryRow(
+		`SELECT `+webhookColumns+` FROM app_webhooks WHERE app_id = ? AND id = ?`,
+		appID, id,
+	))
+}
+
+// CreateWebhook inserts a webhook
+func (db *DB) CreateWebhook(webhook *AppWebhook) error {
+	events, err := json.Marshal(webhook.Events)
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec(
+		`INSERT INTO app_webhooks (id, app_id, url, secret, events, enabled, digest, quiet_hours_start, quiet_hours_end, timezone, created_at, updated_at)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		webhook.ID, webhook.AppID, webhook.URL, webhook.Secret, string(events), webhook.Enabled,
+		webhook.Digest, webhook.QuietHoursStart, webhook.QuietHoursEnd, webhook.Timezone, webhook.CreatedAt, webhook.UpdatedAt,
+	)
+	return err
+}
+
+// UpdateWebhook saves a webhook's URL, events, enabled state, digest mode and quiet hours
+func (db *DB) UpdateWebhook(webhook *AppWebhook) error {
+	events, err := json.Marshal(webhook.Events)
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec(
+		`UPDATE app_webhooks SET url = ?, events = ?, enabled = ?, digest = ?, quiet_hours_start = ?, quiet_hours_end = ?,
+		 timezone = ?, updated_at = ? WHERE id = ?`,
+		webhook.URL, string(events), webhook.Enabled, webhook.Digest, webhook.QuietHoursStart, webhook.QuietHoursEnd,
+		webhook.Timezone, webhook.UpdatedAt, webhook.ID,
+	)
+	return err
+}
+
+// RecordWebhookDelivery stores the outcome of a webhook's latest delivery
+func (db *DB) RecordWebhookDelivery(id string, status int, deliveryError *string, deliveredAt time.Time) error {
+	_, err := db.Exec(
+		`UPDATE app_webhooks SET last_status = ?, last_error = ?, last_delivered_at = ? WHERE id = ?`,
+		status, deliveryError, deliveredAt, id,
+	)
+	return err
+}
+
+// DeleteWebhook deletes one of an app's webhooks, returning sql.ErrNoRows when it doesn't exist
+func (db *DB) DeleteWebhook(appID, id string) error {
+	result, err := db.Exec(`DELETE FROM app_webhooks WHERE app_id = ? AND id = ?`, appID, id)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// HoldWebhookEvent stores an event a webhook holds back, under its delivery ID
+func (db *DB) HoldWebhookEvent(event *WebhookHeldEvent) error {
+	_, err := db.Exec(
+		`INSERT INTO webhook_held_events (id, webhook_id, payload, created_at) VALUES (?, ?, ?, ?)`,
+		event.ID, event.WebhookID, event.Payload, event.CreatedAt,
+	)
+	return err
+}
+
+// GetWebhooksWithHeldEvents returns every webhook that holds at least one event
+func (db *DB) GetWebhooksWithHeldEvents() ([]*AppWebhook, error) {
+	rows, err := db.Query(
+		`SELECT ` + webhookColumns + ` FROM app_webhooks
+		 WHERE id IN (SELECT DISTINCT webhook_id FROM webhook_held_events) ORDER BY created_at`,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	webhooks := []*AppWebhook{}
+	for rows.Next() {
+		webhook, err := scanWebhook(rows)
+		if err != nil {
+			return nil, err
+		}
+		webhooks = append(webhooks, webhook)
+	}
+	return webhooks, rows.Err()
+}
+
+// GetHeldWebhookEvents returns the events a webhook holds, oldest first
+func (db *DB) GetHeldWebhookEvents(webhookID string) ([]*WebhookHeldEvent, error) {
+	rows, err := db.Query(
+		`SELECT id, webhook_id, payload, created_at FROM webhook_held_events WHERE webhook_id = ? ORDER BY created_at`,
+		webhookID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	events := []*WebhookHeldEvent{}
+	for rows.Next() {
+		event := &WebhookHeldEvent{}
+		if err := rows.Scan(&event.ID, &event.WebhookID, &event.Payload, &event.CreatedAt); err != nil {
+			return nil, err
+		}
+		events = append(events, event)
+	}
+	return events, rows.Err()
+}
+
+// DeleteHeldWebhookEvents deletes held events by ID, once they have been delivered
+func (db *DB) DeleteHeldWebhookEvents(ids []string) error {
+	for _, id := range ids {
+		if _, err := db.Exec(`DELETE FROM webhook_held_events WHERE id = ?`, id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// taskColumns lists app_tasks columns in the order scanTask reads them
+const taskColumns = `id, app_id, name, service, command, mode, cron, timezone, timeout_seconds, enabled, last_status, last_run_at, created_at, updated_at`
+
+// scanTask reads a task row selected with taskColumns
+func scanTask(scanner interface{ Scan(dest ...interface{}) error }) (*AppTask, error) {
+	task := &AppTask{}
+	var command string
+	var lastRunAt sql.NullTime
+	if err := scanner.Scan(&task.ID, &task.AppID, &task.Name, &task.Service, &command, &task.Mode, &task.Cron, &task.Timezone,
+		&task.TimeoutSeconds, &task.Enabled, &task.LastStatus, &lastRunAt, &task.CreatedAt, &task.UpdatedAt); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(command), &task.Command); err != nil {
+		return nil, fmt.Errorf("invalid command for task %s: %w", task.ID, err)
+	}
+	if lastRunAt.Valid {
+		task.LastRunAt = &lastRunAt.Time
+	}
+	return task, nil
+}
+
+// queryTasks returns the tasks a taskColumns query selects
+func (db *DB) queryTasks(query string, args ...interface{}) ([]*AppTask, error) {
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tasks := []*AppTask{}
+	for rows.Next() {
+		task, err := scanTask(rows)
+		if err != nil {
+			return nil, err
+		}
+		tasks = append(tasks, task)
+	}
+	return tasks, rows.Err()
+}
+
+// GetTasksByAppID returns an app's recurring tasks, oldest first
+func (db *DB) GetTasksByAppID(appID string) ([]*AppTask, error) {
+	return db.queryTasks(`SELECT `+taskColumns+` FROM app_tasks WHERE app_id = ? ORDER BY created_at`, appID)
+}
+
+// GetEnabledTasks returns the enabled recurring tasks of every app on this node
+func (db *DB) GetEnabledTasks() ([]*AppTask, error) {
+	return db.queryTasks(`SELECT ` + taskColumns + ` FROM app_tasks WHERE enabled = 1 ORDER BY created_at`)
+}
+
+// GetTask returns one of an app's recurring tasks
+func (db *DB) GetTask(appID, id string) (*AppTask, error) {
+	return scanTask(db.QueryRow(
+		`SELECT `+taskColumns+` FROM app_tasks WHERE app_id = ? AND id = ?`,
+		appID, id,
+	))
+}
+
+// GetTaskByID returns a recurring task without knowing its app, for the scheduler
+func (db *DB) GetTaskByID(id string) (*AppTask, error) {
+	return scanTask(db.QueryRow(`SELECT `+taskColumns+` FROM app_tasks WHERE id = ?`, id))
+}
+
+// CreateTask inserts a recurring task
+func (db *DB) CreateTask(task *AppTask) error {
+	command, err := json.Marshal(task.Command)
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec(
+		`INSERT INTO app_tasks (id, app_id, name, service, command, mode, cron, timezone, timeout_seconds, enabled, created_at, updated_at)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		task.ID, task.AppID, task.Name, task.Service, string(command), task.Mode, task.Cron, task.Timezone,
+		task.TimeoutSeconds, task.Enabled, task.CreatedAt, task.UpdatedAt,
+	)
+	return err
+}
+
+// UpdateTask saves a recurring task's definition and enabled state
+func (db *DB) UpdateTask(task *AppTask) error {
+	command, err := json.Marshal(task.Command)
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec(
+		`UPDATE app_tasks SET name = ?, service = ?, command = ?, mode = ?, cron = ?, timezone = ?, timeout_seconds = ?, enabled = ?, updated_at = ?
+		 WHERE id = ?`,
+		task.Name, task.Service, string(command), task.Mode, task.Cron, task.Timezone, task.TimeoutSeconds,
+		task.Enabled, task.UpdatedAt, task.ID,
+	)
+	return err
+}
+
+// DeleteTask deletes one of an app's recurring tasks and its runs, returning sql.ErrNoRows when it
+// doesn't exist
+func (db *DB) DeleteTask(appID, id string) error {
+	result, err := db.Exec(`DELETE FROM app_tasks WHERE app_id = ? AND id = ?`, appID, id)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return sql.ErrNoRows
+	}
+	_, err = db.Exec(`DELETE FROM app_task_runs WHERE task_id = ?`, id)
+	return err
+}
+
+// RecordTaskRun stores a finished run as its task's latest and drops the task's runs beyond the
+// newest keep
+func (db *DB) RecordTaskRun(run *AppTaskRun, keep int) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(
+		`INSERT INTO app_task_runs (id, task_id, app_id, job_id, status, exit_code, timed_out, output, error_message, started_at, finished_at)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		run.ID, run.TaskID, run.AppID, run.JobID, run.Status, run.ExitCode, run.TimedOut, run.Output,
+		run.ErrorMessage, run.StartedAt, run.FinishedAt,
+	); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(
+		`UPDATE app_tasks SET last_status = ?, last_run_at = ? WHERE id = ?`,
+		run.Status, run.StartedAt, run.TaskID,
+	); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(
+		`DELETE FROM app_task_runs WHERE task_id = ? AND id NOT IN (
+		     SELECT id FROM app_task_runs WHERE task_id = ? ORDER BY started_at DESC LIMIT ?
+		 )`,
+		run.TaskID, run.TaskID, keep,
+	); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// GetTaskRuns returns a task's most recent runs, newest first
+func (db *DB) GetTaskRuns(taskID string, limit int) ([]*AppTaskRun, error) {
+	rows, err := db.Query(
+		`SELECT id, task_id, app_id, job_id, status, exit_code, timed_out, output, error_message, started_at, finished_at
+		 FROM app_task_runs WHERE task_id = ? ORDER BY started_at DESC LIMIT ?`,
+		taskID, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	runs := []*AppTaskRun{}
+	for rows.Next() {
+		run := &AppTaskRun{}
+		var errorMessage sql.NullString
+		if err := rows.Scan(&run.ID, &run.TaskID, &run.AppID, &run.JobID, &run.Status, &run.ExitCode, &run.TimedOut,
+			&run.Output, &errorMessage, &run.StartedAt, &run.FinishedAt); err != nil {
+			return nil, err
+		}
+		if errorMessage.Valid {
+			run.ErrorMessage = &errorMessage.String
+		}
+		runs = append(runs, run)
+	}
+	return runs, rows.Err()
+}
+
+// GetActiveTaskJob returns the pending or running job of a recurring task, or nil when it has none
+func (db *DB) GetActiveTaskJob(taskID string) (*Job, error) {
+	var jobID string
+	err := db.QueryRow(
+		`SELECT id FROM jobs WHERE type = ? AND status IN (?, ?) AND json_extract(payload, '$.task_id') = ?
+		 ORDER BY created_at LIMIT 1`,
+		constants.JobTypeAppRun, constants.JobStatusPending, constants.JobStatusRunning, taskID,
+	).Scan(&jobID)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return db.GetJob(jobID)
+}
+
+// CreateSettingsChange records an update to a settings section
+func (db *DB) CreateSettingsChange(change *SettingsChange) error {
+	changes, err := json.Marshal(change.Changes)
+	if err != nil {
+		return err
+	}
+	var changedBy interface{}
+	if change.ChangedBy != "" {
+		changedBy = change.ChangedBy
+	}
+	_, err = db.Exec(
+		`INSERT INTO settings_history (id, section, changes, changed_by, created_at) VALUES (?, ?, ?, ?, ?)`,
+		change.ID, change.Section, string(changes), changedBy, change.CreatedAt,
+	)
+	return err
+}
+
+// GetSettingsHistory returns a section's most recent changes, newest first
+func (db *DB) GetSettingsHistory(section string, limit int) ([]*SettingsChange, error) {
+	rows, err := db.Query(
+		`SELECT id, section, changes, changed_by, created_at FROM settings_history
+		 WHERE section = ? ORDER BY created_at DESC LIMIT ?`,
+		section, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	history := []*SettingsChange{}
+	for rows.Next() {
+		change := &SettingsChange{}
+		var changes string
+		var changedBy sql.NullString
+		if err := rows.Scan(&change.ID, &change.Section, &changes, &changedBy, &change.CreatedAt); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(changes), &change.Changes); err != nil {
+			return nil, fmt.Errorf("invalid changes in settings history %s: %w", change.ID, err)
+		}
+		change.ChangedBy = changedBy.String
+		history = append(history, change)
+	}
+	return history, rows.Err()
+}
+
+// SavePrimaryReplica replaces the stored copy of one kind of primary metadata
+func (db *DB) SavePrimaryReplica(kind, data string) error {
+	_, err := db.Exec(
+		`INSERT INTO primary_replica (kind, data, synced_at) VALUES (?, ?, ?)
+		 ON CONFLICT(kind) DO UPDATE SET data = excluded.data, synced_at = excluded.synced_at`,
+		kind, data, time.Now(),
+	)
+	return err
+}
+
+// GetPrimaryReplica returns the stored copy of one kind of primary metadata, or sql.ErrNoRows
+// when it has never been synced
+func (db *DB) GetPrimaryReplica(kind string) (*PrimaryReplica, error) {
+	replica := &PrimaryReplica{}
+	err := db.QueryRow("SELECT kind, data, synced_at FROM primary_replica WHERE kind = ?", kind).
+		Scan(&replica.Kind, &replica.Data, &replica.SyncedAt)
+	if err != nil {
+		return nil, err
+	}
+	return replica, nil
+}
+
+// CreateNodeMetric stores one system stats sample of a node
+func (db *DB) CreateNodeMetric(metric *NodeMetric) error {
+	_, err := db.Exec(
+		`INSERT INTO node_metrics (node_id, cpu_percent, cpu_cores, memory_percent, disk_percent, load_1, load_5, load_15, temperature_celsius, recorded_at)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		metric.NodeID, metric.CPUPercent, metric.CPUCores, metric.MemoryPercent, metric.DiskPercent,
+		metric.Load1, metric.Load5, metric.Load15, metric.TemperatureCelsius, metric.RecordedAt,
+	)
+	return err
+}
+
+// GetNodeMetrics returns a node's samples recorded since the given time, oldest first
+func (db *DB) GetNodeMetrics(nodeID string, since time.Time) ([]*NodeMetric, error) {
+	rows, err := db.Query(
+		`SELECT node_id, cpu_percent, cpu_cores, memory_percent, disk_percent, load_1, load_5, load_15, temperature_celsius, recorded_at
+		 FROM node_metrics WHERE node_id = ? AND recorded_at >= ? ORDER BY recorded_at`,
+		nodeID, since,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	metrics := []*NodeMetric{}
+	for rows.Next() {
+		metric := &NodeMetric{}
+		var temperature sql.NullFloat64
+		if err := rows.Scan(&metric.NodeID, &metric.CPUPercent, &metric.CPUCores, &metric.MemoryPercent, &metric.DiskPercent,
+			&metric.Load1, &metric.Load5, &metric.Load15, &temperature, &metric.RecordedAt); err != nil {
+			return nil, err
+		}
+		if temperature.Valid {
+			metric.TemperatureCelsius = &temperature.Float64
+		}
+		metrics = append(metrics, metric)
+	}
+	return metrics, rows.Err()
+}
+
+// DeleteNodeMetricsBefore prunes samples of every node recorded before the given time
+func (db *DB) DeleteNodeMetricsBefore(before time.Time) (int64, error) {
+	result, err := db.Exec(`DELETE FROM node_metrics WHERE recorded_at < ?`, before)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// CreateNodeAlert records a node metric going over its threshold
+func (db *DB) CreateNodeAlert(alert *NodeAlert) error {
+	_, err := db.Exec(
+		`INSERT INTO node_alerts (id, node_id, metric, value, threshold, started_at, resolved_at) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		alert.ID, alert.NodeID, alert.Metric, alert.Value, alert.Threshold, alert.StartedAt, alert.ResolvedAt,
+	)
+	return err
+}
+
+// ResolveNodeAlert marks an active alert resolved
+func (db *DB) ResolveNodeAlert(id string, resolvedAt time.Time) error {
+	_, err := db.Exec(`UPDATE node_alerts SET resolved_at = ? WHERE id = ? AND resolved_at IS NULL`, resolvedAt, id)
+	return err
+}
+
+// GetNodeAlerts returns a node's most recent alerts, newest first; all nodes' when nodeID is
+// empty. activeOnly leaves out resolved alerts.
+func (db *DB) GetNodeAlerts(nodeID string, activeOnly bool, limit int) ([]*NodeAlert, error) {
+	query := `SELECT id, node_id, metric, value, threshold, started_at, resolved_at FROM node_alerts WHERE 1 = 1`
+	args := []interface{}{}
+	if nodeID != "" {
+		query += ` AND node_id = ?`
+		args = append(args, nodeID)
+	}
+	if activeOnly {
+		query += ` AND resolved_at IS NULL`
+	}
+	query += ` ORDER BY started_at DESC LIMIT ?`
+	args = append(args, limit)
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	alerts := []*NodeAlert{}
+	for rows.Next() {
+		alert := &NodeAlert{}
+		var resolvedAt sql.NullTime
+		if err := rows.Scan(&alert.ID, &alert.NodeID, &alert.Metric, &alert.Value, &alert.Threshold, &alert.StartedAt, &resolvedAt); err != nil {
+			return nil, err
+		}
+		if resolvedAt.Valid {
+			alert.ResolvedAt = &resolvedAt.Time
+		}
+		alerts = append(alerts, alert)
+	}
+	return alerts, rows.Err()
+}
+
+// EnsureSigningKey returns the signing key stored under name, storing candidate first if there
+// is none yet
+func (db *DB) EnsureSigningKey(name, candidate string) (string, error) {
+	if _, err := db.Exec(
+		`INSERT INTO signing_keys (name, secret, created_at) VALUES (?, ?, ?) ON CONFLICT(name) DO NOTHING`,
+		name, candidate, time.Now(),
+	); err != nil {
+		return "", err
+	}
+	var secret string
+	err := db.QueryRow(`SELECT secret FROM signing_keys WHERE name = ?`, name).Scan(&secret)
+	return secret, err
+}
+
+// gatewayTokenColumns lists gateway_tokens columns in the order scanGatewayToken reads them
+const gatewayTokenColumns = `id, name, rotated_from, expires_at, last_used_at, revoked_at, created_at`
+
+// scanGatewayToken reads a gateway token row selected with gatewayTokenColumns
+func scanGatewayToken(scanner interface{ Scan(dest ...interface{}) error }) (*GatewayToken, error) {
+	token := &GatewayToken{}
+	var rotatedFrom sql.NullString
+	var lastUsedAt, revokedAt sql.NullTime
+	if err := scanner.Scan(&token.ID, &token.Name, &rotatedFrom, &token.ExpiresAt, &lastUsedAt, &revokedAt, &token.CreatedAt); err != nil {
+		return nil, err
+	}
+	if rotatedFrom.Valid {
+		token.RotatedFrom = &rotatedFrom.String
+	}
+	if lastUsedAt.Valid {
+		token.LastUsedAt = &lastUsedAt.Time
+	}
+	if revokedAt.Valid {
+		token.RevokedAt = &revokedAt.Time
+	}
+	return token, nil
+}
+
+// GetGatewayTokens returns every gateway token, newest first
+func (db *DB) GetGatewayTokens() ([]*GatewayToken, error) {
+	rows, err := db.Query(`SELECT ` + gatewayTokenColumns + ` FROM gateway_tokens ORDER BY created_at DESC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tokens := []*GatewayToken{}
+	for rows.Next() {
+		token, err := scanGatewayToken(rows)
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, token)
+	}
+	return tokens, rows.Err()
+}
+
+// GetGatewayToken returns one gateway token
+func (db *DB) GetGatewayToken(id string) (*GatewayToken, error) {
+	return scanGatewayToken(db.QueryRow(`SELECT `+gatewayTokenColumns+` FROM gateway_tokens WHERE id = ?`, id))
+}
+
+// CreateGatewayToken inserts a gateway token
+func (db *DB) CreateGatewayToken(token *GatewayToken) error {
+	_, err := db.Exec(
+		`INSERT INTO gateway_tokens (id, name, rotated_from, expires_at, created_at) VALUES (?, ?, ?, ?, ?)`,
+		token.ID, token.Name, token.RotatedFrom, token.ExpiresAt, token.CreatedAt,
+	)
+	return err
+}
+
+// TouchGatewayToken records that a gateway token was just used
+func (db *DB) TouchGatewayToken(id string, usedAt time.Time) error {
+	_, err := db.Exec(`UPDATE gateway_tokens SET last_used_at = ? WHERE id = ?`, usedAt, id)
+	return err
+}
+
+// RevokeGatewayToken revokes a gateway token; returns sql.ErrNoRows if there is no such token
+// or it was already revoked
+func (db *DB) RevokeGatewayToken(id string, revokedAt time.Time) error {
+	result, err := db.Exec(`UPDATE gateway_tokens SET revoked_at = ? WHERE id = ? AND revoked_at IS NULL`, revokedAt, id)
+	if err != nil {
+		return err
+	}
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		return sql.ErrNoRows
+	}
+	return err
+}
+
+// snapshotColumns lists app_snapshots columns in the order scanSnapshot reads them
+const snapshotColumns = `id, app_id, name, compose_version, compose_content, compose_override, compose_files, env_template, env_content, image_digests, created_by, created_at`
+
+// scanSnapshot reads a snapshot row selected with snapshotColumns
+func scanSnapshot(scanner interface{ Scan(dest ...interface{}) error }) (*AppSnapshot, error) {
+	snapshot := &AppSnapshot{}
+	var composeOverride, composeFiles, envTemplate, envContent, imageDigests, createdBy sql.NullString
+	if err := scanner.Scan(&snapshot.ID, &snapshot.AppID, &snapshot.Name, &snapshot.ComposeVersion, &snapshot.ComposeContent,
+		&composeOverride, &composeFiles, &envTemplate, &envContent, &imageDigests, &createdBy, &snapshot.CreatedAt); err != nil {
+		return nil, err
+	}
+	snapshot.ComposeOverride = composeOverride.String
+	snapshot.EnvTemplate = envTemplate.String
+	snapshot.EnvContent = envContent.String
+	snapshot.ImageDigests = decodeImageDigests(imageDigests.String)
+	if createdBy.Valid {
+		snapshot.CreatedBy = &createdBy.String
+	}
+	var err error
+	snapshot.ComposeFiles, err = decodeComposeFiles(snapshot.AppID, composeFiles.String)
+	return snapshot, err
+}
+
+// GetSnapshotsByAppID returns an app's snapshots, newest first
+func (db *DB) GetSnapshotsByAppID(appID string) ([]*AppSnapshot, error) {
+	rows, err := db.Query(`SELECT `+snapshotColumns+` FROM app_snapshots WHERE app_id = ? ORDER BY created_at DESC`, appID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	snapshots := []*AppSnapshot{}
+	for rows.Next() {
+		snapshot, err := scanSnapshot(rows)
+		if err != nil {
+			return nil, err
+		}
+		snapshots = append(snapshots, snapshot)
+	}
+	return snapshots, rows.Err()
+}
+
+// GetSnapshot returns one of an app's snapshots
+func (db *DB) GetSnapshot(appID, id string) (*AppSnapshot, error) {
+	return scanSnapshot(db.QueryRow(`SELECT `+snapshotColumns+` FROM app_snapshots WHERE app_id = ? AND id = ?`, appID, id))
+}
+
+// GetSnapshotByName returns the app's snapshot with the given name
+func (db *DB) GetSnapshotByName(appID, name string) (*AppSnapshot, error) {
+	return scanSnapshot(db.QueryRow(`SELECT `+snapshotColumns+` FROM app_snapshots WHERE app_id = ? AND name = ?`, appID, name))
+}
+
+// CreateSnapshot inserts an app snapshot
+func (db *DB) CreateSnapshot(snapshot *AppSnapshot) error {
+	composeFiles, err := encodeComposeFiles(snapshot.ComposeFiles)
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec(
+		`INSERT INTO app_snapshots (id, app_id, name, compose_version, compose_content, compose_override, compose_files, env_template, env_content, image_digests, created_by, created_at)
+		 VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		snapshot.ID, snapshot.AppID, snapshot.Name, snapshot.ComposeVersion, snapshot.ComposeContent, snapshot.ComposeOverride,
+		composeFiles, snapshot.EnvTemplate, snapshot.EnvContent, encodeImageDigests(snapshot.ImageDigests), snapshot.CreatedBy, snapshot.CreatedAt,
+	)
+	return err
+}
+
+// DeleteSnapshot deletes one of an app's snapshots, returning sql.ErrNoRows when it doesn't exist
+func (db *DB) DeleteSnapshot(appID, id string) error {
+	result, err := db.Exec(`DELETE FROM app_snapshots WHERE app_id = ? AND id = ?`, appID, id)
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// CreateNodeLogs stores a batch of server log lines shipped by a node
+func (db *DB) CreateNodeLogs(logs []*NodeLog) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.Prepare(`INSERT INTO node_logs (node_id, logged_at, level, message, line) VALUES (?, ?, ?, ?, ?)`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for _, l := range logs {
+		if _, err := stmt.Exec(l.NodeID, l.LoggedAt, l.Level, l.Message, l.Line); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// GetNodeLogs returns the newest limit server log lines a node shipped since the given time,
+// oldest first. levels, when not empty, keeps only lines at those levels.
+func (db *DB) GetNodeLogs(nodeID string, since time.Time, levels []string, limit int) ([]*NodeLog, error) {
+	query := `SELECT id, node_id, logged_at, level, message, line FROM node_logs WHERE node_id = ? AND logged_at >= ?`
+	args := []interface{}{nodeID, since}
+	if len(levels) > 0 {
+		query += ` AND level IN (?` + strings.Repeat(`, ?`, len(levels)-1) + `)`
+		for _, level := range levels {
+			args = append(args, level)
+		}
+	}
+	query += ` ORDER BY logged_at DESC, id DESC LIMIT ?`
+	args = append(args, limit)
+
+	rows, err := db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	logs := []*NodeLog{}
+	for rows.Next() {
+		l := &NodeLog{}
+		if err := rows.Scan(&l.ID, &l.NodeID, &l.LoggedAt, &l.Level, &l.Message, &l.Line); err != nil {
+			return nil, err
+		}
+		logs = append(logs, l)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	slices.Reverse(logs)
+	return logs, nil
+}
+
+// DeleteNodeLogsBefore prunes server log lines of every node logged before the given time
+func (db *DB) DeleteNodeLogsBefore(before time.Time) (int64, error) {
+	result, err := db.Exec(`DELETE FROM node_logs WHERE logged_at < ?`, before)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// SetAppLogForwarding turns log forwarding on or off for an app
+func (db *DB) SetAppLogForwarding(appID string, enabled bool) error {
+	if !enabled {
+		_, err := db.Exec(`DELETE FROM app_log_forwarding WHERE app_id = ?`, appID)
+		return err
+	}
+	_, err := db.Exec(`INSERT INTO app_log_forwarding (app_id, enabled_at) VALUES (?, ?) ON CONFLICT(app_id) DO NOTHING`, appID, time.Now())
+	return err
+}
+
+// IsAppLogForwardingEnabled reports whether an app's container logs are forwarded
+func (db *DB) IsAppLogForwardingEnabled(appID string) (bool, error) {
+	var count int
+	if err := db.QueryRow(`SELECT COUNT(*) FROM app_log_forwarding WHERE app_id = ?`, appID).Scan(&count); err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// GetLogForwardingAppIDs returns the IDs of every app with log forwarding on
+func (db *DB) GetLogForwardingAppIDs() ([]string, error) {
+	rows, err := db.Query(`SELECT app_id FROM app_log_forwarding ORDER BY enabled_at`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := []string{}
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// GetUserPreferences returns a user's preferences, or nil if they haven't saved any
+func (db *DB) GetUserPreferences(userID string) (*UserPreferences, error) {
+	prefs := &UserPreferences{}
+	var tableColumns string
+	err := db.QueryRow(
+		`SELECT user_id, default_node_id, table_columns, refresh_interval, theme, updated_at
+		 FROM user_preferences WHERE user_id = ?`,
+		userID,
+	).Scan(&prefs.UserID, &prefs.DefaultNodeID, &tableColumns, &prefs.RefreshInterval, &prefs.Theme, &prefs.UpdatedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(tableColumns), &prefs.TableColumns); err != nil {
+		return nil, fmt.Errorf("failed to parse table columns: %w", err)
+	}
+	return prefs, nil
+}
+
+// SaveUserPreferences creates or replaces a user's preferences
+func (db *DB) SaveUserPreferences(prefs *UserPreferences) error {
+	tableColumns, err := json.Marshal(prefs.TableColumns)
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec(
+		`INSERT INTO user_preferences (user_id, default_node_id, table_columns, refresh_interval, theme, updated_at)
+		 VALUES (?, ?, ?, ?, ?, ?)
+		 ON CONFLICT(user_id) DO UPDATE SET default_node_id = excluded.default_node_id, table_columns = excluded.table_columns,
+		 refresh_interval = excluded.refresh_interval, theme = excluded.theme, updated_at = excluded.updated_at`,
+		prefs.UserID, prefs.DefaultNodeID, string(tableColumns), prefs.RefreshInterval, prefs.Theme, prefs.UpdatedAt,
+	)
+	return err
+}
+
+// GetOperationsLock returns the operations lock, or nil while operations aren't locked
+func (db *DB) GetOperationsLock() (*OperationsLock, error) {
+	lock := &OperationsLock{}
+	err := db.QueryRow(`SELECT reason, locked_by, locked_at FROM operations_lock WHERE id = 1`).
+		Scan(&lock.Reason, &lock.LockedBy, &lock.LockedAt)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return lock, nil
+}
+
+// SetOperationsLock locks operations, replacing any current lock, or unlocks them when lock is nil
+func (db *DB) SetOperationsLock(lock *OperationsLock) error {
+	if lock == nil {
+		_, err := db.Exec(`DELETE FROM operations_lock`)
+		return err
+	}
+	_, err := db.Exec(
+		`INSERT INTO operations_lock (id, reason, locked_by, locked_at) VALUES (1, ?, ?, ?)
+		 ON CONFLICT(id) DO UPDATE SET reason = excluded.reason, locked_by = excluded.locked_by, locked_at = excluded.locked_at`,
+		lock.Reason, lock.LockedBy, lock.LockedAt,
+	)
+	return err
+}
+
+// approvalColumns lists approvals columns in the order scanApproval reads them
+const approvalColumns = `id, operation, resource_id, params, status, requested_by, requested_at, expires_at, decided_by, decided_at, error`
+
+// scanApproval reads an approval row selected with approvalColumns
+func scanApproval(scanner interface{ Scan(dest ...interface{}) error }) (*Approval, error) {
+	approval := &Approval{}
+	var params string
+	var decidedAt sql.NullTime
+	if err := scanner.Scan(&approval.ID, &approval.Operation, &approval.ResourceID, &params, &approval.Status, &approval.RequestedBy,
+		&approval.RequestedAt, &approval.ExpiresAt, &approval.DecidedBy, &decidedAt, &approval.Error); err != nil {
+		return nil, err
+	}
+	if decidedAt.Valid {
+		approval.DecidedAt = &decidedAt.Time
+	}
+	if err := json.Unmarshal([]byte(params), &approval.Params); err != nil {
+		return nil, fmt.Errorf("failed to parse approval params: %w", err)
+	}
+	return approval, nil
+}
+
+// CreateApproval inserts a pending approval
+func (db *DB) CreateApproval(approval *Approval) error {
+	params, err := json.Marshal(approval.Params)
+	if err != nil {
+		return err
+	}
+	_, err = db.Exec(
+		`INSERT INTO approvals (id, operation, resource_id, params, status, requested_by, requested_at, expires_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		approval.ID, approval.Operation, approval.ResourceID, string(params), approval.Status, approval.RequestedBy, approval.RequestedAt, approval.ExpiresAt,
+	)
+	return err
+}
+
+// GetApproval returns one approval
+func (db *DB) GetApproval(id string) (*Approval, error) {
+	return scanApproval(db.QueryRow(`SELECT `+approvalColumns+` FROM approvals WHERE id = ?`, id))
+}
+
+// GetPendingApproval returns the pending approval of an operation on a resource, or nil if there is none
+func (db *DB) GetPendingApproval(operation, resourceID string) (*Approval, error) {
+	approval, err := scanApproval(db.QueryRow(
+		`SELECT `+approvalColumns+` FROM approvals WHERE operation = ? AND resource_id = ? AND status = ? ORDER BY requested_at DESC LIMIT 1`,
+		operation, resourceID, constants.ApprovalStatusPending,
+	))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return approval, err
+}
+
+// GetApprovals returns up to limit approvals, newest first; status "" returns every status
+func (db *DB) GetApprovals(status string, limit int) ([]*Approval, error) {
+	rows, err := db.Query(
+		`SELECT `+approvalColumns+` FROM approvals WHERE (? = '' OR status = ?) ORDER BY requested_at DESC LIMIT ?`,
+		status, status, limit,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	approvals := []*Approval{}
+	for rows.Next() {
+		approval, err := scanApproval(rows)
+		if err != nil {
+			return nil, err
+		}
+		approvals = append(approvals, approval)
+	}
+	return approvals, rows.Err()
+}
+
+// ExpireApprovals marks pending approvals that expired before now and returns how many there were
+func (db *DB) ExpireApprovals(now time.Time) (int64, error) {
+	result, err := db.Exec(
+		`UPDATE approvals SET status = ?, decided_at = expires_at WHERE status = ? AND expires_at <= ?`,
+		constants.ApprovalStatusExpired, constants.ApprovalStatusPending, now,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// DecideApproval confirms or rejects a pending approval; returns sql.ErrNoRows if there is no such
+// approval or it is no longer pending, so two admins can't both confirm it
+func (db *DB) DecideApproval(id, status, decidedBy string, decidedAt time.Time) error {
+	result, err := db.Exec(
+		`UPDATE approvals SET status = ?, decided_by = ?, decided_at = ? WHERE id = ? AND status = ? AND expires_at > ?`,
+		status, decidedBy, decidedAt, id, constants.ApprovalStatusPending, decidedAt,
+	)
+	if err != nil {
+		return err
+	}
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		return sql.ErrNoRows
+	}
+	return err
+}
+
+// SetApprovalError records why a confirmed operation failed
+func (db *DB) SetApprovalError(id, message string) error {
+	_, err := db.Exec(`UPDATE approvals SET error = ? WHERE id = ?`, message, id)
+	return err
+}
+
+// userQuotaColumns lists user_quotas columns in the order scanUserQuota reads them
+const userQuotaColumns = `user_name, max_apps, max_memory_mb, max_cpus, max_tunnels, updated_at`
+
+// scanUserQuota reads a user_quotas row selected with userQuotaColumns
+func scanUserQuota(scanner interface{ Scan(dest ...interface{}) error }) (*UserQuota, error) {
+	quota := &UserQuota{}
+	if err := scanner.Scan(&quota.UserName, &quota.MaxApps, &quota.MaxMemoryMB, &quota.MaxCPUs, &quota.MaxTunnels, &quota.UpdatedAt); err != nil {
+		return nil, err
+	}
+	return quota, nil
+}
+
+// GetUserQuotas returns every quota, ordered by user name
+func (db *DB) GetUserQuotas() ([]*UserQuota, error) {
+	rows, err := db.Query(`SELECT ` + userQuotaColumns + ` FROM user_quotas ORDER BY user_name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	quotas := []*UserQuota{}
+	for rows.Next() {
+		quota, err := scanUserQuota(rows)
+		if err != nil {
+			return nil, err
+		}
+		quotas = append(quotas, quota)
+	}
+	return quotas, rows.Err()
+}
+
+// GetUserQuota returns a user's quota, or nil if they have none of their own
+func (db *DB) GetUserQuota(userName string) (*UserQuota, error) {
+	quota, err := scanUserQuota(db.QueryRow(`SELECT `+userQuotaColumns+` FROM user_quotas WHERE user_name = ?`, userName))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return quota, err
+}
+
+// SaveUserQuota creates or replaces a user's quota
+func (db *DB) SaveUserQuota(quota *UserQuota) error {
+	_, err := db.Exec(
+		`INSERT INTO user_quotas (user_name, max_apps, max_memory_mb, max_cpus, max_tunnels, updated_at) VALUES (?, ?, ?, ?, ?, ?)
+		 ON CONFLICT(user_name) DO UPDATE SET max_apps = excluded.max_apps, max_memory_mb = excluded.max_memory_mb,
+		 max_cpus = excluded.max_cpus, max_tunnels = excluded.max_tunnels, updated_at = excluded.updated_at`,
+		quota.UserName, quota.MaxApps, quota.MaxMemoryMB, quota.MaxCPUs, quota.MaxTunnels, quota.UpdatedAt,
+	)
+	return err
+}
+
+// DeleteUserQuota removes a user's quota; returns sql.ErrNoRows if they had none
+func (db *DB) DeleteUserQuota(userName string) error {
+	result, err := db.Exec(`DELETE FROM user_quotas WHERE user_name = ?`, userName)
+	if err != nil {
+		return err
+	}
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		return sql.ErrNoRows
+	}
+	return err
+}
+
+// userColumns lists users columns in the order scanUser reads them
+const userColumns = `id, username, password, role, email, invited_by, created_at`
+
+// scanUser reads a user row selected with userColumns
+func scanUser(scanner interface{ Scan(dest ...interface{}) error }) (*User, error) {
+	user := &User{}
+	if err := scanner.Scan(&user.ID, &user.Username, &user.Password, &user.Role, &user.Email, &user.InvitedBy, &user.CreatedAt); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// GetUsers returns every user's account, oldest first
+func (db *DB) GetUsers() ([]*User, error) {
+	rows, err := db.Query(`SELECT ` + userColumns + ` FROM users ORDER BY created_at`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := []*User{}
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, user)
+	}
+	return users, rows.Err()
+}
+
+// DeleteUser deletes a user's account; returns sql.ErrNoRows if they have none
+func (db *DB) DeleteUser(username string) error {
+	result, err := db.Exec(`DELETE FROM users WHERE username = ?`, username)
+	if err != nil {
+		return err
+	}
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		return sql.ErrNoRows
+	}
+	return err
+}
+
+// ReplaceUsers replaces every user's account with users, in one transaction
+func (db *DB) ReplaceUsers(users []*User) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`DELETE FROM users`); err != nil {
+		return err
+	}
+	for _, user := range users {
+		if _, err := tx.Exec(
+			`INSERT INTO users (id, username, password, role, email, invited_by, created_at) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+			user.ID, user.Username, user.Password, user.Role, user.Email, user.InvitedBy, user.CreatedAt,
+		); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+// invitationColumns lists invitations columns in the order scanInvitation reads them
+const invitationColumns = `id, token_hash, email, role, created_by, expires_at, accepted_by, accepted_at, revoked_at, created_at`
+
+// scanInvitation reads an invitation row selected with invitationColumns and derives its status
+func scanInvitation(scanner interface{ Scan(dest ...interface{}) error }) (*Invitation, error) {
+	invitation := &Invitation{}
+	var acceptedAt, revokedAt sql.NullTime
+	if err := scanner.Scan(&invitation.ID, &invitation.TokenHash, &invitation.Email, &invitation.Role, &invitation.CreatedBy,
+		&invitation.ExpiresAt, &invitation.AcceptedBy, &acceptedAt, &revokedAt, &invitation.CreatedAt); err != nil {
+		return nil, err
+	}
+	switch {
+	case acceptedAt.Valid:
+		invitation.AcceptedAt = &acceptedAt.Time
+		invitation.Status = constants.InvitationStatusAccepted
+	case revokedAt.Valid:
+		invitation.RevokedAt = &revokedAt.Time
+		invitation.Status = constants.InvitationStatusRevoked
+	case !time.Now().Before(invitation.ExpiresAt):
+		invitation.Status = constants.InvitationStatusExpired
+	default:
+		invitation.Status = constants.InvitationStatusPending
+	}
+	return invitation, nil
+}
+
+// GetInvitations returns every invitation, newest first
+func (db *DB) GetInvitations() ([]*Invitation, error) {
+	rows, err := db.Query(`SELECT ` + invitationColumns + ` FROM invitations ORDER BY created_at DESC`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	invitations := []*Invitation{}
+	for rows.Next() {
+		invitation, err := scanInvitation(rows)
+		if err != nil {
+			return nil, err
+		}
+		invitations = append(invitations, invitation)
+	}
+	return invitations, rows.Err()
+}
+
+// GetInvitationByTokenHash returns the invitation whose token hashes to tokenHash
+func (db *DB) GetInvitationByTokenHash(tokenHash string) (*Invitation, error) {
+	return scanInvitation(db.QueryRow(`SELECT `+invitationColumns+` FROM invitations WHERE token_hash = ?`, tokenHash))
+}
+
+// CreateInvitation inserts an invitation
+func (db *DB) CreateInvitation(invitation *Invitation) error {
+	_, err := db.Exec(
+		`INSERT INTO invitations (id, token_hash, email, role, created_by, expires_at, created_at) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		invitation.ID, invitation.TokenHash, invitation.Email, invitation.Role, invitation.CreatedBy, invitation.ExpiresAt, invitation.CreatedAt,
+	)
+	return err
+}
+
+// RevokeInvitation revokes an invitation; returns sql.ErrNoRows if there is no such invitation or
+// it was already accepted or revoked
+func (db *DB) RevokeInvitation(id string, revokedAt time.Time) error {
+	result, err := db.Exec(`UPDATE invitations SET revoked_at = ? WHERE id = ? AND accepted_at IS NULL AND revoked_at IS NULL`, revokedAt, id)
+	if err != nil {
+		return err
+	}
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		return sql.ErrNoRows
+	}
+	return err
+}
+
+// AcceptInvitation marks an invitation accepted and creates the account of the user accepting it,
+// in one transaction; returns sql.ErrNoRows if the invitation was meanwhile accepted, revoked or
+// has expired
+func (db *DB) AcceptInvitation(id string, user *User) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(
+		`UPDATE invitations SET accepted_by = ?, accepted_at = ? WHERE id = ? AND accepted_at IS NULL AND revoked_at IS NULL AND expires_at > ?`,
+		user.Username, user.CreatedAt, id, user.CreatedAt,
+	)
+	if err != nil {
+		return err
+	}
+	if affected, err := result.RowsAffected(); err != nil {
+		return err
+	} else if affected == 0 {
+		return sql.ErrNoRows
+	}
+
+	if _, err := tx.Exec(
+		`INSERT INTO users (id, username, password, role, email, invited_by, created_at) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		user.ID, user.Username, user.Password, user.Role, user.Email, user.InvitedBy, user.CreatedAt,
+	); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// sessionColumns lists sessions columns in the order scanSession reads them
+const sessionColumns = `id, user_name, user_agent, ip, created_at, last_seen_at, revoked_at`
+
+// scanSession reads a session row selected with sessionColumns
+func scanSession(scanner interface{ Scan(dest ...interface{}) error }) (*Session, error) {
+	session := &Session{}
+	var revokedAt sql.NullTime
+	if err := scanner.Scan(&session.ID, &session.UserName, &session.UserAgent, &session.IP, &session.CreatedAt, &session.LastSeenAt, &revokedAt); err != nil {
+		return nil, err
+	}
+	if revokedAt.Valid {
+		session.RevokedAt = &revokedAt.Time
+	}
+	return session, nil
+}
+
+// GetSession returns one session
+func (db *DB) GetSession(id string) (*Session, error) {
+	return scanSession(db.QueryRow(`SELECT `+sessionColumns+` FROM sessions WHERE id = ?`, id))
+}
+
+// GetUserSessions returns a user's sessions that aren't revoked and were seen after since, most
+// recently seen first
+func (db *DB) GetUserSessions(userName string, since time.Time) ([]*Session, error) {
+	rows, err := db.Query(`SELECT `+sessionColumns+` FROM sessions WHERE user_name = ? AND revoked_at IS NULL AND last_seen_at > ? ORDER BY last_seen_at DESC`, userName, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := []*Session{}
+	for rows.Next() {
+		session, err := scanSession(rows)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, session)
+	}
+	return sessions, rows.Err()
+}
+
+// TouchSession records a session as seen, with the device it was seen from, creating it on first
+// sight. A revoked session stays revoked.
+func (db *DB) TouchSession(session *Session) error {
+	_, err := db.Exec(
+		`INSERT INTO sessions (id, user_name, user_agent, ip, created_at, last_seen_at) VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT(id) DO UPDATE SET user_agent = excluded.user_agent, ip = excluded.ip, last_seen_at = excluded.last_seen_at`,
+		session.ID, session.UserName, session.UserAgent, session.IP, session.CreatedAt, session.LastSeenAt,
+	)
+	return err
+}
+
+// RevokeSession revokes one of a user's sessions; returns sql.ErrNoRows if the user has no such
+// session or it was already revoked
+func (db *DB) RevokeSession(id, userName string, revokedAt time.Time) error {
+	result, err := db.Exec(`UPDATE sessions SET revoked_at = ? WHERE id = ? AND user_name = ? AND revoked_at IS NULL`, revokedAt, id, userName)
+	if err != nil {
+		return err
+	}
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		return sql.ErrNoRows
+	}
+	return err
+}
+
+// apiTokenColumns lists api_tokens columns in the order scanAPIToken reads them
+const apiTokenColumns = `id, name, user_name, token_hash, token_prefix, read_only, expires_at, last_used_at, revoked_at, created_at`
+
+// scanAPIToken reads an API token row selected with apiTokenColumns
+func scanAPIToken(scanner interface{ Scan(dest ...interface{}) error }) (*APIToken, error) {
+	token := &APIToken{}
+	var expiresAt, lastUsedAt, revokedAt sql.NullTime
+	if err := scanner.Scan(&token.ID, &token.Name, &token.UserName, &token.TokenHash, &token.TokenPrefix, &token.ReadOnly,
+		&expiresAt, &lastUsedAt, &revokedAt, &token.CreatedAt); err != nil {
+		return nil, err
+	}
+	if expiresAt.Valid {
+		token.ExpiresAt = &expiresAt.Time
+	}
+	if lastUsedAt.Valid {
+		token.LastUsedAt = &lastUsedAt.Time
+	}
+	if revokedAt.Valid {
+		token.RevokedAt = &revokedAt.Time
+	}
+	return token, nil
+}
+
+// GetUserAPITokens returns a user's API tokens that aren't revoked, newest first
+func (db *DB) GetUserAPITokens(userName string) ([]*APIToken, error) {
+	rows, err := db.Query(`SELECT `+apiTokenColumns+` FROM api_tokens WHERE user_name = ? AND revoked_at IS NULL ORDER BY created_at DESC`, userName)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tokens := []*APIToken{}
+	for rows.Next() {
+		token, err := scanAPIToken(rows)
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, token)
+	}
+	return tokens, rows.Err()
+}
+
+// GetUserAPIToken returns one of a user's API tokens
+func (db *DB) GetUserAPIToken(id, userName string) (*APIToken, error) {
+	return scanAPIToken(db.QueryRow(`SELECT `+apiTokenColumns+` FROM api_tokens WHERE id = ? AND user_name = ?`, id, userName))
+}
+
+// GetAPITokenByHash returns the API token whose token hashes to tokenHash
+func (db *DB) GetAPITokenByHash(tokenHash string) (*APIToken, error) {
+	return scanAPIToken(db.QueryRow(`SELECT `+apiTokenColumns+` FROM api_tokens WHERE token_hash = ?`, tokenHash))
+}
+
+// CreateAPIToken inserts an API token
+func (db *DB) CreateAPIToken(token *APIToken) error {
+	_, err := db.Exec(
+		`INSERT INTO api_tokens (id, name, user_name, token_hash, token_prefix, read_only, expires_at, created_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		token.ID, token.Name, token.UserName, token.TokenHash, token.TokenPrefix, token.ReadOnly, token.ExpiresAt, token.CreatedAt,
+	)
+	return err
+}
+
+// RenameAPIToken renames one of a user's API tokens; returns sql.ErrNoRows if the user has no
+// such token or it was revoked
+func (db *DB) RenameAPIToken(id, userName, name string) error {
+	result, err := db.Exec(`UPDATE api_tokens SET name = ? WHERE id = ? AND user_name = ? AND revoked_at IS NULL`, name, id, userName)
+	if err != nil {
+		return err
+	}
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		return sql.ErrNoRows
+	}
+	return err
+}
+
+// TouchAPIToken records that an API token was just used
+func (db *DB) TouchAPIToken(id string, usedAt time.Time) error {
+	_, err := db.Exec(`UPDATE api_tokens SET last_used_at = ? WHERE id = ?`, usedAt, id)
+	return err
+}
+
+// RevokeAPIToken revokes one of a user's API tokens; returns sql.ErrNoRows if the user has no
+// such token or it was already revoked
+func (db *DB) RevokeAPIToken(id, userName string, revokedAt time.Time) error {
+	result, err := db.Exec(`UPDATE api_tokens SET revoked_at = ? WHERE id = ? AND user_name = ? AND revoked_at IS NULL`, revokedAt, id, userName)
+	if err != nil {
+		return err
+	}
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		return sql.ErrNoRows
+	}
+	return err
+}
+
+// ngrokTunnelColumns lists ngrok_tunnels columns in the order scanNgrokTunnel reads them
+const ngrokTunnelColumns = `id, app_id, tunnel_name, upstream, public_url, status, created_at, updated_at`
+
+// scanNgrokTunnel reads an ngrok tunnel row selected with ngrokTunnelColumns
+func scanNgrokTunnel(scanner interface{ Scan(dest ...interface{}) error }) (*NgrokTunnel, error) {
+	t := &NgrokTunnel{}
+	if err := scanner.Scan(&t.ID, &t.AppID, &t.TunnelName, &t.Upstream, &t.PublicURL, &t.Status, &t.CreatedAt, &t.UpdatedAt); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// CreateNgrokTunnel inserts an ngrok tunnel
+func (db *DB) CreateNgrokTunnel(t *NgrokTunnel) error {
+	_, err := db.Exec(
+		`INSERT INTO ngrok_tunnels (`+ngrokTunnelColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		t.ID, t.AppID, t.TunnelName, t.Upstream, t.PublicURL, t.Status, t.CreatedAt, t.UpdatedAt,
+	)
+	return err
+}
+
+// GetNgrokTunnel returns an ngrok tunnel by its ID
+func (db *DB) GetNgrokTunnel(id string) (*NgrokTunnel, error) {
+	return scanNgrokTunnel(db.QueryRow(`SELECT `+ngrokTunnelColumns+` FROM ngrok_tunnels WHERE id = ?`, id))
+}
+
+// GetNgrokTunnelByAppID returns an app's ngrok tunnel
+func (db *DB) GetNgrokTunnelByAppID(appID string) (*NgrokTunnel, error) {
+	return scanNgrokTunnel(db.QueryRow(`SELECT `+ngrokTunnelColumns+` FROM ngrok_tunnels WHERE app_id = ?`, appID))
+}
+
+// GetNgrokTunnelsWithoutApp returns the ngrok tunnels created before createdBefore whose app no
+// longer exists. Tunnels are created just before their app, so recent ones are left out.
+func (db *DB) GetNgrokTunnelsWithoutApp(createdBefore time.Time) ([]*NgrokTunnel, error) {
+	rows, err := db.Query(`SELECT `+ngrokTunnelColumns+` FROM ngrok_tunnels WHERE app_id NOT IN (SELECT id FROM apps) AND created_at < ?`, createdBefore)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tunnels := []*NgrokTunnel{}
+	for rows.Next() {
+		t, err := scanNgrokTunnel(rows)
+		if err != nil {
+			return nil, err
+		}
+		tunnels = append(tunnels, t)
+	}
+	return tunnels, rows.Err()
+}
+
+// DeleteNgrokTunnel deletes an app's ngrok tunnel
+func (db *DB) DeleteNgrokTunnel(appID string) error {
+	_, err := db.Exec(`DELETE FROM ngrok_tunnels WHERE app_id = ?`, appID)
+	return err
+}
+
+// frpTunnelColumns lists frp_tunnels columns in the order scanFrpTunnel reads them
+const frpTunnelColumns = `id, app_id, tunnel_name, ingress_rules, public_url, status, created_at, updated_at`
+
+// scanFrpTunnel reads an frp tunnel row selected with frpTunnelColumns
+func scanFrpTunnel(scanner interface{ Scan(dest ...interface{}) error }) (*FrpTunnel, error) {
+	t := &FrpTunnel{}
+	var rules string
+	if err := scanner.Scan(&t.ID, &t.AppID, &t.TunnelName, &rules, &t.PublicURL, &t.Status, &t.CreatedAt, &t.UpdatedAt); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(rules), &t.IngressRules); err != nil {
+		return nil, fmt.Errorf("failed to parse ingress rules of frp tunnel %s: %w", t.ID, err)
+	}
+	return t, nil
+}
+
+// CreateFrpTunnel inserts an frp tunnel
+func (db *DB) CreateFrpTunnel(t *FrpTunnel) error {
+	rules, err := json.Marshal(t.IngressRules)
+	if err != nil {
+		return fmt.Errorf("failed to marshal ingress rules: %w", err)
+	}
+	_, err = db.Exec(
+		`INSERT INTO frp_tunnels (`+frpTunnelColumns+`) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		t.ID, t.AppID, t.TunnelName, string(rules), t.PublicURL, t.Status, t.CreatedAt, t.UpdatedAt,
+	)
+	return err
+}
+
+// GetFrpTunnel returns an frp tunnel by its ID
+func (db *DB) GetFrpTunnel(id string) (*FrpTunnel, error) {
+	return scanFrpTunnel(db.QueryRow(`SELECT `+frpTunnelColumns+` FROM frp_tunnels WHERE id = ?`, id))
+}
+
+// GetFrpTunnelByAppID returns an app's frp tunnel
+func (db *DB) GetFrpTunnelByAppID(appID string) (*FrpTunnel, error) {
+	return scanFrpTunnel(db.QueryRow(`SELECT `+frpTunnelColumns+` FROM frp_tunnels WHERE app_id = ?`, appID))
+}
+
+// UpdateFrpTunnelIngress replaces an app's frp tunnel rules and public URL. Returns sql.ErrNoRows
+// if the app has no frp tunnel.
+func (db *DB) UpdateFrpTunnelIngress(appID string, rules []IngressRule, publicURL string) error {
+	encoded, err := json.Marshal(rules)
+	if err != nil {
+		return fmt.Errorf("failed to marshal ingress rules: %w", err)
+	}
+	result, err := db.Exec(
+		`UPDATE frp_tunnels SET ingress_rules = ?, public_url = ?, updated_at = ? WHERE app_id = ?`,
+		string(encoded), publicURL, time.Now(), appID,
+	)
+	if err != nil {
+		return err
+	}
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		return sql.ErrNoRows
+	}
+	return err
+}
+
+// GetFrpTunnelsWithoutApp returns the frp tunnels created before createdBefore whose app no
+// longer exists. Tunnels are created just before their app, so recent ones are left out.
+func (db *DB) GetFrpTunnelsWithoutApp(createdBefore time.Time) ([]*FrpTunnel, error) {
+	rows, err := db.Query(`SELECT `+frpTunnelColumns+` FROM frp_tunnels WHERE app_id NOT IN (SELECT id FROM apps) AND created_at < ?`, createdBefore)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tunnels := []*FrpTunnel{}
+	for rows.Next() {
+		t, err := scanFrpTunnel(rows)
+		if err != nil {
+			return nil, err
+		}
+		tunnels = append(tunnels, t)
+	}
+	return tunnels, rows.Err()
+}
+
+// DeleteFrpTunnel deletes an app's frp tunnel
+func (db *DB) DeleteFrpTunnel(appID string) error {
+	_, err := db.Exec(`DELETE FROM frp_tunnels WHERE app_id = ?`, appID)
+	return err
+}
+
+// RecordAppNetworks records networks as belonging to an app; networks already recorded keep the
+// time they were first seen
+func (db *DB) RecordAppNetworks(appName string, networks []string) error {
+	now := time.Now()
+	for _, network := range networks {
+		if _, err := db.Exec(`INSERT OR IGNORE INTO app_networks (app_name, network, created_at) VALUES (?, ?, ?)`, appName, network, now); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetAppNetworks returns the names of the networks recorded for an app
+func (db *DB) GetAppNetworks(appName string) ([]string, error) {
+	rows, err := db.Query(`SELECT network FROM app_networks WHERE app_name = ? ORDER BY network`, appName)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var networks []string
+	for rows.Next() {
+		var network string
+		if err := rows.Scan(&network); err != nil {
+			return nil, err
+		}
+		networks = append(networks, network)
+	}
+	return networks, rows.Err()
+}
+
+// GetAppNetworksWithoutApp returns the recorded networks of apps that no longer exist
+func (db *DB) GetAppNetworksWithoutApp() ([]*AppNetwork, error) {
+	rows, err := db.Query(`SELECT app_name, network, created_at FROM app_networks
+		WHERE lower(app_name) NOT IN (SELECT lower(name) FROM apps)
+		ORDER BY app_name, network`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	networks := []*AppNetwork{}
+	for rows.Next() {
+		n := &AppNetwork{}
+		if err := rows.Scan(&n.AppName, &n.Network, &n.CreatedAt); err != nil {
+			return nil, err
+		}
+		networks = append(networks, n)
+	}
+	return networks, rows.Err()
+}
+
+// SaveConsistencyAudit stores an audit report and deletes all but the keep most recent ones
+func (db *DB) SaveConsistencyAudit(audit *ConsistencyAudit, keep int) error {
+	if _, err := db.Exec(`INSERT INTO consistency_audits (id, node_id, ran_at, findings) VALUES (?, ?, ?, ?)`,
+		audit.ID, audit.NodeID, audit.RanAt, audit.Findings); err != nil {
+		return err
+	}
+	_, err := db.Exec(`DELETE FROM consistency_audits WHERE id NOT IN (
+		SELECT id FROM consistency_audits ORDER BY ran_at DESC LIMIT ?)`, keep)
+	return err
+}
+
+// GetConsistencyAudits returns up to limit audit reports, newest first
+func (db *DB) GetConsistencyAudits(limit int) ([]*ConsistencyAudit, error) {
+	rows, err := db.Query(`SELECT id, node_id, ran_at, findings FROM consistency_audits ORDER BY ran_at DESC LIMIT ?`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	audits := []*ConsistencyAudit{}
+	for rows.Next() {
+		a := &ConsistencyAudit{}
+		if err := rows.Scan(&a.ID, &a.NodeID, &a.RanAt, &a.Findings); err != nil {
+			return nil, err
+		}
+		audits = append(audits, a)
+	}
+	return audits, rows.Err()
+}
+
+// DeleteAppNetworks forgets networks recorded for an app, once they are removed
+func (db *DB) DeleteAppNetworks(appName string, networks []string) error {
+	for _, network := range networks {
+		if _, err := db.Exec(`DELETE FROM app_networks WHERE app_name = ? AND network = ?`, appName, network); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RevokeUserSessions revokes every session of a user, whatever the case of their name, and
+// returns how many were revoked
+func (db *DB) RevokeUserSessions(userName string, revokedAt time.Time) (int64, error) {
+	result, err := db.Exec(`UPDATE sessions SET revoked_at = ? WHERE lower(user_name) = lower(?) AND revoked_at IS NULL`, revokedAt, userName)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// GetRevokedSessionIDs returns every revoked session
+func (db *DB) GetRevokedSessionIDs() ([]string, error) {
+	rows, err := db.Query(`SELECT id FROM sessions WHERE revoked_at IS NOT NULL ORDER BY revoked_at`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ids := []string{}
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// MarkSessionsRevoked records sessions revoked elsewhere, creating the ones this node hasn't seen
+// yet so they are refused when they turn up, and returns how many weren't revoked here before
+func (db *DB) MarkSessionsRevoked(ids []string, revokedAt time.Time) (int64, error) {
+	var marked int64
+	for _, id := range ids {
+		result, err := db.Exec(
+			`INSERT INTO sessions (id, user_name, created_at, last_seen_at, revoked_at) VALUES (?, '', ?, ?, ?)
+			ON CONFLICT(id) DO UPDATE SET revoked_at = excluded.revoked_at WHERE sessions.revoked_at IS NULL`,
+			id, revokedAt, revokedAt, revokedAt,
+		)
+		if err != nil {
+			return marked, err
+		}
+		if affected, err := result.RowsAffected(); err == nil {
+			marked += affected
+		}
+	}
+	return marked, nil
+}
+
+// DeleteSessionsBefore deletes sessions last seen before cutoff, and revoked sessions revoked
+// before revokedCutoff, and returns how many were deleted
+func (db *DB) DeleteSessionsBefore(cutoff, revokedCutoff time.Time) (int64, error) {
+	result, err := db.Exec(
+		`DELETE FROM sessions WHERE (revoked_at IS NULL AND last_seen_at < ?) OR revoked_at < ?`,
+		cutoff, revokedCutoff,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// CreateAppStatusChange records a change of an app's status
+func (db *DB) CreateAppStatusChange(change *AppStatusChange) error {
+	result, err := db.Exec(
+		`INSERT INTO app_status_history (app_id, from_status, to_status, changed_at) VALUES (?, ?, ?, ?)`,
+		change.AppID, change.FromStatus, change.ToStatus, change.ChangedAt,
+	)
+	if err != nil {
+		return err
+	}
+	change.ID, err = result.LastInsertId()
+	return err
+}
+
+// GetAppStatusHistory returns an app's status changes since the given time, oldest first, preceded
+// by the last change before it (if any) so the app's status at that time is known
+func (db *DB) GetAppStatusHistory(appID string, since time.Time) ([]*AppStatusChange, error) {
+	rows, err := db.Query(
+		`SELECT id, app_id, from_status, to_status, changed_at FROM app_status_history
+		WHERE app_id = ? AND id >= COALESCE((SELECT MAX(id) FROM app_status_history WHERE app_id = ? AND changed_at < ?), 0)
+		ORDER BY id`,
+		appID, appID, since,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	changes := []*AppStatusChange{}
+	for rows.Next() {
+		change := &AppStatusChange{}
+		if err := rows.Scan(&change.ID, &change.AppID, &change.FromStatus, &change.ToStatus, &change.ChangedAt); err != nil {
+			return nil, err
+		}
+		changes = append(changes, change)
+	}
+	return changes, rows.Err()
+}
+
+// DeleteAppStatusHistoryBefore deletes status changes older than cutoff, keeping each app's latest
+// one before it, and returns how many were deleted
+func (db *DB) DeleteAppStatusHistoryBefore(cutoff time.Time) (int64, error) {
+	result, err := db.Exec(
+		`DELETE FROM app_status_history WHERE changed_at < ?
+		AND id NOT IN (SELECT MAX(id) FROM app_status_history WHERE changed_at < ? GROUP BY app_id)`,
+		cutoff, cutoff,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// GetAppStatusPage returns an app's status page
+func (db *DB) GetAppStatusPage(appID string) (*AppStatusPage, error) {
+	page := &AppStatusPage{}
+	err := db.QueryRow(`SELECT app_id, token_hash, created_by, created_at FROM app_status_pages WHERE app_id = ?`, appID).
+		Scan(&page.AppID, &page.TokenHash, &page.CreatedBy, &page.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return page, nil
+}
+
+// GetAppStatusPageByTokenHash returns the status page reached with a token
+func (db *DB) GetAppStatusPageByTokenHash(tokenHash string) (*AppStatusPage, error) {
+	page := &AppStatusPage{}
+	err := db.QueryRow(`SELECT app_id, token_hash, created_by, created_at FROM app_status_pages WHERE token_hash = ?`, tokenHash).
+		Scan(&page.AppID, &page.TokenHash, &page.CreatedBy, &page.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return page, nil
+}
+
+// SaveAppStatusPage creates an app's status page, or replaces its token
+func (db *DB) SaveAppStatusPage(page *AppStatusPage) error {
+	_, err := db.Exec(
+		`INSERT INTO app_status_pages (app_id, token_hash, created_by, created_at) VALUES (?, ?, ?, ?)
+		ON CONFLICT(app_id) DO UPDATE SET token_hash = excluded.token_hash, created_by = excluded.created_by, created_at = excluded.created_at`,
+		page.AppID, page.TokenHash, page.CreatedBy, page.CreatedAt,
+	)
+	return err
+}
+
+// DeleteAppStatusPage deletes an app's status page; returns sql.ErrNoRows if it has none
+func (db *DB) DeleteAppStatusPage(appID string) error {
+	result, err := db.Exec(`DELETE FROM app_status_pages WHERE app_id = ?`, appID)
+	if err != nil {
+		return err
+	}
+	if affected, _ := result.RowsAffected(); affected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+// GetAppIcon returns an app's cached icon
+func (db *DB) GetAppIcon(appID string) (*AppIcon, error) {
+	icon := &AppIcon{}
+	err := db.QueryRow(`SELECT app_id, source_url, icon_url, content_type, data, error, fetched_at FROM app_icons WHERE app_id = ?`, appID).
+		Scan(&icon.AppID, &icon.SourceURL, &icon.IconURL, &icon.ContentType, &icon.Data, &icon.Error, &icon.FetchedAt)
+	if err != nil {
+		return nil, err
+	}
+	return icon, nil
+}
+
+// SaveAppIcon caches an app's icon, or the failure to find one
+func (db *DB) SaveAppIcon(icon *AppIcon) error {
+	_, err := db.Exec(
+		`INSERT INTO app_icons (app_id, source_url, icon_url, content_type, data, error, fetched_at) VALUES (?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(app_id) DO UPDATE SET source_url = excluded.source_url, icon_url = excluded.icon_url,
+			content_type = excluded.content_type, data = excluded.data, error = excluded.error, fetched_at = excluded.fetched_at`,
+		icon.AppID, icon.SourceURL, icon.IconURL, icon.ContentType, icon.Data, icon.Error, icon.FetchedAt,
+	)
+	return err
+}
+
+// portReservationColumns lists port_reservations columns in the order scanPortReservation reads them
+const portReservationColumns = `id, node_id, port, protocol, app_id, source, description, created_at`
+
+// scanPortReservation reads a port_reservations row selected with portReservationColumns
+func scanPortReservation(scanner interface{ Scan(dest ...interface{}) error }) (*PortReservation, error) {
+	reservation := &PortReservation{}
+	var appID sql.NullString
+	if err := scanner.Scan(&reservation.ID, &reservation.NodeID, &reservation.Port, &reservation.Protocol, &appID,
+		&reservation.Source, &reservation.Description, &reservation.CreatedAt); err != nil {
+		return nil, err
+	}
+	if appID.Valid {
+		reservation.AppID = &appID.String
+	}
+	return reservation, nil
+}
+
+// GetPortReservations returns a node's port reservations, ordered by port
+func (db *DB) GetPortReservations(nodeID string) ([]*PortReservation, error) {
+	rows, err := db.Query(`SELECT `+portReservationColumns+` FROM port_reservations WHERE node_id = ? ORDER BY port, protocol`, nodeID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	reservations := []*PortReservation{}
+	for rows.Next() {
+		reservation, err := scanPortReservation(rows)
+		if err != nil {
+			return nil, err
+		}
+		reservations = append(reservations, reservation)
+	}
+	return reservations, rows.Err()
+}
+
+// GetPortReservation returns a port reservation by ID
+func (db *DB) GetPortReservation(id string) (*PortReservation, error) {
+	return scanPortReservation(db.QueryRow(`SELECT `+portReservationColumns+` FROM port_reservations WHERE id = ?`, id))
+}
+
+// portHolder returns the reservation holding a port on a node, or nil if it is free
+func portHolder(q interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
+}, nodeID string, port int, protocol string) (*PortReservation, error) {
+	holder, err := scanPortReservation(q.QueryRow(
+		`SELECT `+portReservationColumns+` FROM port_reservations WHERE node_id = ? AND port = ? AND protocol = ?`,
+		nodeID, port, protocol,
+	))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	return holder, err
+}
+
+// ReservePort creates a reservation unless its port is taken, in which case the reservation
+// holding it is returned and nothing is created
+func (db *DB) ReservePort(reservation *PortReservation) (*PortReservation, error) {
+	result, err := db.Exec(
+		`INSERT INTO port_reservations (id, node_id, port, protocol, app_id, source, description, created_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT(node_id, port, protocol) DO NOTHING`,
+		reservation.ID, reservation.NodeID, reservation.Port, reservation.Protocol, reservation.AppID,
+		reservation.Source, reservation.Description, reservation.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if affected, err := result.RowsAffected(); err != nil || affected > 0 {
+		return nil, err
+	}
+	return portHolder(db, reservation.NodeID, reservation.Port, reservation.Protocol)
+}
+
+// DeletePortReservation deletes a port reservation; returns sql.ErrNoRows if there is none
+func (db *DB) DeletePortReservation(id string) error {
+	result, err := db.Exec(`DELETE FROM port_reservations WHERE id = ?`, id)
+	if err != nil {
+		return err
+	}
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		return sql.ErrNoRows
+	}
+	return err
+}
+
+// DeleteOrphanedPortReservations deletes the reservations of apps that no longer exist and returns
+// how many were deleted
+func (db *DB) DeleteOrphanedPortReservations() (int64, error) {
+	result, err := db.Exec(`DELETE FROM port_reservations WHERE app_id IS NOT NULL AND app_id NOT IN (SELECT id FROM apps)`)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+// FindPortConflict returns the first reservation holding one of the given ports for something
+// other than the app, or nil if the app may have them all
+func (db *DB) FindPortConflict(nodeID, appID string, reservations []*PortReservation) (*PortReservation, error) {
+	for _, reservation := range reservations {
+		holder, err := portHolder(db, nodeID, reservation.Port, reservation.Protocol)
+		if err != nil {
+			return nil, err
+		}
+		if holder != nil && (holder.AppID == nil || *holder.AppID != appID) {
+			return holder, nil
+		}
+	}
+	return nil, nil
+}
+
+// SetAppPortReservations replaces an app's compose and Quick Tunnel reservations on a node. Ports
+// the app holds by a manual reservation are kept as they are. When another app or a manual
+// reservation holds one of the ports, nothing is changed and that reservation is returned.
+func (db *DB) SetAppPortReservations(nodeID, appID string, reservations []*PortReservation) (*PortReservation, error) {
+	tx, err := db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(
+		`DELETE FROM port_reservations WHERE node_id = ? AND app_id = ? AND source != ?`,
+		nodeID, appID, constants.PortReservationSourceManual,
+	); err != nil {
+		return nil, err
+	}
+	for _, reservation := range reservations {
+		holder, err := portHolder(tx, nodeID, reservation.Port, reservation.Protocol)
+		if err != nil {
+			return nil, err
+		}
+		if holder != nil {
+			if holder.AppID != nil && *holder.AppID == appID {
+				continue
+			}
+			return holder, nil
+		}
+		if _, err := tx.Exec(
+			`INSERT INTO port_reservations (id, node_id, port, protocol, app_id, source, description, created_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+			reservation.ID, nodeID, reservation.Port, reservation.Protocol, appID,
+			reservation.Source, reservation.Description, reservation.CreatedAt,
+		); err != nil {
+			return nil, err
+		}
+	}
+	return nil, tx.Commit()
+}
@@ -0,0 +1,45 @@
+package db
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/selfhostly/internal/constants"
+)
+
+func TestClaimPendingJob_Priority(t *testing.T) {
+	database, err := Init(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Init: %v", err)
+	}
+	defer database.Close()
+
+	// A backlog of scheduled work, then a start a user clicked and an update queued after it
+	queued := time.Now().Add(-time.Hour)
+	var jobs []*Job
+	for _, jobType := range []string{
+		constants.JobTypeAppScheduledStop, constants.JobTypeAppScheduledStop,
+		constants.JobTypeAppStart, constants.JobTypeAppUpdate,
+	} {
+		job := NewJob(jobType, "app-1", nil)
+		job.CreatedAt = queued
+		queued = queued.Add(time.Minute)
+		if err := database.CreateJob(job); err != nil {
+			t.Fatalf("CreateJob: %v", err)
+		}
+		jobs = append(jobs, job)
+	}
+
+	want := []*Job{jobs[2], jobs[3], jobs[0], jobs[1]}
+	for i, expected := range want {
+		claimed, err := database.ClaimPendingJob("worker")
+		if err != nil || claimed == nil {
+			t.Fatalf("ClaimPendingJob: %v, %v", claimed, err)
+		}
+		if claimed.ID != expected.ID || claimed.Priority != expected.Priority {
+			t.Errorf("claim %d: expected %s job (priority %d), got %s (priority %d)",
+				i, expected.Type, expected.Priority, claimed.Type, claimed.Priority)
+		}
+	}
+}
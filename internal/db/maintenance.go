@@ -0,0 +1,210 @@
+package db
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/selfhostly/internal/constants"
+)
+
+// Stats describes the database file: how big it is and how much of it is free pages left behind
+// by deleted rows
+type Stats struct {
+	SizeBytes     int64   `json:"size_bytes"`
+	WALSizeBytes  int64   `json:"wal_size_bytes"`
+	PageSize      int64   `json:"page_size"`
+	PageCount     int64   `json:"page_count"`
+	FreePages     int64   `json:"free_pages"`
+	Fragmentation float64 `json:"fragmentation"` // FreePages / PageCount
+	AutoVacuum    string  `json:"auto_vacuum"`   // none, full or incremental
+
+	LastMaintenance *MaintenanceRun `json:"last_maintenance,omitempty"`
+
+	// Queries that took the most time since the server started
+	Queries []*QueryStat `json:"queries"`
+}
+
+// MaintenanceRun records what a maintenance pass did
+type MaintenanceRun struct {
+	StartedAt   time.Time `json:"started_at"`
+	DurationMS  int64     `json:"duration_ms"`
+	FreedPages  int64     `json:"freed_pages"`
+	Rebuilt     bool      `json:"rebuilt"` // the file was rewritten with VACUUM to turn on incremental vacuum
+	Analyzed    bool      `json:"analyzed"`
+	SkippedBusy int       `json:"skipped_busy"` // times the pass was put off because the node was busy
+	Error       string    `json:"error,omitempty"`
+}
+
+var autoVacuumModes = map[int64]string{0: "none", 1: "full", 2: "incremental"}
+
+// Stats reports the size and fragmentation of the database
+func (db *DB) Stats() (*Stats, error) {
+	stats := &Stats{}
+	for _, p := range []struct {
+		pragma string
+		dest   *int64
+	}{
+		{"page_size", &stats.PageSize},
+		{"page_count", &stats.PageCount},
+		{"freelist_count", &stats.FreePages},
+	} {
+		if err := db.QueryRow("PRAGMA " + p.pragma).Scan(p.dest); err != nil {
+			return nil, fmt.Errorf("PRAGMA %s: %w", p.pragma, err)
+		}
+	}
+	var autoVacuum int64
+	if err := db.QueryRow("PRAGMA auto_vacuum").Scan(&autoVacuum); err != nil {
+		return nil, fmt.Errorf("PRAGMA auto_vacuum: %w", err)
+	}
+	stats.AutoVacuum = autoVacuumModes[autoVacuum]
+	if stats.PageCount > 0 {
+		stats.Fragmentation = float64(stats.FreePages) / float64(stats.PageCount)
+	}
+
+	if info, err := os.Stat(db.dbPath); err == nil {
+		stats.SizeBytes = info.Size()
+	}
+	if info, err := os.Stat(db.dbPath + "-wal"); err == nil {
+		stats.WALSizeBytes = info.Size()
+	}
+	stats.Queries = db.QueryStats(constants.DBQueryStatsLimit)
+	return stats, nil
+}
+
+// Maintain compacts the database and refreshes the query planner's statistics. Free pages are
+// returned to the filesystem with incremental_vacuum; a database created before incremental
+// vacuum was turned on is rebuilt once with VACUUM when enough of it is free. The WAL is then
+// checkpointed and truncated.
+func (db *DB) Maintain(ctx context.Context) (*MaintenanceRun, error) {
+	run := &MaintenanceRun{StartedAt: time.Now()}
+	defer func() { run.DurationMS = time.Since(run.StartedAt).Milliseconds() }()
+
+	before, err := db.Stats()
+	if err != nil {
+		return run, err
+	}
+
+	// PRAGMA auto_vacuum only applies to the connection's next VACUUM, so both run on one connection
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return run, err
+	}
+	defer conn.Close()
+
+	switch {
+	case before.AutoVacuum == "incremental" && before.FreePages > 0:
+		// Each step of the statement frees one page, so its rows must be read to the end
+		rows, err := conn.QueryContext(ctx, "PRAGMA incremental_vacuum")
+		if err != nil {
+			return run, fmt.Errorf("incremental vacuum: %w", err)
+		}
+		for rows.Next() {
+		}
+		err = rows.Err()
+		rows.Close()
+		if err != nil {
+			return run, fmt.Errorf("incremental vacuum: %w", err)
+		}
+	case before.AutoVacuum == "none" && before.Fragmentation >= constants.DBRebuildFragmentation:
+		if _, err := conn.ExecContext(ctx, "PRAGMA auto_vacuum=INCREMENTAL"); err != nil {
+			return run, err
+		}
+		if _, err := conn.ExecContext(ctx, "VACUUM"); err != nil {
+			return run, fmt.Errorf("vacuum: %w", err)
+		}
+		run.Rebuilt = true
+	}
+
+	if _, err := conn.ExecContext(ctx, "ANALYZE"); err != nil {
+		return run, fmt.Errorf("analyze: %w", err)
+	}
+	run.Analyzed = true
+
+	if _, err := conn.ExecContext(ctx, "PRAGMA wal_checkpoint(TRUNCATE)"); err != nil {
+		return run, fmt.Errorf("wal checkpoint: %w", err)
+	}
+
+	after, err := db.Stats()
+	if err != nil {
+		return run, err
+	}
+	run.FreedPages = max(before.FreePages-after.FreePages, 0)
+	return run, nil
+}
+
+// Maintainer runs Maintain in the background once a day, at a moment the node is quiet
+type Maintainer struct {
+	db     *DB
+	busy   func() bool
+	logger *slog.Logger
+
+	mu      sync.Mutex
+	last    *MaintenanceRun
+	skipped int
+}
+
+// NewMaintainer creates a Maintainer. busy reports whether the node is doing work that a
+// maintenance pass, which briefly locks the database, would get in the way of.
+func NewMaintainer(db *DB, busy func() bool, logger *slog.Logger) *Maintainer {
+	return &Maintainer{db: db, busy: busy, logger: logger}
+}
+
+// Start checks hourly whether a pass is due and the node is quiet, until ctx is done
+func (m *Maintainer) Start(ctx context.Context) {
+	ticker := time.NewTicker(constants.DBMaintenanceCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			m.runIfDue(ctx, time.Now())
+		}
+	}
+}
+
+// LastRun returns the most recent maintenance pass, or nil before the first one
+func (m *Maintainer) LastRun() *MaintenanceRun {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.last
+}
+
+func (m *Maintainer) runIfDue(ctx context.Context, now time.Time) {
+	m.mu.Lock()
+	due := m.last == nil || now.Sub(m.last.StartedAt) >= constants.DBMaintenanceInterval
+	m.mu.Unlock()
+	if !due {
+		return
+	}
+	if m.busy != nil && m.busy() {
+		m.mu.Lock()
+		m.skipped++
+		m.mu.Unlock()
+		m.logger.Debug("database maintenance put off, node is busy")
+		return
+	}
+
+	run, err := m.db.Maintain(ctx)
+	if err != nil {
+		run.Error = err.Error()
+		m.logger.Warn("database maintenance failed", "error", err)
+	} else {
+		m.logger.Info("database maintenance completed",
+			"freed_pages", run.FreedPages,
+			"rebuilt", run.Rebuilt,
+			"duration_ms", run.DurationMS,
+		)
+	}
+
+	m.mu.Lock()
+	run.SkippedBusy = m.skipped
+	m.skipped = 0
+	m.last = run
+	m.mu.Unlock()
+}
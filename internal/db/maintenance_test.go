@@ -0,0 +1,125 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fragment fills a table and deletes the rows again, leaving free pages behind
+func fragment(t *testing.T, database *DB) {
+	t.Helper()
+	if _, err := database.Exec(`CREATE TABLE filler (data TEXT)`); err != nil {
+		t.Fatal(err)
+	}
+	payload := strings.Repeat("x", 4000)
+	for i := 0; i < 200; i++ {
+		if _, err := database.Exec(`INSERT INTO filler (data) VALUES (?)`, payload); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := database.Exec(`DELETE FROM filler`); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMaintain_IncrementalVacuum(t *testing.T) {
+	database, err := Init(filepath.Join(t.TempDir(), "selfhostly.db"))
+	if err != nil {
+		t.Fatalf("Init: %v", err)
+	}
+	defer database.Close()
+	fragment(t, database)
+
+	before, err := database.Stats()
+	if err != nil {
+		t.Fatalf("Stats: %v", err)
+	}
+	if before.AutoVacuum != "incremental" || before.FreePages == 0 || before.Fragmentation <= 0 {
+		t.Fatalf("expected a fragmented database with incremental vacuum, got %+v", before)
+	}
+
+	run, err := database.Maintain(context.Background())
+	if err != nil {
+		t.Fatalf("Maintain: %v", err)
+	}
+	if run.FreedPages < before.FreePages || run.Rebuilt || !run.Analyzed {
+		t.Errorf("unexpected run %+v (had %d free pages)", run, before.FreePages)
+	}
+	after, _ := database.Stats()
+	if after.FreePages != 0 || after.WALSizeBytes != 0 {
+		t.Errorf("expected no free pages and an empty WAL, got %+v", after)
+	}
+}
+
+func TestMaintain_RebuildsLegacyDatabase(t *testing.T) {
+	// A database created before incremental vacuum was turned on
+	dbPath := filepath.Join(t.TempDir(), "legacy.db")
+	legacy, err := sql.Open("sqlite", dbPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := legacy.Exec(`CREATE TABLE legacy (id INTEGER)`); err != nil {
+		t.Fatal(err)
+	}
+	legacy.Close()
+
+	database, err := Init(dbPath)
+	if err != nil {
+		t.Fatalf("Init: %v", err)
+	}
+	defer database.Close()
+	fragment(t, database)
+	if stats, _ := database.Stats(); stats.AutoVacuum != "none" {
+		t.Fatalf("expected a legacy database without auto vacuum, got %+v", stats)
+	}
+
+	run, err := database.Maintain(context.Background())
+	if err != nil {
+		t.Fatalf("Maintain: %v", err)
+	}
+	if !run.Rebuilt || run.FreedPages == 0 {
+		t.Errorf("expected the database to be rebuilt, got %+v", run)
+	}
+	if stats, _ := database.Stats(); stats.AutoVacuum != "incremental" || stats.FreePages != 0 {
+		t.Errorf("expected incremental vacuum after the rebuild, got %+v", stats)
+	}
+}
+
+func TestMaintainer_WaitsForQuietNode(t *testing.T) {
+	database, err := Init(filepath.Join(t.TempDir(), "selfhostly.db"))
+	if err != nil {
+		t.Fatalf("Init: %v", err)
+	}
+	defer database.Close()
+
+	busy := true
+	m := NewMaintainer(database, func() bool { return busy }, slog.Default())
+	now := time.Now()
+
+	m.runIfDue(context.Background(), now)
+	if m.LastRun() != nil {
+		t.Fatal("expected no maintenance while busy")
+	}
+
+	busy = false
+	m.runIfDue(context.Background(), now)
+	first := m.LastRun()
+	if first == nil || first.Error != "" || first.SkippedBusy != 1 {
+		t.Fatalf("expected a run after one skip, got %+v", first)
+	}
+
+	// Not due again until a day later
+	m.runIfDue(context.Background(), now.Add(time.Hour))
+	if m.LastRun() != first {
+		t.Error("expected no second run within the maintenance interval")
+	}
+	m.runIfDue(context.Background(), first.StartedAt.Add(25*time.Hour))
+	if m.LastRun() == first {
+		t.Error("expected a run once the interval passed")
+	}
+}
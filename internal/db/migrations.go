@@ -88,7 +88,7 @@ func bootstrapSingleNode(db *sql.DB, cfg *config.Config) error {
 	// Check for split-brain: another primary node exists
 	if cfg.Node.PrimaryNodeURL != "" {
 		// If PRIMARY_NODE_URL is set, check if that primary exists
-		err := checkPrimaryNodeExists(cfg.Node.PrimaryNodeURL, cfg.Node.APIKey)
+		err := checkPrimaryNodeExists(cfg.Node.PrimaryNodeURL)
 		if err == nil {
 			// Another primary exists and is reachable
 			slog.Warn("Another primary node already exists!", "primary_url", cfg.Node.PrimaryNodeURL)
@@ -191,19 +191,15 @@ func assignUnassignedAppsToNode(db *sql.DB, nodeID string) error {
 	return nil
 }
 
-// checkPrimaryNodeExists checks if a primary node is reachable at the given URL
-func checkPrimaryNodeExists(primaryURL, apiKey string) error {
+// checkPrimaryNodeExists checks if a primary node is reachable at the given URL. The health
+// check needs no credentials, so the API key isn't sent.
+func checkPrimaryNodeExists(primaryURL string) error {
 	client := &http.Client{Timeout: constants.HTTPClientTimeout}
 	req, err := http.NewRequest("GET", primaryURL+"/api/health", nil)
 	if err != nil {
 		return err
 	}
 
-	// Add node auth header if we have an API key
-	if apiKey != "" {
-		req.Header.Set("X-Node-API-Key", apiKey)
-	}
-
 	resp, err := client.Do(req)
 	if err != nil {
 		return err
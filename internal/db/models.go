@@ -1,6 +1,8 @@
 package db
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"time"
 
 	"github.com/google/uuid"
@@ -23,6 +25,9 @@ type Node struct {
 	LastSeen           *time.Time `json:"last_seen" db:"last_seen"`
 	ConsecutiveFailures int       `json:"consecutive_failures" db:"consecutive_failures"` // Track health check failures
 	LastHealthCheck    *time.Time `json:"last_health_check" db:"last_health_check"`      // When we last checked this node
+	Version            string     `json:"version" db:"version"`                          // Build version the node last reported
+	APIVersion         int        `json:"api_version" db:"api_version"`                  // API schema version the node last reported (0 = never reported)
+	ClockSkewMS        *int64     `json:"clock_skew_ms" db:"clock_skew_ms"`              // Node clock minus the primary's at the last heartbeat (nil = never measured)
 	CreatedAt          time.Time  `json:"created_at" db:"created_at"`
 	UpdatedAt          time.Time  `json:"updated_at" db:"updated_at"`
 }
@@ -33,6 +38,8 @@ type App struct {
 	Name           string        `json:"name" db:"name"`
 	Description    string        `json:"description" db:"description"`
 	ComposeContent string        `json:"compose_content" db:"compose_content"`
+	ComposeOverride string       `json:"compose_override,omitempty" db:"compose_override"` // Optional docker-compose.override.yml content
+	TunnelCompose  string        `json:"tunnel_compose,omitempty" db:"tunnel_compose"`     // Generated tunnel sidecar (docker-compose.tunnel.yml), never user-edited
 	TunnelToken    string        `json:"tunnel_token" db:"tunnel_token"`
 	TunnelID       string        `json:"tunnel_id" db:"tunnel_id"`
 	TunnelDomain   string        `json:"tunnel_domain" db:"tunnel_domain"`
@@ -41,9 +48,20 @@ type App struct {
 	ErrorMessage   *string       `json:"error_message" db:"error_message"` // Make nullable to handle NULL values
 	NodeID         string        `json:"node_id" db:"node_id"`             // Which node this app is deployed on
 	TunnelMode     string        `json:"tunnel_mode" db:"tunnel_mode"`     // "custom" | "quick" | "" (empty = no tunnel)
+	Labels         map[string]string `json:"labels,omitempty" db:"labels"` // Free-form key/value tags matched by label selectors
+	ComposeFiles   map[string]string `json:"compose_files,omitempty" db:"compose_files"` // Extra compose files pulled in with include: or extends:, by path relative to the app directory
+	EnvTemplate    string        `json:"env_template,omitempty" db:"env_template"` // Managed .env template; {{ }} expressions are rendered at deploy time
+	EnvContent     string        `json:"-" db:"env_content"`                       // The rendered .env written to the app directory; holds generated secrets, so never sent
+	StorageRoot    string        `json:"storage_root,omitempty" db:"storage_root"` // Named storage root holding the app directory; empty is APPS_DIR
+	Owner          string        `json:"owner,omitempty" db:"owner"`               // Signed-in user who created the app; quotas count it against them
 	CreatedAt      time.Time     `json:"created_at" db:"created_at"`
 	UpdatedAt      time.Time     `json:"updated_at" db:"updated_at"`
 	Schedule       *AppSchedule  `json:"schedule,omitempty" db:"-"`         // Optional schedule (not stored in apps table)
+
+	// Stale marks an app served from the primary's inventory cache because its node couldn't be
+	// reached; SyncedAt is when that node last reported it
+	Stale    bool       `json:"stale,omitempty" db:"-"`
+	SyncedAt *time.Time `json:"synced_at,omitempty" db:"-"`
 }
 
 // CloudflareTunnel represents Cloudflare tunnel configuration and metadata
@@ -65,6 +83,59 @@ type CloudflareTunnel struct {
 	PublicURL string `json:"public_url,omitempty" db:"public_url"`
 	// NodeID is response-only (derived from app when listing): which node the tunnel's app is on
 	NodeID string `json:"node_id,omitempty" db:"-"`
+	// Options are connector flags rendered into the tunnel container's command (nil = cloudflared defaults)
+	Options *TunnelOptions `json:"options,omitempty" db:"options"`
+}
+
+// TunnelOptions holds per-tunnel cloudflared flags, e.g. forcing HTTP/2 on networks that block QUIC.
+// Empty fields leave the flag unset so cloudflared picks its own default.
+type TunnelOptions struct {
+	Protocol      string `json:"protocol,omitempty"`        // auto, quic or http2
+	EdgeIPVersion string `json:"edge_ip_version,omitempty"` // auto, 4 or 6
+	PostQuantum   bool   `json:"post_quantum,omitempty"`
+	LogLevel      string `json:"log_level,omitempty"` // debug, info, warn, error or fatal
+}
+
+// NgrokTunnel is an app's ngrok endpoint. ngrok has nothing to create ahead: the agent sidecar
+// brings the endpoint up when it starts, so this records what it is started with.
+type NgrokTunnel struct {
+	ID         string    `json:"id" db:"id"`
+	AppID      string    `json:"app_id" db:"app_id"`
+	TunnelName string    `json:"tunnel_name" db:"tunnel_name"`
+	Upstream   string    `json:"upstream" db:"upstream"`     // Compose service URL the agent forwards to, e.g. http://web:80
+	PublicURL  string    `json:"public_url" db:"public_url"` // Empty when ngrok assigns a random URL each time the agent starts
+	Status     string    `json:"status" db:"status"`
+	CreatedAt  time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// FrpTunnel is an app's tunnel through the user's own frp server. The frpc sidecar registers a
+// proxy on the server for each ingress rule when it starts, so this records the rules.
+type FrpTunnel struct {
+	ID           string        `json:"id" db:"id"`
+	AppID        string        `json:"app_id" db:"app_id"`
+	TunnelName   string        `json:"tunnel_name" db:"tunnel_name"`
+	IngressRules []IngressRule `json:"ingress_rules" db:"ingress_rules"` // Stored as JSON
+	PublicURL    string        `json:"public_url" db:"public_url"`       // The first rule's hostname
+	Status       string        `json:"status" db:"status"`
+	CreatedAt    time.Time     `json:"created_at" db:"created_at"`
+	UpdatedAt    time.Time     `json:"updated_at" db:"updated_at"`
+}
+
+// AppNetwork is a Docker network an app's compose project was seen with. It is kept after the
+// app is deleted while the network is still in use, so the network can be removed later.
+type AppNetwork struct {
+	AppName   string    `json:"app_name" db:"app_name"`
+	Network   string    `json:"network" db:"network"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"` // When the network was first seen
+}
+
+// ConsistencyAudit is a stored report of the consistency audit of this node's database
+type ConsistencyAudit struct {
+	ID       string    `json:"id" db:"id"`
+	NodeID   string    `json:"node_id" db:"node_id"`
+	RanAt    time.Time `json:"ran_at" db:"ran_at"`
+	Findings string    `json:"findings" db:"findings"` // JSON array of the findings
 }
 
 // IngressRule represents a single ingress rule for a Cloudflare tunnel
@@ -75,14 +146,41 @@ type IngressRule struct {
 	OriginRequest map[string]interface{} `json:"originRequest" db:"originRequest"`
 }
 
-// User represents a user for authentication
+// User represents a user for authentication. Invited GitHub users get one when they accept their
+// invitation; users in GITHUB_ALLOWED_USERS have none.
 type User struct {
 	ID        string    `json:"id" db:"id"`
-	Username  string    `json:"username" db:"username"`
-	Password  string    `json:"-" db:"password"` // Never expose password in JSON
+	Username  string    `json:"username" db:"username"` // GitHub username, lower case
+	Password  string    `json:"-" db:"password"`        // Never expose password in JSON
+	Role      string    `json:"role" db:"role"`
+	Email     string    `json:"email,omitempty" db:"email"` // Address the invitation was sent to
+	InvitedBy string    `json:"invited_by,omitempty" db:"invited_by"`
 	CreatedAt time.Time `json:"created_at" db:"created_at"`
 }
 
+// NewInvitedUser creates the User of a GitHub user accepting an invitation, with a generated UUID
+func NewInvitedUser(username string, invitation *Invitation) *User {
+	return &User{
+		ID:        uuid.New().String(),
+		Username:  username,
+		Role:      invitation.Role,
+		Email:     invitation.Email,
+		InvitedBy: invitation.CreatedBy,
+		CreatedAt: time.Now(),
+	}
+}
+
+// NewSyncedUser creates the User a node other than the primary keeps for one of the primary's
+// users, with a generated UUID
+func NewSyncedUser(username, role string) *User {
+	return &User{
+		ID:        uuid.New().String(),
+		Username:  username,
+		Role:      role,
+		CreatedAt: time.Now(),
+	}
+}
+
 // Settings holds application settings
 type Settings struct {
 	ID                  string    `json:"id" db:"id"`
@@ -103,6 +201,52 @@ type Settings struct {
 	
 	AutoStartApps        bool      `json:"auto_start_apps" db:"auto_start_apps"`
 	UpdatedAt            time.Time `json:"updated_at" db:"updated_at"`
+
+	// TelemetryEnabled opts in to anonymous usage reports; TelemetryID identifies this install in
+	// them and is generated on the first report
+	TelemetryEnabled bool   `json:"telemetry_enabled" db:"telemetry_enabled"`
+	TelemetryID      string `json:"-" db:"telemetry_id"`
+
+	// FeatureFlags stores experimental feature toggles as JSON: {"gitops": true}
+	FeatureFlags *string `json:"feature_flags,omitempty" db:"feature_flags"`
+
+	// JobHistoryKeepCount is how many finished jobs are kept per app; JobStaleThresholdMinutes is
+	// how long a job may run before it is failed as stale
+	JobHistoryKeepCount      int `json:"job_history_keep_count" db:"job_history_keep_count"`
+	JobStaleThresholdMinutes int `json:"job_stale_threshold_minutes" db:"job_stale_threshold_minutes"`
+
+	// Node alert thresholds, 0 disabling one, and the webhook alerts are posted to when set
+	AlertDiskPercent        int    `json:"alert_disk_percent" db:"alert_disk_percent"`
+	AlertMemoryPercent      int    `json:"alert_memory_percent" db:"alert_memory_percent"`
+	AlertLoadPercent        int    `json:"alert_load_percent" db:"alert_load_percent"`
+	AlertTemperatureCelsius int    `json:"alert_temperature_celsius" db:"alert_temperature_celsius"`
+	AlertSustainedMinutes   int    `json:"alert_sustained_minutes" db:"alert_sustained_minutes"`
+	AlertWebhookURL         string `json:"alert_webhook_url" db:"alert_webhook_url"`
+	AlertWebhookSecret      string `json:"-" db:"alert_webhook_secret"`
+
+	// Where apps with log forwarding on ship their container logs. Unlike the alert settings these
+	// are sent to secondaries with the rest of the settings, since each node runs its own sidecars.
+	LogForwardingDestination string `json:"log_forwarding_destination" db:"log_forwarding_destination"` // loki, elasticsearch or "" (off)
+	LogForwardingURL         string `json:"log_forwarding_url" db:"log_forwarding_url"`
+	LogForwardingUsername    string `json:"log_forwarding_username" db:"log_forwarding_username"`
+	LogForwardingPassword    string `json:"log_forwarding_password" db:"log_forwarding_password"`
+	LogForwardingIndex       string `json:"log_forwarding_index" db:"log_forwarding_index"` // Elasticsearch index; strftime specifiers allowed
+
+	// Compose policies: block, approval or allow for privileged settings in user compose files.
+	// Sent to secondaries, which validate the compose files of their own apps.
+	ComposePolicyPrivileged  string `json:"compose_policy_privileged" db:"compose_policy_privileged"`
+	ComposePolicyHostNetwork string `json:"compose_policy_host_network" db:"compose_policy_host_network"`
+	ComposePolicyCapAdd      string `json:"compose_policy_cap_add" db:"compose_policy_cap_add"`
+	ComposePolicyDevices     string `json:"compose_policy_devices" db:"compose_policy_devices"`
+
+	// SMTP server email is sent through. Like the alert settings these stay on the primary, which
+	// sends all email.
+	SMTPHost     string `json:"smtp_host" db:"smtp_host"`
+	SMTPPort     int    `json:"smtp_port" db:"smtp_port"`
+	SMTPSecurity string `json:"smtp_security" db:"smtp_security"` // starttls, tls or none
+	SMTPUsername string `json:"smtp_username" db:"smtp_username"`
+	SMTPPassword string `json:"-" db:"smtp_password"`
+	SMTPFrom     string `json:"smtp_from" db:"smtp_from"`
 }
 
 // NewNode creates a new Node with a generated UUID (or uses provided ID if not empty)
@@ -150,6 +294,15 @@ func NewApp(name, description, composeContent string) *App {
 	}
 }
 
+// TunnelComposeSource returns the compose content that defines the app's tunnel sidecar.
+// Apps created before the sidecar moved to its own file still carry it inside ComposeContent.
+func (a *App) TunnelComposeSource() string {
+	if a.TunnelCompose != "" {
+		return a.TunnelCompose
+	}
+	return a.ComposeContent
+}
+
 // NewCloudflareTunnel creates a new CloudflareTunnel with a generated UUID.
 // publicURL is the tunnel's public-facing URL (source of truth on the tunnel).
 func NewCloudflareTunnel(appID, tunnelID, tunnelName, tunnelToken, accountID, publicURL string) *CloudflareTunnel {
@@ -168,6 +321,36 @@ func NewCloudflareTunnel(appID, tunnelID, tunnelName, tunnelToken, accountID, pu
 	}
 }
 
+// NewNgrokTunnel creates an active NgrokTunnel with a generated UUID
+func NewNgrokTunnel(appID, tunnelName, upstream, publicURL string) *NgrokTunnel {
+	now := time.Now()
+	return &NgrokTunnel{
+		ID:         uuid.New().String(),
+		AppID:      appID,
+		TunnelName: tunnelName,
+		Upstream:   upstream,
+		PublicURL:  publicURL,
+		Status:     constants.TunnelStatusActive,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}
+}
+
+// NewFrpTunnel creates an active FrpTunnel with a generated UUID
+func NewFrpTunnel(appID, tunnelName string, rules []IngressRule, publicURL string) *FrpTunnel {
+	now := time.Now()
+	return &FrpTunnel{
+		ID:           uuid.New().String(),
+		AppID:        appID,
+		TunnelName:   tunnelName,
+		IngressRules: rules,
+		PublicURL:    publicURL,
+		Status:       constants.TunnelStatusActive,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	}
+}
+
 // NewUser creates a new User with a generated UUID
 func NewUser(username, password string) *User {
 	return &User{
@@ -181,9 +364,22 @@ func NewUser(username, password string) *User {
 // NewSettings creates a new Settings with a generated UUID
 func NewSettings() *Settings {
 	return &Settings{
-		ID:            uuid.New().String(),
-		AutoStartApps: false,
-		UpdatedAt:     time.Now(),
+		ID:                       uuid.New().String(),
+		AutoStartApps:            false,
+		JobHistoryKeepCount:      constants.JobHistoryKeepCount,
+		JobStaleThresholdMinutes: int(constants.JobStaleThreshold / time.Minute),
+		AlertDiskPercent:         constants.DefaultAlertDiskPercent,
+		AlertMemoryPercent:       constants.DefaultAlertMemoryPercent,
+		AlertLoadPercent:         constants.DefaultAlertLoadPercent,
+		AlertTemperatureCelsius:  constants.DefaultAlertTemperatureCelsius,
+		AlertSustainedMinutes:    constants.DefaultAlertSustainedMinutes,
+		ComposePolicyPrivileged:  constants.ComposePolicyBlock,
+		ComposePolicyHostNetwork: constants.ComposePolicyBlock,
+		ComposePolicyCapAdd:      constants.ComposePolicyBlock,
+		ComposePolicyDevices:     constants.ComposePolicyBlock,
+		SMTPPort:                 constants.DefaultSMTPPort,
+		SMTPSecurity:             constants.SMTPSecurityStartTLS,
+		UpdatedAt:                time.Now(),
 	}
 }
 
@@ -193,11 +389,15 @@ type ComposeVersion struct {
 	AppID          string     `json:"app_id" db:"app_id"`
 	Version        int        `json:"version" db:"version"`                 // Sequential version number
 	ComposeContent string     `json:"compose_content" db:"compose_content"` // The actual compose file content
+	ComposeOverride string    `json:"compose_override,omitempty" db:"compose_override"` // Override file content at this version
+	ComposeFiles   map[string]string `json:"compose_files,omitempty" db:"compose_files"` // Extra compose files at this version
+	ImageDigests   map[string]string `json:"image_digests,omitempty" db:"image_digests"` // Image reference -> resolved digest, recorded at deploy time when pinning is enabled
 	ChangeReason   *string    `json:"change_reason" db:"change_reason"`     // Optional reason for the change
 	ChangedBy      *string    `json:"changed_by" db:"changed_by"`           // Optional user who made the change
 	IsCurrent      bool       `json:"is_current" db:"is_current"`           // Whether this is the active version
 	CreatedAt      time.Time  `json:"created_at" db:"created_at"`
 	RolledBackFrom *int       `json:"rolled_back_from" db:"rolled_back_from"` // Version number this was rolled back from (if applicable)
+	Checksum       string     `json:"checksum" db:"checksum"`                 // SHA-256 of ComposeContent, the docker-compose.yml this version writes
 }
 
 // AppSchedule represents a scheduling configuration for an app
@@ -245,6 +445,124 @@ type Job struct {
 	
 	// Deduplication hash
 	JobHash *string `json:"job_hash,omitempty" db:"job_hash"`
+
+	// Claim order among pending jobs: higher first, see constants.JobPriorityInteractive
+	Priority int `json:"priority" db:"priority"`
+
+	// The node running the job, set when jobs of several nodes are listed together
+	NodeID   string `json:"node_id,omitempty" db:"-"`
+	NodeName string `json:"node_name,omitempty" db:"-"`
+}
+
+// AppLock is a lease that serializes conflicting operations (start, stop, update, rollback, tunnel changes) on an app
+type AppLock struct {
+	AppID      string    `json:"app_id" db:"app_id"`
+	Holder     string    `json:"holder" db:"holder"`       // Job ID, or a per-request ID for synchronous operations
+	Operation  string    `json:"operation" db:"operation"` // What the holder is doing, surfaced in conflict errors
+	AcquiredAt time.Time `json:"acquired_at" db:"acquired_at"`
+	ExpiresAt  time.Time `json:"expires_at" db:"expires_at"` // Leases expire so a crashed holder can't block the app forever
+}
+
+// QueuedOperation is a mutating API request held on the primary while its target node is offline
+type QueuedOperation struct {
+	ID             string    `json:"id" db:"id"`
+	NodeID         string    `json:"node_id" db:"node_id"`
+	Method         string    `json:"method" db:"method"`
+	Path           string    `json:"path" db:"path"`
+	RawQuery       string    `json:"raw_query,omitempty" db:"raw_query"`
+	Body           string    `json:"body,omitempty" db:"body"`
+	UserName       string    `json:"user_name,omitempty" db:"user_name"` // Signed-in user who queued it; replayed on their behalf
+	UserRole       string    `json:"-" db:"user_role"`                   // Their role when they queued it
+	Status         string    `json:"status" db:"status"`                             // pending, completed, failed
+	ResponseStatus int       `json:"response_status,omitempty" db:"response_status"` // HTTP status the node answered with on replay
+	ErrorMessage   *string   `json:"error_message,omitempty" db:"error_message"`
+	CreatedAt      time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// CachedApp is the primary's copy of an app running on another node, kept current by that
+// node's periodic inventory push
+type CachedApp struct {
+	NodeID    string    `json:"node_id" db:"node_id"`
+	AppID     string    `json:"app_id" db:"app_id"`
+	Name      string    `json:"name" db:"name"`
+	Status    string    `json:"status" db:"status"`
+	PublicURL string    `json:"public_url" db:"public_url"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"` // The app's own updated_at on its node
+	SyncedAt  time.Time `json:"synced_at" db:"synced_at"`   // Last time the node confirmed this entry
+}
+
+// AppWebhook is a URL notified with a signed JSON payload when one of its app's lifecycle events occurs
+type AppWebhook struct {
+	ID              string     `json:"id" db:"id"`
+	AppID           string     `json:"app_id" db:"app_id"`
+	URL             string     `json:"url" db:"url"`
+	Secret          string     `json:"secret,omitempty" db:"secret"` // HMAC signing key; only returned when the webhook is created
+	Events          []string   `json:"events" db:"events"`           // Subscribed events: start, stop, update, crash, task_failed
+	Enabled         bool       `json:"enabled" db:"enabled"`
+	Digest          string     `json:"digest" db:"digest"`                       // hourly or daily to batch non-critical events into one delivery; empty sends them as they happen
+	QuietHoursStart string     `json:"quiet_hours_start" db:"quiet_hours_start"` // HH:MM; non-critical events are held from start until end, empty for none
+	QuietHoursEnd   string     `json:"quiet_hours_end" db:"quiet_hours_end"`
+	Timezone        string     `json:"timezone" db:"timezone"`       // IANA zone the quiet hours are in
+	LastStatus      int        `json:"last_status" db:"last_status"` // HTTP status of the latest delivery, 0 if it never got a response
+	LastError       *string    `json:"last_error,omitempty" db:"last_error"`
+	LastDeliveredAt *time.Time `json:"last_delivered_at,omitempty" db:"last_delivered_at"`
+	CreatedAt       time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt       time.Time  `json:"updated_at" db:"updated_at"`
+}
+
+// Subscribes reports whether the webhook is enabled and wants event
+func (w *AppWebhook) Subscribes(event string) bool {
+	if !w.Enabled {
+		return false
+	}
+	for _, e := range w.Events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// WebhookHeldEvent is a non-critical event a webhook in digest mode or quiet hours holds back
+type WebhookHeldEvent struct {
+	ID        string    `json:"id" db:"id"` // The held delivery's ID
+	WebhookID string    `json:"webhook_id" db:"webhook_id"`
+	Payload   string    `json:"payload" db:"payload"` // The delivery's JSON payload
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
+
+// AppTask is a command run in one of an app's services on a cron schedule, as an app_run job
+type AppTask struct {
+	ID             string     `json:"id" db:"id"`
+	AppID          string     `json:"app_id" db:"app_id"`
+	Name           string     `json:"name" db:"name"`
+	Service        string     `json:"service" db:"service"`
+	Command        []string   `json:"command" db:"command"`
+	Mode           string     `json:"mode" db:"mode"` // run or exec, as for one-off commands
+	Cron           string     `json:"cron" db:"cron"`
+	Timezone       string     `json:"timezone" db:"timezone"`
+	TimeoutSeconds int        `json:"timeout_seconds" db:"timeout_seconds"` // 0 means the default timeout
+	Enabled        bool       `json:"enabled" db:"enabled"`
+	LastStatus     string     `json:"last_status" db:"last_status"` // Status of the latest run: completed or failed, "" before the first
+	LastRunAt      *time.Time `json:"last_run_at,omitempty" db:"last_run_at"`
+	CreatedAt      time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at" db:"updated_at"`
+}
+
+// AppTaskRun is one finished run of an AppTask
+type AppTaskRun struct {
+	ID           string    `json:"id" db:"id"`
+	TaskID       string    `json:"task_id" db:"task_id"`
+	AppID        string    `json:"app_id" db:"app_id"`
+	JobID        string    `json:"job_id" db:"job_id"`
+	Status       string    `json:"status" db:"status"` // completed or failed
+	ExitCode     int       `json:"exit_code" db:"exit_code"`
+	TimedOut     bool      `json:"timed_out" db:"timed_out"`
+	Output       string    `json:"output" db:"output"` // Last lines of the command's output
+	ErrorMessage *string   `json:"error_message,omitempty" db:"error_message"`
+	StartedAt    time.Time `json:"started_at" db:"started_at"`
+	FinishedAt   time.Time `json:"finished_at" db:"finished_at"`
 }
 
 // NewComposeVersion creates a new ComposeVersion with a generated UUID
@@ -258,9 +576,16 @@ func NewComposeVersion(appID string, version int, composeContent string, changeR
 		ChangedBy:      changedBy,
 		IsCurrent:      true,
 		CreatedAt:      time.Now(),
+		Checksum:       ComposeChecksum(composeContent),
 	}
 }
 
+// ComposeChecksum returns the hex SHA-256 of a compose file's content
+func ComposeChecksum(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
+
 // NewAppSchedule creates a new AppSchedule with a generated UUID
 func NewAppSchedule(appID, startCron, stopCron, timezone string, enabled bool) *AppSchedule {
 	now := time.Now()
@@ -276,7 +601,51 @@ func NewAppSchedule(appID, startCron, stopCron, timezone string, enabled bool) *
 	}
 }
 
-// NewJob creates a new Job with a generated UUID
+// NewAppWebhook creates a new enabled AppWebhook with a generated UUID
+func NewAppWebhook(appID, url, secret string, events []string) *AppWebhook {
+	now := time.Now()
+	return &AppWebhook{
+		ID:        uuid.New().String(),
+		AppID:     appID,
+		URL:       url,
+		Secret:    secret,
+		Events:    events,
+		Enabled:   true,
+		Timezone:  "UTC",
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+}
+
+// NewAppTask creates a new enabled AppTask with a generated UUID
+func NewAppTask(appID, name, service string, command []string, mode, cronExpr, timezone string, timeoutSeconds int) *AppTask {
+	now := time.Now()
+	return &AppTask{
+		ID:             uuid.New().String(),
+		AppID:          appID,
+		Name:           name,
+		Service:        service,
+		Command:        command,
+		Mode:           mode,
+		Cron:           cronExpr,
+		Timezone:       timezone,
+		TimeoutSeconds: timeoutSeconds,
+		Enabled:        true,
+		CreatedAt:      now,
+		UpdatedAt:      now,
+	}
+}
+
+// jobTypePriorities are the priorities of job types that don't run at normal priority
+var jobTypePriorities = map[string]int{
+	constants.JobTypeAppStart:          constants.JobPriorityInteractive,
+	constants.JobTypeAppStop:           constants.JobPriorityInteractive,
+	constants.JobTypeAppRun:            constants.JobPriorityInteractive,
+	constants.JobTypeAppScheduledStart: constants.JobPriorityBackground,
+	constants.JobTypeAppScheduledStop:  constants.JobPriorityBackground,
+}
+
+// NewJob creates a new Job with a generated UUID, at its type's priority
 func NewJob(jobType, appID string, payload *string) *Job {
 	now := time.Now()
 	return &Job{
@@ -286,7 +655,386 @@ func NewJob(jobType, appID string, payload *string) *Job {
 		Status:    constants.JobStatusPending,
 		Payload:   payload,
 		Progress:  0,
+		Priority:  jobTypePriorities[jobType],
 		CreatedAt: now,
 		UpdatedAt: now,
 	}
 }
+
+// NewQueuedOperation creates a pending QueuedOperation with a generated UUID
+func NewQueuedOperation(nodeID, method, path, rawQuery, body string) *QueuedOperation {
+	now := time.Now()
+	return &QueuedOperation{
+		ID:        uuid.New().String(),
+		NodeID:    nodeID,
+		Method:    method,
+		Path:      path,
+		RawQuery:  rawQuery,
+		Body:      body,
+		Status:    constants.QueuedOperationStatusPending,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+}
+
+// SettingsChange is one update to a settings section, kept as an audit trail
+type SettingsChange struct {
+	ID        string                `json:"id" db:"id"`
+	Section   string                `json:"section" db:"section"`
+	Changes   []SettingsFieldChange `json:"changes" db:"changes"` // Secret values are masked
+	ChangedBy string                `json:"changed_by,omitempty" db:"changed_by"`
+	CreatedAt time.Time             `json:"created_at" db:"created_at"`
+}
+
+// SettingsFieldChange is a setting's value before and after an update
+type SettingsFieldChange struct {
+	Field string      `json:"field"`
+	Old   interface{} `json:"old"`
+	New   interface{} `json:"new"`
+}
+
+// NewSettingsChange creates a SettingsChange with a generated UUID
+func NewSettingsChange(section, changedBy string, changes []SettingsFieldChange) *SettingsChange {
+	return &SettingsChange{
+		ID:        uuid.New().String(),
+		Section:   section,
+		Changes:   changes,
+		ChangedBy: changedBy,
+		CreatedAt: time.Now(),
+	}
+}
+
+// PrimaryReplica is a secondary's read-only copy of one kind of primary metadata, so it can
+// answer reads while the primary is unreachable
+type PrimaryReplica struct {
+	Kind     string    `json:"kind" db:"kind"`
+	Data     string    `json:"data" db:"data"` // JSON
+	SyncedAt time.Time `json:"synced_at" db:"synced_at"`
+}
+
+// NodeMetric is one sample of a node's system stats, kept for the metrics history
+type NodeMetric struct {
+	NodeID             string    `json:"node_id" db:"node_id"`
+	CPUPercent         float64   `json:"cpu_percent" db:"cpu_percent"`
+	CPUCores           int       `json:"cpu_cores" db:"cpu_cores"`
+	MemoryPercent      float64   `json:"memory_percent" db:"memory_percent"`
+	DiskPercent        float64   `json:"disk_percent" db:"disk_percent"`
+	Load1              float64   `json:"load_1" db:"load_1"`
+	Load5              float64   `json:"load_5" db:"load_5"`
+	Load15             float64   `json:"load_15" db:"load_15"`
+	TemperatureCelsius *float64  `json:"temperature_celsius,omitempty" db:"temperature_celsius"` // nil when the node has no sensors
+	RecordedAt         time.Time `json:"recorded_at" db:"recorded_at"`
+}
+
+// LoadPercent is the 5-minute load average as a percent of the node's CPU cores
+func (m *NodeMetric) LoadPercent() float64 {
+	if m.CPUCores <= 0 {
+		return 0
+	}
+	return m.Load5 / float64(m.CPUCores) * 100
+}
+
+// NodeLog is one line of selfhostly's own server log, shipped to the primary by a secondary
+type NodeLog struct {
+	ID       int64     `json:"id" db:"id"`
+	NodeID   string    `json:"node_id" db:"node_id"`
+	LoggedAt time.Time `json:"time" db:"logged_at"`
+	Level    string    `json:"level" db:"level"`
+	Message  string    `json:"msg" db:"message"`
+	Line     string    `json:"line" db:"line"` // The whole JSON record, attributes included
+}
+
+// NodeAlert is a node metric that went over its threshold; ResolvedAt is nil while it still is
+type NodeAlert struct {
+	ID         string     `json:"id" db:"id"`
+	NodeID     string     `json:"node_id" db:"node_id"`
+	Metric     string     `json:"metric" db:"metric"` // disk, memory, load or temperature
+	Value      float64    `json:"value" db:"value"`   // Value when the alert fired
+	Threshold  float64    `json:"threshold" db:"threshold"`
+	StartedAt  time.Time  `json:"started_at" db:"started_at"`
+	ResolvedAt *time.Time `json:"resolved_at,omitempty" db:"resolved_at"`
+}
+
+// NewNodeAlert creates an active NodeAlert with a generated UUID
+func NewNodeAlert(nodeID, metric string, value, threshold float64) *NodeAlert {
+	return &NodeAlert{
+		ID:        uuid.New().String(),
+		NodeID:    nodeID,
+		Metric:    metric,
+		Value:     value,
+		Threshold: threshold,
+		StartedAt: time.Now(),
+	}
+}
+
+// GatewayToken is the record of a token issued to the gateway for fetching the node registry.
+// The signed token is only returned when it is issued.
+type GatewayToken struct {
+	ID          string     `json:"id" db:"id"`
+	Name        string     `json:"name" db:"name"`
+	RotatedFrom *string    `json:"rotated_from,omitempty" db:"rotated_from"` // Token this one replaced
+	ExpiresAt   time.Time  `json:"expires_at" db:"expires_at"`
+	LastUsedAt  *time.Time `json:"last_used_at,omitempty" db:"last_used_at"`
+	RevokedAt   *time.Time `json:"revoked_at,omitempty" db:"revoked_at"`
+	CreatedAt   time.Time  `json:"created_at" db:"created_at"`
+}
+
+// NewGatewayToken creates a GatewayToken with a generated UUID, valid for ttl
+func NewGatewayToken(name string, ttl time.Duration) *GatewayToken {
+	now := time.Now()
+	return &GatewayToken{
+		ID:        uuid.New().String(),
+		Name:      name,
+		ExpiresAt: now.Add(ttl),
+		CreatedAt: now,
+	}
+}
+
+// Active reports whether the token is neither revoked nor expired at now
+func (t *GatewayToken) Active(now time.Time) bool {
+	return t.RevokedAt == nil && now.Before(t.ExpiresAt)
+}
+
+// AppSnapshot pins everything an app is deployed from under a name: its compose files, its .env
+// and the digests of the images it ran, so that exact deployment can be restored later
+type AppSnapshot struct {
+	ID              string            `json:"id" db:"id"`
+	AppID           string            `json:"app_id" db:"app_id"`
+	Name            string            `json:"name" db:"name"`
+	ComposeVersion  int               `json:"compose_version" db:"compose_version"` // Compose version current when the snapshot was taken
+	ComposeContent  string            `json:"compose_content" db:"compose_content"`
+	ComposeOverride string            `json:"compose_override,omitempty" db:"compose_override"`
+	ComposeFiles    map[string]string `json:"compose_files,omitempty" db:"compose_files"`
+	EnvTemplate     string            `json:"env_template,omitempty" db:"env_template"`
+	EnvContent      string            `json:"-" db:"env_content"` // Generated secrets; restored, never sent
+	ImageDigests    map[string]string `json:"image_digests,omitempty" db:"image_digests"` // Image reference -> digest
+	CreatedBy       *string           `json:"created_by,omitempty" db:"created_by"`
+	CreatedAt       time.Time         `json:"created_at" db:"created_at"`
+}
+
+// NewAppSnapshot creates a snapshot of the app's current compose files and .env with a generated
+// UUID; the caller fills in the compose version and image digests
+func NewAppSnapshot(app *App, name string, createdBy *string) *AppSnapshot {
+	return &AppSnapshot{
+		ID:              uuid.New().String(),
+		AppID:           app.ID,
+		Name:            name,
+		ComposeContent:  app.ComposeContent,
+		ComposeOverride: app.ComposeOverride,
+		ComposeFiles:    app.ComposeFiles,
+		EnvTemplate:     app.EnvTemplate,
+		EnvContent:      app.EnvContent,
+		CreatedBy:       createdBy,
+		CreatedAt:       time.Now(),
+	}
+}
+
+// UserPreferences is one user's UI and CLI preferences, kept on the server so they follow the user
+// across browsers and into the CLI
+type UserPreferences struct {
+	UserID          string              `json:"user_id" db:"user_id"`
+	DefaultNodeID   string              `json:"default_node_id" db:"default_node_id"`   // Empty for all nodes
+	TableColumns    map[string][]string `json:"table_columns" db:"table_columns"`       // Table name -> visible column keys, in order
+	RefreshInterval int                 `json:"refresh_interval" db:"refresh_interval"` // Seconds; 0 turns auto-refresh off
+	Theme           string              `json:"theme" db:"theme"`                       // system, light or dark
+	UpdatedAt       time.Time           `json:"updated_at,omitempty" db:"updated_at"`
+}
+
+// Approval is a destructive operation waiting for, or given, a second admin's confirmation. Records
+// are kept after they are decided, as the audit trail.
+type Approval struct {
+	ID          string            `json:"id" db:"id"`
+	Operation   string            `json:"operation" db:"operation"`     // delete_app, delete_tunnel, delete_orphaned_tunnel or remove_node
+	ResourceID  string            `json:"resource_id" db:"resource_id"` // App, tunnel or node ID
+	Params      map[string]string `json:"params,omitempty" db:"params"` // Options of the original request, e.g. archive or strategy
+	Status      string            `json:"status" db:"status"`
+	RequestedBy string            `json:"requested_by" db:"requested_by"`
+	RequestedAt time.Time         `json:"requested_at" db:"requested_at"`
+	ExpiresAt   time.Time         `json:"expires_at" db:"expires_at"`
+	DecidedBy   string            `json:"decided_by,omitempty" db:"decided_by"`
+	DecidedAt   *time.Time        `json:"decided_at,omitempty" db:"decided_at"`
+	Error       string            `json:"error,omitempty" db:"error"` // Why the confirmed operation failed
+}
+
+// NewApproval creates a pending Approval with a generated UUID, expiring after ttl
+func NewApproval(operation, resourceID string, params map[string]string, requestedBy string, ttl time.Duration) *Approval {
+	now := time.Now()
+	return &Approval{
+		ID:          uuid.New().String(),
+		Operation:   operation,
+		ResourceID:  resourceID,
+		Params:      params,
+		Status:      constants.ApprovalStatusPending,
+		RequestedBy: requestedBy,
+		RequestedAt: now,
+		ExpiresAt:   now.Add(ttl),
+	}
+}
+
+// UserQuota caps what one user's apps may use across the cluster. Zero leaves a limit off. The
+// quota of user "*" applies to everyone without their own.
+type UserQuota struct {
+	UserName    string    `json:"user_name" db:"user_name"`
+	MaxApps     int       `json:"max_apps" db:"max_apps"`
+	MaxMemoryMB int       `json:"max_memory_mb" db:"max_memory_mb"` // Sum of the apps' deploy.resources.reservations.memory
+	MaxCPUs     float64   `json:"max_cpus" db:"max_cpus"`           // Sum of the apps' deploy.resources.reservations.cpus
+	MaxTunnels  int       `json:"max_tunnels" db:"max_tunnels"`     // Apps with a custom or Quick Tunnel
+	UpdatedAt   time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// Invitation is a single-use invite link that creates an account with Role for the GitHub user who
+// accepts it. Only a hash of its token is stored.
+type Invitation struct {
+	ID         string     `json:"id" db:"id"`
+	TokenHash  string     `json:"-" db:"token_hash"`
+	Email      string     `json:"email,omitempty" db:"email"`
+	Role       string     `json:"role" db:"role"`
+	CreatedBy  string     `json:"created_by,omitempty" db:"created_by"`
+	ExpiresAt  time.Time  `json:"expires_at" db:"expires_at"`
+	AcceptedBy string     `json:"accepted_by,omitempty" db:"accepted_by"`
+	AcceptedAt *time.Time `json:"accepted_at,omitempty" db:"accepted_at"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty" db:"revoked_at"`
+	CreatedAt  time.Time  `json:"created_at" db:"created_at"`
+	Status     string     `json:"status" db:"-"` // Derived from the timestamps when read
+}
+
+// NewInvitation creates a pending Invitation with a generated UUID, valid for ttl
+func NewInvitation(tokenHash, email, role, createdBy string, ttl time.Duration) *Invitation {
+	now := time.Now()
+	return &Invitation{
+		ID:        uuid.New().String(),
+		TokenHash: tokenHash,
+		Email:     email,
+		Role:      role,
+		CreatedBy: createdBy,
+		ExpiresAt: now.Add(ttl),
+		CreatedAt: now,
+		Status:    constants.InvitationStatusPending,
+	}
+}
+
+// Session is a signed-in device: one GitHub sign-in, kept across token refreshes until it goes
+// unused for the session lifetime or is revoked
+type Session struct {
+	ID         string     `json:"id" db:"id"` // ID of the session's tokens
+	UserName   string     `json:"user_name" db:"user_name"`
+	UserAgent  string     `json:"user_agent,omitempty" db:"user_agent"` // Of the last request seen
+	IP         string     `json:"ip,omitempty" db:"ip"`                 // Of the last request seen
+	CreatedAt  time.Time  `json:"created_at" db:"created_at"`           // First seen
+	LastSeenAt time.Time  `json:"last_seen_at" db:"last_seen_at"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty" db:"revoked_at"`
+	Current    bool       `json:"current" db:"-"` // The session the request was made with
+}
+
+// NewSession creates a session seen now with a request from the given device
+func NewSession(id, userName, userAgent, ip string) *Session {
+	now := time.Now()
+	return &Session{
+		ID:         id,
+		UserName:   userName,
+		UserAgent:  userAgent,
+		IP:         ip,
+		CreatedAt:  now,
+		LastSeenAt: now,
+	}
+}
+
+// APIToken is a personal access token a user created for scripts and CI. It acts as the user,
+// limited to reading when ReadOnly; the token itself is only returned when it is created.
+type APIToken struct {
+	ID          string     `json:"id" db:"id"`
+	Name        string     `json:"name" db:"name"`
+	UserName    string     `json:"user_name" db:"user_name"`
+	TokenHash   string     `json:"-" db:"token_hash"`
+	TokenPrefix string     `json:"token_prefix" db:"token_prefix"` // Start of the token, to tell tokens apart
+	ReadOnly    bool       `json:"read_only" db:"read_only"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty" db:"expires_at"` // Nil never expires
+	LastUsedAt  *time.Time `json:"last_used_at,omitempty" db:"last_used_at"`
+	RevokedAt   *time.Time `json:"revoked_at,omitempty" db:"revoked_at"`
+	CreatedAt   time.Time  `json:"created_at" db:"created_at"`
+}
+
+// NewAPIToken creates an APIToken with a generated UUID for the token hashing to tokenHash
+func NewAPIToken(name, userName, tokenHash, tokenPrefix string, readOnly bool, expiresAt *time.Time) *APIToken {
+	return &APIToken{
+		ID:          uuid.New().String(),
+		Name:        name,
+		UserName:    userName,
+		TokenHash:   tokenHash,
+		TokenPrefix: tokenPrefix,
+		ReadOnly:    readOnly,
+		ExpiresAt:   expiresAt,
+		CreatedAt:   time.Now(),
+	}
+}
+
+// Active reports whether the token is neither revoked nor expired at now
+func (t *APIToken) Active(now time.Time) bool {
+	return t.RevokedAt == nil && (t.ExpiresAt == nil || now.Before(*t.ExpiresAt))
+}
+
+// AppStatusChange is one entry of an app's status history, recorded for every status change
+type AppStatusChange struct {
+	ID         int64     `json:"id" db:"id"`
+	AppID      string    `json:"app_id" db:"app_id"`
+	FromStatus string    `json:"from_status" db:"from_status"`
+	ToStatus   string    `json:"to_status" db:"to_status"`
+	ChangedAt  time.Time `json:"changed_at" db:"changed_at"`
+}
+
+// AppStatusPage is an app's public status page, reached with a token of which only a hash is stored
+type AppStatusPage struct {
+	AppID     string    `json:"app_id" db:"app_id"`
+	TokenHash string    `json:"-" db:"token_hash"`
+	CreatedBy string    `json:"created_by,omitempty" db:"created_by"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
+
+// AppIcon is an app's cached icon, downloaded from SourceURL. A failed lookup is cached too, with
+// no data and the error, so it isn't retried on every request.
+type AppIcon struct {
+	AppID       string    `json:"app_id" db:"app_id"`
+	SourceURL   string    `json:"source_url" db:"source_url"` // The icon's label, or the public URL it was detected from
+	IconURL     string    `json:"icon_url,omitempty" db:"icon_url"`
+	ContentType string    `json:"content_type,omitempty" db:"content_type"`
+	Data        []byte    `json:"-" db:"data"`
+	Error       string    `json:"error,omitempty" db:"error"`
+	FetchedAt   time.Time `json:"fetched_at" db:"fetched_at"`
+}
+
+// PortReservation holds a host port on a node for an app, or for something outside selfhostly when
+// AppID is unset. A port and protocol can be reserved only once per node.
+type PortReservation struct {
+	ID          string    `json:"id" db:"id"`
+	NodeID      string    `json:"node_id" db:"node_id"`
+	Port        int       `json:"port" db:"port"`
+	Protocol    string    `json:"protocol" db:"protocol"` // tcp or udp
+	AppID       *string   `json:"app_id,omitempty" db:"app_id"`
+	Source      string    `json:"source" db:"source"` // compose, quick_tunnel_metrics or manual
+	Description string    `json:"description,omitempty" db:"description"`
+	CreatedAt   time.Time `json:"created_at" db:"created_at"`
+}
+
+// NewPortReservation creates a PortReservation with a generated UUID; appID may be empty
+func NewPortReservation(nodeID string, port int, protocol, appID, source, description string) *PortReservation {
+	reservation := &PortReservation{
+		ID:          uuid.New().String(),
+		NodeID:      nodeID,
+		Port:        port,
+		Protocol:    protocol,
+		Source:      source,
+		Description: description,
+		CreatedAt:   time.Now(),
+	}
+	if appID != "" {
+		reservation.AppID = &appID
+	}
+	return reservation
+}
+
+// OperationsLock blocks app changes on every node while an admin maintains or backs up the hosts
+type OperationsLock struct {
+	Reason   string    `json:"reason" db:"reason"`
+	LockedBy string    `json:"locked_by,omitempty" db:"locked_by"`
+	LockedAt time.Time `json:"locked_at" db:"locked_at"`
+}
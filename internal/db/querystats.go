@@ -0,0 +1,215 @@
+package db
+
+import (
+	"database/sql"
+	"log/slog"
+	"path"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/selfhostly/internal/constants"
+)
+
+// QueryStat is how often a query ran and how long it took, since the server started. Durations
+// are until the first row for queries returning rows.
+type QueryStat struct {
+	Query   string  `json:"query"`  // With whitespace collapsed
+	Caller  string  `json:"caller"` // DB method that first ran it
+	Calls   int64   `json:"calls"`
+	Errors  int64   `json:"errors"`
+	Slow    int64   `json:"slow"` // Calls that took at least constants.DBSlowQueryThreshold
+	TotalMS float64 `json:"total_ms"`
+	AvgMS   float64 `json:"avg_ms"`
+	MaxMS   float64 `json:"max_ms"`
+}
+
+// queryStats prepares the statements the DB runs, keeping each for reuse, and times them
+type queryStats struct {
+	mu    sync.Mutex
+	stmts map[string]*sql.Stmt
+	stats map[string]*QueryStat
+}
+
+func newQueryStats() *queryStats {
+	return &queryStats{stmts: make(map[string]*sql.Stmt), stats: make(map[string]*QueryStat)}
+}
+
+// Exec runs a statement, from the statement cache when it can be prepared
+func (db *DB) Exec(query string, args ...any) (sql.Result, error) {
+	start := time.Now()
+	var result sql.Result
+	stmt, err := db.prepared(query)
+	if err == nil {
+		if stmt != nil {
+			result, err = stmt.Exec(args...)
+		} else {
+			result, err = db.DB.Exec(query, args...)
+		}
+	}
+	db.observe(query, start, err)
+	return result, err
+}
+
+// Query runs a query, from the statement cache when it can be prepared
+func (db *DB) Query(query string, args ...any) (*sql.Rows, error) {
+	start := time.Now()
+	var rows *sql.Rows
+	stmt, err := db.prepared(query)
+	if err == nil {
+		if stmt != nil {
+			rows, err = stmt.Query(args...)
+		} else {
+			rows, err = db.DB.Query(query, args...)
+		}
+	}
+	db.observe(query, start, err)
+	return rows, err
+}
+
+// QueryRow runs a query expected to return at most one row, from the statement cache when it can
+// be prepared. Errors surface from Scan, as with sql.DB, and aren't counted.
+func (db *DB) QueryRow(query string, args ...any) *sql.Row {
+	start := time.Now()
+	var row *sql.Row
+	if stmt, err := db.prepared(query); err == nil && stmt != nil {
+		row = stmt.QueryRow(args...)
+	} else {
+		row = db.DB.QueryRow(query, args...)
+	}
+	db.observe(query, start, nil)
+	return row
+}
+
+// Close closes the cached statements and the database
+func (db *DB) Close() error {
+	db.queries.mu.Lock()
+	for query, stmt := range db.queries.stmts {
+		stmt.Close()
+		delete(db.queries.stmts, query)
+	}
+	db.queries.mu.Unlock()
+	return db.DB.Close()
+}
+
+// QueryStats returns the limit queries that took the most time in total, slowest first
+func (db *DB) QueryStats(limit int) []*QueryStat {
+	db.queries.mu.Lock()
+	stats := make([]*QueryStat, 0, len(db.queries.stats))
+	for _, stat := range db.queries.stats {
+		copied := *stat
+		if copied.Calls > 0 {
+			copied.AvgMS = copied.TotalMS / float64(copied.Calls)
+		}
+		stats = append(stats, &copied)
+	}
+	db.queries.mu.Unlock()
+
+	slices.SortFunc(stats, func(a, b *QueryStat) int {
+		if a.TotalMS != b.TotalMS {
+			if a.TotalMS > b.TotalMS {
+				return -1
+			}
+			return 1
+		}
+		return strings.Compare(a.Query, b.Query)
+	})
+	if len(stats) > limit {
+		stats = stats[:limit]
+	}
+	return stats
+}
+
+// prepared returns the cached statement for query, preparing it on first use. It returns nil for
+// queries that aren't cached: schema changes and pragmas, which run rarely or can't be prepared
+// ahead, and everything once the cache is full, which only queries built with varying numbers of
+// placeholders could fill.
+func (db *DB) prepared(query string) (*sql.Stmt, error) {
+	if !cacheableQuery(query) {
+		return nil, nil
+	}
+	db.queries.mu.Lock()
+	stmt, ok := db.queries.stmts[query]
+	full := len(db.queries.stmts) >= constants.DBStatementCacheSize
+	db.queries.mu.Unlock()
+	if ok || full {
+		return stmt, nil
+	}
+
+	stmt, err := db.DB.Prepare(query)
+	if err != nil {
+		return nil, err
+	}
+	db.queries.mu.Lock()
+	defer db.queries.mu.Unlock()
+	if cached, ok := db.queries.stmts[query]; ok {
+		// Prepared meanwhile by another caller
+		stmt.Close()
+		return cached, nil
+	}
+	db.queries.stmts[query] = stmt
+	return stmt, nil
+}
+
+// observe records a run of query that started at start, logging it when it was slow
+func (db *DB) observe(query string, start time.Time, err error) {
+	elapsed := time.Since(start)
+	ms := float64(elapsed) / float64(time.Millisecond)
+	slow := elapsed >= constants.DBSlowQueryThreshold
+
+	db.queries.mu.Lock()
+	stat, ok := db.queries.stats[query]
+	if !ok {
+		stat = &QueryStat{Query: strings.Join(strings.Fields(query), " "), Caller: queryCaller()}
+		// Past the cap, new queries are only logged when slow
+		if len(db.queries.stats) < constants.DBQueryStatsMaxQueries {
+			db.queries.stats[query] = stat
+		}
+	}
+	stat.Calls++
+	stat.TotalMS += ms
+	stat.MaxMS = max(stat.MaxMS, ms)
+	if err != nil {
+		stat.Errors++
+	}
+	if slow {
+		stat.Slow++
+	}
+	db.queries.mu.Unlock()
+
+	if slow {
+		slog.Warn("slow database query", "caller", stat.Caller, "duration_ms", elapsed.Milliseconds(), "query", stat.Query)
+	}
+}
+
+// cacheableQuery reports whether a statement is one rows are read or written with
+func cacheableQuery(query string) bool {
+	keyword := strings.TrimLeftFunc(query, unicode.IsSpace)
+	if i := strings.IndexFunc(keyword, unicode.IsSpace); i >= 0 {
+		keyword = keyword[:i]
+	}
+	switch strings.ToUpper(keyword) {
+	case "SELECT", "INSERT", "UPDATE", "DELETE", "WITH":
+		return true
+	}
+	return false
+}
+
+// queryCaller names the first function outside this file on the stack, i.e. the DB method that
+// ran the query
+func queryCaller() string {
+	pcs := make([]uintptr, 16)
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(2, pcs)])
+	for {
+		frame, more := frames.Next()
+		if !strings.HasSuffix(frame.File, "querystats.go") {
+			return path.Base(frame.Function)
+		}
+		if !more {
+			return ""
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package db
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/selfhostly/internal/constants"
+)
+
+func TestQueryStats(t *testing.T) {
+	database, err := Init(filepath.Join(t.TempDir(), "selfhostly.db"))
+	if err != nil {
+		t.Fatalf("Init: %v", err)
+	}
+	defer database.Close()
+
+	const query = "SELECT COUNT(*) FROM apps WHERE status = ?"
+	for range 3 {
+		var count int
+		if err := database.QueryRow(query, "running").Scan(&count); err != nil {
+			t.Fatalf("QueryRow: %v", err)
+		}
+	}
+	if _, err := database.Exec("INSERT INTO missing_table (id) VALUES (?)", "x"); err == nil {
+		t.Fatal("Expected an error inserting into a missing table")
+	}
+	var pageSize int64
+	if err := database.QueryRow("PRAGMA page_size").Scan(&pageSize); err != nil {
+		t.Fatalf("PRAGMA page_size: %v", err)
+	}
+
+	database.queries.mu.Lock()
+	_, cached := database.queries.stmts[query]
+	_, pragmaCached := database.queries.stmts["PRAGMA page_size"]
+	database.queries.mu.Unlock()
+	if !cached || pragmaCached {
+		t.Errorf("Expected the SELECT to be cached and the pragma not, got %v and %v", cached, pragmaCached)
+	}
+
+	stats := map[string]*QueryStat{}
+	for _, stat := range database.QueryStats(constants.DBQueryStatsMaxQueries) {
+		stats[stat.Query] = stat
+	}
+	if stat := stats[query]; stat == nil || stat.Calls != 3 || stat.Errors != 0 || stat.Caller != "db.TestQueryStats" {
+		t.Errorf("Unexpected stats for the SELECT: %+v", stat)
+	}
+	if stat := stats["INSERT INTO missing_table (id) VALUES (?)"]; stat == nil || stat.Errors != 1 {
+		t.Errorf("Expected the failed INSERT to be counted, got %+v", stat)
+	}
+	if stat := stats["PRAGMA page_size"]; stat == nil || stat.Calls != 1 {
+		t.Errorf("Expected uncached statements to be timed too, got %+v", stat)
+	}
+	if limited := database.QueryStats(1); len(limited) != 1 {
+		t.Errorf("Expected 1 query, got %d", len(limited))
+	}
+
+	if err := database.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if len(database.queries.stmts) != 0 {
+		t.Errorf("Expected the statement cache to be emptied, got %d statements", len(database.queries.stmts))
+	}
+}
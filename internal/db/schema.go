@@ -0,0 +1,120 @@
+package db
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/selfhostly/internal/constants"
+)
+
+// The schema version is the number of migration statements a build knows about, recorded in
+// SQLite's user_version once they have all run. Migrations are only ever appended, so a database
+// with a higher version was written by a newer build.
+
+// SchemaVersion returns the schema version recorded in the database; 0 for new databases and
+// ones created before the version was recorded
+func (db *DB) SchemaVersion() (int, error) {
+	var version int
+	if err := db.QueryRow("PRAGMA user_version").Scan(&version); err != nil {
+		return 0, fmt.Errorf("failed to read schema version: %w", err)
+	}
+	return version, nil
+}
+
+// prepareSchemaUpgrade runs before migrations. It refuses a database written by a newer build,
+// since migrating it down could drop data that build relies on, and snapshots an existing
+// database that is about to be migrated.
+func (db *DB) prepareSchemaUpgrade(target int) error {
+	current, err := db.SchemaVersion()
+	if err != nil {
+		return err
+	}
+	if current > target {
+		return fmt.Errorf("database schema version %d is newer than this build supports (%d); run the newer selfhostly build or restore a snapshot from %s",
+			current, target, db.snapshotDir())
+	}
+	if current == target {
+		return nil
+	}
+
+	var tables int
+	if err := db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type = 'table'").Scan(&tables); err != nil {
+		return fmt.Errorf("failed to inspect database: %w", err)
+	}
+	if tables == 0 {
+		// New database, nothing to protect
+		return nil
+	}
+
+	path, err := db.snapshot(current)
+	if err != nil {
+		return fmt.Errorf("failed to snapshot database before migrating: %w", err)
+	}
+	slog.Info("Snapshotted database before migrating", "path", path, "from_schema", current, "to_schema", target)
+	db.pruneSnapshots()
+	return nil
+}
+
+// setSchemaVersion records version once migrations have run
+func (db *DB) setSchemaVersion(version int) error {
+	// PRAGMA doesn't take bound parameters
+	if _, err := db.Exec(fmt.Sprintf("PRAGMA user_version = %d", version)); err != nil {
+		return fmt.Errorf("failed to record schema version: %w", err)
+	}
+	return nil
+}
+
+// snapshotDir is where pre-migration snapshots are kept, next to the database file
+func (db *DB) snapshotDir() string {
+	return filepath.Join(filepath.Dir(db.dbPath), constants.DBSnapshotDir)
+}
+
+// snapshot writes a consistent copy of the database, including anything still in the WAL, and
+// returns its path
+func (db *DB) snapshot(schemaVersion int) (string, error) {
+	dir := db.snapshotDir()
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	name := strings.TrimSuffix(filepath.Base(db.dbPath), filepath.Ext(db.dbPath))
+	path := filepath.Join(dir, fmt.Sprintf("%s-schema%d-%s.db", name, schemaVersion, time.Now().UTC().Format("20060102T150405Z")))
+	if _, err := db.Exec("VACUUM INTO ?", path); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// pruneSnapshots keeps the most recent DBSnapshotKeepCount snapshots of this database. Failures
+// are only logged; a leftover snapshot is harmless.
+func (db *DB) pruneSnapshots() {
+	name := strings.TrimSuffix(filepath.Base(db.dbPath), filepath.Ext(db.dbPath))
+	snapshots, err := filepath.Glob(filepath.Join(db.snapshotDir(), name+"-schema*.db"))
+	if err != nil || len(snapshots) <= constants.DBSnapshotKeepCount {
+		return
+	}
+
+	type snapshotFile struct {
+		path    string
+		modTime time.Time
+	}
+	files := make([]snapshotFile, 0, len(snapshots))
+	for _, path := range snapshots {
+		info, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+		files = append(files, snapshotFile{path, info.ModTime()})
+	}
+	slices.SortFunc(files, func(a, b snapshotFile) int { return b.modTime.Compare(a.modTime) })
+
+	for _, f := range files[min(len(files), constants.DBSnapshotKeepCount):] {
+		if err := os.Remove(f.path); err != nil {
+			slog.Warn("Failed to remove old database snapshot", "path", f.path, "error", err)
+		}
+	}
+}
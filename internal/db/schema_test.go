@@ -0,0 +1,79 @@
+package db
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/selfhostly/internal/constants"
+)
+
+func TestInit_SchemaVersion(t *testing.T) {
+	dir := t.TempDir()
+	dbPath := filepath.Join(dir, "selfhostly.db")
+	snapshots := filepath.Join(dir, constants.DBSnapshotDir)
+
+	database, err := Init(dbPath)
+	if err != nil {
+		t.Fatalf("Init: %v", err)
+	}
+	target, err := database.SchemaVersion()
+	if err != nil || target == 0 {
+		t.Fatalf("Expected schema version to be recorded, got %d (err %v)", target, err)
+	}
+	if _, err := os.Stat(snapshots); !os.IsNotExist(err) {
+		t.Errorf("Expected no snapshot for a new database, got %v", err)
+	}
+
+	// An older schema is snapshotted before migrating
+	if err := database.setSchemaVersion(target - 1); err != nil {
+		t.Fatalf("setSchemaVersion: %v", err)
+	}
+	database.Close()
+	if database, err = Init(dbPath); err != nil {
+		t.Fatalf("Init with an older schema: %v", err)
+	}
+	entries, _ := os.ReadDir(snapshots)
+	if len(entries) != 1 || !strings.HasPrefix(entries[0].Name(), "selfhostly-schema") {
+		t.Fatalf("Expected one snapshot, got %v", entries)
+	}
+	if version, _ := database.SchemaVersion(); version != target {
+		t.Errorf("Expected schema version %d after migrating, got %d", target, version)
+	}
+
+	// A newer schema is refused
+	if err := database.setSchemaVersion(target + 1); err != nil {
+		t.Fatalf("setSchemaVersion: %v", err)
+	}
+	database.Close()
+	if database, err = Init(dbPath); err == nil {
+		database.Close()
+		t.Fatal("Expected Init to refuse a database with a newer schema")
+	}
+}
+
+func TestPruneSnapshots(t *testing.T) {
+	dir := t.TempDir()
+	database, err := Init(filepath.Join(dir, "selfhostly.db"))
+	if err != nil {
+		t.Fatalf("Init: %v", err)
+	}
+	defer database.Close()
+
+	for i := 0; i < constants.DBSnapshotKeepCount+2; i++ {
+		path := filepath.Join(database.snapshotDir(), "selfhostly-schema"+strings.Repeat("1", i+1)+".db")
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	database.pruneSnapshots()
+
+	entries, _ := os.ReadDir(database.snapshotDir())
+	if len(entries) != constants.DBSnapshotKeepCount {
+		t.Errorf("Expected %d snapshots kept, got %d", constants.DBSnapshotKeepCount, len(entries))
+	}
+}
@@ -0,0 +1,149 @@
+package db
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/selfhostly/internal/constants"
+)
+
+// SeedAppPrefix starts the name of every app SeedFakeData creates
+const SeedAppPrefix = "seed-app-"
+
+// SeedOptions sizes the fake data SeedFakeData creates
+type SeedOptions struct {
+	Apps        int    // Apps to create
+	Jobs        int    // Finished jobs to create, spread over the new apps
+	PendingJobs int    // Jobs left pending, which a running worker picks up
+	NodeID      string // Node the apps belong to; empty is the primary node
+}
+
+// SeedFakeData fills the database with fake apps and jobs, for benchmarks and for trying the panel
+// at scale. Apps are named seed-app-NNNN, continuing after the ones an earlier run created, and
+// carry compose and env files of a realistic size; nothing is deployed. Everything is inserted in
+// one transaction.
+func (db *DB) SeedFakeData(opts SeedOptions) error {
+	if opts.Apps <= 0 && (opts.Jobs > 0 || opts.PendingJobs > 0) {
+		return fmt.Errorf("jobs need apps to belong to")
+	}
+
+	nodeID := opts.NodeID
+	if nodeID == "" {
+		// A database without nodes (e.g. in a benchmark) gets unassigned apps
+		_ = db.QueryRow("SELECT id FROM nodes WHERE is_primary = 1 LIMIT 1").Scan(&nodeID)
+	}
+	var existing int
+	if err := db.QueryRow("SELECT COUNT(*) FROM apps WHERE name LIKE ?", SeedAppPrefix+"%").Scan(&existing); err != nil {
+		return err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	appStmt, err := tx.Prepare(
+		"INSERT INTO apps (id, name, description, compose_content, tunnel_token, tunnel_id, tunnel_domain, public_url, status, node_id, tunnel_mode, env_template, env_content, created_at, updated_at) VALUES (?, ?, ?, ?, '', '', '', ?, ?, ?, '', ?, ?, ?, ?)",
+	)
+	if err != nil {
+		return err
+	}
+	defer appStmt.Close()
+	jobStmt, err := tx.Prepare(
+		`INSERT INTO jobs (id, type, app_id, status, progress, retry_count, started_at, completed_at, created_at, updated_at)
+		 VALUES (?, ?, ?, ?, ?, 0, ?, ?, ?, ?)`,
+	)
+	if err != nil {
+		return err
+	}
+	defer jobStmt.Close()
+
+	now := time.Now()
+	statuses := []string{constants.AppStatusRunning, constants.AppStatusRunning, constants.AppStatusRunning, constants.AppStatusStopped, constants.AppStatusError}
+	appIDs := make([]string, opts.Apps)
+	for i := range opts.Apps {
+		appIDs[i] = uuid.New().String()
+		name := fmt.Sprintf("%s%04d", SeedAppPrefix, existing+i+1)
+		env := seedEnv(name)
+		createdAt := now.Add(-time.Duration(opts.Apps-i) * time.Minute)
+		if _, err := appStmt.Exec(
+			appIDs[i], name, "Fake app created by the seed command", seedCompose(name, 10000+i),
+			fmt.Sprintf("https://%s.example.com", name), statuses[i%len(statuses)], nodeID, env, env, createdAt, createdAt,
+		); err != nil {
+			return fmt.Errorf("failed to create app %s: %w", name, err)
+		}
+	}
+
+	jobTypes := []string{constants.JobTypeAppCreate, constants.JobTypeAppUpdate, constants.JobTypeAppStart, constants.JobTypeAppStop}
+	for i := range opts.Jobs + opts.PendingJobs {
+		createdAt := now.Add(-time.Duration(opts.Jobs+opts.PendingJobs-i) * time.Second)
+		status, progress := constants.JobStatusCompleted, 100
+		var startedAt, completedAt *time.Time
+		switch {
+		case i >= opts.Jobs:
+			status, progress = constants.JobStatusPending, 0
+		case i%20 == 0:
+			status = constants.JobStatusFailed
+			fallthrough
+		default:
+			finishedAt := createdAt.Add(time.Second)
+			startedAt, completedAt = &createdAt, &finishedAt
+		}
+		if _, err := jobStmt.Exec(
+			uuid.New().String(), jobTypes[i%len(jobTypes)], appIDs[i%len(appIDs)], status, progress,
+			startedAt, completedAt, createdAt, createdAt,
+		); err != nil {
+			return fmt.Errorf("failed to create job: %w", err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// seedCompose is a compose file about the size of a typical app's
+func seedCompose(name string, port int) string {
+	return fmt.Sprintf(`services:
+  app:
+    image: nginx:alpine
+    container_name: %[1]s
+    restart: unless-stopped
+    ports:
+      - "%[2]d:80"
+    env_file:
+      - .env
+    volumes:
+      - ./data:/usr/share/nginx/html:ro
+      - ./config:/etc/nginx/conf.d:ro
+    healthcheck:
+      test: ["CMD", "wget", "-q", "--spider", "http://localhost"]
+      interval: 30s
+      timeout: 5s
+      retries: 3
+    labels:
+      selfhostly.seed: "true"
+  db:
+    image: postgres:16-alpine
+    restart: unless-stopped
+    environment:
+      POSTGRES_DB: %[1]s
+      POSTGRES_USER: %[1]s
+      POSTGRES_PASSWORD: ${DB_PASSWORD}
+    volumes:
+      - db-data:/var/lib/postgresql/data
+volumes:
+  db-data:
+`, name, port)
+}
+
+// seedEnv is an env file about the size of a typical app's
+func seedEnv(name string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "APP_NAME=%s\nDB_PASSWORD=%s\n", name, strings.Repeat("x", 32))
+	for i := range 10 {
+		fmt.Fprintf(&b, "SETTING_%02d=value-%02d\n", i, i)
+	}
+	return b.String()
+}
@@ -0,0 +1,105 @@
+package db
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/selfhostly/internal/constants"
+)
+
+func TestSeedFakeData(t *testing.T) {
+	database, err := Init(filepath.Join(t.TempDir(), "selfhostly.db"))
+	if err != nil {
+		t.Fatalf("Init: %v", err)
+	}
+	defer database.Close()
+
+	if err := database.SeedFakeData(SeedOptions{Apps: 5, Jobs: 20, PendingJobs: 2, NodeID: "node-1"}); err != nil {
+		t.Fatalf("SeedFakeData: %v", err)
+	}
+	// A second run continues the numbering instead of clashing with the first
+	if err := database.SeedFakeData(SeedOptions{Apps: 1}); err != nil {
+		t.Fatalf("SeedFakeData again: %v", err)
+	}
+
+	apps, err := database.GetAllApps()
+	if err != nil || len(apps) != 6 {
+		t.Fatalf("Expected 6 apps, got %d (err %v)", len(apps), err)
+	}
+	if apps[0].Name != SeedAppPrefix+"0006" || apps[len(apps)-1].Name != SeedAppPrefix+"0001" {
+		t.Errorf("Unexpected app names %s ... %s", apps[0].Name, apps[len(apps)-1].Name)
+	}
+	if !strings.Contains(apps[1].ComposeContent, "services:") || apps[1].NodeID != "node-1" {
+		t.Errorf("Expected a compose file and the node, got %+v", apps[1])
+	}
+
+	pending, err := database.GetPendingJobs(10)
+	if err != nil || len(pending) != 2 {
+		t.Fatalf("Expected 2 pending jobs, got %d (err %v)", len(pending), err)
+	}
+	var finished int
+	if err := database.QueryRow("SELECT COUNT(*) FROM jobs WHERE status IN (?, ?)", constants.JobStatusCompleted, constants.JobStatusFailed).Scan(&finished); err != nil || finished != 20 {
+		t.Errorf("Expected 20 finished jobs, got %d (err %v)", finished, err)
+	}
+
+	if err := database.SeedFakeData(SeedOptions{Jobs: 1}); err == nil {
+		t.Error("Expected jobs without apps to be refused")
+	}
+}
+
+func TestGetAllAppsMetadata(t *testing.T) {
+	database, err := Init(filepath.Join(t.TempDir(), "selfhostly.db"))
+	if err != nil {
+		t.Fatalf("Init: %v", err)
+	}
+	defer database.Close()
+	if err := database.SeedFakeData(SeedOptions{Apps: 3, NodeID: "node-1"}); err != nil {
+		t.Fatalf("SeedFakeData: %v", err)
+	}
+
+	full, _ := database.GetAllApps()
+	apps, err := database.GetAllAppsMetadata()
+	if err != nil || len(apps) != len(full) {
+		t.Fatalf("Expected %d apps, got %d (err %v)", len(full), len(apps), err)
+	}
+	for i, app := range apps {
+		if app.ID != full[i].ID || app.Status != full[i].Status || app.NodeID != "node-1" || app.PublicURL != full[i].PublicURL {
+			t.Errorf("Expected the same metadata as GetAllApps, got %+v", app)
+		}
+		if app.ComposeContent != "" || app.EnvContent != "" {
+			t.Errorf("Expected no compose or env content, got %q / %q", app.ComposeContent, app.EnvContent)
+		}
+	}
+}
+
+func TestCleanupOldCompletedJobs(t *testing.T) {
+	database, err := Init(filepath.Join(t.TempDir(), "selfhostly.db"))
+	if err != nil {
+		t.Fatalf("Init: %v", err)
+	}
+	defer database.Close()
+	// 2 apps with 15 finished jobs and 2 pending jobs each
+	if err := database.SeedFakeData(SeedOptions{Apps: 2, Jobs: 30, PendingJobs: 4}); err != nil {
+		t.Fatalf("SeedFakeData: %v", err)
+	}
+	apps, _ := database.GetAllApps()
+
+	if err := database.CleanupOldCompletedJobs(apps[0].ID, 10); err != nil {
+		t.Fatalf("CleanupOldCompletedJobs: %v", err)
+	}
+	if err := database.CleanupAllOldCompletedJobs(5); err != nil {
+		t.Fatalf("CleanupAllOldCompletedJobs: %v", err)
+	}
+
+	for _, app := range apps {
+		jobs, err := database.GetJobsByAppID(app.ID, 100)
+		if err != nil {
+			t.Fatalf("GetJobsByAppID: %v", err)
+		}
+		// The most recent finished jobs are kept, and pending ones are never removed
+		if len(jobs) != 7 {
+			t.Errorf("Expected 7 jobs left for %s, got %d", app.Name, len(jobs))
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package db
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/selfhostly/internal/constants"
+)
+
+func TestOverrideTunnelProvider(t *testing.T) {
+	database, err := Init(filepath.Join(t.TempDir(), "selfhostly.db"))
+	if err != nil {
+		t.Fatalf("Init: %v", err)
+	}
+	defer database.Close()
+
+	settings, err := database.GetSettings()
+	if err != nil {
+		t.Fatalf("GetSettings: %v", err)
+	}
+	provider := constants.ProviderCloudflare
+	settings.ActiveTunnelProvider = &provider
+	if err := settings.SetProviderConfig(constants.ProviderCloudflare, map[string]interface{}{"api_token": "token"}); err != nil {
+		t.Fatalf("SetProviderConfig: %v", err)
+	}
+	if err := database.UpdateSettings(settings); err != nil {
+		t.Fatalf("UpdateSettings: %v", err)
+	}
+
+	OverrideTunnelProvider(constants.ProviderFake)
+	t.Cleanup(func() { tunnelProviderOverride.Store(nil) })
+
+	settings, err = database.GetSettings()
+	if err != nil {
+		t.Fatalf("GetSettings: %v", err)
+	}
+	if name := settings.GetActiveProviderName(); name != constants.ProviderFake {
+		t.Errorf("Expected the override to be active, got %q", name)
+	}
+	if config, err := settings.GetProviderConfig(constants.ProviderFake); err != nil || config == nil {
+		t.Errorf("Expected an empty config for the override, got %v, %v", config, err)
+	}
+
+	// Saving other settings leaves the saved provider alone
+	settings.AutoStartApps = false
+	if err := database.UpdateSettings(settings); err != nil {
+		t.Fatalf("UpdateSettings: %v", err)
+	}
+	tunnelProviderOverride.Store(nil)
+	settings, err = database.GetSettings()
+	if err != nil {
+		t.Fatalf("GetSettings: %v", err)
+	}
+	if name := settings.GetActiveProviderName(); name != constants.ProviderCloudflare {
+		t.Errorf("Expected the saved provider once the override is gone, got %q", name)
+	}
+	if _, err := settings.GetProviderConfig(constants.ProviderFake); err == nil {
+		t.Error("Expected no fake provider config to be saved")
+	}
+}
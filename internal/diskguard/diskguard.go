@@ -0,0 +1,113 @@
+package diskguard
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+
+	"github.com/shirou/gopsutil/v3/disk"
+)
+
+// ErrInsufficientSpace is returned when a filesystem is below the minimum free space
+var ErrInsufficientSpace = errors.New("insufficient disk space")
+
+const mib = 1024 * 1024
+
+// Config holds the free-space thresholds checked before heavy operations (image pulls and app
+// creation). A zero threshold disables that level.
+type Config struct {
+	MinFreeBytes  uint64 // Operations are refused below this
+	WarnFreeBytes uint64 // Operations go ahead with a warning below this
+}
+
+// Default returns the built-in thresholds: refuse below 1 GiB free, warn below 5 GiB
+func Default() Config {
+	return Config{
+		MinFreeBytes:  1024 * mib,
+		WarnFreeBytes: 5 * 1024 * mib,
+	}
+}
+
+// LoadFromEnv reads DISK_MIN_FREE_MB and DISK_WARN_FREE_MB, keeping the default for anything
+// unset or invalid. Set either to 0 to turn that check off.
+func LoadFromEnv() Config {
+	cfg := Default()
+	cfg.MinFreeBytes = megabytesFromEnv("DISK_MIN_FREE_MB", cfg.MinFreeBytes)
+	cfg.WarnFreeBytes = megabytesFromEnv("DISK_WARN_FREE_MB", cfg.WarnFreeBytes)
+	return cfg
+}
+
+// Usage is the space on the filesystem holding Path, judged against the thresholds
+type Usage struct {
+	Path        string  `json:"path"`
+	FreeBytes   uint64  `json:"free_bytes"`
+	TotalBytes  uint64  `json:"total_bytes"`
+	UsedPercent float64 `json:"usage_percent"`
+	Low         bool    `json:"low"` // Below the warning threshold
+}
+
+// Guard checks filesystems against the configured thresholds
+type Guard struct {
+	config Config
+	usage  func(path string) (*disk.UsageStat, error)
+}
+
+// New creates a guard with the given thresholds
+func New(cfg Config) *Guard {
+	return &Guard{config: cfg, usage: disk.Usage}
+}
+
+// Config returns the guard's thresholds
+func (g *Guard) Config() Config {
+	return g.config
+}
+
+// Check reads the free space on the filesystem holding path. It returns an error wrapping
+// ErrInsufficientSpace, along with the usage, when free space is below the minimum.
+func (g *Guard) Check(path string) (*Usage, error) {
+	stat, err := g.usage(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read disk usage for %s: %w", path, err)
+	}
+
+	usage := &Usage{
+		Path:        path,
+		FreeBytes:   stat.Free,
+		TotalBytes:  stat.Total,
+		UsedPercent: stat.UsedPercent,
+		Low:         g.config.WarnFreeBytes > 0 && stat.Free < g.config.WarnFreeBytes,
+	}
+	if g.config.MinFreeBytes > 0 && stat.Free < g.config.MinFreeBytes {
+		return usage, fmt.Errorf("%w: %s has %s free, below the %s minimum",
+			ErrInsufficientSpace, path, FormatBytes(stat.Free), FormatBytes(g.config.MinFreeBytes))
+	}
+	return usage, nil
+}
+
+// FormatBytes renders a byte count with a binary unit, e.g. "1.5 GiB"
+func FormatBytes(b uint64) string {
+	const unit = 1024
+	if b < unit {
+		return fmt.Sprintf("%d B", b)
+	}
+	div, exp := uint64(unit), 0
+	for n := b / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(b)/float64(div), "KMGTPE"[exp])
+}
+
+// megabytesFromEnv parses key as a whole number of MiB; 0 is allowed and disables the check
+func megabytesFromEnv(key string, fallback uint64) uint64 {
+	v := os.Getenv(key)
+	if v == "" {
+		return fallback
+	}
+	n, err := strconv.ParseUint(v, 10, 64)
+	if err != nil {
+		return fallback
+	}
+	return n * mib
+}
@@ -0,0 +1,84 @@
+package diskguard
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/shirou/gopsutil/v3/disk"
+)
+
+func guardWithFree(cfg Config, free uint64) *Guard {
+	g := New(cfg)
+	g.usage = func(path string) (*disk.UsageStat, error) {
+		return &disk.UsageStat{Path: path, Free: free, Total: 100 * 1024 * mib}, nil
+	}
+	return g
+}
+
+func TestCheck(t *testing.T) {
+	cfg := Config{MinFreeBytes: 1024 * mib, WarnFreeBytes: 5 * 1024 * mib}
+
+	tests := []struct {
+		name        string
+		free        uint64
+		wantLow     bool
+		wantRefused bool
+	}{
+		{"plenty of space", 50 * 1024 * mib, false, false},
+		{"below warning", 2 * 1024 * mib, true, false},
+		{"below minimum", 512 * mib, true, true},
+	}
+	for _, tt := range tests {
+		usage, err := guardWithFree(cfg, tt.free).Check("/data")
+		if refused := errors.Is(err, ErrInsufficientSpace); refused != tt.wantRefused {
+			t.Errorf("%s: refused = %v, want %v (err: %v)", tt.name, refused, tt.wantRefused, err)
+		}
+		if usage == nil || usage.Low != tt.wantLow {
+			t.Errorf("%s: unexpected usage %+v", tt.name, usage)
+		}
+	}
+}
+
+func TestCheck_ZeroThresholdsDisable(t *testing.T) {
+	usage, err := guardWithFree(Config{}, 1).Check("/data")
+	if err != nil || usage.Low {
+		t.Errorf("Expected no refusal or warning with zero thresholds, got usage=%+v err=%v", usage, err)
+	}
+}
+
+func TestCheck_UsageError(t *testing.T) {
+	g := New(Default())
+	g.usage = func(path string) (*disk.UsageStat, error) { return nil, errors.New("no such file") }
+
+	usage, err := g.Check("/missing")
+	if err == nil || errors.Is(err, ErrInsufficientSpace) || usage != nil {
+		t.Errorf("Expected a plain read error, got usage=%+v err=%v", usage, err)
+	}
+}
+
+func TestLoadFromEnv(t *testing.T) {
+	t.Setenv("DISK_MIN_FREE_MB", "0")
+	t.Setenv("DISK_WARN_FREE_MB", "invalid")
+
+	cfg := LoadFromEnv()
+	if cfg.MinFreeBytes != 0 {
+		t.Errorf("Expected DISK_MIN_FREE_MB=0 to disable the minimum, got %d", cfg.MinFreeBytes)
+	}
+	if cfg.WarnFreeBytes != Default().WarnFreeBytes {
+		t.Errorf("Expected invalid DISK_WARN_FREE_MB to keep the default, got %d", cfg.WarnFreeBytes)
+	}
+}
+
+func TestFormatBytes(t *testing.T) {
+	tests := map[uint64]string{
+		512:              "512 B",
+		1536:             "1.5 KiB",
+		1024 * mib:       "1.0 GiB",
+		5*1024*mib + 512: "5.0 GiB",
+	}
+	for in, want := range tests {
+		if got := FormatBytes(in); got != want {
+			t.Errorf("FormatBytes(%d) = %q, want %q", in, got, want)
+		}
+	}
+}
@@ -1,7 +1,11 @@
 package docker
 
 import (
+	"context"
+	"io"
+	"os"
 	"os/exec"
+	"time"
 )
 
 // CommandExecutor defines the interface for executing system commands
@@ -13,6 +17,24 @@ type CommandExecutor interface {
 	ExecuteCommandInDir(dir, name string, args ...string) ([]byte, error)
 }
 
+// StreamingCommandExecutor is implemented by executors that can run long-lived commands whose
+// output is read while they run, such as `docker stats` without --no-stream
+type StreamingCommandExecutor interface {
+	// StreamCommand starts a command and returns its stdout. Closing it, or cancelling ctx, stops
+	// the command.
+	StreamCommand(ctx context.Context, name string, args ...string) (io.ReadCloser, error)
+}
+
+// ContextCommandExecutor is implemented by executors that can stop a command when its context is
+// done, for commands as long-running as whatever the user asked for
+type ContextCommandExecutor interface {
+	// ExecuteCommandInDirContext executes a command in a specific directory until ctx is done
+	ExecuteCommandInDirContext(ctx context.Context, dir, name string, args ...string) ([]byte, error)
+}
+
+// commandStopGrace is how long a command interrupted by its context has to exit before it is killed
+const commandStopGrace = 10 * time.Second
+
 // RealCommandExecutor is the production implementation that actually executes commands
 type RealCommandExecutor struct{}
 
@@ -33,3 +55,40 @@ func (r *RealCommandExecutor) ExecuteCommandInDir(dir, name string, args ...stri
 	cmd.Dir = dir
 	return cmd.CombinedOutput()
 }
+
+// ExecuteCommandInDirContext executes a command in a specific directory. When ctx is done the
+// command is interrupted, so docker compose can stop the container it started, and killed if it
+// hasn't exited after commandStopGrace.
+func (r *RealCommandExecutor) ExecuteCommandInDirContext(ctx context.Context, dir, name string, args ...string) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Dir = dir
+	cmd.Cancel = func() error { return cmd.Process.Signal(os.Interrupt) }
+	cmd.WaitDelay = commandStopGrace
+	return cmd.CombinedOutput()
+}
+
+// StreamCommand starts a command and returns its stdout as it is written
+func (r *RealCommandExecutor) StreamCommand(ctx context.Context, name string, args ...string) (io.ReadCloser, error) {
+	cmd := exec.CommandContext(ctx, name, args...)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+	return &commandStream{ReadCloser: stdout, cmd: cmd}, nil
+}
+
+// commandStream is a running command's stdout; closing it stops the command
+type commandStream struct {
+	io.ReadCloser
+	cmd *exec.Cmd
+}
+
+func (s *commandStream) Close() error {
+	_ = s.cmd.Process.Kill()
+	_ = s.ReadCloser.Close()
+	_ = s.cmd.Wait()
+	return nil
+}
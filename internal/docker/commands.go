@@ -10,6 +10,24 @@ const (
 	ComposeCommand  = "compose"
 	ComposeFileFlag = "-f"
 	ComposeFileName = "docker-compose.yml"
+
+	// ComposeBackupFileName keeps the compose file as it was before the last write
+	ComposeBackupFileName = ComposeFileName + ".bak"
+
+	// ComposeOverrideFileName is the optional per-app override merged on top of ComposeFileName
+	ComposeOverrideFileName = "docker-compose.override.yml"
+	// ComposeArchFileName is generated from the per-architecture images in ComposeFileName, layered
+	// right after it so the tunnel sidecar and the user override still apply on top
+	ComposeArchFileName = "docker-compose.arch.yml"
+	// ComposeTunnelFileName is the generated tunnel sidecar, layered between the base file and the user override
+	ComposeTunnelFileName = "docker-compose.tunnel.yml"
+	// ComposeTunnelStandbyFileName holds a second tunnel sidecar that runs next to the current one
+	// while an app's tunnel is being switched
+	ComposeTunnelStandbyFileName = "docker-compose.tunnel-standby.yml"
+	// ComposeLoggingFileName is the generated log forwarding sidecar, layered after the tunnel file
+	ComposeLoggingFileName = "docker-compose.logging.yml"
+	// VectorConfigFileName is the log forwarding sidecar's config, mounted into it
+	VectorConfigFileName = "vector.yaml"
 )
 
 // Docker Compose subcommands
@@ -23,6 +41,8 @@ const (
 	ComposeSubcommandPs      = "ps"
 	ComposeSubcommandLogs    = "logs"
 	ComposeSubcommandConfig  = "config"
+	ComposeSubcommandRun     = "run"
+	ComposeSubcommandExec    = "exec"
 )
 
 // Docker Compose flags
@@ -33,12 +53,19 @@ const (
 	ComposeFlagForceRecreate   = "--force-recreate"
 	ComposeFlagIgnoreBuildable = "--ignore-buildable"
 	ComposeFlagTail            = "--tail"
+	ComposeFlagSince           = "--since"
+	ComposeFlagTimestamps      = "--timestamps"
+	ComposeFlagNoColor         = "--no-color"
+	ComposeFlagRemove          = "--rm"
+	ComposeFlagNoTTY           = "-T"
+	ComposeFlagName            = "--name"
 )
 
 // Docker Compose service names
 const (
-	ServiceTunnel      = "tunnel"
-	ServiceCloudflared = "cloudflared"
+	ServiceTunnel        = "tunnel"
+	ServiceTunnelStandby = "tunnel-standby"
+	ServiceCloudflared   = "cloudflared"
 )
 
 // Docker command parts (for direct docker commands, not compose)
@@ -54,6 +81,7 @@ type ComposeCommandBuilder struct {
 	subcommand string
 	flags      []string
 	services   []string
+	args       []string
 }
 
 // NewComposeCommand creates a new compose command builder
@@ -77,11 +105,18 @@ func (b *ComposeCommandBuilder) WithService(service string) *ComposeCommandBuild
 	return b
 }
 
+// WithArgs adds arguments that follow the service names, such as the command for run and exec
+func (b *ComposeCommandBuilder) WithArgs(args ...string) *ComposeCommandBuilder {
+	b.args = append(b.args, args...)
+	return b
+}
+
 // Build returns the command as a slice of strings ready for ExecuteCommandInDir
 func (b *ComposeCommandBuilder) Build() []string {
 	cmd := []string{DockerCommand, ComposeCommand, ComposeFileFlag, ComposeFileName, b.subcommand}
 	cmd = append(cmd, b.flags...)
 	cmd = append(cmd, b.services...)
+	cmd = append(cmd, b.args...)
 	return cmd
 }
 
@@ -145,6 +180,32 @@ func ComposeLogsCommand(tailLines int, service string) []string {
 	return builder.Build()
 }
 
+// ComposeLogsTailCommand returns command for
+// "docker compose -f docker-compose.yml logs --no-color --tail=N [service]"
+func ComposeLogsTailCommand(tailLines int, service string) []string {
+	builder := NewComposeCommand(ComposeSubcommandLogs).
+		WithFlag(ComposeFlagNoColor).
+		WithFlag(ComposeFlagTail + "=" + fmt.Sprintf("%d", tailLines))
+	if service != "" {
+		builder = builder.WithService(service)
+	}
+	return builder.Build()
+}
+
+// ComposeLogsSinceCommand returns command for
+// "docker compose -f docker-compose.yml logs --no-color --timestamps --tail=N [--since=X]"
+// If since is empty, only the tail limit applies
+func ComposeLogsSinceCommand(tailLines int, since string) []string {
+	builder := NewComposeCommand(ComposeSubcommandLogs).
+		WithFlag(ComposeFlagNoColor).
+		WithFlag(ComposeFlagTimestamps).
+		WithFlag(ComposeFlagTail + "=" + fmt.Sprintf("%d", tailLines))
+	if since != "" {
+		builder = builder.WithFlag(ComposeFlagSince + "=" + since)
+	}
+	return builder.Build()
+}
+
 // ComposeConfigServicesCommand returns command for "docker compose -f docker-compose.yml config --services"
 func ComposeConfigServicesCommand() []string {
 	return NewComposeCommand(ComposeSubcommandConfig).
@@ -152,6 +213,13 @@ func ComposeConfigServicesCommand() []string {
 		Build()
 }
 
+// ComposeConfigImagesCommand returns command for "docker compose -f docker-compose.yml config --images"
+func ComposeConfigImagesCommand() []string {
+	return NewComposeCommand(ComposeSubcommandConfig).
+		WithFlag("--images").
+		Build()
+}
+
 // ComposeRestartServiceCommand returns command for "docker compose -f docker-compose.yml restart <service>"
 func ComposeRestartServiceCommand(service string) []string {
 	return NewComposeCommand(ComposeSubcommandRestart).
@@ -177,6 +245,14 @@ func ComposeRemoveServiceCommand(service string) []string {
 		Build()
 }
 
+// ComposeUpServiceCommand returns command for "docker compose -f docker-compose.yml up -d <service>"
+func ComposeUpServiceCommand(service string) []string {
+	return NewComposeCommand(ComposeSubcommandUp).
+		WithFlag(ComposeFlagDetached).
+		WithService(service).
+		Build()
+}
+
 // ComposeForceRecreateServiceCommand returns command for "docker compose -f docker-compose.yml up -d --force-recreate <service>"
 func ComposeForceRecreateServiceCommand(service string) []string {
 	return NewComposeCommand(ComposeSubcommandUp).
@@ -186,6 +262,27 @@ func ComposeForceRecreateServiceCommand(service string) []string {
 		Build()
 }
 
+// ComposeRunCommand returns command for "docker compose -f docker-compose.yml run --rm -T --name <containerName> <service> <command>..."
+func ComposeRunCommand(containerName, service string, command []string) []string {
+	return NewComposeCommand(ComposeSubcommandRun).
+		WithFlag(ComposeFlagRemove).
+		WithFlag(ComposeFlagNoTTY).
+		WithFlag(ComposeFlagName).
+		WithFlag(containerName).
+		WithService(service).
+		WithArgs(command...).
+		Build()
+}
+
+// ComposeExecCommand returns command for "docker compose -f docker-compose.yml exec -T <service> <command>..."
+func ComposeExecCommand(service string, command []string) []string {
+	return NewComposeCommand(ComposeSubcommandExec).
+		WithFlag(ComposeFlagNoTTY).
+		WithService(service).
+		WithArgs(command...).
+		Build()
+}
+
 // Direct Docker commands (not compose)
 
 // DockerRestartCommand returns command for "docker restart <containerID>"
@@ -202,3 +299,70 @@ func DockerStopCommand(containerID string) []string {
 func DockerRmCommand(containerID string) []string {
 	return []string{DockerCommand, DockerSubcommandRm, DockerFlagForce, containerID}
 }
+
+// DockerManifestInspectCommand returns command for "docker manifest inspect <image>", which reads
+// the image's manifest, with the platforms of a multi-arch image, from its registry
+func DockerManifestInspectCommand(image string) []string {
+	return []string{DockerCommand, "manifest", "inspect", image}
+}
+
+// DockerImageRepoDigestCommand returns command for "docker image inspect --format {{index .RepoDigests 0}} <image>"
+func DockerImageRepoDigestCommand(image string) []string {
+	return []string{DockerCommand, "image", "inspect", "--format", "{{index .RepoDigests 0}}", image}
+}
+
+// DockerServerVersionCommand returns command for "docker version --format {{.Server.Version}}", which
+// fails when the daemon is unreachable
+func DockerServerVersionCommand() []string {
+	return []string{DockerCommand, "version", "--format", "{{.Server.Version}}"}
+}
+
+// DockerRootDirCommand returns command for "docker info --format {{.DockerRootDir}}", where the
+// daemon stores images and volumes
+func DockerRootDirCommand() []string {
+	return []string{DockerCommand, "info", "--format", "{{.DockerRootDir}}"}
+}
+
+// composeProjectFilter returns the --filter value matching resources compose created for project
+func composeProjectFilter(project string) string {
+	return "label=com.docker.compose.project=" + project
+}
+
+// DockerProjectContainersCommand returns command for
+// "docker ps -a --filter label=com.docker.compose.project=<project> --format {{.Names}}"
+func DockerProjectContainersCommand(project string) []string {
+	return []string{DockerCommand, "ps", "-a", "--filter", composeProjectFilter(project), "--format", "{{.Names}}"}
+}
+
+// DockerProjectNetworksCommand returns command for
+// "docker network ls --filter label=com.docker.compose.project=<project> --format {{.Name}}"
+func DockerProjectNetworksCommand(project string) []string {
+	return []string{DockerCommand, "network", "ls", "--filter", composeProjectFilter(project), "--format", "{{.Name}}"}
+}
+
+// DockerProjectVolumesCommand returns command for
+// "docker volume ls -q --filter label=com.docker.compose.project=<project>"
+func DockerProjectVolumesCommand(project string) []string {
+	return []string{DockerCommand, "volume", "ls", "-q", "--filter", composeProjectFilter(project)}
+}
+
+// DockerInspectCommand returns command for "docker inspect <container>..."
+func DockerInspectCommand(containers ...string) []string {
+	return append([]string{DockerCommand, "inspect"}, containers...)
+}
+
+// DockerNetworkContainersCommand returns command for
+// "docker network inspect --format {{range .Containers}}{{println .Name}}{{end}} <network>"
+func DockerNetworkContainersCommand(network string) []string {
+	return []string{DockerCommand, "network", "inspect", "--format", "{{range .Containers}}{{println .Name}}{{end}}", network}
+}
+
+// DockerNetworkRmCommand returns command for "docker network rm <network>..."
+func DockerNetworkRmCommand(networks ...string) []string {
+	return append([]string{DockerCommand, "network", DockerSubcommandRm}, networks...)
+}
+
+// DockerVolumeRmCommand returns command for "docker volume rm <volume>..."
+func DockerVolumeRmCommand(volumes ...string) []string {
+	return append([]string{DockerCommand, "volume", DockerSubcommandRm}, volumes...)
+}
@@ -3,8 +3,10 @@ package docker
 import (
 	"context"
 	"fmt"
+	"os"
 	"os/exec"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 
@@ -41,12 +43,17 @@ func (e *ComposeParseError) Unwrap() error {
 	return e.OriginalErr
 }
 
+// defaultNetworkName is the implicit network compose creates for services without explicit networks
+const defaultNetworkName = "default"
+
 // ComposeFile represents a docker-compose.yml structure
 type ComposeFile struct {
 	Version  string             `yaml:"version,omitempty"`
 	Services map[string]Service `yaml:"services"`
 	Networks map[string]Network `yaml:"networks,omitempty"`
 	Volumes  map[string]Volume  `yaml:"volumes,omitempty"`
+	// Extensions are the top-level x-* fields, kept for tools that read them from the compose file
+	Extensions map[string]interface{} `yaml:",inline"`
 }
 
 // DependsOnConfig represents a dependency configuration for a service
@@ -78,6 +85,7 @@ type Service struct {
 	Image            string                 `yaml:"image"`
 	ContainerName    string                 `yaml:"container_name,omitempty"`
 	Command          string                 `yaml:"command,omitempty"`
+	Entrypoint       []string               `yaml:"entrypoint,omitempty"`
 	Build            BuildConfig            `yaml:"build,omitempty"`
 	Environment      map[string]string      `yaml:"environment,omitempty"`
 	EnvironmentFiles []string               `yaml:"env_file,omitempty"`
@@ -110,17 +118,92 @@ type Service struct {
 	CapDrop          []string               `yaml:"cap_drop,omitempty"`
 	SecurityOpt      []string               `yaml:"security_opt,omitempty"`
 	CgroupParent     string                 `yaml:"cgroup_parent,omitempty"`
+	// NetworkSettings holds the service's options on those of its Networks that have any, e.g. a
+	// static address; with any set, networks are written in the long syntax
+	NetworkSettings map[string]ServiceNetwork `yaml:"-"`
+	// Extensions are the service's x-* fields, e.g. the labels-like config watchtower and diun read
+	Extensions map[string]interface{} `yaml:",inline"`
+}
+
+// ServiceNetwork is a service's options on one of its networks
+type ServiceNetwork struct {
+	Aliases     []string `yaml:"aliases,omitempty"`
+	IPv4Address string   `yaml:"ipv4_address,omitempty"`
+	IPv6Address string   `yaml:"ipv6_address,omitempty"`
+}
+
+// MarshalYAML writes the service's networks as a map of their settings when it has any, since the
+// short syntax can only name them
+func (s Service) MarshalYAML() (interface{}, error) {
+	type plain Service
+	if len(s.NetworkSettings) == 0 {
+		return plain(s), nil
+	}
+
+	networks := make(map[string]ServiceNetwork, len(s.Networks))
+	for _, name := range s.Networks {
+		networks[name] = s.NetworkSettings[name]
+	}
+	var node, networksNode yaml.Node
+	if err := node.Encode(plain(s)); err != nil {
+		return nil, err
+	}
+	if err := networksNode.Encode(networks); err != nil {
+		return nil, err
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == "networks" {
+			node.Content[i+1] = &networksNode
+		}
+	}
+	return &node, nil
 }
 
 // Network represents a docker-compose network
 type Network struct {
-	Name     string `yaml:"name,omitempty"`
-	Driver   string `yaml:"driver,omitempty"`
-	External bool   `yaml:"external,omitempty"`
+	Name       string            `yaml:"name,omitempty"`
+	Driver     string            `yaml:"driver,omitempty"`
+	DriverOpts map[string]string `yaml:"driver_opts,omitempty"`
+	External   bool              `yaml:"external,omitempty"`
+	Internal   bool              `yaml:"internal,omitempty"`
+	Attachable bool              `yaml:"attachable,omitempty"`
+	EnableIPv6 bool              `yaml:"enable_ipv6,omitempty"`
+	IPAM       *NetworkIPAM      `yaml:"ipam,omitempty"`
+}
+
+// NetworkIPAM is a network's address management, e.g. the custom subnet static addresses need
+type NetworkIPAM struct {
+	Driver string     `yaml:"driver,omitempty"`
+	Config []IPAMPool `yaml:"config,omitempty"`
+}
+
+// IPAMPool is one address range of a network
+type IPAMPool struct {
+	Subnet       string            `yaml:"subnet,omitempty"`
+	Gateway      string            `yaml:"gateway,omitempty"`
+	IPRange      string            `yaml:"ip_range,omitempty"`
+	AuxAddresses map[string]string `yaml:"aux_addresses,omitempty"`
+}
+
+// Subnets returns the subnets of the network's custom address pools
+func (n Network) Subnets() []string {
+	if n.IPAM == nil {
+		return nil
+	}
+	var subnets []string
+	for _, pool := range n.IPAM.Config {
+		if pool.Subnet != "" {
+			subnets = append(subnets, pool.Subnet)
+		}
+	}
+	return subnets
 }
 
 // Volume represents a docker-compose volume
-type Volume struct{}
+type Volume struct {
+	Driver     string            `yaml:"driver,omitempty"`
+	DriverOpts map[string]string `yaml:"driver_opts,omitempty"`
+}
 
 // BuildConfig represents a docker-compose build configuration
 type BuildConfig struct {
@@ -278,21 +361,77 @@ func extractLineNumberFromError(err error) (line, column int) {
 // ParseCompose parses and validates docker-compose YAML content using the official compose-go library.
 // This handles all Docker Compose formats (list vs map for environment, depends_on, build.args, etc.)
 func ParseCompose(content []byte) (*ComposeFile, error) {
+	return parseComposeFiles(nil, composetypes.ConfigFile{Content: content})
+}
+
+// ParseComposeWithFiles parses compose content whose include: and extends: file: references
+// resolve to the app's extra compose files, keyed by path relative to the app directory
+func ParseComposeWithFiles(content []byte, files map[string]string) (*ComposeFile, error) {
+	return parseComposeFiles(files, composetypes.ConfigFile{Filename: ComposeFileName, Content: content})
+}
+
+// ParseComposeWithOverride parses a base compose file merged with an override file,
+// the same way "docker compose -f docker-compose.yml -f docker-compose.override.yml" does.
+// files are the app's extra compose files, as for ParseComposeWithFiles.
+func ParseComposeWithOverride(base, override []byte, files map[string]string) (*ComposeFile, error) {
+	return parseComposeFiles(files,
+		composetypes.ConfigFile{Filename: ComposeFileName, Content: base},
+		composetypes.ConfigFile{Filename: ComposeOverrideFileName, Content: override},
+	)
+}
+
+// parseComposeFiles loads and merges one or more compose files in order
+func parseComposeFiles(extra map[string]string, files ...composetypes.ConfigFile) (*ComposeFile, error) {
+	project, err := loadComposeProject(extra, files...)
+	if err != nil {
+		return nil, err
+	}
+
+	// Convert compose-go types to our internal types
+	return convertProject(project), nil
+}
+
+// loadComposeProject loads and merges compose files with compose-go, keeping ${VAR} references
+// as-is. include: and extends: file: may only reference the extra files. It fails when the result
+// defines no services.
+func loadComposeProject(extra map[string]string, files ...composetypes.ConfigFile) (*composetypes.Project, error) {
 	// First, quick YAML syntax check to give better errors
-	var raw map[string]interface{}
-	if err := yaml.Unmarshal(content, &raw); err != nil {
-		return nil, enhanceComposeGoError(err, content)
+	for _, f := range files {
+		var raw map[string]interface{}
+		if err := yaml.Unmarshal(f.Content, &raw); err != nil {
+			return nil, enhanceComposeGoError(err, f.Content)
+		}
+	}
+	for _, name := range ComposeFileNames(extra) {
+		var raw map[string]interface{}
+		if err := yaml.Unmarshal([]byte(extra[name]), &raw); err != nil {
+			parseErr := enhanceComposeGoError(err, []byte(extra[name]))
+			parseErr.Message = name + ": " + parseErr.Message
+			return nil, parseErr
+		}
+	}
+	if err := checkComposeReferences(extra, files...); err != nil {
+		return nil, err
 	}
 
 	// Use compose-go to parse the content
 	config := composetypes.ConfigDetails{
-		ConfigFiles: []composetypes.ConfigFile{
-			{Content: content},
-		},
+		ConfigFiles: files,
 		// Empty environment map - we don't interpolate variables at parse time
 		// Docker resolves ${VAR} at container runtime
 		Environment: composetypes.Mapping{},
 	}
+	if len(extra) > 0 {
+		workDir, err := os.MkdirTemp("", "selfhostly-compose-")
+		if err != nil {
+			return nil, fmt.Errorf("failed to stage compose files: %w", err)
+		}
+		defer os.RemoveAll(workDir)
+		if err := stageComposeFiles(workDir, extra); err != nil {
+			return nil, err
+		}
+		config.WorkingDir = workDir
+	}
 
 	// Load with skip interpolation (keep ${VAR} as-is) and skip validation
 	// (we do our own validation downstream). We keep normalization enabled
@@ -304,7 +443,7 @@ func ParseCompose(content []byte) (*ComposeFile, error) {
 
 	project, err := loader.LoadWithContext(context.Background(), config, opts)
 	if err != nil {
-		return nil, enhanceComposeGoError(err, content)
+		return nil, enhanceComposeGoError(err, files[len(files)-1].Content)
 	}
 
 	if project == nil || len(project.Services) == 0 {
@@ -313,9 +452,10 @@ func ParseCompose(content []byte) (*ComposeFile, error) {
 			Suggestion: "Add at least one service under the 'services:' section",
 		}
 	}
-
-	// Convert compose-go types to our internal types
-	return convertProject(project), nil
+	if config.WorkingDir != "" {
+		rebaseProjectPaths(project, config.WorkingDir, "")
+	}
+	return project, nil
 }
 
 // convertProject converts a compose-go Project to our ComposeFile type
@@ -337,13 +477,30 @@ func convertProject(project *composetypes.Project) *ComposeFile {
 	}
 
 	// Convert volumes
-	for name := range project.Volumes {
-		compose.Volumes[name] = Volume{}
+	for name, vol := range project.Volumes {
+		compose.Volumes[name] = Volume{Driver: vol.Driver, DriverOpts: vol.DriverOpts}
 	}
 
+	compose.Extensions = convertExtensions(project.Extensions)
+
 	return compose
 }
 
+// convertExtensions keeps the x-* extension fields compose-go collected
+func convertExtensions(extensions composetypes.Extensions) map[string]interface{} {
+	var result map[string]interface{}
+	for key, value := range extensions {
+		if !strings.HasPrefix(key, "x-") {
+			continue
+		}
+		if result == nil {
+			result = make(map[string]interface{})
+		}
+		result[key] = value
+	}
+	return result
+}
+
 // convertService converts a compose-go ServiceConfig to our Service type
 func convertService(svc composetypes.ServiceConfig) Service {
 	service := Service{
@@ -365,6 +522,8 @@ func convertService(svc composetypes.ServiceConfig) Service {
 		CapDrop:        svc.CapDrop,
 		SecurityOpt:    svc.SecurityOpt,
 		CgroupParent:   svc.CgroupParent,
+		Entrypoint:     svc.Entrypoint,
+		Extensions:     convertExtensions(svc.Extensions),
 	}
 
 	// Command ([]string → joined string)
@@ -399,10 +558,26 @@ func convertService(svc composetypes.ServiceConfig) Service {
 		service.Volumes = append(service.Volumes, convertVolume(v))
 	}
 
-	// Networks (map[string]*ServiceNetworkConfig → []string)
-	for networkName := range svc.Networks {
+	// Networks (map[string]*ServiceNetworkConfig → []string plus the settings of those with any)
+	for networkName, networkConfig := range svc.Networks {
 		service.Networks = append(service.Networks, networkName)
+		if networkConfig == nil {
+			continue
+		}
+		settings := ServiceNetwork{
+			Aliases:     networkConfig.Aliases,
+			IPv4Address: networkConfig.Ipv4Address,
+			IPv6Address: networkConfig.Ipv6Address,
+		}
+		if len(settings.Aliases) == 0 && settings.IPv4Address == "" && settings.IPv6Address == "" {
+			continue
+		}
+		if service.NetworkSettings == nil {
+			service.NetworkSettings = make(map[string]ServiceNetwork)
+		}
+		service.NetworkSettings[networkName] = settings
 	}
+	sort.Strings(service.Networks)
 
 	// DependsOn (compose-go DependsOnConfig → our DependsOnConfig)
 	if len(svc.DependsOn) > 0 {
@@ -576,11 +751,30 @@ func convertHealthcheck(hc composetypes.HealthCheckConfig) HealthcheckConfig {
 
 // convertNetwork converts a compose-go NetworkConfig to our Network type
 func convertNetwork(net composetypes.NetworkConfig) Network {
-	return Network{
-		Name:     net.Name,
-		Driver:   net.Driver,
-		External: bool(net.External),
+	network := Network{
+		Name:       net.Name,
+		Driver:     net.Driver,
+		DriverOpts: net.DriverOpts,
+		External:   bool(net.External),
+		Internal:   net.Internal,
+		Attachable: net.Attachable,
+		EnableIPv6: derefBool(net.EnableIPv6),
+	}
+	if net.Ipam.Driver != "" || len(net.Ipam.Config) > 0 {
+		network.IPAM = &NetworkIPAM{Driver: net.Ipam.Driver}
+		for _, pool := range net.Ipam.Config {
+			if pool == nil {
+				continue
+			}
+			network.IPAM.Config = append(network.IPAM.Config, IPAMPool{
+				Subnet:       pool.Subnet,
+				Gateway:      pool.Gateway,
+				IPRange:      pool.IPRange,
+				AuxAddresses: pool.AuxiliaryAddresses,
+			})
+		}
 	}
+	return network
 }
 
 // derefBool safely dereferences a *bool, returning false if nil
@@ -681,6 +875,117 @@ func InjectTunnelContainer(compose *ComposeFile, appName string, containerConfig
 		}
 	}
 
+	compose.Services[ServiceTunnel] = newTunnelService(appName, containerConfig, networks)
+	return true, nil
+}
+
+// BuildTunnelCompose generates the tunnel sidecar compose file for an app. It is layered on top of
+// the user's compose at deploy time (see ComposeTunnelFileName), so the stored compose never contains
+// provider details. The sidecar joins every network of the app, so it can reach any service, plus the
+// core API network so the primary can reach it. Returns "" if containerConfig is nil.
+func BuildTunnelCompose(compose *ComposeFile, appName string, containerConfig *tunnel.ContainerConfig) (string, error) {
+	if containerConfig == nil {
+		return "", nil
+	}
+
+	networks := append([]string(nil), containerConfig.Networks...)
+	if len(networks) == 0 {
+		networks = ExtractNetworks(compose)
+		sort.Strings(networks)
+		if len(networks) == 0 {
+			networks = []string{defaultNetworkName}
+		}
+	}
+
+	hasCoreAPINetwork := false
+	for _, n := range networks {
+		if n == constants.CoreAPINetwork {
+			hasCoreAPINetwork = true
+			break
+		}
+	}
+	if !hasCoreAPINetwork {
+		networks = append(networks, constants.CoreAPINetwork)
+	}
+
+	sidecar := &ComposeFile{
+		Services: map[string]Service{ServiceTunnel: newTunnelService(appName, containerConfig, networks)},
+		Networks: make(map[string]Network),
+	}
+	for _, n := range networks {
+		if n == defaultNetworkName {
+			// Implicit project network, created by compose for services without explicit networks
+			continue
+		}
+		if _, declared := compose.Networks[n]; declared {
+			// Already defined by the user's compose; an empty entry keeps this file loadable on its own
+			// and merges without changing the user's definition
+			sidecar.Networks[n] = Network{}
+			continue
+		}
+		if n == constants.CoreAPINetwork {
+			sidecar.Networks[n] = Network{Driver: "bridge", External: checkDockerNetworkExists(n)}
+			continue
+		}
+		sidecar.Networks[n] = Network{Driver: "bridge"}
+	}
+
+	data, err := MarshalComposeFile(sidecar)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal tunnel compose: %w", err)
+	}
+	return string(data), nil
+}
+
+// GenerateTunnelCompose builds the tunnel sidecar for an app's compose content. Apps created before the
+// sidecar had its own file carry the tunnel service inline; it is stripped so it can't shadow the
+// generated one, and the cleaned compose is returned. Otherwise composeContent is returned unchanged.
+// composeFiles are the app's extra compose files, so included services' networks are joined too.
+func GenerateTunnelCompose(composeContent string, composeFiles map[string]string, appName string, containerConfig *tunnel.ContainerConfig) (userCompose, tunnelCompose string, err error) {
+	compose, err := ParseComposeWithFiles([]byte(composeContent), composeFiles)
+	if err != nil {
+		return "", "", err
+	}
+
+	userCompose = composeContent
+	if RemoveTunnelService(compose) {
+		userCompose, _, err = StripTunnelService(composeContent)
+		if err != nil {
+			return "", "", err
+		}
+	}
+
+	tunnelCompose, err = BuildTunnelCompose(compose, appName, containerConfig)
+	if err != nil {
+		return "", "", err
+	}
+	return userCompose, tunnelCompose, nil
+}
+
+// StandbyTunnelCompose turns a tunnel sidecar compose (see BuildTunnelCompose) into one whose service
+// and container are named apart from the app's current tunnel, so both can run while the app's tunnel
+// is switched and the new one is confirmed connected before the old one is stopped
+func StandbyTunnelCompose(tunnelCompose, appName string) (string, error) {
+	sidecar, err := ParseCompose([]byte(tunnelCompose))
+	if err != nil {
+		return "", fmt.Errorf("failed to parse tunnel compose: %w", err)
+	}
+	service, ok := sidecar.Services[ServiceTunnel]
+	if !ok {
+		return "", fmt.Errorf("tunnel compose has no %s service", ServiceTunnel)
+	}
+	service.ContainerName = fmt.Sprintf("%s-%s", appName, ServiceTunnelStandby)
+	sidecar.Services = map[string]Service{ServiceTunnelStandby: service}
+
+	data, err := MarshalComposeFile(sidecar)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal standby tunnel compose: %w", err)
+	}
+	return string(data), nil
+}
+
+// newTunnelService builds the tunnel sidecar service from a provider's container config
+func newTunnelService(appName string, containerConfig *tunnel.ContainerConfig, networks []string) Service {
 	// Build command string from array
 	commandStr := ""
 	if len(containerConfig.Command) > 0 {
@@ -700,6 +1005,7 @@ func InjectTunnelContainer(compose *ComposeFile, appName string, containerConfig
 		Networks:      networks,
 		Environment:   env,
 		Command:       commandStr,
+		Entrypoint:    containerConfig.Entrypoint,
 	}
 
 	// Add volumes if specified
@@ -712,8 +1018,28 @@ func InjectTunnelContainer(compose *ComposeFile, appName string, containerConfig
 		tunnelService.Ports = containerConfig.Ports
 	}
 
-	compose.Services["tunnel"] = tunnelService
-	return true, nil
+	// Replicas can't share a container name; compose numbers them instead
+	if containerConfig.Replicas > 1 {
+		replicas := containerConfig.Replicas
+		tunnelService.ContainerName = ""
+		tunnelService.Deploy.Replicas = &replicas
+	}
+
+	return tunnelService
+}
+
+// TunnelReplicas returns how many copies of the tunnel container a tunnel sidecar compose runs,
+// 1 unless its tunnel service sets deploy.replicas
+func TunnelReplicas(tunnelCompose string) int {
+	project, err := loadComposeProject(nil, composetypes.ConfigFile{Content: []byte(tunnelCompose)})
+	if err != nil {
+		return 1
+	}
+	service, ok := project.Services[ServiceTunnel]
+	if !ok || service.Deploy == nil || service.Deploy.Replicas == nil || *service.Deploy.Replicas < 1 {
+		return 1
+	}
+	return *service.Deploy.Replicas
 }
 
 // RemoveTunnelService removes the tunnel service from the compose file (e.g. after tunnel deletion).
@@ -729,6 +1055,27 @@ func RemoveTunnelService(compose *ComposeFile) bool {
 	return true
 }
 
+// ExtractIconURL returns the icon URL a service of the compose content names with the
+// selfhostly.icon label, or "" when none does. Services are checked in name order.
+func ExtractIconURL(composeContent string, files map[string]string) string {
+	compose, err := ParseComposeWithFiles([]byte(composeContent), files)
+	if err != nil {
+		return ""
+	}
+	names := make([]string, 0, len(compose.Services))
+	for name := range compose.Services {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		value := strings.TrimSpace(compose.Services[name].Labels[constants.AppIconLabel])
+		if strings.HasPrefix(value, "http://") || strings.HasPrefix(value, "https://") {
+			return value
+		}
+	}
+	return ""
+}
+
 // ExtractQuickTunnelTargetFromCompose parses compose content and extracts the Quick Tunnel target
 // (service name and port) from the tunnel service's command (e.g. --url http://web:80).
 // Returns ("", 0, false) if not found. Used when updating an app to re-inject the Quick Tunnel container.
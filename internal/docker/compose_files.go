@@ -0,0 +1,237 @@
+package docker
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	composetypes "github.com/compose-spec/compose-go/v2/types"
+	"gopkg.in/yaml.v3"
+)
+
+// An app's compose can pull in more compose files with the top-level include: element and with
+// extends: file:. Those files are stored with the app, keyed by their path relative to the app
+// directory, and written next to docker-compose.yml. Parsing stages them in a temporary directory
+// so compose-go can resolve the references without reading anything else from disk.
+
+// composeReferences is the part of a compose file that points at other files
+type composeReferences struct {
+	Include  []interface{} `yaml:"include"`
+	Services map[string]struct {
+		Extends interface{} `yaml:"extends"`
+	} `yaml:"services"`
+}
+
+// checkComposeReferences makes sure every include: and extends: file: in files and in extra points
+// at one of the extra files. References are resolved relative to the referencing file, as docker
+// compose does.
+func checkComposeReferences(extra map[string]string, files ...composetypes.ConfigFile) error {
+	check := func(from, ref string) error {
+		target := path.Clean(path.Join(path.Dir(from), filepath.ToSlash(ref)))
+		if path.IsAbs(ref) || target == ".." || strings.HasPrefix(target, "../") {
+			return &ComposeParseError{
+				Message:    fmt.Sprintf("%s references %s, which is outside the app directory", from, ref),
+				Suggestion: "Reference compose files by a path relative to the app directory",
+			}
+		}
+		if _, ok := extra[target]; !ok {
+			return &ComposeParseError{
+				Message:    fmt.Sprintf("%s references %s, which is not one of the app's compose files", from, ref),
+				Suggestion: fmt.Sprintf("Add %s to the app's compose files", target),
+			}
+		}
+		return nil
+	}
+
+	sources := make(map[string]string, len(files)+len(extra))
+	for _, f := range files {
+		name := f.Filename
+		if name == "" {
+			name = ComposeFileName
+		}
+		sources[name] = string(f.Content)
+	}
+	for name, content := range extra {
+		sources[name] = content
+	}
+
+	for _, from := range ComposeFileNames(sources) {
+		// Syntax errors are reported by the loader
+		var refs composeReferences
+		if err := yaml.Unmarshal([]byte(sources[from]), &refs); err != nil {
+			continue
+		}
+		for _, entry := range refs.Include {
+			paths, err := includePaths(from, entry)
+			if err != nil {
+				return err
+			}
+			for _, p := range paths {
+				if err := check(from, p); err != nil {
+					return err
+				}
+			}
+		}
+		for name, svc := range refs.Services {
+			extends, ok := svc.Extends.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			if file, ok := extends["file"].(string); ok && file != "" {
+				if err := check(from, file); err != nil {
+					return fmt.Errorf("service %s: %w", name, err)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// includePaths returns the files one include: entry loads. Entries that would read other files
+// from the host (env_file, project_directory) are rejected.
+func includePaths(from string, entry interface{}) ([]string, error) {
+	switch v := entry.(type) {
+	case string:
+		return []string{v}, nil
+	case map[string]interface{}:
+		for _, key := range []string{"env_file", "project_directory"} {
+			if _, ok := v[key]; ok {
+				return nil, &ComposeParseError{
+					Message:    fmt.Sprintf("%s: include %s is not supported", from, key),
+					Suggestion: "Include compose files by path only",
+				}
+			}
+		}
+		switch p := v["path"].(type) {
+		case string:
+			return []string{p}, nil
+		case []interface{}:
+			var paths []string
+			for _, item := range p {
+				if s, ok := item.(string); ok {
+					paths = append(paths, s)
+				}
+			}
+			return paths, nil
+		}
+	}
+	return nil, &ComposeParseError{
+		Message:    fmt.Sprintf("%s: invalid include entry", from),
+		Suggestion: "List included files as paths, or as entries with a path key",
+	}
+}
+
+// stageComposeFiles writes files into dir, creating subdirectories as needed
+func stageComposeFiles(dir string, files map[string]string) error {
+	for name, content := range files {
+		filePath, err := composeFilePath(dir, name)
+		if err != nil {
+			return err
+		}
+		if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+			return fmt.Errorf("failed to create directory for %s: %w", name, err)
+		}
+		if err := writeFileAtomic(filePath, []byte(content), 0644); err != nil {
+			return fmt.Errorf("failed to write %s: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// composeFilePath returns where the compose file name goes in dir. Names that leave dir and paths
+// through a symlink are refused, so neither a crafted name nor a link left in the app directory
+// can make a write or removal land outside it.
+func composeFilePath(dir, name string) (string, error) {
+	clean := path.Clean(filepath.ToSlash(name))
+	if path.IsAbs(clean) || clean == "." || clean == ".." || strings.HasPrefix(clean, "../") {
+		return "", fmt.Errorf("compose file %s is outside the app directory", name)
+	}
+
+	current := dir
+	for _, part := range strings.Split(clean, "/") {
+		current = filepath.Join(current, part)
+		info, err := os.Lstat(current)
+		if os.IsNotExist(err) {
+			break // Nothing below it exists yet either
+		}
+		if err != nil {
+			return "", fmt.Errorf("failed to check %s: %w", name, err)
+		}
+		if info.Mode()&os.ModeSymlink != 0 {
+			return "", fmt.Errorf("compose file %s: %s is a symlink", name, current)
+		}
+	}
+	return filepath.Join(dir, filepath.FromSlash(clean)), nil
+}
+
+// rebaseProjectPaths moves the paths compose-go resolved inside the staging directory dir onto
+// base. With an empty base they become relative, the way paths of the main file are kept when
+// parsing, so they are validated as paths inside the app directory.
+func rebaseProjectPaths(project *composetypes.Project, dir, base string) {
+	rel := func(p string) string {
+		if p != dir && !strings.HasPrefix(p, dir+string(filepath.Separator)) {
+			return p
+		}
+		r, err := filepath.Rel(dir, p)
+		if err != nil {
+			return p
+		}
+		if base != "" {
+			return filepath.Join(base, r)
+		}
+		return filepath.ToSlash(r)
+	}
+
+	for name, svc := range project.Services {
+		for i, vol := range svc.Volumes {
+			if vol.Type == composetypes.VolumeTypeBind {
+				svc.Volumes[i].Source = rel(vol.Source)
+			}
+		}
+		if svc.Build != nil {
+			svc.Build.Context = rel(svc.Build.Context)
+		}
+		for i, envFile := range svc.EnvFiles {
+			svc.EnvFiles[i].Path = rel(envFile.Path)
+		}
+		project.Services[name] = svc
+	}
+}
+
+// ComposeFileNames returns the names of an app's extra compose files in a stable order
+func ComposeFileNames(files map[string]string) []string {
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// WriteComposeFiles writes an app's extra compose files into its directory and removes the ones
+// in previous that are no longer part of the app
+func (m *Manager) WriteComposeFiles(name string, files, previous map[string]string) error {
+	appPath := m.AppPath(name)
+	for stale := range previous {
+		if _, ok := files[stale]; ok {
+			continue
+		}
+		filePath, err := composeFilePath(appPath, stale)
+		if err != nil {
+			return err
+		}
+		if err := os.Remove(filePath); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove %s: %w", stale, err)
+		}
+	}
+	if len(files) == 0 {
+		return nil
+	}
+
+	slog.Info("writing compose files", "app", name, "files", ComposeFileNames(files))
+	return stageComposeFiles(appPath, files)
+}
@@ -0,0 +1,154 @@
+package docker
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseComposeWithFiles(t *testing.T) {
+	compose := `include:
+  - db/compose.yml
+services:
+  web:
+    extends:
+      file: common.yml
+      service: base
+    ports:
+      - "8080:80"
+`
+	files := map[string]string{
+		"db/compose.yml": "services:\n  db:\n    image: postgres:16\n    volumes:\n      - ./data:/var/lib/postgresql/data\n",
+		"common.yml":     "services:\n  base:\n    image: nginx:latest\n    volumes:\n      - ./html:/usr/share/nginx/html\n",
+	}
+
+	parsed, err := ParseComposeWithFiles([]byte(compose), files)
+	if err != nil {
+		t.Fatalf("ParseComposeWithFiles() error = %v", err)
+	}
+	web, ok := parsed.Services["web"]
+	if !ok || web.Image != "nginx:latest" {
+		t.Fatalf("expected web to extend base, got %+v", parsed.Services)
+	}
+	db, ok := parsed.Services["db"]
+	if !ok || db.Image != "postgres:16" {
+		t.Fatalf("expected db to be included, got %+v", parsed.Services)
+	}
+
+	// Paths resolved in the staging directory come back relative to the app directory
+	if got := db.Volumes[0]; !strings.HasPrefix(got, "db/data:") {
+		t.Errorf("included volume = %q, want it relative to the app directory", got)
+	}
+	if got := web.Volumes[0]; !strings.HasPrefix(got, "html:") {
+		t.Errorf("extended volume = %q, want it relative to the app directory", got)
+	}
+}
+
+func TestParseComposeWithFiles_References(t *testing.T) {
+	tests := []struct {
+		name    string
+		compose string
+		files   map[string]string
+		wantErr string
+	}{
+		{
+			name:    "missing include",
+			compose: "include:\n  - db.yml\nservices:\n  web:\n    image: nginx\n",
+			wantErr: "not one of the app's compose files",
+		},
+		{
+			name:    "include outside the app directory",
+			compose: "include:\n  - ../other/docker-compose.yml\nservices:\n  web:\n    image: nginx\n",
+			wantErr: "outside the app directory",
+		},
+		{
+			name:    "absolute extends file",
+			compose: "services:\n  web:\n    extends:\n      file: /etc/compose.yml\n      service: base\n",
+			wantErr: "outside the app directory",
+		},
+		{
+			name:    "include env_file",
+			compose: "include:\n  - path: db.yml\n    env_file: /etc/environment\nservices:\n  web:\n    image: nginx\n",
+			files:   map[string]string{"db.yml": "services:\n  db:\n    image: postgres\n"},
+			wantErr: "not supported",
+		},
+		{
+			name:    "nested reference resolved from the including file",
+			compose: "include:\n  - stack/compose.yml\n",
+			files: map[string]string{
+				"stack/compose.yml": "services:\n  web:\n    extends:\n      file: base.yml\n      service: base\n",
+				"base.yml":          "services:\n  base:\n    image: nginx\n",
+			},
+			wantErr: "stack/base.yml",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ParseComposeWithFiles([]byte(tt.compose), tt.files)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("ParseComposeWithFiles() error = %v, want it to mention %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestWriteComposeFiles(t *testing.T) {
+	appsDir := t.TempDir()
+	manager := NewManagerWithExecutor(appsDir, NewMockCommandExecutor())
+	appPath := filepath.Join(appsDir, "blog")
+
+	files := map[string]string{"db/compose.yml": "services: {}\n", "common.yml": "services: {}\n"}
+	if err := manager.WriteComposeFiles("blog", files, nil); err != nil {
+		t.Fatalf("WriteComposeFiles() error = %v", err)
+	}
+	for name := range files {
+		if _, err := os.Stat(filepath.Join(appPath, name)); err != nil {
+			t.Errorf("expected %s to be written: %v", name, err)
+		}
+	}
+
+	if err := manager.WriteComposeFiles("blog", map[string]string{"common.yml": "services: {}\n"}, files); err != nil {
+		t.Fatalf("WriteComposeFiles() error = %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(appPath, "db", "compose.yml")); !os.IsNotExist(err) {
+		t.Errorf("expected the dropped file to be removed, stat error = %v", err)
+	}
+}
+
+func TestWriteComposeFiles_Symlinks(t *testing.T) {
+	appsDir := t.TempDir()
+	outside := t.TempDir()
+	manager := NewManagerWithExecutor(appsDir, NewMockCommandExecutor())
+	appPath := filepath.Join(appsDir, "blog")
+	if err := os.MkdirAll(appPath, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(outside, filepath.Join(appPath, "linked")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(outside, "target.yml"), filepath.Join(appPath, "common.yml")); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"linked/compose.yml", "common.yml", "../escape.yml", "db/../../escape.yml"} {
+		if err := manager.WriteComposeFiles("blog", map[string]string{name: "services: {}\n"}, nil); err == nil {
+			t.Errorf("expected writing %s to be refused", name)
+		}
+	}
+	if err := manager.WriteComposeFiles("blog", nil, map[string]string{"linked/compose.yml": ""}); err == nil {
+		t.Error("expected removing a file through a symlink to be refused")
+	}
+
+	entries, err := os.ReadDir(outside)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("expected nothing to be written outside the app directory, got %d entries", len(entries))
+	}
+	if _, err := os.Stat(filepath.Join(appsDir, "escape.yml")); !os.IsNotExist(err) {
+		t.Errorf("expected nothing to be written next to the app directory, stat error = %v", err)
+	}
+}
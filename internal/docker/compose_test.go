@@ -3,6 +3,7 @@ package docker
 import (
 	"errors"
 	"fmt"
+	"slices"
 	"strings"
 	"testing"
 
@@ -745,6 +746,28 @@ services:
 	}
 }
 
+func TestExtractIconURL(t *testing.T) {
+	compose := `
+services:
+  worker:
+    image: example/worker
+    labels:
+      selfhostly.icon: not-a-url
+  web:
+    image: nginx:latest
+    labels:
+      selfhostly.icon: https://example.com/logo.png
+`
+	if got := ExtractIconURL(compose, nil); got != "https://example.com/logo.png" {
+		t.Errorf("ExtractIconURL() = %q, want https://example.com/logo.png", got)
+	}
+
+	noLabel := `services: { web: { image: nginx } }`
+	if got := ExtractIconURL(noLabel, nil); got != "" {
+		t.Errorf("ExtractIconURL(no label) = %q, want empty", got)
+	}
+}
+
 func TestExtractQuickTunnelMetricsHostPort(t *testing.T) {
 	composeWithPort := `
 services:
@@ -811,6 +834,201 @@ networks:
 	}
 }
 
+func TestGenerateTunnelCompose(t *testing.T) {
+	userCompose := `services:
+  web:
+    image: nginx:latest
+  db:
+    image: postgres:16
+    networks: [backend]
+networks:
+  backend:
+    external: true
+`
+	cleaned, tunnelCompose, err := GenerateTunnelCompose(userCompose, nil, "test-app", quickTunnelContainerConfig(2005))
+	if err != nil {
+		t.Fatalf("GenerateTunnelCompose: %v", err)
+	}
+	if cleaned != userCompose {
+		t.Errorf("user compose without inline tunnel should be returned unchanged, got:\n%s", cleaned)
+	}
+
+	// The sidecar must load on its own so tunnel metadata can be read from it
+	sidecar, err := ParseCompose([]byte(tunnelCompose))
+	if err != nil {
+		t.Fatalf("tunnel compose should parse on its own: %v\n%s", err, tunnelCompose)
+	}
+	if len(sidecar.Services) != 1 {
+		t.Errorf("tunnel compose should only define the tunnel service, got %v", sidecar.Services)
+	}
+	tunnelSvc := sidecar.Services[ServiceTunnel]
+	for _, want := range []string{"backend", "default", constants.CoreAPINetwork} {
+		found := false
+		for _, n := range tunnelSvc.Networks {
+			if n == want {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("tunnel should join network %q, got %v", want, tunnelSvc.Networks)
+		}
+	}
+	if port, ok := ExtractQuickTunnelMetricsHostPort(tunnelCompose); !ok || port != 2005 {
+		t.Errorf("ExtractQuickTunnelMetricsHostPort(tunnel compose) = %d, %v; want 2005, true", port, ok)
+	}
+
+	// Merged the way docker compose sees it, the user's network definition is preserved
+	merged, err := ParseComposeWithOverride([]byte(userCompose), []byte(tunnelCompose), nil)
+	if err != nil {
+		t.Fatalf("merged compose should parse: %v", err)
+	}
+	if !merged.Networks["backend"].External {
+		t.Error("user's external network definition should survive the merge")
+	}
+	if _, ok := merged.Services["web"]; !ok {
+		t.Error("user services should be present in merged compose")
+	}
+
+	// nil container config: nothing to generate
+	_, tunnelCompose, err = GenerateTunnelCompose(userCompose, nil, "test-app", nil)
+	if err != nil || tunnelCompose != "" {
+		t.Errorf("GenerateTunnelCompose(nil config) = %q, %v; want empty", tunnelCompose, err)
+	}
+}
+
+func TestStandbyTunnelCompose(t *testing.T) {
+	userCompose := `services:
+  web:
+    image: nginx:latest
+    networks: [backend]
+networks:
+  backend: {}
+`
+	_, tunnelCompose, err := GenerateTunnelCompose(userCompose, nil, "test-app", &tunnel.ContainerConfig{
+		Image:       "cloudflare/cloudflared:latest",
+		Command:     []string{"tunnel", "run"},
+		Environment: map[string]string{"TUNNEL_TOKEN": "named-token"},
+	})
+	if err != nil {
+		t.Fatalf("GenerateTunnelCompose: %v", err)
+	}
+	standbyCompose, err := StandbyTunnelCompose(tunnelCompose, "test-app")
+	if err != nil {
+		t.Fatalf("StandbyTunnelCompose: %v", err)
+	}
+
+	// Layered on top of the current sidecar, both tunnel containers run side by side
+	merged, err := ParseComposeWithOverride([]byte(tunnelCompose), []byte(standbyCompose), nil)
+	if err != nil {
+		t.Fatalf("standby compose should merge with the tunnel compose: %v\n%s", err, standbyCompose)
+	}
+	current, standby := merged.Services[ServiceTunnel], merged.Services[ServiceTunnelStandby]
+	if current.ContainerName != "test-app-tunnel" || standby.ContainerName != "test-app-tunnel-standby" {
+		t.Errorf("container names = %q, %q; want test-app-tunnel, test-app-tunnel-standby", current.ContainerName, standby.ContainerName)
+	}
+	if standby.Environment["TUNNEL_TOKEN"] != "named-token" {
+		t.Errorf("standby should run the new tunnel's token, got %v", standby.Environment)
+	}
+	if len(standby.Networks) != len(current.Networks) {
+		t.Errorf("standby should join the tunnel's networks %v, got %v", current.Networks, standby.Networks)
+	}
+
+	if _, err := StandbyTunnelCompose("services:\n  web:\n    image: nginx\n", "test-app"); err == nil {
+		t.Error("expected a compose without a tunnel service to be refused")
+	}
+}
+
+func TestGenerateTunnelCompose_Replicas(t *testing.T) {
+	userCompose := "services:\n  web:\n    image: nginx:latest\n"
+	config := &tunnel.ContainerConfig{
+		Image:       "cloudflare/cloudflared:latest",
+		Command:     []string{"tunnel", "run"},
+		Environment: map[string]string{"TUNNEL_TOKEN": "named-token"},
+	}
+	_, single, err := GenerateTunnelCompose(userCompose, nil, "test-app", config)
+	if err != nil {
+		t.Fatalf("GenerateTunnelCompose: %v", err)
+	}
+	if got := TunnelReplicas(single); got != 1 {
+		t.Errorf("TunnelReplicas of a single tunnel = %d, want 1", got)
+	}
+
+	config.Replicas = 3
+	_, replicated, err := GenerateTunnelCompose(userCompose, nil, "test-app", config)
+	if err != nil {
+		t.Fatalf("GenerateTunnelCompose: %v", err)
+	}
+	if got := TunnelReplicas(replicated); got != 3 {
+		t.Errorf("TunnelReplicas = %d, want 3\n%s", got, replicated)
+	}
+	if strings.Contains(replicated, "container_name") {
+		t.Errorf("replicated tunnel should leave container names to compose, got\n%s", replicated)
+	}
+
+	// The standby that covers a switch is always a single container
+	standbyCompose, err := StandbyTunnelCompose(replicated, "test-app")
+	if err != nil {
+		t.Fatalf("StandbyTunnelCompose: %v", err)
+	}
+	if strings.Contains(standbyCompose, "replicas") || !strings.Contains(standbyCompose, "container_name: test-app-tunnel-standby") {
+		t.Errorf("standby should be one named container, got\n%s", standbyCompose)
+	}
+}
+
+func TestGenerateTunnelCompose_Entrypoint(t *testing.T) {
+	userCompose := "services:\n  web:\n    image: nginx:latest\n"
+	config := &tunnel.ContainerConfig{
+		Image:       "fatedier/frpc:v0.61.0",
+		Entrypoint:  []string{"/bin/sh", "-c", "printf '%s' \"$$FRPC_CONFIG\" > /tmp/frpc.toml && exec frpc -c /tmp/frpc.toml"},
+		Environment: map[string]string{"FRPC_CONFIG": "serverAddr = \"vps.example.com\"\n"},
+	}
+	_, tunnelCompose, err := GenerateTunnelCompose(userCompose, nil, "test-app", config)
+	if err != nil {
+		t.Fatalf("GenerateTunnelCompose: %v", err)
+	}
+
+	// The entrypoint must survive the sidecar being parsed again, e.g. for a standby
+	standbyCompose, err := StandbyTunnelCompose(tunnelCompose, "test-app")
+	if err != nil {
+		t.Fatalf("StandbyTunnelCompose: %v", err)
+	}
+	standby, err := ParseCompose([]byte(standbyCompose))
+	if err != nil {
+		t.Fatalf("standby compose should parse: %v\n%s", err, standbyCompose)
+	}
+	var got []string
+	for _, svc := range standby.Services {
+		got = svc.Entrypoint
+	}
+	if !slices.Equal(got, config.Entrypoint) {
+		t.Errorf("expected entrypoint %q, got %q\n%s", config.Entrypoint, got, standbyCompose)
+	}
+}
+
+func TestGenerateTunnelComposeStripsInlineTunnel(t *testing.T) {
+	legacyCompose := `services:
+  web:
+    image: nginx:latest
+  tunnel:
+    image: cloudflare/cloudflared:latest
+    command: tunnel run
+`
+	cleaned, tunnelCompose, err := GenerateTunnelCompose(legacyCompose, nil, "test-app", cloudflaredLikeContainerConfig("token"))
+	if err != nil {
+		t.Fatalf("GenerateTunnelCompose: %v", err)
+	}
+	compose, err := ParseCompose([]byte(cleaned))
+	if err != nil {
+		t.Fatalf("cleaned compose should parse: %v", err)
+	}
+	if _, ok := compose.Services[ServiceTunnel]; ok {
+		t.Error("inline tunnel service should be stripped from the user's compose")
+	}
+	if !strings.Contains(tunnelCompose, "TUNNEL_TOKEN: token") {
+		t.Errorf("tunnel compose should carry the provider config, got:\n%s", tunnelCompose)
+	}
+}
+
 func TestMarshalComposeFile(t *testing.T) {
 	// Create a compose file
 	compose := &ComposeFile{
@@ -1047,3 +1265,85 @@ func TestInjectTunnelContainerPreservesExistingNetwork(t *testing.T) {
 		t.Errorf("Expected web service to keep [my-custom-network], got %v", webService.Networks)
 	}
 }
+
+func TestParseComposeKeepsExtensions(t *testing.T) {
+	content := `x-diun:
+  watch_repo: true
+services:
+  web:
+    image: nginx:latest
+    x-watchtower:
+      enable: true
+`
+	compose, err := ParseCompose([]byte(content))
+	if err != nil {
+		t.Fatalf("ParseCompose() error = %v", err)
+	}
+	if _, ok := compose.Extensions["x-diun"]; !ok {
+		t.Errorf("expected top-level x-diun to be kept, got %v", compose.Extensions)
+	}
+	if _, ok := compose.Services["web"].Extensions["x-watchtower"]; !ok {
+		t.Errorf("expected service x-watchtower to be kept, got %v", compose.Services["web"].Extensions)
+	}
+
+	data, err := MarshalComposeFile(compose)
+	if err != nil {
+		t.Fatalf("MarshalComposeFile() error = %v", err)
+	}
+	roundTripped, err := ParseCompose(data)
+	if err != nil {
+		t.Fatalf("marshaled compose does not parse: %v\n%s", err, data)
+	}
+	if _, ok := roundTripped.Extensions["x-diun"]; !ok {
+		t.Errorf("expected x-diun to survive the round trip:\n%s", data)
+	}
+	if _, ok := roundTripped.Services["web"].Extensions["x-watchtower"]; !ok {
+		t.Errorf("expected x-watchtower to survive the round trip:\n%s", data)
+	}
+}
+
+func TestParseComposeKeepsNetworkSettings(t *testing.T) {
+	content := `services:
+  dns:
+    image: pihole/pihole:latest
+    networks:
+      lan:
+        ipv4_address: 172.28.0.53
+        aliases: [pihole]
+      default:
+networks:
+  lan:
+    driver: bridge
+    ipam:
+      config:
+        - subnet: 172.28.0.0/24
+          gateway: 172.28.0.1
+`
+	compose, err := ParseCompose([]byte(content))
+	if err != nil {
+		t.Fatalf("ParseCompose() error = %v", err)
+	}
+	dns := compose.Services["dns"]
+	if len(dns.Networks) != 2 || dns.NetworkSettings["lan"].IPv4Address != "172.28.0.53" {
+		t.Fatalf("expected the static address to be kept, got %v %+v", dns.Networks, dns.NetworkSettings)
+	}
+	if subnets := compose.Networks["lan"].Subnets(); len(subnets) != 1 || subnets[0] != "172.28.0.0/24" {
+		t.Fatalf("expected the custom subnet to be kept, got %v", subnets)
+	}
+
+	data, err := MarshalComposeFile(compose)
+	if err != nil {
+		t.Fatalf("MarshalComposeFile() error = %v", err)
+	}
+	roundTripped, err := ParseCompose(data)
+	if err != nil {
+		t.Fatalf("marshaled compose does not parse: %v\n%s", err, data)
+	}
+	lan := roundTripped.Services["dns"].NetworkSettings["lan"]
+	if lan.IPv4Address != "172.28.0.53" || len(lan.Aliases) != 1 || lan.Aliases[0] != "pihole" {
+		t.Errorf("expected the network settings to survive the round trip:\n%s", data)
+	}
+	if network := roundTripped.Networks["lan"]; network.IPAM == nil || network.IPAM.Config[0].Gateway != "172.28.0.1" {
+		t.Errorf("expected the subnet to survive the round trip:\n%s", data)
+	}
+}
@@ -0,0 +1,157 @@
+package docker
+
+import (
+	"bytes"
+	"fmt"
+	"log/slog"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Compose content the user wrote is edited as a YAML node tree rather than re-marshaled from
+// ComposeFile, which would drop comments, x- extension blocks, anchors, aliases and merge keys and
+// expand every alias into a copy of its anchor.
+
+// StripTunnelService removes the inline tunnel service apps created before the sidecar had its own
+// file carry, leaving the rest of the document as written. Aliases of anchors defined inside the
+// removed service are expanded in place, with a warning, so the result stays valid YAML. Returns the
+// content unchanged with removed=false when there is no tunnel service.
+func StripTunnelService(composeContent string) (string, bool, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal([]byte(composeContent), &doc); err != nil {
+		return "", false, fmt.Errorf("failed to parse compose content: %w", err)
+	}
+	if len(doc.Content) == 0 {
+		return composeContent, false, nil
+	}
+	services := resolveAlias(mappingValue(doc.Content[0], "services"))
+	if services == nil || services.Kind != yaml.MappingNode {
+		return composeContent, false, nil
+	}
+
+	index := -1
+	for i := 0; i+1 < len(services.Content); i += 2 {
+		if services.Content[i].Value == ServiceTunnel {
+			index = i
+			break
+		}
+	}
+	if index < 0 {
+		return composeContent, false, nil
+	}
+
+	removed := services.Content[index+1]
+	services.Content = append(services.Content[:index], services.Content[index+2:]...)
+
+	anchors := map[*yaml.Node]bool{}
+	collectAnchors(removed, anchors)
+	if len(anchors) > 0 {
+		if expanded := expandAliases(&doc, anchors); len(expanded) > 0 {
+			slog.Warn("expanded YAML aliases whose anchors were defined in the removed tunnel service", "anchors", expanded)
+		}
+	}
+
+	content, err := encodeComposeNode(&doc)
+	if err != nil {
+		return "", false, err
+	}
+	return content, true, nil
+}
+
+// encodeComposeNode renders a compose document with the 2-space indent compose files use
+func encodeComposeNode(doc *yaml.Node) (string, error) {
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(doc); err != nil {
+		return "", fmt.Errorf("failed to marshal compose content: %w", err)
+	}
+	if err := encoder.Close(); err != nil {
+		return "", fmt.Errorf("failed to marshal compose content: %w", err)
+	}
+	return buf.String(), nil
+}
+
+// resolveAlias returns the node an alias points at, or node itself
+func resolveAlias(node *yaml.Node) *yaml.Node {
+	for node != nil && node.Kind == yaml.AliasNode {
+		node = node.Alias
+	}
+	return node
+}
+
+// lookupKey returns the value for key in a mapping, following alias and merge keys (<<) the way
+// YAML does: keys written in the mapping win over merged ones, and earlier merged mappings win over
+// later ones. direct is false when the value comes from a merged mapping.
+func lookupKey(node *yaml.Node, key string) (value *yaml.Node, direct bool) {
+	node = resolveAlias(node)
+	if value := mappingValue(node, key); value != nil {
+		return value, true
+	}
+	merge := resolveAlias(mappingValue(node, "<<"))
+	if merge == nil {
+		return nil, false
+	}
+	sources := []*yaml.Node{merge}
+	if merge.Kind == yaml.SequenceNode {
+		sources = merge.Content
+	}
+	for _, source := range sources {
+		if value, _ := lookupKey(source, key); value != nil {
+			return value, false
+		}
+	}
+	return nil, false
+}
+
+// collectAnchors adds the nodes under node that define an anchor
+func collectAnchors(node *yaml.Node, anchors map[*yaml.Node]bool) {
+	if node == nil || node.Kind == yaml.AliasNode {
+		return
+	}
+	if node.Anchor != "" {
+		anchors[node] = true
+	}
+	for _, child := range node.Content {
+		collectAnchors(child, anchors)
+	}
+}
+
+// expandAliases replaces aliases of the given anchors with copies of what they point at and
+// returns the names of the anchors that were expanded
+func expandAliases(node *yaml.Node, anchors map[*yaml.Node]bool) []string {
+	var expanded []string
+	seen := map[string]bool{}
+	var walk func(n *yaml.Node)
+	walk = func(n *yaml.Node) {
+		for i, child := range n.Content {
+			if child.Kind == yaml.AliasNode && anchors[child.Alias] {
+				if name := child.Alias.Anchor; !seen[name] {
+					seen[name] = true
+					expanded = append(expanded, name)
+				}
+				n.Content[i] = copyNode(child.Alias, anchors)
+				continue
+			}
+			walk(child)
+		}
+	}
+	walk(node)
+	return expanded
+}
+
+// copyNode deep-copies node without its anchor, expanding nested aliases of the given anchors too
+func copyNode(node *yaml.Node, anchors map[*yaml.Node]bool) *yaml.Node {
+	if node.Kind == yaml.AliasNode && anchors[node.Alias] {
+		return copyNode(node.Alias, anchors)
+	}
+	clone := *node
+	clone.Anchor = ""
+	if node.Content != nil {
+		clone.Content = make([]*yaml.Node, len(node.Content))
+		for i, child := range node.Content {
+			clone.Content[i] = copyNode(child, anchors)
+		}
+	}
+	return &clone
+}
@@ -0,0 +1,91 @@
+package docker
+
+import (
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestStripTunnelService(t *testing.T) {
+	compose := `x-common: &common
+  restart: unless-stopped
+  environment:
+    TZ: UTC
+services:
+  web:
+    <<: *common
+    image: nginx:latest
+  worker:
+    <<: *common
+    image: worker:latest
+  tunnel:
+    image: cloudflare/cloudflared:latest
+`
+	got, removed, err := StripTunnelService(compose)
+	if err != nil {
+		t.Fatalf("StripTunnelService() error = %v", err)
+	}
+	if !removed {
+		t.Fatal("expected the tunnel service to be removed")
+	}
+	for _, want := range []string{"x-common: &common", "<<: *common"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("expected %q to be preserved, got:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "cloudflared") {
+		t.Errorf("expected the tunnel service to be gone, got:\n%s", got)
+	}
+
+	parsed, err := ParseCompose([]byte(got))
+	if err != nil {
+		t.Fatalf("stripped compose does not parse: %v", err)
+	}
+	if parsed.Services["worker"].Restart != "unless-stopped" {
+		t.Errorf("expected merged keys to still apply, got %+v", parsed.Services["worker"])
+	}
+}
+
+func TestStripTunnelService_NoTunnel(t *testing.T) {
+	compose := "services:\n  web:\n    image: nginx\n"
+	got, removed, err := StripTunnelService(compose)
+	if err != nil {
+		t.Fatalf("StripTunnelService() error = %v", err)
+	}
+	if removed || got != compose {
+		t.Errorf("expected content unchanged, got removed=%v:\n%s", removed, got)
+	}
+}
+
+func TestStripTunnelService_ExpandsAnchorsInRemovedService(t *testing.T) {
+	compose := `services:
+  tunnel:
+    image: cloudflare/cloudflared:latest
+    logging: &logging
+      driver: json-file
+      options:
+        max-size: 10m
+  web:
+    image: nginx
+    logging: *logging
+`
+	got, removed, err := StripTunnelService(compose)
+	if err != nil {
+		t.Fatalf("StripTunnelService() error = %v", err)
+	}
+	if !removed {
+		t.Fatal("expected the tunnel service to be removed")
+	}
+	if strings.Contains(got, "*logging") {
+		t.Errorf("expected the dangling alias to be expanded, got:\n%s", got)
+	}
+
+	var out map[string]interface{}
+	if err := yaml.Unmarshal([]byte(got), &out); err != nil {
+		t.Fatalf("stripped compose is not valid YAML: %v\n%s", err, got)
+	}
+	if !strings.Contains(got, "max-size: 10m") {
+		t.Errorf("expected the anchored block to be copied into web, got:\n%s", got)
+	}
+}
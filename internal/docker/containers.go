@@ -0,0 +1,77 @@
+package docker
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// composeServiceLabel is the label compose puts on each container naming its service
+const composeServiceLabel = "com.docker.compose.service"
+
+// ContainerState is the lifecycle state docker reports for one of an app's containers
+type ContainerState struct {
+	ID           string
+	Name         string
+	Service      string
+	Status       string // created, running, restarting, exited, ...
+	ExitCode     int
+	OOMKilled    bool // The last exit was the kernel OOM killer
+	RestartCount int  // Restarts by docker's restart policy since the container was created
+	StartedAt    time.Time
+	FinishedAt   time.Time // Zero until the container first exits
+}
+
+// InspectAppContainers returns the state of every container of the app's compose project,
+// running or not
+func (m *Manager) InspectAppContainers(name string) ([]ContainerState, error) {
+	containers, err := m.ListAppContainers(name)
+	if err != nil || len(containers) == 0 {
+		return nil, err
+	}
+	cmd := DockerInspectCommand(containers...)
+	output, err := m.commandExecutor.ExecuteCommand(cmd[0], cmd[1:]...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to inspect containers: %w\nOutput: %s", err, string(output))
+	}
+	return parseContainerInspect(output)
+}
+
+// parseContainerInspect reads the state of each container in "docker inspect" output
+func parseContainerInspect(output []byte) ([]ContainerState, error) {
+	var inspected []struct {
+		ID           string `json:"Id"`
+		Name         string `json:"Name"`
+		RestartCount int    `json:"RestartCount"`
+		State        struct {
+			Status     string    `json:"Status"`
+			OOMKilled  bool      `json:"OOMKilled"`
+			ExitCode   int       `json:"ExitCode"`
+			StartedAt  time.Time `json:"StartedAt"`
+			FinishedAt time.Time `json:"FinishedAt"`
+		} `json:"State"`
+		Config struct {
+			Labels map[string]string `json:"Labels"`
+		} `json:"Config"`
+	}
+	if err := json.Unmarshal(output, &inspected); err != nil {
+		return nil, fmt.Errorf("failed to parse docker inspect output: %w", err)
+	}
+
+	states := make([]ContainerState, len(inspected))
+	for i, c := range inspected {
+		states[i] = ContainerState{
+			ID:           c.ID,
+			Name:         strings.TrimPrefix(c.Name, "/"),
+			Service:      c.Config.Labels[composeServiceLabel],
+			Status:       c.State.Status,
+			ExitCode:     c.State.ExitCode,
+			OOMKilled:    c.State.OOMKilled,
+			RestartCount: c.RestartCount,
+			StartedAt:    c.State.StartedAt,
+			FinishedAt:   c.State.FinishedAt,
+		}
+	}
+	return states, nil
+}
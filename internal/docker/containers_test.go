@@ -0,0 +1,48 @@
+package docker
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseContainerInspect(t *testing.T) {
+	output := []byte(`[
+  {
+    "Id": "abc123",
+    "Name": "/myapp-web-1",
+    "RestartCount": 4,
+    "State": {"Status": "restarting", "OOMKilled": true, "ExitCode": 137, "StartedAt": "2024-01-15T10:00:00Z", "FinishedAt": "2024-01-15T10:05:00.5Z"},
+    "Config": {"Labels": {"com.docker.compose.service": "web"}}
+  },
+  {
+    "Id": "def456",
+    "Name": "/myapp-db-1",
+    "RestartCount": 0,
+    "State": {"Status": "running", "OOMKilled": false, "ExitCode": 0, "StartedAt": "2024-01-15T09:00:00Z", "FinishedAt": "0001-01-01T00:00:00Z"},
+    "Config": {"Labels": {"com.docker.compose.service": "db"}}
+  }
+]`)
+
+	states, err := parseContainerInspect(output)
+	if err != nil {
+		t.Fatalf("parseContainerInspect: %v", err)
+	}
+	if len(states) != 2 {
+		t.Fatalf("Expected 2 containers, got %d", len(states))
+	}
+
+	web := states[0]
+	if web.Name != "myapp-web-1" || web.Service != "web" || web.Status != "restarting" || !web.OOMKilled || web.ExitCode != 137 || web.RestartCount != 4 {
+		t.Errorf("Unexpected web state %+v", web)
+	}
+	if want := time.Date(2024, 1, 15, 10, 5, 0, 5e8, time.UTC); !web.FinishedAt.Equal(want) {
+		t.Errorf("Expected FinishedAt %v, got %v", want, web.FinishedAt)
+	}
+	if !states[1].FinishedAt.IsZero() {
+		t.Errorf("Expected a zero FinishedAt for a container that never exited, got %v", states[1].FinishedAt)
+	}
+
+	if _, err := parseContainerInspect([]byte("not json")); err == nil {
+		t.Error("Expected an error for invalid output")
+	}
+}
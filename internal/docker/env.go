@@ -0,0 +1,37 @@
+package docker
+
+import (
+	"regexp"
+)
+
+// envReference matches $$ (an escaped dollar), ${NAME}, ${NAME:-default}, ${NAME-default},
+// ${NAME:?err}, ${NAME?err} and $NAME
+var envReference = regexp.MustCompile(`\$\$|\$\{([A-Za-z_][A-Za-z0-9_]*)(?::?[-?][^}]*)?\}|\$([A-Za-z_][A-Za-z0-9_]*)`)
+
+// envName matches names docker compose accepts for interpolation
+var envName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// ValidEnvName reports whether name can be referenced from a compose file
+func ValidEnvName(name string) bool {
+	return envName.MatchString(name)
+}
+
+// InterpolateEnv replaces references to the variables in env with their values, the way docker
+// compose would. References to other variables, and escaped $$, are left for docker compose to
+// resolve from the host environment at deploy time.
+func InterpolateEnv(content string, env map[string]string) string {
+	if len(env) == 0 {
+		return content
+	}
+	return envReference.ReplaceAllStringFunc(content, func(ref string) string {
+		m := envReference.FindStringSubmatch(ref)
+		name := m[1]
+		if name == "" {
+			name = m[2]
+		}
+		if value, ok := env[name]; ok {
+			return value
+		}
+		return ref
+	})
+}
@@ -0,0 +1,129 @@
+package docker
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"github.com/selfhostly/internal/secrets"
+)
+
+// EnvFileName is the managed .env docker compose reads from the app directory
+const EnvFileName = ".env"
+
+// EnvTemplateData is what a managed .env template can reference, e.g. {{ .AppName }}
+type EnvTemplateData struct {
+	AppName    string
+	BaseDomain string
+}
+
+// envTemplateFuncs are the helpers a managed .env template can call. They generate the same
+// secrets as POST /api/utils/generate-secret; their names start with "rand" so RenderEnvTemplate
+// can tell which values to keep.
+var envTemplateFuncs = template.FuncMap{
+	"randAlphaNum": func(n int) (string, error) { return secrets.String(n, secrets.CharsetAlphanumeric) },
+	"randAlpha":    func(n int) (string, error) { return secrets.String(n, secrets.CharsetAlpha) },
+	"randNumeric":  func(n int) (string, error) { return secrets.String(n, secrets.CharsetNumeric) },
+	"randHex":      func(n int) (string, error) { return secrets.String(n, secrets.CharsetHex) },
+	"randURLSafe":  func(n int) (string, error) { return secrets.String(n, secrets.CharsetURLSafe) },
+	"randBase64": func(n int) (string, error) {
+		return secrets.Generate(secrets.Options{Length: n, Format: secrets.FormatBase64})
+	},
+	"randUUID": func() (string, error) { return secrets.Generate(secrets.Options{Format: secrets.FormatUUID}) },
+}
+
+// envLine is one KEY=VALUE line of a .env file
+type envLine struct {
+	key   string
+	value string // Everything after the first =, as written
+}
+
+// parseEnvLine splits a KEY=VALUE line; ok is false for blank lines and comments
+func parseEnvLine(line string) (envLine, bool, error) {
+	trimmed := strings.TrimSpace(line)
+	if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+		return envLine{}, false, nil
+	}
+	key, value, found := strings.Cut(trimmed, "=")
+	key = strings.TrimSpace(strings.TrimPrefix(key, "export "))
+	if !found || !ValidEnvName(key) {
+		return envLine{}, false, fmt.Errorf("expected KEY=VALUE, got %q", trimmed)
+	}
+	return envLine{key: key, value: value}, true, nil
+}
+
+// envValues maps each variable of a .env file to its value as written
+func envValues(content string) map[string]string {
+	values := make(map[string]string)
+	for _, line := range strings.Split(content, "\n") {
+		if parsed, ok, err := parseEnvLine(line); err == nil && ok {
+			values[parsed.key] = parsed.value
+		}
+	}
+	return values
+}
+
+// RenderEnvTemplate renders an app's managed .env template. Values are rendered one line at a time
+// with text/template, so comments and blank lines are kept. A value that calls a rand* helper is
+// generated once: while its line is unchanged from prevTemplate, the value rendered into
+// prevContent is reused, so redeploying doesn't rotate passwords the app has already stored.
+func RenderEnvTemplate(tmpl string, data EnvTemplateData, prevTemplate, prevContent string) (string, error) {
+	prevLines := envValues(prevTemplate)
+	prevValues := envValues(prevContent)
+
+	lines := strings.Split(tmpl, "\n")
+	for i, line := range lines {
+		parsed, ok, err := parseEnvLine(line)
+		if err != nil {
+			return "", fmt.Errorf("line %d: %w", i+1, err)
+		}
+		if !ok || !strings.Contains(parsed.value, "{{") {
+			continue
+		}
+
+		if strings.Contains(parsed.value, "rand") && prevLines[parsed.key] == parsed.value {
+			if value, ok := prevValues[parsed.key]; ok {
+				lines[i] = parsed.key + "=" + value
+				continue
+			}
+		}
+
+		t, err := template.New(parsed.key).Funcs(envTemplateFuncs).Option("missingkey=error").Parse(parsed.value)
+		if err != nil {
+			return "", fmt.Errorf("line %d: %w", i+1, err)
+		}
+		var value strings.Builder
+		if err := t.Execute(&value, data); err != nil {
+			return "", fmt.Errorf("line %d: %w", i+1, err)
+		}
+		if strings.ContainsAny(value.String(), "\r\n") {
+			return "", fmt.Errorf("line %d: %s renders to more than one line", i+1, parsed.key)
+		}
+		lines[i] = parsed.key + "=" + value.String()
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+// WriteEnvFile writes an app's managed .env, readable only by the owner since it usually holds
+// secrets. Empty content removes it.
+func (m *Manager) WriteEnvFile(name, content string) error {
+	filePath := filepath.Join(m.AppPath(name), EnvFileName)
+	if content == "" {
+		if err := os.Remove(filePath); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove %s: %w", EnvFileName, err)
+		}
+		return nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(filePath), 0755); err != nil {
+		return fmt.Errorf("failed to create app directory: %w", err)
+	}
+	slog.Info("writing managed env file", "app", name)
+	if err := os.WriteFile(filePath, []byte(content), 0600); err != nil {
+		return fmt.Errorf("failed to write %s: %w", EnvFileName, err)
+	}
+	return nil
+}
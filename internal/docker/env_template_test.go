@@ -0,0 +1,95 @@
+package docker
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestRenderEnvTemplate(t *testing.T) {
+	tmpl := "# database\nDB_PASSWORD={{ randAlphaNum 32 }}\nDOMAIN={{ .AppName }}.{{ .BaseDomain }}\nPLAIN=value\n"
+	data := EnvTemplateData{AppName: "blog", BaseDomain: "example.com"}
+
+	content, err := RenderEnvTemplate(tmpl, data, "", "")
+	if err != nil {
+		t.Fatalf("RenderEnvTemplate() error = %v", err)
+	}
+	values := envValues(content)
+	if !regexp.MustCompile(`^[A-Za-z0-9]{32}$`).MatchString(values["DB_PASSWORD"]) {
+		t.Errorf("DB_PASSWORD = %q, want 32 alphanumeric characters", values["DB_PASSWORD"])
+	}
+	if values["DOMAIN"] != "blog.example.com" {
+		t.Errorf("DOMAIN = %q, want blog.example.com", values["DOMAIN"])
+	}
+	if values["PLAIN"] != "value" || !strings.HasPrefix(content, "# database\n") {
+		t.Errorf("expected plain lines and comments to be kept, got:\n%s", content)
+	}
+
+	// Generated secrets survive a re-render while their line is unchanged
+	data.BaseDomain = "example.org"
+	rerendered, err := RenderEnvTemplate(tmpl, data, tmpl, content)
+	if err != nil {
+		t.Fatalf("RenderEnvTemplate() error = %v", err)
+	}
+	again := envValues(rerendered)
+	if again["DB_PASSWORD"] != values["DB_PASSWORD"] {
+		t.Errorf("expected DB_PASSWORD to be kept, got %q then %q", values["DB_PASSWORD"], again["DB_PASSWORD"])
+	}
+	if again["DOMAIN"] != "blog.example.org" {
+		t.Errorf("expected DOMAIN to be re-rendered, got %q", again["DOMAIN"])
+	}
+
+	// Editing the line generates a new one
+	changed := strings.Replace(tmpl, "randAlphaNum 32", "randHex 16", 1)
+	rotated, err := RenderEnvTemplate(changed, data, tmpl, content)
+	if err != nil {
+		t.Fatalf("RenderEnvTemplate() error = %v", err)
+	}
+	if got := envValues(rotated)["DB_PASSWORD"]; len(got) != 16 {
+		t.Errorf("expected a new 16 character DB_PASSWORD, got %q", got)
+	}
+}
+
+func TestRenderEnvTemplate_Errors(t *testing.T) {
+	tests := map[string]string{
+		"not KEY=VALUE":  "just some text",
+		"invalid name":   "1KEY=value",
+		"bad template":   "KEY={{ randAlphaNum }",
+		"unknown field":  "KEY={{ .Nope }}",
+		"unknown helper": "KEY={{ randomThing 3 }}",
+		"bad length":     "KEY={{ randAlphaNum 0 }}",
+	}
+	for name, tmpl := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := RenderEnvTemplate(tmpl, EnvTemplateData{}, "", ""); err == nil {
+				t.Errorf("expected an error for %q", tmpl)
+			}
+		})
+	}
+}
+
+func TestWriteEnvFile(t *testing.T) {
+	appsDir := t.TempDir()
+	manager := NewManagerWithExecutor(appsDir, NewMockCommandExecutor())
+	envPath := filepath.Join(appsDir, "blog", EnvFileName)
+
+	if err := manager.WriteEnvFile("blog", "KEY=value\n"); err != nil {
+		t.Fatalf("WriteEnvFile() error = %v", err)
+	}
+	info, err := os.Stat(envPath)
+	if err != nil {
+		t.Fatalf("expected .env to be written: %v", err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf(".env mode = %v, want 0600", info.Mode().Perm())
+	}
+
+	if err := manager.WriteEnvFile("blog", ""); err != nil {
+		t.Fatalf("WriteEnvFile() error = %v", err)
+	}
+	if _, err := os.Stat(envPath); !os.IsNotExist(err) {
+		t.Errorf("expected .env to be removed, stat error = %v", err)
+	}
+}
@@ -0,0 +1,32 @@
+package docker
+
+import "testing"
+
+func TestInterpolateEnv(t *testing.T) {
+	env := map[string]string{"TAG": "1.25", "PORT": "8080"}
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{"braced", "image: nginx:${TAG}", "image: nginx:1.25"},
+		{"bare", "- $PORT:80", "- 8080:80"},
+		{"default ignored when set", "image: nginx:${TAG:-latest}", "image: nginx:1.25"},
+		{"unknown kept", "image: nginx:${OTHER:-latest}", "image: nginx:${OTHER:-latest}"},
+		{"escaped kept", "command: echo $$TAG", "command: echo $$TAG"},
+		{"longer name not matched", "x: $TAGS", "x: $TAGS"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := InterpolateEnv(tt.in, env); got != tt.want {
+				t.Errorf("InterpolateEnv(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+
+	for name, want := range map[string]bool{"TAG": true, "_x1": true, "1X": false, "A-B": false, "": false} {
+		if got := ValidEnvName(name); got != want {
+			t.Errorf("ValidEnvName(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package docker
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/selfhostly/internal/constants"
+)
+
+// OperationError is a failed start or update of an app, with the end of the compose output and
+// of the app's container logs captured when it failed, so the cause is on record without a second
+// logs request. Error() is the plain error message; Details() includes the captured output.
+type OperationError struct {
+	App        string    `json:"app"`
+	Operation  string    `json:"operation"` // "start" or "update"
+	Message    string    `json:"error"`
+	Output     string    `json:"output,omitempty"` // Last lines of the compose command's output
+	Logs       string    `json:"logs,omitempty"`   // Last lines of the app's container logs
+	CapturedAt time.Time `json:"captured_at"`
+
+	err error
+}
+
+func (e *OperationError) Error() string {
+	return e.err.Error()
+}
+
+func (e *OperationError) Unwrap() error {
+	return e.err
+}
+
+// Details is the error followed by the captured output, as stored in the app's error message
+func (e *OperationError) Details() string {
+	var b strings.Builder
+	b.WriteString(e.Message)
+	if e.Output != "" {
+		fmt.Fprintf(&b, "\n\nCompose output:\n%s", e.Output)
+	}
+	if e.Logs != "" {
+		fmt.Fprintf(&b, "\n\nContainer logs:\n%s", e.Logs)
+	}
+	return b.String()
+}
+
+// ErrorDetails returns the details of an OperationError in err's chain, or err's message otherwise
+func ErrorDetails(err error) string {
+	var opErr *OperationError
+	if errors.As(err, &opErr) {
+		return opErr.Details()
+	}
+	return err.Error()
+}
+
+// captureFailure wraps err, the failure of a compose command run for operation, with the end of the
+// command's output and of the app's container logs. Failing to read the logs is only logged.
+func (m *Manager) captureFailure(name, operation string, err error, output []byte) error {
+	message, _, _ := strings.Cut(err.Error(), "\n")
+	opErr := &OperationError{
+		App:        name,
+		Operation:  operation,
+		Message:    message,
+		Output:     lastLines(string(output), constants.FailureLogTailLines),
+		CapturedAt: time.Now(),
+		err:        err,
+	}
+	logs, logsErr := m.TailAppLogs(name, "", constants.FailureLogTailLines)
+	if logsErr != nil {
+		slog.Debug("failed to capture container logs", "app", name, "operation", operation, "error", logsErr)
+	} else {
+		opErr.Logs = logs
+	}
+	return opErr
+}
+
+// lastLines returns the last n lines of s, without trailing newlines
+func lastLines(s string, n int) string {
+	lines := strings.Split(strings.TrimRight(s, "\n"), "\n")
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,66 @@
+package docker
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/selfhostly/internal/constants"
+)
+
+func TestStartAppCapturesFailureLogs(t *testing.T) {
+	tmpDir := t.TempDir()
+	mockExecutor := NewMockCommandExecutor()
+	manager := NewManagerWithExecutor(tmpDir, mockExecutor)
+	if err := os.MkdirAll(filepath.Join(tmpDir, "test-app"), 0755); err != nil {
+		t.Fatalf("Failed to create app directory: %v", err)
+	}
+
+	upCmd := ComposeUpCommand()
+	mockExecutor.SetMockError("docker", upCmd[1:], fmt.Errorf("exit status 1"))
+	logsCmd := ComposeLogsTailCommand(constants.FailureLogTailLines, "")
+	mockExecutor.SetMockOutput("docker", logsCmd[1:], []byte("web-1  | Error: DATABASE_URL is not set\n"))
+
+	err := manager.StartApp("test-app")
+	var opErr *OperationError
+	if !errors.As(err, &opErr) {
+		t.Fatalf("Expected an OperationError, got %v", err)
+	}
+	if opErr.Operation != "start" || opErr.App != "test-app" {
+		t.Errorf("Unexpected operation %q for app %q", opErr.Operation, opErr.App)
+	}
+	if opErr.Message != "failed to start app: exit status 1" {
+		t.Errorf("Expected the first line of the error as the message, got %q", opErr.Message)
+	}
+	if opErr.Logs != "web-1  | Error: DATABASE_URL is not set" {
+		t.Errorf("Expected the container logs to be captured, got %q", opErr.Logs)
+	}
+
+	details := ErrorDetails(fmt.Errorf("job failed: %w", err))
+	if !strings.HasPrefix(details, opErr.Message) || !strings.Contains(details, "DATABASE_URL is not set") {
+		t.Errorf("Expected the details to include the message and logs, got %q", details)
+	}
+	if got := ErrorDetails(errors.New("plain")); got != "plain" {
+		t.Errorf("Expected a plain error's message, got %q", got)
+	}
+}
+
+func TestLastLines(t *testing.T) {
+	tests := []struct {
+		input string
+		n     int
+		want  string
+	}{
+		{"a\nb\nc\n", 2, "b\nc"},
+		{"a\nb", 5, "a\nb"},
+		{"", 3, ""},
+	}
+	for _, tt := range tests {
+		if got := lastLines(tt.input, tt.n); got != tt.want {
+			t.Errorf("lastLines(%q, %d) = %q, want %q", tt.input, tt.n, got, tt.want)
+		}
+	}
+}
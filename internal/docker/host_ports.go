@@ -0,0 +1,86 @@
+package docker
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	composetypes "github.com/compose-spec/compose-go/v2/types"
+)
+
+// HostPort is a port an app publishes on the host
+type HostPort struct {
+	Port     int    `json:"port"`
+	Protocol string `json:"protocol"` // tcp or udp
+	Service  string `json:"service"`
+}
+
+// ComposeHostPorts returns the host ports the app's compose and override publish, merged as docker
+// compose does, ordered by port. A range is expanded into its ports; a port docker picks itself (no
+// published port) is skipped. The host IP a port is bound to is ignored, so a port counts as taken
+// whatever address it listens on.
+func ComposeHostPorts(composeContent, composeOverride string, composeFiles map[string]string) ([]HostPort, error) {
+	files := []composetypes.ConfigFile{{Filename: ComposeFileName, Content: []byte(composeContent)}}
+	if strings.TrimSpace(composeOverride) != "" {
+		files = append(files, composetypes.ConfigFile{Filename: ComposeOverrideFileName, Content: []byte(composeOverride)})
+	}
+	project, err := loadComposeProject(composeFiles, files...)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[HostPort]bool)
+	var ports []HostPort
+	for _, svc := range project.Services {
+		for _, port := range svc.Ports {
+			low, high, ok := parsePublishedPorts(port.Published)
+			if !ok {
+				continue
+			}
+			protocol := strings.ToLower(port.Protocol)
+			if protocol == "" {
+				protocol = "tcp"
+			}
+			for p := low; p <= high; p++ {
+				key := HostPort{Port: p, Protocol: protocol}
+				if seen[key] {
+					continue
+				}
+				seen[key] = true
+				ports = append(ports, HostPort{Port: p, Protocol: protocol, Service: svc.Name})
+			}
+		}
+	}
+	sort.Slice(ports, func(i, j int) bool {
+		if ports[i].Port != ports[j].Port {
+			return ports[i].Port < ports[j].Port
+		}
+		return ports[i].Protocol < ports[j].Protocol
+	})
+	return ports, nil
+}
+
+// parsePublishedPorts parses a published port, "8080" or "8080-8090"
+func parsePublishedPorts(published string) (low, high int, ok bool) {
+	if published == "" {
+		return 0, 0, false
+	}
+	from, to, isRange := strings.Cut(published, "-")
+	low, err := strconv.Atoi(from)
+	if err != nil || low <= 0 {
+		return 0, 0, false
+	}
+	high = low
+	if isRange {
+		if high, err = strconv.Atoi(to); err != nil || high < low {
+			return 0, 0, false
+		}
+	}
+	return low, high, true
+}
+
+// String formats the port as docker does, e.g. 8080/tcp
+func (p HostPort) String() string {
+	return fmt.Sprintf("%d/%s", p.Port, p.Protocol)
+}
@@ -0,0 +1,45 @@
+package docker
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestComposeHostPorts(t *testing.T) {
+	compose := `services:
+  web:
+    image: nginx
+    ports:
+      - "8080:80"
+      - "127.0.0.1:8443:443"
+      - "53:53/udp"
+      - "9000-9002:9000-9002"
+      - "80"
+  db:
+    image: postgres
+    ports:
+      - "5432"
+`
+	override := `services:
+  db:
+    ports:
+      - target: 5432
+        published: "5433"
+`
+
+	got, err := ComposeHostPorts(compose, override, nil)
+	if err != nil {
+		t.Fatalf("ComposeHostPorts: %v", err)
+	}
+	var ports []string
+	for _, port := range got {
+		ports = append(ports, port.String())
+	}
+	want := "53/udp 5433/tcp 8080/tcp 8443/tcp 9000/tcp 9001/tcp 9002/tcp"
+	if joined := strings.Join(ports, " "); joined != want {
+		t.Errorf("ComposeHostPorts = %q, want %q", joined, want)
+	}
+	if got[1].Service != "db" || got[2].Service != "web" {
+		t.Errorf("expected ports to name their service, got %+v", got)
+	}
+}
@@ -0,0 +1,221 @@
+package docker
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+
+	"github.com/selfhostly/internal/platform"
+	"gopkg.in/yaml.v3"
+)
+
+// ArchImagesExtension is the service field naming the image to run on each architecture, for
+// images that don't publish one multi-arch manifest:
+//
+//	services:
+//	  web:
+//	    image: example/app:1.2
+//	    x-arch-images:
+//	      arm64: example/app:1.2-arm64
+const ArchImagesExtension = "x-arch-images"
+
+// ResolveImageDigests resolves every image the app's compose files reference to the digest
+// that is present locally (e.g. "nginx:latest" -> "nginx@sha256:..."). Images without a
+// registry digest, such as ones built locally, are skipped.
+func (m *Manager) ResolveImageDigests(name string) (map[string]string, error) {
+	appPath := m.AppPath(name)
+
+	output, err := m.runCompose(appPath, ComposeConfigImagesCommand())
+	if err != nil {
+		slog.Error("failed to list app images", "app", name, "error", err, "output", string(output))
+		return nil, fmt.Errorf("failed to list images: %w\nOutput: %s", err, string(output))
+	}
+
+	digests := make(map[string]string)
+	for _, image := range strings.Split(string(output), "\n") {
+		image = strings.TrimSpace(image)
+		if image == "" {
+			continue
+		}
+		if strings.Contains(image, "@sha256:") {
+			// Already pinned
+			digests[image] = image
+			continue
+		}
+
+		cmd := DockerImageRepoDigestCommand(image)
+		digest, err := m.commandExecutor.ExecuteCommand(cmd[0], cmd[1:]...)
+		if err != nil || strings.TrimSpace(string(digest)) == "" {
+			slog.Debug("no registry digest for image", "app", name, "image", image, "error", err)
+			continue
+		}
+		digests[image] = strings.TrimSpace(string(digest))
+	}
+
+	return digests, nil
+}
+
+// ArchImageCompose returns the compose file that swaps in each service's ArchImagesExtension image
+// for arch (a GOARCH value), or "" when no service has one for it
+func ArchImageCompose(composeContent, arch string) (string, error) {
+	var doc struct {
+		Services map[string]struct {
+			ArchImages map[string]string `yaml:"x-arch-images"`
+		} `yaml:"services"`
+	}
+	if err := yaml.Unmarshal([]byte(composeContent), &doc); err != nil {
+		return "", fmt.Errorf("failed to parse compose content: %w", err)
+	}
+
+	services := map[string]map[string]string{}
+	for name, service := range doc.Services {
+		if image := strings.TrimSpace(service.ArchImages[arch]); image != "" {
+			services[name] = map[string]string{"image": image}
+		}
+	}
+	if len(services) == 0 {
+		return "", nil
+	}
+	content, err := yaml.Marshal(map[string]interface{}{"services": services})
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal %s: %w", ComposeArchFileName, err)
+	}
+	return string(content), nil
+}
+
+// writeArchComposeFile regenerates the app's ComposeArchFileName from its compose file for this
+// node's architecture, removing it when no service has an image for it
+func (m *Manager) writeArchComposeFile(name, composeContent string) error {
+	content, err := ArchImageCompose(composeContent, platform.Arch())
+	if err != nil {
+		// Compose validation reports the document itself; without services there's nothing to swap
+		slog.Debug("no per-architecture images read from compose file", "app", name, "error", err)
+		content = ""
+	}
+	return m.writeOptionalComposeFile(name, ComposeArchFileName, content)
+}
+
+// AppImagePlatformWarnings returns ImagePlatformWarnings for the images the app's compose files
+// run on this node, after its per-architecture images are swapped in
+func (m *Manager) AppImagePlatformWarnings(name string) ([]string, error) {
+	output, err := m.runCompose(m.AppPath(name), ComposeConfigImagesCommand())
+	if err != nil {
+		return nil, fmt.Errorf("failed to list images: %w\nOutput: %s", err, string(output))
+	}
+	return m.ImagePlatformWarnings(strings.Fields(string(output)), platform.Arch()), nil
+}
+
+// ImagePlatformWarnings checks the registry manifests of images and returns a warning for each
+// multi-arch image that has no manifest for arch (a GOARCH value, e.g. arm64 on a Raspberry Pi),
+// which would fail to pull or run under emulation on this node. Images whose manifest can't be
+// read, such as local builds, and single-platform manifests, which don't name their platform, are
+// not reported.
+func (m *Manager) ImagePlatformWarnings(images []string, arch string) []string {
+	var warnings []string
+	checked := map[string]bool{}
+	for _, image := range images {
+		if image == "" || checked[image] {
+			continue
+		}
+		checked[image] = true
+
+		cmd := DockerManifestInspectCommand(image)
+		output, err := m.commandExecutor.ExecuteCommand(cmd[0], cmd[1:]...)
+		if err != nil {
+			slog.Debug("no registry manifest for image", "image", image, "error", err)
+			continue
+		}
+		var manifest struct {
+			Manifests []struct {
+				Platform struct {
+					Architecture string `json:"architecture"`
+					OS           string `json:"os"`
+				} `json:"platform"`
+			} `json:"manifests"`
+		}
+		if err := json.Unmarshal(output, &manifest); err != nil || len(manifest.Manifests) == 0 {
+			continue
+		}
+		var available []string
+		for _, entry := range manifest.Manifests {
+			// Attestation manifests are listed with an unknown platform
+			if a := entry.Platform.Architecture; a != "" && a != "unknown" && !slices.Contains(available, a) {
+				available = append(available, a)
+			}
+		}
+		if len(available) > 0 && !slices.Contains(available, arch) {
+			warnings = append(warnings, fmt.Sprintf("image %s has no %s manifest (available: %s)", image, arch, strings.Join(available, ", ")))
+		}
+	}
+	return warnings
+}
+
+// PinComposeImages rewrites service images in composeContent to the digests recorded for them,
+// leaving the rest of the document (comments, ordering) untouched. Images without a recorded
+// digest are kept as-is. Returns the content unchanged with pinned=false when nothing matched.
+func PinComposeImages(composeContent string, digests map[string]string) (string, bool, error) {
+	if len(digests) == 0 {
+		return composeContent, false, nil
+	}
+
+	var doc yaml.Node
+	if err := yaml.Unmarshal([]byte(composeContent), &doc); err != nil {
+		return "", false, fmt.Errorf("failed to parse compose content: %w", err)
+	}
+	if len(doc.Content) == 0 {
+		return composeContent, false, nil
+	}
+
+	services := mappingValue(doc.Content[0], "services")
+	if services == nil || services.Kind != yaml.MappingNode {
+		return composeContent, false, nil
+	}
+
+	pinned := false
+	for i := 1; i < len(services.Content); i += 2 {
+		service := resolveAlias(services.Content[i])
+		image, direct := lookupKey(service, "image")
+		image = resolveAlias(image)
+		if image == nil || image.Kind != yaml.ScalarNode {
+			continue
+		}
+		digest, ok := digests[image.Value]
+		if !ok || digest == image.Value {
+			continue
+		}
+		if direct {
+			image.Value = digest
+		} else {
+			// The image comes from a merge key (<<: *common); pin it on the service so the shared
+			// block other services merge stays as written
+			service.Content = append(service.Content,
+				&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: "image"},
+				&yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: digest})
+		}
+		pinned = true
+	}
+	if !pinned {
+		return composeContent, false, nil
+	}
+
+	content, err := encodeComposeNode(&doc)
+	if err != nil {
+		return "", false, err
+	}
+	return content, true, nil
+}
+
+// mappingValue returns the value node for key in a YAML mapping node, or nil
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	if node == nil || node.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,220 @@
+package docker
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/selfhostly/internal/platform"
+)
+
+func TestResolveImageDigests(t *testing.T) {
+	tmpDir := t.TempDir()
+	mockExecutor := NewMockCommandExecutor()
+	manager := NewManagerWithExecutor(tmpDir, mockExecutor)
+
+	appName := "test-app"
+	if err := manager.CreateAppDirectory(appName, "services:\n  web:\n    image: nginx:latest"); err != nil {
+		t.Fatalf("Failed to create app directory: %v", err)
+	}
+
+	imagesCmd := ComposeConfigImagesCommand()
+	mockExecutor.SetMockOutput("docker", imagesCmd[1:], []byte("nginx:latest\nlocal-build:dev\nredis@sha256:abc123\n"))
+
+	nginxCmd := DockerImageRepoDigestCommand("nginx:latest")
+	mockExecutor.SetMockOutput("docker", nginxCmd[1:], []byte("nginx@sha256:def456\n"))
+
+	// Locally built images have no repo digest, so inspect fails
+	localCmd := DockerImageRepoDigestCommand("local-build:dev")
+	mockExecutor.SetMockError("docker", localCmd[1:], errors.New("index out of range"))
+
+	digests, err := manager.ResolveImageDigests(appName)
+	if err != nil {
+		t.Fatalf("ResolveImageDigests: %v", err)
+	}
+
+	if got := digests["nginx:latest"]; got != "nginx@sha256:def456" {
+		t.Errorf("nginx:latest digest = %q, want nginx@sha256:def456", got)
+	}
+	if got := digests["redis@sha256:abc123"]; got != "redis@sha256:abc123" {
+		t.Errorf("already pinned image should map to itself, got %q", got)
+	}
+	if _, ok := digests["local-build:dev"]; ok {
+		t.Error("image without registry digest should be skipped")
+	}
+}
+
+func TestImagePlatformWarnings(t *testing.T) {
+	mockExecutor := NewMockCommandExecutor()
+	manager := NewManagerWithExecutor(t.TempDir(), mockExecutor)
+
+	multiArch := `{"manifests": [
+		{"platform": {"architecture": "amd64", "os": "linux"}},
+		{"platform": {"architecture": "arm64", "os": "linux", "variant": "v8"}}
+	]}`
+	amd64Only := `{"manifests": [
+		{"platform": {"architecture": "amd64", "os": "linux"}},
+		{"platform": {"architecture": "unknown", "os": "unknown"}}
+	]}`
+	singlePlatform := `{"schemaVersion": 2, "config": {"digest": "sha256:abc"}}`
+	for image, output := range map[string]string{"nginx:latest": multiArch, "legacy:1.0": amd64Only, "tool:2": singlePlatform} {
+		cmd := DockerManifestInspectCommand(image)
+		mockExecutor.SetMockOutput("docker", cmd[1:], []byte(output))
+	}
+	localCmd := DockerManifestInspectCommand("local-build:dev")
+	mockExecutor.SetMockError("docker", localCmd[1:], errors.New("no such manifest"))
+
+	images := []string{"nginx:latest", "legacy:1.0", "legacy:1.0", "tool:2", "local-build:dev"}
+	warnings := manager.ImagePlatformWarnings(images, "arm64")
+	if len(warnings) != 1 || warnings[0] != "image legacy:1.0 has no arm64 manifest (available: amd64)" {
+		t.Errorf("expected one warning for legacy:1.0, got %v", warnings)
+	}
+	if warnings := manager.ImagePlatformWarnings(images, "amd64"); len(warnings) != 0 {
+		t.Errorf("expected no warnings on amd64, got %v", warnings)
+	}
+}
+
+func TestArchImageCompose(t *testing.T) {
+	content := `services:
+  web:
+    image: example/web:1.2
+    x-arch-images:
+      arm64: example/web:1.2-arm64
+      arm: example/web:1.2-armv7
+  db:
+    image: postgres:16
+`
+	override, err := ArchImageCompose(content, "arm64")
+	if err != nil {
+		t.Fatalf("ArchImageCompose: %v", err)
+	}
+	if override != "services:\n    web:\n        image: example/web:1.2-arm64\n" {
+		t.Errorf("expected only web to be swapped, got %q", override)
+	}
+	if override, err := ArchImageCompose(content, "amd64"); err != nil || override != "" {
+		t.Errorf("expected nothing to swap on amd64, got %q, %v", override, err)
+	}
+	if _, err := ArchImageCompose("services: [", "arm64"); err == nil {
+		t.Error("expected invalid compose content to be an error")
+	}
+}
+
+func TestManager_ArchComposeFile(t *testing.T) {
+	mockExecutor := NewMockCommandExecutor()
+	manager := NewManagerWithExecutor(t.TempDir(), mockExecutor)
+	appName := "arch-app"
+	archFile := filepath.Join(manager.AppPath(appName), ComposeArchFileName)
+
+	// The file is generated with the images for the node's own architecture
+	withOverride := "services:\n  web:\n    image: example/web:1.2\n    x-arch-images:\n      " + platform.Arch() + ": example/web:native\n"
+	if err := manager.CreateAppDirectory(appName, withOverride); err != nil {
+		t.Fatalf("CreateAppDirectory: %v", err)
+	}
+	if content, err := os.ReadFile(archFile); err != nil || !strings.Contains(string(content), "example/web:native") {
+		t.Fatalf("expected %s with the native image, got %q, %v", ComposeArchFileName, content, err)
+	}
+	imagesCmd := ComposeConfigImagesCommand()
+	args := composeArgs(manager.AppPath(appName), imagesCmd)
+	if !slices.Contains(args, ComposeArchFileName) {
+		t.Errorf("expected compose commands to include %s, got %v", ComposeArchFileName, args)
+	}
+
+	// The update check looks at the images compose resolves with the file applied
+	mockExecutor.SetMockOutput("docker", args, []byte("example/web:native\n"))
+	manifestCmd := DockerManifestInspectCommand("example/web:native")
+	mockExecutor.SetMockOutput("docker", manifestCmd[1:], []byte(`{"manifests": [{"platform": {"architecture": "s390x", "os": "linux"}}]}`))
+	warnings, err := manager.AppImagePlatformWarnings(appName)
+	if err != nil {
+		t.Fatalf("AppImagePlatformWarnings: %v", err)
+	}
+	if len(warnings) != 1 || !strings.Contains(warnings[0], "example/web:native") {
+		t.Errorf("expected a warning for the swapped image, got %v", warnings)
+	}
+
+	// Dropping the override removes the file
+	if err := manager.WriteComposeFile(appName, "services:\n  web:\n    image: example/web:1.2\n"); err != nil {
+		t.Fatalf("WriteComposeFile: %v", err)
+	}
+	if _, err := os.Stat(archFile); !os.IsNotExist(err) {
+		t.Errorf("expected %s to be removed, got %v", ComposeArchFileName, err)
+	}
+}
+
+func TestPinComposeImages(t *testing.T) {
+	content := `# my app
+services:
+  web:
+    image: nginx:latest # frontend
+    ports:
+      - "80:80"
+  worker:
+    build: .
+`
+	digests := map[string]string{"nginx:latest": "nginx@sha256:def456"}
+
+	pinnedContent, pinned, err := PinComposeImages(content, digests)
+	if err != nil {
+		t.Fatalf("PinComposeImages: %v", err)
+	}
+	if !pinned {
+		t.Fatal("expected image to be pinned")
+	}
+
+	compose, err := ParseCompose([]byte(pinnedContent))
+	if err != nil {
+		t.Fatalf("pinned content does not parse: %v", err)
+	}
+	if got := compose.Services["web"].Image; got != "nginx@sha256:def456" {
+		t.Errorf("web image = %q, want nginx@sha256:def456", got)
+	}
+	if !strings.Contains(pinnedContent, "# frontend") {
+		t.Error("comments should be preserved")
+	}
+
+	unchanged, pinned, err := PinComposeImages(content, map[string]string{"redis:7": "redis@sha256:abc"})
+	if err != nil {
+		t.Fatalf("PinComposeImages: %v", err)
+	}
+	if pinned || unchanged != content {
+		t.Error("content without matching images should be returned unchanged")
+	}
+}
+
+func TestPinComposeImages_MergeKeys(t *testing.T) {
+	content := `x-app: &app
+  image: myapp:latest
+  restart: unless-stopped
+services:
+  web:
+    <<: *app
+    command: serve
+  worker:
+    <<: *app
+    command: work
+`
+	digests := map[string]string{"myapp:latest": "myapp@sha256:abc123"}
+
+	pinnedContent, pinned, err := PinComposeImages(content, digests)
+	if err != nil {
+		t.Fatalf("PinComposeImages: %v", err)
+	}
+	if !pinned {
+		t.Fatal("expected merged image to be pinned")
+	}
+
+	compose, err := ParseCompose([]byte(pinnedContent))
+	if err != nil {
+		t.Fatalf("pinned content does not parse: %v", err)
+	}
+	for _, name := range []string{"web", "worker"} {
+		if got := compose.Services[name].Image; got != "myapp@sha256:abc123" {
+			t.Errorf("%s image = %q, want myapp@sha256:abc123", name, got)
+		}
+	}
+	if !strings.Contains(pinnedContent, "x-app: &app\n  image: myapp:latest") {
+		t.Errorf("the shared block should be left as written, got:\n%s", pinnedContent)
+	}
+}
@@ -0,0 +1,259 @@
+package docker
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+
+	composetypes "github.com/compose-spec/compose-go/v2/types"
+)
+
+// IngressWarning flags an ingress rule whose target doesn't match anything in the app's compose.
+// Such rules are still saved; they only fail with 502s once traffic arrives.
+type IngressWarning struct {
+	Rule    int    `json:"rule"`    // Index of the rule in the submitted list
+	Service string `json:"service"` // The rule's service URL
+	Message string `json:"message"`
+}
+
+// ingressTarget is a compose service as seen from the tunnel sidecar on the app's networks
+type ingressTarget struct {
+	service string
+	ports   []int // Container ports from ports and expose; empty when the compose declares none
+}
+
+// CheckIngressTargets compares ingress service URLs (http://service:port) with the services and
+// ports defined in the app's compose and override, returning a warning per rule that points at
+// an unknown service, at localhost, or at a port the service doesn't declare. Targets outside the
+// compose (FQDNs, IP addresses) and non-HTTP services like http_status:404 are not checked.
+// Services pulled in from the app's extra compose files count as defined.
+func CheckIngressTargets(composeContent, composeOverride string, composeFiles map[string]string, services []string) ([]IngressWarning, error) {
+	targets, serviceNames, err := loadIngressTargets(composeContent, composeOverride, composeFiles)
+	if err != nil {
+		return nil, err
+	}
+
+	var warnings []IngressWarning
+	for i, service := range services {
+		if msg := checkIngressTarget(service, targets, serviceNames); msg != "" {
+			warnings = append(warnings, IngressWarning{Rule: i, Service: service, Message: msg})
+		}
+	}
+	return warnings, nil
+}
+
+// QuickTunnelTargetFromIngress returns the compose service and port the first plain HTTP ingress
+// service URL reaches (http://service:port), for a Quick Tunnel that should serve what the named
+// tunnel served. ok is false when no rule targets a service of the compose that way.
+func QuickTunnelTargetFromIngress(composeContent, composeOverride string, composeFiles map[string]string, services []string) (service string, port int, ok bool, err error) {
+	targets, _, err := loadIngressTargets(composeContent, composeOverride, composeFiles)
+	if err != nil {
+		return "", 0, false, err
+	}
+	for _, s := range services {
+		u, err := url.Parse(strings.TrimSpace(s))
+		if err != nil || u.Scheme != "http" {
+			continue
+		}
+		target, found := targets[strings.ToLower(u.Hostname())]
+		if !found {
+			continue
+		}
+		port := defaultSchemePort(u.Scheme)
+		if p := u.Port(); p != "" {
+			if port, err = strconv.Atoi(p); err != nil {
+				continue
+			}
+		}
+		return target.service, port, true, nil
+	}
+	return "", 0, false, nil
+}
+
+// TunnelUpstream returns the service URL a single-upstream tunnel (e.g. an ngrok agent) should
+// forward to: the first HTTP or HTTPS ingress service, or else the first service of the compose,
+// by name, that declares a port. Returns "" when there is neither.
+func TunnelUpstream(composeContent, composeOverride string, composeFiles map[string]string, services []string) (string, error) {
+	for _, s := range services {
+		u, err := url.Parse(strings.TrimSpace(s))
+		if err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Hostname() != "" {
+			return u.String(), nil
+		}
+	}
+
+	targets, serviceNames, err := loadIngressTargets(composeContent, composeOverride, composeFiles)
+	if err != nil {
+		return "", err
+	}
+	for _, name := range serviceNames {
+		if target := targets[strings.ToLower(name)]; target != nil && len(target.ports) > 0 {
+			return fmt.Sprintf("http://%s:%d", name, target.ports[0]), nil
+		}
+	}
+	return "", nil
+}
+
+// loadIngressTargets loads the app's compose and override and returns its services by every name
+// they can be reached by, along with the sorted service names
+func loadIngressTargets(composeContent, composeOverride string, composeFiles map[string]string) (map[string]*ingressTarget, []string, error) {
+	files := []composetypes.ConfigFile{{Filename: ComposeFileName, Content: []byte(composeContent)}}
+	if strings.TrimSpace(composeOverride) != "" {
+		files = append(files, composetypes.ConfigFile{Filename: ComposeOverrideFileName, Content: []byte(composeOverride)})
+	}
+	project, err := loadComposeProject(composeFiles, files...)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	targets := make(map[string]*ingressTarget)
+	var serviceNames []string
+	for name, svc := range project.Services {
+		target := &ingressTarget{service: name, ports: declaredPorts(svc)}
+		serviceNames = append(serviceNames, name)
+		for _, alias := range serviceAliases(name, svc) {
+			targets[strings.ToLower(alias)] = target
+		}
+	}
+	slices.Sort(serviceNames)
+	return targets, serviceNames, nil
+}
+
+// checkIngressTarget returns why a single service URL looks wrong, or "" when it looks fine or
+// can't be checked
+func checkIngressTarget(service string, targets map[string]*ingressTarget, serviceNames []string) string {
+	service = strings.TrimSpace(service)
+	if service == "" || !strings.Contains(service, "://") {
+		// http_status:404, hello_world and similar built-ins
+		return ""
+	}
+	u, err := url.Parse(service)
+	if err != nil || u.Hostname() == "" {
+		return fmt.Sprintf("%q is not a valid service URL", service)
+	}
+	if u.Scheme == "unix" {
+		return ""
+	}
+
+	host := strings.ToLower(u.Hostname())
+	if ip := net.ParseIP(host); host == "localhost" || (ip != nil && ip.IsLoopback()) {
+		return "localhost is the tunnel container itself, not your app; use the compose service name instead"
+	}
+	if net.ParseIP(host) != nil || strings.Contains(host, ".") {
+		// Reached outside the compose project; nothing to compare against
+		return ""
+	}
+
+	target, ok := targets[host]
+	if !ok {
+		msg := fmt.Sprintf("no service named %q in the app's compose", host)
+		if suggestion := closestName(host, serviceNames); suggestion != "" {
+			msg += fmt.Sprintf(" (did you mean %q?)", suggestion)
+		}
+		return msg
+	}
+
+	port := defaultSchemePort(u.Scheme)
+	if p := u.Port(); p != "" {
+		port, _ = strconv.Atoi(p)
+	}
+	if port == 0 || len(target.ports) == 0 || slices.Contains(target.ports, port) {
+		return ""
+	}
+	declared := make([]string, len(target.ports))
+	for i, p := range target.ports {
+		declared[i] = strconv.Itoa(p)
+	}
+	return fmt.Sprintf("service %q doesn't declare port %d (it declares %s)", target.service, port, strings.Join(declared, ", "))
+}
+
+// serviceAliases returns the names other containers on the app's networks can reach a service by
+func serviceAliases(name string, svc composetypes.ServiceConfig) []string {
+	aliases := []string{name}
+	if svc.ContainerName != "" {
+		aliases = append(aliases, svc.ContainerName)
+	}
+	if svc.Hostname != "" {
+		aliases = append(aliases, svc.Hostname)
+	}
+	for _, network := range svc.Networks {
+		if network != nil {
+			aliases = append(aliases, network.Aliases...)
+		}
+	}
+	return aliases
+}
+
+// declaredPorts returns the sorted container ports a service publishes or exposes
+func declaredPorts(svc composetypes.ServiceConfig) []int {
+	var ports []int
+	for _, p := range svc.Ports {
+		ports = append(ports, int(p.Target))
+	}
+	for _, e := range svc.Expose {
+		spec, _, _ := strings.Cut(e, "/")
+		first, last, isRange := strings.Cut(spec, "-")
+		start, err := strconv.Atoi(first)
+		if err != nil {
+			continue
+		}
+		end := start
+		if isRange {
+			if end, err = strconv.Atoi(last); err != nil || end < start {
+				continue
+			}
+		}
+		for p := start; p <= end; p++ {
+			ports = append(ports, p)
+		}
+	}
+	slices.Sort(ports)
+	return slices.Compact(ports)
+}
+
+// defaultSchemePort is the port a service URL without one connects to, or 0 if unknown
+func defaultSchemePort(scheme string) int {
+	switch scheme {
+	case "http", "ws":
+		return 80
+	case "https", "wss":
+		return 443
+	default:
+		return 0
+	}
+}
+
+// closestName returns the candidate within a small edit distance of name, for "did you mean"
+// hints, or "" if none is close
+func closestName(name string, candidates []string) string {
+	best, bestDistance := "", max(2, len(name)/3)+1
+	for _, c := range candidates {
+		if d := editDistance(name, strings.ToLower(c)); d < bestDistance {
+			best, bestDistance = c, d
+		}
+	}
+	return best
+}
+
+// editDistance is the Levenshtein distance between a and b
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = min(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(b)]
+}
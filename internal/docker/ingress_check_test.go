@@ -0,0 +1,143 @@
+package docker
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCheckIngressTargets(t *testing.T) {
+	compose := `services:
+  web:
+    image: nginx
+    ports:
+      - "8080:80"
+  api:
+    image: myapi
+    container_name: blog-api
+    expose:
+      - "3000-3001"
+  worker:
+    image: myworker
+`
+	override := `services:
+  web:
+    expose:
+      - "8443"
+`
+
+	tests := []struct {
+		name    string
+		service string
+		want    string // Substring of the warning; empty for none
+	}{
+		{"published container port", "http://web:80", ""},
+		{"default http port", "http://web", ""},
+		{"port from override", "https://web:8443", ""},
+		{"container name and exposed range", "http://blog-api:3001", ""},
+		{"service without declared ports", "http://worker:9000", ""},
+		{"built-in service", "http_status:404", ""},
+		{"external host", "http://192.168.1.20:8123", ""},
+		{"fqdn", "https://example.com", ""},
+		{"renamed service", "http://wbe:80", `did you mean "web"`},
+		{"unknown service", "http://database:5432", `no service named "database"`},
+		{"wrong port", "http://web:8080", `doesn't declare port 8080 (it declares 80, 8443)`},
+		{"localhost", "http://localhost:8080", "tunnel container itself"},
+	}
+
+	services := make([]string, len(tests))
+	for i, tt := range tests {
+		services[i] = tt.service
+	}
+	warnings, err := CheckIngressTargets(compose, override, nil, services)
+	if err != nil {
+		t.Fatalf("CheckIngressTargets: %v", err)
+	}
+	byRule := make(map[int]string)
+	for _, w := range warnings {
+		byRule[w.Rule] = w.Message
+	}
+
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := byRule[i]
+			if tt.want == "" && got != "" {
+				t.Errorf("Expected no warning for %s, got %q", tt.service, got)
+			}
+			if tt.want != "" && !strings.Contains(got, tt.want) {
+				t.Errorf("Expected warning containing %q for %s, got %q", tt.want, tt.service, got)
+			}
+		})
+	}
+}
+
+func TestQuickTunnelTargetFromIngress(t *testing.T) {
+	compose := `services:
+  web:
+    image: nginx
+  api:
+    image: myapi
+    container_name: blog-api
+`
+	tests := []struct {
+		name     string
+		services []string
+		service  string
+		port     int
+		ok       bool
+	}{
+		{"explicit port", []string{"http://web:8080"}, "web", 8080, true},
+		{"default http port", []string{"http://web"}, "web", 80, true},
+		{"container name resolves to its service", []string{"http://blog-api:3000"}, "api", 3000, true},
+		{"first usable rule wins", []string{"http_status:404", "https://web:443", "http://external.example.com", "http://api:3000"}, "api", 3000, true},
+		{"nothing in the compose", []string{"http://192.168.1.20:8123", "https://web"}, "", 0, false},
+		{"no rules", nil, "", 0, false},
+	}
+	for _, tt := range tests {
+		service, port, ok, err := QuickTunnelTargetFromIngress(compose, "", nil, tt.services)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if service != tt.service || port != tt.port || ok != tt.ok {
+			t.Errorf("%s: got %q, %d, %v; want %q, %d, %v", tt.name, service, port, ok, tt.service, tt.port, tt.ok)
+		}
+	}
+}
+
+func TestTunnelUpstream(t *testing.T) {
+	compose := `services:
+  web:
+    image: nginx
+    ports:
+      - "8080:80"
+  api:
+    image: myapi
+    expose:
+      - "3000"
+  worker:
+    image: myworker
+`
+	tests := []struct {
+		name     string
+		services []string
+		want     string
+	}{
+		{"first HTTP rule wins", []string{"http_status:404", "http://web:80", "https://api:3000"}, "http://web:80"},
+		{"outside the compose", []string{"http://192.168.1.20:8123"}, "http://192.168.1.20:8123"},
+		{"first service with a port", nil, "http://api:3000"},
+		{"only non-HTTP rules", []string{"http_status:404"}, "http://api:3000"},
+	}
+	for _, tt := range tests {
+		got, err := TunnelUpstream(compose, "", nil, tt.services)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if got != tt.want {
+			t.Errorf("%s: got %q, want %q", tt.name, got, tt.want)
+		}
+	}
+
+	got, err := TunnelUpstream("services:\n  worker:\n    image: myworker\n", "", nil, nil)
+	if err != nil || got != "" {
+		t.Errorf("expected no upstream without ports, got %q, %v", got, err)
+	}
+}
@@ -0,0 +1,142 @@
+package docker
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/selfhostly/internal/constants"
+	"gopkg.in/yaml.v3"
+)
+
+// LogForwarding is where an app's log forwarding sidecar ships its container logs
+type LogForwarding struct {
+	Destination string // loki or elasticsearch
+	URL         string // Loki server or Elasticsearch endpoint
+	Username    string // Basic auth, when set
+	Password    string
+	Index       string // Elasticsearch index; DefaultLogForwardingIndex when empty
+	NodeName    string // Added to every line so logs from several nodes can be told apart
+}
+
+// ValidateLogForwarding checks a destination and its URL
+func ValidateLogForwarding(destination, rawURL string) error {
+	switch destination {
+	case constants.LogForwardingLoki, constants.LogForwardingElasticsearch:
+	default:
+		return fmt.Errorf("destination must be %s or %s", constants.LogForwardingLoki, constants.LogForwardingElasticsearch)
+	}
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("url must be an http(s) URL, e.g. http://loki:3100")
+	}
+	return nil
+}
+
+// RenderLogForwarding returns the compose file adding a Vector sidecar to the app, and the Vector
+// config it runs. The sidecar reads the logs of every container in the app's compose project from
+// the Docker socket and tags them with the app, service and node.
+func RenderLogForwarding(appName string, cfg LogForwarding) (composeContent, vectorConfig string, err error) {
+	if err := ValidateLogForwarding(cfg.Destination, cfg.URL); err != nil {
+		return "", "", err
+	}
+
+	compose := map[string]any{
+		"services": map[string]any{
+			constants.LogForwarderService: map[string]any{
+				"image":   constants.LogForwarderImage,
+				"restart": "unless-stopped",
+				"volumes": []string{
+					"/var/run/docker.sock:/var/run/docker.sock:ro",
+					"./" + VectorConfigFileName + ":/etc/vector/vector.yaml:ro",
+				},
+			},
+		},
+	}
+
+	// VRL string literals use JSON's escaping
+	appLiteral, _ := json.Marshal(appName)
+	nodeLiteral, _ := json.Marshal(cfg.NodeName)
+	tag := fmt.Sprintf(".app = %s\n.node = %s\n.service = .label.\"com.docker.compose.service\"\ndel(.label)\n", appLiteral, nodeLiteral)
+
+	sink := map[string]any{"inputs": []string{"tag"}}
+	switch cfg.Destination {
+	case constants.LogForwardingLoki:
+		sink["type"] = "loki"
+		sink["endpoint"] = cfg.URL
+		sink["encoding"] = map[string]any{"codec": "json"}
+		sink["labels"] = map[string]string{
+			"job":     "selfhostly",
+			"app":     appName,
+			"node":    cfg.NodeName,
+			"service": "{{ service }}",
+		}
+	case constants.LogForwardingElasticsearch:
+		index := cfg.Index
+		if index == "" {
+			index = constants.DefaultLogForwardingIndex
+		}
+		sink["type"] = "elasticsearch"
+		sink["endpoints"] = []string{cfg.URL}
+		sink["bulk"] = map[string]any{"index": index}
+	}
+	if cfg.Username != "" {
+		sink["auth"] = map[string]any{"strategy": "basic", "user": cfg.Username, "password": cfg.Password}
+	}
+
+	vector := map[string]any{
+		"sources": map[string]any{
+			"app": map[string]any{
+				"type":           "docker_logs",
+				"include_labels": []string{"com.docker.compose.project=" + composeProjectName(appName)},
+			},
+		},
+		"transforms": map[string]any{
+			"tag": map[string]any{"type": "remap", "inputs": []string{"app"}, "source": tag},
+		},
+		"sinks": map[string]any{"out": sink},
+	}
+
+	composeBytes, err := yaml.Marshal(compose)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to render log forwarding compose file: %w", err)
+	}
+	vectorBytes, err := yaml.Marshal(vector)
+	if err != nil {
+		return "", "", fmt.Errorf("failed to render log forwarding config: %w", err)
+	}
+	return "# Generated by selfhostly for log forwarding; changes are overwritten\n" + string(composeBytes), string(vectorBytes), nil
+}
+
+// WriteLogForwardingFiles writes or, when composeContent is empty, removes an app's log
+// forwarding sidecar and its config. It reports whether either file changed, in which case a
+// running app needs reconciling to pick the change up. The config holds the destination's
+// credentials, so it is readable only by the owner.
+func (m *Manager) WriteLogForwardingFiles(name, composeContent, vectorConfig string) (bool, error) {
+	appPath := m.AppPath(name)
+	composePath := filepath.Join(appPath, ComposeLoggingFileName)
+	configPath := filepath.Join(appPath, VectorConfigFileName)
+
+	currentCompose, _ := os.ReadFile(composePath)
+	currentConfig, _ := os.ReadFile(configPath)
+	if string(currentCompose) == composeContent && string(currentConfig) == vectorConfig {
+		return false, nil
+	}
+
+	if strings.TrimSpace(composeContent) == "" {
+		if err := os.Remove(configPath); err != nil && !os.IsNotExist(err) {
+			return false, fmt.Errorf("failed to remove %s: %w", VectorConfigFileName, err)
+		}
+		return true, m.writeOptionalComposeFile(name, ComposeLoggingFileName, "")
+	}
+
+	if err := os.WriteFile(configPath, []byte(vectorConfig), 0600); err != nil {
+		slog.Error("failed to write log forwarding config", "app", name, "configPath", configPath, "error", err)
+		return false, fmt.Errorf("failed to write %s: %w", VectorConfigFileName, err)
+	}
+	return true, m.writeOptionalComposeFile(name, ComposeLoggingFileName, composeContent)
+}
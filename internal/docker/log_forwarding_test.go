@@ -0,0 +1,105 @@
+package docker
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/selfhostly/internal/constants"
+	"gopkg.in/yaml.v3"
+)
+
+func TestRenderLogForwarding(t *testing.T) {
+	composeContent, vectorConfig, err := RenderLogForwarding("MyApp", LogForwarding{
+		Destination: constants.LogForwardingLoki,
+		URL:         "http://loki:3100",
+		Username:    "grafana",
+		Password:    "s3cret",
+		NodeName:    "edge-1",
+	})
+	if err != nil {
+		t.Fatalf("RenderLogForwarding: %v", err)
+	}
+
+	compose, err := ParseCompose([]byte(composeContent))
+	if err != nil {
+		t.Fatalf("generated compose file does not parse: %v", err)
+	}
+	if service, ok := compose.Services[constants.LogForwarderService]; !ok || service.Image != constants.LogForwarderImage {
+		t.Errorf("expected the %s sidecar, got %+v", constants.LogForwarderService, compose.Services)
+	}
+
+	var vector struct {
+		Sources map[string]struct {
+			IncludeLabels []string `yaml:"include_labels"`
+		} `yaml:"sources"`
+		Transforms map[string]struct {
+			Source string `yaml:"source"`
+		} `yaml:"transforms"`
+		Sinks map[string]map[string]any `yaml:"sinks"`
+	}
+	if err := yaml.Unmarshal([]byte(vectorConfig), &vector); err != nil {
+		t.Fatalf("generated vector config does not parse: %v", err)
+	}
+	if labels := vector.Sources["app"].IncludeLabels; len(labels) != 1 || labels[0] != "com.docker.compose.project=myapp" {
+		t.Errorf("expected the source to follow the app's compose project, got %v", labels)
+	}
+	if source := vector.Transforms["tag"].Source; !strings.Contains(source, `.app = "MyApp"`) || !strings.Contains(source, `.node = "edge-1"`) {
+		t.Errorf("expected lines to be tagged with the app and node, got %q", source)
+	}
+	sink := vector.Sinks["out"]
+	if sink["type"] != "loki" || sink["endpoint"] != "http://loki:3100" || sink["auth"] == nil {
+		t.Errorf("unexpected loki sink %+v", sink)
+	}
+
+	_, vectorConfig, err = RenderLogForwarding("web", LogForwarding{Destination: constants.LogForwardingElasticsearch, URL: "https://es:9200"})
+	if err != nil {
+		t.Fatalf("RenderLogForwarding: %v", err)
+	}
+	if !strings.Contains(vectorConfig, "type: elasticsearch") || !strings.Contains(vectorConfig, constants.DefaultLogForwardingIndex) || strings.Contains(vectorConfig, "auth") {
+		t.Errorf("expected an elasticsearch sink on the default index without auth, got %s", vectorConfig)
+	}
+
+	for _, cfg := range []LogForwarding{
+		{Destination: "splunk", URL: "http://splunk:8088"},
+		{Destination: constants.LogForwardingLoki, URL: "loki:3100"},
+	} {
+		if _, _, err := RenderLogForwarding("web", cfg); err == nil {
+			t.Errorf("expected %+v to be rejected", cfg)
+		}
+	}
+}
+
+func TestWriteLogForwardingFiles(t *testing.T) {
+	appsDir := t.TempDir()
+	m := NewManagerWithExecutor(appsDir, NewMockCommandExecutor())
+	if err := m.CreateAppDirectory("web", "services:\n  web:\n    image: nginx\n"); err != nil {
+		t.Fatal(err)
+	}
+
+	changed, err := m.WriteLogForwardingFiles("web", "services: {}\n", "sinks: {}\n")
+	if err != nil || !changed {
+		t.Fatalf("expected the files to be written, got changed=%v err=%v", changed, err)
+	}
+	info, err := os.Stat(filepath.Join(appsDir, "web", VectorConfigFileName))
+	if err != nil || info.Mode().Perm() != 0600 {
+		t.Errorf("expected the config to be readable only by the owner, got %v (%v)", info, err)
+	}
+	if changed, _ := m.WriteLogForwardingFiles("web", "services: {}\n", "sinks: {}\n"); changed {
+		t.Error("expected identical files to be reported unchanged")
+	}
+
+	if args := composeArgs(filepath.Join(appsDir, "web"), ComposeUpCommand()); !strings.Contains(strings.Join(args, " "), ComposeLoggingFileName) {
+		t.Errorf("expected compose commands to include %s, got %v", ComposeLoggingFileName, args)
+	}
+
+	if changed, err := m.WriteLogForwardingFiles("web", "", ""); err != nil || !changed {
+		t.Fatalf("expected the files to be removed, got changed=%v err=%v", changed, err)
+	}
+	for _, name := range []string{ComposeLoggingFileName, VectorConfigFileName} {
+		if _, err := os.Stat(filepath.Join(appsDir, "web", name)); !os.IsNotExist(err) {
+			t.Errorf("expected %s to be removed, got %v", name, err)
+		}
+	}
+}
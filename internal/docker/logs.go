@@ -0,0 +1,74 @@
+package docker
+
+import (
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// replicaSuffixRegex matches the "-N" replica index compose appends to service names in log prefixes
+var replicaSuffixRegex = regexp.MustCompile(`-\d+$`)
+
+// LogEntry represents a single timestamped line from docker compose logs
+type LogEntry struct {
+	Service   string
+	Timestamp time.Time
+	Message   string
+}
+
+// ParseComposeLogs parses output of "docker compose logs --no-color --timestamps"
+// Lines look like "web-1  | 2024-01-15T10:00:00.123456789Z message". Lines without a
+// parseable timestamp are kept with a zero Timestamp so no output is silently dropped.
+func ParseComposeLogs(output []byte) []LogEntry {
+	var entries []LogEntry
+	for _, line := range strings.Split(string(output), "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+
+		entry := LogEntry{Message: line}
+		if prefix, rest, found := strings.Cut(line, "|"); found {
+			entry.Service = replicaSuffixRegex.ReplaceAllString(strings.TrimSpace(prefix), "")
+			entry.Message = strings.TrimPrefix(rest, " ")
+		}
+
+		if ts, msg, found := strings.Cut(entry.Message, " "); found {
+			if parsed, err := time.Parse(time.RFC3339Nano, ts); err == nil {
+				entry.Timestamp = parsed
+				entry.Message = msg
+			}
+		}
+
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+// GetAppLogEntries fetches timestamped logs for all services of an app in chronological order
+// since accepts anything docker understands (e.g. "15m", "2024-01-15T10:00:00Z"); empty means no lower bound
+func (m *Manager) GetAppLogEntries(name string, since string, tailLines int) ([]LogEntry, error) {
+	appPath := m.AppPath(name)
+
+	cmd := ComposeLogsSinceCommand(tailLines, since)
+	slog.Debug("fetching app log entries", "app", name, "since", since, "tail", tailLines)
+
+	output, err := m.runCompose(appPath, cmd)
+	if err != nil {
+		slog.Error("failed to get app log entries", "app", name, "error", err, "output", string(output))
+		return nil, fmt.Errorf("failed to get logs: %w\nOutput: %s", err, string(output))
+	}
+
+	return ParseComposeLogs(output), nil
+}
+
+// TailAppLogs returns the last tailLines lines of an app's compose output in chronological order,
+// for one service or for all of them when service is empty
+func (m *Manager) TailAppLogs(name, service string, tailLines int) (string, error) {
+	output, err := m.runCompose(m.AppPath(name), ComposeLogsTailCommand(tailLines, service))
+	if err != nil {
+		return "", fmt.Errorf("failed to get logs: %w\nOutput: %s", err, string(output))
+	}
+	return strings.TrimRight(string(output), "\n"), nil
+}
@@ -0,0 +1,50 @@
+package docker
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestComposeLogsSinceCommand(t *testing.T) {
+	cmd := ComposeLogsSinceCommand(500, "15m")
+	want := []string{DockerCommand, ComposeCommand, ComposeFileFlag, ComposeFileName, ComposeSubcommandLogs, "--no-color", "--timestamps", "--tail=500", "--since=15m"}
+	if !reflect.DeepEqual(cmd, want) {
+		t.Errorf("ComposeLogsSinceCommand() = %v, want %v", cmd, want)
+	}
+
+	cmd = ComposeLogsSinceCommand(500, "")
+	want = []string{DockerCommand, ComposeCommand, ComposeFileFlag, ComposeFileName, ComposeSubcommandLogs, "--no-color", "--timestamps", "--tail=500"}
+	if !reflect.DeepEqual(cmd, want) {
+		t.Errorf("ComposeLogsSinceCommand() without since = %v, want %v", cmd, want)
+	}
+}
+
+func TestParseComposeLogs(t *testing.T) {
+	output := []byte("web-1  | 2024-01-15T10:00:00.123456789Z GET / 200\n" +
+		"\n" +
+		"db-12  | 2024-01-15T10:00:01Z ready | accepting connections\n" +
+		"no prefix line\n")
+
+	entries := ParseComposeLogs(output)
+	if len(entries) != 3 {
+		t.Fatalf("expected 3 entries, got %d", len(entries))
+	}
+
+	if entries[0].Service != "web" || entries[0].Message != "GET / 200" {
+		t.Errorf("unexpected first entry: %+v", entries[0])
+	}
+	wantTS := time.Date(2024, 1, 15, 10, 0, 0, 123456789, time.UTC)
+	if !entries[0].Timestamp.Equal(wantTS) {
+		t.Errorf("expected timestamp %v, got %v", wantTS, entries[0].Timestamp)
+	}
+
+	// Only the first separator splits the prefix; the message may contain pipes
+	if entries[1].Service != "db" || entries[1].Message != "ready | accepting connections" {
+		t.Errorf("unexpected second entry: %+v", entries[1])
+	}
+
+	if entries[2].Service != "" || !entries[2].Timestamp.IsZero() || entries[2].Message != "no prefix line" {
+		t.Errorf("unexpected third entry: %+v", entries[2])
+	}
+}
@@ -2,6 +2,7 @@ package docker
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
 	"os"
@@ -10,6 +11,7 @@ import (
 	"time"
 
 	"github.com/selfhostly/internal/constants"
+	"github.com/selfhostly/internal/trash"
 )
 
 // ProgressCallback is a function that receives progress updates during long operations
@@ -18,7 +20,9 @@ type ProgressCallback func(progress int, message string)
 // Manager handles Docker operations
 type Manager struct {
 	appsDir         string
+	storageRoots    []StorageRoot // Named roots besides appsDir, sorted by name
 	commandExecutor CommandExecutor
+	networkRecorder NetworkRecorder // Optional; told about each app's networks after it is deployed
 }
 
 // NewManager creates a new Docker manager with default command executor
@@ -50,7 +54,20 @@ func (m *Manager) directoryExists(path string) bool {
 
 // CreateAppDirectory creates an app directory and writes compose file
 func (m *Manager) CreateAppDirectory(name, composeContent string) error {
-	appPath := filepath.Join(m.appsDir, name)
+	return m.createAppDirectory(m.AppPath(name), name, composeContent)
+}
+
+// CreateAppDirectoryIn creates an app directory under the named storage root and writes compose file.
+// An empty root is the apps directory.
+func (m *Manager) CreateAppDirectoryIn(root, name, composeContent string) error {
+	rootDir, ok := m.StorageRootDir(root)
+	if !ok {
+		return fmt.Errorf("unknown storage root: %s", root)
+	}
+	return m.createAppDirectory(filepath.Join(rootDir, name), name, composeContent)
+}
+
+func (m *Manager) createAppDirectory(appPath, name, composeContent string) error {
 	composePath := filepath.Join(appPath, "docker-compose.yml")
 
 	slog.Info("creating app directory", "app", name, "appPath", appPath, "composePath", composePath)
@@ -62,33 +79,183 @@ func (m *Manager) CreateAppDirectory(name, composeContent string) error {
 	}
 
 	// Write docker-compose.yml
-	if err := os.WriteFile(composePath, []byte(composeContent), 0644); err != nil {
+	if err := writeFileAtomic(composePath, []byte(composeContent), 0644); err != nil {
 		slog.Error("failed to write compose file", "app", name, "composePath", composePath, "error", err)
 		return fmt.Errorf("failed to write compose file: %w", err)
 	}
+	if err := m.writeArchComposeFile(name, composeContent); err != nil {
+		return err
+	}
 
 	slog.Info("app directory created successfully", "app", name, "appPath", appPath, "composeSize", len(composeContent))
 	return nil
 }
 
-// WriteComposeFile writes the compose file content to the app directory
+// WriteComposeFile writes the compose file content to the app directory. The file is replaced
+// atomically, so a crash mid-write leaves either the old or the new file, and the previous
+// content is kept in ComposeBackupFileName.
 func (m *Manager) WriteComposeFile(name, content string) error {
-	composePath := filepath.Join(m.appsDir, name, "docker-compose.yml")
+	composePath := filepath.Join(m.AppPath(name), ComposeFileName)
 
 	slog.Info("writing compose file", "app", name, "composePath", composePath, "composeSize", len(content))
 
-	if err := os.WriteFile(composePath, []byte(content), 0644); err != nil {
+	previous, err := os.ReadFile(composePath)
+	if err != nil && !os.IsNotExist(err) {
+		slog.Error("failed to read compose file", "app", name, "composePath", composePath, "error", err)
+		return fmt.Errorf("failed to read compose file: %w", err)
+	}
+	if err == nil && string(previous) != content {
+		backupPath := filepath.Join(m.AppPath(name), ComposeBackupFileName)
+		if err := writeFileAtomic(backupPath, previous, 0644); err != nil {
+			slog.Error("failed to back up compose file", "app", name, "backupPath", backupPath, "error", err)
+			return fmt.Errorf("failed to back up compose file: %w", err)
+		}
+	}
+
+	if err := writeFileAtomic(composePath, []byte(content), 0644); err != nil {
 		slog.Error("failed to write compose file", "app", name, "composePath", composePath, "error", err)
 		return fmt.Errorf("failed to write compose file: %w", err)
 	}
+	if err := m.writeArchComposeFile(name, content); err != nil {
+		return err
+	}
 
 	slog.Info("compose file written successfully", "app", name, "composePath", composePath)
 	return nil
 }
 
+// ReadComposeFile returns the content of the app's docker-compose.yml as it is on disk
+func (m *Manager) ReadComposeFile(name string) (string, error) {
+	content, err := os.ReadFile(filepath.Join(m.AppPath(name), ComposeFileName))
+	if err != nil {
+		return "", err
+	}
+	return string(content), nil
+}
+
+// WriteComposeOverrideFile writes the compose override file to the app directory
+// An empty content removes the override file so only the base compose file is used
+func (m *Manager) WriteComposeOverrideFile(name, content string) error {
+	return m.writeOptionalComposeFile(name, ComposeOverrideFileName, content)
+}
+
+// WriteTunnelComposeFile writes the generated tunnel sidecar compose file to the app directory
+// An empty content removes the file, e.g. after the app's tunnel was deleted
+func (m *Manager) WriteTunnelComposeFile(name, content string) error {
+	return m.writeOptionalComposeFile(name, ComposeTunnelFileName, content)
+}
+
+// writeOptionalComposeFile writes or removes a compose file that is layered on top of the base compose file
+func (m *Manager) writeOptionalComposeFile(name, fileName, content string) error {
+	filePath := filepath.Join(m.AppPath(name), fileName)
+
+	if strings.TrimSpace(content) == "" {
+		if err := os.Remove(filePath); err != nil && !os.IsNotExist(err) {
+			slog.Error("failed to remove compose file", "app", name, "filePath", filePath, "error", err)
+			return fmt.Errorf("failed to remove %s: %w", fileName, err)
+		}
+		return nil
+	}
+
+	slog.Info("writing compose file", "app", name, "filePath", filePath, "size", len(content))
+
+	if err := writeFileAtomic(filePath, []byte(content), 0644); err != nil {
+		slog.Error("failed to write compose file", "app", name, "filePath", filePath, "error", err)
+		return fmt.Errorf("failed to write %s: %w", fileName, err)
+	}
+
+	return nil
+}
+
+// writeFileAtomic replaces file with data: it is written and synced to a temp file in the same
+// directory, which is then renamed over file, so readers never see it half written
+func writeFileAtomic(file string, data []byte, perm os.FileMode) error {
+	dir := filepath.Dir(file)
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(file)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(perm); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), file); err != nil {
+		return err
+	}
+
+	// Sync the directory too, so the rename itself survives a crash
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
+
+// runCompose executes a docker compose command in the app directory.
+// Commands pin "-f docker-compose.yml", which disables compose's automatic override loading,
+// so the generated per-architecture, tunnel and log forwarding files and the user override are
+// added explicitly when present. The user override goes last so it can still adjust the sidecars.
+func (m *Manager) runCompose(appPath string, cmd []string) ([]byte, error) {
+	return m.commandExecutor.ExecuteCommandInDir(appPath, cmd[0], composeArgs(appPath, cmd)...)
+}
+
+// runComposeContext is runCompose for commands that stop when ctx is done, on executors that
+// support it
+func (m *Manager) runComposeContext(ctx context.Context, appPath string, cmd []string) ([]byte, error) {
+	executor, ok := m.commandExecutor.(ContextCommandExecutor)
+	if !ok {
+		return m.runCompose(appPath, cmd)
+	}
+	return executor.ExecuteCommandInDirContext(ctx, appPath, cmd[0], composeArgs(appPath, cmd)...)
+}
+
+// composeArgs returns the arguments of a compose command with the tunnel and override files of the
+// app in appPath added
+func composeArgs(appPath string, cmd []string) []string {
+	var extraFiles []string
+	for _, fileName := range []string{ComposeArchFileName, ComposeTunnelFileName, ComposeTunnelStandbyFileName, ComposeLoggingFileName, ComposeOverrideFileName} {
+		if _, err := os.Stat(filepath.Join(appPath, fileName)); err == nil {
+			extraFiles = append(extraFiles, fileName)
+		}
+	}
+	return withComposeFiles(cmd[1:], extraFiles...)
+}
+
+// withComposeFiles inserts "-f <file>" for each extra file after the base compose file flag
+func withComposeFiles(args []string, files ...string) []string {
+	if len(files) == 0 {
+		return args
+	}
+	for i := 0; i+1 < len(args); i++ {
+		if args[i] == ComposeFileFlag && args[i+1] == ComposeFileName {
+			result := make([]string, 0, len(args)+2*len(files))
+			result = append(result, args[:i+2]...)
+			for _, f := range files {
+				result = append(result, ComposeFileFlag, f)
+			}
+			return append(result, args[i+2:]...)
+		}
+	}
+	return args
+}
+
 // StartApp starts the app using docker compose
 func (m *Manager) StartApp(name string) error {
-	appPath := filepath.Join(m.appsDir, name)
+	appPath := m.AppPath(name)
 
 	// Directory must exist for start operation
 	if !m.directoryExists(appPath) {
@@ -99,13 +266,14 @@ func (m *Manager) StartApp(name string) error {
 	slog.Info("starting app", "app", name, "appPath", appPath, "command", "docker compose up -d")
 
 	cmd := ComposeUpCommand()
-	output, err := m.commandExecutor.ExecuteCommandInDir(appPath, cmd[0], cmd[1:]...)
+	output, err := m.runCompose(appPath, cmd)
 	if err != nil {
 		slog.Error("failed to start app", "app", name, "error", err, "output", string(output))
-		return fmt.Errorf("failed to start app: %w\nOutput: %s", err, string(output))
+		return m.captureFailure(name, "start", fmt.Errorf("failed to start app: %w\nOutput: %s", err, string(output)), output)
 	}
 
 	slog.Info("app started successfully", "app", name, "output", string(output))
+	m.recordAppNetworks(name)
 	return nil
 }
 
@@ -113,7 +281,7 @@ func (m *Manager) StartApp(name string) error {
 // (e.g. after removing the tunnel service from compose). Use this when the compose file was changed
 // to remove a service so that the old container is stopped and removed.
 func (m *Manager) ReconcileApp(name string) error {
-	appPath := filepath.Join(m.appsDir, name)
+	appPath := m.AppPath(name)
 
 	// Directory must exist for reconcile operation
 	if !m.directoryExists(appPath) {
@@ -124,19 +292,20 @@ func (m *Manager) ReconcileApp(name string) error {
 	slog.Info("reconciling app", "app", name, "appPath", appPath, "command", "docker compose up -d --remove-orphans")
 
 	cmd := ComposeUpWithRemoveOrphansCommand()
-	output, err := m.commandExecutor.ExecuteCommandInDir(appPath, cmd[0], cmd[1:]...)
+	output, err := m.runCompose(appPath, cmd)
 	if err != nil {
 		slog.Error("failed to reconcile app", "app", name, "error", err, "output", string(output))
 		return fmt.Errorf("failed to reconcile app: %w\nOutput: %s", err, string(output))
 	}
 
 	slog.Info("app reconciled successfully", "app", name, "output", string(output))
+	m.recordAppNetworks(name)
 	return nil
 }
 
 // StopApp stops the app using docker compose
 func (m *Manager) StopApp(name string) error {
-	appPath := filepath.Join(m.appsDir, name)
+	appPath := m.AppPath(name)
 
 	// Check if directory exists first
 	if !m.directoryExists(appPath) {
@@ -147,7 +316,7 @@ func (m *Manager) StopApp(name string) error {
 	slog.Info("stopping app", "app", name, "appPath", appPath, "command", "docker compose down")
 
 	cmd := ComposeDownCommand()
-	output, err := m.commandExecutor.ExecuteCommandInDir(appPath, cmd[0], cmd[1:]...)
+	output, err := m.runCompose(appPath, cmd)
 	if err != nil {
 		slog.Error("failed to stop app", "app", name, "error", err, "output", string(output))
 		return fmt.Errorf("failed to stop app: %w\nOutput: %s", err, string(output))
@@ -159,7 +328,7 @@ func (m *Manager) StopApp(name string) error {
 
 // UpdateApp performs zero-downtime update
 func (m *Manager) UpdateApp(name string) error {
-	appPath := filepath.Join(m.appsDir, name)
+	appPath := m.AppPath(name)
 	composeFile := "docker-compose.yml"
 	composePath := filepath.Join(appPath, composeFile)
 
@@ -180,7 +349,7 @@ func (m *Manager) UpdateApp(name string) error {
 	// Step 1: Pull latest images (ignoring services with build configurations)
 	slog.Info("pulling latest images", "app", name, "command", "docker compose pull --ignore-buildable")
 	pullCmd := ComposePullCommand()
-	pullOutput, pullErr := m.commandExecutor.ExecuteCommandInDir(appPath, pullCmd[0], pullCmd[1:]...)
+	pullOutput, pullErr := m.runCompose(appPath, pullCmd)
 	if pullErr != nil {
 		// If pull fails (e.g., older docker compose version, or all services use build),
 		// log but continue - the 'up' command will handle building if needed
@@ -196,23 +365,24 @@ func (m *Manager) UpdateApp(name string) error {
 	// Step 2: Update app services with --build flag
 	slog.Info("updating app services", "app", name, "command", "docker compose up -d --build")
 	upCmd := ComposeUpWithBuildCommand()
-	upOutput, upErr := m.commandExecutor.ExecuteCommandInDir(appPath, upCmd[0], upCmd[1:]...)
+	upOutput, upErr := m.runCompose(appPath, upCmd)
 	if upErr != nil {
 		slog.Error("failed to update app services",
 			"app", name,
 			"error", upErr,
 			"output", string(upOutput),
 			"exitCode", upErr.Error())
-		return fmt.Errorf("failed to update app: %w\nCommand: docker compose -f %s up -d --build\nOutput: %s", upErr, composeFile, string(upOutput))
+		return m.captureFailure(name, "update", fmt.Errorf("failed to update app: %w\nCommand: docker compose -f %s up -d --build\nOutput: %s", upErr, composeFile, string(upOutput)), upOutput)
 	}
 
 	slog.Info("app updated successfully", "app", name, "output", string(upOutput))
+	m.recordAppNetworks(name)
 	return nil
 }
 
 // UpdateAppWithProgress performs zero-downtime update with progress callbacks
 func (m *Manager) UpdateAppWithProgress(ctx context.Context, name string, progressCb ProgressCallback) error {
-	appPath := filepath.Join(m.appsDir, name)
+	appPath := m.AppPath(name)
 	composeFile := "docker-compose.yml"
 	composePath := filepath.Join(appPath, composeFile)
 
@@ -239,7 +409,7 @@ func (m *Manager) UpdateAppWithProgress(ctx context.Context, name string, progre
 
 	slog.Info("pulling latest images", "app", name, "command", "docker compose pull --ignore-buildable")
 	pullCmd := ComposePullCommand()
-	pullOutput, pullErr := m.commandExecutor.ExecuteCommandInDir(appPath, pullCmd[0], pullCmd[1:]...)
+	pullOutput, pullErr := m.runCompose(appPath, pullCmd)
 	if pullErr != nil {
 		// If pull fails, log but continue
 		slog.Warn("failed to pull images, continuing with update",
@@ -257,13 +427,13 @@ func (m *Manager) UpdateAppWithProgress(ctx context.Context, name string, progre
 	// Step 2: Update app services with --build flag
 	slog.Info("updating app services", "app", name, "command", "docker compose up -d --build")
 	upCmd := ComposeUpWithBuildCommand()
-	upOutput, upErr := m.commandExecutor.ExecuteCommandInDir(appPath, upCmd[0], upCmd[1:]...)
+	upOutput, upErr := m.runCompose(appPath, upCmd)
 	if upErr != nil {
 		slog.Error("failed to update app services",
 			"app", name,
 			"error", upErr,
 			"output", string(upOutput))
-		return fmt.Errorf("failed to update app: %w\nOutput: %s", upErr, string(upOutput))
+		return m.captureFailure(name, "update", fmt.Errorf("failed to update app: %w\nOutput: %s", upErr, string(upOutput)), upOutput)
 	}
 
 	if progressCb != nil {
@@ -278,6 +448,7 @@ func (m *Manager) UpdateAppWithProgress(ctx context.Context, name string, progre
 	}
 
 	slog.Info("app updated successfully", "app", name)
+	m.recordAppNetworks(name)
 	return nil
 }
 
@@ -308,12 +479,12 @@ func (m *Manager) StartAppWithProgress(ctx context.Context, name string, progres
 // ForceRecreateTunnel forces the tunnel service to be recreated so it picks up new config (e.g. new TUNNEL_TOKEN after switch to custom domain).
 // The injected tunnel service is named "tunnel". If the app has no tunnel service, the command may fail; callers should log and ignore.
 func (m *Manager) ForceRecreateTunnel(name string) error {
-	appPath := filepath.Join(m.appsDir, name)
+	appPath := m.AppPath(name)
 
 	slog.Info("force-recreating tunnel service", "app", name, "appPath", appPath, "command", "docker compose up -d --force-recreate tunnel")
 
 	cmd := ComposeForceRecreateServiceCommand(ServiceTunnel)
-	output, err := m.commandExecutor.ExecuteCommandInDir(appPath, cmd[0], cmd[1:]...)
+	output, err := m.runCompose(appPath, cmd)
 	if err != nil {
 		slog.Warn("force-recreate tunnel failed (app may have no tunnel service)", "app", name, "error", err, "output", string(output))
 		return fmt.Errorf("force-recreate tunnel: %w\nOutput: %s", err, string(output))
@@ -323,14 +494,32 @@ func (m *Manager) ForceRecreateTunnel(name string) error {
 	return nil
 }
 
+// UpTunnel brings the tunnel service in line with its compose, starting or removing replicas so as
+// many run as it asks for. Replicas whose config is unchanged keep running.
+func (m *Manager) UpTunnel(name string) error {
+	appPath := m.AppPath(name)
+
+	slog.Info("updating tunnel service", "app", name, "appPath", appPath, "command", "docker compose up -d tunnel")
+
+	cmd := ComposeUpServiceCommand(ServiceTunnel)
+	output, err := m.runCompose(appPath, cmd)
+	if err != nil {
+		slog.Error("failed to update tunnel service", "app", name, "error", err, "output", string(output))
+		return fmt.Errorf("failed to update tunnel service: %w\nOutput: %s", err, string(output))
+	}
+
+	slog.Info("tunnel service updated successfully", "app", name, "output", string(output))
+	return nil
+}
+
 // GetAppStatus checks the status of app containers
 func (m *Manager) GetAppStatus(name string) (string, error) {
-	appPath := filepath.Join(m.appsDir, name)
+	appPath := m.AppPath(name)
 
 	slog.Debug("getting app status", "app", name, "appPath", appPath)
 
 	cmd := ComposePsCommand()
-	output, err := m.commandExecutor.ExecuteCommandInDir(appPath, cmd[0], cmd[1:]...)
+	output, err := m.runCompose(appPath, cmd)
 	if err != nil {
 		slog.Error("failed to get app status", "app", name, "error", err, "output", string(output))
 		return "unknown", fmt.Errorf("failed to get status: %w\nOutput: %s", err, string(output))
@@ -346,15 +535,34 @@ func (m *Manager) GetAppStatus(name string) (string, error) {
 	return constants.AppStatusStopped, nil
 }
 
+// CountRunningContainers returns how many of the app's containers are running ("docker compose ps -q")
+func (m *Manager) CountRunningContainers(name string) (int, error) {
+	appPath := m.AppPath(name)
+
+	output, err := m.runCompose(appPath, ComposePsQuietCommand())
+	if err != nil {
+		slog.Debug("failed to list running containers", "app", name, "error", err, "output", string(output))
+		return 0, fmt.Errorf("failed to list containers: %w\nOutput: %s", err, string(output))
+	}
+
+	count := 0
+	for _, line := range strings.Split(string(output), "\n") {
+		if strings.TrimSpace(line) != "" {
+			count++
+		}
+	}
+	return count, nil
+}
+
 // GetAppLogs fetches logs from the app
 // If service is empty, returns logs for all services
 func (m *Manager) GetAppLogs(name string, service string) ([]byte, error) {
-	appPath := filepath.Join(m.appsDir, name)
+	appPath := m.AppPath(name)
 
 	slog.Debug("fetching app logs", "app", name, "service", service, "appPath", appPath, "command", "docker compose logs --tail=100")
 
 	cmd := ComposeLogsCommand(100, service)
-	output, err := m.commandExecutor.ExecuteCommandInDir(appPath, cmd[0], cmd[1:]...)
+	output, err := m.runCompose(appPath, cmd)
 	if err != nil {
 		slog.Error("failed to get app logs", "app", name, "service", service, "error", err, "output", string(output))
 		return nil, fmt.Errorf("failed to get logs: %w\nOutput: %s", err, string(output))
@@ -383,12 +591,12 @@ func (m *Manager) GetAppLogs(name string, service string) ([]byte, error) {
 
 // GetAppServices returns the list of service names defined in the app's docker-compose.yml
 func (m *Manager) GetAppServices(name string) ([]string, error) {
-	appPath := filepath.Join(m.appsDir, name)
+	appPath := m.AppPath(name)
 
 	slog.Debug("fetching app services", "app", name, "appPath", appPath, "command", "docker compose config --services")
 
 	cmd := ComposeConfigServicesCommand()
-	output, err := m.commandExecutor.ExecuteCommandInDir(appPath, cmd[0], cmd[1:]...)
+	output, err := m.runCompose(appPath, cmd)
 	if err != nil {
 		slog.Error("failed to get app services", "app", name, "error", err, "output", string(output))
 		return nil, fmt.Errorf("failed to get services: %w\nOutput: %s", err, string(output))
@@ -421,7 +629,7 @@ func (m *Manager) GetAppServices(name string) ([]string, error) {
 
 // DeleteAppDirectory removes the app directory
 func (m *Manager) DeleteAppDirectory(name string) error {
-	appPath := filepath.Join(m.appsDir, name)
+	appPath := m.AppPath(name)
 
 	// Check if directory exists first
 	if !m.directoryExists(appPath) {
@@ -440,14 +648,116 @@ func (m *Manager) DeleteAppDirectory(name string) error {
 	return nil
 }
 
+// ArchiveAppDirectory writes a tarball of the app directory into trashDir and returns its path.
+// Returns an empty path if the directory doesn't exist.
+func (m *Manager) ArchiveAppDirectory(name, trashDir string) (string, error) {
+	appPath := m.AppPath(name)
+	if !m.directoryExists(appPath) {
+		slog.Info("app directory does not exist, nothing to archive", "app", name, "appPath", appPath)
+		return "", nil
+	}
+
+	archivePath, err := trash.Archive(appPath, trashDir, name)
+	if err != nil {
+		slog.Error("failed to archive app directory", "app", name, "appPath", appPath, "error", err)
+		return "", err
+	}
+
+	slog.Info("app directory archived", "app", name, "archivePath", archivePath)
+	return archivePath, nil
+}
+
+// AppDirectory returns the path of the app directory and whether it exists
+func (m *Manager) AppDirectory(name string) (string, bool) {
+	appPath := m.AppPath(name)
+	return appPath, m.directoryExists(appPath)
+}
+
+// ListAppContainers returns the names of the app's containers, running or not
+func (m *Manager) ListAppContainers(name string) ([]string, error) {
+	return m.listProjectResources("containers", DockerProjectContainersCommand(composeProjectName(name)))
+}
+
+// ListAppNetworks returns the names of the networks compose created for the app
+func (m *Manager) ListAppNetworks(name string) ([]string, error) {
+	return m.listProjectResources("networks", DockerProjectNetworksCommand(composeProjectName(name)))
+}
+
+// ListAppVolumes returns the names of the named volumes compose created for the app
+func (m *Manager) ListAppVolumes(name string) ([]string, error) {
+	return m.listProjectResources("volumes", DockerProjectVolumesCommand(composeProjectName(name)))
+}
+
+// ForceRemoveAppContainers force-removes every container of the app's compose project, for when
+// "docker compose down" fails or the compose file is gone. Returns the removed container names.
+func (m *Manager) ForceRemoveAppContainers(name string) ([]string, error) {
+	containers, err := m.ListAppContainers(name)
+	if err != nil {
+		return nil, err
+	}
+
+	var removed []string
+	var errs []error
+	for _, container := range containers {
+		if err := m.DeleteContainer(container); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		removed = append(removed, container)
+	}
+	return removed, errors.Join(errs...)
+}
+
+// RemoveAppVolumes removes the app's named volumes and returns their names. The app's containers
+// must already be removed, since docker refuses to remove volumes that are in use.
+func (m *Manager) RemoveAppVolumes(name string) ([]string, error) {
+	volumes, err := m.ListAppVolumes(name)
+	if err != nil || len(volumes) == 0 {
+		return nil, err
+	}
+
+	slog.Info("removing app volumes", "app", name, "volumes", volumes)
+
+	cmd := DockerVolumeRmCommand(volumes...)
+	output, err := m.commandExecutor.ExecuteCommand(cmd[0], cmd[1:]...)
+	if err != nil {
+		slog.Error("failed to remove app volumes", "app", name, "error", err, "output", string(output))
+		return nil, fmt.Errorf("failed to remove volumes: %w\nOutput: %s", err, string(output))
+	}
+
+	slog.Info("app volumes removed successfully", "app", name, "volumes", volumes)
+	return volumes, nil
+}
+
+// listProjectResources runs a docker listing command and returns one name per non-empty line
+func (m *Manager) listProjectResources(kind string, cmd []string) ([]string, error) {
+	output, err := m.commandExecutor.ExecuteCommand(cmd[0], cmd[1:]...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list %s: %w\nOutput: %s", kind, err, string(output))
+	}
+
+	var names []string
+	for _, line := range strings.Split(string(output), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			names = append(names, line)
+		}
+	}
+	return names, nil
+}
+
+// composeProjectName returns the project name compose derives from the app directory
+func composeProjectName(name string) string {
+	return strings.ToLower(name)
+}
+
 // RestartCloudflared restarts the cloudflared service to pick up new ingress configuration
 func (m *Manager) RestartCloudflared(name string) error {
-	appPath := filepath.Join(m.appsDir, name)
+	appPath := m.AppPath(name)
 
 	slog.Info("restarting cloudflared service", "app", name, "appPath", appPath, "command", "docker compose restart cloudflared")
 
 	cmd := ComposeRestartServiceCommand(ServiceCloudflared)
-	output, err := m.commandExecutor.ExecuteCommandInDir(appPath, cmd[0], cmd[1:]...)
+	output, err := m.runCompose(appPath, cmd)
 	if err != nil {
 		slog.Error("failed to restart cloudflared", "app", name, "error", err, "output", string(output))
 		return fmt.Errorf("failed to restart cloudflared: %w\nOutput: %s", err, string(output))
@@ -459,12 +769,12 @@ func (m *Manager) RestartCloudflared(name string) error {
 
 // RestartTunnelService restarts the generic tunnel service
 func (m *Manager) RestartTunnelService(name string) error {
-	appPath := filepath.Join(m.appsDir, name)
+	appPath := m.AppPath(name)
 
 	slog.Info("restarting tunnel service", "app", name, "appPath", appPath, "command", "docker compose restart tunnel")
 
 	cmd := ComposeRestartServiceCommand(ServiceTunnel)
-	output, err := m.commandExecutor.ExecuteCommandInDir(appPath, cmd[0], cmd[1:]...)
+	output, err := m.runCompose(appPath, cmd)
 	if err != nil {
 		slog.Error("failed to restart tunnel service", "app", name, "error", err, "output", string(output))
 		return fmt.Errorf("failed to restart tunnel service: %w\nOutput: %s", err, string(output))
@@ -476,7 +786,7 @@ func (m *Manager) RestartTunnelService(name string) error {
 
 // RestartAppService restarts a specific service within an app
 func (m *Manager) RestartAppService(appName string, serviceName string) error {
-	appPath := filepath.Join(m.appsDir, appName)
+	appPath := m.AppPath(appName)
 
 	// Check if directory exists first
 	if !m.directoryExists(appPath) {
@@ -487,7 +797,7 @@ func (m *Manager) RestartAppService(appName string, serviceName string) error {
 	slog.Info("restarting app service", "app", appName, "service", serviceName, "appPath", appPath, "command", fmt.Sprintf("docker compose restart %s", serviceName))
 
 	cmd := ComposeRestartServiceCommand(serviceName)
-	output, err := m.commandExecutor.ExecuteCommandInDir(appPath, cmd[0], cmd[1:]...)
+	output, err := m.runCompose(appPath, cmd)
 	if err != nil {
 		slog.Error("failed to restart app service", "app", appName, "service", serviceName, "error", err, "output", string(output))
 		return fmt.Errorf("failed to restart service %s: %w\nOutput: %s", serviceName, err, string(output))
@@ -499,7 +809,7 @@ func (m *Manager) RestartAppService(appName string, serviceName string) error {
 
 // StopTunnelService stops the generic tunnel service
 func (m *Manager) StopTunnelService(name string) error {
-	appPath := filepath.Join(m.appsDir, name)
+	appPath := m.AppPath(name)
 
 	// Check if directory exists first
 	if !m.directoryExists(appPath) {
@@ -510,7 +820,7 @@ func (m *Manager) StopTunnelService(name string) error {
 	slog.Info("stopping tunnel service", "app", name, "appPath", appPath, "command", "docker compose stop tunnel")
 
 	cmd := ComposeStopServiceCommand(ServiceTunnel)
-	output, err := m.commandExecutor.ExecuteCommandInDir(appPath, cmd[0], cmd[1:]...)
+	output, err := m.runCompose(appPath, cmd)
 	if err != nil {
 		// If service doesn't exist, that's okay - it's already stopped
 		slog.Debug("failed to stop tunnel service (may not exist)", "app", name, "error", err, "output", string(output))
@@ -524,7 +834,7 @@ func (m *Manager) StopTunnelService(name string) error {
 // RemoveTunnelService removes the generic tunnel service container
 // This is more aggressive than just stopping - it actually removes the container
 func (m *Manager) RemoveTunnelService(name string) error {
-	appPath := filepath.Join(m.appsDir, name)
+	appPath := m.AppPath(name)
 
 	// Check if directory exists first
 	if !m.directoryExists(appPath) {
@@ -535,7 +845,7 @@ func (m *Manager) RemoveTunnelService(name string) error {
 	slog.Info("removing tunnel service container", "app", name, "appPath", appPath, "command", "docker compose rm -f -s tunnel")
 
 	cmd := ComposeRemoveServiceCommand(ServiceTunnel)
-	output, err := m.commandExecutor.ExecuteCommandInDir(appPath, cmd[0], cmd[1:]...)
+	output, err := m.runCompose(appPath, cmd)
 	if err != nil {
 		// If service doesn't exist, that's okay - it's already removed
 		slog.Debug("failed to remove tunnel service (may not exist)", "app", name, "error", err, "output", string(output))
@@ -546,6 +856,65 @@ func (m *Manager) RemoveTunnelService(name string) error {
 	return nil
 }
 
+// StartStandbyTunnel writes a standby tunnel sidecar (see StandbyTunnelCompose) and starts it next to
+// the app's current tunnel container, which keeps running
+func (m *Manager) StartStandbyTunnel(name, standbyCompose string) error {
+	appPath := m.AppPath(name)
+	if !m.directoryExists(appPath) {
+		return fmt.Errorf("app directory not found: %s", appPath)
+	}
+	if err := m.writeOptionalComposeFile(name, ComposeTunnelStandbyFileName, standbyCompose); err != nil {
+		return err
+	}
+
+	slog.Info("starting standby tunnel service", "app", name, "appPath", appPath, "command", "docker compose up -d --force-recreate tunnel-standby")
+
+	output, err := m.runCompose(appPath, ComposeForceRecreateServiceCommand(ServiceTunnelStandby))
+	if err != nil {
+		slog.Error("failed to start standby tunnel service", "app", name, "error", err, "output", string(output))
+		return fmt.Errorf("failed to start standby tunnel: %w\nOutput: %s", err, string(output))
+	}
+	return nil
+}
+
+// RemoveStandbyTunnel removes the app's standby tunnel container and its compose file. It is a
+// no-op when the app has no standby tunnel.
+func (m *Manager) RemoveStandbyTunnel(name string) error {
+	appPath := m.AppPath(name)
+	if _, err := os.Stat(filepath.Join(appPath, ComposeTunnelStandbyFileName)); err != nil {
+		return nil
+	}
+
+	slog.Info("removing standby tunnel service", "app", name, "appPath", appPath, "command", "docker compose rm -f -s tunnel-standby")
+
+	if output, err := m.runCompose(appPath, ComposeRemoveServiceCommand(ServiceTunnelStandby)); err != nil {
+		// Keep the file so compose still knows the container and a later call can remove it
+		slog.Error("failed to remove standby tunnel service", "app", name, "error", err, "output", string(output))
+		return fmt.Errorf("failed to remove standby tunnel: %w\nOutput: %s", err, string(output))
+	}
+	return m.writeOptionalComposeFile(name, ComposeTunnelStandbyFileName, "")
+}
+
+// ServerVersion returns the Docker daemon version, or an error when the daemon can't be reached
+func (m *Manager) ServerVersion() (string, error) {
+	cmd := DockerServerVersionCommand()
+	output, err := m.commandExecutor.ExecuteCommand(cmd[0], cmd[1:]...)
+	if err != nil {
+		return "", fmt.Errorf("docker daemon unreachable: %w\nOutput: %s", err, strings.TrimSpace(string(output)))
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
+// DockerRootDir returns the directory the Docker daemon stores images and volumes in
+func (m *Manager) DockerRootDir() (string, error) {
+	cmd := DockerRootDirCommand()
+	output, err := m.commandExecutor.ExecuteCommand(cmd[0], cmd[1:]...)
+	if err != nil {
+		return "", fmt.Errorf("failed to get docker root dir: %w", err)
+	}
+	return strings.TrimSpace(string(output)), nil
+}
+
 // RestartContainer restarts a specific container by ID
 func (m *Manager) RestartContainer(containerID string) error {
 	slog.Info("restarting container", "containerID", containerID)
@@ -102,6 +102,44 @@ func TestWriteComposeFile(t *testing.T) {
 	}
 }
 
+func TestWriteComposeFile_KeepsBackup(t *testing.T) {
+	tmpDir := t.TempDir()
+	manager := NewManager(tmpDir)
+	appPath := filepath.Join(tmpDir, "test-app")
+	if err := manager.CreateAppDirectory("test-app", "services:\n  web:\n    image: nginx:1.25\n"); err != nil {
+		t.Fatalf("Failed to create app directory: %v", err)
+	}
+
+	if err := manager.WriteComposeFile("test-app", "services:\n  web:\n    image: nginx:1.27\n"); err != nil {
+		t.Fatalf("Failed to write compose file: %v", err)
+	}
+
+	backup, err := os.ReadFile(filepath.Join(appPath, ComposeBackupFileName))
+	if err != nil || !strings.Contains(string(backup), "nginx:1.25") {
+		t.Errorf("Expected the previous compose file to be kept, got %q (%v)", backup, err)
+	}
+	info, err := os.Stat(filepath.Join(appPath, ComposeFileName))
+	if err != nil || info.Mode().Perm() != 0644 {
+		t.Errorf("Expected the compose file to be readable as before, got %v (%v)", info, err)
+	}
+
+	// Rewriting the same content keeps the backup of the actual previous version
+	if err := manager.WriteComposeFile("test-app", "services:\n  web:\n    image: nginx:1.27\n"); err != nil {
+		t.Fatalf("Failed to write compose file: %v", err)
+	}
+	if backup, _ := os.ReadFile(filepath.Join(appPath, ComposeBackupFileName)); !strings.Contains(string(backup), "nginx:1.25") {
+		t.Errorf("Expected an unchanged write not to replace the backup, got %q", backup)
+	}
+
+	// No temp files are left behind
+	entries, _ := os.ReadDir(appPath)
+	for _, e := range entries {
+		if strings.Contains(e.Name(), ".tmp-") {
+			t.Errorf("Unexpected leftover temp file %s", e.Name())
+		}
+	}
+}
+
 func TestDeleteAppDirectory(t *testing.T) {
 	// Create a temporary directory for testing
 	tmpDir, err := ioutil.TempDir("", "docker-test")
@@ -523,3 +561,92 @@ func TestRestartCloudflared(t *testing.T) {
 		t.Errorf("Expected command to be executed in %s, got %s", appPath, commands[0].Dir)
 	}
 }
+
+// TestStartAppWithOverrideFile verifies the override file is passed to compose when present
+func TestStartAppWithOverrideFile(t *testing.T) {
+	tmpDir := t.TempDir()
+	mockExecutor := NewMockCommandExecutor()
+	manager := NewManagerWithExecutor(tmpDir, mockExecutor)
+
+	appName := "test-app"
+	if err := manager.CreateAppDirectory(appName, "services:\n  web:\n    image: nginx:latest"); err != nil {
+		t.Fatalf("Failed to create app directory: %v", err)
+	}
+	if err := manager.WriteComposeOverrideFile(appName, "services:\n  web:\n    mem_limit: 256m"); err != nil {
+		t.Fatalf("Failed to write override file: %v", err)
+	}
+
+	if err := manager.StartApp(appName); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if !mockExecutor.AssertCommandExecuted("docker", []string{"compose", "-f", "docker-compose.yml", "-f", "docker-compose.override.yml", "up", "-d"}) {
+		t.Errorf("Expected override file in compose command, got %v", mockExecutor.GetExecutedCommands())
+	}
+
+	// Clearing the override removes the file and the extra flag
+	if err := manager.WriteComposeOverrideFile(appName, ""); err != nil {
+		t.Fatalf("Failed to clear override file: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(tmpDir, appName, ComposeOverrideFileName)); !os.IsNotExist(err) {
+		t.Errorf("Expected override file to be removed, stat err: %v", err)
+	}
+	if err := manager.StartApp(appName); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	commands := mockExecutor.GetExecutedCommands()
+	last := commands[len(commands)-1]
+	if strings.Contains(strings.Join(last.Args, " "), ComposeOverrideFileName) {
+		t.Errorf("Expected no override file after clearing, got %v", last.Args)
+	}
+}
+
+func TestStartAppWithTunnelComposeFile(t *testing.T) {
+	tmpDir := t.TempDir()
+	mockExecutor := NewMockCommandExecutor()
+	manager := NewManagerWithExecutor(tmpDir, mockExecutor)
+
+	appName := "test-app"
+	if err := manager.CreateAppDirectory(appName, "services:\n  web:\n    image: nginx:latest"); err != nil {
+		t.Fatalf("Failed to create app directory: %v", err)
+	}
+	if err := manager.WriteTunnelComposeFile(appName, "services:\n  tunnel:\n    image: cloudflare/cloudflared:latest"); err != nil {
+		t.Fatalf("Failed to write tunnel compose file: %v", err)
+	}
+	if err := manager.WriteComposeOverrideFile(appName, "services:\n  web:\n    mem_limit: 256m"); err != nil {
+		t.Fatalf("Failed to write override file: %v", err)
+	}
+
+	if err := manager.StartApp(appName); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	// The user override is layered last so it can adjust the generated sidecar
+	if !mockExecutor.AssertCommandExecuted("docker", []string{"compose", "-f", "docker-compose.yml", "-f", "docker-compose.tunnel.yml", "-f", "docker-compose.override.yml", "up", "-d"}) {
+		t.Errorf("Expected tunnel and override files in compose command, got %v", mockExecutor.GetExecutedCommands())
+	}
+}
+
+// TestCountRunningContainers tests counting container IDs from "docker compose ps -q"
+func TestCountRunningContainers(t *testing.T) {
+	mockExecutor := NewMockCommandExecutor()
+	manager := NewManagerWithExecutor("/tmp/apps", mockExecutor)
+
+	cmd := ComposePsQuietCommand()
+	mockExecutor.SetMockOutput("docker", cmd[1:], []byte("3f4e5d6c7b8a\n9a8b7c6d5e4f\n"))
+
+	count, err := manager.CountRunningContainers("test-app")
+	if err != nil {
+		t.Fatalf("CountRunningContainers: %v", err)
+	}
+	if count != 2 {
+		t.Errorf("Expected 2 running containers, got %d", count)
+	}
+
+	mockExecutor.SetMockOutput("docker", cmd[1:], []byte(""))
+	count, err = manager.CountRunningContainers("test-app")
+	if err != nil {
+		t.Fatalf("CountRunningContainers: %v", err)
+	}
+	if count != 0 {
+		t.Errorf("Expected 0 running containers, got %d", count)
+	}
+}
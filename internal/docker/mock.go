@@ -1,5 +1,11 @@
 package docker
 
+import (
+	"bytes"
+	"context"
+	"io"
+)
+
 // MockCommandExecutor is a test implementation that doesn't actually execute commands
 type MockCommandExecutor struct {
 	// Map of command to mock output
@@ -36,6 +42,26 @@ func (m *MockCommandExecutor) ExecuteCommandInDir(dir, name string, args ...stri
 	return m.executeCommand(dir, name, args)
 }
 
+// ExecuteCommandInDirContext records the command and returns ctx's error if it is already done,
+// or the mocked output/error otherwise
+func (m *MockCommandExecutor) ExecuteCommandInDirContext(ctx context.Context, dir, name string, args ...string) ([]byte, error) {
+	output, err := m.executeCommand(dir, name, args)
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return nil, ctxErr
+	}
+	return output, err
+}
+
+// StreamCommand records the command and returns its mocked output as a stream that ends once
+// the output has been read
+func (m *MockCommandExecutor) StreamCommand(ctx context.Context, name string, args ...string) (io.ReadCloser, error) {
+	output, err := m.executeCommand("", name, args)
+	if err != nil {
+		return nil, err
+	}
+	return io.NopCloser(bytes.NewReader(output)), nil
+}
+
 // executeCommand is the internal method that handles both execution types
 func (m *MockCommandExecutor) executeCommand(dir, name string, args []string) ([]byte, error) {
 	// Record the command execution
@@ -0,0 +1,110 @@
+package docker
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/selfhostly/internal/constants"
+)
+
+// NetworkRecorder is told which networks an app's compose project has after the app is deployed,
+// so they can still be found and removed once the compose file or the app is gone
+type NetworkRecorder func(appName string, networks []string)
+
+// errNetworkNotFound is returned by networkContainers for a network docker doesn't know
+var errNetworkNotFound = errors.New("network not found")
+
+// protectedNetworks are never removed: docker's own networks and the network shared by every
+// app's tunnel container
+var protectedNetworks = map[string]bool{
+	"bridge":                 true,
+	"host":                   true,
+	"none":                   true,
+	constants.CoreAPINetwork: true,
+}
+
+// SetNetworkRecorder sets the recorder told about each app's networks after StartApp, UpdateApp
+// and ReconcileApp succeed. It is called once at startup, before the manager is used.
+func (m *Manager) SetNetworkRecorder(recorder NetworkRecorder) {
+	m.networkRecorder = recorder
+}
+
+// recordAppNetworks passes the app's current networks to the recorder, if one is set. Failures
+// are only logged: the deploy itself succeeded.
+func (m *Manager) recordAppNetworks(name string) {
+	if m.networkRecorder == nil {
+		return
+	}
+	networks, err := m.ListAppNetworks(name)
+	if err != nil {
+		slog.Warn("failed to list app networks to record", "app", name, "error", err)
+		return
+	}
+	if len(networks) > 0 {
+		m.networkRecorder(name, networks)
+	}
+}
+
+// RemoveUnusedNetworks removes those of networks no container is attached to. It returns the
+// networks that are gone, removed now or already missing, and those kept because they are still
+// in use. docker's own networks and the core API network are never removed nor reported.
+func (m *Manager) RemoveUnusedNetworks(networks []string) (gone, inUse []string, err error) {
+	var errs []error
+	for _, network := range networks {
+		if protectedNetworks[network] {
+			continue
+		}
+
+		containers, err := m.networkContainers(network)
+		if errors.Is(err, errNetworkNotFound) {
+			gone = append(gone, network)
+			continue
+		}
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if len(containers) > 0 {
+			inUse = append(inUse, network)
+			continue
+		}
+
+		cmd := DockerNetworkRmCommand(network)
+		output, err := m.commandExecutor.ExecuteCommand(cmd[0], cmd[1:]...)
+		if err != nil {
+			// A container may have joined since the count was taken
+			if strings.Contains(string(output), "active endpoints") {
+				inUse = append(inUse, network)
+				continue
+			}
+			errs = append(errs, fmt.Errorf("failed to remove network %s: %w\nOutput: %s", network, err, string(output)))
+			continue
+		}
+		slog.Info("removed network", "network", network)
+		gone = append(gone, network)
+	}
+	return gone, inUse, errors.Join(errs...)
+}
+
+// networkContainers returns the names of the containers attached to a network
+func (m *Manager) networkContainers(network string) ([]string, error) {
+	cmd := DockerNetworkContainersCommand(network)
+	output, err := m.commandExecutor.ExecuteCommand(cmd[0], cmd[1:]...)
+	if err != nil {
+		message := strings.ToLower(string(output) + err.Error())
+		if strings.Contains(message, "no such network") || strings.Contains(message, "not found") {
+			return nil, errNetworkNotFound
+		}
+		return nil, fmt.Errorf("failed to inspect network %s: %w\nOutput: %s", network, err, string(output))
+	}
+
+	var containers []string
+	for _, line := range strings.Split(string(output), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			containers = append(containers, line)
+		}
+	}
+	return containers, nil
+}
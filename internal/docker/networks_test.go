@@ -0,0 +1,60 @@
+package docker
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	"github.com/selfhostly/internal/constants"
+)
+
+func TestManager_RecordsNetworksAfterStart(t *testing.T) {
+	executor := NewMockCommandExecutor()
+	manager := NewManagerWithExecutor(t.TempDir(), executor)
+	if err := manager.CreateAppDirectory("Blog", "services: {}\n"); err != nil {
+		t.Fatal(err)
+	}
+	executor.SetMockOutput("docker", DockerProjectNetworksCommand("blog")[1:], []byte("blog_default\nblog_backend\n"))
+
+	recorded := map[string][]string{}
+	manager.SetNetworkRecorder(func(appName string, networks []string) {
+		recorded[appName] = networks
+	})
+
+	if err := manager.StartApp("Blog"); err != nil {
+		t.Fatalf("StartApp: %v", err)
+	}
+	if !slices.Equal(recorded["Blog"], []string{"blog_default", "blog_backend"}) {
+		t.Errorf("Expected the project networks to be recorded, got %v", recorded)
+	}
+}
+
+func TestManager_RemoveUnusedNetworks(t *testing.T) {
+	executor := NewMockCommandExecutor()
+	manager := NewManagerWithExecutor(t.TempDir(), executor)
+
+	executor.SetMockOutput("docker", DockerNetworkContainersCommand("blog_default")[1:], []byte(""))
+	executor.SetMockOutput("docker", DockerNetworkContainersCommand("blog_shared")[1:], []byte("other-web-1\n"))
+	executor.SetMockError("docker", DockerNetworkContainersCommand("blog_old")[1:], errors.New("Error: No such network: blog_old"))
+	executor.SetMockError("docker", DockerNetworkContainersCommand("blog_broken")[1:], errors.New("Cannot connect to the Docker daemon"))
+
+	gone, inUse, err := manager.RemoveUnusedNetworks([]string{"blog_default", "blog_shared", "blog_old", "blog_broken", constants.CoreAPINetwork})
+	if err == nil {
+		t.Error("Expected an error for the network that couldn't be inspected")
+	}
+	if !slices.Equal(gone, []string{"blog_default", "blog_old"}) {
+		t.Errorf("Expected the unused and missing networks to be gone, got %v", gone)
+	}
+	if !slices.Equal(inUse, []string{"blog_shared"}) {
+		t.Errorf("Expected the shared network to be kept, got %v", inUse)
+	}
+
+	if !executor.AssertCommandExecuted("docker", DockerNetworkRmCommand("blog_default")[1:]) {
+		t.Error("Expected the unused network to be removed")
+	}
+	for _, network := range []string{"blog_shared", "blog_old", constants.CoreAPINetwork} {
+		if executor.AssertCommandExecuted("docker", DockerNetworkRmCommand(network)[1:]) {
+			t.Errorf("Expected %s not to be removed", network)
+		}
+	}
+}
@@ -0,0 +1,206 @@
+package docker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/compose-spec/compose-go/v2/loader"
+	composetypes "github.com/compose-spec/compose-go/v2/types"
+	"github.com/joho/godotenv"
+	"github.com/selfhostly/internal/platform"
+)
+
+// Sources of a compose variable's value
+const (
+	VariableSourceDotEnv = ".env"  // The app's .env
+	VariableSourceUnset  = "unset" // Not in the app's .env; the reference's default, or an empty string
+)
+
+// ComposeSource is the content of one compose file passed with -f
+type ComposeSource struct {
+	Name    string
+	Content string
+}
+
+// ComposeVariable is a variable referenced from an app's compose files
+type ComposeVariable struct {
+	Name   string `json:"name"`
+	Value  string `json:"value,omitempty"`
+	Source string `json:"source"`
+}
+
+// ComposePreview is the configuration docker compose receives for an app, as "docker compose
+// config" would print it
+type ComposePreview struct {
+	Files     []string           `json:"files"`   // Merged in this order
+	Compose   string             `json:"compose"` // Merged, with variables substituted
+	Variables []*ComposeVariable `json:"variables"`
+	// Warnings are problems that don't stop a deploy, e.g. images without a manifest for the node's architecture
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+// AppComposeSources returns the files docker compose is run with for an app, in the order
+// runCompose passes them: the app's compose, then its images for this node's architecture, the
+// tunnel sidecar and the override when set
+func AppComposeSources(compose, tunnelCompose, override string) []ComposeSource {
+	sources := []ComposeSource{{Name: ComposeFileName, Content: compose}}
+	if archCompose, err := ArchImageCompose(compose, platform.Arch()); err == nil && archCompose != "" {
+		sources = append(sources, ComposeSource{Name: ComposeArchFileName, Content: archCompose})
+	}
+	if strings.TrimSpace(tunnelCompose) != "" {
+		sources = append(sources, ComposeSource{Name: ComposeTunnelFileName, Content: tunnelCompose})
+	}
+	if strings.TrimSpace(override) != "" {
+		sources = append(sources, ComposeSource{Name: ComposeOverrideFileName, Content: override})
+	}
+	return sources
+}
+
+// PreviewCompose merges an app's compose files and substitutes variables from the app's .env:
+// env, the app's managed .env content, or the .env file in the app directory when env is empty.
+// The server's own environment is never used, so the preview can't reveal it. files are the
+// app's extra compose files; when set, the merge runs in a scratch copy of the app directory
+// holding them, so unsaved files can be previewed. Nothing is written or deployed.
+func (m *Manager) PreviewCompose(name string, sources []ComposeSource, files map[string]string, env string) (*ComposePreview, error) {
+	appPath := m.AppPath(name)
+	var dotEnv map[string]string
+	var err error
+	if env != "" {
+		dotEnv, err = godotenv.Unmarshal(env)
+	} else {
+		dotEnv, err = godotenv.Read(filepath.Join(appPath, ".env"))
+		if errors.Is(err, fs.ErrNotExist) {
+			err = nil
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read .env: %w", err)
+	}
+
+	environment := composetypes.Mapping{}
+	for key, value := range dotEnv {
+		environment[key] = value
+	}
+
+	preview := &ComposePreview{Files: make([]string, len(sources))}
+	configFiles := make([]composetypes.ConfigFile, len(sources))
+	referenced := map[string]bool{}
+	for i, source := range sources {
+		preview.Files[i] = source.Name
+		configFiles[i] = composetypes.ConfigFile{Filename: source.Name, Content: []byte(source.Content)}
+		for _, key := range referencedVariables(source.Content) {
+			referenced[key] = true
+		}
+	}
+	for _, content := range files {
+		for _, key := range referencedVariables(content) {
+			referenced[key] = true
+		}
+	}
+	if err := checkComposeReferences(files, configFiles...); err != nil {
+		return nil, err
+	}
+
+	workDir := appPath
+	if len(files) > 0 {
+		if workDir, err = previewWorkspace(appPath, files); err != nil {
+			return nil, err
+		}
+		defer os.RemoveAll(workDir)
+	}
+
+	project, err := loader.LoadWithContext(context.Background(), composetypes.ConfigDetails{
+		WorkingDir:  workDir,
+		ConfigFiles: configFiles,
+		Environment: environment,
+	}, func(o *loader.Options) {
+		o.SetProjectName(name, true)
+		o.SkipValidation = true
+	})
+	if err != nil {
+		return nil, enhanceComposeGoError(err, configFiles[len(configFiles)-1].Content)
+	}
+	if workDir != appPath {
+		rebaseProjectPaths(project, workDir, appPath)
+	}
+	rendered, err := project.MarshalYAML()
+	if err != nil {
+		return nil, fmt.Errorf("failed to render compose: %w", err)
+	}
+	preview.Compose = string(rendered)
+
+	images := make([]string, 0, len(project.Services))
+	for _, service := range project.Services {
+		images = append(images, service.Image)
+	}
+	sort.Strings(images)
+	preview.Warnings = m.ImagePlatformWarnings(images, platform.Arch())
+
+	keys := make([]string, 0, len(referenced))
+	for key := range referenced {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	preview.Variables = make([]*ComposeVariable, len(keys))
+	for i, key := range keys {
+		variable := &ComposeVariable{Name: key, Source: VariableSourceUnset}
+		if value, ok := dotEnv[key]; ok {
+			variable.Value, variable.Source = value, VariableSourceDotEnv
+		}
+		preview.Variables[i] = variable
+	}
+	return preview, nil
+}
+
+// previewWorkspace creates a temporary directory with files written into it and links to
+// everything else in the app directory, so env_file and other references still resolve
+func previewWorkspace(appPath string, files map[string]string) (string, error) {
+	workDir, err := os.MkdirTemp("", "selfhostly-preview-")
+	if err != nil {
+		return "", fmt.Errorf("failed to create preview directory: %w", err)
+	}
+
+	// Top-level entries that hold one of the files are written, not linked
+	staged := make(map[string]bool, len(files))
+	for name := range files {
+		top, _, _ := strings.Cut(name, "/")
+		staged[top] = true
+	}
+	entries, err := os.ReadDir(appPath)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		os.RemoveAll(workDir)
+		return "", fmt.Errorf("failed to read app directory: %w", err)
+	}
+	for _, entry := range entries {
+		if staged[entry.Name()] {
+			continue
+		}
+		if err := os.Symlink(filepath.Join(appPath, entry.Name()), filepath.Join(workDir, entry.Name())); err != nil {
+			os.RemoveAll(workDir)
+			return "", fmt.Errorf("failed to link %s: %w", entry.Name(), err)
+		}
+	}
+
+	if err := stageComposeFiles(workDir, files); err != nil {
+		os.RemoveAll(workDir)
+		return "", err
+	}
+	return workDir, nil
+}
+
+// referencedVariables returns the names of the variables content references, ignoring escaped $$
+func referencedVariables(content string) []string {
+	var names []string
+	for _, m := range envReference.FindAllStringSubmatch(content, -1) {
+		if name := m[1] + m[2]; name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
@@ -0,0 +1,102 @@
+package docker
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPreviewCompose(t *testing.T) {
+	tmpDir := t.TempDir()
+	manager := NewManagerWithExecutor(tmpDir, NewMockCommandExecutor())
+	appPath := filepath.Join(tmpDir, "blog")
+	if err := os.MkdirAll(appPath, 0755); err != nil {
+		t.Fatalf("Failed to create app directory: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(appPath, ".env"), []byte("DB_PASSWORD=from-dotenv\nTAG=1.25\n"), 0600); err != nil {
+		t.Fatalf("Failed to write .env: %v", err)
+	}
+	t.Setenv("GREETING", "from-server-environment")
+
+	compose := `services:
+  web:
+    image: nginx:${TAG}
+    environment:
+      DB_PASSWORD: ${DB_PASSWORD}
+      GREETING: ${GREETING:-hello}
+      PRICE: $$5
+`
+	tunnelCompose := `services:
+  cloudflared:
+    image: cloudflare/cloudflared:latest
+`
+	preview, err := manager.PreviewCompose("blog", AppComposeSources(compose, tunnelCompose, ""), nil, "")
+	if err != nil {
+		t.Fatalf("PreviewCompose: %v", err)
+	}
+
+	if strings.Join(preview.Files, ",") != ComposeFileName+","+ComposeTunnelFileName {
+		t.Errorf("Unexpected files %v", preview.Files)
+	}
+	for _, want := range []string{"nginx:1.25", "DB_PASSWORD: from-dotenv", "GREETING: hello", "cloudflared"} {
+		if !strings.Contains(preview.Compose, want) {
+			t.Errorf("Expected %q in the rendered compose:\n%s", want, preview.Compose)
+		}
+	}
+
+	sources := map[string]string{}
+	for _, v := range preview.Variables {
+		sources[v.Name] = v.Source
+	}
+	want := map[string]string{"DB_PASSWORD": VariableSourceDotEnv, "GREETING": VariableSourceUnset, "TAG": VariableSourceDotEnv}
+	if len(sources) != len(want) {
+		t.Errorf("Expected variables %v, got %v", want, sources)
+	}
+	for name, source := range want {
+		if sources[name] != source {
+			t.Errorf("Expected %s to come from %q, got %q", name, source, sources[name])
+		}
+	}
+}
+
+func TestPreviewComposeRequiredVariable(t *testing.T) {
+	tmpDir := t.TempDir()
+	manager := NewManagerWithExecutor(tmpDir, NewMockCommandExecutor())
+	compose := "services:\n  web:\n    image: nginx:${SELFHOSTLY_TEST_UNSET_TAG:?tag is required}\n"
+	if _, err := manager.PreviewCompose("blog", AppComposeSources(compose, "", ""), nil, ""); err == nil {
+		t.Error("Expected an error for a required variable that is not set")
+	}
+}
+
+func TestPreviewComposeWithFiles(t *testing.T) {
+	tmpDir := t.TempDir()
+	manager := NewManagerWithExecutor(tmpDir, NewMockCommandExecutor())
+	appPath := filepath.Join(tmpDir, "blog")
+	if err := os.MkdirAll(appPath, 0755); err != nil {
+		t.Fatalf("Failed to create app directory: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(appPath, "db.env"), []byte("POSTGRES_DB=blog\n"), 0600); err != nil {
+		t.Fatalf("Failed to write db.env: %v", err)
+	}
+
+	compose := "include:\n  - db/compose.yml\nservices:\n  web:\n    image: nginx:latest\n"
+	files := map[string]string{
+		"db/compose.yml": "services:\n  db:\n    image: postgres:${DB_TAG}\n    env_file: ../db.env\n    volumes:\n      - ./data:/var/lib/postgresql/data\n",
+	}
+	preview, err := manager.PreviewCompose("blog", AppComposeSources(compose, "", ""), files, "DB_TAG=16\n")
+	if err != nil {
+		t.Fatalf("PreviewCompose: %v", err)
+	}
+
+	// The unsaved file is included, the app directory's other files still resolve, and paths
+	// point into the app directory rather than the scratch copy
+	for _, want := range []string{"postgres:16", "POSTGRES_DB: blog", filepath.Join(appPath, "db", "data")} {
+		if !strings.Contains(preview.Compose, want) {
+			t.Errorf("Expected %q in the rendered compose:\n%s", want, preview.Compose)
+		}
+	}
+	if len(preview.Variables) != 1 || preview.Variables[0].Name != "DB_TAG" {
+		t.Errorf("Expected DB_TAG from the included file, got %+v", preview.Variables)
+	}
+}
@@ -0,0 +1,44 @@
+package docker
+
+import (
+	"strings"
+
+	composetypes "github.com/compose-spec/compose-go/v2/types"
+)
+
+// Reservations is what an app's services reserve through deploy.resources.reservations, summed
+// over every service and replica
+type Reservations struct {
+	MemoryBytes int64   `json:"memory_bytes"`
+	CPUs        float64 `json:"cpus"`
+}
+
+// MemoryMB returns the reserved memory in megabytes, rounded up
+func (r Reservations) MemoryMB() int {
+	return int((r.MemoryBytes + 1<<20 - 1) >> 20)
+}
+
+// ComposeReservations sums the reservations of the app's compose and override, merged as docker
+// compose does. Services without reservations count as reserving nothing.
+func ComposeReservations(composeContent, composeOverride string, composeFiles map[string]string) (Reservations, error) {
+	files := []composetypes.ConfigFile{{Filename: ComposeFileName, Content: []byte(composeContent)}}
+	if strings.TrimSpace(composeOverride) != "" {
+		files = append(files, composetypes.ConfigFile{Filename: ComposeOverrideFileName, Content: []byte(composeOverride)})
+	}
+	project, err := loadComposeProject(composeFiles, files...)
+	if err != nil {
+		return Reservations{}, err
+	}
+
+	var total Reservations
+	for _, svc := range project.Services {
+		if svc.Deploy == nil || svc.Deploy.Resources.Reservations == nil {
+			continue
+		}
+		reservation := svc.Deploy.Resources.Reservations
+		replicas := svc.GetScale()
+		total.MemoryBytes += int64(reservation.MemoryBytes) * int64(replicas)
+		total.CPUs += float64(reservation.NanoCPUs.Value()) * float64(replicas)
+	}
+	return total, nil
+}
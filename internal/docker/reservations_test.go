@@ -0,0 +1,51 @@
+package docker
+
+import (
+	"math"
+	"testing"
+)
+
+func TestComposeReservations(t *testing.T) {
+	compose := `services:
+  web:
+    image: nginx
+    deploy:
+      replicas: 2
+      resources:
+        reservations:
+          memory: 256M
+          cpus: "0.5"
+  worker:
+    image: busybox
+`
+	override := `services:
+  worker:
+    deploy:
+      resources:
+        reservations:
+          memory: 1g
+`
+
+	got, err := ComposeReservations(compose, "", nil)
+	if err != nil {
+		t.Fatalf("ComposeReservations: %v", err)
+	}
+	if got.MemoryBytes != 512<<20 || math.Abs(got.CPUs-1) > 0.001 {
+		t.Errorf("Expected both replicas of web to count, got %+v", got)
+	}
+
+	got, err = ComposeReservations(compose, override, nil)
+	if err != nil {
+		t.Fatalf("ComposeReservations with override: %v", err)
+	}
+	if got.MemoryBytes != 512<<20+1<<30 || got.MemoryMB() != 1536 {
+		t.Errorf("Expected the override's reservation to count, got %d bytes", got.MemoryBytes)
+	}
+	if mb := (Reservations{MemoryBytes: 1}).MemoryMB(); mb != 1 {
+		t.Errorf("Expected part of a megabyte to round up, got %d", mb)
+	}
+
+	if _, err := ComposeReservations("services: [", "", nil); err == nil {
+		t.Error("Expected invalid compose to fail")
+	}
+}
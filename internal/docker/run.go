@@ -0,0 +1,76 @@
+package docker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/selfhostly/internal/constants"
+)
+
+// CommandResult is the outcome of a one-off command run in one of an app's services
+type CommandResult struct {
+	Service  string   `json:"service"`
+	Command  []string `json:"command"`
+	Mode     string   `json:"mode"`      // constants.RunModeRun or constants.RunModeExec
+	ExitCode int      `json:"exit_code"` // -1 when the command could not be started or timed out
+	Output   string   `json:"output"`    // Last lines of combined stdout and stderr
+	TimedOut bool     `json:"timed_out,omitempty"`
+}
+
+// RunServiceCommand runs command in service, in a new container removed afterwards
+// (docker compose run --rm) or, in exec mode, in the service's running container. runID names the
+// run's container. When ctx is done the docker CLI is interrupted and, in run mode, the container
+// is removed so the command stops with it; docker exec can't stop the command it started, so in
+// exec mode it may keep running in the service's container. The result is returned even when the
+// command fails, so its output is on record; err is non-nil when the command exits non-zero, times
+// out or can't be run.
+func (m *Manager) RunServiceCommand(ctx context.Context, name, service string, command []string, mode, runID string) (*CommandResult, error) {
+	appPath := m.AppPath(name)
+	if _, err := os.Stat(appPath); os.IsNotExist(err) {
+		return nil, fmt.Errorf("app directory does not exist: %s", appPath)
+	}
+
+	var cmd []string
+	containerName := fmt.Sprintf("%s-%s-run-%s", composeProjectName(name), service, runID)
+	switch mode {
+	case constants.RunModeRun:
+		cmd = ComposeRunCommand(containerName, service, command)
+	case constants.RunModeExec:
+		cmd = ComposeExecCommand(service, command)
+	default:
+		return nil, fmt.Errorf("unknown run mode %q", mode)
+	}
+
+	slog.Info("running one-off command", "app", name, "service", service, "mode", mode, "command", strings.Join(command, " "))
+	output, err := m.runComposeContext(ctx, appPath, cmd)
+	result := &CommandResult{
+		Service: service,
+		Command: command,
+		Mode:    mode,
+		Output:  lastLines(string(output), constants.RunOutputTailLines),
+	}
+	if err != nil {
+		result.ExitCode = -1
+		var exitErr *exec.ExitError
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			result.TimedOut = true
+			err = fmt.Errorf("command timed out: %w", ctx.Err())
+			if mode == constants.RunModeRun {
+				// The container outlives the interrupted CLI; removing it stops the command
+				if rmErr := m.DeleteContainer(containerName); rmErr != nil {
+					slog.Warn("failed to remove timed out command container", "app", name, "container", containerName, "error", rmErr)
+				}
+			}
+		} else if errors.As(err, &exitErr) {
+			result.ExitCode = exitErr.ExitCode()
+		}
+		slog.Warn("one-off command failed", "app", name, "service", service, "exit_code", result.ExitCode, "error", err)
+		return result, fmt.Errorf("command failed in service %s: %w", service, err)
+	}
+	return result, nil
+}
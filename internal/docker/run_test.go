@@ -0,0 +1,72 @@
+package docker
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/selfhostly/internal/constants"
+)
+
+func TestComposeRunAndExecCommands(t *testing.T) {
+	cmd := ComposeRunCommand("blog-web-run-1", "web", []string{"php", "artisan", "migrate", "--force"})
+	want := []string{DockerCommand, ComposeCommand, ComposeFileFlag, ComposeFileName, ComposeSubcommandRun, "--rm", "-T", "--name", "blog-web-run-1", "web", "php", "artisan", "migrate", "--force"}
+	if !reflect.DeepEqual(cmd, want) {
+		t.Errorf("ComposeRunCommand() = %v, want %v", cmd, want)
+	}
+
+	cmd = ComposeExecCommand("web", []string{"rm", "-rf", "/tmp/cache"})
+	want = []string{DockerCommand, ComposeCommand, ComposeFileFlag, ComposeFileName, ComposeSubcommandExec, "-T", "web", "rm", "-rf", "/tmp/cache"}
+	if !reflect.DeepEqual(cmd, want) {
+		t.Errorf("ComposeExecCommand() = %v, want %v", cmd, want)
+	}
+}
+
+func TestRunServiceCommand(t *testing.T) {
+	tmpDir := t.TempDir()
+	mockExecutor := NewMockCommandExecutor()
+	manager := NewManagerWithExecutor(tmpDir, mockExecutor)
+	if err := os.MkdirAll(filepath.Join(tmpDir, "test-app"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	args := []string{"compose", "-f", "docker-compose.yml", "run", "--rm", "-T", "--name", "test-app-web-run-job1", "web", "echo", "hi"}
+	mockExecutor.SetMockOutput("docker", args, []byte("hi\n"))
+
+	result, err := manager.RunServiceCommand(context.Background(), "test-app", "web", []string{"echo", "hi"}, constants.RunModeRun, "job1")
+	if err != nil {
+		t.Fatalf("RunServiceCommand() error = %v", err)
+	}
+	if result.ExitCode != 0 || result.Output != "hi" || result.Mode != constants.RunModeRun {
+		t.Errorf("unexpected result: %+v", result)
+	}
+
+	mockExecutor.SetMockError("docker", args, errors.New("exit status 1"))
+	result, err = manager.RunServiceCommand(context.Background(), "test-app", "web", []string{"echo", "hi"}, constants.RunModeRun, "job1")
+	if err == nil {
+		t.Fatal("expected an error when the command fails")
+	}
+	if result == nil || result.ExitCode != -1 {
+		t.Errorf("expected a result with exit code -1, got %+v", result)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 0)
+	defer cancel()
+	result, err = manager.RunServiceCommand(ctx, "test-app", "web", []string{"echo", "hi"}, constants.RunModeRun, "job1")
+	if err == nil || result == nil || !result.TimedOut {
+		t.Errorf("expected a timed out result, got %+v, %v", result, err)
+	}
+	if !mockExecutor.AssertCommandExecuted("docker", DockerRmCommand("test-app-web-run-job1")[1:]) {
+		t.Error("expected the timed out command's container to be removed")
+	}
+
+	if _, err := manager.RunServiceCommand(context.Background(), "test-app", "web", []string{"true"}, "attach", "job1"); err == nil {
+		t.Error("expected an error for an unknown mode")
+	}
+	if _, err := manager.RunServiceCommand(context.Background(), "missing", "web", []string{"true"}, constants.RunModeExec, "job1"); err == nil {
+		t.Error("expected an error for a missing app directory")
+	}
+}
@@ -1,9 +1,13 @@
 package docker
 
 import (
-	"path/filepath"
+	"bufio"
+	"context"
+	"regexp"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -32,23 +36,8 @@ type ContainerStat struct {
 
 // GetAppStats retrieves real-time resource statistics for all containers in an app
 func (m *Manager) GetAppStats(name string) (*AppStats, error) {
-	appPath := filepath.Join(m.appsDir, name)
-
-	// Get list of container IDs for this app
-	cmd := ComposePsQuietCommand()
-	output, err := m.commandExecutor.ExecuteCommandInDir(appPath, cmd[0], cmd[1:]...)
-	if err != nil {
-		// If docker compose ps fails (app stopped, no compose file, etc.), return empty stats
-		// This is not an error condition - just means no containers are running
-		return &AppStats{
-			AppName:    name,
-			Containers: []ContainerStat{},
-			Timestamp:  time.Now(),
-		}, nil
-	}
-
-	containerIDs := strings.Split(strings.TrimSpace(string(output)), "\n")
-	if len(containerIDs) == 0 || (len(containerIDs) == 1 && containerIDs[0] == "") {
+	containerIDs := m.appContainerIDs(name)
+	if len(containerIDs) == 0 {
 		// No running containers
 		return &AppStats{
 			AppName:    name,
@@ -101,6 +90,131 @@ func (m *Manager) GetAppStats(name string) (*AppStats, error) {
 	}, nil
 }
 
+// appContainerIDs lists the IDs of an app's running containers. A failing `docker compose ps`
+// (app stopped, no compose file, etc.) just means there are none.
+func (m *Manager) appContainerIDs(name string) []string {
+	output, err := m.runCompose(m.AppPath(name), ComposePsQuietCommand())
+	if err != nil {
+		return nil
+	}
+	var ids []string
+	for _, id := range strings.Split(string(output), "\n") {
+		if id = strings.TrimSpace(id); id != "" {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// statsStreamFormat prefixes the fields parseContainerStats reads with the container ID and name
+const statsStreamFormat = "{{.Container}}|{{.Name}}|{{.CPUPerc}}|{{.MemUsage}}|{{.MemPerc}}|{{.NetIO}}|{{.BlockIO}}"
+
+// ansiEscape matches the cursor and clear-screen sequences `docker stats` writes between refreshes
+var ansiEscape = regexp.MustCompile(`\x1b\[[0-9;?]*[A-Za-z]`)
+
+// StreamAppStats passes a sample of an app's resource usage to fn every interval until ctx is
+// done, fn returns an error or the app's containers stop. One `docker stats` process feeds the
+// whole stream; each sample carries the latest figures it reported for every container. An app
+// with no running containers gets a single empty sample.
+func (m *Manager) StreamAppStats(ctx context.Context, name string, interval time.Duration, fn func(*AppStats) error) error {
+	containerIDs := m.appContainerIDs(name)
+	if len(containerIDs) == 0 {
+		return fn(&AppStats{AppName: name, Containers: []ContainerStat{}, Timestamp: time.Now()})
+	}
+
+	streamer, ok := m.commandExecutor.(StreamingCommandExecutor)
+	if !ok {
+		return m.pollAppStats(ctx, name, interval, fn)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	args := append([]string{"stats", "--no-trunc", "--format", statsStreamFormat}, containerIDs...)
+	stream, err := streamer.StreamCommand(ctx, "docker", args...)
+	if err != nil {
+		return err
+	}
+	defer stream.Close()
+
+	var mu sync.Mutex
+	latest := make(map[string]ContainerStat)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		scanner := bufio.NewScanner(stream)
+		for scanner.Scan() {
+			parts := strings.SplitN(strings.TrimSpace(ansiEscape.ReplaceAllString(scanner.Text(), "")), "|", 3)
+			if len(parts) < 3 {
+				continue
+			}
+			stat := parseContainerStats(parts[0], parts[1], parts[2])
+			mu.Lock()
+			latest[stat.ContainerID] = stat
+			mu.Unlock()
+		}
+	}()
+
+	sample := func() *AppStats {
+		mu.Lock()
+		defer mu.Unlock()
+		if len(latest) == 0 {
+			return nil
+		}
+		stats := &AppStats{AppName: name, Timestamp: time.Now()}
+		for _, stat := range latest {
+			stats.Containers = append(stats.Containers, stat)
+			stats.TotalCPU += stat.CPUPercent
+			stats.TotalMemory += stat.MemoryUsage
+			stats.MemoryLimit += stat.MemoryLimit
+		}
+		sort.Slice(stats.Containers, func(i, j int) bool {
+			return stats.Containers[i].ContainerName < stats.Containers[j].ContainerName
+		})
+		return stats
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-done:
+			// docker stats exits once the containers are gone; report what it saw last
+			if stats := sample(); stats != nil {
+				return fn(stats)
+			}
+			return nil
+		case <-ticker.C:
+			if stats := sample(); stats != nil {
+				if err := fn(stats); err != nil {
+					return err
+				}
+			}
+		}
+	}
+}
+
+// pollAppStats is StreamAppStats for executors that cannot stream, taking a snapshot per sample
+func (m *Manager) pollAppStats(ctx context.Context, name string, interval time.Duration, fn func(*AppStats) error) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		stats, err := m.GetAppStats(name)
+		if err != nil {
+			return err
+		}
+		if err := fn(stats); err != nil {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
 // parseContainerStats parses Docker stats output
 // Format: "CPUPerc|MemUsage|MemPerc|NetIO|BlockIO"
 // Example: "0.50%|100MiB / 2GiB|4.88%|1.2MB / 3.4MB|5.6MB / 7.8MB"
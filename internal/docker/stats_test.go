@@ -0,0 +1,75 @@
+package docker
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestStreamAppStats(t *testing.T) {
+	tmpDir := t.TempDir()
+	mockExecutor := NewMockCommandExecutor()
+	manager := NewManagerWithExecutor(tmpDir, mockExecutor)
+
+	appName := "test-app"
+	if err := manager.CreateAppDirectory(appName, "services:\n  web:\n    image: nginx:latest"); err != nil {
+		t.Fatalf("Failed to create app directory: %v", err)
+	}
+
+	psCmd := ComposePsQuietCommand()
+	mockExecutor.SetMockOutput("docker", psCmd[1:], []byte("abc\ndef\n"))
+	mockExecutor.SetMockOutput("docker", []string{"stats", "--no-trunc", "--format", statsStreamFormat, "abc", "def"}, []byte(
+		"\x1b[2J\x1b[Habc|test-app-web-1|1.00%|100MiB / 1GiB|9.77%|1kB / 2kB|0B / 0B\n"+
+			"def|test-app-db-1|2.50%|200MiB / 1GiB|19.53%|3kB / 4kB|0B / 0B\n"+
+			"\x1b[2J\x1b[Habc|test-app-web-1|3.00%|150MiB / 1GiB|14.65%|1kB / 2kB|0B / 0B\n"))
+
+	var samples []*AppStats
+	err := manager.StreamAppStats(context.Background(), appName, time.Hour, func(stats *AppStats) error {
+		samples = append(samples, stats)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("StreamAppStats: %v", err)
+	}
+
+	if got := mockExecutor.GetCommandCount("docker", []string{"stats", "--no-trunc", "--format", statsStreamFormat, "abc", "def"}); got != 1 {
+		t.Errorf("expected one docker stats process, got %d", got)
+	}
+	if len(samples) != 1 {
+		t.Fatalf("expected the last sample when the stream ends, got %d samples", len(samples))
+	}
+	stats := samples[0]
+	if len(stats.Containers) != 2 || stats.Containers[0].ContainerName != "test-app-db-1" {
+		t.Fatalf("expected both containers sorted by name, got %+v", stats.Containers)
+	}
+	if stats.TotalCPU != 5.5 {
+		t.Errorf("expected the latest web sample to be used, total CPU = %v", stats.TotalCPU)
+	}
+	if stats.TotalMemory != 350*1024*1024 {
+		t.Errorf("expected total memory of 350MiB, got %d", stats.TotalMemory)
+	}
+}
+
+func TestStreamAppStats_NoContainers(t *testing.T) {
+	mockExecutor := NewMockCommandExecutor()
+	manager := NewManagerWithExecutor(t.TempDir(), mockExecutor)
+
+	psCmd := ComposePsQuietCommand()
+	mockExecutor.SetMockError("docker", psCmd[1:], errors.New("no such service"))
+
+	calls := 0
+	err := manager.StreamAppStats(context.Background(), "test-app", time.Millisecond, func(stats *AppStats) error {
+		calls++
+		if len(stats.Containers) != 0 {
+			t.Errorf("expected an empty sample, got %+v", stats.Containers)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("StreamAppStats: %v", err)
+	}
+	if calls != 1 {
+		t.Errorf("expected a single empty sample, got %d", calls)
+	}
+}
@@ -0,0 +1,54 @@
+package docker
+
+import (
+	"path/filepath"
+	"sort"
+
+	"github.com/selfhostly/internal/constants"
+)
+
+// StorageRoot is a directory app directories are created under, e.g. an SSD or an HDD
+type StorageRoot struct {
+	Name string `json:"name"`
+	Path string `json:"path"`
+}
+
+// SetStorageRoots adds named roots apps can be created under besides the apps directory, which is
+// the default root. It is called once at startup, before the manager is used.
+func (m *Manager) SetStorageRoots(roots map[string]string) {
+	m.storageRoots = make([]StorageRoot, 0, len(roots))
+	for name, path := range roots {
+		m.storageRoots = append(m.storageRoots, StorageRoot{Name: name, Path: path})
+	}
+	sort.Slice(m.storageRoots, func(i, j int) bool { return m.storageRoots[i].Name < m.storageRoots[j].Name })
+}
+
+// StorageRoots returns the default root followed by the named roots
+func (m *Manager) StorageRoots() []StorageRoot {
+	roots := []StorageRoot{{Name: constants.DefaultStorageRoot, Path: m.appsDir}}
+	return append(roots, m.storageRoots...)
+}
+
+// StorageRootDir returns the directory of the named root; "" and "default" are the apps directory
+func (m *Manager) StorageRootDir(root string) (string, bool) {
+	if root == "" || root == constants.DefaultStorageRoot {
+		return m.appsDir, true
+	}
+	for _, r := range m.storageRoots {
+		if r.Name == root {
+			return r.Path, true
+		}
+	}
+	return "", false
+}
+
+// AppPath returns the app's directory: under the named root that holds it, otherwise under the
+// apps directory. Apps only ever live in one root, so the lookup needs no record of which.
+func (m *Manager) AppPath(name string) string {
+	for _, r := range m.storageRoots {
+		if appPath := filepath.Join(r.Path, name); m.directoryExists(appPath) {
+			return appPath
+		}
+	}
+	return filepath.Join(m.appsDir, name)
+}
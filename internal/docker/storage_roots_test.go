@@ -0,0 +1,45 @@
+package docker
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/selfhostly/internal/constants"
+)
+
+func TestManager_StorageRoots(t *testing.T) {
+	appsDir, ssdDir := t.TempDir(), t.TempDir()
+	manager := NewManagerWithExecutor(appsDir, NewMockCommandExecutor())
+	manager.SetStorageRoots(map[string]string{"ssd": ssdDir})
+
+	roots := manager.StorageRoots()
+	if len(roots) != 2 || roots[0].Name != constants.DefaultStorageRoot || roots[1].Path != ssdDir {
+		t.Errorf("Unexpected storage roots %+v", roots)
+	}
+
+	if err := manager.CreateAppDirectoryIn("ssd", "fast", "services: {}\n"); err != nil {
+		t.Fatalf("CreateAppDirectoryIn: %v", err)
+	}
+	if err := manager.CreateAppDirectory("slow", "services: {}\n"); err != nil {
+		t.Fatalf("CreateAppDirectory: %v", err)
+	}
+	if got := manager.AppPath("fast"); got != filepath.Join(ssdDir, "fast") {
+		t.Errorf("Expected the app to resolve to the ssd root, got %s", got)
+	}
+	if got := manager.AppPath("slow"); got != filepath.Join(appsDir, "slow") {
+		t.Errorf("Expected the app to resolve to the apps directory, got %s", got)
+	}
+
+	// Later writes land next to the compose file, wherever it is
+	if err := manager.WriteComposeOverrideFile("fast", "services: {}\n"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(ssdDir, "fast", ComposeOverrideFileName)); err != nil {
+		t.Errorf("Expected the override file under the ssd root, got %v", err)
+	}
+
+	if err := manager.CreateAppDirectoryIn("nvme", "other", "services: {}\n"); err == nil {
+		t.Error("Expected an unknown storage root to be refused")
+	}
+}
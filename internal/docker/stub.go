@@ -0,0 +1,189 @@
+package docker
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/selfhostly/internal/chaos"
+)
+
+// StubCommandExecutor simulates docker and docker compose for chaos mode: nothing is executed,
+// each compose project is tracked as one container that compose up starts and compose down
+// stops, and every command goes through the chaos injector's latency and failures. Operations
+// are named "compose_<subcommand>" or "docker_<subcommand>" for CHAOS_FAIL_OPERATIONS.
+type StubCommandExecutor struct {
+	injector *chaos.Injector
+	mu       sync.Mutex
+	started  map[string]time.Time // Compose project -> when its container started, while running
+}
+
+// NewStubCommandExecutor creates a stub executor whose commands are delayed and failed by injector
+func NewStubCommandExecutor(injector *chaos.Injector) *StubCommandExecutor {
+	return &StubCommandExecutor{injector: injector, started: make(map[string]time.Time)}
+}
+
+// ExecuteCommand simulates a command
+func (s *StubCommandExecutor) ExecuteCommand(name string, args ...string) ([]byte, error) {
+	return s.execute(context.Background(), "", args)
+}
+
+// ExecuteCommandInDir simulates a command run in an app directory
+func (s *StubCommandExecutor) ExecuteCommandInDir(dir, name string, args ...string) ([]byte, error) {
+	return s.execute(context.Background(), dir, args)
+}
+
+// ExecuteCommandInDirContext simulates a command run in an app directory until ctx is done
+func (s *StubCommandExecutor) ExecuteCommandInDirContext(ctx context.Context, dir, name string, args ...string) ([]byte, error) {
+	return s.execute(ctx, dir, args)
+}
+
+// StreamCommand simulates a streaming command, returning its output as a stream that ends once
+// it has been read
+func (s *StubCommandExecutor) StreamCommand(ctx context.Context, name string, args ...string) (io.ReadCloser, error) {
+	output, err := s.execute(ctx, "", args)
+	if err != nil {
+		return nil, err
+	}
+	return io.NopCloser(bytes.NewReader(output)), nil
+}
+
+// execute runs the simulated command through the injector and answers it from the stub's state
+func (s *StubCommandExecutor) execute(ctx context.Context, dir string, args []string) ([]byte, error) {
+	if len(args) == 0 {
+		return nil, fmt.Errorf("stub docker: no command")
+	}
+	if args[0] == ComposeCommand {
+		subcommand, rest := stubComposeSubcommand(args[1:])
+		if err := s.injector.Do(ctx, "compose_"+subcommand); err != nil {
+			return []byte(err.Error()), err
+		}
+		return s.compose(strings.ToLower(filepath.Base(dir)), subcommand, rest), nil
+	}
+	if err := s.injector.Do(ctx, "docker_"+args[0]); err != nil {
+		return []byte(err.Error()), err
+	}
+	return s.docker(args), nil
+}
+
+// compose answers a docker compose subcommand for project
+func (s *StubCommandExecutor) compose(project, subcommand string, args []string) []byte {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// Commands naming services (e.g. the tunnel sidecar) leave the app's container as it is
+	wholeProject := len(stubPositionalArgs(args)) == 0
+	switch subcommand {
+	case ComposeSubcommandUp, ComposeSubcommandRestart, "start":
+		if _, running := s.started[project]; wholeProject && (!running || subcommand == ComposeSubcommandRestart) {
+			s.started[project] = time.Now()
+		}
+	case ComposeSubcommandDown, ComposeSubcommandStop:
+		if wholeProject {
+			delete(s.started, project)
+		}
+	case ComposeSubcommandPs:
+		if _, running := s.started[project]; !running {
+			return nil
+		}
+		if slices.Contains(args, "-q") {
+			return []byte(stubContainerID(project) + "\n")
+		}
+		return []byte(fmt.Sprintf("NAME STATUS\n%s running\n", stubContainerName(project)))
+	case ComposeSubcommandLogs:
+		if started, running := s.started[project]; running {
+			return []byte(fmt.Sprintf("%s  | %s stub container started (chaos mode)\n", stubContainerName(project), started.UTC().Format(time.RFC3339)))
+		}
+	}
+	return nil
+}
+
+// docker answers a plain docker command
+func (s *StubCommandExecutor) docker(args []string) []byte {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch args[0] {
+	case "version":
+		return []byte("stub\n")
+	case "info":
+		return []byte("/var/lib/docker\n")
+	case "ps":
+		// Only the compose project filter is simulated
+		for _, arg := range args {
+			project, ok := strings.CutPrefix(arg, composeProjectFilter(""))
+			if !ok {
+				continue
+			}
+			if _, running := s.started[project]; running {
+				return []byte(stubContainerName(project) + "\n")
+			}
+			return nil
+		}
+	case "inspect":
+		type state struct {
+			Status    string    `json:"Status"`
+			StartedAt time.Time `json:"StartedAt"`
+		}
+		type config struct {
+			Labels map[string]string `json:"Labels"`
+		}
+		type container struct {
+			ID     string `json:"Id"`
+			Name   string `json:"Name"`
+			State  state  `json:"State"`
+			Config config `json:"Config"`
+		}
+		containers := []container{}
+		for project, started := range s.started {
+			if slices.Contains(args, stubContainerName(project)) || slices.Contains(args, stubContainerID(project)) {
+				containers = append(containers, container{
+					ID:     stubContainerID(project),
+					Name:   "/" + stubContainerName(project),
+					State:  state{Status: "running", StartedAt: started},
+					Config: config{Labels: map[string]string{composeServiceLabel: "app"}},
+				})
+			}
+		}
+		output, _ := json.Marshal(containers)
+		return output
+	}
+	return nil
+}
+
+// stubComposeSubcommand returns the subcommand of docker compose arguments and what follows it,
+// skipping the -f file flags
+func stubComposeSubcommand(args []string) (string, []string) {
+	for i := 0; i < len(args); i++ {
+		if args[i] == ComposeFileFlag {
+			i++
+			continue
+		}
+		return args[i], args[i+1:]
+	}
+	return "", nil
+}
+
+// stubPositionalArgs returns the arguments that aren't flags, i.e. the services named
+func stubPositionalArgs(args []string) []string {
+	var positional []string
+	for _, arg := range args {
+		if !strings.HasPrefix(arg, "-") {
+			positional = append(positional, arg)
+		}
+	}
+	return positional
+}
+
+func stubContainerName(project string) string {
+	return project + "-app-1"
+}
+
+func stubContainerID(project string) string {
+	return fmt.Sprintf("stub%x", project)
+}
@@ -0,0 +1,59 @@
+package docker
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/selfhostly/internal/chaos"
+	"github.com/selfhostly/internal/constants"
+)
+
+func TestStubCommandExecutor_AppLifecycle(t *testing.T) {
+	manager := NewManagerWithExecutor(t.TempDir(), NewStubCommandExecutor(chaos.New(chaos.Config{})))
+	if err := manager.CreateAppDirectory("Blog", "services:\n  web:\n    image: nginx\n"); err != nil {
+		t.Fatalf("CreateAppDirectory: %v", err)
+	}
+
+	if status, _ := manager.GetAppStatus("Blog"); status != constants.AppStatusStopped {
+		t.Errorf("expected a new app to be stopped, got %s", status)
+	}
+	if err := manager.StartApp("Blog"); err != nil {
+		t.Fatalf("StartApp: %v", err)
+	}
+	if status, _ := manager.GetAppStatus("Blog"); status != constants.AppStatusRunning {
+		t.Errorf("expected the app to be running, got %s", status)
+	}
+	if n, _ := manager.CountRunningContainers("Blog"); n != 1 {
+		t.Errorf("expected one running container, got %d", n)
+	}
+	states, err := manager.InspectAppContainers("Blog")
+	if err != nil || len(states) != 1 || states[0].Status != "running" || states[0].Name != "blog-app-1" {
+		t.Errorf("unexpected container states %+v, %v", states, err)
+	}
+
+	// Restarting only the tunnel sidecar leaves the app as it is
+	if err := manager.StopApp("Blog"); err != nil {
+		t.Fatalf("StopApp: %v", err)
+	}
+	if err := manager.UpTunnel("Blog"); err != nil {
+		t.Fatalf("UpTunnel: %v", err)
+	}
+	if status, _ := manager.GetAppStatus("Blog"); status != constants.AppStatusStopped {
+		t.Errorf("expected the app to be stopped, got %s", status)
+	}
+}
+
+func TestStubCommandExecutor_InjectedFailure(t *testing.T) {
+	injector := chaos.New(chaos.Config{FailureRate: 1, FailOperations: []string{"compose_up"}})
+	manager := NewManagerWithExecutor(t.TempDir(), NewStubCommandExecutor(injector))
+	if err := manager.CreateAppDirectory("blog", "services:\n  web:\n    image: nginx\n"); err != nil {
+		t.Fatalf("CreateAppDirectory: %v", err)
+	}
+
+	if err := manager.StartApp("blog"); !errors.Is(err, chaos.ErrInjected) {
+		t.Errorf("expected the injected failure, got %v", err)
+	}
+	if status, err := manager.GetAppStatus("blog"); err != nil || status != constants.AppStatusStopped {
+		t.Errorf("expected the failed start to leave the app stopped, got %s, %v", status, err)
+	}
+}
@@ -0,0 +1,24 @@
+package domain
+
+import "context"
+
+// ActingUser is the signed-in user a request acts for. It travels with requests the primary sends
+// to other nodes on the user's behalf, so changes there are attributed to and limited by the user.
+type ActingUser struct {
+	Name string
+	Role string
+}
+
+// actingUserKey is the context key of the ActingUser
+type actingUserKey struct{}
+
+// WithActingUser returns a copy of ctx carrying user
+func WithActingUser(ctx context.Context, user ActingUser) context.Context {
+	return context.WithValue(ctx, actingUserKey{}, user)
+}
+
+// ActingUserFromContext returns the user ctx acts for; the zero ActingUser when there is none
+func ActingUserFromContext(ctx context.Context) ActingUser {
+	user, _ := ctx.Value(actingUserKey{}).(ActingUser)
+	return user
+}
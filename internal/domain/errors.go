@@ -3,6 +3,7 @@ package domain
 import (
 	"errors"
 	"fmt"
+	"time"
 )
 
 // ============================================================================
@@ -74,6 +75,37 @@ const (
 	codeRequiredFieldMissing     = "REQUIRED_FIELD_MISSING"
 	codeAppNameInvalid           = "APP_NAME_INVALID"
 	codeDatabaseOperation        = "DATABASE_OPERATION_FAILED"
+	codeAppLocked                = "APP_LOCKED"
+
+	codeQueuedOperationNotFound = "QUEUED_OPERATION_NOT_FOUND"
+	codeFeatureFlagNotFound     = "FEATURE_FLAG_NOT_FOUND"
+	codeWebhookNotFound         = "WEBHOOK_NOT_FOUND"
+	codeInsufficientDiskSpace   = "INSUFFICIENT_DISK_SPACE"
+	codeTunnelInUse             = "TUNNEL_IN_USE"
+	codeZoneNotFound            = "ZONE_NOT_FOUND"
+	codeNodeNotFound            = "NODE_NOT_FOUND"
+	codeTaskNotFound            = "TASK_NOT_FOUND"
+	codeSnapshotNotFound        = "SNAPSHOT_NOT_FOUND"
+	codeNodeHasApps             = "NODE_HAS_APPS"
+	codeGatewayTokenNotFound    = "GATEWAY_TOKEN_NOT_FOUND"
+	codeInvalidTransition       = "INVALID_STATUS_TRANSITION"
+	codeOperationsLocked        = "OPERATIONS_LOCKED"
+	codePolicyApprovalRequired  = "POLICY_APPROVAL_REQUIRED"
+	codeApprovalNotFound        = "APPROVAL_NOT_FOUND"
+	codeApprovalTooEarly        = "APPROVAL_TOO_EARLY"
+	codeQuotaNotFound           = "QUOTA_NOT_FOUND"
+	codeQuotaExceeded           = "QUOTA_EXCEEDED"
+	codeUserNotFound            = "USER_NOT_FOUND"
+	codeUserExists              = "USER_EXISTS"
+	codeInvitationNotFound      = "INVITATION_NOT_FOUND"
+	codeSessionNotFound         = "SESSION_NOT_FOUND"
+	codeStatusPageNotFound      = "STATUS_PAGE_NOT_FOUND"
+	codeAppIconNotFound         = "APP_ICON_NOT_FOUND"
+	codeJobNotFound             = "JOB_NOT_FOUND"
+	codeComposeModified         = "COMPOSE_MODIFIED"
+	codePortReservationNotFound = "PORT_RESERVATION_NOT_FOUND"
+	codePortReserved            = "PORT_RESERVED"
+	codeAPITokenNotFound        = "API_TOKEN_NOT_FOUND"
 )
 
 // WrapAppNotFound wraps an error as an app not found error
@@ -121,6 +153,280 @@ func WrapDatabaseOperation(operation string, cause error) error {
 	}
 }
 
+// WrapAppLocked reports that another operation holds the app's lease
+func WrapAppLocked(appID, operation string) error {
+	return &DomainError{
+		Code:    codeAppLocked,
+		Message: fmt.Sprintf("app %s is busy: %s in progress", appID, operation),
+	}
+}
+
+// WrapQueuedOperationNotFound reports a queued node operation that doesn't exist or is no longer pending
+func WrapQueuedOperationNotFound(operationID string, cause error) error {
+	return &DomainError{
+		Code:    codeQueuedOperationNotFound,
+		Message: fmt.Sprintf("pending queued operation not found: %s", operationID),
+		Cause:   cause,
+	}
+}
+
+// WrapFeatureFlagNotFound reports a feature flag name that isn't defined
+func WrapFeatureFlagNotFound(name string) error {
+	return &DomainError{
+		Code:    codeFeatureFlagNotFound,
+		Message: fmt.Sprintf("unknown feature flag: %s", name),
+	}
+}
+
+// WrapSettingsSectionNotFound reports a settings section name that isn't defined
+func WrapSettingsSectionNotFound(section string) error {
+	return &DomainError{
+		Code:    codeSettingsNotFound,
+		Message: fmt.Sprintf("unknown settings section: %s", section),
+	}
+}
+
+// WrapWebhookNotFound reports a webhook that doesn't exist on the app
+func WrapWebhookNotFound(webhookID string, cause error) error {
+	return &DomainError{
+		Code:    codeWebhookNotFound,
+		Message: fmt.Sprintf("webhook not found: %s", webhookID),
+		Cause:   cause,
+	}
+}
+
+// WrapTaskNotFound reports a recurring task that doesn't exist on the app
+func WrapTaskNotFound(taskID string, cause error) error {
+	return &DomainError{
+		Code:    codeTaskNotFound,
+		Message: fmt.Sprintf("task not found: %s", taskID),
+		Cause:   cause,
+	}
+}
+
+// WrapSnapshotNotFound reports a snapshot that doesn't exist on the app
+func WrapSnapshotNotFound(snapshotID string, cause error) error {
+	return &DomainError{
+		Code:    codeSnapshotNotFound,
+		Message: fmt.Sprintf("snapshot not found: %s", snapshotID),
+		Cause:   cause,
+	}
+}
+
+// WrapGatewayTokenNotFound reports a gateway token that doesn't exist or is already revoked
+func WrapGatewayTokenNotFound(tokenID string, cause error) error {
+	return &DomainError{
+		Code:    codeGatewayTokenNotFound,
+		Message: fmt.Sprintf("gateway token not found: %s", tokenID),
+		Cause:   cause,
+	}
+}
+
+// WrapApprovalNotFound reports an approval that doesn't exist or is no longer pending
+func WrapApprovalNotFound(approvalID string, cause error) error {
+	return &DomainError{
+		Code:    codeApprovalNotFound,
+		Message: fmt.Sprintf("pending approval not found: %s", approvalID),
+		Cause:   cause,
+	}
+}
+
+// WrapApprovalTooEarly reports an admin confirming their own request before the delay is up
+func WrapApprovalTooEarly(approvalID string, confirmableAt time.Time) error {
+	return &DomainError{
+		Code:    codeApprovalTooEarly,
+		Message: fmt.Sprintf("approval %s needs another admin, or the requester after %s", approvalID, confirmableAt.Format(time.RFC3339)),
+	}
+}
+
+// WrapInsufficientDiskSpace reports an operation refused because the node is low on disk space.
+// The cause is included in the message so the user can see which filesystem is full.
+func WrapInsufficientDiskSpace(operation string, cause error) error {
+	return &DomainError{
+		Code:    codeInsufficientDiskSpace,
+		Message: fmt.Sprintf("cannot %s: %v", operation, cause),
+		Cause:   cause,
+	}
+}
+
+// WrapOperationsLocked reports an app change refused because an admin has locked operations, e.g.
+// for host maintenance; the reason they gave is included
+func WrapOperationsLocked(reason string) error {
+	return &DomainError{
+		Code:    codeOperationsLocked,
+		Message: fmt.Sprintf("operations are locked: %s", reason),
+	}
+}
+
+// WrapPolicyApprovalRequired reports a compose file that uses settings a compose policy only lets
+// through with an admin's approval; the message names the policies to approve
+func WrapPolicyApprovalRequired(cause error) error {
+	return &DomainError{
+		Code:    codePolicyApprovalRequired,
+		Message: fmt.Sprintf("admin approval required: %v", cause),
+		Cause:   cause,
+	}
+}
+
+// WrapQuotaNotFound reports a user without a quota of their own
+func WrapQuotaNotFound(userName string, cause error) error {
+	return &DomainError{
+		Code:    codeQuotaNotFound,
+		Message: fmt.Sprintf("no quota for user %s", userName),
+		Cause:   cause,
+	}
+}
+
+// WrapQuotaExceeded reports an app change refused because it would take the app's owner over
+// their quota; detail says which limit and by how much
+func WrapQuotaExceeded(userName, detail string) error {
+	return &DomainError{
+		Code:    codeQuotaExceeded,
+		Message: fmt.Sprintf("quota exceeded for user %s: %s", userName, detail),
+	}
+}
+
+// WrapUserNotFound reports a user without an account; users in GITHUB_ALLOWED_USERS have none
+func WrapUserNotFound(userName string, cause error) error {
+	return &DomainError{
+		Code:    codeUserNotFound,
+		Message: fmt.Sprintf("user not found: %s", userName),
+		Cause:   cause,
+	}
+}
+
+// WrapUserExists reports an invitation accepted by a user who already has access
+func WrapUserExists(userName string) error {
+	return &DomainError{
+		Code:    codeUserExists,
+		Message: fmt.Sprintf("user %s already has access", userName),
+	}
+}
+
+// WrapInvitationNotFound reports an invitation that doesn't exist or can't be used any more;
+// accepting one doesn't say which, so invite tokens can't be probed
+func WrapInvitationNotFound(cause error) error {
+	return &DomainError{
+		Code:    codeInvitationNotFound,
+		Message: "invitation is invalid, expired, revoked or already used",
+		Cause:   cause,
+	}
+}
+
+// WrapSessionNotFound reports a session the user doesn't have, or has already revoked
+func WrapSessionNotFound(sessionID string, cause error) error {
+	return &DomainError{
+		Code:    codeSessionNotFound,
+		Message: fmt.Sprintf("session not found: %s", sessionID),
+		Cause:   cause,
+	}
+}
+
+// WrapAPITokenNotFound reports an API token the user doesn't have, or has already revoked
+func WrapAPITokenNotFound(tokenID string, cause error) error {
+	return &DomainError{
+		Code:    codeAPITokenNotFound,
+		Message: fmt.Sprintf("API token not found: %s", tokenID),
+		Cause:   cause,
+	}
+}
+
+// WrapStatusPageNotFound reports an app without a status page, or a status page token that is
+// unknown or was replaced
+func WrapStatusPageNotFound(cause error) error {
+	return &DomainError{
+		Code:    codeStatusPageNotFound,
+		Message: "status page not found",
+		Cause:   cause,
+	}
+}
+
+// WrapAppIconNotFound reports an app whose icon couldn't be found, with why
+func WrapAppIconNotFound(appID, reason string) error {
+	return &DomainError{
+		Code:    codeAppIconNotFound,
+		Message: fmt.Sprintf("no icon found for app %s: %s", appID, reason),
+	}
+}
+
+// WrapJobNotFound wraps an error as a job not found error
+func WrapJobNotFound(jobID string, cause error) error {
+	return &DomainError{
+		Code:    codeJobNotFound,
+		Message: fmt.Sprintf("job not found: %s", jobID),
+		Cause:   cause,
+	}
+}
+
+// WrapComposeModified wraps a refusal to deploy an app whose docker-compose.yml was edited outside
+// of its compose versions
+func WrapComposeModified(appID string, version int) error {
+	return &DomainError{
+		Code:    codeComposeModified,
+		Message: fmt.Sprintf("docker-compose.yml of app %s was edited on disk and no longer matches compose version %d; save the edit as a new version or restore the file", appID, version),
+	}
+}
+
+// WrapPortReservationNotFound wraps an error as a port reservation not found error
+func WrapPortReservationNotFound(reservationID string, cause error) error {
+	return &DomainError{
+		Code:    codePortReservationNotFound,
+		Message: fmt.Sprintf("port reservation not found: %s", reservationID),
+		Cause:   cause,
+	}
+}
+
+// WrapPortReserved reports a host port that is already reserved on the node; holder says by what
+func WrapPortReserved(port int, protocol, holder string) error {
+	return &DomainError{
+		Code:    codePortReserved,
+		Message: fmt.Sprintf("host port %d/%s is already reserved by %s", port, protocol, holder),
+	}
+}
+
+// WrapTunnelInUse reports a tunnel that can't be deleted on its own because an app may still use it
+func WrapTunnelInUse(tunnelID, reason string) error {
+	return &DomainError{
+		Code:    codeTunnelInUse,
+		Message: fmt.Sprintf("tunnel %s is in use: %s", tunnelID, reason),
+	}
+}
+
+// WrapZoneNotFound reports a DNS zone the tunnel provider doesn't know or can't access
+func WrapZoneNotFound(zoneID string, cause error) error {
+	return &DomainError{
+		Code:    codeZoneNotFound,
+		Message: fmt.Sprintf("zone not found: %s", zoneID),
+		Cause:   cause,
+	}
+}
+
+// WrapNodeNotFound wraps an error as a node not found error
+func WrapNodeNotFound(nodeID string, cause error) error {
+	return &DomainError{
+		Code:    codeNodeNotFound,
+		Message: fmt.Sprintf("node not found: %s", nodeID),
+		Cause:   cause,
+	}
+}
+
+// WrapNodeHasApps reports a node that can't be deleted outright because apps are still deployed on it
+func WrapNodeHasApps(nodeID string, count int) error {
+	return &DomainError{
+		Code:    codeNodeHasApps,
+		Message: fmt.Sprintf("node %s still has %d apps; remove it with strategy orphan, migrate or purge", nodeID, count),
+	}
+}
+
+// WrapInvalidTransition reports an app status change its lifecycle doesn't allow, e.g. because
+// another operation moved the app on in the meantime
+func WrapInvalidTransition(appID, from, to string) error {
+	return &DomainError{
+		Code:    codeInvalidTransition,
+		Message: fmt.Sprintf("app %s cannot go from %s to %s", appID, from, to),
+	}
+}
+
 // WrapValidationError wraps an error as a validation failure
 // For validation errors, we include the cause details in the message since they're safe and helpful for users
 func WrapValidationError(field string, cause error) error {
@@ -147,7 +453,25 @@ func IsNotFoundError(err error) bool {
 			domainErr.Code == ErrTunnelNotFound.Code ||
 			domainErr.Code == codeContainerNotFound ||
 			domainErr.Code == ErrComposeVersionNotFound.Code ||
-			domainErr.Code == codeSettingsNotFound
+			domainErr.Code == codeSettingsNotFound ||
+			domainErr.Code == codeQueuedOperationNotFound ||
+			domainErr.Code == codeFeatureFlagNotFound ||
+			domainErr.Code == codeWebhookNotFound ||
+			domainErr.Code == codeZoneNotFound ||
+			domainErr.Code == codeNodeNotFound ||
+			domainErr.Code == codeTaskNotFound ||
+			domainErr.Code == codeSnapshotNotFound ||
+			domainErr.Code == codeGatewayTokenNotFound ||
+			domainErr.Code == codeApprovalNotFound ||
+			domainErr.Code == codeQuotaNotFound ||
+			domainErr.Code == codeUserNotFound ||
+			domainErr.Code == codeInvitationNotFound ||
+			domainErr.Code == codeSessionNotFound ||
+			domainErr.Code == codeStatusPageNotFound ||
+			domainErr.Code == codeAppIconNotFound ||
+			domainErr.Code == codeJobNotFound ||
+			domainErr.Code == codePortReservationNotFound ||
+			domainErr.Code == codeAPITokenNotFound
 	}
 	return false
 }
@@ -164,6 +488,58 @@ func IsValidationError(err error) bool {
 	return false
 }
 
+// IsConflictError checks if an error is caused by a conflicting operation on the same resource
+func IsConflictError(err error) bool {
+	var domainErr *DomainError
+	if errors.As(err, &domainErr) {
+		return domainErr.Code == codeAppLocked ||
+			domainErr.Code == codeTunnelInUse ||
+			domainErr.Code == codeNodeHasApps ||
+			domainErr.Code == codeInvalidTransition ||
+			domainErr.Code == codeApprovalTooEarly ||
+			domainErr.Code == codeUserExists ||
+			domainErr.Code == codeComposeModified ||
+			domainErr.Code == codePortReserved
+	}
+	return false
+}
+
+// IsInsufficientStorageError checks if an operation was refused for lack of disk space
+func IsInsufficientStorageError(err error) bool {
+	var domainErr *DomainError
+	if errors.As(err, &domainErr) {
+		return domainErr.Code == codeInsufficientDiskSpace
+	}
+	return false
+}
+
+// IsPolicyApprovalRequiredError checks if a compose file needs an admin to approve its policies
+func IsPolicyApprovalRequiredError(err error) bool {
+	var domainErr *DomainError
+	if errors.As(err, &domainErr) {
+		return domainErr.Code == codePolicyApprovalRequired
+	}
+	return false
+}
+
+// IsQuotaExceededError checks if a change was refused because of the owner's quota
+func IsQuotaExceededError(err error) bool {
+	var domainErr *DomainError
+	if errors.As(err, &domainErr) {
+		return domainErr.Code == codeQuotaExceeded
+	}
+	return false
+}
+
+// IsOperationsLockedError checks if a change was refused because operations are locked
+func IsOperationsLockedError(err error) bool {
+	var domainErr *DomainError
+	if errors.As(err, &domainErr) {
+		return domainErr.Code == codeOperationsLocked
+	}
+	return false
+}
+
 // PublicMessage returns a safe, user-facing message for API responses.
 // For DomainError it returns only the Message (never Cause, to avoid leaking DB/driver internals).
 // For other errors it returns a generic message.
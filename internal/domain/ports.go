@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"github.com/selfhostly/internal/db"
+	"github.com/selfhostly/internal/features"
 	"github.com/selfhostly/internal/system"
 	"github.com/selfhostly/internal/tunnel"
 )
@@ -17,11 +18,14 @@ import (
 type AppService interface {
 	CreateApp(ctx context.Context, req CreateAppRequest) (*db.App, error)
 	GetApp(ctx context.Context, appID string, nodeID string) (*db.App, error)
-	GetAppWithSchedule(ctx context.Context, appID string, nodeID string) (*db.App, error)
+	// GetAppWithSchedule and ListAppsWithSchedules correct stale statuses against docker when reconcile
+	// is true (bypassing the ps cache) or when status reconciliation on read is enabled in config.
+	GetAppWithSchedule(ctx context.Context, appID string, nodeID string, reconcile bool) (*db.App, error)
 	ListApps(ctx context.Context, nodeIDs []string) ([]*db.App, error)
-	ListAppsWithSchedules(ctx context.Context, nodeIDs []string) ([]*db.App, error)
+	// ListAppsWithSchedules returns what it could list along with the nodes it couldn't list fully
+	ListAppsWithSchedules(ctx context.Context, nodeIDs []string, reconcile bool) ([]*db.App, []NodeError, error)
 	UpdateApp(ctx context.Context, appID string, nodeID string, req UpdateAppRequest) (*db.App, error)
-	DeleteApp(ctx context.Context, appID string, nodeID string) error
+	DeleteApp(ctx context.Context, appID string, nodeID string, opts DeleteAppOptions) (*DeleteAppResult, error)
 	StartApp(ctx context.Context, appID string, nodeID string) (*db.App, error)
 	StopApp(ctx context.Context, appID string, nodeID string) (*db.App, error)
 	UpdateAppContainers(ctx context.Context, appID string, nodeID string) (*db.App, error)
@@ -29,29 +33,50 @@ type AppService interface {
 	RestartAppService(ctx context.Context, appID string, nodeID string, serviceName string) error
 
 	// Async job-based operations (return job instead of waiting for completion)
-	UpdateAppContainersAsync(ctx context.Context, appID string) (*db.Job, error)
+	// When nodeID (or req.NodeID) names another node, the job is queued on that node instead
+	UpdateAppContainersAsync(ctx context.Context, appID string, nodeID string) (*db.Job, error)
 	CreateAppAsync(ctx context.Context, req CreateAppRequest) (*db.Job, error)
-	CreateTunnelForAppAsync(ctx context.Context, appID string, ingressRules []db.IngressRule) (*db.Job, error)
-	CreateQuickTunnelForAppAsync(ctx context.Context, appID string, service string, port int) (*db.Job, error)
-	SwitchAppToCustomTunnelAsync(ctx context.Context, appID string, ingressRules []db.IngressRule) (*db.Job, error)
-	DeleteTunnelAsync(ctx context.Context, appID string) (*db.Job, error)
+	CreateTunnelForAppAsync(ctx context.Context, appID string, nodeID string, ingressRules []db.IngressRule) (*db.Job, error)
+	CreateQuickTunnelForAppAsync(ctx context.Context, appID string, nodeID string, service string, port int) (*db.Job, error)
+	SwitchAppToCustomTunnelAsync(ctx context.Context, appID string, nodeID string, ingressRules []db.IngressRule) (*db.Job, error)
+	DeleteTunnelAsync(ctx context.Context, appID string, nodeID string, opts DeleteTunnelOptions) (*db.Job, error)
 	StartAppAsync(ctx context.Context, appID string) (*db.Job, error)
 	StopAppAsync(ctx context.Context, appID string) (*db.Job, error)
+	RunAppCommandAsync(ctx context.Context, appID string, req RunAppCommandRequest) (*db.Job, error)
 
 	// Scheduler operations (called by scheduler, not exposed via HTTP)
 	CreateStartJob(ctx context.Context, appID string) error
 	CreateStopJob(ctx context.Context, appID string) error
 
+	// RecordImageDigests resolves the app's running image digests and stores them on its current
+	// compose version. It is a no-op unless image digest pinning is enabled.
+	RecordImageDigests(ctx context.Context, appID string) error
+
 	// CreateTunnelForApp creates a named (custom domain) tunnel for an app that has none. When nodeID is remote, the request is forwarded to that node (all-or-nothing).
 	// Returns (app, handledLocally, error). handledLocally is true when the work was done on this node so the HTTP layer may apply optional ingress_rules.
 	CreateTunnelForApp(ctx context.Context, appID string, nodeID string, body interface{}) (*db.App, bool, error)
 	// SwitchAppToCustomTunnel switches an app from Quick Tunnel to a named (custom domain) tunnel.
-	// When nodeID is a remote node, the request is forwarded to that node (all-or-nothing). body is optional (ingress_rules).
-	SwitchAppToCustomTunnel(ctx context.Context, appID string, nodeID string, body interface{}) (*db.App, error)
+	// ingressRules, when given, are applied to the named tunnel before it replaces the Quick Tunnel.
+	SwitchAppToCustomTunnel(ctx context.Context, appID string, nodeID string, ingressRules []db.IngressRule) (*db.App, error)
 	// GetQuickTunnelURL runs Quick Tunnel URL extraction on the node that hosts the app and returns the URL.
 	GetQuickTunnelURL(ctx context.Context, appID string, nodeID string) (string, error)
 	// CreateQuickTunnelForApp adds a Quick Tunnel (temporary trycloudflare.com URL) to an app that has no tunnel.
 	CreateQuickTunnelForApp(ctx context.Context, appID string, nodeID string, service string, port int) (*db.App, error)
+	// SetTunnelReplicas runs an app's named tunnel in that many containers, so restarting one doesn't
+	// take the app's public URL down. The provider must allow several connectors per tunnel (local only).
+	SetTunnelReplicas(ctx context.Context, appID string, nodeID string, replicas int) (*db.App, error)
+	// SetTunnelOptions saves connector options (e.g. cloudflared's edge protocol) on an app's named
+	// tunnel and restarts its tunnel containers with them (local only).
+	SetTunnelOptions(ctx context.Context, appID string, nodeID string, options tunnel.ConnectorOptions) (*db.App, error)
+	// ImportTunnel adopts a tunnel that already exists in the provider account, attaching it to an
+	// app without a tunnel or to a new app created around it (local only).
+	ImportTunnel(ctx context.Context, req ImportTunnelRequest) (*db.App, error)
+	// PreviewCompose returns the compose docker compose would receive for an app, with variables
+	// substituted and the tunnel sidecar merged in, without saving or deploying (local only).
+	PreviewCompose(ctx context.Context, appID string, req ComposePreviewRequest) (*ComposePreview, error)
+	// Quotas returns the quota service the app service checks quotas with, so other services
+	// check them with the same one.
+	Quotas() QuotaService
 }
 
 type ScheduleNextRuns struct {
@@ -78,8 +103,17 @@ type TunnelService interface {
 	ListActiveTunnels(ctx context.Context, nodeIDs []string) ([]*db.CloudflareTunnel, error)
 	SyncTunnelStatus(ctx context.Context, appID string, nodeID string) error
 	UpdateTunnelIngress(ctx context.Context, appID string, nodeID string, req UpdateIngressRequest) error
+	// CheckIngressTargets flags ingress rules whose service URL matches no service or port in the
+	// app's compose (local only). The rules are not changed.
+	CheckIngressTargets(ctx context.Context, appID string, rules []db.IngressRule) ([]IngressWarning, error)
 	CreateDNSRecord(ctx context.Context, appID string, nodeID string, req CreateDNSRequest) error
 	DeleteTunnel(ctx context.Context, appID string, nodeID string) error
+	// GetTunnelConnectorStatus asks the provider which connectors serve an app's named tunnel, so a
+	// tunnel nothing runs can be told apart from a healthy one (local only)
+	GetTunnelConnectorStatus(ctx context.Context, appID string, nodeID string) (*TunnelConnectorStatus, error)
+	// GetAppTraffic reports the requests, bandwidth and visits the provider's edge served for an
+	// app's tunnel hostnames over a period (local only)
+	GetAppTraffic(ctx context.Context, appID string, nodeID string, period string) (*AppTraffic, error)
 
 	// Quick Tunnel operations (provider-specific)
 	// These delegate to QuickTunnelProvider if the active provider supports it
@@ -90,6 +124,21 @@ type TunnelService interface {
 	// Provider discovery (NEW)
 	ListProviders(ctx context.Context) ([]ProviderInfo, error)
 	GetProviderFeatures(ctx context.Context, providerName string) (*ProviderFeatures, error)
+
+	// CheckProviderHealth verifies the active provider's API is reachable and returns the
+	// provider name. Fails with tunnel.ErrProviderNotConfigured when no provider is set up.
+	CheckProviderHealth(ctx context.Context) (string, error)
+
+	// ListTunnelInventory lists tunnels across all nodes and the provider account, flagging
+	// tunnels no app uses any more. DeleteOrphanedTunnel deletes one of those from the account.
+	ListTunnelInventory(ctx context.Context) (*TunnelInventory, error)
+	DeleteOrphanedTunnel(ctx context.Context, tunnelID string) error
+
+	// ListProviderZones lists the DNS zones a configured provider can route hostnames in, and
+	// ListZoneHostnames the existing records in one of them, for ingress editors to offer and
+	// check hostnames before submitting rules.
+	ListProviderZones(ctx context.Context, providerName string) ([]*tunnel.Zone, error)
+	ListZoneHostnames(ctx context.Context, providerName, zoneID string) ([]*tunnel.Hostname, error)
 }
 
 // ProviderInfo contains metadata about an available tunnel provider
@@ -111,11 +160,16 @@ type ProviderFeatures struct {
 type SystemService interface {
 	GetSystemStats(ctx context.Context, nodeIDs []string) ([]*system.SystemStats, error)
 	GetAppStats(ctx context.Context, appID string, nodeID string) (*AppStats, error)
+	StreamAppStats(ctx context.Context, appID string, interval time.Duration, fn func(*AppStats) error) error
 	GetAppLogs(ctx context.Context, appID string, nodeID string, service string) ([]byte, error)
 	GetAppServices(ctx context.Context, appID string, nodeID string) ([]string, error)
 	RestartContainer(ctx context.Context, containerID, nodeID string) error
 	StopContainer(ctx context.Context, containerID, nodeID string) error
 	DeleteContainer(ctx context.Context, containerID, nodeID string) error
+	SearchLogs(ctx context.Context, req LogSearchRequest, nodeIDs []string) ([]*LogSearchMatch, error)
+	ReloadConfig(ctx context.Context) (*ConfigReload, error)
+	GetLogSettings(ctx context.Context, nodeID string) (*LogSettings, error)
+	UpdateLogSettings(ctx context.Context, nodeID string, req UpdateLogSettingsRequest) (*LogSettings, error)
 }
 
 // ComposeService defines the primary port for compose version management
@@ -123,6 +177,8 @@ type ComposeService interface {
 	GetVersions(ctx context.Context, appID string, nodeID string) ([]*db.ComposeVersion, error)
 	GetVersion(ctx context.Context, appID string, version int, nodeID string) (*db.ComposeVersion, error)
 	RollbackToVersion(ctx context.Context, appID string, version int, nodeID string, reason *string, changedBy *string) (*db.ComposeVersion, error)
+	CheckIntegrity(ctx context.Context, appID string, nodeID string) (*ComposeIntegrity, error)
+	RestoreComposeFile(ctx context.Context, appID string, nodeID string) (*ComposeIntegrity, error)
 }
 
 // NodeService defines the primary port for node management use cases
@@ -132,11 +188,321 @@ type NodeService interface {
 	ListNodes(ctx context.Context) ([]*db.Node, error)
 	UpdateNode(ctx context.Context, nodeID string, req UpdateNodeRequest) (*db.Node, error)
 	DeleteNode(ctx context.Context, nodeID string) error
+	RemoveNodeAsync(ctx context.Context, nodeID string, req RemoveNodeRequest) (*db.Job, error)
+	RemoveNode(ctx context.Context, nodeID string, req RemoveNodeRequest, progress func(percent int, message string)) (*RemoveNodeResult, error)
 	HealthCheckNode(ctx context.Context, nodeID string) error
 	HealthCheckAllNodes(ctx context.Context) error
-	NodeHeartbeat(ctx context.Context, nodeID string) error
+	NodeHeartbeat(ctx context.Context, nodeID string, reported NodeVersion, clockSkew *time.Duration) error
 	SyncSettingsFromPrimary(ctx context.Context) error
+	SyncPrimaryReplica(ctx context.Context) error
+	ListReplicaNodes(ctx context.Context) (*ReplicaNodes, error)
 	GetCurrentNodeInfo(ctx context.Context) (*db.Node, error)
+
+	// Offline operation queue
+	QueueOperation(ctx context.Context, nodeID string, req QueueOperationRequest) (*db.QueuedOperation, error)
+	ListQueuedOperations(ctx context.Context, nodeID string, status string) ([]*db.QueuedOperation, error)
+	CancelQueuedOperation(ctx context.Context, nodeID string, operationID string) error
+	ReplayQueuedOperations(ctx context.Context, nodeID string) error
+
+	// App inventory sync (secondary pushes, primary caches)
+	PushAppInventory(ctx context.Context) error
+	SyncAppInventory(ctx context.Context, nodeID string, req AppInventorySyncRequest) (*AppInventorySyncResponse, error)
+
+	// Server log shipping (secondary pushes, primary stores)
+	PushNodeLogs(ctx context.Context, entries []NodeLogEntry) error
+	IngestNodeLogs(ctx context.Context, nodeID string, req NodeLogPushRequest) error
+	GetNodeLogs(ctx context.Context, nodeID string, query NodeLogQuery) ([]*db.NodeLog, error)
+}
+
+// TelemetryService defines the primary port for opt-in anonymous usage reporting
+type TelemetryService interface {
+	Preview(ctx context.Context) (*TelemetryReport, error)
+	SendReport(ctx context.Context) error
+}
+
+// FeatureService defines the primary port for experimental feature flags
+type FeatureService interface {
+	IsEnabled(ctx context.Context, flag features.Flag) bool
+	ListFeatureFlags(ctx context.Context) ([]*FeatureFlag, error)
+	SetFeatureFlag(ctx context.Context, name string, enabled bool) (*FeatureFlag, error)
+}
+
+// WebhookService defines the primary port for per-app lifecycle webhooks
+type WebhookService interface {
+	ListWebhooks(ctx context.Context, appID string) ([]*db.AppWebhook, error)
+	CreateWebhook(ctx context.Context, appID string, req CreateWebhookRequest) (*db.AppWebhook, error)
+	UpdateWebhook(ctx context.Context, appID, webhookID string, req UpdateWebhookRequest) (*db.AppWebhook, error)
+	DeleteWebhook(ctx context.Context, appID, webhookID string) error
+	TestWebhook(ctx context.Context, appID, webhookID string) (*db.AppWebhook, error)
+}
+
+// TaskService defines the primary port for recurring tasks, commands run in an app's services on
+// a cron schedule
+type TaskService interface {
+	ListTasks(ctx context.Context, appID string) ([]*db.AppTask, error)
+	CreateTask(ctx context.Context, appID string, req CreateTaskRequest) (*db.AppTask, error)
+	UpdateTask(ctx context.Context, appID, taskID string, req UpdateTaskRequest) (*db.AppTask, error)
+	DeleteTask(ctx context.Context, appID, taskID string) error
+	GetTaskRuns(ctx context.Context, appID, taskID string) ([]*db.AppTaskRun, error)
+	RunTaskAsync(ctx context.Context, appID, taskID string) (*db.Job, error)
+
+	// CreateTaskJob queues a scheduled run of a task (called by scheduler, not exposed via HTTP).
+	// A run is skipped while the previous one is still queued or running.
+	CreateTaskJob(ctx context.Context, taskID string) error
+}
+
+// SnapshotService defines the primary port for app snapshots, which pin an app's compose files,
+// .env and image digests so that exact deployment can be restored
+type SnapshotService interface {
+	ListSnapshots(ctx context.Context, appID string) ([]*db.AppSnapshot, error)
+	GetSnapshot(ctx context.Context, appID, snapshotID string) (*db.AppSnapshot, error)
+	CreateSnapshot(ctx context.Context, appID string, req CreateSnapshotRequest, createdBy *string) (*db.AppSnapshot, error)
+	DeleteSnapshot(ctx context.Context, appID, snapshotID string) error
+
+	// RestoreSnapshot writes the snapshot's files back as a new compose version, with its images
+	// pinned to their digests. It doesn't touch the running containers.
+	RestoreSnapshot(ctx context.Context, appID, snapshotID string, changedBy *string) (*db.ComposeVersion, error)
+}
+
+// LogForwardingService defines the primary port for forwarding app container logs to Loki or
+// Elasticsearch through a sidecar deployed with each app that has it on
+type LogForwardingService interface {
+	GetAppLogForwarding(ctx context.Context, appID string) (*AppLogForwarding, error)
+
+	// SetAppLogForwarding turns forwarding on or off for an app and, if the app is running, adds
+	// or removes its sidecar right away
+	SetAppLogForwarding(ctx context.Context, appID string, enabled bool) (*AppLogForwarding, error)
+
+	// ApplyLogForwarding rewrites the sidecar of every app on this node after the destination
+	// settings changed, reconciling the running ones
+	ApplyLogForwarding(ctx context.Context) error
+}
+
+// PreferencesService defines the primary port for per-user UI and CLI preferences
+type PreferencesService interface {
+	// GetPreferences returns a user's preferences, with defaults for a user who hasn't saved any
+	GetPreferences(ctx context.Context, userID string) (*db.UserPreferences, error)
+	UpdatePreferences(ctx context.Context, userID string, req UpdatePreferencesRequest) (*db.UserPreferences, error)
+}
+
+// OperationsLockService defines the primary port for the admin lock that blocks app changes on
+// every node during host maintenance or backup windows
+type OperationsLockService interface {
+	GetStatus(ctx context.Context) (*OperationsLockStatus, error)
+
+	// Lock and Unlock change the lock on the primary and push it to the online secondaries; the
+	// others pick it up from their next heartbeat reply
+	Lock(ctx context.Context, req LockOperationsRequest, lockedBy string) (*OperationsLockStatus, error)
+	Unlock(ctx context.Context, unlockedBy string) (*OperationsLockStatus, error)
+
+	// ApplyFromPrimary stores the lock state the primary sent this secondary
+	ApplyFromPrimary(ctx context.Context, status OperationsLockStatus) error
+
+	// CheckUnlocked returns an operations locked error while app changes are blocked
+	CheckUnlocked(ctx context.Context) error
+}
+
+// ApprovalService defines the primary port for the two-step approval of app deletion, tunnel
+// deletion and node removal. Approvals are kept on the node that runs the operation.
+type ApprovalService interface {
+	// Required reports whether destructive operations wait for a second confirmation
+	Required() bool
+
+	// Request records an operation awaiting confirmation, returning the pending one if the same
+	// operation on the same resource was already requested
+	Request(ctx context.Context, operation, resourceID string, params map[string]string, requestedBy string) (*db.Approval, error)
+
+	ListApprovals(ctx context.Context, status string) ([]*db.Approval, error)
+	GetApproval(ctx context.Context, id string) (*db.Approval, error)
+
+	// Confirm marks a pending approval confirmed; the caller then runs the operation and records
+	// its outcome with RecordResult
+	Confirm(ctx context.Context, id, confirmedBy string) (*db.Approval, error)
+	Reject(ctx context.Context, id, rejectedBy string) (*db.Approval, error)
+	RecordResult(ctx context.Context, id string, err error)
+}
+
+// PortService defines the primary port for a node's host port reservations. Apps reserve the ports
+// their compose publishes on their own; other ports can be reserved by hand for something running
+// outside selfhostly. Reservations are kept on the node they are for.
+type PortService interface {
+	ListPortReservations(ctx context.Context) ([]*db.PortReservation, error)
+
+	// ReservePort reserves a port by hand, picking the lowest free one in the allocation range
+	// when the request names none
+	ReservePort(ctx context.Context, req ReservePortRequest) (*db.PortReservation, error)
+
+	// ReleasePort deletes a manual reservation; an app's reservations go with its compose
+	ReleasePort(ctx context.Context, reservationID string) error
+
+	// SyncAppReservations reserves the ports of every app on this node, logging the ones that
+	// conflict, to catch up on apps deployed before the registry
+	SyncAppReservations(ctx context.Context) error
+}
+
+// QuotaService defines the primary port for per-user app quotas. Quotas are kept on the primary,
+// which sees every node's apps; secondaries ask it before taking an app change.
+type QuotaService interface {
+	ListQuotas(ctx context.Context) ([]*db.UserQuota, error)
+	SetQuota(ctx context.Context, userName string, req SetQuotaRequest) (*db.UserQuota, error)
+	DeleteQuota(ctx context.Context, userName string) error
+
+	// GetUsage returns what a user's apps use across the cluster against the quota that applies
+	GetUsage(ctx context.Context, userName string) (*QuotaUsage, error)
+
+	// CheckApp reports whether creating or changing the app would take its owner over their quota
+	CheckApp(ctx context.Context, req QuotaCheckRequest) (*QuotaCheckResponse, error)
+}
+
+// UserService defines the primary port for invited users. Users in GITHUB_ALLOWED_USERS are admins
+// without an account; anyone else needs an account, created by accepting an invitation. Accounts
+// and invitations are kept on the primary.
+type UserService interface {
+	ListUsers(ctx context.Context) ([]*db.User, error)
+	DeleteUser(ctx context.Context, name string) error
+
+	// Invite creates a single-use invite link, emailing it when an email is given and SMTP is set up
+	Invite(ctx context.Context, req InviteUserRequest) (*IssuedInvitation, error)
+	ListInvitations(ctx context.Context) ([]*db.Invitation, error)
+	RevokeInvitation(ctx context.Context, id string) error
+
+	// AcceptInvitation creates the account of the signed-in GitHub user holding an invite token
+	AcceptInvitation(ctx context.Context, token, userName string) (*db.User, error)
+
+	// UserRole returns the role of a GitHub user, and false when they have no access
+	UserRole(ctx context.Context, userName string) (string, bool)
+
+	// UserRoles returns the role of every user who may sign in, for heartbeat replies
+	UserRoles(ctx context.Context) (map[string]string, error)
+	ApplyRolesFromPrimary(ctx context.Context, roles map[string]string) error
+
+	// RolesSynced reports whether a node other than the primary has its users yet; until then it
+	// trusts the role in a user's token
+	RolesSynced() bool
+}
+
+// SessionService defines the primary port for signed-in devices. Each node records the sessions
+// requests reach it with; sessions are revoked on the primary, and heartbeats take the
+// revocations to the other nodes.
+type SessionService interface {
+	// Touch records the session a request was made with, and reports false when it was revoked
+	Touch(ctx context.Context, session *db.Session) bool
+	// IsRevoked reports whether a session was revoked, so its token isn't refreshed
+	IsRevoked(ctx context.Context, id string) bool
+	ListSessions(ctx context.Context, userName, currentID string) ([]*db.Session, error)
+	RevokeSession(ctx context.Context, userName, id string) error
+
+	// RevokedSessionIDs returns the sessions revoked on this node, for heartbeat replies
+	RevokedSessionIDs(ctx context.Context) ([]string, error)
+	ApplyRevokedFromPrimary(ctx context.Context, ids []string) error
+}
+
+// APITokenService defines the primary port for personal access tokens, which let scripts and CI
+// call the API as the user who created them. Tokens are kept and checked on the primary.
+type APITokenService interface {
+	ListTokens(ctx context.Context, userName string) ([]*db.APIToken, error)
+	CreateToken(ctx context.Context, userName string, req CreateAPITokenRequest) (*IssuedAPIToken, error)
+	UpdateToken(ctx context.Context, userName, id string, req UpdateAPITokenRequest) (*db.APIToken, error)
+	RevokeToken(ctx context.Context, userName, id string) error
+
+	// VerifyToken returns the record of a token presented with a request, with the role its
+	// requests get, and records its use. Nodes other than the primary ask the primary.
+	VerifyToken(ctx context.Context, token string) (*VerifiedAPIToken, error)
+}
+
+// StatusPageService defines the primary port for public app status pages. A status page is served
+// by the node running its app, from the app's status history there.
+type StatusPageService interface {
+	GetStatusPage(ctx context.Context, appID string) (*db.AppStatusPage, error)
+
+	// EnableStatusPage gives an app a status page, or a new token for the one it has, which
+	// stops the old link working
+	EnableStatusPage(ctx context.Context, appID, createdBy, baseURL string) (*IssuedStatusPage, error)
+	DisableStatusPage(ctx context.Context, appID string) error
+
+	// GetAppStatus returns the status report of the app whose status page token is given
+	GetAppStatus(ctx context.Context, token string) (*AppStatusReport, error)
+
+	// PruneStatusHistory deletes app status history older than the uptime window; it runs in the
+	// background, not on public requests
+	PruneStatusHistory(ctx context.Context) error
+}
+
+// AppIconService defines the primary port for app icons, detected from each app's public URL (or
+// named by a compose label) and cached on the app's node
+type AppIconService interface {
+	// GetAppIcon returns an app's icon, looking for it again when the cached one is stale, its
+	// source changed or refresh is set
+	GetAppIcon(ctx context.Context, appID string, refresh bool) (*db.AppIcon, error)
+}
+
+// JobService defines the primary port for viewing background jobs, which each node keeps for the
+// operations it runs
+type JobService interface {
+	// ListJobs lists recent jobs on the requested nodes, newest first and tagged with their node,
+	// along with the nodes that couldn't be asked
+	ListJobs(ctx context.Context, filter JobListFilter, nodeIDs []string) ([]*db.Job, []NodeError, error)
+	// GetJob returns a job from the node that runs it, asking that node when it isn't this one
+	GetJob(ctx context.Context, jobID, nodeID string) (*db.Job, error)
+}
+
+// HealthService defines the primary port for the aggregated platform health report
+type HealthService interface {
+	CheckHealth(ctx context.Context) *HealthReport
+}
+
+// SettingsService defines the primary port for typed settings, grouped into documented sections
+type SettingsService interface {
+	ListSettingsSchema(ctx context.Context) []*SettingsSectionSchema
+	GetSettingsSection(ctx context.Context, section string) (*SettingsSection, error)
+	UpdateSettingsSection(ctx context.Context, section string, values map[string]interface{}, changedBy string) (*SettingsSection, error)
+	ListSettingsHistory(ctx context.Context, section string) ([]*db.SettingsChange, error)
+	SendTestEmail(ctx context.Context, to string) error
+}
+
+// AuditService defines the primary port for the consistency audit of this node's database
+type AuditService interface {
+	RunAudit(ctx context.Context) (*AuditReport, error)
+	LatestAudit(ctx context.Context) (*AuditReport, error)
+
+	// ListAudits returns the stored reports, newest first
+	ListAudits(ctx context.Context) ([]*AuditReport, error)
+}
+
+// ApplyService defines the primary port for reconciling apps with a declarative manifest
+type ApplyService interface {
+	Apply(ctx context.Context, manifest AppManifest, dryRun bool) (*ApplyResult, error)
+}
+
+// CrashMonitorService defines the primary port for detecting OOM kills and crash loops in this
+// node's apps
+type CrashMonitorService interface {
+	CheckApps(ctx context.Context) error
+	GetAppContainerHealth(ctx context.Context, appID string) (*AppContainerHealth, error)
+}
+
+// NodeMetricsService defines the primary port for node metrics history and threshold alerts
+type NodeMetricsService interface {
+	RecordNodeMetrics(ctx context.Context) error
+	GetNodeMetrics(ctx context.Context, nodeID string, since time.Time) ([]*db.NodeMetric, error)
+	ListNodeAlerts(ctx context.Context, nodeID string, activeOnly bool) ([]*db.NodeAlert, error)
+}
+
+// GatewayTokenService defines the primary port for the short-lived tokens the gateway uses to
+// fetch the node registry (primary only)
+type GatewayTokenService interface {
+	ListTokens(ctx context.Context) ([]*db.GatewayToken, error)
+	IssueToken(ctx context.Context, req IssueGatewayTokenRequest) (*IssuedGatewayToken, error)
+	RevokeToken(ctx context.Context, tokenID string) error
+
+	// RotateToken replaces a still active token with a new one of the same lifetime and revokes it
+	RotateToken(ctx context.Context, tokenID string) (*IssuedGatewayToken, error)
+
+	// HasActiveToken reports whether a token is in use, which retires the static gateway API key
+	HasActiveToken(ctx context.Context) (bool, error)
+
+	// VerifyToken checks a token's signature and expiry, and that it hasn't been revoked
+	VerifyToken(ctx context.Context, token string) (*db.GatewayToken, error)
 }
 
 // ============================================================================
@@ -148,11 +514,18 @@ type CreateAppRequest struct {
 	Name               string           `json:"name" binding:"required"`
 	Description        string           `json:"description"`
 	ComposeContent    string           `json:"compose_content" binding:"required"`
+	ComposeOverride   string           `json:"compose_override,omitempty"`       // Optional docker-compose.override.yml content
 	IngressRules      []db.IngressRule `json:"ingress_rules,omitempty"`
 	NodeID            string           `json:"node_id,omitempty"`             // Target node for app deployment
 	TunnelMode        string           `json:"tunnel_mode,omitempty"`        // "custom" | "quick" | "" (empty = no tunnel)
 	QuickTunnelService string          `json:"quick_tunnel_service,omitempty"` // Required when tunnel_mode="quick"
 	QuickTunnelPort   int              `json:"quick_tunnel_port,omitempty"`   // Required when tunnel_mode="quick"
+	Labels            map[string]string `json:"labels,omitempty"`
+	ComposeFiles      map[string]string `json:"compose_files,omitempty"` // Extra compose files for include: and extends:, by path relative to the app directory
+	EnvTemplate       string            `json:"env_template,omitempty"`  // Managed .env; {{ randAlphaNum 32 }}, {{ .AppName }} and {{ .BaseDomain }} are rendered at deploy time
+	StorageRoot       string            `json:"storage_root,omitempty"`  // Named storage root (STORAGE_ROOTS) to create the app directory under; empty is APPS_DIR
+	ApprovePolicies   []string          `json:"approve_policies,omitempty"` // Compose policies in approval mode the admin approves for this app
+	Owner             string            `json:"-"`                          // Signed-in user creating the app, set by the handler
 }
 
 // UpdateAppRequest represents the request to update an app
@@ -160,6 +533,180 @@ type UpdateAppRequest struct {
 	Name           string `json:"name"`
 	Description    string `json:"description"`
 	ComposeContent string `json:"compose_content"`
+	// ComposeOverride replaces the docker-compose.override.yml content when set; "" removes it, nil leaves it unchanged
+	ComposeOverride *string `json:"compose_override,omitempty"`
+	// Labels replaces the app's labels when set; {} removes them all, nil leaves them unchanged
+	Labels map[string]string `json:"labels"`
+	// ComposeFiles replaces the app's extra compose files when set; {} removes them all, nil leaves them unchanged
+	ComposeFiles map[string]string `json:"compose_files"`
+	// EnvTemplate replaces the managed .env template when set; "" removes the managed .env, nil leaves it unchanged.
+	// Generated secrets are kept for lines that don't change.
+	EnvTemplate *string `json:"env_template,omitempty"`
+	// ApprovePolicies are the compose policies in approval mode the admin approves for this change
+	ApprovePolicies []string `json:"approve_policies,omitempty"`
+}
+
+// RunAppCommandRequest runs a one-off command, such as a migration or cache clear, in one of an
+// app's services. Command is passed to the container as-is, without a shell; use
+// ["sh", "-c", "..."] for pipes or globs.
+type RunAppCommandRequest struct {
+	Service string   `json:"service" binding:"required"`
+	Command []string `json:"command" binding:"required"`
+	Mode    string   `json:"mode,omitempty"` // "run" (default): a new container removed afterwards | "exec": the running container
+	// TimeoutSeconds fails the command after this long, 0 meaning 10 minutes, at most 6 hours. In
+	// run mode its container is removed, stopping it; in exec mode it may keep running in the
+	// service's container, since docker exec can't stop it.
+	TimeoutSeconds int `json:"timeout_seconds,omitempty"`
+}
+
+// CreateTaskRequest adds a recurring task to an app. Service, Command, Mode and TimeoutSeconds are
+// as for one-off commands; Cron accepts the same expressions as app schedules.
+type CreateTaskRequest struct {
+	Name           string   `json:"name" binding:"required"`
+	Service        string   `json:"service" binding:"required"`
+	Command        []string `json:"command" binding:"required"`
+	Mode           string   `json:"mode,omitempty"`
+	Cron           string   `json:"cron" binding:"required"`
+	Timezone       string   `json:"timezone,omitempty"` // IANA timezone, UTC by default
+	TimeoutSeconds int      `json:"timeout_seconds,omitempty"`
+	Enabled        *bool    `json:"enabled,omitempty"` // Enabled unless false
+}
+
+// UpdateTaskRequest changes a recurring task; nil fields are left unchanged
+type UpdateTaskRequest struct {
+	Name           *string  `json:"name,omitempty"`
+	Service        *string  `json:"service,omitempty"`
+	Command        []string `json:"command,omitempty"`
+	Mode           *string  `json:"mode,omitempty"`
+	Cron           *string  `json:"cron,omitempty"`
+	Timezone       *string  `json:"timezone,omitempty"`
+	TimeoutSeconds *int     `json:"timeout_seconds,omitempty"`
+	Enabled        *bool    `json:"enabled,omitempty"`
+}
+
+// CreateSnapshotRequest names a snapshot of an app's current deployment
+type CreateSnapshotRequest struct {
+	Name string `json:"name" binding:"required"`
+}
+
+// AppLogForwarding is whether an app's container logs are forwarded, and where
+type AppLogForwarding struct {
+	AppID       string `json:"app_id"`
+	Enabled     bool   `json:"enabled"`
+	Destination string `json:"destination,omitempty"` // loki or elasticsearch; empty until configured in settings
+	Active      bool   `json:"active"`                // Enabled with a destination configured, so the sidecar is deployed
+}
+
+// SetLogForwardingRequest turns log forwarding on or off for an app
+type SetLogForwardingRequest struct {
+	Enabled *bool `json:"enabled" binding:"required"`
+}
+
+// UpdatePreferencesRequest changes the preferences that are set and keeps the rest
+type UpdatePreferencesRequest struct {
+	DefaultNodeID   *string              `json:"default_node_id,omitempty"` // "" clears it
+	TableColumns    *map[string][]string `json:"table_columns,omitempty"`   // Replaces every table's layout; a table left out gets its default columns
+	RefreshInterval *int                 `json:"refresh_interval,omitempty"`
+	Theme           *string              `json:"theme,omitempty"`
+}
+
+// OperationsLockStatus is whether app changes are blocked and, if so, why and by whom
+type OperationsLockStatus struct {
+	Locked bool               `json:"locked"`
+	Lock   *db.OperationsLock `json:"lock,omitempty"`
+}
+
+// LockOperationsRequest locks operations; the reason is shown to anyone whose change is refused
+type LockOperationsRequest struct {
+	Reason string `json:"reason" binding:"required"`
+}
+
+// SetQuotaRequest sets a user's quota; zero leaves a limit off
+type SetQuotaRequest struct {
+	MaxApps     int     `json:"max_apps"`
+	MaxMemoryMB int     `json:"max_memory_mb"`
+	MaxCPUs     float64 `json:"max_cpus"`
+	MaxTunnels  int     `json:"max_tunnels"`
+}
+
+// ReservePortRequest reserves a host port by hand. Port 0 picks the lowest free one; AppID may
+// name an app on the node the port is for.
+type ReservePortRequest struct {
+	Port        int    `json:"port"`
+	Protocol    string `json:"protocol"` // tcp (default) or udp
+	AppID       string `json:"app_id,omitempty"`
+	Description string `json:"description"`
+}
+
+// QuotaUsage is what one user's apps use across the cluster. Memory and CPUs are the apps'
+// deploy.resources.reservations; tunnels count apps with a custom or Quick Tunnel.
+type QuotaUsage struct {
+	UserName string        `json:"user_name"`
+	Quota    *db.UserQuota `json:"quota"` // The user's quota, else the default ("*"); nil when neither is set
+	Apps     int           `json:"apps"`
+	MemoryMB int           `json:"memory_mb"`
+	CPUs     float64       `json:"cpus"`
+	Tunnels  int           `json:"tunnels"`
+	// Incomplete is set when some apps couldn't be counted, e.g. their node is offline
+	Incomplete bool `json:"incomplete,omitempty"`
+}
+
+// QuotaCheckRequest describes an app about to be created or changed, as a secondary sends it to
+// the primary
+type QuotaCheckRequest struct {
+	UserName string  `json:"user_name"`
+	AppID    string  `json:"app_id,omitempty"` // The app being updated, whose current usage is replaced; empty when creating
+	MemoryMB int     `json:"memory_mb"`
+	CPUs     float64 `json:"cpus"`
+	Tunnel   bool    `json:"tunnel"`
+}
+
+// QuotaCheckResponse is the answer to a quota check
+type QuotaCheckResponse struct {
+	Exceeded string `json:"exceeded,omitempty"` // Why the change is refused; empty when it fits the quota
+}
+
+// ComposePreviewRequest previews unsaved compose content; empty fields preview what is saved
+type ComposePreviewRequest struct {
+	ComposeContent  string            `json:"compose_content"`
+	ComposeOverride *string           `json:"compose_override,omitempty"` // "" previews without the override
+	ComposeFiles    map[string]string `json:"compose_files"`              // {} previews without extra compose files
+}
+
+// ComposePreview is the configuration docker compose receives for an app, as "docker compose
+// config" would print it
+type ComposePreview struct {
+	Files     []string           `json:"files"`   // Merged in this order
+	Compose   string             `json:"compose"` // Merged, with variables substituted
+	Variables []*ComposeVariable `json:"variables"`
+	// Warnings are problems that don't stop a deploy, e.g. images without a manifest for the node's architecture
+	Warnings []string `json:"warnings,omitempty"`
+}
+
+// ComposeVariable is a variable referenced from an app's compose files, with the value it gets
+// and where that came from (".env" or "unset")
+type ComposeVariable struct {
+	Name   string `json:"name"`
+	Value  string `json:"value,omitempty"`
+	Source string `json:"source"`
+}
+
+// IngressWarning flags an ingress rule whose target doesn't match anything in the app's compose.
+// Rules are saved anyway; the warning only helps catch typos.
+type IngressWarning struct {
+	Rule    int    `json:"rule"`    // Index of the rule in the submitted list
+	Service string `json:"service"` // The rule's service URL
+	Message string `json:"message"`
+}
+
+// ComposeIntegrity compares an app's docker-compose.yml on disk with the checksum recorded for its
+// current compose version
+type ComposeIntegrity struct {
+	AppID            string `json:"app_id"`
+	Version          int    `json:"version"`
+	ExpectedChecksum string `json:"expected_checksum"`
+	DiskChecksum     string `json:"disk_checksum,omitempty"` // Empty when the file is missing
+	Modified         bool   `json:"modified"`
 }
 
 // UpdateIngressRequest represents the request to update tunnel ingress
@@ -187,6 +734,41 @@ type AppStats struct {
 	Message           string              `json:"message,omitempty"`
 }
 
+// LogSearchRequest represents a cross-app log search
+type LogSearchRequest struct {
+	Query        string   `json:"q"`
+	Apps         []string `json:"apps,omitempty"`    // App IDs or names to search; empty = all apps
+	Since        string   `json:"since,omitempty"`   // Relative duration ("15m") or RFC3339 timestamp
+	ContextLines int      `json:"context,omitempty"` // Lines of context before and after each match
+}
+
+// JobListFilter narrows a job listing; empty fields match every job
+type JobListFilter struct {
+	Status string `json:"status,omitempty"`
+	Type   string `json:"type,omitempty"`
+	Limit  int    `json:"limit,omitempty"` // Most jobs to return; 0 means constants.JobListDefaultLimit
+}
+
+// NodeError reports a node whose part of a multi-node result is missing, or served from cache
+type NodeError struct {
+	NodeID   string `json:"node_id"`
+	NodeName string `json:"node_name"`
+	Error    string `json:"error"`
+}
+
+// LogSearchMatch represents a single log line matching a search, with surrounding context
+type LogSearchMatch struct {
+	NodeID    string    `json:"node_id"`
+	NodeName  string    `json:"node_name"`
+	AppID     string    `json:"app_id"`
+	AppName   string    `json:"app_name"`
+	Service   string    `json:"service"`
+	Timestamp time.Time `json:"timestamp"`
+	Line      string    `json:"line"`
+	Before    []string  `json:"before,omitempty"`
+	After     []string  `json:"after,omitempty"`
+}
+
 // ContainerStats represents individual container statistics
 type ContainerStats struct {
 	ID            string  `json:"id"`
@@ -248,3 +830,489 @@ type UpdateNodeRequest struct {
 	APIEndpoint string `json:"api_endpoint"`
 	APIKey      string `json:"api_key"`
 }
+
+// RemoveNodeRequest chooses what happens to a node's apps when the node is removed
+type RemoveNodeRequest struct {
+	Strategy     string `json:"strategy"`                 // constants.NodeRemoveStrategy*
+	TargetNodeID string `json:"target_node_id,omitempty"` // Node the apps are recreated on; migrate only
+}
+
+// RemoveNodeResult is the result of a node_remove job
+type RemoveNodeResult struct {
+	NodeID   string            `json:"node_id"`
+	Strategy string            `json:"strategy"`
+	Removed  bool              `json:"removed"` // Whether the node itself was deleted; false when orphaned or an app failed
+	Apps     []*RemovedNodeApp `json:"apps"`
+}
+
+// RemovedNodeApp is what happened to one of a removed node's apps
+type RemovedNodeApp struct {
+	AppID    string `json:"app_id"`
+	Name     string `json:"name"`
+	Success  bool   `json:"success"`
+	NewAppID string `json:"new_app_id,omitempty"` // The app's ID on the target node after a migration
+	Detail   string `json:"detail,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+// AppInventorySyncRequest is a secondary's app inventory push. Apps holds only apps changed since
+// the previous push unless Full is set; AppIDs always lists every app so deletions propagate.
+type AppInventorySyncRequest struct {
+	Full   bool         `json:"full"`
+	Apps   []AppSummary `json:"apps"`
+	AppIDs []string     `json:"app_ids"`
+}
+
+// AppSummary is the subset of app metadata the primary caches for other nodes
+type AppSummary struct {
+	ID        string    `json:"id"`
+	Name      string    `json:"name"`
+	Status    string    `json:"status"`
+	PublicURL string    `json:"public_url,omitempty"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// AppInventorySyncResponse tells the secondary whether the primary needs a full inventory
+type AppInventorySyncResponse struct {
+	Cached         int  `json:"cached"`
+	ResyncRequired bool `json:"resync_required"`
+}
+
+// NodeLogEntry is one line of a node's own server log
+type NodeLogEntry struct {
+	Time    time.Time `json:"time"`
+	Level   string    `json:"level"`
+	Message string    `json:"msg"`
+	Line    string    `json:"line"` // The whole JSON record, attributes included
+}
+
+// NodeLogPushRequest is a batch of server log lines a secondary ships to the primary
+type NodeLogPushRequest struct {
+	Entries []NodeLogEntry `json:"entries"`
+}
+
+// NodeLogQuery filters a node's shipped server logs
+type NodeLogQuery struct {
+	Since    time.Time
+	MinLevel string // debug, info, warn or error; empty returns every level
+	Limit    int
+}
+
+// QueueOperationRequest represents a mutating API request to hold until its node is back online
+type QueueOperationRequest struct {
+	Method string `json:"method" binding:"required"`
+	Path   string `json:"path" binding:"required"`
+	Query  string `json:"query,omitempty"`
+	Body   string `json:"body,omitempty"` // Raw request body, replayed verbatim
+}
+
+// TelemetryReport is everything an anonymous usage report contains. Counts are bucketed so a
+// report can't be used to fingerprint an install.
+type TelemetryReport struct {
+	InstallID       string   `json:"install_id"`
+	Version         string   `json:"version"`
+	OS              string   `json:"os"`
+	Arch            string   `json:"arch"`
+	NodeCount       string   `json:"node_count"`
+	AppCount        string   `json:"app_count"`
+	TunnelProviders []string `json:"tunnel_providers"`
+}
+
+// FeatureFlag is a feature flag's effective value and where it came from (default, settings or env)
+type FeatureFlag struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Enabled     bool   `json:"enabled"`
+	Source      string `json:"source"`
+	EnvVar      string `json:"env_var"`
+}
+
+// CreateWebhookRequest represents the request to add a webhook to an app. Digest and quiet hours
+// only hold back non-critical events; Timezone defaults to UTC.
+type CreateWebhookRequest struct {
+	URL             string   `json:"url" binding:"required"`
+	Events          []string `json:"events" binding:"required"`
+	Digest          string   `json:"digest,omitempty"`            // hourly or daily, empty to send events as they happen
+	QuietHoursStart string   `json:"quiet_hours_start,omitempty"` // HH:MM
+	QuietHoursEnd   string   `json:"quiet_hours_end,omitempty"`   // HH:MM
+	Timezone        string   `json:"timezone,omitempty"`
+}
+
+// UpdateWebhookRequest represents the request to change a webhook; nil fields are left unchanged.
+// Empty quiet hours turn them off.
+type UpdateWebhookRequest struct {
+	URL             *string  `json:"url,omitempty"`
+	Events          []string `json:"events,omitempty"`
+	Enabled         *bool    `json:"enabled,omitempty"`
+	Digest          *string  `json:"digest,omitempty"`
+	QuietHoursStart *string  `json:"quiet_hours_start,omitempty"`
+	QuietHoursEnd   *string  `json:"quiet_hours_end,omitempty"`
+	Timezone        *string  `json:"timezone,omitempty"`
+}
+
+// IssueGatewayTokenRequest represents the request to issue a gateway token. TTLHours defaults to
+// 24 and is at most 720 (30 days).
+type IssueGatewayTokenRequest struct {
+	Name     string `json:"name" binding:"required"`
+	TTLHours int    `json:"ttl_hours,omitempty"`
+}
+
+// CreateAPITokenRequest represents the request to create an API token. ExpiresInDays is at most
+// 365; 0 creates a token that never expires.
+type CreateAPITokenRequest struct {
+	Name          string `json:"name" binding:"required"`
+	ReadOnly      bool   `json:"read_only,omitempty"`
+	ExpiresInDays int    `json:"expires_in_days,omitempty"`
+}
+
+// UpdateAPITokenRequest represents the request to rename an API token
+type UpdateAPITokenRequest struct {
+	Name string `json:"name" binding:"required"`
+}
+
+// InviteUserRequest represents the request to invite a user. Role defaults to viewer; TTLHours
+// defaults to 168 (7 days) and is at most 720 (30 days).
+type InviteUserRequest struct {
+	Email     string `json:"email,omitempty"`
+	Role      string `json:"role,omitempty"`
+	TTLHours  int    `json:"ttl_hours,omitempty"`
+	BaseURL   string `json:"-"` // Public URL the invite link points at
+	InvitedBy string `json:"-"`
+}
+
+// IssuedInvitation is a newly created invitation; Token and URL are only shown this once
+type IssuedInvitation struct {
+	*db.Invitation
+	Token      string `json:"token"`
+	URL        string `json:"url"`
+	Emailed    bool   `json:"emailed"`
+	EmailError string `json:"email_error,omitempty"` // Why the link couldn't be emailed; share the URL instead
+}
+
+// IssuedStatusPage is a newly enabled status page; Token and URL are only shown this once
+type IssuedStatusPage struct {
+	*db.AppStatusPage
+	Token string `json:"token"`
+	URL   string `json:"url"`
+}
+
+// AppStatusReport is what an app's public status page shows. It leaves out the app's internals,
+// such as error messages.
+type AppStatusReport struct {
+	App              string       `json:"app"`
+	Status           string       `json:"status"` // operational, degraded, down, maintenance or stopped
+	Since            time.Time    `json:"since"`  // When the app got its current status
+	UptimePercent    float64      `json:"uptime_percent"`
+	UptimeWindowDays int          `json:"uptime_window_days"`
+	LastIncident     *AppIncident `json:"last_incident,omitempty"` // Within the uptime window
+	GeneratedAt      time.Time    `json:"generated_at"`
+}
+
+// AppIncident is a period an app was degraded or down
+type AppIncident struct {
+	Status     string     `json:"status"` // The worst it got: degraded or down
+	StartedAt  time.Time  `json:"started_at"`
+	ResolvedAt *time.Time `json:"resolved_at,omitempty"` // Unset while ongoing
+}
+
+// IssuedAPIToken is a newly created API token; Token is only shown this once
+type IssuedAPIToken struct {
+	*db.APIToken
+	Token string `json:"token"`
+}
+
+// VerifiedAPIToken is an API token presented with a request and the role its requests get: the
+// current role of its user, or viewer for a read-only token
+type VerifiedAPIToken struct {
+	*db.APIToken
+	Role string `json:"role"`
+}
+
+// VerifyAPITokenRequest is a token a node asks the primary to check
+type VerifyAPITokenRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+// IssuedGatewayToken is a newly issued gateway token; Token is only shown this once
+type IssuedGatewayToken struct {
+	*db.GatewayToken
+	Token string `json:"token"`
+}
+
+// HealthReport is the platform's overall health: the worst status of its individual checks
+type HealthReport struct {
+	Status    string         `json:"status"`
+	CheckedAt time.Time      `json:"checked_at"`
+	Checks    []*HealthCheck `json:"checks"`
+}
+
+// HealthCheck is the outcome of one subsystem check, with check-specific details
+type HealthCheck struct {
+	Name       string                 `json:"name"`
+	Status     string                 `json:"status"`
+	Message    string                 `json:"message,omitempty"`
+	DurationMs int64                  `json:"duration_ms"`
+	Details    map[string]interface{} `json:"details,omitempty"`
+}
+
+// DeleteAppOptions changes how an app is deleted
+type DeleteAppOptions struct {
+	Archive       bool     // Keep a tarball of the app directory in the trash instead of discarding it
+	DryRun        bool     // Report what would be removed without removing anything
+	SkipSteps     []string // Cleanup step IDs to leave out, e.g. "tunnel"
+	RemoveVolumes bool     // Also remove the app's named volumes, which are kept by default
+	Force         bool     // Delete the app record even if cleaning up its containers, tunnel etc. fails
+}
+
+// DeleteTunnelOptions changes how an app's tunnel is deleted
+type DeleteTunnelOptions struct {
+	// QuickTunnelFallback replaces a named tunnel with a Quick Tunnel to the service and port its
+	// ingress rules pointed at, in the same job, so the app keeps a public URL
+	QuickTunnelFallback bool
+}
+
+// DeleteAppResult reports what deleting an app removed and left behind
+type DeleteAppResult struct {
+	AppID            string           `json:"appID"`
+	DryRun           bool             `json:"dry_run,omitempty"`
+	ArchivePath      string           `json:"archive_path,omitempty"`
+	ArchiveExpiresAt *time.Time       `json:"archive_expires_at,omitempty"`
+	Steps            []*DeleteAppStep `json:"steps"`
+}
+
+// DeleteAppStep is the outcome of one cleanup step, or in a dry run what it would remove
+type DeleteAppStep struct {
+	ID        string               `json:"id"`
+	Name      string               `json:"name"`
+	Success   bool                 `json:"success"`
+	Skipped   bool                 `json:"skipped,omitempty"`
+	Error     string               `json:"error,omitempty"`
+	Detail    string               `json:"detail,omitempty"`
+	Resources []*DeleteAppResource `json:"resources,omitempty"`
+}
+
+// DeleteAppResource is a container, volume, tunnel, directory etc. touched by a cleanup step
+type DeleteAppResource struct {
+	Kind string `json:"kind"`
+	Name string `json:"name"`
+}
+
+// TunnelInventory lists every tunnel any node has a record of, merged with the tunnels that
+// exist in the active provider's account
+type TunnelInventory struct {
+	Provider         string                 `json:"provider"`
+	AccountListed    bool                   `json:"account_listed"`              // The provider account was listed, so untracked tunnels are visible
+	Complete         bool                   `json:"complete"`                    // Every node answered; orphans are only flagged when true
+	UnreachableNodes []string               `json:"unreachable_nodes,omitempty"` // Nodes whose tunnel records couldn't be read
+	OrphanCount      int                    `json:"orphan_count"`
+	Tunnels          []*TunnelInventoryItem `json:"tunnels"`
+}
+
+// TunnelConnectorStatus is the connector state of an app's named tunnel
+type TunnelConnectorStatus struct {
+	AppID           string              `json:"app_id"`
+	Provider        string              `json:"provider"`
+	TunnelID        string              `json:"tunnel_id"`
+	TunnelName      string              `json:"tunnel_name"`
+	State           string              `json:"state"` // healthy, reconnecting or no_connector
+	ConnectorCount  int                 `json:"connector_count"`
+	Locations       []string            `json:"locations"`                   // Edge locations with an active connection
+	LastConnectedAt *time.Time          `json:"last_connected_at,omitempty"` // When the newest active connection was opened
+	Connectors      []*tunnel.Connector `json:"connectors"`
+	CheckedAt       time.Time           `json:"checked_at"`
+}
+
+// AppTraffic is the HTTP traffic an app's tunnel hostnames received over a period
+type AppTraffic struct {
+	AppID    string `json:"app_id"`
+	Provider string `json:"provider"`
+	Period   string `json:"period"` // 1h, 24h or 7d
+	*tunnel.Traffic
+}
+
+// TunnelInventoryItem is one tunnel with the app it belongs to, if any
+type TunnelInventoryItem struct {
+	TunnelID   string     `json:"tunnel_id"`
+	TunnelName string     `json:"tunnel_name"`
+	Status     string     `json:"status"`
+	NodeID     string     `json:"node_id,omitempty"`
+	AppID      string     `json:"app_id,omitempty"`
+	AppName    string     `json:"app_name,omitempty"`
+	PublicURL  string     `json:"public_url,omitempty"`
+	Tracked    bool       `json:"tracked"`    // Some node has an app or tunnel record for it
+	InAccount  bool       `json:"in_account"` // Still exists in the provider account
+	Orphaned   bool       `json:"orphaned"`   // In the account but no app uses it; safe to delete
+	CreatedAt  *time.Time `json:"created_at,omitempty"`
+}
+
+// ImportTunnelRequest adopts an existing provider tunnel. Either AppID names an app without a
+// tunnel, or AppName names a new app to create around the tunnel.
+type ImportTunnelRequest struct {
+	TunnelID       string `json:"tunnel_id" binding:"required"`
+	AppID          string `json:"app_id,omitempty"`
+	AppName        string `json:"app_name,omitempty"`
+	ComposeContent string `json:"compose_content,omitempty"` // New app's compose; a placeholder service when empty
+}
+
+// AuditReport lists the inconsistencies found in this node's database by a consistency audit
+type AuditReport struct {
+	ID       string          `json:"id"`
+	NodeID   string          `json:"node_id"`
+	RanAt    time.Time       `json:"ran_at"`
+	Findings []*AuditFinding `json:"findings"`
+}
+
+// AuditFinding is one inconsistency and what to do about it
+type AuditFinding struct {
+	Check       string `json:"check"`
+	Resource    string `json:"resource"` // ID of the affected app, tunnel or job
+	Message     string `json:"message"`
+	Remediation string `json:"remediation"`
+}
+
+// SettingsSectionSchema documents a settings section and the fields it accepts
+type SettingsSectionSchema struct {
+	Name        string           `json:"name"`
+	Description string           `json:"description"`
+	Fields      []*SettingsField `json:"fields"`
+}
+
+// SettingsField documents one setting: its type, default and accepted values
+type SettingsField struct {
+	Name        string           `json:"name"`
+	Type        string           `json:"type"` // bool, int, string or object
+	Description string           `json:"description"`
+	Default     interface{}      `json:"default,omitempty"`
+	Min         *int             `json:"min,omitempty"`
+	Max         *int             `json:"max,omitempty"`
+	Enum        []string         `json:"enum,omitempty"`
+	Secret      bool             `json:"secret,omitempty"` // Masked in responses and history; send the masked value back to keep it
+	Fields      []*SettingsField `json:"fields,omitempty"` // Members of an object field
+}
+
+// SettingsSection is a settings section's current values, with secrets masked
+type SettingsSection struct {
+	Section   string                 `json:"section"`
+	Values    map[string]interface{} `json:"values"`
+	UpdatedAt time.Time              `json:"updated_at"`
+}
+
+// ConfigReload reports which reload-safe settings changed when a node re-read its configuration
+type ConfigReload struct {
+	NodeID     string    `json:"node_id"`
+	Changed    []string  `json:"changed"`
+	LogLevel   string    `json:"log_level"`
+	ReloadedAt time.Time `json:"reloaded_at"`
+}
+
+// LogSettings is a node's current log level and output format
+type LogSettings struct {
+	NodeID string `json:"node_id"`
+	Level  string `json:"level"`
+	JSON   bool   `json:"json"`
+}
+
+// UpdateLogSettingsRequest changes a node's logging until it restarts; omitted fields are kept
+type UpdateLogSettingsRequest struct {
+	Level string `json:"level,omitempty"`
+	JSON  *bool  `json:"json,omitempty"`
+}
+
+// GenerateSecretRequest asks for a random secret; omitted fields default to a 32 character
+// alphanumeric raw secret
+type GenerateSecretRequest struct {
+	Length  int    `json:"length,omitempty"`
+	Charset string `json:"charset,omitempty"` // alphanumeric | alpha | numeric | hex | urlsafe
+	Format  string `json:"format,omitempty"`  // raw | base64 | uuid
+}
+
+// GeneratedSecret is a secret from POST /api/utils/generate-secret with the options used
+type GeneratedSecret struct {
+	Secret  string `json:"secret"`
+	Length  int    `json:"length"`
+	Charset string `json:"charset"`
+	Format  string `json:"format"`
+}
+
+// NodeVersion is the build and API version a node reports in heartbeats and health checks
+type NodeVersion struct {
+	Version    string `json:"version"`
+	APIVersion int    `json:"api_version"`
+}
+
+// ReplicaNodes is a secondary's read-only copy of the primary's node list
+type ReplicaNodes struct {
+	Nodes    []*db.Node `json:"nodes"`
+	SyncedAt time.Time  `json:"synced_at"`
+}
+
+// AppManifest declares the apps that should exist, for POST /api/apply. With Prune set, apps the
+// manifest doesn't list are deleted.
+type AppManifest struct {
+	Apps  []ManifestApp `json:"apps"`
+	Prune bool          `json:"prune"`
+}
+
+// ManifestApp is one declared app. Apps are matched to existing ones by name.
+type ManifestApp struct {
+	Name            string            `json:"name"`
+	Node            string            `json:"node,omitempty"` // Node ID or name; the primary when empty
+	Description     string            `json:"description,omitempty"`
+	Compose         string            `json:"compose"`
+	ComposeOverride string            `json:"compose_override,omitempty"`
+	Env             map[string]string `json:"env,omitempty"`          // Substituted into compose and compose_override
+	EnvTemplate     string            `json:"env_template,omitempty"` // The app's managed .env, rendered on its node
+	Labels          map[string]string `json:"labels,omitempty"`
+	Tunnel          *ManifestTunnel   `json:"tunnel,omitempty"`
+	// ApprovePolicies are the compose policies in approval mode the admin applying the manifest
+	// approves for this app's create or update
+	ApprovePolicies []string `json:"approve_policies,omitempty"`
+}
+
+// ManifestTunnel is a declared app's tunnel; omitted means no tunnel
+type ManifestTunnel struct {
+	Mode         string           `json:"mode"`                    // "custom" | "quick"
+	Service      string           `json:"service,omitempty"`       // Quick Tunnel target service
+	Port         int              `json:"port,omitempty"`          // Quick Tunnel target port
+	IngressRules []db.IngressRule `json:"ingress_rules,omitempty"` // Custom tunnel rules; omitted leaves them unchanged
+}
+
+// ApplyResult is the plan for a manifest and, unless it was a dry run, the outcome of each action
+type ApplyResult struct {
+	DryRun  bool           `json:"dry_run"`
+	Actions []*ApplyAction `json:"actions"`
+	Failed  int            `json:"failed"`
+}
+
+// ApplyAction is what apply does to one app
+type ApplyAction struct {
+	App     string   `json:"app"`
+	AppID   string   `json:"app_id,omitempty"`
+	NodeID  string   `json:"node_id"`
+	Action  string   `json:"action"`            // create, update, delete or unchanged
+	Changes []string `json:"changes,omitempty"` // Fields an update changes
+	Error   string   `json:"error,omitempty"`
+}
+
+// AppContainerHealth is the exit and restart state of an app's containers, as tracked by the
+// crash monitor
+type AppContainerHealth struct {
+	AppID      string             `json:"app_id"`
+	AppName    string             `json:"app_name"`
+	Status     string             `json:"status"`
+	Containers []*ContainerHealth `json:"containers"`
+	CheckedAt  time.Time          `json:"checked_at"`
+}
+
+// ContainerHealth is one container's state and the restarts the crash monitor has seen
+type ContainerHealth struct {
+	Name           string     `json:"name"`
+	Service        string     `json:"service"`
+	State          string     `json:"state"` // running, restarting, exited, ...
+	ExitCode       int        `json:"exit_code"`
+	OOMKilled      bool       `json:"oom_killed"`
+	RestartCount   int        `json:"restart_count"`   // Since the container was created
+	RecentRestarts int        `json:"recent_restarts"` // Within the crash-loop window
+	CrashLooping   bool       `json:"crash_looping"`
+	FinishedAt     *time.Time `json:"finished_at,omitempty"` // Last exit
+}
@@ -0,0 +1,161 @@
+// Package events carries what changes on a node (app statuses, job progress, node health and
+// notifications) to the clients watching GET /api/events, so the UI doesn't have to poll for it.
+// Publishers call Publish wherever the change happens; events are not persisted, and a client
+// that falls behind or reconnects is told to refetch instead of being replayed what it missed.
+package events
+
+import (
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/selfhostly/internal/constants"
+)
+
+// Event types
+const (
+	TypeAppStatus    = "app_status"   // An app's status changed
+	TypeJob          = "job"          // A job was claimed, made progress or finished
+	TypeNodeHealth   = "node_health"  // A node went online, offline or unreachable
+	TypeNotification = "notification" // A lifecycle event or alert that webhooks are sent for
+)
+
+// Types lists every event type, in the order the API documents them
+var Types = []string{TypeAppStatus, TypeJob, TypeNodeHealth, TypeNotification}
+
+// Event is one change, sent to clients as a server-sent event named by its Type
+type Event struct {
+	ID     uint64    `json:"id"`
+	Type   string    `json:"type"`
+	NodeID string    `json:"node_id,omitempty"` // Set for changes reported by other nodes; empty is this node
+	AppID  string    `json:"app_id,omitempty"`
+	At     time.Time `json:"at"`
+	Data   any       `json:"data"`
+}
+
+// NodeHealth is the data of a node_health event
+type NodeHealth struct {
+	NodeID   string `json:"node_id"`
+	NodeName string `json:"node_name"`
+	From     string `json:"from"`
+	To       string `json:"to"`
+}
+
+// Notification is the data of a notification event: what a lifecycle webhook or alert says
+type Notification struct {
+	Event   string `json:"event"`   // A webhook event such as crash, or node_alert.firing / node_alert.resolved
+	Subject string `json:"subject"` // The app or node it is about
+	Message string `json:"message,omitempty"`
+}
+
+// Filter picks the events a subscriber receives; its zero value receives everything
+type Filter struct {
+	Types []string // Event types; empty is all
+	AppID string   // Only events of this app
+}
+
+// Matches reports whether e passes the filter
+func (f Filter) Matches(e Event) bool {
+	if len(f.Types) > 0 && !slices.Contains(f.Types, e.Type) {
+		return false
+	}
+	return f.AppID == "" || e.AppID == f.AppID
+}
+
+// Hub fans published events out to its subscribers. Publishing never blocks: a subscriber whose
+// buffer is full misses the event and is marked as having dropped events.
+type Hub struct {
+	nextID atomic.Uint64
+
+	mu   sync.RWMutex
+	subs map[*Subscription]struct{}
+}
+
+// NewHub creates a hub without subscribers
+func NewHub() *Hub {
+	return &Hub{subs: make(map[*Subscription]struct{})}
+}
+
+// Publish sends e to every subscriber whose filter it matches, numbering it and stamping its time
+func (h *Hub) Publish(e Event) {
+	e.ID = h.nextID.Add(1)
+	if e.At.IsZero() {
+		e.At = time.Now()
+	}
+
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	for sub := range h.subs {
+		if !sub.filter.Matches(e) {
+			continue
+		}
+		select {
+		case sub.events <- e:
+		default:
+			sub.dropped.Store(true)
+		}
+	}
+}
+
+// Subscribe starts receiving the events that match filter. The subscription must be closed.
+func (h *Hub) Subscribe(filter Filter) *Subscription {
+	sub := &Subscription{
+		hub:    h,
+		filter: filter,
+		events: make(chan Event, constants.EventsSubscriberBuffer),
+	}
+	h.mu.Lock()
+	h.subs[sub] = struct{}{}
+	h.mu.Unlock()
+	return sub
+}
+
+// Subscribers returns how many subscriptions are open
+func (h *Hub) Subscribers() int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.subs)
+}
+
+// Subscription is one subscriber's feed of events
+type Subscription struct {
+	hub     *Hub
+	filter  Filter
+	events  chan Event
+	dropped atomic.Bool
+	once    sync.Once
+}
+
+// Events returns the channel events are delivered on
+func (s *Subscription) Events() <-chan Event {
+	return s.events
+}
+
+// Dropped reports whether events were missed since it was last called, because the subscriber
+// didn't keep up
+func (s *Subscription) Dropped() bool {
+	return s.dropped.Swap(false)
+}
+
+// Close stops the subscription. Events already buffered can still be read.
+func (s *Subscription) Close() {
+	s.once.Do(func() {
+		s.hub.mu.Lock()
+		delete(s.hub.subs, s)
+		s.hub.mu.Unlock()
+	})
+}
+
+// defaultHub is the hub of this process, which publishers and the events endpoint share
+var defaultHub = NewHub()
+
+// Default returns the hub of this process
+func Default() *Hub {
+	return defaultHub
+}
+
+// Publish sends e to the subscribers of the process's hub
+func Publish(e Event) {
+	defaultHub.Publish(e)
+}
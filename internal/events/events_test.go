@@ -0,0 +1,70 @@
+package events
+
+import (
+	"testing"
+
+	"github.com/selfhostly/internal/constants"
+)
+
+func TestHubFiltersEvents(t *testing.T) {
+	hub := NewHub()
+	jobs := hub.Subscribe(Filter{Types: []string{TypeJob}})
+	defer jobs.Close()
+	app := hub.Subscribe(Filter{AppID: "app-1"})
+	defer app.Close()
+
+	hub.Publish(Event{Type: TypeAppStatus, AppID: "app-1"})
+	hub.Publish(Event{Type: TypeJob, AppID: "app-2"})
+	hub.Publish(Event{Type: TypeNodeHealth})
+
+	if got := receive(jobs); len(got) != 1 || got[0].AppID != "app-2" || got[0].ID != 2 || got[0].At.IsZero() {
+		t.Errorf("Expected the job event, numbered and stamped, got %+v", got)
+	}
+	if got := receive(app); len(got) != 1 || got[0].Type != TypeAppStatus {
+		t.Errorf("Expected the app-1 event, got %+v", got)
+	}
+}
+
+func TestHubDropsEventsForSlowSubscribers(t *testing.T) {
+	hub := NewHub()
+	sub := hub.Subscribe(Filter{})
+	defer sub.Close()
+
+	for range constants.EventsSubscriberBuffer + 5 {
+		hub.Publish(Event{Type: TypeJob})
+	}
+	if got := receive(sub); len(got) != constants.EventsSubscriberBuffer {
+		t.Errorf("Expected %d buffered events, got %d", constants.EventsSubscriberBuffer, len(got))
+	}
+	if !sub.Dropped() {
+		t.Error("Expected the subscription to report dropped events")
+	}
+	if sub.Dropped() {
+		t.Error("Expected Dropped to reset once reported")
+	}
+}
+
+func TestSubscriptionClose(t *testing.T) {
+	hub := NewHub()
+	sub := hub.Subscribe(Filter{})
+	sub.Close()
+	sub.Close()
+
+	hub.Publish(Event{Type: TypeJob})
+	if hub.Subscribers() != 0 || len(receive(sub)) != 0 {
+		t.Errorf("Expected a closed subscription to receive nothing, %d subscribers left", hub.Subscribers())
+	}
+}
+
+// receive returns the events waiting on sub
+func receive(sub *Subscription) []Event {
+	var got []Event
+	for {
+		select {
+		case e := <-sub.Events():
+			got = append(got, e)
+		default:
+			return got
+		}
+	}
+}
@@ -0,0 +1,74 @@
+// Package features defines the feature flags that gate experimental subsystems. Flags are off by
+// default, can be switched per install in settings, and can be pinned with FEATURE_<NAME> env vars.
+package features
+
+import (
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Flag names an experimental feature
+type Flag string
+
+// Known flags. Add new experimental subsystems here so they can ship dark.
+const (
+	BlueGreenUpdates Flag = "blue_green_updates"
+	GitOps           Flag = "gitops"
+	PostgresBackend  Flag = "postgres_backend"
+)
+
+// Sources a flag's value can come from, in increasing precedence
+const (
+	SourceDefault  = "default"
+	SourceSettings = "settings"
+	SourceEnv      = "env"
+)
+
+// Definition describes a flag
+type Definition struct {
+	Name        Flag
+	Description string
+}
+
+var definitions = []Definition{
+	{Name: BlueGreenUpdates, Description: "Start updated containers alongside the old ones and switch over once healthy"},
+	{Name: GitOps, Description: "Sync app compose files from a Git repository"},
+	{Name: PostgresBackend, Description: "Store state in PostgreSQL instead of SQLite"},
+}
+
+// All returns every known flag in display order
+func All() []Definition {
+	return append([]Definition(nil), definitions...)
+}
+
+// Lookup returns the definition of a flag by name
+func Lookup(name string) (Definition, bool) {
+	for _, d := range definitions {
+		if string(d.Name) == name {
+			return d, true
+		}
+	}
+	return Definition{}, false
+}
+
+// EnvVar returns the environment variable that pins flag, e.g. FEATURE_GITOPS
+func EnvVar(flag Flag) string {
+	return "FEATURE_" + strings.ToUpper(string(flag))
+}
+
+// LoadEnvOverrides reads FEATURE_<NAME> for every known flag. Unset or unparsable values leave
+// the flag to settings.
+func LoadEnvOverrides() map[Flag]bool {
+	overrides := make(map[Flag]bool)
+	for _, d := range definitions {
+		v := os.Getenv(EnvVar(d.Name))
+		if v == "" {
+			continue
+		}
+		if enabled, err := strconv.ParseBool(v); err == nil {
+			overrides[d.Name] = enabled
+		}
+	}
+	return overrides
+}
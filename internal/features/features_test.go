@@ -0,0 +1,29 @@
+package features
+
+import "testing"
+
+func TestLoadEnvOverrides(t *testing.T) {
+	t.Setenv("FEATURE_GITOPS", "true")
+	t.Setenv("FEATURE_BLUE_GREEN_UPDATES", "0")
+	t.Setenv("FEATURE_POSTGRES_BACKEND", "maybe")
+
+	overrides := LoadEnvOverrides()
+	if enabled, ok := overrides[GitOps]; !ok || !enabled {
+		t.Errorf("expected gitops pinned on, got %v (set=%v)", enabled, ok)
+	}
+	if enabled, ok := overrides[BlueGreenUpdates]; !ok || enabled {
+		t.Errorf("expected blue/green pinned off, got %v (set=%v)", enabled, ok)
+	}
+	if _, ok := overrides[PostgresBackend]; ok {
+		t.Error("expected an unparsable value to leave the flag to settings")
+	}
+}
+
+func TestLookup(t *testing.T) {
+	if d, ok := Lookup("gitops"); !ok || d.Name != GitOps {
+		t.Errorf("Lookup(gitops) = %+v, %v", d, ok)
+	}
+	if _, ok := Lookup("unknown"); ok {
+		t.Error("expected unknown flag lookup to fail")
+	}
+}
@@ -5,11 +5,13 @@ import (
 	"strings"
 
 	"github.com/golang-jwt/jwt"
+	"github.com/selfhostly/internal/constants"
 )
 
 const jwtCookieName = "JWT"
 
-// ValidateRequest checks JWT from Cookie or Authorization header; returns true if valid or auth not required
+// ValidateRequest checks JWT from Cookie or Authorization header; returns true if valid or auth not required.
+// API tokens (Bearer shp_...) are passed through: only the nodes can check them, with the primary.
 func (c *Config) ValidateRequest(req *http.Request) bool {
 	if !c.AuthEnabled {
 		return true
@@ -21,6 +23,9 @@ func (c *Config) ValidateRequest(req *http.Request) bool {
 	if tokenStr == "" {
 		return false
 	}
+	if strings.HasPrefix(tokenStr, constants.APITokenPrefix) {
+		return true
+	}
 	_, err := jwt.Parse(tokenStr, func(token *jwt.Token) (interface{}, error) {
 		return []byte(c.JWTSecret), nil
 	})
@@ -44,6 +49,16 @@ func (c *Config) pathSkipsAuth(path string) bool {
 		return true
 	}
 
+	// Invite links are opened before signing in; the primary sends the invitee to sign in first
+	if strings.HasPrefix(path, constants.InvitePath) {
+		return true
+	}
+
+	// Status pages are public; their token is checked by the node serving them
+	if strings.HasPrefix(path, constants.StatusPagePath) {
+		return true
+	}
+
 	return false
 }
 
@@ -6,6 +6,7 @@ import (
 	"testing"
 
 	"github.com/golang-jwt/jwt"
+	"github.com/selfhostly/internal/constants"
 )
 
 func TestConfig_ValidateRequest(t *testing.T) {
@@ -108,6 +109,15 @@ func TestConfig_ValidateRequest(t *testing.T) {
 			method:      http.MethodGet,
 			want:        true,
 		},
+		{
+			name:        "auth enabled, API token left to the nodes",
+			authEnabled: true,
+			jwtSecret:   "test-secret",
+			path:        "/api/apps",
+			method:      http.MethodGet,
+			headerValue: "Bearer " + constants.APITokenPrefix + "abc",
+			want:        true,
+		},
 		{
 			name:        "auth enabled, header without Bearer prefix",
 			authEnabled: true,
@@ -159,6 +169,8 @@ func TestConfig_pathSkipsAuth(t *testing.T) {
 		{"health endpoint", "/api/health", http.MethodGet, true},
 		{"health POST", "/api/health", http.MethodPost, true},
 		{"me endpoint", "/api/me", http.MethodGet, true},
+		{"invite link", "/api/invite/abc123", http.MethodGet, true},
+		{"status page", "/api/status/abc123", http.MethodGet, true},
 		{"protected path", "/api/apps", http.MethodGet, false},
 		{"other path", "/api/other", http.MethodGet, false},
 	}
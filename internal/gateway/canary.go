@@ -0,0 +1,72 @@
+package gateway
+
+import (
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+)
+
+const (
+	// canaryCookie holds a client's rollout bucket (0-99), so a browser keeps talking to one
+	// version while the canary percentage stays put
+	canaryCookie = "_gateway_canary"
+	// canaryCookieMaxAge is long enough to outlast a rollout
+	canaryCookieMaxAge = 7 * 24 * 60 * 60
+
+	// backendHeader tells the client which primary instance answered while a canary is configured
+	backendHeader = "X-Selfhostly-Backend"
+)
+
+// canaryTarget returns the backend a request should go to. Requests for the primary whose client
+// bucket is below CanaryPercent go to CanaryBackendURL; everything else, including all node-routed
+// requests for secondaries, keeps baseURL. Setting the percentage to 0 and reloading sends every
+// request back to the stable primary at once.
+func (p *Proxy) canaryTarget(w http.ResponseWriter, req *http.Request, baseURL string) string {
+	cfg := p.config.Load()
+	if cfg.CanaryBackendURL == "" || !p.isPrimaryTarget(req, baseURL) {
+		return baseURL
+	}
+
+	bucket, ok := canaryBucket(req)
+	if !ok {
+		bucket = rand.IntN(100)
+		http.SetCookie(w, &http.Cookie{
+			Name:     canaryCookie,
+			Value:    strconv.Itoa(bucket),
+			Path:     "/",
+			MaxAge:   canaryCookieMaxAge,
+			HttpOnly: true,
+			SameSite: http.SameSiteLaxMode,
+		})
+	}
+
+	if bucket < cfg.CanaryPercent {
+		w.Header().Set(backendHeader, "canary")
+		return cfg.CanaryBackendURL
+	}
+	w.Header().Set(backendHeader, "stable")
+	return baseURL
+}
+
+// isPrimaryTarget reports whether baseURL is the primary, either through a primary-only route or
+// through node_id naming the primary node
+func (p *Proxy) isPrimaryTarget(req *http.Request, baseURL string) bool {
+	if baseURL == p.registry.PrimaryBaseURL() {
+		return true
+	}
+	nodeID := req.URL.Query().Get("node_id")
+	return nodeID != "" && nodeID == p.registry.PrimaryID()
+}
+
+// canaryBucket reads the client's bucket from its cookie
+func canaryBucket(req *http.Request) (int, bool) {
+	cookie, err := req.Cookie(canaryCookie)
+	if err != nil {
+		return 0, false
+	}
+	bucket, err := strconv.Atoi(cookie.Value)
+	if err != nil || bucket < 0 || bucket > 99 {
+		return 0, false
+	}
+	return bucket, true
+}
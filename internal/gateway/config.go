@@ -2,22 +2,64 @@ package gateway
 
 import (
 	"errors"
+	"fmt"
 	"os"
 	"strconv"
+	"strings"
 	"time"
+
+	"github.com/selfhostly/internal/timeouts"
 )
 
 // Config holds gateway configuration
 type Config struct {
 	PrimaryBackendURL string        // Primary backend URL (e.g. http://primary:8082)
-	GatewayAPIKey     string        // API key gateway sends to backends; must match backends' GATEWAY_API_KEY
+	GatewayAPIKey     string        // API key gateway sends to backends until it has a token; must match backends' GATEWAY_API_KEY
 	ListenAddress     string        // Address to listen on (e.g. :8080)
 	JWTSecret         string        // JWT secret to validate user tokens (same as primary)
 	AuthEnabled       bool          // Whether to validate JWT for user requests
 	RegistryTTL       time.Duration // How often to refresh node list from primary
+
+	// RegistryToken is a token issued by the primary that the node list is fetched with instead
+	// of GatewayAPIKey. An admin rotates it on the primary; when RegistryTokenFile is set, the
+	// gateway re-reads the file on every refresh, so a rotated token written there is picked up
+	// without a restart. A token in the file wins over GATEWAY_REGISTRY_TOKEN.
+	RegistryToken     string
+	RegistryTokenFile string
+
+	// QueueOfflineOperations hands mutating requests for offline nodes to the primary's
+	// per-node queue instead of rejecting them
+	QueueOfflineOperations bool
+
+	// Per-node concurrency limits for node-routed requests (NodeMaxInFlight <= 0 disables them)
+	NodeMaxInFlight int
+	NodeQueueSize   int
+	NodeQueueWait   time.Duration
+
+	// Canary sends CanaryPercent (0-100) of the primary's traffic to a second primary instance,
+	// usually a new selfhostly version being rolled out
+	CanaryBackendURL string
+	CanaryPercent    int
+
+	// Timeouts bounds each proxied request by its operation class (reads, logs, container updates, image pulls)
+	Timeouts timeouts.Config
+
+	// Compression gzips or deflates responses for clients that accept it, unless the backend
+	// already compressed them
+	Compression bool
+
+	// ErrorPagesDir holds custom pages for the gateway's own errors (502.html, 503.html, 429.html
+	// or error.html) and the files under assets/ they link to; empty uses the built-in page
+	ErrorPagesDir string
+	// BrandName is the product name shown on error pages
+	BrandName string
+	// DrainDelay is how long the gateway keeps answering 503 and reporting itself draining after
+	// SIGTERM before it stops, giving load balancers time to take it out of rotation
+	DrainDelay time.Duration
 }
 
-var ErrGatewayAPIKeyRequired = errors.New("GATEWAY_API_KEY is required")
+// ErrGatewayAPIKeyRequired is returned when the gateway has neither the static API key nor a gateway token
+var ErrGatewayAPIKeyRequired = errors.New("GATEWAY_API_KEY or a gateway token (GATEWAY_REGISTRY_TOKEN, GATEWAY_REGISTRY_TOKEN_FILE) is required")
 
 // LoadConfig loads gateway configuration from environment
 func LoadConfig() (*Config, error) {
@@ -26,21 +68,69 @@ func LoadConfig() (*Config, error) {
 		primaryBackendURL = "http://localhost:8082"
 	}
 	gatewayAPIKey := os.Getenv("GATEWAY_API_KEY")
-	if gatewayAPIKey == "" {
-		return nil, ErrGatewayAPIKeyRequired
-	}
 	listenAddr := os.Getenv("GATEWAY_LISTEN_ADDRESS")
 	if listenAddr == "" {
 		listenAddr = ":8080"
 	}
 	jwtSecret := os.Getenv("JWT_SECRET")
 	authEnabled := os.Getenv("AUTH_ENABLED") == "true"
+	queueOfflineOps := os.Getenv("GATEWAY_QUEUE_OFFLINE_OPS") == "true"
 	ttlSec := 60
 	if t := os.Getenv("GATEWAY_REGISTRY_TTL_SEC"); t != "" {
 		if n, err := parseInt(t); err == nil && n > 0 {
 			ttlSec = n
 		}
 	}
+	maxInFlight := 8
+	if v := os.Getenv("GATEWAY_NODE_MAX_INFLIGHT"); v != "" {
+		if n, err := parseInt(v); err == nil {
+			maxInFlight = n
+		}
+	}
+	queueSize := 16
+	if v := os.Getenv("GATEWAY_NODE_QUEUE_SIZE"); v != "" {
+		if n, err := parseInt(v); err == nil && n >= 0 {
+			queueSize = n
+		}
+	}
+	queueWaitSec := 15
+	if v := os.Getenv("GATEWAY_NODE_QUEUE_WAIT_SEC"); v != "" {
+		if n, err := parseInt(v); err == nil && n >= 0 {
+			queueWaitSec = n
+		}
+	}
+	registryTokenFile := os.Getenv("GATEWAY_REGISTRY_TOKEN_FILE")
+	registryToken := strings.TrimSpace(os.Getenv("GATEWAY_REGISTRY_TOKEN"))
+	if registryTokenFile != "" {
+		saved, err := readTokenFile(registryTokenFile)
+		if err != nil {
+			return nil, fmt.Errorf("GATEWAY_REGISTRY_TOKEN_FILE: %w", err)
+		}
+		if saved != "" {
+			registryToken = saved
+		}
+	}
+	// The static key only bootstraps the gateway; once it has a token the key can be dropped
+	if gatewayAPIKey == "" && registryToken == "" && registryTokenFile == "" {
+		return nil, ErrGatewayAPIKeyRequired
+	}
+	canaryBackendURL := strings.TrimRight(os.Getenv("GATEWAY_CANARY_BACKEND_URL"), "/")
+	canaryPercent := 0
+	if v := os.Getenv("GATEWAY_CANARY_PERCENT"); v != "" {
+		if n, err := parseInt(v); err == nil {
+			canaryPercent = min(max(n, 0), 100)
+		}
+	}
+	brandName := strings.TrimSpace(os.Getenv("GATEWAY_BRAND_NAME"))
+	if brandName == "" {
+		brandName = "Selfhostly"
+	}
+	drainDelaySec := 0
+	if v := os.Getenv("GATEWAY_DRAIN_DELAY_SEC"); v != "" {
+		if n, err := parseInt(v); err == nil && n >= 0 {
+			drainDelaySec = n
+		}
+	}
 	return &Config{
 		PrimaryBackendURL: primaryBackendURL,
 		GatewayAPIKey:     gatewayAPIKey,
@@ -48,6 +138,25 @@ func LoadConfig() (*Config, error) {
 		JWTSecret:         jwtSecret,
 		AuthEnabled:       authEnabled,
 		RegistryTTL:       time.Duration(ttlSec) * time.Second,
+		RegistryToken:     registryToken,
+		RegistryTokenFile: registryTokenFile,
+
+		QueueOfflineOperations: queueOfflineOps,
+
+		NodeMaxInFlight: maxInFlight,
+		NodeQueueSize:   queueSize,
+		NodeQueueWait:   time.Duration(queueWaitSec) * time.Second,
+
+		CanaryBackendURL: canaryBackendURL,
+		CanaryPercent:    canaryPercent,
+
+		Timeouts: timeouts.LoadFromEnv(),
+
+		Compression: os.Getenv("RESPONSE_COMPRESSION") != "false",
+
+		ErrorPagesDir: os.Getenv("GATEWAY_ERROR_PAGES_DIR"),
+		BrandName:     brandName,
+		DrainDelay:    time.Duration(drainDelaySec) * time.Second,
 	}, nil
 }
 
@@ -2,6 +2,7 @@ package gateway
 
 import (
 	"os"
+	"path/filepath"
 	"testing"
 	"time"
 )
@@ -15,6 +16,8 @@ func TestLoadConfig(t *testing.T) {
 		"JWT_SECRET":              os.Getenv("JWT_SECRET"),
 		"AUTH_ENABLED":            os.Getenv("AUTH_ENABLED"),
 		"GATEWAY_REGISTRY_TTL_SEC": os.Getenv("GATEWAY_REGISTRY_TTL_SEC"),
+		"GATEWAY_CANARY_BACKEND_URL": os.Getenv("GATEWAY_CANARY_BACKEND_URL"),
+		"GATEWAY_CANARY_PERCENT":     os.Getenv("GATEWAY_CANARY_PERCENT"),
 	}
 
 	// Cleanup: restore original env vars
@@ -119,6 +122,23 @@ func TestLoadConfig(t *testing.T) {
 				}
 			},
 		},
+		{
+			name: "canary percent is clamped",
+			env: map[string]string{
+				"GATEWAY_API_KEY":            "test-api-key",
+				"GATEWAY_CANARY_BACKEND_URL": "http://primary-next:8082/",
+				"GATEWAY_CANARY_PERCENT":     "150",
+			},
+			wantErr: false,
+			checkFields: func(t *testing.T, cfg *Config) {
+				if cfg.CanaryBackendURL != "http://primary-next:8082" {
+					t.Errorf("CanaryBackendURL = %q, want %q", cfg.CanaryBackendURL, "http://primary-next:8082")
+				}
+				if cfg.CanaryPercent != 100 {
+					t.Errorf("CanaryPercent = %d, want 100", cfg.CanaryPercent)
+				}
+			},
+		},
 		{
 			name: "zero TTL defaults to 60",
 			env: map[string]string{
@@ -166,3 +186,33 @@ func TestLoadConfig(t *testing.T) {
 		})
 	}
 }
+
+func TestLoadConfig_RegistryToken(t *testing.T) {
+	t.Setenv("GATEWAY_API_KEY", "test-api-key")
+	t.Setenv("GATEWAY_REGISTRY_TOKEN", "gwt.configured")
+	file := filepath.Join(t.TempDir(), "gateway-token")
+	t.Setenv("GATEWAY_REGISTRY_TOKEN_FILE", file)
+
+	// Until a rotated token is saved to the file, the configured token is used
+	cfg, err := LoadConfig()
+	if err != nil {
+		t.Fatalf("LoadConfig() error = %v", err)
+	}
+	if cfg.RegistryToken != "gwt.configured" || cfg.RegistryTokenFile != file {
+		t.Errorf("RegistryToken = %q, RegistryTokenFile = %q", cfg.RegistryToken, cfg.RegistryTokenFile)
+	}
+
+	// A rotated token saved to the file wins
+	if err := os.WriteFile(file, []byte("gwt.rotated\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if cfg, err = LoadConfig(); err != nil || cfg.RegistryToken != "gwt.rotated" {
+		t.Errorf("expected the saved token, got %q, %v", cfg.RegistryToken, err)
+	}
+
+	// With a token the static API key isn't needed
+	t.Setenv("GATEWAY_API_KEY", "")
+	if cfg, err = LoadConfig(); err != nil || cfg.GatewayAPIKey != "" {
+		t.Errorf("expected a token alone to be enough, got %v", err)
+	}
+}
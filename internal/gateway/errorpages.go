@@ -0,0 +1,197 @@
+package gateway
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// Kinds of error the gateway answers itself, sent in the X-Gateway-Error header so clients can
+// tell them apart from errors a backend returned
+const (
+	GatewayErrorNodeDown  = "node_down"
+	GatewayErrorDraining  = "draining"
+	GatewayErrorThrottled = "throttled"
+)
+
+// GatewayErrorHeader is set on every error response the gateway produces itself
+const GatewayErrorHeader = "X-Gateway-Error"
+
+// errorPageStatuses are the statuses with their own page; each may be overridden by <status>.html
+var errorPageStatuses = []int{http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusTooManyRequests}
+
+// defaultErrorPage is the built-in page for every gateway error, used unless GATEWAY_ERROR_PAGES_DIR
+// provides <status>.html or error.html
+const defaultErrorPage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+{{if .RetryAfter}}<meta http-equiv="refresh" content="{{.RetryAfter}}">{{end}}
+<title>{{.Title}} · {{.Brand}}</title>
+<style>
+body{margin:0;min-height:100vh;display:flex;align-items:center;justify-content:center;font-family:system-ui,-apple-system,sans-serif;background:#f8fafc;color:#0f172a}
+main{max-width:28rem;padding:2rem;text-align:center}
+.status{font-size:3rem;font-weight:700;color:#64748b;margin:0}
+h1{font-size:1.25rem;margin:.5rem 0}
+p{color:#475569;line-height:1.5}
+small{color:#94a3b8}
+</style>
+</head>
+<body>
+<main>
+<p class="status">{{.Status}}</p>
+<h1>{{.Title}}</h1>
+<p>{{.Message}}</p>
+{{if .RetryAfter}}<p>This page retries in {{.RetryAfter}} seconds.</p>{{end}}
+<small>{{.Brand}} gateway</small>
+</main>
+</body>
+</html>
+`
+
+// ErrorPages holds the HTML pages the gateway serves browsers for its own errors
+type ErrorPages struct {
+	pages  map[int]*template.Template
+	assets http.Handler // serves GATEWAY_ERROR_PAGES_DIR/assets under /gateway/assets/, nil without a directory
+}
+
+// errorPageData is what an error page template is rendered with
+type errorPageData struct {
+	Brand      string
+	Status     int
+	Title      string
+	Message    string
+	Kind       string // One of the GatewayError* kinds
+	RetryAfter int    // Seconds, 0 when the client shouldn't retry on its own
+}
+
+// LoadErrorPages reads the error page templates from dir: <status>.html for 502, 503 and 429, and
+// error.html for any of them without its own file. Pages missing from dir, or all of them when dir
+// is empty, fall back to the built-in page.
+func LoadErrorPages(dir string) (*ErrorPages, error) {
+	fallback := template.Must(template.New("error").Parse(defaultErrorPage))
+	e := &ErrorPages{pages: make(map[int]*template.Template)}
+	if dir == "" {
+		for _, status := range errorPageStatuses {
+			e.pages[status] = fallback
+		}
+		return e, nil
+	}
+
+	info, err := os.Stat(dir)
+	if err != nil {
+		return nil, fmt.Errorf("GATEWAY_ERROR_PAGES_DIR: %w", err)
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("GATEWAY_ERROR_PAGES_DIR: %s is not a directory", dir)
+	}
+	if t, err := parseErrorPage(filepath.Join(dir, "error.html")); err != nil {
+		return nil, err
+	} else if t != nil {
+		fallback = t
+	}
+	for _, status := range errorPageStatuses {
+		t, err := parseErrorPage(filepath.Join(dir, strconv.Itoa(status)+".html"))
+		if err != nil {
+			return nil, err
+		}
+		if t == nil {
+			t = fallback
+		}
+		e.pages[status] = t
+	}
+	e.assets = http.StripPrefix("/gateway/assets/", http.FileServer(http.Dir(filepath.Join(dir, "assets"))))
+	return e, nil
+}
+
+// parseErrorPage parses one template file, returning nil if it doesn't exist
+func parseErrorPage(file string) (*template.Template, error) {
+	data, err := os.ReadFile(file)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("GATEWAY_ERROR_PAGES_DIR: %w", err)
+	}
+	t, err := template.New(filepath.Base(file)).Parse(string(data))
+	if err != nil {
+		return nil, fmt.Errorf("GATEWAY_ERROR_PAGES_DIR: %w", err)
+	}
+	return t, nil
+}
+
+// Write answers req with a gateway error: the status's HTML page for browsers navigating to a
+// page, and {"error": message, "gateway_error": kind} for API calls. Both carry X-Gateway-Error, and
+// Retry-After when retryAfter > 0.
+func (e *ErrorPages) Write(w http.ResponseWriter, req *http.Request, status int, kind, message, brand string, retryAfter int) {
+	w.Header().Set(GatewayErrorHeader, kind)
+	w.Header().Set("Cache-Control", "no-store")
+	if retryAfter > 0 {
+		w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+	}
+
+	if t := e.pages[status]; t != nil && wantsHTML(req) {
+		var buf bytes.Buffer
+		err := t.Execute(&buf, errorPageData{
+			Brand:      brand,
+			Status:     status,
+			Title:      errorPageTitle(kind, status),
+			Message:    message,
+			Kind:       kind,
+			RetryAfter: retryAfter,
+		})
+		if err == nil {
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			w.WriteHeader(status)
+			_, _ = w.Write(buf.Bytes())
+			return
+		}
+		// A broken custom template still gets the client an answer
+	}
+
+	body, _ := json.Marshal(map[string]string{"error": message, "gateway_error": kind})
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_, _ = w.Write(body)
+}
+
+// ServeAsset serves a file from the error pages' assets directory, so custom pages can link their
+// stylesheets and logos from the gateway while the backends are down
+func (e *ErrorPages) ServeAsset(w http.ResponseWriter, req *http.Request) {
+	if e.assets == nil || strings.HasSuffix(req.URL.Path, "/") {
+		http.NotFound(w, req)
+		return
+	}
+	e.assets.ServeHTTP(w, req)
+}
+
+// wantsHTML reports whether req is a browser loading a page rather than the web UI or a client
+// calling the API
+func wantsHTML(req *http.Request) bool {
+	if strings.HasPrefix(req.URL.Path, "/api/") {
+		return false
+	}
+	return strings.Contains(req.Header.Get("Accept"), "text/html")
+}
+
+// errorPageTitle is the heading of an error page
+func errorPageTitle(kind string, status int) string {
+	switch kind {
+	case GatewayErrorNodeDown:
+		return "Node unavailable"
+	case GatewayErrorDraining:
+		return "Restarting"
+	case GatewayErrorThrottled:
+		return "Too many requests"
+	default:
+		return http.StatusText(status)
+	}
+}
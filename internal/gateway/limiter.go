@@ -0,0 +1,120 @@
+package gateway
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// NodeLimiter caps in-flight requests per target node. Requests over the limit wait in a short,
+// bounded queue; when the queue is full or the wait times out the caller should shed the request.
+type NodeLimiter struct {
+	mu          sync.Mutex // guards the limits, which change on reload, and nodes
+	maxInFlight int
+	queueSize   int
+	queueWait   time.Duration
+	nodes       map[string]*nodeSlots
+}
+
+// nodeSlots tracks one node's in-flight slots and how many requests are queued for one
+type nodeSlots struct {
+	slots   chan struct{}
+	waiting int
+}
+
+// NewNodeLimiter creates a limiter; maxInFlight <= 0 disables limiting
+func NewNodeLimiter(maxInFlight, queueSize int, queueWait time.Duration) *NodeLimiter {
+	return &NodeLimiter{
+		maxInFlight: maxInFlight,
+		queueSize:   queueSize,
+		queueWait:   queueWait,
+		nodes:       make(map[string]*nodeSlots),
+	}
+}
+
+// Acquire takes an in-flight slot for target, queueing for up to the configured wait when the node
+// is saturated. It returns a release func and true on success, or false when the request should be
+// rejected (queue full, wait timed out, or ctx cancelled).
+func (l *NodeLimiter) Acquire(ctx context.Context, target string) (func(), bool) {
+	if l == nil {
+		return func() {}, true
+	}
+	n, queueSize, queueWait := l.slotsFor(target)
+	if n == nil {
+		return func() {}, true
+	}
+	release := func() { <-n.slots }
+
+	select {
+	case n.slots <- struct{}{}:
+		return release, true
+	default:
+	}
+
+	l.mu.Lock()
+	if n.waiting >= queueSize {
+		l.mu.Unlock()
+		return nil, false
+	}
+	n.waiting++
+	l.mu.Unlock()
+
+	defer func() {
+		l.mu.Lock()
+		n.waiting--
+		l.mu.Unlock()
+	}()
+
+	timer := time.NewTimer(queueWait)
+	defer timer.Stop()
+
+	select {
+	case n.slots <- struct{}{}:
+		return release, true
+	case <-timer.C:
+		return nil, false
+	case <-ctx.Done():
+		return nil, false
+	}
+}
+
+// RetryAfter is the Retry-After hint, in seconds, sent with rejected requests
+func (l *NodeLimiter) RetryAfter() int {
+	l.mu.Lock()
+	queueWait := l.queueWait
+	l.mu.Unlock()
+	if seconds := int(queueWait.Seconds()); seconds > 1 {
+		return seconds
+	}
+	return 1
+}
+
+// SetLimits replaces the limits on a config reload. Requests already holding or waiting for a slot
+// finish under the old limits; later requests start from fresh slots.
+func (l *NodeLimiter) SetLimits(maxInFlight, queueSize int, queueWait time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if maxInFlight == l.maxInFlight && queueSize == l.queueSize && queueWait == l.queueWait {
+		return
+	}
+	l.maxInFlight = maxInFlight
+	l.queueSize = queueSize
+	l.queueWait = queueWait
+	l.nodes = make(map[string]*nodeSlots)
+}
+
+// slotsFor returns the slot tracker for target, creating it on first use, along with the current
+// queue limits. It returns nil when limiting is disabled.
+func (l *NodeLimiter) slotsFor(target string) (*nodeSlots, int, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.maxInFlight <= 0 {
+		return nil, 0, 0
+	}
+	n, ok := l.nodes[target]
+	if !ok {
+		n = &nodeSlots{slots: make(chan struct{}, l.maxInFlight)}
+		l.nodes[target] = n
+	}
+	return n, l.queueSize, l.queueWait
+}
@@ -0,0 +1,73 @@
+package gateway
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestNodeLimiter(t *testing.T) {
+	limiter := NewNodeLimiter(1, 1, 50*time.Millisecond)
+	ctx := context.Background()
+
+	release, ok := limiter.Acquire(ctx, "http://node-a")
+	if !ok {
+		t.Fatal("expected first request to get a slot")
+	}
+
+	// Other nodes have their own slots
+	releaseB, ok := limiter.Acquire(ctx, "http://node-b")
+	if !ok {
+		t.Fatal("expected request to another node to get a slot")
+	}
+	releaseB()
+
+	// A queued request gets the slot once it is released
+	acquired := make(chan bool)
+	go func() {
+		releaseQueued, ok := limiter.Acquire(ctx, "http://node-a")
+		if ok {
+			releaseQueued()
+		}
+		acquired <- ok
+	}()
+
+	// Wait for the goroutine to take the only queue position, then overflow the queue
+	deadline := time.Now().Add(time.Second)
+	for {
+		limiter.mu.Lock()
+		waiting := limiter.nodes["http://node-a"].waiting
+		limiter.mu.Unlock()
+		if waiting == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("queued request never started waiting")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if _, ok := limiter.Acquire(ctx, "http://node-a"); ok {
+		t.Fatal("expected request to be rejected when the queue is full")
+	}
+
+	release()
+	if !<-acquired {
+		t.Fatal("expected queued request to get the released slot")
+	}
+
+	// Saturated with no release: queued requests time out
+	release, _ = limiter.Acquire(ctx, "http://node-a")
+	defer release()
+	if _, ok := limiter.Acquire(ctx, "http://node-a"); ok {
+		t.Fatal("expected queued request to time out")
+	}
+}
+
+func TestNodeLimiter_Disabled(t *testing.T) {
+	limiter := NewNodeLimiter(0, 0, 0)
+	for i := 0; i < 100; i++ {
+		if _, ok := limiter.Acquire(context.Background(), "http://node-a"); !ok {
+			t.Fatal("expected disabled limiter to admit every request")
+		}
+	}
+}
@@ -1,35 +1,116 @@
 package gateway
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
 	"io"
 	"log/slog"
 	"net/http"
 	"net/url"
 	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/selfhostly/internal/reqsign"
+	"github.com/selfhostly/internal/timeouts"
 )
 
+// writeDeadlineSlack is extra time to stream a response after the upstream call's own timeout
+const writeDeadlineSlack = 30 * time.Second
+
 // Proxy forwards requests to the target node and returns the response as-is
 type Proxy struct {
 	router        *Router
 	registry      *NodeRegistry
 	gatewayAPIKey string
-	config        *Config
+	config        atomic.Pointer[Config] // swapped whole on reload
 	transport     http.RoundTripper
 	logger        *slog.Logger
+	limiter       *NodeLimiter
+	errorPages    atomic.Pointer[ErrorPages]
+	draining      atomic.Bool
 }
 
+// drainRetryAfter is the Retry-After a draining gateway sends; by then another replica or the
+// restarted gateway should be answering
+const drainRetryAfter = 5
+
 // NewProxy creates a proxy that uses the router and adds gateway auth
 func NewProxy(router *Router, registry *NodeRegistry, cfg *Config, logger *slog.Logger) *Proxy {
-	return &Proxy{
+	p := &Proxy{
 		router:        router,
 		registry:      registry,
 		gatewayAPIKey: cfg.GatewayAPIKey,
-		config:        cfg,
 		transport:     http.DefaultTransport,
 		logger:        logger,
+		limiter:       NewNodeLimiter(cfg.NodeMaxInFlight, cfg.NodeQueueSize, cfg.NodeQueueWait),
+	}
+	p.config.Store(cfg)
+	pages, _ := LoadErrorPages("")
+	p.errorPages.Store(pages)
+	return p
+}
+
+// SetErrorPages replaces the pages served for the gateway's own errors
+func (p *Proxy) SetErrorPages(pages *ErrorPages) {
+	p.errorPages.Store(pages)
+}
+
+// Drain makes the gateway answer new requests with 503 and report itself draining on /api/health
+// and /gateway/status, ahead of shutting down
+func (p *Proxy) Drain() {
+	p.draining.Store(true)
+}
+
+// Reload applies the reload-safe values of next (timeouts, per-node limits, the registry TTL and
+// the canary) to the running gateway and returns the environment variables whose values changed. Everything
+// else in next is ignored until a restart.
+func (p *Proxy) Reload(next *Config) []string {
+	current := p.config.Load()
+	updated := *current
+
+	changed := []string{}
+	changed = append(changed, timeouts.ChangedEnv(current.Timeouts, next.Timeouts)...)
+	updated.Timeouts = next.Timeouts
+
+	for _, l := range []struct {
+		env     string
+		changed bool
+	}{
+		{"GATEWAY_NODE_MAX_INFLIGHT", next.NodeMaxInFlight != current.NodeMaxInFlight},
+		{"GATEWAY_NODE_QUEUE_SIZE", next.NodeQueueSize != current.NodeQueueSize},
+		{"GATEWAY_NODE_QUEUE_WAIT_SEC", next.NodeQueueWait != current.NodeQueueWait},
+		{"GATEWAY_CANARY_BACKEND_URL", next.CanaryBackendURL != current.CanaryBackendURL},
+		{"GATEWAY_CANARY_PERCENT", next.CanaryPercent != current.CanaryPercent},
+		{"GATEWAY_BRAND_NAME", next.BrandName != current.BrandName},
+	} {
+		if l.changed {
+			changed = append(changed, l.env)
+		}
+	}
+	updated.NodeMaxInFlight = next.NodeMaxInFlight
+	updated.NodeQueueSize = next.NodeQueueSize
+	updated.NodeQueueWait = next.NodeQueueWait
+	p.limiter.SetLimits(next.NodeMaxInFlight, next.NodeQueueSize, next.NodeQueueWait)
+	updated.CanaryBackendURL = next.CanaryBackendURL
+	updated.CanaryPercent = next.CanaryPercent
+	updated.BrandName = next.BrandName
+
+	if next.RegistryTTL != current.RegistryTTL {
+		updated.RegistryTTL = next.RegistryTTL
+		p.registry.SetTTL(next.RegistryTTL)
+		changed = append(changed, "GATEWAY_REGISTRY_TTL_SEC")
+	}
+	// Compared with the registry's token, which differs from the configured one once rotated
+	if next.RegistryToken != "" && next.RegistryToken != p.registry.Token() {
+		updated.RegistryToken, updated.RegistryTokenFile = next.RegistryToken, next.RegistryTokenFile
+		p.registry.SetToken(next.RegistryToken, next.RegistryTokenFile)
+		changed = append(changed, "GATEWAY_REGISTRY_TOKEN")
 	}
+	p.config.Store(&updated)
+	return changed
 }
 
 // ServeHTTP validates auth, resolves target, and forwards the request
@@ -38,6 +119,11 @@ func (p *Proxy) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 	// Support both GET and HEAD methods (Docker healthcheck uses HEAD)
 	if (req.Method == http.MethodGet || req.Method == http.MethodHead) && req.URL.Path == "/api/health" {
 		w.Header().Set("Content-Type", "application/json")
+		if p.draining.Load() {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			_, _ = w.Write([]byte(`{"status":"draining","service":"gateway"}`))
+			return
+		}
 		// Check if registry is ready (has successfully connected to primary at least once)
 		if !p.registry.IsReady() {
 			w.WriteHeader(http.StatusServiceUnavailable)
@@ -48,6 +134,19 @@ func (p *Proxy) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 		_, _ = w.Write([]byte(`{"status":"healthy","service":"gateway"}`))
 		return
 	}
+	// Gateway-local endpoints answer without auth and without a backend
+	if (req.Method == http.MethodGet || req.Method == http.MethodHead) && req.URL.Path == "/gateway/status" {
+		p.writeStatus(w)
+		return
+	}
+	if (req.Method == http.MethodGet || req.Method == http.MethodHead) && strings.HasPrefix(req.URL.Path, "/gateway/assets/") {
+		p.errorPages.Load().ServeAsset(w, req)
+		return
+	}
+	if p.draining.Load() {
+		p.writeError(w, req, http.StatusServiceUnavailable, GatewayErrorDraining, "The gateway is restarting, retry shortly", drainRetryAfter)
+		return
+	}
 
 	hasReqCookie := req.Header.Get("Cookie") != ""
 	p.logger.InfoContext(req.Context(), "gateway: incoming request",
@@ -58,7 +157,7 @@ func (p *Proxy) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 		"has_cookie", hasReqCookie,
 	)
 
-	if !p.config.ValidateRequest(req) {
+	if !p.config.Load().ValidateRequest(req) {
 		p.logger.WarnContext(req.Context(), "gateway: auth required",
 			"path", req.URL.Path,
 			"has_cookie", req.Header.Get("Cookie") != "",
@@ -71,7 +170,21 @@ func (p *Proxy) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 	}
 
 	baseURL, ok := p.router.Target(req)
+	if !ok && p.config.Load().QueueOfflineOperations && isMutatingMethod(req.Method) {
+		if nodeID := p.router.OfflineNodeID(req); nodeID != "" {
+			p.queueForOfflineNode(w, req, nodeID)
+			return
+		}
+	}
 	if !ok {
+		if nodeID := p.router.OfflineNodeID(req); nodeID != "" {
+			p.logger.WarnContext(req.Context(), "gateway: target node is offline",
+				"path", req.URL.Path,
+				"node_id", nodeID,
+			)
+			p.writeError(w, req, http.StatusBadGateway, GatewayErrorNodeDown, "Node "+nodeID+" is offline", 0)
+			return
+		}
 		p.logger.WarnContext(req.Context(), "gateway: could not resolve target",
 			"path", req.URL.Path,
 			"node_id", req.URL.Query().Get("node_id"),
@@ -87,6 +200,38 @@ func (p *Proxy) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 		"target", baseURL,
 	)
 
+	if skewed := p.router.VersionSkewedNode(req); skewed != nil {
+		p.logger.WarnContext(req.Context(), "gateway: refusing write to node with incompatible version",
+			"path", req.URL.Path,
+			"node_id", skewed.ID,
+			"warning", skewed.VersionWarning,
+		)
+		body, _ := json.Marshal(map[string]string{
+			"error":   "Node runs an incompatible version; upgrade it before making changes",
+			"details": skewed.VersionWarning,
+		})
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusConflict)
+		_, _ = w.Write(body)
+		return
+	}
+
+	baseURL = p.canaryTarget(w, req, baseURL)
+
+	// Node-routed requests drive docker on the target; cap how many run there at once
+	if !p.router.isPrimaryOnly(req.URL.Path, req.Method) {
+		release, ok := p.limiter.Acquire(req.Context(), baseURL)
+		if !ok {
+			p.logger.WarnContext(req.Context(), "gateway: node saturated, rejecting request",
+				"path", req.URL.Path,
+				"target", baseURL,
+			)
+			p.writeError(w, req, http.StatusTooManyRequests, GatewayErrorThrottled, "Node is busy, retry later", p.limiter.RetryAfter())
+			return
+		}
+		defer release()
+	}
+
 	targetURL, err := url.Parse(baseURL)
 	if err != nil {
 		p.logger.ErrorContext(req.Context(), "gateway: invalid target URL",
@@ -97,8 +242,15 @@ func (p *Proxy) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
+	// Bound the upstream call by the request's operation class and give the response write the same
+	// budget, rather than one server-wide timeout (ignored when the writer has no deadline support)
+	timeout := p.config.Load().Timeouts.ForRequest(req.Method, req.URL.Path)
+	ctx, cancel := context.WithTimeout(req.Context(), timeout)
+	defer cancel()
+	_ = http.NewResponseController(w).SetWriteDeadline(time.Now().Add(timeout + writeDeadlineSlack))
+
 	// Build outgoing request: same method, path, query, body
-	outReq := req.Clone(req.Context())
+	outReq := req.Clone(ctx)
 	outReq.URL.Scheme = targetURL.Scheme
 	outReq.URL.Host = targetURL.Host
 	outReq.URL.Path = req.URL.Path
@@ -146,11 +298,16 @@ func (p *Proxy) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 	// Add gateway auth only for node registry/management endpoints.
 	// Don't add it for user-facing endpoints (like /api/me, /api/apps, etc.)
 	// because gateway auth bypasses user authentication.
+	// Once the gateway has a token the key is retired and the user's own credentials are passed on.
 	isNodeManagementEndpoint := strings.HasPrefix(req.URL.Path, "/api/nodes") &&
 		!strings.HasSuffix(req.URL.Path, "/register")
 
-	if isNodeManagementEndpoint {
+	if isNodeManagementEndpoint && p.usesGatewayAPIKey() {
 		outReq.Header.Set("X-Gateway-API-Key", p.gatewayAPIKey)
+		if err := reqsign.Sign(outReq, p.gatewayAPIKey); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
 	}
 	// So primary can rewrite OAuth redirects to the public URL (where the user actually is).
 	// Use incoming X-Forwarded-Host if set, else derive from Referer (for dev with Vite proxy),
@@ -258,6 +415,14 @@ func (p *Proxy) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 			p.logger.DebugContext(req.Context(), "gateway: upstream request canceled by client",
 				"target", baseURL,
 			)
+		} else if errors.Is(err, context.DeadlineExceeded) {
+			p.logger.WarnContext(req.Context(), "gateway: upstream request timed out",
+				"target", baseURL,
+				"path", req.URL.Path,
+				"timeout", timeout,
+			)
+			w.WriteHeader(http.StatusGatewayTimeout)
+			return
 		} else {
 			p.logger.ErrorContext(req.Context(), "gateway: upstream request failed",
 				"target", baseURL,
@@ -265,7 +430,7 @@ func (p *Proxy) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 				"error", err,
 			)
 		}
-		w.WriteHeader(http.StatusBadGateway)
+		p.writeError(w, req, http.StatusBadGateway, GatewayErrorNodeDown, "The node did not respond", 0)
 		return
 	}
 	defer resp.Body.Close()
@@ -301,9 +466,150 @@ func (p *Proxy) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 		}
 	}
 	w.WriteHeader(resp.StatusCode)
+	if strings.HasPrefix(resp.Header.Get("Content-Type"), "text/event-stream") {
+		copyFlushing(w, resp.Body)
+		return
+	}
+	_, _ = io.Copy(w, resp.Body)
+}
+
+// writeError answers req with one of the gateway's own errors, as a page or JSON
+func (p *Proxy) writeError(w http.ResponseWriter, req *http.Request, status int, kind, message string, retryAfter int) {
+	p.errorPages.Load().Write(w, req, status, kind, message, p.config.Load().BrandName, retryAfter)
+}
+
+// gatewayStatus is the body of GET /gateway/status
+type gatewayStatus struct {
+	Service       string    `json:"service"`
+	Status        string    `json:"status"` // healthy, initializing or draining
+	RegistryReady bool      `json:"registry_ready"`
+	NodesTotal    int       `json:"nodes_total"`
+	NodesOnline   int       `json:"nodes_online"`
+	Time          time.Time `json:"time"`
+}
+
+// writeStatus reports the gateway's own state. It always answers 200 while the gateway is up, so
+// the web UI can tell a failed backend (this succeeds) from an unreachable gateway (it doesn't).
+func (p *Proxy) writeStatus(w http.ResponseWriter) {
+	status := gatewayStatus{
+		Service:       "gateway",
+		Status:        "healthy",
+		RegistryReady: p.registry.IsReady(),
+		Time:          time.Now().UTC(),
+	}
+	status.NodesTotal, status.NodesOnline = p.registry.NodeCounts()
+	switch {
+	case p.draining.Load():
+		status.Status = "draining"
+	case !status.RegistryReady:
+		status.Status = "initializing"
+	}
+	body, _ := json.Marshal(status)
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(body)
+}
+
+// queueForOfflineNode hands a request for an offline node to the primary's operation queue and
+// relays the primary's answer (202 with the queued operation on success). The operation is later
+// replayed with node credentials, so only requests carrying the caller's credentials are queued;
+// they are passed along for the primary to authorize the caller.
+func (p *Proxy) queueForOfflineNode(w http.ResponseWriter, req *http.Request, nodeID string) {
+	if p.config.Load().extractToken(req) == "" {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnauthorized)
+		_, _ = w.Write([]byte(`{"error":"Authentication required to queue operations for an offline node"}`))
+		return
+	}
+
+	var body []byte
+	if req.Body != nil {
+		var err error
+		if body, err = io.ReadAll(req.Body); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+	}
+
+	payload, err := json.Marshal(queuedOperationPayload{
+		Method: req.Method,
+		Path:   req.URL.Path,
+		Query:  req.URL.RawQuery,
+		Body:   string(body),
+	})
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	queueURL := p.registry.PrimaryBaseURL() + "/api/nodes/" + url.PathEscape(nodeID) + "/operations"
+	outReq, err := http.NewRequestWithContext(req.Context(), http.MethodPost, queueURL, bytes.NewReader(payload))
+	if err != nil {
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+	outReq.Header.Set("Content-Type", "application/json")
+	for _, header := range []string{"Cookie", "Authorization", "X-XSRF-TOKEN"} {
+		if value := req.Header.Get(header); value != "" {
+			outReq.Header.Set(header, value)
+		}
+	}
+	if p.usesGatewayAPIKey() {
+		outReq.Header.Set("X-Gateway-API-Key", p.gatewayAPIKey)
+		if err := reqsign.Sign(outReq, p.gatewayAPIKey); err != nil {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+	}
+
+	p.logger.InfoContext(req.Context(), "gateway: node offline, queueing operation on primary",
+		"node_id", nodeID,
+		"method", req.Method,
+		"path", req.URL.Path,
+	)
+
+	resp, err := p.transport.RoundTrip(outReq)
+	if err != nil {
+		p.logger.ErrorContext(req.Context(), "gateway: failed to queue operation",
+			"node_id", nodeID,
+			"path", req.URL.Path,
+			"error", err,
+		)
+		w.WriteHeader(http.StatusBadGateway)
+		return
+	}
+	defer resp.Body.Close()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(resp.StatusCode)
 	_, _ = io.Copy(w, resp.Body)
 }
 
+// usesGatewayAPIKey reports whether requests to the primary still carry the static gateway API
+// key: only while the gateway has one and hasn't been given a token, which retires it
+func (p *Proxy) usesGatewayAPIKey() bool {
+	return p.gatewayAPIKey != "" && p.registry.Token() == ""
+}
+
+// queuedOperationPayload mirrors the primary's queue-operation request body
+type queuedOperationPayload struct {
+	Method string `json:"method"`
+	Path   string `json:"path"`
+	Query  string `json:"query,omitempty"`
+	Body   string `json:"body,omitempty"`
+}
+
+// isMutatingMethod reports whether a request changes state and is therefore worth queueing
+func isMutatingMethod(method string) bool {
+	switch method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		return true
+	default:
+		return false
+	}
+}
+
 // containsCookieName checks if any Set-Cookie header contains the given cookie name
 func containsCookieName(cookies []string, name string) bool {
 	for _, cookie := range cookies {
@@ -313,3 +619,22 @@ func containsCookieName(cookies []string, name string) bool {
 	}
 	return false
 }
+
+// copyFlushing relays an event stream, flushing after every read so events reach the client as
+// the backend sends them instead of when a buffer fills
+func copyFlushing(w http.ResponseWriter, body io.Reader) {
+	rc := http.NewResponseController(w)
+	buf := make([]byte, 4096)
+	for {
+		n, err := body.Read(buf)
+		if n > 0 {
+			if _, werr := w.Write(buf[:n]); werr != nil {
+				return
+			}
+			_ = rc.Flush()
+		}
+		if err != nil {
+			return
+		}
+	}
+}
@@ -1,11 +1,19 @@
 package gateway
 
 import (
+	"context"
+	"encoding/json"
+	"io"
 	"log/slog"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
+
+	"github.com/selfhostly/internal/constants"
 )
 
 func setupTestProxy(t *testing.T) (*Proxy, *NodeRegistry, *Config) {
@@ -107,3 +115,395 @@ func TestProxy_HealthCheck_HEAD(t *testing.T) {
 		t.Errorf("expected Content-Type application/json, got %q", contentType)
 	}
 }
+
+func TestProxy_QueuesOperationsForOfflineNode(t *testing.T) {
+	var gotPath, gotKey, gotAuth string
+	var gotPayload queuedOperationPayload
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		gotKey = r.Header.Get("X-Gateway-API-Key")
+		gotAuth = r.Header.Get("Authorization")
+		_ = json.NewDecoder(r.Body).Decode(&gotPayload)
+		w.WriteHeader(http.StatusAccepted)
+		_, _ = w.Write([]byte(`{"queued":true}`))
+	}))
+	defer primary.Close()
+
+	proxy, registry, cfg := setupTestProxy(t)
+	registry.mu.Lock()
+	registry.nodes = map[string]NodeEntry{
+		"primary-node": {ID: "primary-node", APIEndpoint: primary.URL, IsPrimary: true, Status: constants.NodeStatusOnline},
+		"offline-node": {ID: "offline-node", APIEndpoint: "http://offline:8084", Status: constants.NodeStatusOffline},
+	}
+	registry.primary = "primary-node"
+	registry.primaryBackendURL = primary.URL
+	registry.mu.Unlock()
+
+	newRequest := func(method string) *http.Request {
+		req := httptest.NewRequest(method, "/api/apps/app-123/start?node_id=offline-node", strings.NewReader(`{"force":true}`))
+		req.Header.Set("Authorization", "Bearer user-jwt")
+		return req
+	}
+
+	// Disabled by default: the request is rejected as node down
+	w := httptest.NewRecorder()
+	proxy.ServeHTTP(w, newRequest(http.MethodPost))
+	if w.Code != http.StatusBadGateway || w.Header().Get(GatewayErrorHeader) != GatewayErrorNodeDown {
+		t.Fatalf("expected status %d with queueing disabled, got %d", http.StatusBadGateway, w.Code)
+	}
+
+	cfg.QueueOfflineOperations = true
+
+	// Reads are never queued
+	w = httptest.NewRecorder()
+	proxy.ServeHTTP(w, newRequest(http.MethodGet))
+	if w.Code != http.StatusBadGateway {
+		t.Fatalf("expected GET to be rejected, got %d", w.Code)
+	}
+
+	// Anonymous requests are never queued: they'd be replayed with node credentials
+	w = httptest.NewRecorder()
+	anonymous := newRequest(http.MethodPost)
+	anonymous.Header.Del("Authorization")
+	proxy.ServeHTTP(w, anonymous)
+	if w.Code != http.StatusUnauthorized || gotPath != "" {
+		t.Fatalf("expected an anonymous request to be refused, got %d", w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	proxy.ServeHTTP(w, newRequest(http.MethodPost))
+	if w.Code != http.StatusAccepted {
+		t.Fatalf("expected status %d, got %d", http.StatusAccepted, w.Code)
+	}
+	if gotPath != "/api/nodes/offline-node/operations" {
+		t.Errorf("expected queue request to primary, got path %q", gotPath)
+	}
+	if gotKey != cfg.GatewayAPIKey {
+		t.Errorf("expected gateway API key on queue request")
+	}
+	if gotAuth != "Bearer user-jwt" {
+		t.Errorf("expected the caller's credentials on queue request, got %q", gotAuth)
+	}
+	want := queuedOperationPayload{Method: http.MethodPost, Path: "/api/apps/app-123/start", Query: "node_id=offline-node", Body: `{"force":true}`}
+	if gotPayload != want {
+		t.Errorf("payload = %+v, want %+v", gotPayload, want)
+	}
+}
+
+func TestProxy_RejectsWhenNodeSaturated(t *testing.T) {
+	proxy, registry, _ := setupTestProxy(t)
+	registry.mu.Lock()
+	registry.nodes = map[string]NodeEntry{
+		"busy-node": {ID: "busy-node", APIEndpoint: "http://busy:8083", Status: constants.NodeStatusOnline},
+	}
+	registry.mu.Unlock()
+	proxy.limiter = NewNodeLimiter(1, 0, time.Second)
+
+	release, ok := proxy.limiter.Acquire(context.Background(), "http://busy:8083")
+	if !ok {
+		t.Fatal("failed to occupy the node's only slot")
+	}
+	defer release()
+
+	w := httptest.NewRecorder()
+	proxy.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/apps/app-123/start?node_id=busy-node", nil))
+
+	if w.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected status %d, got %d", http.StatusTooManyRequests, w.Code)
+	}
+	if got := w.Header().Get("Retry-After"); got != "1" {
+		t.Errorf("expected Retry-After 1, got %q", got)
+	}
+	if got := w.Header().Get(GatewayErrorHeader); got != GatewayErrorThrottled {
+		t.Errorf("expected %s %q, got %q", GatewayErrorHeader, GatewayErrorThrottled, got)
+	}
+}
+
+func TestProxy_TimesOutByOperationClass(t *testing.T) {
+	proxy, registry, cfg := setupTestProxy(t)
+	registry.mu.Lock()
+	registry.nodes = map[string]NodeEntry{
+		"slow-node": {ID: "slow-node", APIEndpoint: "http://slow:8083", Status: constants.NodeStatusOnline},
+	}
+	registry.mu.Unlock()
+	cfg.Timeouts.Read = 50 * time.Millisecond
+	cfg.Timeouts.ContainerUpdate = time.Minute
+
+	var deadlines []time.Duration
+	proxy.transport = roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		deadline, ok := req.Context().Deadline()
+		if !ok {
+			t.Fatal("expected upstream request to carry a deadline")
+		}
+		deadlines = append(deadlines, time.Until(deadline))
+		if req.Method == http.MethodGet {
+			<-req.Context().Done()
+			return nil, req.Context().Err()
+		}
+		return &http.Response{StatusCode: http.StatusOK, Header: http.Header{}, Body: http.NoBody}, nil
+	})
+
+	w := httptest.NewRecorder()
+	proxy.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/apps/app-123?node_id=slow-node", nil))
+	if w.Code != http.StatusGatewayTimeout {
+		t.Errorf("expected status %d for slow read, got %d", http.StatusGatewayTimeout, w.Code)
+	}
+
+	w = httptest.NewRecorder()
+	proxy.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/api/apps/app-123/stop?node_id=slow-node", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("expected status %d for container update, got %d", http.StatusOK, w.Code)
+	}
+
+	if len(deadlines) != 2 || deadlines[1] < 30*time.Second {
+		t.Errorf("expected container update to get the longer timeout, got %v", deadlines)
+	}
+}
+
+func TestProxy_FlushesEventStreams(t *testing.T) {
+	proxy, registry, _ := setupTestProxy(t)
+	registry.mu.Lock()
+	registry.nodes = map[string]NodeEntry{
+		"node-1": {ID: "node-1", APIEndpoint: "http://node1:8083", Status: constants.NodeStatusOnline},
+	}
+	registry.mu.Unlock()
+
+	proxy.transport = roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Type": {"text/event-stream"}},
+			Body:       io.NopCloser(strings.NewReader("event:stats\ndata:{}\n\n")),
+		}, nil
+	})
+
+	w := httptest.NewRecorder()
+	proxy.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/apps/app-123/stats/stream?node_id=node-1", nil))
+	if !w.Flushed {
+		t.Error("expected the event stream to be flushed")
+	}
+	if w.Body.String() != "event:stats\ndata:{}\n\n" {
+		t.Errorf("unexpected body %q", w.Body.String())
+	}
+}
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) { return f(req) }
+
+func TestProxy_Reload(t *testing.T) {
+	proxy, registry, cfg := setupTestProxy(t)
+
+	next := *cfg
+	next.RegistryTTL = 10 * time.Second
+	next.NodeMaxInFlight = 1
+	next.Timeouts.Read = 5 * time.Second
+	next.ListenAddress = ":9090"
+
+	changed := proxy.Reload(&next)
+	want := []string{"TIMEOUT_READ_SEC", "GATEWAY_NODE_MAX_INFLIGHT", "GATEWAY_REGISTRY_TTL_SEC"}
+	if strings.Join(changed, ",") != strings.Join(want, ",") {
+		t.Errorf("changed = %v, want %v", changed, want)
+	}
+	if got := registry.TTL(); got != 10*time.Second {
+		t.Errorf("expected registry TTL 10s, got %v", got)
+	}
+	if got := proxy.config.Load(); got.Timeouts.Read != 5*time.Second || got.ListenAddress != ":8080" {
+		t.Errorf("expected reloaded timeouts and the original listen address, got %+v", got)
+	}
+
+	// The new in-flight limit applies to the next request
+	release, ok := proxy.limiter.Acquire(context.Background(), "http://node:8083")
+	if !ok {
+		t.Fatal("expected the first request to get a slot")
+	}
+	defer release()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, ok := proxy.limiter.Acquire(ctx, "http://node:8083"); ok {
+		t.Error("expected a second request to be refused under the reloaded limit")
+	}
+
+	if changed := proxy.Reload(&next); len(changed) != 0 {
+		t.Errorf("expected nothing to change on a repeat reload, got %v", changed)
+	}
+}
+
+func TestProxy_CanaryRouting(t *testing.T) {
+	proxy, registry, cfg := setupTestProxy(t)
+	registry.mu.Lock()
+	registry.primary = "primary-node"
+	registry.nodes = map[string]NodeEntry{
+		"primary-node": {ID: "primary-node", APIEndpoint: "http://primary-self:8082", IsPrimary: true, Status: constants.NodeStatusOnline},
+		"edge-node":    {ID: "edge-node", APIEndpoint: "http://edge:8083", Status: constants.NodeStatusOnline},
+	}
+	registry.mu.Unlock()
+	cfg.CanaryBackendURL = "http://primary-next:8082"
+	cfg.CanaryPercent = 50
+
+	var host string
+	proxy.transport = roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		host = req.URL.Host
+		return &http.Response{StatusCode: http.StatusOK, Header: http.Header{}, Body: http.NoBody}, nil
+	})
+	serve := func(target string, bucket string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, target, nil)
+		if bucket != "" {
+			req.AddCookie(&http.Cookie{Name: canaryCookie, Value: bucket})
+		}
+		w := httptest.NewRecorder()
+		proxy.ServeHTTP(w, req)
+		return w
+	}
+
+	tests := []struct {
+		name     string
+		target   string
+		bucket   string
+		wantHost string
+	}{
+		{"bucket below percent", "/api/apps", "10", "primary-next:8082"},
+		{"bucket above percent", "/api/apps", "75", "primary:8082"},
+		{"primary node by id", "/api/apps/app-1?node_id=primary-node", "10", "primary-next:8082"},
+		{"secondary node", "/api/apps/app-1?node_id=edge-node", "10", "edge:8083"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			serve(tt.target, tt.bucket)
+			if host != tt.wantHost {
+				t.Errorf("routed to %q, want %q", host, tt.wantHost)
+			}
+		})
+	}
+
+	// A new client is assigned a bucket and told which instance answered
+	w := serve("/api/apps", "")
+	if !strings.Contains(w.Header().Get("Set-Cookie"), canaryCookie+"=") {
+		t.Errorf("expected a %s cookie, got %q", canaryCookie, w.Header().Get("Set-Cookie"))
+	}
+	if got := w.Header().Get(backendHeader); got != "canary" && got != "stable" {
+		t.Errorf("unexpected %s header %q", backendHeader, got)
+	}
+
+	// Rolling back to 0% sends every client to the stable primary on the next request
+	next := *cfg
+	next.CanaryPercent = 0
+	if changed := proxy.Reload(&next); strings.Join(changed, ",") != "GATEWAY_CANARY_PERCENT" {
+		t.Errorf("changed = %v, want [GATEWAY_CANARY_PERCENT]", changed)
+	}
+	if w := serve("/api/apps", "0"); host != "primary:8082" || w.Header().Get(backendHeader) != "stable" {
+		t.Errorf("expected the stable primary after rollback, got %q", host)
+	}
+}
+
+func TestProxy_GatewayStatus(t *testing.T) {
+	proxy, registry, _ := setupTestProxy(t)
+	registry.mu.Lock()
+	registry.nodes = map[string]NodeEntry{
+		"primary-node": {ID: "primary-node", IsPrimary: true, Status: constants.NodeStatusOnline},
+		"offline-node": {ID: "offline-node", Status: constants.NodeStatusOffline},
+	}
+	registry.mu.Unlock()
+
+	getStatus := func() gatewayStatus {
+		w := httptest.NewRecorder()
+		proxy.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/gateway/status", nil))
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status %d, got %d", http.StatusOK, w.Code)
+		}
+		var status gatewayStatus
+		if err := json.Unmarshal(w.Body.Bytes(), &status); err != nil {
+			t.Fatalf("failed to decode status: %v", err)
+		}
+		return status
+	}
+
+	status := getStatus()
+	if status.Status != "healthy" || !status.RegistryReady || status.NodesTotal != 2 || status.NodesOnline != 1 {
+		t.Errorf("unexpected status %+v", status)
+	}
+
+	proxy.Drain()
+	if status := getStatus(); status.Status != "draining" {
+		t.Errorf("expected draining, got %q", status.Status)
+	}
+}
+
+func TestProxy_Draining(t *testing.T) {
+	proxy, _, _ := setupTestProxy(t)
+	proxy.Drain()
+
+	w := httptest.NewRecorder()
+	proxy.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/health", nil))
+	if w.Code != http.StatusServiceUnavailable || !strings.Contains(w.Body.String(), `"draining"`) {
+		t.Errorf("expected health check to report draining, got %d %s", w.Code, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	proxy.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/api/apps", nil))
+	if w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected status %d, got %d", http.StatusServiceUnavailable, w.Code)
+	}
+	if w.Header().Get(GatewayErrorHeader) != GatewayErrorDraining || w.Header().Get("Retry-After") == "" {
+		t.Errorf("expected draining error with Retry-After, got headers %v", w.Header())
+	}
+	if !strings.HasPrefix(w.Header().Get("Content-Type"), "application/json") {
+		t.Errorf("expected JSON for an API call, got %q", w.Header().Get("Content-Type"))
+	}
+}
+
+func TestProxy_ErrorPages(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	upstream.Close() // refuses connections, like a node that went down
+
+	proxy, registry, cfg := setupTestProxy(t)
+	cfg.BrandName = "Homelab"
+	registry.mu.Lock()
+	registry.primaryBackendURL = upstream.URL
+	registry.mu.Unlock()
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "502.html"), []byte(`<h1>{{.Brand}}: {{.Title}}</h1>`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Mkdir(filepath.Join(dir, "assets"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "assets", "logo.svg"), []byte(`<svg/>`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	pages, err := LoadErrorPages(dir)
+	if err != nil {
+		t.Fatalf("LoadErrorPages: %v", err)
+	}
+	proxy.SetErrorPages(pages)
+
+	// A browser loading a page gets the custom page
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Accept", "text/html,application/xhtml+xml")
+	w := httptest.NewRecorder()
+	proxy.ServeHTTP(w, req)
+	if w.Code != http.StatusBadGateway || w.Body.String() != "<h1>Homelab: Node unavailable</h1>" {
+		t.Errorf("expected custom 502 page, got %d %q", w.Code, w.Body.String())
+	}
+
+	// The web UI's API calls get JSON
+	req = httptest.NewRequest(http.MethodGet, "/api/apps", nil)
+	req.Header.Set("Accept", "text/html")
+	w = httptest.NewRecorder()
+	proxy.ServeHTTP(w, req)
+	if w.Code != http.StatusBadGateway || !strings.Contains(w.Body.String(), `"gateway_error":"node_down"`) {
+		t.Errorf("expected JSON 502, got %d %q", w.Code, w.Body.String())
+	}
+
+	// Assets are served from the directory while the backend is down
+	w = httptest.NewRecorder()
+	proxy.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/gateway/assets/logo.svg", nil))
+	if w.Code != http.StatusOK || w.Body.String() != "<svg/>" {
+		t.Errorf("expected asset, got %d %q", w.Code, w.Body.String())
+	}
+	w = httptest.NewRecorder()
+	proxy.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/gateway/assets/", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected no directory listing, got %d", w.Code)
+	}
+}
@@ -17,6 +17,9 @@ type NodeEntry struct {
 	APIEndpoint string `json:"api_endpoint"`
 	IsPrimary   bool   `json:"is_primary"`
 	Status      string `json:"status"`
+
+	// VersionWarning is set by the primary when the node's version is incompatible with its own
+	VersionWarning string `json:"version_warning,omitempty"`
 }
 
 // 	NodeRegistry caches node list from primary and refreshes periodically
@@ -25,9 +28,10 @@ type NodeRegistry struct {
 	gatewayAPIKey     string
 	httpClient        *http.Client
 	logger            *slog.Logger
-	ttl               time.Duration
+	token             registryToken // replaces gatewayAPIKey for the fetch when set
 
 	mu          sync.RWMutex
+	ttl         time.Duration        // refresh interval; changes on reload
 	nodes       map[string]NodeEntry // nodeID -> NodeEntry (includes endpoint and status)
 	primary     string               // primary node ID for "global" routes
 	initialized bool                 // true after first successful refresh
@@ -58,9 +62,9 @@ func (r *NodeRegistry) Start() {
 	}()
 	
 	go func() {
-		ticker := time.NewTicker(r.ttl)
-		defer ticker.Stop()
-		for range ticker.C {
+		// Re-read the TTL each round so a reload takes effect after the current wait
+		for {
+			time.Sleep(r.TTL())
 			if err := r.refresh(); err != nil {
 				r.logger.Warn("node registry refresh failed", "error", err)
 			}
@@ -68,21 +72,40 @@ func (r *NodeRegistry) Start() {
 	}()
 }
 
+// TTL returns how often the node list is refreshed
+func (r *NodeRegistry) TTL() time.Duration {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.ttl
+}
+
+// SetTTL changes how often the node list is refreshed
+func (r *NodeRegistry) SetTTL(ttl time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.ttl = ttl
+}
+
 func (r *NodeRegistry) refresh() error {
 	r.logger.Debug("node registry: refreshing", "primary_backend_url", r.primaryBackendURL)
+	r.reloadToken()
 
 	req, err := http.NewRequest(http.MethodGet, r.primaryBackendURL+"/api/nodes", nil)
 	if err != nil {
 		r.logger.Error("node registry: failed to create request", "error", err)
 		return err
 	}
-	req.Header.Set("X-Gateway-API-Key", r.gatewayAPIKey)
+	r.authenticate(req)
 	resp, err := r.httpClient.Do(req)
 	if err != nil {
 		r.logger.Error("node registry: request failed", "error", err, "primary_backend_url", r.primaryBackendURL)
 		return err
 	}
 	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusUnauthorized && r.Token() != "" {
+		r.logger.Error("node registry: gateway token rejected (expired or revoked); issue a new one and set GATEWAY_REGISTRY_TOKEN")
+		return errStatusCode(resp.StatusCode)
+	}
 	if resp.StatusCode != http.StatusOK {
 		r.logger.Warn("node registry: unexpected status", "status", resp.StatusCode)
 		return errStatusCode(resp.StatusCode)
@@ -126,8 +149,8 @@ func (r *NodeRegistry) Get(nodeID string) string {
 	if !ok {
 		return ""
 	}
-	// Don't route to offline or unreachable nodes
-	if entry.Status == constants.NodeStatusOffline || entry.Status == constants.NodeStatusUnreachable {
+	// Don't route to offline, unreachable or lost nodes
+	if entry.Status == constants.NodeStatusOffline || entry.Status == constants.NodeStatusUnreachable || entry.Status == constants.NodeStatusLost {
 		r.logger.Debug("node registry: skipping offline/unreachable node",
 			"node_id", nodeID,
 			"status", entry.Status,
@@ -148,6 +171,18 @@ func (r *NodeRegistry) GetEntry(nodeID string) *NodeEntry {
 	return &entry
 }
 
+// NodeCounts returns how many nodes the registry knows and how many of them are online
+func (r *NodeRegistry) NodeCounts() (total, online int) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, entry := range r.nodes {
+		if entry.Status == constants.NodeStatusOnline {
+			online++
+		}
+	}
+	return len(r.nodes), online
+}
+
 // PrimaryID returns the primary node ID
 func (r *NodeRegistry) PrimaryID() string {
 	r.mu.RLock()
@@ -5,10 +5,13 @@ import (
 	"log/slog"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
 	"github.com/selfhostly/internal/constants"
+	"github.com/selfhostly/internal/gatewaytoken"
 )
 
 func TestNodeRegistry_Refresh(t *testing.T) {
@@ -286,3 +289,55 @@ func TestNodeRegistry_PrimaryBaseURL(t *testing.T) {
 		t.Errorf("PrimaryBaseURL() = %q, want %q", got, primaryURL)
 	}
 }
+
+func TestNodeRegistry_TokenReload(t *testing.T) {
+	current := gatewaytoken.Sign("key", gatewaytoken.Claims{ID: "token-1", IssuedAt: time.Now(), ExpiresAt: time.Now().Add(24 * time.Hour)})
+	next := gatewaytoken.Sign("key", gatewaytoken.Claims{ID: "token-2", IssuedAt: time.Now(), ExpiresAt: time.Now().Add(24 * time.Hour)})
+
+	var listedWith string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Gateway-API-Key") != "" {
+			t.Errorf("expected no API key when a token is set, got %q", r.Header.Get("X-Gateway-API-Key"))
+		}
+		if r.URL.Path != "/api/nodes" {
+			t.Errorf("expected only the registry fetch, got %s %s", r.Method, r.URL.Path)
+			return
+		}
+		listedWith = r.Header.Get(gatewaytoken.Header)
+		json.NewEncoder(w).Encode([]NodeEntry{{ID: "primary-1", IsPrimary: true, Status: constants.NodeStatusOnline}})
+	}))
+	defer server.Close()
+
+	file := filepath.Join(t.TempDir(), "gateway-token")
+	registry := NewNodeRegistry(server.URL, "test-api-key", 60*time.Second, slog.Default())
+	registry.SetToken(current, file)
+
+	if err := registry.refresh(); err != nil {
+		t.Fatalf("refresh() error = %v", err)
+	}
+	if listedWith != current {
+		t.Errorf("expected the configured token to be used, listed with %q", listedWith)
+	}
+
+	// An admin rotates the token on the primary and saves the new one to the file
+	if err := os.WriteFile(file, []byte(next+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := registry.refresh(); err != nil {
+		t.Fatalf("refresh() error = %v", err)
+	}
+	if listedWith != next || registry.Token() != next {
+		t.Errorf("expected the saved token to be picked up, listed with %q", listedWith)
+	}
+
+	// A malformed token in the file is ignored
+	if err := os.WriteFile(file, []byte("garbage\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := registry.refresh(); err != nil {
+		t.Fatalf("refresh() error = %v", err)
+	}
+	if registry.Token() != next {
+		t.Error("expected a malformed token file to leave the current token in use")
+	}
+}
@@ -0,0 +1,92 @@
+package gateway
+
+import (
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/selfhostly/internal/gatewaytoken"
+	"github.com/selfhostly/internal/reqsign"
+)
+
+// registryToken is the token the registry fetch authenticates with when one is configured, in
+// place of the static gateway API key
+type registryToken struct {
+	mu    sync.Mutex
+	token string
+	file  string // re-read on every refresh so rotated tokens are picked up; optional
+}
+
+// SetToken makes the registry fetch authenticate with a gateway token issued by the primary
+// instead of the gateway API key. When file is set, the token saved there replaces it.
+func (r *NodeRegistry) SetToken(token, file string) {
+	r.token.mu.Lock()
+	defer r.token.mu.Unlock()
+	r.token.token = token
+	r.token.file = file
+}
+
+// Token returns the gateway token currently used for the registry fetch, if any
+func (r *NodeRegistry) Token() string {
+	r.token.mu.Lock()
+	defer r.token.mu.Unlock()
+	return r.token.token
+}
+
+// authenticate sets the registry fetch's credentials
+func (r *NodeRegistry) authenticate(req *http.Request) {
+	if token := r.Token(); token != "" {
+		req.Header.Set(gatewaytoken.Header, token)
+		return
+	}
+	if r.gatewayAPIKey == "" {
+		return
+	}
+	req.Header.Set("X-Gateway-API-Key", r.gatewayAPIKey)
+	_ = reqsign.Sign(req, r.gatewayAPIKey) // a bodiless request always signs
+}
+
+// reloadToken picks up a rotated token saved in the token file, and warns once the token in use
+// is past half its lifetime so an admin rotates it before it expires
+func (r *NodeRegistry) reloadToken() {
+	r.token.mu.Lock()
+	defer r.token.mu.Unlock()
+	if r.token.file != "" {
+		saved, err := readTokenFile(r.token.file)
+		if err != nil {
+			r.logger.Error("node registry: gateway token file could not be read", "file", r.token.file, "error", err)
+		} else if saved != "" && saved != r.token.token {
+			if _, err := gatewaytoken.Parse(saved); err != nil {
+				r.logger.Error("node registry: gateway token file holds a malformed token; keeping the current one", "file", r.token.file, "error", err)
+			} else {
+				r.token.token = saved
+				r.logger.Info("node registry: gateway token reloaded", "file", r.token.file)
+			}
+		}
+	}
+	if r.token.token == "" {
+		return
+	}
+	claims, err := gatewaytoken.Parse(r.token.token)
+	if err != nil {
+		r.logger.Error("node registry: configured gateway token is malformed", "error", err)
+		return
+	}
+	if !time.Now().Before(claims.RotateAt()) {
+		r.logger.Warn("node registry: gateway token is due for rotation; rotate it on the primary", "token_id", claims.ID, "expires_at", claims.ExpiresAt)
+	}
+}
+
+// readTokenFile returns the token saved in file, or "" if there is none yet
+func readTokenFile(file string) (string, error) {
+	data, err := os.ReadFile(file)
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
@@ -51,6 +51,11 @@ func (r *Router) Target(req *http.Request) (baseURL string, ok bool) {
 			return "", false
 		}
 		base := r.registry.Get(nodeID)
+		if base == "" && isForceDeleteApp(req) {
+			// The node can't clean up; the primary drops the app from its inventory instead
+			r.logger.Warn("router: force delete for unreachable node, routing to primary", "node_id", nodeID, "path", path)
+			return r.registry.PrimaryBaseURL(), true
+		}
 		if base == "" {
 			// Check if node exists but is offline/unreachable
 			if entry := r.registry.GetEntry(nodeID); entry != nil {
@@ -67,6 +72,43 @@ func (r *Router) Target(req *http.Request) (baseURL string, ok bool) {
 		return base, true
 	}
 
+	// Approvals live on the node that runs the operation, picked by an optional node_id. Those of
+	// an unreachable node's force deletes are on the primary, which ran them.
+	if path == "/api/approvals" || strings.HasPrefix(path, "/api/approvals/") {
+		if base := r.registry.Get(query.Get("node_id")); base != "" {
+			return base, true
+		}
+		return r.registry.PrimaryBaseURL(), true
+	}
+
+	// Event streams carry the changes of the node named by an optional node_id; the primary's
+	// also has app statuses and health of every node
+	if path == "/api/events" {
+		nodeID := query.Get("node_id")
+		if nodeID == "" {
+			return r.registry.PrimaryBaseURL(), true
+		}
+		if base := r.registry.Get(nodeID); base != "" {
+			return base, true
+		}
+		r.logger.Warn("router: event stream node is unreachable", "node_id", nodeID)
+		return "", false
+	}
+
+	// Status pages are served by the node running the app, named by the page's link. Links
+	// without a node_id are for single-node setups.
+	if strings.HasPrefix(path, constants.StatusPagePath) {
+		nodeID := query.Get("node_id")
+		if nodeID == "" {
+			return r.registry.PrimaryBaseURL(), true
+		}
+		if base := r.registry.Get(nodeID); base != "" {
+			return base, true
+		}
+		r.logger.Warn("router: status page node is unreachable", "node_id", nodeID)
+		return "", false
+	}
+
 	// POST /api/apps: node_id in body
 	if req.Method == http.MethodPost && path == "/api/apps" {
 		nodeID, err := r.nodeIDFromCreateAppBody(req)
@@ -90,6 +132,23 @@ func (r *Router) Target(req *http.Request) (baseURL string, ok bool) {
 	return r.registry.PrimaryBaseURL(), true
 }
 
+// OfflineNodeID returns the node_id of a node-routed request whose target node is known but not
+// online, or "" when the request isn't node-routed or the node is reachable, unknown or lost (a
+// lost node never comes back to replay its queue)
+func (r *Router) OfflineNodeID(req *http.Request) string {
+	if r.isPrimaryOnly(req.URL.Path, req.Method) || !r.requiresNodeID(req.URL.Path) {
+		return ""
+	}
+	nodeID := req.URL.Query().Get("node_id")
+	if nodeID == "" || r.registry.Get(nodeID) != "" {
+		return ""
+	}
+	if entry := r.registry.GetEntry(nodeID); entry != nil && entry.Status != constants.NodeStatusOnline && entry.Status != constants.NodeStatusLost {
+		return nodeID
+	}
+	return ""
+}
+
 func (r *Router) isPrimaryOnly(path, method string) bool {
 	switch {
 	case strings.HasPrefix(path, "/auth/"):
@@ -101,7 +160,7 @@ func (r *Router) isPrimaryOnly(path, method string) bool {
 		return true
 	case path == "/api/nodes" || strings.HasPrefix(path, "/api/nodes/"):
 		return true
-	case path == "/api/settings":
+	case path == "/api/settings" || strings.HasPrefix(path, "/api/settings/"):
 		return true
 	case path == "/api/me":
 		return true
@@ -111,10 +170,24 @@ func (r *Router) isPrimaryOnly(path, method string) bool {
 		return true
 	case method == http.MethodGet && path == "/api/tunnels":
 		return true
+	case method == http.MethodDelete && strings.HasPrefix(path, "/api/tunnels/") && !strings.HasPrefix(path, "/api/tunnels/apps/"):
+		return true
 	case strings.HasPrefix(path, "/api/tunnels/providers"):
 		return true
 	case method == http.MethodGet && path == "/api/system/stats":
 		return true
+	case method == http.MethodGet && path == "/api/logs/search":
+		return true
+	case method == http.MethodGet && path == "/api/jobs":
+		return true
+	case path == "/api/apply":
+		return true
+	case strings.HasPrefix(path, "/api/gateway/"):
+		return true
+	case path == "/api/quotas" || strings.HasPrefix(path, "/api/quotas/"):
+		return true
+	case path == "/api/users" || strings.HasPrefix(path, "/api/users/") || strings.HasPrefix(path, constants.InvitePath):
+		return true
 	default:
 		return false
 	}
@@ -126,7 +199,7 @@ func (r *Router) requiresNodeID(path string) bool {
 		rest := strings.TrimPrefix(path, "/api/apps/")
 		return rest != ""
 	}
-	if strings.HasPrefix(path, "/api/tunnels/apps/") {
+	if strings.HasPrefix(path, "/api/tunnels/apps/") || path == "/api/tunnels/import" {
 		return true
 	}
 	if strings.HasPrefix(path, "/api/system/containers/") {
@@ -136,9 +209,42 @@ func (r *Router) requiresNodeID(path string) bool {
 	if strings.HasPrefix(path, "/api/jobs/") {
 		return true
 	}
+	// Port reservations are per node; one made on the wrong node would guard nothing
+	if path == "/api/ports" || strings.HasPrefix(path, "/api/ports/") {
+		return true
+	}
 	return false
 }
 
+// isForceDeleteApp reports whether req is DELETE /api/apps/:id?force=true
+func isForceDeleteApp(req *http.Request) bool {
+	if req.Method != http.MethodDelete || req.URL.Query().Get("force") != "true" {
+		return false
+	}
+	rest := strings.TrimPrefix(req.URL.Path, "/api/apps/")
+	return rest != req.URL.Path && rest != "" && !strings.Contains(rest, "/")
+}
+
+// VersionSkewedNode returns the target of a node-routed write when the primary reports that node's
+// version as incompatible, or nil when the write can go ahead. Reads are never blocked, so an
+// operator can still inspect the node before upgrading it.
+func (r *Router) VersionSkewedNode(req *http.Request) *NodeEntry {
+	if !isMutatingMethod(req.Method) || r.isPrimaryOnly(req.URL.Path, req.Method) {
+		return nil
+	}
+	nodeID := req.URL.Query().Get("node_id")
+	if nodeID == "" && req.Method == http.MethodPost && req.URL.Path == "/api/apps" {
+		nodeID, _ = r.nodeIDFromCreateAppBody(req)
+	}
+	if nodeID == "" {
+		return nil
+	}
+	if entry := r.registry.GetEntry(nodeID); entry != nil && !entry.IsPrimary && entry.VersionWarning != "" {
+		return entry
+	}
+	return nil
+}
+
 // createAppBody is a minimal struct to read node_id from POST /api/apps
 type createAppBody struct {
 	NodeID string `json:"node_id"`
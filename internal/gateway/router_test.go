@@ -242,6 +242,196 @@ func TestRouter_Target_ResourceByID(t *testing.T) {
 	}
 }
 
+func TestRouter_OfflineNodeID(t *testing.T) {
+	router, _ := setupTestRouter(t)
+
+	tests := []struct {
+		name string
+		url  string
+		want string
+	}{
+		{"offline node", "/api/apps/app-123/start?node_id=offline-node", "offline-node"},
+		{"unreachable node", "/api/tunnels/apps/app-123?node_id=unreachable-node", "unreachable-node"},
+		{"online node", "/api/apps/app-123/start?node_id=online-node", ""},
+		{"unknown node", "/api/apps/app-123/start?node_id=non-existent", ""},
+		{"missing node_id", "/api/apps/app-123/start", ""},
+		{"primary-only route", "/api/nodes/offline-node?node_id=offline-node", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, tt.url, nil)
+			if got := router.OfflineNodeID(req); got != tt.want {
+				t.Errorf("OfflineNodeID() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRouter_VersionSkewedNode(t *testing.T) {
+	router, registry := setupTestRouter(t)
+	registry.mu.Lock()
+	registry.nodes["old-node"] = NodeEntry{
+		ID:             "old-node",
+		APIEndpoint:    "http://old:8086",
+		Status:         constants.NodeStatusOnline,
+		VersionWarning: "node has not reported its version",
+	}
+	registry.mu.Unlock()
+
+	tests := []struct {
+		name   string
+		method string
+		url    string
+		body   string
+		want   bool
+	}{
+		{"write to skewed node", http.MethodPost, "/api/apps/app-123/start?node_id=old-node", "", true},
+		{"create app on skewed node", http.MethodPost, "/api/apps", `{"name":"a","node_id":"old-node"}`, true},
+		{"read from skewed node", http.MethodGet, "/api/apps/app-123?node_id=old-node", "", false},
+		{"write to compatible node", http.MethodPost, "/api/apps/app-123/start?node_id=online-node", "", false},
+		{"primary-only route", http.MethodDelete, "/api/nodes/old-node?node_id=old-node", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.url, bytes.NewBufferString(tt.body))
+			if got := router.VersionSkewedNode(req) != nil; got != tt.want {
+				t.Errorf("VersionSkewedNode() blocked = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRouter_Target_ForceDeleteApp(t *testing.T) {
+	router, _ := setupTestRouter(t)
+
+	tests := []struct {
+		name       string
+		method     string
+		url        string
+		wantTarget string
+		wantOK     bool
+	}{
+		{"force delete on offline node goes to primary", http.MethodDelete, "/api/apps/app-123?node_id=offline-node&force=true", "http://primary:8082", true},
+		{"force delete on online node goes to the node", http.MethodDelete, "/api/apps/app-123?node_id=online-node&force=true", "http://online:8083", true},
+		{"plain delete on offline node is unresolved", http.MethodDelete, "/api/apps/app-123?node_id=offline-node", "", false},
+		{"force on a sub-resource is unresolved", http.MethodDelete, "/api/apps/app-123/schedule?node_id=offline-node&force=true", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.url, nil)
+			target, ok := router.Target(req)
+			if target != tt.wantTarget || ok != tt.wantOK {
+				t.Errorf("Target() = (%q, %v), want (%q, %v)", target, ok, tt.wantTarget, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestRouter_Target_Approvals(t *testing.T) {
+	router, _ := setupTestRouter(t)
+
+	tests := []struct {
+		name       string
+		method     string
+		url        string
+		wantTarget string
+	}{
+		{"list without node_id goes to primary", http.MethodGet, "/api/approvals", "http://primary:8082"},
+		{"confirm goes to the node", http.MethodPost, "/api/approvals/ap-1/confirm?node_id=online-node", "http://online:8083"},
+		{"offline node falls back to primary", http.MethodPost, "/api/approvals/ap-1/confirm?node_id=offline-node", "http://primary:8082"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.url, nil)
+			target, ok := router.Target(req)
+			if target != tt.wantTarget || !ok {
+				t.Errorf("Target() = (%q, %v), want (%q, true)", target, ok, tt.wantTarget)
+			}
+		})
+	}
+}
+
+func TestRouter_Target_Events(t *testing.T) {
+	router, _ := setupTestRouter(t)
+
+	tests := []struct {
+		name       string
+		url        string
+		wantTarget string
+		wantOK     bool
+	}{
+		{"without node_id goes to primary", "/api/events", "http://primary:8082", true},
+		{"node_id goes to the node", "/api/events?node_id=online-node&types=job", "http://online:8083", true},
+		{"offline node is refused", "/api/events?node_id=offline-node", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.url, nil)
+			target, ok := router.Target(req)
+			if target != tt.wantTarget || ok != tt.wantOK {
+				t.Errorf("Target() = (%q, %v), want (%q, %v)", target, ok, tt.wantTarget, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestRouter_Target_PortReservations(t *testing.T) {
+	router, _ := setupTestRouter(t)
+
+	tests := []struct {
+		name       string
+		method     string
+		url        string
+		wantTarget string
+		wantOK     bool
+	}{
+		{"list goes to the node", http.MethodGet, "/api/ports?node_id=online-node", "http://online:8083", true},
+		{"release goes to the node", http.MethodDelete, "/api/ports/res-1?node_id=online-node", "http://online:8083", true},
+		{"missing node_id is refused", http.MethodPost, "/api/ports", "", false},
+		{"offline node is not replaced by the primary", http.MethodPost, "/api/ports?node_id=offline-node", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.url, nil)
+			target, ok := router.Target(req)
+			if target != tt.wantTarget || ok != tt.wantOK {
+				t.Errorf("Target() = (%q, %v), want (%q, %v)", target, ok, tt.wantTarget, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestRouter_Target_StatusPage(t *testing.T) {
+	router, _ := setupTestRouter(t)
+
+	tests := []struct {
+		name       string
+		url        string
+		wantTarget string
+		wantOK     bool
+	}{
+		{"without node_id goes to primary", "/api/status/abc123", "http://primary:8082", true},
+		{"goes to the app's node", "/api/status/abc123?node_id=online-node", "http://online:8083", true},
+		{"offline node is unresolved", "/api/status/abc123?node_id=offline-node", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.url, nil)
+			target, ok := router.Target(req)
+			if target != tt.wantTarget || ok != tt.wantOK {
+				t.Errorf("Target() = (%q, %v), want (%q, %v)", target, ok, tt.wantTarget, tt.wantOK)
+			}
+		})
+	}
+}
+
 func TestRouter_Target_CreateApp(t *testing.T) {
 	router, _ := setupTestRouter(t)
 
@@ -327,6 +517,8 @@ func TestRouter_isPrimaryOnly(t *testing.T) {
 		{"nodes list", "/api/nodes", http.MethodGet, true},
 		{"node detail", "/api/nodes/123", http.MethodGet, true},
 		{"settings", "/api/settings", http.MethodGet, true},
+		{"settings telemetry preview", "/api/settings/telemetry/preview", http.MethodGet, true},
+		{"settings section PUT", "/api/settings/jobs", http.MethodPut, true},
 		{"me", "/api/me", http.MethodGet, true},
 		{"node info", "/api/node/info", http.MethodGet, true},
 		{"apps list GET", "/api/apps", http.MethodGet, true},
@@ -334,8 +526,21 @@ func TestRouter_isPrimaryOnly(t *testing.T) {
 		{"tunnels list GET", "/api/tunnels", http.MethodGet, true},
 		{"tunnels list POST", "/api/tunnels", http.MethodPost, false},
 		{"tunnel providers", "/api/tunnels/providers", http.MethodGet, true},
+		{"provider zones", "/api/tunnels/providers/cloudflare/zones/zone-1/hostnames", http.MethodGet, true},
+		{"orphaned tunnel delete", "/api/tunnels/abc-123", http.MethodDelete, true},
+		{"apply", "/api/apply", http.MethodPost, true},
+		{"gateway tokens", "/api/gateway/tokens/gwt-1/rotate", http.MethodPost, true},
+		{"quotas", "/api/quotas/alice", http.MethodPut, true},
+		{"quota usage", "/api/quotas/usage", http.MethodGet, true},
+		{"users", "/api/users/invite", http.MethodPost, true},
+		{"invite link", "/api/invite/abc123", http.MethodGet, true},
+		{"app tunnel delete", "/api/tunnels/apps/app-123", http.MethodDelete, false},
+		{"tunnel import", "/api/tunnels/import", http.MethodPost, false},
 		{"system stats GET", "/api/system/stats", http.MethodGet, true},
 		{"system stats POST", "/api/system/stats", http.MethodPost, false},
+		{"logs search GET", "/api/logs/search", http.MethodGet, true},
+		{"job list GET", "/api/jobs", http.MethodGet, true},
+		{"job detail", "/api/jobs/job-123", http.MethodGet, false},
 		{"app detail", "/api/apps/123", http.MethodGet, false},
 		{"other path", "/api/other", http.MethodGet, false},
 	}
@@ -362,6 +567,7 @@ func TestRouter_requiresNodeID(t *testing.T) {
 		{"app detail with subpath", "/api/apps/123/logs", true},
 		{"app list", "/api/apps", false},
 		{"tunnel app", "/api/tunnels/apps/123", true},
+		{"tunnel import", "/api/tunnels/import", true},
 		{"container", "/api/system/containers/123", true},
 		{"other path", "/api/other", false},
 	}
@@ -0,0 +1,91 @@
+// Package gatewaytoken implements the short-lived tokens the primary issues to the gateway for
+// fetching the node registry, in place of the static GATEWAY_API_KEY.
+//
+// A token is "gwt.<id>.<issued unix>.<expires unix>.<signature>", where the signature is the hex
+// HMAC-SHA256 of everything before it, keyed with the primary's signing key. The times can be
+// read without the key, so the gateway knows when to rotate; only the primary can verify a token,
+// and it also checks the id against its records so a token can be revoked before it expires.
+package gatewaytoken
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Header carries the token on requests from the gateway to the primary
+const Header = "X-Gateway-Token"
+
+const prefix = "gwt"
+
+var (
+	ErrMalformed        = errors.New("malformed gateway token")
+	ErrInvalidSignature = errors.New("invalid gateway token signature")
+	ErrExpired          = errors.New("gateway token expired")
+)
+
+// Claims is what a token says about itself
+type Claims struct {
+	ID        string
+	IssuedAt  time.Time
+	ExpiresAt time.Time
+}
+
+// RotateAt returns when a token is due for rotation: half way through its lifetime
+func (c *Claims) RotateAt() time.Time {
+	return c.IssuedAt.Add(c.ExpiresAt.Sub(c.IssuedAt) / 2)
+}
+
+// Sign returns the token for claims, signed with key
+func Sign(key string, claims Claims) string {
+	payload := strings.Join([]string{
+		prefix,
+		claims.ID,
+		strconv.FormatInt(claims.IssuedAt.Unix(), 10),
+		strconv.FormatInt(claims.ExpiresAt.Unix(), 10),
+	}, ".")
+	return payload + "." + signature(key, payload)
+}
+
+// Parse reads a token's claims without checking its signature or expiry
+func Parse(token string) (*Claims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 5 || parts[0] != prefix || parts[1] == "" {
+		return nil, ErrMalformed
+	}
+	issued, err := strconv.ParseInt(parts[2], 10, 64)
+	if err != nil {
+		return nil, ErrMalformed
+	}
+	expires, err := strconv.ParseInt(parts[3], 10, 64)
+	if err != nil || expires <= issued {
+		return nil, ErrMalformed
+	}
+	return &Claims{ID: parts[1], IssuedAt: time.Unix(issued, 0), ExpiresAt: time.Unix(expires, 0)}, nil
+}
+
+// Verify checks a token's signature against key and that it hasn't expired by now
+func Verify(key, token string, now time.Time) (*Claims, error) {
+	claims, err := Parse(token)
+	if err != nil {
+		return nil, err
+	}
+	i := strings.LastIndexByte(token, '.')
+	if !hmac.Equal([]byte(signature(key, token[:i])), []byte(token[i+1:])) {
+		return nil, ErrInvalidSignature
+	}
+	if !now.Before(claims.ExpiresAt) {
+		return nil, ErrExpired
+	}
+	return claims, nil
+}
+
+func signature(key, payload string) string {
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write([]byte(payload))
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,44 @@
+package gatewaytoken
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSignAndVerify(t *testing.T) {
+	issued := time.Unix(1_700_000_000, 0)
+	claims := Claims{ID: "token-1", IssuedAt: issued, ExpiresAt: issued.Add(24 * time.Hour)}
+	token := Sign("key", claims)
+
+	got, err := Verify("key", token, issued.Add(time.Hour))
+	if err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	if got.ID != "token-1" || !got.ExpiresAt.Equal(claims.ExpiresAt) {
+		t.Errorf("unexpected claims %+v", got)
+	}
+	if want := issued.Add(12 * time.Hour); !got.RotateAt().Equal(want) {
+		t.Errorf("RotateAt = %v, want %v", got.RotateAt(), want)
+	}
+
+	if _, err := Verify("other-key", token, issued); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("expected invalid signature with another key, got %v", err)
+	}
+	if _, err := Verify("key", token, claims.ExpiresAt); !errors.Is(err, ErrExpired) {
+		t.Errorf("expected expired token, got %v", err)
+	}
+
+	// Extending the expiry invalidates the signature
+	forged := strings.Replace(token, ".1700086400.", ".1900000000.", 1)
+	if _, err := Verify("key", forged, issued); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("expected invalid signature for a forged expiry, got %v", err)
+	}
+
+	for _, malformed := range []string{"", "gwt.a.b.c.d", "jwt.id.1.2.sig", "gwt..1.2.sig", "gwt.id.5.2.sig"} {
+		if _, err := Parse(malformed); !errors.Is(err, ErrMalformed) {
+			t.Errorf("Parse(%q): expected malformed, got %v", malformed, err)
+		}
+	}
+}
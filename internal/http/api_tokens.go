@@ -0,0 +1,90 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/selfhostly/internal/domain"
+)
+
+// apiTokenUser is whose API tokens a request manages: the signed-in user, or nobody in particular
+// while auth is disabled
+func apiTokenUser(c *gin.Context) string {
+	if user, ok := getUserFromContext(c); ok {
+		return user.Name
+	}
+	return ""
+}
+
+// listAPITokens returns the user's API tokens, without the tokens themselves
+func (s *Server) listAPITokens(c *gin.Context) {
+	tokens, err := s.apiTokens.ListTokens(c.Request.Context(), apiTokenUser(c))
+	if err != nil {
+		s.handleServiceError(c, "list API tokens", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, tokens)
+}
+
+// createAPIToken creates an API token; the response carries the token, which isn't shown again
+func (s *Server) createAPIToken(c *gin.Context) {
+	var req domain.CreateAPITokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Invalid request format", Details: "name is required"})
+		return
+	}
+
+	issued, err := s.apiTokens.CreateToken(c.Request.Context(), apiTokenUser(c), req)
+	if err != nil {
+		s.handleServiceError(c, "create API token", err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, issued)
+}
+
+// updateAPIToken renames one of the user's API tokens
+func (s *Server) updateAPIToken(c *gin.Context) {
+	var req domain.UpdateAPITokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Invalid request format", Details: "name is required"})
+		return
+	}
+
+	token, err := s.apiTokens.UpdateToken(c.Request.Context(), apiTokenUser(c), c.Param("id"), req)
+	if err != nil {
+		s.handleServiceError(c, "update API token", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, token)
+}
+
+// revokeAPIToken revokes one of the user's API tokens
+func (s *Server) revokeAPIToken(c *gin.Context) {
+	if err := s.apiTokens.RevokeToken(c.Request.Context(), apiTokenUser(c), c.Param("id")); err != nil {
+		s.handleServiceError(c, "revoke API token", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "API token revoked"})
+}
+
+// verifyAPIToken checks an API token presented to another node, which sends it with its node
+// credentials; tokens are only stored on the primary
+func (s *Server) verifyAPIToken(c *gin.Context) {
+	var req domain.VerifyAPITokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Invalid request format", Details: "token is required"})
+		return
+	}
+
+	verified, err := s.apiTokens.VerifyToken(c.Request.Context(), req.Token)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "invalid API token", Details: err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, verified)
+}
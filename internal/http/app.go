@@ -1,16 +1,24 @@
 package http
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log/slog"
 	"net/http"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/selfhostly/internal/constants"
 	"github.com/selfhostly/internal/db"
 	"github.com/selfhostly/internal/domain"
 	"github.com/selfhostly/internal/httputil"
+	"github.com/selfhostly/internal/selector"
+	"github.com/selfhostly/internal/timeouts"
 )
 
 // ErrorResponse represents a standardized error response
@@ -36,6 +44,31 @@ func (s *Server) handleServiceError(c *gin.Context, operation string, err error)
 		return
 	}
 
+	if domain.IsConflictError(err) {
+		c.JSON(http.StatusConflict, ErrorResponse{Error: "Conflicting operation in progress", Details: detailForError(err)})
+		return
+	}
+
+	if domain.IsPolicyApprovalRequiredError(err) {
+		c.JSON(http.StatusForbidden, ErrorResponse{Error: "Admin approval required", Details: detailForError(err)})
+		return
+	}
+
+	if domain.IsQuotaExceededError(err) {
+		c.JSON(http.StatusForbidden, ErrorResponse{Error: "Quota exceeded", Details: detailForError(err)})
+		return
+	}
+
+	if domain.IsOperationsLockedError(err) {
+		c.JSON(http.StatusLocked, ErrorResponse{Error: "Operations are locked", Details: detailForError(err)})
+		return
+	}
+
+	if domain.IsInsufficientStorageError(err) {
+		c.JSON(http.StatusInsufficientStorage, ErrorResponse{Error: "Insufficient disk space", Details: detailForError(err)})
+		return
+	}
+
 	slog.ErrorContext(c.Request.Context(), "service error", "operation", operation, "error", err)
 	c.JSON(http.StatusInternalServerError, ErrorResponse{Error: fmt.Sprintf("Failed to %s", operation), Details: detailForError(err)})
 }
@@ -60,6 +93,21 @@ func getNodeIDFromContext(c *gin.Context) string {
 	return ""
 }
 
+// allowPolicyApproval refuses approve_policies unless a signed-in admin sent them: compose policies
+// can't be approved with an API token, by a viewer or with node or gateway credentials alone.
+// Returns false when the request was refused.
+func allowPolicyApproval(c *gin.Context, policies []string) bool {
+	if len(policies) == 0 {
+		return true
+	}
+	if _, viaToken := c.Get("api_token_id"); viaToken {
+		c.JSON(http.StatusForbidden, ErrorResponse{Error: "admin required", Details: "compose policies can't be approved with an API token; sign in as an admin"})
+		return false
+	}
+	_, ok := requireAdminUser(c)
+	return ok
+}
+
 // createApp creates a new app
 func (s *Server) createApp(c *gin.Context) {
 	var req domain.CreateAppRequest
@@ -72,6 +120,12 @@ func (s *Server) createApp(c *gin.Context) {
 	if nodeID := getNodeIDFromContext(c); nodeID != "" {
 		req.NodeID = nodeID
 	}
+	// The signed-in user, or for node requests the user the primary forwarded the request for
+	user, _ := getUserFromContext(c)
+	req.Owner = user.Name
+	if !allowPolicyApproval(c, req.ApprovePolicies) {
+		return
+	}
 	// Validate Quick Tunnel params when tunnel_mode is "quick"
 	if req.TunnelMode == constants.TunnelModeQuick {
 		if strings.TrimSpace(req.QuickTunnelService) == "" {
@@ -84,6 +138,23 @@ func (s *Server) createApp(c *gin.Context) {
 		}
 	}
 
+	// ?async=true queues the creation as a job on the app's node and returns right away
+	if c.Query("async") == "true" {
+		job, err := s.appService.CreateAppAsync(c.Request.Context(), req)
+		if err != nil {
+			s.handleServiceError(c, "create app job", err)
+			return
+		}
+
+		c.JSON(http.StatusAccepted, gin.H{
+			"job_id":  job.ID,
+			"app_id":  job.AppID,
+			"status":  job.Status,
+			"message": "App creation started in background",
+		})
+		return
+	}
+
 	app, err := s.appService.CreateApp(c.Request.Context(), req)
 	if err != nil {
 		s.handleServiceError(c, "create app", err)
@@ -108,13 +179,42 @@ func (s *Server) getApp(c *gin.Context) {
 		return
 	}
 
-	app, err := s.appService.GetAppWithSchedule(c.Request.Context(), id, nodeID)
+	fields, ok := parseAppFields(c)
+	if !ok {
+		return
+	}
+
+	// ?reconcile=true forces the stored status to be checked against docker
+	reconcile := c.Query("reconcile") == "true"
+
+	app, err := s.appService.GetAppWithSchedule(c.Request.Context(), id, nodeID, reconcile)
 	if err != nil {
 		s.handleServiceError(c, "get app", err)
 		return
 	}
 
-	c.JSON(http.StatusOK, app)
+	if fields == nil {
+		c.JSON(http.StatusOK, app)
+		return
+	}
+	sparse, err := httputil.SelectFields(app, fields)
+	if err != nil {
+		s.handleServiceError(c, "get app", err)
+		return
+	}
+	c.JSON(http.StatusOK, sparse)
+}
+
+// parseAppFields reads ?fields=, which limits an app response to the named fields, e.g.
+// ?fields=id,name,status so a list doesn't carry every app's compose files. It answers 400 and
+// returns false when a name isn't an app field.
+func parseAppFields(c *gin.Context) ([]string, bool) {
+	fields := httputil.ParseFields(c)
+	if err := httputil.ValidateFields(db.App{}, fields); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Invalid fields", Details: err.Error()})
+		return nil, false
+	}
+	return fields, true
 }
 
 // updateApp updates an app
@@ -138,6 +238,9 @@ func (s *Server) updateApp(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Invalid request format"})
 		return
 	}
+	if !allowPolicyApproval(c, req.ApprovePolicies) {
+		return
+	}
 
 	app, err := s.appService.UpdateApp(c.Request.Context(), id, nodeID, req)
 	if err != nil {
@@ -163,15 +266,58 @@ func (s *Server) deleteApp(c *gin.Context) {
 		return
 	}
 
-	if err := s.appService.DeleteApp(c.Request.Context(), id, nodeID); err != nil {
-		s.handleServiceError(c, "delete app", err)
+	// skip takes comma-separated step IDs and may be repeated: ?skip=networks,tunnel or ?skip=networks&skip=tunnel
+	var skipSteps []string
+	for _, value := range c.QueryArray("skip") {
+		for _, step := range strings.Split(value, ",") {
+			if step = strings.TrimSpace(step); step != "" {
+				skipSteps = append(skipSteps, step)
+			}
+		}
+	}
+	opts := domain.DeleteAppOptions{
+		Archive:       c.Query("archive") == "true",
+		DryRun:        c.Query("dry_run") == "true",
+		SkipSteps:     skipSteps,
+		RemoveVolumes: c.Query("remove_volumes") == "true",
+		Force:         c.Query("force") == "true",
+	}
+	if !opts.DryRun && s.awaitApproval(c, constants.ApprovalOperationDeleteApp, id, deleteAppParams(nodeID, opts)) {
 		return
 	}
+	if err := s.runDeleteApp(c, id, nodeID, opts); err != nil {
+		s.handleServiceError(c, "delete app", err)
+	}
+}
+
+// runDeleteApp deletes an app and writes the response; errors are left to the caller
+func (s *Server) runDeleteApp(c *gin.Context, id, nodeID string, opts domain.DeleteAppOptions) error {
+	result, err := s.appService.DeleteApp(c.Request.Context(), id, nodeID, opts)
+	if err != nil {
+		return err
+	}
 
-	c.JSON(http.StatusOK, gin.H{
+	if result.DryRun {
+		c.JSON(http.StatusOK, gin.H{
+			"message": "Dry run: nothing was deleted",
+			"appID":   id,
+			"dry_run": true,
+			"steps":   result.Steps,
+		})
+		return nil
+	}
+
+	response := gin.H{
 		"message": "App deleted successfully",
 		"appID":   id,
-	})
+		"steps":   result.Steps,
+	}
+	if result.ArchivePath != "" {
+		response["archive_path"] = result.ArchivePath
+		response["archive_expires_at"] = result.ArchiveExpiresAt
+	}
+	c.JSON(http.StatusOK, response)
+	return nil
 }
 
 // startApp starts an app
@@ -238,7 +384,7 @@ func (s *Server) updateAppContainers(c *gin.Context) {
 	}
 
 	// Create background job for app update (async operation)
-	job, err := s.appService.UpdateAppContainersAsync(c.Request.Context(), id)
+	job, err := s.appService.UpdateAppContainersAsync(c.Request.Context(), id, nodeID)
 	if err != nil {
 		s.handleServiceError(c, "create update job", err)
 		return
@@ -252,6 +398,34 @@ func (s *Server) updateAppContainers(c *gin.Context) {
 	})
 }
 
+// runAppCommand runs a one-off command in one of the app's services as a background job; the job's
+// result holds the command's exit code and output
+func (s *Server) runAppCommand(c *gin.Context) {
+	id := c.Param("id")
+	if id == "" {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Invalid app ID"})
+		return
+	}
+
+	var req domain.RunAppCommandRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Invalid request format", Details: err.Error()})
+		return
+	}
+
+	job, err := s.appService.RunAppCommandAsync(c.Request.Context(), id, req)
+	if err != nil {
+		s.handleServiceError(c, "create run job", err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"job_id":  job.ID,
+		"status":  job.Status,
+		"message": "Command started in background",
+	})
+}
+
 // getAppLogs returns app logs
 func (s *Server) getAppLogs(c *gin.Context) {
 	id := c.Param("id")
@@ -304,6 +478,40 @@ func (s *Server) getAppServices(c *gin.Context) {
 	c.JSON(http.StatusOK, services)
 }
 
+// getAppContainerHealth returns the exit codes and restart counts of an app's containers
+func (s *Server) getAppContainerHealth(c *gin.Context) {
+	id := c.Param("id")
+	if id == "" {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Invalid app ID"})
+		return
+	}
+
+	health, err := s.crashMonitor.GetAppContainerHealth(c.Request.Context(), id)
+	if err != nil {
+		s.handleServiceError(c, "get app container health", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, health)
+}
+
+// getAppIcon serves the app's icon, detected from its public URL or named by a compose label;
+// ?refresh=true looks for it again. Browsers may cache it for a day.
+func (s *Server) getAppIcon(c *gin.Context) {
+	icon, err := s.appIcons.GetAppIcon(c.Request.Context(), c.Param("id"), c.Query("refresh") == "true")
+	if err != nil {
+		s.handleServiceError(c, "get app icon", err)
+		return
+	}
+
+	// Icons come from the apps, so an SVG's scripts must never run on this origin
+	c.Header("Content-Security-Policy", "default-src 'none'; style-src 'unsafe-inline'; sandbox")
+	c.Header("Cache-Control", "private, max-age=86400")
+	c.Header("Pragma", "")
+	c.Header("Expires", "")
+	c.Data(http.StatusOK, icon.ContentType, icon.Data)
+}
+
 // restartAppService restarts a specific service within an app
 func (s *Server) restartAppService(c *gin.Context) {
 	id := c.Param("id")
@@ -360,6 +568,64 @@ func (s *Server) getAppStats(c *gin.Context) {
 	c.JSON(http.StatusOK, stats)
 }
 
+// streamAppStats sends an app's resource usage as server-sent "stats" events every ?interval=
+// seconds (default 2), so live charts don't poll. The stream closes after the stream timeout;
+// EventSource clients reconnect by themselves.
+func (s *Server) streamAppStats(c *gin.Context) {
+	id := c.Param("id")
+	if id == "" {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Invalid app ID"})
+		return
+	}
+
+	// Get node_id from middleware (already validated)
+	if getNodeIDFromContext(c) == "" {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "node_id is required"})
+		return
+	}
+
+	interval := constants.StatsStreamDefaultInterval
+	if raw := c.Query("interval"); raw != "" {
+		seconds, err := strconv.Atoi(raw)
+		if err != nil || seconds < 1 || time.Duration(seconds)*time.Second > constants.StatsStreamMaxInterval {
+			c.JSON(http.StatusBadRequest, ErrorResponse{
+				Error:   "Invalid interval",
+				Details: fmt.Sprintf("interval must be between 1 and %d seconds", int(constants.StatsStreamMaxInterval.Seconds())),
+			})
+			return
+		}
+		interval = time.Duration(seconds) * time.Second
+	}
+
+	// The server's write timeout would cut the stream short, so give it the stream's own budget
+	duration := s.config.Timeouts.Load().For(timeouts.Stream)
+	ctx, cancel := context.WithTimeout(c.Request.Context(), duration)
+	defer cancel()
+	_ = http.NewResponseController(c.Writer).SetWriteDeadline(time.Now().Add(duration + 10*time.Second))
+
+	started := false
+	err := s.systemService.StreamAppStats(ctx, id, interval, func(stats *domain.AppStats) error {
+		if !started {
+			c.Header("Content-Type", "text/event-stream")
+			c.Header("Cache-Control", "no-cache")
+			c.Header("X-Accel-Buffering", "no")
+			started = true
+		}
+		c.SSEvent("stats", stats)
+		c.Writer.Flush()
+		return nil
+	})
+	if err != nil {
+		if !started {
+			s.handleServiceError(c, "stream app stats", err)
+			return
+		}
+		slog.WarnContext(ctx, "app stats stream ended with error", "appID", id, "error", err)
+		c.SSEvent("error", ErrorResponse{Error: "Stats stream failed", Details: detailForError(err)})
+		c.Writer.Flush()
+	}
+}
+
 // getQuickTunnelURL runs Quick Tunnel URL extraction on the node that hosts the app and returns the URL.
 func (s *Server) getQuickTunnelURL(c *gin.Context) {
 	id := c.Param("id")
@@ -415,7 +681,7 @@ func (s *Server) createQuickTunnelForApp(c *gin.Context) {
 	}
 
 	// Create background job for Quick Tunnel creation (async operation)
-	job, err := s.appService.CreateQuickTunnelForAppAsync(c.Request.Context(), id, strings.TrimSpace(req.Service), req.Port)
+	job, err := s.appService.CreateQuickTunnelForAppAsync(c.Request.Context(), id, nodeID, strings.TrimSpace(req.Service), req.Port)
 	if err != nil {
 		s.handleServiceError(c, "create quick tunnel job", err)
 		return
@@ -429,8 +695,19 @@ func (s *Server) createQuickTunnelForApp(c *gin.Context) {
 	})
 }
 
-// listApps returns all apps
+// listApps returns all apps, optionally narrowed by a label selector (?selector=env=prod,team=media).
+// Nodes that couldn't be asked are listed in the X-Node-Errors header as a JSON array; their apps
+// are left out, or served stale from the primary's inventory cache.
 func (s *Server) listApps(c *gin.Context) {
+	sel, ok := parseSelector(c)
+	if !ok {
+		return
+	}
+	fields, ok := parseAppFields(c)
+	if !ok {
+		return
+	}
+
 	var nodeIDs []string
 	if scope, ok := c.Get("request_scope"); ok && scope == "local" {
 		nodeIDs = []string{s.config.Node.ID}
@@ -439,18 +716,87 @@ func (s *Server) listApps(c *gin.Context) {
 	}
 
 	// Include schedules in the response for better UX
-	apps, err := s.appService.ListAppsWithSchedules(c.Request.Context(), nodeIDs)
+	reconcile := c.Query("reconcile") == "true"
+	apps, nodeErrors, err := s.appService.ListAppsWithSchedules(c.Request.Context(), nodeIDs, reconcile)
 	if err != nil {
 		s.handleServiceError(c, "list apps", err)
 		return
 	}
+	if len(nodeErrors) > 0 {
+		if header, err := json.Marshal(nodeErrors); err == nil {
+			c.Header("X-Node-Errors", string(header))
+		}
+	}
+
+	if len(sel) > 0 {
+		matched := make([]*db.App, 0, len(apps))
+		for _, app := range apps {
+			if sel.Matches(app.Labels) {
+				matched = append(matched, app)
+			}
+		}
+		apps = matched
+	}
+
+	if fields == nil {
+		c.JSON(http.StatusOK, apps)
+		return
+	}
+	sparse := make([]map[string]json.RawMessage, 0, len(apps))
+	for _, app := range apps {
+		selected, err := httputil.SelectFields(app, fields)
+		if err != nil {
+			s.handleServiceError(c, "list apps", err)
+			return
+		}
+		sparse = append(sparse, selected)
+	}
+	c.JSON(http.StatusOK, sparse)
+}
 
-	c.JSON(http.StatusOK, apps)
+// parseSelector reads the ?selector= label selector, answering 400 when it doesn't parse
+func parseSelector(c *gin.Context) (selector.Selector, bool) {
+	sel, err := selector.Parse(c.Query("selector"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Invalid selector", Details: err.Error()})
+		return nil, false
+	}
+	return sel, true
+}
+
+// selectedAppIDs returns the IDs of the apps on nodeIDs whose labels match sel.
+// Lists of app-owned items (jobs, tunnels) use it to honor ?selector=.
+func (s *Server) selectedAppIDs(ctx context.Context, sel selector.Selector, nodeIDs []string) (map[string]bool, error) {
+	apps, err := s.appService.ListApps(ctx, nodeIDs)
+	if err != nil {
+		return nil, err
+	}
+	ids := make(map[string]bool)
+	for _, app := range apps {
+		if sel.Matches(app.Labels) {
+			ids[app.ID] = true
+		}
+	}
+	return ids, nil
+}
+
+// refuseSelector rejects ?selector= on lists whose items carry no labels,
+// rather than silently returning everything.
+func refuseSelector(c *gin.Context) {
+	if _, ok := c.GetQuery("selector"); ok {
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid selector",
+			Details: "label selectors are only supported on apps, jobs and tunnels",
+		})
+	}
 }
 
 // RollbackRequest represents a rollback request with optional metadata
 type RollbackRequest struct {
 	ChangeReason *string `json:"change_reason"`
+	// RestartContainers recreates containers from the restored version via an app_update job.
+	// Defaults to true; send false to only restore the compose files.
+	RestartContainers *bool `json:"restart_containers"`
 }
 
 // getComposeVersions returns all compose versions for an app
@@ -477,6 +823,64 @@ func (s *Server) getComposeVersions(c *gin.Context) {
 	c.JSON(http.StatusOK, versions)
 }
 
+// getComposeIntegrity reports whether an app's docker-compose.yml was edited on disk outside of its compose versions
+func (s *Server) getComposeIntegrity(c *gin.Context) {
+	id, err := httputil.ValidateAndGetAppID(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Invalid app ID", Details: domain.PublicMessage(err)})
+		return
+	}
+
+	integrity, err := s.composeService.CheckIntegrity(c.Request.Context(), id, getNodeIDFromContext(c))
+	if err != nil {
+		s.handleServiceError(c, "check compose integrity", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, integrity)
+}
+
+// restoreComposeFile rewrites an app's edited docker-compose.yml from its current compose version
+func (s *Server) restoreComposeFile(c *gin.Context) {
+	id, err := httputil.ValidateAndGetAppID(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Invalid app ID", Details: domain.PublicMessage(err)})
+		return
+	}
+
+	integrity, err := s.composeService.RestoreComposeFile(c.Request.Context(), id, getNodeIDFromContext(c))
+	if err != nil {
+		s.handleServiceError(c, "restore compose file", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, integrity)
+}
+
+// previewCompose returns the compose an app would be deployed with, with variables substituted
+// and the tunnel sidecar merged in. An optional body previews unsaved compose content.
+func (s *Server) previewCompose(c *gin.Context) {
+	id, err := httputil.ValidateAndGetAppID(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Invalid app ID", Details: domain.PublicMessage(err)})
+		return
+	}
+
+	var req domain.ComposePreviewRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Invalid request format"})
+		return
+	}
+
+	preview, err := s.appService.PreviewCompose(c.Request.Context(), id, req)
+	if err != nil {
+		s.handleServiceError(c, "preview compose", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, preview)
+}
+
 // getComposeVersion returns a specific compose version
 func (s *Server) getComposeVersion(c *gin.Context) {
 	id, err := httputil.ValidateAndGetAppID(c)
@@ -548,16 +952,31 @@ func (s *Server) rollbackToVersion(c *gin.Context) {
 		return
 	}
 
+	response := gin.H{
+		"message":      "Rolled back successfully",
+		"new_version":  newVersion,
+		"from_version": targetVersion,
+	}
+
+	// Bring running containers in line with the restored version (pull + up -d)
+	if req.RestartContainers == nil || *req.RestartContainers {
+		job, err := s.appService.UpdateAppContainersAsync(c.Request.Context(), id, nodeID)
+		if err != nil {
+			slog.WarnContext(c.Request.Context(), "rolled back but failed to start container update", "appID", id, "error", err)
+			response["message"] = "Rolled back successfully, but containers could not be updated; run an update to apply it"
+		} else {
+			response["message"] = "Rolled back successfully, updating containers in background"
+			response["job_id"] = job.ID
+			response["job"] = job
+		}
+	}
+
 	// Get updated app
 	var app *db.App
 	if nodeID != "" {
 		app, _ = s.appService.GetApp(c.Request.Context(), id, nodeID)
 	}
+	response["app"] = app
 
-	c.JSON(http.StatusOK, gin.H{
-		"message":      "Rolled back successfully",
-		"app":          app,
-		"new_version":  newVersion,
-		"from_version": targetVersion,
-	})
+	c.JSON(http.StatusOK, response)
 }
@@ -0,0 +1,63 @@
+package http
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/selfhostly/internal/domain"
+	"gopkg.in/yaml.v3"
+)
+
+// applyManifest reconciles apps with a YAML or JSON manifest. With ?dry_run=true it only returns
+// the plan.
+func (s *Server) applyManifest(c *gin.Context) {
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil || len(bytes.TrimSpace(body)) == 0 {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Manifest is required"})
+		return
+	}
+
+	manifest, err := decodeManifest(body)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Invalid manifest", Details: err.Error()})
+		return
+	}
+
+	for _, app := range manifest.Apps {
+		if !allowPolicyApproval(c, app.ApprovePolicies) {
+			return
+		}
+	}
+
+	result, err := s.applyService.Apply(c.Request.Context(), *manifest, c.Query("dry_run") == "true")
+	if err != nil {
+		s.handleServiceError(c, "apply manifest", err)
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// decodeManifest reads a manifest in YAML or JSON (which is also YAML). It goes through JSON so
+// the manifest uses the same field names as the rest of the API, and unknown fields are rejected
+// rather than silently ignored.
+func decodeManifest(body []byte) (*domain.AppManifest, error) {
+	var raw interface{}
+	if err := yaml.Unmarshal(body, &raw); err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	var manifest domain.AppManifest
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&manifest); err != nil {
+		return nil, err
+	}
+	return &manifest, nil
+}
@@ -0,0 +1,148 @@
+package http
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/selfhostly/internal/constants"
+	"github.com/selfhostly/internal/domain"
+)
+
+// awaitApproval records the operation as awaiting confirmation and answers 202 with the approval
+// when APPROVAL_REQUIRED is on. It reports whether it handled the request.
+func (s *Server) awaitApproval(c *gin.Context, operation, resourceID string, params map[string]string) bool {
+	if !s.approvals.Required() {
+		return false
+	}
+	// On a secondary, requests with node credentials come from the primary, which has already had
+	// the operation approved or runs it itself, e.g. purging the apps of a node being removed
+	if _, fromNode := c.Get("node_id"); fromNode && !s.config.Node.IsPrimary {
+		return false
+	}
+	approval, err := s.approvals.Request(c.Request.Context(), operation, resourceID, params, approvalActor(c))
+	if err != nil {
+		s.handleServiceError(c, "request approval", err)
+		return true
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"message":  "Approval required: another admin must confirm this operation",
+		"approval": approval,
+	})
+	return true
+}
+
+// approvalActor names the admin requesting or deciding an approval; "" when auth is off or the
+// request came with node credentials, which counts as one and the same admin
+func approvalActor(c *gin.Context) string {
+	user, _ := getUserFromContext(c)
+	return user.Name
+}
+
+// deleteAppParams records the options of an app deletion so confirming it deletes the same way
+func deleteAppParams(nodeID string, opts domain.DeleteAppOptions) map[string]string {
+	return map[string]string{
+		"node_id":        nodeID,
+		"archive":        fmt.Sprint(opts.Archive),
+		"force":          fmt.Sprint(opts.Force),
+		"skip":           strings.Join(opts.SkipSteps, ","),
+		"remove_volumes": fmt.Sprint(opts.RemoveVolumes),
+	}
+}
+
+// deleteTunnelParams records a tunnel deletion's options on its approval
+func deleteTunnelParams(opts domain.DeleteTunnelOptions) map[string]string {
+	return map[string]string{"quick_tunnel": fmt.Sprint(opts.QuickTunnelFallback)}
+}
+
+// listApprovals returns this node's approvals, newest first (?status=pending for those waiting)
+func (s *Server) listApprovals(c *gin.Context) {
+	approvals, err := s.approvals.ListApprovals(c.Request.Context(), c.Query("status"))
+	if err != nil {
+		s.handleServiceError(c, "list approvals", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, approvals)
+}
+
+// getApproval returns one approval
+func (s *Server) getApproval(c *gin.Context) {
+	approval, err := s.approvals.GetApproval(c.Request.Context(), c.Param("approvalId"))
+	if err != nil {
+		s.handleServiceError(c, "get approval", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, approval)
+}
+
+// confirmApproval confirms a pending approval and runs the operation, answering as the original
+// request would have. A failure is recorded on the approval, which stays confirmed.
+func (s *Server) confirmApproval(c *gin.Context) {
+	ctx := c.Request.Context()
+	id := c.Param("approvalId")
+
+	approval, err := s.approvals.GetApproval(ctx, id)
+	if err != nil {
+		s.handleServiceError(c, "confirm approval", err)
+		return
+	}
+	// App changes stay blocked by the operations lock however they were requested
+	if approval.Operation == constants.ApprovalOperationDeleteApp || approval.Operation == constants.ApprovalOperationDeleteTunnel {
+		if err := s.operationsLock.CheckUnlocked(ctx); err != nil {
+			s.handleServiceError(c, "confirm approval", err)
+			return
+		}
+	}
+
+	approval, err = s.approvals.Confirm(ctx, id, approvalActor(c))
+	if err != nil {
+		s.handleServiceError(c, "confirm approval", err)
+		return
+	}
+
+	params := approval.Params
+	switch approval.Operation {
+	case constants.ApprovalOperationDeleteApp:
+		var skipSteps []string
+		if params["skip"] != "" {
+			skipSteps = strings.Split(params["skip"], ",")
+		}
+		err = s.runDeleteApp(c, approval.ResourceID, params["node_id"], domain.DeleteAppOptions{
+			Archive:       params["archive"] == "true",
+			Force:         params["force"] == "true",
+			SkipSteps:     skipSteps,
+			RemoveVolumes: params["remove_volumes"] == "true",
+		})
+	case constants.ApprovalOperationDeleteTunnel:
+		err = s.runDeleteTunnel(c, approval.ResourceID, domain.DeleteTunnelOptions{QuickTunnelFallback: params["quick_tunnel"] == "true"})
+	case constants.ApprovalOperationDeleteOrphanedTunnel:
+		err = s.runDeleteOrphanedTunnel(c, approval.ResourceID)
+	case constants.ApprovalOperationRemoveNode:
+		err = s.runRemoveNode(c, approval.ResourceID, domain.RemoveNodeRequest{
+			Strategy:     params["strategy"],
+			TargetNodeID: params["target_node_id"],
+		})
+	default:
+		err = domain.WrapValidationError("operation", fmt.Errorf("unknown operation %q", approval.Operation))
+	}
+
+	s.approvals.RecordResult(ctx, approval.ID, err)
+	if err != nil {
+		s.handleServiceError(c, "run approved "+approval.Operation, err)
+	}
+}
+
+// rejectApproval turns a pending approval down, or withdraws it when sent by the requester
+func (s *Server) rejectApproval(c *gin.Context) {
+	approval, err := s.approvals.Reject(c.Request.Context(), c.Param("approvalId"), approvalActor(c))
+	if err != nil {
+		s.handleServiceError(c, "reject approval", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, approval)
+}
@@ -0,0 +1,72 @@
+package http
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/selfhostly/internal/constants"
+	"github.com/selfhostly/internal/events"
+	"github.com/selfhostly/internal/timeouts"
+)
+
+// streamEvents sends this node's changes as server-sent events named by their type: app_status,
+// job, node_health and notification. ?types= (comma-separated) and ?app_id= narrow what is sent.
+// A "resync" event means the client fell behind and missed events, so it should refetch what it
+// shows. The stream closes after the stream timeout; EventSource clients reconnect by themselves.
+func (s *Server) streamEvents(c *gin.Context) {
+	filter := events.Filter{AppID: c.Query("app_id")}
+	if raw := c.Query("types"); raw != "" {
+		for _, eventType := range strings.Split(raw, ",") {
+			eventType = strings.TrimSpace(eventType)
+			if !slices.Contains(events.Types, eventType) {
+				c.JSON(http.StatusBadRequest, ErrorResponse{
+					Error:   "Invalid event type",
+					Details: fmt.Sprintf("types must be a comma-separated list of %s", strings.Join(events.Types, ", ")),
+				})
+				return
+			}
+			filter.Types = append(filter.Types, eventType)
+		}
+	}
+
+	// The server's write timeout would cut the stream short, so give it the stream's own budget
+	duration := s.config.Timeouts.Load().For(timeouts.Stream)
+	ctx, cancel := context.WithTimeout(c.Request.Context(), duration)
+	defer cancel()
+	_ = http.NewResponseController(c.Writer).SetWriteDeadline(time.Now().Add(duration + 10*time.Second))
+
+	sub := events.Default().Subscribe(filter)
+	defer sub.Close()
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("X-Accel-Buffering", "no")
+	c.SSEvent("ready", gin.H{"node_id": s.config.Node.ID})
+	c.Writer.Flush()
+
+	keepalive := time.NewTicker(constants.EventsKeepaliveInterval)
+	defer keepalive.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-keepalive.C:
+			_, _ = io.WriteString(c.Writer, ": keepalive\n\n")
+		case event := <-sub.Events():
+			if sub.Dropped() {
+				c.SSEvent("resync", gin.H{"node_id": s.config.Node.ID})
+			}
+			if event.NodeID == "" {
+				event.NodeID = s.config.Node.ID
+			}
+			c.SSEvent(event.Type, event)
+		}
+		c.Writer.Flush()
+	}
+}
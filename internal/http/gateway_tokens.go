@@ -0,0 +1,64 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/selfhostly/internal/domain"
+)
+
+// listGatewayTokens returns the issued gateway tokens, without the tokens themselves
+func (s *Server) listGatewayTokens(c *gin.Context) {
+	tokens, err := s.gatewayTokens.ListTokens(c.Request.Context())
+	if err != nil {
+		s.handleServiceError(c, "list gateway tokens", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, tokens)
+}
+
+// issueGatewayToken issues a token for the gateway; the response carries the token, which isn't shown again
+func (s *Server) issueGatewayToken(c *gin.Context) {
+	var req domain.IssueGatewayTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Invalid request format", Details: "name is required"})
+		return
+	}
+
+	issued, err := s.gatewayTokens.IssueToken(c.Request.Context(), req)
+	if err != nil {
+		s.handleServiceError(c, "issue gateway token", err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, issued)
+}
+
+// revokeGatewayToken revokes a gateway token before it expires
+func (s *Server) revokeGatewayToken(c *gin.Context) {
+	if err := s.gatewayTokens.RevokeToken(c.Request.Context(), c.Param("id")); err != nil {
+		s.handleServiceError(c, "revoke gateway token", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Gateway token revoked"})
+}
+
+// rotateGatewayToken issues a successor to a gateway token and revokes the old one. Only an admin,
+// or the primary itself with its node credentials, may rotate; the token can't rotate itself.
+func (s *Server) rotateGatewayToken(c *gin.Context) {
+	if nodeID, ok := c.Get("node_id"); !ok || nodeID != s.config.Node.ID {
+		if _, ok := requireAdminUser(c); !ok {
+			return
+		}
+	}
+
+	issued, err := s.gatewayTokens.RotateToken(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		s.handleServiceError(c, "rotate gateway token", err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, issued)
+}
@@ -9,6 +9,8 @@ import (
 	"github.com/selfhostly/internal/apipaths"
 	"github.com/selfhostly/internal/constants"
 	"github.com/selfhostly/internal/domain"
+	"github.com/selfhostly/internal/node"
+	"github.com/selfhostly/internal/version"
 )
 
 // sendNodeHeartbeat allows a node to announce it's online to the primary
@@ -21,7 +23,15 @@ func (s *Server) sendNodeHeartbeat(c *gin.Context) {
 		return
 	}
 
-	if err := s.nodeService.NodeHeartbeat(c.Request.Context(), nodeID); err != nil {
+	nodeVersion, apiVersion := version.FromHeaders(c.Request.Header)
+	reported := domain.NodeVersion{Version: nodeVersion, APIVersion: apiVersion}
+	// Nodes that send their clock get it compared with this one; older nodes don't send it
+	var clockSkew *time.Duration
+	if nodeTime, err := time.Parse(time.RFC3339Nano, c.GetHeader(constants.NodeTimeHeader)); err == nil {
+		skew := nodeTime.Sub(time.Now())
+		clockSkew = &skew
+	}
+	if err := s.nodeService.NodeHeartbeat(c.Request.Context(), nodeID, reported, clockSkew); err != nil {
 		c.JSON(http.StatusInternalServerError, ErrorResponse{
 			Error:   "Failed to process heartbeat",
 			Details: domain.PublicMessage(err),
@@ -29,10 +39,26 @@ func (s *Server) sendNodeHeartbeat(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"message": "Heartbeat received",
-		"nodeID":  nodeID,
-	})
+	// The primary's own versions and clock let the node spot skew from its side too, the operations
+	// lock reaches nodes that missed it being pushed, revoked sessions are refused everywhere, and
+	// users keep the role they have here
+	reply := gin.H{
+		"message":     "Heartbeat received",
+		"nodeID":      nodeID,
+		"version":     version.Get(),
+		"api_version": version.APIVersion,
+		"time":        time.Now().UTC(),
+	}
+	if lock, err := s.operationsLock.GetStatus(c.Request.Context()); err == nil {
+		reply["operations_lock"] = lock
+	}
+	if revoked, err := s.sessions.RevokedSessionIDs(c.Request.Context()); err == nil {
+		reply["revoked_sessions"] = revoked
+	}
+	if roles, err := s.users.UserRoles(c.Request.Context()); err == nil {
+		reply["user_roles"] = roles
+	}
+	c.JSON(http.StatusOK, reply)
 }
 
 // manualCheckNode triggers a manual health check on a specific node
@@ -93,9 +119,10 @@ func (s *Server) sendStartupHeartbeat() {
 		return
 	}
 
-	// Add node authentication headers
-	req.Header.Set("X-Node-ID", s.config.Node.ID)
-	req.Header.Set("X-Node-API-Key", s.config.Node.APIKey)
+	// Add node authentication and version headers
+	req.Header.Set(constants.NodeTimeHeader, time.Now().UTC().Format(time.RFC3339Nano))
+	version.SetHeaders(req.Header)
+	node.SetAuthHeaders(req, s.config.Node.ID, s.config.Node.APIKey)
 
 	resp, err := client.Do(req)
 	if err != nil {
@@ -112,3 +139,55 @@ func (s *Server) sendStartupHeartbeat() {
 		slog.Warn("heartbeat failed", "status", resp.StatusCode, "response", string(body[:n]))
 	}
 }
+
+// syncNodeAppInventory receives a secondary's app inventory push for the primary's app cache
+// Protected by node authentication middleware; a node may only push its own inventory
+func (s *Server) syncNodeAppInventory(c *gin.Context) {
+	nodeID := c.Param("id")
+	if authNodeID, ok := c.Get("node_id"); ok && authNodeID != nodeID {
+		c.JSON(http.StatusForbidden, ErrorResponse{Error: "Nodes may only sync their own app inventory"})
+		return
+	}
+
+	var req domain.AppInventorySyncRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid request body",
+			Details: domain.PublicMessage(err),
+		})
+		return
+	}
+
+	resp, err := s.nodeService.SyncAppInventory(c.Request.Context(), nodeID, req)
+	if err != nil {
+		s.handleServiceError(c, "sync app inventory", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// receiveNodeServerLogs stores a batch of server log lines shipped by a secondary
+func (s *Server) receiveNodeServerLogs(c *gin.Context) {
+	nodeID := c.Param("id")
+	if authNodeID, ok := c.Get("node_id"); ok && authNodeID != nodeID {
+		c.JSON(http.StatusForbidden, ErrorResponse{Error: "Nodes may only ship their own logs"})
+		return
+	}
+
+	var req domain.NodeLogPushRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid request body",
+			Details: domain.PublicMessage(err),
+		})
+		return
+	}
+
+	if err := s.nodeService.IngestNodeLogs(c.Request.Context(), nodeID, req); err != nil {
+		s.handleServiceError(c, "store node logs", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"stored": len(req.Entries)})
+}
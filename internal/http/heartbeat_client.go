@@ -12,6 +12,9 @@ import (
 
 	"github.com/selfhostly/internal/apipaths"
 	"github.com/selfhostly/internal/constants"
+	"github.com/selfhostly/internal/domain"
+	"github.com/selfhostly/internal/node"
+	"github.com/selfhostly/internal/version"
 )
 
 // HeartbeatClient manages continuous heartbeat with exponential backoff
@@ -24,6 +27,12 @@ type HeartbeatClient struct {
 	wasDisconnected bool
 	failureCount    int
 	onReconnect     func(context.Context) error // Callback for reconnection events
+	primaryVersion  domain.NodeVersion          // Versions the primary reported in its last heartbeat reply
+	clockSkewed     bool                        // Whether the primary's clock was off by more than the warning threshold
+
+	onOpsLock         func(context.Context, domain.OperationsLockStatus) error // Stores the primary's operations lock
+	onRevokedSessions func(context.Context, []string) error                    // Refuses sessions revoked on the primary
+	onUserRoles       func(context.Context, map[string]string) error           // Stores the primary's users
 }
 
 // Config holds heartbeat configuration
@@ -36,6 +45,15 @@ type Config struct {
 	MaxRetries        int
 	HeartbeatInterval time.Duration
 	OnReconnect       func(context.Context) error // Callback for reconnection events
+
+	// OnOperationsLock is called with the operations lock state from each heartbeat reply
+	OnOperationsLock func(context.Context, domain.OperationsLockStatus) error
+
+	// OnRevokedSessions is called with the sessions revoked on the primary from each heartbeat reply
+	OnRevokedSessions func(context.Context, []string) error
+
+	// OnUserRoles is called with the role of every user of the primary from each heartbeat reply
+	OnUserRoles func(context.Context, map[string]string) error
 }
 
 // NewHeartbeatClient creates a new heartbeat client
@@ -58,6 +76,10 @@ func NewHeartbeatClient(config *Config) *HeartbeatClient {
 		client:      &http.Client{Timeout: 10 * time.Second},
 		stopCh:      make(chan struct{}),
 		onReconnect: config.OnReconnect,
+		onOpsLock:   config.OnOperationsLock,
+
+		onRevokedSessions: config.OnRevokedSessions,
+		onUserRoles:       config.OnUserRoles,
 	}
 }
 
@@ -194,10 +216,12 @@ func (h *HeartbeatClient) sendHeartbeat() error {
 		return fmt.Errorf("failed to create request: %w", err)
 	}
 
-	// Add node authentication headers
-	req.Header.Set("X-Node-ID", h.config.NodeID)
-	req.Header.Set("X-Node-API-Key", h.config.NodeAPIKey)
+	// Add node authentication and version headers
+	req.Header.Set(constants.NodeTimeHeader, time.Now().UTC().Format(time.RFC3339Nano))
+	version.SetHeaders(req.Header)
+	node.SetAuthHeaders(req, h.config.NodeID, h.config.NodeAPIKey)
 
+	sentAt := time.Now()
 	resp, err := h.client.Do(req)
 	if err != nil {
 		return fmt.Errorf("failed to send heartbeat: %w", err)
@@ -212,9 +236,79 @@ func (h *HeartbeatClient) sendHeartbeat() error {
 		return fmt.Errorf("heartbeat failed with status: %d", resp.StatusCode)
 	}
 
+	var reply heartbeatReply
+	if json.NewDecoder(resp.Body).Decode(&reply) == nil {
+		h.checkPrimaryVersion(reply.NodeVersion)
+		if reply.Time != nil {
+			// The primary read its clock about halfway through the round trip
+			h.checkPrimaryClock(reply.Time.Sub(sentAt.Add(time.Since(sentAt) / 2)))
+		}
+		if reply.OperationsLock != nil && h.onOpsLock != nil {
+			if err := h.onOpsLock(context.Background(), *reply.OperationsLock); err != nil {
+				slog.Warn("failed to apply the primary's operations lock", "error", err)
+			}
+		}
+		if len(reply.RevokedSessions) > 0 && h.onRevokedSessions != nil {
+			if err := h.onRevokedSessions(context.Background(), reply.RevokedSessions); err != nil {
+				slog.Warn("failed to apply the primary's revoked sessions", "error", err)
+			}
+		}
+		// An empty list still counts: the primary has no users left
+		if reply.UserRoles != nil && h.onUserRoles != nil {
+			if err := h.onUserRoles(context.Background(), reply.UserRoles); err != nil {
+				slog.Warn("failed to apply the primary's users", "error", err)
+			}
+		}
+	}
 	return nil
 }
 
+// heartbeatReply is what the primary answers a heartbeat with; older primaries don't send the
+// operations lock, revoked sessions, users or their time
+type heartbeatReply struct {
+	domain.NodeVersion
+	OperationsLock  *domain.OperationsLockStatus `json:"operations_lock"`
+	RevokedSessions []string                     `json:"revoked_sessions"`
+	UserRoles       map[string]string            `json:"user_roles"`
+	Time            *time.Time                   `json:"time"`
+}
+
+// checkPrimaryVersion warns once each time the primary's reported version changes to one this
+// node can't safely work with
+func (h *HeartbeatClient) checkPrimaryVersion(primary domain.NodeVersion) {
+	h.mu.Lock()
+	changed := primary != h.primaryVersion
+	h.primaryVersion = primary
+	h.mu.Unlock()
+
+	if !changed {
+		return
+	}
+	if warning := version.SkewWarning(primary.Version, primary.APIVersion); warning != "" {
+		slog.Warn("primary runs an incompatible version", "primary_version", primary.Version,
+			"primary_api_version", primary.APIVersion, "version", version.Get(), "api_version", version.APIVersion, "warning", warning)
+	}
+}
+
+// checkPrimaryClock warns when the primary's clock goes further than the warning threshold from
+// this node's, and logs when it comes back. The primary measures the same skew and corrects for it.
+func (h *HeartbeatClient) checkPrimaryClock(skew time.Duration) {
+	skewed := skew.Abs() >= constants.ClockSkewWarnThreshold
+	h.mu.Lock()
+	changed := skewed != h.clockSkewed
+	h.clockSkewed = skewed
+	h.mu.Unlock()
+
+	if !changed {
+		return
+	}
+	if skewed {
+		slog.Warn("primary's clock differs from this node's; sync both with NTP", "skew", skew.Round(time.Millisecond))
+	} else {
+		slog.Info("primary's clock is back in sync with this node's", "skew", skew.Round(time.Millisecond))
+	}
+}
+
 // calculateBackoff calculates exponential backoff interval
 func (h *HeartbeatClient) calculateBackoff(failures int) time.Duration {
 	// Exponential backoff: initialInterval * 2^failures
@@ -271,6 +365,9 @@ func (s *Server) sendPeriodicHeartbeats() {
 			// Sync settings from primary node
 			return s.nodeService.SyncSettingsFromPrimary(ctx)
 		},
+		OnOperationsLock:  s.operationsLock.ApplyFromPrimary,
+		OnRevokedSessions: s.sessions.ApplyRevokedFromPrimary,
+		OnUserRoles:       s.users.ApplyRolesFromPrimary,
 	}
 
 	heartbeatClient := NewHeartbeatClient(config)
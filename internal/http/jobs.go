@@ -1,28 +1,82 @@
 package http
 
 import (
+	"encoding/json"
 	"net/http"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
 	"github.com/selfhostly/internal/db"
+	"github.com/selfhostly/internal/domain"
+	"github.com/selfhostly/internal/httputil"
 )
 
-// getJob retrieves a job by ID
+// getJob retrieves a job by ID from the node named by node_id; the primary fetches jobs of other
+// nodes from them
 func (s *Server) getJob(c *gin.Context) {
-	jobID := c.Param("id")
-
-	job, err := s.database.GetJob(jobID)
+	job, err := s.jobs.GetJob(c.Request.Context(), c.Param("id"), c.GetString("node_id_param"))
 	if err != nil {
-		c.JSON(http.StatusNotFound, ErrorResponse{
-			Error:   "Job not found",
-			Details: "Could not find job with the specified ID",
-		})
+		s.handleServiceError(c, "get job", err)
 		return
 	}
 
 	c.JSON(http.StatusOK, job)
 }
 
+// listJobs returns recent jobs of every requested node, newest first and tagged with their node.
+// Query params: node_ids, status, type, limit. Nodes that couldn't be asked are listed in the
+// X-Node-Errors header, as for the app list.
+func (s *Server) listJobs(c *gin.Context) {
+	sel, ok := parseSelector(c)
+	if !ok {
+		return
+	}
+	filter := domain.JobListFilter{Status: c.Query("status"), Type: c.Query("type")}
+	if raw := c.Query("limit"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit <= 0 {
+			c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Invalid limit", Details: "limit must be a positive number"})
+			return
+		}
+		filter.Limit = limit
+	}
+
+	var nodeIDs []string
+	if scope, ok := c.Get("request_scope"); ok && scope == "local" {
+		nodeIDs = []string{s.config.Node.ID}
+	} else {
+		nodeIDs = httputil.ParseNodeIDs(c)
+	}
+
+	jobs, nodeErrors, err := s.jobs.ListJobs(c.Request.Context(), filter, nodeIDs)
+	if err != nil {
+		s.handleServiceError(c, "list jobs", err)
+		return
+	}
+	if len(nodeErrors) > 0 {
+		if header, err := json.Marshal(nodeErrors); err == nil {
+			c.Header("X-Node-Errors", string(header))
+		}
+	}
+
+	if len(sel) > 0 {
+		appIDs, err := s.selectedAppIDs(c.Request.Context(), sel, nodeIDs)
+		if err != nil {
+			s.handleServiceError(c, "list jobs", err)
+			return
+		}
+		matched := make([]*db.Job, 0, len(jobs))
+		for _, job := range jobs {
+			if appIDs[job.AppID] {
+				matched = append(matched, job)
+			}
+		}
+		jobs = matched
+	}
+
+	c.JSON(http.StatusOK, jobs)
+}
+
 // getAppJobs retrieves recent jobs for an app
 func (s *Server) getAppJobs(c *gin.Context) {
 	appID := c.Param("id")
@@ -0,0 +1,37 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/selfhostly/internal/domain"
+)
+
+// getAppLogForwarding returns whether the app's container logs are forwarded
+func (s *Server) getAppLogForwarding(c *gin.Context) {
+	status, err := s.logForwarding.GetAppLogForwarding(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		s.handleServiceError(c, "get log forwarding", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, status)
+}
+
+// setAppLogForwarding turns log forwarding on or off for the app; a running app gets its sidecar
+// added or removed right away
+func (s *Server) setAppLogForwarding(c *gin.Context) {
+	var req domain.SetLogForwardingRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Invalid request format", Details: "enabled is required"})
+		return
+	}
+
+	status, err := s.logForwarding.SetAppLogForwarding(c.Request.Context(), c.Param("id"), *req.Enabled)
+	if err != nil {
+		s.handleServiceError(c, "set log forwarding", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, status)
+}
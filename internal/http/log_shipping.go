@@ -0,0 +1,59 @@
+package http
+
+import (
+	"log/slog"
+
+	"github.com/selfhostly/internal/constants"
+	"github.com/selfhostly/internal/logger"
+	"github.com/selfhostly/internal/logship"
+)
+
+// startLogShipping copies this node's own server logs to the configured destination until
+// shutdown. A misconfigured destination is logged and shipping stays off; the node runs as usual.
+func (s *Server) startLogShipping() {
+	cfg := s.config.LogShipping
+	if cfg.Destination == "" {
+		return
+	}
+
+	minLevel := slog.LevelInfo
+	if cfg.Level != "" {
+		l, err := logger.ParseLevel(cfg.Level)
+		if err != nil {
+			slog.Warn("invalid LOG_SHIPPING_LEVEL, shipping info and above", "error", err)
+		} else {
+			minLevel = l
+		}
+	}
+
+	var dest logship.Destination
+	switch cfg.Destination {
+	case constants.LogShippingPrimary:
+		if s.config.Node.IsPrimary || s.config.Node.PrimaryNodeURL == "" {
+			slog.Warn("LOG_SHIPPING=primary only applies to secondary nodes with PRIMARY_NODE_URL set, log shipping disabled")
+			return
+		}
+		dest = logship.DestinationFunc(s.nodeService.PushNodeLogs)
+	case constants.LogShippingLoki:
+		if cfg.LokiURL == "" {
+			slog.Warn("LOG_SHIPPING=loki needs LOG_SHIPPING_LOKI_URL, log shipping disabled")
+			return
+		}
+		dest = logship.NewLoki(cfg.LokiURL, map[string]string{
+			"job":  "selfhostly",
+			"node": s.config.Node.Name,
+		})
+	default:
+		slog.Warn("unknown LOG_SHIPPING destination, log shipping disabled", "destination", cfg.Destination)
+		return
+	}
+
+	shipper := logship.New(dest, slog.Default())
+	logger.SetSink(shipper, minLevel)
+	slog.Info("shipping server logs", "destination", cfg.Destination, "level", minLevel.String())
+
+	go func() {
+		shipper.Run(s.shutdownCtx)
+		logger.SetSink(nil, 0)
+	}()
+}
@@ -0,0 +1,50 @@
+package http
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/selfhostly/internal/domain"
+	"github.com/selfhostly/internal/httputil"
+)
+
+// searchLogs searches recent container logs across apps and nodes
+// Query params: q (required), apps (comma-separated IDs or names), since (e.g. 15m), context (lines)
+func (s *Server) searchLogs(c *gin.Context) {
+	req := domain.LogSearchRequest{
+		Query: c.Query("q"),
+		Since: c.Query("since"),
+	}
+	if apps := c.Query("apps"); apps != "" {
+		for _, a := range strings.Split(apps, ",") {
+			if a = strings.TrimSpace(a); a != "" {
+				req.Apps = append(req.Apps, a)
+			}
+		}
+	}
+	if ctxLines := c.Query("context"); ctxLines != "" {
+		n, err := strconv.Atoi(ctxLines)
+		if err != nil || n < 0 {
+			c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Invalid context value", Details: "context must be a non-negative integer"})
+			return
+		}
+		req.ContextLines = n
+	}
+
+	var nodeIDs []string
+	if scope, ok := c.Get("request_scope"); ok && scope == "local" {
+		nodeIDs = []string{s.config.Node.ID}
+	} else {
+		nodeIDs = httputil.ParseNodeIDs(c)
+	}
+
+	matches, err := s.systemService.SearchLogs(c.Request.Context(), req, nodeIDs)
+	if err != nil {
+		s.handleServiceError(c, "search logs", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, matches)
+}
@@ -1,14 +1,24 @@
 package http
 
 import (
+	"fmt"
+	"log/slog"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/selfhostly/internal/constants"
 	"github.com/selfhostly/internal/db"
 	"github.com/selfhostly/internal/domain"
+	"github.com/selfhostly/internal/service"
+	"github.com/selfhostly/internal/version"
 )
 
+// ReplicaSyncedAtHeader marks a response served from a secondary's replica of primary metadata,
+// giving the time of the last sync
+const ReplicaSyncedAtHeader = "X-Replica-Synced-At"
+
 // NodeResponse represents a node without sensitive information (API key excluded)
 type NodeResponse struct {
 	ID          string     `json:"id"`
@@ -19,6 +29,20 @@ type NodeResponse struct {
 	LastSeen    *time.Time `json:"last_seen"`
 	CreatedAt   time.Time  `json:"created_at"`
 	UpdatedAt   time.Time  `json:"updated_at"`
+
+	// Version and APIVersion are what the node last reported; VersionWarning is set when they are
+	// incompatible with this build, and the gateway then refuses writes to the node
+	Version        string `json:"version"`
+	APIVersion     int    `json:"api_version"`
+	VersionWarning string `json:"version_warning,omitempty"`
+
+	// ClockSkewMS is how far the node's clock was from the primary's at its last heartbeat (positive
+	// is ahead); ClockSkewWarning is set when that is enough to matter
+	ClockSkewMS      *int64 `json:"clock_skew_ms,omitempty"`
+	ClockSkewWarning string `json:"clock_skew_warning,omitempty"`
+
+	// ActiveAlerts are the node's metrics currently over their alert threshold (primary only)
+	ActiveAlerts []*db.NodeAlert `json:"active_alerts,omitempty"`
 }
 
 // toNodeResponse converts a db.Node to NodeResponse (excluding API key)
@@ -32,6 +56,13 @@ func toNodeResponse(node *db.Node) *NodeResponse {
 		LastSeen:    node.LastSeen,
 		CreatedAt:   node.CreatedAt,
 		UpdatedAt:   node.UpdatedAt,
+
+		Version:        node.Version,
+		APIVersion:     node.APIVersion,
+		VersionWarning: version.SkewWarning(node.Version, node.APIVersion),
+
+		ClockSkewMS:      node.ClockSkewMS,
+		ClockSkewWarning: service.ClockSkewWarning(node),
 	}
 }
 
@@ -44,8 +75,22 @@ func toNodeResponseList(nodes []*db.Node) []*NodeResponse {
 	return result
 }
 
-// listNodes returns all nodes in the cluster (API keys excluded for security)
+// listNodes returns all nodes in the cluster (API keys excluded for security). A secondary's own
+// UI gets the node list replicated from the primary, which it knows nothing else about.
 func (s *Server) listNodes(c *gin.Context) {
+	if scope, _ := c.Get("request_scope"); !s.config.Node.IsPrimary && scope != "local" {
+		replica, err := s.nodeService.ListReplicaNodes(c.Request.Context())
+		if err != nil {
+			s.handleServiceError(c, "list replicated nodes", err)
+			return
+		}
+		if replica != nil {
+			c.Header(ReplicaSyncedAtHeader, replica.SyncedAt.UTC().Format(time.RFC3339))
+			c.JSON(http.StatusOK, toNodeResponseList(replica.Nodes))
+			return
+		}
+	}
+
 	nodes, err := s.nodeService.ListNodes(c.Request.Context())
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, ErrorResponse{
@@ -56,7 +101,19 @@ func (s *Server) listNodes(c *gin.Context) {
 	}
 
 	// Convert to response format without API keys
-	c.JSON(http.StatusOK, toNodeResponseList(nodes))
+	responses := toNodeResponseList(nodes)
+	alerts, err := s.metricsService.ListNodeAlerts(c.Request.Context(), "", true)
+	if err != nil {
+		slog.WarnContext(c.Request.Context(), "failed to load active node alerts", "error", err)
+	}
+	for _, alert := range alerts {
+		for _, node := range responses {
+			if node.ID == alert.NodeID {
+				node.ActiveAlerts = append(node.ActiveAlerts, alert)
+			}
+		}
+	}
+	c.JSON(http.StatusOK, responses)
 }
 
 // registerNode registers a new node in the cluster (API key excluded from response for security)
@@ -97,7 +154,11 @@ func (s *Server) getNode(c *gin.Context) {
 	}
 
 	// Return response without API key
-	c.JSON(http.StatusOK, toNodeResponse(node))
+	response := toNodeResponse(node)
+	if response.ActiveAlerts, err = s.metricsService.ListNodeAlerts(c.Request.Context(), nodeID, true); err != nil {
+		slog.WarnContext(c.Request.Context(), "failed to load active node alerts", "nodeID", nodeID, "error", err)
+	}
+	c.JSON(http.StatusOK, response)
 }
 
 // updateNode updates a node's information (API key excluded from response for security)
@@ -126,23 +187,48 @@ func (s *Server) updateNode(c *gin.Context) {
 	c.JSON(http.StatusOK, toNodeResponse(node))
 }
 
-// deleteNode removes a node from the cluster
+// deleteNode removes a node from the cluster. A node with apps needs ?strategy=orphan|migrate|purge
+// (plus target_node_id to migrate), which is carried out by a node_remove job.
 func (s *Server) deleteNode(c *gin.Context) {
 	nodeID := c.Param("id")
+	req := domain.RemoveNodeRequest{
+		Strategy:     c.Query("strategy"),
+		TargetNodeID: c.Query("target_node_id"),
+	}
+	params := map[string]string{"strategy": req.Strategy, "target_node_id": req.TargetNodeID}
+	if s.awaitApproval(c, constants.ApprovalOperationRemoveNode, nodeID, params) {
+		return
+	}
+	if err := s.runRemoveNode(c, nodeID, req); err != nil {
+		s.handleServiceError(c, "remove node", err)
+	}
+}
 
-	err := s.nodeService.DeleteNode(c.Request.Context(), nodeID)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, ErrorResponse{
-			Error:   "Failed to delete node",
-			Details: domain.PublicMessage(err),
+// runRemoveNode removes a node, in a background job when a strategy for its apps is given, and
+// writes the response; errors are left to the caller
+func (s *Server) runRemoveNode(c *gin.Context, nodeID string, req domain.RemoveNodeRequest) error {
+	if req.Strategy != "" {
+		job, err := s.nodeService.RemoveNodeAsync(c.Request.Context(), nodeID, req)
+		if err != nil {
+			return err
+		}
+		c.JSON(http.StatusAccepted, gin.H{
+			"job_id":  job.ID,
+			"status":  job.Status,
+			"message": "Node removal started in background",
 		})
-		return
+		return nil
+	}
+
+	if err := s.nodeService.DeleteNode(c.Request.Context(), nodeID); err != nil {
+		return err
 	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"message": "Node deleted successfully",
 		"nodeID":  nodeID,
 	})
+	return nil
 }
 
 // checkNodeHealth performs a health check on a specific node
@@ -196,3 +282,162 @@ func (s *Server) getSettingsForNode(c *gin.Context) {
 
 	c.JSON(http.StatusOK, settings)
 }
+
+// queueNodeOperation stores a mutating request for an offline node; it is replayed when the node returns
+// The gateway passes the caller's credentials along, so the operation is queued (and later
+// replayed) for a signed-in user whose role allows it.
+func (s *Server) queueNodeOperation(c *gin.Context) {
+	nodeID := c.Param("id")
+	if _, ok := getUserFromContext(c); !ok && s.config.Auth.Enabled {
+		c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "Authentication required", Details: "only signed-in users can queue operations for an offline node"})
+		return
+	}
+
+	var req domain.QueueOperationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid request body",
+			Details: domain.PublicMessage(err),
+		})
+		return
+	}
+
+	op, err := s.nodeService.QueueOperation(c.Request.Context(), nodeID, req)
+	if err != nil {
+		s.handleServiceError(c, "queue operation", err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"message":   "Node is offline; operation queued and will be applied when it reconnects",
+		"queued":    true,
+		"operation": op,
+	})
+}
+
+// listNodeOperations returns the operations queued for a node, optionally filtered by ?status=
+func (s *Server) listNodeOperations(c *gin.Context) {
+	ops, err := s.nodeService.ListQueuedOperations(c.Request.Context(), c.Param("id"), c.Query("status"))
+	if err != nil {
+		s.handleServiceError(c, "list queued operations", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, ops)
+}
+
+// cancelNodeOperation removes a queued operation that has not been replayed yet
+func (s *Server) cancelNodeOperation(c *gin.Context) {
+	nodeID := c.Param("id")
+	opID := c.Param("opId")
+
+	if err := s.nodeService.CancelQueuedOperation(c.Request.Context(), nodeID, opID); err != nil {
+		s.handleServiceError(c, "cancel queued operation", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":     "Queued operation cancelled",
+		"operationID": opID,
+	})
+}
+
+// getNodeMetrics returns a node's metrics history, oldest first. ?since= is a duration ("6h") or
+// an RFC3339 timestamp and defaults to the last 24 hours.
+func (s *Server) getNodeMetrics(c *gin.Context) {
+	nodeID := c.Param("id")
+
+	since, err := parseMetricsSince(c.Query("since"), time.Now())
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid since",
+			Details: err.Error(),
+		})
+		return
+	}
+
+	metrics, err := s.metricsService.GetNodeMetrics(c.Request.Context(), nodeID, since)
+	if err != nil {
+		s.handleServiceError(c, "get node metrics", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"node_id": nodeID,
+		"since":   since.UTC(),
+		"metrics": metrics,
+	})
+}
+
+// listNodeAlerts returns a node's most recent alerts, newest first; ?active=true leaves out
+// resolved ones
+func (s *Server) listNodeAlerts(c *gin.Context) {
+	nodeID := c.Param("id")
+
+	alerts, err := s.metricsService.ListNodeAlerts(c.Request.Context(), nodeID, c.Query("active") == "true")
+	if err != nil {
+		s.handleServiceError(c, "list node alerts", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"node_id": nodeID,
+		"alerts":  alerts,
+	})
+}
+
+// getNodeServerLogs returns the server log lines a secondary shipped to this primary, oldest
+// first. ?since= takes a duration or an RFC3339 timestamp, ?level= a minimum level and ?limit=
+// caps the newest lines returned.
+func (s *Server) getNodeServerLogs(c *gin.Context) {
+	nodeID := c.Param("id")
+
+	query := domain.NodeLogQuery{MinLevel: c.Query("level")}
+	if raw := c.Query("since"); raw != "" {
+		since, err := parseMetricsSince(raw, time.Now())
+		if err != nil {
+			c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Invalid since", Details: err.Error()})
+			return
+		}
+		query.Since = since
+	}
+	if raw := c.Query("limit"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit <= 0 {
+			c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Invalid limit", Details: "limit must be a positive number"})
+			return
+		}
+		query.Limit = limit
+	}
+
+	logs, err := s.nodeService.GetNodeLogs(c.Request.Context(), nodeID, query)
+	if err != nil {
+		s.handleServiceError(c, "get node server logs", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"node_id": nodeID,
+		"logs":    logs,
+	})
+}
+
+// parseMetricsSince turns ?since= into the start of a metrics window, which can't reach back
+// further than samples are kept
+func parseMetricsSince(raw string, now time.Time) (time.Time, error) {
+	if raw == "" {
+		return now.Add(-constants.NodeMetricsDefaultRange), nil
+	}
+	since, err := time.Parse(time.RFC3339, raw)
+	if err != nil {
+		d, durErr := time.ParseDuration(raw)
+		if durErr != nil || d <= 0 {
+			return time.Time{}, fmt.Errorf("since must be a positive duration (e.g. 30m, 6h) or an RFC3339 timestamp")
+		}
+		since = now.Add(-d)
+	}
+	if oldest := now.Add(-constants.NodeMetricsRetention); since.Before(oldest) {
+		since = oldest
+	}
+	return since, nil
+}
@@ -0,0 +1,120 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/selfhostly/internal/constants"
+	"github.com/selfhostly/internal/domain"
+)
+
+// readOnlyWrites are the POST routes under locked groups that change nothing, so they stay
+// available while operations are locked
+var readOnlyWrites = map[string]bool{
+	"/api/apps/:id/compose/preview":          true,
+	"/api/apps/:id/schedule/test":            true,
+	"/api/apps/:id/webhooks/:webhookId/test": true,
+}
+
+// operationsLockMiddleware refuses app changes with 423 Locked while an admin has locked
+// operations; reads always go through
+func (s *Server) operationsLockMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		switch c.Request.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			c.Next()
+			return
+		}
+		if readOnlyWrites[c.FullPath()] || (c.FullPath() == "/api/apply" && c.Query("dry_run") == "true") {
+			c.Next()
+			return
+		}
+		if err := s.operationsLock.CheckUnlocked(c.Request.Context()); err != nil {
+			s.handleServiceError(c, "check operations lock", err)
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// requireAdminUser refuses requests made with node or gateway credentials alone, so the operations
+// lock, quotas and users can only be managed by a signed-in admin (anyone, while auth is off) and
+// not by another node or a viewer. The gateway passes the signed-in user along.
+func requireAdminUser(c *gin.Context) (string, bool) {
+	user, signedIn := getUserFromContext(c)
+	if _, scoped := c.Get("request_scope"); scoped && !signedIn {
+		c.JSON(http.StatusForbidden, ErrorResponse{Error: "admin required", Details: "this can't be changed with node or gateway credentials"})
+		return "", false
+	}
+	if user.Role == constants.UserRoleViewer {
+		c.JSON(http.StatusForbidden, ErrorResponse{Error: "admin required", Details: "viewers can't manage this; ask an admin"})
+		return "", false
+	}
+	return user.Name, true
+}
+
+// getOperationsLock returns whether app changes are blocked on this node
+func (s *Server) getOperationsLock(c *gin.Context) {
+	status, err := s.operationsLock.GetStatus(c.Request.Context())
+	if err != nil {
+		s.handleServiceError(c, "get operations lock", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, status)
+}
+
+// lockOperations blocks app changes on every node (primary only)
+func (s *Server) lockOperations(c *gin.Context) {
+	lockedBy, ok := requireAdminUser(c)
+	if !ok {
+		return
+	}
+
+	var req domain.LockOperationsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Invalid request format", Details: "reason is required"})
+		return
+	}
+
+	status, err := s.operationsLock.Lock(c.Request.Context(), req, lockedBy)
+	if err != nil {
+		s.handleServiceError(c, "lock operations", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, status)
+}
+
+// unlockOperations lets app changes through again on every node (primary only)
+func (s *Server) unlockOperations(c *gin.Context) {
+	unlockedBy, ok := requireAdminUser(c)
+	if !ok {
+		return
+	}
+
+	status, err := s.operationsLock.Unlock(c.Request.Context(), unlockedBy)
+	if err != nil {
+		s.handleServiceError(c, "unlock operations", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, status)
+}
+
+// syncOperationsLock stores the lock state the primary pushes to this secondary (node auth)
+func (s *Server) syncOperationsLock(c *gin.Context) {
+	var status domain.OperationsLockStatus
+	if err := c.ShouldBindJSON(&status); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Invalid request format", Details: err.Error()})
+		return
+	}
+
+	if err := s.operationsLock.ApplyFromPrimary(c.Request.Context(), status); err != nil {
+		s.handleServiceError(c, "sync operations lock", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, status)
+}
@@ -0,0 +1,46 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/selfhostly/internal/domain"
+)
+
+// listPortReservations returns this node's host port reservations, ordered by port
+func (s *Server) listPortReservations(c *gin.Context) {
+	reservations, err := s.ports.ListPortReservations(c.Request.Context())
+	if err != nil {
+		s.handleServiceError(c, "list port reservations", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, reservations)
+}
+
+// reservePort reserves a host port by hand; port 0 picks the lowest free one
+func (s *Server) reservePort(c *gin.Context) {
+	var req domain.ReservePortRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Invalid request format", Details: err.Error()})
+		return
+	}
+
+	reservation, err := s.ports.ReservePort(c.Request.Context(), req)
+	if err != nil {
+		s.handleServiceError(c, "reserve port", err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, reservation)
+}
+
+// releasePort deletes a manual port reservation
+func (s *Server) releasePort(c *gin.Context) {
+	if err := s.ports.ReleasePort(c.Request.Context(), c.Param("reservationId")); err != nil {
+		s.handleServiceError(c, "release port", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Port released"})
+}
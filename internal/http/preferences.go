@@ -0,0 +1,60 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/selfhostly/internal/constants"
+	"github.com/selfhostly/internal/domain"
+)
+
+// preferencesUserID returns the user preferences are stored under. With authentication off
+// everyone shares the local user's preferences; with it on, only a logged-in user has any.
+func (s *Server) preferencesUserID(c *gin.Context) (string, bool) {
+	if s.authService == nil {
+		return constants.LocalUserID, true
+	}
+	if user, ok := getUserFromContext(c); ok && user.ID != "" {
+		return user.ID, true
+	}
+	c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "Not authenticated", Details: "preferences belong to a logged-in user"})
+	return "", false
+}
+
+// getPreferences returns the current user's preferences
+func (s *Server) getPreferences(c *gin.Context) {
+	userID, ok := s.preferencesUserID(c)
+	if !ok {
+		return
+	}
+
+	prefs, err := s.preferences.GetPreferences(c.Request.Context(), userID)
+	if err != nil {
+		s.handleServiceError(c, "get preferences", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, prefs)
+}
+
+// updatePreferences changes the current user's preferences that are sent and keeps the rest
+func (s *Server) updatePreferences(c *gin.Context) {
+	userID, ok := s.preferencesUserID(c)
+	if !ok {
+		return
+	}
+
+	var req domain.UpdatePreferencesRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Invalid request format", Details: err.Error()})
+		return
+	}
+
+	prefs, err := s.preferences.UpdatePreferences(c.Request.Context(), userID, req)
+	if err != nil {
+		s.handleServiceError(c, "update preferences", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, prefs)
+}
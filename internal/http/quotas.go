@@ -0,0 +1,98 @@
+package http
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/selfhostly/internal/domain"
+)
+
+// listQuotas returns every user's quota, including the default ("*")
+func (s *Server) listQuotas(c *gin.Context) {
+	quotas, err := s.quotas.ListQuotas(c.Request.Context())
+	if err != nil {
+		s.handleServiceError(c, "list quotas", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, quotas)
+}
+
+// getQuotaUsage returns what a user's apps use against their quota: the signed-in user's, or
+// ?user= for someone else's, which only admins may see
+func (s *Server) getQuotaUsage(c *gin.Context) {
+	user, _ := getUserFromContext(c)
+	userName := c.Query("user")
+	if userName == "" {
+		userName = user.Name
+	} else if !strings.EqualFold(userName, user.Name) {
+		if _, ok := requireAdminUser(c); !ok {
+			return
+		}
+	}
+	if userName == "" {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "user is required", Details: "pass ?user= when not signed in"})
+		return
+	}
+
+	usage, err := s.quotas.GetUsage(c.Request.Context(), userName)
+	if err != nil {
+		s.handleServiceError(c, "get quota usage", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, usage)
+}
+
+// setQuota creates or replaces a user's quota; user "*" is the default
+func (s *Server) setQuota(c *gin.Context) {
+	if _, ok := requireAdminUser(c); !ok {
+		return
+	}
+
+	var req domain.SetQuotaRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Invalid request format", Details: err.Error()})
+		return
+	}
+
+	quota, err := s.quotas.SetQuota(c.Request.Context(), c.Param("user"), req)
+	if err != nil {
+		s.handleServiceError(c, "set quota", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, quota)
+}
+
+// deleteQuota removes a user's quota, leaving them on the default
+func (s *Server) deleteQuota(c *gin.Context) {
+	if _, ok := requireAdminUser(c); !ok {
+		return
+	}
+
+	if err := s.quotas.DeleteQuota(c.Request.Context(), c.Param("user")); err != nil {
+		s.handleServiceError(c, "delete quota", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Quota removed"})
+}
+
+// checkQuota answers a secondary asking whether an app change fits its owner's quota (node auth)
+func (s *Server) checkQuota(c *gin.Context) {
+	var req domain.QuotaCheckRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Invalid request format", Details: err.Error()})
+		return
+	}
+
+	check, err := s.quotas.CheckApp(c.Request.Context(), req)
+	if err != nil {
+		s.handleServiceError(c, "check quota", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, check)
+}
@@ -5,6 +5,8 @@ import (
 	"strings"
 
 	"github.com/gin-gonic/gin"
+	"github.com/selfhostly/internal/constants"
+	"github.com/selfhostly/internal/version"
 )
 
 // setupRoutes configures all API routes
@@ -27,9 +29,12 @@ func (s *Server) setupRoutes() {
 	// Health check endpoint (no auth required)
 	// Support both GET and HEAD for Docker healthcheck
 	healthHandler := func(c *gin.Context) {
+		version.SetHeaders(c.Writer.Header())
 		c.JSON(http.StatusOK, gin.H{
-			"status":  "healthy",
-			"service": "selfhostly",
+			"status":      "healthy",
+			"service":     "selfhostly",
+			"version":     version.Get(),
+			"api_version": version.APIVersion,
 		})
 	}
 	s.engine.GET("/api/health", healthHandler)
@@ -38,6 +43,13 @@ func (s *Server) setupRoutes() {
 	// Node auto-registration: no pre-auth (node doesn't exist yet). Handler validates REGISTRATION_TOKEN in body.
 	s.engine.POST("/api/nodes/register", s.autoRegisterNode)
 
+	// Invite links: signs in with GitHub, then creates the invited user's account. Outside the
+	// API's auth since the invitee has no access yet.
+	s.engine.GET(constants.InvitePath+":token", s.acceptInvitation)
+
+	// Public app status pages, reached with the page's token instead of signing in
+	s.engine.GET(constants.StatusPagePath+":token", s.getPublicAppStatus)
+
 	// Single API: user auth OR node auth (composite auth)
 	api := s.engine.Group("/api")
 	api.Use(s.userOrNodeAuthMiddleware())
@@ -54,19 +66,59 @@ func (s *Server) setupRoutes() {
 		// System/monitoring routes
 		s.setupSystemRoutes(api)
 
+		// Cross-app log search (fans out to nodes)
+		api.GET("/logs/search", s.searchLogs)
+
+		// App status, job, node health and notification events as server-sent events
+		api.GET("/events", s.streamEvents)
+
+		// Declarative apply: reconcile apps with a manifest (primary only)
+		api.POST("/apply", s.operationsLockMiddleware(), s.applyManifest)
+
+		// Random passwords and keys for compose files and .env templates
+		api.POST("/utils/generate-secret", s.generateSecret)
+
 		// Node management routes
 		s.setupNodeRoutes(api)
 
+		// Tokens the gateway fetches the node registry with (primary only)
+		s.setupGatewayTokenRoutes(api)
+
+		// Personal access tokens for scripts and CI (primary only)
+		s.setupAPITokenRoutes(api)
+
+		// Destructive operations awaiting a second confirmation (APPROVAL_REQUIRED)
+		s.setupApprovalRoutes(api)
+
+		// Per-user app quotas and usage (primary only)
+		s.setupQuotaRoutes(api)
+
+		// Host ports reserved on a node (routed by node_id)
+		s.setupPortRoutes(api)
+
+		// Invited users and their invitations (primary only)
+		s.setupUserRoutes(api)
+
 		// Job routes (require node_id from query for routing)
 		s.setupJobRoutes(api)
 
 		// Node-only routes (require node auth)
 		api.POST("/nodes/:id/heartbeat", s.requireNodeAuthMiddleware(), s.sendNodeHeartbeat)
+		api.POST("/nodes/:id/apps/sync", s.requireNodeAuthMiddleware(), s.syncNodeAppInventory)
+		api.POST("/nodes/:id/server-logs", s.requireNodeAuthMiddleware(), s.receiveNodeServerLogs)
 
 		// User info endpoint (only when auth is enabled)
 		if s.authService != nil {
 			api.GET("/me", s.getCurrentUser)
+
+			// Signed-in devices of the current user
+			api.GET("/me/sessions", s.getSessions)
+			api.DELETE("/me/sessions/:id", s.revokeSession)
 		}
+
+		// Per-user preferences (shared by everyone while auth is off)
+		api.GET("/me/preferences", s.getPreferences)
+		api.PUT("/me/preferences", s.updatePreferences)
 	}
 
 	// API-only mode: Return 404 for all unmatched routes
@@ -77,7 +129,7 @@ func (s *Server) setupRoutes() {
 }
 
 func (s *Server) setupAppRoutes(api *gin.RouterGroup) {
-	apps := api.Group("/apps")
+	apps := api.Group("/apps", s.operationsLockMiddleware())
 	{
 		// List and create don't require node_id
 		apps.GET("", s.listApps)
@@ -92,12 +144,18 @@ func (s *Server) setupAppRoutes(api *gin.RouterGroup) {
 			appSpecific.POST("/start", s.startApp)
 			appSpecific.POST("/stop", s.stopApp)
 			appSpecific.POST("/update", s.updateAppContainers)
+			appSpecific.POST("/run", s.runAppCommand)
 			appSpecific.GET("/logs", s.getAppLogs)
 			appSpecific.GET("/services", s.getAppServices)
 			appSpecific.POST("/services/:service/restart", s.restartAppService)
 			appSpecific.GET("/stats", s.getAppStats)
+			appSpecific.GET("/stats/stream", s.streamAppStats)
+			appSpecific.GET("/health", s.getAppContainerHealth)
+			appSpecific.GET("/icon", s.getAppIcon)
 			appSpecific.GET("/quick-tunnel-url", s.getQuickTunnelURL)
 			appSpecific.POST("/quick-tunnel", s.createQuickTunnelForApp)
+			appSpecific.GET("/tunnel/status", s.getAppTunnelStatus)
+			appSpecific.GET("/traffic", s.getAppTraffic)
 
 			// Schedule routes
 			appSpecific.GET("/schedule", s.getAppSchedule)
@@ -106,10 +164,44 @@ func (s *Server) setupAppRoutes(api *gin.RouterGroup) {
 			appSpecific.POST("/schedule/test", s.testAppSchedule)
 			appSpecific.GET("/schedule/next-runs", s.getAppScheduleNextRuns)
 
+			// Lifecycle webhook routes
+			appSpecific.GET("/webhooks", s.listAppWebhooks)
+			appSpecific.POST("/webhooks", s.createAppWebhook)
+			appSpecific.PUT("/webhooks/:webhookId", s.updateAppWebhook)
+			appSpecific.DELETE("/webhooks/:webhookId", s.deleteAppWebhook)
+			appSpecific.POST("/webhooks/:webhookId/test", s.testAppWebhook)
+
+			// Recurring task routes
+			appSpecific.GET("/tasks", s.listAppTasks)
+			appSpecific.POST("/tasks", s.createAppTask)
+			appSpecific.PUT("/tasks/:taskId", s.updateAppTask)
+			appSpecific.DELETE("/tasks/:taskId", s.deleteAppTask)
+			appSpecific.GET("/tasks/:taskId/runs", s.getAppTaskRuns)
+			appSpecific.POST("/tasks/:taskId/run", s.runAppTask)
+
+			// Snapshot routes
+			appSpecific.GET("/snapshots", s.listAppSnapshots)
+			appSpecific.POST("/snapshots", s.createAppSnapshot)
+			appSpecific.GET("/snapshots/:snapshotId", s.getAppSnapshot)
+			appSpecific.DELETE("/snapshots/:snapshotId", s.deleteAppSnapshot)
+			appSpecific.POST("/snapshots/:snapshotId/restore", s.restoreAppSnapshot)
+
+			// Public status page (served at /api/status/:token)
+			appSpecific.GET("/status-page", s.getAppStatusPage)
+			appSpecific.POST("/status-page", s.enableAppStatusPage)
+			appSpecific.DELETE("/status-page", s.disableAppStatusPage)
+
+			// Log forwarding to Loki or Elasticsearch (destination set in settings)
+			appSpecific.GET("/log-forwarding", s.getAppLogForwarding)
+			appSpecific.PUT("/log-forwarding", s.setAppLogForwarding)
+
 			// Compose version routes
 			appSpecific.GET("/compose/versions", s.getComposeVersions)
 			appSpecific.GET("/compose/versions/:version", s.getComposeVersion)
 			appSpecific.POST("/compose/rollback/:version", s.rollbackToVersion)
+			appSpecific.POST("/compose/preview", s.previewCompose)
+			appSpecific.GET("/compose/integrity", s.getComposeIntegrity)
+			appSpecific.POST("/compose/restore", s.restoreComposeFile)
 
 			// Job routes for this app
 			appSpecific.GET("/jobs", s.getAppJobs)
@@ -117,9 +209,74 @@ func (s *Server) setupAppRoutes(api *gin.RouterGroup) {
 	}
 }
 
+func (s *Server) setupGatewayTokenRoutes(api *gin.RouterGroup) {
+	tokens := api.Group("/gateway/tokens")
+	{
+		tokens.GET("", refuseSelector, s.listGatewayTokens)
+		tokens.POST("", s.issueGatewayToken)
+		tokens.DELETE("/:id", s.revokeGatewayToken)
+		tokens.POST("/:id/rotate", s.rotateGatewayToken)
+	}
+}
+
+func (s *Server) setupAPITokenRoutes(api *gin.RouterGroup) {
+	tokens := api.Group("/tokens")
+	{
+		tokens.GET("", refuseSelector, s.listAPITokens)
+		tokens.POST("", s.createAPIToken)
+		tokens.PUT("/:id", s.updateAPIToken)
+		tokens.DELETE("/:id", s.revokeAPIToken)
+		tokens.POST("/verify", s.requireNodeAuthMiddleware(), s.verifyAPIToken)
+	}
+}
+
+func (s *Server) setupApprovalRoutes(api *gin.RouterGroup) {
+	approvals := api.Group("/approvals")
+	{
+		approvals.GET("", refuseSelector, s.listApprovals)
+		approvals.GET("/:approvalId", s.getApproval)
+		approvals.POST("/:approvalId/confirm", s.confirmApproval)
+		approvals.POST("/:approvalId/reject", s.rejectApproval)
+	}
+}
+
+func (s *Server) setupQuotaRoutes(api *gin.RouterGroup) {
+	quotas := api.Group("/quotas")
+	{
+		quotas.GET("", refuseSelector, s.listQuotas)
+		quotas.GET("/usage", s.getQuotaUsage)
+		quotas.PUT("/:user", s.setQuota)
+		quotas.DELETE("/:user", s.deleteQuota)
+		quotas.POST("/check", s.requireNodeAuthMiddleware(), s.checkQuota)
+	}
+}
+
+func (s *Server) setupPortRoutes(api *gin.RouterGroup) {
+	ports := api.Group("/ports")
+	{
+		ports.GET("", refuseSelector, s.listPortReservations)
+		ports.POST("", s.reservePort)
+		ports.DELETE("/:reservationId", s.releasePort)
+	}
+}
+
+func (s *Server) setupUserRoutes(api *gin.RouterGroup) {
+	users := api.Group("/users")
+	{
+		users.GET("", refuseSelector, s.listUsers)
+		users.DELETE("/:name", s.deleteUser)
+		users.POST("/invite", s.inviteUser)
+		users.GET("/invitations", refuseSelector, s.listInvitations)
+		users.DELETE("/invitations/:id", s.revokeInvitation)
+	}
+}
+
 func (s *Server) setupJobRoutes(api *gin.RouterGroup) {
 	jobs := api.Group("/jobs")
 	{
+		// Recent jobs of every node, merged on the primary
+		jobs.GET("", s.listJobs)
+
 		// Job-specific operations require node_id (from query when user auth)
 		jobs.GET("/:id", s.resolveNodeMiddleware(), s.getJob)
 	}
@@ -131,18 +288,28 @@ func (s *Server) setupTunnelRoutes(api *gin.RouterGroup) {
 		// Provider discovery
 		tunnels.GET("/providers", s.ListTunnelProviders)
 		tunnels.GET("/providers/:provider/features", s.GetProviderFeatures)
+		tunnels.GET("/providers/:provider/zones", s.ListProviderZones)
+		tunnels.GET("/providers/:provider/zones/:zoneID/hostnames", s.ListZoneHostnames)
 
 		// List all tunnels
 		tunnels.GET("", s.ListTunnelsGeneric)
 
+		// Delete a tunnel no app uses any more (see ?inventory=true)
+		tunnels.DELETE("/:tunnelID", s.DeleteOrphanedTunnelGeneric)
+
+		// Adopt a tunnel that already exists in the provider account
+		tunnels.POST("/import", s.resolveNodeMiddleware(), s.ImportTunnelGeneric)
+
 		// App-specific tunnel operations require node_id
-		tunnelOps := tunnels.Group("/apps/:appId", s.resolveNodeMiddleware())
+		tunnelOps := tunnels.Group("/apps/:appId", s.resolveNodeMiddleware(), s.operationsLockMiddleware())
 		{
 			tunnelOps.GET("", s.GetTunnelByAppIDGeneric)
 			tunnelOps.POST("", s.CreateTunnelForAppGeneric)
 			tunnelOps.POST("/switch-to-custom", s.SwitchAppToCustomTunnelGeneric)
 			tunnelOps.POST("/sync", s.SyncTunnelStatusGeneric)
 			tunnelOps.PUT("/ingress", s.UpdateTunnelIngressGeneric)
+			tunnelOps.PUT("/replicas", s.SetTunnelReplicasGeneric)
+			tunnelOps.PUT("/options", s.SetTunnelOptionsGeneric)
 			tunnelOps.POST("/dns", s.CreateDNSRecordGeneric)
 			tunnelOps.DELETE("", s.DeleteTunnelGeneric)
 		}
@@ -154,6 +321,14 @@ func (s *Server) setupSettingsRoutes(api *gin.RouterGroup) {
 	{
 		settings.GET("", s.getSettingsDispatch)
 		settings.PUT("", s.updateSettings)
+		settings.GET("/telemetry/preview", s.previewTelemetry)
+		settings.GET("/features", s.listFeatureFlags)
+		settings.PUT("/features/:name", s.updateFeatureFlag)
+		settings.GET("/schema", s.getSettingsSchema)
+		settings.POST("/smtp/test", s.testSMTPSettings)
+		settings.GET("/:section", s.getSettingsSection)
+		settings.PUT("/:section", s.updateSettingsSection)
+		settings.GET("/:section/history", s.getSettingsHistory)
 	}
 }
 
@@ -161,28 +336,55 @@ func (s *Server) setupSystemRoutes(api *gin.RouterGroup) {
 	systemGroup := api.Group("/system")
 	{
 		systemGroup.GET("/stats", s.getSystemStats)
+		systemGroup.GET("/health", s.getPlatformHealth)
+		systemGroup.GET("/audit", s.getConsistencyAudit)
+		systemGroup.POST("/audit", s.runConsistencyAudit)
+		systemGroup.GET("/audit/history", s.listConsistencyAudits)
+		systemGroup.GET("/db/stats", s.getDatabaseStats)
+		systemGroup.POST("/reload", s.reloadConfig)
+		systemGroup.GET("/log-level", s.getLogSettings)
+		systemGroup.PUT("/log-level", s.updateLogSettings)
 
 		// Only expose debug endpoints in non-production environments
 		if s.config.Environment != "production" {
 			systemGroup.GET("/debug/docker-stats/:id", s.getDebugDockerStats)
 		}
 
-		systemGroup.POST("/containers/:id/restart", s.restartContainer)
-		systemGroup.POST("/containers/:id/stop", s.stopContainer)
-		systemGroup.DELETE("/containers/:id", s.deleteContainer)
+		// Admin lock blocking app changes on every node during maintenance (changed on the primary)
+		systemGroup.GET("/operations-lock", s.getOperationsLock)
+		systemGroup.PUT("/operations-lock", s.lockOperations)
+		systemGroup.DELETE("/operations-lock", s.unlockOperations)
+		systemGroup.PUT("/operations-lock/sync", s.requireNodeAuthMiddleware(), s.syncOperationsLock)
+
+		containers := systemGroup.Group("/containers", s.operationsLockMiddleware())
+		containers.POST("/:id/restart", s.restartContainer)
+		containers.POST("/:id/stop", s.stopContainer)
+		containers.DELETE("/:id", s.deleteContainer)
 	}
 }
 
 func (s *Server) setupNodeRoutes(api *gin.RouterGroup) {
 	nodes := api.Group("/nodes")
 	{
-		nodes.GET("", s.listNodes)
+		nodes.GET("", refuseSelector, s.listNodes)
 		nodes.POST("", s.registerNode)
 		nodes.GET("/:id", s.getNode)
 		nodes.PUT("/:id", s.updateNode)
 		nodes.DELETE("/:id", s.deleteNode)
 		nodes.GET("/:id/health", s.checkNodeHealth)
 		nodes.POST("/:id/check", s.manualCheckNode) // Manual health check trigger (for UI)
+
+		// Operations queued while the node is offline (replayed on reconnect)
+		nodes.GET("/:id/operations", s.listNodeOperations)
+		nodes.POST("/:id/operations", s.queueNodeOperation)
+		nodes.DELETE("/:id/operations/:opId", s.cancelNodeOperation)
+
+		// Metrics history and threshold alerts, sampled by the primary
+		nodes.GET("/:id/metrics", s.getNodeMetrics)
+		nodes.GET("/:id/alerts", s.listNodeAlerts)
+
+		// Server logs shipped to the primary by secondaries (LOG_SHIPPING=primary)
+		nodes.GET("/:id/server-logs", s.getNodeServerLogs)
 	}
 
 	// Current node info
@@ -204,6 +406,7 @@ func (s *Server) getCurrentUser(c *gin.Context) {
 		"id":      user.ID,
 		"name":    user.Name,
 		"picture": user.Picture,
+		"role":    user.Role,
 	})
 }
 
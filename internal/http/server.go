@@ -1,7 +1,9 @@
 package http
 
 import (
+	"bytes"
 	"context"
+	"io"
 	"log/slog"
 	"net/http"
 	"net/url"
@@ -12,37 +14,72 @@ import (
 	"github.com/go-pkgz/auth"
 	"github.com/go-pkgz/auth/avatar"
 	"github.com/go-pkgz/auth/token"
+	"github.com/selfhostly/internal/appstate"
+	"github.com/selfhostly/internal/chaos"
+	"github.com/selfhostly/internal/compress"
 	"github.com/selfhostly/internal/config"
 	"github.com/selfhostly/internal/constants"
 	"github.com/selfhostly/internal/db"
 	"github.com/selfhostly/internal/docker"
 	"github.com/selfhostly/internal/domain"
+	"github.com/selfhostly/internal/gatewaytoken"
 	"github.com/selfhostly/internal/jobs"
 	"github.com/selfhostly/internal/logger"
 	"github.com/selfhostly/internal/node"
+	"github.com/selfhostly/internal/reqsign"
 	"github.com/selfhostly/internal/routing"
 	"github.com/selfhostly/internal/scheduler"
+	"github.com/selfhostly/internal/secretstore"
 	"github.com/selfhostly/internal/service"
+	"github.com/selfhostly/internal/trash"
+	"github.com/selfhostly/internal/webhook"
 )
 
 // Server wraps the HTTP server
 type Server struct {
-	config          *config.Config
-	database        *db.DB
-	dockerManager   *docker.Manager
-	appService      domain.AppService
-	tunnelService   domain.TunnelService
-	systemService   domain.SystemService
-	composeService  domain.ComposeService
-	nodeService     domain.NodeService
-	scheduleService domain.ScheduleService
-	jobWorker       *jobs.Worker
-	scheduler       *scheduler.Scheduler
-	engine          *gin.Engine
-	authService     *auth.Service
-	httpServer      *http.Server
-	shutdownCtx     context.Context
-	shutdownCancel  context.CancelFunc
+	config           *config.Config
+	database         *db.DB
+	dockerManager    *docker.Manager
+	appService       domain.AppService
+	tunnelService    domain.TunnelService
+	systemService    domain.SystemService
+	composeService   domain.ComposeService
+	nodeService      domain.NodeService
+	scheduleService  domain.ScheduleService
+	telemetryService domain.TelemetryService
+	featureService   domain.FeatureService
+	webhookService   domain.WebhookService
+	taskService      domain.TaskService
+	snapshotService  domain.SnapshotService
+	logForwarding    domain.LogForwardingService
+	preferences      domain.PreferencesService
+	operationsLock   domain.OperationsLockService
+	approvals        domain.ApprovalService
+	healthService    domain.HealthService
+	auditService     domain.AuditService
+	settingsService  domain.SettingsService
+	applyService     domain.ApplyService
+	quotas           domain.QuotaService
+	ports            domain.PortService
+	users            domain.UserService
+	sessions         domain.SessionService
+	statusPages      domain.StatusPageService
+	appIcons         domain.AppIconService
+	jobs             domain.JobService
+	metricsService   domain.NodeMetricsService
+	crashMonitor     domain.CrashMonitorService
+	gatewayTokens    domain.GatewayTokenService
+	apiTokens        domain.APITokenService
+	requestVerifier  *reqsign.Verifier
+	webhooks         *webhook.Dispatcher
+	dbMaintainer     *db.Maintainer
+	jobWorker        *jobs.Worker
+	scheduler        *scheduler.Scheduler
+	engine           *gin.Engine
+	authService      *auth.Service
+	httpServer       *http.Server
+	shutdownCtx      context.Context
+	shutdownCancel   context.CancelFunc
 }
 
 // NewServer creates a new HTTP server
@@ -63,63 +100,184 @@ func NewServer(cfg *config.Config, database *db.DB) *Server {
 	engine.Use(loggerMiddleware())
 	engine.Use(jsonBodyLimitMiddleware(maxBodySize))
 
-	// Initialize auth service
-	var authService *auth.Service
-	if cfg.Auth.Enabled {
-		authService = initAuthService(cfg)
-	}
-
 	// Request body size limit
 	engine.MaxMultipartMemory = maxBodySize
 
-	// Initialize docker manager
+	// Initialize docker manager (simulated in chaos mode)
 	dockerManager := docker.NewManager(cfg.AppsDir)
+	if cfg.Chaos.Enabled {
+		dockerManager = docker.NewManagerWithExecutor(cfg.AppsDir, docker.NewStubCommandExecutor(chaos.Shared(cfg.Chaos)))
+	}
+	dockerManager.SetStorageRoots(cfg.StorageRoots)
+	dockerManager.SetNetworkRecorder(func(appName string, networks []string) {
+		if err := database.RecordAppNetworks(appName, networks); err != nil {
+			slog.Warn("failed to record app networks", "app", appName, "error", err)
+		}
+	})
 
 	// Initialize logger with configuration
 	appLogger := logger.InitLogger(cfg.Environment, cfg.LogJSON)
 
+	// Initialize user service (invited users and roles; accounts are kept on the primary)
+	userService := service.NewUserService(database, cfg, appLogger)
+
+	// Initialize session service (signed-in devices, listed and revoked by their users)
+	sessionService := service.NewSessionService(database, cfg, appLogger)
+
+	// Initialize auth service
+	var authService *auth.Service
+	if cfg.Auth.Enabled {
+		authService = initAuthService(cfg, userService, sessionService)
+	}
+
 	// Initialize services (Phase 2 integration)
 	tunnelService := service.NewTunnelService(database, dockerManager, cfg, appLogger)
-	appService := service.NewAppService(database, dockerManager, cfg, appLogger, tunnelService)
+	// One state machine applies every app status change, so its listeners see them all
+	appStates := appstate.New(database, appLogger)
+	appStates.OnTransition(appstate.PublishStatusChanges)
+	// One webhook dispatcher delivers every event of this node
+	webhookDispatcher := webhook.NewDispatcher(database, cfg.Node.ID, appLogger)
+	// One secret resolver renders app and snapshot .env files, so both read the same stores the same way
+	secretResolver := secretstore.New(cfg.SecretStores)
+	appService := service.NewAppService(database, dockerManager, appStates, webhookDispatcher, secretResolver, cfg, appLogger, tunnelService)
+
+	// Quota service (per-user app quotas, kept on the primary), shared with the app service that counts them
+	quotaService := appService.Quotas()
 	systemService := service.NewSystemService(database, dockerManager, cfg, appLogger)
 
 	// Initialize routing dependencies for compose service
-	composeNodeClient := node.NewClient()
+	composeNodeClient := node.NewClientWithTimeouts(cfg.Timeouts)
 	composeRouter := routing.NewNodeRouter(database, composeNodeClient, cfg.Node.ID, appLogger)
-	composeService := service.NewComposeService(database, dockerManager, composeRouter, composeNodeClient, appLogger)
+	composeService := service.NewComposeService(database, dockerManager, composeRouter, composeNodeClient, quotaService, appLogger)
 
 	nodeService := service.NewNodeService(database, cfg, appLogger)
 
 	// Initialize job processing system
-	jobProcessor := jobs.NewProcessor(database, dockerManager, appService, tunnelService, appLogger)
-	jobWorker := jobs.NewWorker(jobProcessor, database, constants.JobWorkerPollInterval, appLogger)
+	jobProcessor := jobs.NewProcessor(database, appStates, dockerManager, appService, tunnelService, nodeService, webhookDispatcher, appLogger)
+	jobWorker := jobs.NewWorker(jobProcessor, database, appStates, constants.JobWorkerPollInterval, appLogger)
 
 	// Initialize schedule service
 	scheduleService := service.NewScheduleService(database, appLogger)
 
+	// Initialize telemetry service (opt-in usage reports)
+	telemetryService := service.NewTelemetryService(database, cfg, appLogger)
+
+	// Initialize feature flag service (gates experimental subsystems)
+	featureService := service.NewFeatureService(database, cfg, appLogger)
+
+	// Initialize per-app lifecycle webhook service
+	webhookService := service.NewWebhookService(database, webhookDispatcher, appLogger)
+
+	// Initialize recurring task service (cron-scheduled commands in app services)
+	taskService := service.NewTaskService(database, dockerManager, appLogger)
+
+	// Initialize app snapshot service (compose, .env and image digests pinned under a name)
+	snapshotService := service.NewSnapshotService(database, dockerManager, secretResolver, quotaService, appLogger)
+
+	// Initialize app log forwarding service (Vector sidecars shipping to Loki or Elasticsearch)
+	logForwardingService := service.NewLogForwardingService(database, dockerManager, cfg, appLogger)
+
+	// Initialize preferences service (per-user UI and CLI preferences)
+	preferencesService := service.NewPreferencesService(database, appLogger)
+
+	// Initialize operations lock service (admin lock blocking app changes during maintenance)
+	operationsLockService := service.NewOperationsLockService(database, cfg, appLogger)
+
+	// Initialize approval service (second confirmation of destructive operations, APPROVAL_REQUIRED)
+	approvalService := service.NewApprovalService(database, cfg, appLogger)
+
+	// Initialize platform health service (aggregated checks for external monitors)
+	healthService := service.NewHealthService(database, dockerManager, tunnelService, cfg, appLogger)
+
+	// Initialize typed settings service (validated sections with change history)
+	settingsService := service.NewSettingsService(database, appLogger)
+
+	// Initialize consistency audit service (weekly report of contradictory records)
+	auditService := service.NewAuditService(database, cfg, appLogger)
+
+	// Initialize declarative apply service (reconciles apps with a manifest)
+	applyService := service.NewApplyService(database, appService, tunnelService, cfg, appLogger)
+
+	// Initialize node metrics service (per-node history and threshold alerts, sampled on the primary)
+	metricsService := service.NewNodeMetricsService(database, systemService, webhookDispatcher, appLogger)
+
+	// Initialize crash monitor (OOM kills and crash loops in this node's apps)
+	crashMonitor := service.NewCrashMonitorService(database, appStates, dockerManager, webhookDispatcher, cfg, appLogger)
+
+	// Initialize status page service (public uptime and incidents of this node's apps)
+	statusPageService := service.NewStatusPageService(database, cfg, appLogger)
+
+	// Initialize port service (host port reservations, kept per node)
+	portService := service.NewPortService(database, cfg, appLogger)
+
+	// Initialize app icon service (icons detected from each app's public URL, cached per node)
+	appIconService := service.NewAppIconService(database, appLogger)
+
+	// Initialize job service (background jobs of every node, merged on the primary)
+	jobService := service.NewJobService(database, cfg, appLogger)
+
+	// Initialize gateway token service (short-lived tokens for the gateway's node registry fetch)
+	gatewayTokens := service.NewGatewayTokenService(database, cfg, appLogger)
+
+	// Initialize API token service (personal access tokens for scripts and CI)
+	apiTokens := service.NewAPITokenService(database, cfg, appLogger)
+
+	// Initialize database maintenance (daily vacuum and ANALYZE, put off while jobs are running)
+	dbMaintainer := db.NewMaintainer(database, func() bool {
+		pending, running, _, err := database.GetJobBacklog()
+		return err != nil || pending+running > 0
+	}, appLogger)
+
 	// Initialize scheduler
-	appScheduler := scheduler.NewScheduler(database, appService, appLogger)
+	appScheduler := scheduler.NewScheduler(database, appService, taskService, appLogger)
 
 	// Create shutdown context
 	shutdownCtx, shutdownCancel := context.WithCancel(context.Background())
 
 	// Initialize server
 	server := &Server{
-		config:          cfg,
-		database:        database,
-		dockerManager:   dockerManager,
-		appService:      appService,
-		tunnelService:   tunnelService,
-		systemService:   systemService,
-		composeService:  composeService,
-		nodeService:     nodeService,
-		scheduleService: scheduleService,
-		jobWorker:       jobWorker,
-		scheduler:       appScheduler,
-		engine:          engine,
-		authService:     authService,
-		shutdownCtx:     shutdownCtx,
-		shutdownCancel:  shutdownCancel,
+		config:           cfg,
+		database:         database,
+		dockerManager:    dockerManager,
+		appService:       appService,
+		tunnelService:    tunnelService,
+		systemService:    systemService,
+		composeService:   composeService,
+		nodeService:      nodeService,
+		scheduleService:  scheduleService,
+		telemetryService: telemetryService,
+		featureService:   featureService,
+		webhookService:   webhookService,
+		taskService:      taskService,
+		snapshotService:  snapshotService,
+		logForwarding:    logForwardingService,
+		preferences:      preferencesService,
+		operationsLock:   operationsLockService,
+		approvals:        approvalService,
+		healthService:    healthService,
+		auditService:     auditService,
+		settingsService:  settingsService,
+		applyService:     applyService,
+		quotas:           quotaService,
+		ports:            portService,
+		users:            userService,
+		sessions:         sessionService,
+		statusPages:      statusPageService,
+		appIcons:         appIconService,
+		jobs:             jobService,
+		metricsService:   metricsService,
+		crashMonitor:     crashMonitor,
+		gatewayTokens:    gatewayTokens,
+		apiTokens:        apiTokens,
+		requestVerifier:  reqsign.NewVerifier(),
+		webhooks:         webhookDispatcher,
+		dbMaintainer:     dbMaintainer,
+		jobWorker:        jobWorker,
+		scheduler:        appScheduler,
+		engine:           engine,
+		authService:      authService,
+		shutdownCtx:      shutdownCtx,
+		shutdownCancel:   shutdownCancel,
 	}
 
 	// Setup routes
@@ -129,7 +287,7 @@ func NewServer(cfg *config.Config, database *db.DB) *Server {
 }
 
 // initAuthService initializes go-pkgz/auth with GitHub OAuth
-func initAuthService(cfg *config.Config) *auth.Service {
+func initAuthService(cfg *config.Config, users domain.UserService, sessions domain.SessionService) *auth.Service {
 	// Determine base URL - must include /auth since we mount at /auth/*
 	baseURL := cfg.Auth.BaseURL
 	if baseURL == "" {
@@ -142,8 +300,8 @@ func initAuthService(cfg *config.Config) *auth.Service {
 		SecretReader: token.SecretFunc(func(id string) (string, error) {
 			return cfg.Auth.JWTSecret, nil
 		}),
-		TokenDuration:  time.Hour * 24,     // Token valid for 24 hours
-		CookieDuration: time.Hour * 24 * 7, // Cookie valid for 7 days
+		TokenDuration:  constants.SessionTokenLifetime, // Token valid for 24 hours
+		CookieDuration: constants.SessionLifetime,      // Cookie valid for 7 days
 		Issuer:         "selfhostly",
 		URL:            baseURL + "/auth", // Include /auth prefix for callback URLs
 		AvatarStore:    avatar.NewNoOp(),  // No avatar storage
@@ -156,26 +314,53 @@ func initAuthService(cfg *config.Config) *auth.Service {
 				return false
 			}
 
-			// If no whitelist is configured, reject all access (fail-secure)
-			if len(cfg.Auth.GitHub.AllowedUsers) == 0 {
-				slog.Warn("GitHub auth enabled but no allowed users configured - rejecting access", "username", claims.User.Name)
+			// Expired tokens are refreshed from the cookie for as long as it is sent, so stop at the
+			// cookie's lifetime; with revoked sessions refused before refreshing, this bounds how long
+			// a revocation has to be remembered (RevokedSessionRetention)
+			if claims.ExpiresAt != 0 && time.Since(time.Unix(claims.ExpiresAt, 0)) > constants.SessionLifetime {
+				slog.Info("JWT validation failed: token expired beyond the session lifetime", "username", claims.User.Name)
+				return false
+			}
+			if claims.Id != "" && sessions.IsRevoked(context.Background(), claims.Id) {
+				slog.Info("JWT validation failed: session revoked", "username", claims.User.Name)
 				return false
 			}
 
-			// Check if GitHub username is in the whitelist
-			// GitHub usernames are case-insensitive, so normalize for comparison
-			username := strings.ToLower(claims.User.Name)
-			for _, allowedUser := range cfg.Auth.GitHub.AllowedUsers {
-				if username == strings.ToLower(allowedUser) {
-					slog.Info("User authorized", "username", claims.User.Name)
-					return true
-				}
+			// Check if GitHub username is in the whitelist, or has the account of an invited user
+			// (GitHub usernames are case-insensitive; UserRole normalizes them)
+			if _, ok := users.UserRole(context.Background(), claims.User.Name); ok {
+				slog.Info("User authorized", "username", claims.User.Name)
+				return true
+			}
+			// Accounts are kept on the primary; other nodes trust the role it put in the token until
+			// heartbeats bring them its users
+			if !cfg.Node.IsPrimary && !users.RolesSynced() && isUserRole(claims.User.Role) {
+				slog.Info("User authorized by role", "username", claims.User.Name, "role", claims.User.Role)
+				return true
+			}
+
+			// If no whitelist is configured and the user wasn't invited, reject (fail-secure)
+			if len(cfg.Auth.GitHub.AllowedUsers) == 0 {
+				slog.Warn("GitHub auth enabled but no allowed users configured - rejecting access", "username", claims.User.Name)
+				return false
 			}
 
 			// User not in whitelist
-			slog.Warn("Unauthorized GitHub user attempted access", "username", username, "allowedUsers", len(cfg.Auth.GitHub.AllowedUsers))
+			slog.Warn("Unauthorized GitHub user attempted access", "username", strings.ToLower(claims.User.Name), "allowedUsers", len(cfg.Auth.GitHub.AllowedUsers))
 			return false
 		}),
+		// Put the user's role in the token when it is issued or refreshed, so every node can tell
+		// viewers from admins. Invited users are kept on the primary, and other nodes get them with
+		// its heartbeat replies; a user who lost access loses their role with the next refresh.
+		ClaimsUpd: token.ClaimsUpdFunc(func(claims token.Claims) token.Claims {
+			if claims.User == nil {
+				return claims
+			}
+			if role, ok := users.UserRole(context.Background(), claims.User.Name); ok || cfg.Node.IsPrimary || users.RolesSynced() {
+				claims.User.Role = role
+			}
+			return claims
+		}),
 	}
 
 	// Create auth service
@@ -284,9 +469,13 @@ func (s *Server) Run() error {
 	s.startBackgroundTasks()
 
 	// Configure server with timeouts
+	var handler http.Handler = s.engine
+	if s.config.ResponseCompression {
+		handler = compress.Handler(handler)
+	}
 	s.httpServer = &http.Server{
 		Addr:           addr,
-		Handler:        s.engine,
+		Handler:        handler,
 		ReadTimeout:    readTimeout,
 		WriteTimeout:   writeTimeout,
 		IdleTimeout:    idleTimeout,
@@ -296,6 +485,17 @@ func (s *Server) Run() error {
 	return s.httpServer.ListenAndServe()
 }
 
+// Handler returns the server's routes without starting it or its background tasks, for
+// serving from tests
+func (s *Server) Handler() http.Handler {
+	return s.engine
+}
+
+// ReloadConfig applies the reload-safe settings from the environment; main calls it on SIGHUP
+func (s *Server) ReloadConfig(ctx context.Context) (*domain.ConfigReload, error) {
+	return s.systemService.ReloadConfig(ctx)
+}
+
 // Shutdown gracefully shuts down the server
 func (s *Server) Shutdown(ctx context.Context) error {
 	slog.Info("Starting graceful shutdown...")
@@ -330,6 +530,9 @@ func (s *Server) Shutdown(ctx context.Context) error {
 
 // startBackgroundTasks starts periodic background tasks like health checks
 func (s *Server) startBackgroundTasks() {
+	// Ship our own logs first so the rest of startup is shipped too
+	s.startLogShipping()
+
 	// Start periodic health checks for all nodes
 	go s.runPeriodicHealthChecks()
 
@@ -338,8 +541,52 @@ func (s *Server) startBackgroundTasks() {
 		go s.attemptAutoRegistration()
 		// After registration, start continuous heartbeats
 		go s.sendPeriodicHeartbeats()
+		// Keep the primary's copy of this node's apps current for when we're unreachable
+		go s.runPeriodicAppInventorySync()
+		// And keep our copy of the primary's metadata current for when it is
+		go s.runPeriodicPrimaryReplicaSync()
+	}
+
+	// The primary reports for the whole cluster; the report is skipped unless telemetry is enabled
+	if s.config.Node.IsPrimary {
+		go s.runPeriodicTelemetry()
+		// Node metrics history and alerts also cover every node, so only the primary samples them
+		go s.runPeriodicNodeMetrics()
 	}
 
+	// Every node deploys its own apps' log forwarding sidecars; catch up on settings that changed
+	// while it was down
+	go func() {
+		if err := s.logForwarding.ApplyLogForwarding(s.shutdownCtx); err != nil {
+			slog.Warn("failed to apply log forwarding settings", "error", err)
+		}
+	}()
+
+	// Every node reserves its own apps' host ports; catch up on apps deployed before the registry
+	go func() {
+		if err := s.ports.SyncAppReservations(s.shutdownCtx); err != nil {
+			slog.Warn("failed to reserve app ports", "error", err)
+		}
+	}()
+
+	// Every node keeps its own trash of archived app directories
+	go s.runPeriodicTrashPurge()
+
+	// Every node keeps its own apps' status history for status pages
+	go s.runPeriodicStatusHistoryPrune()
+
+	// Every node audits its own database
+	go s.runPeriodicConsistencyAudit()
+
+	// Every node watches its own apps' containers for OOM kills and crash loops
+	go s.runPeriodicCrashDetection()
+
+	// Every node sends the digests and quiet-hours events its own apps' webhooks hold
+	go s.runPeriodicWebhookFlush()
+
+	// Every node keeps its own database file compact
+	go s.dbMaintainer.Start(s.shutdownCtx)
+
 	// Start job worker for background async operations
 	go func() {
 		slog.Info("starting job worker")
@@ -386,6 +633,175 @@ func (s *Server) runPeriodicHealthChecks() {
 	}
 }
 
+// runPeriodicAppInventorySync pushes app inventory changes to the primary on secondary nodes
+func (s *Server) runPeriodicAppInventorySync() {
+	ticker := time.NewTicker(constants.AppInventorySyncInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.shutdownCtx.Done():
+			return
+		case <-ticker.C:
+			if err := s.nodeService.PushAppInventory(s.shutdownCtx); err != nil {
+				slog.Debug("app inventory sync failed", "error", err)
+			}
+		}
+	}
+}
+
+// runPeriodicPrimaryReplicaSync refreshes a secondary's read-only copy of primary metadata
+func (s *Server) runPeriodicPrimaryReplicaSync() {
+	ticker := time.NewTicker(constants.PrimaryReplicaSyncInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := s.nodeService.SyncPrimaryReplica(s.shutdownCtx); err != nil {
+			slog.Debug("primary replica sync failed", "error", err)
+		} else if err := s.logForwarding.ApplyLogForwarding(s.shutdownCtx); err != nil {
+			// Settings synced from the primary may have moved the log forwarding destination
+			slog.Warn("failed to apply log forwarding settings", "error", err)
+		}
+		select {
+		case <-s.shutdownCtx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// runPeriodicTelemetry sends the opt-in usage report once a day
+func (s *Server) runPeriodicTelemetry() {
+	ticker := time.NewTicker(constants.TelemetryReportInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.shutdownCtx.Done():
+			return
+		case <-ticker.C:
+			if err := s.telemetryService.SendReport(s.shutdownCtx); err != nil {
+				slog.Debug("telemetry report failed", "error", err)
+			}
+		}
+	}
+}
+
+// runPeriodicNodeMetrics samples every node's system stats once a minute and checks them against
+// the alert thresholds
+func (s *Server) runPeriodicNodeMetrics() {
+	ticker := time.NewTicker(constants.NodeMetricsInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.shutdownCtx.Done():
+			return
+		case <-ticker.C:
+			if err := s.metricsService.RecordNodeMetrics(s.shutdownCtx); err != nil {
+				slog.Warn("node metrics sampling failed", "error", err)
+			}
+		}
+	}
+}
+
+// runPeriodicTrashPurge removes archived app directories once they outlive the trash TTL
+func (s *Server) runPeriodicTrashPurge() {
+	ticker := time.NewTicker(constants.TrashPurgeInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.shutdownCtx.Done():
+			return
+		case <-ticker.C:
+			removed, err := trash.Purge(s.config.TrashDir, s.config.TrashTTL, time.Now())
+			for _, path := range removed {
+				slog.Info("purged archived app directory", "path", path)
+			}
+			if err != nil {
+				slog.Warn("failed to purge trash", "dir", s.config.TrashDir, "error", err)
+			}
+		}
+	}
+}
+
+// runPeriodicStatusHistoryPrune deletes app status history once it falls out of the status pages'
+// uptime window
+func (s *Server) runPeriodicStatusHistoryPrune() {
+	ticker := time.NewTicker(constants.StatusHistoryPruneInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.shutdownCtx.Done():
+			return
+		case <-ticker.C:
+			if err := s.statusPages.PruneStatusHistory(s.shutdownCtx); err != nil {
+				slog.Warn("failed to prune app status history", "error", err)
+			}
+		}
+	}
+}
+
+// runPeriodicConsistencyAudit audits the database on startup and then once a week. Findings are
+// logged as warnings and stored for GET /api/system/audit.
+func (s *Server) runPeriodicConsistencyAudit() {
+	if _, err := s.auditService.RunAudit(s.shutdownCtx); err != nil {
+		slog.Warn("initial consistency audit failed", "error", err)
+	}
+
+	ticker := time.NewTicker(constants.ConsistencyAuditInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.shutdownCtx.Done():
+			return
+		case <-ticker.C:
+			if _, err := s.auditService.RunAudit(s.shutdownCtx); err != nil {
+				slog.Warn("consistency audit failed", "error", err)
+			}
+		}
+	}
+}
+
+// runPeriodicCrashDetection checks this node's running apps for OOM-killed and crash-looping
+// containers every 30 seconds
+func (s *Server) runPeriodicCrashDetection() {
+	ticker := time.NewTicker(constants.CrashCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.shutdownCtx.Done():
+			return
+		case <-ticker.C:
+			if err := s.crashMonitor.CheckApps(s.shutdownCtx); err != nil {
+				slog.Warn("crash detection failed", "error", err)
+			}
+		}
+	}
+}
+
+// runPeriodicWebhookFlush sends held webhook events once their digest is due or quiet hours end,
+// checking every minute
+func (s *Server) runPeriodicWebhookFlush() {
+	ticker := time.NewTicker(constants.WebhookHeldFlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.shutdownCtx.Done():
+			return
+		case now := <-ticker.C:
+			if err := s.webhooks.FlushHeldEvents(s.shutdownCtx, now); err != nil {
+				slog.Warn("failed to flush held webhook events", "error", err)
+			}
+		}
+	}
+}
+
 // securityHeadersMiddleware adds security-related HTTP headers
 func securityHeadersMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -511,6 +927,8 @@ func (s *Server) getAuthMiddleware() gin.HandlerFunc {
 	authMiddleware := s.authService.Middleware()
 
 	return func(c *gin.Context) {
+		bearerJWT(c.Request)
+
 		// Debug: log incoming auth attempt
 		hasCookie := c.Request.Header.Get("Cookie") != ""
 		hasAuth := c.Request.Header.Get("Authorization") != ""
@@ -554,7 +972,13 @@ func (s *Server) getAuthMiddleware() gin.HandlerFunc {
 		}
 
 		// Store user info in gin context for handlers
-		c.Set("user", userInfo)
+		var sessionID string
+		if claims, _, err := s.authService.TokenService().Get(c.Request); err == nil {
+			sessionID = claims.Id
+		}
+		if !s.authorizeUser(c, userInfo, sessionID) {
+			return
+		}
 		c.Next()
 	}
 }
@@ -572,7 +996,31 @@ func getUserFromContext(c *gin.Context) (token.User, bool) {
 // userOrNodeAuthMiddleware accepts gateway auth (X-Gateway-API-Key), node auth (X-Node-ID + X-Node-API-Key), or user auth (JWT/session).
 // When gateway or node auth is valid, sets node_id_param = local node ID and request_scope = "local" so handlers treat the request as local-only.
 // When user auth is valid, does not set target/scope; resolveNodeMiddleware or handlers will use node_id from query/body.
+// A gateway token (X-Gateway-Token) is accepted too, but only for the registry fetch. On the primary the
+// static gateway API key stops being accepted once a gateway token has been issued.
+// An API token (Authorization: Bearer shp_...) authenticates as the user who created it, like user auth.
 func (s *Server) userOrNodeAuthMiddleware() gin.HandlerFunc {
+	tryGatewayTokenAuth := func(c *gin.Context) bool {
+		token := c.GetHeader(gatewaytoken.Header)
+		if token == "" {
+			return false
+		}
+		route := c.Request.Method + " " + c.FullPath()
+		if route != "GET /api/nodes" {
+			c.JSON(http.StatusForbidden, ErrorResponse{Error: "gateway token not accepted here", Details: "gateway tokens only list nodes"})
+			c.Abort()
+			return true
+		}
+		if _, err := s.gatewayTokens.VerifyToken(c.Request.Context(), token); err != nil {
+			c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "invalid gateway token", Details: err.Error()})
+			c.Abort()
+			return true
+		}
+		c.Set("node_id_param", s.config.Node.ID)
+		c.Set("request_scope", "local")
+		c.Next()
+		return true
+	}
 	tryGatewayAuth := func(c *gin.Context) bool {
 		key := c.GetHeader("X-Gateway-API-Key")
 		if key == "" || s.config.Node.GatewayAPIKey == "" {
@@ -583,19 +1031,37 @@ func (s *Server) userOrNodeAuthMiddleware() gin.HandlerFunc {
 			c.Abort()
 			return true
 		}
+		if s.config.Node.IsPrimary {
+			// The key only bootstraps the gateway until it has a token
+			if active, err := s.gatewayTokens.HasActiveToken(c.Request.Context()); err != nil || active {
+				c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "gateway API key retired", Details: "a gateway token has been issued; the gateway must use it instead"})
+				c.Abort()
+				return true
+			}
+		}
+		if !s.verifyRequestSignature(c, key) {
+			return true
+		}
 		c.Set("node_id_param", s.config.Node.ID)
 		c.Set("request_scope", "local")
+		if !s.attachForwardedUser(c) {
+			return true
+		}
 		c.Next()
 		return true
 	}
+	// Signed node requests may leave the API key out (see node.SetKeylessAuth); the key is then
+	// looked up by node ID and the signature alone authenticates the request
 	tryNodeAuth := func(c *gin.Context) bool {
 		nodeID := c.GetHeader("X-Node-ID")
 		apiKey := c.GetHeader("X-Node-API-Key")
-		if nodeID == "" || apiKey == "" {
+		if nodeID == "" || (apiKey == "" && !reqsign.IsSigned(c.Request)) {
 			return false
 		}
+		var key string
 		if !s.config.Node.IsPrimary {
-			if apiKey != s.config.Node.APIKey {
+			key = s.config.Node.APIKey
+			if key == "" || (apiKey != "" && apiKey != key) {
 				c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "invalid API key", Details: "provided API key does not match this node"})
 				c.Abort()
 				return true // handled
@@ -603,32 +1069,205 @@ func (s *Server) userOrNodeAuthMiddleware() gin.HandlerFunc {
 			c.Set("node_id", nodeID)
 		} else {
 			node, err := s.database.GetNode(nodeID)
-			if err != nil || node.APIKey != apiKey {
+			if err != nil || node.APIKey == "" || (apiKey != "" && node.APIKey != apiKey) {
 				c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "unknown or invalid node", Details: "node ID or API key invalid"})
 				c.Abort()
 				return true
 			}
+			key = node.APIKey
 			c.Set("node_id", node.ID)
 		}
+		if !s.verifyRequestSignature(c, key) {
+			return true
+		}
 		// Node auth valid: set target = local, scope = local for list
 		c.Set("node_id_param", s.config.Node.ID)
 		c.Set("request_scope", "local")
+		if !s.attachNodeForwardedUser(c) {
+			return true
+		}
+		c.Next()
+		return true
+	}
+
+	tryAPITokenAuth := func(c *gin.Context) bool {
+		apiToken, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if !ok || !strings.HasPrefix(apiToken, constants.APITokenPrefix) {
+			return false
+		}
+		// A leaked token mustn't be able to mint more
+		if strings.HasPrefix(c.FullPath(), "/api/tokens") {
+			c.JSON(http.StatusForbidden, ErrorResponse{Error: "API token not accepted here", Details: "API tokens are managed after signing in"})
+			c.Abort()
+			return true
+		}
+		verified, err := s.apiTokens.VerifyToken(c.Request.Context(), apiToken)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "invalid API token", Details: err.Error()})
+			c.Abort()
+			return true
+		}
+		if !s.authorizeAPIToken(c, verified) {
+			return true
+		}
 		c.Next()
 		return true
 	}
 
 	return func(c *gin.Context) {
+		if tryGatewayTokenAuth(c) {
+			return
+		}
 		if tryGatewayAuth(c) {
 			return
 		}
 		if tryNodeAuth(c) {
 			return
 		}
+		if tryAPITokenAuth(c) {
+			return
+		}
 		// No gateway or node auth; require user auth
 		s.getAuthMiddleware()(c)
 	}
 }
 
+// bearerJWT hands a JWT sent as a bearer token, as the gateway accepts it and the Go client sends
+// it, to go-pkgz/auth, which only reads the X-JWT header, the token query and the JWT cookie. API
+// tokens are left alone.
+func bearerJWT(r *http.Request) {
+	jwt, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if ok && jwt != "" && !strings.HasPrefix(jwt, constants.APITokenPrefix) && r.Header.Get("X-JWT") == "" {
+		r.Header.Set("X-JWT", jwt)
+	}
+}
+
+// attachForwardedUser sets the signed-in user from the JWT the gateway passed along with its own
+// credentials, which the gateway has already checked, so changes made through it are attributed
+// to the user (app owners, approvals) and limited to their role. Returns false when the request
+// was refused.
+func (s *Server) attachForwardedUser(c *gin.Context) bool {
+	if s.authService == nil {
+		return true
+	}
+	if apiToken, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer "); ok && strings.HasPrefix(apiToken, constants.APITokenPrefix) {
+		verified, err := s.apiTokens.VerifyToken(c.Request.Context(), apiToken)
+		if err != nil {
+			return true
+		}
+		return s.authorizeAPIToken(c, verified)
+	}
+	bearerJWT(c.Request)
+	claims, _, err := s.authService.TokenService().Get(c.Request)
+	if err != nil || claims.User == nil {
+		return true
+	}
+	return s.authorizeUser(c, *claims.User, claims.Id)
+}
+
+// attachNodeForwardedUser sets the user a node request acts for, named by the primary in the
+// signed request (see node.Client.ForUser), with their current role when this node knows them.
+// Returns false when the request was refused.
+func (s *Server) attachNodeForwardedUser(c *gin.Context) bool {
+	name := c.GetHeader(reqsign.HeaderUser)
+	if name == "" {
+		return true
+	}
+	user := token.User{Name: name, Role: c.GetHeader(reqsign.HeaderUserRole)}
+	if role, ok := s.users.UserRole(c.Request.Context(), name); ok {
+		user.Role = role
+	}
+	setUser(c, user)
+	return s.allowRole(c, user.Role)
+}
+
+// setUser stores the user a request acts for in the gin context, for handlers, and in the request
+// context, for services forwarding the request to other nodes
+func setUser(c *gin.Context, user token.User) {
+	c.Set("user", user)
+	c.Request = c.Request.WithContext(domain.WithActingUser(c.Request.Context(), domain.ActingUser{Name: user.Name, Role: user.Role}))
+}
+
+// authorizeUser stores the signed-in user in the context with their current role, and refuses
+// revoked sessions and changes from viewers, who may only read and manage their own preferences
+// and sessions.
+// The role in the token is only used for users this node doesn't know, before a node other than
+// the primary got the primary's users. Returns false when the request was refused.
+func (s *Server) authorizeUser(c *gin.Context, user token.User, sessionID string) bool {
+	if sessionID != "" {
+		session := db.NewSession(sessionID, user.Name, c.Request.UserAgent(), c.ClientIP())
+		if !s.sessions.Touch(c.Request.Context(), session) {
+			s.authService.TokenService().Reset(c.Writer)
+			c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "session revoked", Details: "this session was signed out; login with GitHub again"})
+			c.Abort()
+			return false
+		}
+		c.Set("session_id", sessionID)
+	}
+
+	if role, ok := s.users.UserRole(c.Request.Context(), user.Name); ok {
+		user.Role = role
+	}
+	setUser(c, user)
+	return s.allowRole(c, user.Role)
+}
+
+// authorizeAPIToken stores the user who created an API token in the context, with the role the
+// primary gave the token (see APITokenService.VerifyToken). Returns false when the request was
+// refused.
+func (s *Server) authorizeAPIToken(c *gin.Context, verified *domain.VerifiedAPIToken) bool {
+	setUser(c, token.User{Name: verified.UserName, Role: verified.Role})
+	c.Set("api_token_id", verified.ID)
+	return s.allowRole(c, verified.Role)
+}
+
+// allowRole refuses changes from viewers, who may only read and manage their own preferences,
+// sessions and API tokens (which can only read, too). Returns false when the request was refused.
+func (s *Server) allowRole(c *gin.Context, role string) bool {
+	if role != constants.UserRoleViewer {
+		return true
+	}
+	switch c.Request.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	}
+	if strings.HasPrefix(c.FullPath(), "/api/me/") || strings.HasPrefix(c.FullPath(), "/api/tokens") {
+		return true
+	}
+	c.JSON(http.StatusForbidden, ErrorResponse{Error: "read-only role", Details: "viewers can't make changes; ask an admin"})
+	c.Abort()
+	return false
+}
+
+// isUserRole reports whether role is one the primary hands out
+func isUserRole(role string) bool {
+	return role == constants.UserRoleAdmin || role == constants.UserRoleViewer
+}
+
+// verifyRequestSignature checks the signature of a request authenticated with key, the gateway or
+// a node API key. Unsigned requests pass unless REQUIRE_SIGNED_REQUESTS is set. Responds and
+// returns false when the request is refused.
+func (s *Server) verifyRequestSignature(c *gin.Context, key string) bool {
+	if !reqsign.IsSigned(c.Request) && !s.config.Security.RequireSignedRequests {
+		return true
+	}
+	body, err := io.ReadAll(c.Request.Body)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Invalid request body", Details: err.Error()})
+		c.Abort()
+		return false
+	}
+	c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+	if err := s.requestVerifier.Verify(c.Request, key, body); err != nil {
+		slog.WarnContext(c.Request.Context(), "request signature rejected", "path", c.Request.URL.Path, "remote", c.ClientIP(), "error", err)
+		c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "invalid request signature", Details: err.Error()})
+		c.Abort()
+		return false
+	}
+	return true
+}
+
 // resolveNodeMiddleware sets node_id_param from query for user-authenticated requests.
 // When request_scope is already set (node auth), does nothing. Otherwise requires node_id query and sets node_id_param.
 // Used on resource-by-id routes so handlers get target node from context.
@@ -0,0 +1,44 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// getSessions returns the signed-in user's active sessions, marking the current one
+func (s *Server) getSessions(c *gin.Context) {
+	user, ok := getUserFromContext(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "Not authenticated"})
+		return
+	}
+
+	sessions, err := s.sessions.ListSessions(c.Request.Context(), user.Name, c.GetString("session_id"))
+	if err != nil {
+		s.handleServiceError(c, "list sessions", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, sessions)
+}
+
+// revokeSession signs one of the user's sessions out; revoking the current one signs out here too
+func (s *Server) revokeSession(c *gin.Context) {
+	user, ok := getUserFromContext(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, ErrorResponse{Error: "Not authenticated"})
+		return
+	}
+
+	id := c.Param("id")
+	if err := s.sessions.RevokeSession(c.Request.Context(), user.Name, id); err != nil {
+		s.handleServiceError(c, "revoke session", err)
+		return
+	}
+	if id == c.GetString("session_id") {
+		s.authService.TokenService().Reset(c.Writer)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Session revoked"})
+}
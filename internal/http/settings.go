@@ -7,6 +7,7 @@ import (
 	"strings"
 
 	"github.com/gin-gonic/gin"
+	"github.com/selfhostly/internal/constants"
 )
 
 // UpdateSettingsRequest represents an update settings request
@@ -14,6 +15,17 @@ type UpdateSettingsRequest struct {
 	AutoStartApps        bool   `json:"auto_start_apps"`
 	ActiveTunnelProvider string `json:"active_tunnel_provider"`
 	TunnelProviderConfig string `json:"tunnel_provider_config"`
+	TelemetryEnabled     *bool  `json:"telemetry_enabled,omitempty"` // Left unchanged when omitted
+}
+
+// UpdateFeatureFlagRequest represents a request to switch a feature flag
+type UpdateFeatureFlagRequest struct {
+	Enabled *bool `json:"enabled" binding:"required"`
+}
+
+// TestSMTPRequest names who a test email is sent to
+type TestSMTPRequest struct {
+	To string `json:"to" binding:"required"`
 }
 
 // getSettingsDispatch returns settings: when node auth (request_scope=local) calls getSettingsForNode, else getSettings
@@ -48,6 +60,7 @@ func (s *Server) getSettings(c *gin.Context) {
 		"auto_start_apps":        settings.AutoStartApps,
 		"active_tunnel_provider": activeTunnelProvider,
 		"tunnel_provider_config": tunnelProviderConfig,
+		"telemetry_enabled":      settings.TelemetryEnabled,
 		"updated_at":             settings.UpdatedAt,
 	}
 
@@ -81,6 +94,9 @@ func (s *Server) updateSettings(c *gin.Context) {
 	if req.TunnelProviderConfig != "" {
 		settings.TunnelProviderConfig = &req.TunnelProviderConfig
 	}
+	if req.TelemetryEnabled != nil {
+		settings.TelemetryEnabled = *req.TelemetryEnabled
+	}
 
 	if err := s.database.UpdateSettings(settings); err != nil {
 		slog.ErrorContext(c.Request.Context(), "failed to update settings", "error", err)
@@ -104,12 +120,122 @@ func (s *Server) updateSettings(c *gin.Context) {
 		"auto_start_apps":        settings.AutoStartApps,
 		"active_tunnel_provider": activeTunnelProvider,
 		"tunnel_provider_config": tunnelProviderConfig,
+		"telemetry_enabled":      settings.TelemetryEnabled,
 		"updated_at":             settings.UpdatedAt,
 	}
 
 	c.JSON(http.StatusOK, response)
 }
 
+// previewTelemetry returns the exact usage report that would be sent if telemetry were enabled
+func (s *Server) previewTelemetry(c *gin.Context) {
+	report, err := s.telemetryService.Preview(c.Request.Context())
+	if err != nil {
+		s.handleServiceError(c, "preview telemetry", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+// listFeatureFlags returns every experimental feature flag with its effective value
+func (s *Server) listFeatureFlags(c *gin.Context) {
+	flags, err := s.featureService.ListFeatureFlags(c.Request.Context())
+	if err != nil {
+		s.handleServiceError(c, "list feature flags", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, flags)
+}
+
+// updateFeatureFlag switches a feature flag on or off for this install
+func (s *Server) updateFeatureFlag(c *gin.Context) {
+	var req UpdateFeatureFlagRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Invalid request format", Details: "enabled is required"})
+		return
+	}
+
+	flag, err := s.featureService.SetFeatureFlag(c.Request.Context(), c.Param("name"), *req.Enabled)
+	if err != nil {
+		s.handleServiceError(c, "update feature flag", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, flag)
+}
+
+// getSettingsSchema documents every settings section and its fields
+func (s *Server) getSettingsSchema(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"sections": s.settingsService.ListSettingsSchema(c.Request.Context())})
+}
+
+// getSettingsSection returns one settings section's values
+func (s *Server) getSettingsSection(c *gin.Context) {
+	section, err := s.settingsService.GetSettingsSection(c.Request.Context(), c.Param("section"))
+	if err != nil {
+		s.handleServiceError(c, "get settings section", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, section)
+}
+
+// updateSettingsSection validates and saves the fields sent for one settings section
+func (s *Server) updateSettingsSection(c *gin.Context) {
+	var values map[string]interface{}
+	if err := c.ShouldBindJSON(&values); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Invalid request format", Details: "expected a JSON object of setting names to values"})
+		return
+	}
+
+	changedBy := ""
+	if user, ok := getUserFromContext(c); ok {
+		changedBy = user.Name
+	}
+	section, err := s.settingsService.UpdateSettingsSection(c.Request.Context(), c.Param("section"), values, changedBy)
+	if err != nil {
+		s.handleServiceError(c, "update settings section", err)
+		return
+	}
+
+	if section.Section == constants.SettingsSectionLogForwarding {
+		if err := s.logForwarding.ApplyLogForwarding(c.Request.Context()); err != nil {
+			slog.WarnContext(c.Request.Context(), "log forwarding settings saved but not applied to apps", "error", err)
+		}
+	}
+
+	c.JSON(http.StatusOK, section)
+}
+
+// getSettingsHistory returns the recent changes to one settings section
+func (s *Server) getSettingsHistory(c *gin.Context) {
+	history, err := s.settingsService.ListSettingsHistory(c.Request.Context(), c.Param("section"))
+	if err != nil {
+		s.handleServiceError(c, "get settings history", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"section": c.Param("section"), "history": history})
+}
+
+// testSMTPSettings sends a test email through the saved SMTP settings
+func (s *Server) testSMTPSettings(c *gin.Context) {
+	var req TestSMTPRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Invalid request format", Details: "to is required"})
+		return
+	}
+
+	if err := s.settingsService.SendTestEmail(c.Request.Context(), req.To); err != nil {
+		s.handleServiceError(c, "send test email", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Test email sent", "to": req.To})
+}
+
 // maskToken masks sensitive token data
 func maskToken(token string) string {
 	if token == "" {
@@ -121,7 +247,7 @@ func maskToken(token string) string {
 	return token[:4] + "****" + token[len(token)-4:]
 }
 
-// maskTokensInProviderConfig masks all api_token fields in the provider config JSON
+// maskTokensInProviderConfig masks the api_token and auth_token fields in the provider config JSON
 func maskTokensInProviderConfig(configJSON string) string {
 	if configJSON == "" {
 		return configJSON
@@ -136,11 +262,13 @@ func maskTokensInProviderConfig(configJSON string) string {
 	// Mask tokens in all providers
 	for providerName, providerConfig := range providerConfigs {
 		if configMap, ok := providerConfig.(map[string]interface{}); ok {
-			if apiToken, ok := configMap["api_token"].(string); ok && apiToken != "" {
-				// Only mask if not already masked
-				if !strings.Contains(apiToken, "****") {
-					configMap["api_token"] = maskToken(apiToken)
-					providerConfigs[providerName] = configMap
+			for _, key := range []string{"api_token", "auth_token"} {
+				if token, ok := configMap[key].(string); ok && token != "" {
+					// Only mask if not already masked
+					if !strings.Contains(token, "****") {
+						configMap[key] = maskToken(token)
+						providerConfigs[providerName] = configMap
+					}
 				}
 			}
 		}
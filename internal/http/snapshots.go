@@ -0,0 +1,121 @@
+package http
+
+import (
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/selfhostly/internal/db"
+	"github.com/selfhostly/internal/domain"
+)
+
+// RestoreSnapshotRequest controls a snapshot restore
+type RestoreSnapshotRequest struct {
+	// RestartContainers redeploys the app from the snapshot via an app_update job. Defaults to
+	// true; send false to only restore the files.
+	RestartContainers *bool `json:"restart_containers"`
+}
+
+// listAppSnapshots returns the app's snapshots
+func (s *Server) listAppSnapshots(c *gin.Context) {
+	snapshots, err := s.snapshotService.ListSnapshots(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		s.handleServiceError(c, "list snapshots", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, snapshots)
+}
+
+// getAppSnapshot returns one of the app's snapshots
+func (s *Server) getAppSnapshot(c *gin.Context) {
+	snapshot, err := s.snapshotService.GetSnapshot(c.Request.Context(), c.Param("id"), c.Param("snapshotId"))
+	if err != nil {
+		s.handleServiceError(c, "get snapshot", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, snapshot)
+}
+
+// createAppSnapshot captures the app's compose files, .env and image digests under a name
+func (s *Server) createAppSnapshot(c *gin.Context) {
+	var req domain.CreateSnapshotRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Invalid request format", Details: "name is required"})
+		return
+	}
+
+	var createdBy *string
+	if user, ok := getUserFromContext(c); ok && user.Name != "" {
+		createdBy = &user.Name
+	}
+
+	snapshot, err := s.snapshotService.CreateSnapshot(c.Request.Context(), c.Param("id"), req, createdBy)
+	if err != nil {
+		s.handleServiceError(c, "create snapshot", err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, snapshot)
+}
+
+// deleteAppSnapshot removes one of the app's snapshots
+func (s *Server) deleteAppSnapshot(c *gin.Context) {
+	if err := s.snapshotService.DeleteSnapshot(c.Request.Context(), c.Param("id"), c.Param("snapshotId")); err != nil {
+		s.handleServiceError(c, "delete snapshot", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Snapshot deleted successfully"})
+}
+
+// restoreAppSnapshot restores a snapshot as a new compose version and, unless told otherwise,
+// redeploys the app from it
+func (s *Server) restoreAppSnapshot(c *gin.Context) {
+	id := c.Param("id")
+
+	var req RestoreSnapshotRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Invalid request format"})
+		return
+	}
+
+	var changedBy *string
+	if user, ok := getUserFromContext(c); ok && user.Name != "" {
+		changedBy = &user.Name
+	}
+
+	newVersion, err := s.snapshotService.RestoreSnapshot(c.Request.Context(), id, c.Param("snapshotId"), changedBy)
+	if err != nil {
+		s.handleServiceError(c, "restore snapshot", err)
+		return
+	}
+
+	response := gin.H{
+		"message":     "Snapshot restored successfully",
+		"new_version": newVersion,
+	}
+
+	if req.RestartContainers == nil || *req.RestartContainers {
+		job, err := s.appService.UpdateAppContainersAsync(c.Request.Context(), id, getNodeIDFromContext(c))
+		if err != nil {
+			slog.WarnContext(c.Request.Context(), "restored snapshot but failed to start container update", "appID", id, "error", err)
+			response["message"] = "Snapshot restored, but containers could not be updated; run an update to apply it"
+		} else {
+			response["message"] = "Snapshot restored, updating containers in background"
+			response["job_id"] = job.ID
+			response["job"] = job
+		}
+	}
+
+	var app *db.App
+	if nodeID := getNodeIDFromContext(c); nodeID != "" {
+		app, _ = s.appService.GetApp(c.Request.Context(), id, nodeID)
+	}
+	response["app"] = app
+
+	c.JSON(http.StatusOK, response)
+}
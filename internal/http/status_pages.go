@@ -0,0 +1,101 @@
+package http
+
+import (
+	"html/template"
+	"log/slog"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// getAppStatusPage returns whether the app has a status page; its link was only shown when enabled
+func (s *Server) getAppStatusPage(c *gin.Context) {
+	page, err := s.statusPages.GetStatusPage(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		s.handleServiceError(c, "get status page", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, page)
+}
+
+// enableAppStatusPage gives the app a status page, or a new link for the one it has
+func (s *Server) enableAppStatusPage(c *gin.Context) {
+	user, _ := getUserFromContext(c)
+	page, err := s.statusPages.EnableStatusPage(c.Request.Context(), c.Param("id"), user.Name, s.publicBaseURL(c))
+	if err != nil {
+		s.handleServiceError(c, "enable status page", err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, page)
+}
+
+// disableAppStatusPage deletes the app's status page
+func (s *Server) disableAppStatusPage(c *gin.Context) {
+	if err := s.statusPages.DisableStatusPage(c.Request.Context(), c.Param("id")); err != nil {
+		s.handleServiceError(c, "disable status page", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Status page disabled"})
+}
+
+// getPublicAppStatus serves a status page to anyone with its link: JSON by default, HTML for
+// browsers or ?format=html. It can be fetched from and framed by any site.
+func (s *Server) getPublicAppStatus(c *gin.Context) {
+	header := c.Writer.Header()
+	header.Del("X-Frame-Options")
+	header.Del("Access-Control-Allow-Credentials")
+	header.Set("Access-Control-Allow-Origin", "*")
+
+	report, err := s.statusPages.GetAppStatus(c.Request.Context(), c.Param("token"))
+	if err != nil {
+		s.handleServiceError(c, "get app status", err)
+		return
+	}
+
+	if c.Query("format") == "html" || (c.Query("format") == "" && c.NegotiateFormat(gin.MIMEJSON, gin.MIMEHTML) == gin.MIMEHTML) {
+		header.Set("Content-Type", "text/html; charset=utf-8")
+		c.Status(http.StatusOK)
+		if err := statusPageTemplate.Execute(c.Writer, report); err != nil {
+			slog.WarnContext(c.Request.Context(), "failed to render status page", "app", report.App, "error", err)
+		}
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
+
+// statusPageTemplate renders a domain.AppStatusReport as a self-contained page
+var statusPageTemplate = template.Must(template.New("status").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<meta http-equiv="refresh" content="60">
+<title>{{.App}} status</title>
+<style>
+body { font-family: system-ui, sans-serif; margin: 0; padding: 2rem; color: #1f2937; background: #f9fafb; }
+main { max-width: 32rem; margin: 0 auto; background: #fff; border-radius: 0.5rem; padding: 1.5rem; box-shadow: 0 1px 3px rgba(0,0,0,0.1); }
+h1 { font-size: 1.25rem; margin: 0 0 1rem; }
+.status { display: inline-block; padding: 0.25rem 0.75rem; border-radius: 9999px; font-weight: 600; color: #fff; background: #6b7280; }
+.operational { background: #16a34a; } .degraded { background: #d97706; } .down { background: #dc2626; } .maintenance { background: #2563eb; }
+dl { display: grid; grid-template-columns: auto 1fr; gap: 0.5rem 1rem; margin: 1.25rem 0 0; }
+dt { color: #6b7280; } dd { margin: 0; }
+footer { margin-top: 1.25rem; font-size: 0.75rem; color: #9ca3af; }
+</style>
+</head>
+<body>
+<main>
+<h1>{{.App}}</h1>
+<span class="status {{.Status}}">{{.Status}}</span>
+<dl>
+<dt>Since</dt><dd>{{.Since.UTC.Format "2006-01-02 15:04 MST"}}</dd>
+<dt>Uptime</dt><dd>{{printf "%.2f" .UptimePercent}}% over {{.UptimeWindowDays}} days</dd>
+<dt>Last incident</dt><dd>{{with .LastIncident}}{{.Status}} from {{.StartedAt.UTC.Format "2006-01-02 15:04 MST"}}{{if .ResolvedAt}} to {{.ResolvedAt.UTC.Format "2006-01-02 15:04 MST"}}{{else}}, ongoing{{end}}{{else}}none{{end}}</dd>
+</dl>
+<footer>Updated {{.GeneratedAt.UTC.Format "2006-01-02 15:04 MST"}}</footer>
+</main>
+</body>
+</html>
+`))
@@ -4,6 +4,7 @@ import (
 	"net/http"
 
 	"github.com/gin-gonic/gin"
+	"github.com/selfhostly/internal/constants"
 	"github.com/selfhostly/internal/domain"
 	"github.com/selfhostly/internal/httputil"
 	"github.com/selfhostly/internal/validation"
@@ -32,6 +33,105 @@ func (s *Server) getSystemStats(c *gin.Context) {
 	c.JSON(http.StatusOK, stats)
 }
 
+// getPlatformHealth returns the aggregated health of this node's subsystems. Responds 503 when
+// any check is unhealthy so monitors can alert on the status code alone.
+func (s *Server) getPlatformHealth(c *gin.Context) {
+	report := s.healthService.CheckHealth(c.Request.Context())
+
+	status := http.StatusOK
+	if report.Status == constants.HealthStatusUnhealthy {
+		status = http.StatusServiceUnavailable
+	}
+	c.JSON(status, report)
+}
+
+// getConsistencyAudit returns the latest consistency audit of this node's database
+func (s *Server) getConsistencyAudit(c *gin.Context) {
+	report, err := s.auditService.LatestAudit(c.Request.Context())
+	if err != nil {
+		s.handleServiceError(c, "get consistency audit", err)
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
+
+// getDatabaseStats returns the size and fragmentation of this node's database and what the last
+// maintenance pass did
+func (s *Server) getDatabaseStats(c *gin.Context) {
+	stats, err := s.database.Stats()
+	if err != nil {
+		s.handleServiceError(c, "get database stats", err)
+		return
+	}
+	stats.LastMaintenance = s.dbMaintainer.LastRun()
+	c.JSON(http.StatusOK, stats)
+}
+
+// listConsistencyAudits returns the stored consistency audits of this node's database, newest first
+func (s *Server) listConsistencyAudits(c *gin.Context) {
+	reports, err := s.auditService.ListAudits(c.Request.Context())
+	if err != nil {
+		s.handleServiceError(c, "list consistency audits", err)
+		return
+	}
+	c.JSON(http.StatusOK, reports)
+}
+
+// runConsistencyAudit runs the consistency audit now instead of waiting for the weekly run
+func (s *Server) runConsistencyAudit(c *gin.Context) {
+	report, err := s.auditService.RunAudit(c.Request.Context())
+	if err != nil {
+		s.handleServiceError(c, "run consistency audit", err)
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
+
+// reloadConfig re-reads this node's reload-safe settings, as SIGHUP does
+func (s *Server) reloadConfig(c *gin.Context) {
+	result, err := s.systemService.ReloadConfig(c.Request.Context())
+	if err != nil {
+		s.handleServiceError(c, "reload config", err)
+		return
+	}
+	c.JSON(http.StatusOK, result)
+}
+
+// logSettingsNodeID is the node whose logging a request targets: ?node_id=, or this node for
+// node-to-node calls and when it is omitted
+func (s *Server) logSettingsNodeID(c *gin.Context) string {
+	if scope, ok := c.Get("request_scope"); ok && scope == "local" {
+		return s.config.Node.ID
+	}
+	return c.Query("node_id")
+}
+
+// getLogSettings returns a node's current log level and output format
+func (s *Server) getLogSettings(c *gin.Context) {
+	settings, err := s.systemService.GetLogSettings(c.Request.Context(), s.logSettingsNodeID(c))
+	if err != nil {
+		s.handleServiceError(c, "get log settings", err)
+		return
+	}
+	c.JSON(http.StatusOK, settings)
+}
+
+// updateLogSettings switches a node's log level or JSON/text output without a restart
+func (s *Server) updateLogSettings(c *gin.Context) {
+	var req domain.UpdateLogSettingsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Invalid request format", Details: "level must be a string and json a boolean"})
+		return
+	}
+
+	settings, err := s.systemService.UpdateLogSettings(c.Request.Context(), s.logSettingsNodeID(c), req)
+	if err != nil {
+		s.handleServiceError(c, "update log settings", err)
+		return
+	}
+	c.JSON(http.StatusOK, settings)
+}
+
 // restartContainer restarts a specific container by ID
 func (s *Server) restartContainer(c *gin.Context) {
 	containerID, err := httputil.ValidateAndGetContainerID(c)
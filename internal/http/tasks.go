@@ -0,0 +1,101 @@
+package http
+
+import (
+	"log/slog"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/selfhostly/internal/domain"
+)
+
+// listAppTasks returns the app's recurring tasks
+func (s *Server) listAppTasks(c *gin.Context) {
+	tasks, err := s.taskService.ListTasks(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		s.handleServiceError(c, "list tasks", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, tasks)
+}
+
+// createAppTask adds a recurring task and schedules it
+func (s *Server) createAppTask(c *gin.Context) {
+	var req domain.CreateTaskRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Invalid request format", Details: "name, service, command and cron are required"})
+		return
+	}
+
+	task, err := s.taskService.CreateTask(c.Request.Context(), c.Param("id"), req)
+	if err != nil {
+		s.handleServiceError(c, "create task", err)
+		return
+	}
+
+	if err := s.scheduler.UpdateTask(task); err != nil {
+		slog.ErrorContext(c.Request.Context(), "failed to schedule task", "task_id", task.ID, "error", err)
+	}
+
+	c.JSON(http.StatusCreated, task)
+}
+
+// updateAppTask changes a recurring task and reschedules it
+func (s *Server) updateAppTask(c *gin.Context) {
+	var req domain.UpdateTaskRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Invalid request format", Details: err.Error()})
+		return
+	}
+
+	task, err := s.taskService.UpdateTask(c.Request.Context(), c.Param("id"), c.Param("taskId"), req)
+	if err != nil {
+		s.handleServiceError(c, "update task", err)
+		return
+	}
+
+	if err := s.scheduler.UpdateTask(task); err != nil {
+		slog.ErrorContext(c.Request.Context(), "failed to reschedule task", "task_id", task.ID, "error", err)
+	}
+
+	c.JSON(http.StatusOK, task)
+}
+
+// deleteAppTask removes a recurring task and its run history
+func (s *Server) deleteAppTask(c *gin.Context) {
+	taskID := c.Param("taskId")
+	if err := s.taskService.DeleteTask(c.Request.Context(), c.Param("id"), taskID); err != nil {
+		s.handleServiceError(c, "delete task", err)
+		return
+	}
+
+	s.scheduler.RemoveTask(taskID)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Task deleted successfully"})
+}
+
+// getAppTaskRuns returns a recurring task's recent runs with their exit codes and output
+func (s *Server) getAppTaskRuns(c *gin.Context) {
+	runs, err := s.taskService.GetTaskRuns(c.Request.Context(), c.Param("id"), c.Param("taskId"))
+	if err != nil {
+		s.handleServiceError(c, "get task runs", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, runs)
+}
+
+// runAppTask runs a recurring task now, as a background job
+func (s *Server) runAppTask(c *gin.Context) {
+	job, err := s.taskService.RunTaskAsync(c.Request.Context(), c.Param("id"), c.Param("taskId"))
+	if err != nil {
+		s.handleServiceError(c, "create task run job", err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"job_id":  job.ID,
+		"status":  job.Status,
+		"message": "Task run started in background",
+	})
+}
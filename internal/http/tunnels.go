@@ -8,6 +8,7 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/selfhostly/internal/constants"
 	"github.com/selfhostly/internal/db"
+	"github.com/selfhostly/internal/docker"
 	"github.com/selfhostly/internal/domain"
 	"github.com/selfhostly/internal/httputil"
 	"github.com/selfhostly/internal/tunnel"
@@ -57,9 +58,48 @@ func (s *Server) GetProviderFeatures(c *gin.Context) {
 	c.JSON(http.StatusOK, features)
 }
 
+// ListProviderZones lists the DNS zones a provider can route hostnames in, for ingress editors
+// GET /api/tunnels/providers/:provider/zones
+func (s *Server) ListProviderZones(c *gin.Context) {
+	ctx := c.Request.Context()
+	providerName := c.Param("provider")
+
+	zones, err := s.tunnelService.ListProviderZones(ctx, providerName)
+	if err != nil {
+		s.handleServiceError(c, "list zones", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"provider": providerName,
+		"zones":    zones,
+	})
+}
+
+// ListZoneHostnames lists the existing DNS records in a zone, so ingress editors can flag
+// hostnames that already point somewhere else before the rules are submitted
+// GET /api/tunnels/providers/:provider/zones/:zoneID/hostnames
+func (s *Server) ListZoneHostnames(c *gin.Context) {
+	ctx := c.Request.Context()
+	providerName := c.Param("provider")
+	zoneID := c.Param("zoneID")
+
+	hostnames, err := s.tunnelService.ListZoneHostnames(ctx, providerName, zoneID)
+	if err != nil {
+		s.handleServiceError(c, "list zone hostnames", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"zone_id":   zoneID,
+		"hostnames": hostnames,
+	})
+}
+
 // tunnelByAppEnvelope is the single response shape for GET /api/tunnels/apps/:appId (primary and secondary).
-// Always returned so primary vs secondary responses are consistent.
-func tunnelByAppEnvelope(appID, nodeID, tunnelMode, publicURL string, tun *db.CloudflareTunnel) gin.H {
+// Always returned so primary vs secondary responses are consistent. replicas is how many containers
+// run the tunnel.
+func tunnelByAppEnvelope(appID, nodeID, tunnelMode, publicURL string, tun *db.CloudflareTunnel, replicas int) gin.H {
 	env := gin.H{
 		"tunnel":      nil,
 		"app_id":      appID,
@@ -84,6 +124,8 @@ func tunnelByAppEnvelope(appID, nodeID, tunnelMode, publicURL string, tun *db.Cl
 			"created_at":     tun.CreatedAt,
 			"updated_at":     tun.UpdatedAt,
 			"last_synced_at": tun.LastSyncedAt,
+			"replicas":       replicas,
+			"options":        tun.Options,
 			"error_details": func() string {
 				if tun.ErrorDetails != nil {
 					return *tun.ErrorDetails
@@ -119,7 +161,7 @@ func (s *Server) GetTunnelByAppIDGeneric(c *gin.Context) {
 	tun, err := s.tunnelService.GetTunnelByAppID(ctx, appID, nodeID)
 	if err != nil {
 		if domain.IsNotFoundError(err) {
-			c.JSON(http.StatusOK, tunnelByAppEnvelope(appID, nodeID, app.TunnelMode, app.PublicURL, nil))
+			c.JSON(http.StatusOK, tunnelByAppEnvelope(appID, nodeID, app.TunnelMode, app.PublicURL, nil, 0))
 			return
 		}
 		slog.ErrorContext(ctx, "failed to get tunnel", "appID", appID, "error", err)
@@ -131,13 +173,66 @@ func (s *Server) GetTunnelByAppIDGeneric(c *gin.Context) {
 	if tun.PublicURL != "" {
 		publicURL = tun.PublicURL
 	}
-	c.JSON(http.StatusOK, tunnelByAppEnvelope(appID, nodeID, constants.TunnelModeCustom, publicURL, tun))
+	c.JSON(http.StatusOK, tunnelByAppEnvelope(appID, nodeID, constants.TunnelModeCustom, publicURL, tun, docker.TunnelReplicas(app.TunnelComposeSource())))
+}
+
+// getAppTunnelStatus reports the connectors serving an app's named tunnel, telling a tunnel
+// nothing runs apart from a healthy one.
+// GET /api/apps/:id/tunnel/status
+func (s *Server) getAppTunnelStatus(c *gin.Context) {
+	appID := c.Param("id")
+
+	nodeID := getNodeIDFromContext(c)
+	if nodeID == "" {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "node_id is required"})
+		return
+	}
+
+	status, err := s.tunnelService.GetTunnelConnectorStatus(c.Request.Context(), appID, nodeID)
+	if err != nil {
+		s.handleServiceError(c, "get tunnel status", err)
+		return
+	}
+	c.JSON(http.StatusOK, status)
+}
+
+// getAppTraffic reports the HTTP traffic served for an app's tunnel hostnames, ?period=1h, 24h
+// (default) or 7d
+// GET /api/apps/:id/traffic
+func (s *Server) getAppTraffic(c *gin.Context) {
+	appID := c.Param("id")
+
+	nodeID := getNodeIDFromContext(c)
+	if nodeID == "" {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "node_id is required"})
+		return
+	}
+
+	traffic, err := s.tunnelService.GetAppTraffic(c.Request.Context(), appID, nodeID, c.Query("period"))
+	if err != nil {
+		s.handleServiceError(c, "get app traffic", err)
+		return
+	}
+	c.JSON(http.StatusOK, traffic)
 }
 
-// ListTunnelsGeneric lists all tunnels using provider abstraction
+// ListTunnelsGeneric lists all tunnels using provider abstraction.
+// With ?inventory=true it returns every tunnel across nodes and the provider account, flagging orphans.
 // GET /api/tunnels
 func (s *Server) ListTunnelsGeneric(c *gin.Context) {
 	ctx := c.Request.Context()
+	if c.Query("inventory") == "true" {
+		// The inventory includes orphaned tunnels, which have no app labels to match
+		if refuseSelector(c); c.IsAborted() {
+			return
+		}
+		s.listTunnelInventory(c)
+		return
+	}
+	sel, ok := parseSelector(c)
+	if !ok {
+		return
+	}
 	var nodeIDs []string
 	if scope, ok := c.Get("request_scope"); ok && scope == "local" {
 		nodeIDs = []string{s.config.Node.ID}
@@ -154,6 +249,21 @@ func (s *Server) ListTunnelsGeneric(c *gin.Context) {
 		return
 	}
 
+	if len(sel) > 0 {
+		appIDs, err := s.selectedAppIDs(ctx, sel, nodeIDs)
+		if err != nil {
+			s.handleServiceError(c, "list tunnels", err)
+			return
+		}
+		matched := tunnels[:0]
+		for _, t := range tunnels {
+			if appIDs[t.AppID] {
+				matched = append(matched, t)
+			}
+		}
+		tunnels = matched
+	}
+
 	// Tunnel is source of truth for public_url. Only set node_id from app (and fallback public_url for legacy rows).
 	for _, t := range tunnels {
 		app, err := s.database.GetApp(t.AppID)
@@ -176,6 +286,74 @@ func (s *Server) ListTunnelsGeneric(c *gin.Context) {
 	})
 }
 
+// listTunnelInventory responds with the cross-node tunnel inventory
+func (s *Server) listTunnelInventory(c *gin.Context) {
+	inventory, err := s.tunnelService.ListTunnelInventory(c.Request.Context())
+	if err != nil {
+		s.handleServiceError(c, "list tunnel inventory", err)
+		return
+	}
+	c.JSON(http.StatusOK, inventory)
+}
+
+// DeleteOrphanedTunnelGeneric deletes a tunnel that no app uses any more from the provider account.
+// Tunnels still linked to an app must be removed through DELETE /api/tunnels/apps/:appId.
+// DELETE /api/tunnels/:tunnelID
+func (s *Server) DeleteOrphanedTunnelGeneric(c *gin.Context) {
+	ctx := c.Request.Context()
+	tunnelID := c.Param("tunnelID")
+
+	if s.awaitApproval(c, constants.ApprovalOperationDeleteOrphanedTunnel, tunnelID, nil) {
+		return
+	}
+
+	slog.InfoContext(ctx, "deleting orphaned tunnel", "tunnelID", tunnelID)
+	if err := s.runDeleteOrphanedTunnel(c, tunnelID); err != nil {
+		s.handleServiceError(c, "delete tunnel", err)
+	}
+}
+
+// runDeleteOrphanedTunnel deletes a tunnel no app uses and writes the response; errors are left to the caller
+func (s *Server) runDeleteOrphanedTunnel(c *gin.Context, tunnelID string) error {
+	if err := s.tunnelService.DeleteOrphanedTunnel(c.Request.Context(), tunnelID); err != nil {
+		return err
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"tunnel_id": tunnelID,
+		"message":   "Tunnel deleted",
+	})
+	return nil
+}
+
+// ImportTunnelGeneric adopts a tunnel that already exists in the provider account. The body names
+// either an app without a tunnel (app_id) or a new app to create around the tunnel (app_name).
+// POST /api/tunnels/import (with node_id)
+func (s *Server) ImportTunnelGeneric(c *gin.Context) {
+	ctx := c.Request.Context()
+	nodeID := getNodeIDFromContext(c)
+	if nodeID == "" {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "node_id is required"})
+		return
+	}
+	var req domain.ImportTunnelRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		slog.WarnContext(ctx, "invalid tunnel import request", "error", err)
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Invalid request format"})
+		return
+	}
+
+	slog.InfoContext(ctx, "importing tunnel", "tunnelID", req.TunnelID, "appID", req.AppID, "nodeID", nodeID)
+
+	app, err := s.appService.ImportTunnel(ctx, req)
+	if err != nil {
+		s.handleServiceError(c, "import tunnel", err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, app)
+}
+
 // SyncTunnelStatusGeneric syncs tunnel status (if provider supports it)
 // POST /api/tunnels/apps/:appId/sync
 func (s *Server) SyncTunnelStatusGeneric(c *gin.Context) {
@@ -244,10 +422,90 @@ func (s *Server) UpdateTunnelIngressGeneric(c *gin.Context) {
 		slog.WarnContext(ctx, "failed to restart tunnel container", "appID", appID, "error", err)
 	}
 
+	// Rules pointing at services the compose doesn't define are saved anyway, but flagged
+	warnings, err := s.tunnelService.CheckIngressTargets(ctx, appID, req.IngressRules)
+	if err != nil {
+		slog.WarnContext(ctx, "failed to check ingress targets", "appID", appID, "error", err)
+	}
+	if warnings == nil {
+		warnings = []domain.IngressWarning{}
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"message":       "ingress rules updated successfully",
 		"appID":         appID,
 		"ingress_rules": req.IngressRules,
+		"warnings":      warnings,
+	})
+}
+
+// setTunnelReplicasRequest is the body for PUT /api/tunnels/apps/:appId/replicas
+type setTunnelReplicasRequest struct {
+	Replicas int `json:"replicas" binding:"required,min=1"`
+}
+
+// SetTunnelReplicasGeneric sets how many containers run an app's named tunnel
+// PUT /api/tunnels/apps/:appId/replicas
+func (s *Server) SetTunnelReplicasGeneric(c *gin.Context) {
+	ctx := c.Request.Context()
+	appID := c.Param("appId")
+
+	nodeID := getNodeIDFromContext(c)
+	if nodeID == "" {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "node_id is required"})
+		return
+	}
+
+	var req setTunnelReplicasRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "replicas must be a number of at least 1"})
+		return
+	}
+
+	slog.InfoContext(ctx, "setting tunnel replicas", "appID", appID, "nodeID", nodeID, "replicas", req.Replicas)
+
+	if _, err := s.appService.SetTunnelReplicas(ctx, appID, nodeID, req.Replicas); err != nil {
+		s.handleServiceError(c, "set tunnel replicas", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":  "tunnel replicas updated",
+		"app_id":   appID,
+		"replicas": req.Replicas,
+	})
+}
+
+// SetTunnelOptionsGeneric saves connector options (edge protocol, IP version, post-quantum,
+// log level) on an app's named tunnel; empty fields keep the provider's defaults
+// PUT /api/tunnels/apps/:appId/options
+func (s *Server) SetTunnelOptionsGeneric(c *gin.Context) {
+	ctx := c.Request.Context()
+	appID := c.Param("appId")
+
+	nodeID := getNodeIDFromContext(c)
+	if nodeID == "" {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "node_id is required"})
+		return
+	}
+
+	var req tunnel.ConnectorOptions
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Invalid request body"})
+		return
+	}
+
+	slog.InfoContext(ctx, "setting tunnel options", "appID", appID, "nodeID", nodeID, "protocol", req.Protocol, "edgeIPVersion", req.EdgeIPVersion, "postQuantum", req.PostQuantum, "logLevel", req.LogLevel)
+
+	if _, err := s.appService.SetTunnelOptions(ctx, appID, nodeID, req); err != nil {
+		s.handleServiceError(c, "set tunnel options", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "tunnel options updated",
+		"app_id":  appID,
+		"options": req,
 	})
 }
 
@@ -309,7 +567,7 @@ func (s *Server) CreateDNSRecordGeneric(c *gin.Context) {
 }
 
 // DeleteTunnelGeneric deletes a tunnel
-// DELETE /api/tunnels/apps/:appId
+// DELETE /api/tunnels/apps/:appId (?quick_tunnel=true replaces it with a Quick Tunnel to the service its ingress pointed at)
 func (s *Server) DeleteTunnelGeneric(c *gin.Context) {
 	ctx := c.Request.Context()
 	appID := c.Param("appId")
@@ -321,21 +579,36 @@ func (s *Server) DeleteTunnelGeneric(c *gin.Context) {
 		return
 	}
 
-	slog.InfoContext(ctx, "deleting tunnel", "appID", appID, "nodeID", nodeID)
+	opts := domain.DeleteTunnelOptions{QuickTunnelFallback: c.Query("quick_tunnel") == "true"}
+	if s.awaitApproval(c, constants.ApprovalOperationDeleteTunnel, appID, deleteTunnelParams(opts)) {
+		return
+	}
 
+	slog.InfoContext(ctx, "deleting tunnel", "appID", appID, "nodeID", nodeID, "quickTunnelFallback", opts.QuickTunnelFallback)
+	if err := s.runDeleteTunnel(c, appID, opts); err != nil {
+		s.handleServiceError(c, "delete tunnel job", err)
+	}
+}
+
+// runDeleteTunnel starts a job deleting an app's tunnel and writes the response; errors are left to the caller
+func (s *Server) runDeleteTunnel(c *gin.Context, appID string, opts domain.DeleteTunnelOptions) error {
 	// Create background job for tunnel deletion (async operation)
-	job, err := s.appService.DeleteTunnelAsync(ctx, appID)
+	job, err := s.appService.DeleteTunnelAsync(c.Request.Context(), appID, getNodeIDFromContext(c), opts)
 	if err != nil {
-		s.handleServiceError(c, "delete tunnel job", err)
-		return
+		return err
 	}
 
+	message := "Tunnel deletion started in background"
+	if opts.QuickTunnelFallback {
+		message = "Tunnel deletion and Quick Tunnel creation started in background"
+	}
 	c.JSON(http.StatusAccepted, gin.H{
 		"job_id":  job.ID,
 		"app_id":  job.AppID,
 		"status":  job.Status,
-		"message": "Tunnel deletion started in background",
+		"message": message,
 	})
+	return nil
 }
 
 // CreateTunnelForAppGeneric creates a named (custom domain) tunnel for an app that has none.
@@ -354,7 +627,7 @@ func (s *Server) CreateTunnelForAppGeneric(c *gin.Context) {
 	_ = c.ShouldBindJSON(&body)
 
 	// Create background job for tunnel creation (async operation)
-	job, err := s.appService.CreateTunnelForAppAsync(ctx, appID, body.IngressRules)
+	job, err := s.appService.CreateTunnelForAppAsync(ctx, appID, nodeID, body.IngressRules)
 	if err != nil {
 		s.handleServiceError(c, "create tunnel job", err)
 		return
@@ -385,7 +658,7 @@ func (s *Server) SwitchAppToCustomTunnelGeneric(c *gin.Context) {
 	_ = c.ShouldBindJSON(&body)
 
 	// Create background job for switching to custom tunnel (async operation)
-	job, err := s.appService.SwitchAppToCustomTunnelAsync(ctx, appID, body.IngressRules)
+	job, err := s.appService.SwitchAppToCustomTunnelAsync(ctx, appID, nodeID, body.IngressRules)
 	if err != nil {
 		s.handleServiceError(c, "switch to custom tunnel job", err)
 		return
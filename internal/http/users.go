@@ -0,0 +1,154 @@
+package http
+
+import (
+	"log/slog"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/selfhostly/internal/domain"
+)
+
+// listUsers returns the accounts of invited users; users in GITHUB_ALLOWED_USERS have none
+func (s *Server) listUsers(c *gin.Context) {
+	if _, ok := requireAdminUser(c); !ok {
+		return
+	}
+
+	users, err := s.users.ListUsers(c.Request.Context())
+	if err != nil {
+		s.handleServiceError(c, "list users", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"users": users, "allowed_users": s.config.Auth.GitHub.AllowedUsers})
+}
+
+// deleteUser deletes an invited user's account
+func (s *Server) deleteUser(c *gin.Context) {
+	if _, ok := requireAdminUser(c); !ok {
+		return
+	}
+
+	if err := s.users.DeleteUser(c.Request.Context(), c.Param("name")); err != nil {
+		s.handleServiceError(c, "delete user", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "User deleted"})
+}
+
+// inviteUser creates a single-use invite link; the link is only returned this once
+func (s *Server) inviteUser(c *gin.Context) {
+	invitedBy, ok := requireAdminUser(c)
+	if !ok {
+		return
+	}
+
+	var req domain.InviteUserRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Invalid request format", Details: err.Error()})
+		return
+	}
+	req.BaseURL = s.publicBaseURL(c)
+	req.InvitedBy = invitedBy
+
+	invitation, err := s.users.Invite(c.Request.Context(), req)
+	if err != nil {
+		s.handleServiceError(c, "invite user", err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, invitation)
+}
+
+// listInvitations returns every invitation with its status, newest first
+func (s *Server) listInvitations(c *gin.Context) {
+	if _, ok := requireAdminUser(c); !ok {
+		return
+	}
+
+	invitations, err := s.users.ListInvitations(c.Request.Context())
+	if err != nil {
+		s.handleServiceError(c, "list invitations", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, invitations)
+}
+
+// revokeInvitation revokes an invitation that hasn't been accepted
+func (s *Server) revokeInvitation(c *gin.Context) {
+	if _, ok := requireAdminUser(c); !ok {
+		return
+	}
+
+	if err := s.users.RevokeInvitation(c.Request.Context(), c.Param("id")); err != nil {
+		s.handleServiceError(c, "revoke invitation", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Invitation revoked"})
+}
+
+// acceptInvitation is where invite links point. It sends visitors to sign in with GitHub first and
+// back here afterwards, then creates their account and refreshes their session so it carries the
+// invited role.
+func (s *Server) acceptInvitation(c *gin.Context) {
+	if s.authService == nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Authentication is off", Details: "everyone has access while AUTH_ENABLED is off"})
+		return
+	}
+
+	claims, _, err := s.authService.TokenService().Get(c.Request)
+	if err != nil || claims.User == nil {
+		c.Redirect(http.StatusFound, "/auth/github/login?from="+url.QueryEscape(c.Request.URL.Path))
+		return
+	}
+
+	user, err := s.users.AcceptInvitation(c.Request.Context(), c.Param("token"), claims.User.Name)
+	if err != nil {
+		s.handleServiceError(c, "accept invitation", err)
+		return
+	}
+
+	claims.User.Role = user.Role
+	if _, err := s.authService.TokenService().Set(c.Writer, claims); err != nil {
+		slog.WarnContext(c.Request.Context(), "invitation accepted but session not refreshed", "user", user.Username, "error", err)
+	}
+	c.Redirect(http.StatusFound, "/")
+}
+
+// publicBaseURL returns the URL users reach this server at: the public host when the request came
+// through a proxy in TRUSTED_PROXIES, such as the gateway, else AUTH_BASE_URL. Anyone else could make
+// links point at a host of their choosing.
+func (s *Server) publicBaseURL(c *gin.Context) string {
+	if host := c.GetHeader("X-Forwarded-Host"); host != "" && s.fromTrustedProxy(c) {
+		proto := c.GetHeader("X-Forwarded-Proto")
+		if proto == "" {
+			proto = "http"
+		}
+		return proto + "://" + host
+	}
+	if s.config.Auth.BaseURL != "" {
+		return strings.TrimRight(s.config.Auth.BaseURL, "/")
+	}
+	return "http://" + c.Request.Host
+}
+
+// fromTrustedProxy reports whether the request was sent by a proxy in TRUSTED_PROXIES
+func (s *Server) fromTrustedProxy(c *gin.Context) bool {
+	addrPort, err := netip.ParseAddrPort(c.Request.RemoteAddr)
+	if err != nil {
+		return false
+	}
+	addr := addrPort.Addr().Unmap()
+	for _, proxy := range s.config.Auth.TrustedProxies {
+		if proxy.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,41 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/selfhostly/internal/domain"
+	"github.com/selfhostly/internal/secrets"
+)
+
+// generateSecret returns a random secret, so compose files and .env templates don't ship with
+// "changeme" passwords. Nothing is stored.
+func (s *Server) generateSecret(c *gin.Context) {
+	var req domain.GenerateSecretRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Invalid request format", Details: "length must be a number, charset and format strings"})
+			return
+		}
+	}
+
+	opts, err := secrets.Options{Length: req.Length, Charset: req.Charset, Format: req.Format}.Normalize()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Invalid secret options", Details: err.Error()})
+		return
+	}
+	secret, err := secrets.Generate(opts)
+	if err != nil {
+		s.handleServiceError(c, "generate secret", err)
+		return
+	}
+
+	// Secrets must not end up in shared caches
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, domain.GeneratedSecret{
+		Secret:  secret,
+		Length:  opts.Length,
+		Charset: opts.Charset,
+		Format:  opts.Format,
+	})
+}
@@ -0,0 +1,74 @@
+package http
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/selfhostly/internal/domain"
+)
+
+// listAppWebhooks returns the app's lifecycle webhooks
+func (s *Server) listAppWebhooks(c *gin.Context) {
+	webhooks, err := s.webhookService.ListWebhooks(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		s.handleServiceError(c, "list webhooks", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, webhooks)
+}
+
+// createAppWebhook adds a webhook; the response carries the signing secret, which isn't shown again
+func (s *Server) createAppWebhook(c *gin.Context) {
+	var req domain.CreateWebhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Invalid request format", Details: "url and events are required"})
+		return
+	}
+
+	webhook, err := s.webhookService.CreateWebhook(c.Request.Context(), c.Param("id"), req)
+	if err != nil {
+		s.handleServiceError(c, "create webhook", err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, webhook)
+}
+
+// updateAppWebhook changes a webhook's URL, events or enabled state
+func (s *Server) updateAppWebhook(c *gin.Context) {
+	var req domain.UpdateWebhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse{Error: "Invalid request format", Details: err.Error()})
+		return
+	}
+
+	webhook, err := s.webhookService.UpdateWebhook(c.Request.Context(), c.Param("id"), c.Param("webhookId"), req)
+	if err != nil {
+		s.handleServiceError(c, "update webhook", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, webhook)
+}
+
+// deleteAppWebhook removes a webhook
+func (s *Server) deleteAppWebhook(c *gin.Context) {
+	if err := s.webhookService.DeleteWebhook(c.Request.Context(), c.Param("id"), c.Param("webhookId")); err != nil {
+		s.handleServiceError(c, "delete webhook", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Webhook deleted successfully"})
+}
+
+// testAppWebhook sends a test delivery and returns the webhook with its outcome
+func (s *Server) testAppWebhook(c *gin.Context) {
+	webhook, err := s.webhookService.TestWebhook(c.Request.Context(), c.Param("id"), c.Param("webhookId"))
+	if err != nil {
+		s.handleServiceError(c, "test webhook", err)
+		return
+	}
+
+	c.JSON(http.StatusOK, webhook)
+}
@@ -0,0 +1,84 @@
+package httputil
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ParseFields extracts the comma-separated field names from the fields query parameter. It
+// returns nil when the parameter is absent, meaning every field is wanted.
+func ParseFields(c *gin.Context) []string {
+	param := c.Query("fields")
+	if param == "" {
+		return nil
+	}
+
+	var fields []string
+	for _, field := range strings.Split(param, ",") {
+		if field = strings.TrimSpace(field); field != "" {
+			fields = append(fields, field)
+		}
+	}
+	return fields
+}
+
+// ValidateFields checks that every name in fields is a JSON field of the struct v (or that v
+// points to)
+func ValidateFields(v any, fields []string) error {
+	known := jsonFieldNames(reflect.TypeOf(v))
+	for _, field := range fields {
+		if !known[field] {
+			return fmt.Errorf("unknown field %q", field)
+		}
+	}
+	return nil
+}
+
+// SelectFields returns v's JSON encoding reduced to the named fields. Fields v leaves out
+// (omitempty) stay left out.
+func SelectFields(v any, fields []string) (map[string]json.RawMessage, error) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var all map[string]json.RawMessage
+	if err := json.Unmarshal(data, &all); err != nil {
+		return nil, err
+	}
+
+	selected := make(map[string]json.RawMessage, len(fields))
+	for _, field := range fields {
+		if raw, ok := all[field]; ok {
+			selected[field] = raw
+		}
+	}
+	return selected, nil
+}
+
+func jsonFieldNames(t reflect.Type) map[string]bool {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	names := make(map[string]bool)
+	if t.Kind() != reflect.Struct {
+		return names
+	}
+	for _, f := range reflect.VisibleFields(t) {
+		if !f.IsExported() || f.Anonymous {
+			continue
+		}
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		switch name {
+		case "-":
+			continue
+		case "":
+			name = f.Name
+		}
+		names[name] = true
+	}
+	return names
+}
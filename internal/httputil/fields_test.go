@@ -0,0 +1,61 @@
+package httputil
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+type fieldsTestApp struct {
+	ID      string            `json:"id"`
+	Name    string            `json:"name"`
+	Compose string            `json:"compose_content"`
+	Labels  map[string]string `json:"labels,omitempty"`
+	Secret  string            `json:"-"`
+}
+
+func TestParseFields(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	for query, want := range map[string][]string{
+		"":                      nil,
+		"?fields=id":            {"id"},
+		"?fields=id,+name,,":    {"id", "name"},
+		"?fields=%20id%20,name": {"id", "name"},
+	} {
+		c, _ := gin.CreateTestContext(httptest.NewRecorder())
+		c.Request = httptest.NewRequest("GET", "/api/apps"+query, nil)
+		got := ParseFields(c)
+		if len(got) != len(want) {
+			t.Errorf("%q: got %v, want %v", query, got, want)
+			continue
+		}
+		for i := range want {
+			if got[i] != want[i] {
+				t.Errorf("%q: got %v, want %v", query, got, want)
+			}
+		}
+	}
+}
+
+func TestValidateFields(t *testing.T) {
+	if err := ValidateFields(&fieldsTestApp{}, []string{"id", "labels"}); err != nil {
+		t.Errorf("expected known fields to pass, got %v", err)
+	}
+	for _, field := range []string{"Secret", "Compose", "nope"} {
+		if err := ValidateFields(fieldsTestApp{}, []string{"id", field}); err == nil {
+			t.Errorf("expected %q to be rejected", field)
+		}
+	}
+}
+
+func TestSelectFields(t *testing.T) {
+	app := &fieldsTestApp{ID: "app-1", Name: "web", Compose: "services: {}"}
+	got, err := SelectFields(app, []string{"id", "name", "labels"})
+	if err != nil {
+		t.Fatalf("SelectFields: %v", err)
+	}
+	if len(got) != 2 || string(got["id"]) != `"app-1"` || string(got["name"]) != `"web"` {
+		t.Errorf("unexpected selection %v", got)
+	}
+}
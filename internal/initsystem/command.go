@@ -0,0 +1,102 @@
+package initsystem
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/selfhostly/internal/platform"
+)
+
+// CommandName is the subcommand both binaries accept, e.g. `selfhostly install-service`
+const CommandName = "install-service"
+
+// RunCommand parses install-service flags for component, installs the service and returns the
+// process exit code
+func RunCommand(component string, args []string, stdout, stderr io.Writer) int {
+	cwd, _ := os.Getwd()
+	binary, _ := os.Executable()
+	if resolved, err := filepath.EvalSymlinks(binary); err == nil {
+		binary = resolved
+	}
+
+	fs := flag.NewFlagSet(CommandName, flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	envFile := fs.String("env-file", filepath.Join(cwd, ".env"), "env file the service loads (ENV_FILE)")
+	workingDir := fs.String("working-dir", cwd, "directory the service runs in")
+	binaryPath := fs.String("binary", binary, "binary the service runs")
+	user := fs.String("user", "", "user to run as (systemd)")
+	writePaths := fs.String("write-paths", "", "comma-separated extra paths the server may write to (systemd)")
+	system := fs.Bool("system", false, "install as a system daemon instead of a user agent (launchd)")
+	dryRun := fs.Bool("dry-run", false, "print the service definition without installing it")
+	noStart := fs.Bool("no-start", false, "install without enabling or starting the service")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	opts := Options{
+		Component:  component,
+		BinaryPath: absPath(*binaryPath),
+		WorkingDir: absPath(*workingDir),
+		EnvFile:    absPath(*envFile),
+		User:       *user,
+		System:     *system,
+	}
+	for _, p := range strings.Split(*writePaths, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			opts.WritePaths = append(opts.WritePaths, absPath(p))
+		}
+	}
+	if component == ComponentServer && len(opts.WritePaths) == 0 {
+		opts.WritePaths = []string{opts.WorkingDir}
+	}
+
+	home, _ := os.UserHomeDir()
+	path, content, err := Render(platform.OS(), opts, home)
+	if err != nil {
+		fmt.Fprintf(stderr, "install-service: %v\n", err)
+		return 1
+	}
+
+	if *dryRun {
+		fmt.Fprintf(stdout, "# %s\n%s", path, content)
+		return 0
+	}
+
+	if _, err := os.Stat(opts.EnvFile); err != nil {
+		fmt.Fprintf(stderr, "install-service: warning: env file %s not found; the service will start with defaults\n", opts.EnvFile)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		fmt.Fprintf(stderr, "install-service: %v\n", err)
+		return 1
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		fmt.Fprintf(stderr, "install-service: failed to write %s: %v (try again with sudo)\n", path, err)
+		return 1
+	}
+	fmt.Fprintf(stdout, "Installed %s\n", path)
+
+	if *noStart {
+		return 0
+	}
+	for _, cmd := range ActivateCommands(platform.OS(), opts, path) {
+		out, err := exec.Command(cmd[0], cmd[1:]...).CombinedOutput()
+		if err != nil && !(cmd[0] == "launchctl" && cmd[1] == "unload") {
+			fmt.Fprintf(stderr, "install-service: %s failed: %v\n%s", strings.Join(cmd, " "), err, out)
+			return 1
+		}
+	}
+	fmt.Fprintf(stdout, "Started %s\n", opts.Name())
+	return 0
+}
+
+func absPath(p string) string {
+	if abs, err := filepath.Abs(p); err == nil {
+		return abs
+	}
+	return p
+}
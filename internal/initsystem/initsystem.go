@@ -0,0 +1,196 @@
+// Package initsystem generates and installs init system definitions (a systemd unit on Linux,
+// a launchd plist on macOS) so the server and gateway run as managed services.
+package initsystem
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"text/template"
+
+	"github.com/selfhostly/internal/platform"
+)
+
+// Components that can be installed as a service
+const (
+	ComponentServer  = "server"
+	ComponentGateway = "gateway"
+)
+
+// Options describe the service to generate
+type Options struct {
+	Component  string   // ComponentServer or ComponentGateway
+	BinaryPath string   // Absolute path of the binary to run
+	WorkingDir string   // Directory the service runs in (relative DATABASE_PATH/APPS_DIR resolve here)
+	EnvFile    string   // Absolute path of the .env file, passed as ENV_FILE
+	User       string   // User to run as (systemd only; empty keeps the unit's default)
+	WritePaths []string // Extra paths the server may write to under systemd's read-only protections
+	System     bool     // launchd: install as a system daemon rather than a per-user agent
+}
+
+// Name returns the service name, e.g. "selfhostly-server"
+func (o Options) Name() string {
+	return "selfhostly-" + o.Component
+}
+
+// Label returns the launchd label, e.g. "com.selfhostly.server"
+func (o Options) Label() string {
+	return "com.selfhostly." + o.Component
+}
+
+// Validate checks the options are complete and use absolute paths
+func (o Options) Validate() error {
+	if o.Component != ComponentServer && o.Component != ComponentGateway {
+		return fmt.Errorf("unknown component %q", o.Component)
+	}
+	for name, p := range map[string]string{"binary": o.BinaryPath, "working directory": o.WorkingDir, "env file": o.EnvFile} {
+		if !filepath.IsAbs(p) {
+			return fmt.Errorf("%s path must be absolute, got %q", name, p)
+		}
+	}
+	for _, p := range o.WritePaths {
+		if !filepath.IsAbs(p) {
+			return fmt.Errorf("write path must be absolute, got %q", p)
+		}
+	}
+	return nil
+}
+
+// Render returns the service definition for goos and the path it is installed to
+func Render(goos string, opts Options, homeDir string) (path string, content string, err error) {
+	if err := opts.Validate(); err != nil {
+		return "", "", err
+	}
+
+	switch goos {
+	case platform.OSLinux:
+		content, err = render(systemdTemplate, opts)
+		return filepath.Join("/etc/systemd/system", opts.Name()+".service"), content, err
+	case platform.OSDarwin:
+		content, err = render(launchdTemplate, opts)
+		if opts.System {
+			return filepath.Join("/Library/LaunchDaemons", opts.Label()+".plist"), content, err
+		}
+		return filepath.Join(homeDir, "Library/LaunchAgents", opts.Label()+".plist"), content, err
+	default:
+		return "", "", fmt.Errorf("service install is not supported on %s", goos)
+	}
+}
+
+// ActivateCommands returns the commands that load and start an installed service
+func ActivateCommands(goos string, opts Options, path string) [][]string {
+	switch goos {
+	case platform.OSLinux:
+		return [][]string{
+			{"systemctl", "daemon-reload"},
+			{"systemctl", "enable", "--now", opts.Name()},
+		}
+	case platform.OSDarwin:
+		return [][]string{
+			{"launchctl", "unload", path}, // ignore failure: not loaded on first install
+			{"launchctl", "load", "-w", path},
+		}
+	}
+	return nil
+}
+
+func render(tmpl *template.Template, opts Options) (string, error) {
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, opts); err != nil {
+		return "", fmt.Errorf("failed to render service definition: %w", err)
+	}
+	return buf.String(), nil
+}
+
+var funcs = template.FuncMap{
+	// quote wraps a value for systemd, which splits unquoted values on whitespace
+	"quote": func(s string) string {
+		return `"` + strings.ReplaceAll(strings.ReplaceAll(s, `\`, `\\`), `"`, `\"`) + `"`
+	},
+	"xml": func(s string) (string, error) {
+		var buf bytes.Buffer
+		if err := xml.EscapeText(&buf, []byte(s)); err != nil {
+			return "", err
+		}
+		return buf.String(), nil
+	},
+}
+
+// The server drives docker compose and writes its database and app directories, so it gets the
+// protections that leave those working; the gateway only proxies HTTP and is locked down further.
+var systemdTemplate = template.Must(template.New("systemd").Funcs(funcs).Parse(`[Unit]
+Description=Selfhostly {{.Component}}
+Documentation=https://github.com/SamsonNegedu/selfhostly
+Wants=network-online.target
+After=network-online.target{{if eq .Component "server"}} docker.service
+Requires=docker.service{{end}}
+
+[Service]
+Type=simple
+{{- if .User}}
+User={{.User}}
+{{- end}}
+WorkingDirectory={{.WorkingDir}}
+Environment={{quote (print "ENV_FILE=" .EnvFile)}}
+ExecStart={{quote .BinaryPath}}
+Restart=on-failure
+RestartSec=5
+TimeoutStopSec=45
+
+NoNewPrivileges=true
+PrivateTmp=true
+ProtectKernelModules=true
+ProtectKernelTunables=true
+ProtectControlGroups=true
+RestrictSUIDSGID=true
+LockPersonality=true
+{{- if eq .Component "server"}}
+ProtectSystem=full
+{{- if .WritePaths}}
+ReadWritePaths={{range $i, $p := .WritePaths}}{{if $i}} {{end}}{{quote $p}}{{end}}
+{{- end}}
+{{- else}}
+ProtectSystem=strict
+ProtectHome=read-only
+PrivateDevices=true
+{{- end}}
+
+[Install]
+WantedBy=multi-user.target
+`))
+
+var launchdTemplate = template.Must(template.New("launchd").Funcs(funcs).Parse(`<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+	<key>Label</key>
+	<string>{{.Label}}</string>
+	<key>ProgramArguments</key>
+	<array>
+		<string>{{xml .BinaryPath}}</string>
+	</array>
+	<key>WorkingDirectory</key>
+	<string>{{xml .WorkingDir}}</string>
+	<key>EnvironmentVariables</key>
+	<dict>
+		<key>ENV_FILE</key>
+		<string>{{xml .EnvFile}}</string>
+	</dict>
+	<key>RunAtLoad</key>
+	<true/>
+	<key>KeepAlive</key>
+	<dict>
+		<key>SuccessfulExit</key>
+		<false/>
+	</dict>
+	<key>ThrottleInterval</key>
+	<integer>5</integer>
+	<key>StandardOutPath</key>
+	<string>{{xml .WorkingDir}}/{{.Name}}.log</string>
+	<key>StandardErrorPath</key>
+	<string>{{xml .WorkingDir}}/{{.Name}}.log</string>
+</dict>
+</plist>
+`))
@@ -0,0 +1,109 @@
+package initsystem
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/selfhostly/internal/platform"
+)
+
+func TestRender_SystemdServer(t *testing.T) {
+	opts := Options{
+		Component:  ComponentServer,
+		BinaryPath: "/opt/selfhostly/selfhostly",
+		WorkingDir: "/opt/selfhostly",
+		EnvFile:    "/opt/selfhostly/.env",
+		User:       "selfhostly",
+		WritePaths: []string{"/opt/selfhostly", "/srv/apps dir"},
+	}
+
+	path, content, err := Render(platform.OSLinux, opts, "/home/pi")
+	if err != nil {
+		t.Fatalf("Render: %v", err)
+	}
+	if path != "/etc/systemd/system/selfhostly-server.service" {
+		t.Errorf("unexpected path %q", path)
+	}
+	for _, want := range []string{
+		"Requires=docker.service",
+		"User=selfhostly",
+		`Environment="ENV_FILE=/opt/selfhostly/.env"`,
+		`ExecStart="/opt/selfhostly/selfhostly"`,
+		"Restart=on-failure",
+		"ProtectSystem=full",
+		`ReadWritePaths="/opt/selfhostly" "/srv/apps dir"`,
+		"WantedBy=multi-user.target",
+	} {
+		if !strings.Contains(content, want) {
+			t.Errorf("unit missing %q:\n%s", want, content)
+		}
+	}
+	if strings.Contains(content, "ProtectHome") {
+		t.Error("server unit must not hide home directories, apps may live there")
+	}
+}
+
+func TestRender_SystemdGateway(t *testing.T) {
+	opts := Options{
+		Component:  ComponentGateway,
+		BinaryPath: "/usr/local/bin/selfhostly-gateway",
+		WorkingDir: "/etc/selfhostly",
+		EnvFile:    "/etc/selfhostly/gateway.env",
+	}
+
+	_, content, err := Render(platform.OSLinux, opts, "")
+	if err != nil {
+		t.Fatalf("Render: %v", err)
+	}
+	for _, want := range []string{"ProtectSystem=strict", "ProtectHome=read-only", "PrivateDevices=true"} {
+		if !strings.Contains(content, want) {
+			t.Errorf("unit missing %q:\n%s", want, content)
+		}
+	}
+	if strings.Contains(content, "docker.service") || strings.Contains(content, "User=") {
+		t.Errorf("gateway unit should not depend on docker or set a user:\n%s", content)
+	}
+}
+
+func TestRender_Launchd(t *testing.T) {
+	opts := Options{
+		Component:  ComponentServer,
+		BinaryPath: "/Users/me/bin/selfhostly",
+		WorkingDir: "/Users/me/R&D",
+		EnvFile:    "/Users/me/R&D/.env",
+	}
+
+	path, content, err := Render(platform.OSDarwin, opts, "/Users/me")
+	if err != nil {
+		t.Fatalf("Render: %v", err)
+	}
+	if path != "/Users/me/Library/LaunchAgents/com.selfhostly.server.plist" {
+		t.Errorf("unexpected path %q", path)
+	}
+	if !strings.Contains(content, "<string>/Users/me/R&amp;D/.env</string>") {
+		t.Errorf("expected escaped env file path:\n%s", content)
+	}
+
+	opts.System = true
+	if path, _, _ = Render(platform.OSDarwin, opts, "/Users/me"); path != "/Library/LaunchDaemons/com.selfhostly.server.plist" {
+		t.Errorf("unexpected system daemon path %q", path)
+	}
+}
+
+func TestRender_Invalid(t *testing.T) {
+	valid := Options{Component: ComponentGateway, BinaryPath: "/bin/gw", WorkingDir: "/srv", EnvFile: "/srv/.env"}
+
+	relative := valid
+	relative.EnvFile = ".env"
+	if _, _, err := Render(platform.OSLinux, relative, ""); err == nil {
+		t.Error("expected relative env file to be rejected")
+	}
+	unknown := valid
+	unknown.Component = "worker"
+	if _, _, err := Render(platform.OSLinux, unknown, ""); err == nil {
+		t.Error("expected unknown component to be rejected")
+	}
+	if _, _, err := Render(platform.OSWindows, valid, ""); err == nil {
+		t.Error("expected windows to be unsupported")
+	}
+}
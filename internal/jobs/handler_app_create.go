@@ -7,6 +7,7 @@ import (
 	"log/slog"
 	"strings"
 
+	"github.com/selfhostly/internal/appstate"
 	"github.com/selfhostly/internal/constants"
 	"github.com/selfhostly/internal/db"
 	"github.com/selfhostly/internal/docker"
@@ -16,6 +17,7 @@ import (
 // AppCreateHandler handles app_create jobs
 type AppCreateHandler struct {
 	db            *db.DB
+	states        *appstate.Machine
 	dockerManager *docker.Manager
 	appService    domain.AppService
 	tunnelService domain.TunnelService
@@ -25,6 +27,7 @@ type AppCreateHandler struct {
 // NewAppCreateHandler creates a new app create handler
 func NewAppCreateHandler(
 	database *db.DB,
+	states *appstate.Machine,
 	dockerMgr *docker.Manager,
 	appSvc domain.AppService,
 	tunnelSvc domain.TunnelService,
@@ -32,6 +35,7 @@ func NewAppCreateHandler(
 ) *AppCreateHandler {
 	return &AppCreateHandler{
 		db:            database,
+		states:        states,
 		dockerManager: dockerMgr,
 		appService:    appSvc,
 		tunnelService: tunnelSvc,
@@ -65,10 +69,7 @@ func (h *AppCreateHandler) Handle(ctx context.Context, job *db.Job, progress *Pr
 	// Start app (SLOW: docker pull/build/up)
 	if err := h.dockerManager.StartApp(app.Name); err != nil {
 		// Update app to error state
-		app.Status = constants.AppStatusError
-		errorMsg := err.Error()
-		app.ErrorMessage = &errorMsg
-		if updateErr := h.db.UpdateApp(app); updateErr != nil {
+		if updateErr := h.states.Transition(ctx, app, constants.AppStatusError, docker.ErrorDetails(err)); updateErr != nil {
 			h.logger.Warn("failed to update app to error state", "app_id", app.ID, "error", updateErr)
 		}
 		return fmt.Errorf("failed to start app: %w", err)
@@ -107,12 +108,14 @@ func (h *AppCreateHandler) Handle(ctx context.Context, job *db.Job, progress *Pr
 	progress.Update(95, "Updating app status...")
 
 	// Update app status to running
-	app.Status = constants.AppStatusRunning
-	app.ErrorMessage = nil
-	if err := h.db.UpdateApp(app); err != nil {
+	if err := h.states.Transition(ctx, app, constants.AppStatusRunning, ""); err != nil {
 		h.logger.Warn("failed to update app status to running", "app_id", app.ID, "error", err)
 	}
 
+	if err := h.appService.RecordImageDigests(ctx, app.ID); err != nil {
+		h.logger.Warn("failed to record image digests", "app_id", app.ID, "error", err)
+	}
+
 	progress.Update(100, "App started successfully")
 	return nil
 }
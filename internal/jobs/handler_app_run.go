@@ -0,0 +1,121 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/selfhostly/internal/constants"
+	"github.com/selfhostly/internal/db"
+	"github.com/selfhostly/internal/docker"
+	"github.com/selfhostly/internal/webhook"
+)
+
+// AppRunHandler handles app_run jobs, one-off commands and recurring task runs in one of an app's
+// services
+type AppRunHandler struct {
+	database      *db.DB
+	dockerManager *docker.Manager
+	webhooks      *webhook.Dispatcher
+	logger        *slog.Logger
+}
+
+// NewAppRunHandler creates a new AppRunHandler
+func NewAppRunHandler(database *db.DB, dockerManager *docker.Manager, webhooks *webhook.Dispatcher, logger *slog.Logger) JobHandler {
+	return &AppRunHandler{
+		database:      database,
+		dockerManager: dockerManager,
+		webhooks:      webhooks,
+		logger:        logger,
+	}
+}
+
+// Handle implements the JobHandler interface for app_run. The command's exit code and output are
+// the job's result whether or not it succeeds; the app's status is left alone either way. Runs of
+// a recurring task are added to its history, and a failed one fires the task_failed webhook event.
+func (h *AppRunHandler) Handle(ctx context.Context, job *db.Job, progress *ProgressTracker) error {
+	var payload AppRunPayload
+	if job.Payload != nil {
+		if err := json.Unmarshal([]byte(*job.Payload), &payload); err != nil {
+			return fmt.Errorf("failed to parse app_run payload: %w", err)
+		}
+	}
+	if payload.Service == "" || len(payload.Command) == 0 {
+		return fmt.Errorf("invalid payload: service and command are required")
+	}
+
+	app, err := h.database.GetApp(job.AppID)
+	if err != nil {
+		return fmt.Errorf("failed to get app: %w", err)
+	}
+
+	timeout := constants.RunDefaultTimeout
+	if payload.TimeoutSeconds > 0 {
+		timeout = time.Duration(payload.TimeoutSeconds) * time.Second
+	}
+	runCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	progress.Update(10, fmt.Sprintf("Running %q in %s...", strings.Join(payload.Command, " "), payload.Service))
+
+	startedAt := time.Now()
+	result, err := h.dockerManager.RunServiceCommand(runCtx, app.Name, payload.Service, payload.Command, payload.Mode, job.ID)
+	if result != nil {
+		progress.SetResult(result)
+	}
+	if payload.TaskID != "" {
+		h.recordTaskRun(ctx, app, job, payload.TaskID, startedAt, result, err)
+	}
+	if err != nil {
+		return err
+	}
+
+	progress.Update(100, "Command finished successfully")
+
+	h.logger.Info("One-off command completed",
+		"app_id", app.ID,
+		"app_name", app.Name,
+		"service", payload.Service,
+		"job_id", job.ID)
+
+	return nil
+}
+
+// recordTaskRun adds a run to its task's history. A task deleted while its run was queued has no
+// history to add to, so failing to record is only logged.
+func (h *AppRunHandler) recordTaskRun(ctx context.Context, app *db.App, job *db.Job, taskID string, startedAt time.Time, result *docker.CommandResult, runErr error) {
+	run := &db.AppTaskRun{
+		ID:         uuid.New().String(),
+		TaskID:     taskID,
+		AppID:      app.ID,
+		JobID:      job.ID,
+		Status:     constants.JobStatusCompleted,
+		StartedAt:  startedAt,
+		FinishedAt: time.Now(),
+	}
+	if result != nil {
+		run.ExitCode = result.ExitCode
+		run.TimedOut = result.TimedOut
+		run.Output = result.Output
+	}
+	if runErr != nil {
+		run.Status = constants.JobStatusFailed
+		message := runErr.Error()
+		run.ErrorMessage = &message
+	}
+	if err := h.database.RecordTaskRun(run, constants.TaskRunHistoryLimit); err != nil {
+		h.logger.WarnContext(ctx, "failed to record task run", "task_id", taskID, "job_id", job.ID, "error", err)
+	}
+
+	if runErr != nil {
+		name := taskID
+		if task, err := h.database.GetTask(app.ID, taskID); err == nil {
+			name = task.Name
+		}
+		h.webhooks.Fire(ctx, app, constants.WebhookEventTaskFailed, fmt.Sprintf("task %s failed: %v", name, runErr))
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"log/slog"
 
+	"github.com/selfhostly/internal/appstate"
 	"github.com/selfhostly/internal/constants"
 	"github.com/selfhostly/internal/db"
 	"github.com/selfhostly/internal/docker"
@@ -14,14 +15,16 @@ import (
 // AppScheduledStartHandler handles scheduled app start jobs
 type AppScheduledStartHandler struct {
 	database      *db.DB
+	states        *appstate.Machine
 	dockerManager *docker.Manager
 	logger        *slog.Logger
 }
 
 // NewAppScheduledStartHandler creates a new AppScheduledStartHandler
-func NewAppScheduledStartHandler(database *db.DB, dockerManager *docker.Manager, logger *slog.Logger) JobHandler {
+func NewAppScheduledStartHandler(database *db.DB, states *appstate.Machine, dockerManager *docker.Manager, logger *slog.Logger) JobHandler {
 	return &AppScheduledStartHandler{
 		database:      database,
+		states:        states,
 		dockerManager: dockerManager,
 		logger:        logger,
 	}
@@ -57,32 +60,25 @@ func (h *AppScheduledStartHandler) Handle(ctx context.Context, job *db.Job, prog
 	// Start the app
 	if err := h.dockerManager.StartApp(app.Name); err != nil {
 		// Update app to error state
-		app.Status = constants.AppStatusError
-		errorMsg := err.Error()
-		app.ErrorMessage = &errorMsg
-		
-		if updateErr := h.database.UpdateApp(app); updateErr != nil {
+		if updateErr := h.states.Transition(ctx, app, constants.AppStatusError, docker.ErrorDetails(err)); updateErr != nil {
 			h.logger.Warn("Failed to update app to error state", "app_id", app.ID, "error", updateErr)
 		}
-		
+
 		return fmt.Errorf("failed to start app: %w", err)
 	}
 
 	progress.Update(60, "Application started")
 
 	// Update app status
-	app.Status = constants.AppStatusRunning
-	app.ErrorMessage = nil
-
-	if err := h.database.UpdateApp(app); err != nil {
+	if err := h.states.Transition(ctx, app, constants.AppStatusRunning, ""); err != nil {
 		h.logger.Warn("Failed to update app status", "app_id", app.ID, "error", err)
 		return fmt.Errorf("failed to update app status: %w", err)
 	}
 
 	progress.Update(100, "Application started successfully")
 
-	h.logger.Info("Scheduled start completed successfully", 
-		"app_id", app.ID, 
+	h.logger.Info("Scheduled start completed successfully",
+		"app_id", app.ID,
 		"app_name", app.Name,
 		"job_id", job.ID)
 
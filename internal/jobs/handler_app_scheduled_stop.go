@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"log/slog"
 
+	"github.com/selfhostly/internal/appstate"
 	"github.com/selfhostly/internal/constants"
 	"github.com/selfhostly/internal/db"
 	"github.com/selfhostly/internal/docker"
@@ -14,14 +15,16 @@ import (
 // AppScheduledStopHandler handles scheduled app stop jobs
 type AppScheduledStopHandler struct {
 	database      *db.DB
+	states        *appstate.Machine
 	dockerManager *docker.Manager
 	logger        *slog.Logger
 }
 
 // NewAppScheduledStopHandler creates a new AppScheduledStopHandler
-func NewAppScheduledStopHandler(database *db.DB, dockerManager *docker.Manager, logger *slog.Logger) JobHandler {
+func NewAppScheduledStopHandler(database *db.DB, states *appstate.Machine, dockerManager *docker.Manager, logger *slog.Logger) JobHandler {
 	return &AppScheduledStopHandler{
 		database:      database,
+		states:        states,
 		dockerManager: dockerManager,
 		logger:        logger,
 	}
@@ -57,32 +60,25 @@ func (h *AppScheduledStopHandler) Handle(ctx context.Context, job *db.Job, progr
 	// Stop the app
 	if err := h.dockerManager.StopApp(app.Name); err != nil {
 		// Update app to error state
-		app.Status = constants.AppStatusError
-		errorMsg := err.Error()
-		app.ErrorMessage = &errorMsg
-		
-		if updateErr := h.database.UpdateApp(app); updateErr != nil {
+		if updateErr := h.states.Transition(ctx, app, constants.AppStatusError, err.Error()); updateErr != nil {
 			h.logger.Warn("Failed to update app to error state", "app_id", app.ID, "error", updateErr)
 		}
-		
+
 		return fmt.Errorf("failed to stop app: %w", err)
 	}
 
 	progress.Update(60, "Application stopped")
 
 	// Update app status
-	app.Status = constants.AppStatusStopped
-	app.ErrorMessage = nil
-
-	if err := h.database.UpdateApp(app); err != nil {
+	if err := h.states.Transition(ctx, app, constants.AppStatusStopped, ""); err != nil {
 		h.logger.Warn("Failed to update app status", "app_id", app.ID, "error", err)
 		return fmt.Errorf("failed to update app status: %w", err)
 	}
 
 	progress.Update(100, "Application stopped successfully")
 
-	h.logger.Info("Scheduled stop completed successfully", 
-		"app_id", app.ID, 
+	h.logger.Info("Scheduled stop completed successfully",
+		"app_id", app.ID,
 		"app_name", app.Name,
 		"job_id", job.ID)
 
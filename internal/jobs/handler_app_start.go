@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"log/slog"
 
+	"github.com/selfhostly/internal/appstate"
 	"github.com/selfhostly/internal/constants"
 	"github.com/selfhostly/internal/db"
 	"github.com/selfhostly/internal/docker"
@@ -14,14 +15,16 @@ import (
 // AppStartHandler handles app start jobs
 type AppStartHandler struct {
 	database      *db.DB
+	states        *appstate.Machine
 	dockerManager *docker.Manager
 	logger        *slog.Logger
 }
 
 // NewAppStartHandler creates a new AppStartHandler
-func NewAppStartHandler(database *db.DB, dockerManager *docker.Manager, logger *slog.Logger) JobHandler {
+func NewAppStartHandler(database *db.DB, states *appstate.Machine, dockerManager *docker.Manager, logger *slog.Logger) JobHandler {
 	return &AppStartHandler{
 		database:      database,
+		states:        states,
 		dockerManager: dockerManager,
 		logger:        logger,
 	}
@@ -52,11 +55,7 @@ func (h *AppStartHandler) Handle(ctx context.Context, job *db.Job, progress *Pro
 	}
 
 	if err := h.dockerManager.StartApp(app.Name); err != nil {
-		app.Status = constants.AppStatusError
-		errorMsg := err.Error()
-		app.ErrorMessage = &errorMsg
-
-		if updateErr := h.database.UpdateApp(app); updateErr != nil {
+		if updateErr := h.states.Transition(ctx, app, constants.AppStatusError, docker.ErrorDetails(err)); updateErr != nil {
 			h.logger.Warn("Failed to update app to error state", "app_id", app.ID, "error", updateErr)
 		}
 
@@ -65,10 +64,7 @@ func (h *AppStartHandler) Handle(ctx context.Context, job *db.Job, progress *Pro
 
 	progress.Update(60, "Application started")
 
-	app.Status = constants.AppStatusRunning
-	app.ErrorMessage = nil
-
-	if err := h.database.UpdateApp(app); err != nil {
+	if err := h.states.Transition(ctx, app, constants.AppStatusRunning, ""); err != nil {
 		h.logger.Warn("Failed to update app status", "app_id", app.ID, "error", err)
 		return fmt.Errorf("failed to update app status: %w", err)
 	}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"log/slog"
 
+	"github.com/selfhostly/internal/appstate"
 	"github.com/selfhostly/internal/constants"
 	"github.com/selfhostly/internal/db"
 	"github.com/selfhostly/internal/docker"
@@ -14,14 +15,16 @@ import (
 // AppStopHandler handles app stop jobs
 type AppStopHandler struct {
 	database      *db.DB
+	states        *appstate.Machine
 	dockerManager *docker.Manager
 	logger        *slog.Logger
 }
 
 // NewAppStopHandler creates a new AppStopHandler
-func NewAppStopHandler(database *db.DB, dockerManager *docker.Manager, logger *slog.Logger) JobHandler {
+func NewAppStopHandler(database *db.DB, states *appstate.Machine, dockerManager *docker.Manager, logger *slog.Logger) JobHandler {
 	return &AppStopHandler{
 		database:      database,
+		states:        states,
 		dockerManager: dockerManager,
 		logger:        logger,
 	}
@@ -52,11 +55,7 @@ func (h *AppStopHandler) Handle(ctx context.Context, job *db.Job, progress *Prog
 	}
 
 	if err := h.dockerManager.StopApp(app.Name); err != nil {
-		app.Status = constants.AppStatusError
-		errorMsg := err.Error()
-		app.ErrorMessage = &errorMsg
-
-		if updateErr := h.database.UpdateApp(app); updateErr != nil {
+		if updateErr := h.states.Transition(ctx, app, constants.AppStatusError, err.Error()); updateErr != nil {
 			h.logger.Warn("Failed to update app to error state", "app_id", app.ID, "error", updateErr)
 		}
 
@@ -65,10 +64,7 @@ func (h *AppStopHandler) Handle(ctx context.Context, job *db.Job, progress *Prog
 
 	progress.Update(60, "Application stopped")
 
-	app.Status = constants.AppStatusStopped
-	app.ErrorMessage = nil
-
-	if err := h.database.UpdateApp(app); err != nil {
+	if err := h.states.Transition(ctx, app, constants.AppStatusStopped, ""); err != nil {
 		h.logger.Warn("Failed to update app status", "app_id", app.ID, "error", err)
 		return fmt.Errorf("failed to update app status: %w", err)
 	}
@@ -5,27 +5,35 @@ import (
 	"fmt"
 	"log/slog"
 
+	"github.com/selfhostly/internal/appstate"
 	"github.com/selfhostly/internal/constants"
 	"github.com/selfhostly/internal/db"
 	"github.com/selfhostly/internal/docker"
+	"github.com/selfhostly/internal/domain"
 )
 
 // AppUpdateHandler handles app_update jobs
 type AppUpdateHandler struct {
 	db            *db.DB
+	states        *appstate.Machine
 	dockerManager *docker.Manager
+	appService    domain.AppService
 	logger        *slog.Logger
 }
 
 // NewAppUpdateHandler creates a new app update handler
 func NewAppUpdateHandler(
 	database *db.DB,
+	states *appstate.Machine,
 	dockerMgr *docker.Manager,
+	appSvc domain.AppService,
 	logger *slog.Logger,
 ) *AppUpdateHandler {
 	return &AppUpdateHandler{
 		db:            database,
+		states:        states,
 		dockerManager: dockerMgr,
+		appService:    appSvc,
 		logger:        logger,
 	}
 }
@@ -40,6 +48,14 @@ func (h *AppUpdateHandler) Handle(ctx context.Context, job *db.Job, progress *Pr
 
 	progress.Update(5, "Preparing to update...")
 
+	// Flag the images the node would pull for another architecture, before pulling them
+	if warnings, err := h.dockerManager.AppImagePlatformWarnings(app.Name); err != nil {
+		h.logger.Warn("failed to check app images against the node's architecture", "app_id", app.ID, "error", err)
+	} else if len(warnings) > 0 {
+		h.logger.Warn("app images have no manifest for the node's architecture", "app_id", app.ID, "warnings", warnings)
+		progress.SetResult(AppUpdateResult{PlatformWarnings: warnings})
+	}
+
 	// Create progress callback that forwards to our tracker
 	progressCallback := func(pct int, msg string) {
 		// Docker progress is 0-100, map it to our overall progress (5-95)
@@ -49,17 +65,26 @@ func (h *AppUpdateHandler) Handle(ctx context.Context, job *db.Job, progress *Pr
 
 	// Pull latest images and rebuild (this is the slow operation)
 	if err := h.dockerManager.UpdateAppWithProgress(ctx, app.Name, progressCallback); err != nil {
+		if updateErr := h.states.Transition(ctx, app, constants.AppStatusError, docker.ErrorDetails(err)); updateErr != nil {
+			h.logger.Warn("failed to update app to error state", "app_id", app.ID, "error", updateErr)
+		}
 		return fmt.Errorf("failed to update app: %w", err)
 	}
 
 	progress.Update(97, "Updating app status...")
 
 	// Update app status in database
-	app.Status = constants.AppStatusRunning
-	if err := h.db.UpdateApp(app); err != nil {
+	if err := h.states.Transition(ctx, app, constants.AppStatusRunning, ""); err != nil {
 		h.logger.Warn("failed to update app status", "app_id", app.ID, "error", err)
 	}
 
+	// Digest pinning is optional; the handler works without an app service
+	if h.appService != nil {
+		if err := h.appService.RecordImageDigests(ctx, app.ID); err != nil {
+			h.logger.Warn("failed to record image digests", "app_id", app.ID, "error", err)
+		}
+	}
+
 	progress.Update(100, "App updated successfully")
 	return nil
 }
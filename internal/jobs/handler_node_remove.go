@@ -0,0 +1,58 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+
+	"github.com/selfhostly/internal/db"
+	"github.com/selfhostly/internal/domain"
+)
+
+// NodeRemoveHandler handles node_remove jobs, which run on the primary with the node's ID as app_id
+type NodeRemoveHandler struct {
+	nodeService domain.NodeService
+	logger      *slog.Logger
+}
+
+// NewNodeRemoveHandler creates a new NodeRemoveHandler
+func NewNodeRemoveHandler(nodeSvc domain.NodeService, logger *slog.Logger) JobHandler {
+	return &NodeRemoveHandler{
+		nodeService: nodeSvc,
+		logger:      logger,
+	}
+}
+
+// Handle implements the JobHandler interface for node_remove. What happened to each of the node's
+// apps is the job's result, also when some of them failed and the node was kept.
+func (h *NodeRemoveHandler) Handle(ctx context.Context, job *db.Job, progress *ProgressTracker) error {
+	if h.nodeService == nil {
+		return fmt.Errorf("node removal is not available on this node")
+	}
+	var payload NodeRemovePayload
+	if job.Payload != nil {
+		if err := json.Unmarshal([]byte(*job.Payload), &payload); err != nil {
+			return fmt.Errorf("failed to parse node_remove payload: %w", err)
+		}
+	}
+
+	result, err := h.nodeService.RemoveNode(ctx, job.AppID, domain.RemoveNodeRequest{
+		Strategy:     payload.Strategy,
+		TargetNodeID: payload.TargetNodeID,
+	}, progress.Update)
+	if result != nil {
+		progress.SetResult(result)
+	}
+	if err != nil {
+		return err
+	}
+
+	message := "Node removed"
+	if !result.Removed {
+		message = "Node marked lost"
+	}
+	progress.Update(100, message)
+	h.logger.InfoContext(ctx, "node removal completed", "node_id", job.AppID, "strategy", payload.Strategy, "apps", len(result.Apps), "job_id", job.ID)
+	return nil
+}
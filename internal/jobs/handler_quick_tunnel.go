@@ -8,6 +8,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/selfhostly/internal/appstate"
 	"github.com/selfhostly/internal/constants"
 	"github.com/selfhostly/internal/db"
 	"github.com/selfhostly/internal/docker"
@@ -17,6 +18,7 @@ import (
 // QuickTunnelHandler handles quick_tunnel jobs
 type QuickTunnelHandler struct {
 	db            *db.DB
+	states        *appstate.Machine
 	dockerManager *docker.Manager
 	tunnelService domain.TunnelService
 	logger        *slog.Logger
@@ -25,12 +27,14 @@ type QuickTunnelHandler struct {
 // NewQuickTunnelHandler creates a new quick tunnel handler
 func NewQuickTunnelHandler(
 	database *db.DB,
+	states *appstate.Machine,
 	dockerMgr *docker.Manager,
 	tunnelSvc domain.TunnelService,
 	logger *slog.Logger,
 ) *QuickTunnelHandler {
 	return &QuickTunnelHandler{
 		db:            database,
+		states:        states,
 		dockerManager: dockerMgr,
 		tunnelService: tunnelSvc,
 		logger:        logger,
@@ -62,24 +66,18 @@ func (h *QuickTunnelHandler) Handle(ctx context.Context, job *db.Job, progress *
 	// Check if we're recreating an existing quick tunnel
 	isRecreating := app.TunnelMode == constants.TunnelModeQuick
 
-	progress.Update(20, "Parsing compose configuration...")
-
-	compose, err := docker.ParseCompose([]byte(app.ComposeContent))
-	if err != nil {
-		return fmt.Errorf("invalid compose file: %w", err)
-	}
-
 	progress.Update(30, "Allocating metrics port...")
 
 	// If recreating, try to reuse the existing metrics port
 	var metricsPort int
 	if isRecreating {
-		if existingPort, ok := docker.ExtractQuickTunnelMetricsHostPort(app.ComposeContent); ok {
+		if existingPort, ok := docker.ExtractQuickTunnelMetricsHostPort(app.TunnelComposeSource()); ok {
 			metricsPort = existingPort
 		}
 	}
 	// If not recreating or failed to extract port, allocate a new one
 	if metricsPort == 0 {
+		var err error
 		metricsPort, err = h.tunnelService.NextFreeQuickTunnelMetricsPort()
 		if err != nil {
 			// Use fallback port if allocation fails
@@ -89,39 +87,27 @@ func (h *QuickTunnelHandler) Handle(ctx context.Context, job *db.Job, progress *
 
 	progress.Update(40, "Configuring Quick Tunnel container...")
 
-	// Remove existing tunnel service if recreating
-	if isRecreating {
-		docker.RemoveTunnelService(compose)
-	}
-
 	// Create tunnel container config
 	containerConfig, err := h.tunnelService.CreateQuickTunnelConfig(payload.Service, payload.Port, metricsPort)
 	if err != nil {
 		return fmt.Errorf("failed to create Quick Tunnel config: %w", err)
 	}
 
-	// Inject tunnel container
-	networks := docker.ExtractNetworks(compose)
-	network := ""
-	if len(networks) > 0 {
-		network = networks[0]
-	}
-	injected, err := docker.InjectTunnelContainer(compose, app.Name, containerConfig, network)
+	// Generate the tunnel sidecar; the user's compose is left untouched
+	composeContent, tunnelCompose, err := docker.GenerateTunnelCompose(app.ComposeContent, app.ComposeFiles, app.Name, containerConfig)
 	if err != nil {
-		return fmt.Errorf("failed to inject Quick Tunnel container: %w", err)
+		return fmt.Errorf("invalid compose file: %w", err)
 	}
-	if !injected {
-		return fmt.Errorf("Quick Tunnel container was not injected")
+	if tunnelCompose == "" {
+		return fmt.Errorf("Quick Tunnel sidecar was not generated")
 	}
 
-	progress.Update(50, "Updating compose file...")
+	progress.Update(50, "Updating app configuration...")
 
-	// Marshal and save compose content
-	composeBytes, err := docker.MarshalComposeFile(compose)
-	if err != nil {
-		return fmt.Errorf("failed to marshal compose file: %w", err)
-	}
-	app.ComposeContent = string(composeBytes)
+	// The user's compose only changes when an inline tunnel service left by older versions was stripped
+	composeChanged := composeContent != app.ComposeContent
+	app.ComposeContent = composeContent
+	app.TunnelCompose = tunnelCompose
 	app.TunnelMode = constants.TunnelModeQuick
 	app.TunnelID = ""
 	app.TunnelToken = ""
@@ -132,21 +118,26 @@ func (h *QuickTunnelHandler) Handle(ctx context.Context, job *db.Job, progress *
 		return fmt.Errorf("failed to update app in database: %w", err)
 	}
 
-	progress.Update(60, "Saving compose version...")
+	if composeChanged {
+		progress.Update(60, "Saving compose version...")
 
-	// Create compose version
-	latestVersion, _ := h.db.GetLatestVersionNumber(job.AppID)
-	_ = h.db.MarkAllVersionsAsNotCurrent(job.AppID)
-	updateReason := constants.ComposeVersionReasonQuickTunnel
-	newVersion := db.NewComposeVersion(job.AppID, latestVersion+1, app.ComposeContent, &updateReason, nil)
-	_ = h.db.CreateComposeVersion(newVersion)
+		latestVersion, _ := h.db.GetLatestVersionNumber(job.AppID)
+		_ = h.db.MarkAllVersionsAsNotCurrent(job.AppID)
+		updateReason := constants.ComposeVersionReasonQuickTunnel
+		newVersion := db.NewComposeVersion(job.AppID, latestVersion+1, app.ComposeContent, &updateReason, nil)
+		newVersion.ComposeOverride = app.ComposeOverride
+		newVersion.ComposeFiles = app.ComposeFiles
+		_ = h.db.CreateComposeVersion(newVersion)
+	}
 
-	progress.Update(70, "Writing compose file to disk...")
+	progress.Update(70, "Writing compose files to disk...")
 
-	// Write compose file
 	if err := h.dockerManager.WriteComposeFile(app.Name, app.ComposeContent); err != nil {
 		return fmt.Errorf("failed to write compose file: %w", err)
 	}
+	if err := h.dockerManager.WriteTunnelComposeFile(app.Name, app.TunnelCompose); err != nil {
+		return fmt.Errorf("failed to write tunnel compose file: %w", err)
+	}
 
 	progress.Update(80, "Restarting containers...")
 
@@ -166,10 +157,9 @@ func (h *QuickTunnelHandler) Handle(ctx context.Context, job *db.Job, progress *
 	}
 
 	// Update app status
-	app.Status = constants.AppStatusRunning
-	app.ErrorMessage = nil
-	app.UpdatedAt = time.Now()
-	_ = h.db.UpdateApp(app)
+	if err := h.states.Transition(ctx, app, constants.AppStatusRunning, ""); err != nil {
+		h.logger.Warn("failed to update app status to running", "app_id", app.ID, "error", err)
+	}
 
 	progress.Update(90, "Extracting Quick Tunnel URL...")
 
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"log/slog"
 
+	"github.com/selfhostly/internal/constants"
 	"github.com/selfhostly/internal/db"
 	"github.com/selfhostly/internal/docker"
 	"github.com/selfhostly/internal/domain"
@@ -56,32 +57,43 @@ func (h *TunnelCreateHandler) Handle(ctx context.Context, job *db.Job, progress
 		return fmt.Errorf("failed to get app: %w", err)
 	}
 
-	progress.Update(20, "Creating tunnel with provider...")
+	// Convert payload ingress rules to domain ingress rules
+	domainRules := make([]db.IngressRule, len(payload.IngressRules))
+	for i, rule := range payload.IngressRules {
+		domainRules[i] = db.IngressRule{
+			Hostname:      rule.Hostname,
+			Service:       rule.Service,
+			Path:          rule.Path,
+			OriginRequest: rule.OriginRequest,
+		}
+	}
 
 	// Delegate to app service which has access to provider registry
-	// The sync method CreateTunnelForApp does all the heavy lifting
-	// We call it here in the background job context
+	// The sync methods do all the heavy lifting
+	// We call them here in the background job context
 	// Note: We pass app.NodeID since we're on the same node
-	updatedApp, _, err := h.appService.CreateTunnelForApp(ctx, app.ID, app.NodeID, nil)
-	if err != nil {
-		return fmt.Errorf("failed to create tunnel: %w", err)
+	var updatedApp *db.App
+	switching := app.TunnelMode == constants.TunnelModeQuick
+	if switching {
+		// The Quick Tunnel keeps serving until the named tunnel is connected, and the ingress
+		// rules are applied before the named tunnel takes over
+		progress.Update(20, "Starting named tunnel alongside Quick Tunnel...")
+		updatedApp, err = h.appService.SwitchAppToCustomTunnel(ctx, app.ID, app.NodeID, domainRules)
+		if err != nil {
+			return fmt.Errorf("failed to switch to named tunnel: %w", err)
+		}
+	} else {
+		progress.Update(20, "Creating tunnel with provider...")
+		updatedApp, _, err = h.appService.CreateTunnelForApp(ctx, app.ID, app.NodeID, nil)
+		if err != nil {
+			return fmt.Errorf("failed to create tunnel: %w", err)
+		}
 	}
 
-	// If ingress rules were provided, apply them now
-	if len(payload.IngressRules) > 0 {
+	// If ingress rules were provided for a new tunnel, apply them now
+	if len(domainRules) > 0 && !switching {
 		progress.Update(80, "Applying ingress rules...")
 
-		// Convert payload ingress rules to domain ingress rules
-		domainRules := make([]db.IngressRule, len(payload.IngressRules))
-		for i, rule := range payload.IngressRules {
-			domainRules[i] = db.IngressRule{
-				Hostname:      rule.Hostname,
-				Service:       rule.Service,
-				Path:          rule.Path,
-				OriginRequest: rule.OriginRequest,
-			}
-		}
-
 		ingressReq := domain.UpdateIngressRequest{IngressRules: domainRules}
 		if err := h.tunnelService.UpdateTunnelIngress(ctx, app.ID, app.NodeID, ingressReq); err != nil {
 			// Don't fail the entire job if ingress update fails - tunnel is still created
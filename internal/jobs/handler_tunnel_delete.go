@@ -2,6 +2,8 @@ package jobs
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
 	"log/slog"
 
 	"github.com/selfhostly/internal/db"
@@ -10,9 +12,11 @@ import (
 )
 
 // TunnelDeleteHandler handles tunnel_delete jobs
+// When the payload names a Quick Tunnel target, the deleted tunnel is replaced with a Quick Tunnel in the same job
 type TunnelDeleteHandler struct {
 	db            *db.DB
 	dockerManager *docker.Manager
+	appService    domain.AppService
 	tunnelService domain.TunnelService
 	logger        *slog.Logger
 }
@@ -21,12 +25,14 @@ type TunnelDeleteHandler struct {
 func NewTunnelDeleteHandler(
 	database *db.DB,
 	dockerMgr *docker.Manager,
+	appSvc domain.AppService,
 	tunnelSvc domain.TunnelService,
 	logger *slog.Logger,
 ) *TunnelDeleteHandler {
 	return &TunnelDeleteHandler{
 		db:            database,
 		dockerManager: dockerMgr,
+		appService:    appSvc,
 		tunnelService: tunnelSvc,
 		logger:        logger,
 	}
@@ -34,6 +40,14 @@ func NewTunnelDeleteHandler(
 
 // Handle processes a tunnel_delete job
 func (h *TunnelDeleteHandler) Handle(ctx context.Context, job *db.Job, progress *ProgressTracker) error {
+	var payload TunnelDeletePayload
+	if job.Payload != nil {
+		if err := json.Unmarshal([]byte(*job.Payload), &payload); err != nil {
+			return fmt.Errorf("failed to parse tunnel_delete payload: %w", err)
+		}
+	}
+	quickTunnelFallback := payload.QuickTunnelService != "" && payload.QuickTunnelPort != 0
+
 	progress.Update(5, "Getting app details...")
 
 	// Get app
@@ -64,6 +78,20 @@ func (h *TunnelDeleteHandler) Handle(ctx context.Context, job *db.Job, progress
 		return err
 	}
 
+	if quickTunnelFallback {
+		progress.Update(60, fmt.Sprintf("Starting Quick Tunnel to %s:%d...", payload.QuickTunnelService, payload.QuickTunnelPort))
+
+		// The named tunnel is already gone, so a failure here leaves the app without a public URL;
+		// the job fails so that shows, and a Quick Tunnel can be added again from the app
+		updatedApp, err := h.appService.CreateQuickTunnelForApp(ctx, app.ID, app.NodeID, payload.QuickTunnelService, payload.QuickTunnelPort)
+		if err != nil {
+			return fmt.Errorf("tunnel deleted, but the Quick Tunnel could not be started: %w", err)
+		}
+		h.logger.Info("tunnel replaced with Quick Tunnel via background job", "app_id", app.ID, "public_url", updatedApp.PublicURL)
+		progress.Update(100, "Tunnel replaced with Quick Tunnel")
+		return nil
+	}
+
 	progress.Update(90, "Cleaning up configuration...")
 	progress.Update(95, "Tunnel deleted successfully")
 
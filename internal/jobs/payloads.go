@@ -20,17 +20,49 @@ type AppUpdatePayload struct {
 	// NoCache       bool `json:"no_cache,omitempty"`
 }
 
+// AppUpdateResult is the result of app_update jobs
+type AppUpdateResult struct {
+	// PlatformWarnings flag the app's images that have no manifest for the node's architecture
+	PlatformWarnings []string `json:"platform_warnings,omitempty"`
+}
+
 // TunnelCreatePayload contains data for tunnel_create jobs
 type TunnelCreatePayload struct {
 	IngressRules []IngressRule `json:"ingress_rules,omitempty"`
 }
 
+// TunnelDeletePayload contains data for tunnel_delete jobs
+type TunnelDeletePayload struct {
+	// QuickTunnelService and QuickTunnelPort are set when a Quick Tunnel to that target replaces
+	// the deleted tunnel
+	QuickTunnelService string `json:"quick_tunnel_service,omitempty"`
+	QuickTunnelPort    int    `json:"quick_tunnel_port,omitempty"`
+}
+
 // QuickTunnelPayload contains data for quick_tunnel jobs
 type QuickTunnelPayload struct {
 	Service string `json:"service"`
 	Port    int    `json:"port"`
 }
 
+// AppRunPayload contains data for app_run jobs
+type AppRunPayload struct {
+	Service string   `json:"service"`
+	Command []string `json:"command"`
+	Mode    string   `json:"mode"` // constants.RunModeRun or constants.RunModeExec
+	// TimeoutSeconds fails the command after this long, as for domain.RunAppCommandRequest; 0 means
+	// constants.RunDefaultTimeout
+	TimeoutSeconds int `json:"timeout_seconds,omitempty"`
+	// TaskID is set when the command is a run of a recurring task, which records its history
+	TaskID string `json:"task_id,omitempty"`
+}
+
+// NodeRemovePayload contains data for node_remove jobs
+type NodeRemovePayload struct {
+	Strategy     string `json:"strategy"`                 // constants.NodeRemoveStrategy*
+	TargetNodeID string `json:"target_node_id,omitempty"` // Migration target
+}
+
 // IngressRule represents a tunnel ingress rule
 type IngressRule struct {
 	Hostname      *string                `json:"hostname,omitempty"`
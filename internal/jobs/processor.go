@@ -2,45 +2,67 @@ package jobs
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"log/slog"
 
+	"github.com/selfhostly/internal/applock"
+	"github.com/selfhostly/internal/appstate"
 	"github.com/selfhostly/internal/constants"
 	"github.com/selfhostly/internal/db"
 	"github.com/selfhostly/internal/docker"
 	"github.com/selfhostly/internal/domain"
+	"github.com/selfhostly/internal/webhook"
 )
 
 // Processor handles the execution of background jobs
 type Processor struct {
 	registry *HandlerRegistry
 	db       *db.DB
+	webhooks *webhook.Dispatcher
 	logger   *slog.Logger
 }
 
+// jobWebhookEvents maps job types to the app webhook event fired when they succeed
+var jobWebhookEvents = map[string]string{
+	constants.JobTypeAppStart:          constants.WebhookEventStart,
+	constants.JobTypeAppScheduledStart: constants.WebhookEventStart,
+	constants.JobTypeAppStop:           constants.WebhookEventStop,
+	constants.JobTypeAppScheduledStop:  constants.WebhookEventStop,
+	constants.JobTypeAppUpdate:         constants.WebhookEventUpdate,
+}
+
 // NewProcessor creates a new job processor with registered handlers
 func NewProcessor(
 	database *db.DB,
+	states *appstate.Machine,
 	dockerMgr *docker.Manager,
 	appSvc domain.AppService,
 	tunnelSvc domain.TunnelService,
+	nodeSvc domain.NodeService,
+	webhooks *webhook.Dispatcher,
 	logger *slog.Logger,
 ) *Processor {
 	registry := NewHandlerRegistry()
 
 	// Register all handlers
-	registry.Register(constants.JobTypeAppCreate, NewAppCreateHandler(database, dockerMgr, appSvc, tunnelSvc, logger))
-	registry.Register(constants.JobTypeAppUpdate, NewAppUpdateHandler(database, dockerMgr, logger))
-	registry.Register(constants.JobTypeAppStart, NewAppStartHandler(database, dockerMgr, logger))
-	registry.Register(constants.JobTypeAppStop, NewAppStopHandler(database, dockerMgr, logger))
-	registry.Register(constants.JobTypeAppScheduledStart, NewAppScheduledStartHandler(database, dockerMgr, logger))
-	registry.Register(constants.JobTypeAppScheduledStop, NewAppScheduledStopHandler(database, dockerMgr, logger))
+	registry.Register(constants.JobTypeAppCreate, NewAppCreateHandler(database, states, dockerMgr, appSvc, tunnelSvc, logger))
+	registry.Register(constants.JobTypeAppUpdate, NewAppUpdateHandler(database, states, dockerMgr, appSvc, logger))
+	registry.Register(constants.JobTypeAppStart, NewAppStartHandler(database, states, dockerMgr, logger))
+	registry.Register(constants.JobTypeAppStop, NewAppStopHandler(database, states, dockerMgr, logger))
+	registry.Register(constants.JobTypeAppScheduledStart, NewAppScheduledStartHandler(database, states, dockerMgr, logger))
+	registry.Register(constants.JobTypeAppScheduledStop, NewAppScheduledStopHandler(database, states, dockerMgr, logger))
 	registry.Register(constants.JobTypeTunnelCreate, NewTunnelCreateHandler(database, dockerMgr, appSvc, tunnelSvc, logger))
-	registry.Register(constants.JobTypeTunnelDelete, NewTunnelDeleteHandler(database, dockerMgr, tunnelSvc, logger))
-	registry.Register(constants.JobTypeQuickTunnel, NewQuickTunnelHandler(database, dockerMgr, tunnelSvc, logger))
+	registry.Register(constants.JobTypeTunnelDelete, NewTunnelDeleteHandler(database, dockerMgr, appSvc, tunnelSvc, logger))
+	registry.Register(constants.JobTypeQuickTunnel, NewQuickTunnelHandler(database, states, dockerMgr, tunnelSvc, logger))
+	registry.Register(constants.JobTypeAppRun, NewAppRunHandler(database, dockerMgr, webhooks, logger))
+	registry.Register(constants.JobTypeNodeRemove, NewNodeRemoveHandler(nodeSvc, logger))
 
 	return &Processor{
 		registry: registry,
 		db:       database,
+		webhooks: webhooks,
 		logger:   logger,
 	}
 }
@@ -60,7 +82,28 @@ func (p *Processor) ProcessJob(ctx context.Context, job *db.Job) error {
 	if err != nil {
 		p.logger.ErrorContext(ctx, "unknown job type", "job_id", job.ID, "type", job.Type, "error", err)
 		errorMsg := err.Error()
-		return p.db.UpdateJobCompleted(job.ID, constants.JobStatusFailed, nil, &errorMsg)
+		return completeJob(p.db, job.ID, constants.JobStatusFailed, nil, &errorMsg)
+	}
+
+	// Serialize with other operations on the same app: jobs queue behind the current lease
+	// holder instead of failing, and pass the lease on to anything the handler calls
+	if job.AppID != "" {
+		lockCtx, unlock, lockErr := applock.Wait(ctx, p.db, job.AppID, job.ID, job.Type, func(blockedBy string) {
+			progress.Update(0, fmt.Sprintf("Waiting for %s to finish...", blockedBy))
+		})
+		if lockErr != nil {
+			p.logger.ErrorContext(ctx, "job could not acquire app lock", "job_id", job.ID, "type", job.Type, "app_id", job.AppID, "error", lockErr)
+			errorMsg := lockErr.Error()
+			return completeJob(p.db, job.ID, constants.JobStatusFailed, nil, &errorMsg)
+		}
+		defer unlock()
+		ctx = lockCtx
+	}
+
+	// Start and stop jobs skip apps already in the target state; only a real change fires a webhook
+	var statusBefore string
+	if app, getErr := p.db.GetApp(job.AppID); getErr == nil {
+		statusBefore = app.Status
 	}
 
 	// Process the job
@@ -70,9 +113,47 @@ func (p *Processor) ProcessJob(ctx context.Context, job *db.Job) error {
 	if err != nil {
 		p.logger.ErrorContext(ctx, "job failed", "job_id", job.ID, "type", job.Type, "error", err)
 		errorMsg := err.Error()
-		return p.db.UpdateJobCompleted(job.ID, constants.JobStatusFailed, nil, &errorMsg)
+		result := p.failureResult(ctx, job, err)
+		if result == nil {
+			result = progress.Result()
+		}
+		return completeJob(p.db, job.ID, constants.JobStatusFailed, result, &errorMsg)
 	}
 
 	p.logger.InfoContext(ctx, "job completed successfully", "job_id", job.ID, "type", job.Type)
-	return p.db.UpdateJobCompleted(job.ID, constants.JobStatusCompleted, nil, nil)
+	p.fireWebhook(ctx, job, statusBefore)
+	return completeJob(p.db, job.ID, constants.JobStatusCompleted, progress.Result(), nil)
+}
+
+// failureResult is the job result of a failed job: the compose output and container logs captured
+// when a start or update failed, as JSON. Nil for other failures, which keep any result the handler
+// recorded.
+func (p *Processor) failureResult(ctx context.Context, job *db.Job, err error) *string {
+	var opErr *docker.OperationError
+	if !errors.As(err, &opErr) {
+		return nil
+	}
+	data, marshalErr := json.Marshal(map[string]*docker.OperationError{"failure": opErr})
+	if marshalErr != nil {
+		p.logger.WarnContext(ctx, "failed to encode job failure", "job_id", job.ID, "error", marshalErr)
+		return nil
+	}
+	result := string(data)
+	return &result
+}
+
+// fireWebhook notifies the app's webhooks of a completed lifecycle job
+func (p *Processor) fireWebhook(ctx context.Context, job *db.Job, statusBefore string) {
+	event, ok := jobWebhookEvents[job.Type]
+	if !ok {
+		return
+	}
+	app, err := p.db.GetApp(job.AppID)
+	if err != nil {
+		return
+	}
+	if event != constants.WebhookEventUpdate && app.Status == statusBefore {
+		return
+	}
+	p.webhooks.Fire(ctx, app, event, fmt.Sprintf("%s job %s", job.Type, job.ID))
 }
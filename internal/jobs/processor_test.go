@@ -2,12 +2,16 @@ package jobs
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
 	"log/slog"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/selfhostly/internal/appstate"
 	"github.com/selfhostly/internal/constants"
 	"github.com/selfhostly/internal/db"
 	"github.com/selfhostly/internal/docker"
@@ -58,9 +62,12 @@ func TestProcessor_AppUpdate(t *testing.T) {
 	// Since these aren't used by AppUpdateHandler, we can pass nil
 	processor := NewProcessor(
 		database,
+		appstate.New(database, slog.Default()),
 		dockerMgrWithMock,
 		nil, // appService not needed for app_update
 		nil, // tunnelService not needed for app_update
+		nil, // nodeService not needed for app_update
+		nil, // no webhooks
 		slog.Default(),
 	)
 
@@ -95,6 +102,140 @@ func TestProcessor_AppUpdate(t *testing.T) {
 	}
 }
 
+func TestProcessor_AppUpdateFailureCapturesLogs(t *testing.T) {
+	tmpDir := t.TempDir()
+	appsDir := filepath.Join(tmpDir, "apps")
+
+	database, err := db.Init(filepath.Join(tmpDir, "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to initialize database: %v", err)
+	}
+	defer database.Close()
+
+	app := db.NewApp("broken-app", "", "services:\n  web:\n    image: nginx:latest\n")
+	app.Status = constants.AppStatusRunning
+	if err := database.CreateApp(app); err != nil {
+		t.Fatalf("Failed to create app: %v", err)
+	}
+
+	mockExecutor := docker.NewMockCommandExecutor()
+	dockerMgr := docker.NewManagerWithExecutor(appsDir, mockExecutor)
+	if err := dockerMgr.CreateAppDirectory(app.Name, app.ComposeContent); err != nil {
+		t.Fatalf("Failed to create app directory: %v", err)
+	}
+	upCmd := docker.ComposeUpWithBuildCommand()
+	mockExecutor.SetMockError("docker", upCmd[1:], fmt.Errorf("exit status 1"))
+	logsCmd := docker.ComposeLogsTailCommand(constants.FailureLogTailLines, "")
+	mockExecutor.SetMockOutput("docker", logsCmd[1:], []byte("web-1  | bind: address already in use\n"))
+
+	job := db.NewJob(constants.JobTypeAppUpdate, app.ID, nil)
+	if err := database.CreateJob(job); err != nil {
+		t.Fatalf("Failed to create job: %v", err)
+	}
+
+	processor := NewProcessor(database, appstate.New(database, slog.Default()), dockerMgr, nil, nil, nil, nil, slog.Default())
+	if err := processor.ProcessJob(context.Background(), job); err != nil {
+		t.Fatalf("Failed to record job failure: %v", err)
+	}
+
+	failedJob, err := database.GetJob(job.ID)
+	if err != nil {
+		t.Fatalf("Failed to get job: %v", err)
+	}
+	if failedJob.Status != constants.JobStatusFailed || failedJob.Result == nil {
+		t.Fatalf("Expected a failed job with a result, got %s (%v)", failedJob.Status, failedJob.Result)
+	}
+	var result struct {
+		Failure docker.OperationError `json:"failure"`
+	}
+	if err := json.Unmarshal([]byte(*failedJob.Result), &result); err != nil {
+		t.Fatalf("Failed to decode job result: %v", err)
+	}
+	if result.Failure.Operation != "update" || !strings.Contains(result.Failure.Logs, "address already in use") {
+		t.Errorf("Expected the captured logs in the job result, got %+v", result.Failure)
+	}
+
+	failedApp, err := database.GetApp(app.ID)
+	if err != nil {
+		t.Fatalf("Failed to get app: %v", err)
+	}
+	if failedApp.Status != constants.AppStatusError || failedApp.ErrorMessage == nil || !strings.Contains(*failedApp.ErrorMessage, "address already in use") {
+		t.Errorf("Expected the app in error with the captured logs, got %s (%v)", failedApp.Status, failedApp.ErrorMessage)
+	}
+}
+
+func TestProcessor_AppRunRecordsOutput(t *testing.T) {
+	tmpDir := t.TempDir()
+	appsDir := filepath.Join(tmpDir, "apps")
+
+	database, err := db.Init(filepath.Join(tmpDir, "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to initialize database: %v", err)
+	}
+	defer database.Close()
+
+	app := db.NewApp("run-app", "", "services:\n  web:\n    image: nginx:latest\n")
+	app.Status = constants.AppStatusRunning
+	if err := database.CreateApp(app); err != nil {
+		t.Fatalf("Failed to create app: %v", err)
+	}
+
+	mockExecutor := docker.NewMockCommandExecutor()
+	dockerMgr := docker.NewManagerWithExecutor(appsDir, mockExecutor)
+	if err := dockerMgr.CreateAppDirectory(app.Name, app.ComposeContent); err != nil {
+		t.Fatalf("Failed to create app directory: %v", err)
+	}
+	runCmd := docker.ComposeExecCommand("web", []string{"nginx", "-t"})
+	mockExecutor.SetMockOutput("docker", runCmd[1:], []byte("syntax is ok\ntest is successful\n"))
+
+	payload, _ := json.Marshal(AppRunPayload{Service: "web", Command: []string{"nginx", "-t"}, Mode: constants.RunModeExec})
+	payloadStr := string(payload)
+	job := db.NewJob(constants.JobTypeAppRun, app.ID, &payloadStr)
+	if err := database.CreateJob(job); err != nil {
+		t.Fatalf("Failed to create job: %v", err)
+	}
+
+	processor := NewProcessor(database, appstate.New(database, slog.Default()), dockerMgr, nil, nil, nil, nil, slog.Default())
+	if err := processor.ProcessJob(context.Background(), job); err != nil {
+		t.Fatalf("Job processing failed: %v", err)
+	}
+
+	doneJob, err := database.GetJob(job.ID)
+	if err != nil {
+		t.Fatalf("Failed to get job: %v", err)
+	}
+	if doneJob.Status != constants.JobStatusCompleted || doneJob.Result == nil {
+		t.Fatalf("Expected a completed job with a result, got %s (%v)", doneJob.Status, doneJob.Result)
+	}
+	var result docker.CommandResult
+	if err := json.Unmarshal([]byte(*doneJob.Result), &result); err != nil {
+		t.Fatalf("Failed to decode job result: %v", err)
+	}
+	if result.ExitCode != 0 || result.Output != "syntax is ok\ntest is successful" {
+		t.Errorf("Unexpected command result: %+v", result)
+	}
+
+	// A failing command keeps its result and leaves the app's status alone
+	mockExecutor.SetMockError("docker", runCmd[1:], fmt.Errorf("exit status 1"))
+	failJob := db.NewJob(constants.JobTypeAppRun, app.ID, &payloadStr)
+	if err := database.CreateJob(failJob); err != nil {
+		t.Fatalf("Failed to create job: %v", err)
+	}
+	if err := processor.ProcessJob(context.Background(), failJob); err != nil {
+		t.Fatalf("Failed to record job failure: %v", err)
+	}
+	failedJob, err := database.GetJob(failJob.ID)
+	if err != nil {
+		t.Fatalf("Failed to get job: %v", err)
+	}
+	if failedJob.Status != constants.JobStatusFailed || failedJob.Result == nil {
+		t.Fatalf("Expected a failed job with a result, got %s (%v)", failedJob.Status, failedJob.Result)
+	}
+	if runApp, _ := database.GetApp(app.ID); runApp.Status != constants.AppStatusRunning {
+		t.Errorf("Expected the app to stay running, got %s", runApp.Status)
+	}
+}
+
 func TestWorker_ConcurrencyControl(t *testing.T) {
 	tmpDir := t.TempDir()
 	dbPath := filepath.Join(tmpDir, "test.db")
@@ -198,3 +339,56 @@ func TestDB_MarkStaleJobs(t *testing.T) {
 		t.Error("Expected error message to be set")
 	}
 }
+
+func TestProcessor_AppRunRecordsTaskRun(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	database, err := db.Init(filepath.Join(tmpDir, "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to initialize database: %v", err)
+	}
+	defer database.Close()
+
+	app := db.NewApp("task-app", "", "services:\n  web:\n    image: nginx:latest\n")
+	if err := database.CreateApp(app); err != nil {
+		t.Fatalf("Failed to create app: %v", err)
+	}
+	task := db.NewAppTask(app.ID, "backup", "web", []string{"backup.sh"}, constants.RunModeRun, "@daily", "UTC", 0)
+	if err := database.CreateTask(task); err != nil {
+		t.Fatalf("Failed to create task: %v", err)
+	}
+
+	mockExecutor := docker.NewMockCommandExecutor()
+	dockerMgr := docker.NewManagerWithExecutor(filepath.Join(tmpDir, "apps"), mockExecutor)
+	if err := dockerMgr.CreateAppDirectory(app.Name, app.ComposeContent); err != nil {
+		t.Fatalf("Failed to create app directory: %v", err)
+	}
+	payload, _ := json.Marshal(AppRunPayload{Service: "web", Command: []string{"backup.sh"}, Mode: constants.RunModeRun, TaskID: task.ID})
+	payloadStr := string(payload)
+	job := db.NewJob(constants.JobTypeAppRun, app.ID, &payloadStr)
+	if err := database.CreateJob(job); err != nil {
+		t.Fatalf("Failed to create job: %v", err)
+	}
+	runCmd := docker.ComposeRunCommand(app.Name+"-web-run-"+job.ID, "web", []string{"backup.sh"})
+	mockExecutor.SetMockError("docker", runCmd[1:], fmt.Errorf("exit status 2"))
+
+	processor := NewProcessor(database, appstate.New(database, slog.Default()), dockerMgr, nil, nil, nil, nil, slog.Default())
+	if err := processor.ProcessJob(context.Background(), job); err != nil {
+		t.Fatalf("Failed to record job failure: %v", err)
+	}
+
+	runs, err := database.GetTaskRuns(task.ID, 10)
+	if err != nil || len(runs) != 1 {
+		t.Fatalf("GetTaskRuns = %+v, %v", runs, err)
+	}
+	if runs[0].JobID != job.ID || runs[0].Status != constants.JobStatusFailed || runs[0].ErrorMessage == nil {
+		t.Errorf("Unexpected task run: %+v", runs[0])
+	}
+	updated, err := database.GetTaskByID(task.ID)
+	if err != nil {
+		t.Fatalf("Failed to get task: %v", err)
+	}
+	if updated.LastStatus != constants.JobStatusFailed || updated.LastRunAt == nil {
+		t.Errorf("Expected the task's last run to be recorded, got %+v", updated)
+	}
+}
@@ -1,10 +1,12 @@
 package jobs
 
 import (
+	"encoding/json"
 	"log/slog"
 
 	"github.com/selfhostly/internal/constants"
 	"github.com/selfhostly/internal/db"
+	"github.com/selfhostly/internal/events"
 )
 
 // ProgressTracker provides a simple interface for updating job progress
@@ -12,6 +14,7 @@ type ProgressTracker struct {
 	jobID  string
 	db     *db.DB
 	logger *slog.Logger
+	result *string
 }
 
 // NewProgressTracker creates a new progress tracker for a job
@@ -37,6 +40,7 @@ func (pt *ProgressTracker) Update(progress int, message string) {
 		pt.logger.Error("failed to update job progress", "job_id", pt.jobID, "error", err)
 	} else {
 		pt.logger.Debug("job progress updated", "job_id", pt.jobID, "progress", progress, "message", message)
+		publishJob(pt.db, pt.jobID)
 	}
 }
 
@@ -51,5 +55,45 @@ func (pt *ProgressTracker) UpdateMessage(message string) {
 
 	if err := pt.db.UpdateJobStatus(pt.jobID, constants.JobStatusRunning, job.Progress, &message); err != nil {
 		pt.logger.Error("failed to update job message", "job_id", pt.jobID, "error", err)
+		return
+	}
+	publishJob(pt.db, pt.jobID)
+}
+
+// SetResult records v, as JSON, to be stored as the job's result when it finishes
+func (pt *ProgressTracker) SetResult(v interface{}) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		pt.logger.Error("failed to encode job result", "job_id", pt.jobID, "error", err)
+		return
+	}
+	result := string(data)
+	pt.result = &result
+}
+
+// Result returns the result recorded with SetResult, or nil
+func (pt *ProgressTracker) Result() *string {
+	return pt.result
+}
+
+// publishJob tells event stream clients a job changed, sending it as it now is. Nothing is
+// published while no client is watching, or if the job can't be read back.
+func publishJob(database *db.DB, jobID string) {
+	if events.Default().Subscribers() == 0 {
+		return
+	}
+	job, err := database.GetJob(jobID)
+	if err != nil {
+		return
+	}
+	events.Publish(events.Event{Type: events.TypeJob, AppID: job.AppID, Data: job})
+}
+
+// completeJob marks a job completed or failed and publishes it
+func completeJob(database *db.DB, jobID, status string, result, errorMsg *string) error {
+	if err := database.UpdateJobCompleted(jobID, status, result, errorMsg); err != nil {
+		return err
 	}
+	publishJob(database, jobID)
+	return nil
 }
@@ -0,0 +1,102 @@
+package jobs
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/selfhostly/internal/constants"
+	"github.com/selfhostly/internal/db"
+)
+
+// requeueableJobTypes are the app jobs that can simply run again after being interrupted, as each
+// drives the app to its target state from wherever it was left
+var requeueableJobTypes = map[string]bool{
+	constants.JobTypeAppCreate:         true,
+	constants.JobTypeAppUpdate:         true,
+	constants.JobTypeAppStart:          true,
+	constants.JobTypeAppStop:           true,
+	constants.JobTypeAppScheduledStart: true,
+	constants.JobTypeAppScheduledStop:  true,
+}
+
+// recoverStuckApps finds apps left "updating" or "pending" with no job to finish them, e.g.
+// because the server crashed mid-update, and moves each to a consistent state: its interrupted job
+// is queued again, or, when that isn't possible, the app is marked as errored with the reason.
+func (w *Worker) recoverStuckApps() error {
+	apps, err := w.db.GetAllAppsMetadata()
+	if err != nil {
+		return err
+	}
+
+	for _, app := range apps {
+		if app.Status != constants.AppStatusUpdating && app.Status != constants.AppStatusPending {
+			continue
+		}
+		if err := w.recoverStuckApp(app); err != nil {
+			w.logger.Error("failed to recover stuck app", "app_id", app.ID, "status", app.Status, "error", err)
+		}
+	}
+	return nil
+}
+
+func (w *Worker) recoverStuckApp(app *db.App) error {
+	active, err := w.db.GetActiveJobForApp(app.ID)
+	if err != nil {
+		return err
+	}
+	if active != nil {
+		// A pending job will still run and settle the app
+		if active.Status == constants.JobStatusPending {
+			return nil
+		}
+		// A running one can't be: this worker has only just started
+		msg := "Interrupted by a server restart"
+		if err := completeJob(w.db, active.ID, constants.JobStatusFailed, nil, &msg); err != nil {
+			return err
+		}
+	}
+
+	recent, err := w.db.GetJobsByAppID(app.ID, 1)
+	if err != nil {
+		return err
+	}
+	var last *db.Job
+	if len(recent) > 0 {
+		last = recent[0]
+	}
+
+	if last != nil && last.Status == constants.JobStatusFailed && requeueableJobTypes[last.Type] &&
+		last.RetryCount < constants.JobRecoveryMaxRequeues {
+		retry := db.NewJob(last.Type, app.ID, last.Payload)
+		retry.RetryCount = last.RetryCount + 1
+		retry.Priority = last.Priority
+		if err := w.db.CreateJob(retry); err != nil {
+			return err
+		}
+		w.logger.Warn("re-queued interrupted job for stuck app",
+			"app_id", app.ID,
+			"app_name", app.Name,
+			"status", app.Status,
+			"job_type", last.Type,
+			"interrupted_job_id", last.ID,
+			"job_id", retry.ID,
+		)
+		return nil
+	}
+
+	reason := fmt.Sprintf("App was left %s with no job to finish it; retry the operation", app.Status)
+	if last != nil {
+		reason = fmt.Sprintf("App was left %s when its %s job (%s) ended with status %s; retry the operation",
+			app.Status, last.Type, last.ID, last.Status)
+	}
+	// app is a metadata row without the compose and env files; Transition saves every column
+	full, err := w.db.GetApp(app.ID)
+	if err != nil {
+		return err
+	}
+	if err := w.states.Transition(context.Background(), full, constants.AppStatusError, reason); err != nil {
+		return err
+	}
+	w.logger.Warn("marked stuck app as errored", "app_id", app.ID, "app_name", app.Name, "reason", reason)
+	return nil
+}
@@ -0,0 +1,102 @@
+package jobs
+
+import (
+	"log/slog"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/selfhostly/internal/appstate"
+	"github.com/selfhostly/internal/constants"
+	"github.com/selfhostly/internal/db"
+)
+
+func createStuckApp(t *testing.T, database *db.DB, name, status string) *db.App {
+	t.Helper()
+	app := db.NewApp(name, "", "services:\n  web:\n    image: nginx:latest\n")
+	app.Status = status
+	app.NodeID = "test-node"
+	if err := database.CreateApp(app); err != nil {
+		t.Fatalf("Failed to create app: %v", err)
+	}
+	return app
+}
+
+func TestWorker_RecoverStuckApps(t *testing.T) {
+	database, err := db.Init(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to initialize database: %v", err)
+	}
+	defer database.Close()
+
+	// Crashed mid-update: the job is still marked running
+	interrupted := createStuckApp(t, database, "interrupted", constants.AppStatusUpdating)
+	payload := `{"pull":true}`
+	running := db.NewJob(constants.JobTypeAppUpdate, interrupted.ID, &payload)
+	if err := database.CreateJob(running); err != nil {
+		t.Fatal(err)
+	}
+	msg := "Pulling images..."
+	if err := database.UpdateJobStatus(running.ID, constants.JobStatusRunning, 40, &msg); err != nil {
+		t.Fatal(err)
+	}
+
+	// Already re-queued once after an earlier crash
+	requeued := createStuckApp(t, database, "requeued", constants.AppStatusUpdating)
+	retried := db.NewJob(constants.JobTypeAppUpdate, requeued.ID, nil)
+	retried.RetryCount = constants.JobRecoveryMaxRequeues
+	if err := database.CreateJob(retried); err != nil {
+		t.Fatal(err)
+	}
+	failed := "Interrupted by a server restart"
+	if err := database.UpdateJobCompleted(retried.ID, constants.JobStatusFailed, nil, &failed); err != nil {
+		t.Fatal(err)
+	}
+
+	// No job at all, and one whose queued job will still run
+	orphaned := createStuckApp(t, database, "orphaned", constants.AppStatusPending)
+	queued := createStuckApp(t, database, "queued", constants.AppStatusPending)
+	if err := database.CreateJob(db.NewJob(constants.JobTypeAppCreate, queued.ID, nil)); err != nil {
+		t.Fatal(err)
+	}
+	healthy := createStuckApp(t, database, "healthy", constants.AppStatusRunning)
+
+	w := NewWorker(nil, database, appstate.New(database, slog.Default()), constants.JobWorkerPollInterval, slog.Default())
+	if err := w.recoverStuckApps(); err != nil {
+		t.Fatalf("recoverStuckApps: %v", err)
+	}
+
+	if job, _ := database.GetJob(running.ID); job.Status != constants.JobStatusFailed {
+		t.Errorf("Expected the interrupted job to be failed, got %s", job.Status)
+	}
+	active, err := database.GetActiveJobForApp(interrupted.ID)
+	if err != nil || active == nil {
+		t.Fatalf("Expected the interrupted job to be re-queued, got %v (%v)", active, err)
+	}
+	if active.Type != constants.JobTypeAppUpdate || active.RetryCount != 1 || active.Payload == nil || *active.Payload != payload {
+		t.Errorf("Unexpected re-queued job %+v", active)
+	}
+	if app, _ := database.GetApp(interrupted.ID); app.Status != constants.AppStatusUpdating {
+		t.Errorf("Expected the re-queued app to stay updating, got %s", app.Status)
+	}
+
+	for _, app := range []*db.App{requeued, orphaned} {
+		got, _ := database.GetApp(app.ID)
+		if got.Status != constants.AppStatusError || got.ErrorMessage == nil || !strings.Contains(*got.ErrorMessage, "was left") {
+			t.Errorf("%s: expected an error status with the reason, got %s (%v)", app.Name, got.Status, got.ErrorMessage)
+		}
+		if got.ComposeContent == "" || got.ComposeContent != app.ComposeContent {
+			t.Errorf("%s: expected the compose file to survive the status change, got %q", app.Name, got.ComposeContent)
+		}
+	}
+	if active, _ := database.GetActiveJobForApp(requeued.ID); active != nil {
+		t.Errorf("Expected no second re-queue, got %+v", active)
+	}
+
+	if got, _ := database.GetApp(queued.ID); got.Status != constants.AppStatusPending {
+		t.Errorf("Expected the app with a queued job to be left alone, got %s", got.Status)
+	}
+	if got, _ := database.GetApp(healthy.ID); got.Status != constants.AppStatusRunning {
+		t.Errorf("Expected a running app to be left alone, got %s", got.Status)
+	}
+}
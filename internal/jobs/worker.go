@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"github.com/google/uuid"
+	"github.com/selfhostly/internal/appstate"
 	"github.com/selfhostly/internal/constants"
 	"github.com/selfhostly/internal/db"
 )
@@ -15,6 +16,7 @@ import (
 type Worker struct {
 	processor    *Processor
 	db           *db.DB
+	states       *appstate.Machine
 	pollInterval time.Duration
 	logger       *slog.Logger
 	workerID     string // Unique ID for this worker instance
@@ -22,13 +24,16 @@ type Worker struct {
 	// State management for graceful shutdown
 	currentJobID string
 	mu           sync.RWMutex
+
+	opsLocked bool // Whether the last poll found operations locked, so the change is logged once
 }
 
 // NewWorker creates a new job worker
-func NewWorker(processor *Processor, database *db.DB, pollInterval time.Duration, logger *slog.Logger) *Worker {
+func NewWorker(processor *Processor, database *db.DB, states *appstate.Machine, pollInterval time.Duration, logger *slog.Logger) *Worker {
 	return &Worker{
 		processor:    processor,
 		db:           database,
+		states:       states,
 		pollInterval: pollInterval,
 		logger:       logger,
 		workerID:     uuid.New().String(), // Generate unique worker ID
@@ -44,6 +49,9 @@ func (w *Worker) Start(ctx context.Context) error {
 		w.logger.Error("failed to recover stale jobs", "error", err)
 		// Don't fail startup, just log the error
 	}
+	if err := w.recoverStuckApps(); err != nil {
+		w.logger.Error("failed to recover apps stuck in a transitional status", "error", err)
+	}
 
 	// Start cleanup routine in background
 	go w.cleanupLoop(ctx)
@@ -65,9 +73,13 @@ func (w *Worker) Start(ctx context.Context) error {
 
 // recoverStaleJobs marks stale "running" jobs as failed on startup
 func (w *Worker) recoverStaleJobs() error {
-	w.logger.Info("checking for stale jobs", "threshold", constants.JobStaleThreshold)
+	threshold := constants.JobStaleThreshold
+	if settings, err := w.db.GetSettings(); err == nil && settings.JobStaleThresholdMinutes > 0 {
+		threshold = time.Duration(settings.JobStaleThresholdMinutes) * time.Minute
+	}
+	w.logger.Info("checking for stale jobs", "threshold", threshold)
 
-	if err := w.db.MarkStaleJobsAsFailed(constants.JobStaleThreshold); err != nil {
+	if err := w.db.MarkStaleJobsAsFailed(threshold); err != nil {
 		return err
 	}
 
@@ -108,7 +120,7 @@ func (w *Worker) gracefulShutdown() error {
 	// Timeout reached, mark job as failed
 	w.logger.Warn("shutdown timeout reached, marking current job as failed", "job_id", currentJobID)
 	errorMsg := "Worker shutdown before job completion"
-	return w.db.UpdateJobCompleted(currentJobID, constants.JobStatusFailed, nil, &errorMsg)
+	return completeJob(w.db, currentJobID, constants.JobStatusFailed, nil, &errorMsg)
 }
 
 // processPendingJobs processes a single pending job if worker is idle
@@ -123,6 +135,11 @@ func (w *Worker) processPendingJobs(ctx context.Context) {
 		return // Already processing a job
 	}
 
+	// While an admin has locked operations, jobs stay pending and run once it is lifted
+	if w.operationsLocked() {
+		return
+	}
+
 	// Atomically claim a pending job
 	job, err := w.db.ClaimPendingJob(w.workerID)
 	if err != nil {
@@ -133,6 +150,7 @@ func (w *Worker) processPendingJobs(ctx context.Context) {
 	if job == nil {
 		return // No job available
 	}
+	publishJob(w.db, job.ID)
 
 	// Mark as current job
 	w.mu.Lock()
@@ -164,6 +182,26 @@ func (w *Worker) processPendingJobs(ctx context.Context) {
 	}
 }
 
+// operationsLocked reports whether an admin has locked operations. Jobs are held rather than run
+// if the lock can't be read.
+func (w *Worker) operationsLocked() bool {
+	lock, err := w.db.GetOperationsLock()
+	if err != nil {
+		w.logger.Error("failed to check operations lock, holding pending jobs", "error", err)
+		return true
+	}
+	locked := lock != nil
+	if locked != w.opsLocked {
+		if locked {
+			w.logger.Warn("operations locked, holding pending jobs", "reason", lock.Reason)
+		} else {
+			w.logger.Info("operations unlocked, resuming pending jobs")
+		}
+		w.opsLocked = locked
+	}
+	return locked
+}
+
 // cleanupLoop periodically cleans up old completed/failed jobs
 func (w *Worker) cleanupLoop(ctx context.Context) {
 	ticker := time.NewTicker(constants.JobHistoryCleanupInterval)
@@ -184,7 +222,11 @@ func (w *Worker) cleanupLoop(ctx context.Context) {
 func (w *Worker) performCleanup() {
 	w.logger.Debug("cleaning up old job records")
 
-	if err := w.db.CleanupAllOldCompletedJobs(constants.JobHistoryKeepCount); err != nil {
+	keepCount := constants.JobHistoryKeepCount
+	if settings, err := w.db.GetSettings(); err == nil && settings.JobHistoryKeepCount > 0 {
+		keepCount = settings.JobHistoryKeepCount
+	}
+	if err := w.db.CleanupAllOldCompletedJobs(keepCount); err != nil {
 		w.logger.Error("failed to cleanup old jobs", "error", err)
 		return
 	}
@@ -1,32 +1,52 @@
 package logger
 
 import (
+	"context"
+	"fmt"
+	"io"
 	"log/slog"
 	"os"
+	"strings"
+	"sync/atomic"
 )
 
+// level and useJSON are shared by every handler InitLogger builds, so the level and output
+// format can change without replacing loggers that services already hold
+var (
+	level   = new(slog.LevelVar)
+	useJSON atomic.Bool
+	sink    atomic.Pointer[sinkTarget]
+)
+
+// sinkTarget receives a JSON copy of records at or above min, for log shipping
+type sinkTarget struct {
+	w   io.Writer
+	min slog.Level
+}
+
 // InitLogger initializes and configures the application logger based on environment
 // Returns a configured slog.Logger instance
-// useJSON: if true, use JSON handler; if false, use text handler
+// json: if true, use JSON handler; if false, use text handler
 // environment: used to determine log level and source info (development enables debug level)
-func InitLogger(environment string, useJSON bool) *slog.Logger {
+func InitLogger(environment string, json bool) *slog.Logger {
 	var handler slog.Handler
 
+	level.Set(defaultLevel(environment))
 	opts := &slog.HandlerOptions{
-		Level: slog.LevelInfo,
+		Level: level,
 	}
 
-	// In development, use more verbose logging
+	// In development, include source file and line number
 	if environment == "development" {
-		opts.Level = slog.LevelDebug
-		opts.AddSource = true // Include source file and line number
+		opts.AddSource = true
 	}
 
-	// Choose handler based on useJSON parameter
-	if useJSON {
-		handler = slog.NewJSONHandler(os.Stdout, opts)
-	} else {
-		handler = slog.NewTextHandler(os.Stdout, opts)
+	// Build both handlers; useJSON picks one per record
+	SetJSON(json)
+	handler = &switchHandler{
+		json: slog.NewJSONHandler(os.Stdout, opts),
+		text: slog.NewTextHandler(os.Stdout, opts),
+		ship: slog.NewJSONHandler(sinkWriter{}, &slog.HandlerOptions{Level: slog.LevelDebug}),
 	}
 
 	logger := slog.New(handler)
@@ -36,3 +56,124 @@ func InitLogger(environment string, useJSON bool) *slog.Logger {
 
 	return logger
 }
+
+// ApplyLevel sets the log level by name (debug, info, warn or error). An empty name restores the
+// environment's default: debug in development, info otherwise.
+func ApplyLevel(environment, name string) error {
+	if name == "" {
+		level.Set(defaultLevel(environment))
+		return nil
+	}
+	l, err := ParseLevel(name)
+	if err != nil {
+		return err
+	}
+	level.Set(l)
+	return nil
+}
+
+// ParseLevel parses a level name (debug, info, warn or error, in any case)
+func ParseLevel(name string) (slog.Level, error) {
+	var l slog.Level
+	if err := l.UnmarshalText([]byte(strings.ToUpper(name))); err != nil {
+		return l, fmt.Errorf("invalid log level %q: use debug, info, warn or error", name)
+	}
+	return l, nil
+}
+
+// Level returns the current log level
+func Level() slog.Level {
+	return level.Level()
+}
+
+// SetJSON switches the output of every logger between JSON and text
+func SetJSON(json bool) {
+	useJSON.Store(json)
+}
+
+// JSON reports whether logs are written as JSON
+func JSON() bool {
+	return useJSON.Load()
+}
+
+// SetSink sends a JSON line for every record at or above min to w, on top of the regular output,
+// whatever the log level. Each record is one Write call; w must not block or log. A nil w stops
+// the copies.
+func SetSink(w io.Writer, min slog.Level) {
+	if w == nil {
+		sink.Store(nil)
+		return
+	}
+	sink.Store(&sinkTarget{w: w, min: min})
+}
+
+// sinkWriter forwards to the current sink, dropping writes while there is none
+type sinkWriter struct{}
+
+func (sinkWriter) Write(p []byte) (int, error) {
+	if t := sink.Load(); t != nil {
+		return t.w.Write(p)
+	}
+	return len(p), nil
+}
+
+// switchHandler writes each record with the JSON or text handler depending on useJSON. Both
+// handlers carry the same attributes and groups, so switching keeps logger.With context. ship,
+// when set, copies records to the sink.
+type switchHandler struct {
+	json slog.Handler
+	text slog.Handler
+	ship slog.Handler
+}
+
+// shipping reports whether records at level l go to the sink
+func (h *switchHandler) shipping(l slog.Level) bool {
+	t := sink.Load()
+	return h.ship != nil && t != nil && l >= t.min
+}
+
+func (h *switchHandler) current() slog.Handler {
+	if useJSON.Load() {
+		return h.json
+	}
+	return h.text
+}
+
+func (h *switchHandler) Enabled(ctx context.Context, l slog.Level) bool {
+	return h.current().Enabled(ctx, l) || h.shipping(l)
+}
+
+func (h *switchHandler) Handle(ctx context.Context, r slog.Record) error {
+	var err error
+	if current := h.current(); current.Enabled(ctx, r.Level) {
+		err = current.Handle(ctx, r)
+	}
+	if h.shipping(r.Level) {
+		// A failed copy must never fail the regular output
+		_ = h.ship.Handle(ctx, r)
+	}
+	return err
+}
+
+func (h *switchHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	next := &switchHandler{json: h.json.WithAttrs(attrs), text: h.text.WithAttrs(attrs)}
+	if h.ship != nil {
+		next.ship = h.ship.WithAttrs(attrs)
+	}
+	return next
+}
+
+func (h *switchHandler) WithGroup(name string) slog.Handler {
+	next := &switchHandler{json: h.json.WithGroup(name), text: h.text.WithGroup(name)}
+	if h.ship != nil {
+		next.ship = h.ship.WithGroup(name)
+	}
+	return next
+}
+
+func defaultLevel(environment string) slog.Level {
+	if environment == "development" {
+		return slog.LevelDebug
+	}
+	return slog.LevelInfo
+}
@@ -0,0 +1,88 @@
+package logger
+
+import (
+	"bytes"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestSwitchHandler(t *testing.T) {
+	defer SetJSON(false)
+	defer level.Set(slog.LevelInfo)
+
+	var out bytes.Buffer
+	opts := &slog.HandlerOptions{Level: level}
+	log := slog.New(&switchHandler{
+		json: slog.NewJSONHandler(&out, opts),
+		text: slog.NewTextHandler(&out, opts),
+	}).With("app", "web")
+
+	SetJSON(false)
+	log.Info("text line")
+	SetJSON(true)
+	log.Info("json line")
+	log.Debug("hidden")
+	if err := ApplyLevel("production", "debug"); err != nil {
+		t.Fatalf("ApplyLevel: %v", err)
+	}
+	log.Debug("shown")
+
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("expected 3 lines, got %q", lines)
+	}
+	if !strings.Contains(lines[0], "msg=\"text line\" app=web") {
+		t.Errorf("expected a text line with the logger's attrs, got %q", lines[0])
+	}
+	if !strings.Contains(lines[1], `"msg":"json line","app":"web"`) {
+		t.Errorf("expected a JSON line with the logger's attrs, got %q", lines[1])
+	}
+	if !strings.Contains(lines[2], `"level":"DEBUG"`) {
+		t.Errorf("expected the debug line after raising the level, got %q", lines[2])
+	}
+
+	if err := ApplyLevel("production", "verbose"); err == nil {
+		t.Error("expected an unknown level to be rejected")
+	}
+	if err := ApplyLevel("development", ""); err != nil || Level() != slog.LevelDebug {
+		t.Errorf("expected an empty level to restore the development default, got %v (err %v)", Level(), err)
+	}
+}
+
+func TestSetSink(t *testing.T) {
+	defer SetSink(nil, 0)
+	defer level.Set(slog.LevelInfo)
+	level.Set(slog.LevelError)
+
+	var out, shipped bytes.Buffer
+	opts := &slog.HandlerOptions{Level: level}
+	log := slog.New(&switchHandler{
+		json: slog.NewJSONHandler(&out, opts),
+		text: slog.NewTextHandler(&out, opts),
+		ship: slog.NewJSONHandler(sinkWriter{}, &slog.HandlerOptions{Level: slog.LevelDebug}),
+	}).With("app", "web")
+
+	log.Warn("before sink")
+	SetSink(&shipped, slog.LevelWarn)
+	log.Info("below sink level")
+	log.Warn("shipped warning")
+	log.Error("shipped error")
+
+	if out.Len() == 0 || strings.Contains(out.String(), "warning") {
+		t.Errorf("expected stdout to keep its own level, got %q", out.String())
+	}
+	lines := strings.Split(strings.TrimSpace(shipped.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 shipped lines, got %q", lines)
+	}
+	if !strings.Contains(lines[0], `"msg":"shipped warning","app":"web"`) {
+		t.Errorf("expected a JSON copy with the logger's attrs, got %q", lines[0])
+	}
+
+	SetSink(nil, 0)
+	log.Error("after sink")
+	if strings.Contains(shipped.String(), "after sink") {
+		t.Error("expected nothing to be shipped once the sink is removed")
+	}
+}
@@ -0,0 +1,196 @@
+// Package logship ships selfhostly's own server logs off the node, to the primary or to a Loki
+// endpoint, so a cluster can be troubleshot without reading each node's journal. A Shipper is the
+// logger's sink: it buffers JSON lines in memory and sends them in batches, dropping the oldest
+// lines rather than blocking the logger when the destination is unreachable.
+package logship
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/selfhostly/internal/constants"
+	"github.com/selfhostly/internal/domain"
+)
+
+// Destination receives batches of log lines
+type Destination interface {
+	Send(ctx context.Context, entries []domain.NodeLogEntry) error
+}
+
+// DestinationFunc adapts a function to a Destination
+type DestinationFunc func(ctx context.Context, entries []domain.NodeLogEntry) error
+
+// Send calls f
+func (f DestinationFunc) Send(ctx context.Context, entries []domain.NodeLogEntry) error {
+	return f(ctx, entries)
+}
+
+// Shipper buffers log lines written to it and ships them to a destination
+type Shipper struct {
+	dest      Destination
+	logger    *slog.Logger
+	interval  time.Duration
+	bufferCap int
+	batchSize int
+
+	mu      sync.Mutex
+	pending []domain.NodeLogEntry
+	dropped int
+	failing bool
+	wake    chan struct{}
+}
+
+// New creates a Shipper for dest. logger reports shipping failures; its records are shipped too
+// once the destination is back.
+func New(dest Destination, logger *slog.Logger) *Shipper {
+	return &Shipper{
+		dest:      dest,
+		logger:    logger,
+		interval:  constants.LogShippingInterval,
+		bufferCap: constants.LogShippingBufferSize,
+		batchSize: constants.LogShippingBatchSize,
+		wake:      make(chan struct{}, 1),
+	}
+}
+
+// Write queues one JSON log record. It never blocks on the destination and never fails, so it
+// can't hold up or break the logger.
+func (s *Shipper) Write(p []byte) (int, error) {
+	line := bytes.TrimSpace(p)
+	if len(line) == 0 {
+		return len(p), nil
+	}
+
+	var record struct {
+		Time  time.Time `json:"time"`
+		Level string    `json:"level"`
+		Msg   string    `json:"msg"`
+	}
+	_ = json.Unmarshal(line, &record)
+	if record.Time.IsZero() {
+		record.Time = time.Now()
+	}
+
+	s.mu.Lock()
+	s.pending = append(s.pending, domain.NodeLogEntry{
+		Time:    record.Time,
+		Level:   record.Level,
+		Message: record.Msg,
+		Line:    string(line),
+	})
+	if over := len(s.pending) - s.bufferCap; over > 0 {
+		s.pending = s.pending[over:]
+		s.dropped += over
+	}
+	full := len(s.pending) >= s.batchSize
+	s.mu.Unlock()
+
+	if full {
+		select {
+		case s.wake <- struct{}{}:
+		default:
+		}
+	}
+	return len(p), nil
+}
+
+// Run ships buffered lines every interval, or sooner once a batch is full, until ctx is done. It
+// then makes one last attempt to ship what is left.
+func (s *Shipper) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			flushCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			s.Flush(flushCtx)
+			cancel()
+			return
+		case <-ticker.C:
+		case <-s.wake:
+		}
+		s.Flush(ctx)
+	}
+}
+
+// Flush ships everything buffered, a batch at a time. A batch that fails goes back to the front
+// of the buffer to be retried on the next flush.
+func (s *Shipper) Flush(ctx context.Context) {
+	for {
+		batch := s.take()
+		if len(batch) == 0 {
+			return
+		}
+
+		if err := s.dest.Send(ctx, batch); err != nil {
+			s.requeue(batch)
+			s.mu.Lock()
+			first := !s.failing
+			s.failing = true
+			s.mu.Unlock()
+			// Only the first failure is logged; every record logged here is itself shipped later
+			if first {
+				s.logger.WarnContext(ctx, "failed to ship server logs, buffering until the destination is back", "error", err)
+			}
+			return
+		}
+
+		s.mu.Lock()
+		recovered := s.failing
+		s.failing = false
+		s.mu.Unlock()
+		if recovered {
+			s.logger.InfoContext(ctx, "server log shipping resumed")
+		}
+	}
+}
+
+// take removes the next batch from the buffer. Lines dropped since the last batch are reported by
+// a warning line of their own at its front.
+func (s *Shipper) take() []domain.NodeLogEntry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	n := min(len(s.pending), s.batchSize)
+	if n == 0 && s.dropped == 0 {
+		return nil
+	}
+
+	batch := make([]domain.NodeLogEntry, 0, n+1)
+	if s.dropped > 0 {
+		batch = append(batch, droppedEntry(s.dropped))
+		s.dropped = 0
+		if n == s.batchSize {
+			n--
+		}
+	}
+	batch = append(batch, s.pending[:n]...)
+	s.pending = s.pending[n:]
+	return batch
+}
+
+// requeue puts a batch that failed back in front of the buffer, still within its capacity
+func (s *Shipper) requeue(batch []domain.NodeLogEntry) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.pending = append(batch, s.pending...)
+	if over := len(s.pending) - s.bufferCap; over > 0 {
+		s.pending = s.pending[over:]
+		s.dropped += over
+	}
+}
+
+// droppedEntry is the line standing in for n lines the buffer had no room for
+func droppedEntry(n int) domain.NodeLogEntry {
+	now := time.Now()
+	msg := fmt.Sprintf("dropped %d server log lines while shipping was behind", n)
+	line, _ := json.Marshal(map[string]any{"time": now, "level": slog.LevelWarn.String(), "msg": msg, "dropped": n})
+	return domain.NodeLogEntry{Time: now, Level: slog.LevelWarn.String(), Message: msg, Line: string(line)}
+}
@@ -0,0 +1,104 @@
+package logship
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/selfhostly/internal/domain"
+)
+
+type recordingDestination struct {
+	fail    bool
+	batches [][]domain.NodeLogEntry
+}
+
+func (d *recordingDestination) Send(_ context.Context, entries []domain.NodeLogEntry) error {
+	if d.fail {
+		return errors.New("unreachable")
+	}
+	d.batches = append(d.batches, entries)
+	return nil
+}
+
+func TestShipper_BuffersAndRetries(t *testing.T) {
+	dest := &recordingDestination{fail: true}
+	s := New(dest, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	s.bufferCap = 3
+	ctx := context.Background()
+
+	for _, msg := range []string{"one", "two", "three", "four"} {
+		s.Write([]byte(`{"time":"2026-01-02T03:04:05Z","level":"INFO","msg":"` + msg + `","app":"web"}` + "\n"))
+	}
+	s.Flush(ctx)
+	if len(dest.batches) != 0 {
+		t.Fatalf("expected nothing shipped while the destination fails")
+	}
+
+	dest.fail = false
+	s.Flush(ctx)
+	if len(dest.batches) != 1 {
+		t.Fatalf("expected one batch, got %d", len(dest.batches))
+	}
+	batch := dest.batches[0]
+	if len(batch) != 4 || batch[0].Level != "WARN" || !strings.Contains(batch[0].Message, "dropped 1") {
+		t.Fatalf("expected a dropped-lines warning and the 3 newest lines, got %+v", batch)
+	}
+	if batch[1].Message != "two" || batch[3].Message != "four" || !strings.Contains(batch[1].Line, `"app":"web"`) {
+		t.Errorf("unexpected lines %+v", batch[1:])
+	}
+	if !batch[1].Time.Equal(time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)) {
+		t.Errorf("expected the record's own time, got %v", batch[1].Time)
+	}
+
+	s.Flush(ctx)
+	if len(dest.batches) != 1 {
+		t.Errorf("expected an empty buffer to ship nothing")
+	}
+}
+
+func TestLoki_Send(t *testing.T) {
+	var got struct {
+		Streams []lokiStream `json:"streams"`
+	}
+	var path string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		json.NewDecoder(r.Body).Decode(&got)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	loki := NewLoki(srv.URL+"/", map[string]string{"job": "selfhostly", "node": "edge"})
+	at := time.Unix(1700000000, 0)
+	err := loki.Send(context.Background(), []domain.NodeLogEntry{
+		{Time: at, Level: "INFO", Line: `{"msg":"a"}`},
+		{Time: at, Level: "ERROR", Line: `{"msg":"b"}`},
+		{Time: at, Level: "INFO", Line: `{"msg":"c"}`},
+	})
+	if err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	if path != lokiPushPath {
+		t.Errorf("expected a push to %s, got %s", lokiPushPath, path)
+	}
+	if len(got.Streams) != 2 || got.Streams[0].Stream["level"] != "info" || got.Streams[0].Stream["node"] != "edge" ||
+		len(got.Streams[0].Values) != 2 || got.Streams[0].Values[0][0] != "1700000000000000000" {
+		t.Errorf("unexpected streams %+v", got.Streams)
+	}
+
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "bad labels", http.StatusBadRequest)
+	}))
+	defer failing.Close()
+	if err := NewLoki(failing.URL, nil).Send(context.Background(), []domain.NodeLogEntry{{Time: at, Level: "INFO"}}); err == nil || !strings.Contains(err.Error(), "400") {
+		t.Errorf("expected the status to be reported, got %v", err)
+	}
+}
@@ -0,0 +1,89 @@
+package logship
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/selfhostly/internal/domain"
+)
+
+// lokiPushPath is Loki's push API, appended to the configured URL unless it already ends with it
+const lokiPushPath = "/loki/api/v1/push"
+
+// Loki pushes log lines to a Loki endpoint, one stream per level
+type Loki struct {
+	url        string
+	labels     map[string]string
+	httpClient *http.Client
+}
+
+// NewLoki creates a Loki destination. baseURL is the Loki server (or the full push URL); labels
+// are added to every stream, alongside a level label.
+func NewLoki(baseURL string, labels map[string]string) *Loki {
+	url := strings.TrimRight(baseURL, "/")
+	if !strings.HasSuffix(url, lokiPushPath) {
+		url += lokiPushPath
+	}
+	return &Loki{
+		url:        url,
+		labels:     labels,
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+type lokiStream struct {
+	Stream map[string]string `json:"stream"`
+	Values [][2]string       `json:"values"`
+}
+
+// Send pushes a batch to Loki
+func (l *Loki) Send(ctx context.Context, entries []domain.NodeLogEntry) error {
+	streams := []*lokiStream{}
+	byLevel := map[string]*lokiStream{}
+	for _, e := range entries {
+		level := strings.ToLower(e.Level)
+		stream, ok := byLevel[level]
+		if !ok {
+			labels := maps.Clone(l.labels)
+			if labels == nil {
+				labels = map[string]string{}
+			}
+			labels["level"] = level
+			stream = &lokiStream{Stream: labels}
+			byLevel[level] = stream
+			streams = append(streams, stream)
+		}
+		stream.Values = append(stream.Values, [2]string{strconv.FormatInt(e.Time.UnixNano(), 10), e.Line})
+	}
+
+	payload, err := json.Marshal(map[string]any{"streams": streams})
+	if err != nil {
+		return fmt.Errorf("failed to marshal logs: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, l.url, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := l.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to push logs to loki: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("loki returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
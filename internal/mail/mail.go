@@ -0,0 +1,168 @@
+// Package mail sends plain-text email through the SMTP server configured in settings. It is the
+// building block for email notifications and user invitations.
+package mail
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"mime"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/selfhostly/internal/constants"
+	"github.com/selfhostly/internal/db"
+)
+
+// dialTimeout bounds connecting to the SMTP server when the context has no deadline
+const dialTimeout = 30 * time.Second
+
+// Config is how to reach the SMTP server
+type Config struct {
+	Host     string
+	Port     int
+	Security string // starttls, tls (implicit, usually port 465) or none
+	Username string // No authentication when empty
+	Password string
+	From     string // Sender address, optionally with a name: "Selfhostly <noreply@example.com>"
+}
+
+// Message is a plain-text email
+type Message struct {
+	To      []string
+	Subject string
+	Body    string
+}
+
+// ConfigFromSettings returns the SMTP server configured in settings
+func ConfigFromSettings(settings *db.Settings) Config {
+	return Config{
+		Host:     settings.SMTPHost,
+		Port:     settings.SMTPPort,
+		Security: settings.SMTPSecurity,
+		Username: settings.SMTPUsername,
+		Password: settings.SMTPPassword,
+		From:     settings.SMTPFrom,
+	}
+}
+
+// Configured reports whether enough is set to send mail
+func (c Config) Configured() bool {
+	return c.Host != "" && c.From != ""
+}
+
+// Validate checks the configuration without connecting
+func (c Config) Validate() error {
+	if c.Host == "" {
+		return fmt.Errorf("host is required")
+	}
+	if c.Port < 1 || c.Port > 65535 {
+		return fmt.Errorf("port must be between 1 and 65535")
+	}
+	switch c.Security {
+	case constants.SMTPSecurityStartTLS, constants.SMTPSecurityTLS, constants.SMTPSecurityNone:
+	default:
+		return fmt.Errorf("security must be %s, %s or %s", constants.SMTPSecurityStartTLS, constants.SMTPSecurityTLS, constants.SMTPSecurityNone)
+	}
+	if _, err := mail.ParseAddress(c.From); err != nil {
+		return fmt.Errorf("invalid from address: %w", err)
+	}
+	return nil
+}
+
+// Send delivers msg through the SMTP server
+func Send(ctx context.Context, cfg Config, msg Message) error {
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+	from, _ := mail.ParseAddress(cfg.From)
+	if len(msg.To) == 0 {
+		return fmt.Errorf("no recipients")
+	}
+	recipients := make([]*mail.Address, len(msg.To))
+	for i, to := range msg.To {
+		addr, err := mail.ParseAddress(to)
+		if err != nil {
+			return fmt.Errorf("invalid recipient %q: %w", to, err)
+		}
+		recipients[i] = addr
+	}
+
+	addr := net.JoinHostPort(cfg.Host, strconv.Itoa(cfg.Port))
+	dialer := &net.Dialer{Timeout: dialTimeout}
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to connect to %s: %w", addr, err)
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	} else {
+		conn.SetDeadline(time.Now().Add(dialTimeout))
+	}
+	tlsConfig := &tls.Config{ServerName: cfg.Host}
+	if cfg.Security == constants.SMTPSecurityTLS {
+		conn = tls.Client(conn, tlsConfig)
+	}
+
+	client, err := smtp.NewClient(conn, cfg.Host)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("failed to start SMTP session: %w", err)
+	}
+	defer client.Close()
+
+	if cfg.Security == constants.SMTPSecurityStartTLS {
+		if err := client.StartTLS(tlsConfig); err != nil {
+			return fmt.Errorf("STARTTLS failed: %w", err)
+		}
+	}
+	if cfg.Username != "" {
+		if err := client.Auth(smtp.PlainAuth("", cfg.Username, cfg.Password, cfg.Host)); err != nil {
+			return fmt.Errorf("authentication failed: %w", err)
+		}
+	}
+
+	if err := client.Mail(from.Address); err != nil {
+		return fmt.Errorf("sender refused: %w", err)
+	}
+	for _, to := range recipients {
+		if err := client.Rcpt(to.Address); err != nil {
+			return fmt.Errorf("recipient %s refused: %w", to.Address, err)
+		}
+	}
+	w, err := client.Data()
+	if err != nil {
+		return fmt.Errorf("failed to send message: %w", err)
+	}
+	if _, err := w.Write(compose(from, recipients, msg)); err != nil {
+		return fmt.Errorf("failed to send message: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("message refused: %w", err)
+	}
+	return client.Quit()
+}
+
+// compose formats msg with the headers mail clients expect
+func compose(from *mail.Address, to []*mail.Address, msg Message) []byte {
+	recipients := make([]string, len(to))
+	for i, addr := range to {
+		recipients[i] = addr.String()
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", from)
+	fmt.Fprintf(&b, "To: %s\r\n", strings.Join(recipients, ", "))
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", msg.Subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(strings.ReplaceAll(msg.Body, "\r\n", "\n"), "\n", "\r\n"))
+	b.WriteString("\r\n")
+	return []byte(b.String())
+}
@@ -0,0 +1,105 @@
+package mail
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/selfhostly/internal/constants"
+)
+
+// fakeSMTPServer accepts one message and sends what it received down the returned channel
+func fakeSMTPServer(t *testing.T) (int, <-chan string) {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	received := make(chan string, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		reply := func(line string) { conn.Write([]byte(line + "\r\n")) }
+
+		var transcript strings.Builder
+		reply("220 fake ESMTP")
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			transcript.WriteString(line)
+			switch cmd := strings.ToUpper(strings.TrimSpace(line)); {
+			case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+				reply("250 fake")
+			case strings.HasPrefix(cmd, "DATA"):
+				reply("354 go ahead")
+				for {
+					data, err := r.ReadString('\n')
+					if err != nil {
+						return
+					}
+					if data == ".\r\n" {
+						break
+					}
+					transcript.WriteString(data)
+				}
+				reply("250 queued")
+			case strings.HasPrefix(cmd, "QUIT"):
+				reply("221 bye")
+				received <- transcript.String()
+				return
+			default:
+				reply("250 ok")
+			}
+		}
+	}()
+	return listener.Addr().(*net.TCPAddr).Port, received
+}
+
+func TestSend(t *testing.T) {
+	port, received := fakeSMTPServer(t)
+	cfg := Config{Host: "127.0.0.1", Port: port, Security: constants.SMTPSecurityNone, From: "Selfhostly <noreply@example.com>"}
+
+	err := Send(context.Background(), cfg, Message{To: []string{"admin@example.com"}, Subject: "Test", Body: "Hello\nthere"})
+	if err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	transcript := <-received
+	for _, want := range []string{"MAIL FROM:<noreply@example.com>", "RCPT TO:<admin@example.com>", "Subject: Test\r\n", "Hello\r\nthere"} {
+		if !strings.Contains(transcript, want) {
+			t.Errorf("Expected %q in the SMTP session, got:\n%s", want, transcript)
+		}
+	}
+}
+
+func TestConfig_Validate(t *testing.T) {
+	valid := Config{Host: "smtp.example.com", Port: 587, Security: constants.SMTPSecurityStartTLS, From: "noreply@example.com"}
+	if err := valid.Validate(); err != nil {
+		t.Errorf("Expected a valid config, got %v", err)
+	}
+
+	for name, cfg := range map[string]Config{
+		"no host":      {Port: 587, Security: constants.SMTPSecurityStartTLS, From: "noreply@example.com"},
+		"bad port":     {Host: "smtp.example.com", Security: constants.SMTPSecurityStartTLS, From: "noreply@example.com"},
+		"bad security": {Host: "smtp.example.com", Port: 587, Security: "ssl", From: "noreply@example.com"},
+		"bad from":     {Host: "smtp.example.com", Port: 587, Security: constants.SMTPSecurityStartTLS, From: "noreply"},
+	} {
+		if err := cfg.Validate(); err == nil {
+			t.Errorf("%s: expected the config to be refused", name)
+		}
+	}
+
+	if err := Send(context.Background(), valid, Message{To: []string{"not an address"}}); err == nil {
+		t.Error("Expected an invalid recipient to be refused")
+	}
+}
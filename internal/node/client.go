@@ -2,38 +2,122 @@ package node
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
-	"time"
+	"strconv"
+	"strings"
+	"sync/atomic"
 
 	"github.com/selfhostly/internal/apipaths"
 	"github.com/selfhostly/internal/db"
 	"github.com/selfhostly/internal/domain"
+	"github.com/selfhostly/internal/reqsign"
+	"github.com/selfhostly/internal/timeouts"
 )
 
+// keylessAuth leaves the API key out of signed node requests; see SetKeylessAuth
+var keylessAuth atomic.Bool
+
+// SetKeylessAuth makes node requests authenticate with their signature alone, leaving the API
+// key out so it is never on the wire. Only enable it once every receiving node checks
+// signatures (REQUIRE_SIGNED_REQUESTS); older nodes need the key.
+func SetKeylessAuth(enabled bool) {
+	keylessAuth.Store(enabled)
+}
+
+// SetAuthHeaders authenticates req as node nodeID: it is signed with a key derived from apiKey,
+// and carries apiKey itself unless keyless auth is on. A request that couldn't be signed always
+// carries the key.
+func SetAuthHeaders(req *http.Request, nodeID, apiKey string) {
+	req.Header.Set("X-Node-ID", nodeID)
+	if err := reqsign.Sign(req, apiKey); err != nil || !keylessAuth.Load() {
+		req.Header.Set("X-Node-API-Key", apiKey)
+	}
+}
+
+// sharedCircuitBreaker is used by every Client so that a failing node fails fast for all
+// services at once, and so its circuit state can be reported in one place
+var sharedCircuitBreaker = NewCircuitBreaker()
+
+// CircuitStatsFor returns the circuit breaker state for a node
+func CircuitStatsFor(nodeID string) CircuitStats {
+	return sharedCircuitBreaker.GetStats(nodeID)
+}
+
 // Client handles communication with other nodes
 type Client struct {
 	httpClient     *http.Client
 	circuitBreaker *CircuitBreaker
+	timeouts       *timeouts.Live
+	user           domain.ActingUser // Sent with every request when set, see ForUser
 }
 
-// NewClient creates a new inter-node API client
+// NewClient creates a new inter-node API client with the default operation timeouts
 func NewClient() *Client {
+	return NewClientWithTimeouts(timeouts.NewLive(timeouts.Default()))
+}
+
+// NewClientWithTimeouts creates a new inter-node API client. Each request is bounded by the
+// timeout for its operation class rather than one client-wide timeout, read from t when the
+// request is sent so reloaded timeouts apply to existing clients.
+func NewClientWithTimeouts(t *timeouts.Live) *Client {
 	return &Client{
-		httpClient: &http.Client{
-			Timeout: 90 * time.Second,
-		},
-		circuitBreaker: NewCircuitBreaker(),
+		httpClient:     &http.Client{},
+		circuitBreaker: sharedCircuitBreaker,
+		timeouts:       t,
+	}
+}
+
+// ForUser returns a client whose requests act for user, so the receiving node attributes changes
+// to the user and applies the user's role. A zero user returns c itself.
+func (c *Client) ForUser(user domain.ActingUser) *Client {
+	if user.Name == "" {
+		return c
 	}
+	scoped := *c
+	scoped.user = user
+	return &scoped
+}
+
+// do sends req with a deadline for its operation class. The deadline covers reading the body,
+// so it is released when the caller closes resp.Body.
+func (c *Client) do(req *http.Request) (*http.Response, error) {
+	ctx, cancel := context.WithTimeout(req.Context(), c.timeouts.Load().ForRequest(req.Method, req.URL.Path))
+	resp, err := c.httpClient.Do(req.WithContext(ctx))
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	resp.Body = &cancelOnClose{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
+
+// cancelOnClose releases a request's context once its response body is closed
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
 }
 
-// setNodeAuthHeaders sets the required authentication headers for inter-node requests
+func (b *cancelOnClose) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
+
+// setNodeAuthHeaders sets the required authentication headers for inter-node requests and signs
+// the request with the node's API key. Call it once the request's body is set.
 func (c *Client) setNodeAuthHeaders(req *http.Request, node *db.Node) {
-	req.Header.Set("X-Node-ID", node.ID)
-	req.Header.Set("X-Node-API-Key", node.APIKey)
+	if c.user.Name != "" {
+		req.Header.Set(reqsign.HeaderUser, c.user.Name)
+		req.Header.Set(reqsign.HeaderUserRole, c.user.Role)
+	}
+	// Signing only fails if the body can't be read; the unsigned request is then refused by nodes
+	// that require signatures and accepted by the others
+	SetAuthHeaders(req, node.ID, node.APIKey)
 }
 
 // GetApps fetches all apps from a remote node
@@ -53,7 +137,7 @@ func (c *Client) GetApps(node *db.Node) ([]*db.App, error) {
 	// Add node authentication
 	c.setNodeAuthHeaders(req, node)
 
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
 		c.circuitBreaker.RecordFailure(node.ID)
 		return nil, fmt.Errorf("failed to fetch apps from node %s: %w", node.Name, err)
@@ -91,7 +175,7 @@ func (c *Client) GetApp(node *db.Node, appID string) (*db.App, error) {
 
 	c.setNodeAuthHeaders(req, node)
 
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch app from node %s: %w", node.Name, err)
 	}
@@ -125,7 +209,7 @@ func (c *Client) CreateApp(node *db.Node, reqData interface{}) (*db.App, error)
 	c.setNodeAuthHeaders(req, node)
 	req.Header.Set("Content-Type", "application/json")
 
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create app on node %s: %w", node.Name, err)
 	}
@@ -169,7 +253,7 @@ func (c *Client) UpdateApp(node *db.Node, appID string, reqData interface{}) (*d
 	c.setNodeAuthHeaders(req, node)
 	req.Header.Set("Content-Type", "application/json")
 
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to update app on node %s: %w", node.Name, err)
 	}
@@ -190,14 +274,40 @@ func (c *Client) UpdateApp(node *db.Node, appID string, reqData interface{}) (*d
 
 // DeleteApp deletes an app from a remote node
 func (c *Client) DeleteApp(node *db.Node, appID string) error {
-	req, err := http.NewRequest("DELETE", node.APIEndpoint+apipaths.AppByID(appID), nil)
+	return c.DeleteAppWithOptions(node, appID, domain.DeleteAppOptions{})
+}
+
+// DeleteAppWithOptions deletes an app from a remote node, passing opts as the delete query parameters
+func (c *Client) DeleteAppWithOptions(node *db.Node, appID string, opts domain.DeleteAppOptions) error {
+	query := url.Values{}
+	if opts.Archive {
+		query.Set("archive", "true")
+	}
+	if opts.DryRun {
+		query.Set("dry_run", "true")
+	}
+	if len(opts.SkipSteps) > 0 {
+		query.Set("skip", strings.Join(opts.SkipSteps, ","))
+	}
+	if opts.RemoveVolumes {
+		query.Set("remove_volumes", "true")
+	}
+	if opts.Force {
+		query.Set("force", "true")
+	}
+	endpoint := node.APIEndpoint + apipaths.AppByID(appID)
+	if len(query) > 0 {
+		endpoint += "?" + query.Encode()
+	}
+
+	req, err := http.NewRequest("DELETE", endpoint, nil)
 	if err != nil {
 		return fmt.Errorf("failed to create request: %w", err)
 	}
 
 	c.setNodeAuthHeaders(req, node)
 
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
 		return fmt.Errorf("failed to delete app on node %s: %w", node.Name, err)
 	}
@@ -229,7 +339,7 @@ func (c *Client) appAction(node *db.Node, appID, action string) error {
 
 	c.setNodeAuthHeaders(req, node)
 
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
 		return fmt.Errorf("failed to %s app on node %s: %w", action, node.Name, err)
 	}
@@ -243,263 +353,415 @@ func (c *Client) appAction(node *db.Node, appID, action string) error {
 	return nil
 }
 
-// UpdateAppContainers triggers a container update on a remote node
-func (c *Client) UpdateAppContainers(node *db.Node, appID string) (*db.App, error) {
-	req, err := http.NewRequest("POST", node.APIEndpoint+apipaths.AppUpdateContainers(appID), nil)
+// GetQuickTunnelURL fetches the Quick Tunnel URL for an app from a remote node.
+// The node runs extraction locally and returns the trycloudflare.com URL.
+func (c *Client) GetQuickTunnelURL(node *db.Node, appID string) (string, error) {
+	req, err := http.NewRequest("GET", node.APIEndpoint+apipaths.AppQuickTunnelURL(appID), nil)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
+		return "", fmt.Errorf("failed to create request: %w", err)
 	}
 
 	c.setNodeAuthHeaders(req, node)
 
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
-		return nil, fmt.Errorf("failed to update app containers on node %s: %w", node.Name, err)
+		return "", fmt.Errorf("failed to get quick tunnel URL from node %s: %w", node.Name, err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("node returned status %d: %s", resp.StatusCode, string(body))
+		return "", fmt.Errorf("node returned status %d: %s", resp.StatusCode, string(body))
 	}
 
-	var app db.App
-	if err := json.NewDecoder(resp.Body).Decode(&app); err != nil {
-		return nil, fmt.Errorf("failed to decode response: %w", err)
+	var out struct {
+		URL string `json:"url"`
 	}
-
-	return &app, nil
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return "", fmt.Errorf("failed to decode response: %w", err)
+	}
+	return out.URL, nil
 }
 
-// GetQuickTunnelURL fetches the Quick Tunnel URL for an app from a remote node.
-// The node runs extraction locally and returns the trycloudflare.com URL.
-func (c *Client) GetQuickTunnelURL(node *db.Node, appID string) (string, error) {
-	req, err := http.NewRequest("GET", node.APIEndpoint+apipaths.AppQuickTunnelURL(appID), nil)
+// GetSystemStats fetches system statistics from a remote node
+func (c *Client) GetSystemStats(node *db.Node) (map[string]interface{}, error) {
+	// Check circuit breaker
+	if c.circuitBreaker.IsOpen(node.ID) {
+		stats := c.circuitBreaker.GetStats(node.ID)
+		return nil, &CircuitOpenError{NodeID: node.ID, Stats: stats}
+	}
+
+	req, err := http.NewRequest("GET", node.APIEndpoint+apipaths.SystemStats, nil)
 	if err != nil {
-		return "", fmt.Errorf("failed to create request: %w", err)
+		c.circuitBreaker.RecordFailure(node.ID)
+		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
 	c.setNodeAuthHeaders(req, node)
 
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
-		return "", fmt.Errorf("failed to get quick tunnel URL from node %s: %w", node.Name, err)
+		c.circuitBreaker.RecordFailure(node.ID)
+		return nil, fmt.Errorf("failed to fetch stats from node %s: %w", node.Name, err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
+		c.circuitBreaker.RecordFailure(node.ID)
 		body, _ := io.ReadAll(resp.Body)
-		return "", fmt.Errorf("node returned status %d: %s", resp.StatusCode, string(body))
+		return nil, fmt.Errorf("node returned status %d: %s", resp.StatusCode, string(body))
 	}
 
-	var out struct {
-		URL string `json:"url"`
+	var stats map[string]interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&stats); err != nil {
+		c.circuitBreaker.RecordFailure(node.ID)
+		return nil, fmt.Errorf("failed to decode response: %w", err)
 	}
-	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
-		return "", fmt.Errorf("failed to decode response: %w", err)
+
+	// Record success
+	c.circuitBreaker.RecordSuccess(node.ID)
+	return stats, nil
+}
+
+// ForwardRequest replays a stored API request on a remote node with node authentication.
+// It returns the node's status code and body; err is only set when the node could not be reached.
+func (c *Client) ForwardRequest(node *db.Node, method, path, rawQuery string, body []byte) (int, []byte, error) {
+	url := node.APIEndpoint + path
+	if rawQuery != "" {
+		url += "?" + rawQuery
 	}
-	return out.URL, nil
+
+	req, err := http.NewRequest(method, url, bytes.NewReader(body))
+	if err != nil {
+		return 0, nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	if len(body) > 0 {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	c.setNodeAuthHeaders(req, node)
+
+	resp, err := c.do(req)
+	if err != nil {
+		c.circuitBreaker.RecordFailure(node.ID)
+		return 0, nil, fmt.Errorf("failed to reach node %s: %w", node.Name, err)
+	}
+	defer resp.Body.Close()
+	c.circuitBreaker.RecordSuccess(node.ID)
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return resp.StatusCode, nil, fmt.Errorf("failed to read response: %w", err)
+	}
+	return resp.StatusCode, respBody, nil
 }
 
-// CreateQuickTunnelForApp adds a Quick Tunnel to an app that has no tunnel on a remote node.
-func (c *Client) CreateQuickTunnelForApp(node *db.Node, appID string, service string, port int) (*db.App, error) {
-	body := map[string]interface{}{"service": service, "port": port}
-	jsonData, err := json.Marshal(body)
+// HealthCheck performs a health check on a remote node and returns the versions it reports
+func (c *Client) HealthCheck(node *db.Node) (*domain.NodeVersion, error) {
+	// Check circuit breaker
+	if c.circuitBreaker.IsOpen(node.ID) {
+		stats := c.circuitBreaker.GetStats(node.ID)
+		return nil, &CircuitOpenError{NodeID: node.ID, Stats: stats}
+	}
+
+	req, err := http.NewRequest("GET", node.APIEndpoint+apipaths.Health, nil)
 	if err != nil {
-		return nil, fmt.Errorf("failed to marshal request: %w", err)
+		c.circuitBreaker.RecordFailure(node.ID)
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	c.setNodeAuthHeaders(req, node)
+
+	resp, err := c.do(req)
+	if err != nil {
+		c.circuitBreaker.RecordFailure(node.ID)
+		return nil, err
 	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		c.circuitBreaker.RecordFailure(node.ID)
+		return nil, fmt.Errorf("health check failed with status %d", resp.StatusCode)
+	}
+
+	// Record success
+	c.circuitBreaker.RecordSuccess(node.ID)
+
+	// Nodes that predate version reporting answer without versions, leaving them zero
+	var reported domain.NodeVersion
+	_ = json.NewDecoder(resp.Body).Decode(&reported)
+	return &reported, nil
+}
 
-	req, err := http.NewRequest("POST", node.APIEndpoint+apipaths.AppQuickTunnel(appID), bytes.NewBuffer(jsonData))
+// GetSettings fetches settings from the primary node (for secondary nodes)
+func (c *Client) GetSettings(node *db.Node) (*db.Settings, error) {
+	req, err := http.NewRequest("GET", node.APIEndpoint+apipaths.Settings, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 
 	c.setNodeAuthHeaders(req, node)
-	req.Header.Set("Content-Type", "application/json")
 
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create quick tunnel on node %s: %w", node.Name, err)
+		return nil, fmt.Errorf("failed to fetch settings from node %s: %w", node.Name, err)
 	}
 	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
-		bodyBytes, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("node returned status %d: %s", resp.StatusCode, string(bodyBytes))
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("node returned status %d: %s", resp.StatusCode, string(body))
 	}
 
-	var app db.App
-	if err := json.NewDecoder(resp.Body).Decode(&app); err != nil {
+	var settings db.Settings
+	if err := json.NewDecoder(resp.Body).Decode(&settings); err != nil {
 		return nil, fmt.Errorf("failed to decode response: %w", err)
 	}
-	app.NodeID = node.ID
-	return &app, nil
+
+	return &settings, nil
 }
 
-// SwitchAppToCustomTunnel forwards switch-to-custom to a remote node so that node processes the request (its DB, its Cloudflare config).
-func (c *Client) SwitchAppToCustomTunnel(node *db.Node, appID string, body interface{}) (*db.App, error) {
-	jsonData, err := json.Marshal(body)
+// ListNodes fetches the nodes a node knows about; API keys are never included
+func (c *Client) ListNodes(node *db.Node) ([]*db.Node, error) {
+	req, err := http.NewRequest("GET", node.APIEndpoint+apipaths.Nodes, nil)
 	if err != nil {
-		return nil, fmt.Errorf("failed to marshal request: %w", err)
+		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
-	req, err := http.NewRequest("POST", node.APIEndpoint+apipaths.TunnelSwitchToCustom(appID), bytes.NewBuffer(jsonData))
+
+	c.setNodeAuthHeaders(req, node)
+
+	resp, err := c.do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch nodes from node %s: %w", node.Name, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("node returned status %d: %s", resp.StatusCode, string(body))
+	}
+
+	var nodes []*db.Node
+	if err := json.NewDecoder(resp.Body).Decode(&nodes); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	return nodes, nil
+}
+
+// GetLogSettings fetches a remote node's log level and output format
+func (c *Client) GetLogSettings(node *db.Node) (*domain.LogSettings, error) {
+	req, err := http.NewRequest("GET", node.APIEndpoint+apipaths.LogLevel, nil)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
+
 	c.setNodeAuthHeaders(req, node)
-	req.Header.Set("Content-Type", "application/json")
 
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
-		return nil, fmt.Errorf("failed to switch to custom tunnel on node %s: %w", node.Name, err)
+		return nil, fmt.Errorf("failed to fetch log settings from node %s: %w", node.Name, err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		bodyBytes, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("node returned status %d: %s", resp.StatusCode, string(bodyBytes))
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("node returned status %d: %s", resp.StatusCode, string(body))
 	}
 
-	var app db.App
-	if err := json.NewDecoder(resp.Body).Decode(&app); err != nil {
+	var settings domain.LogSettings
+	if err := json.NewDecoder(resp.Body).Decode(&settings); err != nil {
 		return nil, fmt.Errorf("failed to decode response: %w", err)
 	}
-	app.NodeID = node.ID
-	return &app, nil
+	return &settings, nil
 }
 
-// CreateTunnelForApp forwards create-tunnel (custom domain) to a remote node so that node processes the request (its DB, its Cloudflare config).
-func (c *Client) CreateTunnelForApp(node *db.Node, appID string, body interface{}) (*db.App, error) {
-	jsonData, err := json.Marshal(body)
+// UpdateLogSettings changes a remote node's log level or output format
+func (c *Client) UpdateLogSettings(node *db.Node, reqData domain.UpdateLogSettingsRequest) (*domain.LogSettings, error) {
+	jsonData, err := json.Marshal(reqData)
 	if err != nil {
 		return nil, fmt.Errorf("failed to marshal request: %w", err)
 	}
-	url := node.APIEndpoint + apipaths.TunnelByApp(appID) + "?node_id=" + node.ID
-	req, err := http.NewRequest("POST", url, bytes.NewBuffer(jsonData))
+
+	req, err := http.NewRequest("PUT", node.APIEndpoint+apipaths.LogLevel, bytes.NewBuffer(jsonData))
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
-	c.setNodeAuthHeaders(req, node)
+
 	req.Header.Set("Content-Type", "application/json")
+	c.setNodeAuthHeaders(req, node)
 
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create tunnel for app on node %s: %w", node.Name, err)
+		return nil, fmt.Errorf("failed to update log settings on node %s: %w", node.Name, err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		bodyBytes, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("node returned status %d: %s", resp.StatusCode, string(bodyBytes))
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("node returned status %d: %s", resp.StatusCode, string(body))
 	}
 
-	var app db.App
-	if err := json.NewDecoder(resp.Body).Decode(&app); err != nil {
+	var settings domain.LogSettings
+	if err := json.NewDecoder(resp.Body).Decode(&settings); err != nil {
 		return nil, fmt.Errorf("failed to decode response: %w", err)
 	}
-	app.NodeID = node.ID
-	return &app, nil
+	return &settings, nil
 }
 
-// GetSystemStats fetches system statistics from a remote node
-func (c *Client) GetSystemStats(node *db.Node) (map[string]interface{}, error) {
-	// Check circuit breaker
-	if c.circuitBreaker.IsOpen(node.ID) {
-		stats := c.circuitBreaker.GetStats(node.ID)
-		return nil, &CircuitOpenError{NodeID: node.ID, Stats: stats}
+// PushAppInventory sends this node's app inventory to the primary (node is the primary, with this
+// node's own credentials)
+func (c *Client) PushAppInventory(node *db.Node, inventory domain.AppInventorySyncRequest) (*domain.AppInventorySyncResponse, error) {
+	payload, err := json.Marshal(inventory)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal inventory: %w", err)
 	}
 
-	req, err := http.NewRequest("GET", node.APIEndpoint+apipaths.SystemStats, nil)
+	req, err := http.NewRequest("POST", node.APIEndpoint+apipaths.NodeAppInventory(node.ID), bytes.NewReader(payload))
 	if err != nil {
-		c.circuitBreaker.RecordFailure(node.ID)
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
-
+	req.Header.Set("Content-Type", "application/json")
 	c.setNodeAuthHeaders(req, node)
 
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
-		c.circuitBreaker.RecordFailure(node.ID)
-		return nil, fmt.Errorf("failed to fetch stats from node %s: %w", node.Name, err)
+		return nil, fmt.Errorf("failed to push app inventory: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		c.circuitBreaker.RecordFailure(node.ID)
 		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("node returned status %d: %s", resp.StatusCode, string(body))
+		return nil, fmt.Errorf("primary returned status %d: %s", resp.StatusCode, string(body))
 	}
 
-	var stats map[string]interface{}
-	if err := json.NewDecoder(resp.Body).Decode(&stats); err != nil {
-		c.circuitBreaker.RecordFailure(node.ID)
+	var result domain.AppInventorySyncResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
 		return nil, fmt.Errorf("failed to decode response: %w", err)
 	}
 
-	// Record success
-	c.circuitBreaker.RecordSuccess(node.ID)
-	return stats, nil
+	return &result, nil
 }
 
-// HealthCheck performs a health check on a remote node
-func (c *Client) HealthCheck(node *db.Node) error {
-	// Check circuit breaker
-	if c.circuitBreaker.IsOpen(node.ID) {
-		stats := c.circuitBreaker.GetStats(node.ID)
-		return &CircuitOpenError{NodeID: node.ID, Stats: stats}
+// PushNodeLogs ships a batch of this node's server log lines to the primary
+func (c *Client) PushNodeLogs(node *db.Node, batch domain.NodeLogPushRequest) error {
+	payload, err := json.Marshal(batch)
+	if err != nil {
+		return fmt.Errorf("failed to marshal logs: %w", err)
 	}
 
-	req, err := http.NewRequest("GET", node.APIEndpoint+apipaths.Health, nil)
+	req, err := http.NewRequest("POST", node.APIEndpoint+apipaths.NodeServerLogs(node.ID), bytes.NewReader(payload))
 	if err != nil {
-		c.circuitBreaker.RecordFailure(node.ID)
 		return fmt.Errorf("failed to create request: %w", err)
 	}
-
+	req.Header.Set("Content-Type", "application/json")
 	c.setNodeAuthHeaders(req, node)
 
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
-		c.circuitBreaker.RecordFailure(node.ID)
-		return err
+		return fmt.Errorf("failed to push logs: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		c.circuitBreaker.RecordFailure(node.ID)
-		return fmt.Errorf("health check failed with status %d", resp.StatusCode)
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("primary returned status %d: %s", resp.StatusCode, string(body))
 	}
+	return nil
+}
 
-	// Record success
-	c.circuitBreaker.RecordSuccess(node.ID)
+// SyncOperationsLock sends the primary's operations lock state to a secondary
+func (c *Client) SyncOperationsLock(node *db.Node, status domain.OperationsLockStatus) error {
+	payload, err := json.Marshal(status)
+	if err != nil {
+		return fmt.Errorf("failed to marshal operations lock: %w", err)
+	}
+
+	req, err := http.NewRequest("PUT", node.APIEndpoint+apipaths.OpsLockSync, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	c.setNodeAuthHeaders(req, node)
+
+	resp, err := c.do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send operations lock to node %s: %w", node.Name, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("node returned status %d: %s", resp.StatusCode, string(body))
+	}
 	return nil
 }
 
-// GetSettings fetches settings from the primary node (for secondary nodes)
-func (c *Client) GetSettings(node *db.Node) (*db.Settings, error) {
-	req, err := http.NewRequest("GET", node.APIEndpoint+apipaths.Settings, nil)
+// CheckQuota asks the primary whether an app change fits its owner's quota
+func (c *Client) CheckQuota(node *db.Node, check domain.QuotaCheckRequest) (*domain.QuotaCheckResponse, error) {
+	payload, err := json.Marshal(check)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
+		return nil, fmt.Errorf("failed to marshal quota check: %w", err)
 	}
 
+	req, err := http.NewRequest("POST", node.APIEndpoint+apipaths.QuotaCheck, bytes.NewReader(payload))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
 	c.setNodeAuthHeaders(req, node)
 
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
-		return nil, fmt.Errorf("failed to fetch settings from node %s: %w", node.Name, err)
+		return nil, fmt.Errorf("failed to check quota: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("node returned status %d: %s", resp.StatusCode, string(body))
+		return nil, fmt.Errorf("primary returned status %d: %s", resp.StatusCode, string(body))
 	}
 
-	var settings db.Settings
-	if err := json.NewDecoder(resp.Body).Decode(&settings); err != nil {
+	var result domain.QuotaCheckResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
 		return nil, fmt.Errorf("failed to decode response: %w", err)
 	}
+	return &result, nil
+}
 
-	return &settings, nil
+// VerifyAPIToken asks the primary to check an API token presented to this node. Returns nil
+// without an error when the primary refused the token.
+func (c *Client) VerifyAPIToken(node *db.Node, token string) (*domain.VerifiedAPIToken, error) {
+	payload, err := json.Marshal(domain.VerifyAPITokenRequest{Token: token})
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal token: %w", err)
+	}
+
+	req, err := http.NewRequest("POST", node.APIEndpoint+apipaths.TokenVerify, bytes.NewReader(payload))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	c.setNodeAuthHeaders(req, node)
+
+	resp, err := c.do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to verify API token: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusUnauthorized {
+		return nil, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("primary returned status %d: %s", resp.StatusCode, string(body))
+	}
+
+	var result domain.VerifiedAPIToken
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+	return &result, nil
 }
 
 // GetTunnels fetches all tunnels from a remote node
@@ -511,7 +773,7 @@ func (c *Client) GetTunnels(node *db.Node) ([]*db.CloudflareTunnel, error) {
 
 	c.setNodeAuthHeaders(req, node)
 
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch tunnels from node %s: %w", node.Name, err)
 	}
@@ -539,7 +801,7 @@ func (c *Client) RestartContainer(node *db.Node, containerID string) error {
 
 	c.setNodeAuthHeaders(req, node)
 
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
 		return fmt.Errorf("failed to restart container on node %s: %w", node.Name, err)
 	}
@@ -562,7 +824,7 @@ func (c *Client) StopContainer(node *db.Node, containerID string) error {
 
 	c.setNodeAuthHeaders(req, node)
 
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
 		return fmt.Errorf("failed to stop container on node %s: %w", node.Name, err)
 	}
@@ -585,7 +847,7 @@ func (c *Client) DeleteContainer(node *db.Node, containerID string) error {
 
 	c.setNodeAuthHeaders(req, node)
 
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
 		return fmt.Errorf("failed to delete container on node %s: %w", node.Name, err)
 	}
@@ -608,7 +870,7 @@ func (c *Client) GetComposeVersions(node *db.Node, appID string) ([]*db.ComposeV
 
 	c.setNodeAuthHeaders(req, node)
 
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch compose versions from node %s: %w", node.Name, err)
 	}
@@ -636,7 +898,7 @@ func (c *Client) GetComposeVersion(node *db.Node, appID string, version int) (*d
 
 	c.setNodeAuthHeaders(req, node)
 
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch compose version from node %s: %w", node.Name, err)
 	}
@@ -679,7 +941,7 @@ func (c *Client) RollbackComposeVersion(node *db.Node, appID string, version int
 	req.Header.Set("Content-Type", "application/json")
 	c.setNodeAuthHeaders(req, node)
 
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to rollback compose version on node %s: %w", node.Name, err)
 	}
@@ -721,7 +983,7 @@ func (c *Client) GetAppLogs(node *db.Node, appID string, service string) ([]byte
 
 	c.setNodeAuthHeaders(req, node)
 
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch app logs from node %s: %w", node.Name, err)
 	}
@@ -740,6 +1002,211 @@ func (c *Client) GetAppLogs(node *db.Node, appID string, service string) ([]byte
 	return logs, nil
 }
 
+// SearchLogs searches recent container logs on a remote node
+func (c *Client) SearchLogs(node *db.Node, searchReq domain.LogSearchRequest) ([]*domain.LogSearchMatch, error) {
+	u, err := url.Parse(node.APIEndpoint + apipaths.LogsSearch)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse URL: %w", err)
+	}
+	q := u.Query()
+	q.Set("q", searchReq.Query)
+	if len(searchReq.Apps) > 0 {
+		q.Set("apps", strings.Join(searchReq.Apps, ","))
+	}
+	if searchReq.Since != "" {
+		q.Set("since", searchReq.Since)
+	}
+	if searchReq.ContextLines > 0 {
+		q.Set("context", strconv.Itoa(searchReq.ContextLines))
+	}
+	u.RawQuery = q.Encode()
+
+	req, err := http.NewRequest("GET", u.String(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	c.setNodeAuthHeaders(req, node)
+
+	resp, err := c.do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to search logs on node %s: %w", node.Name, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("node returned status %d: %s", resp.StatusCode, string(body))
+	}
+
+	var matches []*domain.LogSearchMatch
+	if err := json.NewDecoder(resp.Body).Decode(&matches); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	return matches, nil
+}
+
+// ListJobs lists recent jobs on a remote node
+func (c *Client) ListJobs(node *db.Node, filter domain.JobListFilter) ([]*db.Job, error) {
+	u, err := url.Parse(node.APIEndpoint + apipaths.Jobs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse URL: %w", err)
+	}
+	q := u.Query()
+	if filter.Status != "" {
+		q.Set("status", filter.Status)
+	}
+	if filter.Type != "" {
+		q.Set("type", filter.Type)
+	}
+	if filter.Limit > 0 {
+		q.Set("limit", strconv.Itoa(filter.Limit))
+	}
+	u.RawQuery = q.Encode()
+
+	req, err := http.NewRequest("GET", u.String(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	c.setNodeAuthHeaders(req, node)
+
+	resp, err := c.do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list jobs on node %s: %w", node.Name, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("node returned status %d: %s", resp.StatusCode, string(body))
+	}
+
+	var jobs []*db.Job
+	if err := json.NewDecoder(resp.Body).Decode(&jobs); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	return jobs, nil
+}
+
+// GetJob fetches a job from the remote node that runs it
+func (c *Client) GetJob(node *db.Node, jobID string) (*db.Job, error) {
+	req, err := http.NewRequest("GET", node.APIEndpoint+apipaths.JobByID(url.PathEscape(jobID)), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	c.setNodeAuthHeaders(req, node)
+
+	resp, err := c.do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch job from node %s: %w", node.Name, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, domain.WrapJobNotFound(jobID, nil)
+	}
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("node returned status %d: %s", resp.StatusCode, string(body))
+	}
+
+	var job db.Job
+	if err := json.NewDecoder(resp.Body).Decode(&job); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+
+	return &job, nil
+}
+
+// CreateAppAsync queues an app creation on a remote node
+func (c *Client) CreateAppAsync(node *db.Node, createReq domain.CreateAppRequest) (*db.Job, error) {
+	return c.startJob(node, http.MethodPost, apipaths.Apps+"?async=true", createReq)
+}
+
+// UpdateAppContainersAsync queues an app update on a remote node
+func (c *Client) UpdateAppContainersAsync(node *db.Node, appID string) (*db.Job, error) {
+	return c.startJob(node, http.MethodPost, apipaths.AppUpdateContainers(appID), nil)
+}
+
+// CreateTunnelForAppAsync queues a custom domain tunnel creation on a remote node
+func (c *Client) CreateTunnelForAppAsync(node *db.Node, appID string, ingressRules []db.IngressRule) (*db.Job, error) {
+	return c.startJob(node, http.MethodPost, apipaths.TunnelByApp(appID), map[string]interface{}{"ingress_rules": ingressRules})
+}
+
+// CreateQuickTunnelForAppAsync queues a Quick Tunnel creation on a remote node
+func (c *Client) CreateQuickTunnelForAppAsync(node *db.Node, appID, service string, port int) (*db.Job, error) {
+	return c.startJob(node, http.MethodPost, apipaths.AppQuickTunnel(appID), map[string]interface{}{"service": service, "port": port})
+}
+
+// SwitchAppToCustomTunnelAsync queues a switch from Quick Tunnel to a custom domain tunnel on a remote node
+func (c *Client) SwitchAppToCustomTunnelAsync(node *db.Node, appID string, ingressRules []db.IngressRule) (*db.Job, error) {
+	return c.startJob(node, http.MethodPost, apipaths.TunnelSwitchToCustom(appID), map[string]interface{}{"ingress_rules": ingressRules})
+}
+
+// DeleteTunnelAsync queues a tunnel deletion on a remote node, replacing the tunnel with a Quick
+// Tunnel when quickTunnelFallback is set
+func (c *Client) DeleteTunnelAsync(node *db.Node, appID string, quickTunnelFallback bool) (*db.Job, error) {
+	path := apipaths.TunnelByApp(appID)
+	if quickTunnelFallback {
+		path += "?quick_tunnel=true"
+	}
+	return c.startJob(node, http.MethodDelete, path, nil)
+}
+
+// startJob sends a request that a remote node answers by queuing a job (202 with the job's ID),
+// returning the queued job tagged with the node
+func (c *Client) startJob(node *db.Node, method, path string, body interface{}) (*db.Job, error) {
+	var reqBody io.Reader
+	if body != nil {
+		jsonData, err := json.Marshal(body)
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal request: %w", err)
+		}
+		reqBody = bytes.NewBuffer(jsonData)
+	}
+
+	req, err := http.NewRequest(method, node.APIEndpoint+path, reqBody)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	c.setNodeAuthHeaders(req, node)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start job on node %s: %w", node.Name, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusAccepted {
+		bodyBytes, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("node returned status %d: %s", resp.StatusCode, string(bodyBytes))
+	}
+
+	var accepted struct {
+		JobID   string `json:"job_id"`
+		AppID   string `json:"app_id"`
+		Status  string `json:"status"`
+		Message string `json:"message"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&accepted); err != nil {
+		return nil, fmt.Errorf("failed to decode response: %w", err)
+	}
+	if accepted.JobID == "" {
+		// e.g. the node holds the operation for approval
+		return nil, fmt.Errorf("node %s didn't start a job: %s", node.Name, accepted.Message)
+	}
+
+	return &db.Job{ID: accepted.JobID, AppID: accepted.AppID, Status: accepted.Status, NodeID: node.ID, NodeName: node.Name}, nil
+}
+
 // GetAppServices fetches the list of service names for an app from a remote node
 func (c *Client) GetAppServices(node *db.Node, appID string) ([]string, error) {
 	req, err := http.NewRequest("GET", node.APIEndpoint+apipaths.AppServices(appID), nil)
@@ -749,7 +1216,7 @@ func (c *Client) GetAppServices(node *db.Node, appID string) ([]string, error) {
 
 	c.setNodeAuthHeaders(req, node)
 
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch app services from node %s: %w", node.Name, err)
 	}
@@ -777,7 +1244,7 @@ func (c *Client) GetAppStats(node *db.Node, appID string) (*domain.AppStats, err
 
 	c.setNodeAuthHeaders(req, node)
 
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch app stats from node %s: %w", node.Name, err)
 	}
@@ -805,7 +1272,7 @@ func (c *Client) GetTunnelByAppID(node *db.Node, appID string) (*db.CloudflareTu
 
 	c.setNodeAuthHeaders(req, node)
 
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch tunnel from node %s: %w", node.Name, err)
 	}
@@ -843,7 +1310,7 @@ func (c *Client) SyncTunnelStatus(node *db.Node, appID string) error {
 
 	c.setNodeAuthHeaders(req, node)
 
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
 		return fmt.Errorf("failed to sync tunnel on node %s: %w", node.Name, err)
 	}
@@ -872,7 +1339,7 @@ func (c *Client) UpdateTunnelIngress(node *db.Node, appID string, req domain.Upd
 	httpReq.Header.Set("Content-Type", "application/json")
 	c.setNodeAuthHeaders(httpReq, node)
 
-	resp, err := c.httpClient.Do(httpReq)
+	resp, err := c.do(httpReq)
 	if err != nil {
 		return fmt.Errorf("failed to update tunnel ingress on node %s: %w", node.Name, err)
 	}
@@ -901,7 +1368,7 @@ func (c *Client) CreateTunnelDNSRecord(node *db.Node, appID string, req domain.C
 	httpReq.Header.Set("Content-Type", "application/json")
 	c.setNodeAuthHeaders(httpReq, node)
 
-	resp, err := c.httpClient.Do(httpReq)
+	resp, err := c.do(httpReq)
 	if err != nil {
 		return fmt.Errorf("failed to create DNS record on node %s: %w", node.Name, err)
 	}
@@ -914,26 +1381,3 @@ func (c *Client) CreateTunnelDNSRecord(node *db.Node, appID string, req domain.C
 
 	return nil
 }
-
-// DeleteTunnel deletes a tunnel on a remote node
-func (c *Client) DeleteTunnel(node *db.Node, appID string) error {
-	req, err := http.NewRequest("DELETE", node.APIEndpoint+apipaths.TunnelByApp(appID), nil)
-	if err != nil {
-		return fmt.Errorf("failed to create request: %w", err)
-	}
-
-	c.setNodeAuthHeaders(req, node)
-
-	resp, err := c.httpClient.Do(req)
-	if err != nil {
-		return fmt.Errorf("failed to delete tunnel on node %s: %w", node.Name, err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return fmt.Errorf("node returned status %d: %s", resp.StatusCode, string(body))
-	}
-
-	return nil
-}
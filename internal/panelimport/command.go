@@ -0,0 +1,119 @@
+package panelimport
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/joho/godotenv"
+	"github.com/selfhostly/internal/config"
+	"github.com/selfhostly/internal/constants"
+	"github.com/selfhostly/pkg/client"
+)
+
+// CommandName is the server's subcommand, e.g. `selfhostly import --from=dockge`
+const CommandName = "import"
+
+// appCreator is the part of the API client the import needs
+type appCreator interface {
+	CreateApp(ctx context.Context, req client.CreateAppRequest) (*client.App, error)
+}
+
+// RunCommand parses import flags, creates an app through the server's API for each stack found
+// and returns the process exit code
+func RunCommand(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet(CommandName, flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	from := fs.String("from", "", "panel to import from: "+strings.Join(Sources, ", "))
+	dir := fs.String("dir", "", "directory the panel keeps its stacks in (default: the panel's default install)")
+	envFile := fs.String("env-file", config.EnvFile(), "server env file, read for the API endpoint and node credentials")
+	apiURL := fs.String("url", "", "API to create the apps through (default: NODE_API_ENDPOINT)")
+	token := fs.String("token", os.Getenv("SELFHOSTLY_TOKEN"), "API token or JWT to authenticate with instead of the node credentials (SELFHOSTLY_TOKEN)")
+	nodeID := fs.String("node-id", "", "node to create the apps on (default: the node the API runs on)")
+	dryRun := fs.Bool("dry-run", false, "list the stacks found without creating apps")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *from == "" {
+		fmt.Fprintf(stderr, "import: --from is required (%s)\n", strings.Join(Sources, ", "))
+		return 2
+	}
+	if *dir == "" {
+		*dir = DefaultDir(*from)
+	}
+
+	stacks, err := Scan(*from, *dir)
+	if err != nil {
+		fmt.Fprintf(stderr, "import: %v\n", err)
+		return 1
+	}
+	if len(stacks) == 0 {
+		fmt.Fprintf(stdout, "No stacks found in %s\n", *dir)
+		return 0
+	}
+	if *dryRun {
+		for _, stack := range stacks {
+			fmt.Fprintf(stdout, "%s\t%s\n", AppName(stack.Name), stack.Path)
+		}
+		return 0
+	}
+
+	// The variables already set win, as for the server itself
+	_ = godotenv.Load(*envFile)
+	if *apiURL == "" {
+		*apiURL = getEnv("NODE_API_ENDPOINT", "http://localhost:8080")
+	}
+	var opts []client.Option
+	if strings.HasPrefix(*token, constants.APITokenPrefix) {
+		opts = append(opts, client.WithAPIToken(*token))
+	} else if *token != "" {
+		opts = append(opts, client.WithToken(*token))
+	} else if id, key := os.Getenv("NODE_ID"), os.Getenv("NODE_API_KEY"); id != "" && id != "auto" && key != "" {
+		// The server creates apps for node credentials on its own node, whatever the request names
+		if *nodeID != "" && *nodeID != id {
+			fmt.Fprintf(stderr, "import: node credentials only create apps on node %s; pass --token to import to node %s\n", id, *nodeID)
+			return 2
+		}
+		opts = append(opts, client.WithNodeCredentials(id, key))
+	}
+
+	if failed := importStacks(context.Background(), client.New(*apiURL, opts...), stacks, *nodeID, stdout, stderr); failed > 0 {
+		fmt.Fprintf(stderr, "import: %d of %d stacks could not be imported\n", failed, len(stacks))
+		return 1
+	}
+	return 0
+}
+
+// importStacks creates an app for each stack, carrying on past the ones that fail, and returns
+// how many failed
+func importStacks(ctx context.Context, apps appCreator, stacks []*Stack, nodeID string, stdout, stderr io.Writer) int {
+	failed := 0
+	for _, stack := range stacks {
+		name := AppName(stack.Name)
+		app, err := apps.CreateApp(ctx, client.CreateAppRequest{
+			Name:           name,
+			Description:    "Imported from " + stack.Path,
+			ComposeContent: stack.ComposeContent,
+			// The env file becomes the app's .env template; literal braces must not render
+			EnvTemplate: strings.ReplaceAll(stack.Env, "{{", `{{"{{"}}`),
+			NodeID:      nodeID,
+		})
+		if err != nil {
+			fmt.Fprintf(stderr, "import: %s: %v\n", name, err)
+			failed++
+			continue
+		}
+		fmt.Fprintf(stdout, "Imported %s (app %s)\n", name, app.ID)
+	}
+	return failed
+}
+
+func getEnv(key, defaultValue string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return defaultValue
+}
@@ -0,0 +1,293 @@
+// Package panelimport reads the stacks of other Docker panels from their data directories, so
+// they can be created as selfhostly apps
+package panelimport
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Panels stacks can be imported from
+const (
+	SourcePortainer = "portainer"
+	SourceDockge    = "dockge"
+	SourceRuntipi   = "runtipi"
+)
+
+// Sources lists the panels stacks can be imported from
+var Sources = []string{SourcePortainer, SourceDockge, SourceRuntipi}
+
+// composeFileNames are the names a stack's compose file is looked up by, in order
+var composeFileNames = []string{"compose.yaml", "compose.yml", "docker-compose.yaml", "docker-compose.yml"}
+
+// Stack is a compose project found in another panel's data
+type Stack struct {
+	Name           string // Name the app is created with
+	Path           string // Compose file the stack was read from
+	ComposeContent string
+	Env            string // The stack's env file, if it had one
+}
+
+// DefaultDir returns where source keeps its stacks in a default install
+func DefaultDir(source string) string {
+	switch source {
+	case SourcePortainer:
+		return "/data/compose"
+	case SourceDockge:
+		return "/opt/stacks"
+	case SourceRuntipi:
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, "runtipi")
+		}
+		return "runtipi"
+	}
+	return ""
+}
+
+// Scan reads the stacks source keeps under dir, sorted by name
+func Scan(source, dir string) ([]*Stack, error) {
+	var stacks []*Stack
+	var err error
+	switch source {
+	case SourcePortainer:
+		stacks, err = scanPortainer(dir)
+	case SourceDockge:
+		stacks, err = scanDockge(dir)
+	case SourceRuntipi:
+		stacks, err = scanRuntipi(dir)
+	default:
+		return nil, fmt.Errorf("unknown source %q (expected one of %s)", source, strings.Join(Sources, ", "))
+	}
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(stacks, func(i, j int) bool { return stacks[i].Name < stacks[j].Name })
+	return stacks, nil
+}
+
+// scanPortainer reads Portainer's compose directory, one numbered directory per stack. The stack
+// names live in Portainer's database, so a stack is named after its compose project name, if set.
+func scanPortainer(dir string) ([]*Stack, error) {
+	return scanStackDirs(dir, func(stackDir string) (*Stack, error) {
+		stack, err := readStack(stackDir, "stack.env", ".env")
+		if stack == nil || err != nil {
+			return stack, err
+		}
+		stack.Name = "portainer-" + filepath.Base(stackDir)
+		if name := composeProjectName(stack.ComposeContent); name != "" {
+			stack.Name = name
+		}
+		return stack, nil
+	})
+}
+
+// scanDockge reads Dockge's stacks directory, one directory per stack named after it
+func scanDockge(dir string) ([]*Stack, error) {
+	return scanStackDirs(dir, func(stackDir string) (*Stack, error) {
+		stack, err := readStack(stackDir, ".env")
+		if stack != nil {
+			stack.Name = filepath.Base(stackDir)
+		}
+		return stack, err
+	})
+}
+
+// scanRuntipi reads the installed apps of a Runtipi install: apps/<app> (or apps/<store>/<app>
+// since app stores) with the app's env in the matching app-data directory
+func scanRuntipi(dir string) ([]*Stack, error) {
+	appsDir := filepath.Join(dir, "apps")
+	read := func(appDir string) (*Stack, error) {
+		rel, _ := filepath.Rel(appsDir, appDir)
+		stack, err := readStack(appDir)
+		if stack == nil || err != nil {
+			return stack, err
+		}
+		stack.Name = filepath.Base(appDir)
+		env, err := os.ReadFile(filepath.Join(dir, "app-data", rel, "app.env"))
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+		stack.Env = string(env)
+		return stack, nil
+	}
+
+	stacks, err := scanStackDirs(appsDir, read)
+	if err != nil {
+		return nil, err
+	}
+	// Directories without a compose file are app stores; their apps are one level down
+	entries, _ := os.ReadDir(appsDir)
+	for _, entry := range entries {
+		storeDir := filepath.Join(appsDir, entry.Name())
+		if !entry.IsDir() || findComposeFile(storeDir) != "" {
+			continue
+		}
+		storeStacks, err := scanStackDirs(storeDir, read)
+		if err != nil {
+			return nil, err
+		}
+		stacks = append(stacks, storeStacks...)
+	}
+	return stacks, nil
+}
+
+// scanStackDirs reads each directory under dir with read, skipping the ones without a stack
+func scanStackDirs(dir string, read func(stackDir string) (*Stack, error)) ([]*Stack, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", dir, err)
+	}
+	var stacks []*Stack
+	for _, entry := range entries {
+		if !entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		stack, err := read(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		if stack != nil {
+			stacks = append(stacks, stack)
+		}
+	}
+	return stacks, nil
+}
+
+// readStack reads the compose file in dir and the first of envFiles that exists. It returns nil
+// when dir has no compose file.
+func readStack(dir string, envFiles ...string) (*Stack, error) {
+	composePath := findComposeFile(dir)
+	if composePath == "" {
+		return nil, nil
+	}
+	content, err := os.ReadFile(composePath)
+	if err != nil {
+		return nil, err
+	}
+	stack := &Stack{Path: composePath, ComposeContent: absoluteBindMounts(string(content), dir)}
+	for _, name := range envFiles {
+		env, err := os.ReadFile(filepath.Join(dir, name))
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		stack.Env = string(env)
+		break
+	}
+	return stack, nil
+}
+
+func findComposeFile(dir string) string {
+	for _, name := range composeFileNames {
+		path := filepath.Join(dir, name)
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			return path
+		}
+	}
+	return ""
+}
+
+// absoluteBindMounts points the relative bind mounts of a stack's services (./data:/data) at the
+// stack's directory, where their data is, instead of the new app's empty one. The content is
+// returned as it was when nothing is relative, or when it doesn't parse, for the server to refuse.
+func absoluteBindMounts(content, stackDir string) string {
+	var doc yaml.Node
+	if err := yaml.Unmarshal([]byte(content), &doc); err != nil || len(doc.Content) == 0 {
+		return content
+	}
+	services := mappingValue(doc.Content[0], "services")
+	if services == nil || services.Kind != yaml.MappingNode {
+		return content
+	}
+	if abs, err := filepath.Abs(stackDir); err == nil {
+		stackDir = abs
+	}
+
+	changed := false
+	for i := 1; i < len(services.Content); i += 2 {
+		volumes := mappingValue(services.Content[i], "volumes")
+		if volumes == nil || volumes.Kind != yaml.SequenceNode {
+			continue
+		}
+		for _, volume := range volumes.Content {
+			switch volume.Kind {
+			case yaml.ScalarNode:
+				// Short syntax: SOURCE:TARGET[:MODE]
+				source, rest, ok := strings.Cut(volume.Value, ":")
+				if ok && isRelativePath(source) {
+					volume.Value = filepath.Join(stackDir, source) + ":" + rest
+					changed = true
+				}
+			case yaml.MappingNode:
+				source := mappingValue(volume, "source")
+				if kind := mappingValue(volume, "type"); kind != nil && kind.Value == "bind" && source != nil && isRelativePath(source.Value) {
+					source.Value = filepath.Join(stackDir, source.Value)
+					changed = true
+				}
+			}
+		}
+	}
+	if !changed {
+		return content
+	}
+
+	var buf bytes.Buffer
+	encoder := yaml.NewEncoder(&buf)
+	encoder.SetIndent(2)
+	if encoder.Encode(&doc) != nil || encoder.Close() != nil {
+		return content
+	}
+	return buf.String()
+}
+
+// isRelativePath reports whether a volume source is a path relative to the compose file, as
+// opposed to an absolute path or a named volume
+func isRelativePath(source string) bool {
+	return source == "." || source == ".." || strings.HasPrefix(source, "./") || strings.HasPrefix(source, "../")
+}
+
+// mappingValue returns the value for key in a mapping node, or nil
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	if node == nil || node.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// composeProjectName returns the top-level name of a compose file, if it sets one
+func composeProjectName(content string) string {
+	var project struct {
+		Name string `yaml:"name"`
+	}
+	if err := yaml.Unmarshal([]byte(content), &project); err != nil {
+		return ""
+	}
+	return project.Name
+}
+
+var invalidNameChars = regexp.MustCompile(`[^a-zA-Z0-9_-]+`)
+
+// AppName turns a stack name into a valid app name: letters, numbers, hyphens and underscores, at
+// most 64 characters
+func AppName(name string) string {
+	name = strings.Trim(invalidNameChars.ReplaceAllString(name, "-"), "-")
+	if len(name) > 64 {
+		name = strings.TrimRight(name[:64], "-")
+	}
+	return name
+}
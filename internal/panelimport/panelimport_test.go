@@ -0,0 +1,185 @@
+package panelimport
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/selfhostly/internal/docker"
+	"github.com/selfhostly/pkg/client"
+)
+
+const testCompose = "services:\n  web:\n    image: nginx:latest\n"
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestScan_Portainer(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "1", "docker-compose.yml"), "name: nextcloud\n"+testCompose)
+	writeFile(t, filepath.Join(dir, "1", "stack.env"), "TZ=UTC\n")
+	writeFile(t, filepath.Join(dir, "2", "docker-compose.yml"), testCompose)
+	writeFile(t, filepath.Join(dir, "3", "notes.txt"), "not a stack")
+
+	stacks, err := Scan(SourcePortainer, dir)
+	if err != nil {
+		t.Fatalf("Scan: %v", err)
+	}
+	if len(stacks) != 2 || stacks[0].Name != "nextcloud" || stacks[0].Env != "TZ=UTC\n" || stacks[1].Name != "portainer-2" {
+		t.Errorf("unexpected stacks %+v", stacks)
+	}
+}
+
+func TestScan_Dockge(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "uptime-kuma", "compose.yaml"), testCompose)
+	writeFile(t, filepath.Join(dir, "uptime-kuma", ".env"), "PORT=3001\n")
+
+	stacks, err := Scan(SourceDockge, dir)
+	if err != nil {
+		t.Fatalf("Scan: %v", err)
+	}
+	if len(stacks) != 1 || stacks[0].Name != "uptime-kuma" || stacks[0].ComposeContent != testCompose || stacks[0].Env != "PORT=3001\n" {
+		t.Errorf("unexpected stacks %+v", stacks)
+	}
+}
+
+func TestScan_Runtipi(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "apps", "jellyfin", "docker-compose.yml"), testCompose)
+	writeFile(t, filepath.Join(dir, "app-data", "jellyfin", "app.env"), "APP_PORT=8096\n")
+	// App store layout
+	writeFile(t, filepath.Join(dir, "apps", "migrated", "gitea", "docker-compose.yml"), testCompose)
+
+	stacks, err := Scan(SourceRuntipi, dir)
+	if err != nil {
+		t.Fatalf("Scan: %v", err)
+	}
+	if len(stacks) != 2 || stacks[0].Name != "gitea" || stacks[1].Name != "jellyfin" || stacks[1].Env != "APP_PORT=8096\n" {
+		t.Errorf("unexpected stacks %+v", stacks)
+	}
+}
+
+func TestScan_RelativeBindMounts(t *testing.T) {
+	dir := t.TempDir()
+	stackDir := filepath.Join(dir, "vaultwarden")
+	writeFile(t, filepath.Join(stackDir, "compose.yaml"), `services:
+  web:
+    image: vaultwarden/server # pinned below
+    volumes:
+      - ./data:/data
+      - ../shared:/shared:ro
+      - /srv/backups:/backups
+      - cache:/cache
+      - type: bind
+        source: ./config
+        target: /config
+volumes:
+  cache:
+`)
+
+	stacks, err := Scan(SourceDockge, dir)
+	if err != nil || len(stacks) != 1 {
+		t.Fatalf("Scan: %+v, %v", stacks, err)
+	}
+	compose := stacks[0].ComposeContent
+	for _, want := range []string{
+		filepath.Join(stackDir, "data") + ":/data",
+		filepath.Join(dir, "shared") + ":/shared:ro",
+		"/srv/backups:/backups",
+		"cache:/cache",
+		"source: " + filepath.Join(stackDir, "config"),
+		"# pinned below",
+	} {
+		if !strings.Contains(compose, want) {
+			t.Errorf("expected %q in the imported compose:\n%s", want, compose)
+		}
+	}
+
+	// Compose without relative mounts is imported as written
+	writeFile(t, filepath.Join(dir, "vaultwarden", "compose.yaml"), testCompose)
+	if stacks, _ := Scan(SourceDockge, dir); stacks[0].ComposeContent != testCompose {
+		t.Errorf("expected the compose unchanged, got %q", stacks[0].ComposeContent)
+	}
+}
+
+func TestRunCommand_NodeIDNeedsToken(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "web", "compose.yaml"), testCompose)
+	t.Setenv("NODE_ID", "node-1")
+	t.Setenv("NODE_API_KEY", "key")
+	t.Setenv("SELFHOSTLY_TOKEN", "")
+
+	var stdout, stderr bytes.Buffer
+	args := []string{"--from=dockge", "--dir=" + dir, "--env-file=" + filepath.Join(dir, "missing.env"), "--url=http://127.0.0.1:1", "--node-id=node-2"}
+	if code := RunCommand(args, &stdout, &stderr); code != 2 || !strings.Contains(stderr.String(), "pass --token") {
+		t.Errorf("expected another node to need a token, got %d: %s", code, stderr.String())
+	}
+}
+
+func TestScan_UnknownSource(t *testing.T) {
+	if _, err := Scan("yacht", t.TempDir()); err == nil {
+		t.Error("expected an unknown source to be refused")
+	}
+}
+
+func TestAppName(t *testing.T) {
+	for in, want := range map[string]string{
+		"uptime-kuma":           "uptime-kuma",
+		"My Stack (v2)":         "My-Stack-v2",
+		"home.assist":           "home-assist",
+		strings.Repeat("a", 70): strings.Repeat("a", 64),
+	} {
+		if got := AppName(in); got != want {
+			t.Errorf("AppName(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+type fakeCreator struct {
+	requests []client.CreateAppRequest
+}
+
+func (f *fakeCreator) CreateApp(ctx context.Context, req client.CreateAppRequest) (*client.App, error) {
+	f.requests = append(f.requests, req)
+	if req.Name == "taken" {
+		return nil, errors.New("app already exists")
+	}
+	return &client.App{ID: "id-" + req.Name, Name: req.Name}, nil
+}
+
+func TestImportStacks(t *testing.T) {
+	creator := &fakeCreator{}
+	stacks := []*Stack{
+		{Name: "taken", Path: "/opt/stacks/taken/compose.yaml", ComposeContent: testCompose},
+		{Name: "web", Path: "/opt/stacks/web/compose.yaml", ComposeContent: testCompose, Env: "GREETING={{ hi }}\n"},
+	}
+	var stdout, stderr bytes.Buffer
+
+	if failed := importStacks(context.Background(), creator, stacks, "node-1", &stdout, &stderr); failed != 1 {
+		t.Errorf("expected one failed stack, got %d", failed)
+	}
+	if len(creator.requests) != 2 || creator.requests[1].NodeID != "node-1" {
+		t.Fatalf("expected both stacks to be created on node-1, got %+v", creator.requests)
+	}
+	if !strings.Contains(stdout.String(), "Imported web") || !strings.Contains(stderr.String(), "taken: app already exists") {
+		t.Errorf("unexpected output %q / %q", stdout.String(), stderr.String())
+	}
+
+	// The env file keeps its literal braces once rendered
+	rendered, err := docker.RenderEnvTemplate(creator.requests[1].EnvTemplate, docker.EnvTemplateData{}, "", "")
+	if err != nil || rendered != "GREETING={{ hi }}\n" {
+		t.Errorf("expected the env to render unchanged, got %q (%v)", rendered, err)
+	}
+}
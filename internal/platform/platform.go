@@ -0,0 +1,83 @@
+// Package platform contains OS-aware helpers so nodes can run on Linux hosts as well as
+// macOS/Windows machines with Docker Desktop.
+package platform
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/selfhostly/internal/constants"
+)
+
+// Operating system identifiers as reported by runtime.GOOS
+const (
+	OSLinux   = "linux"
+	OSDarwin  = "darwin"
+	OSWindows = "windows"
+)
+
+// OS returns the current operating system (runtime.GOOS)
+func OS() string {
+	return runtime.GOOS
+}
+
+// Arch returns the current CPU architecture (runtime.GOARCH), e.g. "amd64" or "arm64"
+func Arch() string {
+	return runtime.GOARCH
+}
+
+// IsDockerDesktop reports whether Docker is expected to run through Docker Desktop
+// (a VM), i.e. the host is macOS or Windows
+func IsDockerDesktop() bool {
+	return isDockerDesktop(runtime.GOOS)
+}
+
+func isDockerDesktop(goos string) bool {
+	return goos == OSDarwin || goos == OSWindows
+}
+
+// HostGatewayAddresses returns hostnames/IPs that reach the Docker host from inside a
+// container, most likely first. On Docker Desktop the bridge IP is inside the VM and
+// is not reachable, so only host.docker.internal is returned.
+func HostGatewayAddresses() []string {
+	return hostGatewayAddresses(runtime.GOOS)
+}
+
+func hostGatewayAddresses(goos string) []string {
+	if isDockerDesktop(goos) {
+		return []string{constants.DockerHostInternal}
+	}
+	return []string{constants.DockerBridgeGateway, constants.DockerHostInternal}
+}
+
+// IsRunningInContainer checks if the current process is running inside a Docker container
+func IsRunningInContainer() bool {
+	// Docker sets /.dockerenv on every OS that runs Linux containers
+	if _, err := os.Stat("/.dockerenv"); err == nil {
+		return true
+	}
+
+	// cgroup inspection is only meaningful on Linux
+	if runtime.GOOS != OSLinux {
+		return false
+	}
+	cgroup, err := os.ReadFile("/proc/self/cgroup")
+	return err == nil && strings.Contains(string(cgroup), "docker")
+}
+
+// DiskRoot returns the filesystem root to report disk usage for, given a path on it.
+// On Windows this is the drive of path (e.g. "C:\"); elsewhere it is "/".
+func DiskRoot(path string) string {
+	if runtime.GOOS != OSWindows {
+		return "/"
+	}
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	if volume := filepath.VolumeName(path); volume != "" {
+		return volume + `\`
+	}
+	return `C:\`
+}
@@ -0,0 +1,36 @@
+package platform
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/selfhostly/internal/constants"
+)
+
+func TestHostGatewayAddresses(t *testing.T) {
+	tests := []struct {
+		goos string
+		want []string
+	}{
+		{OSLinux, []string{constants.DockerBridgeGateway, constants.DockerHostInternal}},
+		{OSDarwin, []string{constants.DockerHostInternal}},
+		{OSWindows, []string{constants.DockerHostInternal}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.goos, func(t *testing.T) {
+			if got := hostGatewayAddresses(tt.goos); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("hostGatewayAddresses(%q) = %v, want %v", tt.goos, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIsDockerDesktop(t *testing.T) {
+	if isDockerDesktop(OSLinux) {
+		t.Error("linux should not be treated as Docker Desktop")
+	}
+	if !isDockerDesktop(OSDarwin) || !isDockerDesktop(OSWindows) {
+		t.Error("darwin and windows should be treated as Docker Desktop")
+	}
+}
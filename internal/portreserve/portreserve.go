@@ -0,0 +1,109 @@
+// Package portreserve keeps a node's host port reservations in step with its apps. An app reserves
+// every host port its compose publishes, and its Quick Tunnel's metrics port, whenever its compose
+// is written; a port held by another app or by a manual reservation refuses the change, so two apps
+// on a node can't be deployed onto the same port.
+package portreserve
+
+import (
+	"fmt"
+
+	"github.com/selfhostly/internal/constants"
+	"github.com/selfhostly/internal/db"
+	"github.com/selfhostly/internal/docker"
+	"github.com/selfhostly/internal/domain"
+)
+
+// ForApp returns the reservations an app needs on a node
+func ForApp(nodeID string, app *db.App) ([]*db.PortReservation, error) {
+	ports, err := docker.ComposeHostPorts(app.ComposeContent, app.ComposeOverride, app.ComposeFiles)
+	if err != nil {
+		return nil, domain.WrapComposeInvalid(err)
+	}
+	reservations := make([]*db.PortReservation, 0, len(ports)+1)
+	for _, port := range ports {
+		reservations = append(reservations, db.NewPortReservation(nodeID, port.Port, port.Protocol, app.ID,
+			constants.PortReservationSourceCompose, "Published by service "+port.Service))
+	}
+	if app.TunnelMode == constants.TunnelModeQuick {
+		if port, ok := docker.ExtractQuickTunnelMetricsHostPort(app.TunnelComposeSource()); ok {
+			reservations = append(reservations, db.NewPortReservation(nodeID, port, "tcp", app.ID,
+				constants.PortReservationSourceQuickTunnelMetrics, "Quick Tunnel metrics"))
+		}
+	}
+	return reservations, nil
+}
+
+// Check refuses an app's compose when a port it publishes is held by something other than the
+// app; appID is empty for an app that doesn't exist yet
+func Check(database *db.DB, nodeID, appID, composeContent, composeOverride string, composeFiles map[string]string) error {
+	reservations, err := ForApp(nodeID, &db.App{
+		ID:              appID,
+		ComposeContent:  composeContent,
+		ComposeOverride: composeOverride,
+		ComposeFiles:    composeFiles,
+	})
+	if err != nil {
+		return err
+	}
+	holder, err := database.FindPortConflict(nodeID, appID, reservations)
+	if err != nil {
+		return domain.WrapDatabaseOperation("check port reservations", err)
+	}
+	if holder != nil {
+		return domain.WrapPortReserved(holder.Port, holder.Protocol, Describe(database, holder))
+	}
+	return nil
+}
+
+// Sync replaces an app's reservations with the ones its compose needs now, refusing when a port is
+// held by something else. An app whose compose can't be read keeps the reservations it has; docker
+// compose reports the problem when it is deployed.
+func Sync(database *db.DB, nodeID string, app *db.App) error {
+	reservations, err := ForApp(nodeID, app)
+	if err != nil {
+		return nil
+	}
+	holder, err := database.SetAppPortReservations(nodeID, app.ID, reservations)
+	if err != nil {
+		return domain.WrapDatabaseOperation("reserve ports", err)
+	}
+	if holder != nil {
+		return domain.WrapPortReserved(holder.Port, holder.Protocol, Describe(database, holder))
+	}
+	return nil
+}
+
+// NextFree returns the lowest port in [min, max] that isn't reserved on the node for the protocol
+func NextFree(database *db.DB, nodeID, protocol string, min, max int) (int, error) {
+	reservations, err := database.GetPortReservations(nodeID)
+	if err != nil {
+		return 0, err
+	}
+	taken := make(map[int]bool, len(reservations))
+	for _, reservation := range reservations {
+		if reservation.Protocol == protocol {
+			taken[reservation.Port] = true
+		}
+	}
+	for port := min; port <= max; port++ {
+		if !taken[port] {
+			return port, nil
+		}
+	}
+	return 0, fmt.Errorf("every %s port from %d to %d is reserved", protocol, min, max)
+}
+
+// Describe names what holds a reservation, for error messages
+func Describe(database *db.DB, reservation *db.PortReservation) string {
+	if reservation.AppID == nil {
+		if reservation.Description != "" {
+			return fmt.Sprintf("manual reservation %q", reservation.Description)
+		}
+		return "a manual reservation"
+	}
+	name := *reservation.AppID
+	if app, err := database.GetApp(*reservation.AppID); err == nil {
+		name = app.Name
+	}
+	return "app " + name
+}
@@ -0,0 +1,135 @@
+package reqsign
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// received turns a signed client request into the request its receiver sees
+func received(t *testing.T, req *http.Request) (*http.Request, []byte) {
+	t.Helper()
+	var body []byte
+	if req.Body != nil {
+		var err error
+		if body, err = io.ReadAll(req.Body); err != nil {
+			t.Fatal(err)
+		}
+	}
+	in := httptest.NewRequest(req.Method, req.URL.RequestURI(), bytes.NewReader(body))
+	in.Header = req.Header.Clone()
+	return in, body
+}
+
+func TestSignAndVerify(t *testing.T) {
+	req, _ := http.NewRequest(http.MethodPost, "http://node:8080/api/apps/app-1/start?node_id=n1", bytes.NewReader([]byte(`{"a":1}`)))
+	if err := Sign(req, "key"); err != nil {
+		t.Fatalf("Sign: %v", err)
+	}
+	// Signing reads the body through GetBody, leaving it to be sent
+	in, body := received(t, req)
+	if string(body) != `{"a":1}` {
+		t.Fatalf("body consumed by signing, got %q", body)
+	}
+
+	v := NewVerifier()
+	if err := v.Verify(in, "other-key", body); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("expected invalid signature with another key, got %v", err)
+	}
+	if err := v.Verify(in, "key", []byte(`{"a":2}`)); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("expected invalid signature for an altered body, got %v", err)
+	}
+	if err := v.Verify(in, "key", body); err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	if err := v.Verify(in, "key", body); !errors.Is(err, ErrReplayed) {
+		t.Errorf("expected a replay to be rejected, got %v", err)
+	}
+}
+
+func TestVerify_StaleAndUnsigned(t *testing.T) {
+	v := NewVerifier()
+
+	unsigned := httptest.NewRequest(http.MethodGet, "/api/nodes", nil)
+	if err := v.Verify(unsigned, "key", nil); !errors.Is(err, ErrUnsigned) {
+		t.Errorf("expected unsigned error, got %v", err)
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, "http://primary/api/nodes", nil)
+	if err := Sign(req, "key"); err != nil {
+		t.Fatalf("Sign: %v", err)
+	}
+	in, _ := received(t, req)
+	v.now = func() time.Time { return time.Now().Add(MaxSkew + time.Minute) }
+	if err := v.Verify(in, "key", nil); !errors.Is(err, ErrStale) {
+		t.Errorf("expected stale error, got %v", err)
+	}
+
+	// Moving the timestamp invalidates the signature
+	in.Header.Set(HeaderTimestamp, strconv.FormatInt(v.now().Unix(), 10))
+	if err := v.Verify(in, "key", nil); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("expected invalid signature for a moved timestamp, got %v", err)
+	}
+}
+
+func TestVerify_DerivedKey(t *testing.T) {
+	// A signature keyed with the API key itself is refused, even when the request carries the key
+	raw := httptest.NewRequest(http.MethodGet, "/api/nodes", nil)
+	raw.Header.Set(HeaderTimestamp, strconv.FormatInt(time.Now().Unix(), 10))
+	raw.Header.Set(HeaderNonce, "raw")
+	raw.Header.Set(HeaderSignature, signature([]byte("key"), raw, nil))
+	raw.Header.Set("X-Node-API-Key", "key")
+
+	v := NewVerifier()
+	if err := v.Verify(raw, "key", nil); !errors.Is(err, ErrInvalidSignature) {
+		t.Errorf("expected a signature made with the API key to be refused, got %v", err)
+	}
+
+	// Requests signed now don't need the key to be verified
+	req, _ := http.NewRequest(http.MethodGet, "http://primary/api/nodes", nil)
+	if err := Sign(req, "key"); err != nil {
+		t.Fatalf("Sign: %v", err)
+	}
+	if req.Header.Get(HeaderSignature) == signature([]byte("key"), req, nil) {
+		t.Error("expected requests to be signed with a key derived from the API key")
+	}
+	in, _ := received(t, req)
+	if err := v.Verify(in, "key", nil); err != nil {
+		t.Errorf("Verify: %v", err)
+	}
+}
+
+func TestVerify_ForgetsExpiredNonces(t *testing.T) {
+	// signedAt builds a request signed with key at a given time
+	signedAt := func(at time.Time, nonce string) *http.Request {
+		req := httptest.NewRequest(http.MethodGet, "/api/nodes", nil)
+		req.Header.Set(HeaderTimestamp, strconv.FormatInt(at.Unix(), 10))
+		req.Header.Set(HeaderNonce, nonce)
+		req.Header.Set(HeaderSignature, signature(signingKey("key"), req, nil))
+		return req
+	}
+
+	start := time.Now()
+	v := NewVerifier()
+	v.now = func() time.Time { return start }
+	for _, nonce := range []string{"a", "b", "c"} {
+		if err := v.Verify(signedAt(start, nonce), "key", nil); err != nil {
+			t.Fatalf("Verify: %v", err)
+		}
+	}
+
+	// Once their timestamps would be stale, the nonces are forgotten by the next request
+	later := start.Add(MaxSkew + time.Minute)
+	v.now = func() time.Time { return later }
+	if err := v.Verify(signedAt(later, "d"), "key", nil); err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	if len(v.seen) != 1 || v.expiring.Len() != 1 {
+		t.Errorf("expected only the new nonce to be remembered, got %d nonces and %d queued", len(v.seen), v.expiring.Len())
+	}
+}
@@ -2,80 +2,150 @@ package routing
 
 import (
 	"context"
+	"fmt"
 	"log/slog"
 	"sync"
+	"time"
 
+	"github.com/selfhostly/internal/constants"
 	"github.com/selfhostly/internal/db"
+	"github.com/selfhostly/internal/domain"
 )
 
 // AppsAggregator aggregates apps from multiple nodes
 type AppsAggregator struct {
-	router *NodeRouter
-	logger *slog.Logger
+	router      *NodeRouter
+	logger      *slog.Logger
+	concurrency int
+	timeout     time.Duration
 }
 
 // NewAppsAggregator creates a new apps aggregator
 func NewAppsAggregator(router *NodeRouter, logger *slog.Logger) *AppsAggregator {
 	return &AppsAggregator{
-		router: router,
-		logger: logger,
+		router:      router,
+		logger:      logger,
+		concurrency: constants.NodeFanOutConcurrency,
+		timeout:     constants.NodeFanOutTimeout,
 	}
 }
 
-// AggregateApps fetches apps from multiple nodes in parallel
+// AggregateApps fetches apps from multiple nodes in parallel, at most
+// constants.NodeFanOutConcurrency at a time. A node that fails or takes longer than
+// constants.NodeFanOutTimeout is left out and reported in the returned node errors, so one bad
+// node can't hide or stall the rest; its apps come from fallback instead when one is given.
 func (a *AppsAggregator) AggregateApps(
 	ctx context.Context,
 	nodes []*db.Node,
 	localFetcher func() ([]*db.App, error),
 	remoteFetcher func(*db.Node) ([]*db.App, error),
-) ([]*db.App, error) {
+	fallback func(*db.Node) ([]*db.App, error),
+) ([]*db.App, []domain.NodeError) {
+	fetch := func(n *db.Node) ([]*db.App, error) {
+		if n.ID == a.router.localNodeID {
+			return localFetcher()
+		}
+		a.logger.DebugContext(ctx, "fetching apps from remote node", "nodeID", n.ID, "nodeName", n.Name)
+		return remoteFetcher(n)
+	}
+	tag := func(n *db.Node, apps []*db.App) {
+		// Add node ID to each app for display
+		for _, app := range apps {
+			app.NodeID = n.ID
+		}
+	}
+	return fanOut(ctx, a.logger, "apps", nodes, a.concurrency, a.timeout, fetch, fallback, tag)
+}
+
+// fanOut runs fetch on each node in parallel, at most concurrency at a time, giving up on a node
+// after timeout. Nodes that fail are logged and reported in the returned node errors, and their
+// items come from fallback when one is given. tag marks each node's items as coming from it.
+func fanOut[T any](
+	ctx context.Context,
+	logger *slog.Logger,
+	what string,
+	nodes []*db.Node,
+	concurrency int,
+	timeout time.Duration,
+	fetch func(*db.Node) ([]T, error),
+	fallback func(*db.Node) ([]T, error),
+	tag func(*db.Node, []T),
+) ([]T, []domain.NodeError) {
 	var (
-		allApps []*db.App
-		mu      sync.Mutex
-		wg      sync.WaitGroup
+		all        []T
+		nodeErrors []domain.NodeError
+		mu         sync.Mutex
+		wg         sync.WaitGroup
 	)
+	slots := make(chan struct{}, concurrency)
 
 	for _, node := range nodes {
 		wg.Add(1)
 		go func(n *db.Node) {
 			defer wg.Done()
 
-			if n.ID == a.router.localNodeID {
-				// Fetch local apps
-				localApps, err := localFetcher()
-				if err != nil {
-					a.logger.ErrorContext(ctx, "failed to retrieve local apps", "error", err)
-					return
-				}
-
-				// Add node ID to each app for display
-				for _, app := range localApps {
-					app.NodeID = n.ID
-				}
-
+			select {
+			case slots <- struct{}{}:
+				defer func() { <-slots }()
+			case <-ctx.Done():
 				mu.Lock()
-				allApps = append(allApps, localApps...)
+				nodeErrors = append(nodeErrors, domain.NodeError{NodeID: n.ID, NodeName: n.Name, Error: ctx.Err().Error()})
 				mu.Unlock()
-			} else {
-				// Fetch from remote node
-				a.logger.InfoContext(ctx, "fetching apps from remote node", "nodeID", n.ID, "nodeName", n.Name)
-				remoteApps, err := remoteFetcher(n)
-				if err != nil {
-					a.logger.WarnContext(ctx, "failed to fetch apps from remote node", "nodeID", n.ID, "nodeName", n.Name, "error", err)
-					return
+				return
+			}
+
+			items, err := fetchWithTimeout(ctx, timeout, func() ([]T, error) { return fetch(n) })
+			if err != nil {
+				logger.WarnContext(ctx, "failed to fetch "+what+" from node", "nodeID", n.ID, "nodeName", n.Name, "error", err)
+				items = nil
+				if fallback != nil {
+					cached, fallbackErr := fallback(n)
+					if fallbackErr != nil {
+						logger.WarnContext(ctx, "failed to fall back for node "+what, "nodeID", n.ID, "error", fallbackErr)
+					}
+					items = cached
 				}
+			}
+			tag(n, items)
 
-				mu.Lock()
-				allApps = append(allApps, remoteApps...)
-				mu.Unlock()
+			mu.Lock()
+			all = append(all, items...)
+			if err != nil {
+				nodeErrors = append(nodeErrors, domain.NodeError{NodeID: n.ID, NodeName: n.Name, Error: err.Error()})
 			}
+			mu.Unlock()
 		}(node)
 	}
 
 	// Wait for all fetches to complete
 	wg.Wait()
 
-	return allApps, nil
+	return all, nodeErrors
+}
+
+// fetchWithTimeout runs fetch, giving up after timeout. A fetch that is given up on finishes in
+// the background, bounded by the node client's own request timeout.
+func fetchWithTimeout[T any](ctx context.Context, timeout time.Duration, fetch func() ([]T, error)) ([]T, error) {
+	type result struct {
+		items []T
+		err   error
+	}
+	done := make(chan result, 1)
+	go func() {
+		items, err := fetch()
+		done <- result{items, err}
+	}()
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case r := <-done:
+		return r.items, r.err
+	case <-timer.C:
+		return nil, fmt.Errorf("node didn't answer within %s", timeout)
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
 }
 
 // TunnelsAggregator aggregates Cloudflare tunnels from multiple nodes
@@ -0,0 +1,83 @@
+package routing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/selfhostly/internal/db"
+)
+
+func TestAppsAggregator_AggregateApps(t *testing.T) {
+	agg := NewAppsAggregator(NewNodeRouter(nil, nil, "local", slog.Default()), slog.Default())
+	agg.concurrency = 2
+	agg.timeout = 100 * time.Millisecond
+
+	nodes := []*db.Node{{ID: "local", Name: "local"}}
+	for i := 0; i < 6; i++ {
+		nodes = append(nodes, &db.Node{ID: fmt.Sprintf("node-%d", i), Name: fmt.Sprintf("node %d", i)})
+	}
+	nodes = append(nodes, &db.Node{ID: "broken", Name: "broken"}, &db.Node{ID: "slow", Name: "slow"})
+
+	var running, maxRunning atomic.Int32
+	remote := func(n *db.Node) ([]*db.App, error) {
+		if n.ID == "slow" {
+			// Given up on, so it keeps running after its slot is freed
+			time.Sleep(time.Second)
+			return nil, nil
+		}
+		now := running.Add(1)
+		defer running.Add(-1)
+		for {
+			peak := maxRunning.Load()
+			if now <= peak || maxRunning.CompareAndSwap(peak, now) {
+				break
+			}
+		}
+
+		time.Sleep(10 * time.Millisecond)
+		if n.ID == "broken" {
+			return nil, errors.New("connection refused")
+		}
+		return []*db.App{{ID: "app-" + n.ID}}, nil
+	}
+	fallback := func(n *db.Node) ([]*db.App, error) {
+		return []*db.App{{ID: "cached-" + n.ID, Stale: true}}, nil
+	}
+
+	start := time.Now()
+	apps, nodeErrors := agg.AggregateApps(context.Background(), nodes,
+		func() ([]*db.App, error) { return []*db.App{{ID: "app-local"}}, nil },
+		remote, fallback)
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("Expected the slow node not to stall the list, took %s", elapsed)
+	}
+	if peak := maxRunning.Load(); peak > 2 {
+		t.Errorf("Expected at most 2 nodes to be asked at once, got %d", peak)
+	}
+
+	// Every node is listed, the failed ones from the fallback
+	if len(apps) != len(nodes) {
+		t.Errorf("Expected %d apps, got %d", len(nodes), len(apps))
+	}
+	for _, app := range apps {
+		if app.NodeID == "" {
+			t.Errorf("Expected app %s to be tagged with its node", app.ID)
+		}
+		if (app.NodeID == "broken" || app.NodeID == "slow") != app.Stale {
+			t.Errorf("Unexpected app %+v", app)
+		}
+	}
+
+	reported := map[string]string{}
+	for _, e := range nodeErrors {
+		reported[e.NodeID] = e.Error
+	}
+	if len(reported) != 2 || reported["broken"] != "connection refused" || reported["slow"] == "" {
+		t.Errorf("Expected the broken and slow nodes to be reported, got %+v", nodeErrors)
+	}
+}
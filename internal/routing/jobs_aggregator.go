@@ -0,0 +1,63 @@
+package routing
+
+import (
+	"context"
+	"log/slog"
+	"sort"
+	"time"
+
+	"github.com/selfhostly/internal/constants"
+	"github.com/selfhostly/internal/db"
+	"github.com/selfhostly/internal/domain"
+)
+
+// JobsAggregator aggregates job listings from multiple nodes
+type JobsAggregator struct {
+	router      *NodeRouter
+	logger      *slog.Logger
+	concurrency int
+	timeout     time.Duration
+}
+
+// NewJobsAggregator creates a new jobs aggregator
+func NewJobsAggregator(router *NodeRouter, logger *slog.Logger) *JobsAggregator {
+	return &JobsAggregator{
+		router:      router,
+		logger:      logger,
+		concurrency: constants.NodeFanOutConcurrency,
+		timeout:     constants.NodeFanOutTimeout,
+	}
+}
+
+// AggregateJobs lists jobs on multiple nodes in parallel and merges them newest first, keeping
+// at most limit. Each job is tagged with the node that runs it; nodes that fail or are too slow
+// are left out and reported in the returned node errors.
+func (a *JobsAggregator) AggregateJobs(
+	ctx context.Context,
+	nodes []*db.Node,
+	limit int,
+	localFetcher func() ([]*db.Job, error),
+	remoteFetcher func(*db.Node) ([]*db.Job, error),
+) ([]*db.Job, []domain.NodeError) {
+	fetch := func(n *db.Node) ([]*db.Job, error) {
+		if n.ID == a.router.localNodeID {
+			return localFetcher()
+		}
+		return remoteFetcher(n)
+	}
+	tag := func(n *db.Node, jobs []*db.Job) {
+		for _, job := range jobs {
+			job.NodeID = n.ID
+			job.NodeName = n.Name
+		}
+	}
+	jobs, nodeErrors := fanOut(ctx, a.logger, "jobs", nodes, a.concurrency, a.timeout, fetch, nil, tag)
+
+	sort.SliceStable(jobs, func(i, j int) bool {
+		return jobs[i].CreatedAt.After(jobs[j].CreatedAt)
+	})
+	if limit > 0 && len(jobs) > limit {
+		jobs = jobs[:limit]
+	}
+	return jobs, nodeErrors
+}
@@ -0,0 +1,79 @@
+package routing
+
+import (
+	"context"
+	"log/slog"
+	"sort"
+	"sync"
+
+	"github.com/selfhostly/internal/db"
+	"github.com/selfhostly/internal/domain"
+)
+
+// LogsAggregator aggregates log search results from multiple nodes
+type LogsAggregator struct {
+	router *NodeRouter
+	logger *slog.Logger
+}
+
+// NewLogsAggregator creates a new logs aggregator
+func NewLogsAggregator(router *NodeRouter, logger *slog.Logger) *LogsAggregator {
+	return &LogsAggregator{
+		router: router,
+		logger: logger,
+	}
+}
+
+// AggregateSearch runs a log search on multiple nodes in parallel and merges the matches
+// newest first. Nodes that fail are logged and skipped so one bad node doesn't hide the rest.
+func (a *LogsAggregator) AggregateSearch(
+	ctx context.Context,
+	nodes []*db.Node,
+	localSearcher func() ([]*domain.LogSearchMatch, error),
+	remoteSearcher func(*db.Node) ([]*domain.LogSearchMatch, error),
+) ([]*domain.LogSearchMatch, error) {
+	var (
+		allMatches []*domain.LogSearchMatch
+		mu         sync.Mutex
+		wg         sync.WaitGroup
+	)
+
+	for _, node := range nodes {
+		wg.Add(1)
+		go func(n *db.Node) {
+			defer wg.Done()
+
+			var (
+				matches []*domain.LogSearchMatch
+				err     error
+			)
+			if n.ID == a.router.localNodeID {
+				matches, err = localSearcher()
+			} else {
+				matches, err = remoteSearcher(n)
+			}
+			if err != nil {
+				a.logger.WarnContext(ctx, "failed to search logs on node", "nodeID", n.ID, "nodeName", n.Name, "error", err)
+				return
+			}
+
+			// Tag matches with the node they came from
+			for _, m := range matches {
+				m.NodeID = n.ID
+				m.NodeName = n.Name
+			}
+
+			mu.Lock()
+			allMatches = append(allMatches, matches...)
+			mu.Unlock()
+		}(node)
+	}
+
+	wg.Wait()
+
+	sort.SliceStable(allMatches, func(i, j int) bool {
+		return allMatches[i].Timestamp.After(allMatches[j].Timestamp)
+	})
+
+	return allMatches, nil
+}
@@ -65,8 +65,8 @@ func (r *NodeRouter) DetermineTargetNodes(ctx context.Context, nodeIDs []string)
 			continue
 		}
 
-		// Skip offline and unreachable nodes for remote requests
-		if node.Status == constants.NodeStatusOffline || node.Status == constants.NodeStatusUnreachable {
+		// Skip offline, unreachable and lost nodes for remote requests
+		if node.Status == constants.NodeStatusOffline || node.Status == constants.NodeStatusUnreachable || node.Status == constants.NodeStatusLost {
 			r.logger.DebugContext(ctx, "skipping offline/unreachable node for request forwarding",
 				"nodeID", node.ID,
 				"nodeName", node.Name,
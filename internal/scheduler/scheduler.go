@@ -12,30 +12,34 @@ import (
 	"github.com/selfhostly/internal/domain"
 )
 
-// Scheduler manages application schedules using cron expressions
+// Scheduler manages application schedules and recurring tasks using cron expressions
 type Scheduler struct {
-	cron       *cron.Cron
-	db         *db.DB
-	logger     *slog.Logger
-	mu         sync.RWMutex
-	schedules  map[string]*db.AppSchedule
-	entries    map[string]*scheduleEntry
-	appService domain.AppService
+	cron        *cron.Cron
+	db          *db.DB
+	logger      *slog.Logger
+	mu          sync.RWMutex
+	schedules   map[string]*db.AppSchedule
+	entries     map[string]*scheduleEntry
+	taskEntries map[string]cron.EntryID // By task ID
+	appService  domain.AppService
+	taskService domain.TaskService
 }
 
 // NewScheduler creates a new scheduler instance
-func NewScheduler(database *db.DB, appService domain.AppService, logger *slog.Logger) *Scheduler {
+func NewScheduler(database *db.DB, appService domain.AppService, taskService domain.TaskService, logger *slog.Logger) *Scheduler {
 	c := cron.New(cron.WithSeconds(), cron.WithParser(cron.NewParser(
 		cron.SecondOptional | cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor,
 	)))
 
 	return &Scheduler{
-		cron:       c,
-		db:         database,
-		logger:     logger,
-		schedules:  make(map[string]*db.AppSchedule),
-		entries:    make(map[string]*scheduleEntry),
-		appService: appService,
+		cron:        c,
+		db:          database,
+		logger:      logger,
+		schedules:   make(map[string]*db.AppSchedule),
+		entries:     make(map[string]*scheduleEntry),
+		taskEntries: make(map[string]cron.EntryID),
+		appService:  appService,
+		taskService: taskService,
 	}
 }
 
@@ -47,6 +51,9 @@ func (s *Scheduler) Start(ctx context.Context) error {
 	if err := s.loadSchedules(); err != nil {
 		return err
 	}
+	if err := s.loadTasks(); err != nil {
+		return err
+	}
 	
 	// Start the cron scheduler
 	s.cron.Start()
@@ -64,7 +71,7 @@ func (s *Scheduler) Start(ctx context.Context) error {
 		}
 	}()
 	
-	s.logger.Info("Scheduler started", "active_schedules", len(s.schedules))
+	s.logger.Info("Scheduler started", "active_schedules", len(s.schedules), "active_tasks", len(s.taskEntries))
 	return nil
 }
 
@@ -189,6 +196,9 @@ func (s *Scheduler) createStartHandler(appID string) func() {
 	return func() {
 		ctx := context.Background()
 		s.logger.Info("Scheduled start triggered", "app_id", appID)
+		if s.operationsLocked("start", appID) {
+			return
+		}
 
 		if err := s.appService.CreateStartJob(ctx, appID); err != nil {
 			s.logger.Error("Failed to create start job", "app_id", appID, "error", err)
@@ -201,9 +211,104 @@ func (s *Scheduler) createStopHandler(appID string) func() {
 	return func() {
 		ctx := context.Background()
 		s.logger.Info("Scheduled stop triggered", "app_id", appID)
+		if s.operationsLocked("stop", appID) {
+			return
+		}
 
 		if err := s.appService.CreateStopJob(ctx, appID); err != nil {
 			s.logger.Error("Failed to create stop job", "app_id", appID, "error", err)
 		}
 	}
 }
+
+// loadTasks loads all enabled recurring tasks from the database
+func (s *Scheduler) loadTasks() error {
+	tasks, err := s.db.GetEnabledTasks()
+	if err != nil {
+		return err
+	}
+
+	for _, task := range tasks {
+		if err := s.UpdateTask(task); err != nil {
+			s.logger.Error("Failed to load task", "app_id", task.AppID, "task_id", task.ID, "error", err)
+		}
+	}
+
+	return nil
+}
+
+// UpdateTask schedules a recurring task, replacing its previous schedule; disabled tasks are only
+// removed
+func (s *Scheduler) UpdateTask(task *db.AppTask) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.removeTaskUnsafe(task.ID)
+	if !task.Enabled {
+		return nil
+	}
+
+	entryID, err := s.cron.AddFunc(formatCronWithTimezone(task.Cron, task.Timezone), s.createTaskHandler(task.ID))
+	if err != nil {
+		return fmt.Errorf("failed to add task schedule: %w", err)
+	}
+	s.taskEntries[task.ID] = entryID
+
+	s.logger.Info("Added task schedule",
+		"app_id", task.AppID,
+		"task_id", task.ID,
+		"cron", task.Cron,
+		"timezone", task.Timezone)
+	return nil
+}
+
+// RemoveTask unschedules a recurring task
+func (s *Scheduler) RemoveTask(taskID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.removeTaskUnsafe(taskID)
+}
+
+// removeTaskUnsafe unschedules a task without locking (caller must hold lock)
+func (s *Scheduler) removeTaskUnsafe(taskID string) {
+	if entryID, exists := s.taskEntries[taskID]; exists {
+		s.cron.Remove(entryID)
+		delete(s.taskEntries, taskID)
+		s.logger.Info("Removed task schedule", "task_id", taskID)
+	}
+}
+
+// createTaskHandler creates a handler function for a recurring task. A task deleted along with
+// its app is unscheduled the next time it fires.
+func (s *Scheduler) createTaskHandler(taskID string) func() {
+	return func() {
+		ctx := context.Background()
+		s.logger.Info("Scheduled task triggered", "task_id", taskID)
+		if s.operationsLocked("task", taskID) {
+			return
+		}
+
+		if err := s.taskService.CreateTaskJob(ctx, taskID); err != nil {
+			if domain.IsNotFoundError(err) {
+				s.RemoveTask(taskID)
+				return
+			}
+			s.logger.Error("Failed to create task job", "task_id", taskID, "error", err)
+		}
+	}
+}
+
+// operationsLocked reports whether an admin has locked operations, in which case a scheduled run
+// is skipped rather than held until the lock is lifted
+func (s *Scheduler) operationsLocked(run, id string) bool {
+	lock, err := s.db.GetOperationsLock()
+	if err != nil {
+		s.logger.Error("Failed to check operations lock, skipping scheduled run", "run", run, "id", id, "error", err)
+		return true
+	}
+	if lock != nil {
+		s.logger.Warn("Operations locked, skipping scheduled run", "run", run, "id", id, "reason", lock.Reason)
+		return true
+	}
+	return false
+}
@@ -0,0 +1,118 @@
+// Package secrets generates random passwords and keys for app configuration
+package secrets
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"math/big"
+
+	"github.com/google/uuid"
+)
+
+// Character sets a raw secret can be drawn from
+const (
+	CharsetAlphanumeric = "alphanumeric"
+	CharsetAlpha        = "alpha"
+	CharsetNumeric      = "numeric"
+	CharsetHex          = "hex"
+	CharsetURLSafe      = "urlsafe" // Alphanumeric plus - _ . ~, safe in URLs and connection strings
+)
+
+// Output formats
+const (
+	FormatRaw    = "raw"    // Length characters from Charset
+	FormatBase64 = "base64" // Length random bytes, URL-safe base64 without padding
+	FormatUUID   = "uuid"   // A random (version 4) UUID; Length and Charset are ignored
+)
+
+const (
+	DefaultLength = 32
+	MaxLength     = 1024
+)
+
+var charsets = map[string]string{
+	CharsetAlphanumeric: "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789",
+	CharsetAlpha:        "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ",
+	CharsetNumeric:      "0123456789",
+	CharsetHex:          "0123456789abcdef",
+	CharsetURLSafe:      "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789-_.~",
+}
+
+// Options controls Generate. Zero values mean a 32 character alphanumeric raw secret.
+type Options struct {
+	Length  int
+	Charset string
+	Format  string
+}
+
+// Normalize fills in defaults and rejects options Generate can't honour
+func (o Options) Normalize() (Options, error) {
+	if o.Length == 0 {
+		o.Length = DefaultLength
+	}
+	if o.Charset == "" {
+		o.Charset = CharsetAlphanumeric
+	}
+	if o.Format == "" {
+		o.Format = FormatRaw
+	}
+	if o.Length < 1 || o.Length > MaxLength {
+		return o, fmt.Errorf("length must be between 1 and %d, got %d", MaxLength, o.Length)
+	}
+	if _, ok := charsets[o.Charset]; !ok {
+		return o, fmt.Errorf("unknown charset %q (use %s, %s, %s, %s or %s)", o.Charset,
+			CharsetAlphanumeric, CharsetAlpha, CharsetNumeric, CharsetHex, CharsetURLSafe)
+	}
+	switch o.Format {
+	case FormatRaw, FormatBase64, FormatUUID:
+	default:
+		return o, fmt.Errorf("unknown format %q (use %s, %s or %s)", o.Format, FormatRaw, FormatBase64, FormatUUID)
+	}
+	return o, nil
+}
+
+// Generate returns a new secret from crypto/rand
+func Generate(opts Options) (string, error) {
+	opts, err := opts.Normalize()
+	if err != nil {
+		return "", err
+	}
+	switch opts.Format {
+	case FormatBase64:
+		buf := make([]byte, opts.Length)
+		if _, err := rand.Read(buf); err != nil {
+			return "", fmt.Errorf("failed to generate secret: %w", err)
+		}
+		return base64.RawURLEncoding.EncodeToString(buf), nil
+	case FormatUUID:
+		id, err := uuid.NewRandom()
+		if err != nil {
+			return "", fmt.Errorf("failed to generate secret: %w", err)
+		}
+		return id.String(), nil
+	default:
+		return String(opts.Length, opts.Charset)
+	}
+}
+
+// String returns n characters picked uniformly from the named charset
+func String(n int, charset string) (string, error) {
+	alphabet, ok := charsets[charset]
+	if !ok {
+		return "", fmt.Errorf("unknown charset %q", charset)
+	}
+	if n < 1 || n > MaxLength {
+		return "", fmt.Errorf("length must be between 1 and %d, got %d", MaxLength, n)
+	}
+	size := big.NewInt(int64(len(alphabet)))
+	out := make([]byte, n)
+	for i := range out {
+		idx, err := rand.Int(rand.Reader, size)
+		if err != nil {
+			return "", fmt.Errorf("failed to generate secret: %w", err)
+		}
+		out[i] = alphabet[idx.Int64()]
+	}
+	return string(out), nil
+}
@@ -0,0 +1,61 @@
+package secrets
+
+import (
+	"encoding/base64"
+	"regexp"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestGenerate(t *testing.T) {
+	secret, err := Generate(Options{})
+	if err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+	if !regexp.MustCompile(`^[A-Za-z0-9]{32}$`).MatchString(secret) {
+		t.Errorf("default secret = %q, want 32 alphanumeric characters", secret)
+	}
+
+	other, _ := Generate(Options{})
+	if other == secret {
+		t.Error("expected two generated secrets to differ")
+	}
+
+	hex, err := Generate(Options{Length: 16, Charset: CharsetHex})
+	if err != nil || !regexp.MustCompile(`^[0-9a-f]{16}$`).MatchString(hex) {
+		t.Errorf("hex secret = %q, %v", hex, err)
+	}
+
+	encoded, err := Generate(Options{Length: 24, Format: FormatBase64})
+	if err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+	if raw, err := base64.RawURLEncoding.DecodeString(encoded); err != nil || len(raw) != 24 {
+		t.Errorf("base64 secret %q decodes to %d bytes, %v; want 24", encoded, len(raw), err)
+	}
+
+	id, err := Generate(Options{Format: FormatUUID})
+	if err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+	if _, err := uuid.Parse(id); err != nil {
+		t.Errorf("uuid secret %q does not parse: %v", id, err)
+	}
+}
+
+func TestGenerate_InvalidOptions(t *testing.T) {
+	tests := map[string]Options{
+		"negative length": {Length: -1},
+		"too long":        {Length: MaxLength + 1},
+		"unknown charset": {Charset: "emoji"},
+		"unknown format":  {Format: "pem"},
+	}
+	for name, opts := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := Generate(opts); err == nil {
+				t.Errorf("expected an error for %+v", opts)
+			}
+		})
+	}
+}
@@ -0,0 +1,227 @@
+// Package secretstore resolves references to secrets kept in an external store (HashiCorp Vault,
+// 1Password Connect or a SOPS encrypted file) when an app's .env is written, so only the
+// reference is ever stored in the database
+package secretstore
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// Reference schemes, e.g. vault://secret/myapp#password
+const (
+	SchemeVault       = "vault"
+	SchemeOnePassword = "op"
+	SchemeSOPS        = "sops"
+)
+
+// Config locates the stores references are resolved against. The env var names are the ones the
+// stores' own CLIs read.
+type Config struct {
+	VaultAddr      string // VAULT_ADDR
+	VaultToken     string // VAULT_TOKEN
+	VaultNamespace string // VAULT_NAMESPACE, Vault Enterprise only
+
+	OnePasswordHost  string // OP_CONNECT_HOST, the 1Password Connect server
+	OnePasswordToken string // OP_CONNECT_TOKEN
+
+	SOPSDir    string // SOPS_SECRETS_DIR; sops:// paths are relative to it
+	SOPSBinary string // SOPS_BINARY, sops from PATH by default
+}
+
+// LoadFromEnv reads the store configuration from the environment
+func LoadFromEnv() Config {
+	return Config{
+		VaultAddr:        strings.TrimRight(os.Getenv("VAULT_ADDR"), "/"),
+		VaultToken:       os.Getenv("VAULT_TOKEN"),
+		VaultNamespace:   os.Getenv("VAULT_NAMESPACE"),
+		OnePasswordHost:  strings.TrimRight(os.Getenv("OP_CONNECT_HOST"), "/"),
+		OnePasswordToken: os.Getenv("OP_CONNECT_TOKEN"),
+		SOPSDir:          getEnv("SOPS_SECRETS_DIR", "./secrets"),
+		SOPSBinary:       getEnv("SOPS_BINARY", "sops"),
+	}
+}
+
+// Ref is a parsed secret reference
+type Ref struct {
+	Scheme string
+	Path   string // Vault: mount/path, 1Password: vault/item[/section], SOPS: file
+	Key    string // Vault and SOPS: the key after #, 1Password: the field
+}
+
+func (r Ref) String() string {
+	if r.Scheme == SchemeOnePassword {
+		return r.Scheme + "://" + r.Path + "/" + r.Key
+	}
+	return r.Scheme + "://" + r.Path + "#" + r.Key
+}
+
+// IsRef reports whether value is meant as a secret reference, i.e. starts with a known scheme
+func IsRef(value string) bool {
+	scheme, _, found := strings.Cut(value, "://")
+	return found && (scheme == SchemeVault || scheme == SchemeOnePassword || scheme == SchemeSOPS)
+}
+
+// ParseRef parses vault://<mount>/<path>#<key>, op://<vault>/<item>[/<section>]/<field> or
+// sops://<file>#<key>, where a SOPS key may be nested with dots (db.password)
+func ParseRef(value string) (Ref, error) {
+	scheme, rest, found := strings.Cut(value, "://")
+	if !found || !IsRef(value) {
+		return Ref{}, fmt.Errorf("%q is not a secret reference (use vault://, op:// or sops://)", value)
+	}
+	ref := Ref{Scheme: scheme}
+	switch scheme {
+	case SchemeOnePassword:
+		parts := strings.Split(rest, "/")
+		if len(parts) < 3 || len(parts) > 4 || containsEmpty(parts) {
+			return Ref{}, fmt.Errorf("%q: expected op://<vault>/<item>/<field>", value)
+		}
+		ref.Path = strings.Join(parts[:len(parts)-1], "/")
+		ref.Key = parts[len(parts)-1]
+	default:
+		path, key, _ := strings.Cut(rest, "#")
+		if path == "" || key == "" {
+			return Ref{}, fmt.Errorf("%q: expected %s://<path>#<key>", value, scheme)
+		}
+		if scheme == SchemeVault && !strings.Contains(strings.Trim(path, "/"), "/") {
+			return Ref{}, fmt.Errorf("%q: expected vault://<mount>/<path>#<key>", value)
+		}
+		ref.Path = path
+		if scheme == SchemeVault {
+			ref.Path = strings.Trim(path, "/")
+		}
+		ref.Key = key
+	}
+	return ref, nil
+}
+
+func containsEmpty(parts []string) bool {
+	for _, part := range parts {
+		if part == "" {
+			return true
+		}
+	}
+	return false
+}
+
+// Resolver fetches the secrets references point at
+type Resolver struct {
+	config     Config
+	httpClient *http.Client
+	runSOPS    func(ctx context.Context, binary string, args ...string) ([]byte, error)
+}
+
+// New creates a resolver for the given stores
+func New(cfg Config) *Resolver {
+	return &Resolver{
+		config:     cfg,
+		httpClient: &http.Client{Timeout: 15 * time.Second},
+		runSOPS:    runCommand,
+	}
+}
+
+// Resolve returns the secret ref points at
+func (r *Resolver) Resolve(ctx context.Context, ref Ref) (string, error) {
+	var value string
+	var err error
+	switch ref.Scheme {
+	case SchemeVault:
+		value, err = r.resolveVault(ctx, ref)
+	case SchemeOnePassword:
+		value, err = r.resolveOnePassword(ctx, ref)
+	case SchemeSOPS:
+		value, err = r.resolveSOPS(ctx, ref)
+	default:
+		err = fmt.Errorf("unknown secret store %q", ref.Scheme)
+	}
+	if err != nil {
+		return "", fmt.Errorf("%s: %w", ref, err)
+	}
+	return value, nil
+}
+
+// ResolveEnv replaces every value of a .env that is a secret reference with the secret, quoted
+// as docker compose needs it. Lines are read as compose reads them: an `export ` prefix is
+// allowed, and references may be quoted or followed by a comment. Content without references is
+// returned as is.
+func (r *Resolver) ResolveEnv(ctx context.Context, content string) (string, error) {
+	lines := strings.Split(content, "\n")
+	resolved := make(map[string]string)
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+			continue
+		}
+		var prefix string
+		if rest, ok := strings.CutPrefix(trimmed, "export "); ok {
+			prefix, trimmed = "export ", strings.TrimSpace(rest)
+		}
+		key, value, found := strings.Cut(trimmed, "=")
+		if !found {
+			continue
+		}
+		key, value = strings.TrimSpace(key), envValue(value)
+		if !IsRef(value) {
+			continue
+		}
+		ref, err := ParseRef(value)
+		if err != nil {
+			return "", fmt.Errorf("line %d: %w", i+1, err)
+		}
+		secret, ok := resolved[value]
+		if !ok {
+			if secret, err = r.Resolve(ctx, ref); err != nil {
+				return "", fmt.Errorf("line %d: %w", i+1, err)
+			}
+			resolved[value] = secret
+		}
+		quoted, err := quoteEnvValue(secret)
+		if err != nil {
+			return "", fmt.Errorf("line %d: %s: %w", i+1, ref, err)
+		}
+		lines[i] = prefix + key + "=" + quoted
+	}
+	return strings.Join(lines, "\n"), nil
+}
+
+// envValue returns the value of a .env line as written after the `=`, without the quotes around
+// it or, when it isn't quoted, a trailing comment
+func envValue(raw string) string {
+	value := strings.TrimSpace(raw)
+	if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') {
+		if end := strings.IndexByte(value[1:], value[0]); end >= 0 {
+			return value[1 : end+1]
+		}
+	}
+	if i := strings.Index(value, " #"); i >= 0 {
+		value = strings.TrimSpace(value[:i])
+	}
+	return value
+}
+
+// quoteEnvValue quotes a secret for a .env line when docker compose would otherwise cut it short
+// or interpolate it. Single quotes keep everything literal.
+func quoteEnvValue(value string) (string, error) {
+	if strings.ContainsAny(value, "\r\n") {
+		return "", fmt.Errorf("secret spans more than one line")
+	}
+	if !strings.ContainsAny(value, " \t#$'\"\\`") {
+		return value, nil
+	}
+	if !strings.Contains(value, "'") {
+		return "'" + value + "'", nil
+	}
+	replacer := strings.NewReplacer(`\`, `\\`, `"`, `\"`, `$`, `$$`)
+	return `"` + replacer.Replace(value) + `"`, nil
+}
+
+func getEnv(key, defaultValue string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return defaultValue
+}
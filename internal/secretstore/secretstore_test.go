@@ -0,0 +1,152 @@
+package secretstore
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseRef(t *testing.T) {
+	valid := map[string]Ref{
+		"vault://secret/myapp#password":          {Scheme: SchemeVault, Path: "secret/myapp", Key: "password"},
+		"op://Homelab/Postgres/password":         {Scheme: SchemeOnePassword, Path: "Homelab/Postgres", Key: "password"},
+		"op://Homelab/Postgres/admin/password":   {Scheme: SchemeOnePassword, Path: "Homelab/Postgres/admin", Key: "password"},
+		"sops://myapp.enc.yaml#db.password":      {Scheme: SchemeSOPS, Path: "myapp.enc.yaml", Key: "db.password"},
+		"vault://kv/team/apps/nextcloud#api_key": {Scheme: SchemeVault, Path: "kv/team/apps/nextcloud", Key: "api_key"},
+	}
+	for in, want := range valid {
+		got, err := ParseRef(in)
+		if err != nil || got != want {
+			t.Errorf("ParseRef(%q) = %+v, %v; want %+v", in, got, err, want)
+		}
+		if got.String() != in {
+			t.Errorf("Ref %+v prints as %q, want %q", got, got.String(), in)
+		}
+	}
+
+	for _, in := range []string{"vault://secret#password", "vault://secret/myapp", "op://Homelab/password", "sops://#key", "https://example.com"} {
+		if _, err := ParseRef(in); err == nil {
+			t.Errorf("expected ParseRef(%q) to fail", in)
+		}
+	}
+}
+
+func TestResolveEnv_Vault(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/secret/data/myapp" || r.Header.Get("X-Vault-Token") != "root" {
+			http.Error(w, `{"errors":["permission denied"]}`, http.StatusForbidden)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"data": map[string]interface{}{"data": map[string]interface{}{"password": "p4ss word", "port": 5432}},
+		})
+	}))
+	defer server.Close()
+	resolver := New(Config{VaultAddr: server.URL, VaultToken: "root"})
+
+	env := "# Database\nDB_PASSWORD=vault://secret/myapp#password\nDB_PORT=vault://secret/myapp#port\nDB_USER=app\n"
+	got, err := resolver.ResolveEnv(context.Background(), env)
+	if err != nil {
+		t.Fatalf("ResolveEnv: %v", err)
+	}
+	if want := "# Database\nDB_PASSWORD='p4ss word'\nDB_PORT=5432\nDB_USER=app\n"; got != want {
+		t.Errorf("ResolveEnv = %q, want %q", got, want)
+	}
+
+	// Exported, quoted and commented references are read as compose reads them
+	env = "export DB_PASSWORD=\"vault://secret/myapp#password\"\nDB_PORT = 'vault://secret/myapp#port' # Postgres\nDB_HOST=vault.example.com #vault://x\n"
+	got, err = resolver.ResolveEnv(context.Background(), env)
+	if err != nil {
+		t.Fatalf("ResolveEnv: %v", err)
+	}
+	if want := "export DB_PASSWORD='p4ss word'\nDB_PORT=5432\nDB_HOST=vault.example.com #vault://x\n"; got != want {
+		t.Errorf("ResolveEnv = %q, want %q", got, want)
+	}
+
+	if _, err := resolver.ResolveEnv(context.Background(), "X=vault://secret/myapp#missing"); err == nil || !strings.Contains(err.Error(), "missing") {
+		t.Errorf("expected a missing key to fail, got %v", err)
+	}
+	if _, err := New(Config{}).ResolveEnv(context.Background(), "X=vault://secret/myapp#password"); err == nil || !strings.Contains(err.Error(), "VAULT_ADDR") {
+		t.Errorf("expected an unconfigured store to fail, got %v", err)
+	}
+}
+
+func TestResolveEnv_OnePassword(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/v1/vaults":
+			_ = json.NewEncoder(w).Encode([]map[string]string{{"id": "vault-id"}})
+		case "/v1/vaults/vault-id/items":
+			if r.URL.Query().Get("filter") != `title eq "Postgres"` {
+				_, _ = w.Write([]byte("[]"))
+				return
+			}
+			_ = json.NewEncoder(w).Encode([]map[string]string{{"id": "item-id"}})
+		case "/v1/vaults/vault-id/items/item-id":
+			_, _ = w.Write([]byte(`{"sections":[{"id":"s1","label":"admin"}],"fields":[
+				{"id":"password","label":"password","value":"user-pass"},
+				{"id":"f2","label":"password","value":"admin-pass","section":{"id":"s1"}}]}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+	resolver := New(Config{OnePasswordHost: server.URL, OnePasswordToken: "token"})
+
+	got, err := resolver.ResolveEnv(context.Background(), "USER_PASSWORD=op://Homelab/Postgres/password\nADMIN_PASSWORD=op://Homelab/Postgres/admin/password")
+	if err != nil {
+		t.Fatalf("ResolveEnv: %v", err)
+	}
+	if want := "USER_PASSWORD=user-pass\nADMIN_PASSWORD=admin-pass"; got != want {
+		t.Errorf("ResolveEnv = %q, want %q", got, want)
+	}
+}
+
+func TestResolveEnv_SOPS(t *testing.T) {
+	resolver := New(Config{SOPSDir: "/etc/selfhostly/secrets", SOPSBinary: "sops"})
+	var gotArgs []string
+	resolver.runSOPS = func(ctx context.Context, binary string, args ...string) ([]byte, error) {
+		gotArgs = args
+		return []byte("it's-secret\n"), nil
+	}
+
+	got, err := resolver.ResolveEnv(context.Background(), "API_KEY=sops://myapp.enc.yaml#api.key")
+	if err != nil {
+		t.Fatalf("ResolveEnv: %v", err)
+	}
+	if want := `API_KEY="it's-secret"`; got != want {
+		t.Errorf("ResolveEnv = %q, want %q", got, want)
+	}
+	want := []string{"--decrypt", "--extract", `["api"]["key"]`, filepath.Join("/etc/selfhostly/secrets", "myapp.enc.yaml")}
+	if strings.Join(gotArgs, " ") != strings.Join(want, " ") {
+		t.Errorf("sops called with %q, want %q", gotArgs, want)
+	}
+
+	if _, err := resolver.ResolveEnv(context.Background(), "API_KEY=sops://../../etc/shadow#root"); err == nil {
+		t.Error("expected a path leaving SOPS_SECRETS_DIR to be refused")
+	}
+}
+
+func TestQuoteEnvValue(t *testing.T) {
+	for in, want := range map[string]string{
+		"plain":     "plain",
+		"has space": "'has space'",
+		"$HOME":     "'$HOME'",
+		`it's "$x"`: `"it's \"$$x\""`,
+	} {
+		if got, err := quoteEnvValue(in); err != nil || got != want {
+			t.Errorf("quoteEnvValue(%q) = %q, %v; want %q", in, got, err, want)
+		}
+	}
+	if _, err := quoteEnvValue("two\nlines"); err == nil {
+		t.Error("expected a multi-line secret to be refused")
+	}
+}
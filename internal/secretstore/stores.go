@@ -0,0 +1,176 @@
+package secretstore
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// resolveVault reads a key of a Vault KV version 2 secret: vault://<mount>/<path>#<key>
+func (r *Resolver) resolveVault(ctx context.Context, ref Ref) (string, error) {
+	if r.config.VaultAddr == "" || r.config.VaultToken == "" {
+		return "", errors.New("VAULT_ADDR and VAULT_TOKEN must be set on this node")
+	}
+	mount, secretPath, _ := strings.Cut(ref.Path, "/")
+	headers := map[string]string{"X-Vault-Token": r.config.VaultToken}
+	if r.config.VaultNamespace != "" {
+		headers["X-Vault-Namespace"] = r.config.VaultNamespace
+	}
+
+	var secret struct {
+		Data struct {
+			Data map[string]interface{} `json:"data"`
+		} `json:"data"`
+	}
+	if err := r.getJSON(ctx, r.config.VaultAddr+"/v1/"+mount+"/data/"+secretPath, headers, &secret); err != nil {
+		return "", err
+	}
+	value, ok := secret.Data.Data[ref.Key]
+	if !ok || value == nil {
+		return "", fmt.Errorf("secret has no key %q", ref.Key)
+	}
+	if s, ok := value.(string); ok {
+		return s, nil
+	}
+	return fmt.Sprint(value), nil
+}
+
+// onePasswordItem is the part of a 1Password Connect item a field is looked up in
+type onePasswordItem struct {
+	Fields []struct {
+		ID      string `json:"id"`
+		Label   string `json:"label"`
+		Value   string `json:"value"`
+		Section *struct {
+			ID string `json:"id"`
+		} `json:"section"`
+	} `json:"fields"`
+	Sections []struct {
+		ID    string `json:"id"`
+		Label string `json:"label"`
+	} `json:"sections"`
+}
+
+// resolveOnePassword reads a field of an item through 1Password Connect:
+// op://<vault>/<item>[/<section>]/<field>, each named by title or ID as in the op CLI
+func (r *Resolver) resolveOnePassword(ctx context.Context, ref Ref) (string, error) {
+	if r.config.OnePasswordHost == "" || r.config.OnePasswordToken == "" {
+		return "", errors.New("OP_CONNECT_HOST and OP_CONNECT_TOKEN must be set on this node")
+	}
+	parts := strings.Split(ref.Path, "/")
+	headers := map[string]string{"Authorization": "Bearer " + r.config.OnePasswordToken}
+
+	vaultID, err := r.onePasswordID(ctx, "/v1/vaults", "name", parts[0], headers)
+	if err != nil {
+		return "", err
+	}
+	itemsPath := "/v1/vaults/" + url.PathEscape(vaultID) + "/items"
+	itemID, err := r.onePasswordID(ctx, itemsPath, "title", parts[1], headers)
+	if err != nil {
+		return "", err
+	}
+	var item onePasswordItem
+	if err := r.getJSON(ctx, r.config.OnePasswordHost+itemsPath+"/"+url.PathEscape(itemID), headers, &item); err != nil {
+		return "", err
+	}
+
+	sectionID := ""
+	if len(parts) == 3 {
+		sectionID = parts[2]
+		for _, section := range item.Sections {
+			if section.Label == parts[2] {
+				sectionID = section.ID
+				break
+			}
+		}
+	}
+	for _, field := range item.Fields {
+		if field.Label != ref.Key && field.ID != ref.Key {
+			continue
+		}
+		if sectionID != "" && (field.Section == nil || field.Section.ID != sectionID) {
+			continue
+		}
+		return field.Value, nil
+	}
+	return "", fmt.Errorf("item has no field %q", ref.Key)
+}
+
+// onePasswordID looks up a vault or item ID by its name, taking name as the ID when nothing is
+// called that
+func (r *Resolver) onePasswordID(ctx context.Context, listPath, attribute, name string, headers map[string]string) (string, error) {
+	var matches []struct {
+		ID string `json:"id"`
+	}
+	query := url.Values{"filter": {fmt.Sprintf("%s eq %q", attribute, name)}}
+	if err := r.getJSON(ctx, r.config.OnePasswordHost+listPath+"?"+query.Encode(), headers, &matches); err != nil {
+		return "", err
+	}
+	if len(matches) == 0 {
+		return name, nil
+	}
+	return matches[0].ID, nil
+}
+
+// getJSON GETs a store's API and decodes the response into out
+func (r *Resolver) getJSON(ctx context.Context, rawURL string, headers map[string]string, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, rawURL, nil)
+	if err != nil {
+		return err
+	}
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	resp, err := r.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("request failed: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("store returned %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
+
+// resolveSOPS decrypts a key of a SOPS file under SOPSDir with the sops binary, which finds its
+// age, PGP or KMS keys in the server's environment: sops://<file>#<key>
+func (r *Resolver) resolveSOPS(ctx context.Context, ref Ref) (string, error) {
+	name := filepath.ToSlash(ref.Path)
+	if path.IsAbs(name) || path.Clean(name) != name || name == ".." || strings.HasPrefix(name, "../") {
+		return "", fmt.Errorf("file must be a clean path relative to SOPS_SECRETS_DIR")
+	}
+	var extract strings.Builder
+	for _, key := range strings.Split(ref.Key, ".") {
+		fmt.Fprintf(&extract, "[%q]", key)
+	}
+	out, err := r.runSOPS(ctx, r.config.SOPSBinary, "--decrypt", "--extract", extract.String(), filepath.Join(r.config.SOPSDir, name))
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(out), "\n"), nil
+}
+
+func runCommand(ctx context.Context, binary string, args ...string) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, binary, args...)
+	out, err := cmd.Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && len(exitErr.Stderr) > 0 {
+			return nil, fmt.Errorf("%s failed: %s", binary, strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return nil, fmt.Errorf("%s failed: %w", binary, err)
+	}
+	return out, nil
+}
@@ -0,0 +1,69 @@
+// Package seed implements the server's seed subcommand, which fills a database with fake apps and
+// jobs to benchmark and try the panel at scale.
+package seed
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/joho/godotenv"
+	"github.com/selfhostly/internal/config"
+	"github.com/selfhostly/internal/db"
+)
+
+// CommandName is the server's subcommand, e.g. `selfhostly seed --apps=500`
+const CommandName = "seed"
+
+// RunCommand parses seed flags, adds the fake data to the database and returns the process exit
+// code. Like chaos mode it needs APP_ENV set to something other than production. The server may
+// be running meanwhile; its worker picks up the pending jobs.
+func RunCommand(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet(CommandName, flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	envFile := fs.String("env-file", config.EnvFile(), "server env file, read for DATABASE_PATH and APP_ENV")
+	dbPath := fs.String("db", "", "database to seed (default: DATABASE_PATH)")
+	apps := fs.Int("apps", 500, "fake apps to create")
+	jobs := fs.Int("jobs", 5000, "finished jobs to create, spread over the new apps")
+	pending := fs.Int("pending-jobs", 0, "jobs to leave pending for the worker (run with CHAOS_MODE=true to process them)")
+	nodeID := fs.String("node-id", "", "node the apps belong to (default: the primary node)")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *apps < 0 || *jobs < 0 || *pending < 0 {
+		fmt.Fprintln(stderr, "seed: --apps, --jobs and --pending-jobs can't be negative")
+		return 2
+	}
+
+	// The variables already set win, as for the server itself
+	_ = godotenv.Load(*envFile)
+	if getEnv("APP_ENV", "production") == "production" {
+		fmt.Fprintln(stderr, "seed: refusing to add fake data with APP_ENV=production (the default)")
+		return 1
+	}
+	if *dbPath == "" {
+		*dbPath = getEnv("DATABASE_PATH", "./data/selfhostly.db")
+	}
+
+	database, err := db.Init(*dbPath)
+	if err != nil {
+		fmt.Fprintf(stderr, "seed: %v\n", err)
+		return 1
+	}
+	defer database.Close()
+
+	if err := database.SeedFakeData(db.SeedOptions{Apps: *apps, Jobs: *jobs, PendingJobs: *pending, NodeID: *nodeID}); err != nil {
+		fmt.Fprintf(stderr, "seed: %v\n", err)
+		return 1
+	}
+	fmt.Fprintf(stdout, "Added %d apps, %d finished jobs and %d pending jobs to %s\n", *apps, *jobs, *pending, *dbPath)
+	return 0
+}
+
+func getEnv(key, defaultValue string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return defaultValue
+}
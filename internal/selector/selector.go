@@ -0,0 +1,148 @@
+package selector
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/selfhostly/internal/validation"
+)
+
+// Operator is how a requirement compares a label
+type Operator string
+
+const (
+	Equals       Operator = "="
+	NotEquals    Operator = "!="
+	In           Operator = "in"
+	NotIn        Operator = "notin"
+	Exists       Operator = "exists"
+	DoesNotExist Operator = "!"
+)
+
+// Requirement is one comma-separated term of a selector
+type Requirement struct {
+	Key      string
+	Operator Operator
+	Values   []string // One value for Equals and NotEquals, none for Exists and DoesNotExist
+}
+
+// Selector matches label sets against all of its requirements. The zero value matches everything.
+type Selector []Requirement
+
+// Parse reads a Kubernetes-style label selector: comma-separated requirements of the form
+// "key=value", "key==value", "key!=value", "key in (a,b)", "key notin (a,b)", "key" or "!key".
+func Parse(s string) (Selector, error) {
+	var sel Selector
+	terms, err := splitTerms(s)
+	if err != nil {
+		return nil, err
+	}
+	for _, term := range terms {
+		req, err := parseRequirement(term)
+		if err != nil {
+			return nil, err
+		}
+		sel = append(sel, req)
+	}
+	return sel, nil
+}
+
+// Matches reports whether labels satisfy every requirement
+func (sel Selector) Matches(labels map[string]string) bool {
+	for _, req := range sel {
+		if !req.Matches(labels) {
+			return false
+		}
+	}
+	return true
+}
+
+// Matches reports whether labels satisfy the requirement. As in Kubernetes, "!=" and "notin"
+// also match label sets without the key.
+func (r Requirement) Matches(labels map[string]string) bool {
+	value, ok := labels[r.Key]
+	switch r.Operator {
+	case Exists:
+		return ok
+	case DoesNotExist:
+		return !ok
+	case Equals, In:
+		return ok && slices.Contains(r.Values, value)
+	case NotEquals, NotIn:
+		return !ok || !slices.Contains(r.Values, value)
+	}
+	return false
+}
+
+// splitTerms splits on commas outside parentheses, so set values stay with their requirement
+func splitTerms(s string) ([]string, error) {
+	var terms []string
+	depth, start := 0, 0
+	for i, c := range s {
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth < 0 {
+				return nil, fmt.Errorf("unbalanced parentheses in selector")
+			}
+		case ',':
+			if depth == 0 {
+				terms = append(terms, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+	if depth != 0 {
+		return nil, fmt.Errorf("unbalanced parentheses in selector")
+	}
+	terms = append(terms, s[start:])
+
+	if len(terms) == 1 && strings.TrimSpace(terms[0]) == "" {
+		return nil, nil
+	}
+	return terms, nil
+}
+
+func parseRequirement(term string) (Requirement, error) {
+	term = strings.TrimSpace(term)
+	if term == "" {
+		return Requirement{}, fmt.Errorf("empty requirement in selector")
+	}
+
+	var req Requirement
+	switch {
+	case strings.HasPrefix(term, "!") && !strings.Contains(term, "="):
+		req = Requirement{Key: strings.TrimSpace(term[1:]), Operator: DoesNotExist}
+	case strings.Contains(term, "!="):
+		key, value, _ := strings.Cut(term, "!=")
+		req = Requirement{Key: strings.TrimSpace(key), Operator: NotEquals, Values: []string{strings.TrimSpace(value)}}
+	case strings.Contains(term, "="):
+		key, value, _ := strings.Cut(term, "=")
+		value = strings.TrimPrefix(value, "=")
+		req = Requirement{Key: strings.TrimSpace(key), Operator: Equals, Values: []string{strings.TrimSpace(value)}}
+	case strings.Contains(term, "("):
+		fields := strings.Fields(term[:strings.Index(term, "(")])
+		if len(fields) != 2 || (fields[1] != string(In) && fields[1] != string(NotIn)) || !strings.HasSuffix(term, ")") {
+			return Requirement{}, fmt.Errorf("invalid requirement %q: expected \"key in (values)\" or \"key notin (values)\"", term)
+		}
+		req = Requirement{Key: fields[0], Operator: Operator(fields[1])}
+		for _, value := range strings.Split(term[strings.Index(term, "(")+1:len(term)-1], ",") {
+			req.Values = append(req.Values, strings.TrimSpace(value))
+		}
+	default:
+		req = Requirement{Key: term, Operator: Exists}
+	}
+
+	if err := validation.ValidateLabelKey(req.Key); err != nil {
+		return Requirement{}, fmt.Errorf("invalid requirement %q: %w", term, err)
+	}
+	for _, value := range req.Values {
+		if err := validation.ValidateLabelValue(value); err != nil {
+			return Requirement{}, fmt.Errorf("invalid requirement %q: %w", term, err)
+		}
+	}
+	return req, nil
+}
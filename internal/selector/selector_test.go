@@ -0,0 +1,55 @@
+package selector
+
+import "testing"
+
+func TestParseAndMatch(t *testing.T) {
+	labels := map[string]string{"env": "prod", "team": "media", "critical": ""}
+
+	tests := []struct {
+		selector string
+		matches  bool
+	}{
+		{"", true},
+		{"env=prod", true},
+		{"env==prod", true},
+		{"env=prod,team=media", true},
+		{"env=prod, team=infra", false},
+		{"env!=staging", true},
+		{"region!=eu", true},
+		{"env in (prod, staging)", true},
+		{"env notin (prod,staging)", false},
+		{"region notin (eu)", true},
+		{"team in (infra),env=prod", false},
+		{"critical", true},
+		{"region", false},
+		{"!region", true},
+		{"!env", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.selector, func(t *testing.T) {
+			sel, err := Parse(tt.selector)
+			if err != nil {
+				t.Fatalf("Parse: %v", err)
+			}
+			if got := sel.Matches(labels); got != tt.matches {
+				t.Errorf("Expected Matches to be %v, got %v", tt.matches, got)
+			}
+		})
+	}
+}
+
+func TestParse_Invalid(t *testing.T) {
+	for _, s := range []string{
+		"env=prod,",
+		"env in (prod",
+		"env) in (prod",
+		"env within (prod)",
+		"my env=prod",
+		"env=prod!",
+	} {
+		if _, err := Parse(s); err == nil {
+			t.Errorf("Expected an error for %q", s)
+		}
+	}
+}
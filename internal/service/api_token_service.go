@@ -0,0 +1,178 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/selfhostly/internal/config"
+	"github.com/selfhostly/internal/constants"
+	"github.com/selfhostly/internal/db"
+	"github.com/selfhostly/internal/domain"
+	"github.com/selfhostly/internal/node"
+)
+
+// errAPITokenRejected is returned for any API token that can't be used, without saying why
+var errAPITokenRejected = errors.New("API token is invalid, expired or revoked")
+
+// apiTokenPrefixLength is how much of a token is kept in the clear to tell tokens apart
+const apiTokenPrefixLength = len(constants.APITokenPrefix) + 6
+
+// apiTokenService manages the personal access tokens scripts and CI call the API with
+type apiTokenService struct {
+	database   *db.DB
+	users      domain.UserService
+	nodeClient *node.Client
+	config     *config.Config
+	logger     *slog.Logger
+}
+
+// NewAPITokenService creates a new API token service
+func NewAPITokenService(database *db.DB, cfg *config.Config, logger *slog.Logger) domain.APITokenService {
+	return &apiTokenService{
+		database:   database,
+		users:      NewUserService(database, cfg, logger),
+		nodeClient: node.NewClientWithTimeouts(cfg.Timeouts),
+		config:     cfg,
+		logger:     logger,
+	}
+}
+
+// ListTokens returns a user's tokens that aren't revoked, expired ones included
+func (s *apiTokenService) ListTokens(ctx context.Context, userName string) ([]*db.APIToken, error) {
+	tokens, err := s.database.GetUserAPITokens(userName)
+	if err != nil {
+		return nil, domain.WrapDatabaseOperation("get API tokens", err)
+	}
+	return tokens, nil
+}
+
+// CreateToken creates a token for a user; only its hash is stored
+func (s *apiTokenService) CreateToken(ctx context.Context, userName string, req domain.CreateAPITokenRequest) (*domain.IssuedAPIToken, error) {
+	if err := s.requirePrimary(); err != nil {
+		return nil, err
+	}
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		return nil, domain.WrapValidationError("name", fmt.Errorf("name is required"))
+	}
+	if req.ExpiresInDays < 0 || req.ExpiresInDays > constants.APITokenMaxExpiresInDays {
+		return nil, domain.WrapValidationError("expires_in_days", fmt.Errorf("expires_in_days must be between 0 (never) and %d", constants.APITokenMaxExpiresInDays))
+	}
+	var expiresAt *time.Time
+	if req.ExpiresInDays > 0 {
+		t := time.Now().AddDate(0, 0, req.ExpiresInDays)
+		expiresAt = &t
+	}
+
+	secret, err := newSecretToken()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate API token: %w", err)
+	}
+	token := constants.APITokenPrefix + secret
+	record := db.NewAPIToken(name, userName, hashSecretToken(token), token[:apiTokenPrefixLength], req.ReadOnly, expiresAt)
+	if err := s.database.CreateAPIToken(record); err != nil {
+		return nil, domain.WrapDatabaseOperation("create API token", err)
+	}
+
+	s.logger.InfoContext(ctx, "API token created", "tokenID", record.ID, "user", userName, "name", name, "readOnly", req.ReadOnly)
+	return &domain.IssuedAPIToken{APIToken: record, Token: token}, nil
+}
+
+// UpdateToken renames one of a user's tokens
+func (s *apiTokenService) UpdateToken(ctx context.Context, userName, id string, req domain.UpdateAPITokenRequest) (*db.APIToken, error) {
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		return nil, domain.WrapValidationError("name", fmt.Errorf("name is required"))
+	}
+	if err := s.database.RenameAPIToken(id, userName, name); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, domain.WrapAPITokenNotFound(id, err)
+		}
+		return nil, domain.WrapDatabaseOperation("rename API token", err)
+	}
+	token, err := s.database.GetUserAPIToken(id, userName)
+	if err != nil {
+		return nil, domain.WrapDatabaseOperation("get API token", err)
+	}
+	return token, nil
+}
+
+// RevokeToken revokes one of a user's tokens, so it is refused at once
+func (s *apiTokenService) RevokeToken(ctx context.Context, userName, id string) error {
+	if err := s.database.RevokeAPIToken(id, userName, time.Now()); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return domain.WrapAPITokenNotFound(id, err)
+		}
+		return domain.WrapDatabaseOperation("revoke API token", err)
+	}
+	s.logger.InfoContext(ctx, "API token revoked", "tokenID", id, "user", userName)
+	return nil
+}
+
+// VerifyToken checks a token presented with a request. Its last use is recorded at most once per
+// APITokenTouchInterval, so busy scripts don't write on every request. Tokens are only stored on
+// the primary, so other nodes ask it, and refuse the token when it can't be reached.
+func (s *apiTokenService) VerifyToken(ctx context.Context, token string) (*domain.VerifiedAPIToken, error) {
+	if !strings.HasPrefix(token, constants.APITokenPrefix) {
+		return nil, errAPITokenRejected
+	}
+	if !s.config.Node.IsPrimary {
+		return s.verifyWithPrimary(ctx, token)
+	}
+
+	now := time.Now()
+	record, err := s.database.GetAPITokenByHash(hashSecretToken(token))
+	if err != nil || !record.Active(now) {
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			s.logger.WarnContext(ctx, "failed to get API token", "error", err)
+		}
+		return nil, errAPITokenRejected
+	}
+	role := constants.UserRoleAdmin
+	if s.config.Auth.Enabled {
+		var ok bool
+		if role, ok = s.users.UserRole(ctx, record.UserName); !ok {
+			return nil, errAPITokenRejected
+		}
+	}
+	if record.ReadOnly {
+		role = constants.UserRoleViewer
+	}
+
+	if record.LastUsedAt == nil || now.Sub(*record.LastUsedAt) >= constants.APITokenTouchInterval {
+		if err := s.database.TouchAPIToken(record.ID, now); err != nil {
+			s.logger.WarnContext(ctx, "failed to record API token use", "tokenID", record.ID, "error", err)
+		}
+	}
+	return &domain.VerifiedAPIToken{APIToken: record, Role: role}, nil
+}
+
+// verifyWithPrimary asks the primary to check a token presented to this node
+func (s *apiTokenService) verifyWithPrimary(ctx context.Context, token string) (*domain.VerifiedAPIToken, error) {
+	primaryNode := &db.Node{
+		ID:          s.config.Node.ID,
+		APIEndpoint: s.config.Node.PrimaryNodeURL,
+		APIKey:      s.config.Node.APIKey,
+	}
+	verified, err := s.nodeClient.VerifyAPIToken(primaryNode, token)
+	if err != nil {
+		s.logger.WarnContext(ctx, "failed to check API token with the primary, refusing it", "error", err)
+		return nil, errAPITokenRejected
+	}
+	if verified == nil {
+		return nil, errAPITokenRejected
+	}
+	return verified, nil
+}
+
+func (s *apiTokenService) requirePrimary() error {
+	if !s.config.Node.IsPrimary {
+		return domain.WrapValidationError("api_token", fmt.Errorf("API tokens are issued and checked by the primary node"))
+	}
+	return nil
+}
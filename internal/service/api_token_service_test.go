@@ -0,0 +1,142 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/selfhostly/internal/apipaths"
+	"github.com/selfhostly/internal/config"
+	"github.com/selfhostly/internal/constants"
+	"github.com/selfhostly/internal/db"
+	"github.com/selfhostly/internal/domain"
+)
+
+func setupTestAPITokenService(t *testing.T, isPrimary bool) domain.APITokenService {
+	t.Helper()
+	database, err := db.Init(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to initialize database: %v", err)
+	}
+	t.Cleanup(func() { database.Close() })
+
+	cfg := &config.Config{Node: config.NodeConfig{ID: "primary-node", IsPrimary: isPrimary}}
+	return NewAPITokenService(database, cfg, slog.Default())
+}
+
+func TestAPITokenService_CreateVerifyRevoke(t *testing.T) {
+	svc := setupTestAPITokenService(t, true)
+	ctx := context.Background()
+
+	for name, req := range map[string]domain.CreateAPITokenRequest{
+		"no name":         {Name: " "},
+		"negative expiry": {Name: "ci", ExpiresInDays: -1},
+		"expiry too long": {Name: "ci", ExpiresInDays: constants.APITokenMaxExpiresInDays + 1},
+	} {
+		if _, err := svc.CreateToken(ctx, "alice", req); !domain.IsValidationError(err) {
+			t.Errorf("%s: expected validation error, got %v", name, err)
+		}
+	}
+
+	issued, err := svc.CreateToken(ctx, "alice", domain.CreateAPITokenRequest{Name: "ci", ReadOnly: true, ExpiresInDays: 30})
+	if err != nil {
+		t.Fatalf("CreateToken: %v", err)
+	}
+	if !strings.HasPrefix(issued.Token, constants.APITokenPrefix) || !strings.HasPrefix(issued.Token, issued.TokenPrefix) {
+		t.Errorf("unexpected token %q with prefix %q", issued.Token, issued.TokenPrefix)
+	}
+	if issued.ExpiresAt == nil || !issued.ReadOnly || issued.UserName != "alice" {
+		t.Errorf("unexpected issued token %+v", issued.APIToken)
+	}
+	if strings.Contains(issued.TokenHash, issued.Token) {
+		t.Error("expected the token to be stored hashed")
+	}
+
+	record, err := svc.VerifyToken(ctx, issued.Token)
+	if err != nil || record.ID != issued.ID || record.Role != constants.UserRoleViewer {
+		t.Fatalf("VerifyToken: %+v, %v", record, err)
+	}
+	for _, token := range []string{issued.Token + "0", strings.TrimPrefix(issued.Token, constants.APITokenPrefix), ""} {
+		if _, err := svc.VerifyToken(ctx, token); err == nil {
+			t.Errorf("expected %q to be rejected", token)
+		}
+	}
+
+	// Tokens are per user
+	if tokens, _ := svc.ListTokens(ctx, "bob"); len(tokens) != 0 {
+		t.Errorf("expected bob to have no tokens, got %d", len(tokens))
+	}
+	if err := svc.RevokeToken(ctx, "bob", issued.ID); !domain.IsNotFoundError(err) {
+		t.Errorf("expected bob not to revoke alice's token, got %v", err)
+	}
+
+	renamed, err := svc.UpdateToken(ctx, "alice", issued.ID, domain.UpdateAPITokenRequest{Name: "deploy"})
+	if err != nil || renamed.Name != "deploy" {
+		t.Fatalf("UpdateToken: %+v, %v", renamed, err)
+	}
+	tokens, err := svc.ListTokens(ctx, "alice")
+	if err != nil || len(tokens) != 1 || tokens[0].LastUsedAt == nil || tokens[0].Name != "deploy" {
+		t.Fatalf("expected alice's renamed, used token, got %+v (err %v)", tokens, err)
+	}
+
+	if err := svc.RevokeToken(ctx, "alice", issued.ID); err != nil {
+		t.Fatalf("RevokeToken: %v", err)
+	}
+	if _, err := svc.VerifyToken(ctx, issued.Token); err == nil {
+		t.Error("expected a revoked token to be rejected")
+	}
+	if err := svc.RevokeToken(ctx, "alice", issued.ID); !domain.IsNotFoundError(err) {
+		t.Errorf("expected revoking twice to be not found, got %v", err)
+	}
+	if tokens, _ := svc.ListTokens(ctx, "alice"); len(tokens) != 0 {
+		t.Errorf("expected revoked tokens to be left out, got %d", len(tokens))
+	}
+}
+
+func TestAPITokenService_RequiresPrimary(t *testing.T) {
+	svc := setupTestAPITokenService(t, false)
+	if _, err := svc.CreateToken(context.Background(), "alice", domain.CreateAPITokenRequest{Name: "ci"}); !domain.IsValidationError(err) {
+		t.Errorf("expected secondary nodes to refuse creating tokens, got %v", err)
+	}
+	if _, err := svc.VerifyToken(context.Background(), constants.APITokenPrefix+"abc"); err == nil {
+		t.Error("expected secondary nodes to refuse tokens while the primary can't be reached")
+	}
+}
+
+func TestAPITokenService_SecondaryAsksPrimary(t *testing.T) {
+	good := constants.APITokenPrefix + "good"
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req domain.VerifyAPITokenRequest
+		if r.URL.Path != apipaths.TokenVerify || r.Header.Get("X-Node-ID") != "secondary-node" || json.NewDecoder(r.Body).Decode(&req) != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if req.Token != good {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		json.NewEncoder(w).Encode(domain.VerifiedAPIToken{APIToken: &db.APIToken{ID: "token-1", UserName: "alice"}, Role: constants.UserRoleViewer})
+	}))
+	defer primary.Close()
+
+	database, err := db.Init(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to initialize database: %v", err)
+	}
+	defer database.Close()
+	cfg := &config.Config{Node: config.NodeConfig{ID: "secondary-node", PrimaryNodeURL: primary.URL, APIKey: "secondary-key"}}
+	svc := NewAPITokenService(database, cfg, slog.Default())
+
+	verified, err := svc.VerifyToken(context.Background(), good)
+	if err != nil || verified.ID != "token-1" || verified.UserName != "alice" || verified.Role != constants.UserRoleViewer {
+		t.Fatalf("expected the primary's answer, got %+v, %v", verified, err)
+	}
+	if _, err := svc.VerifyToken(context.Background(), constants.APITokenPrefix+"bad"); err == nil {
+		t.Error("expected a token the primary refuses to be refused")
+	}
+}
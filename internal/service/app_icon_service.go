@@ -0,0 +1,99 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/selfhostly/internal/appicon"
+	"github.com/selfhostly/internal/constants"
+	"github.com/selfhostly/internal/db"
+	"github.com/selfhostly/internal/docker"
+	"github.com/selfhostly/internal/domain"
+)
+
+// appIconService finds app icons so dashboards can tell apps apart. The icon a compose service
+// names with the selfhostly.icon label is used when there is one, else the icon the app's public
+// URL serves. Icons are cached for a week, and failed lookups for an hour.
+type appIconService struct {
+	database   *db.DB
+	httpClient *http.Client
+	logger     *slog.Logger
+}
+
+// NewAppIconService creates a new app icon service
+func NewAppIconService(database *db.DB, logger *slog.Logger) domain.AppIconService {
+	return &appIconService{
+		database:   database,
+		httpClient: appicon.NewClient(constants.AppIconFetchTimeout),
+		logger:     logger,
+	}
+}
+
+// GetAppIcon returns an app's icon, from the cache while it is fresh
+func (s *appIconService) GetAppIcon(ctx context.Context, appID string, refresh bool) (*db.AppIcon, error) {
+	app, err := s.database.GetApp(appID)
+	if err != nil {
+		return nil, domain.WrapAppNotFound(appID, err)
+	}
+	source, fromLabel := docker.ExtractIconURL(app.ComposeContent, app.ComposeFiles), true
+	if source == "" {
+		source, fromLabel = app.PublicURL, false
+	}
+	if source == "" {
+		return nil, domain.WrapAppIconNotFound(appID, "the app has no public URL or "+constants.AppIconLabel+" label")
+	}
+
+	cached, err := s.database.GetAppIcon(appID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return nil, domain.WrapDatabaseOperation("get app icon", err)
+	}
+	if cached != nil && cached.SourceURL == source && !refresh && iconFresh(cached) {
+		if len(cached.Data) == 0 {
+			return nil, domain.WrapAppIconNotFound(appID, cached.Error)
+		}
+		return cached, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, constants.AppIconFetchTimeout)
+	defer cancel()
+	var found *appicon.Icon
+	if fromLabel {
+		found, err = appicon.Fetch(ctx, s.httpClient, source)
+	} else {
+		found, err = appicon.Detect(ctx, s.httpClient, source)
+	}
+
+	icon := &db.AppIcon{AppID: appID, SourceURL: source, FetchedAt: time.Now()}
+	switch {
+	case err == nil:
+		icon.IconURL, icon.ContentType, icon.Data = found.URL, found.ContentType, found.Data
+	case cached != nil && cached.SourceURL == source && len(cached.Data) > 0:
+		// Keep serving the icon we have while the app can't be reached
+		s.logger.DebugContext(ctx, "app icon not refreshed", "app", app.Name, "appID", appID, "error", err)
+		icon.IconURL, icon.ContentType, icon.Data, icon.Error = cached.IconURL, cached.ContentType, cached.Data, err.Error()
+	default:
+		s.logger.DebugContext(ctx, "app icon not found", "app", app.Name, "appID", appID, "source", source, "error", err)
+		icon.Error = err.Error()
+	}
+	if err := s.database.SaveAppIcon(icon); err != nil {
+		s.logger.WarnContext(ctx, "failed to cache app icon", "app", app.Name, "appID", appID, "error", err)
+	}
+
+	if len(icon.Data) == 0 {
+		return nil, domain.WrapAppIconNotFound(appID, icon.Error)
+	}
+	return icon, nil
+}
+
+// iconFresh reports whether a cached icon, or failure to find one, can still be served
+func iconFresh(icon *db.AppIcon) bool {
+	ttl := constants.AppIconCacheTTL
+	if len(icon.Data) == 0 {
+		ttl = constants.AppIconRetryInterval
+	}
+	return time.Since(icon.FetchedAt) < ttl
+}
@@ -0,0 +1,96 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/selfhostly/internal/constants"
+	"github.com/selfhostly/internal/db"
+	"github.com/selfhostly/internal/domain"
+)
+
+func TestAppIconService_GetAppIcon(t *testing.T) {
+	var requests atomic.Int32
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.Write([]byte(`<html><head><link rel="icon" href="/logo.svg"></head></html>`))
+	})
+	mux.HandleFunc("/logo.svg", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/svg+xml")
+		w.Write([]byte(`<svg xmlns="http://www.w3.org/2000/svg"></svg>`))
+	})
+	mux.HandleFunc("/custom.png", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "image/png")
+		w.Write([]byte("\x89PNG\r\n\x1a\n"))
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	database, err := db.Init(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to initialize database: %v", err)
+	}
+	defer database.Close()
+	service := NewAppIconService(database, slog.Default())
+	// The test server listens on loopback, which the icon client refuses
+	service.(*appIconService).httpClient = server.Client()
+	ctx := context.Background()
+
+	app := db.NewApp("web", "", "services:\n  web:\n    image: nginx:latest\n")
+	if err := database.CreateApp(app); err != nil {
+		t.Fatalf("Failed to create app: %v", err)
+	}
+	if _, err := service.GetAppIcon(ctx, app.ID, false); !domain.IsNotFoundError(err) {
+		t.Errorf("Expected an app without a public URL to have no icon, got %v", err)
+	}
+
+	app.PublicURL = server.URL
+	if err := database.UpdateApp(app); err != nil {
+		t.Fatalf("Failed to update app: %v", err)
+	}
+	icon, err := service.GetAppIcon(ctx, app.ID, false)
+	if err != nil {
+		t.Fatalf("GetAppIcon: %v", err)
+	}
+	if icon.ContentType != "image/svg+xml" || icon.IconURL != server.URL+"/logo.svg" {
+		t.Errorf("Unexpected icon %+v", icon)
+	}
+
+	// Served from the cache until refreshed
+	service.GetAppIcon(ctx, app.ID, false)
+	if requests.Load() != 1 {
+		t.Errorf("Expected the cached icon to be served, got %d page requests", requests.Load())
+	}
+	service.GetAppIcon(ctx, app.ID, true)
+	if requests.Load() != 2 {
+		t.Errorf("Expected a refresh to look again, got %d page requests", requests.Load())
+	}
+
+	// The icon label wins over detection
+	app.ComposeContent = "services:\n  web:\n    image: nginx:latest\n    labels:\n      " + constants.AppIconLabel + ": " + server.URL + "/custom.png\n"
+	if err := database.UpdateApp(app); err != nil {
+		t.Fatalf("Failed to update app: %v", err)
+	}
+	if icon, err := service.GetAppIcon(ctx, app.ID, false); err != nil || icon.ContentType != "image/png" {
+		t.Errorf("Expected the labelled icon, got %+v, %v", icon, err)
+	}
+
+	// Failures are cached too
+	app.ComposeContent = strings.Replace(app.ComposeContent, "/custom.png", "/missing.png", 1)
+	if err := database.UpdateApp(app); err != nil {
+		t.Fatalf("Failed to update app: %v", err)
+	}
+	if _, err := service.GetAppIcon(ctx, app.ID, false); !domain.IsNotFoundError(err) {
+		t.Errorf("Expected a missing icon to be not found, got %v", err)
+	}
+	if cached, err := database.GetAppIcon(app.ID); err != nil || cached.Error == "" || len(cached.Data) != 0 {
+		t.Errorf("Expected the failure to be cached, got %+v, %v", cached, err)
+	}
+}
@@ -0,0 +1,216 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/selfhostly/internal/appstate"
+	"github.com/selfhostly/internal/constants"
+	"github.com/selfhostly/internal/db"
+	"github.com/selfhostly/internal/domain"
+	"github.com/selfhostly/internal/events"
+)
+
+// PushAppInventory sends app changes since the last successful push to the primary, along with
+// the full list of app IDs so deletions are picked up. Secondary nodes call this periodically.
+func (s *nodeService) PushAppInventory(ctx context.Context) error {
+	if s.config.Node.IsPrimary {
+		return fmt.Errorf("primary node does not push app inventory")
+	}
+	if s.config.Node.PrimaryNodeURL == "" {
+		return fmt.Errorf("PRIMARY_NODE_URL not configured")
+	}
+
+	s.inventoryMu.Lock()
+	defer s.inventoryMu.Unlock()
+
+	resp, err := s.pushAppInventory(ctx, s.inventoryCursor)
+	if err != nil {
+		return err
+	}
+	if resp.ResyncRequired && !s.inventoryCursor.IsZero() {
+		s.logger.InfoContext(ctx, "primary requested full app inventory")
+		s.inventoryCursor = time.Time{}
+		if _, err := s.pushAppInventory(ctx, s.inventoryCursor); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// pushAppInventory sends apps updated after since (everything when since is zero) and advances the
+// cursor on success. Callers hold inventoryMu.
+func (s *nodeService) pushAppInventory(ctx context.Context, since time.Time) (*domain.AppInventorySyncResponse, error) {
+	startedAt := time.Now()
+
+	apps, err := s.database.GetAllAppsMetadata()
+	if err != nil {
+		return nil, domain.WrapDatabaseOperation("list apps", err)
+	}
+
+	req := domain.AppInventorySyncRequest{
+		Full:   since.IsZero(),
+		Apps:   []domain.AppSummary{},
+		AppIDs: make([]string, 0, len(apps)),
+	}
+	for _, app := range apps {
+		req.AppIDs = append(req.AppIDs, app.ID)
+		if req.Full || app.UpdatedAt.After(since) {
+			req.Apps = append(req.Apps, domain.AppSummary{
+				ID:        app.ID,
+				Name:      app.Name,
+				Status:    app.Status,
+				PublicURL: app.PublicURL,
+				UpdatedAt: app.UpdatedAt,
+			})
+		}
+	}
+
+	// Authenticate to the primary with this node's own credentials
+	primaryNode := &db.Node{
+		ID:          s.config.Node.ID,
+		APIEndpoint: s.config.Node.PrimaryNodeURL,
+		APIKey:      s.config.Node.APIKey,
+	}
+
+	resp, err := s.nodeClient.PushAppInventory(primaryNode, req)
+	if err != nil {
+		return nil, err
+	}
+
+	s.inventoryCursor = startedAt
+	s.logger.DebugContext(ctx, "pushed app inventory to primary", "full", req.Full, "changed", len(req.Apps), "total", len(req.AppIDs))
+	return resp, nil
+}
+
+// SyncAppInventory stores a secondary's inventory push in the primary's app cache. A delta that
+// references apps the cache has never seen (e.g. after the primary's database was restored)
+// asks the node for a full push.
+func (s *nodeService) SyncAppInventory(ctx context.Context, nodeID string, req domain.AppInventorySyncRequest) (*domain.AppInventorySyncResponse, error) {
+	n, err := s.database.GetNode(nodeID)
+	if err != nil {
+		return nil, fmt.Errorf("node not found: %w", err)
+	}
+	if n.Status == constants.NodeStatusLost {
+		// The cache is all that's left of an orphaned node's apps; keep it as it was
+		return nil, fmt.Errorf("node %s was removed from the cluster", nodeID)
+	}
+
+	changed := make([]*db.CachedApp, 0, len(req.Apps))
+	for _, app := range req.Apps {
+		changed = append(changed, &db.CachedApp{
+			NodeID:    nodeID,
+			AppID:     app.ID,
+			Name:      app.Name,
+			Status:    app.Status,
+			PublicURL: app.PublicURL,
+			UpdatedAt: toPrimaryClock(n, app.UpdatedAt),
+		})
+	}
+
+	// Statuses before the push, to tell event stream clients which apps changed status
+	var previous map[string]string
+	if len(changed) > 0 && events.Default().Subscribers() > 0 {
+		if entries, err := s.database.GetNodeAppCache(nodeID); err == nil {
+			previous = make(map[string]string, len(entries))
+			for _, entry := range entries {
+				previous[entry.AppID] = entry.Status
+			}
+		}
+	}
+
+	cached, err := s.database.SyncNodeAppCache(nodeID, changed, req.AppIDs)
+	if err != nil {
+		s.logger.ErrorContext(ctx, "failed to update app cache", "nodeID", nodeID, "error", err)
+		return nil, domain.WrapDatabaseOperation("sync app inventory", err)
+	}
+	if previous != nil {
+		for _, app := range changed {
+			if from, ok := previous[app.AppID]; !ok || from != app.Status {
+				events.Publish(events.Event{
+					Type:   events.TypeAppStatus,
+					NodeID: nodeID,
+					AppID:  app.AppID,
+					At:     app.UpdatedAt,
+					Data:   appstate.Event{AppID: app.AppID, AppName: app.Name, From: from, To: app.Status, At: app.UpdatedAt},
+				})
+			}
+		}
+	}
+
+	resp := &domain.AppInventorySyncResponse{
+		Cached:         cached,
+		ResyncRequired: !req.Full && cached < len(req.AppIDs),
+	}
+	s.logger.DebugContext(ctx, "app inventory synced", "nodeID", nodeID, "full", req.Full, "changed", len(req.Apps), "cached", cached, "resyncRequired", resp.ResyncRequired)
+	return resp, nil
+}
+
+// listRemoteApps lists apps on the requested remote nodes. Reachable nodes are asked directly;
+// apps on nodes that are offline or fail to answer come from the inventory cache, marked stale,
+// and those nodes are reported.
+func (s *appService) listRemoteApps(ctx context.Context, nodeIDs []string) ([]*db.App, []domain.NodeError, error) {
+	var nodes []*db.Node
+	if len(nodeIDs) == 0 || (len(nodeIDs) == 1 && nodeIDs[0] == "all") {
+		all, err := s.database.GetAllNodes()
+		if err != nil {
+			return nil, nil, domain.WrapDatabaseOperation("get nodes", err)
+		}
+		nodes = all
+	} else {
+		for _, nodeID := range nodeIDs {
+			n, err := s.database.GetNode(nodeID)
+			if err != nil {
+				s.logger.WarnContext(ctx, "node not found", "nodeID", nodeID, "error", err)
+				continue
+			}
+			nodes = append(nodes, n)
+		}
+	}
+
+	remoteNodes := make([]*db.Node, 0, len(nodes))
+	for _, n := range nodes {
+		if n.ID != s.config.Node.ID {
+			remoteNodes = append(remoteNodes, n)
+		}
+	}
+
+	apps, nodeErrors := s.appsAgg.AggregateApps(
+		ctx,
+		remoteNodes,
+		func() ([]*db.App, error) { return nil, nil }, // local apps are listed by the caller
+		func(n *db.Node) ([]*db.App, error) {
+			if n.Status != constants.NodeStatusOnline {
+				return nil, fmt.Errorf("node is %s", n.Status)
+			}
+			return s.nodeClient.GetApps(n)
+		},
+		func(n *db.Node) ([]*db.App, error) { return s.cachedApps(n.ID) },
+	)
+	return apps, nodeErrors, nil
+}
+
+// cachedApps returns a node's apps from the inventory cache, marked stale
+func (s *appService) cachedApps(nodeID string) ([]*db.App, error) {
+	cached, err := s.database.GetNodeAppCache(nodeID)
+	if err != nil {
+		return nil, domain.WrapDatabaseOperation("get cached apps", err)
+	}
+
+	apps := make([]*db.App, 0, len(cached))
+	for _, c := range cached {
+		syncedAt := c.SyncedAt
+		apps = append(apps, &db.App{
+			ID:        c.AppID,
+			Name:      c.Name,
+			Status:    c.Status,
+			PublicURL: c.PublicURL,
+			NodeID:    c.NodeID,
+			UpdatedAt: c.UpdatedAt,
+			Stale:     true,
+			SyncedAt:  &syncedAt,
+		})
+	}
+	return apps, nil
+}
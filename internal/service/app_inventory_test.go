@@ -0,0 +1,102 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+	"time"
+
+	"github.com/selfhostly/internal/config"
+	"github.com/selfhostly/internal/constants"
+	"github.com/selfhostly/internal/db"
+	"github.com/selfhostly/internal/domain"
+)
+
+func TestAppInventorySync(t *testing.T) {
+	appSvc, database, cleanup := setupTestAppService(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	cfg := &config.Config{Node: config.NodeConfig{ID: "test-node-id", IsPrimary: true}}
+	nodeSvc := NewNodeService(database, cfg, slog.Default())
+
+	remoteNode := db.NewNodeWithID("remote-node", "remote", "http://127.0.0.1:1", "remote-key", false)
+	remoteNode.Status = constants.NodeStatusOffline
+	if err := database.CreateNode(remoteNode); err != nil {
+		t.Fatalf("CreateNode: %v", err)
+	}
+
+	if _, err := appSvc.CreateApp(ctx, domain.CreateAppRequest{
+		Name:           "local-app",
+		ComposeContent: "services:\n  web:\n    image: nginx:latest",
+	}); err != nil {
+		t.Fatalf("CreateApp: %v", err)
+	}
+
+	updatedAt := time.Now().Add(-time.Hour)
+	full := domain.AppInventorySyncRequest{
+		Full: true,
+		Apps: []domain.AppSummary{
+			{ID: "app-a", Name: "alpha", Status: constants.AppStatusRunning, PublicURL: "https://alpha.example.com", UpdatedAt: updatedAt},
+			{ID: "app-b", Name: "bravo", Status: constants.AppStatusStopped, UpdatedAt: updatedAt},
+		},
+		AppIDs: []string{"app-a", "app-b"},
+	}
+	resp, err := nodeSvc.SyncAppInventory(ctx, remoteNode.ID, full)
+	if err != nil {
+		t.Fatalf("SyncAppInventory: %v", err)
+	}
+	if resp.Cached != 2 || resp.ResyncRequired {
+		t.Fatalf("unexpected response %+v", resp)
+	}
+
+	// Delta: alpha stopped, bravo deleted
+	delta := domain.AppInventorySyncRequest{
+		Apps:   []domain.AppSummary{{ID: "app-a", Name: "alpha", Status: constants.AppStatusStopped, UpdatedAt: time.Now()}},
+		AppIDs: []string{"app-a"},
+	}
+	if resp, err = nodeSvc.SyncAppInventory(ctx, remoteNode.ID, delta); err != nil || resp.ResyncRequired {
+		t.Fatalf("delta sync: resp=%+v err=%v", resp, err)
+	}
+
+	// A delta referencing apps the cache has never seen asks for a full push
+	unknown := domain.AppInventorySyncRequest{AppIDs: []string{"app-a", "app-c"}}
+	if resp, err = nodeSvc.SyncAppInventory(ctx, remoteNode.ID, unknown); err != nil || !resp.ResyncRequired {
+		t.Fatalf("expected resync to be required: resp=%+v err=%v", resp, err)
+	}
+
+	// The offline node's apps are listed from the cache, marked stale, and the node is reported
+	apps, nodeErrors, err := appSvc.ListAppsWithSchedules(ctx, nil, false)
+	if err != nil {
+		t.Fatalf("ListAppsWithSchedules: %v", err)
+	}
+	if len(nodeErrors) != 1 || nodeErrors[0].NodeID != remoteNode.ID || nodeErrors[0].NodeName != "remote" {
+		t.Errorf("expected the offline node to be reported, got %+v", nodeErrors)
+	}
+	var local, stale []*db.App
+	for _, app := range apps {
+		if app.Stale {
+			stale = append(stale, app)
+		} else {
+			local = append(local, app)
+		}
+	}
+	if len(local) != 1 || local[0].Name != "local-app" {
+		t.Errorf("expected the local app to be listed live, got %+v", local)
+	}
+	if len(stale) != 1 {
+		t.Fatalf("expected 1 cached app, got %d", len(stale))
+	}
+	if stale[0].ID != "app-a" || stale[0].Status != constants.AppStatusStopped || stale[0].NodeID != remoteNode.ID || stale[0].SyncedAt == nil {
+		t.Errorf("unexpected cached app %+v", stale[0])
+	}
+
+	// Filtering to the remote node leaves local apps out
+	apps, _, err = appSvc.ListAppsWithSchedules(ctx, []string{remoteNode.ID}, false)
+	if err != nil {
+		t.Fatalf("ListAppsWithSchedules: %v", err)
+	}
+	if len(apps) != 1 || !apps[0].Stale {
+		t.Errorf("expected only the cached remote app, got %d apps", len(apps))
+	}
+}
@@ -4,25 +4,36 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log/slog"
+	"maps"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 	"time"
 
 	"github.com/selfhostly/internal/cleanup"
 	"github.com/selfhostly/internal/cloudflare"
 	"github.com/selfhostly/internal/config"
+	"github.com/selfhostly/internal/applock"
+	"github.com/selfhostly/internal/appstate"
 	"github.com/selfhostly/internal/constants"
 	"github.com/selfhostly/internal/db"
+	"github.com/selfhostly/internal/diskguard"
 	"github.com/selfhostly/internal/docker"
 	"github.com/selfhostly/internal/domain"
 	"github.com/selfhostly/internal/node"
+	"github.com/selfhostly/internal/portreserve"
 	"github.com/selfhostly/internal/routing"
+	"github.com/selfhostly/internal/secretstore"
 	"github.com/selfhostly/internal/tunnel"
 	cloudflareProvider "github.com/selfhostly/internal/tunnel/providers/cloudflare"
+	frpProvider "github.com/selfhostly/internal/tunnel/providers/frp"
+	ngrokProvider "github.com/selfhostly/internal/tunnel/providers/ngrok"
 	"github.com/selfhostly/internal/validation"
+	"github.com/selfhostly/internal/webhook"
 )
 
 // appService implements the AppService interface
@@ -37,17 +48,27 @@ type appService struct {
 	settingsManager  *cloudflare.SettingsManager // DEPRECATED: for backward compatibility
 	providerRegistry *tunnel.Registry            // NEW: for multi-provider support
 	tunnelService    domain.TunnelService        // NEW: for Quick Tunnel operations
+	statusReconciler *statusReconciler
+	states           *appstate.Machine
+	webhooks         *webhook.Dispatcher
+	diskGuard        *diskguard.Guard
+	secrets          *secretstore.Resolver
+	quotas           domain.QuotaService
 }
 
-// NewAppService creates a new app service
+// NewAppService creates a new app service. states is the machine every app status change goes
+// through, shared with the job handlers and crash monitor.
 func NewAppService(
 	database *db.DB,
 	dockerManager *docker.Manager,
+	states *appstate.Machine,
+	webhooks *webhook.Dispatcher,
+	secrets *secretstore.Resolver,
 	cfg *config.Config,
 	logger *slog.Logger,
 	tunnelService domain.TunnelService,
 ) domain.AppService {
-	nodeClient := node.NewClient()
+	nodeClient := node.NewClientWithTimeouts(cfg.Timeouts)
 	router := routing.NewNodeRouter(database, nodeClient, cfg.Node.ID, logger)
 	appsAgg := routing.NewAppsAggregator(router, logger)
 	settingsManager := cloudflare.NewSettingsManager(database, logger)
@@ -62,9 +83,28 @@ func NewAppService(
 		return cloudflareProvider.NewProvider(config)
 	})
 
+	// Register ngrok provider
+	registry.Register(constants.ProviderNgrok, func(config map[string]interface{}) (tunnel.Provider, error) {
+		config["database"] = database
+		config["logger"] = logger
+		return ngrokProvider.NewProvider(config)
+	})
+
+	// Register frp provider
+	registry.Register(constants.ProviderFrp, func(config map[string]interface{}) (tunnel.Provider, error) {
+		config["database"] = database
+		config["logger"] = logger
+		return frpProvider.NewProvider(config)
+	})
+
+	// Chaos mode simulates tunnels without provider credentials
+	if cfg.Chaos.Enabled {
+		registerFakeProvider(registry, database, cfg, logger)
+	}
+
 	// Future providers can be registered here
 
-	return &appService{
+	svc := &appService{
 		database:         database,
 		dockerManager:    dockerManager,
 		nodeClient:       nodeClient,
@@ -75,7 +115,20 @@ func NewAppService(
 		settingsManager:  settingsManager,
 		providerRegistry: registry,
 		tunnelService:    tunnelService,
-	}
+		statusReconciler: newStatusReconciler(database, states, dockerManager, webhooks, logger),
+		states:           states,
+		webhooks:         webhooks,
+		diskGuard:        diskguard.New(cfg.DiskGuard),
+		secrets:          secrets,
+	}
+	// Quotas count the owner's apps through this service, so the checker is built around it
+	svc.quotas = NewQuotaService(database, svc, cfg, logger)
+	return svc
+}
+
+// Quotas returns the quota service the app service checks quotas with
+func (s *appService) Quotas() domain.QuotaService {
+	return s.quotas
 }
 
 // CreateApp creates a new application (local only; gateway forwards POST /api/apps to target node)
@@ -89,12 +142,21 @@ func (s *appService) CreateApp(ctx context.Context, req domain.CreateAppRequest)
 	}
 
 	// Validate compose content with security config
-	securityConfig := &validation.SecurityConfig{
-		AllowedVolumePaths: s.config.Security.AllowedVolumePaths,
+	securityConfig := s.composeSecurityConfig(ctx, req.ApprovePolicies)
+	if err := validation.ValidateComposeFiles(req.ComposeFiles); err != nil {
+		return nil, domain.WrapValidationError("compose files", err)
 	}
-	if err := validation.ValidateComposeContentWithConfig(req.ComposeContent, securityConfig); err != nil {
+	if err := validation.ValidateComposeContentWithFiles(req.ComposeContent, req.ComposeFiles, securityConfig); err != nil {
 		s.logger.WarnContext(ctx, "invalid compose content", "error", err)
-		return nil, domain.WrapValidationError("compose content", err)
+		return nil, composeValidationError("compose content", err)
+	}
+
+	// Validate compose override merged onto the base compose, if provided
+	if strings.TrimSpace(req.ComposeOverride) != "" {
+		if err := validation.ValidateComposeOverrideWithFiles(req.ComposeContent, req.ComposeOverride, req.ComposeFiles, securityConfig); err != nil {
+			s.logger.WarnContext(ctx, "invalid compose override", "error", err)
+			return nil, composeValidationError("compose override", err)
+		}
 	}
 
 	// Validate description if provided
@@ -105,6 +167,19 @@ func (s *appService) CreateApp(ctx context.Context, req domain.CreateAppRequest)
 		}
 	}
 
+	if err := validation.ValidateLabels(req.Labels); err != nil {
+		return nil, domain.WrapValidationError("labels", err)
+	}
+
+	if err := validation.ValidateEnvTemplate(req.EnvTemplate); err != nil {
+		return nil, domain.WrapValidationError("env template", err)
+	}
+
+	storageRoot, err := s.resolveStorageRoot(req.StorageRoot)
+	if err != nil {
+		return nil, err
+	}
+
 	// Validate Quick Tunnel params when tunnel_mode is "quick"
 	if req.TunnelMode == constants.TunnelModeQuick {
 		if strings.TrimSpace(req.QuickTunnelService) == "" {
@@ -115,6 +190,19 @@ func (s *appService) CreateApp(ctx context.Context, req domain.CreateAppRequest)
 		}
 	}
 
+	if err := enforceQuota(ctx, s.quotas, req.Owner, "", req.ComposeContent, req.ComposeOverride, req.ComposeFiles, req.TunnelMode != ""); err != nil {
+		return nil, err
+	}
+
+	// Creating pulls images; refuse before anything is written if the node is nearly full
+	if _, err := s.ensureDiskSpace(ctx, "create app", storageRoot); err != nil {
+		return nil, err
+	}
+	// And before a tunnel is created if another app already holds a port the compose publishes
+	if err := portreserve.Check(s.database, s.config.Node.ID, "", req.ComposeContent, req.ComposeOverride, req.ComposeFiles); err != nil {
+		return nil, err
+	}
+
 	// Get settings
 	settings, err := s.database.GetSettings()
 	if err != nil {
@@ -123,15 +211,17 @@ func (s *appService) CreateApp(ctx context.Context, req domain.CreateAppRequest)
 	}
 
 	// Parse compose to extract networks
-	compose, err := docker.ParseCompose([]byte(req.ComposeContent))
+	compose, err := docker.ParseComposeWithFiles([]byte(req.ComposeContent), req.ComposeFiles)
 	if err != nil {
 		s.logger.WarnContext(ctx, "invalid compose file", "app", req.Name, "error", err)
 		return nil, domain.WrapComposeInvalid(err)
 	}
 
 	var tunnelID, tunnelToken, publicURL string
-	var createdTunnelAppID string // Track the app ID used for tunnel creation
-	var tunnelMode string         // "custom" | "quick" | ""
+	var tunnelCompose string           // Generated tunnel sidecar, kept out of the user's compose
+	var createdTunnelAppID string      // Track the app ID used for tunnel creation
+	var tunnelProvider tunnel.Provider // Set once a provider tunnel exists, so a failed create can delete it
+	var tunnelMode string              // "custom" | "quick" | ""
 
 	providerName := settings.GetActiveProviderName()
 	providerConfig, providerConfigErr := settings.GetProviderConfig(providerName)
@@ -152,25 +242,11 @@ func (s *appService) CreateApp(ctx context.Context, req domain.CreateAppRequest)
 			return nil, fmt.Errorf("failed to create Quick Tunnel config: %w", err)
 		}
 
-		networks := docker.ExtractNetworks(compose)
-		network := ""
-		if len(networks) > 0 {
-			network = networks[0]
-		}
-		s.logger.InfoContext(ctx, "injecting Quick Tunnel container into compose", "app", req.Name, "service", req.QuickTunnelService, "port", req.QuickTunnelPort)
-		injected, err := docker.InjectTunnelContainer(compose, req.Name, containerConfig, network)
+		s.logger.InfoContext(ctx, "generating Quick Tunnel sidecar", "app", req.Name, "service", req.QuickTunnelService, "port", req.QuickTunnelPort)
+		tunnelCompose, err = docker.BuildTunnelCompose(compose, req.Name, containerConfig)
 		if err != nil {
-			s.logger.ErrorContext(ctx, "failed to inject Quick Tunnel container", "app", req.Name, "error", err)
-			return nil, fmt.Errorf("failed to inject Quick Tunnel container: %w", err)
-		}
-		if injected {
-			composeBytes, err := docker.MarshalComposeFile(compose)
-			if err != nil {
-				s.logger.ErrorContext(ctx, "failed to marshal compose file", "app", req.Name, "error", err)
-				return nil, fmt.Errorf("failed to marshal compose file: %w", err)
-			}
-			req.ComposeContent = string(composeBytes)
-			s.logger.InfoContext(ctx, "Quick Tunnel container injected successfully", "app", req.Name)
+			s.logger.ErrorContext(ctx, "failed to generate Quick Tunnel sidecar", "app", req.Name, "error", err)
+			return nil, fmt.Errorf("failed to generate Quick Tunnel sidecar: %w", err)
 		}
 	} else if req.TunnelMode == constants.TunnelModeCustom && providerConfigErr == nil && providerConfig != nil {
 		// Custom (named) tunnel: create via provider API and inject container
@@ -187,14 +263,16 @@ func (s *appService) CreateApp(ctx context.Context, req domain.CreateAppRequest)
 		createdTunnelAppID = tempApp.ID
 
 		tunnelResult, err := provider.CreateTunnel(ctx, tunnel.CreateOptions{
-			AppID: createdTunnelAppID,
-			Name:  req.Name,
+			AppID:            createdTunnelAppID,
+			Name:             req.Name,
+			AdditionalConfig: tunnelCreateConfig(req.ComposeContent, req.ComposeOverride, req.ComposeFiles, req.IngressRules),
 		})
 		if err != nil {
 			s.logger.ErrorContext(ctx, "failed to create tunnel", "provider", providerName, "error", err)
 			return nil, domain.WrapTunnelCreationFailed(req.Name, err)
 		}
 
+		tunnelProvider = provider
 		tunnelID = tunnelResult.TunnelID
 		tunnelToken = tunnelResult.TunnelToken
 		publicURL = tunnelResult.PublicURL
@@ -202,30 +280,14 @@ func (s *appService) CreateApp(ctx context.Context, req domain.CreateAppRequest)
 
 		if containerProvider, ok := provider.(tunnel.ContainerProvider); ok {
 			containerConfig := containerProvider.GetContainerConfig(tunnelToken, req.Name)
-			if containerConfig != nil {
-				networks := docker.ExtractNetworks(compose)
-				network := ""
-				if len(networks) > 0 {
-					network = networks[0]
-				}
-				s.logger.InfoContext(ctx, "injecting tunnel container into compose", "provider", providerName, "app", req.Name)
-				injected, err := docker.InjectTunnelContainer(compose, req.Name, containerConfig, network)
-				if err != nil {
-					s.logger.ErrorContext(ctx, "failed to inject tunnel container", "app", req.Name, "error", err)
-					return nil, fmt.Errorf("failed to inject tunnel container: %w", err)
-				}
-				if injected {
-					composeBytes, err := docker.MarshalComposeFile(compose)
-					if err != nil {
-						s.logger.ErrorContext(ctx, "failed to marshal compose file", "app", req.Name, "error", err)
-						return nil, fmt.Errorf("failed to marshal compose file: %w", err)
-					}
-					req.ComposeContent = string(composeBytes)
-					s.logger.InfoContext(ctx, "tunnel container injected successfully", "provider", providerName)
-				}
+			s.logger.InfoContext(ctx, "generating tunnel sidecar", "provider", providerName, "app", req.Name)
+			tunnelCompose, err = docker.BuildTunnelCompose(compose, req.Name, containerConfig)
+			if err != nil {
+				s.logger.ErrorContext(ctx, "failed to generate tunnel sidecar", "app", req.Name, "error", err)
+				return nil, fmt.Errorf("failed to generate tunnel sidecar: %w", err)
 			}
 		} else {
-			s.logger.DebugContext(ctx, "provider does not require container injection", "provider", providerName)
+			s.logger.DebugContext(ctx, "provider does not require a tunnel container", "provider", providerName)
 		}
 	} else {
 		if req.TunnelMode == "" {
@@ -243,6 +305,8 @@ func (s *appService) CreateApp(ctx context.Context, req domain.CreateAppRequest)
 			Name:           req.Name,
 			Description:    req.Description,
 			ComposeContent: req.ComposeContent,
+			ComposeOverride: req.ComposeOverride,
+			TunnelCompose:  tunnelCompose,
 			TunnelToken:    tunnelToken,
 			TunnelID:       tunnelID,
 			TunnelDomain:   publicURL,
@@ -251,11 +315,17 @@ func (s *appService) CreateApp(ctx context.Context, req domain.CreateAppRequest)
 			ErrorMessage:   nil,
 			NodeID:         s.config.Node.ID,
 			TunnelMode:     tunnelMode,
+			Labels:         req.Labels,
+			ComposeFiles:   req.ComposeFiles,
+			StorageRoot:    storageRoot,
+			Owner:          req.Owner,
 			CreatedAt:      time.Now(),
 			UpdatedAt:      time.Now(),
 		}
 	} else {
 		app = db.NewApp(req.Name, req.Description, req.ComposeContent)
+		app.ComposeOverride = req.ComposeOverride
+		app.TunnelCompose = tunnelCompose
 		app.TunnelToken = tunnelToken
 		app.TunnelID = tunnelID
 		app.TunnelDomain = publicURL
@@ -264,32 +334,67 @@ func (s *appService) CreateApp(ctx context.Context, req domain.CreateAppRequest)
 		app.ErrorMessage = nil
 		app.NodeID = s.config.Node.ID
 		app.TunnelMode = tunnelMode
+		app.Labels = req.Labels
+		app.ComposeFiles = req.ComposeFiles
+		app.StorageRoot = storageRoot
+		app.Owner = req.Owner
 		app.UpdatedAt = time.Now()
 	}
+	if err := s.renderEnvTemplate(app, req.EnvTemplate); err != nil {
+		return nil, domain.WrapValidationError("env template", err)
+	}
 
 	if err := s.database.CreateApp(app); err != nil {
 		s.logger.ErrorContext(ctx, "failed to create app in database", "app", req.Name, "error", err)
 		return nil, domain.WrapDatabaseOperation("create app", err)
 	}
+	if err := portreserve.Sync(s.database, s.config.Node.ID, app); err != nil {
+		s.logger.WarnContext(ctx, "failed to reserve app ports", "app", req.Name, "error", err)
+		s.rollbackCreateApp(ctx, app, tunnelProvider, false)
+		return nil, err
+	}
 
 	// Create initial compose version (version 1)
 	// Note: changedBy will be set by the caller if user context is available
 	initialReason := constants.ComposeVersionReasonInitial
 	initialVersion := db.NewComposeVersion(app.ID, 1, app.ComposeContent, &initialReason, nil)
+	initialVersion.ComposeOverride = app.ComposeOverride
+	initialVersion.ComposeFiles = app.ComposeFiles
 	if err := s.database.CreateComposeVersion(initialVersion); err != nil {
 		s.logger.WarnContext(ctx, "failed to create initial compose version", "appID", app.ID, "error", err)
 		// Don't fail the app creation if version tracking fails
 	}
 
 	// Create app directory and write compose file
-	if err := s.dockerManager.CreateAppDirectory(app.Name, app.ComposeContent); err != nil {
+	if err := s.dockerManager.CreateAppDirectoryIn(app.StorageRoot, app.Name, app.ComposeContent); err != nil {
 		s.logger.ErrorContext(ctx, "failed to create app directory", "app", req.Name, "error", err)
-		// Rollback database entry
-		if deleteErr := s.database.DeleteApp(app.ID); deleteErr != nil {
-			s.logger.ErrorContext(ctx, "failed to rollback app creation", "appID", app.ID, "error", deleteErr)
-		}
+		s.rollbackCreateApp(ctx, app, tunnelProvider, true)
 		return nil, domain.WrapContainerOperationFailed("create app directory", err)
 	}
+	if err := s.dockerManager.WriteComposeOverrideFile(app.Name, app.ComposeOverride); err != nil {
+		s.logger.ErrorContext(ctx, "failed to write compose override file", "app", req.Name, "error", err)
+		s.rollbackCreateApp(ctx, app, tunnelProvider, true)
+		return nil, domain.WrapContainerOperationFailed("write compose override file", err)
+	}
+	if err := s.dockerManager.WriteComposeFiles(app.Name, app.ComposeFiles, nil); err != nil {
+		s.logger.ErrorContext(ctx, "failed to write compose files", "app", req.Name, "error", err)
+		s.rollbackCreateApp(ctx, app, tunnelProvider, true)
+		return nil, domain.WrapContainerOperationFailed("write compose files", err)
+	}
+	if err := s.writeEnvFile(ctx, app, ""); err != nil {
+		s.logger.ErrorContext(ctx, "failed to write env file", "app", req.Name, "error", err)
+		// The .env holds secrets, so it goes even if the rest of the directory can't
+		if removeErr := s.dockerManager.WriteEnvFile(app.Name, ""); removeErr != nil {
+			s.logger.ErrorContext(ctx, "failed to remove env file", "app", req.Name, "error", removeErr)
+		}
+		s.rollbackCreateApp(ctx, app, tunnelProvider, true)
+		return nil, domain.WrapContainerOperationFailed("write env file", err)
+	}
+	if err := s.dockerManager.WriteTunnelComposeFile(app.Name, app.TunnelCompose); err != nil {
+		s.logger.ErrorContext(ctx, "failed to write tunnel compose file", "app", req.Name, "error", err)
+		s.rollbackCreateApp(ctx, app, tunnelProvider, true)
+		return nil, domain.WrapContainerOperationFailed("write tunnel compose file", err)
+	}
 
 	// Note: Tunnel metadata is already created by the provider during CreateTunnel() call
 	// The provider handles its own database table, so we don't need to create it here
@@ -331,9 +436,7 @@ func (s *appService) CreateApp(ctx context.Context, req domain.CreateAppRequest)
 		s.logger.InfoContext(ctx, "queueing auto-start job", "app", req.Name, "appID", app.ID)
 
 		// Keep app in "pending" status - background job will start it
-		app.Status = constants.AppStatusPending
-		app.UpdatedAt = time.Now()
-		if err := s.database.UpdateApp(app); err != nil {
+		if err := s.states.Transition(ctx, app, constants.AppStatusPending, "auto-start queued"); err != nil {
 			s.logger.WarnContext(ctx, "failed to update app status to pending", "app", app.Name, "error", err)
 		}
 
@@ -391,8 +494,8 @@ func (s *appService) ListApps(ctx context.Context, nodeIDs []string) ([]*db.App,
 		return nil, err
 	}
 
-	// Aggregate apps from all target nodes in parallel
-	allApps, err := s.appsAgg.AggregateApps(
+	// Aggregate apps from all target nodes in parallel; nodes that fail are logged and left out
+	allApps, _ := s.appsAgg.AggregateApps(
 		ctx,
 		targetNodes,
 		func() ([]*db.App, error) {
@@ -401,9 +504,10 @@ func (s *appService) ListApps(ctx context.Context, nodeIDs []string) ([]*db.App,
 		func(n *db.Node) ([]*db.App, error) {
 			return s.nodeClient.GetApps(n)
 		},
+		nil,
 	)
 
-	return allApps, err
+	return allApps, nil
 }
 
 // UpdateApp updates an existing app
@@ -426,17 +530,27 @@ func (s *appService) UpdateApp(ctx context.Context, appID string, nodeID string,
 		}
 	}
 
-	// Validate compose content if provided
-	if req.ComposeContent != "" {
-		securityConfig := &validation.SecurityConfig{
-			AllowedVolumePaths: s.config.Security.AllowedVolumePaths,
-		}
-		if err := validation.ValidateComposeContentWithConfig(req.ComposeContent, securityConfig); err != nil {
-			s.logger.WarnContext(ctx, "invalid compose content", "error", err)
-			return nil, domain.WrapValidationError("compose content", err)
+	// Compose content is validated once the app is loaded, against the compose files it will use
+	if err := validation.ValidateComposeFiles(req.ComposeFiles); err != nil {
+		return nil, domain.WrapValidationError("compose files", err)
+	}
+
+	if err := validation.ValidateLabels(req.Labels); err != nil {
+		return nil, domain.WrapValidationError("labels", err)
+	}
+
+	if req.EnvTemplate != nil {
+		if err := validation.ValidateEnvTemplate(*req.EnvTemplate); err != nil {
+			return nil, domain.WrapValidationError("env template", err)
 		}
 	}
 
+	ctx, unlock, err := applock.Acquire(ctx, s.database, appID, "app update")
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
 	app, err := s.database.GetApp(appID)
 	if err != nil {
 		return nil, domain.WrapAppNotFound(appID, err)
@@ -452,69 +566,47 @@ func (s *appService) UpdateApp(ctx context.Context, appID string, nodeID string,
 	if req.ComposeContent != "" {
 		composeContent = req.ComposeContent
 	}
+	composeFiles := app.ComposeFiles
+	if req.ComposeFiles != nil {
+		composeFiles = req.ComposeFiles
+	}
+	securityConfig := s.composeSecurityConfig(ctx, req.ApprovePolicies)
+	if req.ComposeContent != "" || req.ComposeFiles != nil {
+		if err := validation.ValidateComposeContentWithFiles(composeContent, composeFiles, securityConfig); err != nil {
+			s.logger.WarnContext(ctx, "invalid compose content", "appID", appID, "error", err)
+			return nil, composeValidationError("compose content", err)
+		}
+	}
 
-	if app.TunnelMode == constants.TunnelModeQuick && req.ComposeContent != "" {
-		if targetService, targetPort, ok := docker.ExtractQuickTunnelTargetFromCompose(app.ComposeContent); ok {
-			compose, err := docker.ParseCompose([]byte(composeContent))
-			if err == nil {
-				metricsPort := constants.QuickTunnelMetricsPort
-				if p, ok := docker.ExtractQuickTunnelMetricsHostPort(app.ComposeContent); ok {
-					metricsPort = p
-				}
-				containerConfig, err := s.tunnelService.CreateQuickTunnelConfig(targetService, targetPort, metricsPort)
-				if err == nil {
-					networks := docker.ExtractNetworks(compose)
-					network := ""
-					if len(networks) > 0 {
-						network = networks[0]
-					}
-					injected, err := docker.InjectTunnelContainer(compose, app.Name, containerConfig, network)
-					if err == nil && injected {
-						composeBytes, err := docker.MarshalComposeFile(compose)
-						if err == nil {
-							composeContent = string(composeBytes)
-							s.logger.InfoContext(ctx, "re-injected Quick Tunnel container into updated compose", "appID", appID, "target", targetService+":"+fmt.Sprint(targetPort))
-						}
-					}
-				}
-			}
+	// Validate the override against the compose it will be merged with
+	composeOverride := app.ComposeOverride
+	if req.ComposeOverride != nil {
+		composeOverride = *req.ComposeOverride
+	}
+	if strings.TrimSpace(composeOverride) != "" && (req.ComposeOverride != nil || req.ComposeContent != "" || req.ComposeFiles != nil) {
+		if err := validation.ValidateComposeOverrideWithFiles(composeContent, composeOverride, composeFiles, securityConfig); err != nil {
+			s.logger.WarnContext(ctx, "invalid compose override", "appID", appID, "error", err)
+			return nil, composeValidationError("compose override", err)
+		}
+	}
+	// Reservations only change with the compose
+	if req.ComposeContent != "" || req.ComposeOverride != nil || req.ComposeFiles != nil {
+		if err := enforceQuota(ctx, s.quotas, app.Owner, app.ID, composeContent, composeOverride, composeFiles, app.TunnelMode != ""); err != nil {
+			return nil, err
 		}
 	}
 
-	if app.TunnelToken != "" {
-		providerName := settings.GetActiveProviderName()
-		providerConfig, err := settings.GetProviderConfig(providerName)
-		if err == nil && providerConfig != nil {
-			provider, err := s.providerRegistry.GetProvider(providerName, providerConfig)
-			if err == nil {
-				if containerProvider, ok := provider.(tunnel.ContainerProvider); ok {
-					compose, err := docker.ParseCompose([]byte(composeContent))
-					if err != nil {
-						s.logger.WarnContext(ctx, "invalid compose file", "appID", appID, "error", err)
-						return nil, domain.WrapComposeInvalid(err)
-					}
-					containerConfig := containerProvider.GetContainerConfig(app.TunnelToken, app.Name)
-					if containerConfig != nil {
-						networks := docker.ExtractNetworks(compose)
-						network := ""
-						if len(networks) > 0 {
-							network = networks[0]
-						}
-						injected, err := docker.InjectTunnelContainer(compose, app.Name, containerConfig, network)
-						if err != nil {
-							s.logger.ErrorContext(ctx, "failed to inject tunnel container", "appID", appID, "error", err)
-							return nil, fmt.Errorf("failed to inject tunnel container: %w", err)
-						}
-						if injected {
-							composeBytes, err := docker.MarshalComposeFile(compose)
-							if err != nil {
-								s.logger.ErrorContext(ctx, "failed to marshal compose file", "appID", appID, "error", err)
-								return nil, fmt.Errorf("failed to marshal compose file: %w", err)
-							}
-							composeContent = string(composeBytes)
-						}
-					}
-				}
+	// The tunnel sidecar joins the app's networks, so regenerate it whenever the compose changes
+	tunnelCompose := app.TunnelCompose
+	if req.ComposeContent != "" || req.ComposeFiles != nil {
+		containerConfig, err := s.tunnelContainerConfig(ctx, app, settings)
+		if err != nil {
+			s.logger.WarnContext(ctx, "failed to build tunnel container config, keeping existing sidecar", "appID", appID, "error", err)
+		} else if containerConfig != nil {
+			composeContent, tunnelCompose, err = docker.GenerateTunnelCompose(composeContent, composeFiles, app.Name, containerConfig)
+			if err != nil {
+				s.logger.WarnContext(ctx, "failed to generate tunnel sidecar", "appID", appID, "error", err)
+				return nil, domain.WrapComposeInvalid(err)
 			}
 		}
 	}
@@ -525,11 +617,30 @@ func (s *appService) UpdateApp(ctx context.Context, appID string, nodeID string,
 	if req.Description != "" {
 		app.Description = req.Description
 	}
+	if req.Labels != nil {
+		app.Labels = req.Labels
+	}
+	previousEnvTemplate := app.EnvTemplate
+	if req.EnvTemplate != nil {
+		if err := s.renderEnvTemplate(app, *req.EnvTemplate); err != nil {
+			return nil, domain.WrapValidationError("env template", err)
+		}
+	}
 
-	composeChanged := composeContent != app.ComposeContent
+	composeChanged := composeContent != app.ComposeContent || composeOverride != app.ComposeOverride || !maps.Equal(composeFiles, app.ComposeFiles)
+	previousFiles := app.ComposeFiles
 	app.ComposeContent = composeContent
+	app.ComposeOverride = composeOverride
+	app.ComposeFiles = composeFiles
+	app.TunnelCompose = tunnelCompose
 	app.UpdatedAt = time.Now()
 
+	if composeChanged {
+		if err := portreserve.Sync(s.database, s.config.Node.ID, app); err != nil {
+			s.logger.WarnContext(ctx, "failed to reserve app ports", "appID", appID, "error", err)
+			return nil, err
+		}
+	}
 	if err := s.database.UpdateApp(app); err != nil {
 		s.logger.ErrorContext(ctx, "failed to update app in database", "appID", appID, "error", err)
 		return nil, domain.WrapDatabaseOperation("update app", err)
@@ -546,6 +657,8 @@ func (s *appService) UpdateApp(ctx context.Context, appID string, nodeID string,
 		}
 		updateReason := constants.ComposeVersionReasonUpdated
 		newVersion := db.NewComposeVersion(appID, latestVersion+1, app.ComposeContent, &updateReason, nil)
+		newVersion.ComposeOverride = app.ComposeOverride
+		newVersion.ComposeFiles = app.ComposeFiles
 		if err := s.database.CreateComposeVersion(newVersion); err != nil {
 			s.logger.WarnContext(ctx, "failed to create compose version", "appID", appID, "error", err)
 		}
@@ -555,17 +668,150 @@ func (s *appService) UpdateApp(ctx context.Context, appID string, nodeID string,
 		s.logger.ErrorContext(ctx, "failed to update compose file", "app", app.Name, "error", err)
 		return nil, domain.WrapContainerOperationFailed("write compose file", err)
 	}
+	if err := s.dockerManager.WriteComposeOverrideFile(app.Name, app.ComposeOverride); err != nil {
+		s.logger.ErrorContext(ctx, "failed to update compose override file", "app", app.Name, "error", err)
+		return nil, domain.WrapContainerOperationFailed("write compose override file", err)
+	}
+	if err := s.dockerManager.WriteComposeFiles(app.Name, app.ComposeFiles, previousFiles); err != nil {
+		s.logger.ErrorContext(ctx, "failed to update compose files", "app", app.Name, "error", err)
+		return nil, domain.WrapContainerOperationFailed("write compose files", err)
+	}
+	if err := s.writeEnvFile(ctx, app, previousEnvTemplate); err != nil {
+		s.logger.ErrorContext(ctx, "failed to update env file", "app", app.Name, "error", err)
+		return nil, domain.WrapContainerOperationFailed("write env file", err)
+	}
+	if err := s.dockerManager.WriteTunnelComposeFile(app.Name, app.TunnelCompose); err != nil {
+		s.logger.ErrorContext(ctx, "failed to update tunnel compose file", "app", app.Name, "error", err)
+		return nil, domain.WrapContainerOperationFailed("write tunnel compose file", err)
+	}
 
 	s.logger.InfoContext(ctx, "app updated successfully", "app", app.Name, "appID", appID)
 	return app, nil
 }
 
+// renderEnvTemplate renders tmpl as the app's managed .env, keeping the secrets generated when it
+// was last rendered
+func (s *appService) renderEnvTemplate(app *db.App, tmpl string) error {
+	content, err := docker.RenderEnvTemplate(tmpl, docker.EnvTemplateData{
+		AppName:    app.Name,
+		BaseDomain: s.config.BaseDomain,
+	}, app.EnvTemplate, app.EnvContent)
+	if err != nil {
+		return err
+	}
+	app.EnvTemplate = tmpl
+	app.EnvContent = content
+	return nil
+}
+
+// writeEnvFile writes the app's managed .env, with its secret references resolved. An app without
+// one keeps whatever .env is in its directory, unless previousTemplate says the file was managed
+// and has just been dropped.
+func (s *appService) writeEnvFile(ctx context.Context, app *db.App, previousTemplate string) error {
+	if app.EnvTemplate == "" && previousTemplate == "" {
+		return nil
+	}
+	content, err := s.secrets.ResolveEnv(ctx, app.EnvContent)
+	if err != nil {
+		return fmt.Errorf("failed to resolve secrets: %w", err)
+	}
+	return s.dockerManager.WriteEnvFile(app.Name, content)
+}
+
+// tunnelContainerConfig returns the sidecar container config for the app's existing tunnel,
+// or nil if the app has no tunnel or its provider doesn't run a container
+func (s *appService) tunnelContainerConfig(ctx context.Context, app *db.App, settings *db.Settings) (*tunnel.ContainerConfig, error) {
+	if app.TunnelMode == constants.TunnelModeQuick {
+		targetService, targetPort, ok := docker.ExtractQuickTunnelTargetFromCompose(app.TunnelComposeSource())
+		if !ok {
+			return nil, nil
+		}
+		metricsPort := constants.QuickTunnelMetricsPort
+		if p, ok := docker.ExtractQuickTunnelMetricsHostPort(app.TunnelComposeSource()); ok {
+			metricsPort = p
+		}
+		return s.tunnelService.CreateQuickTunnelConfig(targetService, targetPort, metricsPort)
+	}
+
+	if app.TunnelToken == "" {
+		return nil, nil
+	}
+	providerName := settings.GetActiveProviderName()
+	providerConfig, err := settings.GetProviderConfig(providerName)
+	if err != nil || providerConfig == nil {
+		return nil, nil
+	}
+	provider, err := s.providerRegistry.GetProvider(providerName, providerConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create tunnel provider: %w", err)
+	}
+	containerProvider, ok := provider.(tunnel.ContainerProvider)
+	if !ok {
+		return nil, nil
+	}
+	containerConfig := containerProvider.GetContainerConfig(app.TunnelToken, app.Name)
+	if containerConfig != nil {
+		containerConfig.Replicas = docker.TunnelReplicas(app.TunnelComposeSource())
+		if err := s.applyConnectorOptions(ctx, provider, app.ID, containerConfig); err != nil {
+			return nil, err
+		}
+	}
+	return containerConfig, nil
+}
+
+// tunnelCreateConfig is the AdditionalConfig a new tunnel is created with. Providers whose
+// tunnels forward to a single service (ngrok) take its "upstream" and the "hostname" to serve it
+// at from the first ingress rules that give them; providers that render the rules into their
+// sidecar (frp) take the "ingress_rules" themselves; others ignore it.
+func tunnelCreateConfig(composeContent, composeOverride string, composeFiles map[string]string, rules []db.IngressRule) map[string]interface{} {
+	config := map[string]interface{}{}
+	if len(rules) > 0 {
+		config["ingress_rules"] = rules
+	}
+	services := make([]string, len(rules))
+	for i, rule := range rules {
+		services[i] = rule.Service
+		if _, ok := config["hostname"]; !ok && rule.Hostname != nil && *rule.Hostname != "" {
+			config["hostname"] = *rule.Hostname
+		}
+	}
+	if upstream, err := docker.TunnelUpstream(composeContent, composeOverride, composeFiles, services); err == nil && upstream != "" {
+		config["upstream"] = upstream
+	}
+	return config
+}
+
+// applyConnectorOptions renders the options saved on an app's tunnel (e.g. cloudflared --protocol)
+// into its container config; providers without options keep their default command.
+func (s *appService) applyConnectorOptions(ctx context.Context, provider tunnel.Provider, appID string, containerConfig *tunnel.ContainerConfig) error {
+	optionsProvider, ok := provider.(tunnel.ConnectorOptionsProvider)
+	if !ok {
+		return nil
+	}
+	options, err := optionsProvider.GetConnectorOptions(ctx, appID)
+	if err != nil {
+		if errors.Is(err, tunnel.ErrTunnelNotFound) {
+			return nil
+		}
+		return fmt.Errorf("failed to get tunnel options: %w", err)
+	}
+	optionsProvider.ApplyConnectorOptions(containerConfig, options)
+	return nil
+}
+
 // DeleteApp deletes an app using comprehensive cleanup (local only)
-func (s *appService) DeleteApp(ctx context.Context, appID string, nodeID string) error {
-	s.logger.InfoContext(ctx, "deleting app", "appID", appID, "nodeID", nodeID)
+func (s *appService) DeleteApp(ctx context.Context, appID string, nodeID string, opts domain.DeleteAppOptions) (*domain.DeleteAppResult, error) {
+	s.logger.InfoContext(ctx, "deleting app", "appID", appID, "nodeID", nodeID,
+		"archive", opts.Archive, "dryRun", opts.DryRun, "skip", opts.SkipSteps, "removeVolumes", opts.RemoveVolumes, "force", opts.Force)
+	if err := cleanup.ValidateSkipSteps(opts.SkipSteps); err != nil {
+		return nil, domain.WrapValidationError("skip", err)
+	}
 	app, err := s.database.GetApp(appID)
 	if err != nil {
-		return domain.WrapAppNotFound(appID, err)
+		if opts.Force && !opts.DryRun && nodeID != s.config.Node.ID {
+			return s.forgetRemoteApp(ctx, appID, nodeID)
+		}
+		return nil, domain.WrapAppNotFound(appID, err)
 	}
 	tunnelManager, settings, err := s.settingsManager.GetConfiguredTunnelManager()
 	if err != nil {
@@ -573,15 +819,27 @@ func (s *appService) DeleteApp(ctx context.Context, appID string, nodeID string)
 		settings = nil
 	}
 	cleanupManager := cleanup.NewCleanupManager(s.dockerManager, s.database, settings, tunnelManager)
+	cleanupOpts := cleanup.CleanupOptions{DryRun: opts.DryRun, SkipSteps: opts.SkipSteps, RemoveVolumes: opts.RemoveVolumes, Force: opts.Force}
+	if opts.Archive {
+		// Archiving is a backup of the app directory; refuse while the app is still untouched if
+		// the trash's disk is nearly full
+		if !opts.DryRun {
+			if _, err := s.ensureBackupSpace(ctx, "archive app"); err != nil {
+				return nil, err
+			}
+		}
+		cleanupOpts.ArchiveDir = s.config.TrashDir
+	}
+	cleanupManager.SetOptions(cleanupOpts)
 	results, err := cleanupManager.CleanupApp(app)
 	successCount, failedCount, totalDuration := cleanupManager.GetSummary()
 	if err != nil {
 		s.logger.ErrorContext(ctx, "app cleanup completed with errors",
-			"app", app.Name, "appID", app.ID,
+			"app", app.Name, "appID", app.ID, "dryRun", opts.DryRun,
 			"successCount", successCount, "failedCount", failedCount, "totalDuration", totalDuration, "error", err)
 	} else {
 		s.logger.InfoContext(ctx, "app cleanup completed successfully",
-			"app", app.Name, "appID", app.ID,
+			"app", app.Name, "appID", app.ID, "dryRun", opts.DryRun,
 			"successCount", successCount, "failedCount", failedCount, "totalDuration", totalDuration)
 	}
 	for _, result := range results {
@@ -589,115 +847,336 @@ func (s *appService) DeleteApp(ctx context.Context, appID string, nodeID string)
 			s.logger.ErrorContext(ctx, "cleanup step failed", "app", app.Name, "step", result.Step, "error", result.Error, "duration", result.Duration)
 		}
 	}
-	return err
+
+	result := &domain.DeleteAppResult{AppID: app.ID, DryRun: opts.DryRun, Steps: make([]*domain.DeleteAppStep, 0, len(results))}
+	for _, r := range results {
+		step := &domain.DeleteAppStep{ID: r.StepID, Name: r.Step, Success: r.Success, Skipped: r.Skipped, Error: r.Error, Detail: r.Detail}
+		for _, res := range r.Resources {
+			step.Resources = append(step.Resources, &domain.DeleteAppResource{Kind: res.Kind, Name: res.Name})
+		}
+		result.Steps = append(result.Steps, step)
+	}
+	if archivePath := cleanupManager.ArchivePath(); archivePath != "" {
+		expiresAt := time.Now().Add(s.config.TrashTTL)
+		result.ArchivePath = archivePath
+		result.ArchiveExpiresAt = &expiresAt
+	}
+	return result, err
+}
+
+// forgetRemoteApp force-deletes an app whose node can't be reached: the gateway routes the request
+// here, to the primary, which drops the app from the node's cached inventory. Nothing on the node
+// itself is touched, so if it comes back with the app still deployed the app reappears.
+func (s *appService) forgetRemoteApp(ctx context.Context, appID string, nodeID string) (*domain.DeleteAppResult, error) {
+	if err := s.database.DeleteNodeAppCacheEntry(nodeID, appID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, domain.WrapAppNotFound(appID, err)
+		}
+		return nil, domain.WrapDatabaseOperation("delete cached app", err)
+	}
+	s.logger.WarnContext(ctx, "force-deleted app on unreachable node from inventory", "appID", appID, "nodeID", nodeID)
+
+	return &domain.DeleteAppResult{
+		AppID: appID,
+		Steps: []*domain.DeleteAppStep{{
+			ID:      "inventory",
+			Name:    "Remove app from unreachable node's inventory",
+			Success: true,
+			Detail:  "resources on the node were not cleaned up",
+		}},
+	}, nil
+}
+
+// wrapTransitionError reports a status change rejected by the app lifecycle as a conflict and
+// any other failure to save it as a database error
+func wrapTransitionError(err error) error {
+	var transitionErr *appstate.TransitionError
+	if errors.As(err, &transitionErr) {
+		return domain.WrapInvalidTransition(transitionErr.AppID, transitionErr.From, transitionErr.To)
+	}
+	return domain.WrapDatabaseOperation("update app status", err)
 }
 
 // StartApp starts an application (local only)
 func (s *appService) StartApp(ctx context.Context, appID string, nodeID string) (*db.App, error) {
 	s.logger.InfoContext(ctx, "starting app", "appID", appID, "nodeID", nodeID)
+	ctx, unlock, err := applock.Acquire(ctx, s.database, appID, "start")
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
 	app, err := s.database.GetApp(appID)
 	if err != nil {
 		return nil, domain.WrapAppNotFound(appID, err)
 	}
+	// Starting pulls the images that aren't on the node yet; refuse if the node is nearly full
+	if _, err := s.ensureDiskSpace(ctx, "start app", app.StorageRoot); err != nil {
+		return nil, err
+	}
+	if _, err := s.verifyComposeFile(ctx, app); err != nil {
+		return nil, err
+	}
+	if err := portreserve.Sync(s.database, s.config.Node.ID, app); err != nil {
+		return nil, err
+	}
 	if err := s.dockerManager.StartApp(app.Name); err != nil {
-		app.Status = constants.AppStatusError
-		em := err.Error()
-		app.ErrorMessage = &em
-		app.UpdatedAt = time.Now()
-		_ = s.database.UpdateApp(app)
+		_ = s.states.Transition(ctx, app, constants.AppStatusError, docker.ErrorDetails(err))
 		return nil, domain.WrapContainerOperationFailed("start app", err)
 	}
-	app.Status = constants.AppStatusRunning
-	app.ErrorMessage = nil
-	app.UpdatedAt = time.Now()
-	if err := s.database.UpdateApp(app); err != nil {
-		return nil, domain.WrapDatabaseOperation("update app status", err)
+	if err := s.states.Transition(ctx, app, constants.AppStatusRunning, ""); err != nil {
+		return nil, wrapTransitionError(err)
 	}
 	s.logger.InfoContext(ctx, "app started successfully", "app", app.Name, "appID", appID)
+	s.webhooks.Fire(ctx, app, constants.WebhookEventStart, "")
 	return app, nil
 }
 
 // StopApp stops an application (local only)
 func (s *appService) StopApp(ctx context.Context, appID string, nodeID string) (*db.App, error) {
 	s.logger.InfoContext(ctx, "stopping app", "appID", appID, "nodeID", nodeID)
+	ctx, unlock, err := applock.Acquire(ctx, s.database, appID, "stop")
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
 	app, err := s.database.GetApp(appID)
 	if err != nil {
 		return nil, domain.WrapAppNotFound(appID, err)
 	}
 	if err := s.dockerManager.StopApp(app.Name); err != nil {
-		app.Status = constants.AppStatusError
-		em := err.Error()
-		app.ErrorMessage = &em
-		app.UpdatedAt = time.Now()
-		_ = s.database.UpdateApp(app)
+		_ = s.states.Transition(ctx, app, constants.AppStatusError, err.Error())
 		return nil, domain.WrapContainerOperationFailed("stop app", err)
 	}
-	app.Status = constants.AppStatusStopped
-	app.ErrorMessage = nil
-	app.UpdatedAt = time.Now()
-	if err := s.database.UpdateApp(app); err != nil {
-		return nil, domain.WrapDatabaseOperation("update app status", err)
+	if err := s.states.Transition(ctx, app, constants.AppStatusStopped, ""); err != nil {
+		return nil, wrapTransitionError(err)
 	}
 	s.logger.InfoContext(ctx, "app stopped successfully", "app", app.Name, "appID", appID)
+	s.webhooks.Fire(ctx, app, constants.WebhookEventStop, "")
 	return app, nil
 }
 
 // UpdateAppContainers updates app containers with zero downtime (local only)
 func (s *appService) UpdateAppContainers(ctx context.Context, appID string, nodeID string) (*db.App, error) {
 	s.logger.InfoContext(ctx, "updating app containers", "appID", appID, "nodeID", nodeID)
+	ctx, unlock, err := applock.Acquire(ctx, s.database, appID, "container update")
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
 	app, err := s.database.GetApp(appID)
 	if err != nil {
 		return nil, domain.WrapAppNotFound(appID, err)
 	}
 
 	// RECOVERY: If app directory doesn't exist, recreate it from database
-	appPath := filepath.Join(s.config.AppsDir, app.Name)
+	appPath := s.dockerManager.AppPath(app.Name)
 	if _, err := os.Stat(appPath); os.IsNotExist(err) {
 		s.logger.WarnContext(ctx, "app directory missing, recreating from database",
-			"app", app.Name, "appPath", appPath)
+			"app", app.Name, "appPath", appPath, "storageRoot", app.StorageRoot)
 
 		// Recreate directory with compose file from database
-		if err := s.dockerManager.CreateAppDirectory(app.Name, app.ComposeContent); err != nil {
+		if err := s.dockerManager.CreateAppDirectoryIn(app.StorageRoot, app.Name, app.ComposeContent); err != nil {
 			return nil, fmt.Errorf("failed to recover app directory: %w", err)
 		}
+		if err := s.dockerManager.WriteComposeOverrideFile(app.Name, app.ComposeOverride); err != nil {
+			return nil, fmt.Errorf("failed to recover compose override file: %w", err)
+		}
+		if err := s.dockerManager.WriteComposeFiles(app.Name, app.ComposeFiles, nil); err != nil {
+			return nil, fmt.Errorf("failed to recover compose files: %w", err)
+		}
+		if err := s.writeEnvFile(ctx, app, ""); err != nil {
+			return nil, fmt.Errorf("failed to recover env file: %w", err)
+		}
+		if err := s.dockerManager.WriteTunnelComposeFile(app.Name, app.TunnelCompose); err != nil {
+			return nil, fmt.Errorf("failed to recover tunnel compose file: %w", err)
+		}
 
 		s.logger.InfoContext(ctx, "app directory recovered successfully", "app", app.Name)
 	}
 
-	app.Status = constants.AppStatusUpdating
-	app.UpdatedAt = time.Now()
-	if err := s.database.UpdateApp(app); err != nil {
-		return nil, domain.WrapDatabaseOperation("update app status", err)
+	if _, err := s.ensureDiskSpace(ctx, "update app", app.StorageRoot); err != nil {
+		return nil, err
+	}
+	// The update rewrites the file from the database, the edited one being kept as a backup
+	if _, err := s.verifyComposeFile(ctx, app); err != nil {
+		return nil, err
+	}
+	if err := portreserve.Sync(s.database, s.config.Node.ID, app); err != nil {
+		return nil, err
+	}
+
+	if err := s.states.Transition(ctx, app, constants.AppStatusUpdating, "container update"); err != nil {
+		return nil, wrapTransitionError(err)
 	}
 	if err := s.dockerManager.WriteComposeFile(app.Name, app.ComposeContent); err != nil {
-		app.Status = constants.AppStatusError
-		em := err.Error()
-		app.ErrorMessage = &em
-		app.UpdatedAt = time.Now()
-		_ = s.database.UpdateApp(app)
+		_ = s.states.Transition(ctx, app, constants.AppStatusError, err.Error())
 		return nil, domain.WrapContainerOperationFailed("write compose file", err)
 	}
+	if err := s.dockerManager.WriteComposeOverrideFile(app.Name, app.ComposeOverride); err != nil {
+		_ = s.states.Transition(ctx, app, constants.AppStatusError, err.Error())
+		return nil, domain.WrapContainerOperationFailed("write compose override file", err)
+	}
+	if err := s.dockerManager.WriteComposeFiles(app.Name, app.ComposeFiles, nil); err != nil {
+		_ = s.states.Transition(ctx, app, constants.AppStatusError, err.Error())
+		return nil, domain.WrapContainerOperationFailed("write compose files", err)
+	}
+	if err := s.writeEnvFile(ctx, app, ""); err != nil {
+		_ = s.states.Transition(ctx, app, constants.AppStatusError, err.Error())
+		return nil, domain.WrapContainerOperationFailed("write env file", err)
+	}
+	if err := s.dockerManager.WriteTunnelComposeFile(app.Name, app.TunnelCompose); err != nil {
+		_ = s.states.Transition(ctx, app, constants.AppStatusError, err.Error())
+		return nil, domain.WrapContainerOperationFailed("write tunnel compose file", err)
+	}
 	if err := s.dockerManager.UpdateApp(app.Name); err != nil {
-		app.Status = constants.AppStatusError
-		em := err.Error()
-		app.ErrorMessage = &em
-		app.UpdatedAt = time.Now()
-		_ = s.database.UpdateApp(app)
+		_ = s.states.Transition(ctx, app, constants.AppStatusError, docker.ErrorDetails(err))
 		return nil, domain.WrapContainerOperationFailed("update app", err)
 	}
 	if err := s.dockerManager.ForceRecreateTunnel(app.Name); err != nil {
 		s.logger.WarnContext(ctx, "could not force-recreate tunnel (app may have no tunnel)", "app", app.Name, "appID", appID, "error", err)
 	}
-	app.Status = constants.AppStatusRunning
-	app.ErrorMessage = nil
-	app.UpdatedAt = time.Now()
-	if err := s.database.UpdateApp(app); err != nil {
-		return nil, domain.WrapDatabaseOperation("update app status", err)
+	if err := s.states.Transition(ctx, app, constants.AppStatusRunning, ""); err != nil {
+		return nil, wrapTransitionError(err)
+	}
+	if err := s.RecordImageDigests(ctx, appID); err != nil {
+		s.logger.WarnContext(ctx, "failed to record image digests", "app", app.Name, "appID", appID, "error", err)
 	}
 	s.logger.InfoContext(ctx, "app containers updated successfully", "app", app.Name, "appID", appID)
+	s.webhooks.Fire(ctx, app, constants.WebhookEventUpdate, "")
 	return app, nil
 }
 
+// ensureDiskSpace refuses an operation that pulls images when the app's storage root or Docker's
+// image store is below the minimum free space. Below the warning threshold it returns a warning
+// for the caller to surface instead. A filesystem that can't be read doesn't block the operation.
+func (s *appService) ensureDiskSpace(ctx context.Context, operation, storageRoot string) (string, error) {
+	var paths []string
+	if rootDir, ok := s.dockerManager.StorageRootDir(storageRoot); ok {
+		paths = append(paths, rootDir)
+	}
+	// The daemon's root dir is only checkable when it is visible from here (not when running in a container without it mounted)
+	if root, err := s.dockerManager.DockerRootDir(); err == nil && root != "" {
+		if _, statErr := os.Stat(root); statErr == nil {
+			paths = append(paths, root)
+		}
+	}
+	return s.checkDiskSpace(ctx, operation, paths)
+}
+
+// ensureBackupSpace refuses to archive an app directory into the trash when the trash's
+// filesystem is below the minimum free space, so a backup can't fill the disk. The trash
+// directory is only created by the first archive, so its nearest existing parent is checked.
+func (s *appService) ensureBackupSpace(ctx context.Context, operation string) (string, error) {
+	dir := s.config.TrashDir
+	for {
+		if _, err := os.Stat(dir); err == nil || filepath.Dir(dir) == dir {
+			break
+		}
+		dir = filepath.Dir(dir)
+	}
+	return s.checkDiskSpace(ctx, operation, []string{dir})
+}
+
+// checkDiskSpace checks each path against the disk guard and returns an insufficient disk space
+// error for the first below the minimum, or a warning naming every path below the warning
+// threshold
+func (s *appService) checkDiskSpace(ctx context.Context, operation string, paths []string) (string, error) {
+	var warnings []string
+	for _, path := range paths {
+		usage, err := s.diskGuard.Check(path)
+		if errors.Is(err, diskguard.ErrInsufficientSpace) {
+			s.logger.WarnContext(ctx, "refusing operation, disk space below minimum", "operation", operation, "path", path, "freeBytes", usage.FreeBytes)
+			return "", domain.WrapInsufficientDiskSpace(operation, err)
+		}
+		if err != nil {
+			s.logger.WarnContext(ctx, "could not check disk space", "operation", operation, "error", err)
+			continue
+		}
+		if usage.Low {
+			warnings = append(warnings, fmt.Sprintf("Low disk space: %s has %s free", path, diskguard.FormatBytes(usage.FreeBytes)))
+			s.logger.WarnContext(ctx, "low disk space", "operation", operation, "path", path, "freeBytes", usage.FreeBytes)
+		}
+	}
+	return strings.Join(warnings, "; "), nil
+}
+
+// resolveStorageRoot checks that root names one of this node's storage roots and returns it the
+// way the app records it, "" for the default root
+func (s *appService) resolveStorageRoot(root string) (string, error) {
+	root = strings.TrimSpace(root)
+	if root == constants.DefaultStorageRoot {
+		return "", nil
+	}
+	if _, ok := s.dockerManager.StorageRootDir(root); !ok {
+		return "", domain.WrapValidationError("storage_root", fmt.Errorf("storage root %s is not configured on this node", root))
+	}
+	return root, nil
+}
+
+// composeSecurityConfig is the host path and compose policy compose files are validated against.
+// approved are the compose policies the admin approved for the change. When the settings can't be
+// read every compose policy blocks.
+func (s *appService) composeSecurityConfig(ctx context.Context, approved []string) *validation.SecurityConfig {
+	securityConfig := &validation.SecurityConfig{
+		AllowedVolumePaths:     s.config.Security.AllowedVolumePaths,
+		BindMountPolicy:        s.config.Security.BindMountPolicy,
+		AllowedProtectedMounts: s.config.Security.AllowedProtectedMounts,
+		ApprovedPolicies:       approved,
+	}
+	settings, err := s.settingsManager.GetSettings()
+	if err != nil {
+		s.logger.WarnContext(ctx, "failed to get settings, blocking every compose policy", "error", err)
+		return securityConfig
+	}
+	securityConfig.Policies = map[string]string{
+		constants.ComposePolicyPrivileged:  settings.ComposePolicyPrivileged,
+		constants.ComposePolicyHostNetwork: settings.ComposePolicyHostNetwork,
+		constants.ComposePolicyCapAdd:      settings.ComposePolicyCapAdd,
+		constants.ComposePolicyDevices:     settings.ComposePolicyDevices,
+	}
+	if len(approved) > 0 {
+		s.logger.InfoContext(ctx, "compose policies approved for change", "policies", approved)
+	}
+	return securityConfig
+}
+
+// composeValidationError reports a compose file refused by validation, or one that a compose policy
+// lets through once an admin approves it
+func composeValidationError(field string, err error) error {
+	var approvalErr *validation.PolicyApprovalError
+	if errors.As(err, &approvalErr) {
+		return domain.WrapPolicyApprovalRequired(approvalErr)
+	}
+	return domain.WrapValidationError(field, err)
+}
+
+// RecordImageDigests pins the images the app is running to the current compose version
+func (s *appService) RecordImageDigests(ctx context.Context, appID string) error {
+	if !s.config.Security.PinImageDigests {
+		return nil
+	}
+
+	app, err := s.database.GetApp(appID)
+	if err != nil {
+		return domain.WrapAppNotFound(appID, err)
+	}
+
+	digests, err := s.dockerManager.ResolveImageDigests(app.Name)
+	if err != nil {
+		return domain.WrapContainerOperationFailed("resolve image digests", err)
+	}
+	if len(digests) == 0 {
+		return nil
+	}
+
+	if err := s.database.SetCurrentVersionImageDigests(appID, digests); err != nil {
+		return domain.WrapDatabaseOperation("record image digests", err)
+	}
+
+	s.logger.InfoContext(ctx, "recorded image digests", "app", app.Name, "appID", appID, "images", len(digests))
+	return nil
+}
+
 // CreateTunnelForApp creates a named (custom domain) tunnel for an app that has none (local only).
 func (s *appService) CreateTunnelForApp(ctx context.Context, appID string, nodeID string, body interface{}) (*db.App, bool, error) {
 	app, err := s.createTunnelForAppLocal(ctx, appID, nodeID)
@@ -710,6 +1189,11 @@ func (s *appService) CreateTunnelForApp(ctx context.Context, appID string, nodeI
 // createTunnelForAppLocal runs the create-tunnel logic on this node (DB, provider, compose, UpdateAppContainers).
 func (s *appService) createTunnelForAppLocal(ctx context.Context, appID string, nodeID string) (*db.App, error) {
 	s.logger.InfoContext(ctx, "creating tunnel for app", "appID", appID, "nodeID", nodeID)
+	ctx, unlock, err := applock.Acquire(ctx, s.database, appID, "tunnel creation")
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
 
 	app, err := s.database.GetApp(appID)
 	if err != nil {
@@ -742,7 +1226,11 @@ func (s *appService) createTunnelForAppLocal(ctx context.Context, appID string,
 		return nil, fmt.Errorf("failed to create tunnel provider: %w", err)
 	}
 
-	tunnelResult, err := provider.CreateTunnel(ctx, tunnel.CreateOptions{AppID: app.ID, Name: app.Name})
+	tunnelResult, err := provider.CreateTunnel(ctx, tunnel.CreateOptions{
+		AppID:            app.ID,
+		Name:             app.Name,
+		AdditionalConfig: tunnelCreateConfig(app.ComposeContent, app.ComposeOverride, app.ComposeFiles, nil),
+	})
 	if err != nil {
 		return nil, domain.WrapTunnelCreationFailed(app.Name, err)
 	}
@@ -769,26 +1257,10 @@ func (s *appService) createTunnelForAppLocal(ctx context.Context, appID string,
 		return app, nil
 	}
 
-	compose, err := docker.ParseCompose([]byte(app.ComposeContent))
+	app.ComposeContent, app.TunnelCompose, err = docker.GenerateTunnelCompose(app.ComposeContent, app.ComposeFiles, app.Name, containerConfig)
 	if err != nil {
 		return nil, domain.WrapComposeInvalid(err)
 	}
-	networks := docker.ExtractNetworks(compose)
-	network := ""
-	if len(networks) > 0 {
-		network = networks[0]
-	}
-	injected, err := docker.InjectTunnelContainer(compose, app.Name, containerConfig, network)
-	if err != nil {
-		return nil, fmt.Errorf("failed to inject tunnel container: %w", err)
-	}
-	if injected {
-		composeBytes, err := docker.MarshalComposeFile(compose)
-		if err != nil {
-			return nil, fmt.Errorf("failed to marshal compose: %w", err)
-		}
-		app.ComposeContent = string(composeBytes)
-	}
 	app.UpdatedAt = time.Now()
 	if err := s.database.UpdateApp(app); err != nil {
 		return nil, domain.WrapDatabaseOperation("update app", err)
@@ -808,16 +1280,180 @@ func (s *appService) createTunnelForAppLocal(ctx context.Context, appID string,
 }
 
 // SwitchAppToCustomTunnel switches an app from Quick Tunnel to a named (custom domain) tunnel (local only).
-func (s *appService) SwitchAppToCustomTunnel(ctx context.Context, appID string, nodeID string, body interface{}) (*db.App, error) {
+// The named tunnel's container is started next to the Quick Tunnel's, and the Quick Tunnel is only
+// replaced once the provider reports the named tunnel connected, so the app stays reachable
+// throughout. ingressRules are applied to the named tunnel before the cutover. If they can't be
+// applied or the tunnel never connects, the named tunnel is deleted and the Quick Tunnel is kept.
+func (s *appService) SwitchAppToCustomTunnel(ctx context.Context, appID string, nodeID string, ingressRules []db.IngressRule) (*db.App, error) {
+	ctx, unlock, err := applock.Acquire(ctx, s.database, appID, "tunnel switch")
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
 	app, err := s.database.GetApp(appID)
 	if err != nil {
 		return nil, domain.WrapAppNotFound(appID, err)
 	}
+	if app.NodeID != "" && app.NodeID != nodeID {
+		return nil, fmt.Errorf("app belongs to node %s, not %s", app.NodeID, nodeID)
+	}
 	if app.TunnelMode != constants.TunnelModeQuick {
 		return nil, fmt.Errorf("app is not using Quick Tunnel (tunnel_mode=%q)", app.TunnelMode)
 	}
-	createdApp, _, err := s.CreateTunnelForApp(ctx, appID, nodeID, body)
-	return createdApp, err
+	_, err = s.database.GetCloudflareTunnelByAppID(appID)
+	if err == nil {
+		return nil, fmt.Errorf("app already has a named tunnel")
+	}
+	if err != sql.ErrNoRows {
+		return nil, domain.WrapDatabaseOperation("get tunnel", err)
+	}
+
+	settings, err := s.database.GetSettings()
+	if err != nil {
+		return nil, err
+	}
+	providerName := settings.GetActiveProviderName()
+	providerConfig, err := settings.GetProviderConfig(providerName)
+	if err != nil || providerConfig == nil {
+		return nil, fmt.Errorf("tunnel provider not configured: %w", err)
+	}
+	provider, err := s.providerRegistry.GetProvider(providerName, providerConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create tunnel provider: %w", err)
+	}
+	containerProvider, ok := provider.(tunnel.ContainerProvider)
+	if !ok {
+		// Nothing runs next to the Quick Tunnel, so there is nothing to overlap
+		return s.createTunnelForAppLocal(ctx, appID, nodeID)
+	}
+
+	s.logger.InfoContext(ctx, "switching app from Quick Tunnel to named tunnel", "app", app.Name, "appID", appID)
+	tunnelResult, err := provider.CreateTunnel(ctx, tunnel.CreateOptions{
+		AppID:            app.ID,
+		Name:             app.Name,
+		AdditionalConfig: tunnelCreateConfig(app.ComposeContent, app.ComposeOverride, app.ComposeFiles, ingressRules),
+	})
+	if err != nil {
+		return nil, domain.WrapTunnelCreationFailed(app.Name, err)
+	}
+	// abandon deletes the named tunnel and its standby container, leaving the Quick Tunnel as it was
+	abandon := func() {
+		if err := s.dockerManager.RemoveStandbyTunnel(app.Name); err != nil {
+			s.logger.WarnContext(ctx, "failed to remove standby tunnel", "app", app.Name, "error", err)
+		}
+		if err := provider.DeleteTunnel(context.WithoutCancel(ctx), appID); err != nil {
+			s.logger.WarnContext(ctx, "failed to delete named tunnel after failed switch", "app", app.Name, "tunnelID", tunnelResult.TunnelID, "error", err)
+		}
+	}
+
+	// The named tunnel must route like the caller asked before it takes over from the Quick Tunnel
+	if len(ingressRules) > 0 {
+		ingressProvider, ok := provider.(tunnel.IngressProvider)
+		if !ok {
+			abandon()
+			return nil, domain.WrapValidationError("ingress_rules", tunnel.NewFeatureNotSupportedError(provider.DisplayName(), tunnel.FeatureIngress))
+		}
+		if err := ingressProvider.UpdateIngress(ctx, appID, ingressRules); err != nil {
+			abandon()
+			return nil, domain.WrapTunnelCreationFailed(app.Name, fmt.Errorf("apply ingress rules: %w; the Quick Tunnel was kept", err))
+		}
+		if t, err := provider.GetTunnelByAppID(ctx, appID); err == nil && t.PublicURL != "" {
+			tunnelResult.PublicURL = t.PublicURL
+		}
+	}
+
+	containerConfig := containerProvider.GetContainerConfig(tunnelResult.TunnelToken, app.Name)
+	if containerConfig == nil {
+		abandon()
+		return nil, fmt.Errorf("%s did not provide a tunnel container", provider.DisplayName())
+	}
+	composeContent, tunnelCompose, err := docker.GenerateTunnelCompose(app.ComposeContent, app.ComposeFiles, app.Name, containerConfig)
+	if err != nil {
+		abandon()
+		return nil, domain.WrapComposeInvalid(err)
+	}
+	standbyCompose, err := docker.StandbyTunnelCompose(tunnelCompose, app.Name)
+	if err != nil {
+		abandon()
+		return nil, domain.WrapComposeInvalid(err)
+	}
+	if err := s.dockerManager.StartStandbyTunnel(app.Name, standbyCompose); err != nil {
+		abandon()
+		return nil, domain.WrapContainerOperationFailed("start named tunnel", err)
+	}
+	if connectorProvider, ok := provider.(tunnel.ConnectorProvider); ok {
+		if err := waitForTunnelConnector(ctx, connectorProvider, appID, constants.TunnelSwitchConnectTimeout, constants.TunnelSwitchPollInterval); err != nil {
+			abandon()
+			return nil, domain.WrapTunnelCreationFailed(app.Name, fmt.Errorf("%w; the Quick Tunnel was kept", err))
+		}
+	} else {
+		s.logger.InfoContext(ctx, "provider can't report tunnel connectors, switching without waiting", "app", app.Name, "provider", providerName)
+	}
+
+	// The named tunnel is serving: make it the app's tunnel and stop the Quick Tunnel
+	app.ComposeContent = composeContent
+	app.TunnelCompose = tunnelCompose
+	app.TunnelID = tunnelResult.TunnelID
+	app.TunnelToken = tunnelResult.TunnelToken
+	app.TunnelMode = constants.TunnelModeCustom
+	app.PublicURL = tunnelResult.PublicURL
+	app.TunnelDomain = strings.TrimPrefix(tunnelResult.PublicURL, "https://")
+	app.UpdatedAt = time.Now()
+	if err := s.database.UpdateApp(app); err != nil {
+		abandon()
+		return nil, domain.WrapDatabaseOperation("update app", err)
+	}
+	if err := portreserve.Sync(s.database, s.config.Node.ID, app); err != nil {
+		s.logger.WarnContext(ctx, "failed to release Quick Tunnel metrics port", "appID", appID, "error", err)
+	}
+	if err := s.dockerManager.WriteComposeFile(app.Name, app.ComposeContent); err != nil {
+		return nil, domain.WrapContainerOperationFailed("write compose file", err)
+	}
+	if err := s.dockerManager.WriteTunnelComposeFile(app.Name, app.TunnelCompose); err != nil {
+		return nil, domain.WrapContainerOperationFailed("write tunnel compose file", err)
+	}
+	if err := s.dockerManager.ForceRecreateTunnel(app.Name); err != nil {
+		// The standby keeps the named tunnel up; it is removed once the tunnel container is recreated
+		s.logger.WarnContext(ctx, "could not recreate tunnel container, leaving standby running", "app", app.Name, "error", err)
+		return app, nil
+	}
+	if err := s.dockerManager.RemoveStandbyTunnel(app.Name); err != nil {
+		s.logger.WarnContext(ctx, "failed to remove standby tunnel", "app", app.Name, "error", err)
+	}
+
+	s.logger.InfoContext(ctx, "switched app to named tunnel", "app", app.Name, "appID", appID, "tunnelID", app.TunnelID)
+	return app, nil
+}
+
+// waitForTunnelConnector polls the provider until the app's tunnel has a connected connector,
+// failing once timeout passes or ctx is done
+func waitForTunnelConnector(ctx context.Context, provider tunnel.ConnectorProvider, appID string, timeout, interval time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	var lastErr error
+	for {
+		connectors, err := provider.ListConnectors(ctx, appID)
+		if err == nil {
+			for _, connector := range connectors {
+				if connector.IsConnected() {
+					return nil
+				}
+			}
+		}
+		lastErr = err
+
+		if time.Now().After(deadline) {
+			if lastErr != nil {
+				return fmt.Errorf("named tunnel did not connect within %s: %w", timeout, lastErr)
+			}
+			return fmt.Errorf("named tunnel did not connect within %s", timeout)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(interval):
+		}
+	}
 }
 
 // CreateQuickTunnelForApp adds a Quick Tunnel (temporary trycloudflare.com URL) to an app that has no tunnel.
@@ -833,6 +1469,12 @@ func (s *appService) CreateQuickTunnelForApp(ctx context.Context, appID string,
 		return nil, domain.WrapValidationError("port", fmt.Errorf("port must be between %d and %d", constants.MinPort, constants.MaxPort))
 	}
 
+	ctx, unlock, err := applock.Acquire(ctx, s.database, appID, "quick tunnel creation")
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
 	app, err := s.database.GetApp(appID)
 	if err != nil {
 		return nil, domain.WrapAppNotFound(appID, err)
@@ -849,16 +1491,10 @@ func (s *appService) CreateQuickTunnelForApp(ctx context.Context, appID string,
 		s.logger.InfoContext(ctx, "recreating existing Quick Tunnel", "appID", appID)
 	}
 
-	compose, err := docker.ParseCompose([]byte(app.ComposeContent))
-	if err != nil {
-		s.logger.WarnContext(ctx, "invalid compose file", "appID", appID, "error", err)
-		return nil, domain.WrapComposeInvalid(err)
-	}
-
 	// If recreating, try to reuse the existing metrics port, otherwise allocate a new one
 	var metricsPort int
 	if isRecreating {
-		if existingPort, ok := docker.ExtractQuickTunnelMetricsHostPort(app.ComposeContent); ok {
+		if existingPort, ok := docker.ExtractQuickTunnelMetricsHostPort(app.TunnelComposeSource()); ok {
 			metricsPort = existingPort
 			s.logger.InfoContext(ctx, "reusing existing Quick Tunnel metrics port", "appID", appID, "metricsPort", metricsPort)
 		} else {
@@ -876,37 +1512,24 @@ func (s *appService) CreateQuickTunnelForApp(ctx context.Context, appID string,
 		}
 	}
 
-	// Remove existing tunnel service if recreating
-	if isRecreating {
-		if removed := docker.RemoveTunnelService(compose); removed {
-			s.logger.InfoContext(ctx, "removed existing tunnel service before recreating", "app", app.Name)
-		}
-	}
-
 	containerConfig, err := s.tunnelService.CreateQuickTunnelConfig(strings.TrimSpace(service), port, metricsPort)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create Quick Tunnel config: %w", err)
 	}
-	networks := docker.ExtractNetworks(compose)
-	network := ""
-	if len(networks) > 0 {
-		network = networks[0]
-	}
-	s.logger.InfoContext(ctx, "injecting Quick Tunnel container into compose", "app", app.Name, "service", service, "port", port, "metricsPort", metricsPort, "isRecreating", isRecreating)
-	injected, err := docker.InjectTunnelContainer(compose, app.Name, containerConfig, network)
+	s.logger.InfoContext(ctx, "generating Quick Tunnel sidecar", "app", app.Name, "service", service, "port", port, "metricsPort", metricsPort, "isRecreating", isRecreating)
+	composeContent, tunnelCompose, err := docker.GenerateTunnelCompose(app.ComposeContent, app.ComposeFiles, app.Name, containerConfig)
 	if err != nil {
-		s.logger.ErrorContext(ctx, "failed to inject Quick Tunnel container", "app", app.Name, "error", err)
-		return nil, fmt.Errorf("failed to inject Quick Tunnel container: %w", err)
+		s.logger.WarnContext(ctx, "failed to generate Quick Tunnel sidecar", "appID", appID, "error", err)
+		return nil, domain.WrapComposeInvalid(err)
 	}
-	if !injected {
-		return nil, fmt.Errorf("Quick Tunnel container was not injected")
+	if tunnelCompose == "" {
+		return nil, fmt.Errorf("Quick Tunnel sidecar was not generated")
 	}
-	composeBytes, err := docker.MarshalComposeFile(compose)
-	if err != nil {
-		s.logger.ErrorContext(ctx, "failed to marshal compose file", "app", app.Name, "error", err)
-		return nil, fmt.Errorf("failed to marshal compose file: %w", err)
-	}
-	app.ComposeContent = string(composeBytes)
+
+	// The user's compose only changes when an inline tunnel service left by older versions was stripped
+	composeChanged := composeContent != app.ComposeContent
+	app.ComposeContent = composeContent
+	app.TunnelCompose = tunnelCompose
 	app.TunnelMode = constants.TunnelModeQuick
 	app.TunnelID = ""
 	app.TunnelToken = ""
@@ -917,98 +1540,248 @@ func (s *appService) CreateQuickTunnelForApp(ctx context.Context, appID string,
 		s.logger.ErrorContext(ctx, "failed to update app in database", "appID", appID, "error", err)
 		return nil, domain.WrapDatabaseOperation("update app", err)
 	}
+	if err := portreserve.Sync(s.database, s.config.Node.ID, app); err != nil {
+		s.logger.WarnContext(ctx, "failed to reserve Quick Tunnel metrics port", "appID", appID, "error", err)
+	}
+
+	if composeChanged {
+		latestVersion, err := s.database.GetLatestVersionNumber(appID)
+		if err != nil {
+			s.logger.WarnContext(ctx, "failed to get latest version number", "appID", appID, "error", err)
+			latestVersion = 0
+		}
+		if err := s.database.MarkAllVersionsAsNotCurrent(appID); err != nil {
+			s.logger.WarnContext(ctx, "failed to mark versions as not current", "appID", appID, "error", err)
+		}
+		updateReason := constants.ComposeVersionReasonQuickTunnel
+		newVersion := db.NewComposeVersion(appID, latestVersion+1, app.ComposeContent, &updateReason, nil)
+		newVersion.ComposeOverride = app.ComposeOverride
+		newVersion.ComposeFiles = app.ComposeFiles
+		if err := s.database.CreateComposeVersion(newVersion); err != nil {
+			s.logger.WarnContext(ctx, "failed to create compose version", "appID", appID, "error", err)
+		}
+	}
+
+	if err := s.dockerManager.WriteComposeFile(app.Name, app.ComposeContent); err != nil {
+		s.logger.ErrorContext(ctx, "failed to write compose file", "app", app.Name, "error", err)
+		return nil, domain.WrapContainerOperationFailed("write compose file", err)
+	}
+	if err := s.dockerManager.WriteTunnelComposeFile(app.Name, app.TunnelCompose); err != nil {
+		s.logger.ErrorContext(ctx, "failed to write tunnel compose file", "app", app.Name, "error", err)
+		return nil, domain.WrapContainerOperationFailed("write tunnel compose file", err)
+	}
+
+	// When recreating, use UpdateAppContainers to ensure tunnel container is properly recreated
+	// Otherwise, use StartApp for new quick tunnels
+	if isRecreating {
+		s.logger.InfoContext(ctx, "updating app containers to recreate Quick Tunnel", "app", app.Name)
+		if err := s.dockerManager.UpdateApp(app.Name); err != nil {
+			s.logger.ErrorContext(ctx, "failed to update app containers for Quick Tunnel recreation", "app", app.Name, "error", err)
+			_ = s.states.Transition(ctx, app, constants.AppStatusError, docker.ErrorDetails(err))
+			return nil, domain.WrapContainerOperationFailed("update app containers", err)
+		}
+		// Force recreate tunnel container to ensure it picks up new configuration
+		if err := s.dockerManager.ForceRecreateTunnel(app.Name); err != nil {
+			s.logger.WarnContext(ctx, "could not force-recreate tunnel container", "app", app.Name, "error", err)
+		}
+		if err := s.states.Transition(ctx, app, constants.AppStatusRunning, ""); err != nil {
+			s.logger.WarnContext(ctx, "failed to update app status after recreation", "app", app.Name, "error", err)
+		}
+	} else {
+		if err := s.dockerManager.StartApp(app.Name); err != nil {
+			s.logger.ErrorContext(ctx, "failed to start app for Quick Tunnel", "app", app.Name, "error", err)
+			_ = s.states.Transition(ctx, app, constants.AppStatusError, docker.ErrorDetails(err))
+			return nil, domain.WrapContainerOperationFailed("start app", err)
+		}
+		if err := s.states.Transition(ctx, app, constants.AppStatusRunning, ""); err != nil {
+			s.logger.WarnContext(ctx, "failed to update app status after start", "app", app.Name, "error", err)
+		}
+	}
+
+	// Delegate URL extraction to tunnel service (which uses QuickTunnelProvider)
+	// The extractor has built-in retry logic to handle startup delays
+	quickURL, err := s.tunnelService.ExtractQuickTunnelURL(ctx, appID, nodeID)
+	if err != nil {
+		s.logger.WarnContext(ctx, "failed to extract Quick Tunnel URL, URL may need to be retrieved later", "app", app.Name, "error", err)
+	} else if quickURL != "" {
+		app.PublicURL = quickURL
+		app.TunnelDomain = strings.TrimPrefix(quickURL, "https://")
+		app.UpdatedAt = time.Now()
+		if err := s.database.UpdateApp(app); err != nil {
+			s.logger.WarnContext(ctx, "failed to save Quick Tunnel URL to app", "app", app.Name, "error", err)
+		} else {
+			s.logger.InfoContext(ctx, "Quick Tunnel URL captured", "app", app.Name, "public_url", quickURL)
+		}
+	}
+	return app, nil
+}
+
+// GetQuickTunnelURL runs Quick Tunnel URL extraction on this node and returns the URL (local only).
+// Delegates to tunnel service which uses QuickTunnelProvider.
+func (s *appService) GetQuickTunnelURL(ctx context.Context, appID string, nodeID string) (string, error) {
+	app, err := s.database.GetApp(appID)
+	if err != nil {
+		return "", domain.WrapAppNotFound(appID, err)
+	}
+	if app.TunnelMode != constants.TunnelModeQuick {
+		return "", fmt.Errorf("app is not in Quick Tunnel mode (tunnel_mode=%q)", app.TunnelMode)
+	}
+
+	// Delegate to tunnel service (which uses QuickTunnelProvider)
+	return s.tunnelService.ExtractQuickTunnelURL(ctx, appID, nodeID)
+}
+
+// SetTunnelReplicas regenerates an app's tunnel sidecar with the given number of containers and, if
+// the app is running, starts or removes containers to match (local only)
+func (s *appService) SetTunnelReplicas(ctx context.Context, appID string, nodeID string, replicas int) (*db.App, error) {
+	ctx, unlock, err := applock.Acquire(ctx, s.database, appID, "tunnel replicas")
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	app, err := s.database.GetApp(appID)
+	if err != nil {
+		return nil, domain.WrapAppNotFound(appID, err)
+	}
+	if replicas < 1 {
+		return nil, domain.WrapValidationError("replicas", fmt.Errorf("replicas must be at least 1"))
+	}
+	if app.TunnelMode == constants.TunnelModeQuick {
+		return nil, domain.WrapValidationError("replicas", fmt.Errorf("each Quick Tunnel container gets its own URL, so Quick Tunnels can't be replicated; switch to a named tunnel first"))
+	}
+	if app.TunnelToken == "" {
+		return nil, domain.ErrTunnelNotFound
+	}
+
+	settings, err := s.database.GetSettings()
+	if err != nil {
+		return nil, domain.WrapDatabaseOperation("get settings", err)
+	}
+	providerName := settings.GetActiveProviderName()
+	providerConfig, err := settings.GetProviderConfig(providerName)
+	if err != nil || providerConfig == nil {
+		return nil, domain.WrapValidationError("provider", fmt.Errorf("%w: %s", tunnel.ErrProviderNotConfigured, providerName))
+	}
+	provider, err := s.providerRegistry.GetProvider(providerName, providerConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create tunnel provider: %w", err)
+	}
+	if replicas > 1 {
+		replicaProvider, ok := provider.(tunnel.ReplicaProvider)
+		if !ok {
+			return nil, domain.WrapValidationError("provider", tunnel.NewFeatureNotSupportedError(providerName, tunnel.FeatureReplicas))
+		}
+		if max := replicaProvider.MaxReplicas(); replicas > max {
+			return nil, domain.WrapValidationError("replicas", fmt.Errorf("%s allows at most %d connectors per tunnel", provider.DisplayName(), max))
+		}
+	}
+	containerProvider, ok := provider.(tunnel.ContainerProvider)
+	if !ok {
+		return nil, domain.WrapValidationError("provider", tunnel.NewFeatureNotSupportedError(providerName, tunnel.FeatureContainer))
+	}
+	containerConfig := containerProvider.GetContainerConfig(app.TunnelToken, app.Name)
+	if containerConfig == nil {
+		return nil, domain.WrapValidationError("provider", tunnel.NewFeatureNotSupportedError(providerName, tunnel.FeatureContainer))
+	}
+	containerConfig.Replicas = replicas
+	if err := s.applyConnectorOptions(ctx, provider, appID, containerConfig); err != nil {
+		return nil, err
+	}
+	if err := rewriteTunnelSidecar(s.database, s.dockerManager, app, containerConfig); err != nil {
+		return nil, err
+	}
+
+	s.logger.InfoContext(ctx, "tunnel replicas set", "app", app.Name, "appID", appID, "replicas", replicas)
+	return app, nil
+}
+
+// SetTunnelOptions saves connector options on an app's named tunnel and regenerates its sidecar
+// with them, restarting the tunnel containers if the app is running (local only).
+func (s *appService) SetTunnelOptions(ctx context.Context, appID string, nodeID string, options tunnel.ConnectorOptions) (*db.App, error) {
+	ctx, unlock, err := applock.Acquire(ctx, s.database, appID, "tunnel options")
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	app, err := s.database.GetApp(appID)
+	if err != nil {
+		return nil, domain.WrapAppNotFound(appID, err)
+	}
+	if app.TunnelMode == constants.TunnelModeQuick {
+		return nil, domain.WrapValidationError("options", fmt.Errorf("options are saved with a named tunnel; switch the Quick Tunnel to a named tunnel first"))
+	}
+	if app.TunnelToken == "" {
+		return nil, domain.ErrTunnelNotFound
+	}
 
-	latestVersion, err := s.database.GetLatestVersionNumber(appID)
+	settings, err := s.database.GetSettings()
 	if err != nil {
-		s.logger.WarnContext(ctx, "failed to get latest version number", "appID", appID, "error", err)
-		latestVersion = 0
+		return nil, domain.WrapDatabaseOperation("get settings", err)
 	}
-	if err := s.database.MarkAllVersionsAsNotCurrent(appID); err != nil {
-		s.logger.WarnContext(ctx, "failed to mark versions as not current", "appID", appID, "error", err)
+	providerName := settings.GetActiveProviderName()
+	providerConfig, err := settings.GetProviderConfig(providerName)
+	if err != nil || providerConfig == nil {
+		return nil, domain.WrapValidationError("provider", fmt.Errorf("%w: %s", tunnel.ErrProviderNotConfigured, providerName))
 	}
-	updateReason := constants.ComposeVersionReasonQuickTunnel
-	newVersion := db.NewComposeVersion(appID, latestVersion+1, app.ComposeContent, &updateReason, nil)
-	if err := s.database.CreateComposeVersion(newVersion); err != nil {
-		s.logger.WarnContext(ctx, "failed to create compose version", "appID", appID, "error", err)
+	provider, err := s.providerRegistry.GetProvider(providerName, providerConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create tunnel provider: %w", err)
 	}
-
-	if err := s.dockerManager.WriteComposeFile(app.Name, app.ComposeContent); err != nil {
-		s.logger.ErrorContext(ctx, "failed to write compose file", "app", app.Name, "error", err)
-		return nil, domain.WrapContainerOperationFailed("write compose file", err)
+	optionsProvider, ok := provider.(tunnel.ConnectorOptionsProvider)
+	if !ok {
+		return nil, domain.WrapValidationError("provider", tunnel.NewFeatureNotSupportedError(providerName, tunnel.FeatureConnectorOptions))
 	}
-
-	// When recreating, use UpdateAppContainers to ensure tunnel container is properly recreated
-	// Otherwise, use StartApp for new quick tunnels
-	if isRecreating {
-		s.logger.InfoContext(ctx, "updating app containers to recreate Quick Tunnel", "app", app.Name)
-		if err := s.dockerManager.UpdateApp(app.Name); err != nil {
-			s.logger.ErrorContext(ctx, "failed to update app containers for Quick Tunnel recreation", "app", app.Name, "error", err)
-			app.Status = constants.AppStatusError
-			em := err.Error()
-			app.ErrorMessage = &em
-			app.UpdatedAt = time.Now()
-			_ = s.database.UpdateApp(app)
-			return nil, domain.WrapContainerOperationFailed("update app containers", err)
-		}
-		// Force recreate tunnel container to ensure it picks up new configuration
-		if err := s.dockerManager.ForceRecreateTunnel(app.Name); err != nil {
-			s.logger.WarnContext(ctx, "could not force-recreate tunnel container", "app", app.Name, "error", err)
-		}
-		app.Status = constants.AppStatusRunning
-		app.ErrorMessage = nil
-		app.UpdatedAt = time.Now()
-		if err := s.database.UpdateApp(app); err != nil {
-			s.logger.WarnContext(ctx, "failed to update app status after recreation", "app", app.Name, "error", err)
+	if err := optionsProvider.SetConnectorOptions(ctx, appID, &options); err != nil {
+		if errors.Is(err, tunnel.ErrTunnelNotFound) {
+			return nil, domain.ErrTunnelNotFound
 		}
-	} else {
-		if err := s.dockerManager.StartApp(app.Name); err != nil {
-			s.logger.ErrorContext(ctx, "failed to start app for Quick Tunnel", "app", app.Name, "error", err)
-			app.Status = constants.AppStatusError
-			em := err.Error()
-			app.ErrorMessage = &em
-			app.UpdatedAt = time.Now()
-			_ = s.database.UpdateApp(app)
-			return nil, domain.WrapContainerOperationFailed("start app", err)
-		}
-		app.Status = constants.AppStatusRunning
-		app.ErrorMessage = nil
-		app.UpdatedAt = time.Now()
-		if err := s.database.UpdateApp(app); err != nil {
-			s.logger.WarnContext(ctx, "failed to update app status after start", "app", app.Name, "error", err)
+		if errors.Is(err, tunnel.ErrInvalidConfiguration) {
+			return nil, domain.WrapValidationError("options", err)
 		}
+		return nil, domain.WrapDatabaseOperation("save tunnel options", err)
 	}
 
-	// Delegate URL extraction to tunnel service (which uses QuickTunnelProvider)
-	// The extractor has built-in retry logic to handle startup delays
-	quickURL, err := s.tunnelService.ExtractQuickTunnelURL(ctx, appID, nodeID)
-	if err != nil {
-		s.logger.WarnContext(ctx, "failed to extract Quick Tunnel URL, URL may need to be retrieved later", "app", app.Name, "error", err)
-	} else if quickURL != "" {
-		app.PublicURL = quickURL
-		app.TunnelDomain = strings.TrimPrefix(quickURL, "https://")
-		app.UpdatedAt = time.Now()
-		if err := s.database.UpdateApp(app); err != nil {
-			s.logger.WarnContext(ctx, "failed to save Quick Tunnel URL to app", "app", app.Name, "error", err)
-		} else {
-			s.logger.InfoContext(ctx, "Quick Tunnel URL captured", "app", app.Name, "public_url", quickURL)
-		}
+	containerConfig := optionsProvider.GetContainerConfig(app.TunnelToken, app.Name)
+	if containerConfig == nil {
+		return nil, domain.WrapValidationError("provider", tunnel.NewFeatureNotSupportedError(providerName, tunnel.FeatureContainer))
 	}
+	containerConfig.Replicas = docker.TunnelReplicas(app.TunnelComposeSource())
+	optionsProvider.ApplyConnectorOptions(containerConfig, &options)
+	if err := rewriteTunnelSidecar(s.database, s.dockerManager, app, containerConfig); err != nil {
+		return nil, err
+	}
+
+	s.logger.InfoContext(ctx, "tunnel options set", "app", app.Name, "appID", appID, "command", strings.Join(containerConfig.Command, " "))
 	return app, nil
 }
 
-// GetQuickTunnelURL runs Quick Tunnel URL extraction on this node and returns the URL (local only).
-// Delegates to tunnel service which uses QuickTunnelProvider.
-func (s *appService) GetQuickTunnelURL(ctx context.Context, appID string, nodeID string) (string, error) {
-	app, err := s.database.GetApp(appID)
+// rewriteTunnelSidecar regenerates an app's tunnel sidecar from containerConfig, saves and writes
+// both compose files, and brings the tunnel service up to match if the app is running.
+func rewriteTunnelSidecar(database *db.DB, dockerManager *docker.Manager, app *db.App, containerConfig *tunnel.ContainerConfig) error {
+	composeContent, tunnelCompose, err := docker.GenerateTunnelCompose(app.ComposeContent, app.ComposeFiles, app.Name, containerConfig)
 	if err != nil {
-		return "", domain.WrapAppNotFound(appID, err)
+		return domain.WrapComposeInvalid(err)
 	}
-	if app.TunnelMode != constants.TunnelModeQuick {
-		return "", fmt.Errorf("app is not in Quick Tunnel mode (tunnel_mode=%q)", app.TunnelMode)
+	app.ComposeContent = composeContent
+	app.TunnelCompose = tunnelCompose
+	app.UpdatedAt = time.Now()
+	if err := database.UpdateApp(app); err != nil {
+		return domain.WrapDatabaseOperation("update app", err)
 	}
-
-	// Delegate to tunnel service (which uses QuickTunnelProvider)
-	return s.tunnelService.ExtractQuickTunnelURL(ctx, appID, nodeID)
+	if err := dockerManager.WriteComposeFile(app.Name, app.ComposeContent); err != nil {
+		return domain.WrapContainerOperationFailed("write compose file", err)
+	}
+	if err := dockerManager.WriteTunnelComposeFile(app.Name, app.TunnelCompose); err != nil {
+		return domain.WrapContainerOperationFailed("write tunnel compose file", err)
+	}
+	if app.Status == constants.AppStatusRunning {
+		if err := dockerManager.UpTunnel(app.Name); err != nil {
+			return domain.WrapContainerOperationFailed("update tunnel", err)
+		}
+	}
+	return nil
 }
 
 // RestartCloudflared restarts the cloudflared container for an app (local only; gateway routes to this node)
@@ -1072,26 +1845,64 @@ func (s *appService) RestartAppService(ctx context.Context, appID string, nodeID
 // Async Job Operations
 // ============================================================================
 
+// jobNode returns the node an async operation targeting nodeID must be queued on, or nil when
+// the job runs here. Only the primary forwards; other nodes always queue locally.
+func (s *appService) jobNode(ctx context.Context, nodeID string) (*db.Node, error) {
+	if !s.config.Node.IsPrimary || s.router.IsLocalNode(nodeID) {
+		return nil, nil
+	}
+	remote, err := s.database.GetNode(nodeID)
+	if err != nil {
+		return nil, domain.WrapNodeNotFound(nodeID, err)
+	}
+	if remote.Status != constants.NodeStatusOnline {
+		return nil, domain.WrapValidationError("node_id", fmt.Errorf("node %s is %s", remote.Name, remote.Status))
+	}
+	s.logger.InfoContext(ctx, "forwarding async job to node", "nodeID", remote.ID, "node", remote.Name)
+	return remote, nil
+}
+
 // UpdateAppContainersAsync creates a background job for app update (instead of running synchronously)
-func (s *appService) UpdateAppContainersAsync(ctx context.Context, appID string) (*db.Job, error) {
-	s.logger.InfoContext(ctx, "creating async job for app update", "appID", appID)
+func (s *appService) UpdateAppContainersAsync(ctx context.Context, appID string, nodeID string) (*db.Job, error) {
+	s.logger.InfoContext(ctx, "creating async job for app update", "appID", appID, "nodeID", nodeID)
+
+	if remote, err := s.jobNode(ctx, nodeID); err != nil {
+		return nil, err
+	} else if remote != nil {
+		return s.nodeClient.ForUser(domain.ActingUserFromContext(ctx)).UpdateAppContainersAsync(remote, appID)
+	}
 
 	// Verify app exists
 	app, err := s.database.GetApp(appID)
 	if err != nil {
 		return nil, domain.WrapAppNotFound(appID, err)
 	}
+	if err := portreserve.Sync(s.database, s.config.Node.ID, app); err != nil {
+		return nil, err
+	}
 
 	// RECOVERY: If app directory doesn't exist, recreate it from database
-	appPath := filepath.Join(s.config.AppsDir, app.Name)
+	appPath := s.dockerManager.AppPath(app.Name)
 	if _, err := os.Stat(appPath); os.IsNotExist(err) {
 		s.logger.WarnContext(ctx, "app directory missing, recreating from database",
-			"app", app.Name, "appPath", appPath)
+			"app", app.Name, "appPath", appPath, "storageRoot", app.StorageRoot)
 
 		// Recreate directory with compose file from database
-		if err := s.dockerManager.CreateAppDirectory(app.Name, app.ComposeContent); err != nil {
+		if err := s.dockerManager.CreateAppDirectoryIn(app.StorageRoot, app.Name, app.ComposeContent); err != nil {
 			return nil, fmt.Errorf("failed to recover app directory: %w", err)
 		}
+		if err := s.dockerManager.WriteComposeOverrideFile(app.Name, app.ComposeOverride); err != nil {
+			return nil, fmt.Errorf("failed to recover compose override file: %w", err)
+		}
+		if err := s.dockerManager.WriteComposeFiles(app.Name, app.ComposeFiles, nil); err != nil {
+			return nil, fmt.Errorf("failed to recover compose files: %w", err)
+		}
+		if err := s.writeEnvFile(ctx, app, ""); err != nil {
+			return nil, fmt.Errorf("failed to recover env file: %w", err)
+		}
+		if err := s.dockerManager.WriteTunnelComposeFile(app.Name, app.TunnelCompose); err != nil {
+			return nil, fmt.Errorf("failed to recover tunnel compose file: %w", err)
+		}
 
 		s.logger.InfoContext(ctx, "app directory recovered", "app", app.Name)
 	}
@@ -1107,14 +1918,33 @@ func (s *appService) UpdateAppContainersAsync(ctx context.Context, appID string)
 		return existingJob, nil // Return existing job instead of creating duplicate
 	}
 
+	// Updating pulls images; refuse while the app is still untouched if the node is nearly full
+	diskWarning, err := s.ensureDiskSpace(ctx, "update app", app.StorageRoot)
+	if err != nil {
+		return nil, err
+	}
+	composeWarning, err := s.verifyComposeFile(ctx, app)
+	if err != nil {
+		return nil, err
+	}
+
 	// Update app status to "updating"
-	app.Status = constants.AppStatusUpdating
-	if err := s.database.UpdateApp(app); err != nil {
+	if err := s.states.Transition(ctx, app, constants.AppStatusUpdating, "update job queued"); err != nil {
 		s.logger.WarnContext(ctx, "failed to update app status to updating", "appID", appID, "error", err)
 	}
 
-	// Create new job
+	// Create new job, keeping every warning
 	job := db.NewJob(constants.JobTypeAppUpdate, appID, nil)
+	var warnings []string
+	for _, warning := range []string{diskWarning, composeWarning} {
+		if warning != "" {
+			warnings = append(warnings, warning)
+		}
+	}
+	if len(warnings) > 0 {
+		message := strings.Join(warnings, "; ")
+		job.ProgressMessage = &message
+	}
 	if err := s.database.CreateJob(job); err != nil {
 		return nil, fmt.Errorf("failed to create job: %w", err)
 	}
@@ -1123,9 +1953,33 @@ func (s *appService) UpdateAppContainersAsync(ctx context.Context, appID string)
 	return job, nil
 }
 
+// rollbackCreateApp undoes a CreateApp that failed after the app was saved: it deletes the
+// provider tunnel created for it if any, its directory once it was created, and its record
+func (s *appService) rollbackCreateApp(ctx context.Context, app *db.App, tunnelProvider tunnel.Provider, removeDir bool) {
+	if tunnelProvider != nil {
+		if err := tunnelProvider.DeleteTunnel(ctx, app.ID); err != nil {
+			s.logger.ErrorContext(ctx, "failed to delete tunnel while rolling back app creation", "appID", app.ID, "error", err)
+		}
+	}
+	if removeDir {
+		if err := s.dockerManager.DeleteAppDirectory(app.Name); err != nil {
+			s.logger.ErrorContext(ctx, "failed to remove app directory while rolling back app creation", "app", app.Name, "error", err)
+		}
+	}
+	if err := s.database.DeleteApp(app.ID); err != nil {
+		s.logger.ErrorContext(ctx, "failed to rollback app creation", "appID", app.ID, "error", err)
+	}
+}
+
 // CreateAppAsync creates a background job for app creation (instead of running synchronously)
 func (s *appService) CreateAppAsync(ctx context.Context, req domain.CreateAppRequest) (*db.Job, error) {
-	s.logger.InfoContext(ctx, "creating async job for app creation", "name", req.Name)
+	s.logger.InfoContext(ctx, "creating async job for app creation", "name", req.Name, "nodeID", req.NodeID)
+
+	if remote, err := s.jobNode(ctx, req.NodeID); err != nil {
+		return nil, err
+	} else if remote != nil {
+		return s.nodeClient.ForUser(domain.ActingUserFromContext(ctx)).CreateAppAsync(remote, req)
+	}
 
 	// Validate app name
 	if err := validation.ValidateAppName(req.Name); err != nil {
@@ -1134,12 +1988,42 @@ func (s *appService) CreateAppAsync(ctx context.Context, req domain.CreateAppReq
 	}
 
 	// Validate compose content with security config
-	securityConfig := &validation.SecurityConfig{
-		AllowedVolumePaths: s.config.Security.AllowedVolumePaths,
+	securityConfig := s.composeSecurityConfig(ctx, req.ApprovePolicies)
+	if err := validation.ValidateComposeFiles(req.ComposeFiles); err != nil {
+		return nil, domain.WrapValidationError("compose files", err)
 	}
-	if err := validation.ValidateComposeContentWithConfig(req.ComposeContent, securityConfig); err != nil {
+	if err := validation.ValidateComposeContentWithFiles(req.ComposeContent, req.ComposeFiles, securityConfig); err != nil {
 		s.logger.WarnContext(ctx, "invalid compose content", "error", err)
-		return nil, domain.WrapValidationError("compose content", err)
+		return nil, composeValidationError("compose content", err)
+	}
+
+	if strings.TrimSpace(req.ComposeOverride) != "" {
+		if err := validation.ValidateComposeOverrideWithFiles(req.ComposeContent, req.ComposeOverride, req.ComposeFiles, securityConfig); err != nil {
+			s.logger.WarnContext(ctx, "invalid compose override", "error", err)
+			return nil, composeValidationError("compose override", err)
+		}
+	}
+
+	if err := validation.ValidateLabels(req.Labels); err != nil {
+		return nil, domain.WrapValidationError("labels", err)
+	}
+
+	if err := validation.ValidateEnvTemplate(req.EnvTemplate); err != nil {
+		return nil, domain.WrapValidationError("env template", err)
+	}
+
+	storageRoot, err := s.resolveStorageRoot(req.StorageRoot)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := enforceQuota(ctx, s.quotas, req.Owner, "", req.ComposeContent, req.ComposeOverride, req.ComposeFiles, req.TunnelMode != ""); err != nil {
+		return nil, err
+	}
+
+	diskWarning, err := s.ensureDiskSpace(ctx, "create app", storageRoot)
+	if err != nil {
+		return nil, err
 	}
 
 	// Determine node ID (use current node if not specified)
@@ -1150,13 +2034,27 @@ func (s *appService) CreateAppAsync(ctx context.Context, req domain.CreateAppReq
 
 	// Create app record with "pending" status
 	app := db.NewApp(req.Name, req.Description, req.ComposeContent)
+	app.ComposeOverride = req.ComposeOverride
 	app.Status = constants.AppStatusPending
 	app.NodeID = nodeID
 	app.TunnelMode = req.TunnelMode
+	app.Labels = req.Labels
+	app.ComposeFiles = req.ComposeFiles
+	app.StorageRoot = storageRoot
+	app.Owner = req.Owner
+	if err := s.renderEnvTemplate(app, req.EnvTemplate); err != nil {
+		return nil, domain.WrapValidationError("env template", err)
+	}
 
 	if err := s.database.CreateApp(app); err != nil {
 		return nil, fmt.Errorf("failed to create app: %w", err)
 	}
+	if err := portreserve.Sync(s.database, nodeID, app); err != nil {
+		if deleteErr := s.database.DeleteApp(app.ID); deleteErr != nil {
+			s.logger.ErrorContext(ctx, "failed to rollback app creation", "appID", app.ID, "error", deleteErr)
+		}
+		return nil, err
+	}
 
 	// Prepare payload
 	payload := struct {
@@ -1186,6 +2084,9 @@ func (s *appService) CreateAppAsync(ctx context.Context, req domain.CreateAppReq
 
 	// Create job
 	job := db.NewJob(constants.JobTypeAppCreate, app.ID, &payloadStr)
+	if diskWarning != "" {
+		job.ProgressMessage = &diskWarning
+	}
 	if err := s.database.CreateJob(job); err != nil {
 		return nil, fmt.Errorf("failed to create job: %w", err)
 	}
@@ -1195,11 +2096,17 @@ func (s *appService) CreateAppAsync(ctx context.Context, req domain.CreateAppReq
 }
 
 // CreateTunnelForAppAsync creates a background job for tunnel creation (instead of running synchronously)
-func (s *appService) CreateTunnelForAppAsync(ctx context.Context, appID string, ingressRules []db.IngressRule) (*db.Job, error) {
-	s.logger.InfoContext(ctx, "creating async job for tunnel creation", "appID", appID, "hasIngressRules", len(ingressRules) > 0)
+func (s *appService) CreateTunnelForAppAsync(ctx context.Context, appID string, nodeID string, ingressRules []db.IngressRule) (*db.Job, error) {
+	s.logger.InfoContext(ctx, "creating async job for tunnel creation", "appID", appID, "nodeID", nodeID, "hasIngressRules", len(ingressRules) > 0)
+
+	if remote, err := s.jobNode(ctx, nodeID); err != nil {
+		return nil, err
+	} else if remote != nil {
+		return s.nodeClient.ForUser(domain.ActingUserFromContext(ctx)).CreateTunnelForAppAsync(remote, appID, ingressRules)
+	}
 
 	// Verify app exists
-	_, err := s.database.GetApp(appID)
+	app, err := s.database.GetApp(appID)
 	if err != nil {
 		return nil, domain.WrapAppNotFound(appID, err)
 	}
@@ -1212,6 +2119,9 @@ func (s *appService) CreateTunnelForAppAsync(ctx context.Context, appID string,
 	if err != sql.ErrNoRows {
 		return nil, domain.WrapDatabaseOperation("check existing tunnel", err)
 	}
+	if err := enforceQuota(ctx, s.quotas, app.Owner, app.ID, app.ComposeContent, app.ComposeOverride, app.ComposeFiles, true); err != nil {
+		return nil, err
+	}
 
 	// Check for existing pending/running job
 	existingJob, err := s.database.GetActiveJobForApp(appID)
@@ -1248,8 +2158,14 @@ func (s *appService) CreateTunnelForAppAsync(ctx context.Context, appID string,
 }
 
 // DeleteTunnelAsync creates a background job for tunnel deletion (instead of running synchronously)
-func (s *appService) DeleteTunnelAsync(ctx context.Context, appID string) (*db.Job, error) {
-	s.logger.InfoContext(ctx, "creating async job for tunnel deletion", "appID", appID)
+func (s *appService) DeleteTunnelAsync(ctx context.Context, appID string, nodeID string, opts domain.DeleteTunnelOptions) (*db.Job, error) {
+	s.logger.InfoContext(ctx, "creating async job for tunnel deletion", "appID", appID, "nodeID", nodeID, "quickTunnelFallback", opts.QuickTunnelFallback)
+
+	if remote, err := s.jobNode(ctx, nodeID); err != nil {
+		return nil, err
+	} else if remote != nil {
+		return s.nodeClient.ForUser(domain.ActingUserFromContext(ctx)).DeleteTunnelAsync(remote, appID, opts.QuickTunnelFallback)
+	}
 
 	// Verify app exists
 	app, err := s.database.GetApp(appID)
@@ -1272,8 +2188,25 @@ func (s *appService) DeleteTunnelAsync(ctx context.Context, appID string) (*db.J
 		return existingJob, nil
 	}
 
-	// Create job (no payload needed for deletion)
-	job := db.NewJob(constants.JobTypeTunnelDelete, appID, nil)
+	// The Quick Tunnel's target is taken from the ingress rules now, while they still exist
+	var payloadStr *string
+	if opts.QuickTunnelFallback {
+		service, port, err := s.quickTunnelFallbackTarget(app)
+		if err != nil {
+			return nil, err
+		}
+		payloadBytes, err := json.Marshal(map[string]interface{}{
+			"quick_tunnel_service": service,
+			"quick_tunnel_port":    port,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("failed to marshal payload: %w", err)
+		}
+		str := string(payloadBytes)
+		payloadStr = &str
+	}
+
+	job := db.NewJob(constants.JobTypeTunnelDelete, appID, payloadStr)
 	if err := s.database.CreateJob(job); err != nil {
 		return nil, fmt.Errorf("failed to create job: %w", err)
 	}
@@ -1282,9 +2215,36 @@ func (s *appService) DeleteTunnelAsync(ctx context.Context, appID string) (*db.J
 	return job, nil
 }
 
+// quickTunnelFallbackTarget returns the compose service and port a Quick Tunnel replacing the app's
+// named tunnel should serve: the target of its first ingress rule that reaches a compose service
+// over plain HTTP
+func (s *appService) quickTunnelFallbackTarget(app *db.App) (string, int, error) {
+	if app.TunnelMode == constants.TunnelModeQuick {
+		return "", 0, domain.WrapValidationError("quick_tunnel", fmt.Errorf("the app already uses a Quick Tunnel"))
+	}
+	cfTunnel, err := s.database.GetCloudflareTunnelByAppID(app.ID)
+	if err != nil && err != sql.ErrNoRows {
+		return "", 0, domain.WrapDatabaseOperation("get tunnel", err)
+	}
+	var services []string
+	if cfTunnel != nil && cfTunnel.IngressRules != nil {
+		for _, rule := range *cfTunnel.IngressRules {
+			services = append(services, rule.Service)
+		}
+	}
+	service, port, ok, err := docker.QuickTunnelTargetFromIngress(app.ComposeContent, app.ComposeOverride, app.ComposeFiles, services)
+	if err != nil {
+		return "", 0, domain.WrapComposeInvalid(err)
+	}
+	if !ok {
+		return "", 0, domain.WrapValidationError("quick_tunnel", fmt.Errorf("no ingress rule points at a compose service over http, so there is nothing for a Quick Tunnel to serve; delete the tunnel and add a Quick Tunnel for a service yourself"))
+	}
+	return service, port, nil
+}
+
 // CreateQuickTunnelForAppAsync creates a background job for Quick Tunnel creation
-func (s *appService) CreateQuickTunnelForAppAsync(ctx context.Context, appID string, service string, port int) (*db.Job, error) {
-	s.logger.InfoContext(ctx, "creating async job for Quick Tunnel creation", "appID", appID, "service", service, "port", port)
+func (s *appService) CreateQuickTunnelForAppAsync(ctx context.Context, appID string, nodeID string, service string, port int) (*db.Job, error) {
+	s.logger.InfoContext(ctx, "creating async job for Quick Tunnel creation", "appID", appID, "nodeID", nodeID, "service", service, "port", port)
 
 	// Validate inputs
 	if strings.TrimSpace(service) == "" {
@@ -1294,6 +2254,12 @@ func (s *appService) CreateQuickTunnelForAppAsync(ctx context.Context, appID str
 		return nil, domain.WrapValidationError("port", fmt.Errorf("port must be between %d and %d", constants.MinPort, constants.MaxPort))
 	}
 
+	if remote, err := s.jobNode(ctx, nodeID); err != nil {
+		return nil, err
+	} else if remote != nil {
+		return s.nodeClient.ForUser(domain.ActingUserFromContext(ctx)).CreateQuickTunnelForAppAsync(remote, appID, service, port)
+	}
+
 	// Verify app exists
 	app, err := s.database.GetApp(appID)
 	if err != nil {
@@ -1304,6 +2270,9 @@ func (s *appService) CreateQuickTunnelForAppAsync(ctx context.Context, appID str
 	if app.TunnelMode == constants.TunnelModeCustom || (app.TunnelToken != "" && app.TunnelMode != constants.TunnelModeQuick) {
 		return nil, domain.WrapValidationError("tunnel", fmt.Errorf("this app uses a custom domain tunnel; delete the existing tunnel first if you want to use a Quick Tunnel instead"))
 	}
+	if err := enforceQuota(ctx, s.quotas, app.Owner, app.ID, app.ComposeContent, app.ComposeOverride, app.ComposeFiles, true); err != nil {
+		return nil, err
+	}
 
 	// Check for existing pending/running job
 	existingJob, err := s.database.GetActiveJobForApp(appID)
@@ -1337,8 +2306,14 @@ func (s *appService) CreateQuickTunnelForAppAsync(ctx context.Context, appID str
 }
 
 // SwitchAppToCustomTunnelAsync creates a background job to switch from Quick Tunnel to custom domain tunnel
-func (s *appService) SwitchAppToCustomTunnelAsync(ctx context.Context, appID string, ingressRules []db.IngressRule) (*db.Job, error) {
-	s.logger.InfoContext(ctx, "creating async job to switch to custom tunnel", "appID", appID, "hasIngressRules", len(ingressRules) > 0)
+func (s *appService) SwitchAppToCustomTunnelAsync(ctx context.Context, appID string, nodeID string, ingressRules []db.IngressRule) (*db.Job, error) {
+	s.logger.InfoContext(ctx, "creating async job to switch to custom tunnel", "appID", appID, "nodeID", nodeID, "hasIngressRules", len(ingressRules) > 0)
+
+	if remote, err := s.jobNode(ctx, nodeID); err != nil {
+		return nil, err
+	} else if remote != nil {
+		return s.nodeClient.ForUser(domain.ActingUserFromContext(ctx)).SwitchAppToCustomTunnelAsync(remote, appID, ingressRules)
+	}
 
 	// Verify app exists and is using Quick Tunnel
 	app, err := s.database.GetApp(appID)
@@ -1393,6 +2368,9 @@ func (s *appService) StartAppAsync(ctx context.Context, appID string) (*db.Job,
 	if err != nil {
 		return nil, domain.WrapAppNotFound(appID, err)
 	}
+	if err := portreserve.Sync(s.database, s.config.Node.ID, app); err != nil {
+		return nil, err
+	}
 
 	// Check for existing pending/running job for this app (concurrency control)
 	existingJob, err := s.database.GetActiveJobForApp(appID)
@@ -1408,19 +2386,41 @@ func (s *appService) StartAppAsync(ctx context.Context, appID string) (*db.Job,
 	}
 
 	// RECOVERY: If app directory doesn't exist, recreate it from database
-	appPath := filepath.Join(s.config.AppsDir, app.Name)
+	appPath := s.dockerManager.AppPath(app.Name)
 	if _, err := os.Stat(appPath); os.IsNotExist(err) {
 		s.logger.WarnContext(ctx, "app directory missing, recreating from database",
-			"app", app.Name, "appPath", appPath)
+			"app", app.Name, "appPath", appPath, "storageRoot", app.StorageRoot)
 
 		// Recreate directory with compose file from database
-		if err := s.dockerManager.CreateAppDirectory(app.Name, app.ComposeContent); err != nil {
+		if err := s.dockerManager.CreateAppDirectoryIn(app.StorageRoot, app.Name, app.ComposeContent); err != nil {
 			return nil, fmt.Errorf("failed to recover app directory: %w", err)
 		}
+		if err := s.dockerManager.WriteComposeOverrideFile(app.Name, app.ComposeOverride); err != nil {
+			return nil, fmt.Errorf("failed to recover compose override file: %w", err)
+		}
+		if err := s.dockerManager.WriteComposeFiles(app.Name, app.ComposeFiles, nil); err != nil {
+			return nil, fmt.Errorf("failed to recover compose files: %w", err)
+		}
+		if err := s.writeEnvFile(ctx, app, ""); err != nil {
+			return nil, fmt.Errorf("failed to recover env file: %w", err)
+		}
+		if err := s.dockerManager.WriteTunnelComposeFile(app.Name, app.TunnelCompose); err != nil {
+			return nil, fmt.Errorf("failed to recover tunnel compose file: %w", err)
+		}
 
 		s.logger.InfoContext(ctx, "app directory recovered", "app", app.Name)
 	}
 
+	// Starting pulls the images that aren't on the node yet; refuse if the node is nearly full
+	diskWarning, err := s.ensureDiskSpace(ctx, "start app", app.StorageRoot)
+	if err != nil {
+		return nil, err
+	}
+	composeWarning, err := s.verifyComposeFile(ctx, app)
+	if err != nil {
+		return nil, err
+	}
+
 	// Create app_start job
 	payload := map[string]interface{}{
 		"name": app.Name,
@@ -1434,6 +2434,16 @@ func (s *appService) StartAppAsync(ctx context.Context, appID string) (*db.Job,
 	payloadStr := &str
 
 	job := db.NewJob(constants.JobTypeAppStart, appID, payloadStr)
+	var warnings []string
+	for _, warning := range []string{diskWarning, composeWarning} {
+		if warning != "" {
+			warnings = append(warnings, warning)
+		}
+	}
+	if len(warnings) > 0 {
+		message := strings.Join(warnings, "; ")
+		job.ProgressMessage = &message
+	}
 	if err := s.database.CreateJob(job); err != nil {
 		return nil, fmt.Errorf("failed to create job: %w", err)
 	}
@@ -1484,6 +2494,94 @@ func (s *appService) StopAppAsync(ctx context.Context, appID string) (*db.Job, e
 	return job, nil
 }
 
+// RunAppCommandAsync creates a background job that runs a one-off command in one of the app's
+// services. Jobs for the same app queue behind each other, so a migration waits for a running update.
+func (s *appService) RunAppCommandAsync(ctx context.Context, appID string, req domain.RunAppCommandRequest) (*db.Job, error) {
+	s.logger.InfoContext(ctx, "creating async job to run command", "appID", appID, "service", req.Service, "mode", req.Mode)
+
+	if err := normalizeRunCommand(&req); err != nil {
+		return nil, err
+	}
+
+	app, err := s.database.GetApp(appID)
+	if err != nil {
+		return nil, domain.WrapAppNotFound(appID, err)
+	}
+	if err := checkAppService(s.dockerManager, app, req.Service); err != nil {
+		return nil, err
+	}
+
+	job, err := createRunJob(s.database, appID, req, "")
+	if err != nil {
+		return nil, err
+	}
+
+	s.logger.InfoContext(ctx, "created app run job", "appID", appID, "jobID", job.ID)
+	return job, nil
+}
+
+// normalizeRunCommand validates a command to run in one of an app's services and fills in the
+// default mode
+func normalizeRunCommand(req *domain.RunAppCommandRequest) error {
+	if strings.TrimSpace(req.Service) == "" {
+		return domain.WrapValidationError("service", fmt.Errorf("service is required"))
+	}
+	if len(req.Command) == 0 || strings.TrimSpace(req.Command[0]) == "" {
+		return domain.WrapValidationError("command", fmt.Errorf("command is required"))
+	}
+	switch req.Mode {
+	case "":
+		req.Mode = constants.RunModeRun
+	case constants.RunModeRun, constants.RunModeExec:
+	default:
+		return domain.WrapValidationError("mode", fmt.Errorf("mode must be %q or %q", constants.RunModeRun, constants.RunModeExec))
+	}
+	if maxSeconds := int(constants.RunMaxTimeout / time.Second); req.TimeoutSeconds < 0 || req.TimeoutSeconds > maxSeconds {
+		return domain.WrapValidationError("timeout_seconds", fmt.Errorf("timeout_seconds must be between 0 and %d", maxSeconds))
+	}
+	return nil
+}
+
+// checkAppService requires service to be one of the app's compose services
+func checkAppService(dockerManager *docker.Manager, app *db.App, service string) error {
+	services, err := dockerManager.GetAppServices(app.Name)
+	if err != nil {
+		return domain.WrapContainerOperationFailed("get app services", err)
+	}
+	if !slices.Contains(services, service) {
+		return domain.WrapValidationError("service", fmt.Errorf("service %q not found in app %q. Available services: %v", service, app.Name, services))
+	}
+	return nil
+}
+
+// createRunJob queues an app_run job for a normalized command; taskID marks a recurring task's run
+func createRunJob(database *db.DB, appID string, req domain.RunAppCommandRequest, taskID string) (*db.Job, error) {
+	payload := map[string]interface{}{
+		"service":         req.Service,
+		"command":         req.Command,
+		"mode":            req.Mode,
+		"timeout_seconds": req.TimeoutSeconds,
+	}
+	if taskID != "" {
+		payload["task_id"] = taskID
+	}
+	payloadBytes, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal payload: %w", err)
+	}
+	payloadStr := string(payloadBytes)
+
+	job := db.NewJob(constants.JobTypeAppRun, appID, &payloadStr)
+	if taskID != "" {
+		// Nobody waits on a recurring task's run, so it doesn't hold up what users start
+		job.Priority = constants.JobPriorityBackground
+	}
+	if err := database.CreateJob(job); err != nil {
+		return nil, fmt.Errorf("failed to create job: %w", err)
+	}
+	return job, nil
+}
+
 // CreateStartJob creates a scheduled start job for an app (called by scheduler)
 func (s *appService) CreateStartJob(ctx context.Context, appID string) error {
 	app, err := s.database.GetApp(appID)
@@ -1539,12 +2637,15 @@ func (s *appService) CreateStopJob(ctx context.Context, appID string) error {
 }
 
 // GetAppWithSchedule gets an app with its schedule information
-func (s *appService) GetAppWithSchedule(ctx context.Context, appID string, nodeID string) (*db.App, error) {
+func (s *appService) GetAppWithSchedule(ctx context.Context, appID string, nodeID string, reconcile bool) (*db.App, error) {
 	// Get the app
 	app, err := s.GetApp(ctx, appID, nodeID)
 	if err != nil {
 		return nil, err
 	}
+	if reconcile || s.config.ReconcileStatusOnRead {
+		s.statusReconciler.reconcile(ctx, app, reconcile)
+	}
 
 	// Get the schedule for this app
 	schedule, err := s.database.GetScheduleByAppID(appID)
@@ -1556,13 +2657,47 @@ func (s *appService) GetAppWithSchedule(ctx context.Context, appID string, nodeI
 	return app, nil
 }
 
-// ListAppsWithSchedules lists all apps with their schedule information
-func (s *appService) ListAppsWithSchedules(ctx context.Context, nodeIDs []string) ([]*db.App, error) {
-	// Get all apps with schedules
-	apps, err := s.database.GetAllAppsWithSchedules()
-	if err != nil {
-		return nil, fmt.Errorf("failed to get apps with schedules: %w", err)
+// ListAppsWithSchedules lists apps on the requested nodes; local apps include their schedule.
+// On the primary, apps on other nodes are included too (from the inventory cache when a node is
+// down), and the nodes that couldn't be asked are returned alongside.
+func (s *appService) ListAppsWithSchedules(ctx context.Context, nodeIDs []string, reconcile bool) ([]*db.App, []domain.NodeError, error) {
+	apps := []*db.App{}
+
+	if includesNode(nodeIDs, s.config.Node.ID) {
+		localApps, err := s.database.GetAllAppsWithSchedules()
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to get apps with schedules: %w", err)
+		}
+		if reconcile || s.config.ReconcileStatusOnRead {
+			for _, app := range localApps {
+				s.statusReconciler.reconcile(ctx, app, reconcile)
+			}
+		}
+		apps = append(apps, localApps...)
+	}
+
+	var nodeErrors []domain.NodeError
+	if s.config.Node.IsPrimary {
+		remoteApps, errs, err := s.listRemoteApps(ctx, nodeIDs)
+		if err != nil {
+			return nil, nil, err
+		}
+		apps = append(apps, remoteApps...)
+		nodeErrors = errs
 	}
 
-	return apps, nil
+	return apps, nodeErrors, nil
+}
+
+// includesNode reports whether a node_ids filter (empty or "all" meaning every node) selects nodeID
+func includesNode(nodeIDs []string, nodeID string) bool {
+	if len(nodeIDs) == 0 || (len(nodeIDs) == 1 && nodeIDs[0] == "all") {
+		return true
+	}
+	for _, id := range nodeIDs {
+		if id == nodeID {
+			return true
+		}
+	}
+	return false
 }
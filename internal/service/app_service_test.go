@@ -2,15 +2,28 @@ package service
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"log/slog"
+	"math"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/selfhostly/internal/appstate"
 	"github.com/selfhostly/internal/config"
+	"github.com/selfhostly/internal/constants"
 	"github.com/selfhostly/internal/db"
+	"github.com/selfhostly/internal/diskguard"
 	"github.com/selfhostly/internal/docker"
 	"github.com/selfhostly/internal/domain"
+	"github.com/selfhostly/internal/secretstore"
+	"github.com/selfhostly/internal/tunnel"
+	"github.com/selfhostly/internal/webhook"
 )
 
 // setupTestAppService creates a test app service with in-memory database
@@ -71,7 +84,7 @@ func setupTestAppServiceWithMocks(t *testing.T, mockExecutor docker.CommandExecu
 
 	logger := slog.Default()
 	tunnelService := NewTunnelService(database, dockerManager, cfg, logger)
-	service := NewAppService(database, dockerManager, cfg, logger, tunnelService)
+	service := NewAppService(database, dockerManager, appstate.New(database, logger), webhook.NewDispatcher(database, testNodeID, logger), secretstore.New(secretstore.Config{}), cfg, logger, tunnelService)
 
 	cleanup := func() {
 		database.Close()
@@ -122,6 +135,150 @@ func TestAppService_CreateApp(t *testing.T) {
 	}
 }
 
+func TestAppService_CreateApp_InsufficientDiskSpace(t *testing.T) {
+	service, database, cleanup := setupTestAppService(t)
+	defer cleanup()
+
+	// No real filesystem has this much free space
+	service.(*appService).diskGuard = diskguard.New(diskguard.Config{MinFreeBytes: math.MaxUint64})
+
+	ctx := context.Background()
+	req := domain.CreateAppRequest{
+		Name:           "full-disk-app",
+		ComposeContent: "version: '3'\nservices:\n  web:\n    image: nginx:latest",
+	}
+
+	if _, err := service.CreateApp(ctx, req); !domain.IsInsufficientStorageError(err) {
+		t.Errorf("Expected insufficient storage error from CreateApp, got %v", err)
+	}
+	if _, err := service.CreateAppAsync(ctx, req); !domain.IsInsufficientStorageError(err) {
+		t.Errorf("Expected insufficient storage error from CreateAppAsync, got %v", err)
+	}
+
+	apps, err := database.GetAllApps()
+	if err != nil {
+		t.Fatalf("Failed to list apps: %v", err)
+	}
+	if len(apps) != 0 {
+		t.Errorf("Expected no app record to be created, got %d", len(apps))
+	}
+}
+
+func TestAppService_StartApp_InsufficientDiskSpace(t *testing.T) {
+	service, _, cleanup := setupTestAppServiceWithMocks(t, docker.NewMockCommandExecutor())
+	defer cleanup()
+
+	ctx := context.Background()
+	app, err := service.CreateApp(ctx, domain.CreateAppRequest{
+		Name:           "full-disk-app",
+		ComposeContent: "version: '3'\nservices:\n  web:\n    image: nginx:latest",
+	})
+	if err != nil {
+		t.Fatalf("CreateApp: %v", err)
+	}
+
+	// No real filesystem has this much free space
+	service.(*appService).diskGuard = diskguard.New(diskguard.Config{MinFreeBytes: math.MaxUint64})
+
+	if _, err := service.StartApp(ctx, app.ID, app.NodeID); !domain.IsInsufficientStorageError(err) {
+		t.Errorf("Expected insufficient storage error from StartApp, got %v", err)
+	}
+	if _, err := service.StartAppAsync(ctx, app.ID); !domain.IsInsufficientStorageError(err) {
+		t.Errorf("Expected insufficient storage error from StartAppAsync, got %v", err)
+	}
+}
+
+func TestAppService_DeleteApp_ArchiveNeedsDiskSpace(t *testing.T) {
+	service, database, cleanup := setupTestAppService(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	app, err := service.CreateApp(ctx, domain.CreateAppRequest{
+		Name:           "archived-app",
+		ComposeContent: "version: '3'\nservices:\n  web:\n    image: nginx:latest",
+	})
+	if err != nil {
+		t.Fatalf("CreateApp: %v", err)
+	}
+
+	// No real filesystem has this much free space
+	service.(*appService).config.TrashDir = filepath.Join(t.TempDir(), "trash", "not-created-yet")
+	service.(*appService).diskGuard = diskguard.New(diskguard.Config{MinFreeBytes: math.MaxUint64})
+
+	if _, err := service.DeleteApp(ctx, app.ID, app.NodeID, domain.DeleteAppOptions{Archive: true}); !domain.IsInsufficientStorageError(err) {
+		t.Errorf("Expected insufficient storage error when archiving, got %v", err)
+	}
+	if _, err := database.GetApp(app.ID); err != nil {
+		t.Errorf("Expected the app to be kept when its archive can't be written, got %v", err)
+	}
+}
+
+func TestAppService_CreateApp_StorageRoot(t *testing.T) {
+	service, database, cleanup := setupTestAppService(t)
+	defer cleanup()
+
+	ssdDir := t.TempDir()
+	service.(*appService).dockerManager.SetStorageRoots(map[string]string{"ssd": ssdDir})
+
+	ctx := context.Background()
+	req := domain.CreateAppRequest{
+		Name:           "fast-app",
+		ComposeContent: "version: '3'\nservices:\n  web:\n    image: nginx:latest",
+		StorageRoot:    "ssd",
+	}
+
+	app, err := service.CreateApp(ctx, req)
+	if err != nil {
+		t.Fatalf("CreateApp: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(ssdDir, app.Name, "docker-compose.yml")); err != nil {
+		t.Errorf("Expected the app directory under the ssd root, got %v", err)
+	}
+	if stored, _ := database.GetApp(app.ID); stored.StorageRoot != "ssd" {
+		t.Errorf("Expected the storage root to be recorded, got %q", stored.StorageRoot)
+	}
+
+	req.Name, req.StorageRoot = "lost-app", "nvme"
+	if _, err := service.CreateApp(ctx, req); !domain.IsValidationError(err) {
+		t.Errorf("Expected an unknown storage root to be refused, got %v", err)
+	}
+}
+
+func TestAppService_CreateApp_ComposePolicyApproval(t *testing.T) {
+	service, database, cleanup := setupTestAppService(t)
+	defer cleanup()
+
+	settings, err := database.GetSettings()
+	if err != nil {
+		t.Fatalf("GetSettings: %v", err)
+	}
+	settings.ComposePolicyDevices = constants.ComposePolicyApproval
+	if err := database.UpdateSettings(settings); err != nil {
+		t.Fatalf("UpdateSettings: %v", err)
+	}
+
+	ctx := context.Background()
+	req := domain.CreateAppRequest{
+		Name:           "media-app",
+		ComposeContent: "services:\n  tv:\n    image: jellyfin/jellyfin\n    devices:\n      - /dev/dri:/dev/dri\n",
+	}
+	if _, err := service.CreateApp(ctx, req); !domain.IsPolicyApprovalRequiredError(err) {
+		t.Fatalf("Expected the devices to need approval, got %v", err)
+	}
+
+	req.ApprovePolicies = []string{constants.ComposePolicyDevices}
+	if _, err := service.CreateApp(ctx, req); err != nil {
+		t.Fatalf("Expected the approved app to be created, got %v", err)
+	}
+
+	// Privileged mode is still blocked
+	req.Name = "root-app"
+	req.ComposeContent = "services:\n  web:\n    image: nginx\n    privileged: true\n"
+	if _, err := service.CreateApp(ctx, req); !domain.IsValidationError(err) {
+		t.Errorf("Expected privileged mode to be refused, got %v", err)
+	}
+}
+
 func TestAppService_GetApp(t *testing.T) {
 	service, _, cleanup := setupTestAppService(t)
 	defer cleanup()
@@ -252,6 +409,225 @@ func TestAppService_UpdateApp(t *testing.T) {
 	}
 }
 
+func TestAppService_PreviewCompose(t *testing.T) {
+	service, database, cleanup := setupTestAppService(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	createdApp, err := service.CreateApp(ctx, domain.CreateAppRequest{
+		Name:           "preview-app",
+		ComposeContent: "services:\n  web:\n    image: nginx:latest\n",
+		EnvTemplate:    "PREVIEW_TAG=1.25\n",
+	})
+	if err != nil {
+		t.Fatalf("Failed to create app: %v", err)
+	}
+
+	t.Setenv("PREVIEW_SECRET", "server-only")
+	preview, err := service.PreviewCompose(ctx, createdApp.ID, domain.ComposePreviewRequest{
+		ComposeContent: "services:\n  web:\n    image: nginx:${PREVIEW_TAG}\n    environment:\n      SECRET: ${PREVIEW_SECRET:-unset}\n",
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if !strings.Contains(preview.Compose, "nginx:1.25") {
+		t.Errorf("Expected the variable to be substituted from the app's .env, got:\n%s", preview.Compose)
+	}
+	if strings.Contains(preview.Compose, "server-only") {
+		t.Errorf("Expected the server's environment not to be substituted, got:\n%s", preview.Compose)
+	}
+
+	// Previewing never saves
+	stored, err := database.GetApp(createdApp.ID)
+	if err != nil {
+		t.Fatalf("Failed to get app: %v", err)
+	}
+	if stored.ComposeContent != createdApp.ComposeContent {
+		t.Error("Expected the saved compose to be unchanged")
+	}
+}
+
+func TestAppService_ComposeFiles(t *testing.T) {
+	service, database, cleanup := setupTestAppService(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	compose := "include:\n  - db/compose.yml\nservices:\n  web:\n    image: nginx:latest\n"
+
+	// The included file has to be one of the app's compose files
+	if _, err := service.CreateApp(ctx, domain.CreateAppRequest{Name: "files-app", ComposeContent: compose}); err == nil {
+		t.Fatal("Expected an error for an include without the file")
+	}
+
+	files := map[string]string{"db/compose.yml": "services:\n  db:\n    image: postgres:16\n"}
+	createdApp, err := service.CreateApp(ctx, domain.CreateAppRequest{Name: "files-app", ComposeContent: compose, ComposeFiles: files})
+	if err != nil {
+		t.Fatalf("Failed to create app: %v", err)
+	}
+	version, err := database.GetCurrentComposeVersion(createdApp.ID)
+	if err != nil {
+		t.Fatalf("Failed to get compose version: %v", err)
+	}
+	if version.ComposeFiles["db/compose.yml"] != files["db/compose.yml"] {
+		t.Errorf("Expected the initial version to record the compose files, got %v", version.ComposeFiles)
+	}
+
+	// Files that would drop a service the compose includes are rejected
+	if _, err := service.UpdateApp(ctx, createdApp.ID, createdApp.NodeID, domain.UpdateAppRequest{ComposeFiles: map[string]string{}}); err == nil {
+		t.Error("Expected an error for removing a file the compose includes")
+	}
+
+	// Replacing the compose and its files together is a new version
+	updated, err := service.UpdateApp(ctx, createdApp.ID, createdApp.NodeID, domain.UpdateAppRequest{
+		ComposeContent: "services:\n  web:\n    image: nginx:latest\n",
+		ComposeFiles:   map[string]string{},
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(updated.ComposeFiles) != 0 {
+		t.Errorf("Expected the compose files to be removed, got %v", updated.ComposeFiles)
+	}
+	if version, err := database.GetCurrentComposeVersion(createdApp.ID); err != nil || version.Version != 2 {
+		t.Errorf("Expected version 2 to be current, got %+v, %v", version, err)
+	}
+}
+
+func TestAppService_EnvTemplate(t *testing.T) {
+	service, database, cleanup := setupTestAppService(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	compose := "services:\n  web:\n    image: nginx:latest\n"
+
+	if _, err := service.CreateApp(ctx, domain.CreateAppRequest{Name: "env-app", ComposeContent: compose, EnvTemplate: "PASSWORD={{ randAlphaNum }"}); err == nil {
+		t.Fatal("Expected an error for an invalid env template")
+	}
+
+	tmpl := "PASSWORD={{ randAlphaNum 24 }}\nAPP={{ .AppName }}\n"
+	createdApp, err := service.CreateApp(ctx, domain.CreateAppRequest{Name: "env-app", ComposeContent: compose, EnvTemplate: tmpl})
+	if err != nil {
+		t.Fatalf("Failed to create app: %v", err)
+	}
+	if !strings.Contains(createdApp.EnvContent, "APP=env-app\n") || strings.Contains(createdApp.EnvContent, "{{") {
+		t.Fatalf("Expected the env template to be rendered, got %q", createdApp.EnvContent)
+	}
+
+	// Updating something else keeps the rendered file; re-saving the template keeps its secrets
+	updated, err := service.UpdateApp(ctx, createdApp.ID, createdApp.NodeID, domain.UpdateAppRequest{Description: "changed", EnvTemplate: &tmpl})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if updated.EnvContent != createdApp.EnvContent {
+		t.Errorf("Expected the generated password to be kept, got %q then %q", createdApp.EnvContent, updated.EnvContent)
+	}
+
+	empty := ""
+	if _, err := service.UpdateApp(ctx, createdApp.ID, createdApp.NodeID, domain.UpdateAppRequest{EnvTemplate: &empty}); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if app, _ := database.GetApp(createdApp.ID); app.EnvTemplate != "" || app.EnvContent != "" {
+		t.Errorf("Expected the managed env to be removed, got %+v", app)
+	}
+}
+
+func TestAppService_EnvTemplate_SecretReferences(t *testing.T) {
+	service, database, cleanup := setupTestAppService(t)
+	defer cleanup()
+	vault := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"data":{"data":{"password":"from-vault"}}}`))
+	}))
+	defer vault.Close()
+	appSvc := service.(*appService)
+	appSvc.secrets = secretstore.New(secretstore.Config{VaultAddr: vault.URL, VaultToken: "root"})
+
+	app, err := service.CreateApp(context.Background(), domain.CreateAppRequest{
+		Name:           "vault-app",
+		ComposeContent: "services:\n  web:\n    image: nginx:latest\n",
+		EnvTemplate:    "PASSWORD=vault://secret/vault-app#password\n",
+	})
+	if err != nil {
+		t.Fatalf("Failed to create app: %v", err)
+	}
+
+	// Only the reference is stored; the secret is only in the file on the node
+	if stored, _ := database.GetApp(app.ID); strings.Contains(stored.EnvContent, "from-vault") {
+		t.Errorf("Expected the database to hold the reference only, got %q", stored.EnvContent)
+	}
+	written, _ := os.ReadFile(filepath.Join(appSvc.dockerManager.AppPath(app.Name), docker.EnvFileName))
+	if string(written) != "PASSWORD=from-vault\n" {
+		t.Errorf("Expected the secret to be resolved into .env, got %q", written)
+	}
+}
+
+func TestAppService_CreateApp_EnvFileFails(t *testing.T) {
+	service, database, cleanup := setupTestAppService(t)
+	defer cleanup()
+	vault := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer vault.Close()
+	appSvc := service.(*appService)
+	appSvc.secrets = secretstore.New(secretstore.Config{VaultAddr: vault.URL, VaultToken: "root"})
+
+	_, err := service.CreateApp(context.Background(), domain.CreateAppRequest{
+		Name:           "vault-app",
+		ComposeContent: "services:\n  web:\n    image: nginx:latest\n",
+		EnvTemplate:    "PASSWORD=vault://secret/vault-app#password\n",
+	})
+	if err == nil {
+		t.Fatal("Expected creation to fail when the secret can't be resolved")
+	}
+
+	// Nothing of the app is left, so its name can be used again
+	if apps, _ := database.GetAllApps(); len(apps) != 0 {
+		t.Errorf("Expected the app record to be rolled back, got %d apps", len(apps))
+	}
+	if _, exists := appSvc.dockerManager.AppDirectory("vault-app"); exists {
+		t.Error("Expected the app directory to be removed")
+	}
+}
+
+func TestAppService_UpdateApp_Labels(t *testing.T) {
+	service, database, cleanup := setupTestAppService(t)
+	defer cleanup()
+
+	ctx := context.Background()
+
+	createdApp, err := service.CreateApp(ctx, domain.CreateAppRequest{
+		Name:           "test-app",
+		ComposeContent: "version: '3'\nservices:\n  web:\n    image: nginx:latest",
+		Labels:         map[string]string{"env": "prod"},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create app: %v", err)
+	}
+	if app, _ := database.GetApp(createdApp.ID); app.Labels["env"] != "prod" {
+		t.Fatalf("Expected labels to be stored, got %v", app.Labels)
+	}
+
+	// Leaving labels out keeps them
+	if _, err := service.UpdateApp(ctx, createdApp.ID, createdApp.NodeID, domain.UpdateAppRequest{Description: "Updated"}); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if app, _ := database.GetApp(createdApp.ID); len(app.Labels) != 1 {
+		t.Errorf("Expected labels kept, got %v", app.Labels)
+	}
+
+	// An empty map clears them
+	if _, err := service.UpdateApp(ctx, createdApp.ID, createdApp.NodeID, domain.UpdateAppRequest{Labels: map[string]string{}}); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if app, _ := database.GetApp(createdApp.ID); len(app.Labels) != 0 {
+		t.Errorf("Expected labels cleared, got %v", app.Labels)
+	}
+
+	_, err = service.UpdateApp(ctx, createdApp.ID, createdApp.NodeID, domain.UpdateAppRequest{Labels: map[string]string{"bad key": "x"}})
+	if !domain.IsValidationError(err) {
+		t.Errorf("Expected a validation error for an invalid label, got %v", err)
+	}
+}
+
 func TestAppService_DeleteApp(t *testing.T) {
 	mockExecutor := docker.NewMockCommandExecutor()
 	service, _, cleanup := setupTestAppServiceWithMocks(t, mockExecutor)
@@ -275,10 +651,13 @@ func TestAppService_DeleteApp(t *testing.T) {
 	mockExecutor.SetMockOutput("docker", []string{"compose", "-f", "docker-compose.yml", "down"}, []byte("success"))
 
 	// Delete the app
-	err = service.DeleteApp(ctx, createdApp.ID, createdApp.NodeID)
+	result, err := service.DeleteApp(ctx, createdApp.ID, createdApp.NodeID, domain.DeleteAppOptions{})
 	if err != nil {
 		t.Fatalf("Expected no error, got %v", err)
 	}
+	if result.ArchivePath != "" {
+		t.Errorf("Expected no archive without the archive option, got %s", result.ArchivePath)
+	}
 
 	// Verify app no longer exists
 	_, err = service.GetApp(ctx, createdApp.ID, createdApp.NodeID)
@@ -291,6 +670,32 @@ func TestAppService_DeleteApp(t *testing.T) {
 	}
 }
 
+func TestAppService_DeleteApp_ForceUnreachableNode(t *testing.T) {
+	service, database, cleanup := setupTestAppServiceWithMocks(t, docker.NewMockCommandExecutor())
+	defer cleanup()
+	ctx := context.Background()
+
+	remote := db.NewNode("remote", "http://remote:8080", "remote-key", false)
+	if err := database.CreateNode(remote); err != nil {
+		t.Fatalf("Failed to create node: %v", err)
+	}
+	cached := &db.CachedApp{AppID: "remote-app", Name: "remote-app", Status: "running", UpdatedAt: time.Now()}
+	if _, err := database.SyncNodeAppCache(remote.ID, []*db.CachedApp{cached}, []string{cached.AppID}); err != nil {
+		t.Fatalf("Failed to cache app: %v", err)
+	}
+
+	if _, err := service.DeleteApp(ctx, cached.AppID, remote.ID, domain.DeleteAppOptions{}); !domain.IsNotFoundError(err) {
+		t.Errorf("Expected a plain delete of a remote app to be not found here, got %v", err)
+	}
+
+	if _, err := service.DeleteApp(ctx, cached.AppID, remote.ID, domain.DeleteAppOptions{Force: true}); err != nil {
+		t.Fatalf("Expected force delete to drop the cached app, got %v", err)
+	}
+	if apps, _ := database.GetNodeAppCache(remote.ID); len(apps) != 0 {
+		t.Errorf("Expected the node's cached inventory to be empty, got %d apps", len(apps))
+	}
+}
+
 // TestAppService_StartApp tests starting an app with mocked Docker commands
 func TestAppService_StartApp(t *testing.T) {
 	mockExecutor := docker.NewMockCommandExecutor()
@@ -604,6 +1009,347 @@ func TestAppService_RestartCloudflared(t *testing.T) {
 	}
 }
 
+func TestAppService_RunAppCommandAsync(t *testing.T) {
+	mockExecutor := docker.NewMockCommandExecutor()
+	service, database, cleanup := setupTestAppServiceWithMocks(t, mockExecutor)
+	defer cleanup()
+
+	ctx := context.Background()
+	createdApp, err := service.CreateApp(ctx, domain.CreateAppRequest{
+		Name:           "run-app",
+		ComposeContent: "services:\n  web:\n    image: nginx:latest\n",
+	})
+	if err != nil {
+		t.Fatalf("Failed to create app: %v", err)
+	}
+	mockExecutor.SetMockOutput("docker", []string{"compose", "-f", "docker-compose.yml", "config", "--services"}, []byte("web\n"))
+
+	invalid := map[string]domain.RunAppCommandRequest{
+		"no command":      {Service: "web"},
+		"unknown mode":    {Service: "web", Command: []string{"true"}, Mode: "attach"},
+		"unknown service": {Service: "worker", Command: []string{"true"}},
+	}
+	for name, req := range invalid {
+		if _, err := service.RunAppCommandAsync(ctx, createdApp.ID, req); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+
+	job, err := service.RunAppCommandAsync(ctx, createdApp.ID, domain.RunAppCommandRequest{Service: "web", Command: []string{"nginx", "-t"}})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if job.Type != constants.JobTypeAppRun || job.Payload == nil {
+		t.Fatalf("Unexpected job: %+v", job)
+	}
+	var payload struct {
+		Service string   `json:"service"`
+		Command []string `json:"command"`
+		Mode    string   `json:"mode"`
+	}
+	if err := json.Unmarshal([]byte(*job.Payload), &payload); err != nil {
+		t.Fatalf("Failed to decode payload: %v", err)
+	}
+	if payload.Service != "web" || len(payload.Command) != 2 || payload.Mode != constants.RunModeRun {
+		t.Errorf("Unexpected payload: %+v", payload)
+	}
+	if _, err := database.GetJob(job.ID); err != nil {
+		t.Errorf("Expected the job to be stored: %v", err)
+	}
+}
+
 // Note: Cloudflare API tests are in tunnel_service_test.go since app_service
 // creates TunnelManager internally and doesn't support dependency injection.
 // Docker command tests are fully covered above with mocked CommandExecutor.
+
+func TestAppService_AsyncJobsForwardToNode(t *testing.T) {
+	service, database, cleanup := setupTestAppService(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	var gotMethod, gotPath string
+	remote := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotMethod, gotPath = r.Method, r.URL.RequestURI()
+		w.WriteHeader(http.StatusAccepted)
+		json.NewEncoder(w).Encode(map[string]string{"job_id": "remote-job", "app_id": "remote-app", "status": constants.JobStatusPending})
+	}))
+	defer remote.Close()
+
+	worker := db.NewNodeWithID("worker", "worker", remote.URL, "key", false)
+	worker.Status = constants.NodeStatusOnline
+	offline := db.NewNodeWithID("offline", "offline", "http://127.0.0.1:1", "key", false)
+	offline.Status = constants.NodeStatusOffline
+	for _, n := range []*db.Node{worker, offline} {
+		if err := database.CreateNode(n); err != nil {
+			t.Fatalf("CreateNode: %v", err)
+		}
+	}
+
+	job, err := service.UpdateAppContainersAsync(ctx, "remote-app", "worker")
+	if err != nil {
+		t.Fatalf("UpdateAppContainersAsync: %v", err)
+	}
+	if job.ID != "remote-job" || job.NodeID != "worker" || gotMethod != http.MethodPost || gotPath != "/api/apps/remote-app/update" {
+		t.Errorf("Expected the update to be queued on the worker, got %+v via %s %s", job, gotMethod, gotPath)
+	}
+
+	if _, err := service.DeleteTunnelAsync(ctx, "remote-app", "worker", domain.DeleteTunnelOptions{QuickTunnelFallback: true}); err != nil {
+		t.Fatalf("DeleteTunnelAsync: %v", err)
+	}
+	if gotMethod != http.MethodDelete || gotPath != "/api/tunnels/apps/remote-app?quick_tunnel=true" {
+		t.Errorf("Expected the tunnel deletion to be forwarded, got %s %s", gotMethod, gotPath)
+	}
+
+	req := domain.CreateAppRequest{Name: "remote-app", ComposeContent: "services:\n  web:\n    image: nginx:latest\n", NodeID: "worker"}
+	if _, err := service.CreateAppAsync(ctx, req); err != nil {
+		t.Fatalf("CreateAppAsync: %v", err)
+	}
+	if gotPath != "/api/apps?async=true" {
+		t.Errorf("Expected the creation to be forwarded, got %s %s", gotMethod, gotPath)
+	}
+	if apps, _ := database.GetAllApps(); len(apps) != 0 {
+		t.Errorf("Expected no local app record for a forwarded creation, got %d", len(apps))
+	}
+
+	if _, err := service.UpdateAppContainersAsync(ctx, "remote-app", "offline"); !domain.IsValidationError(err) {
+		t.Errorf("Expected a validation error for an offline node, got %v", err)
+	}
+	if _, err := service.UpdateAppContainersAsync(ctx, "remote-app", "missing"); !domain.IsNotFoundError(err) {
+		t.Errorf("Expected not found for an unknown node, got %v", err)
+	}
+}
+
+// connectorProviderStub reports connectors from a list, one call at a time
+type connectorProviderStub struct {
+	tunnel.Provider
+	responses [][]*tunnel.Connector
+	calls     int
+}
+
+func (p *connectorProviderStub) ListConnectors(ctx context.Context, appID string) ([]*tunnel.Connector, error) {
+	p.calls++
+	if p.calls > len(p.responses) {
+		return p.responses[len(p.responses)-1], nil
+	}
+	return p.responses[p.calls-1], nil
+}
+
+func TestWaitForTunnelConnector(t *testing.T) {
+	ctx := context.Background()
+	reconnecting := &tunnel.Connector{ID: "c1", Connections: []*tunnel.Connection{{Location: "ams01", PendingReconnect: true}}}
+	connected := &tunnel.Connector{ID: "c1", Connections: []*tunnel.Connection{{Location: "ams01"}, {Location: "fra02"}}}
+
+	provider := &connectorProviderStub{responses: [][]*tunnel.Connector{{}, {reconnecting}, {connected}}}
+	if err := waitForTunnelConnector(ctx, provider, "app-1", time.Second, time.Millisecond); err != nil {
+		t.Fatalf("Expected the tunnel to be found connected, got %v", err)
+	}
+	if provider.calls != 3 {
+		t.Errorf("Expected polling to stop at the first connected connector, got %d calls", provider.calls)
+	}
+
+	provider = &connectorProviderStub{responses: [][]*tunnel.Connector{{reconnecting}}}
+	if err := waitForTunnelConnector(ctx, provider, "app-1", 20*time.Millisecond, time.Millisecond); err == nil || !strings.Contains(err.Error(), "did not connect") {
+		t.Errorf("Expected a tunnel with no established connection to time out, got %v", err)
+	}
+
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	provider = &connectorProviderStub{responses: [][]*tunnel.Connector{{}}}
+	if err := waitForTunnelConnector(cancelled, provider, "app-1", time.Minute, time.Minute); !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected waiting to stop with the context, got %v", err)
+	}
+}
+
+func TestAppService_DeleteTunnelAsync_QuickTunnelFallback(t *testing.T) {
+	service, database, cleanup := setupTestAppService(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	app := db.NewApp("blog", "", "services:\n  web:\n    image: nginx:latest\n  db:\n    image: postgres:16\n")
+	app.TunnelID = "tunnel-123"
+	app.TunnelMode = constants.TunnelModeCustom
+	if err := database.CreateApp(app); err != nil {
+		t.Fatalf("CreateApp: %v", err)
+	}
+	cfTunnel := db.NewCloudflareTunnel(app.ID, app.TunnelID, app.Name, "token", "account", "https://blog.example.com")
+	hostname := "blog.example.com"
+	cfTunnel.IngressRules = &[]db.IngressRule{{Hostname: &hostname, Service: "http://web:8080"}, {Service: "http_status:404"}}
+	if err := database.CreateCloudflareTunnel(cfTunnel); err != nil {
+		t.Fatalf("CreateCloudflareTunnel: %v", err)
+	}
+
+	job, err := service.DeleteTunnelAsync(ctx, app.ID, "", domain.DeleteTunnelOptions{QuickTunnelFallback: true})
+	if err != nil {
+		t.Fatalf("DeleteTunnelAsync: %v", err)
+	}
+	var payload struct {
+		Service string `json:"quick_tunnel_service"`
+		Port    int    `json:"quick_tunnel_port"`
+	}
+	if job.Payload == nil || json.Unmarshal([]byte(*job.Payload), &payload) != nil || payload.Service != "web" || payload.Port != 8080 {
+		t.Errorf("Expected the job to carry the ingress target web:8080, got %v", job.Payload)
+	}
+
+	// Without a rule reaching a compose service there is nothing to fall back to
+	if err := database.UpdateJobStatus(job.ID, constants.JobStatusCompleted, 100, nil); err != nil {
+		t.Fatalf("UpdateJobStatus: %v", err)
+	}
+	cfTunnel.IngressRules = &[]db.IngressRule{{Hostname: &hostname, Service: "https://192.168.1.20:8443"}}
+	if err := database.UpdateCloudflareTunnel(cfTunnel); err != nil {
+		t.Fatalf("UpdateCloudflareTunnel: %v", err)
+	}
+	if _, err := service.DeleteTunnelAsync(ctx, app.ID, "", domain.DeleteTunnelOptions{QuickTunnelFallback: true}); !domain.IsValidationError(err) {
+		t.Errorf("Expected a validation error without a usable ingress rule, got %v", err)
+	}
+	job, err = service.DeleteTunnelAsync(ctx, app.ID, "", domain.DeleteTunnelOptions{})
+	if err != nil || job.Payload != nil {
+		t.Errorf("Expected a plain deletion job without the fallback, got %+v (err %v)", job, err)
+	}
+}
+
+func TestAppService_SetTunnelReplicas(t *testing.T) {
+	service, database, cleanup := setupTestAppService(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	settings, err := database.GetSettings()
+	if err != nil {
+		t.Fatalf("GetSettings: %v", err)
+	}
+	activeProvider := "cloudflare"
+	providerConfig := `{"cloudflare":{"api_token":"test-api-token","account_id":"test-account-id"}}`
+	settings.ActiveTunnelProvider, settings.TunnelProviderConfig = &activeProvider, &providerConfig
+	if err := database.UpdateSettings(settings); err != nil {
+		t.Fatalf("UpdateSettings: %v", err)
+	}
+	app, err := service.CreateApp(ctx, domain.CreateAppRequest{Name: "blog", ComposeContent: "services:\n  web:\n    image: nginx:latest\n"})
+	if err != nil {
+		t.Fatalf("CreateApp: %v", err)
+	}
+	app.TunnelID = "tunnel-123"
+	app.TunnelToken = "token"
+	app.TunnelMode = constants.TunnelModeCustom
+	if err := database.UpdateApp(app); err != nil {
+		t.Fatalf("UpdateApp: %v", err)
+	}
+
+	updated, err := service.SetTunnelReplicas(ctx, app.ID, "", 3)
+	if err != nil {
+		t.Fatalf("SetTunnelReplicas: %v", err)
+	}
+	if got := docker.TunnelReplicas(updated.TunnelCompose); got != 3 {
+		t.Errorf("Expected the tunnel sidecar to run 3 replicas, got %d\n%s", got, updated.TunnelCompose)
+	}
+	stored, _ := database.GetApp(app.ID)
+	if got := docker.TunnelReplicas(stored.TunnelCompose); got != 3 {
+		t.Errorf("Expected the stored sidecar to run 3 replicas, got %d", got)
+	}
+
+	// Regenerating the sidecar for a compose change keeps the replicas
+	if _, err := service.UpdateApp(ctx, app.ID, "", domain.UpdateAppRequest{ComposeContent: "services:\n  web:\n    image: nginx:1.27\n"}); err != nil {
+		t.Fatalf("UpdateApp: %v", err)
+	}
+	stored, _ = database.GetApp(app.ID)
+	if got := docker.TunnelReplicas(stored.TunnelCompose); got != 3 {
+		t.Errorf("Expected a compose update to keep 3 replicas, got %d", got)
+	}
+
+	for _, replicas := range []int{0, 26} {
+		if _, err := service.SetTunnelReplicas(ctx, app.ID, "", replicas); !domain.IsValidationError(err) {
+			t.Errorf("Expected %d replicas to be refused, got %v", replicas, err)
+		}
+	}
+
+	app.TunnelMode = constants.TunnelModeQuick
+	if err := database.UpdateApp(app); err != nil {
+		t.Fatalf("UpdateApp: %v", err)
+	}
+	if _, err := service.SetTunnelReplicas(ctx, app.ID, "", 2); !domain.IsValidationError(err) {
+		t.Errorf("Expected a Quick Tunnel to be refused, got %v", err)
+	}
+}
+
+func TestAppService_SetTunnelOptions(t *testing.T) {
+	service, database, cleanup := setupTestAppService(t)
+	defer cleanup()
+	ctx := context.Background()
+
+	settings, err := database.GetSettings()
+	if err != nil {
+		t.Fatalf("GetSettings: %v", err)
+	}
+	activeProvider := "cloudflare"
+	providerConfig := `{"cloudflare":{"api_token":"test-api-token","account_id":"test-account-id"}}`
+	settings.ActiveTunnelProvider, settings.TunnelProviderConfig = &activeProvider, &providerConfig
+	if err := database.UpdateSettings(settings); err != nil {
+		t.Fatalf("UpdateSettings: %v", err)
+	}
+	app, err := service.CreateApp(ctx, domain.CreateAppRequest{Name: "blog", ComposeContent: "services:\n  web:\n    image: nginx:latest\n"})
+	if err != nil {
+		t.Fatalf("CreateApp: %v", err)
+	}
+	if _, err := service.SetTunnelOptions(ctx, app.ID, "", tunnel.ConnectorOptions{Protocol: "http2"}); !errors.Is(err, domain.ErrTunnelNotFound) {
+		t.Errorf("Expected an app without a tunnel to return ErrTunnelNotFound, got %v", err)
+	}
+
+	app.TunnelID = "tunnel-123"
+	app.TunnelToken = "token"
+	app.TunnelMode = constants.TunnelModeCustom
+	if err := database.UpdateApp(app); err != nil {
+		t.Fatalf("UpdateApp: %v", err)
+	}
+	if err := database.CreateCloudflareTunnel(db.NewCloudflareTunnel(app.ID, app.TunnelID, app.Name, "token", "account", "")); err != nil {
+		t.Fatalf("CreateCloudflareTunnel: %v", err)
+	}
+
+	const command = "tunnel --protocol http2 --edge-ip-version 4 --loglevel debug run"
+	updated, err := service.SetTunnelOptions(ctx, app.ID, "", tunnel.ConnectorOptions{Protocol: "http2", EdgeIPVersion: "4", LogLevel: "debug"})
+	if err != nil {
+		t.Fatalf("SetTunnelOptions: %v", err)
+	}
+	if !strings.Contains(updated.TunnelCompose, command) {
+		t.Errorf("Expected the tunnel sidecar to run %q, got\n%s", command, updated.TunnelCompose)
+	}
+	cfTunnel, err := database.GetCloudflareTunnelByAppID(app.ID)
+	if err != nil {
+		t.Fatalf("GetCloudflareTunnelByAppID: %v", err)
+	}
+	if cfTunnel.Options == nil || cfTunnel.Options.Protocol != "http2" || cfTunnel.Options.LogLevel != "debug" {
+		t.Errorf("Expected the options to be saved with the tunnel, got %+v", cfTunnel.Options)
+	}
+
+	// Replica changes and compose updates regenerate the sidecar with the saved options
+	if _, err := service.SetTunnelReplicas(ctx, app.ID, "", 2); err != nil {
+		t.Fatalf("SetTunnelReplicas: %v", err)
+	}
+	if _, err := service.UpdateApp(ctx, app.ID, "", domain.UpdateAppRequest{ComposeContent: "services:\n  web:\n    image: nginx:1.27\n"}); err != nil {
+		t.Fatalf("UpdateApp: %v", err)
+	}
+	stored, _ := database.GetApp(app.ID)
+	if !strings.Contains(stored.TunnelCompose, command) || docker.TunnelReplicas(stored.TunnelCompose) != 2 {
+		t.Errorf("Expected the sidecar to keep its options and 2 replicas, got\n%s", stored.TunnelCompose)
+	}
+
+	for _, options := range []tunnel.ConnectorOptions{
+		{Protocol: "udp"},
+		{EdgeIPVersion: "5"},
+		{LogLevel: "trace"},
+		{Protocol: "http2", PostQuantum: true},
+	} {
+		if _, err := service.SetTunnelOptions(ctx, app.ID, "", options); !domain.IsValidationError(err) {
+			t.Errorf("Expected %+v to be refused, got %v", options, err)
+		}
+	}
+
+	// Clearing the options restores cloudflared's default command
+	updated, err = service.SetTunnelOptions(ctx, app.ID, "", tunnel.ConnectorOptions{})
+	if err != nil {
+		t.Fatalf("SetTunnelOptions: %v", err)
+	}
+	if strings.Contains(updated.TunnelCompose, "--protocol") {
+		t.Errorf("Expected cleared options to drop the flags, got\n%s", updated.TunnelCompose)
+	}
+	if cfTunnel, _ := database.GetCloudflareTunnelByAppID(app.ID); cfTunnel.Options != nil {
+		t.Errorf("Expected cleared options to be stored as NULL, got %+v", cfTunnel.Options)
+	}
+}
@@ -0,0 +1,451 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"maps"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/selfhostly/internal/config"
+	"github.com/selfhostly/internal/constants"
+	"github.com/selfhostly/internal/db"
+	"github.com/selfhostly/internal/docker"
+	"github.com/selfhostly/internal/domain"
+	"github.com/selfhostly/internal/node"
+	"github.com/selfhostly/internal/validation"
+)
+
+// applyService reconciles apps across the cluster with a declarative manifest. Writes to apps
+// on this node go through the local services; writes to apps on other nodes go through the node
+// client, as the gateway would route them.
+type applyService struct {
+	database      *db.DB
+	appService    domain.AppService
+	tunnelService domain.TunnelService
+	nodeClient    *node.Client
+	config        *config.Config
+	logger        *slog.Logger
+}
+
+// NewApplyService creates a new declarative apply service
+func NewApplyService(
+	database *db.DB,
+	appService domain.AppService,
+	tunnelService domain.TunnelService,
+	cfg *config.Config,
+	logger *slog.Logger,
+) domain.ApplyService {
+	return &applyService{
+		database:      database,
+		appService:    appService,
+		tunnelService: tunnelService,
+		nodeClient:    node.NewClientWithTimeouts(cfg.Timeouts),
+		config:        cfg,
+		logger:        logger,
+	}
+}
+
+// plannedApp ties a planned action to the declared app and the existing one it acts on
+type plannedApp struct {
+	action   *domain.ApplyAction
+	declared *domain.ManifestApp
+	existing *db.App
+	target   applyTarget
+	// ingress is set when a custom tunnel's rules need replacing
+	ingress []db.IngressRule
+}
+
+// Apply plans the changes that bring the cluster's apps in line with manifest and, unless dryRun
+// is set, carries them out: creates first, then updates, then deletes. A failed action is
+// recorded on its entry and doesn't stop the others.
+func (s *applyService) Apply(ctx context.Context, manifest domain.AppManifest, dryRun bool) (*domain.ApplyResult, error) {
+	if !s.config.Node.IsPrimary {
+		return nil, domain.WrapValidationError("apply", fmt.Errorf("apply runs on the primary node"))
+	}
+	// Pruning would delete apps without the second confirmation deletions need
+	if manifest.Prune && !dryRun && s.config.Approvals.Required {
+		return nil, domain.WrapValidationError("prune", fmt.Errorf("prune is not available while APPROVAL_REQUIRED is on; delete the apps one by one"))
+	}
+
+	nodes, err := s.database.GetAllNodes()
+	if err != nil {
+		return nil, domain.WrapDatabaseOperation("list nodes", err)
+	}
+	declaredNodes, err := s.validateManifest(manifest, nodes)
+	if err != nil {
+		return nil, err
+	}
+
+	existing, err := s.appService.ListApps(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	byName := make(map[string]*db.App, len(existing))
+	for _, app := range existing {
+		byName[app.Name] = app
+	}
+
+	var plan []*plannedApp
+	declared := make(map[string]bool, len(manifest.Apps))
+	for i := range manifest.Apps {
+		want := &manifest.Apps[i]
+		declared[want.Name] = true
+		plan = append(plan, s.planApp(ctx, want, declaredNodes[i], byName[want.Name]))
+	}
+	if manifest.Prune {
+		for _, app := range existing {
+			if declared[app.Name] {
+				continue
+			}
+			p := &plannedApp{
+				action:   &domain.ApplyAction{App: app.Name, AppID: app.ID, NodeID: app.NodeID, Action: constants.ApplyActionDelete},
+				existing: app,
+				target:   s.targetFor(ctx, nodeByID(nodes, app.NodeID)),
+			}
+			if app.Stale {
+				p.action.Error = fmt.Sprintf("node %s is unreachable", app.NodeID)
+			}
+			plan = append(plan, p)
+		}
+	}
+
+	result := &domain.ApplyResult{DryRun: dryRun, Actions: make([]*domain.ApplyAction, 0, len(plan))}
+	for _, p := range plan {
+		result.Actions = append(result.Actions, p.action)
+	}
+
+	if !dryRun {
+		for _, action := range []string{constants.ApplyActionCreate, constants.ApplyActionUpdate, constants.ApplyActionDelete} {
+			for _, p := range plan {
+				if p.action.Action != action || p.action.Error != "" {
+					continue
+				}
+				if err := s.execute(ctx, p); err != nil {
+					s.logger.WarnContext(ctx, "apply action failed", "app", p.action.App, "action", p.action.Action, "error", err)
+					p.action.Error = domain.PublicMessage(err)
+				}
+			}
+		}
+	}
+
+	for _, action := range result.Actions {
+		if action.Error != "" {
+			result.Failed++
+		}
+	}
+	s.logger.InfoContext(ctx, "applied manifest", "apps", len(manifest.Apps), "actions", len(result.Actions), "failed", result.Failed, "dryRun", dryRun)
+	return result, nil
+}
+
+// validateManifest rejects manifests that can't be planned, and resolves each app's node
+func (s *applyService) validateManifest(manifest domain.AppManifest, nodes []*db.Node) ([]*db.Node, error) {
+	resolved := make([]*db.Node, len(manifest.Apps))
+	seen := make(map[string]bool, len(manifest.Apps))
+	for i, app := range manifest.Apps {
+		field := fmt.Sprintf("apps[%d]", i)
+		if err := validation.ValidateAppName(app.Name); err != nil {
+			return nil, domain.WrapValidationError(field+".name", err)
+		}
+		if seen[app.Name] {
+			return nil, domain.WrapValidationError(field+".name", fmt.Errorf("app %q is declared more than once", app.Name))
+		}
+		seen[app.Name] = true
+
+		if strings.TrimSpace(app.Compose) == "" {
+			return nil, domain.WrapValidationError(field+".compose", fmt.Errorf("compose is required"))
+		}
+		for name := range app.Env {
+			if !docker.ValidEnvName(name) {
+				return nil, domain.WrapValidationError(field+".env", fmt.Errorf("invalid variable name %q", name))
+			}
+		}
+		if err := validation.ValidateLabels(app.Labels); err != nil {
+			return nil, domain.WrapValidationError(field+".labels", err)
+		}
+		if err := validation.ValidateEnvTemplate(app.EnvTemplate); err != nil {
+			return nil, domain.WrapValidationError(field+".env_template", err)
+		}
+
+		if app.Tunnel != nil {
+			switch app.Tunnel.Mode {
+			case constants.TunnelModeCustom:
+			case constants.TunnelModeQuick:
+				if strings.TrimSpace(app.Tunnel.Service) == "" {
+					return nil, domain.WrapValidationError(field+".tunnel.service", fmt.Errorf("service is required for Quick Tunnel mode"))
+				}
+				if app.Tunnel.Port < constants.MinPort || app.Tunnel.Port > constants.MaxPort {
+					return nil, domain.WrapValidationError(field+".tunnel.port", fmt.Errorf("port must be between %d and %d", constants.MinPort, constants.MaxPort))
+				}
+			default:
+				return nil, domain.WrapValidationError(field+".tunnel.mode", fmt.Errorf("mode must be %q or %q", constants.TunnelModeCustom, constants.TunnelModeQuick))
+			}
+		}
+
+		nodeRef := app.Node
+		if nodeRef == "" {
+			nodeRef = s.config.Node.ID
+		}
+		for _, n := range nodes {
+			if n.ID == nodeRef || n.Name == nodeRef {
+				resolved[i] = n
+				break
+			}
+		}
+		if resolved[i] == nil {
+			return nil, domain.WrapValidationError(field+".node", fmt.Errorf("unknown node %q", nodeRef))
+		}
+	}
+	return resolved, nil
+}
+
+// planApp works out what brings one declared app in line with the existing app of that name
+func (s *applyService) planApp(ctx context.Context, want *domain.ManifestApp, onNode *db.Node, current *db.App) *plannedApp {
+	p := &plannedApp{
+		action:   &domain.ApplyAction{App: want.Name, NodeID: onNode.ID},
+		declared: want,
+		target:   s.targetFor(ctx, onNode),
+	}
+
+	if current == nil {
+		p.action.Action = constants.ApplyActionCreate
+		return p
+	}
+	p.action.AppID = current.ID
+	p.action.Action = constants.ApplyActionUpdate
+	if current.NodeID != onNode.ID {
+		p.action.Error = fmt.Sprintf("app already exists on node %s; apply doesn't move apps between nodes", current.NodeID)
+		return p
+	}
+	if current.Stale {
+		p.action.Error = fmt.Sprintf("node %s is unreachable", current.NodeID)
+		return p
+	}
+
+	// Listings may leave out compose content, so compare against the full record
+	full, err := p.target.getApp(ctx, current.ID)
+	if err != nil {
+		p.action.Error = domain.PublicMessage(err)
+		return p
+	}
+	p.existing = full
+
+	if full.Description != want.Description {
+		p.action.Changes = append(p.action.Changes, "description")
+	}
+	if full.ComposeContent != docker.InterpolateEnv(want.Compose, want.Env) {
+		p.action.Changes = append(p.action.Changes, "compose")
+	}
+	if full.ComposeOverride != docker.InterpolateEnv(want.ComposeOverride, want.Env) {
+		p.action.Changes = append(p.action.Changes, "compose_override")
+	}
+	if !maps.Equal(full.Labels, want.Labels) {
+		p.action.Changes = append(p.action.Changes, "labels")
+	}
+	if full.EnvTemplate != want.EnvTemplate {
+		p.action.Changes = append(p.action.Changes, "env_template")
+	}
+
+	wantMode := ""
+	if want.Tunnel != nil {
+		wantMode = want.Tunnel.Mode
+	}
+	if full.TunnelMode != wantMode {
+		p.action.Error = fmt.Sprintf("tunnel mode differs (%q, declared %q); apply doesn't change an existing app's tunnel mode", full.TunnelMode, wantMode)
+		return p
+	}
+	if wantMode == constants.TunnelModeCustom && want.Tunnel.IngressRules != nil {
+		rules, err := p.target.getIngress(ctx, full.ID)
+		if err != nil {
+			p.action.Error = domain.PublicMessage(err)
+			return p
+		}
+		if !sameIngressRules(rules, want.Tunnel.IngressRules) {
+			p.action.Changes = append(p.action.Changes, "ingress_rules")
+			p.ingress = want.Tunnel.IngressRules
+		}
+	}
+
+	if len(p.action.Changes) == 0 {
+		p.action.Action = constants.ApplyActionUnchanged
+	}
+	return p
+}
+
+// execute carries out one planned action
+func (s *applyService) execute(ctx context.Context, p *plannedApp) error {
+	switch p.action.Action {
+	case constants.ApplyActionCreate:
+		want := p.declared
+		req := domain.CreateAppRequest{
+			Name:            want.Name,
+			Description:     want.Description,
+			ComposeContent:  docker.InterpolateEnv(want.Compose, want.Env),
+			ComposeOverride: docker.InterpolateEnv(want.ComposeOverride, want.Env),
+			NodeID:          p.action.NodeID,
+			Labels:          want.Labels,
+			EnvTemplate:     want.EnvTemplate,
+			Owner:           domain.ActingUserFromContext(ctx).Name,
+			ApprovePolicies: want.ApprovePolicies,
+		}
+		if want.Tunnel != nil {
+			req.TunnelMode = want.Tunnel.Mode
+			req.QuickTunnelService = want.Tunnel.Service
+			req.QuickTunnelPort = want.Tunnel.Port
+			req.IngressRules = want.Tunnel.IngressRules
+		}
+		app, err := p.target.createApp(ctx, req)
+		if err != nil {
+			return err
+		}
+		p.action.AppID = app.ID
+		return nil
+
+	case constants.ApplyActionUpdate:
+		want := p.declared
+		changed := func(field string) bool { return slices.Contains(p.action.Changes, field) }
+		if changed("description") || changed("compose") || changed("compose_override") || changed("labels") || changed("env_template") {
+			override := docker.InterpolateEnv(want.ComposeOverride, want.Env)
+			req := domain.UpdateAppRequest{
+				Name:            want.Name,
+				Description:     want.Description,
+				ComposeContent:  docker.InterpolateEnv(want.Compose, want.Env),
+				ComposeOverride: &override,
+				Labels:          want.Labels,
+				ApprovePolicies: want.ApprovePolicies,
+			}
+			if req.Labels == nil {
+				// An empty map clears labels; nil would leave them in place
+				req.Labels = map[string]string{}
+			}
+			if changed("env_template") {
+				req.EnvTemplate = &want.EnvTemplate
+			}
+			if _, err := p.target.updateApp(ctx, p.existing.ID, req); err != nil {
+				return err
+			}
+		}
+		if p.ingress != nil {
+			return p.target.updateIngress(ctx, p.existing.ID, p.ingress)
+		}
+		return nil
+
+	case constants.ApplyActionDelete:
+		return p.target.deleteApp(ctx, p.existing.ID)
+	}
+	return nil
+}
+
+// sameIngressRules compares rules by their JSON form, the form the API accepts them in
+func sameIngressRules(a, b []db.IngressRule) bool {
+	aj, _ := json.Marshal(a)
+	bj, _ := json.Marshal(b)
+	var av, bv interface{}
+	_ = json.Unmarshal(aj, &av)
+	_ = json.Unmarshal(bj, &bv)
+	return reflect.DeepEqual(av, bv)
+}
+
+func nodeByID(nodes []*db.Node, id string) *db.Node {
+	for _, n := range nodes {
+		if n.ID == id {
+			return n
+		}
+	}
+	return &db.Node{ID: id}
+}
+
+// applyTarget performs app writes on one node
+type applyTarget interface {
+	getApp(ctx context.Context, appID string) (*db.App, error)
+	createApp(ctx context.Context, req domain.CreateAppRequest) (*db.App, error)
+	updateApp(ctx context.Context, appID string, req domain.UpdateAppRequest) (*db.App, error)
+	deleteApp(ctx context.Context, appID string) error
+	getIngress(ctx context.Context, appID string) ([]db.IngressRule, error)
+	updateIngress(ctx context.Context, appID string, rules []db.IngressRule) error
+}
+
+// targetFor returns the target that writes to apps on n, acting for the user applying the manifest
+func (s *applyService) targetFor(ctx context.Context, n *db.Node) applyTarget {
+	if n.ID == s.config.Node.ID {
+		return &localApplyTarget{s: s}
+	}
+	return &remoteApplyTarget{client: s.nodeClient.ForUser(domain.ActingUserFromContext(ctx)), node: n}
+}
+
+// localApplyTarget writes to apps on this node through the local services
+type localApplyTarget struct {
+	s *applyService
+}
+
+func (t *localApplyTarget) getApp(ctx context.Context, appID string) (*db.App, error) {
+	return t.s.appService.GetApp(ctx, appID, t.s.config.Node.ID)
+}
+
+func (t *localApplyTarget) createApp(ctx context.Context, req domain.CreateAppRequest) (*db.App, error) {
+	return t.s.appService.CreateApp(ctx, req)
+}
+
+func (t *localApplyTarget) updateApp(ctx context.Context, appID string, req domain.UpdateAppRequest) (*db.App, error) {
+	return t.s.appService.UpdateApp(ctx, appID, t.s.config.Node.ID, req)
+}
+
+func (t *localApplyTarget) deleteApp(ctx context.Context, appID string) error {
+	_, err := t.s.appService.DeleteApp(ctx, appID, t.s.config.Node.ID, domain.DeleteAppOptions{})
+	return err
+}
+
+func (t *localApplyTarget) getIngress(ctx context.Context, appID string) ([]db.IngressRule, error) {
+	tunnel, err := t.s.tunnelService.GetTunnelByAppID(ctx, appID, t.s.config.Node.ID)
+	if err != nil {
+		return nil, err
+	}
+	if tunnel.IngressRules == nil {
+		return nil, nil
+	}
+	return *tunnel.IngressRules, nil
+}
+
+func (t *localApplyTarget) updateIngress(ctx context.Context, appID string, rules []db.IngressRule) error {
+	return t.s.tunnelService.UpdateTunnelIngress(ctx, appID, t.s.config.Node.ID, domain.UpdateIngressRequest{IngressRules: rules})
+}
+
+// remoteApplyTarget writes to apps on another node through the node client
+type remoteApplyTarget struct {
+	client *node.Client
+	node   *db.Node
+}
+
+func (t *remoteApplyTarget) getApp(_ context.Context, appID string) (*db.App, error) {
+	return t.client.GetApp(t.node, appID)
+}
+
+func (t *remoteApplyTarget) createApp(_ context.Context, req domain.CreateAppRequest) (*db.App, error) {
+	return t.client.CreateApp(t.node, req)
+}
+
+func (t *remoteApplyTarget) updateApp(_ context.Context, appID string, req domain.UpdateAppRequest) (*db.App, error) {
+	return t.client.UpdateApp(t.node, appID, req)
+}
+
+func (t *remoteApplyTarget) deleteApp(_ context.Context, appID string) error {
+	return t.client.DeleteApp(t.node, appID)
+}
+
+func (t *remoteApplyTarget) getIngress(_ context.Context, appID string) ([]db.IngressRule, error) {
+	tunnel, err := t.client.GetTunnelByAppID(t.node, appID)
+	if err != nil {
+		return nil, err
+	}
+	if tunnel.IngressRules == nil {
+		return nil, nil
+	}
+	return *tunnel.IngressRules, nil
+}
+
+func (t *remoteApplyTarget) updateIngress(_ context.Context, appID string, rules []db.IngressRule) error {
+	return t.client.UpdateTunnelIngress(t.node, appID, domain.UpdateIngressRequest{IngressRules: rules})
+}
@@ -0,0 +1,137 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+
+	"github.com/selfhostly/internal/config"
+	"github.com/selfhostly/internal/constants"
+	"github.com/selfhostly/internal/docker"
+	"github.com/selfhostly/internal/domain"
+)
+
+func TestApplyService(t *testing.T) {
+	appSvc, database, cleanup := setupTestAppServiceWithMocks(t, docker.NewMockCommandExecutor())
+	defer cleanup()
+	ctx := context.Background()
+
+	cfg := &config.Config{Node: config.NodeConfig{ID: "test-node-id", IsPrimary: true}}
+	svc := NewApplyService(database, appSvc, nil, cfg, slog.Default())
+
+	if _, err := appSvc.CreateApp(ctx, domain.CreateAppRequest{
+		Name:           "legacy",
+		ComposeContent: "services:\n  web:\n    image: nginx:latest\n",
+	}); err != nil {
+		t.Fatalf("CreateApp: %v", err)
+	}
+
+	manifest := domain.AppManifest{
+		Apps: []domain.ManifestApp{{
+			Name:    "whoami",
+			Node:    "test-node",
+			Compose: "services:\n  web:\n    image: traefik/whoami:${TAG}\n",
+			Env:     map[string]string{"TAG": "v1.10"},
+			Labels:  map[string]string{"env": "prod"},
+		}},
+		Prune: true,
+	}
+
+	plan, err := svc.Apply(ctx, manifest, true)
+	if err != nil {
+		t.Fatalf("Apply dry run: %v", err)
+	}
+	if got := actionsByApp(plan); got["whoami"] != constants.ApplyActionCreate || got["legacy"] != constants.ApplyActionDelete || len(got) != 2 {
+		t.Fatalf("Unexpected plan %v", got)
+	}
+	if apps, _ := database.GetAllApps(); len(apps) != 1 {
+		t.Fatalf("Expected a dry run to change nothing, got %d apps", len(apps))
+	}
+
+	result, err := svc.Apply(ctx, manifest, false)
+	if err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+	for _, a := range result.Actions {
+		if a.Error != "" {
+			t.Fatalf("%s %s failed: %s", a.Action, a.App, a.Error)
+		}
+	}
+	apps, err := database.GetAllApps()
+	if err != nil || len(apps) != 1 || apps[0].Name != "whoami" {
+		t.Fatalf("Expected only whoami to remain, got %v (err %v)", apps, err)
+	}
+	if apps[0].ComposeContent != "services:\n  web:\n    image: traefik/whoami:v1.10\n" {
+		t.Errorf("Expected env substituted into compose, got %q", apps[0].ComposeContent)
+	}
+	if apps[0].Labels["env"] != "prod" {
+		t.Errorf("Expected labels set, got %v", apps[0].Labels)
+	}
+
+	// Applying again changes nothing; changing a variable updates the app
+	if result, _ = svc.Apply(ctx, manifest, true); actionsByApp(result)["whoami"] != constants.ApplyActionUnchanged {
+		t.Errorf("Expected whoami unchanged, got %+v", result.Actions[0])
+	}
+	manifest.Apps[0].Env["TAG"] = "v1.11"
+	manifest.Apps[0].Description = "echo server"
+	if result, err = svc.Apply(ctx, manifest, false); err != nil || result.Failed != 0 {
+		t.Fatalf("Apply update: %+v (err %v)", result, err)
+	}
+	if a := result.Actions[0]; a.Action != constants.ApplyActionUpdate || len(a.Changes) != 2 {
+		t.Errorf("Expected description and compose update, got %+v", a)
+	}
+	if app, _ := database.GetApp(apps[0].ID); app.Description != "echo server" {
+		t.Errorf("Expected description updated, got %q", app.Description)
+	}
+
+	invalid := []domain.AppManifest{
+		{Apps: []domain.ManifestApp{{Name: "a", Compose: "services: {}"}, {Name: "a", Compose: "services: {}"}}},
+		{Apps: []domain.ManifestApp{{Name: "a"}}},
+		{Apps: []domain.ManifestApp{{Name: "a", Compose: "services: {}", Node: "nowhere"}}},
+		{Apps: []domain.ManifestApp{{Name: "a", Compose: "services: {}", Env: map[string]string{"BAD-NAME": "x"}}}},
+		{Apps: []domain.ManifestApp{{Name: "a", Compose: "services: {}", Tunnel: &domain.ManifestTunnel{Mode: constants.TunnelModeQuick}}}},
+	}
+	for i, m := range invalid {
+		if _, err := svc.Apply(ctx, m, true); !domain.IsValidationError(err) {
+			t.Errorf("manifest %d: expected validation error, got %v", i, err)
+		}
+	}
+}
+
+func actionsByApp(result *domain.ApplyResult) map[string]string {
+	actions := make(map[string]string, len(result.Actions))
+	for _, a := range result.Actions {
+		actions[a.App] = a.Action
+	}
+	return actions
+}
+
+func TestApplyService_ApprovePolicies(t *testing.T) {
+	appSvc, database, cleanup := setupTestAppServiceWithMocks(t, docker.NewMockCommandExecutor())
+	defer cleanup()
+	ctx := context.Background()
+
+	settings, err := database.GetSettings()
+	if err != nil {
+		t.Fatalf("GetSettings: %v", err)
+	}
+	settings.ComposePolicyDevices = constants.ComposePolicyApproval
+	if err := database.UpdateSettings(settings); err != nil {
+		t.Fatalf("UpdateSettings: %v", err)
+	}
+
+	cfg := &config.Config{Node: config.NodeConfig{ID: "test-node-id", IsPrimary: true}}
+	svc := NewApplyService(database, appSvc, nil, cfg, slog.Default())
+	manifest := domain.AppManifest{Apps: []domain.ManifestApp{{
+		Name:    "media",
+		Compose: "services:\n  tv:\n    image: jellyfin/jellyfin\n    devices:\n      - /dev/dri:/dev/dri\n",
+	}}}
+
+	if result, _ := svc.Apply(ctx, manifest, false); result == nil || result.Failed != 1 {
+		t.Fatalf("Expected the devices to need approval, got %+v", result)
+	}
+	manifest.Apps[0].ApprovePolicies = []string{constants.ComposePolicyDevices}
+	if result, err := svc.Apply(ctx, manifest, false); err != nil || result.Failed != 0 {
+		t.Fatalf("Expected the approved app to be created, got %+v (err %v)", result, err)
+	}
+}
@@ -0,0 +1,156 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"time"
+
+	"github.com/selfhostly/internal/config"
+	"github.com/selfhostly/internal/constants"
+	"github.com/selfhostly/internal/db"
+	"github.com/selfhostly/internal/domain"
+)
+
+// approvalService keeps destructive operations waiting for a second confirmation. It only records
+// decisions; the HTTP layer runs the operation once an approval is confirmed.
+type approvalService struct {
+	database *db.DB
+	config   *config.Config
+	logger   *slog.Logger
+}
+
+// NewApprovalService creates a new approval service
+func NewApprovalService(database *db.DB, cfg *config.Config, logger *slog.Logger) domain.ApprovalService {
+	return &approvalService{
+		database: database,
+		config:   cfg,
+		logger:   logger,
+	}
+}
+
+// Required reports whether destructive operations wait for a second confirmation
+func (s *approvalService) Required() bool {
+	return s.config.Approvals.Required
+}
+
+// Request records an operation awaiting confirmation. Asking again while one is pending returns
+// it, so a double click doesn't need confirming twice.
+func (s *approvalService) Request(ctx context.Context, operation, resourceID string, params map[string]string, requestedBy string) (*db.Approval, error) {
+	s.expire(ctx)
+
+	pending, err := s.database.GetPendingApproval(operation, resourceID)
+	if err != nil {
+		return nil, domain.WrapDatabaseOperation("get pending approval", err)
+	}
+	if pending != nil {
+		return pending, nil
+	}
+
+	approval := db.NewApproval(operation, resourceID, params, requestedBy, s.config.Approvals.TTL)
+	if err := s.database.CreateApproval(approval); err != nil {
+		return nil, domain.WrapDatabaseOperation("create approval", err)
+	}
+	s.logger.WarnContext(ctx, "destructive operation awaiting approval",
+		"approvalID", approval.ID, "operation", operation, "resourceID", resourceID, "requestedBy", requestedBy, "expiresAt", approval.ExpiresAt)
+	return approval, nil
+}
+
+// ListApprovals returns this node's most recent approvals, optionally only those with status
+func (s *approvalService) ListApprovals(ctx context.Context, status string) ([]*db.Approval, error) {
+	statuses := []string{constants.ApprovalStatusPending, constants.ApprovalStatusConfirmed, constants.ApprovalStatusRejected, constants.ApprovalStatusExpired}
+	if status != "" && !slices.Contains(statuses, status) {
+		return nil, domain.WrapValidationError("status", fmt.Errorf("unknown approval status %q", status))
+	}
+	s.expire(ctx)
+
+	approvals, err := s.database.GetApprovals(status, constants.ApprovalHistoryLimit)
+	if err != nil {
+		return nil, domain.WrapDatabaseOperation("list approvals", err)
+	}
+	return approvals, nil
+}
+
+// GetApproval returns one approval
+func (s *approvalService) GetApproval(ctx context.Context, id string) (*db.Approval, error) {
+	s.expire(ctx)
+
+	approval, err := s.database.GetApproval(id)
+	if err == sql.ErrNoRows {
+		return nil, domain.WrapApprovalNotFound(id, err)
+	}
+	if err != nil {
+		return nil, domain.WrapDatabaseOperation("get approval", err)
+	}
+	return approval, nil
+}
+
+// Confirm marks a pending approval confirmed. The admin who requested it can only confirm it
+// themselves once the self-confirm delay is up.
+func (s *approvalService) Confirm(ctx context.Context, id, confirmedBy string) (*db.Approval, error) {
+	approval, err := s.GetApproval(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if approval.Status != constants.ApprovalStatusPending {
+		return nil, domain.WrapApprovalNotFound(id, nil)
+	}
+	if confirmedBy == approval.RequestedBy {
+		if confirmableAt := approval.RequestedAt.Add(s.config.Approvals.SelfConfirmDelay); time.Now().Before(confirmableAt) {
+			return nil, domain.WrapApprovalTooEarly(id, confirmableAt)
+		}
+	}
+	return s.decide(ctx, approval, constants.ApprovalStatusConfirmed, confirmedBy)
+}
+
+// Reject turns a pending approval down; the requester can reject it to withdraw the request
+func (s *approvalService) Reject(ctx context.Context, id, rejectedBy string) (*db.Approval, error) {
+	approval, err := s.GetApproval(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	return s.decide(ctx, approval, constants.ApprovalStatusRejected, rejectedBy)
+}
+
+// RecordResult records why a confirmed operation failed; success needs no record
+func (s *approvalService) RecordResult(ctx context.Context, id string, err error) {
+	if err == nil {
+		return
+	}
+	if dbErr := s.database.SetApprovalError(id, domain.PublicMessage(err)); dbErr != nil {
+		s.logger.WarnContext(ctx, "failed to record approved operation failure", "approvalID", id, "error", dbErr)
+	}
+}
+
+func (s *approvalService) decide(ctx context.Context, approval *db.Approval, status, decidedBy string) (*db.Approval, error) {
+	now := time.Now()
+	if err := s.database.DecideApproval(approval.ID, status, decidedBy, now); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, domain.WrapApprovalNotFound(approval.ID, err)
+		}
+		return nil, domain.WrapDatabaseOperation("decide approval", err)
+	}
+	approval.Status = status
+	approval.DecidedBy = decidedBy
+	approval.DecidedAt = &now
+
+	s.logger.WarnContext(ctx, "destructive operation "+status,
+		"approvalID", approval.ID, "operation", approval.Operation, "resourceID", approval.ResourceID,
+		"requestedBy", approval.RequestedBy, "decidedBy", decidedBy)
+	return approval, nil
+}
+
+// expire marks pending approvals past their expiry, so they can't be confirmed and read as expired
+func (s *approvalService) expire(ctx context.Context) {
+	expired, err := s.database.ExpireApprovals(time.Now())
+	if err != nil {
+		s.logger.WarnContext(ctx, "failed to expire approvals", "error", err)
+		return
+	}
+	if expired > 0 {
+		s.logger.InfoContext(ctx, "approvals expired", "count", expired)
+	}
+}
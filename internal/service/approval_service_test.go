@@ -0,0 +1,112 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/selfhostly/internal/config"
+	"github.com/selfhostly/internal/constants"
+	"github.com/selfhostly/internal/db"
+	"github.com/selfhostly/internal/domain"
+)
+
+func setupTestApprovalService(t *testing.T, approvals config.ApprovalConfig) (domain.ApprovalService, *db.DB) {
+	database, err := db.Init(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to initialize database: %v", err)
+	}
+	t.Cleanup(func() { database.Close() })
+
+	cfg := &config.Config{Approvals: approvals}
+	return NewApprovalService(database, cfg, slog.Default()), database
+}
+
+func TestApprovalService(t *testing.T) {
+	service, _ := setupTestApprovalService(t, config.ApprovalConfig{Required: true, SelfConfirmDelay: time.Hour, TTL: time.Hour})
+	ctx := context.Background()
+
+	params := map[string]string{"archive": "true"}
+	approval, err := service.Request(ctx, constants.ApprovalOperationDeleteApp, "app-1", params, "alice")
+	if err != nil {
+		t.Fatalf("Request: %v", err)
+	}
+	if approval.Status != constants.ApprovalStatusPending || approval.Params["archive"] != "true" {
+		t.Errorf("Unexpected approval %+v", approval)
+	}
+
+	// Asking again returns the pending approval
+	again, err := service.Request(ctx, constants.ApprovalOperationDeleteApp, "app-1", nil, "alice")
+	if err != nil || again.ID != approval.ID {
+		t.Errorf("Expected the pending approval back, got %+v, %v", again, err)
+	}
+
+	// The requester has to wait; another admin doesn't
+	if _, err := service.Confirm(ctx, approval.ID, "alice"); !domain.IsConflictError(err) {
+		t.Errorf("Expected the requester to be refused before the delay, got %v", err)
+	}
+	confirmed, err := service.Confirm(ctx, approval.ID, "bob")
+	if err != nil {
+		t.Fatalf("Confirm: %v", err)
+	}
+	if confirmed.Status != constants.ApprovalStatusConfirmed || confirmed.DecidedBy != "bob" {
+		t.Errorf("Unexpected confirmed approval %+v", confirmed)
+	}
+
+	// A decided approval can't be confirmed again, but stays in the trail
+	if _, err := service.Confirm(ctx, approval.ID, "carol"); !domain.IsNotFoundError(err) {
+		t.Errorf("Expected a second confirmation to be refused, got %v", err)
+	}
+	service.RecordResult(ctx, approval.ID, domain.WrapAppLocked("app-1", "update"))
+	stored, err := service.GetApproval(ctx, approval.ID)
+	if err != nil || stored.Error != "app app-1 is busy: update in progress" {
+		t.Errorf("Expected the failure to be recorded, got %+v, %v", stored, err)
+	}
+
+	rejected, err := service.Request(ctx, constants.ApprovalOperationRemoveNode, "node-1", nil, "alice")
+	if err != nil {
+		t.Fatalf("Request: %v", err)
+	}
+	if _, err := service.Reject(ctx, rejected.ID, "alice"); err != nil {
+		t.Fatalf("Reject: %v", err)
+	}
+
+	pending, err := service.ListApprovals(ctx, constants.ApprovalStatusPending)
+	if err != nil || len(pending) != 0 {
+		t.Errorf("Expected no pending approvals, got %d, %v", len(pending), err)
+	}
+	all, _ := service.ListApprovals(ctx, "")
+	if len(all) != 2 {
+		t.Errorf("Expected both approvals in the trail, got %d", len(all))
+	}
+	if _, err := service.ListApprovals(ctx, "done"); !domain.IsValidationError(err) {
+		t.Errorf("Expected an unknown status to be refused, got %v", err)
+	}
+}
+
+func TestApprovalService_SelfConfirmAndExpiry(t *testing.T) {
+	service, database := setupTestApprovalService(t, config.ApprovalConfig{Required: true, TTL: time.Hour})
+	ctx := context.Background()
+
+	// Without a delay the requester can confirm straight away
+	approval, err := service.Request(ctx, constants.ApprovalOperationDeleteTunnel, "app-1", nil, "")
+	if err != nil {
+		t.Fatalf("Request: %v", err)
+	}
+	if _, err := service.Confirm(ctx, approval.ID, ""); err != nil {
+		t.Errorf("Expected the requester to confirm after the delay, got %v", err)
+	}
+
+	expired := db.NewApproval(constants.ApprovalOperationDeleteApp, "app-2", nil, "alice", -time.Minute)
+	if err := database.CreateApproval(expired); err != nil {
+		t.Fatalf("CreateApproval: %v", err)
+	}
+	if _, err := service.Confirm(ctx, expired.ID, "bob"); !domain.IsNotFoundError(err) {
+		t.Errorf("Expected an expired approval to be refused, got %v", err)
+	}
+	if stored, _ := service.GetApproval(ctx, expired.ID); stored.Status != constants.ApprovalStatusExpired {
+		t.Errorf("Expected the approval to read as expired, got %s", stored.Status)
+	}
+}
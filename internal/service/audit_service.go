@@ -0,0 +1,277 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/selfhostly/internal/config"
+	"github.com/selfhostly/internal/constants"
+	"github.com/selfhostly/internal/db"
+	"github.com/selfhostly/internal/docker"
+	"github.com/selfhostly/internal/domain"
+	"github.com/selfhostly/internal/events"
+)
+
+// auditService looks for records in this node's database that contradict each other and stores
+// the most recent reports
+type auditService struct {
+	database *db.DB
+	config   *config.Config
+	logger   *slog.Logger
+}
+
+// NewAuditService creates a new consistency audit service
+func NewAuditService(database *db.DB, cfg *config.Config, logger *slog.Logger) domain.AuditService {
+	return &auditService{
+		database: database,
+		config:   cfg,
+		logger:   logger,
+	}
+}
+
+// RunAudit runs every consistency check, logs each finding as a warning and stores the report,
+// keeping the last ConsistencyAuditsKept. A report with findings is sent as a notification too.
+func (s *auditService) RunAudit(ctx context.Context) (*domain.AuditReport, error) {
+	checks := []func() ([]*domain.AuditFinding, error){
+		s.auditAppNodes,
+		s.auditTunnelRecords,
+		s.auditComposeVersions,
+		s.auditPendingJobs,
+		s.auditMetricsPorts,
+		s.auditAppNetworks,
+	}
+
+	report := &domain.AuditReport{
+		ID:       uuid.New().String(),
+		NodeID:   s.config.Node.ID,
+		RanAt:    time.Now(),
+		Findings: []*domain.AuditFinding{},
+	}
+	for _, check := range checks {
+		findings, err := check()
+		if err != nil {
+			return nil, domain.WrapDatabaseOperation("consistency audit", err)
+		}
+		report.Findings = append(report.Findings, findings...)
+	}
+
+	for _, f := range report.Findings {
+		s.logger.WarnContext(ctx, "consistency audit finding", "check", f.Check, "resource", f.Resource, "message", f.Message, "remediation", f.Remediation)
+	}
+	s.logger.InfoContext(ctx, "consistency audit completed", "findings", len(report.Findings))
+
+	findings, err := json.Marshal(report.Findings)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode audit findings: %w", err)
+	}
+	audit := &db.ConsistencyAudit{ID: report.ID, NodeID: report.NodeID, RanAt: report.RanAt, Findings: string(findings)}
+	if err := s.database.SaveConsistencyAudit(audit, constants.ConsistencyAuditsKept); err != nil {
+		return nil, domain.WrapDatabaseOperation("save consistency audit", err)
+	}
+
+	if len(report.Findings) > 0 {
+		events.Publish(events.Event{
+			Type:   events.TypeNotification,
+			NodeID: report.NodeID,
+			Data: events.Notification{
+				Event:   constants.AuditEventFindings,
+				Subject: report.NodeID,
+				Message: fmt.Sprintf("consistency audit found %d inconsistencies; see GET /api/system/audit", len(report.Findings)),
+			},
+		})
+	}
+	return report, nil
+}
+
+// LatestAudit returns the most recent stored report, running the audit if none has run yet
+func (s *auditService) LatestAudit(ctx context.Context) (*domain.AuditReport, error) {
+	audits, err := s.ListAudits(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(audits) > 0 {
+		return audits[0], nil
+	}
+	return s.RunAudit(ctx)
+}
+
+// ListAudits returns the stored reports, newest first
+func (s *auditService) ListAudits(ctx context.Context) ([]*domain.AuditReport, error) {
+	audits, err := s.database.GetConsistencyAudits(constants.ConsistencyAuditsKept)
+	if err != nil {
+		return nil, domain.WrapDatabaseOperation("get consistency audits", err)
+	}
+
+	reports := make([]*domain.AuditReport, 0, len(audits))
+	for _, audit := range audits {
+		report := &domain.AuditReport{ID: audit.ID, NodeID: audit.NodeID, RanAt: audit.RanAt, Findings: []*domain.AuditFinding{}}
+		if err := json.Unmarshal([]byte(audit.Findings), &report.Findings); err != nil {
+			s.logger.WarnContext(ctx, "skipping unreadable consistency audit", "auditID", audit.ID, "error", err)
+			continue
+		}
+		reports = append(reports, report)
+	}
+	return reports, nil
+}
+
+// auditAppNodes flags apps assigned to a node that is no longer registered
+func (s *auditService) auditAppNodes() ([]*domain.AuditFinding, error) {
+	nodes, err := s.database.GetAllNodes()
+	if err != nil {
+		return nil, err
+	}
+	apps, err := s.database.GetAllAppsMetadata()
+	if err != nil {
+		return nil, err
+	}
+
+	known := make(map[string]bool, len(nodes))
+	for _, n := range nodes {
+		known[n.ID] = true
+	}
+
+	var findings []*domain.AuditFinding
+	for _, app := range apps {
+		if app.NodeID == "" || known[app.NodeID] {
+			continue
+		}
+		findings = append(findings, &domain.AuditFinding{
+			Check:       constants.AuditCheckAppNodeMissing,
+			Resource:    app.ID,
+			Message:     fmt.Sprintf("app %q belongs to node %s, which is not registered", app.Name, app.NodeID),
+			Remediation: fmt.Sprintf("Re-register node %s if it still exists; otherwise force delete the app", app.NodeID),
+		})
+	}
+	return findings, nil
+}
+
+// auditTunnelRecords flags tunnel records left behind by deleted apps
+func (s *auditService) auditTunnelRecords() ([]*domain.AuditFinding, error) {
+	tunnels, err := s.database.GetTunnelsWithoutApp()
+	if err != nil {
+		return nil, err
+	}
+
+	findings := make([]*domain.AuditFinding, 0, len(tunnels))
+	for _, t := range tunnels {
+		findings = append(findings, &domain.AuditFinding{
+			Check:       constants.AuditCheckTunnelWithoutApp,
+			Resource:    t.TunnelID,
+			Message:     fmt.Sprintf("tunnel %q is recorded for app %s, which no longer exists", t.TunnelName, t.AppID),
+			Remediation: "Import the tunnel into an app, or delete it from the unmanaged tunnels list",
+		})
+	}
+	return findings, nil
+}
+
+// auditAppNetworks flags networks left behind by deleted apps because they were still in use
+func (s *auditService) auditAppNetworks() ([]*domain.AuditFinding, error) {
+	networks, err := s.database.GetAppNetworksWithoutApp()
+	if err != nil {
+		return nil, err
+	}
+
+	findings := make([]*domain.AuditFinding, 0, len(networks))
+	for _, n := range networks {
+		findings = append(findings, &domain.AuditFinding{
+			Check:       constants.AuditCheckNetworkWithoutApp,
+			Resource:    n.Network,
+			Message:     fmt.Sprintf("network %q was created for app %s, which no longer exists", n.Network, n.AppName),
+			Remediation: "Stop or disconnect the containers still attached to it; it is removed at the next app deletion once unused",
+		})
+	}
+	return findings, nil
+}
+
+// auditComposeVersions flags apps whose compose history doesn't have exactly one current version
+func (s *auditService) auditComposeVersions() ([]*domain.AuditFinding, error) {
+	flags, err := s.database.GetMisflaggedComposeVersions()
+	if err != nil {
+		return nil, err
+	}
+
+	findings := make([]*domain.AuditFinding, 0, len(flags))
+	for _, f := range flags {
+		message := fmt.Sprintf("app %q has %d compose versions but none is marked current", f.AppName, f.Versions)
+		if f.Current > 1 {
+			message = fmt.Sprintf("app %q has %d compose versions marked current", f.AppName, f.Current)
+		}
+		findings = append(findings, &domain.AuditFinding{
+			Check:       constants.AuditCheckComposeVersionFlag,
+			Resource:    f.AppID,
+			Message:     message,
+			Remediation: fmt.Sprintf("Roll back to version %d (the latest) so exactly one version is current", f.Latest),
+		})
+	}
+	return findings, nil
+}
+
+// auditPendingJobs flags jobs that have waited far longer than the worker takes to claim them
+func (s *auditService) auditPendingJobs() ([]*domain.AuditFinding, error) {
+	jobs, err := s.database.GetPendingJobs(constants.AuditMaxPendingJobs)
+	if err != nil {
+		return nil, err
+	}
+
+	var findings []*domain.AuditFinding
+	for _, job := range jobs {
+		waiting := time.Since(job.CreatedAt)
+		if waiting < constants.AuditJobStuckAfter {
+			// Pending jobs come highest priority first, so an older job may still follow
+			continue
+		}
+		findings = append(findings, &domain.AuditFinding{
+			Check:       constants.AuditCheckJobStuckPending,
+			Resource:    job.ID,
+			Message:     fmt.Sprintf("%s job for app %s has been pending for %s", job.Type, job.AppID, waiting.Round(time.Minute)),
+			Remediation: "Check the job worker's logs and restart the node so the worker picks the job up",
+		})
+	}
+	return findings, nil
+}
+
+// auditMetricsPorts flags Quick Tunnel metrics host ports claimed by more than one app; only one
+// of their tunnel containers can start
+func (s *auditService) auditMetricsPorts() ([]*domain.AuditFinding, error) {
+	apps, err := s.database.GetAllApps()
+	if err != nil {
+		return nil, err
+	}
+
+	appsByPort := make(map[int][]*db.App)
+	for _, app := range apps {
+		if port, ok := docker.ExtractQuickTunnelMetricsHostPort(app.TunnelComposeSource()); ok {
+			appsByPort[port] = append(appsByPort[port], app)
+		}
+	}
+
+	ports := make([]int, 0, len(appsByPort))
+	for port, sharing := range appsByPort {
+		if len(sharing) > 1 {
+			ports = append(ports, port)
+		}
+	}
+	slices.Sort(ports)
+
+	findings := make([]*domain.AuditFinding, 0, len(ports))
+	for _, port := range ports {
+		names := make([]string, len(appsByPort[port]))
+		for i, app := range appsByPort[port] {
+			names[i] = app.Name
+		}
+		slices.Sort(names)
+		findings = append(findings, &domain.AuditFinding{
+			Check:       constants.AuditCheckDuplicateMetricsPort,
+			Resource:    fmt.Sprintf("port/%d", port),
+			Message:     fmt.Sprintf("Quick Tunnel metrics port %d is used by %s", port, strings.Join(names, ", ")),
+			Remediation: fmt.Sprintf("Recreate the Quick Tunnel of all but one of %s so each gets a free port", strings.Join(names, ", ")),
+		})
+	}
+	return findings, nil
+}
@@ -0,0 +1,125 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/selfhostly/internal/config"
+	"github.com/selfhostly/internal/constants"
+	"github.com/selfhostly/internal/db"
+)
+
+func TestAuditService_RunAudit(t *testing.T) {
+	dir := t.TempDir()
+	cfg := &config.Config{Node: config.NodeConfig{ID: "self", IsPrimary: true}}
+	database, err := db.Init(filepath.Join(dir, "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to initialize database: %v", err)
+	}
+	defer database.Close()
+	if err := database.CreateNode(db.NewNodeWithID("self", "self", "http://127.0.0.1:1", "key", true)); err != nil {
+		t.Fatalf("CreateNode: %v", err)
+	}
+
+	createApp := func(name, nodeID, tunnelCompose string) *db.App {
+		t.Helper()
+		app := db.NewApp(name, "", "services:\n  web:\n    image: nginx:latest\n")
+		app.NodeID = nodeID
+		app.TunnelCompose = tunnelCompose
+		if err := database.CreateApp(app); err != nil {
+			t.Fatalf("CreateApp %s: %v", name, err)
+		}
+		return app
+	}
+	quickTunnel := "services:\n  tunnel:\n    image: cloudflare/cloudflared:latest\n    ports:\n      - \"2005:2000\"\n"
+
+	healthy := createApp("healthy", "self", "")
+	stray := createApp("stray", "gone-node", "")
+	createApp("quick-a", "self", quickTunnel)
+	createApp("quick-b", "self", quickTunnel)
+
+	for version, current := range map[int]bool{1: true, 2: false} {
+		if err := database.CreateComposeVersion(&db.ComposeVersion{ID: uuid.New().String(), AppID: healthy.ID, Version: version, ComposeContent: healthy.ComposeContent, IsCurrent: current, CreatedAt: time.Now()}); err != nil {
+			t.Fatalf("CreateComposeVersion: %v", err)
+		}
+		if err := database.CreateComposeVersion(&db.ComposeVersion{ID: uuid.New().String(), AppID: stray.ID, Version: version, ComposeContent: stray.ComposeContent, CreatedAt: time.Now()}); err != nil {
+			t.Fatalf("CreateComposeVersion: %v", err)
+		}
+	}
+
+	leftover := db.NewCloudflareTunnel("deleted-app", "tunnel-1", "leftover", "token", "account", "")
+	if err := database.CreateCloudflareTunnel(leftover); err != nil {
+		t.Fatalf("CreateCloudflareTunnel: %v", err)
+	}
+
+	if err := database.RecordAppNetworks("deleted-app", []string{"deleted-app_default"}); err != nil {
+		t.Fatalf("RecordAppNetworks: %v", err)
+	}
+	if err := database.RecordAppNetworks(healthy.Name, []string{"healthy_default"}); err != nil {
+		t.Fatalf("RecordAppNetworks: %v", err)
+	}
+
+	stuck := db.NewJob(constants.JobTypeAppCreate, healthy.ID, nil)
+	stuck.CreatedAt = time.Now().Add(-2 * constants.AuditJobStuckAfter)
+	fresh := db.NewJob(constants.JobTypeAppCreate, healthy.ID, nil)
+	fresh.Priority = stuck.Priority + 1 // listed before the stuck job
+	for _, job := range []*db.Job{stuck, fresh} {
+		if err := database.CreateJob(job); err != nil {
+			t.Fatalf("CreateJob: %v", err)
+		}
+	}
+
+	svc := NewAuditService(database, cfg, slog.Default())
+	report, err := svc.RunAudit(context.Background())
+	if err != nil {
+		t.Fatalf("RunAudit: %v", err)
+	}
+
+	found := make(map[string][]string)
+	for _, f := range report.Findings {
+		if f.Remediation == "" {
+			t.Errorf("Finding %s/%s has no remediation", f.Check, f.Resource)
+		}
+		found[f.Check] = append(found[f.Check], f.Resource)
+	}
+	expect := map[string]string{
+		constants.AuditCheckAppNodeMissing:       stray.ID,
+		constants.AuditCheckTunnelWithoutApp:     "tunnel-1",
+		constants.AuditCheckComposeVersionFlag:   stray.ID,
+		constants.AuditCheckJobStuckPending:      stuck.ID,
+		constants.AuditCheckDuplicateMetricsPort: "port/2005",
+		constants.AuditCheckNetworkWithoutApp:    "deleted-app_default",
+	}
+	for check, resource := range expect {
+		if len(found[check]) != 1 || found[check][0] != resource {
+			t.Errorf("Expected one %s finding for %s, got %v", check, resource, found[check])
+		}
+	}
+	if len(report.Findings) != len(expect) {
+		t.Errorf("Expected %d findings, got %d", len(expect), len(report.Findings))
+	}
+
+	// The report is stored, so it outlives the service
+	latest, err := NewAuditService(database, cfg, slog.Default()).LatestAudit(context.Background())
+	if err != nil || latest.ID != report.ID || len(latest.Findings) != len(report.Findings) {
+		t.Errorf("Expected LatestAudit to return the stored report, got %+v (err %v)", latest, err)
+	}
+	for i := 0; i < constants.ConsistencyAuditsKept; i++ {
+		if _, err := svc.RunAudit(context.Background()); err != nil {
+			t.Fatalf("RunAudit: %v", err)
+		}
+	}
+	if reports, err := svc.ListAudits(context.Background()); err != nil || len(reports) != constants.ConsistencyAuditsKept || reports[0].ID == report.ID {
+		t.Errorf("Expected the last %d reports to be kept, got %d (err %v)", constants.ConsistencyAuditsKept, len(reports), err)
+	}
+	for _, f := range report.Findings {
+		if f.Check == constants.AuditCheckDuplicateMetricsPort && !strings.Contains(f.Message, "quick-a, quick-b") {
+			t.Errorf("Expected both apps in the message, got %q", f.Message)
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"os"
+
+	"github.com/selfhostly/internal/applock"
+	"github.com/selfhostly/internal/db"
+	"github.com/selfhostly/internal/docker"
+	"github.com/selfhostly/internal/domain"
+)
+
+// composeIntegrity compares the app's docker-compose.yml on disk with the checksum of its current
+// compose version. A missing file counts as unmodified, since deploys recreate it from the database.
+func composeIntegrity(database *db.DB, dockerManager *docker.Manager, app *db.App) (*domain.ComposeIntegrity, error) {
+	integrity := &domain.ComposeIntegrity{AppID: app.ID, ExpectedChecksum: db.ComposeChecksum(app.ComposeContent)}
+	current, err := database.GetCurrentComposeVersion(app.ID)
+	switch {
+	case err == nil:
+		integrity.Version = current.Version
+		integrity.ExpectedChecksum = current.Checksum
+	case !errors.Is(err, sql.ErrNoRows):
+		return nil, domain.WrapDatabaseOperation("get current compose version", err)
+	}
+
+	content, err := dockerManager.ReadComposeFile(app.Name)
+	if os.IsNotExist(err) {
+		return integrity, nil
+	}
+	if err != nil {
+		return nil, domain.WrapContainerOperationFailed("read compose file", err)
+	}
+	integrity.DiskChecksum = db.ComposeChecksum(content)
+	// Some tunnel changes rewrite the app's compose without saving a version; what was saved on the
+	// app is what selfhostly last wrote, so it isn't an out-of-band edit either
+	integrity.Modified = integrity.DiskChecksum != integrity.ExpectedChecksum && content != app.ComposeContent
+	return integrity, nil
+}
+
+// verifyComposeFile checks that nobody edited the app's docker-compose.yml outside of its compose
+// versions before it is deployed. An edited file is refused when RefuseModifiedCompose is set and
+// otherwise returns a warning for the caller to surface.
+func (s *appService) verifyComposeFile(ctx context.Context, app *db.App) (string, error) {
+	integrity, err := composeIntegrity(s.database, s.dockerManager, app)
+	if err != nil {
+		s.logger.WarnContext(ctx, "could not verify compose file", "app", app.Name, "appID", app.ID, "error", err)
+		return "", nil
+	}
+	if !integrity.Modified {
+		return "", nil
+	}
+
+	if s.config.Security.RefuseModifiedCompose {
+		s.logger.WarnContext(ctx, "refusing to deploy modified compose file", "app", app.Name, "appID", app.ID, "version", integrity.Version)
+		return "", domain.WrapComposeModified(app.ID, integrity.Version)
+	}
+	s.logger.WarnContext(ctx, "compose file was edited on disk outside of its versions", "app", app.Name, "appID", app.ID,
+		"version", integrity.Version, "expected", integrity.ExpectedChecksum, "actual", integrity.DiskChecksum)
+	return "docker-compose.yml was edited on disk and doesn't match the current compose version", nil
+}
+
+// CheckIntegrity reports whether the app's docker-compose.yml still matches its current compose version (local only)
+func (s *composeService) CheckIntegrity(ctx context.Context, appID string, nodeID string) (*domain.ComposeIntegrity, error) {
+	s.logger.DebugContext(ctx, "checking compose file integrity", "appID", appID, "nodeID", nodeID)
+	app, err := s.database.GetApp(appID)
+	if err != nil {
+		return nil, domain.WrapAppNotFound(appID, err)
+	}
+	return composeIntegrity(s.database, s.dockerManager, app)
+}
+
+// RestoreComposeFile rewrites the app's docker-compose.yml from its current compose version, the
+// edited file being kept as docker-compose.yml.bak (local only)
+func (s *composeService) RestoreComposeFile(ctx context.Context, appID string, nodeID string) (*domain.ComposeIntegrity, error) {
+	s.logger.InfoContext(ctx, "restoring compose file", "appID", appID, "nodeID", nodeID)
+	ctx, unlock, err := applock.Acquire(ctx, s.database, appID, "compose restore")
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+	app, err := s.database.GetApp(appID)
+	if err != nil {
+		return nil, domain.WrapAppNotFound(appID, err)
+	}
+	if err := s.dockerManager.WriteComposeFile(app.Name, app.ComposeContent); err != nil {
+		return nil, domain.WrapContainerOperationFailed("write compose file", err)
+	}
+	s.logger.InfoContext(ctx, "restored compose file from current version", "app", app.Name, "appID", appID)
+	return composeIntegrity(s.database, s.dockerManager, app)
+}
@@ -0,0 +1,98 @@
+package service
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/selfhostly/internal/db"
+	"github.com/selfhostly/internal/docker"
+	"github.com/selfhostly/internal/domain"
+)
+
+func TestComposeService_CheckIntegrity(t *testing.T) {
+	service, database, appsDir, cleanup := setupTestComposeServiceWithAppsDir(t, docker.NewMockCommandExecutor())
+	defer cleanup()
+	ctx := context.Background()
+
+	content := "services:\n  web:\n    image: nginx:latest\n"
+	app := db.NewApp("test-app", "", content)
+	if err := database.CreateApp(app); err != nil {
+		t.Fatalf("Failed to create app: %v", err)
+	}
+	if err := database.CreateComposeVersion(db.NewComposeVersion(app.ID, 1, content, nil, nil)); err != nil {
+		t.Fatalf("Failed to create version: %v", err)
+	}
+	composePath := filepath.Join(appsDir, app.Name, docker.ComposeFileName)
+	if err := os.MkdirAll(filepath.Dir(composePath), 0755); err != nil {
+		t.Fatalf("Failed to create app directory: %v", err)
+	}
+	if err := os.WriteFile(composePath, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write compose file: %v", err)
+	}
+
+	integrity, err := service.CheckIntegrity(ctx, app.ID, "")
+	if err != nil {
+		t.Fatalf("CheckIntegrity: %v", err)
+	}
+	if integrity.Modified || integrity.Version != 1 || integrity.ExpectedChecksum != db.ComposeChecksum(content) {
+		t.Errorf("Expected an untouched file to match version 1, got %+v", integrity)
+	}
+
+	edited := content + "  db:\n    image: postgres:16\n"
+	if err := os.WriteFile(composePath, []byte(edited), 0644); err != nil {
+		t.Fatalf("Failed to edit compose file: %v", err)
+	}
+	if integrity, _ := service.CheckIntegrity(ctx, app.ID, ""); !integrity.Modified || integrity.DiskChecksum != db.ComposeChecksum(edited) {
+		t.Errorf("Expected the edit to be detected, got %+v", integrity)
+	}
+
+	integrity, err = service.RestoreComposeFile(ctx, app.ID, "")
+	if err != nil {
+		t.Fatalf("RestoreComposeFile: %v", err)
+	}
+	if integrity.Modified {
+		t.Errorf("Expected the restored file to match, got %+v", integrity)
+	}
+	backup, _ := os.ReadFile(filepath.Join(appsDir, app.Name, docker.ComposeBackupFileName))
+	if !strings.Contains(string(backup), "postgres") {
+		t.Errorf("Expected the edited file to be kept as a backup, got %q", backup)
+	}
+}
+
+func TestAppService_StartApp_ModifiedCompose(t *testing.T) {
+	mockExecutor := docker.NewMockCommandExecutor()
+	service, _, cleanup := setupTestAppServiceWithMocks(t, mockExecutor)
+	defer cleanup()
+	ctx := context.Background()
+
+	app, err := service.CreateApp(ctx, domain.CreateAppRequest{Name: "test-app", ComposeContent: "services:\n  web:\n    image: nginx:latest\n"})
+	if err != nil {
+		t.Fatalf("Failed to create app: %v", err)
+	}
+	appSvc := service.(*appService)
+	composePath := filepath.Join(appSvc.dockerManager.AppPath(app.Name), docker.ComposeFileName)
+	if err := os.WriteFile(composePath, []byte("services:\n  web:\n    image: nginx:edited\n"), 0644); err != nil {
+		t.Fatalf("Failed to edit compose file: %v", err)
+	}
+	mockExecutor.SetMockOutput("docker", []string{"compose", "-f", "docker-compose.yml", "up", "-d"}, []byte("success"))
+
+	// Warned about by default
+	if _, err := service.StartApp(ctx, app.ID, app.NodeID); err != nil {
+		t.Fatalf("Expected the edited app to start with a warning, got %v", err)
+	}
+	job, err := service.StartAppAsync(ctx, app.ID)
+	if err != nil {
+		t.Fatalf("StartAppAsync: %v", err)
+	}
+	if job.ProgressMessage == nil || !strings.Contains(*job.ProgressMessage, "edited") {
+		t.Errorf("Expected the start job to carry the warning, got %v", job.ProgressMessage)
+	}
+
+	appSvc.config.Security.RefuseModifiedCompose = true
+	if _, err := service.StartApp(ctx, app.ID, app.NodeID); !domain.IsConflictError(err) {
+		t.Errorf("Expected the edited app to be refused, got %v", err)
+	}
+}
@@ -0,0 +1,69 @@
+package service
+
+import (
+	"context"
+
+	"github.com/selfhostly/internal/docker"
+	"github.com/selfhostly/internal/domain"
+	"github.com/selfhostly/internal/validation"
+)
+
+// PreviewCompose renders the compose an app would be deployed with. Unsaved compose content gets
+// its tunnel sidecar regenerated the way UpdateApp would, so the preview matches the next deploy.
+// Apps with compose files are merged in a scratch copy of the app directory, so unsaved ones work too.
+func (s *appService) PreviewCompose(ctx context.Context, appID string, req domain.ComposePreviewRequest) (*domain.ComposePreview, error) {
+	app, err := s.database.GetApp(appID)
+	if err != nil {
+		return nil, domain.WrapAppNotFound(appID, err)
+	}
+
+	securityConfig := s.composeSecurityConfig(ctx, nil)
+	composeContent := app.ComposeContent
+	tunnelCompose := app.TunnelCompose
+	composeFiles := app.ComposeFiles
+	if req.ComposeFiles != nil {
+		if err := validation.ValidateComposeFiles(req.ComposeFiles); err != nil {
+			return nil, domain.WrapValidationError("compose files", err)
+		}
+		composeFiles = req.ComposeFiles
+	}
+	if req.ComposeContent != "" || req.ComposeFiles != nil {
+		if req.ComposeContent != "" {
+			composeContent = req.ComposeContent
+		}
+		if err := validation.ValidateComposeContentWithFiles(composeContent, composeFiles, securityConfig); err != nil {
+			return nil, composeValidationError("compose content", err)
+		}
+
+		settings, err := s.settingsManager.GetSettings()
+		if err != nil {
+			return nil, domain.WrapDatabaseOperation("get settings", err)
+		}
+		containerConfig, err := s.tunnelContainerConfig(ctx, app, settings)
+		if err != nil {
+			s.logger.WarnContext(ctx, "failed to build tunnel container config, previewing existing sidecar", "appID", appID, "error", err)
+		} else if containerConfig != nil {
+			composeContent, tunnelCompose, err = docker.GenerateTunnelCompose(composeContent, composeFiles, app.Name, containerConfig)
+			if err != nil {
+				return nil, domain.WrapComposeInvalid(err)
+			}
+		}
+	}
+
+	composeOverride := app.ComposeOverride
+	if req.ComposeOverride != nil {
+		composeOverride = *req.ComposeOverride
+	}
+
+	preview, err := s.dockerManager.PreviewCompose(app.Name, docker.AppComposeSources(composeContent, tunnelCompose, composeOverride), composeFiles, app.EnvContent)
+	if err != nil {
+		return nil, domain.WrapComposeInvalid(err)
+	}
+
+	result := &domain.ComposePreview{Files: preview.Files, Compose: preview.Compose, Warnings: preview.Warnings,
+		Variables: make([]*domain.ComposeVariable, len(preview.Variables))}
+	for i, v := range preview.Variables {
+		result.Variables[i] = &domain.ComposeVariable{Name: v.Name, Value: v.Value, Source: v.Source}
+	}
+	return result, nil
+}
@@ -6,6 +6,7 @@ import (
 	"log/slog"
 	"time"
 
+	"github.com/selfhostly/internal/applock"
 	"github.com/selfhostly/internal/db"
 	"github.com/selfhostly/internal/docker"
 	"github.com/selfhostly/internal/domain"
@@ -19,6 +20,7 @@ type composeService struct {
 	dockerManager *docker.Manager
 	router        *routing.NodeRouter
 	nodeClient    *node.Client
+	quotas        domain.QuotaService
 	logger        *slog.Logger
 }
 
@@ -28,6 +30,7 @@ func NewComposeService(
 	dockerManager *docker.Manager,
 	router *routing.NodeRouter,
 	nodeClient *node.Client,
+	quotas domain.QuotaService,
 	logger *slog.Logger,
 ) domain.ComposeService {
 	return &composeService{
@@ -35,6 +38,7 @@ func NewComposeService(
 		dockerManager: dockerManager,
 		router:        router,
 		nodeClient:    nodeClient,
+		quotas:        quotas,
 		logger:        logger,
 	}
 }
@@ -71,6 +75,11 @@ func (s *composeService) GetVersion(ctx context.Context, appID string, version i
 // RollbackToVersion rolls back to a specific compose version (local only)
 func (s *composeService) RollbackToVersion(ctx context.Context, appID string, version int, nodeID string, reason *string, changedBy *string) (*db.ComposeVersion, error) {
 	s.logger.InfoContext(ctx, "rolling back to version", "appID", appID, "version", version, "nodeID", nodeID)
+	ctx, unlock, err := applock.Acquire(ctx, s.database, appID, "rollback")
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
 	app, err := s.database.GetApp(appID)
 	if err != nil {
 		return nil, domain.WrapAppNotFound(appID, err)
@@ -90,7 +99,30 @@ func (s *composeService) RollbackToVersion(ctx context.Context, appID string, ve
 		r := fmt.Sprintf("Rolled back to version %d", version)
 		changeReason = &r
 	}
-	newVersion := db.NewComposeVersion(appID, newVersionNumber, targetComposeVersion.ComposeContent, changeReason, changedBy)
+	composeContent := targetComposeVersion.ComposeContent
+	// Versions saved before the tunnel sidecar had its own file may carry it inline;
+	// the app's current sidecar takes precedence
+	if app.TunnelCompose != "" {
+		if stripped, removed, err := docker.StripTunnelService(composeContent); err == nil && removed {
+			composeContent = stripped
+		}
+	}
+	// Roll back to the exact images that were running, not whatever the tags point at today
+	if len(targetComposeVersion.ImageDigests) > 0 {
+		pinnedContent, pinned, err := docker.PinComposeImages(composeContent, targetComposeVersion.ImageDigests)
+		if err != nil {
+			s.logger.WarnContext(ctx, "could not pin images for rollback, using tags", "appID", appID, "version", version, "error", err)
+		} else if pinned {
+			composeContent = pinnedContent
+		}
+	}
+	if err := enforceQuota(ctx, s.quotas, app.Owner, app.ID, composeContent, targetComposeVersion.ComposeOverride, targetComposeVersion.ComposeFiles, app.TunnelMode != ""); err != nil {
+		return nil, err
+	}
+	newVersion := db.NewComposeVersion(appID, newVersionNumber, composeContent, changeReason, changedBy)
+	newVersion.ComposeOverride = targetComposeVersion.ComposeOverride
+	newVersion.ComposeFiles = targetComposeVersion.ComposeFiles
+	newVersion.ImageDigests = targetComposeVersion.ImageDigests
 	newVersion.RolledBackFrom = &rolledBackFrom
 	if err := s.database.MarkAllVersionsAsNotCurrent(appID); err != nil {
 		return nil, domain.WrapDatabaseOperation("mark versions as not current", err)
@@ -98,7 +130,10 @@ func (s *composeService) RollbackToVersion(ctx context.Context, appID string, ve
 	if err := s.database.CreateComposeVersion(newVersion); err != nil {
 		return nil, domain.WrapDatabaseOperation("create compose version", err)
 	}
-	app.ComposeContent = targetComposeVersion.ComposeContent
+	app.ComposeContent = composeContent
+	app.ComposeOverride = targetComposeVersion.ComposeOverride
+	previousFiles := app.ComposeFiles
+	app.ComposeFiles = targetComposeVersion.ComposeFiles
 	app.UpdatedAt = time.Now()
 	if err := s.database.UpdateApp(app); err != nil {
 		return nil, domain.WrapDatabaseOperation("update app", err)
@@ -106,6 +141,12 @@ func (s *composeService) RollbackToVersion(ctx context.Context, appID string, ve
 	if err := s.dockerManager.WriteComposeFile(app.Name, app.ComposeContent); err != nil {
 		return nil, domain.WrapContainerOperationFailed("write compose file", err)
 	}
+	if err := s.dockerManager.WriteComposeOverrideFile(app.Name, app.ComposeOverride); err != nil {
+		return nil, domain.WrapContainerOperationFailed("write compose override file", err)
+	}
+	if err := s.dockerManager.WriteComposeFiles(app.Name, app.ComposeFiles, previousFiles); err != nil {
+		return nil, domain.WrapContainerOperationFailed("write compose files", err)
+	}
 	s.logger.InfoContext(ctx, "rolled back compose version", "app", app.Name, "appID", appID, "fromVersion", version, "toVersion", newVersionNumber)
 	return newVersion, nil
 }
@@ -62,7 +62,7 @@ func setupTestComposeServiceWithAppsDir(t *testing.T, mockExecutor docker.Comman
 	logger := slog.Default()
 	nodeClient := node.NewClient()
 	router := routing.NewNodeRouter(database, nodeClient, testNodeID, logger)
-	service := NewComposeService(database, dockerManager, router, nodeClient, logger)
+	service := NewComposeService(database, dockerManager, router, nodeClient, nil, logger)
 
 	cleanup := func() {
 		database.Close()
@@ -346,6 +346,61 @@ func TestComposeService_RollbackToVersion(t *testing.T) {
 	}
 }
 
+func TestComposeService_RollbackToVersion_ComposeFiles(t *testing.T) {
+	service, database, tmpAppsDir, cleanup := setupTestComposeServiceWithAppsDir(t, docker.NewMockCommandExecutor())
+	defer cleanup()
+
+	ctx := context.Background()
+	nodes, err := database.GetAllNodes()
+	if err != nil || len(nodes) == 0 {
+		t.Fatalf("Failed to get test node: %v", err)
+	}
+
+	included := "include:\n  - db.yml\nservices:\n  web:\n    image: nginx:latest\n"
+	files := map[string]string{"db.yml": "services:\n  db:\n    image: postgres:16\n"}
+	app := db.NewApp("files-app", "", "services:\n  web:\n    image: nginx:latest\n")
+	app.NodeID = nodes[0].ID
+	if err := database.CreateApp(app); err != nil {
+		t.Fatalf("Failed to create app: %v", err)
+	}
+	appDir := filepath.Join(tmpAppsDir, "files-app")
+	if err := os.MkdirAll(appDir, 0755); err != nil {
+		t.Fatalf("Failed to create app directory: %v", err)
+	}
+
+	reason := constants.ComposeVersionReasonInitial
+	version1 := db.NewComposeVersion(app.ID, 1, included, &reason, nil)
+	version1.ComposeFiles = files
+	if err := database.CreateComposeVersion(version1); err != nil {
+		t.Fatalf("Failed to create version 1: %v", err)
+	}
+	version2 := db.NewComposeVersion(app.ID, 2, app.ComposeContent, &reason, nil)
+	if err := database.CreateComposeVersion(version2); err != nil {
+		t.Fatalf("Failed to create version 2: %v", err)
+	}
+	if err := database.MarkVersionAsCurrent(app.ID, 2); err != nil {
+		t.Fatalf("Failed to mark version as current: %v", err)
+	}
+
+	newVersion, err := service.RollbackToVersion(ctx, app.ID, 1, app.NodeID, nil, nil)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if newVersion.ComposeFiles["db.yml"] != files["db.yml"] {
+		t.Errorf("Expected the new version to keep version 1's compose files, got %v", newVersion.ComposeFiles)
+	}
+	updatedApp, err := database.GetApp(app.ID)
+	if err != nil {
+		t.Fatalf("Failed to get app: %v", err)
+	}
+	if updatedApp.ComposeFiles["db.yml"] != files["db.yml"] {
+		t.Errorf("Expected the app's compose files to be restored, got %v", updatedApp.ComposeFiles)
+	}
+	if data, err := os.ReadFile(filepath.Join(appDir, "db.yml")); err != nil || string(data) != files["db.yml"] {
+		t.Errorf("Expected db.yml to be written to the app directory, got %q, %v", data, err)
+	}
+}
+
 func TestComposeService_RollbackToVersion_NotFound(t *testing.T) {
 	mockExecutor := docker.NewMockCommandExecutor()
 	service, database, cleanup := setupTestComposeService(t, mockExecutor)
@@ -0,0 +1,264 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/selfhostly/internal/appstate"
+	"github.com/selfhostly/internal/config"
+	"github.com/selfhostly/internal/constants"
+	"github.com/selfhostly/internal/db"
+	"github.com/selfhostly/internal/docker"
+	"github.com/selfhostly/internal/domain"
+	"github.com/selfhostly/internal/webhook"
+)
+
+// containerHistory is what the crash monitor remembers about one container between checks
+type containerHistory struct {
+	restartCount int         // RestartCount at the last check
+	restarts     []time.Time // When restarts were first seen, within the window
+	oomKills     []time.Time // FinishedAt of OOM-killed exits, within the window
+}
+
+// crashProblem is why an app is degraded
+type crashProblem struct {
+	service string
+	reason  string
+}
+
+// crashMonitorService watches the containers of this node's running apps for OOM kills and crash
+// loops, marks affected apps degraded with the failing service's last logs, and recovers them
+// once their containers have been stable for the whole window. Restart history lives in memory;
+// after a restart the monitor starts counting from the containers' current restart counts.
+type crashMonitorService struct {
+	database      *db.DB
+	states        *appstate.Machine
+	dockerManager *docker.Manager
+	webhooks      *webhook.Dispatcher
+	config        *config.Config
+	logger        *slog.Logger
+	window        time.Duration
+
+	mu      sync.Mutex
+	history map[string]*containerHistory // By container ID
+}
+
+// NewCrashMonitorService creates a new crash monitor. webhooks may be nil.
+func NewCrashMonitorService(database *db.DB, states *appstate.Machine, dockerManager *docker.Manager, webhooks *webhook.Dispatcher, cfg *config.Config, logger *slog.Logger) domain.CrashMonitorService {
+	return &crashMonitorService{
+		database:      database,
+		states:        states,
+		dockerManager: dockerManager,
+		webhooks:      webhooks,
+		config:        cfg,
+		logger:        logger,
+		window:        constants.CrashLoopWindow,
+		history:       make(map[string]*containerHistory),
+	}
+}
+
+// CheckApps inspects the containers of every running or degraded app on this node. Apps with an
+// operation in progress are skipped, since their containers are expected to come and go.
+func (s *crashMonitorService) CheckApps(ctx context.Context) error {
+	apps, err := s.database.GetAllAppsMetadata()
+	if err != nil {
+		return domain.WrapDatabaseOperation("list apps", err)
+	}
+
+	seen := make(map[string]bool)
+	for _, app := range apps {
+		if app.NodeID != "" && app.NodeID != s.config.Node.ID {
+			continue
+		}
+		if app.Status != constants.AppStatusRunning && app.Status != constants.AppStatusDegraded {
+			continue
+		}
+		if _, err := s.database.GetAppLock(app.ID); err == nil {
+			continue
+		}
+		s.checkApp(ctx, app, seen)
+	}
+
+	// Forget containers that are gone, so recreated ones start from a clean slate
+	s.mu.Lock()
+	for id := range s.history {
+		if !seen[id] {
+			delete(s.history, id)
+		}
+	}
+	s.mu.Unlock()
+	return nil
+}
+
+// checkApp updates the history of an app's containers and flips it between running and degraded
+func (s *crashMonitorService) checkApp(ctx context.Context, app *db.App, seen map[string]bool) {
+	states, err := s.dockerManager.InspectAppContainers(app.Name)
+	if err != nil {
+		s.logger.DebugContext(ctx, "skipping crash check", "app", app.Name, "error", err)
+		return
+	}
+
+	now := time.Now()
+	var problem *crashProblem
+	allRunning := len(states) > 0
+	for _, state := range states {
+		seen[state.ID] = true
+		history := s.observe(state, now)
+		if problem == nil {
+			problem = s.diagnose(state, history)
+		}
+		if state.Status != "running" {
+			allRunning = false
+		}
+	}
+
+	switch {
+	case problem != nil && app.Status == constants.AppStatusRunning:
+		s.markDegraded(ctx, app, problem)
+	case problem == nil && app.Status == constants.AppStatusDegraded && allRunning:
+		s.markRecovered(ctx, app)
+	}
+}
+
+// observe records new restarts and OOM kills of a container and returns a copy of its history,
+// trimmed to the window. The first sighting of a container only sets its baseline restart count.
+func (s *crashMonitorService) observe(state docker.ContainerState, now time.Time) containerHistory {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	history, ok := s.history[state.ID]
+	if !ok {
+		history = &containerHistory{restartCount: state.RestartCount}
+		s.history[state.ID] = history
+	}
+	for ; history.restartCount < state.RestartCount; history.restartCount++ {
+		history.restarts = append(history.restarts, now)
+	}
+	if state.OOMKilled && !state.FinishedAt.IsZero() && !containsTime(history.oomKills, state.FinishedAt) {
+		history.oomKills = append(history.oomKills, state.FinishedAt)
+	}
+
+	cutoff := now.Add(-s.window)
+	history.restarts = timesAfter(history.restarts, cutoff)
+	history.oomKills = timesAfter(history.oomKills, cutoff)
+	return containerHistory{
+		restartCount: history.restartCount,
+		restarts:     append([]time.Time(nil), history.restarts...),
+		oomKills:     append([]time.Time(nil), history.oomKills...),
+	}
+}
+
+// diagnose returns why a container makes its app degraded, or nil when it doesn't
+func (s *crashMonitorService) diagnose(state docker.ContainerState, history containerHistory) *crashProblem {
+	service := state.Service
+	if service == "" {
+		service = state.Name
+	}
+	switch {
+	case len(history.oomKills) > 0:
+		return &crashProblem{service: service, reason: fmt.Sprintf("service %s was killed for running out of memory (exit code %d)", service, state.ExitCode)}
+	case len(history.restarts) >= constants.CrashLoopRestarts:
+		return &crashProblem{service: service, reason: fmt.Sprintf("service %s is crash-looping: %d restarts in the last %s (last exit code %d)", service, len(history.restarts), s.window, state.ExitCode)}
+	}
+	return nil
+}
+
+// markDegraded sets the app degraded with the failing service's last logs as its error details,
+// and fires the crash webhook event
+func (s *crashMonitorService) markDegraded(ctx context.Context, app *db.App, problem *crashProblem) {
+	details := problem.reason
+	if logs, err := s.dockerManager.TailAppLogs(app.Name, problem.service, constants.CrashLogTailLines); err != nil {
+		s.logger.DebugContext(ctx, "failed to capture crash logs", "app", app.Name, "service", problem.service, "error", err)
+	} else if logs != "" {
+		details += fmt.Sprintf("\n\nLast logs of %s:\n%s", problem.service, logs)
+	}
+
+	s.logger.WarnContext(ctx, "app degraded", "app", app.Name, "appID", app.ID, "reason", problem.reason)
+	if err := s.transition(ctx, app.ID, constants.AppStatusDegraded, details); err != nil {
+		s.logger.WarnContext(ctx, "failed to mark app degraded", "app", app.Name, "appID", app.ID, "error", err)
+		return
+	}
+	s.webhooks.Fire(ctx, app, constants.WebhookEventCrash, problem.reason)
+}
+
+// markRecovered sets a degraded app back to running
+func (s *crashMonitorService) markRecovered(ctx context.Context, app *db.App) {
+	s.logger.InfoContext(ctx, "app recovered", "app", app.Name, "appID", app.ID)
+	if err := s.transition(ctx, app.ID, constants.AppStatusRunning, "containers are healthy again"); err != nil {
+		s.logger.WarnContext(ctx, "failed to mark app recovered", "app", app.Name, "appID", app.ID, "error", err)
+	}
+}
+
+// transition changes an app's status. CheckApps works on metadata rows, which lack the compose and
+// env files, so the full app is loaded first: Transition saves every column.
+func (s *crashMonitorService) transition(ctx context.Context, appID, to, reason string) error {
+	app, err := s.database.GetApp(appID)
+	if err != nil {
+		return err
+	}
+	return s.states.Transition(ctx, app, to, reason)
+}
+
+// GetAppContainerHealth returns the state of an app's containers with the restarts seen within
+// the crash-loop window (local only)
+func (s *crashMonitorService) GetAppContainerHealth(ctx context.Context, appID string) (*domain.AppContainerHealth, error) {
+	app, err := s.database.GetApp(appID)
+	if err != nil {
+		return nil, domain.WrapAppNotFound(appID, err)
+	}
+	states, err := s.dockerManager.InspectAppContainers(app.Name)
+	if err != nil {
+		return nil, domain.WrapContainerOperationFailed("inspect app containers", err)
+	}
+
+	health := &domain.AppContainerHealth{
+		AppID:      app.ID,
+		AppName:    app.Name,
+		Status:     app.Status,
+		Containers: make([]*domain.ContainerHealth, len(states)),
+		CheckedAt:  time.Now(),
+	}
+	for i, state := range states {
+		history := s.observe(state, health.CheckedAt)
+		container := &domain.ContainerHealth{
+			Name:           state.Name,
+			Service:        state.Service,
+			State:          state.Status,
+			ExitCode:       state.ExitCode,
+			OOMKilled:      state.OOMKilled,
+			RestartCount:   state.RestartCount,
+			RecentRestarts: len(history.restarts),
+			CrashLooping:   len(history.restarts) >= constants.CrashLoopRestarts,
+		}
+		if !state.FinishedAt.IsZero() {
+			finishedAt := state.FinishedAt
+			container.FinishedAt = &finishedAt
+		}
+		health.Containers[i] = container
+	}
+	return health, nil
+}
+
+// containsTime reports whether times holds t
+func containsTime(times []time.Time, t time.Time) bool {
+	for _, existing := range times {
+		if existing.Equal(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// timesAfter drops the times at or before cutoff, keeping the order of the rest
+func timesAfter(times []time.Time, cutoff time.Time) []time.Time {
+	kept := times[:0]
+	for _, t := range times {
+		if t.After(cutoff) {
+			kept = append(kept, t)
+		}
+	}
+	return kept
+}
@@ -0,0 +1,148 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/selfhostly/internal/appstate"
+	"github.com/selfhostly/internal/config"
+	"github.com/selfhostly/internal/constants"
+	"github.com/selfhostly/internal/db"
+	"github.com/selfhostly/internal/docker"
+)
+
+func TestCrashMonitor_CrashLoop(t *testing.T) {
+	tmpDir := t.TempDir()
+	database, err := db.Init(filepath.Join(tmpDir, "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to initialize database: %v", err)
+	}
+	defer database.Close()
+
+	mockExecutor := docker.NewMockCommandExecutor()
+	dockerManager := docker.NewManagerWithExecutor(filepath.Join(tmpDir, "apps"), mockExecutor)
+	cfg := &config.Config{Node: config.NodeConfig{ID: "self", IsPrimary: true}}
+
+	app := db.NewApp("looper", "", "services:\n  web:\n    image: nginx\n")
+	app.Status = constants.AppStatusRunning
+	app.NodeID = "self"
+	if err := database.CreateApp(app); err != nil {
+		t.Fatalf("Failed to create app: %v", err)
+	}
+	other := db.NewApp("elsewhere", "", "services:\n  web:\n    image: nginx\n")
+	other.Status = constants.AppStatusRunning
+	other.NodeID = "other-node"
+	if err := database.CreateApp(other); err != nil {
+		t.Fatalf("Failed to create app: %v", err)
+	}
+
+	listCmd := docker.DockerProjectContainersCommand("looper")
+	inspectCmd := docker.DockerInspectCommand("looper-web-1")
+	logsCmd := docker.ComposeLogsTailCommand(constants.CrashLogTailLines, "web")
+	mockExecutor.SetMockOutput("docker", listCmd[1:], []byte("looper-web-1\n"))
+	mockExecutor.SetMockOutput("docker", logsCmd[1:], []byte("web-1  | panic: config missing\n"))
+	setState := func(status string, restarts int) {
+		inspect := fmt.Sprintf(`[{"Id": "c1", "Name": "/looper-web-1", "RestartCount": %d,
+			"State": {"Status": %q, "ExitCode": 2, "FinishedAt": "2024-01-15T10:00:00Z"},
+			"Config": {"Labels": {"com.docker.compose.service": "web"}}}]`, restarts, status)
+		mockExecutor.SetMockOutput("docker", inspectCmd[1:], []byte(inspect))
+	}
+
+	monitor := NewCrashMonitorService(database, appstate.New(database, slog.Default()), dockerManager, nil, cfg, slog.Default()).(*crashMonitorService)
+	ctx := context.Background()
+	check := func() *db.App {
+		t.Helper()
+		if err := monitor.CheckApps(ctx); err != nil {
+			t.Fatalf("CheckApps: %v", err)
+		}
+		stored, err := database.GetApp(app.ID)
+		if err != nil {
+			t.Fatalf("GetApp: %v", err)
+		}
+		return stored
+	}
+
+	// Restarts from before the monitor first saw the container don't count
+	setState("running", 7)
+	if stored := check(); stored.Status != constants.AppStatusRunning {
+		t.Fatalf("Expected running after the first check, got %s", stored.Status)
+	}
+
+	setState("restarting", 7+constants.CrashLoopRestarts)
+	stored := check()
+	if stored.Status != constants.AppStatusDegraded {
+		t.Fatalf("Expected degraded after %d restarts, got %s", constants.CrashLoopRestarts, stored.Status)
+	}
+	if stored.ErrorMessage == nil || !strings.Contains(*stored.ErrorMessage, "crash-looping") || !strings.Contains(*stored.ErrorMessage, "panic: config missing") {
+		t.Errorf("Expected the reason and last logs in the error details, got %v", stored.ErrorMessage)
+	}
+	if stored.ComposeContent != app.ComposeContent {
+		t.Errorf("Expected the compose file to survive the status change, got %q", stored.ComposeContent)
+	}
+
+	health, err := monitor.GetAppContainerHealth(ctx, app.ID)
+	if err != nil {
+		t.Fatalf("GetAppContainerHealth: %v", err)
+	}
+	if len(health.Containers) != 1 || health.Containers[0].RecentRestarts != constants.CrashLoopRestarts || !health.Containers[0].CrashLooping || health.Containers[0].ExitCode != 2 {
+		t.Errorf("Unexpected container health %+v", health.Containers)
+	}
+
+	// Apps on other nodes are never inspected
+	otherList := docker.DockerProjectContainersCommand("elsewhere")
+	if mockExecutor.AssertCommandExecuted("docker", otherList[1:]) {
+		t.Error("Expected apps on other nodes to be skipped")
+	}
+
+	// Once the restarts fall out of the window and the container is up, the app recovers
+	monitor.window = 10 * time.Millisecond
+	time.Sleep(20 * time.Millisecond)
+	setState("running", 7+constants.CrashLoopRestarts)
+	if stored := check(); stored.Status != constants.AppStatusRunning || stored.ErrorMessage != nil {
+		t.Errorf("Expected the app to recover, got %s (%v)", stored.Status, stored.ErrorMessage)
+	}
+}
+
+func TestCrashMonitor_OOMKill(t *testing.T) {
+	tmpDir := t.TempDir()
+	database, err := db.Init(filepath.Join(tmpDir, "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to initialize database: %v", err)
+	}
+	defer database.Close()
+
+	mockExecutor := docker.NewMockCommandExecutor()
+	dockerManager := docker.NewManagerWithExecutor(filepath.Join(tmpDir, "apps"), mockExecutor)
+	cfg := &config.Config{Node: config.NodeConfig{ID: "self", IsPrimary: true}}
+
+	app := db.NewApp("hungry", "", "services:\n  worker:\n    image: busybox\n")
+	app.Status = constants.AppStatusRunning
+	if err := database.CreateApp(app); err != nil {
+		t.Fatalf("Failed to create app: %v", err)
+	}
+
+	listCmd := docker.DockerProjectContainersCommand("hungry")
+	inspectCmd := docker.DockerInspectCommand("hungry-worker-1")
+	mockExecutor.SetMockOutput("docker", listCmd[1:], []byte("hungry-worker-1\n"))
+	inspect := fmt.Sprintf(`[{"Id": "c2", "Name": "/hungry-worker-1", "RestartCount": 0,
+		"State": {"Status": "exited", "OOMKilled": true, "ExitCode": 137, "FinishedAt": %q},
+		"Config": {"Labels": {"com.docker.compose.service": "worker"}}}]`, time.Now().Add(-time.Minute).UTC().Format(time.RFC3339Nano))
+	mockExecutor.SetMockOutput("docker", inspectCmd[1:], []byte(inspect))
+
+	monitor := NewCrashMonitorService(database, appstate.New(database, slog.Default()), dockerManager, nil, cfg, slog.Default())
+	if err := monitor.CheckApps(context.Background()); err != nil {
+		t.Fatalf("CheckApps: %v", err)
+	}
+	stored, err := database.GetApp(app.ID)
+	if err != nil {
+		t.Fatalf("GetApp: %v", err)
+	}
+	if stored.Status != constants.AppStatusDegraded || stored.ErrorMessage == nil || !strings.Contains(*stored.ErrorMessage, "out of memory") {
+		t.Errorf("Expected the OOM kill to degrade the app, got %s (%v)", stored.Status, stored.ErrorMessage)
+	}
+}
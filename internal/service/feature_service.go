@@ -0,0 +1,114 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+
+	"github.com/selfhostly/internal/config"
+	"github.com/selfhostly/internal/db"
+	"github.com/selfhostly/internal/domain"
+	"github.com/selfhostly/internal/features"
+)
+
+// featureService resolves feature flags from env overrides, then settings, then the default (off)
+type featureService struct {
+	database *db.DB
+	config   *config.Config
+	logger   *slog.Logger
+}
+
+// NewFeatureService creates a new feature flag service
+func NewFeatureService(database *db.DB, cfg *config.Config, logger *slog.Logger) domain.FeatureService {
+	return &featureService{
+		database: database,
+		config:   cfg,
+		logger:   logger,
+	}
+}
+
+// IsEnabled reports whether flag is on. Subsystems gate on this; a settings read failure keeps
+// the feature off.
+func (s *featureService) IsEnabled(ctx context.Context, flag features.Flag) bool {
+	if enabled, ok := s.config.FeatureOverrides[flag]; ok {
+		return enabled
+	}
+	settings, err := s.database.GetSettings()
+	if err != nil {
+		s.logger.WarnContext(ctx, "failed to read feature flags, treating as disabled", "flag", flag, "error", err)
+		return false
+	}
+	flags, err := settings.GetFeatureFlags()
+	if err != nil {
+		s.logger.WarnContext(ctx, "invalid feature flags in settings, treating as disabled", "flag", flag, "error", err)
+		return false
+	}
+	return flags[string(flag)]
+}
+
+// ListFeatureFlags returns every known flag with its effective value
+func (s *featureService) ListFeatureFlags(ctx context.Context) ([]*domain.FeatureFlag, error) {
+	stored, err := s.storedFlags()
+	if err != nil {
+		return nil, err
+	}
+
+	definitions := features.All()
+	result := make([]*domain.FeatureFlag, 0, len(definitions))
+	for _, d := range definitions {
+		result = append(result, s.resolve(d, stored))
+	}
+	return result, nil
+}
+
+// SetFeatureFlag stores a flag's value in settings. Flags pinned by an env var can't be changed here.
+func (s *featureService) SetFeatureFlag(ctx context.Context, name string, enabled bool) (*domain.FeatureFlag, error) {
+	definition, ok := features.Lookup(name)
+	if !ok {
+		return nil, domain.WrapFeatureFlagNotFound(name)
+	}
+	if _, pinned := s.config.FeatureOverrides[definition.Name]; pinned {
+		return nil, domain.WrapValidationError(name, fmt.Errorf("set by %s; change the environment variable instead", features.EnvVar(definition.Name)))
+	}
+
+	settings, err := s.database.GetSettings()
+	if err != nil {
+		return nil, domain.WrapDatabaseOperation("get settings", err)
+	}
+	if err := settings.SetFeatureFlag(name, enabled); err != nil {
+		return nil, err
+	}
+	if err := s.database.UpdateSettings(settings); err != nil {
+		return nil, domain.WrapDatabaseOperation("update settings", err)
+	}
+
+	s.logger.InfoContext(ctx, "feature flag updated", "flag", name, "enabled", enabled)
+
+	stored, _ := settings.GetFeatureFlags()
+	return s.resolve(definition, stored), nil
+}
+
+// storedFlags returns the flag values saved in settings
+func (s *featureService) storedFlags() (map[string]bool, error) {
+	settings, err := s.database.GetSettings()
+	if err != nil {
+		return nil, domain.WrapDatabaseOperation("get settings", err)
+	}
+	return settings.GetFeatureFlags()
+}
+
+// resolve computes a flag's effective value and source
+func (s *featureService) resolve(d features.Definition, stored map[string]bool) *domain.FeatureFlag {
+	flag := &domain.FeatureFlag{
+		Name:        string(d.Name),
+		Description: d.Description,
+		Source:      features.SourceDefault,
+		EnvVar:      features.EnvVar(d.Name),
+	}
+	if enabled, ok := s.config.FeatureOverrides[d.Name]; ok {
+		flag.Enabled, flag.Source = enabled, features.SourceEnv
+	} else if enabled, ok := stored[flag.Name]; ok {
+		flag.Enabled, flag.Source = enabled, features.SourceSettings
+	}
+	return flag
+}
@@ -0,0 +1,67 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"path/filepath"
+	"testing"
+
+	"github.com/selfhostly/internal/config"
+	"github.com/selfhostly/internal/db"
+	"github.com/selfhostly/internal/domain"
+	"github.com/selfhostly/internal/features"
+)
+
+func TestFeatureService(t *testing.T) {
+	database, err := db.Init(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to initialize database: %v", err)
+	}
+	defer database.Close()
+
+	cfg := &config.Config{FeatureOverrides: map[features.Flag]bool{features.PostgresBackend: false}}
+	svc := NewFeatureService(database, cfg, slog.Default())
+	ctx := context.Background()
+
+	if svc.IsEnabled(ctx, features.GitOps) {
+		t.Error("expected flags to default to off")
+	}
+
+	flag, err := svc.SetFeatureFlag(ctx, string(features.GitOps), true)
+	if err != nil {
+		t.Fatalf("SetFeatureFlag: %v", err)
+	}
+	if !flag.Enabled || flag.Source != features.SourceSettings {
+		t.Errorf("unexpected flag %+v", flag)
+	}
+	if !svc.IsEnabled(ctx, features.GitOps) {
+		t.Error("expected gitops to be enabled from settings")
+	}
+
+	// Env-pinned flags can't be changed through settings
+	if _, err := svc.SetFeatureFlag(ctx, string(features.PostgresBackend), true); !domain.IsValidationError(err) {
+		t.Errorf("expected validation error for env-pinned flag, got %v", err)
+	}
+	if _, err := svc.SetFeatureFlag(ctx, "warp_drive", true); !domain.IsNotFoundError(err) {
+		t.Errorf("expected not found error for unknown flag, got %v", err)
+	}
+
+	flags, err := svc.ListFeatureFlags(ctx)
+	if err != nil {
+		t.Fatalf("ListFeatureFlags: %v", err)
+	}
+	sources := map[string]string{}
+	for _, f := range flags {
+		sources[f.Name] = f.Source
+	}
+	want := map[string]string{
+		string(features.BlueGreenUpdates): features.SourceDefault,
+		string(features.GitOps):           features.SourceSettings,
+		string(features.PostgresBackend):  features.SourceEnv,
+	}
+	for name, source := range want {
+		if sources[name] != source {
+			t.Errorf("flag %s source = %q, want %q", name, sources[name], source)
+		}
+	}
+}
@@ -0,0 +1,193 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/selfhostly/internal/config"
+	"github.com/selfhostly/internal/constants"
+	"github.com/selfhostly/internal/db"
+	"github.com/selfhostly/internal/domain"
+	"github.com/selfhostly/internal/gatewaytoken"
+)
+
+// errGatewayTokenRejected is returned for any token that can't be used, without saying why
+var errGatewayTokenRejected = errors.New("gateway token is invalid, expired or revoked")
+
+// gatewayTokenService issues and checks the tokens the gateway fetches the node registry with
+type gatewayTokenService struct {
+	database *db.DB
+	config   *config.Config
+	logger   *slog.Logger
+}
+
+// NewGatewayTokenService creates a new gateway token service
+func NewGatewayTokenService(database *db.DB, cfg *config.Config, logger *slog.Logger) domain.GatewayTokenService {
+	return &gatewayTokenService{
+		database: database,
+		config:   cfg,
+		logger:   logger,
+	}
+}
+
+// ListTokens returns every issued token, revoked and expired ones included
+func (s *gatewayTokenService) ListTokens(ctx context.Context) ([]*db.GatewayToken, error) {
+	tokens, err := s.database.GetGatewayTokens()
+	if err != nil {
+		return nil, domain.WrapDatabaseOperation("get gateway tokens", err)
+	}
+	return tokens, nil
+}
+
+// IssueToken creates a token valid for the requested number of hours
+func (s *gatewayTokenService) IssueToken(ctx context.Context, req domain.IssueGatewayTokenRequest) (*domain.IssuedGatewayToken, error) {
+	if err := s.requirePrimary(); err != nil {
+		return nil, err
+	}
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		return nil, domain.WrapValidationError("name", fmt.Errorf("name is required"))
+	}
+	ttl := constants.GatewayTokenDefaultTTL
+	if req.TTLHours != 0 {
+		ttl = time.Duration(req.TTLHours) * time.Hour
+		if req.TTLHours < 0 || ttl > constants.GatewayTokenMaxTTL {
+			return nil, domain.WrapValidationError("ttl_hours", fmt.Errorf("ttl_hours must be between 1 and %d", int(constants.GatewayTokenMaxTTL.Hours())))
+		}
+	}
+
+	issued, err := s.issue(db.NewGatewayToken(name, ttl))
+	if err != nil {
+		return nil, err
+	}
+	s.logger.InfoContext(ctx, "gateway token issued", "tokenID", issued.ID, "name", name, "expiresAt", issued.ExpiresAt)
+	return issued, nil
+}
+
+// RevokeToken revokes a token, so the primary stops accepting it at once
+func (s *gatewayTokenService) RevokeToken(ctx context.Context, tokenID string) error {
+	if err := s.database.RevokeGatewayToken(tokenID, time.Now()); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return domain.WrapGatewayTokenNotFound(tokenID, err)
+		}
+		return domain.WrapDatabaseOperation("revoke gateway token", err)
+	}
+	s.logger.InfoContext(ctx, "gateway token revoked", "tokenID", tokenID)
+	return nil
+}
+
+// RotateToken issues a successor to a still active token and revokes the token itself. The
+// successor keeps the name and lifetime. It is called by an admin, never with the token being
+// rotated, so a leaked token can't be used to mint its own replacement.
+func (s *gatewayTokenService) RotateToken(ctx context.Context, tokenID string) (*domain.IssuedGatewayToken, error) {
+	if err := s.requirePrimary(); err != nil {
+		return nil, err
+	}
+	current, err := s.database.GetGatewayToken(tokenID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, domain.WrapGatewayTokenNotFound(tokenID, err)
+		}
+		return nil, domain.WrapDatabaseOperation("get gateway token", err)
+	}
+	if !current.Active(time.Now()) {
+		return nil, domain.WrapValidationError("id", fmt.Errorf("gateway token %s is expired or revoked; issue a new one", tokenID))
+	}
+
+	next := db.NewGatewayToken(current.Name, current.ExpiresAt.Sub(current.CreatedAt))
+	next.RotatedFrom = &current.ID
+	issued, err := s.issue(next)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.database.RevokeGatewayToken(current.ID, time.Now()); err != nil {
+		s.logger.WarnContext(ctx, "rotated gateway token could not be revoked", "tokenID", current.ID, "error", err)
+	}
+
+	s.logger.InfoContext(ctx, "gateway token rotated", "tokenID", current.ID, "newTokenID", next.ID, "expiresAt", next.ExpiresAt)
+	return issued, nil
+}
+
+// HasActiveToken reports whether any gateway token is still usable. Once one is, the static
+// gateway API key is no longer accepted for the registry fetch.
+func (s *gatewayTokenService) HasActiveToken(ctx context.Context) (bool, error) {
+	tokens, err := s.ListTokens(ctx)
+	if err != nil {
+		return false, err
+	}
+	now := time.Now()
+	for _, token := range tokens {
+		if token.Active(now) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// VerifyToken checks a token presented by the gateway and records its use
+func (s *gatewayTokenService) VerifyToken(ctx context.Context, token string) (*db.GatewayToken, error) {
+	if err := s.requirePrimary(); err != nil {
+		return nil, err
+	}
+	key, err := s.signingKey()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	claims, err := gatewaytoken.Verify(key, token, now)
+	if err != nil {
+		s.logger.DebugContext(ctx, "gateway token rejected", "error", err)
+		return nil, errGatewayTokenRejected
+	}
+	record, err := s.database.GetGatewayToken(claims.ID)
+	if err != nil || !record.Active(now) {
+		s.logger.WarnContext(ctx, "gateway token rejected: unknown or revoked", "tokenID", claims.ID)
+		return nil, errGatewayTokenRejected
+	}
+
+	if err := s.database.TouchGatewayToken(record.ID, now); err != nil {
+		s.logger.WarnContext(ctx, "failed to record gateway token use", "tokenID", record.ID, "error", err)
+	}
+	return record, nil
+}
+
+// issue stores a token record and signs the token for it
+func (s *gatewayTokenService) issue(record *db.GatewayToken) (*domain.IssuedGatewayToken, error) {
+	key, err := s.signingKey()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.database.CreateGatewayToken(record); err != nil {
+		return nil, domain.WrapDatabaseOperation("create gateway token", err)
+	}
+	token := gatewaytoken.Sign(key, gatewaytoken.Claims{ID: record.ID, IssuedAt: record.CreatedAt, ExpiresAt: record.ExpiresAt})
+	return &domain.IssuedGatewayToken{GatewayToken: record, Token: token}, nil
+}
+
+// signingKey returns the primary's gateway token key, generating it on first use
+func (s *gatewayTokenService) signingKey() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate signing key: %w", err)
+	}
+	key, err := s.database.EnsureSigningKey(constants.GatewayTokenSigningKey, hex.EncodeToString(b))
+	if err != nil {
+		return "", domain.WrapDatabaseOperation("get signing key", err)
+	}
+	return key, nil
+}
+
+func (s *gatewayTokenService) requirePrimary() error {
+	if !s.config.Node.IsPrimary {
+		return domain.WrapValidationError("gateway_token", fmt.Errorf("gateway tokens are issued by the primary node"))
+	}
+	return nil
+}
@@ -0,0 +1,117 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"path/filepath"
+	"testing"
+
+	"github.com/selfhostly/internal/config"
+	"github.com/selfhostly/internal/db"
+	"github.com/selfhostly/internal/domain"
+)
+
+func setupTestGatewayTokenService(t *testing.T, isPrimary bool) domain.GatewayTokenService {
+	t.Helper()
+	database, err := db.Init(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to initialize database: %v", err)
+	}
+	t.Cleanup(func() { database.Close() })
+
+	cfg := &config.Config{Node: config.NodeConfig{ID: "primary-node", IsPrimary: isPrimary}}
+	return NewGatewayTokenService(database, cfg, slog.Default())
+}
+
+func TestGatewayTokenService_IssueVerifyRevoke(t *testing.T) {
+	svc := setupTestGatewayTokenService(t, true)
+	ctx := context.Background()
+
+	for name, req := range map[string]domain.IssueGatewayTokenRequest{
+		"no name":      {Name: " "},
+		"negative ttl": {Name: "gateway", TTLHours: -1},
+		"ttl too long": {Name: "gateway", TTLHours: 24 * 31},
+	} {
+		if _, err := svc.IssueToken(ctx, req); !domain.IsValidationError(err) {
+			t.Errorf("%s: expected validation error, got %v", name, err)
+		}
+	}
+
+	issued, err := svc.IssueToken(ctx, domain.IssueGatewayTokenRequest{Name: "gateway", TTLHours: 12})
+	if err != nil {
+		t.Fatalf("IssueToken: %v", err)
+	}
+	if issued.Token == "" || issued.ExpiresAt.Sub(issued.CreatedAt).Hours() != 12 {
+		t.Errorf("unexpected issued token %+v", issued)
+	}
+
+	record, err := svc.VerifyToken(ctx, issued.Token)
+	if err != nil {
+		t.Fatalf("VerifyToken: %v", err)
+	}
+	if record.ID != issued.ID {
+		t.Errorf("verified token %s, want %s", record.ID, issued.ID)
+	}
+	if _, err := svc.VerifyToken(ctx, issued.Token+"0"); err == nil {
+		t.Error("expected a tampered token to be rejected")
+	}
+
+	tokens, err := svc.ListTokens(ctx)
+	if err != nil || len(tokens) != 1 || tokens[0].LastUsedAt == nil {
+		t.Fatalf("expected one used token, got %+v, %v", tokens, err)
+	}
+
+	if err := svc.RevokeToken(ctx, issued.ID); err != nil {
+		t.Fatalf("RevokeToken: %v", err)
+	}
+	if _, err := svc.VerifyToken(ctx, issued.Token); err == nil {
+		t.Error("expected a revoked token to be rejected")
+	}
+	if err := svc.RevokeToken(ctx, issued.ID); !domain.IsNotFoundError(err) {
+		t.Errorf("expected not found error revoking twice, got %v", err)
+	}
+}
+
+func TestGatewayTokenService_Rotate(t *testing.T) {
+	svc := setupTestGatewayTokenService(t, true)
+	ctx := context.Background()
+
+	if active, err := svc.HasActiveToken(ctx); err != nil || active {
+		t.Fatalf("expected no active token before one is issued, got %v, %v", active, err)
+	}
+	issued, err := svc.IssueToken(ctx, domain.IssueGatewayTokenRequest{Name: "gateway", TTLHours: 48})
+	if err != nil {
+		t.Fatalf("IssueToken: %v", err)
+	}
+	if active, err := svc.HasActiveToken(ctx); err != nil || !active {
+		t.Fatalf("expected an active token once one is issued, got %v, %v", active, err)
+	}
+	rotated, err := svc.RotateToken(ctx, issued.ID)
+	if err != nil {
+		t.Fatalf("RotateToken: %v", err)
+	}
+	if rotated.Name != "gateway" || rotated.RotatedFrom == nil || *rotated.RotatedFrom != issued.ID {
+		t.Errorf("unexpected rotated token %+v", rotated.GatewayToken)
+	}
+	if rotated.ExpiresAt.Sub(rotated.CreatedAt).Hours() != 48 {
+		t.Errorf("expected the lifetime to be kept, got %v", rotated.ExpiresAt.Sub(rotated.CreatedAt))
+	}
+
+	// The old token is revoked by the rotation, so it can't be rotated again
+	if _, err := svc.VerifyToken(ctx, issued.Token); err == nil {
+		t.Error("expected the rotated token to be revoked")
+	}
+	if _, err := svc.RotateToken(ctx, issued.ID); !domain.IsValidationError(err) {
+		t.Error("expected a second rotation of the same token to fail")
+	}
+	if _, err := svc.VerifyToken(ctx, rotated.Token); err != nil {
+		t.Errorf("VerifyToken(new): %v", err)
+	}
+}
+
+func TestGatewayTokenService_PrimaryOnly(t *testing.T) {
+	svc := setupTestGatewayTokenService(t, false)
+	if _, err := svc.IssueToken(context.Background(), domain.IssueGatewayTokenRequest{Name: "gateway"}); !domain.IsValidationError(err) {
+		t.Errorf("expected validation error on a secondary, got %v", err)
+	}
+}
@@ -0,0 +1,259 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/selfhostly/internal/config"
+	"github.com/selfhostly/internal/constants"
+	"github.com/selfhostly/internal/db"
+	"github.com/selfhostly/internal/diskguard"
+	"github.com/selfhostly/internal/docker"
+	"github.com/selfhostly/internal/domain"
+	"github.com/selfhostly/internal/node"
+	"github.com/selfhostly/internal/tunnel"
+)
+
+// healthCheckFunc runs one subsystem check and reports its status, a summary and details
+type healthCheckFunc func(ctx context.Context) (status, message string, details map[string]interface{})
+
+// healthService aggregates subsystem checks into a single platform health report
+type healthService struct {
+	database      *db.DB
+	dockerManager *docker.Manager
+	tunnelService domain.TunnelService
+	diskGuard     *diskguard.Guard
+	config        *config.Config
+	logger        *slog.Logger
+}
+
+// NewHealthService creates a new health service
+func NewHealthService(
+	database *db.DB,
+	dockerManager *docker.Manager,
+	tunnelService domain.TunnelService,
+	cfg *config.Config,
+	logger *slog.Logger,
+) domain.HealthService {
+	return &healthService{
+		database:      database,
+		dockerManager: dockerManager,
+		tunnelService: tunnelService,
+		diskGuard:     diskguard.New(cfg.DiskGuard),
+		config:        cfg,
+		logger:        logger,
+	}
+}
+
+// CheckHealth runs every check concurrently, each bounded by HealthCheckTimeout. The report's
+// status is the worst status of any check.
+func (s *healthService) CheckHealth(ctx context.Context) *domain.HealthReport {
+	checks := []struct {
+		name string
+		run  healthCheckFunc
+	}{
+		{"database", s.checkDatabase},
+		{"docker", s.checkDocker},
+		{"tunnel_provider", s.checkTunnelProvider},
+		{"nodes", s.checkNodes},
+		{"jobs", s.checkJobs},
+		{"disk", s.checkDisk},
+	}
+
+	report := &domain.HealthReport{
+		Status:    constants.HealthStatusHealthy,
+		CheckedAt: time.Now(),
+		Checks:    make([]*domain.HealthCheck, len(checks)),
+	}
+
+	var wg sync.WaitGroup
+	for i, check := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			report.Checks[i] = runHealthCheck(ctx, check.name, check.run)
+		}()
+	}
+	wg.Wait()
+
+	for _, check := range report.Checks {
+		report.Status = worseHealthStatus(report.Status, check.Status)
+		if check.Status != constants.HealthStatusHealthy {
+			s.logger.WarnContext(ctx, "health check not healthy", "check", check.Name, "status", check.Status, "message", check.Message)
+		}
+	}
+	return report
+}
+
+// runHealthCheck runs a check with a timeout. A check that doesn't finish in time is unhealthy;
+// its goroutine is left to finish on its own.
+func runHealthCheck(ctx context.Context, name string, run healthCheckFunc) *domain.HealthCheck {
+	ctx, cancel := context.WithTimeout(ctx, constants.HealthCheckTimeout)
+	defer cancel()
+
+	start := time.Now()
+	done := make(chan *domain.HealthCheck, 1)
+	go func() {
+		status, message, details := run(ctx)
+		done <- &domain.HealthCheck{Name: name, Status: status, Message: message, Details: details}
+	}()
+
+	var result *domain.HealthCheck
+	select {
+	case result = <-done:
+	case <-ctx.Done():
+		result = &domain.HealthCheck{
+			Name:    name,
+			Status:  constants.HealthStatusUnhealthy,
+			Message: fmt.Sprintf("check did not complete within %s", constants.HealthCheckTimeout),
+		}
+	}
+	result.DurationMs = time.Since(start).Milliseconds()
+	return result
+}
+
+// worseHealthStatus returns whichever of a and b is the more severe status
+func worseHealthStatus(a, b string) string {
+	rank := map[string]int{
+		constants.HealthStatusHealthy:   0,
+		constants.HealthStatusDegraded:  1,
+		constants.HealthStatusUnhealthy: 2,
+	}
+	if rank[b] > rank[a] {
+		return b
+	}
+	return a
+}
+
+// checkDatabase runs SQLite's quick integrity check
+func (s *healthService) checkDatabase(ctx context.Context) (string, string, map[string]interface{}) {
+	if err := s.database.PingContext(ctx); err != nil {
+		return constants.HealthStatusUnhealthy, fmt.Sprintf("database unreachable: %v", err), nil
+	}
+	if err := s.database.QuickCheck(); err != nil {
+		return constants.HealthStatusUnhealthy, err.Error(), nil
+	}
+	return constants.HealthStatusHealthy, "integrity check passed", nil
+}
+
+// checkDocker asks the Docker daemon for its version
+func (s *healthService) checkDocker(ctx context.Context) (string, string, map[string]interface{}) {
+	version, err := s.dockerManager.ServerVersion()
+	if err != nil {
+		return constants.HealthStatusUnhealthy, err.Error(), nil
+	}
+	return constants.HealthStatusHealthy, "docker daemon reachable", map[string]interface{}{"version": version}
+}
+
+// checkTunnelProvider verifies the active tunnel provider's API accepts our credentials. Apps keep
+// serving traffic when the provider API is down, so a failure only degrades the platform.
+func (s *healthService) checkTunnelProvider(ctx context.Context) (string, string, map[string]interface{}) {
+	provider, err := s.tunnelService.CheckProviderHealth(ctx)
+	details := map[string]interface{}{}
+	if provider != "" {
+		details["provider"] = provider
+	}
+
+	switch {
+	case err == nil:
+		return constants.HealthStatusHealthy, "provider API reachable", details
+	case errors.Is(err, tunnel.ErrProviderNotConfigured):
+		return constants.HealthStatusHealthy, "no tunnel provider configured", details
+	case errors.Is(err, tunnel.ErrFeatureNotSupported):
+		return constants.HealthStatusHealthy, "provider does not support health checks", details
+	default:
+		return constants.HealthStatusDegraded, err.Error(), details
+	}
+}
+
+// checkNodes reports the status and circuit breaker state of every other registered node
+func (s *healthService) checkNodes(ctx context.Context) (string, string, map[string]interface{}) {
+	nodes, err := s.database.GetAllNodes()
+	if err != nil {
+		return constants.HealthStatusDegraded, fmt.Sprintf("failed to list nodes: %v", err), nil
+	}
+
+	var problems int
+	nodeDetails := make([]map[string]interface{}, 0, len(nodes))
+	for _, n := range nodes {
+		if n.ID == s.config.Node.ID {
+			continue
+		}
+		circuit := node.CircuitStatsFor(n.ID)
+		if n.Status != constants.NodeStatusOnline || circuit.State == node.StateOpen {
+			problems++
+		}
+		nodeDetails = append(nodeDetails, map[string]interface{}{
+			"id":      n.ID,
+			"name":    n.Name,
+			"status":  n.Status,
+			"circuit": circuit.State,
+		})
+	}
+
+	details := map[string]interface{}{"nodes": nodeDetails}
+	if len(nodeDetails) == 0 {
+		return constants.HealthStatusHealthy, "no other nodes registered", details
+	}
+	if problems > 0 {
+		return constants.HealthStatusDegraded, fmt.Sprintf("%d of %d nodes offline or failing", problems, len(nodeDetails)), details
+	}
+	return constants.HealthStatusHealthy, fmt.Sprintf("%d nodes online", len(nodeDetails)), details
+}
+
+// checkJobs flags a job queue that is backing up or not being worked
+func (s *healthService) checkJobs(ctx context.Context) (string, string, map[string]interface{}) {
+	pending, running, oldestPending, err := s.database.GetJobBacklog()
+	if err != nil {
+		return constants.HealthStatusDegraded, fmt.Sprintf("failed to read job backlog: %v", err), nil
+	}
+
+	details := map[string]interface{}{"pending": pending, "running": running}
+	if oldestPending != nil {
+		details["oldest_pending"] = oldestPending
+	}
+
+	if pending > constants.HealthJobBacklogThreshold {
+		return constants.HealthStatusDegraded, fmt.Sprintf("%d jobs pending", pending), details
+	}
+	if oldestPending != nil && time.Since(*oldestPending) > constants.HealthJobStaleAfter {
+		return constants.HealthStatusDegraded, fmt.Sprintf("oldest pending job queued %s ago", time.Since(*oldestPending).Round(time.Second)), details
+	}
+	return constants.HealthStatusHealthy, fmt.Sprintf("%d pending, %d running", pending, running), details
+}
+
+// checkDisk reports free space on the filesystems holding the storage roots and the database,
+// using the same thresholds that guard image pulls and app creation
+func (s *healthService) checkDisk(ctx context.Context) (string, string, map[string]interface{}) {
+	status := constants.HealthStatusHealthy
+	message := "disk space ok"
+	volumes := make([]*diskguard.Usage, 0, 2)
+
+	var paths []string
+	for _, root := range s.dockerManager.StorageRoots() {
+		paths = append(paths, root.Path)
+	}
+	for _, path := range append(paths, filepath.Dir(s.config.DatabasePath)) {
+		usage, err := s.diskGuard.Check(path)
+		switch {
+		case errors.Is(err, diskguard.ErrInsufficientSpace):
+			status = constants.HealthStatusUnhealthy
+			message = err.Error()
+		case err != nil:
+			status = worseHealthStatus(status, constants.HealthStatusDegraded)
+			message = err.Error()
+			continue
+		case usage.Low && status == constants.HealthStatusHealthy:
+			status = constants.HealthStatusDegraded
+			message = fmt.Sprintf("%s has %s free", path, diskguard.FormatBytes(usage.FreeBytes))
+		}
+		volumes = append(volumes, usage)
+	}
+
+	return status, message, map[string]interface{}{"volumes": volumes}
+}
@@ -0,0 +1,141 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/selfhostly/internal/config"
+	"github.com/selfhostly/internal/constants"
+	"github.com/selfhostly/internal/db"
+	"github.com/selfhostly/internal/docker"
+	"github.com/selfhostly/internal/domain"
+	"github.com/selfhostly/internal/tunnel"
+)
+
+// stubProviderHealth is a TunnelService whose only implemented method is CheckProviderHealth
+type stubProviderHealth struct {
+	domain.TunnelService
+	err error
+}
+
+func (s *stubProviderHealth) CheckProviderHealth(ctx context.Context) (string, error) {
+	return "cloudflare", s.err
+}
+
+func setupHealthService(t *testing.T, tunnelErr error) (*healthService, *db.DB, *docker.MockCommandExecutor) {
+	t.Helper()
+	dir := t.TempDir()
+	cfg := &config.Config{
+		AppsDir:      dir,
+		DatabasePath: filepath.Join(dir, "test.db"),
+		Node:         config.NodeConfig{ID: "self", IsPrimary: true},
+	}
+	database, err := db.Init(cfg.DatabasePath)
+	if err != nil {
+		t.Fatalf("Failed to initialize database: %v", err)
+	}
+	t.Cleanup(func() { database.Close() })
+
+	executor := docker.NewMockCommandExecutor()
+	svc := NewHealthService(
+		database,
+		docker.NewManagerWithExecutor(dir, executor),
+		&stubProviderHealth{err: tunnelErr},
+		cfg,
+		slog.Default(),
+	).(*healthService)
+	return svc, database, executor
+}
+
+func healthCheckByName(t *testing.T, report *domain.HealthReport, name string) *domain.HealthCheck {
+	t.Helper()
+	for _, check := range report.Checks {
+		if check.Name == name {
+			return check
+		}
+	}
+	t.Fatalf("health report has no %q check", name)
+	return nil
+}
+
+func TestHealthService_AllSubsystemsHealthy(t *testing.T) {
+	svc, _, _ := setupHealthService(t, tunnel.ErrProviderNotConfigured)
+
+	report := svc.CheckHealth(context.Background())
+
+	for _, name := range []string{"database", "docker", "tunnel_provider", "nodes", "jobs"} {
+		if check := healthCheckByName(t, report, name); check.Status != constants.HealthStatusHealthy {
+			t.Errorf("Expected %s to be healthy, got %s (%s)", name, check.Status, check.Message)
+		}
+	}
+	if len(report.Checks) != 6 {
+		t.Errorf("Expected 6 checks, got %d", len(report.Checks))
+	}
+}
+
+func TestHealthService_DockerDownIsUnhealthy(t *testing.T) {
+	svc, _, executor := setupHealthService(t, nil)
+	cmd := docker.DockerServerVersionCommand()
+	executor.SetMockError(cmd[0], cmd[1:], fmt.Errorf("cannot connect to the Docker daemon"))
+
+	report := svc.CheckHealth(context.Background())
+
+	if check := healthCheckByName(t, report, "docker"); check.Status != constants.HealthStatusUnhealthy {
+		t.Errorf("Expected docker to be unhealthy, got %s", check.Status)
+	}
+	if report.Status != constants.HealthStatusUnhealthy {
+		t.Errorf("Expected overall status unhealthy, got %s", report.Status)
+	}
+}
+
+func TestHealthService_DegradedSubsystems(t *testing.T) {
+	svc, database, _ := setupHealthService(t, errors.New("cloudflare API error (HTTP 403)"))
+
+	offline := db.NewNode("worker-1", "http://worker-1:8080", "key", false)
+	offline.Status = constants.NodeStatusOffline
+	if err := database.CreateNode(offline); err != nil {
+		t.Fatalf("CreateNode: %v", err)
+	}
+
+	app := db.NewApp("queued-app", "", "services:\n  web:\n    image: nginx\n")
+	if err := database.CreateApp(app); err != nil {
+		t.Fatalf("CreateApp: %v", err)
+	}
+	job := db.NewJob(constants.JobTypeAppStart, app.ID, nil)
+	job.CreatedAt = time.Now().Add(-time.Hour)
+	if err := database.CreateJob(job); err != nil {
+		t.Fatalf("CreateJob: %v", err)
+	}
+
+	report := svc.CheckHealth(context.Background())
+
+	for _, name := range []string{"tunnel_provider", "nodes", "jobs"} {
+		if check := healthCheckByName(t, report, name); check.Status != constants.HealthStatusDegraded {
+			t.Errorf("Expected %s to be degraded, got %s (%s)", name, check.Status, check.Message)
+		}
+	}
+	if report.Status == constants.HealthStatusHealthy {
+		t.Error("Expected overall status to reflect degraded checks")
+	}
+}
+
+func TestWorseHealthStatus(t *testing.T) {
+	tests := []struct {
+		a, b, want string
+	}{
+		{constants.HealthStatusHealthy, constants.HealthStatusHealthy, constants.HealthStatusHealthy},
+		{constants.HealthStatusHealthy, constants.HealthStatusDegraded, constants.HealthStatusDegraded},
+		{constants.HealthStatusUnhealthy, constants.HealthStatusDegraded, constants.HealthStatusUnhealthy},
+		{constants.HealthStatusDegraded, constants.HealthStatusUnhealthy, constants.HealthStatusUnhealthy},
+	}
+	for _, tt := range tests {
+		if got := worseHealthStatus(tt.a, tt.b); got != tt.want {
+			t.Errorf("worseHealthStatus(%s, %s) = %s, want %s", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
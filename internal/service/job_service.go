@@ -0,0 +1,145 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+
+	"github.com/selfhostly/internal/config"
+	"github.com/selfhostly/internal/constants"
+	"github.com/selfhostly/internal/db"
+	"github.com/selfhostly/internal/domain"
+	"github.com/selfhostly/internal/node"
+	"github.com/selfhostly/internal/routing"
+)
+
+// jobService shows background jobs. Each node keeps the jobs it runs; the primary merges the
+// listings of every node and fetches single jobs from the node that runs them.
+type jobService struct {
+	database   *db.DB
+	nodeClient *node.Client
+	jobsAgg    *routing.JobsAggregator
+	config     *config.Config
+	logger     *slog.Logger
+}
+
+// NewJobService creates a new job service
+func NewJobService(database *db.DB, cfg *config.Config, logger *slog.Logger) domain.JobService {
+	nodeClient := node.NewClientWithTimeouts(cfg.Timeouts)
+	router := routing.NewNodeRouter(database, nodeClient, cfg.Node.ID, logger)
+	return &jobService{
+		database:   database,
+		nodeClient: nodeClient,
+		jobsAgg:    routing.NewJobsAggregator(router, logger),
+		config:     cfg,
+		logger:     logger,
+	}
+}
+
+// ListJobs lists recent jobs on the requested nodes. Only the primary asks other nodes; nodes
+// that are down or fail to answer are reported rather than hidden.
+func (s *jobService) ListJobs(ctx context.Context, filter domain.JobListFilter, nodeIDs []string) ([]*db.Job, []domain.NodeError, error) {
+	switch filter.Status {
+	case "", constants.JobStatusPending, constants.JobStatusRunning, constants.JobStatusCompleted, constants.JobStatusFailed:
+	default:
+		return nil, nil, domain.WrapValidationError("status", fmt.Errorf("unknown job status %q", filter.Status))
+	}
+	if filter.Limit < 0 || filter.Limit > constants.JobListMaxLimit {
+		return nil, nil, domain.WrapValidationError("limit", fmt.Errorf("limit must be between 1 and %d", constants.JobListMaxLimit))
+	}
+	if filter.Limit == 0 {
+		filter.Limit = constants.JobListDefaultLimit
+	}
+
+	nodes, err := s.targetNodes(ctx, nodeIDs)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	jobs, nodeErrors := s.jobsAgg.AggregateJobs(
+		ctx,
+		nodes,
+		filter.Limit,
+		func() ([]*db.Job, error) {
+			return s.database.GetRecentJobs(filter.Status, filter.Type, filter.Limit)
+		},
+		func(n *db.Node) ([]*db.Job, error) {
+			if n.Status != constants.NodeStatusOnline {
+				return nil, fmt.Errorf("node is %s", n.Status)
+			}
+			jobs, err := s.nodeClient.ListJobs(n, filter)
+			for _, job := range jobs {
+				jobToPrimaryClock(n, job)
+			}
+			return jobs, err
+		},
+	)
+	if jobs == nil {
+		jobs = []*db.Job{}
+	}
+	return jobs, nodeErrors, nil
+}
+
+// GetJob returns a job from this node, or on the primary from the node that runs it
+func (s *jobService) GetJob(ctx context.Context, jobID, nodeID string) (*db.Job, error) {
+	if nodeID == "" || nodeID == s.config.Node.ID {
+		job, err := s.database.GetJob(jobID)
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, domain.WrapJobNotFound(jobID, err)
+		}
+		if err != nil {
+			return nil, domain.WrapDatabaseOperation("get job", err)
+		}
+		job.NodeID = s.config.Node.ID
+		job.NodeName = s.config.Node.Name
+		return job, nil
+	}
+
+	if !s.config.Node.IsPrimary {
+		return nil, domain.WrapValidationError("node_id", fmt.Errorf("jobs of other nodes are fetched through the primary"))
+	}
+	n, err := s.database.GetNode(nodeID)
+	if err != nil {
+		return nil, domain.WrapNodeNotFound(nodeID, err)
+	}
+	job, err := s.nodeClient.GetJob(n, jobID)
+	if err != nil {
+		return nil, err
+	}
+	jobToPrimaryClock(n, job)
+	job.NodeID = n.ID
+	job.NodeName = n.Name
+	return job, nil
+}
+
+// targetNodes returns the nodes a listing asks: on the primary every requested node, elsewhere
+// just this one when it was requested
+func (s *jobService) targetNodes(ctx context.Context, nodeIDs []string) ([]*db.Node, error) {
+	if !s.config.Node.IsPrimary {
+		if !includesNode(nodeIDs, s.config.Node.ID) {
+			return nil, nil
+		}
+		return []*db.Node{{ID: s.config.Node.ID, Name: s.config.Node.Name, Status: constants.NodeStatusOnline}}, nil
+	}
+
+	if len(nodeIDs) == 0 || (len(nodeIDs) == 1 && nodeIDs[0] == "all") {
+		nodes, err := s.database.GetAllNodes()
+		if err != nil {
+			return nil, domain.WrapDatabaseOperation("get nodes", err)
+		}
+		return nodes, nil
+	}
+
+	var nodes []*db.Node
+	for _, nodeID := range nodeIDs {
+		n, err := s.database.GetNode(nodeID)
+		if err != nil {
+			s.logger.WarnContext(ctx, "node not found", "nodeID", nodeID, "error", err)
+			continue
+		}
+		nodes = append(nodes, n)
+	}
+	return nodes, nil
+}
@@ -0,0 +1,99 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/selfhostly/internal/config"
+	"github.com/selfhostly/internal/constants"
+	"github.com/selfhostly/internal/db"
+	"github.com/selfhostly/internal/domain"
+)
+
+func TestJobService_ListJobs(t *testing.T) {
+	database, err := db.Init(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to initialize database: %v", err)
+	}
+	t.Cleanup(func() { database.Close() })
+
+	cfg := &config.Config{}
+	cfg.Node.ID = "primary"
+	cfg.Node.Name = "primary"
+	cfg.Node.IsPrimary = true
+	service := NewJobService(database, cfg, slog.Default())
+	ctx := context.Background()
+
+	remoteJob := db.NewJob(constants.JobTypeAppUpdate, "remote-app", nil)
+	remoteJob.CreatedAt = time.Now().Add(time.Minute)
+	remote := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/jobs":
+			json.NewEncoder(w).Encode([]*db.Job{remoteJob})
+		case "/api/jobs/" + remoteJob.ID:
+			json.NewEncoder(w).Encode(remoteJob)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(remote.Close)
+
+	nodes := []*db.Node{
+		db.NewNodeWithID("primary", "primary", "http://127.0.0.1:1", "key", true),
+		db.NewNodeWithID("worker", "worker", remote.URL, "key", false),
+		db.NewNodeWithID("down", "down", "http://127.0.0.1:1", "key", false),
+	}
+	nodes[0].Status = constants.NodeStatusOnline
+	nodes[1].Status = constants.NodeStatusOnline
+	nodes[2].Status = constants.NodeStatusOffline
+	for _, n := range nodes {
+		if err := database.CreateNode(n); err != nil {
+			t.Fatalf("CreateNode: %v", err)
+		}
+	}
+
+	app := db.NewApp("web", "", "services:\n  web:\n    image: nginx:latest\n")
+	if err := database.CreateApp(app); err != nil {
+		t.Fatalf("CreateApp: %v", err)
+	}
+	localJob := db.NewJob(constants.JobTypeAppCreate, app.ID, nil)
+	if err := database.CreateJob(localJob); err != nil {
+		t.Fatalf("CreateJob: %v", err)
+	}
+
+	jobs, nodeErrors, err := service.ListJobs(ctx, domain.JobListFilter{}, nil)
+	if err != nil {
+		t.Fatalf("ListJobs: %v", err)
+	}
+	if len(jobs) != 2 || jobs[0].ID != remoteJob.ID || jobs[0].NodeID != "worker" || jobs[1].ID != localJob.ID || jobs[1].NodeName != "primary" {
+		t.Errorf("Expected both jobs newest first with their node, got %+v", jobs)
+	}
+	if len(nodeErrors) != 1 || nodeErrors[0].NodeID != "down" {
+		t.Errorf("Expected the offline node to be reported, got %+v", nodeErrors)
+	}
+
+	// Jobs of other nodes are fetched from them
+	job, err := service.GetJob(ctx, remoteJob.ID, "worker")
+	if err != nil {
+		t.Fatalf("GetJob: %v", err)
+	}
+	if job.ID != remoteJob.ID || job.NodeID != "worker" {
+		t.Errorf("Unexpected remote job %+v", job)
+	}
+	if _, err := service.GetJob(ctx, "missing", "worker"); !domain.IsNotFoundError(err) {
+		t.Errorf("Expected a missing remote job to be not found, got %v", err)
+	}
+	if _, err := service.GetJob(ctx, "missing", "primary"); !domain.IsNotFoundError(err) {
+		t.Errorf("Expected a missing local job to be not found, got %v", err)
+	}
+
+	if _, _, err := service.ListJobs(ctx, domain.JobListFilter{Status: "done"}, nil); !domain.IsValidationError(err) {
+		t.Errorf("Expected an unknown status to be refused, got %v", err)
+	}
+}
@@ -0,0 +1,161 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/selfhostly/internal/applock"
+	"github.com/selfhostly/internal/config"
+	"github.com/selfhostly/internal/constants"
+	"github.com/selfhostly/internal/db"
+	"github.com/selfhostly/internal/docker"
+	"github.com/selfhostly/internal/domain"
+)
+
+// logForwardingService deploys a log forwarding sidecar next to each app on this node that has
+// forwarding on. The destination comes from the log_forwarding settings section.
+type logForwardingService struct {
+	database      *db.DB
+	dockerManager *docker.Manager
+	config        *config.Config
+	logger        *slog.Logger
+}
+
+// NewLogForwardingService creates a new log forwarding service
+func NewLogForwardingService(database *db.DB, dockerManager *docker.Manager, cfg *config.Config, logger *slog.Logger) domain.LogForwardingService {
+	return &logForwardingService{
+		database:      database,
+		dockerManager: dockerManager,
+		config:        cfg,
+		logger:        logger,
+	}
+}
+
+// GetAppLogForwarding returns whether an app's logs are forwarded
+func (s *logForwardingService) GetAppLogForwarding(ctx context.Context, appID string) (*domain.AppLogForwarding, error) {
+	if _, err := s.database.GetApp(appID); err != nil {
+		return nil, domain.WrapAppNotFound(appID, err)
+	}
+	enabled, err := s.database.IsAppLogForwardingEnabled(appID)
+	if err != nil {
+		return nil, domain.WrapDatabaseOperation("get log forwarding", err)
+	}
+	destination, err := s.destination()
+	if err != nil {
+		return nil, err
+	}
+	return s.status(appID, enabled, destination), nil
+}
+
+// SetAppLogForwarding turns forwarding on or off for an app. Turning it on while no destination is
+// configured only records the choice; the sidecar is deployed once one is.
+func (s *logForwardingService) SetAppLogForwarding(ctx context.Context, appID string, enabled bool) (*domain.AppLogForwarding, error) {
+	ctx, unlock, err := applock.Acquire(ctx, s.database, appID, "log forwarding")
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	app, err := s.database.GetApp(appID)
+	if err != nil {
+		return nil, domain.WrapAppNotFound(appID, err)
+	}
+	if err := s.database.SetAppLogForwarding(appID, enabled); err != nil {
+		return nil, domain.WrapDatabaseOperation("set log forwarding", err)
+	}
+
+	destination, err := s.destination()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.apply(ctx, app, enabled, destination, true); err != nil {
+		return nil, err
+	}
+
+	s.logger.InfoContext(ctx, "app log forwarding changed", "app", app.Name, "appID", appID, "enabled", enabled, "active", enabled && destination != nil)
+	return s.status(appID, enabled, destination), nil
+}
+
+// ApplyLogForwarding rewrites the sidecar of every app with forwarding on. Apps busy with another
+// operation only get their files rewritten; that operation's deploy picks them up.
+func (s *logForwardingService) ApplyLogForwarding(ctx context.Context) error {
+	appIDs, err := s.database.GetLogForwardingAppIDs()
+	if err != nil {
+		return domain.WrapDatabaseOperation("get log forwarding apps", err)
+	}
+	destination, err := s.destination()
+	if err != nil {
+		return err
+	}
+
+	for _, appID := range appIDs {
+		app, err := s.database.GetApp(appID)
+		if err != nil {
+			continue // Apps on other nodes aren't in this node's database
+		}
+		lockCtx, unlock, err := applock.Acquire(ctx, s.database, appID, "log forwarding")
+		locked := err == nil
+		if !locked {
+			s.logger.InfoContext(ctx, "app is busy, its log forwarding sidecar will be updated by its next deploy", "app", app.Name, "appID", appID, "error", err)
+			lockCtx, unlock = ctx, func() {}
+		}
+		if err := s.apply(lockCtx, app, true, destination, locked); err != nil {
+			s.logger.WarnContext(ctx, "failed to update log forwarding sidecar", "app", app.Name, "appID", appID, "error", err)
+		}
+		unlock()
+	}
+	return nil
+}
+
+// apply writes or removes an app's sidecar files and, if they changed, reconcile is set and the
+// app is running, reconciles it so the sidecar is started, restarted or removed
+func (s *logForwardingService) apply(ctx context.Context, app *db.App, enabled bool, destination *docker.LogForwarding, reconcile bool) error {
+	var composeContent, vectorConfig string
+	if enabled && destination != nil {
+		var err error
+		composeContent, vectorConfig, err = docker.RenderLogForwarding(app.Name, *destination)
+		if err != nil {
+			return domain.WrapValidationError("log_forwarding", err)
+		}
+	}
+
+	changed, err := s.dockerManager.WriteLogForwardingFiles(app.Name, composeContent, vectorConfig)
+	if err != nil {
+		return domain.WrapContainerOperationFailed("write log forwarding files", err)
+	}
+	if !changed || !reconcile || (app.Status != constants.AppStatusRunning && app.Status != constants.AppStatusDegraded) {
+		return nil
+	}
+	if err := s.dockerManager.ReconcileApp(app.Name); err != nil {
+		return domain.WrapContainerOperationFailed("reconcile app", err)
+	}
+	return nil
+}
+
+// destination returns where forwarded logs go, or nil while forwarding isn't configured
+func (s *logForwardingService) destination() (*docker.LogForwarding, error) {
+	settings, err := s.database.GetSettings()
+	if err != nil {
+		return nil, domain.WrapDatabaseOperation("get settings", err)
+	}
+	if settings.LogForwardingDestination == "" || settings.LogForwardingURL == "" {
+		return nil, nil
+	}
+	return &docker.LogForwarding{
+		Destination: settings.LogForwardingDestination,
+		URL:         settings.LogForwardingURL,
+		Username:    settings.LogForwardingUsername,
+		Password:    settings.LogForwardingPassword,
+		Index:       settings.LogForwardingIndex,
+		NodeName:    s.config.Node.Name,
+	}, nil
+}
+
+func (s *logForwardingService) status(appID string, enabled bool, destination *docker.LogForwarding) *domain.AppLogForwarding {
+	status := &domain.AppLogForwarding{AppID: appID, Enabled: enabled}
+	if destination != nil {
+		status.Destination = destination.Destination
+		status.Active = enabled
+	}
+	return status
+}
@@ -0,0 +1,82 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/selfhostly/internal/config"
+	"github.com/selfhostly/internal/constants"
+	"github.com/selfhostly/internal/db"
+	"github.com/selfhostly/internal/docker"
+	"github.com/selfhostly/internal/domain"
+)
+
+func TestLogForwardingService_SetAndApply(t *testing.T) {
+	database, err := db.Init(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to initialize database: %v", err)
+	}
+	defer database.Close()
+
+	appsDir := t.TempDir()
+	dockerManager := docker.NewManagerWithExecutor(appsDir, docker.NewMockCommandExecutor())
+	service := NewLogForwardingService(database, dockerManager, &config.Config{}, slog.Default())
+	ctx := context.Background()
+
+	compose := "services:\n  web:\n    image: nginx:latest\n"
+	app := db.NewApp("web", "", compose)
+	if err := database.CreateApp(app); err != nil {
+		t.Fatalf("Failed to create app: %v", err)
+	}
+	if err := dockerManager.CreateAppDirectory(app.Name, compose); err != nil {
+		t.Fatal(err)
+	}
+	loggingFile := filepath.Join(appsDir, app.Name, docker.ComposeLoggingFileName)
+
+	// Without a destination the choice is only recorded
+	status, err := service.SetAppLogForwarding(ctx, app.ID, true)
+	if err != nil {
+		t.Fatalf("SetAppLogForwarding: %v", err)
+	}
+	if !status.Enabled || status.Active {
+		t.Errorf("Expected forwarding enabled but inactive, got %+v", status)
+	}
+	if _, err := os.Stat(loggingFile); !os.IsNotExist(err) {
+		t.Errorf("Expected no sidecar without a destination, got %v", err)
+	}
+
+	settings, err := database.GetSettings()
+	if err != nil {
+		t.Fatal(err)
+	}
+	settings.LogForwardingDestination = constants.LogForwardingLoki
+	settings.LogForwardingURL = "http://loki:3100"
+	if err := database.UpdateSettings(settings); err != nil {
+		t.Fatal(err)
+	}
+	if err := service.ApplyLogForwarding(ctx); err != nil {
+		t.Fatalf("ApplyLogForwarding: %v", err)
+	}
+	if _, err := os.Stat(loggingFile); err != nil {
+		t.Errorf("Expected the sidecar once a destination is set, got %v", err)
+	}
+	if status, _ := service.GetAppLogForwarding(ctx, app.ID); !status.Active || status.Destination != constants.LogForwardingLoki {
+		t.Errorf("Expected forwarding to loki to be active, got %+v", status)
+	}
+
+	if _, err := service.SetAppLogForwarding(ctx, app.ID, false); err != nil {
+		t.Fatalf("SetAppLogForwarding: %v", err)
+	}
+	for _, name := range []string{docker.ComposeLoggingFileName, docker.VectorConfigFileName} {
+		if _, err := os.Stat(filepath.Join(appsDir, app.Name, name)); !os.IsNotExist(err) {
+			t.Errorf("Expected %s to be removed, got %v", name, err)
+		}
+	}
+
+	if _, err := service.GetAppLogForwarding(ctx, "missing"); !domain.IsNotFoundError(err) {
+		t.Errorf("Expected a not found error, got %v", err)
+	}
+}
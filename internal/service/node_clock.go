@@ -0,0 +1,81 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/selfhostly/internal/constants"
+	"github.com/selfhostly/internal/db"
+	"github.com/selfhostly/internal/reqsign"
+)
+
+// recordClockSkew stores how far a node's clock is from this primary's, measured at a heartbeat,
+// and warns when it goes past constants.ClockSkewWarnThreshold or back under it. A nil skew (the
+// node didn't send its time) leaves the last measurement. The caller saves the node.
+func (s *nodeService) recordClockSkew(ctx context.Context, node *db.Node, skew *time.Duration) {
+	if skew == nil {
+		return
+	}
+	wasSkewed := ClockSkewWarning(node) != ""
+	ms := skew.Milliseconds()
+	node.ClockSkewMS = &ms
+
+	if warning := ClockSkewWarning(node); warning != "" {
+		if !wasSkewed {
+			s.logger.WarnContext(ctx, "node clock is skewed", "nodeID", node.ID, "nodeName", node.Name, "skew", skew.Round(time.Millisecond), "warning", warning)
+		}
+	} else if wasSkewed {
+		s.logger.InfoContext(ctx, "node clock is back in sync", "nodeID", node.ID, "nodeName", node.Name, "skew", skew.Round(time.Millisecond))
+	}
+}
+
+// ClockSkewWarning explains what the skew last measured for a node affects, or returns "" when it
+// is within constants.ClockSkewWarnThreshold or was never measured
+func ClockSkewWarning(node *db.Node) string {
+	if node.ClockSkewMS == nil {
+		return ""
+	}
+	skew := time.Duration(*node.ClockSkewMS) * time.Millisecond
+	direction := "ahead of"
+	if skew < 0 {
+		skew, direction = -skew, "behind"
+	}
+	clock := fmt.Sprintf("clock is %s %s the primary's", skew.Round(time.Second), direction)
+	switch {
+	case skew < constants.ClockSkewWarnThreshold:
+		return ""
+	case skew > reqsign.MaxSkew:
+		return fmt.Sprintf("%s: signed requests between them are refused and job and log times are not corrected; sync it with NTP", clock)
+	case skew > constants.ClockSkewMaxCompensation:
+		return fmt.Sprintf("%s: job and log times it reports are not corrected; sync it with NTP", clock)
+	default:
+		return fmt.Sprintf("%s: job and log times it reports are corrected, but sync it with NTP", clock)
+	}
+}
+
+// toPrimaryClock converts a time a node reported to the primary's clock. Only skews up to
+// constants.ClockSkewMaxCompensation are corrected; a clock further off is left as reported rather
+// than trusting a large correction.
+func toPrimaryClock(node *db.Node, t time.Time) time.Time {
+	if node.ClockSkewMS == nil || t.IsZero() {
+		return t
+	}
+	skew := time.Duration(*node.ClockSkewMS) * time.Millisecond
+	if skew > constants.ClockSkewMaxCompensation || skew < -constants.ClockSkewMaxCompensation {
+		return t
+	}
+	return t.Add(-skew)
+}
+
+// jobToPrimaryClock converts the times of a job fetched from a node to the primary's clock, so jobs
+// of different nodes merge in the right order and durations shown against now add up
+func jobToPrimaryClock(node *db.Node, job *db.Job) {
+	job.CreatedAt = toPrimaryClock(node, job.CreatedAt)
+	job.UpdatedAt = toPrimaryClock(node, job.UpdatedAt)
+	for _, t := range []*time.Time{job.StartedAt, job.CompletedAt, job.ClaimedAt, job.RetryAfter, job.CancelledAt} {
+		if t != nil {
+			*t = toPrimaryClock(node, *t)
+		}
+	}
+}
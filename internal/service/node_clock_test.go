@@ -0,0 +1,95 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/selfhostly/internal/db"
+	"github.com/selfhostly/internal/domain"
+)
+
+func nodeWithSkew(skew time.Duration) *db.Node {
+	ms := skew.Milliseconds()
+	return &db.Node{ID: "node-1", Name: "node-1", ClockSkewMS: &ms}
+}
+
+func TestClockSkewWarning(t *testing.T) {
+	tests := []struct {
+		name string
+		node *db.Node
+		want string
+	}{
+		{"never measured", &db.Node{}, ""},
+		{"in sync", nodeWithSkew(2 * time.Second), ""},
+		{"corrected", nodeWithSkew(30 * time.Second), "are corrected"},
+		{"behind, corrected", nodeWithSkew(-30 * time.Second), "behind"},
+		{"too far to correct", nodeWithSkew(3 * time.Minute), "not corrected"},
+		{"past the signing window", nodeWithSkew(-10 * time.Minute), "signed requests"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ClockSkewWarning(tt.node)
+			if tt.want == "" && got != "" {
+				t.Errorf("expected no warning, got %q", got)
+			}
+			if !strings.Contains(got, tt.want) {
+				t.Errorf("expected warning containing %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestToPrimaryClock(t *testing.T) {
+	reported := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	if got := toPrimaryClock(&db.Node{}, reported); !got.Equal(reported) {
+		t.Errorf("expected unmeasured node's time unchanged, got %v", got)
+	}
+	if got := toPrimaryClock(nodeWithSkew(30*time.Second), reported); !got.Equal(reported.Add(-30 * time.Second)) {
+		t.Errorf("expected a node 30s ahead to be corrected back, got %v", got)
+	}
+	if got := toPrimaryClock(nodeWithSkew(-30*time.Second), reported); !got.Equal(reported.Add(30 * time.Second)) {
+		t.Errorf("expected a node 30s behind to be corrected forward, got %v", got)
+	}
+	if got := toPrimaryClock(nodeWithSkew(time.Hour), reported); !got.Equal(reported) {
+		t.Errorf("expected a skew past the compensation limit to be left alone, got %v", got)
+	}
+	if got := toPrimaryClock(nodeWithSkew(30*time.Second), time.Time{}); !got.IsZero() {
+		t.Errorf("expected a zero time to stay zero, got %v", got)
+	}
+
+	startedAt := reported
+	job := &db.Job{CreatedAt: reported, UpdatedAt: reported, StartedAt: &startedAt}
+	jobToPrimaryClock(nodeWithSkew(time.Minute), job)
+	want := reported.Add(-time.Minute)
+	if !job.CreatedAt.Equal(want) || !job.UpdatedAt.Equal(want) || !job.StartedAt.Equal(want) || job.CompletedAt != nil {
+		t.Errorf("unexpected job times after correction: %+v", job)
+	}
+}
+
+func TestNodeHeartbeat_RecordsClockSkew(t *testing.T) {
+	svc, database, remote := setupTestNodeRemoval(t)
+	ctx := context.Background()
+
+	skew := 45 * time.Second
+	if err := svc.NodeHeartbeat(ctx, remote.ID, domain.NodeVersion{}, &skew); err != nil {
+		t.Fatalf("NodeHeartbeat: %v", err)
+	}
+	node, err := database.GetNode(remote.ID)
+	if err != nil {
+		t.Fatalf("GetNode: %v", err)
+	}
+	if node.ClockSkewMS == nil || *node.ClockSkewMS != skew.Milliseconds() {
+		t.Fatalf("expected skew %dms to be stored, got %v", skew.Milliseconds(), node.ClockSkewMS)
+	}
+
+	// A heartbeat without the node's time keeps the last measurement
+	if err := svc.NodeHeartbeat(ctx, remote.ID, domain.NodeVersion{}, nil); err != nil {
+		t.Fatalf("NodeHeartbeat: %v", err)
+	}
+	if node, _ = database.GetNode(remote.ID); node.ClockSkewMS == nil || *node.ClockSkewMS != skew.Milliseconds() {
+		t.Errorf("expected skew to be kept, got %v", node.ClockSkewMS)
+	}
+}
@@ -0,0 +1,100 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/selfhostly/internal/constants"
+	"github.com/selfhostly/internal/db"
+	"github.com/selfhostly/internal/domain"
+	"github.com/selfhostly/internal/logger"
+)
+
+// nodeLogLevels are the levels slog writes, lowest first
+var nodeLogLevels = []slog.Level{slog.LevelDebug, slog.LevelInfo, slog.LevelWarn, slog.LevelError}
+
+// PushNodeLogs ships a batch of this secondary's server log lines to the primary
+func (s *nodeService) PushNodeLogs(ctx context.Context, entries []domain.NodeLogEntry) error {
+	if s.config.Node.IsPrimary {
+		return fmt.Errorf("primary node does not ship its logs to itself")
+	}
+	if s.config.Node.PrimaryNodeURL == "" {
+		return fmt.Errorf("PRIMARY_NODE_URL not configured")
+	}
+
+	primaryNode := &db.Node{
+		ID:          s.config.Node.ID,
+		APIEndpoint: s.config.Node.PrimaryNodeURL,
+		APIKey:      s.config.Node.APIKey,
+	}
+	return s.nodeClient.PushNodeLogs(primaryNode, domain.NodeLogPushRequest{Entries: entries})
+}
+
+// IngestNodeLogs stores server log lines shipped by a secondary and prunes lines past retention
+func (s *nodeService) IngestNodeLogs(ctx context.Context, nodeID string, req domain.NodeLogPushRequest) error {
+	node, err := s.database.GetNode(nodeID)
+	if err != nil {
+		return domain.WrapNodeNotFound(nodeID, err)
+	}
+	if len(req.Entries) > constants.LogShippingBatchSize {
+		return domain.WrapValidationError("entries", fmt.Errorf("at most %d lines can be shipped at once", constants.LogShippingBatchSize))
+	}
+
+	logs := make([]*db.NodeLog, 0, len(req.Entries))
+	for _, e := range req.Entries {
+		loggedAt := toPrimaryClock(node, e.Time)
+		if loggedAt.IsZero() {
+			loggedAt = time.Now()
+		}
+		logs = append(logs, &db.NodeLog{
+			NodeID:   nodeID,
+			LoggedAt: loggedAt.UTC(),
+			Level:    strings.ToUpper(e.Level),
+			Message:  e.Message,
+			Line:     e.Line,
+		})
+	}
+	if err := s.database.CreateNodeLogs(logs); err != nil {
+		return domain.WrapDatabaseOperation("store node logs", err)
+	}
+
+	if _, err := s.database.DeleteNodeLogsBefore(time.Now().Add(-constants.NodeLogsRetention)); err != nil {
+		s.logger.WarnContext(ctx, "failed to prune node logs", "error", err)
+	}
+	return nil
+}
+
+// GetNodeLogs returns the newest server log lines a secondary shipped, oldest first
+func (s *nodeService) GetNodeLogs(ctx context.Context, nodeID string, query domain.NodeLogQuery) ([]*db.NodeLog, error) {
+	if _, err := s.database.GetNode(nodeID); err != nil {
+		return nil, domain.WrapNodeNotFound(nodeID, err)
+	}
+
+	var levels []string
+	if query.MinLevel != "" {
+		minLevel, err := logger.ParseLevel(query.MinLevel)
+		if err != nil {
+			return nil, domain.WrapValidationError("level", err)
+		}
+		for _, l := range nodeLogLevels {
+			if l >= minLevel {
+				levels = append(levels, l.String())
+			}
+		}
+	}
+
+	limit := query.Limit
+	if limit <= 0 {
+		limit = constants.NodeLogsDefaultLimit
+	}
+	limit = min(limit, constants.NodeLogsMaxLimit)
+
+	logs, err := s.database.GetNodeLogs(nodeID, query.Since, levels, limit)
+	if err != nil {
+		return nil, domain.WrapDatabaseOperation("get node logs", err)
+	}
+	return logs, nil
+}
@@ -0,0 +1,62 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/selfhostly/internal/config"
+	"github.com/selfhostly/internal/constants"
+	"github.com/selfhostly/internal/db"
+	"github.com/selfhostly/internal/domain"
+)
+
+func TestNodeLogs_IngestAndQuery(t *testing.T) {
+	database, err := db.Init(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to initialize database: %v", err)
+	}
+	defer database.Close()
+	ctx := context.Background()
+
+	cfg := &config.Config{Node: config.NodeConfig{ID: "test-node-id", IsPrimary: true}}
+	svc := NewNodeService(database, cfg, slog.Default())
+
+	remote := db.NewNodeWithID("remote-node", "remote", "http://127.0.0.1:1", "remote-key", false)
+	if err := database.CreateNode(remote); err != nil {
+		t.Fatalf("CreateNode: %v", err)
+	}
+
+	now := time.Now().UTC()
+	err = svc.IngestNodeLogs(ctx, remote.ID, domain.NodeLogPushRequest{Entries: []domain.NodeLogEntry{
+		{Time: now.Add(-constants.NodeLogsRetention - time.Hour), Level: "INFO", Message: "expired", Line: `{"msg":"expired"}`},
+		{Time: now.Add(-2 * time.Minute), Level: "INFO", Message: "started", Line: `{"msg":"started"}`},
+		{Time: now.Add(-time.Minute), Level: "WARN", Message: "slow pull", Line: `{"msg":"slow pull"}`},
+		{Time: now, Level: "error", Message: "deploy failed", Line: `{"msg":"deploy failed"}`},
+	}})
+	if err != nil {
+		t.Fatalf("IngestNodeLogs: %v", err)
+	}
+
+	logs, err := svc.GetNodeLogs(ctx, remote.ID, domain.NodeLogQuery{})
+	if err != nil {
+		t.Fatalf("GetNodeLogs: %v", err)
+	}
+	if len(logs) != 3 || logs[0].Message != "started" || logs[2].Level != "ERROR" {
+		t.Errorf("Expected the 3 lines within retention, oldest first, got %+v", logs)
+	}
+
+	logs, _ = svc.GetNodeLogs(ctx, remote.ID, domain.NodeLogQuery{MinLevel: "warn", Limit: 1})
+	if len(logs) != 1 || logs[0].Message != "deploy failed" {
+		t.Errorf("Expected only the newest warning or worse, got %+v", logs)
+	}
+
+	if _, err := svc.GetNodeLogs(ctx, remote.ID, domain.NodeLogQuery{MinLevel: "loud"}); !domain.IsValidationError(err) {
+		t.Errorf("Expected a validation error for an unknown level, got %v", err)
+	}
+	if err := svc.IngestNodeLogs(ctx, "missing", domain.NodeLogPushRequest{}); !domain.IsNotFoundError(err) {
+		t.Errorf("Expected not found for an unknown node, got %v", err)
+	}
+}
@@ -0,0 +1,230 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/selfhostly/internal/constants"
+	"github.com/selfhostly/internal/db"
+	"github.com/selfhostly/internal/domain"
+	"github.com/selfhostly/internal/events"
+	"github.com/selfhostly/internal/system"
+	"github.com/selfhostly/internal/webhook"
+)
+
+// nodeMetricsService samples every node's system stats on the primary, keeps a history of them
+// and raises alerts when a metric crosses its threshold
+type nodeMetricsService struct {
+	database      *db.DB
+	systemService domain.SystemService
+	dispatcher    *webhook.Dispatcher
+	logger        *slog.Logger
+}
+
+// NewNodeMetricsService creates a new node metrics service
+func NewNodeMetricsService(database *db.DB, systemService domain.SystemService, dispatcher *webhook.Dispatcher, logger *slog.Logger) domain.NodeMetricsService {
+	return &nodeMetricsService{
+		database:      database,
+		systemService: systemService,
+		dispatcher:    dispatcher,
+		logger:        logger,
+	}
+}
+
+// alertThreshold is one metric checked against its configured threshold
+type alertThreshold struct {
+	metric    string
+	unit      string
+	threshold int  // 0 disables the check
+	sustained bool // Must stay over the threshold for the sustained window before firing
+	value     func(m *db.NodeMetric) (float64, bool)
+}
+
+func alertThresholds(settings *db.Settings) []alertThreshold {
+	return []alertThreshold{
+		{constants.NodeMetricDisk, "%", settings.AlertDiskPercent, false, func(m *db.NodeMetric) (float64, bool) {
+			return m.DiskPercent, true
+		}},
+		{constants.NodeMetricMemory, "%", settings.AlertMemoryPercent, true, func(m *db.NodeMetric) (float64, bool) {
+			return m.MemoryPercent, true
+		}},
+		{constants.NodeMetricLoad, "% of cores", settings.AlertLoadPercent, true, func(m *db.NodeMetric) (float64, bool) {
+			return m.LoadPercent(), m.CPUCores > 0
+		}},
+		{constants.NodeMetricTemperature, "°C", settings.AlertTemperatureCelsius, true, func(m *db.NodeMetric) (float64, bool) {
+			if m.TemperatureCelsius == nil {
+				return 0, false
+			}
+			return *m.TemperatureCelsius, true
+		}},
+	}
+}
+
+// RecordNodeMetrics samples every reachable node, checks each sample against the alert
+// thresholds and prunes samples older than the retention period
+func (s *nodeMetricsService) RecordNodeMetrics(ctx context.Context) error {
+	allStats, err := s.systemService.GetSystemStats(ctx, nil)
+	if err != nil {
+		return err
+	}
+	settings, err := s.database.GetSettings()
+	if err != nil {
+		return domain.WrapDatabaseOperation("get settings", err)
+	}
+
+	now := time.Now()
+	for _, stats := range allStats {
+		if stats.Status != constants.NodeStatusOnline {
+			continue
+		}
+		metric := nodeMetricFromStats(stats, now)
+		if err := s.database.CreateNodeMetric(metric); err != nil {
+			s.logger.WarnContext(ctx, "failed to record node metrics", "nodeID", stats.NodeID, "error", err)
+			continue
+		}
+		if err := s.checkThresholds(ctx, settings, stats.NodeName, metric); err != nil {
+			s.logger.WarnContext(ctx, "failed to check node alert thresholds", "nodeID", stats.NodeID, "error", err)
+		}
+	}
+
+	if pruned, err := s.database.DeleteNodeMetricsBefore(now.Add(-constants.NodeMetricsRetention)); err != nil {
+		s.logger.WarnContext(ctx, "failed to prune node metrics", "error", err)
+	} else if pruned > 0 {
+		s.logger.DebugContext(ctx, "pruned node metrics", "count", pruned)
+	}
+	return nil
+}
+
+// nodeMetricFromStats converts a node's system stats into a history sample
+func nodeMetricFromStats(stats *system.SystemStats, recordedAt time.Time) *db.NodeMetric {
+	return &db.NodeMetric{
+		NodeID:             stats.NodeID,
+		CPUPercent:         stats.CPU.UsagePercent,
+		CPUCores:           stats.CPU.Cores,
+		MemoryPercent:      stats.Memory.UsagePercent,
+		DiskPercent:        stats.Disk.UsagePercent,
+		Load1:              stats.CPU.Load1,
+		Load5:              stats.CPU.Load5,
+		Load15:             stats.CPU.Load15,
+		TemperatureCelsius: stats.TemperatureCelsius,
+		RecordedAt:         recordedAt,
+	}
+}
+
+// checkThresholds fires an alert for each metric of the sample newly over its threshold, and
+// resolves active alerts whose metric is back under it (or whose threshold was disabled)
+func (s *nodeMetricsService) checkThresholds(ctx context.Context, settings *db.Settings, nodeName string, metric *db.NodeMetric) error {
+	active, err := s.database.GetNodeAlerts(metric.NodeID, true, constants.NodeAlertsListLimit)
+	if err != nil {
+		return err
+	}
+	activeByMetric := make(map[string]*db.NodeAlert, len(active))
+	for _, alert := range active {
+		activeByMetric[alert.Metric] = alert
+	}
+
+	window := time.Duration(settings.AlertSustainedMinutes) * time.Minute
+	var history []*db.NodeMetric
+	for _, t := range alertThresholds(settings) {
+		value, ok := t.value(metric)
+		over := ok && t.threshold > 0 && value > float64(t.threshold)
+		alert := activeByMetric[t.metric]
+
+		switch {
+		case alert != nil && !over:
+			if err := s.database.ResolveNodeAlert(alert.ID, metric.RecordedAt); err != nil {
+				return err
+			}
+			resolvedAt := metric.RecordedAt
+			alert.ResolvedAt = &resolvedAt
+			s.notify(ctx, settings, alert, nodeName, constants.NodeAlertEventResolved,
+				fmt.Sprintf("%s on %s is back to %.1f%s (threshold %d%s)", t.metric, nodeName, value, t.unit, t.threshold, t.unit))
+
+		case alert == nil && over:
+			if t.sustained && window > 0 {
+				if history == nil {
+					if history, err = s.database.GetNodeMetrics(metric.NodeID, metric.RecordedAt.Add(-window)); err != nil {
+						return err
+					}
+				}
+				if !sustainedOver(history, t, window, metric.RecordedAt) {
+					continue
+				}
+			}
+			alert = db.NewNodeAlert(metric.NodeID, t.metric, value, float64(t.threshold))
+			if err := s.database.CreateNodeAlert(alert); err != nil {
+				return err
+			}
+			s.notify(ctx, settings, alert, nodeName, constants.NodeAlertEventFiring,
+				fmt.Sprintf("%s on %s is at %.1f%s (threshold %d%s)", t.metric, nodeName, value, t.unit, t.threshold, t.unit))
+		}
+	}
+	return nil
+}
+
+// sustainedOver reports whether every sample in history is over t's threshold, and the samples
+// cover the whole window give or take one sampling interval
+func sustainedOver(history []*db.NodeMetric, t alertThreshold, window time.Duration, now time.Time) bool {
+	if len(history) == 0 || now.Sub(history[0].RecordedAt) < window-constants.NodeMetricsInterval {
+		return false
+	}
+	for _, m := range history {
+		if value, ok := t.value(m); !ok || value <= float64(t.threshold) {
+			return false
+		}
+	}
+	return true
+}
+
+// notify logs an alert and, when an alerts webhook is configured, posts it there in the
+// background so a slow receiver can't hold up sampling
+func (s *nodeMetricsService) notify(ctx context.Context, settings *db.Settings, alert *db.NodeAlert, nodeName, event, message string) {
+	if event == constants.NodeAlertEventFiring {
+		s.logger.WarnContext(ctx, "node alert firing", "nodeID", alert.NodeID, "metric", alert.Metric, "message", message)
+	} else {
+		s.logger.InfoContext(ctx, "node alert resolved", "nodeID", alert.NodeID, "metric", alert.Metric, "message", message)
+	}
+	events.Publish(events.Event{
+		Type:   events.TypeNotification,
+		NodeID: alert.NodeID,
+		Data:   events.Notification{Event: event, Subject: nodeName, Message: message},
+	})
+	if settings.AlertWebhookURL == "" || s.dispatcher == nil {
+		return
+	}
+
+	payload := webhook.NewAlertPayload(alert, nodeName, event, message)
+	url, secret := settings.AlertWebhookURL, settings.AlertWebhookSecret
+	go func() {
+		_ = s.dispatcher.DeliverAlert(context.WithoutCancel(ctx), url, secret, payload)
+	}()
+}
+
+// GetNodeMetrics returns a node's samples recorded since the given time, oldest first
+func (s *nodeMetricsService) GetNodeMetrics(ctx context.Context, nodeID string, since time.Time) ([]*db.NodeMetric, error) {
+	if _, err := s.database.GetNode(nodeID); err != nil {
+		return nil, domain.WrapNodeNotFound(nodeID, err)
+	}
+	metrics, err := s.database.GetNodeMetrics(nodeID, since)
+	if err != nil {
+		return nil, domain.WrapDatabaseOperation("get node metrics", err)
+	}
+	return metrics, nil
+}
+
+// ListNodeAlerts returns a node's most recent alerts, newest first, or those of every node when
+// nodeID is empty
+func (s *nodeMetricsService) ListNodeAlerts(ctx context.Context, nodeID string, activeOnly bool) ([]*db.NodeAlert, error) {
+	if nodeID != "" {
+		if _, err := s.database.GetNode(nodeID); err != nil {
+			return nil, domain.WrapNodeNotFound(nodeID, err)
+		}
+	}
+	alerts, err := s.database.GetNodeAlerts(nodeID, activeOnly, constants.NodeAlertsListLimit)
+	if err != nil {
+		return nil, domain.WrapDatabaseOperation("list node alerts", err)
+	}
+	return alerts, nil
+}
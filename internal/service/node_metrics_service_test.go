@@ -0,0 +1,147 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/selfhostly/internal/constants"
+	"github.com/selfhostly/internal/db"
+	"github.com/selfhostly/internal/domain"
+	"github.com/selfhostly/internal/system"
+	"github.com/selfhostly/internal/webhook"
+)
+
+// stubSystemService returns fixed system stats; its other methods are not used
+type stubSystemService struct {
+	domain.SystemService
+	stats []*system.SystemStats
+}
+
+func (s *stubSystemService) GetSystemStats(ctx context.Context, nodeIDs []string) ([]*system.SystemStats, error) {
+	return s.stats, nil
+}
+
+func TestNodeMetricsService_RecordNodeMetrics(t *testing.T) {
+	database, err := db.Init(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to initialize database: %v", err)
+	}
+	defer database.Close()
+	if err := database.CreateNode(db.NewNodeWithID("self", "self", "http://127.0.0.1:1", "key", true)); err != nil {
+		t.Fatalf("CreateNode: %v", err)
+	}
+
+	events := make(chan webhook.AlertPayload, 10)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload webhook.AlertPayload
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			t.Errorf("Failed to decode alert: %v", err)
+		}
+		events <- payload
+	}))
+	defer receiver.Close()
+
+	settings, err := database.GetSettings()
+	if err != nil {
+		t.Fatalf("GetSettings: %v", err)
+	}
+	settings.AlertWebhookURL = receiver.URL
+	if err := database.UpdateSettings(settings); err != nil {
+		t.Fatalf("UpdateSettings: %v", err)
+	}
+
+	// Memory has been over its threshold for the whole sustained window, load only just went over
+	for minutes := constants.DefaultAlertSustainedMinutes + 2; minutes > 0; minutes-- {
+		sample := &db.NodeMetric{NodeID: "self", CPUCores: 4, MemoryPercent: 97, DiskPercent: 50, Load5: 1, RecordedAt: time.Now().Add(-time.Duration(minutes) * time.Minute)}
+		if err := database.CreateNodeMetric(sample); err != nil {
+			t.Fatalf("CreateNodeMetric: %v", err)
+		}
+	}
+
+	stats := &system.SystemStats{
+		NodeID:   "self",
+		NodeName: "self",
+		Status:   constants.NodeStatusOnline,
+		CPU:      system.CPUStats{Cores: 4, Load5: 12},
+		Memory:   system.MemoryStats{UsagePercent: 97},
+		Disk:     system.DiskStats{UsagePercent: 95},
+	}
+	offline := &system.SystemStats{NodeID: "gone", Status: constants.NodeStatusOffline}
+	systemService := &stubSystemService{stats: []*system.SystemStats{stats, offline}}
+	svc := NewNodeMetricsService(database, systemService, webhook.NewDispatcher(database, "self", slog.Default()), slog.Default())
+	ctx := context.Background()
+
+	if err := svc.RecordNodeMetrics(ctx); err != nil {
+		t.Fatalf("RecordNodeMetrics: %v", err)
+	}
+	active, err := svc.ListNodeAlerts(ctx, "self", true)
+	if err != nil {
+		t.Fatalf("ListNodeAlerts: %v", err)
+	}
+	firing := map[string]bool{}
+	for _, alert := range active {
+		firing[alert.Metric] = true
+	}
+	if len(active) != 2 || !firing[constants.NodeMetricDisk] || !firing[constants.NodeMetricMemory] {
+		t.Fatalf("Expected disk and memory alerts, got %+v", active)
+	}
+	for range 2 {
+		select {
+		case payload := <-events:
+			if payload.Event != constants.NodeAlertEventFiring || payload.NodeName != "self" {
+				t.Errorf("Unexpected alert delivery %+v", payload)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("Timed out waiting for alert delivery")
+		}
+	}
+
+	// Disk recovering resolves its alert; running again doesn't fire memory twice
+	stats.Disk.UsagePercent = 60
+	if err := svc.RecordNodeMetrics(ctx); err != nil {
+		t.Fatalf("RecordNodeMetrics: %v", err)
+	}
+	active, err = svc.ListNodeAlerts(ctx, "self", true)
+	if err != nil {
+		t.Fatalf("ListNodeAlerts: %v", err)
+	}
+	if len(active) != 1 || active[0].Metric != constants.NodeMetricMemory {
+		t.Errorf("Expected only the memory alert to stay active, got %+v", active)
+	}
+	select {
+	case payload := <-events:
+		if payload.Event != constants.NodeAlertEventResolved || payload.Alert.Metric != constants.NodeMetricDisk || payload.Alert.ResolvedAt == nil {
+			t.Errorf("Expected disk alert resolution, got %+v", payload)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for resolution delivery")
+	}
+
+	all, err := svc.ListNodeAlerts(ctx, "self", false)
+	if err != nil {
+		t.Fatalf("ListNodeAlerts: %v", err)
+	}
+	if len(all) != 2 {
+		t.Errorf("Expected 2 alerts in history, got %d", len(all))
+	}
+
+	metrics, err := svc.GetNodeMetrics(ctx, "self", time.Now().Add(-time.Hour))
+	if err != nil {
+		t.Fatalf("GetNodeMetrics: %v", err)
+	}
+	if want := constants.DefaultAlertSustainedMinutes + 4; len(metrics) != want {
+		t.Errorf("Expected %d samples, got %d", want, len(metrics))
+	}
+	if last := metrics[len(metrics)-1]; last.Load5 != 12 || last.DiskPercent != 60 {
+		t.Errorf("Unexpected latest sample %+v", last)
+	}
+	if _, err := svc.GetNodeMetrics(ctx, "missing", time.Now()); !domain.IsNotFoundError(err) {
+		t.Errorf("Expected not found error for unknown node, got %v", err)
+	}
+}
@@ -0,0 +1,226 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/selfhostly/internal/constants"
+	"github.com/selfhostly/internal/db"
+	"github.com/selfhostly/internal/domain"
+	"github.com/selfhostly/internal/version"
+)
+
+// queueableMethods are the HTTP methods that may be held for an offline node; reads are never queued
+var queueableMethods = map[string]bool{
+	http.MethodPost:   true,
+	http.MethodPut:    true,
+	http.MethodPatch:  true,
+	http.MethodDelete: true,
+}
+
+// queueablePathPrefixes are the node-routed API paths whose mutations can be replayed later
+var queueablePathPrefixes = []string{
+	"/api/apps/",
+	"/api/tunnels/apps/",
+	"/api/system/containers/",
+}
+
+// QueueOperation stores a mutating request for a node so it can be replayed once the node is reachable
+func (s *nodeService) QueueOperation(ctx context.Context, nodeID string, req domain.QueueOperationRequest) (*db.QueuedOperation, error) {
+	method := strings.ToUpper(strings.TrimSpace(req.Method))
+	if !queueableMethods[method] {
+		return nil, domain.WrapValidationError("method", fmt.Errorf("only POST, PUT, PATCH and DELETE requests can be queued"))
+	}
+	if !isQueueablePath(req.Path) {
+		return nil, domain.WrapValidationError("path", fmt.Errorf("%q is not a node-routed app, tunnel or container endpoint", req.Path))
+	}
+
+	node, err := s.database.GetNode(nodeID)
+	if err != nil {
+		return nil, fmt.Errorf("node not found: %w", err)
+	}
+	if node.ID == s.config.Node.ID {
+		return nil, domain.WrapValidationError("node_id", fmt.Errorf("operations for the local node are not queued"))
+	}
+	if node.Status == constants.NodeStatusLost {
+		return nil, domain.WrapValidationError("node_id", fmt.Errorf("node %s was removed from the cluster", node.Name))
+	}
+
+	// Replayed with node credentials, so it must be made by someone allowed to make it
+	user := domain.ActingUserFromContext(ctx)
+	if s.config.Auth.Enabled && user.Name == "" {
+		return nil, domain.WrapValidationError("user", fmt.Errorf("only signed-in users can queue operations"))
+	}
+
+	op := db.NewQueuedOperation(node.ID, method, req.Path, req.Query, req.Body)
+	op.UserName, op.UserRole = user.Name, user.Role
+	if err := s.database.CreateQueuedOperation(op); err != nil {
+		s.logger.ErrorContext(ctx, "failed to queue operation", "nodeID", nodeID, "method", method, "path", req.Path, "error", err)
+		return nil, domain.WrapDatabaseOperation("queue operation", err)
+	}
+
+	s.logger.InfoContext(ctx, "queued operation for node", "nodeID", nodeID, "nodeName", node.Name, "operationID", op.ID, "method", method, "path", req.Path)
+
+	// The gateway's view of node status can lag; don't hold the operation if the node is already back
+	if node.Status == constants.NodeStatusOnline {
+		s.replayInBackground(node.ID)
+	}
+
+	return op, nil
+}
+
+// ListQueuedOperations returns a node's queued operations oldest first, optionally filtered by status
+func (s *nodeService) ListQueuedOperations(ctx context.Context, nodeID string, status string) ([]*db.QueuedOperation, error) {
+	if _, err := s.database.GetNode(nodeID); err != nil {
+		return nil, fmt.Errorf("node not found: %w", err)
+	}
+
+	ops, err := s.database.GetQueuedOperationsByNodeID(nodeID, status)
+	if err != nil {
+		return nil, domain.WrapDatabaseOperation("list queued operations", err)
+	}
+	if ops == nil {
+		ops = []*db.QueuedOperation{}
+	}
+	return ops, nil
+}
+
+// CancelQueuedOperation drops a pending operation before it is replayed
+func (s *nodeService) CancelQueuedOperation(ctx context.Context, nodeID string, operationID string) error {
+	if err := s.database.DeleteQueuedOperation(nodeID, operationID); err != nil {
+		if err == sql.ErrNoRows {
+			return domain.WrapQueuedOperationNotFound(operationID, err)
+		}
+		return domain.WrapDatabaseOperation("cancel queued operation", err)
+	}
+
+	s.logger.InfoContext(ctx, "cancelled queued operation", "nodeID", nodeID, "operationID", operationID)
+	return nil
+}
+
+// ReplayQueuedOperations sends a node's pending operations in the order they were queued.
+// Replay stops at the first transport failure so later operations never overtake earlier ones;
+// whatever the node answers (success or not) is recorded and the next operation is sent.
+func (s *nodeService) ReplayQueuedOperations(ctx context.Context, nodeID string) error {
+	if _, busy := s.replaying.LoadOrStore(nodeID, struct{}{}); busy {
+		return nil
+	}
+	defer s.replaying.Delete(nodeID)
+
+	node, err := s.database.GetNode(nodeID)
+	if err != nil {
+		return fmt.Errorf("node not found: %w", err)
+	}
+	// Queued writes were accepted for the API this build speaks; hold them until the node matches
+	if warning := version.SkewWarning(node.Version, node.APIVersion); warning != "" {
+		s.logger.WarnContext(ctx, "not replaying queued operations to node with incompatible version",
+			"nodeID", nodeID, "nodeName", node.Name, "warning", warning)
+		return nil
+	}
+	// Replayed now, they'd only be refused by the node; hold them until the lock is lifted
+	if lock, err := s.database.GetOperationsLock(); err != nil || lock != nil {
+		s.logger.InfoContext(ctx, "operations locked, not replaying queued operations", "nodeID", nodeID, "nodeName", node.Name)
+		return nil
+	}
+
+	ops, err := s.database.GetQueuedOperationsByNodeID(nodeID, constants.QueuedOperationStatusPending)
+	if err != nil {
+		return domain.WrapDatabaseOperation("list queued operations", err)
+	}
+	if len(ops) == 0 {
+		return nil
+	}
+
+	s.logger.InfoContext(ctx, "replaying queued operations", "nodeID", nodeID, "nodeName", node.Name, "count", len(ops))
+
+	for _, op := range ops {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		user, refusal := s.replayUser(ctx, op)
+		if refusal != "" {
+			s.logger.WarnContext(ctx, "not replaying queued operation", "nodeID", nodeID, "operationID", op.ID, "user", op.UserName, "reason", refusal)
+			if err := s.database.UpdateQueuedOperationResult(op.ID, constants.QueuedOperationStatusFailed, 0, &refusal); err != nil {
+				return domain.WrapDatabaseOperation("update queued operation", err)
+			}
+			continue
+		}
+
+		statusCode, body, err := s.nodeClient.ForUser(user).ForwardRequest(node, op.Method, op.Path, op.RawQuery, []byte(op.Body))
+		if err != nil && statusCode == 0 {
+			s.logger.WarnContext(ctx, "node unreachable during replay, leaving remaining operations queued",
+				"nodeID", nodeID, "operationID", op.ID, "error", err)
+			return err
+		}
+
+		status := constants.QueuedOperationStatusCompleted
+		var errorMessage *string
+		if statusCode < 200 || statusCode >= 300 {
+			status = constants.QueuedOperationStatusFailed
+			msg := strings.TrimSpace(string(body))
+			if msg == "" {
+				msg = http.StatusText(statusCode)
+			}
+			errorMessage = &msg
+		}
+
+		if err := s.database.UpdateQueuedOperationResult(op.ID, status, statusCode, errorMessage); err != nil {
+			// Stop rather than risk replaying this operation a second time on the next pass
+			s.logger.ErrorContext(ctx, "failed to record queued operation result", "operationID", op.ID, "error", err)
+			return domain.WrapDatabaseOperation("update queued operation", err)
+		}
+
+		s.logger.InfoContext(ctx, "replayed queued operation",
+			"nodeID", nodeID, "operationID", op.ID, "method", op.Method, "path", op.Path, "status", status, "responseStatus", statusCode)
+	}
+
+	return nil
+}
+
+// replayUser checks that whoever queued op may still make changes, since their role may have
+// changed or been revoked while the node was offline. Returns the user to replay op for, or why it
+// must not be replayed.
+func (s *nodeService) replayUser(ctx context.Context, op *db.QueuedOperation) (domain.ActingUser, string) {
+	if !s.config.Auth.Enabled {
+		return domain.ActingUser{Name: op.UserName, Role: op.UserRole}, ""
+	}
+	if op.UserName == "" {
+		return domain.ActingUser{}, "queued without a signed-in user; queue it again"
+	}
+	role, ok := s.users.UserRole(ctx, op.UserName)
+	if !ok {
+		return domain.ActingUser{}, fmt.Sprintf("%s no longer has access", op.UserName)
+	}
+	if role == constants.UserRoleViewer {
+		return domain.ActingUser{}, fmt.Sprintf("%s can no longer make changes", op.UserName)
+	}
+	return domain.ActingUser{Name: op.UserName, Role: role}, ""
+}
+
+// replayInBackground replays a node's queue without blocking the caller (health checks, heartbeats)
+func (s *nodeService) replayInBackground(nodeID string) {
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), constants.QueuedOperationReplayTimeout)
+		defer cancel()
+		if err := s.ReplayQueuedOperations(ctx, nodeID); err != nil {
+			s.logger.WarnContext(ctx, "queued operation replay incomplete", "nodeID", nodeID, "error", err)
+		}
+	}()
+}
+
+// isQueueablePath reports whether path is a node-routed endpoint that may be replayed later
+func isQueueablePath(path string) bool {
+	if strings.Contains(path, "..") {
+		return false
+	}
+	for _, prefix := range queueablePathPrefixes {
+		if strings.HasPrefix(path, prefix) && len(path) > len(prefix) {
+			return true
+		}
+	}
+	return false
+}
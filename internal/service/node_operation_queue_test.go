@@ -0,0 +1,265 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/selfhostly/internal/config"
+	"github.com/selfhostly/internal/constants"
+	"github.com/selfhostly/internal/db"
+	"github.com/selfhostly/internal/domain"
+	"github.com/selfhostly/internal/reqsign"
+	"github.com/selfhostly/internal/version"
+)
+
+func TestNodeOperationQueue(t *testing.T) {
+	database, err := db.Init(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to initialize database: %v", err)
+	}
+	defer database.Close()
+
+	var replayed []string
+	remote := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Node-API-Key") != "remote-key" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		replayed = append(replayed, r.Method+" "+r.URL.RequestURI())
+		if r.URL.Path == "/api/apps/app-2/start" {
+			w.WriteHeader(http.StatusConflict)
+			_, _ = w.Write([]byte(`{"error":"busy"}`))
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer remote.Close()
+
+	remoteNode := db.NewNodeWithID("remote-node", "remote", remote.URL, "remote-key", false)
+	remoteNode.Status = constants.NodeStatusOffline
+	remoteNode.Version, remoteNode.APIVersion = version.Get(), version.APIVersion
+	if err := database.CreateNode(remoteNode); err != nil {
+		t.Fatalf("CreateNode: %v", err)
+	}
+
+	cfg := &config.Config{Node: config.NodeConfig{ID: "primary-node", IsPrimary: true}}
+	svc := NewNodeService(database, cfg, slog.Default())
+	ctx := context.Background()
+
+	// Reads and non node-routed paths are rejected
+	if _, err := svc.QueueOperation(ctx, remoteNode.ID, domain.QueueOperationRequest{Method: "GET", Path: "/api/apps/app-1"}); !domain.IsValidationError(err) {
+		t.Errorf("expected validation error for GET, got %v", err)
+	}
+	if _, err := svc.QueueOperation(ctx, remoteNode.ID, domain.QueueOperationRequest{Method: "POST", Path: "/api/settings"}); !domain.IsValidationError(err) {
+		t.Errorf("expected validation error for /api/settings, got %v", err)
+	}
+
+	first, err := svc.QueueOperation(ctx, remoteNode.ID, domain.QueueOperationRequest{Method: "post", Path: "/api/apps/app-1/stop", Query: "node_id=remote-node"})
+	if err != nil {
+		t.Fatalf("QueueOperation: %v", err)
+	}
+	if first.Method != http.MethodPost || first.Status != constants.QueuedOperationStatusPending {
+		t.Errorf("unexpected queued operation: %+v", first)
+	}
+	if _, err := svc.QueueOperation(ctx, remoteNode.ID, domain.QueueOperationRequest{Method: "POST", Path: "/api/apps/app-2/start"}); err != nil {
+		t.Fatalf("QueueOperation: %v", err)
+	}
+	cancelled, err := svc.QueueOperation(ctx, remoteNode.ID, domain.QueueOperationRequest{Method: "DELETE", Path: "/api/apps/app-3"})
+	if err != nil {
+		t.Fatalf("QueueOperation: %v", err)
+	}
+	if err := svc.CancelQueuedOperation(ctx, remoteNode.ID, cancelled.ID); err != nil {
+		t.Fatalf("CancelQueuedOperation: %v", err)
+	}
+	if err := svc.CancelQueuedOperation(ctx, remoteNode.ID, cancelled.ID); !domain.IsNotFoundError(err) {
+		t.Errorf("expected not found error cancelling twice, got %v", err)
+	}
+
+	if err := svc.ReplayQueuedOperations(ctx, remoteNode.ID); err != nil {
+		t.Fatalf("ReplayQueuedOperations: %v", err)
+	}
+
+	wantReplayed := []string{"POST /api/apps/app-1/stop?node_id=remote-node", "POST /api/apps/app-2/start"}
+	if len(replayed) != len(wantReplayed) {
+		t.Fatalf("replayed %v, want %v", replayed, wantReplayed)
+	}
+	for i := range wantReplayed {
+		if replayed[i] != wantReplayed[i] {
+			t.Errorf("replayed[%d] = %q, want %q", i, replayed[i], wantReplayed[i])
+		}
+	}
+
+	ops, err := svc.ListQueuedOperations(ctx, remoteNode.ID, "")
+	if err != nil {
+		t.Fatalf("ListQueuedOperations: %v", err)
+	}
+	if len(ops) != 2 {
+		t.Fatalf("expected 2 operations, got %d", len(ops))
+	}
+	if ops[0].Status != constants.QueuedOperationStatusCompleted {
+		t.Errorf("expected first operation completed, got %s", ops[0].Status)
+	}
+	if ops[1].Status != constants.QueuedOperationStatusFailed || ops[1].ResponseStatus != http.StatusConflict {
+		t.Errorf("expected second operation failed with 409, got %s/%d", ops[1].Status, ops[1].ResponseStatus)
+	}
+	if ops[1].ErrorMessage == nil || *ops[1].ErrorMessage != `{"error":"busy"}` {
+		t.Errorf("expected node response recorded as error, got %v", ops[1].ErrorMessage)
+	}
+
+	// Nothing is left to replay
+	if err := svc.ReplayQueuedOperations(ctx, remoteNode.ID); err != nil {
+		t.Fatalf("second ReplayQueuedOperations: %v", err)
+	}
+	if len(replayed) != 2 {
+		t.Errorf("expected completed operations not to be replayed again, got %v", replayed)
+	}
+}
+
+func TestNodeOperationQueue_UnreachableNodeKeepsOperationsPending(t *testing.T) {
+	database, err := db.Init(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to initialize database: %v", err)
+	}
+	defer database.Close()
+
+	remote := httptest.NewServer(http.NotFoundHandler())
+	endpoint := remote.URL
+	remote.Close()
+
+	remoteNode := db.NewNodeWithID("remote-node", "remote", endpoint, "remote-key", false)
+	remoteNode.Status = constants.NodeStatusUnreachable
+	remoteNode.Version, remoteNode.APIVersion = version.Get(), version.APIVersion
+	if err := database.CreateNode(remoteNode); err != nil {
+		t.Fatalf("CreateNode: %v", err)
+	}
+
+	cfg := &config.Config{Node: config.NodeConfig{ID: "primary-node", IsPrimary: true}}
+	svc := NewNodeService(database, cfg, slog.Default())
+	ctx := context.Background()
+
+	if _, err := svc.QueueOperation(ctx, remoteNode.ID, domain.QueueOperationRequest{Method: "POST", Path: "/api/apps/app-1/start"}); err != nil {
+		t.Fatalf("QueueOperation: %v", err)
+	}
+
+	if err := svc.ReplayQueuedOperations(ctx, remoteNode.ID); err == nil {
+		t.Fatal("expected replay to fail while node is unreachable")
+	}
+
+	pending, err := svc.ListQueuedOperations(ctx, remoteNode.ID, constants.QueuedOperationStatusPending)
+	if err != nil {
+		t.Fatalf("ListQueuedOperations: %v", err)
+	}
+	if len(pending) != 1 {
+		t.Errorf("expected operation to stay pending, got %d pending", len(pending))
+	}
+}
+
+func TestNodeOperationQueue_IncompatibleNodeHoldsOperations(t *testing.T) {
+	database, err := db.Init(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to initialize database: %v", err)
+	}
+	defer database.Close()
+
+	var replayed int
+	remote := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		replayed++
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer remote.Close()
+
+	remoteNode := db.NewNodeWithID("remote-node", "remote", remote.URL, "remote-key", false)
+	remoteNode.Version, remoteNode.APIVersion = "v99.0.0", version.APIVersion+1
+	if err := database.CreateNode(remoteNode); err != nil {
+		t.Fatalf("CreateNode: %v", err)
+	}
+
+	cfg := &config.Config{Node: config.NodeConfig{ID: "primary-node", IsPrimary: true}}
+	svc := NewNodeService(database, cfg, slog.Default())
+	ctx := context.Background()
+
+	if _, err := svc.QueueOperation(ctx, remoteNode.ID, domain.QueueOperationRequest{Method: "POST", Path: "/api/apps/app-1/start"}); err != nil {
+		t.Fatalf("QueueOperation: %v", err)
+	}
+	if err := svc.ReplayQueuedOperations(ctx, remoteNode.ID); err != nil {
+		t.Fatalf("ReplayQueuedOperations: %v", err)
+	}
+	if replayed != 0 {
+		t.Errorf("expected no operations sent to an incompatible node, got %d", replayed)
+	}
+	if pending, _ := svc.ListQueuedOperations(ctx, remoteNode.ID, constants.QueuedOperationStatusPending); len(pending) != 1 {
+		t.Errorf("expected operation to stay pending, got %d pending", len(pending))
+	}
+}
+
+func TestNodeOperationQueue_ActsForQueuingUser(t *testing.T) {
+	database, err := db.Init(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to initialize database: %v", err)
+	}
+	defer database.Close()
+
+	replayedFor := map[string]string{}
+	remote := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		replayedFor[r.URL.Path] = r.Header.Get(reqsign.HeaderUser) + "/" + r.Header.Get(reqsign.HeaderUserRole)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer remote.Close()
+
+	remoteNode := db.NewNodeWithID("remote-node", "remote", remote.URL, "remote-key", false)
+	remoteNode.Status = constants.NodeStatusOffline
+	remoteNode.Version, remoteNode.APIVersion = version.Get(), version.APIVersion
+	if err := database.CreateNode(remoteNode); err != nil {
+		t.Fatalf("CreateNode: %v", err)
+	}
+	demoted := db.NewUser("bob", "")
+	demoted.Role = constants.UserRoleAdmin
+	if err := database.CreateUser(demoted); err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+
+	cfg := &config.Config{Node: config.NodeConfig{ID: "primary-node", IsPrimary: true}}
+	cfg.Auth.Enabled = true
+	cfg.Auth.GitHub.AllowedUsers = []string{"alice"}
+	svc := NewNodeService(database, cfg, slog.Default())
+	ctx := context.Background()
+
+	if _, err := svc.QueueOperation(ctx, remoteNode.ID, domain.QueueOperationRequest{Method: "POST", Path: "/api/apps/app-0/start"}); !domain.IsValidationError(err) {
+		t.Errorf("expected an anonymous operation to be refused, got %v", err)
+	}
+	for user, path := range map[string]string{"alice": "/api/apps/app-1/start", "bob": "/api/apps/app-2/start"} {
+		userCtx := domain.WithActingUser(ctx, domain.ActingUser{Name: user, Role: constants.UserRoleAdmin})
+		op, err := svc.QueueOperation(userCtx, remoteNode.ID, domain.QueueOperationRequest{Method: "POST", Path: path})
+		if err != nil || op.UserName != user {
+			t.Fatalf("QueueOperation for %s: %+v, %v", user, op, err)
+		}
+	}
+
+	// bob became a viewer while the node was offline
+	if err := database.DeleteUser("bob"); err != nil {
+		t.Fatalf("DeleteUser: %v", err)
+	}
+	demoted = db.NewUser("bob", "")
+	demoted.Role = constants.UserRoleViewer
+	if err := database.CreateUser(demoted); err != nil {
+		t.Fatalf("CreateUser: %v", err)
+	}
+	if err := svc.ReplayQueuedOperations(ctx, remoteNode.ID); err != nil {
+		t.Fatalf("ReplayQueuedOperations: %v", err)
+	}
+
+	if got := replayedFor["/api/apps/app-1/start"]; got != "alice/"+constants.UserRoleAdmin {
+		t.Errorf("expected alice's operation replayed on her behalf, got %q", got)
+	}
+	if _, sent := replayedFor["/api/apps/app-2/start"]; sent {
+		t.Error("expected the operation of a user who can no longer make changes not to be replayed")
+	}
+	ops, _ := svc.ListQueuedOperations(ctx, remoteNode.ID, constants.QueuedOperationStatusFailed)
+	if len(ops) != 1 || ops[0].UserName != "bob" || ops[0].ErrorMessage == nil {
+		t.Errorf("expected bob's operation to be failed with the reason, got %+v", ops)
+	}
+}
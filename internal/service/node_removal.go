@@ -0,0 +1,261 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/selfhostly/internal/constants"
+	"github.com/selfhostly/internal/db"
+	"github.com/selfhostly/internal/domain"
+)
+
+// RemoveNodeAsync queues a node_remove job that deals with the node's apps using the requested
+// strategy before removing the node
+func (s *nodeService) RemoveNodeAsync(ctx context.Context, nodeID string, req domain.RemoveNodeRequest) (*db.Job, error) {
+	node, err := s.removableNode(nodeID)
+	if err != nil {
+		return nil, err
+	}
+
+	switch req.Strategy {
+	case constants.NodeRemoveStrategyOrphan, constants.NodeRemoveStrategyPurge:
+		req.TargetNodeID = ""
+	case constants.NodeRemoveStrategyMigrate:
+		if _, err := s.migrationTarget(node, req.TargetNodeID); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, domain.WrapValidationError("strategy", fmt.Errorf("unknown strategy %q (use %s, %s or %s)", req.Strategy,
+			constants.NodeRemoveStrategyOrphan, constants.NodeRemoveStrategyMigrate, constants.NodeRemoveStrategyPurge))
+	}
+
+	payloadJSON, err := json.Marshal(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal payload: %w", err)
+	}
+	payload := string(payloadJSON)
+
+	job := db.NewJob(constants.JobTypeNodeRemove, node.ID, &payload)
+	if err := s.database.CreateJob(job); err != nil {
+		return nil, domain.WrapDatabaseOperation("create job", err)
+	}
+
+	s.logger.InfoContext(ctx, "created node removal job", "nodeID", nodeID, "nodeName", node.Name, "strategy", req.Strategy, "jobID", job.ID)
+	return job, nil
+}
+
+// RemoveNode carries out a node removal. Orphaning marks the node lost and keeps it, with its
+// cached apps. Migrating and purging handle every app first and only delete the node when all of
+// them succeeded, so a failed run can be retried; the per-app outcome is in the result either way.
+func (s *nodeService) RemoveNode(ctx context.Context, nodeID string, req domain.RemoveNodeRequest, progress func(percent int, message string)) (*domain.RemoveNodeResult, error) {
+	node, err := s.removableNode(nodeID)
+	if err != nil {
+		return nil, err
+	}
+
+	progress(5, "Listing the node's apps...")
+	apps, reachable, err := s.nodeApps(ctx, node)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &domain.RemoveNodeResult{NodeID: node.ID, Strategy: req.Strategy, Apps: []*domain.RemovedNodeApp{}}
+
+	var target *db.Node
+	switch req.Strategy {
+	case constants.NodeRemoveStrategyOrphan:
+		for _, app := range apps {
+			result.Apps = append(result.Apps, &domain.RemovedNodeApp{AppID: app.ID, Name: app.Name, Success: true, Detail: "kept in the app inventory"})
+		}
+		node.Status = constants.NodeStatusLost
+		if err := s.database.UpdateNode(node); err != nil {
+			return result, domain.WrapDatabaseOperation("update node", err)
+		}
+		s.logger.WarnContext(ctx, "node orphaned, its apps are kept as stale records", "nodeID", node.ID, "nodeName", node.Name, "apps", len(apps))
+		return result, nil
+	case constants.NodeRemoveStrategyMigrate:
+		if target, err = s.migrationTarget(node, req.TargetNodeID); err != nil {
+			return nil, err
+		}
+		if !reachable && len(apps) > 0 {
+			return nil, fmt.Errorf("node %s is not reachable, so its apps can't be read for migration", node.Name)
+		}
+	case constants.NodeRemoveStrategyPurge:
+	default:
+		return nil, domain.WrapValidationError("strategy", fmt.Errorf("unknown strategy %q", req.Strategy))
+	}
+
+	failed := 0
+	for i, app := range apps {
+		var entry *domain.RemovedNodeApp
+		if target != nil {
+			progress(10+80*i/len(apps), fmt.Sprintf("Migrating %s to %s (%d/%d)...", app.Name, target.Name, i+1, len(apps)))
+			entry = s.migrateApp(ctx, node, target, app)
+		} else {
+			progress(10+80*i/len(apps), fmt.Sprintf("Deleting %s (%d/%d)...", app.Name, i+1, len(apps)))
+			entry = s.purgeApp(ctx, node, app, reachable)
+		}
+		if !entry.Success {
+			failed++
+		}
+		result.Apps = append(result.Apps, entry)
+	}
+	if failed > 0 {
+		return result, fmt.Errorf("%d of %d apps could not be handled; node %s was kept", failed, len(apps), node.Name)
+	}
+
+	progress(95, "Removing the node...")
+	if err := s.database.DeleteNode(node.ID); err != nil {
+		return result, domain.WrapDatabaseOperation("delete node", err)
+	}
+	result.Removed = true
+
+	s.logger.InfoContext(ctx, "node removed", "nodeID", node.ID, "nodeName", node.Name, "strategy", req.Strategy, "apps", len(apps))
+	return result, nil
+}
+
+// migrateApp recreates one app on target from its definition on node, then deletes the original
+// so a retried removal doesn't create it twice. Only the app's configuration moves: the original's
+// volumes are kept and its directory, with any bind-mounted data, is archived in node's trash.
+// An app of the same name and compose already on target is taken to be an earlier attempt's copy.
+func (s *nodeService) migrateApp(ctx context.Context, node, target *db.Node, app *db.App) *domain.RemovedNodeApp {
+	entry := &domain.RemovedNodeApp{AppID: app.ID, Name: app.Name}
+
+	source, err := s.nodeClient.GetApp(node, app.ID)
+	if err != nil {
+		entry.Error = err.Error()
+		return entry
+	}
+	created, err := s.migratedCopy(target, source)
+	if err != nil {
+		entry.Error = err.Error()
+		return entry
+	}
+	if created == nil {
+		created, err = s.createMigratedCopy(target, source)
+		if err != nil {
+			entry.Error = err.Error()
+			return entry
+		}
+	}
+	entry.Success = true
+	entry.NewAppID = created.ID
+
+	if err := s.nodeClient.DeleteAppWithOptions(node, app.ID, domain.DeleteAppOptions{Archive: true}); err != nil {
+		s.logger.WarnContext(ctx, "migrated app could not be deleted on its old node", "appID", app.ID, "nodeID", node.ID, "error", err)
+		entry.Success = false
+		entry.Error = fmt.Sprintf("created on %s, but deleting the original failed: %v", target.Name, err)
+		return entry
+	}
+	entry.Detail = fmt.Sprintf("created on %s; the original was deleted, its volumes kept and its directory archived on %s", target.Name, node.Name)
+	return entry
+}
+
+// migratedCopy finds the copy of source an earlier migration left on target. It returns nil when
+// there is none, and an error when target has an unrelated app with the same name.
+func (s *nodeService) migratedCopy(target *db.Node, source *db.App) (*db.App, error) {
+	apps, err := s.nodeClient.GetApps(target)
+	if err != nil {
+		return nil, err
+	}
+	for _, app := range apps {
+		if app.Name != source.Name {
+			continue
+		}
+		if app.ComposeContent != source.ComposeContent {
+			return nil, fmt.Errorf("%s already has a different app named %s", target.Name, source.Name)
+		}
+		return app, nil
+	}
+	return nil, nil
+}
+
+// createMigratedCopy creates source's definition on target. The request acts for source's owner,
+// so the copy keeps its owner; target applies the role it knows for them.
+func (s *nodeService) createMigratedCopy(target *db.Node, source *db.App) (*db.App, error) {
+	return s.nodeClient.ForUser(domain.ActingUser{Name: source.Owner}).CreateApp(target, domain.CreateAppRequest{
+		Name:            source.Name,
+		Description:     source.Description,
+		ComposeContent:  source.ComposeContent,
+		ComposeOverride: source.ComposeOverride,
+		Labels:          source.Labels,
+		ComposeFiles:    source.ComposeFiles,
+		EnvTemplate:     source.EnvTemplate,
+	})
+}
+
+// purgeApp deletes one app, with its tunnel, on node. When the node can't be reached only the
+// primary's record of the app is dropped.
+func (s *nodeService) purgeApp(ctx context.Context, node *db.Node, app *db.App, reachable bool) *domain.RemovedNodeApp {
+	entry := &domain.RemovedNodeApp{AppID: app.ID, Name: app.Name}
+	if !reachable {
+		if err := s.database.DeleteNodeAppCacheEntry(node.ID, app.ID); err != nil {
+			entry.Error = err.Error()
+			return entry
+		}
+		entry.Success = true
+		entry.Detail = "node unreachable; resources on the node were not cleaned up"
+		return entry
+	}
+
+	if err := s.nodeClient.DeleteApp(node, app.ID); err != nil {
+		entry.Error = err.Error()
+		return entry
+	}
+	s.logger.InfoContext(ctx, "deleted app on removed node", "appID", app.ID, "nodeID", node.ID)
+	entry.Success = true
+	return entry
+}
+
+// removableNode loads a node that may be removed: any node but the primary
+func (s *nodeService) removableNode(nodeID string) (*db.Node, error) {
+	node, err := s.database.GetNode(nodeID)
+	if err != nil {
+		return nil, domain.WrapNodeNotFound(nodeID, err)
+	}
+	if node.IsPrimary {
+		return nil, domain.WrapValidationError("node_id", fmt.Errorf("cannot delete primary node"))
+	}
+	return node, nil
+}
+
+// migrationTarget loads the online node a removed node's apps are migrated to
+func (s *nodeService) migrationTarget(node *db.Node, targetID string) (*db.Node, error) {
+	if targetID == "" {
+		return nil, domain.WrapValidationError("target_node_id", fmt.Errorf("target_node_id is required to migrate"))
+	}
+	if targetID == node.ID {
+		return nil, domain.WrapValidationError("target_node_id", fmt.Errorf("apps can't be migrated to the node being removed"))
+	}
+	target, err := s.database.GetNode(targetID)
+	if err != nil {
+		return nil, domain.WrapNodeNotFound(targetID, err)
+	}
+	if target.ID != s.config.Node.ID && target.Status != constants.NodeStatusOnline {
+		return nil, domain.WrapValidationError("target_node_id", fmt.Errorf("node %s is %s", target.Name, target.Status))
+	}
+	return target, nil
+}
+
+// nodeApps lists a node's apps, from the node itself while it's online and from the primary's
+// inventory cache otherwise. reachable reports whether the node answered.
+func (s *nodeService) nodeApps(ctx context.Context, node *db.Node) ([]*db.App, bool, error) {
+	if node.Status == constants.NodeStatusOnline {
+		apps, err := s.nodeClient.GetApps(node)
+		if err == nil {
+			return apps, true, nil
+		}
+		s.logger.WarnContext(ctx, "failed to list apps on node, using cached inventory", "nodeID", node.ID, "error", err)
+	}
+
+	cached, err := s.database.GetNodeAppCache(node.ID)
+	if err != nil {
+		return nil, false, domain.WrapDatabaseOperation("get cached apps", err)
+	}
+	apps := make([]*db.App, 0, len(cached))
+	for _, c := range cached {
+		apps = append(apps, &db.App{ID: c.AppID, Name: c.Name, Status: c.Status, NodeID: c.NodeID})
+	}
+	return apps, false, nil
+}
@@ -0,0 +1,294 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/selfhostly/internal/config"
+	"github.com/selfhostly/internal/constants"
+	"github.com/selfhostly/internal/db"
+	"github.com/selfhostly/internal/domain"
+	"github.com/selfhostly/internal/reqsign"
+)
+
+func setupTestNodeRemoval(t *testing.T) (domain.NodeService, *db.DB, *db.Node) {
+	t.Helper()
+	database, err := db.Init(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to initialize database: %v", err)
+	}
+	t.Cleanup(func() { database.Close() })
+
+	primary := db.NewNodeWithID("primary-node", "primary", "http://localhost:8080", "primary-key", true)
+	primary.Status = constants.NodeStatusOnline
+	if err := database.CreateNode(primary); err != nil {
+		t.Fatalf("CreateNode: %v", err)
+	}
+	// The node is offline, so removal works from the primary's cached inventory
+	remote := db.NewNodeWithID("remote-node", "remote", "http://127.0.0.1:1", "remote-key", false)
+	remote.Status = constants.NodeStatusOffline
+	if err := database.CreateNode(remote); err != nil {
+		t.Fatalf("CreateNode: %v", err)
+	}
+	cached := []*db.CachedApp{{AppID: "app-1", Name: "blog", Status: "running"}, {AppID: "app-2", Name: "wiki", Status: "stopped"}}
+	if _, err := database.SyncNodeAppCache(remote.ID, cached, []string{"app-1", "app-2"}); err != nil {
+		t.Fatalf("SyncNodeAppCache: %v", err)
+	}
+
+	cfg := &config.Config{Node: config.NodeConfig{ID: primary.ID, IsPrimary: true}}
+	return NewNodeService(database, cfg, slog.Default()), database, remote
+}
+
+func noProgress(int, string) {}
+
+func TestNodeRemoval_Validation(t *testing.T) {
+	svc, _, remote := setupTestNodeRemoval(t)
+	ctx := context.Background()
+
+	if err := svc.DeleteNode(ctx, remote.ID); !domain.IsConflictError(err) {
+		t.Errorf("expected conflict error deleting a node with apps, got %v", err)
+	}
+
+	invalid := map[string]domain.RemoveNodeRequest{
+		"unknown strategy": {Strategy: "shred"},
+		"no target":        {Strategy: constants.NodeRemoveStrategyMigrate},
+		"target is itself": {Strategy: constants.NodeRemoveStrategyMigrate, TargetNodeID: remote.ID},
+		"unknown target":   {Strategy: constants.NodeRemoveStrategyMigrate, TargetNodeID: "missing"},
+	}
+	for name, req := range invalid {
+		if _, err := svc.RemoveNodeAsync(ctx, remote.ID, req); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+	if _, err := svc.RemoveNodeAsync(ctx, "primary-node", domain.RemoveNodeRequest{Strategy: constants.NodeRemoveStrategyPurge}); !domain.IsValidationError(err) {
+		t.Errorf("expected validation error removing the primary, got %v", err)
+	}
+
+	job, err := svc.RemoveNodeAsync(ctx, remote.ID, domain.RemoveNodeRequest{Strategy: constants.NodeRemoveStrategyMigrate, TargetNodeID: "primary-node"})
+	if err != nil {
+		t.Fatalf("RemoveNodeAsync: %v", err)
+	}
+	if job.Type != constants.JobTypeNodeRemove || job.AppID != remote.ID || job.Payload == nil {
+		t.Errorf("unexpected job %+v", job)
+	}
+}
+
+func TestNodeRemoval_Orphan(t *testing.T) {
+	svc, database, remote := setupTestNodeRemoval(t)
+	ctx := context.Background()
+
+	result, err := svc.RemoveNode(ctx, remote.ID, domain.RemoveNodeRequest{Strategy: constants.NodeRemoveStrategyOrphan}, noProgress)
+	if err != nil {
+		t.Fatalf("RemoveNode: %v", err)
+	}
+	if result.Removed || len(result.Apps) != 2 {
+		t.Errorf("unexpected result %+v", result)
+	}
+
+	node, err := database.GetNode(remote.ID)
+	if err != nil || node.Status != constants.NodeStatusLost {
+		t.Fatalf("expected the node to be kept as lost, got %+v, %v", node, err)
+	}
+	if cached, _ := database.GetNodeAppCache(remote.ID); len(cached) != 2 {
+		t.Errorf("expected the cached apps to be kept, got %d", len(cached))
+	}
+
+	// A lost node stays out of heartbeats and the offline queue
+	if err := svc.NodeHeartbeat(ctx, remote.ID, domain.NodeVersion{}, nil); err == nil {
+		t.Error("expected heartbeat from a lost node to be rejected")
+	}
+	if _, err := svc.QueueOperation(ctx, remote.ID, domain.QueueOperationRequest{Method: "POST", Path: "/api/apps/app-1/stop"}); !domain.IsValidationError(err) {
+		t.Errorf("expected validation error queueing for a lost node, got %v", err)
+	}
+}
+
+func TestNodeRemoval_PurgeUnreachable(t *testing.T) {
+	svc, database, remote := setupTestNodeRemoval(t)
+	ctx := context.Background()
+
+	var steps []string
+	result, err := svc.RemoveNode(ctx, remote.ID, domain.RemoveNodeRequest{Strategy: constants.NodeRemoveStrategyPurge}, func(_ int, message string) {
+		steps = append(steps, message)
+	})
+	if err != nil {
+		t.Fatalf("RemoveNode: %v", err)
+	}
+	if !result.Removed || len(result.Apps) != 2 {
+		t.Fatalf("unexpected result %+v", result)
+	}
+	for _, app := range result.Apps {
+		if !app.Success || app.Detail == "" {
+			t.Errorf("expected %s to be dropped with a note, got %+v", app.Name, app)
+		}
+	}
+	if len(steps) < 4 {
+		t.Errorf("expected progress for every app, got %v", steps)
+	}
+	if _, err := database.GetNode(remote.ID); err == nil {
+		t.Error("expected the node to be deleted")
+	}
+}
+
+// fakeAppNode serves the app endpoints a migration uses from an in-memory app list
+type fakeAppNode struct {
+	mu         sync.Mutex
+	apps       map[string]*db.App
+	creates    int
+	owners     []string
+	deletes    []string
+	failDelete bool
+}
+
+func (f *fakeAppNode) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/apps", func(w http.ResponseWriter, r *http.Request) {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		apps := []*db.App{}
+		for _, app := range f.apps {
+			apps = append(apps, app)
+		}
+		json.NewEncoder(w).Encode(apps)
+	})
+	mux.HandleFunc("GET /api/apps/{id}", func(w http.ResponseWriter, r *http.Request) {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		app, ok := f.apps[r.PathValue("id")]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		json.NewEncoder(w).Encode(app)
+	})
+	mux.HandleFunc("POST /api/apps", func(w http.ResponseWriter, r *http.Request) {
+		var req domain.CreateAppRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		for _, app := range f.apps {
+			if app.Name == req.Name {
+				http.Error(w, "UNIQUE constraint failed: apps.name", http.StatusInternalServerError)
+				return
+			}
+		}
+		f.creates++
+		f.owners = append(f.owners, r.Header.Get(reqsign.HeaderUser))
+		app := db.NewApp(req.Name, req.Description, req.ComposeContent)
+		f.apps[app.ID] = app
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(app)
+	})
+	mux.HandleFunc("DELETE /api/apps/{id}", func(w http.ResponseWriter, r *http.Request) {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		if f.failDelete {
+			http.Error(w, "cleanup failed", http.StatusInternalServerError)
+			return
+		}
+		f.deletes = append(f.deletes, r.PathValue("id")+"?"+r.URL.RawQuery)
+		delete(f.apps, r.PathValue("id"))
+		w.WriteHeader(http.StatusOK)
+	})
+	return mux
+}
+
+func TestNodeRemoval_MigrateRetry(t *testing.T) {
+	svc, database, remote := setupTestNodeRemoval(t)
+	ctx := context.Background()
+
+	source := &fakeAppNode{apps: map[string]*db.App{
+		"app-1": {ID: "app-1", Name: "blog", ComposeContent: "services: {blog: {image: ghost}}", Owner: "alice"},
+		"app-2": {ID: "app-2", Name: "wiki", ComposeContent: "services: {wiki: {image: wikijs}}"},
+	}, failDelete: true}
+	sourceServer := httptest.NewServer(source.handler())
+	defer sourceServer.Close()
+	target := &fakeAppNode{apps: map[string]*db.App{}}
+	targetServer := httptest.NewServer(target.handler())
+	defer targetServer.Close()
+
+	remote.APIEndpoint = sourceServer.URL
+	remote.Status = constants.NodeStatusOnline
+	if err := database.UpdateNode(remote); err != nil {
+		t.Fatalf("UpdateNode: %v", err)
+	}
+	targetNode := db.NewNodeWithID("target-node", "target", targetServer.URL, "target-key", false)
+	targetNode.Status = constants.NodeStatusOnline
+	if err := database.CreateNode(targetNode); err != nil {
+		t.Fatalf("CreateNode: %v", err)
+	}
+	req := domain.RemoveNodeRequest{Strategy: constants.NodeRemoveStrategyMigrate, TargetNodeID: targetNode.ID}
+
+	// The originals can't be deleted, so the node is kept for a retry
+	result, err := svc.RemoveNode(ctx, remote.ID, req, noProgress)
+	if err == nil || result.Removed {
+		t.Fatalf("expected the node to be kept, got %+v, %v", result, err)
+	}
+	if target.creates != 2 {
+		t.Fatalf("expected both apps created on the target, got %d", target.creates)
+	}
+	if !slices.Contains(target.owners, "alice") {
+		t.Errorf("expected the copy to be created for its owner, got %v", target.owners)
+	}
+
+	// The retry reuses the copies instead of hitting the target's unique app names
+	source.failDelete = false
+	result, err = svc.RemoveNode(ctx, remote.ID, req, noProgress)
+	if err != nil || !result.Removed {
+		t.Fatalf("expected the retry to remove the node, got %+v, %v", result, err)
+	}
+	for _, app := range result.Apps {
+		if !app.Success || app.NewAppID == "" || target.apps[app.NewAppID] == nil {
+			t.Errorf("expected %s to be migrated, got %+v", app.Name, app)
+		}
+	}
+	if target.creates != 2 {
+		t.Errorf("expected the retry not to create the apps again, got %d creates", target.creates)
+	}
+	if len(source.apps) != 0 || len(source.deletes) != 2 {
+		t.Fatalf("expected the originals to be deleted, got %v", source.deletes)
+	}
+	for _, deleted := range source.deletes {
+		if !strings.HasSuffix(deleted, "?archive=true") {
+			t.Errorf("expected %s to be deleted with its directory archived", deleted)
+		}
+	}
+}
+
+func TestNodeRemoval_MigrateNameTaken(t *testing.T) {
+	svc, database, remote := setupTestNodeRemoval(t)
+	ctx := context.Background()
+
+	source := &fakeAppNode{apps: map[string]*db.App{"app-1": {ID: "app-1", Name: "blog", ComposeContent: "services: {blog: {image: ghost}}"}}}
+	sourceServer := httptest.NewServer(source.handler())
+	defer sourceServer.Close()
+	target := &fakeAppNode{apps: map[string]*db.App{"other": {ID: "other", Name: "blog", ComposeContent: "services: {blog: {image: wordpress}}"}}}
+	targetServer := httptest.NewServer(target.handler())
+	defer targetServer.Close()
+
+	remote.APIEndpoint = sourceServer.URL
+	remote.Status = constants.NodeStatusOnline
+	if err := database.UpdateNode(remote); err != nil {
+		t.Fatalf("UpdateNode: %v", err)
+	}
+	targetNode := db.NewNodeWithID("target-node", "target", targetServer.URL, "target-key", false)
+	targetNode.Status = constants.NodeStatusOnline
+	if err := database.CreateNode(targetNode); err != nil {
+		t.Fatalf("CreateNode: %v", err)
+	}
+
+	result, err := svc.RemoveNode(ctx, remote.ID, domain.RemoveNodeRequest{Strategy: constants.NodeRemoveStrategyMigrate, TargetNodeID: targetNode.ID}, noProgress)
+	if err == nil || result.Removed || len(result.Apps) != 1 || result.Apps[0].Success {
+		t.Fatalf("expected the migration to fail on the name clash, got %+v, %v", result, err)
+	}
+	if len(source.deletes) != 0 || source.apps["app-1"] == nil {
+		t.Errorf("expected the original to be left alone, got deletes %v", source.deletes)
+	}
+}
@@ -4,21 +4,30 @@ import (
 	"context"
 	"fmt"
 	"log/slog"
+	"sync"
 	"time"
 
 	"github.com/selfhostly/internal/config"
 	"github.com/selfhostly/internal/constants"
 	"github.com/selfhostly/internal/db"
 	"github.com/selfhostly/internal/domain"
+	"github.com/selfhostly/internal/events"
 	"github.com/selfhostly/internal/node"
+	"github.com/selfhostly/internal/version"
 )
 
 // nodeService implements node management operations
 type nodeService struct {
 	database   *db.DB
 	nodeClient *node.Client
+	users      domain.UserService // Current roles, checked again before queued operations are replayed
 	config     *config.Config
 	logger     *slog.Logger
+
+	replaying sync.Map // nodeID -> struct{}; guards against concurrent replays of one node's queue
+
+	inventoryMu     sync.Mutex
+	inventoryCursor time.Time // start of the last successful app inventory push; zero forces a full push
 }
 
 // NewNodeService creates a new node service
@@ -29,7 +38,8 @@ func NewNodeService(
 ) domain.NodeService {
 	return &nodeService{
 		database:   database,
-		nodeClient: node.NewClient(),
+		nodeClient: node.NewClientWithTimeouts(cfg.Timeouts),
+		users:      NewUserService(database, cfg, logger),
 		config:     cfg,
 		logger:     logger,
 	}
@@ -60,13 +70,14 @@ func (s *nodeService) RegisterNode(ctx context.Context, req domain.RegisterNodeR
 	newNode := db.NewNodeWithID(req.ID, req.Name, req.APIEndpoint, req.APIKey, false)
 
 	// Perform initial health check
-	if err := s.nodeClient.HealthCheck(newNode); err != nil {
+	if reported, err := s.nodeClient.HealthCheck(newNode); err != nil {
 		s.logger.WarnContext(ctx, "health check failed for new node", "name", req.Name, "error", err)
 		newNode.Status = "unreachable"
 	} else {
 		newNode.Status = "online"
 		now := time.Now()
 		newNode.LastSeen = &now
+		s.recordNodeVersion(ctx, newNode, *reported)
 	}
 
 	// Save to database
@@ -88,7 +99,7 @@ func (s *nodeService) GetNode(ctx context.Context, nodeID string) (*db.Node, err
 		return nil, fmt.Errorf("node not found: %w", err)
 	}
 
-	return node, nil
+	return s.withLocalVersion(node), nil
 }
 
 // ListNodes retrieves all nodes in the cluster
@@ -101,6 +112,9 @@ func (s *nodeService) ListNodes(ctx context.Context) ([]*db.Node, error) {
 		return nil, domain.WrapDatabaseOperation("list nodes", err)
 	}
 
+	for _, node := range nodes {
+		s.withLocalVersion(node)
+	}
 	return nodes, nil
 }
 
@@ -132,37 +146,24 @@ func (s *nodeService) UpdateNode(ctx context.Context, nodeID string, req domain.
 	}
 
 	s.logger.InfoContext(ctx, "node updated successfully", "nodeID", nodeID)
-	return node, nil
+	return s.withLocalVersion(node), nil
 }
 
-// DeleteNode removes a node from the cluster
+// DeleteNode removes a node without apps from the cluster; a node with apps needs RemoveNodeAsync
 func (s *nodeService) DeleteNode(ctx context.Context, nodeID string) error {
 	s.logger.InfoContext(ctx, "deleting node", "nodeID", nodeID)
 
-	node, err := s.database.GetNode(nodeID)
+	node, err := s.removableNode(nodeID)
 	if err != nil {
-		return fmt.Errorf("node not found: %w", err)
-	}
-
-	// Prevent deletion of primary node
-	if node.IsPrimary {
-		return fmt.Errorf("cannot delete primary node")
+		return err
 	}
 
 	// Check if node has apps
-	apps, err := s.database.GetAllApps()
+	apps, _, err := s.nodeApps(ctx, node)
 	if err != nil {
 		s.logger.WarnContext(ctx, "failed to check for apps on node", "nodeID", nodeID, "error", err)
-	} else {
-		appsOnNode := 0
-		for _, app := range apps {
-			if app.NodeID == nodeID {
-				appsOnNode++
-			}
-		}
-		if appsOnNode > 0 {
-			return fmt.Errorf("cannot delete node with %d apps still deployed", appsOnNode)
-		}
+	} else if len(apps) > 0 {
+		return domain.WrapNodeHasApps(nodeID, len(apps))
 	}
 
 	if err := s.database.DeleteNode(nodeID); err != nil {
@@ -182,9 +183,14 @@ func (s *nodeService) HealthCheckNode(ctx context.Context, nodeID string) error
 	if err != nil {
 		return fmt.Errorf("node not found: %w", err)
 	}
+	if node.Status == constants.NodeStatusLost {
+		return fmt.Errorf("node %s was removed from the cluster", nodeID)
+	}
 
 	// Perform health check
-	err = s.nodeClient.HealthCheck(node)
+	previousStatus := node.Status
+	wasOnline := previousStatus == constants.NodeStatusOnline
+	reported, err := s.nodeClient.HealthCheck(node)
 	now := time.Now()
 
 	if err != nil {
@@ -214,6 +220,7 @@ func (s *nodeService) HealthCheckNode(ctx context.Context, nodeID string) error
 		node.Status = "online"
 		node.LastSeen = &now
 		node.LastHealthCheck = &now
+		s.recordNodeVersion(ctx, node, *reported)
 		s.logger.DebugContext(ctx, "node health check succeeded", "nodeID", nodeID)
 	}
 
@@ -222,6 +229,13 @@ func (s *nodeService) HealthCheckNode(ctx context.Context, nodeID string) error
 	// Update node status in database
 	if dbErr := s.database.UpdateNode(node); dbErr != nil {
 		s.logger.ErrorContext(ctx, "failed to update node status", "nodeID", nodeID, "error", dbErr)
+	} else {
+		publishNodeHealth(node, previousStatus)
+	}
+
+	// Node came back: flush operations queued while it was away
+	if err == nil && !wasOnline {
+		s.replayInBackground(nodeID)
 	}
 
 	return err
@@ -250,6 +264,11 @@ func (s *nodeService) HealthCheckAllNodes(ctx context.Context) error {
 			continue
 		}
 
+		// Lost nodes were removed from the cluster; only their app records remain
+		if node.Status == constants.NodeStatusLost {
+			continue
+		}
+
 		// Implement exponential backoff based on consecutive failures
 		// 0 failures (online): check every cycle (30s)
 		// 1-2 failures (offline): check every cycle (30s)
@@ -349,6 +368,26 @@ func (s *nodeService) SyncSettingsFromPrimary(ctx context.Context) error {
 	localSettings.ActiveTunnelProvider = settings.ActiveTunnelProvider
 	localSettings.TunnelProviderConfig = settings.TunnelProviderConfig
 	localSettings.AutoStartApps = settings.AutoStartApps
+	localSettings.FeatureFlags = settings.FeatureFlags
+	localSettings.LogForwardingDestination = settings.LogForwardingDestination
+	localSettings.LogForwardingURL = settings.LogForwardingURL
+	localSettings.LogForwardingUsername = settings.LogForwardingUsername
+	localSettings.LogForwardingPassword = settings.LogForwardingPassword
+	localSettings.LogForwardingIndex = settings.LogForwardingIndex
+	// Primaries from before the jobs settings section don't send these
+	if settings.JobHistoryKeepCount > 0 {
+		localSettings.JobHistoryKeepCount = settings.JobHistoryKeepCount
+	}
+	if settings.JobStaleThresholdMinutes > 0 {
+		localSettings.JobStaleThresholdMinutes = settings.JobStaleThresholdMinutes
+	}
+	// Nor these, from before the compose policies
+	if settings.ComposePolicyPrivileged != "" {
+		localSettings.ComposePolicyPrivileged = settings.ComposePolicyPrivileged
+		localSettings.ComposePolicyHostNetwork = settings.ComposePolicyHostNetwork
+		localSettings.ComposePolicyCapAdd = settings.ComposePolicyCapAdd
+		localSettings.ComposePolicyDevices = settings.ComposePolicyDevices
+	}
 	localSettings.UpdatedAt = time.Now()
 
 	if err := s.database.UpdateSettings(localSettings); err != nil {
@@ -371,7 +410,7 @@ func (s *nodeService) GetCurrentNodeInfo(ctx context.Context) (*db.Node, error)
 	// Find node matching current config
 	for _, node := range nodes {
 		if node.Name == s.config.Node.Name || node.ID == s.config.Node.ID {
-			return node, nil
+			return s.withLocalVersion(node), nil
 		}
 	}
 
@@ -386,28 +425,37 @@ func (s *nodeService) GetCurrentNodeInfo(ctx context.Context) (*db.Node, error)
 		IsPrimary:   s.config.Node.IsPrimary,
 		Status:      "online",
 		LastSeen:    &now,
+		Version:     version.Get(),
+		APIVersion:  version.APIVersion,
 		CreatedAt:   now,
 		UpdatedAt:   now,
 	}, nil
 }
 
 // NodeHeartbeat handles a heartbeat from a node announcing it's online
-// This resets the failure counter and triggers an immediate health check
-func (s *nodeService) NodeHeartbeat(ctx context.Context, nodeID string) error {
+// This resets the failure counter and triggers an immediate health check. clockSkew is how far
+// the node's clock is from this one's, when the node sent its time.
+func (s *nodeService) NodeHeartbeat(ctx context.Context, nodeID string, reported domain.NodeVersion, clockSkew *time.Duration) error {
 	s.logger.InfoContext(ctx, "received heartbeat from node", "nodeID", nodeID)
 
 	node, err := s.database.GetNode(nodeID)
 	if err != nil {
 		return fmt.Errorf("node not found: %w", err)
 	}
+	if node.Status == constants.NodeStatusLost {
+		return fmt.Errorf("node %s was removed from the cluster", nodeID)
+	}
 
 	// Reset failure counter and mark as online
+	previousStatus := node.Status
 	now := time.Now()
 	node.ConsecutiveFailures = 0
 	node.Status = "online"
 	node.LastSeen = &now
 	node.LastHealthCheck = &now
 	node.UpdatedAt = now
+	s.recordNodeVersion(ctx, node, reported)
+	s.recordClockSkew(ctx, node, clockSkew)
 
 	if err := s.database.UpdateNode(node); err != nil {
 		s.logger.ErrorContext(ctx, "failed to update node after heartbeat", "nodeID", nodeID, "error", err)
@@ -415,5 +463,48 @@ func (s *nodeService) NodeHeartbeat(ctx context.Context, nodeID string) error {
 	}
 
 	s.logger.InfoContext(ctx, "node heartbeat processed successfully", "nodeID", nodeID, "nodeName", node.Name)
+	publishNodeHealth(node, previousStatus)
+
+	// Heartbeats are sent on startup, so this is the earliest point the node can take queued operations
+	s.replayInBackground(nodeID)
 	return nil
 }
+
+// publishNodeHealth tells event stream clients that a node's status changed from from
+func publishNodeHealth(node *db.Node, from string) {
+	if node.Status == from {
+		return
+	}
+	events.Publish(events.Event{
+		Type:   events.TypeNodeHealth,
+		NodeID: node.ID,
+		Data:   events.NodeHealth{NodeID: node.ID, NodeName: node.Name, From: from, To: node.Status},
+	})
+}
+
+// recordNodeVersion stores the versions a node reported and warns when they change to or from
+// one that is incompatible with this build. The caller saves the node.
+func (s *nodeService) recordNodeVersion(ctx context.Context, node *db.Node, reported domain.NodeVersion) {
+	if node.Version == reported.Version && node.APIVersion == reported.APIVersion {
+		return
+	}
+	wasCompatible := version.Compatible(node.APIVersion)
+	node.Version = reported.Version
+	node.APIVersion = reported.APIVersion
+
+	if warning := version.SkewWarning(reported.Version, reported.APIVersion); warning != "" {
+		s.logger.WarnContext(ctx, "node version is incompatible with this build; writes to it are blocked",
+			"nodeID", node.ID, "nodeName", node.Name, "version", reported.Version, "api_version", reported.APIVersion, "warning", warning)
+	} else if !wasCompatible {
+		s.logger.InfoContext(ctx, "node version is compatible again", "nodeID", node.ID, "nodeName", node.Name, "version", reported.Version)
+	}
+}
+
+// withLocalVersion fills in this node's own versions, which no heartbeat or health check records
+func (s *nodeService) withLocalVersion(node *db.Node) *db.Node {
+	if node.ID == s.config.Node.ID {
+		node.Version = version.Get()
+		node.APIVersion = version.APIVersion
+	}
+	return node
+}
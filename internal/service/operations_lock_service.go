@@ -0,0 +1,165 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/selfhostly/internal/config"
+	"github.com/selfhostly/internal/constants"
+	"github.com/selfhostly/internal/db"
+	"github.com/selfhostly/internal/domain"
+	"github.com/selfhostly/internal/node"
+)
+
+// maxLockReasonLength bounds the reason shown to everyone whose change is refused
+const maxLockReasonLength = 500
+
+// operationsLockService keeps the admin operations lock. The primary owns it; each secondary
+// keeps a copy so it can refuse changes sent to it directly through the gateway.
+type operationsLockService struct {
+	database   *db.DB
+	nodeClient *node.Client
+	config     *config.Config
+	logger     *slog.Logger
+}
+
+// NewOperationsLockService creates a new operations lock service
+func NewOperationsLockService(database *db.DB, cfg *config.Config, logger *slog.Logger) domain.OperationsLockService {
+	return &operationsLockService{
+		database:   database,
+		nodeClient: node.NewClientWithTimeouts(cfg.Timeouts),
+		config:     cfg,
+		logger:     logger,
+	}
+}
+
+// GetStatus returns whether operations are locked on this node
+func (s *operationsLockService) GetStatus(ctx context.Context) (*domain.OperationsLockStatus, error) {
+	lock, err := s.database.GetOperationsLock()
+	if err != nil {
+		return nil, domain.WrapDatabaseOperation("get operations lock", err)
+	}
+	return &domain.OperationsLockStatus{Locked: lock != nil, Lock: lock}, nil
+}
+
+// Lock blocks app changes on every node until Unlock. Locking again only replaces the reason.
+func (s *operationsLockService) Lock(ctx context.Context, req domain.LockOperationsRequest, lockedBy string) (*domain.OperationsLockStatus, error) {
+	if err := s.requirePrimary(); err != nil {
+		return nil, err
+	}
+	reason := strings.TrimSpace(req.Reason)
+	if reason == "" {
+		return nil, domain.WrapValidationError("reason", fmt.Errorf("reason is required"))
+	}
+	if len(reason) > maxLockReasonLength {
+		return nil, domain.WrapValidationError("reason", fmt.Errorf("reason must be at most %d characters", maxLockReasonLength))
+	}
+
+	lock := &db.OperationsLock{Reason: reason, LockedBy: lockedBy, LockedAt: time.Now()}
+	if err := s.database.SetOperationsLock(lock); err != nil {
+		return nil, domain.WrapDatabaseOperation("lock operations", err)
+	}
+	s.logger.WarnContext(ctx, "operations locked", "reason", reason, "lockedBy", lockedBy)
+
+	status := &domain.OperationsLockStatus{Locked: true, Lock: lock}
+	s.pushToSecondaries(ctx, *status)
+	return status, nil
+}
+
+// Unlock lets app changes through again on every node
+func (s *operationsLockService) Unlock(ctx context.Context, unlockedBy string) (*domain.OperationsLockStatus, error) {
+	if err := s.requirePrimary(); err != nil {
+		return nil, err
+	}
+	if err := s.database.SetOperationsLock(nil); err != nil {
+		return nil, domain.WrapDatabaseOperation("unlock operations", err)
+	}
+	s.logger.WarnContext(ctx, "operations unlocked", "unlockedBy", unlockedBy)
+
+	status := &domain.OperationsLockStatus{Locked: false}
+	s.pushToSecondaries(ctx, *status)
+	return status, nil
+}
+
+// ApplyFromPrimary stores the lock state the primary sent. The primary never takes it from
+// another node, so a secondary's credentials can't lift a lock.
+func (s *operationsLockService) ApplyFromPrimary(ctx context.Context, status domain.OperationsLockStatus) error {
+	if s.config.Node.IsPrimary {
+		return domain.WrapValidationError("operations_lock", fmt.Errorf("the primary's operations lock is changed by an admin, not synced from another node"))
+	}
+
+	current, err := s.database.GetOperationsLock()
+	if err != nil {
+		return domain.WrapDatabaseOperation("get operations lock", err)
+	}
+
+	var lock *db.OperationsLock
+	if status.Locked {
+		lock = status.Lock
+		if lock == nil {
+			lock = &db.OperationsLock{LockedAt: time.Now()}
+		}
+	}
+	if sameOperationsLock(current, lock) {
+		return nil
+	}
+
+	if err := s.database.SetOperationsLock(lock); err != nil {
+		return domain.WrapDatabaseOperation("sync operations lock", err)
+	}
+	if lock != nil {
+		s.logger.WarnContext(ctx, "operations locked by the primary", "reason", lock.Reason, "lockedBy", lock.LockedBy)
+	} else {
+		s.logger.InfoContext(ctx, "operations unlocked by the primary")
+	}
+	return nil
+}
+
+// CheckUnlocked returns an operations locked error while app changes are blocked
+func (s *operationsLockService) CheckUnlocked(ctx context.Context) error {
+	lock, err := s.database.GetOperationsLock()
+	if err != nil {
+		return domain.WrapDatabaseOperation("get operations lock", err)
+	}
+	if lock != nil {
+		return domain.WrapOperationsLocked(lock.Reason)
+	}
+	return nil
+}
+
+// pushToSecondaries sends the lock state to every online secondary. A node that misses it gets
+// it with its next heartbeat reply.
+func (s *operationsLockService) pushToSecondaries(ctx context.Context, status domain.OperationsLockStatus) {
+	nodes, err := s.database.GetAllNodes()
+	if err != nil {
+		s.logger.WarnContext(ctx, "failed to list nodes for the operations lock, secondaries will get it with their next heartbeat", "error", err)
+		return
+	}
+	for _, n := range nodes {
+		if n.ID == s.config.Node.ID || n.Status != constants.NodeStatusOnline {
+			continue
+		}
+		if err := s.nodeClient.SyncOperationsLock(n, status); err != nil {
+			s.logger.WarnContext(ctx, "failed to send operations lock to node, it will get it with its next heartbeat",
+				"nodeID", n.ID, "nodeName", n.Name, "error", err)
+		}
+	}
+}
+
+// sameOperationsLock reports whether a and b are the same lock, or both unlocked
+func sameOperationsLock(a, b *db.OperationsLock) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Reason == b.Reason && a.LockedBy == b.LockedBy && a.LockedAt.Equal(b.LockedAt)
+}
+
+func (s *operationsLockService) requirePrimary() error {
+	if !s.config.Node.IsPrimary {
+		return domain.WrapValidationError("operations_lock", fmt.Errorf("operations are locked and unlocked on the primary node"))
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/selfhostly/internal/config"
+	"github.com/selfhostly/internal/db"
+	"github.com/selfhostly/internal/domain"
+	"github.com/selfhostly/internal/timeouts"
+)
+
+func TestOperationsLockService(t *testing.T) {
+	database, err := db.Init(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to initialize database: %v", err)
+	}
+	defer database.Close()
+
+	cfg := &config.Config{
+		Node:     config.NodeConfig{ID: "primary", IsPrimary: true},
+		Timeouts: timeouts.NewLive(timeouts.Default()),
+	}
+	service := NewOperationsLockService(database, cfg, slog.Default())
+	ctx := context.Background()
+
+	if _, err := service.Lock(ctx, domain.LockOperationsRequest{Reason: "  "}, "alice"); !domain.IsValidationError(err) {
+		t.Errorf("Expected a reason to be required, got %v", err)
+	}
+
+	status, err := service.Lock(ctx, domain.LockOperationsRequest{Reason: "host maintenance"}, "alice")
+	if err != nil {
+		t.Fatalf("Lock: %v", err)
+	}
+	if !status.Locked || status.Lock.Reason != "host maintenance" || status.Lock.LockedBy != "alice" {
+		t.Errorf("Unexpected lock status %+v", status)
+	}
+	if err := service.CheckUnlocked(ctx); !domain.IsOperationsLockedError(err) {
+		t.Errorf("Expected changes to be refused while locked, got %v", err)
+	}
+
+	// The primary doesn't take the lock state from other nodes
+	if err := service.ApplyFromPrimary(ctx, domain.OperationsLockStatus{Locked: false}); !domain.IsValidationError(err) {
+		t.Errorf("Expected the primary to refuse a synced lock, got %v", err)
+	}
+
+	if _, err := service.Unlock(ctx, "alice"); err != nil {
+		t.Fatalf("Unlock: %v", err)
+	}
+	if err := service.CheckUnlocked(ctx); err != nil {
+		t.Errorf("Expected changes to go through once unlocked, got %v", err)
+	}
+}
+
+func TestOperationsLockService_Secondary(t *testing.T) {
+	database, err := db.Init(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to initialize database: %v", err)
+	}
+	defer database.Close()
+
+	cfg := &config.Config{
+		Node:     config.NodeConfig{ID: "edge"},
+		Timeouts: timeouts.NewLive(timeouts.Default()),
+	}
+	service := NewOperationsLockService(database, cfg, slog.Default())
+	ctx := context.Background()
+
+	if _, err := service.Lock(ctx, domain.LockOperationsRequest{Reason: "backup"}, ""); !domain.IsValidationError(err) {
+		t.Errorf("Expected a secondary to refuse locking, got %v", err)
+	}
+
+	lock := &db.OperationsLock{Reason: "backup window", LockedBy: "alice", LockedAt: time.Now()}
+	if err := service.ApplyFromPrimary(ctx, domain.OperationsLockStatus{Locked: true, Lock: lock}); err != nil {
+		t.Fatalf("ApplyFromPrimary: %v", err)
+	}
+	if err := service.CheckUnlocked(ctx); !domain.IsOperationsLockedError(err) {
+		t.Errorf("Expected the primary's lock to apply, got %v", err)
+	}
+
+	if err := service.ApplyFromPrimary(ctx, domain.OperationsLockStatus{Locked: false}); err != nil {
+		t.Fatalf("ApplyFromPrimary: %v", err)
+	}
+	if status, _ := service.GetStatus(ctx); status.Locked {
+		t.Errorf("Expected the lock to be lifted, got %+v", status)
+	}
+}
@@ -0,0 +1,132 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"strings"
+
+	"github.com/selfhostly/internal/config"
+	"github.com/selfhostly/internal/constants"
+	"github.com/selfhostly/internal/db"
+	"github.com/selfhostly/internal/domain"
+	"github.com/selfhostly/internal/portreserve"
+)
+
+// maxPortReservationDescriptionLength bounds what a manual reservation says it is for
+const maxPortReservationDescriptionLength = 200
+
+// portService keeps this node's host port reservations
+type portService struct {
+	database *db.DB
+	config   *config.Config
+	logger   *slog.Logger
+}
+
+// NewPortService creates a new port reservation service
+func NewPortService(database *db.DB, cfg *config.Config, logger *slog.Logger) domain.PortService {
+	return &portService{
+		database: database,
+		config:   cfg,
+		logger:   logger,
+	}
+}
+
+// ListPortReservations returns this node's reservations, ordered by port
+func (s *portService) ListPortReservations(ctx context.Context) ([]*db.PortReservation, error) {
+	reservations, err := s.database.GetPortReservations(s.config.Node.ID)
+	if err != nil {
+		return nil, domain.WrapDatabaseOperation("list port reservations", err)
+	}
+	return reservations, nil
+}
+
+// ReservePort reserves a port by hand, for an app on this node or for nothing selfhostly runs
+func (s *portService) ReservePort(ctx context.Context, req domain.ReservePortRequest) (*db.PortReservation, error) {
+	protocol := strings.ToLower(strings.TrimSpace(req.Protocol))
+	if protocol == "" {
+		protocol = "tcp"
+	}
+	if protocol != "tcp" && protocol != "udp" {
+		return nil, domain.WrapValidationError("protocol", fmt.Errorf("protocol must be tcp or udp"))
+	}
+	if req.Port != 0 && (req.Port < constants.MinPort || req.Port > constants.MaxPort) {
+		return nil, domain.WrapValidationError("port", fmt.Errorf("port must be between %d and %d, or 0 to pick one", constants.MinPort, constants.MaxPort))
+	}
+	description := strings.TrimSpace(req.Description)
+	if len(description) > maxPortReservationDescriptionLength {
+		return nil, domain.WrapValidationError("description", fmt.Errorf("description must be at most %d characters", maxPortReservationDescriptionLength))
+	}
+	if req.AppID != "" {
+		if _, err := s.database.GetApp(req.AppID); err != nil {
+			return nil, domain.WrapAppNotFound(req.AppID, err)
+		}
+	}
+
+	port := req.Port
+	if port == 0 {
+		var err error
+		port, err = portreserve.NextFree(s.database, s.config.Node.ID, protocol, constants.PortReservationAllocateMin, constants.PortReservationAllocateMax)
+		if err != nil {
+			return nil, domain.WrapValidationError("port", err)
+		}
+	}
+
+	reservation := db.NewPortReservation(s.config.Node.ID, port, protocol, req.AppID, constants.PortReservationSourceManual, description)
+	holder, err := s.database.ReservePort(reservation)
+	if err != nil {
+		return nil, domain.WrapDatabaseOperation("reserve port", err)
+	}
+	if holder != nil {
+		return nil, domain.WrapPortReserved(holder.Port, holder.Protocol, portreserve.Describe(s.database, holder))
+	}
+	s.logger.InfoContext(ctx, "port reserved", "port", port, "protocol", protocol, "appID", req.AppID, "description", description)
+	return reservation, nil
+}
+
+// ReleasePort deletes a manual reservation
+func (s *portService) ReleasePort(ctx context.Context, reservationID string) error {
+	reservation, err := s.database.GetPortReservation(reservationID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return domain.WrapPortReservationNotFound(reservationID, err)
+		}
+		return domain.WrapDatabaseOperation("get port reservation", err)
+	}
+	if reservation.Source != constants.PortReservationSourceManual {
+		return domain.WrapValidationError("reservation", fmt.Errorf("port %d/%s is published by its app's compose; remove it there", reservation.Port, reservation.Protocol))
+	}
+	if err := s.database.DeletePortReservation(reservationID); err != nil {
+		if err == sql.ErrNoRows {
+			return domain.WrapPortReservationNotFound(reservationID, err)
+		}
+		return domain.WrapDatabaseOperation("delete port reservation", err)
+	}
+	s.logger.InfoContext(ctx, "port released", "port", reservation.Port, "protocol", reservation.Protocol)
+	return nil
+}
+
+// SyncAppReservations reserves the ports of every app on this node and releases those of apps that
+// are gone. An app whose ports are taken keeps the reservations it had; it is refused when next
+// deployed.
+func (s *portService) SyncAppReservations(ctx context.Context) error {
+	if released, err := s.database.DeleteOrphanedPortReservations(); err != nil {
+		return domain.WrapDatabaseOperation("release orphaned port reservations", err)
+	} else if released > 0 {
+		s.logger.InfoContext(ctx, "released ports of deleted apps", "count", released)
+	}
+	apps, err := s.database.GetAllApps()
+	if err != nil {
+		return domain.WrapDatabaseOperation("get apps", err)
+	}
+	for _, app := range apps {
+		if app.NodeID != "" && app.NodeID != s.config.Node.ID {
+			continue
+		}
+		if err := portreserve.Sync(s.database, s.config.Node.ID, app); err != nil {
+			s.logger.WarnContext(ctx, "failed to reserve app ports", "app", app.Name, "appID", app.ID, "error", err)
+		}
+	}
+	return nil
+}
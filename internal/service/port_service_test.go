@@ -0,0 +1,138 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+
+	"github.com/selfhostly/internal/config"
+	"github.com/selfhostly/internal/constants"
+	"github.com/selfhostly/internal/docker"
+	"github.com/selfhostly/internal/domain"
+)
+
+func TestPortService(t *testing.T) {
+	appSvc, database, cleanup := setupTestAppServiceWithMocks(t, docker.NewMockCommandExecutor())
+	defer cleanup()
+	ctx := context.Background()
+
+	cfg := &config.Config{Node: config.NodeConfig{ID: "test-node-id"}}
+	svc := NewPortService(database, cfg, slog.Default())
+
+	compose := "services:\n  web:\n    image: nginx:latest\n    ports:\n      - \"8080:80\"\n      - \"53:53/udp\"\n"
+	app, err := appSvc.CreateApp(ctx, domain.CreateAppRequest{Name: "blog", ComposeContent: compose})
+	if err != nil {
+		t.Fatalf("CreateApp: %v", err)
+	}
+	reservations, err := svc.ListPortReservations(ctx)
+	if err != nil {
+		t.Fatalf("ListPortReservations: %v", err)
+	}
+	if len(reservations) != 2 || reservations[0].Port != 53 || reservations[0].Protocol != "udp" ||
+		reservations[1].Port != 8080 || reservations[1].Source != constants.PortReservationSourceCompose || *reservations[1].AppID != app.ID {
+		t.Fatalf("Expected the app's ports to be reserved, got %+v", reservations)
+	}
+
+	// Another app can't publish the same port, and nothing of it is left behind
+	if _, err := appSvc.CreateApp(ctx, domain.CreateAppRequest{Name: "shop", ComposeContent: compose}); !domain.IsConflictError(err) {
+		t.Fatalf("Expected a taken port to be refused, got %v", err)
+	}
+	if apps, _ := database.GetAllApps(); len(apps) != 1 {
+		t.Errorf("Expected the refused app not to be created, got %d apps", len(apps))
+	}
+
+	// A manual reservation blocks an app from taking the port
+	manual, err := svc.ReservePort(ctx, domain.ReservePortRequest{Port: 9090, Description: "node exporter"})
+	if err != nil {
+		t.Fatalf("ReservePort: %v", err)
+	}
+	moved := "services:\n  web:\n    image: nginx:latest\n    ports:\n      - \"9090:80\"\n"
+	if _, err := appSvc.UpdateApp(ctx, app.ID, "", domain.UpdateAppRequest{ComposeContent: moved}); !domain.IsConflictError(err) {
+		t.Fatalf("Expected a manually reserved port to be refused, got %v", err)
+	}
+	if _, err := svc.ReservePort(ctx, domain.ReservePortRequest{Port: 8080}); !domain.IsConflictError(err) {
+		t.Errorf("Expected an app's port not to be reservable, got %v", err)
+	}
+
+	// Without a port, the lowest free one of the allocation range is picked
+	first, err := svc.ReservePort(ctx, domain.ReservePortRequest{Protocol: "UDP"})
+	if err != nil {
+		t.Fatalf("ReservePort: %v", err)
+	}
+	second, err := svc.ReservePort(ctx, domain.ReservePortRequest{Protocol: "udp"})
+	if err != nil {
+		t.Fatalf("ReservePort: %v", err)
+	}
+	if first.Port != constants.PortReservationAllocateMin || first.Protocol != "udp" || second.Port != first.Port+1 {
+		t.Errorf("Expected ports %d and %d to be allocated, got %d and %d", constants.PortReservationAllocateMin, constants.PortReservationAllocateMin+1, first.Port, second.Port)
+	}
+	if _, err := svc.ReservePort(ctx, domain.ReservePortRequest{Port: 80, Protocol: "sctp"}); !domain.IsValidationError(err) {
+		t.Errorf("Expected an unknown protocol to be refused, got %v", err)
+	}
+
+	// Only manual reservations are released by hand
+	if err := svc.ReleasePort(ctx, reservations[1].ID); !domain.IsValidationError(err) {
+		t.Errorf("Expected a compose reservation not to be released, got %v", err)
+	}
+	if err := svc.ReleasePort(ctx, manual.ID); err != nil {
+		t.Fatalf("ReleasePort: %v", err)
+	}
+	if err := svc.ReleasePort(ctx, manual.ID); !domain.IsNotFoundError(err) {
+		t.Errorf("Expected a released reservation to be gone, got %v", err)
+	}
+
+	// With the port free the app moves onto it, giving up its old ones
+	if _, err := appSvc.UpdateApp(ctx, app.ID, "", domain.UpdateAppRequest{ComposeContent: moved}); err != nil {
+		t.Fatalf("UpdateApp: %v", err)
+	}
+	reservations, _ = svc.ListPortReservations(ctx)
+	if len(reservations) != 3 || reservations[0].Port != 9090 {
+		t.Errorf("Expected the app to hold only 9090 besides the manual reservations, got %+v", reservations)
+	}
+
+	// Deleting the app releases its ports
+	if err := database.DeleteApp(app.ID); err != nil {
+		t.Fatalf("DeleteApp: %v", err)
+	}
+	reservations, _ = svc.ListPortReservations(ctx)
+	if len(reservations) != 2 {
+		t.Errorf("Expected only the manual reservations to remain, got %+v", reservations)
+	}
+}
+
+func TestPortService_SyncAppReservations(t *testing.T) {
+	appSvc, database, cleanup := setupTestAppServiceWithMocks(t, docker.NewMockCommandExecutor())
+	defer cleanup()
+	ctx := context.Background()
+
+	cfg := &config.Config{Node: config.NodeConfig{ID: "test-node-id"}}
+	svc := NewPortService(database, cfg, slog.Default())
+
+	app, err := appSvc.CreateApp(ctx, domain.CreateAppRequest{Name: "blog", ComposeContent: "services:\n  web:\n    image: nginx:latest\n    ports: [\"8080:80\"]\n"})
+	if err != nil {
+		t.Fatalf("CreateApp: %v", err)
+	}
+	// As for an app deployed before the registry
+	if _, err := database.Exec(`DELETE FROM port_reservations`); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := svc.SyncAppReservations(ctx); err != nil {
+		t.Fatalf("SyncAppReservations: %v", err)
+	}
+	reservations, _ := svc.ListPortReservations(ctx)
+	if len(reservations) != 1 || reservations[0].Port != 8080 || *reservations[0].AppID != app.ID {
+		t.Errorf("Expected the app's port to be reserved, got %+v", reservations)
+	}
+
+	// The ports of an app removed behind the registry's back are released
+	if _, err := database.Exec(`DELETE FROM apps`); err != nil {
+		t.Fatal(err)
+	}
+	if err := svc.SyncAppReservations(ctx); err != nil {
+		t.Fatalf("SyncAppReservations: %v", err)
+	}
+	if reservations, _ := svc.ListPortReservations(ctx); len(reservations) != 0 {
+		t.Errorf("Expected the deleted app's port to be released, got %+v", reservations)
+	}
+}
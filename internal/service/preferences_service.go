@@ -0,0 +1,122 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/selfhostly/internal/constants"
+	"github.com/selfhostly/internal/db"
+	"github.com/selfhostly/internal/domain"
+)
+
+// preferencesService stores each user's UI and CLI preferences
+type preferencesService struct {
+	database *db.DB
+	logger   *slog.Logger
+}
+
+// NewPreferencesService creates a new preferences service
+func NewPreferencesService(database *db.DB, logger *slog.Logger) domain.PreferencesService {
+	return &preferencesService{
+		database: database,
+		logger:   logger,
+	}
+}
+
+// GetPreferences returns a user's preferences, with defaults for a user who hasn't saved any
+func (s *preferencesService) GetPreferences(ctx context.Context, userID string) (*db.UserPreferences, error) {
+	prefs, err := s.database.GetUserPreferences(userID)
+	if err != nil {
+		return nil, domain.WrapDatabaseOperation("get preferences", err)
+	}
+	if prefs == nil {
+		prefs = defaultPreferences(userID)
+	}
+	return prefs, nil
+}
+
+// UpdatePreferences changes the preferences set in req and keeps the rest
+func (s *preferencesService) UpdatePreferences(ctx context.Context, userID string, req domain.UpdatePreferencesRequest) (*db.UserPreferences, error) {
+	prefs, err := s.GetPreferences(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.DefaultNodeID != nil {
+		if *req.DefaultNodeID != "" {
+			if _, err := s.database.GetNode(*req.DefaultNodeID); err != nil {
+				return nil, domain.WrapValidationError("default_node_id", fmt.Errorf("node %s not found", *req.DefaultNodeID))
+			}
+		}
+		prefs.DefaultNodeID = *req.DefaultNodeID
+	}
+	if req.TableColumns != nil {
+		if err := validateTableColumns(*req.TableColumns); err != nil {
+			return nil, domain.WrapValidationError("table_columns", err)
+		}
+		prefs.TableColumns = *req.TableColumns
+		if prefs.TableColumns == nil {
+			prefs.TableColumns = map[string][]string{}
+		}
+	}
+	if req.RefreshInterval != nil {
+		interval := *req.RefreshInterval
+		if interval != 0 && (interval < constants.MinRefreshInterval || interval > constants.MaxRefreshInterval) {
+			return nil, domain.WrapValidationError("refresh_interval", fmt.Errorf("refresh_interval must be 0 (off) or between %d and %d seconds", constants.MinRefreshInterval, constants.MaxRefreshInterval))
+		}
+		prefs.RefreshInterval = interval
+	}
+	if req.Theme != nil {
+		switch *req.Theme {
+		case constants.ThemeSystem, constants.ThemeLight, constants.ThemeDark:
+		default:
+			return nil, domain.WrapValidationError("theme", fmt.Errorf("theme must be %s, %s or %s", constants.ThemeSystem, constants.ThemeLight, constants.ThemeDark))
+		}
+		prefs.Theme = *req.Theme
+	}
+
+	prefs.UpdatedAt = time.Now()
+	if err := s.database.SaveUserPreferences(prefs); err != nil {
+		return nil, domain.WrapDatabaseOperation("save preferences", err)
+	}
+	s.logger.DebugContext(ctx, "preferences updated", "userID", userID)
+	return prefs, nil
+}
+
+func defaultPreferences(userID string) *db.UserPreferences {
+	return &db.UserPreferences{
+		UserID:          userID,
+		TableColumns:    map[string][]string{},
+		RefreshInterval: constants.DefaultRefreshInterval,
+		Theme:           constants.ThemeSystem,
+	}
+}
+
+// validateTableColumns bounds the column layouts a user can save; the keys themselves are the
+// UI's business
+func validateTableColumns(tables map[string][]string) error {
+	if len(tables) > constants.MaxPreferenceTables {
+		return fmt.Errorf("at most %d tables can be saved", constants.MaxPreferenceTables)
+	}
+	for table, columns := range tables {
+		if table == "" || len(table) > 64 {
+			return fmt.Errorf("table names must be 1 to 64 characters")
+		}
+		if len(columns) > constants.MaxPreferenceColumns {
+			return fmt.Errorf("table %s has more than %d columns", table, constants.MaxPreferenceColumns)
+		}
+		seen := make(map[string]bool, len(columns))
+		for _, column := range columns {
+			if column == "" || len(column) > 64 {
+				return fmt.Errorf("column names in table %s must be 1 to 64 characters", table)
+			}
+			if seen[column] {
+				return fmt.Errorf("table %s lists column %s twice", table, column)
+			}
+			seen[column] = true
+		}
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"path/filepath"
+	"testing"
+
+	"github.com/selfhostly/internal/constants"
+	"github.com/selfhostly/internal/db"
+	"github.com/selfhostly/internal/domain"
+)
+
+func TestPreferencesService_Update(t *testing.T) {
+	database, err := db.Init(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to initialize database: %v", err)
+	}
+	defer database.Close()
+
+	service := NewPreferencesService(database, slog.Default())
+	ctx := context.Background()
+
+	prefs, err := service.GetPreferences(ctx, "alice")
+	if err != nil {
+		t.Fatalf("GetPreferences: %v", err)
+	}
+	if prefs.Theme != constants.ThemeSystem || prefs.RefreshInterval != constants.DefaultRefreshInterval {
+		t.Errorf("Expected defaults for a new user, got %+v", prefs)
+	}
+
+	node := db.NewNode("edge", "http://edge:8080", "key", false)
+	if err := database.CreateNode(node); err != nil {
+		t.Fatal(err)
+	}
+	theme, interval := constants.ThemeDark, 0
+	columns := map[string][]string{"apps": {"name", "status"}}
+	if _, err := service.UpdatePreferences(ctx, "alice", domain.UpdatePreferencesRequest{
+		DefaultNodeID:   &node.ID,
+		TableColumns:    &columns,
+		RefreshInterval: &interval,
+		Theme:           &theme,
+	}); err != nil {
+		t.Fatalf("UpdatePreferences: %v", err)
+	}
+
+	// Fields left out keep their saved values
+	light := constants.ThemeLight
+	prefs, err = service.UpdatePreferences(ctx, "alice", domain.UpdatePreferencesRequest{Theme: &light})
+	if err != nil {
+		t.Fatalf("UpdatePreferences: %v", err)
+	}
+	if prefs.Theme != light || prefs.DefaultNodeID != node.ID || prefs.RefreshInterval != 0 || len(prefs.TableColumns["apps"]) != 2 {
+		t.Errorf("Unexpected preferences %+v", prefs)
+	}
+
+	if other, _ := service.GetPreferences(ctx, "bob"); other.Theme != constants.ThemeSystem {
+		t.Errorf("Expected another user's preferences to be separate, got %+v", other)
+	}
+
+	missing, badTheme, tooFast := "missing", "blue", 1
+	duplicate := map[string][]string{"apps": {"name", "name"}}
+	for name, req := range map[string]domain.UpdatePreferencesRequest{
+		"unknown node":     {DefaultNodeID: &missing},
+		"unknown theme":    {Theme: &badTheme},
+		"short interval":   {RefreshInterval: &tooFast},
+		"repeated columns": {TableColumns: &duplicate},
+	} {
+		if _, err := service.UpdatePreferences(ctx, "alice", req); !domain.IsValidationError(err) {
+			t.Errorf("%s: expected a validation error, got %v", name, err)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/selfhostly/internal/constants"
+	"github.com/selfhostly/internal/db"
+	"github.com/selfhostly/internal/domain"
+)
+
+// SyncPrimaryReplica refreshes this secondary's read-only copy of the primary's node list and
+// settings, so the local UI can still show them while the primary is unreachable
+func (s *nodeService) SyncPrimaryReplica(ctx context.Context) error {
+	if s.config.Node.IsPrimary {
+		return fmt.Errorf("primary node does not keep a replica")
+	}
+	if s.config.Node.PrimaryNodeURL == "" {
+		return fmt.Errorf("PRIMARY_NODE_URL not configured")
+	}
+
+	// Authenticate to the primary with this node's own credentials
+	primaryNode := &db.Node{
+		ID:          s.config.Node.ID,
+		APIEndpoint: s.config.Node.PrimaryNodeURL,
+		APIKey:      s.config.Node.APIKey,
+	}
+
+	nodes, err := s.nodeClient.ListNodes(primaryNode)
+	if err != nil {
+		return err
+	}
+	for _, node := range nodes {
+		// The primary doesn't send keys, but the replica must never hold one either way
+		node.APIKey = ""
+	}
+	data, err := json.Marshal(nodes)
+	if err != nil {
+		return fmt.Errorf("failed to encode node replica: %w", err)
+	}
+	if err := s.database.SavePrimaryReplica(constants.PrimaryReplicaNodes, string(data)); err != nil {
+		return domain.WrapDatabaseOperation("save node replica", err)
+	}
+	s.logger.DebugContext(ctx, "synced node replica from primary", "nodes", len(nodes))
+
+	return s.SyncSettingsFromPrimary(ctx)
+}
+
+// ListReplicaNodes returns the replicated node list with this node's own entry taken from the
+// local database, or nil when no replica has been synced yet
+func (s *nodeService) ListReplicaNodes(ctx context.Context) (*domain.ReplicaNodes, error) {
+	replica, err := s.database.GetPrimaryReplica(constants.PrimaryReplicaNodes)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, domain.WrapDatabaseOperation("get node replica", err)
+	}
+
+	var nodes []*db.Node
+	if err := json.Unmarshal([]byte(replica.Data), &nodes); err != nil {
+		return nil, fmt.Errorf("invalid node replica: %w", err)
+	}
+
+	if self, err := s.database.GetNode(s.config.Node.ID); err == nil {
+		self.APIKey = ""
+		for i, node := range nodes {
+			if node.ID == self.ID {
+				nodes[i] = s.withLocalVersion(self)
+			}
+		}
+	}
+
+	return &domain.ReplicaNodes{Nodes: nodes, SyncedAt: replica.SyncedAt}, nil
+}
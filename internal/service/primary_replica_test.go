@@ -0,0 +1,92 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/selfhostly/internal/apipaths"
+	"github.com/selfhostly/internal/config"
+	"github.com/selfhostly/internal/constants"
+	"github.com/selfhostly/internal/db"
+)
+
+func TestPrimaryReplica(t *testing.T) {
+	database, err := db.Init(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to initialize database: %v", err)
+	}
+	defer database.Close()
+
+	self := db.NewNodeWithID("secondary", "secondary", "http://secondary:8080", "secondary-key", false)
+	self.Status = constants.NodeStatusOnline
+	if err := database.CreateNode(self); err != nil {
+		t.Fatalf("CreateNode: %v", err)
+	}
+
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Node-ID") != "secondary" || r.Header.Get("X-Node-API-Key") != "secondary-key" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case apipaths.Nodes:
+			_ = json.NewEncoder(w).Encode([]map[string]interface{}{
+				{"id": "primary", "name": "primary", "is_primary": true, "status": constants.NodeStatusOnline, "api_key": "leaked"},
+				{"id": "secondary", "name": "secondary", "status": constants.NodeStatusOffline},
+			})
+		case apipaths.Settings:
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"auto_start_apps": true})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer primary.Close()
+
+	cfg := &config.Config{Node: config.NodeConfig{ID: "secondary", APIKey: "secondary-key", PrimaryNodeURL: primary.URL}}
+	svc := NewNodeService(database, cfg, slog.Default())
+	ctx := context.Background()
+
+	if replica, err := svc.ListReplicaNodes(ctx); err != nil || replica != nil {
+		t.Fatalf("Expected no replica before the first sync, got %+v (err %v)", replica, err)
+	}
+
+	if err := svc.SyncPrimaryReplica(ctx); err != nil {
+		t.Fatalf("SyncPrimaryReplica: %v", err)
+	}
+
+	replica, err := svc.ListReplicaNodes(ctx)
+	if err != nil || replica == nil {
+		t.Fatalf("ListReplicaNodes: %+v (err %v)", replica, err)
+	}
+	if len(replica.Nodes) != 2 || replica.SyncedAt.IsZero() {
+		t.Fatalf("Expected 2 replicated nodes with a sync time, got %+v", replica)
+	}
+	for _, node := range replica.Nodes {
+		if node.APIKey != "" {
+			t.Errorf("Expected no API key in the replica for %s", node.ID)
+		}
+	}
+	// This node's own entry comes from its local record, not the primary's view
+	if replica.Nodes[1].Status != constants.NodeStatusOnline {
+		t.Errorf("Expected local status for this node, got %s", replica.Nodes[1].Status)
+	}
+
+	settings, err := database.GetSettings()
+	if err != nil || !settings.AutoStartApps {
+		t.Errorf("Expected settings synced from the primary, got %+v (err %v)", settings, err)
+	}
+
+	// The replica survives the primary going away
+	primary.Close()
+	if err := svc.SyncPrimaryReplica(ctx); err == nil {
+		t.Error("Expected sync to fail while the primary is unreachable")
+	}
+	if replica, err := svc.ListReplicaNodes(ctx); err != nil || replica == nil || len(replica.Nodes) != 2 {
+		t.Errorf("Expected the last replica to be kept, got %+v (err %v)", replica, err)
+	}
+}
@@ -0,0 +1,277 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/selfhostly/internal/config"
+	"github.com/selfhostly/internal/constants"
+	"github.com/selfhostly/internal/db"
+	"github.com/selfhostly/internal/docker"
+	"github.com/selfhostly/internal/domain"
+	"github.com/selfhostly/internal/node"
+)
+
+// maxQuotaUserNameLength bounds the user names quotas are kept under
+const maxQuotaUserNameLength = 100
+
+// quotaService keeps per-user app quotas on the primary and counts usage from every node's apps.
+// Secondaries hold no quotas; they ask the primary whether a change fits.
+type quotaService struct {
+	database   *db.DB
+	appService domain.AppService
+	nodeClient *node.Client
+	config     *config.Config
+	logger     *slog.Logger
+}
+
+// NewQuotaService creates a new quota service
+func NewQuotaService(database *db.DB, appService domain.AppService, cfg *config.Config, logger *slog.Logger) domain.QuotaService {
+	return &quotaService{
+		database:   database,
+		appService: appService,
+		nodeClient: node.NewClientWithTimeouts(cfg.Timeouts),
+		config:     cfg,
+		logger:     logger,
+	}
+}
+
+// appQuotaUsage is what a single app counts against its owner's quota
+type appQuotaUsage struct {
+	reservations docker.Reservations
+	tunnel       bool
+}
+
+// ListQuotas returns every user's quota, including the default ("*")
+func (s *quotaService) ListQuotas(ctx context.Context) ([]*db.UserQuota, error) {
+	if err := s.requirePrimary(); err != nil {
+		return nil, err
+	}
+	quotas, err := s.database.GetUserQuotas()
+	if err != nil {
+		return nil, domain.WrapDatabaseOperation("list quotas", err)
+	}
+	return quotas, nil
+}
+
+// SetQuota creates or replaces a user's quota; "*" sets the default
+func (s *quotaService) SetQuota(ctx context.Context, userName string, req domain.SetQuotaRequest) (*db.UserQuota, error) {
+	if err := s.requirePrimary(); err != nil {
+		return nil, err
+	}
+	userName = strings.TrimSpace(userName)
+	if userName == "" || len(userName) > maxQuotaUserNameLength {
+		return nil, domain.WrapValidationError("user", fmt.Errorf("user name must be 1 to %d characters", maxQuotaUserNameLength))
+	}
+	if req.MaxApps < 0 || req.MaxMemoryMB < 0 || req.MaxCPUs < 0 || req.MaxTunnels < 0 {
+		return nil, domain.WrapValidationError("quota", fmt.Errorf("limits can't be negative; use 0 for no limit"))
+	}
+
+	quota := &db.UserQuota{
+		UserName:    userName,
+		MaxApps:     req.MaxApps,
+		MaxMemoryMB: req.MaxMemoryMB,
+		MaxCPUs:     req.MaxCPUs,
+		MaxTunnels:  req.MaxTunnels,
+		UpdatedAt:   time.Now(),
+	}
+	if err := s.database.SaveUserQuota(quota); err != nil {
+		return nil, domain.WrapDatabaseOperation("save quota", err)
+	}
+	s.logger.InfoContext(ctx, "quota set", "user", userName, "maxApps", quota.MaxApps,
+		"maxMemoryMB", quota.MaxMemoryMB, "maxCPUs", quota.MaxCPUs, "maxTunnels", quota.MaxTunnels)
+	return quota, nil
+}
+
+// DeleteQuota removes a user's quota, leaving them on the default
+func (s *quotaService) DeleteQuota(ctx context.Context, userName string) error {
+	if err := s.requirePrimary(); err != nil {
+		return err
+	}
+	if err := s.database.DeleteUserQuota(userName); err != nil {
+		if err == sql.ErrNoRows {
+			return domain.WrapQuotaNotFound(userName, err)
+		}
+		return domain.WrapDatabaseOperation("delete quota", err)
+	}
+	s.logger.InfoContext(ctx, "quota removed", "user", userName)
+	return nil
+}
+
+// GetUsage returns what a user's apps use across the cluster against the quota that applies
+func (s *quotaService) GetUsage(ctx context.Context, userName string) (*domain.QuotaUsage, error) {
+	if err := s.requirePrimary(); err != nil {
+		return nil, err
+	}
+	quota, err := s.quotaFor(userName)
+	if err != nil {
+		return nil, err
+	}
+	usage, _, err := s.usage(ctx, userName, "")
+	if err != nil {
+		return nil, err
+	}
+	usage.Quota = quota
+	return usage, nil
+}
+
+// CheckApp reports whether the change would take the owner over their quota. Apps without an
+// owner, created while auth was off or with node credentials, always fit. A secondary that can't
+// reach the primary refuses the change rather than letting it through unchecked.
+func (s *quotaService) CheckApp(ctx context.Context, req domain.QuotaCheckRequest) (*domain.QuotaCheckResponse, error) {
+	if req.UserName == "" {
+		return &domain.QuotaCheckResponse{}, nil
+	}
+
+	if !s.config.Node.IsPrimary {
+		primaryNode := &db.Node{
+			ID:          s.config.Node.ID,
+			APIEndpoint: s.config.Node.PrimaryNodeURL,
+			APIKey:      s.config.Node.APIKey,
+		}
+		resp, err := s.nodeClient.CheckQuota(primaryNode, req)
+		if err != nil {
+			s.logger.WarnContext(ctx, "failed to check quota with the primary, refusing the change", "user", req.UserName, "error", err)
+			return nil, fmt.Errorf("quota could not be checked with the primary: %w", err)
+		}
+		return resp, nil
+	}
+
+	exceeded, err := s.exceeded(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if exceeded != "" {
+		s.logger.WarnContext(ctx, "app change refused by quota", "user", req.UserName, "appID", req.AppID, "reason", exceeded)
+	}
+	return &domain.QuotaCheckResponse{Exceeded: exceeded}, nil
+}
+
+// enforceQuota refuses an app change that would take owner over their quota. Every path that
+// creates an app, changes its compose or gives it a tunnel goes through here, and it fails closed:
+// compose whose reservations can't be read, or a quota that can't be checked, refuses the change.
+func enforceQuota(ctx context.Context, quotas domain.QuotaService, owner, appID, composeContent, composeOverride string, composeFiles map[string]string, tunnel bool) error {
+	if owner == "" {
+		return nil
+	}
+	reservations, err := docker.ComposeReservations(composeContent, composeOverride, composeFiles)
+	if err != nil {
+		return domain.WrapComposeInvalid(err)
+	}
+	check, err := quotas.CheckApp(ctx, domain.QuotaCheckRequest{
+		UserName: owner,
+		AppID:    appID,
+		MemoryMB: reservations.MemoryMB(),
+		CPUs:     reservations.CPUs,
+		Tunnel:   tunnel,
+	})
+	if err != nil {
+		return err
+	}
+	if check.Exceeded != "" {
+		return domain.WrapQuotaExceeded(owner, check.Exceeded)
+	}
+	return nil
+}
+
+// exceeded returns which limit the change would go over, or "" when it fits. An update is only
+// refused for what it adds, so an owner already over a lowered quota can still shrink an app.
+func (s *quotaService) exceeded(ctx context.Context, req domain.QuotaCheckRequest) (string, error) {
+	quota, err := s.quotaFor(req.UserName)
+	if err != nil || quota == nil {
+		return "", err
+	}
+	usage, previous, err := s.usage(ctx, req.UserName, req.AppID)
+	if err != nil {
+		return "", err
+	}
+
+	updating := previous != nil
+	var previousMB int
+	var previousCPUs float64
+	var previousTunnel bool
+	if updating {
+		previousMB = previous.reservations.MemoryMB()
+		previousCPUs = previous.reservations.CPUs
+		previousTunnel = previous.tunnel
+	}
+
+	if !updating && quota.MaxApps > 0 && usage.Apps+1 > quota.MaxApps {
+		return fmt.Sprintf("%d of %d apps already used", usage.Apps, quota.MaxApps), nil
+	}
+	if memory := usage.MemoryMB + req.MemoryMB; quota.MaxMemoryMB > 0 && memory > quota.MaxMemoryMB && req.MemoryMB > previousMB {
+		return fmt.Sprintf("memory reservations would reach %d MB of %d MB", memory, quota.MaxMemoryMB), nil
+	}
+	if cpus := usage.CPUs + req.CPUs; quota.MaxCPUs > 0 && cpus > quota.MaxCPUs+1e-9 && req.CPUs > previousCPUs {
+		return fmt.Sprintf("CPU reservations would reach %g of %g CPUs", cpus, quota.MaxCPUs), nil
+	}
+	if req.Tunnel && !previousTunnel && quota.MaxTunnels > 0 && usage.Tunnels+1 > quota.MaxTunnels {
+		return fmt.Sprintf("%d of %d tunnels already used", usage.Tunnels, quota.MaxTunnels), nil
+	}
+	return "", nil
+}
+
+// usage counts the user's apps on every node, leaving out excludeAppID, whose own usage is
+// returned separately (nil when the user doesn't own it)
+func (s *quotaService) usage(ctx context.Context, userName, excludeAppID string) (*domain.QuotaUsage, *appQuotaUsage, error) {
+	apps, err := s.appService.ListApps(ctx, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	usage := &domain.QuotaUsage{UserName: userName}
+	var memoryBytes int64
+	var excluded *appQuotaUsage
+	for _, app := range apps {
+		if app.Stale {
+			// Only a summary of apps on unreachable nodes is known, without the owner
+			usage.Incomplete = true
+			continue
+		}
+		if app.Owner != userName {
+			continue
+		}
+		reservations, err := docker.ComposeReservations(app.ComposeContent, app.ComposeOverride, app.ComposeFiles)
+		if err != nil {
+			s.logger.DebugContext(ctx, "failed to read app reservations for quota", "appID", app.ID, "error", err)
+			usage.Incomplete = true
+		}
+		appUsage := &appQuotaUsage{reservations: reservations, tunnel: app.TunnelMode != ""}
+		if app.ID == excludeAppID {
+			excluded = appUsage
+			continue
+		}
+
+		usage.Apps++
+		memoryBytes += reservations.MemoryBytes
+		usage.CPUs += reservations.CPUs
+		if appUsage.tunnel {
+			usage.Tunnels++
+		}
+	}
+	usage.MemoryMB = docker.Reservations{MemoryBytes: memoryBytes}.MemoryMB()
+	return usage, excluded, nil
+}
+
+// quotaFor returns the user's quota, else the default, else nil
+func (s *quotaService) quotaFor(userName string) (*db.UserQuota, error) {
+	quota, err := s.database.GetUserQuota(userName)
+	if err == nil && quota == nil {
+		quota, err = s.database.GetUserQuota(constants.DefaultQuotaUser)
+	}
+	if err != nil {
+		return nil, domain.WrapDatabaseOperation("get quota", err)
+	}
+	return quota, nil
+}
+
+func (s *quotaService) requirePrimary() error {
+	if !s.config.Node.IsPrimary {
+		return domain.WrapValidationError("quota", fmt.Errorf("quotas are kept on the primary node"))
+	}
+	return nil
+}
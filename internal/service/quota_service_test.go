@@ -0,0 +1,131 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+
+	"github.com/selfhostly/internal/config"
+	"github.com/selfhostly/internal/constants"
+	"github.com/selfhostly/internal/docker"
+	"github.com/selfhostly/internal/domain"
+)
+
+func TestQuotaService(t *testing.T) {
+	appSvc, database, cleanup := setupTestAppServiceWithMocks(t, docker.NewMockCommandExecutor())
+	defer cleanup()
+	ctx := context.Background()
+
+	cfg := &config.Config{Node: config.NodeConfig{ID: "test-node-id", IsPrimary: true}}
+	svc := NewQuotaService(database, appSvc, cfg, slog.Default())
+	exceeded := func(req domain.QuotaCheckRequest) string {
+		t.Helper()
+		check, err := svc.CheckApp(ctx, req)
+		if err != nil {
+			t.Fatalf("CheckApp: %v", err)
+		}
+		return check.Exceeded
+	}
+
+	compose := "services:\n  web:\n    image: nginx:latest\n    deploy:\n      resources:\n        reservations:\n          memory: 256M\n          cpus: \"0.5\"\n"
+	app, err := appSvc.CreateApp(ctx, domain.CreateAppRequest{Name: "blog", ComposeContent: compose, Owner: "alice"})
+	if err != nil {
+		t.Fatalf("CreateApp: %v", err)
+	}
+	if _, err := appSvc.CreateApp(ctx, domain.CreateAppRequest{Name: "shared", ComposeContent: compose}); err != nil {
+		t.Fatalf("CreateApp: %v", err)
+	}
+
+	// Without a quota nothing is refused
+	if reason := exceeded(domain.QuotaCheckRequest{UserName: "alice", MemoryMB: 100000}); reason != "" {
+		t.Errorf("Expected no quota to allow anything, got %v", reason)
+	}
+
+	if _, err := svc.SetQuota(ctx, "alice", domain.SetQuotaRequest{MaxApps: 2, MaxMemoryMB: 512, MaxCPUs: 1}); err != nil {
+		t.Fatalf("SetQuota: %v", err)
+	}
+	if _, err := svc.SetQuota(ctx, constants.DefaultQuotaUser, domain.SetQuotaRequest{MaxApps: 1}); err != nil {
+		t.Fatalf("SetQuota default: %v", err)
+	}
+	if _, err := svc.SetQuota(ctx, "bob", domain.SetQuotaRequest{MaxApps: -1}); !domain.IsValidationError(err) {
+		t.Errorf("Expected a negative limit to be refused, got %v", err)
+	}
+
+	usage, err := svc.GetUsage(ctx, "alice")
+	if err != nil {
+		t.Fatalf("GetUsage: %v", err)
+	}
+	if usage.Apps != 1 || usage.MemoryMB != 256 || usage.CPUs != 0.5 || usage.Quota == nil || usage.Quota.MaxApps != 2 {
+		t.Errorf("Unexpected usage %+v", usage)
+	}
+
+	// Another app fits; one reserving too much memory doesn't
+	if reason := exceeded(domain.QuotaCheckRequest{UserName: "alice", MemoryMB: 256, CPUs: 0.5}); reason != "" {
+		t.Errorf("Expected the app to fit the quota, got %v", reason)
+	}
+	if reason := exceeded(domain.QuotaCheckRequest{UserName: "alice", MemoryMB: 300}); reason == "" {
+		t.Error("Expected the memory quota to be exceeded")
+	}
+
+	// Updating replaces the app's own usage rather than adding to it
+	if reason := exceeded(domain.QuotaCheckRequest{UserName: "alice", AppID: app.ID, MemoryMB: 512, CPUs: 1}); reason != "" {
+		t.Errorf("Expected the update to fit the quota, got %v", reason)
+	}
+	if reason := exceeded(domain.QuotaCheckRequest{UserName: "alice", AppID: app.ID, MemoryMB: 513}); reason == "" {
+		t.Error("Expected the update to exceed the quota")
+	}
+
+	// Users without their own quota get the default, which counts their apps only
+	if reason := exceeded(domain.QuotaCheckRequest{UserName: "carol"}); reason != "" {
+		t.Errorf("Expected carol's first app to fit the default quota, got %v", reason)
+	}
+	if _, err := appSvc.CreateApp(ctx, domain.CreateAppRequest{Name: "notes", ComposeContent: "services:\n  web:\n    image: nginx:latest\n", Owner: "carol"}); err != nil {
+		t.Fatalf("CreateApp: %v", err)
+	}
+	if reason := exceeded(domain.QuotaCheckRequest{UserName: "carol"}); reason == "" {
+		t.Error("Expected carol's second app to exceed the default quota")
+	}
+
+	// The app service enforces the quota itself, whichever path the app comes through
+	if _, err := appSvc.CreateApp(ctx, domain.CreateAppRequest{Name: "wiki", ComposeContent: "services:\n  web:\n    image: nginx:latest\n", Owner: "carol"}); !domain.IsQuotaExceededError(err) {
+		t.Errorf("Expected carol's second app to be refused, got %v", err)
+	}
+	if _, err := appSvc.CreateAppAsync(ctx, domain.CreateAppRequest{Name: "wiki", ComposeContent: "services:\n  web:\n    image: nginx:latest\n", Owner: "carol"}); !domain.IsQuotaExceededError(err) {
+		t.Errorf("Expected carol's queued second app to be refused, got %v", err)
+	}
+	if _, err := appSvc.UpdateApp(ctx, app.ID, "test-node-id", domain.UpdateAppRequest{ComposeContent: strings.Replace(compose, "256M", "1G", 1)}); !domain.IsQuotaExceededError(err) {
+		t.Errorf("Expected an update over alice's memory quota to be refused, got %v", err)
+	}
+
+	// Apps without an owner are never refused
+	if reason := exceeded(domain.QuotaCheckRequest{MemoryMB: 100000}); reason != "" {
+		t.Errorf("Expected an app without an owner to be allowed, got %v", reason)
+	}
+
+	if err := svc.DeleteQuota(ctx, "alice"); err != nil {
+		t.Fatalf("DeleteQuota: %v", err)
+	}
+	if err := svc.DeleteQuota(ctx, "alice"); !domain.IsNotFoundError(err) {
+		t.Errorf("Expected deleting a missing quota to be not found, got %v", err)
+	}
+	quotas, err := svc.ListQuotas(ctx)
+	if err != nil || len(quotas) != 1 || quotas[0].UserName != constants.DefaultQuotaUser {
+		t.Errorf("Expected only the default quota to remain, got %+v, %v", quotas, err)
+	}
+}
+
+func TestQuotaService_SecondaryFailsClosed(t *testing.T) {
+	appSvc, database, cleanup := setupTestAppServiceWithMocks(t, docker.NewMockCommandExecutor())
+	defer cleanup()
+
+	// Nothing listens on the primary's address
+	cfg := &config.Config{Node: config.NodeConfig{ID: "secondary-1", PrimaryNodeURL: "http://127.0.0.1:1", APIKey: "key"}}
+	svc := NewQuotaService(database, appSvc, cfg, slog.Default())
+	if _, err := svc.CheckApp(context.Background(), domain.QuotaCheckRequest{UserName: "alice"}); err == nil {
+		t.Error("Expected the change to be refused when the primary can't be asked")
+	}
+	if err := enforceQuota(context.Background(), svc, "alice", "", "services: [", "", nil, false); !domain.IsValidationError(err) {
+		t.Errorf("Expected unreadable compose to be refused, got %v", err)
+	}
+}
@@ -28,6 +28,12 @@ func NewScheduleService(database *db.DB, logger *slog.Logger) domain.ScheduleSer
 
 // ValidateCronExpression validates a cron expression
 func (s *scheduleService) ValidateCronExpression(expression string) error {
+	return validateCronExpression(expression)
+}
+
+// validateCronExpression checks expression parses the way the scheduler parses it: 5 fields,
+// an optional leading seconds field, or a descriptor such as @daily
+func validateCronExpression(expression string) error {
 	if expression == "" {
 		return fmt.Errorf("cron expression cannot be empty")
 	}
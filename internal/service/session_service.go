@@ -0,0 +1,141 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/selfhostly/internal/config"
+	"github.com/selfhostly/internal/constants"
+	"github.com/selfhostly/internal/db"
+	"github.com/selfhostly/internal/domain"
+)
+
+// sessionService keeps track of signed-in devices so they can be listed and revoked, which
+// stateless tokens alone can't do
+type sessionService struct {
+	database *db.DB
+	config   *config.Config
+	logger   *slog.Logger
+}
+
+// NewSessionService creates a new session service
+func NewSessionService(database *db.DB, cfg *config.Config, logger *slog.Logger) domain.SessionService {
+	return &sessionService{
+		database: database,
+		config:   cfg,
+		logger:   logger,
+	}
+}
+
+// Touch records the session a request was made with. Its last-seen time and device are only
+// written once per SessionTouchInterval. A session that can't be read is let through, so a
+// database hiccup doesn't sign everyone out.
+func (s *sessionService) Touch(ctx context.Context, session *db.Session) bool {
+	stored, err := s.database.GetSession(session.ID)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		s.logger.WarnContext(ctx, "failed to get session", "sessionID", session.ID, "error", err)
+		return true
+	}
+	if stored != nil && stored.RevokedAt != nil {
+		return false
+	}
+	if stored != nil && time.Since(stored.LastSeenAt) < constants.SessionTouchInterval && stored.UserAgent == session.UserAgent && stored.IP == session.IP {
+		return true
+	}
+
+	if err := s.database.TouchSession(session); err != nil {
+		s.logger.WarnContext(ctx, "failed to record session", "sessionID", session.ID, "error", err)
+	}
+	return true
+}
+
+// IsRevoked reports whether a session was revoked. A session that can't be read counts as not
+// revoked, as in Touch.
+func (s *sessionService) IsRevoked(ctx context.Context, id string) bool {
+	stored, err := s.database.GetSession(id)
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			s.logger.WarnContext(ctx, "failed to get session", "sessionID", id, "error", err)
+		}
+		return false
+	}
+	return stored.RevokedAt != nil
+}
+
+// ListSessions returns a user's sessions seen within the session lifetime, most recent first,
+// marking the one the request was made with
+func (s *sessionService) ListSessions(ctx context.Context, userName, currentID string) ([]*db.Session, error) {
+	s.prune(ctx)
+
+	sessions, err := s.database.GetUserSessions(userName, time.Now().Add(-constants.SessionLifetime))
+	if err != nil {
+		return nil, domain.WrapDatabaseOperation("get sessions", err)
+	}
+	for _, session := range sessions {
+		session.Current = session.ID == currentID
+	}
+	return sessions, nil
+}
+
+// RevokeSession revokes one of a user's sessions: it is refused on this node at once and on the
+// others with their next heartbeat
+func (s *sessionService) RevokeSession(ctx context.Context, userName, id string) error {
+	if !s.config.Node.IsPrimary {
+		return domain.WrapValidationError("session", fmt.Errorf("sessions are revoked on the primary node"))
+	}
+	if err := s.database.RevokeSession(id, userName, time.Now()); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return domain.WrapSessionNotFound(id, err)
+		}
+		return domain.WrapDatabaseOperation("revoke session", err)
+	}
+	s.logger.InfoContext(ctx, "session revoked", "sessionID", id, "user", userName)
+	return nil
+}
+
+// RevokedSessionIDs returns every session revoked on this node within RevokedSessionRetention
+func (s *sessionService) RevokedSessionIDs(ctx context.Context) ([]string, error) {
+	s.prune(ctx)
+
+	ids, err := s.database.GetRevokedSessionIDs()
+	if err != nil {
+		return nil, domain.WrapDatabaseOperation("get revoked sessions", err)
+	}
+	return ids, nil
+}
+
+// ApplyRevokedFromPrimary refuses the sessions the primary revoked. The primary never takes
+// revocations from another node.
+func (s *sessionService) ApplyRevokedFromPrimary(ctx context.Context, ids []string) error {
+	if s.config.Node.IsPrimary {
+		return domain.WrapValidationError("session", fmt.Errorf("the primary's sessions are revoked by their users, not synced from another node"))
+	}
+	s.prune(ctx)
+
+	marked, err := s.database.MarkSessionsRevoked(ids, time.Now())
+	if err != nil {
+		return domain.WrapDatabaseOperation("sync revoked sessions", err)
+	}
+	if marked > 0 {
+		s.logger.InfoContext(ctx, "sessions revoked by the primary", "count", marked)
+	}
+	return nil
+}
+
+// prune deletes sessions that went unused for the session lifetime, and revoked sessions once
+// RevokedSessionRetention has passed; the tokens of either can't be refreshed any more
+func (s *sessionService) prune(ctx context.Context) {
+	now := time.Now()
+	deleted, err := s.database.DeleteSessionsBefore(now.Add(-constants.SessionLifetime), now.Add(-constants.RevokedSessionRetention))
+	if err != nil {
+		s.logger.WarnContext(ctx, "failed to prune sessions", "error", err)
+		return
+	}
+	if deleted > 0 {
+		s.logger.DebugContext(ctx, "stale sessions pruned", "count", deleted)
+	}
+}
@@ -0,0 +1,132 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/selfhostly/internal/config"
+	"github.com/selfhostly/internal/constants"
+	"github.com/selfhostly/internal/db"
+	"github.com/selfhostly/internal/domain"
+)
+
+func setupTestSessionService(t *testing.T, primary bool) domain.SessionService {
+	database, err := db.Init(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to initialize database: %v", err)
+	}
+	t.Cleanup(func() { database.Close() })
+
+	cfg := &config.Config{}
+	cfg.Node.IsPrimary = primary
+	return NewSessionService(database, cfg, slog.Default())
+}
+
+func TestSessionService_Sessions(t *testing.T) {
+	service := setupTestSessionService(t, true)
+	ctx := context.Background()
+
+	if !service.Touch(ctx, db.NewSession("laptop", "alice", "Firefox", "10.0.0.1")) {
+		t.Fatal("Expected a new session to be accepted")
+	}
+	service.Touch(ctx, db.NewSession("phone", "alice", "Safari", "10.0.0.2"))
+	service.Touch(ctx, db.NewSession("desktop", "bob", "Chrome", "10.0.0.3"))
+
+	// A new device is recorded even within the touch interval
+	service.Touch(ctx, db.NewSession("laptop", "alice", "Firefox", "10.0.0.9"))
+
+	sessions, err := service.ListSessions(ctx, "alice", "laptop")
+	if err != nil {
+		t.Fatalf("ListSessions: %v", err)
+	}
+	if len(sessions) != 2 {
+		t.Fatalf("Expected alice to have 2 sessions, got %d", len(sessions))
+	}
+	for _, session := range sessions {
+		if session.Current != (session.ID == "laptop") {
+			t.Errorf("Unexpected current flag on %+v", session)
+		}
+		if session.ID == "laptop" && session.IP != "10.0.0.9" {
+			t.Errorf("Expected the laptop's new IP to be recorded, got %s", session.IP)
+		}
+	}
+
+	// Users can only revoke their own sessions
+	if err := service.RevokeSession(ctx, "alice", "desktop"); !domain.IsNotFoundError(err) {
+		t.Errorf("Expected another user's session to be not found, got %v", err)
+	}
+	if err := service.RevokeSession(ctx, "alice", "phone"); err != nil {
+		t.Fatalf("RevokeSession: %v", err)
+	}
+	if err := service.RevokeSession(ctx, "alice", "phone"); !domain.IsNotFoundError(err) {
+		t.Errorf("Expected revoking again to be not found, got %v", err)
+	}
+	if service.Touch(ctx, db.NewSession("phone", "alice", "Safari", "10.0.0.2")) {
+		t.Error("Expected a revoked session to be refused")
+	}
+	if sessions, _ := service.ListSessions(ctx, "alice", ""); len(sessions) != 1 {
+		t.Errorf("Expected revoked sessions not to be listed, got %d", len(sessions))
+	}
+
+	ids, err := service.RevokedSessionIDs(ctx)
+	if err != nil || len(ids) != 1 || ids[0] != "phone" {
+		t.Errorf("Unexpected revoked sessions %v, %v", ids, err)
+	}
+	if err := service.ApplyRevokedFromPrimary(ctx, ids); !domain.IsValidationError(err) {
+		t.Errorf("Expected the primary to refuse synced revocations, got %v", err)
+	}
+}
+
+func TestSessionService_ApplyRevokedFromPrimary(t *testing.T) {
+	service := setupTestSessionService(t, false)
+	ctx := context.Background()
+
+	service.Touch(ctx, db.NewSession("laptop", "alice", "Firefox", "10.0.0.1"))
+
+	// Sessions never seen here are refused too, in case they show up later
+	if err := service.ApplyRevokedFromPrimary(ctx, []string{"laptop", "tablet"}); err != nil {
+		t.Fatalf("ApplyRevokedFromPrimary: %v", err)
+	}
+	for _, id := range []string{"laptop", "tablet"} {
+		if service.Touch(ctx, db.NewSession(id, "alice", "Firefox", "10.0.0.1")) {
+			t.Errorf("Expected %s to be refused", id)
+		}
+	}
+	if err := service.RevokeSession(ctx, "alice", "laptop"); !domain.IsValidationError(err) {
+		t.Errorf("Expected sessions to be revoked on the primary only, got %v", err)
+	}
+}
+
+func TestSessionService_PrunesRevokedSessions(t *testing.T) {
+	database, err := db.Init(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to initialize database: %v", err)
+	}
+	t.Cleanup(func() { database.Close() })
+	cfg := &config.Config{}
+	cfg.Node.IsPrimary = true
+	service := NewSessionService(database, cfg, slog.Default())
+	ctx := context.Background()
+
+	// Revoked long enough ago that its tokens can't be refreshed any more
+	if _, err := database.MarkSessionsRevoked([]string{"old"}, time.Now().Add(-constants.RevokedSessionRetention-time.Hour)); err != nil {
+		t.Fatalf("MarkSessionsRevoked: %v", err)
+	}
+	if _, err := database.MarkSessionsRevoked([]string{"recent"}, time.Now()); err != nil {
+		t.Fatalf("MarkSessionsRevoked: %v", err)
+	}
+
+	ids, err := service.RevokedSessionIDs(ctx)
+	if err != nil || len(ids) != 1 || ids[0] != "recent" {
+		t.Errorf("Expected only the recently revoked session, got %v, %v", ids, err)
+	}
+	if !service.IsRevoked(ctx, "recent") {
+		t.Error("Expected the recently revoked session to be revoked")
+	}
+	if service.IsRevoked(ctx, "unknown") {
+		t.Error("Expected an unknown session not to be revoked")
+	}
+}
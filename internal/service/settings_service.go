@@ -0,0 +1,592 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"math"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/selfhostly/internal/constants"
+	"github.com/selfhostly/internal/db"
+	"github.com/selfhostly/internal/docker"
+	"github.com/selfhostly/internal/domain"
+	"github.com/selfhostly/internal/mail"
+)
+
+// settingsSection is one section of the typed settings API: its documented schema, and how its
+// values are read from and written to the settings row
+type settingsSection struct {
+	schema *domain.SettingsSectionSchema
+	read   func(settings *db.Settings) (map[string]interface{}, error)
+	write  func(settings *db.Settings, values map[string]interface{}) error
+}
+
+// settingsService validates and stores settings one section at a time and records each change
+type settingsService struct {
+	database *db.DB
+	logger   *slog.Logger
+	sections []*settingsSection
+}
+
+// NewSettingsService creates a new typed settings service
+func NewSettingsService(database *db.DB, logger *slog.Logger) domain.SettingsService {
+	return &settingsService{
+		database: database,
+		logger:   logger,
+		sections: []*settingsSection{generalSettings(), tunnelProviderSettings(), jobSettings(), alertSettings(), logForwardingSettings(), composePolicySettings(), smtpSettings()},
+	}
+}
+
+// tunnelProviderFields lists the configuration each tunnel provider accepts
+var tunnelProviderFields = map[string][]*domain.SettingsField{
+	constants.ProviderCloudflare: {
+		{Name: "api_token", Type: "string", Description: "API token with Tunnel and DNS edit permissions", Secret: true},
+		{Name: "account_id", Type: "string", Description: "Account that owns the tunnels"},
+	},
+	constants.ProviderNgrok: {
+		{Name: "auth_token", Type: "string", Description: "Agent auth token from the ngrok dashboard", Secret: true},
+		{Name: "domain", Type: "string", Description: "Domain reserved in ngrok with a wildcard; apps are served at <app>.<domain>, or at a random URL without one", Default: ""},
+	},
+	constants.ProviderFrp: {
+		{Name: "server_addr", Type: "string", Description: "Address of your frp server (frps), e.g. vps.example.com"},
+		{Name: "server_port", Type: "int", Description: "The server's bindPort", Default: 7000},
+		{Name: "auth_token", Type: "string", Description: "The server's auth.token, if it sets one", Secret: true, Default: ""},
+		{Name: "domain", Type: "string", Description: "The server's subDomainHost; rules without a hostname are served at <app>.<domain>", Default: ""},
+	},
+}
+
+func generalSettings() *settingsSection {
+	return &settingsSection{
+		schema: &domain.SettingsSectionSchema{
+			Name:        constants.SettingsSectionGeneral,
+			Description: "Platform-wide behaviour",
+			Fields: []*domain.SettingsField{
+				{Name: "auto_start_apps", Type: "bool", Description: "Start new apps as soon as they are created", Default: false},
+				{Name: "telemetry_enabled", Type: "bool", Description: "Send the anonymous daily usage report", Default: false},
+			},
+		},
+		read: func(settings *db.Settings) (map[string]interface{}, error) {
+			return map[string]interface{}{
+				"auto_start_apps":   settings.AutoStartApps,
+				"telemetry_enabled": settings.TelemetryEnabled,
+			}, nil
+		},
+		write: func(settings *db.Settings, values map[string]interface{}) error {
+			settings.AutoStartApps = values["auto_start_apps"].(bool)
+			settings.TelemetryEnabled = values["telemetry_enabled"].(bool)
+			return nil
+		},
+	}
+}
+
+func tunnelProviderSettings() *settingsSection {
+	providerNames := make([]string, 0, len(tunnelProviderFields))
+	for name := range tunnelProviderFields {
+		providerNames = append(providerNames, name)
+	}
+	slices.Sort(providerNames)
+	providerSchemas := make([]*domain.SettingsField, len(providerNames))
+	for i, name := range providerNames {
+		providerSchemas[i] = &domain.SettingsField{Name: name, Type: "object", Description: fmt.Sprintf("%s credentials", name), Fields: tunnelProviderFields[name]}
+	}
+
+	return &settingsSection{
+		schema: &domain.SettingsSectionSchema{
+			Name:        constants.SettingsSectionTunnelProviders,
+			Description: "Tunnel provider credentials and which provider new tunnels use",
+			Fields: []*domain.SettingsField{
+				{Name: "active_provider", Type: "string", Description: "Provider used for new tunnels", Default: constants.DefaultProviderName, Enum: providerNames},
+				{Name: "providers", Type: "object", Description: "Configuration per provider, keyed by provider name", Fields: providerSchemas},
+			},
+		},
+		read: func(settings *db.Settings) (map[string]interface{}, error) {
+			providers := map[string]interface{}{}
+			if settings.TunnelProviderConfig != nil && *settings.TunnelProviderConfig != "" {
+				if err := json.Unmarshal([]byte(*settings.TunnelProviderConfig), &providers); err != nil {
+					return nil, fmt.Errorf("invalid tunnel provider config in settings: %w", err)
+				}
+			}
+			return map[string]interface{}{
+				"active_provider": settings.GetActiveProviderName(),
+				"providers":       providers,
+			}, nil
+		},
+		write: func(settings *db.Settings, values map[string]interface{}) error {
+			active := values["active_provider"].(string)
+			providers := values["providers"].(map[string]interface{})
+			config, _ := providers[active].(map[string]interface{})
+			for _, field := range tunnelProviderFields[active] {
+				if value, _ := config[field.Name].(string); value == "" && field.Default == nil {
+					return domain.WrapValidationError("providers", fmt.Errorf("%s.%s is required while %s is the active provider", active, field.Name, active))
+				}
+			}
+
+			encoded, err := json.Marshal(providers)
+			if err != nil {
+				return err
+			}
+			providerConfig := string(encoded)
+			settings.ActiveTunnelProvider = &active
+			settings.TunnelProviderConfig = &providerConfig
+			return nil
+		},
+	}
+}
+
+func jobSettings() *settingsSection {
+	minKeep, maxKeep := 1, 1000
+	minStale, maxStale := 5, 24*60
+	return &settingsSection{
+		schema: &domain.SettingsSectionSchema{
+			Name:        constants.SettingsSectionJobs,
+			Description: "Background job queue; secondary nodes pick changes up when they sync settings from the primary",
+			Fields: []*domain.SettingsField{
+				{Name: "history_keep_count", Type: "int", Description: "Finished jobs kept per app; older ones are pruned hourly", Default: constants.JobHistoryKeepCount, Min: &minKeep, Max: &maxKeep},
+				{Name: "stale_threshold_minutes", Type: "int", Description: "Running jobs older than this are failed when the node starts", Default: int(constants.JobStaleThreshold.Minutes()), Min: &minStale, Max: &maxStale},
+			},
+		},
+		read: func(settings *db.Settings) (map[string]interface{}, error) {
+			return map[string]interface{}{
+				"history_keep_count":      settings.JobHistoryKeepCount,
+				"stale_threshold_minutes": settings.JobStaleThresholdMinutes,
+			}, nil
+		},
+		write: func(settings *db.Settings, values map[string]interface{}) error {
+			settings.JobHistoryKeepCount = values["history_keep_count"].(int)
+			settings.JobStaleThresholdMinutes = values["stale_threshold_minutes"].(int)
+			return nil
+		},
+	}
+}
+
+func alertSettings() *settingsSection {
+	minPercent, maxPercent := 0, 100
+	minLoad, maxLoad := 0, 10000
+	minTemp, maxTemp := 0, 150
+	minSustained, maxSustained := 0, 24*60
+	return &settingsSection{
+		schema: &domain.SettingsSectionSchema{
+			Name:        constants.SettingsSectionAlerts,
+			Description: "Node metric thresholds checked by the primary every minute; 0 disables a threshold",
+			Fields: []*domain.SettingsField{
+				{Name: "disk_percent", Type: "int", Description: "Alert when a node's data disk is fuller than this", Default: constants.DefaultAlertDiskPercent, Min: &minPercent, Max: &maxPercent},
+				{Name: "memory_percent", Type: "int", Description: "Alert when a node's memory use stays above this", Default: constants.DefaultAlertMemoryPercent, Min: &minPercent, Max: &maxPercent},
+				{Name: "load_percent", Type: "int", Description: "Alert when a node's 5-minute load average stays above this percent of its CPU cores", Default: constants.DefaultAlertLoadPercent, Min: &minLoad, Max: &maxLoad},
+				{Name: "temperature_celsius", Type: "int", Description: "Alert when a node's hottest sensor stays above this; ignored on nodes without sensors", Default: constants.DefaultAlertTemperatureCelsius, Min: &minTemp, Max: &maxTemp},
+				{Name: "sustained_minutes", Type: "int", Description: "How long memory, load and temperature must stay over their threshold before alerting", Default: constants.DefaultAlertSustainedMinutes, Min: &minSustained, Max: &maxSustained},
+				{Name: "webhook_url", Type: "string", Description: "URL alerts are posted to as they fire and resolve; alerts are only logged when empty", Default: ""},
+				{Name: "webhook_secret", Type: "string", Description: "Signs alert deliveries like app webhooks; unsigned when empty", Default: "", Secret: true},
+			},
+		},
+		read: func(settings *db.Settings) (map[string]interface{}, error) {
+			return map[string]interface{}{
+				"disk_percent":        settings.AlertDiskPercent,
+				"memory_percent":      settings.AlertMemoryPercent,
+				"load_percent":        settings.AlertLoadPercent,
+				"temperature_celsius": settings.AlertTemperatureCelsius,
+				"sustained_minutes":   settings.AlertSustainedMinutes,
+				"webhook_url":         settings.AlertWebhookURL,
+				"webhook_secret":      settings.AlertWebhookSecret,
+			}, nil
+		},
+		write: func(settings *db.Settings, values map[string]interface{}) error {
+			webhookURL := values["webhook_url"].(string)
+			if webhookURL != "" {
+				if err := validateWebhookURL("webhook_url", webhookURL); err != nil {
+					return err
+				}
+			}
+			settings.AlertDiskPercent = values["disk_percent"].(int)
+			settings.AlertMemoryPercent = values["memory_percent"].(int)
+			settings.AlertLoadPercent = values["load_percent"].(int)
+			settings.AlertTemperatureCelsius = values["temperature_celsius"].(int)
+			settings.AlertSustainedMinutes = values["sustained_minutes"].(int)
+			settings.AlertWebhookURL = webhookURL
+			settings.AlertWebhookSecret = values["webhook_secret"].(string)
+			return nil
+		},
+	}
+}
+
+func logForwardingSettings() *settingsSection {
+	return &settingsSection{
+		schema: &domain.SettingsSectionSchema{
+			Name:        constants.SettingsSectionLogForwarding,
+			Description: "Where apps with log forwarding on ship their container logs; secondary nodes pick changes up when they sync settings from the primary",
+			Fields: []*domain.SettingsField{
+				{Name: "destination", Type: "string", Description: "Log store to ship to; forwarding is off when empty", Default: "", Enum: []string{"", constants.LogForwardingLoki, constants.LogForwardingElasticsearch}},
+				{Name: "url", Type: "string", Description: "Loki server (e.g. http://loki:3100) or Elasticsearch endpoint, as reached from the app's node", Default: ""},
+				{Name: "username", Type: "string", Description: "Basic auth user; no auth when empty", Default: ""},
+				{Name: "password", Type: "string", Description: "Basic auth password", Default: "", Secret: true},
+				{Name: "index", Type: "string", Description: "Elasticsearch index, with strftime specifiers for dates", Default: constants.DefaultLogForwardingIndex},
+			},
+		},
+		read: func(settings *db.Settings) (map[string]interface{}, error) {
+			index := settings.LogForwardingIndex
+			if index == "" {
+				index = constants.DefaultLogForwardingIndex
+			}
+			return map[string]interface{}{
+				"destination": settings.LogForwardingDestination,
+				"url":         settings.LogForwardingURL,
+				"username":    settings.LogForwardingUsername,
+				"password":    settings.LogForwardingPassword,
+				"index":       index,
+			}, nil
+		},
+		write: func(settings *db.Settings, values map[string]interface{}) error {
+			destination := values["destination"].(string)
+			rawURL := values["url"].(string)
+			if destination != "" {
+				if err := docker.ValidateLogForwarding(destination, rawURL); err != nil {
+					return domain.WrapValidationError("url", err)
+				}
+			}
+			settings.LogForwardingDestination = destination
+			settings.LogForwardingURL = rawURL
+			settings.LogForwardingUsername = values["username"].(string)
+			settings.LogForwardingPassword = values["password"].(string)
+			settings.LogForwardingIndex = values["index"].(string)
+			return nil
+		},
+	}
+}
+
+func composePolicySettings() *settingsSection {
+	modes := []string{constants.ComposePolicyBlock, constants.ComposePolicyApproval, constants.ComposePolicyAllow}
+	policy := func(value string) string {
+		if value == "" {
+			return constants.ComposePolicyBlock
+		}
+		return value
+	}
+	return &settingsSection{
+		schema: &domain.SettingsSectionSchema{
+			Name:        constants.SettingsSectionComposePolicies,
+			Description: "Whether privileged settings in user compose files are blocked, need an admin to approve the change (approve_policies) or are allowed",
+			Fields: []*domain.SettingsField{
+				{Name: constants.ComposePolicyPrivileged, Type: "string", Description: "privileged: true", Default: constants.ComposePolicyBlock, Enum: modes},
+				{Name: constants.ComposePolicyHostNetwork, Type: "string", Description: "network_mode: host", Default: constants.ComposePolicyBlock, Enum: modes},
+				{Name: constants.ComposePolicyCapAdd, Type: "string", Description: "cap_add with SYS_ADMIN or another dangerous capability", Default: constants.ComposePolicyBlock, Enum: modes},
+				{Name: constants.ComposePolicyDevices, Type: "string", Description: "devices mappings", Default: constants.ComposePolicyBlock, Enum: modes},
+			},
+		},
+		read: func(settings *db.Settings) (map[string]interface{}, error) {
+			return map[string]interface{}{
+				constants.ComposePolicyPrivileged:  policy(settings.ComposePolicyPrivileged),
+				constants.ComposePolicyHostNetwork: policy(settings.ComposePolicyHostNetwork),
+				constants.ComposePolicyCapAdd:      policy(settings.ComposePolicyCapAdd),
+				constants.ComposePolicyDevices:     policy(settings.ComposePolicyDevices),
+			}, nil
+		},
+		write: func(settings *db.Settings, values map[string]interface{}) error {
+			settings.ComposePolicyPrivileged = values[constants.ComposePolicyPrivileged].(string)
+			settings.ComposePolicyHostNetwork = values[constants.ComposePolicyHostNetwork].(string)
+			settings.ComposePolicyCapAdd = values[constants.ComposePolicyCapAdd].(string)
+			settings.ComposePolicyDevices = values[constants.ComposePolicyDevices].(string)
+			return nil
+		},
+	}
+}
+
+func smtpSettings() *settingsSection {
+	minPort, maxPort := 1, 65535
+	return &settingsSection{
+		schema: &domain.SettingsSectionSchema{
+			Name:        constants.SettingsSectionSMTP,
+			Description: "SMTP server the primary sends email through; check it with POST /api/settings/smtp/test",
+			Fields: []*domain.SettingsField{
+				{Name: "host", Type: "string", Description: "SMTP server; email is off when empty", Default: ""},
+				{Name: "port", Type: "int", Description: "587 for STARTTLS, 465 for TLS", Default: constants.DefaultSMTPPort, Min: &minPort, Max: &maxPort},
+				{Name: "security", Type: "string", Description: "How the connection is secured", Default: constants.SMTPSecurityStartTLS, Enum: []string{constants.SMTPSecurityStartTLS, constants.SMTPSecurityTLS, constants.SMTPSecurityNone}},
+				{Name: "username", Type: "string", Description: "Login; no authentication when empty", Default: ""},
+				{Name: "password", Type: "string", Description: "Password or app token", Default: "", Secret: true},
+				{Name: "from", Type: "string", Description: "Sender address, e.g. Selfhostly <noreply@example.com>", Default: ""},
+			},
+		},
+		read: func(settings *db.Settings) (map[string]interface{}, error) {
+			return map[string]interface{}{
+				"host":     settings.SMTPHost,
+				"port":     settings.SMTPPort,
+				"security": settings.SMTPSecurity,
+				"username": settings.SMTPUsername,
+				"password": settings.SMTPPassword,
+				"from":     settings.SMTPFrom,
+			}, nil
+		},
+		write: func(settings *db.Settings, values map[string]interface{}) error {
+			cfg := mail.Config{
+				Host:     values["host"].(string),
+				Port:     values["port"].(int),
+				Security: values["security"].(string),
+				Username: values["username"].(string),
+				Password: values["password"].(string),
+				From:     values["from"].(string),
+			}
+			if cfg.Host != "" {
+				if err := cfg.Validate(); err != nil {
+					return domain.WrapValidationError("smtp", err)
+				}
+			}
+			settings.SMTPHost = cfg.Host
+			settings.SMTPPort = cfg.Port
+			settings.SMTPSecurity = cfg.Security
+			settings.SMTPUsername = cfg.Username
+			settings.SMTPPassword = cfg.Password
+			settings.SMTPFrom = cfg.From
+			return nil
+		},
+	}
+}
+
+// SendTestEmail sends a test message through the saved SMTP settings, reporting why it failed
+func (s *settingsService) SendTestEmail(ctx context.Context, to string) error {
+	settings, err := s.database.GetSettings()
+	if err != nil {
+		return domain.WrapDatabaseOperation("get settings", err)
+	}
+	cfg := mail.ConfigFromSettings(settings)
+	if !cfg.Configured() {
+		return domain.WrapValidationError("smtp", fmt.Errorf("set host and from in the smtp settings first"))
+	}
+
+	err = mail.Send(ctx, cfg, mail.Message{
+		To:      []string{to},
+		Subject: "Selfhostly test email",
+		Body:    fmt.Sprintf("This is a test email from Selfhostly, sent through %s:%d.\n\nIf you can read it, email is set up.", cfg.Host, cfg.Port),
+	})
+	if err != nil {
+		s.logger.WarnContext(ctx, "test email failed", "host", cfg.Host, "port", cfg.Port, "to", to, "error", err)
+		return domain.WrapValidationError("smtp", err)
+	}
+	s.logger.InfoContext(ctx, "test email sent", "host", cfg.Host, "to", to)
+	return nil
+}
+
+// ListSettingsSchema documents every settings section
+func (s *settingsService) ListSettingsSchema(ctx context.Context) []*domain.SettingsSectionSchema {
+	schemas := make([]*domain.SettingsSectionSchema, len(s.sections))
+	for i, section := range s.sections {
+		schemas[i] = section.schema
+	}
+	return schemas
+}
+
+// GetSettingsSection returns a section's current values with secrets masked
+func (s *settingsService) GetSettingsSection(ctx context.Context, name string) (*domain.SettingsSection, error) {
+	section, err := s.section(name)
+	if err != nil {
+		return nil, err
+	}
+	settings, err := s.database.GetSettings()
+	if err != nil {
+		return nil, domain.WrapDatabaseOperation("get settings", err)
+	}
+	values, err := section.read(settings)
+	if err != nil {
+		return nil, err
+	}
+	return &domain.SettingsSection{Section: name, Values: maskSettings(section.schema.Fields, values), UpdatedAt: settings.UpdatedAt}, nil
+}
+
+// UpdateSettingsSection validates values against the section's schema and saves them. Fields
+// left out keep their value, as do secrets sent back in their masked form. Each update that
+// changes something is recorded in the section's history.
+func (s *settingsService) UpdateSettingsSection(ctx context.Context, name string, values map[string]interface{}, changedBy string) (*domain.SettingsSection, error) {
+	section, err := s.section(name)
+	if err != nil {
+		return nil, err
+	}
+	settings, err := s.database.GetSettings()
+	if err != nil {
+		return nil, domain.WrapDatabaseOperation("get settings", err)
+	}
+	current, err := section.read(settings)
+	if err != nil {
+		return nil, err
+	}
+
+	merged := make(map[string]interface{}, len(current))
+	for k, v := range current {
+		merged[k] = v
+	}
+	for key, raw := range values {
+		field := findSettingsField(section.schema.Fields, key)
+		if field == nil {
+			return nil, domain.WrapValidationError(key, fmt.Errorf("unknown setting in section %s; expected one of %s", name, strings.Join(settingsFieldNames(section.schema.Fields), ", ")))
+		}
+		value, err := validateSettingsValue(field, raw, current[key])
+		if err != nil {
+			return nil, domain.WrapValidationError(key, err)
+		}
+		merged[key] = value
+	}
+	if err := section.write(settings, merged); err != nil {
+		return nil, err
+	}
+
+	var changes []db.SettingsFieldChange
+	for _, field := range section.schema.Fields {
+		if !reflect.DeepEqual(current[field.Name], merged[field.Name]) {
+			changes = append(changes, db.SettingsFieldChange{
+				Field: field.Name,
+				Old:   maskSettingsValue(field, current[field.Name]),
+				New:   maskSettingsValue(field, merged[field.Name]),
+			})
+		}
+	}
+	if len(changes) > 0 {
+		if err := s.database.UpdateSettings(settings); err != nil {
+			return nil, domain.WrapDatabaseOperation("update settings", err)
+		}
+		if err := s.database.CreateSettingsChange(db.NewSettingsChange(name, changedBy, changes)); err != nil {
+			s.logger.WarnContext(ctx, "settings updated but the change was not recorded", "section", name, "error", err)
+		}
+		s.logger.InfoContext(ctx, "settings section updated", "section", name, "changes", len(changes), "changedBy", changedBy)
+	}
+
+	return s.GetSettingsSection(ctx, name)
+}
+
+// ListSettingsHistory returns a section's most recent changes, newest first
+func (s *settingsService) ListSettingsHistory(ctx context.Context, name string) ([]*db.SettingsChange, error) {
+	if _, err := s.section(name); err != nil {
+		return nil, err
+	}
+	history, err := s.database.GetSettingsHistory(name, constants.SettingsHistoryLimit)
+	if err != nil {
+		return nil, domain.WrapDatabaseOperation("get settings history", err)
+	}
+	return history, nil
+}
+
+func (s *settingsService) section(name string) (*settingsSection, error) {
+	for _, section := range s.sections {
+		if section.schema.Name == name {
+			return section, nil
+		}
+	}
+	return nil, domain.WrapSettingsSectionNotFound(name)
+}
+
+func findSettingsField(fields []*domain.SettingsField, name string) *domain.SettingsField {
+	for _, f := range fields {
+		if f.Name == name {
+			return f
+		}
+	}
+	return nil
+}
+
+func settingsFieldNames(fields []*domain.SettingsField) []string {
+	names := make([]string, len(fields))
+	for i, f := range fields {
+		names[i] = f.Name
+	}
+	return names
+}
+
+// validateSettingsValue checks a value decoded from JSON against its field and converts it to the
+// type the section stores. current is the stored value, used to keep secrets sent back masked.
+func validateSettingsValue(field *domain.SettingsField, raw, current interface{}) (interface{}, error) {
+	switch field.Type {
+	case "bool":
+		b, ok := raw.(bool)
+		if !ok {
+			return nil, fmt.Errorf("must be true or false")
+		}
+		return b, nil
+
+	case "int":
+		f, ok := raw.(float64)
+		if !ok || f != math.Trunc(f) {
+			return nil, fmt.Errorf("must be a whole number")
+		}
+		n := int(f)
+		if (field.Min != nil && n < *field.Min) || (field.Max != nil && n > *field.Max) {
+			return nil, fmt.Errorf("must be between %d and %d", *field.Min, *field.Max)
+		}
+		return n, nil
+
+	case "string":
+		str, ok := raw.(string)
+		if !ok {
+			return nil, fmt.Errorf("must be a string")
+		}
+		str = strings.TrimSpace(str)
+		if field.Secret && isMaskedSecret(str) {
+			currentStr, _ := current.(string)
+			return currentStr, nil
+		}
+		if len(field.Enum) > 0 && !slices.Contains(field.Enum, str) {
+			return nil, fmt.Errorf("must be one of %s", strings.Join(field.Enum, ", "))
+		}
+		return str, nil
+
+	case "object":
+		obj, ok := raw.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("must be an object")
+		}
+		currentObj, _ := current.(map[string]interface{})
+		result := make(map[string]interface{}, len(currentObj))
+		for k, v := range currentObj {
+			result[k] = v
+		}
+		for key, value := range obj {
+			member := findSettingsField(field.Fields, key)
+			if member == nil {
+				return nil, fmt.Errorf("unknown key %q; expected one of %s", key, strings.Join(settingsFieldNames(field.Fields), ", "))
+			}
+			validated, err := validateSettingsValue(member, value, currentObj[key])
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", key, err)
+			}
+			result[key] = validated
+		}
+		return result, nil
+
+	default:
+		return nil, fmt.Errorf("unsupported setting type %s", field.Type)
+	}
+}
+
+// isMaskedSecret reports whether a secret is the masked form returned by the API
+func isMaskedSecret(value string) bool {
+	return strings.Contains(value, "****")
+}
+
+// maskSettings returns a copy of values with every secret field masked
+func maskSettings(fields []*domain.SettingsField, values map[string]interface{}) map[string]interface{} {
+	masked := make(map[string]interface{}, len(values))
+	for k, v := range values {
+		if field := findSettingsField(fields, k); field != nil {
+			v = maskSettingsValue(field, v)
+		}
+		masked[k] = v
+	}
+	return masked
+}
+
+func maskSettingsValue(field *domain.SettingsField, value interface{}) interface{} {
+	switch {
+	case field.Secret:
+		secret, _ := value.(string)
+		if secret == "" {
+			return ""
+		}
+		if len(secret) <= 8 {
+			return "********"
+		}
+		return secret[:4] + "****" + secret[len(secret)-4:]
+	case field.Type == "object":
+		if obj, ok := value.(map[string]interface{}); ok {
+			return maskSettings(field.Fields, obj)
+		}
+	}
+	return value
+}
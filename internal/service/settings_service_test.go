@@ -0,0 +1,147 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"path/filepath"
+	"testing"
+
+	"github.com/selfhostly/internal/constants"
+	"github.com/selfhostly/internal/db"
+	"github.com/selfhostly/internal/domain"
+)
+
+func TestSettingsService(t *testing.T) {
+	database, err := db.Init(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to initialize database: %v", err)
+	}
+	defer database.Close()
+
+	svc := NewSettingsService(database, slog.Default())
+	ctx := context.Background()
+
+	jobs, err := svc.GetSettingsSection(ctx, constants.SettingsSectionJobs)
+	if err != nil {
+		t.Fatalf("GetSettingsSection: %v", err)
+	}
+	if jobs.Values["history_keep_count"] != constants.JobHistoryKeepCount {
+		t.Errorf("Expected default history_keep_count %d, got %v", constants.JobHistoryKeepCount, jobs.Values["history_keep_count"])
+	}
+
+	// Values arrive decoded from JSON, so numbers are float64
+	jobs, err = svc.UpdateSettingsSection(ctx, constants.SettingsSectionJobs, map[string]interface{}{"history_keep_count": float64(50)}, "admin")
+	if err != nil {
+		t.Fatalf("UpdateSettingsSection: %v", err)
+	}
+	if jobs.Values["history_keep_count"] != 50 || jobs.Values["stale_threshold_minutes"] != 30 {
+		t.Errorf("Unexpected jobs section %+v", jobs.Values)
+	}
+
+	invalid := []struct {
+		name    string
+		section string
+		values  map[string]interface{}
+	}{
+		{"below minimum", constants.SettingsSectionJobs, map[string]interface{}{"history_keep_count": float64(0)}},
+		{"fractional int", constants.SettingsSectionJobs, map[string]interface{}{"stale_threshold_minutes": 12.5}},
+		{"wrong type", constants.SettingsSectionGeneral, map[string]interface{}{"auto_start_apps": "yes"}},
+		{"unknown field", constants.SettingsSectionGeneral, map[string]interface{}{"dark_mode": true}},
+		{"unknown provider", constants.SettingsSectionTunnelProviders, map[string]interface{}{"active_provider": "wormhole"}},
+		{"relative alerts webhook", constants.SettingsSectionAlerts, map[string]interface{}{"webhook_url": "/alerts"}},
+		{"disk threshold over 100", constants.SettingsSectionAlerts, map[string]interface{}{"disk_percent": float64(120)}},
+		{"missing credentials", constants.SettingsSectionTunnelProviders, map[string]interface{}{"providers": map[string]interface{}{"cloudflare": map[string]interface{}{"account_id": "acc"}}}},
+	}
+	for _, tt := range invalid {
+		if _, err := svc.UpdateSettingsSection(ctx, tt.section, tt.values, "admin"); !domain.IsValidationError(err) {
+			t.Errorf("%s: expected validation error, got %v", tt.name, err)
+		}
+	}
+	if _, err := svc.GetSettingsSection(ctx, "backups"); !domain.IsNotFoundError(err) {
+		t.Errorf("Expected not found error for unknown section, got %v", err)
+	}
+
+	// Secrets are masked on the way out and kept when sent back masked
+	cloudflare := map[string]interface{}{"api_token": "token-1234567890", "account_id": "acc"}
+	providers, err := svc.UpdateSettingsSection(ctx, constants.SettingsSectionTunnelProviders, map[string]interface{}{"providers": map[string]interface{}{"cloudflare": cloudflare}}, "admin")
+	if err != nil {
+		t.Fatalf("UpdateSettingsSection providers: %v", err)
+	}
+	masked := providers.Values["providers"].(map[string]interface{})["cloudflare"].(map[string]interface{})
+	if masked["api_token"] != "toke****7890" {
+		t.Errorf("Expected masked token, got %v", masked["api_token"])
+	}
+	masked["account_id"] = "acc-2"
+	if _, err := svc.UpdateSettingsSection(ctx, constants.SettingsSectionTunnelProviders, map[string]interface{}{"providers": map[string]interface{}{"cloudflare": masked}}, "admin"); err != nil {
+		t.Fatalf("UpdateSettingsSection with masked token: %v", err)
+	}
+	settings, err := database.GetSettings()
+	if err != nil {
+		t.Fatalf("GetSettings: %v", err)
+	}
+	config, err := settings.GetProviderConfig(constants.ProviderCloudflare)
+	if err != nil || config["api_token"] != "token-1234567890" || config["account_id"] != "acc-2" {
+		t.Errorf("Expected stored token to be kept and account updated, got %v (err %v)", config, err)
+	}
+
+	history, err := svc.ListSettingsHistory(ctx, constants.SettingsSectionTunnelProviders)
+	if err != nil {
+		t.Fatalf("ListSettingsHistory: %v", err)
+	}
+	if len(history) != 2 || history[0].ChangedBy != "admin" {
+		t.Fatalf("Expected 2 recorded changes by admin, got %+v", history)
+	}
+	for _, change := range history {
+		for _, c := range change.Changes {
+			if c.Field == "providers" {
+				if token := c.New.(map[string]interface{})["cloudflare"].(map[string]interface{})["api_token"]; token != "toke****7890" {
+					t.Errorf("Expected history to mask the token, got %v", token)
+				}
+			}
+		}
+	}
+
+	// Saving the same values again records nothing
+	if _, err := svc.UpdateSettingsSection(ctx, constants.SettingsSectionJobs, map[string]interface{}{"history_keep_count": float64(50)}, "admin"); err != nil {
+		t.Fatalf("UpdateSettingsSection: %v", err)
+	}
+	if history, _ := svc.ListSettingsHistory(ctx, constants.SettingsSectionJobs); len(history) != 1 {
+		t.Errorf("Expected 1 jobs change, got %d", len(history))
+	}
+}
+
+func TestSettingsService_SMTP(t *testing.T) {
+	database, err := db.Init(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to initialize database: %v", err)
+	}
+	defer database.Close()
+
+	svc := NewSettingsService(database, slog.Default())
+	ctx := context.Background()
+
+	if err := svc.SendTestEmail(ctx, "admin@example.com"); !domain.IsValidationError(err) {
+		t.Errorf("Expected a test email without SMTP settings to be refused, got %v", err)
+	}
+	if _, err := svc.UpdateSettingsSection(ctx, constants.SettingsSectionSMTP, map[string]interface{}{"host": "smtp.example.com", "from": "not an address"}, "admin"); !domain.IsValidationError(err) {
+		t.Errorf("Expected an invalid from address to be refused, got %v", err)
+	}
+
+	smtp, err := svc.UpdateSettingsSection(ctx, constants.SettingsSectionSMTP, map[string]interface{}{
+		"host": "smtp.example.com", "port": float64(465), "security": constants.SMTPSecurityTLS,
+		"username": "mailer", "password": "app-password-1234", "from": "Selfhostly <noreply@example.com>",
+	}, "admin")
+	if err != nil {
+		t.Fatalf("UpdateSettingsSection smtp: %v", err)
+	}
+	if smtp.Values["password"] == "app-password-1234" {
+		t.Error("Expected the SMTP password to be masked")
+	}
+	settings, err := database.GetSettings()
+	if err != nil {
+		t.Fatalf("GetSettings: %v", err)
+	}
+	if settings.SMTPHost != "smtp.example.com" || settings.SMTPPort != 465 || settings.SMTPPassword != "app-password-1234" {
+		t.Errorf("Unexpected stored SMTP settings %+v", settings)
+	}
+}
@@ -0,0 +1,214 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/selfhostly/internal/applock"
+	"github.com/selfhostly/internal/db"
+	"github.com/selfhostly/internal/docker"
+	"github.com/selfhostly/internal/domain"
+	"github.com/selfhostly/internal/secretstore"
+)
+
+// snapshotService manages snapshots of the apps on this node. Unlike a compose version, a snapshot
+// also keeps the app's .env and the digests of the images it was running.
+type snapshotService struct {
+	database      *db.DB
+	dockerManager *docker.Manager
+	secrets       *secretstore.Resolver
+	quotas        domain.QuotaService
+	logger        *slog.Logger
+}
+
+// NewSnapshotService creates a new snapshot service
+func NewSnapshotService(database *db.DB, dockerManager *docker.Manager, secrets *secretstore.Resolver, quotas domain.QuotaService, logger *slog.Logger) domain.SnapshotService {
+	return &snapshotService{
+		database:      database,
+		dockerManager: dockerManager,
+		secrets:       secrets,
+		quotas:        quotas,
+		logger:        logger,
+	}
+}
+
+// ListSnapshots returns an app's snapshots, newest first
+func (s *snapshotService) ListSnapshots(ctx context.Context, appID string) ([]*db.AppSnapshot, error) {
+	if _, err := s.database.GetApp(appID); err != nil {
+		return nil, domain.WrapAppNotFound(appID, err)
+	}
+	snapshots, err := s.database.GetSnapshotsByAppID(appID)
+	if err != nil {
+		return nil, domain.WrapDatabaseOperation("get snapshots", err)
+	}
+	return snapshots, nil
+}
+
+// GetSnapshot returns one of an app's snapshots
+func (s *snapshotService) GetSnapshot(ctx context.Context, appID, snapshotID string) (*db.AppSnapshot, error) {
+	if _, err := s.database.GetApp(appID); err != nil {
+		return nil, domain.WrapAppNotFound(appID, err)
+	}
+	return s.getSnapshot(appID, snapshotID)
+}
+
+// CreateSnapshot captures the app's current compose files, .env and image digests. Digests are
+// resolved from the images on this node, falling back to those recorded on the current compose
+// version when they can't be.
+func (s *snapshotService) CreateSnapshot(ctx context.Context, appID string, req domain.CreateSnapshotRequest, createdBy *string) (*db.AppSnapshot, error) {
+	app, err := s.database.GetApp(appID)
+	if err != nil {
+		return nil, domain.WrapAppNotFound(appID, err)
+	}
+
+	name := strings.TrimSpace(req.Name)
+	if name == "" {
+		return nil, domain.WrapValidationError("name", fmt.Errorf("name is required"))
+	}
+	if _, err := s.database.GetSnapshotByName(appID, name); err == nil {
+		return nil, domain.WrapValidationError("name", fmt.Errorf("the app already has a snapshot named %q", name))
+	} else if !errors.Is(err, sql.ErrNoRows) {
+		return nil, domain.WrapDatabaseOperation("get snapshot", err)
+	}
+
+	snapshot := db.NewAppSnapshot(app, name, createdBy)
+	current, err := s.database.GetCurrentComposeVersion(appID)
+	switch {
+	case err == nil:
+		snapshot.ComposeVersion = current.Version
+	case errors.Is(err, sql.ErrNoRows):
+		current = nil
+	default:
+		return nil, domain.WrapDatabaseOperation("get current compose version", err)
+	}
+
+	digests, err := s.dockerManager.ResolveImageDigests(app.Name)
+	if err != nil {
+		s.logger.WarnContext(ctx, "could not resolve image digests for snapshot", "app", app.Name, "appID", appID, "error", err)
+	}
+	if len(digests) == 0 && current != nil {
+		digests = current.ImageDigests
+	}
+	if len(digests) == 0 {
+		s.logger.WarnContext(ctx, "snapshot has no image digests, its images will be restored by tag", "app", app.Name, "appID", appID, "snapshot", name)
+	}
+	snapshot.ImageDigests = digests
+
+	if err := s.database.CreateSnapshot(snapshot); err != nil {
+		return nil, domain.WrapDatabaseOperation("create snapshot", err)
+	}
+
+	s.logger.InfoContext(ctx, "snapshot created", "app", app.Name, "appID", appID, "snapshotID", snapshot.ID, "name", name, "images", len(digests))
+	return snapshot, nil
+}
+
+// DeleteSnapshot removes one of an app's snapshots
+func (s *snapshotService) DeleteSnapshot(ctx context.Context, appID, snapshotID string) error {
+	if err := s.database.DeleteSnapshot(appID, snapshotID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return domain.WrapSnapshotNotFound(snapshotID, err)
+		}
+		return domain.WrapDatabaseOperation("delete snapshot", err)
+	}
+	s.logger.InfoContext(ctx, "snapshot deleted", "appID", appID, "snapshotID", snapshotID)
+	return nil
+}
+
+// RestoreSnapshot saves the snapshot as a new compose version with its images pinned to their
+// digests, restores its .env, and writes all of it to the app directory
+func (s *snapshotService) RestoreSnapshot(ctx context.Context, appID, snapshotID string, changedBy *string) (*db.ComposeVersion, error) {
+	s.logger.InfoContext(ctx, "restoring snapshot", "appID", appID, "snapshotID", snapshotID)
+	ctx, unlock, err := applock.Acquire(ctx, s.database, appID, "snapshot restore")
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	app, err := s.database.GetApp(appID)
+	if err != nil {
+		return nil, domain.WrapAppNotFound(appID, err)
+	}
+	snapshot, err := s.getSnapshot(appID, snapshotID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Deploy the exact images the snapshot was taken with, not whatever the tags point at today
+	composeContent := snapshot.ComposeContent
+	pinnedContent, pinned, err := docker.PinComposeImages(composeContent, snapshot.ImageDigests)
+	if err != nil {
+		s.logger.WarnContext(ctx, "could not pin images for snapshot restore, using tags", "appID", appID, "snapshotID", snapshotID, "error", err)
+	} else if pinned {
+		composeContent = pinnedContent
+	}
+	if err := enforceQuota(ctx, s.quotas, app.Owner, app.ID, composeContent, snapshot.ComposeOverride, snapshot.ComposeFiles, app.TunnelMode != ""); err != nil {
+		return nil, err
+	}
+
+	latest, err := s.database.GetLatestVersionNumber(appID)
+	if err != nil {
+		return nil, domain.WrapDatabaseOperation("get latest version", err)
+	}
+	reason := fmt.Sprintf("Restored snapshot %q", snapshot.Name)
+	version := db.NewComposeVersion(appID, latest+1, composeContent, &reason, changedBy)
+	version.ComposeOverride = snapshot.ComposeOverride
+	version.ComposeFiles = snapshot.ComposeFiles
+	version.ImageDigests = snapshot.ImageDigests
+	if err := s.database.MarkAllVersionsAsNotCurrent(appID); err != nil {
+		return nil, domain.WrapDatabaseOperation("mark versions as not current", err)
+	}
+	if err := s.database.CreateComposeVersion(version); err != nil {
+		return nil, domain.WrapDatabaseOperation("create compose version", err)
+	}
+
+	previousFiles := app.ComposeFiles
+	previousTemplate := app.EnvTemplate
+	app.ComposeContent = composeContent
+	app.ComposeOverride = snapshot.ComposeOverride
+	app.ComposeFiles = snapshot.ComposeFiles
+	app.EnvTemplate = snapshot.EnvTemplate
+	app.EnvContent = snapshot.EnvContent
+	app.UpdatedAt = time.Now()
+	if err := s.database.UpdateApp(app); err != nil {
+		return nil, domain.WrapDatabaseOperation("update app", err)
+	}
+
+	if err := s.dockerManager.WriteComposeFile(app.Name, app.ComposeContent); err != nil {
+		return nil, domain.WrapContainerOperationFailed("write compose file", err)
+	}
+	if err := s.dockerManager.WriteComposeOverrideFile(app.Name, app.ComposeOverride); err != nil {
+		return nil, domain.WrapContainerOperationFailed("write compose override file", err)
+	}
+	if err := s.dockerManager.WriteComposeFiles(app.Name, app.ComposeFiles, previousFiles); err != nil {
+		return nil, domain.WrapContainerOperationFailed("write compose files", err)
+	}
+	if app.EnvTemplate != "" || previousTemplate != "" {
+		envContent, err := s.secrets.ResolveEnv(ctx, app.EnvContent)
+		if err != nil {
+			return nil, domain.WrapContainerOperationFailed("resolve env secrets", err)
+		}
+		if err := s.dockerManager.WriteEnvFile(app.Name, envContent); err != nil {
+			return nil, domain.WrapContainerOperationFailed("write env file", err)
+		}
+	}
+
+	s.logger.InfoContext(ctx, "snapshot restored", "app", app.Name, "appID", appID, "snapshot", snapshot.Name, "version", version.Version, "pinned", pinned)
+	return version, nil
+}
+
+// getSnapshot loads one of an app's snapshots
+func (s *snapshotService) getSnapshot(appID, snapshotID string) (*db.AppSnapshot, error) {
+	snapshot, err := s.database.GetSnapshot(appID, snapshotID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, domain.WrapSnapshotNotFound(snapshotID, err)
+		}
+		return nil, domain.WrapDatabaseOperation("get snapshot", err)
+	}
+	return snapshot, nil
+}
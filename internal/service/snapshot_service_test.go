@@ -0,0 +1,97 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/selfhostly/internal/db"
+	"github.com/selfhostly/internal/docker"
+	"github.com/selfhostly/internal/domain"
+	"github.com/selfhostly/internal/secretstore"
+)
+
+func TestSnapshotService_CreateAndRestore(t *testing.T) {
+	database, err := db.Init(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to initialize database: %v", err)
+	}
+	defer database.Close()
+
+	appsDir := t.TempDir()
+	mockExecutor := docker.NewMockCommandExecutor()
+	dockerManager := docker.NewManagerWithExecutor(appsDir, mockExecutor)
+	service := NewSnapshotService(database, dockerManager, secretstore.New(secretstore.Config{}), nil, slog.Default())
+	ctx := context.Background()
+
+	compose := "services:\n  web:\n    image: nginx:latest\n"
+	app := db.NewApp("pinned", "", compose)
+	app.EnvTemplate = "PASSWORD={{ secret 16 }}\n"
+	app.EnvContent = "PASSWORD=first\n"
+	if err := database.CreateApp(app); err != nil {
+		t.Fatalf("Failed to create app: %v", err)
+	}
+	if err := database.CreateComposeVersion(db.NewComposeVersion(app.ID, 1, compose, nil, nil)); err != nil {
+		t.Fatal(err)
+	}
+	if err := dockerManager.CreateAppDirectory(app.Name, compose); err != nil {
+		t.Fatal(err)
+	}
+
+	imagesCmd := docker.ComposeConfigImagesCommand()
+	mockExecutor.SetMockOutput("docker", imagesCmd[1:], []byte("nginx:latest\n"))
+	digestCmd := docker.DockerImageRepoDigestCommand("nginx:latest")
+	mockExecutor.SetMockOutput("docker", digestCmd[1:], []byte("nginx@sha256:def456\n"))
+
+	user := "alice"
+	snapshot, err := service.CreateSnapshot(ctx, app.ID, domain.CreateSnapshotRequest{Name: " release-1 "}, &user)
+	if err != nil {
+		t.Fatalf("CreateSnapshot: %v", err)
+	}
+	if snapshot.Name != "release-1" || snapshot.ComposeVersion != 1 || snapshot.EnvContent != "PASSWORD=first\n" ||
+		snapshot.ImageDigests["nginx:latest"] != "nginx@sha256:def456" {
+		t.Errorf("Unexpected snapshot %+v", snapshot)
+	}
+	if encoded, _ := json.Marshal(snapshot); strings.Contains(string(encoded), "PASSWORD=first") {
+		t.Errorf("Expected the snapshot's .env to be left out of its JSON, got %s", encoded)
+	}
+
+	if _, err := service.CreateSnapshot(ctx, app.ID, domain.CreateSnapshotRequest{Name: "release-1"}, nil); !domain.IsValidationError(err) {
+		t.Errorf("Expected a validation error for a duplicate name, got %v", err)
+	}
+
+	// The app moves on: new compose, re-rendered secrets
+	app.ComposeContent = "services:\n  web:\n    image: nginx:1.27\n"
+	app.EnvContent = "PASSWORD=second\n"
+	if err := database.UpdateApp(app); err != nil {
+		t.Fatal(err)
+	}
+
+	version, err := service.RestoreSnapshot(ctx, app.ID, snapshot.ID, &user)
+	if err != nil {
+		t.Fatalf("RestoreSnapshot: %v", err)
+	}
+	if version.Version != 2 || !version.IsCurrent || version.ChangeReason == nil || !strings.Contains(*version.ChangeReason, "release-1") {
+		t.Errorf("Unexpected compose version %+v", version)
+	}
+
+	restored, _ := database.GetApp(app.ID)
+	if !strings.Contains(restored.ComposeContent, "nginx@sha256:def456") || restored.EnvContent != "PASSWORD=first\n" {
+		t.Errorf("Expected the pinned compose and original .env, got %q / %q", restored.ComposeContent, restored.EnvContent)
+	}
+	envFile, err := os.ReadFile(filepath.Join(appsDir, app.Name, docker.EnvFileName))
+	if err != nil || string(envFile) != "PASSWORD=first\n" {
+		t.Errorf("Expected the .env to be written, got %q (%v)", envFile, err)
+	}
+
+	if err := service.DeleteSnapshot(ctx, app.ID, snapshot.ID); err != nil {
+		t.Fatalf("DeleteSnapshot: %v", err)
+	}
+	if _, err := service.GetSnapshot(ctx, app.ID, snapshot.ID); !domain.IsNotFoundError(err) {
+		t.Errorf("Expected a not found error after delete, got %v", err)
+	}
+}
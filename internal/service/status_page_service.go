@@ -0,0 +1,216 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/selfhostly/internal/config"
+	"github.com/selfhostly/internal/constants"
+	"github.com/selfhostly/internal/db"
+	"github.com/selfhostly/internal/domain"
+)
+
+// statusPageService serves public status pages: uptime, current status and the last incident of
+// an app, worked out from its status history, to anyone holding the page's token
+type statusPageService struct {
+	database *db.DB
+	config   *config.Config
+	logger   *slog.Logger
+	window   time.Duration
+}
+
+// NewStatusPageService creates a new status page service
+func NewStatusPageService(database *db.DB, cfg *config.Config, logger *slog.Logger) domain.StatusPageService {
+	return &statusPageService{
+		database: database,
+		config:   cfg,
+		logger:   logger,
+		window:   constants.StatusPageUptimeWindow,
+	}
+}
+
+// GetStatusPage returns an app's status page; its token isn't stored, so can't be shown again
+func (s *statusPageService) GetStatusPage(ctx context.Context, appID string) (*db.AppStatusPage, error) {
+	if _, err := s.database.GetApp(appID); err != nil {
+		return nil, domain.WrapAppNotFound(appID, err)
+	}
+	page, err := s.database.GetAppStatusPage(appID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, domain.WrapStatusPageNotFound(err)
+	}
+	if err != nil {
+		return nil, domain.WrapDatabaseOperation("get status page", err)
+	}
+	return page, nil
+}
+
+// EnableStatusPage gives an app a status page with a new token. The link names this node, so the
+// gateway can route it to the app's status history.
+func (s *statusPageService) EnableStatusPage(ctx context.Context, appID, createdBy, baseURL string) (*domain.IssuedStatusPage, error) {
+	app, err := s.database.GetApp(appID)
+	if err != nil {
+		return nil, domain.WrapAppNotFound(appID, err)
+	}
+
+	token, err := newSecretToken()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate status page token: %w", err)
+	}
+	page := &db.AppStatusPage{AppID: appID, TokenHash: hashSecretToken(token), CreatedBy: createdBy, CreatedAt: time.Now()}
+	if err := s.database.SaveAppStatusPage(page); err != nil {
+		return nil, domain.WrapDatabaseOperation("save status page", err)
+	}
+
+	link := strings.TrimRight(baseURL, "/") + constants.StatusPagePath + token
+	if s.config.Node.ID != "" {
+		link += "?node_id=" + url.QueryEscape(s.config.Node.ID)
+	}
+
+	s.logger.InfoContext(ctx, "status page enabled", "app", app.Name, "appID", appID, "createdBy", createdBy)
+	return &domain.IssuedStatusPage{AppStatusPage: page, Token: token, URL: link}, nil
+}
+
+// DisableStatusPage deletes an app's status page, so its link stops working
+func (s *statusPageService) DisableStatusPage(ctx context.Context, appID string) error {
+	if err := s.database.DeleteAppStatusPage(appID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return domain.WrapStatusPageNotFound(err)
+		}
+		return domain.WrapDatabaseOperation("delete status page", err)
+	}
+	s.logger.InfoContext(ctx, "status page disabled", "appID", appID)
+	return nil
+}
+
+// GetAppStatus returns the status report of the app a status page token belongs to
+func (s *statusPageService) GetAppStatus(ctx context.Context, token string) (*domain.AppStatusReport, error) {
+	page, err := s.database.GetAppStatusPageByTokenHash(hashSecretToken(token))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, domain.WrapStatusPageNotFound(err)
+	}
+	if err != nil {
+		return nil, domain.WrapDatabaseOperation("get status page", err)
+	}
+	app, err := s.database.GetApp(page.AppID)
+	if err != nil {
+		return nil, domain.WrapStatusPageNotFound(err)
+	}
+
+	now := time.Now()
+	history, err := s.database.GetAppStatusHistory(app.ID, now.Add(-s.window))
+	if err != nil {
+		return nil, domain.WrapDatabaseOperation("get status history", err)
+	}
+	return buildStatusReport(app, history, now, s.window), nil
+}
+
+// PruneStatusHistory deletes status history older than the uptime window, keeping what each app's
+// status was when the window starts
+func (s *statusPageService) PruneStatusHistory(ctx context.Context) error {
+	deleted, err := s.database.DeleteAppStatusHistoryBefore(time.Now().Add(-s.window))
+	if err != nil {
+		return domain.WrapDatabaseOperation("prune app status history", err)
+	}
+	if deleted > 0 {
+		s.logger.DebugContext(ctx, "app status history pruned", "count", deleted)
+	}
+	return nil
+}
+
+// buildStatusReport works out an app's uptime and last incident over the window before now from
+// its status history, which starts with the last change before the window when there is one. The
+// window starts no earlier than the app was created; an app is up while running or degraded.
+func buildStatusReport(app *db.App, history []*db.AppStatusChange, now time.Time, window time.Duration) *domain.AppStatusReport {
+	start := now.Add(-window)
+	if app.CreatedAt.After(start) {
+		start = app.CreatedAt
+	}
+
+	report := &domain.AppStatusReport{
+		App:              app.Name,
+		Status:           publicAppStatus(app.Status),
+		Since:            app.CreatedAt,
+		UptimeWindowDays: int(window.Hours() / 24),
+		GeneratedAt:      now,
+	}
+
+	status := app.Status
+	if len(history) > 0 {
+		status = history[0].FromStatus
+		report.Since = history[len(history)-1].ChangedAt
+	}
+
+	var up, total time.Duration
+	cursor := start
+	var incident *domain.AppIncident
+	for _, change := range history {
+		if change.ChangedAt.After(cursor) {
+			elapsed := change.ChangedAt.Sub(cursor)
+			total += elapsed
+			if isAppUp(status) {
+				up += elapsed
+			}
+			cursor = change.ChangedAt
+		}
+
+		switch {
+		case isIncidentStatus(change.ToStatus) && !isIncidentStatus(status):
+			incident = &domain.AppIncident{Status: publicAppStatus(change.ToStatus), StartedAt: change.ChangedAt}
+		case isIncidentStatus(change.ToStatus) && incident != nil:
+			if change.ToStatus == constants.AppStatusError {
+				incident.Status = constants.PublicStatusDown
+			}
+		case !isIncidentStatus(change.ToStatus) && isIncidentStatus(status) && incident != nil:
+			resolvedAt := change.ChangedAt
+			incident.ResolvedAt = &resolvedAt
+		}
+		status = change.ToStatus
+	}
+	if now.After(cursor) {
+		elapsed := now.Sub(cursor)
+		total += elapsed
+		if isAppUp(status) {
+			up += elapsed
+		}
+	}
+
+	switch {
+	case total > 0:
+		report.UptimePercent = math.Round(float64(up)/float64(total)*10000) / 100
+	case isAppUp(app.Status):
+		report.UptimePercent = 100
+	}
+	// Incidents resolved before the window started are too old to report
+	if incident != nil && (incident.ResolvedAt == nil || incident.ResolvedAt.After(start)) {
+		report.LastIncident = incident
+	}
+	return report
+}
+
+// isIncidentStatus reports whether an app with the given status is having an incident
+func isIncidentStatus(status string) bool {
+	return status == constants.AppStatusError || status == constants.AppStatusDegraded
+}
+
+// publicAppStatus returns the status a status page shows for an app status
+func publicAppStatus(status string) string {
+	switch status {
+	case constants.AppStatusRunning:
+		return constants.PublicStatusOperational
+	case constants.AppStatusDegraded:
+		return constants.PublicStatusDegraded
+	case constants.AppStatusError:
+		return constants.PublicStatusDown
+	case constants.AppStatusPending, constants.AppStatusUpdating:
+		return constants.PublicStatusMaintenance
+	default:
+		return constants.PublicStatusStopped
+	}
+}
@@ -0,0 +1,156 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/selfhostly/internal/config"
+	"github.com/selfhostly/internal/constants"
+	"github.com/selfhostly/internal/db"
+	"github.com/selfhostly/internal/domain"
+)
+
+func TestBuildStatusReport(t *testing.T) {
+	now := time.Date(2026, 3, 31, 0, 0, 0, 0, time.UTC)
+	app := &db.App{Name: "web", Status: constants.AppStatusRunning, CreatedAt: now.Add(-100 * 24 * time.Hour)}
+
+	// Running since before the window, down for 3 days, degraded for a day, then running again
+	history := []*db.AppStatusChange{
+		{FromStatus: constants.AppStatusPending, ToStatus: constants.AppStatusRunning, ChangedAt: now.Add(-60 * 24 * time.Hour)},
+		{FromStatus: constants.AppStatusRunning, ToStatus: constants.AppStatusDegraded, ChangedAt: now.Add(-10 * 24 * time.Hour)},
+		{FromStatus: constants.AppStatusDegraded, ToStatus: constants.AppStatusError, ChangedAt: now.Add(-9 * 24 * time.Hour)},
+		{FromStatus: constants.AppStatusError, ToStatus: constants.AppStatusRunning, ChangedAt: now.Add(-6 * 24 * time.Hour)},
+	}
+	report := buildStatusReport(app, history, now, 30*24*time.Hour)
+
+	if report.Status != constants.PublicStatusOperational || !report.Since.Equal(history[3].ChangedAt) {
+		t.Errorf("Unexpected current status %s since %v", report.Status, report.Since)
+	}
+	if report.UptimePercent != 90 || report.UptimeWindowDays != 30 {
+		t.Errorf("Expected 90%% uptime over 30 days, got %v%% over %d", report.UptimePercent, report.UptimeWindowDays)
+	}
+	incident := report.LastIncident
+	if incident == nil || incident.Status != constants.PublicStatusDown || !incident.StartedAt.Equal(history[1].ChangedAt) ||
+		incident.ResolvedAt == nil || !incident.ResolvedAt.Equal(history[3].ChangedAt) {
+		t.Errorf("Unexpected last incident %+v", incident)
+	}
+
+	// A new app without history is up for as long as it exists
+	fresh := &db.App{Name: "new", Status: constants.AppStatusRunning, CreatedAt: now.Add(-time.Hour)}
+	if report := buildStatusReport(fresh, nil, now, 30*24*time.Hour); report.UptimePercent != 100 || report.LastIncident != nil {
+		t.Errorf("Expected a new running app to be fully up, got %+v", report)
+	}
+
+	// An ongoing incident is reported without a resolution
+	failing := &db.App{Name: "broken", Status: constants.AppStatusError, CreatedAt: now.Add(-2 * time.Hour)}
+	history = []*db.AppStatusChange{
+		{FromStatus: constants.AppStatusRunning, ToStatus: constants.AppStatusError, ChangedAt: now.Add(-time.Hour)},
+	}
+	report = buildStatusReport(failing, history, now, 30*24*time.Hour)
+	if report.Status != constants.PublicStatusDown || report.UptimePercent != 50 || report.LastIncident == nil || report.LastIncident.ResolvedAt != nil {
+		t.Errorf("Unexpected report for a failing app %+v", report)
+	}
+}
+
+func TestStatusPageService_Tokens(t *testing.T) {
+	database, err := db.Init(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to initialize database: %v", err)
+	}
+	t.Cleanup(func() { database.Close() })
+
+	cfg := &config.Config{}
+	cfg.Node.ID = "node-1"
+	service := NewStatusPageService(database, cfg, slog.Default())
+	ctx := context.Background()
+
+	app := db.NewApp("web", "", "services:\n  web:\n    image: nginx:latest\n")
+	app.Status = constants.AppStatusRunning
+	if err := database.CreateApp(app); err != nil {
+		t.Fatalf("Failed to create app: %v", err)
+	}
+
+	if _, err := service.GetStatusPage(ctx, app.ID); !domain.IsNotFoundError(err) {
+		t.Errorf("Expected no status page yet, got %v", err)
+	}
+	first, err := service.EnableStatusPage(ctx, app.ID, "alice", "https://selfhostly.example.com/")
+	if err != nil {
+		t.Fatalf("EnableStatusPage: %v", err)
+	}
+	if first.URL != "https://selfhostly.example.com/api/status/"+first.Token+"?node_id=node-1" {
+		t.Errorf("Unexpected status page link %s", first.URL)
+	}
+
+	report, err := service.GetAppStatus(ctx, first.Token)
+	if err != nil {
+		t.Fatalf("GetAppStatus: %v", err)
+	}
+	if report.App != "web" || report.Status != constants.PublicStatusOperational {
+		t.Errorf("Unexpected report %+v", report)
+	}
+
+	// A new token replaces the old one
+	second, err := service.EnableStatusPage(ctx, app.ID, "alice", "https://selfhostly.example.com")
+	if err != nil {
+		t.Fatalf("EnableStatusPage: %v", err)
+	}
+	if _, err := service.GetAppStatus(ctx, first.Token); !domain.IsNotFoundError(err) {
+		t.Errorf("Expected the replaced token to be refused, got %v", err)
+	}
+	if strings.Contains(second.URL, first.Token) {
+		t.Error("Expected a new token")
+	}
+
+	if err := service.DisableStatusPage(ctx, app.ID); err != nil {
+		t.Fatalf("DisableStatusPage: %v", err)
+	}
+	if _, err := service.GetAppStatus(ctx, second.Token); !domain.IsNotFoundError(err) {
+		t.Errorf("Expected a disabled status page to be refused, got %v", err)
+	}
+	if err := service.DisableStatusPage(ctx, app.ID); !domain.IsNotFoundError(err) {
+		t.Errorf("Expected disabling again to be not found, got %v", err)
+	}
+}
+
+func TestStatusPageService_PruneStatusHistory(t *testing.T) {
+	database, err := db.Init(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to initialize database: %v", err)
+	}
+	t.Cleanup(func() { database.Close() })
+
+	service := NewStatusPageService(database, &config.Config{}, slog.Default())
+	ctx := context.Background()
+
+	app := db.NewApp("web", "", "services:\n  web:\n    image: nginx:latest\n")
+	if err := database.CreateApp(app); err != nil {
+		t.Fatalf("Failed to create app: %v", err)
+	}
+	now := time.Now()
+	for _, change := range []*db.AppStatusChange{
+		{AppID: app.ID, FromStatus: constants.AppStatusPending, ToStatus: constants.AppStatusRunning, ChangedAt: now.Add(-60 * 24 * time.Hour)},
+		{AppID: app.ID, FromStatus: constants.AppStatusRunning, ToStatus: constants.AppStatusError, ChangedAt: now.Add(-45 * 24 * time.Hour)},
+		{AppID: app.ID, FromStatus: constants.AppStatusError, ToStatus: constants.AppStatusRunning, ChangedAt: now.Add(-time.Hour)},
+	} {
+		if err := database.CreateAppStatusChange(change); err != nil {
+			t.Fatalf("Failed to record status change: %v", err)
+		}
+	}
+
+	if err := service.PruneStatusHistory(ctx); err != nil {
+		t.Fatalf("PruneStatusHistory: %v", err)
+	}
+
+	// The last change before the window is kept, so the app's status when it starts is known
+	history, err := database.GetAppStatusHistory(app.ID, time.Time{})
+	if err != nil {
+		t.Fatalf("GetAppStatusHistory: %v", err)
+	}
+	if len(history) != 2 || history[0].ToStatus != constants.AppStatusError {
+		t.Errorf("Expected the oldest change to be pruned, got %+v", history)
+	}
+}
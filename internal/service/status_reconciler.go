@@ -0,0 +1,120 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/selfhostly/internal/appstate"
+	"github.com/selfhostly/internal/constants"
+	"github.com/selfhostly/internal/db"
+	"github.com/selfhostly/internal/docker"
+	"github.com/selfhostly/internal/webhook"
+)
+
+// psResult is a cached observation of how many containers an app had running
+type psResult struct {
+	running   int
+	checkedAt time.Time
+}
+
+// statusReconciler corrects stored app statuses that disagree with docker (e.g. "running" with no
+// containers after a host reboot). "docker compose ps" results are cached briefly so listing apps
+// doesn't shell out for every app on every read.
+type statusReconciler struct {
+	database      *db.DB
+	states        *appstate.Machine
+	dockerManager *docker.Manager
+	webhooks      *webhook.Dispatcher
+	logger        *slog.Logger
+	ttl           time.Duration
+
+	mu    sync.Mutex
+	cache map[string]psResult
+}
+
+// newStatusReconciler creates a status reconciler with the default cache TTL. webhooks may be nil.
+func newStatusReconciler(database *db.DB, states *appstate.Machine, dockerManager *docker.Manager, webhooks *webhook.Dispatcher, logger *slog.Logger) *statusReconciler {
+	return &statusReconciler{
+		database:      database,
+		states:        states,
+		dockerManager: dockerManager,
+		webhooks:      webhooks,
+		logger:        logger,
+		ttl:           constants.AppStatusCacheTTL,
+		cache:         make(map[string]psResult),
+	}
+}
+
+// isAppUp reports whether an app with the given status has containers that should be up, which
+// a degraded app still does
+func isAppUp(status string) bool {
+	return status == constants.AppStatusRunning || status == constants.AppStatusDegraded
+}
+
+// reconcile flips app.Status between running and stopped when docker disagrees, persisting the
+// correction. Other statuses (updating, pending, error, degraded) and apps with an operation in
+// progress are left alone. force bypasses the ps cache.
+func (r *statusReconciler) reconcile(ctx context.Context, app *db.App, force bool) {
+	if app.Status != constants.AppStatusRunning && app.Status != constants.AppStatusStopped {
+		return
+	}
+
+	observed, err := r.observe(app.Name, force)
+	if err != nil {
+		r.logger.DebugContext(ctx, "skipping status reconcile", "app", app.Name, "error", err)
+		return
+	}
+
+	// An observation older than the last status change can't contradict it
+	if observed.checkedAt.Before(app.UpdatedAt) {
+		return
+	}
+
+	actual := constants.AppStatusStopped
+	if observed.running > 0 {
+		actual = constants.AppStatusRunning
+	}
+	if actual == app.Status {
+		return
+	}
+
+	if _, err := r.database.GetAppLock(app.ID); err == nil {
+		return
+	}
+
+	r.logger.InfoContext(ctx, "correcting stale app status", "app", app.Name, "appID", app.ID, "stored", app.Status, "actual", actual)
+	crashed := app.Status == constants.AppStatusRunning
+	if err := r.states.Transition(ctx, app, actual, "docker reports a different state"); err != nil {
+		r.logger.WarnContext(ctx, "failed to persist corrected app status", "app", app.Name, "appID", app.ID, "error", err)
+		return
+	}
+
+	// Nothing we did stopped it, so its containers exited or were removed outside of selfhostly
+	if crashed {
+		r.webhooks.Fire(ctx, app, constants.WebhookEventCrash, "app was running but no containers are up")
+	}
+}
+
+// observe returns the running container count for an app, from cache when fresh
+func (r *statusReconciler) observe(appName string, force bool) (psResult, error) {
+	r.mu.Lock()
+	cached, ok := r.cache[appName]
+	r.mu.Unlock()
+	if ok && !force && time.Since(cached.checkedAt) < r.ttl {
+		return cached, nil
+	}
+
+	checkedAt := time.Now()
+	running, err := r.dockerManager.CountRunningContainers(appName)
+	if err != nil {
+		return psResult{}, err
+	}
+
+	result := psResult{running: running, checkedAt: checkedAt}
+	r.mu.Lock()
+	r.cache[appName] = result
+	r.mu.Unlock()
+	return result, nil
+}
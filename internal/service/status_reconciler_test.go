@@ -0,0 +1,72 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/selfhostly/internal/appstate"
+	"github.com/selfhostly/internal/constants"
+	"github.com/selfhostly/internal/db"
+	"github.com/selfhostly/internal/docker"
+)
+
+func TestStatusReconciler(t *testing.T) {
+	tmpDir := t.TempDir()
+	database, err := db.Init(filepath.Join(tmpDir, "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to initialize database: %v", err)
+	}
+	defer database.Close()
+
+	mockExecutor := docker.NewMockCommandExecutor()
+	dockerManager := docker.NewManagerWithExecutor(filepath.Join(tmpDir, "apps"), mockExecutor)
+	psCmd := docker.ComposePsQuietCommand()
+
+	app := db.NewApp("stale-app", "", "services:\n  web:\n    image: nginx\n")
+	app.Status = constants.AppStatusRunning
+	app.UpdatedAt = time.Now().Add(-time.Minute)
+	if err := database.CreateApp(app); err != nil {
+		t.Fatalf("Failed to create app: %v", err)
+	}
+
+	reconciler := newStatusReconciler(database, appstate.New(database, slog.Default()), dockerManager, nil, slog.Default())
+	ctx := context.Background()
+
+	// "running" with no containers is corrected to "stopped" and persisted
+	mockExecutor.SetMockOutput("docker", psCmd[1:], []byte(""))
+	reconciler.reconcile(ctx, app, false)
+	if app.Status != constants.AppStatusStopped {
+		t.Fatalf("Expected status stopped, got %s", app.Status)
+	}
+	stored, err := database.GetApp(app.ID)
+	if err != nil {
+		t.Fatalf("GetApp: %v", err)
+	}
+	if stored.Status != constants.AppStatusStopped {
+		t.Errorf("Expected corrected status to be persisted, got %s", stored.Status)
+	}
+
+	// Containers came back, but the cached observation predates the correction above
+	mockExecutor.SetMockOutput("docker", psCmd[1:], []byte("3f4e5d6c7b8a\n"))
+	reconciler.reconcile(ctx, app, false)
+	if app.Status != constants.AppStatusStopped {
+		t.Errorf("Expected cached observation to be ignored, got %s", app.Status)
+	}
+
+	// Forcing bypasses the cache
+	reconciler.reconcile(ctx, app, true)
+	if app.Status != constants.AppStatusRunning {
+		t.Errorf("Expected forced reconcile to mark app running, got %s", app.Status)
+	}
+
+	// Transitional statuses are left alone
+	app.Status = constants.AppStatusUpdating
+	mockExecutor.SetMockOutput("docker", psCmd[1:], []byte(""))
+	reconciler.reconcile(ctx, app, true)
+	if app.Status != constants.AppStatusUpdating {
+		t.Errorf("Expected updating status to be left alone, got %s", app.Status)
+	}
+}
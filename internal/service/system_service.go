@@ -5,12 +5,17 @@ import (
 	"encoding/json"
 	"fmt"
 	"log/slog"
+	"sort"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/selfhostly/internal/config"
 	"github.com/selfhostly/internal/constants"
 	"github.com/selfhostly/internal/db"
 	"github.com/selfhostly/internal/docker"
 	"github.com/selfhostly/internal/domain"
+	"github.com/selfhostly/internal/logger"
 	"github.com/selfhostly/internal/node"
 	"github.com/selfhostly/internal/routing"
 	"github.com/selfhostly/internal/system"
@@ -27,6 +32,9 @@ type systemService struct {
 	logger        *slog.Logger
 	router        *routing.NodeRouter
 	statsAgg      *routing.StatsAggregator
+	logsAgg       *routing.LogsAggregator
+
+	reloadMu sync.Mutex // serializes config reloads from the API and SIGHUP
 }
 
 // NewSystemService creates a new system service
@@ -37,9 +45,10 @@ func NewSystemService(
 	logger *slog.Logger,
 ) domain.SystemService {
 	collector := system.NewCollector(cfg.AppsDir, dockerManager, database, cfg.Node.ID, cfg.Node.Name)
-	nodeClient := node.NewClient()
+	nodeClient := node.NewClientWithTimeouts(cfg.Timeouts)
 	router := routing.NewNodeRouter(database, nodeClient, cfg.Node.ID, logger)
 	statsAgg := routing.NewStatsAggregator(router, logger)
+	logsAgg := routing.NewLogsAggregator(router, logger)
 
 	return &systemService{
 		database:      database,
@@ -50,6 +59,7 @@ func NewSystemService(
 		logger:        logger,
 		router:        router,
 		statsAgg:      statsAgg,
+		logsAgg:       logsAgg,
 	}
 }
 
@@ -115,7 +125,7 @@ func (s *systemService) GetAppStats(ctx context.Context, appID string, nodeID st
 	if err != nil {
 		return nil, domain.WrapAppNotFound(appID, err)
 	}
-	if app.Status != constants.AppStatusRunning {
+	if !isAppUp(app.Status) {
 		return &domain.AppStats{
 			AppName:          app.Name,
 			TotalCPUPercent:  0,
@@ -129,6 +139,38 @@ func (s *systemService) GetAppStats(ctx context.Context, appID string, nodeID st
 	if err != nil {
 		return nil, domain.WrapContainerOperationFailed("get app stats", err)
 	}
+	return toDomainAppStats(dockerStats, app.Status), nil
+}
+
+// StreamAppStats passes an app's resource usage to fn every interval until ctx is done or fn
+// fails (local only). A stopped app gets a single sample saying so.
+func (s *systemService) StreamAppStats(ctx context.Context, appID string, interval time.Duration, fn func(*domain.AppStats) error) error {
+	app, err := s.database.GetApp(appID)
+	if err != nil {
+		return domain.WrapAppNotFound(appID, err)
+	}
+	if !isAppUp(app.Status) {
+		return fn(&domain.AppStats{
+			AppName:    app.Name,
+			Containers: []domain.ContainerStats{},
+			Timestamp:  time.Now(),
+			Status:     app.Status,
+			Message:    fmt.Sprintf("App is %s", app.Status),
+		})
+	}
+
+	s.logger.DebugContext(ctx, "streaming app stats", "appID", appID, "interval", interval)
+	err = s.dockerManager.StreamAppStats(ctx, app.Name, interval, func(stats *docker.AppStats) error {
+		return fn(toDomainAppStats(stats, app.Status))
+	})
+	if err != nil {
+		return domain.WrapContainerOperationFailed("stream app stats", err)
+	}
+	return nil
+}
+
+// toDomainAppStats converts docker's stats for an app with the given status
+func toDomainAppStats(dockerStats *docker.AppStats, status string) *domain.AppStats {
 	containers := make([]domain.ContainerStats, len(dockerStats.Containers))
 	for i, c := range dockerStats.Containers {
 		memPercent := float64(0)
@@ -155,9 +197,9 @@ func (s *systemService) GetAppStats(ctx context.Context, appID string, nodeID st
 		MemoryLimitBytes: int64(dockerStats.MemoryLimit),
 		Containers:       containers,
 		Timestamp:        dockerStats.Timestamp,
-		Status:           app.Status,
+		Status:           status,
 		Message:          "",
-	}, nil
+	}
 }
 
 // GetAppLogs retrieves logs for a specific app
@@ -178,6 +220,141 @@ func (s *systemService) GetAppLogs(ctx context.Context, appID string, nodeID str
 	return logs, nil
 }
 
+// SearchLogs greps recent container logs of apps across the specified nodes
+// Matches are merged newest first and tagged with node, app and service
+func (s *systemService) SearchLogs(ctx context.Context, req domain.LogSearchRequest, nodeIDs []string) ([]*domain.LogSearchMatch, error) {
+	s.logger.DebugContext(ctx, "searching logs", "query", req.Query, "apps", req.Apps, "since", req.Since, "nodeIDs", nodeIDs)
+
+	req.Query = strings.TrimSpace(req.Query)
+	if req.Query == "" {
+		return nil, domain.WrapValidationError("q", fmt.Errorf("search query is required"))
+	}
+	if err := validation.ValidateLogSince(req.Since); err != nil {
+		return nil, domain.WrapValidationError("since", err)
+	}
+	if req.Since == "" {
+		req.Since = constants.LogSearchDefaultSince
+	}
+	if req.ContextLines <= 0 {
+		req.ContextLines = constants.LogSearchDefaultContextLines
+	}
+	if req.ContextLines > constants.LogSearchMaxContextLines {
+		req.ContextLines = constants.LogSearchMaxContextLines
+	}
+
+	targetNodes, err := s.router.DetermineTargetNodes(ctx, nodeIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	matches, err := s.logsAgg.AggregateSearch(
+		ctx,
+		targetNodes,
+		func() ([]*domain.LogSearchMatch, error) {
+			return s.searchLocalLogs(ctx, req)
+		},
+		func(n *db.Node) ([]*domain.LogSearchMatch, error) {
+			return s.nodeClient.SearchLogs(n, req)
+		},
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if matches == nil {
+		matches = []*domain.LogSearchMatch{}
+	}
+	return matches, nil
+}
+
+// searchLocalLogs searches logs of running apps on this node
+func (s *systemService) searchLocalLogs(ctx context.Context, req domain.LogSearchRequest) ([]*domain.LogSearchMatch, error) {
+	apps, err := s.database.GetAllAppsMetadata()
+	if err != nil {
+		return nil, domain.WrapDatabaseOperation("get apps", err)
+	}
+
+	wanted := make(map[string]bool, len(req.Apps))
+	for _, a := range req.Apps {
+		wanted[a] = true
+	}
+
+	var matches []*domain.LogSearchMatch
+	for _, app := range apps {
+		if app.NodeID != "" && app.NodeID != s.config.Node.ID {
+			continue
+		}
+		if len(wanted) > 0 && !wanted[app.ID] && !wanted[app.Name] {
+			continue
+		}
+		if !isAppUp(app.Status) {
+			continue
+		}
+
+		entries, err := s.dockerManager.GetAppLogEntries(app.Name, req.Since, constants.LogSearchTailLines)
+		if err != nil {
+			// One app failing to return logs shouldn't fail the whole search
+			s.logger.WarnContext(ctx, "failed to read logs for search", "app", app.Name, "error", err)
+			continue
+		}
+
+		for _, m := range searchLogEntries(entries, req.Query, req.ContextLines) {
+			m.AppID = app.ID
+			m.AppName = app.Name
+			matches = append(matches, m)
+		}
+	}
+
+	// Keep newest matches when over the per-node cap
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].Timestamp.After(matches[j].Timestamp)
+	})
+	if len(matches) > constants.LogSearchMaxResults {
+		matches = matches[:constants.LogSearchMaxResults]
+	}
+
+	return matches, nil
+}
+
+// searchLogEntries returns case-insensitive matches of query in entries, with context lines
+// taken from the same service so interleaved output from other containers doesn't leak in
+func searchLogEntries(entries []docker.LogEntry, query string, contextLines int) []*domain.LogSearchMatch {
+	byService := make(map[string][]docker.LogEntry)
+	var serviceOrder []string
+	for _, e := range entries {
+		if _, ok := byService[e.Service]; !ok {
+			serviceOrder = append(serviceOrder, e.Service)
+		}
+		byService[e.Service] = append(byService[e.Service], e)
+	}
+
+	needle := strings.ToLower(query)
+	var matches []*domain.LogSearchMatch
+	for _, service := range serviceOrder {
+		lines := byService[service]
+		for i, e := range lines {
+			if !strings.Contains(strings.ToLower(e.Message), needle) {
+				continue
+			}
+
+			m := &domain.LogSearchMatch{
+				Service:   service,
+				Timestamp: e.Timestamp,
+				Line:      e.Message,
+			}
+			for j := max(0, i-contextLines); j < i; j++ {
+				m.Before = append(m.Before, lines[j].Message)
+			}
+			for j := i + 1; j < len(lines) && j <= i+contextLines; j++ {
+				m.After = append(m.After, lines[j].Message)
+			}
+			matches = append(matches, m)
+		}
+	}
+
+	return matches
+}
+
 // GetAppServices retrieves the list of service names for a specific app
 func (s *systemService) GetAppServices(ctx context.Context, appID string, nodeID string) ([]string, error) {
 	s.logger.DebugContext(ctx, "getting app services", "appID", appID, "nodeID", nodeID)
@@ -251,3 +428,87 @@ func (s *systemService) DeleteContainer(ctx context.Context, containerID, nodeID
 	s.logger.InfoContext(ctx, "container deleted successfully", "containerID", containerID, "nodeID", nodeID)
 	return nil
 }
+
+// ReloadConfig re-reads the env file and applies the reload-safe settings (log level and
+// inter-node timeouts) without restarting, so running tunnels and jobs are left alone
+func (s *systemService) ReloadConfig(ctx context.Context) (*domain.ConfigReload, error) {
+	s.reloadMu.Lock()
+	defer s.reloadMu.Unlock()
+
+	if err := config.ReloadEnvFile(); err != nil {
+		// The process environment may hold everything, as it does in most container setups
+		s.logger.WarnContext(ctx, "config reload: env file not read", "file", config.EnvFile(), "error", err)
+	}
+
+	next := config.LoadReloadable()
+	if err := logger.ApplyLevel(s.config.Environment, next.LogLevel); err != nil {
+		return nil, domain.WrapValidationError("LOG_LEVEL", err)
+	}
+	changed := s.config.ApplyReloadable(next)
+
+	s.logger.InfoContext(ctx, "configuration reloaded", "changed", changed, "log_level", logger.Level().String())
+	return &domain.ConfigReload{
+		NodeID:     s.config.Node.ID,
+		Changed:    changed,
+		LogLevel:   s.localLogSettings().Level,
+		ReloadedAt: time.Now(),
+	}, nil
+}
+
+// GetLogSettings returns the log level and output format of nodeID, or of this node when nodeID
+// is empty
+func (s *systemService) GetLogSettings(ctx context.Context, nodeID string) (*domain.LogSettings, error) {
+	if nodeID == "" || nodeID == s.config.Node.ID {
+		return s.localLogSettings(), nil
+	}
+
+	n, err := s.database.GetNode(nodeID)
+	if err != nil {
+		return nil, domain.WrapNodeNotFound(nodeID, err)
+	}
+	return s.nodeClient.GetLogSettings(n)
+}
+
+// UpdateLogSettings switches the log level or output format of nodeID (this node when empty)
+// until it restarts or reloads its config. Other nodes are updated through the node client.
+func (s *systemService) UpdateLogSettings(ctx context.Context, nodeID string, req domain.UpdateLogSettingsRequest) (*domain.LogSettings, error) {
+	if req.Level == "" && req.JSON == nil {
+		return nil, domain.WrapValidationError("level", fmt.Errorf("level or json is required"))
+	}
+	// Validate here so a bad level is a 400 even when the change is for another node
+	if req.Level != "" {
+		if _, err := logger.ParseLevel(req.Level); err != nil {
+			return nil, domain.WrapValidationError("level", err)
+		}
+	}
+
+	if nodeID != "" && nodeID != s.config.Node.ID {
+		n, err := s.database.GetNode(nodeID)
+		if err != nil {
+			return nil, domain.WrapNodeNotFound(nodeID, err)
+		}
+		s.logger.InfoContext(ctx, "updating log settings on remote node", "nodeID", nodeID, "level", req.Level)
+		return s.nodeClient.UpdateLogSettings(n, req)
+	}
+
+	if req.Level != "" {
+		if err := logger.ApplyLevel(s.config.Environment, req.Level); err != nil {
+			return nil, domain.WrapValidationError("level", err)
+		}
+	}
+	if req.JSON != nil {
+		logger.SetJSON(*req.JSON)
+	}
+
+	settings := s.localLogSettings()
+	s.logger.InfoContext(ctx, "log settings changed", "level", settings.Level, "json", settings.JSON)
+	return settings, nil
+}
+
+func (s *systemService) localLogSettings() *domain.LogSettings {
+	return &domain.LogSettings{
+		NodeID: s.config.Node.ID,
+		Level:  strings.ToLower(logger.Level().String()),
+		JSON:   logger.JSON(),
+	}
+}
@@ -2,15 +2,20 @@ package service
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"log/slog"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"testing"
+	"time"
 
 	"github.com/selfhostly/internal/config"
 	"github.com/selfhostly/internal/db"
 	"github.com/selfhostly/internal/docker"
 	"github.com/selfhostly/internal/domain"
+	"github.com/selfhostly/internal/logger"
 )
 
 // setupTestSystemService creates a test system service with mocked dependencies
@@ -192,6 +197,47 @@ func TestSystemService_GetAppStats_NotFound(t *testing.T) {
 	}
 }
 
+func TestSystemService_StreamAppStats(t *testing.T) {
+	mockExecutor := docker.NewMockCommandExecutor()
+	service, database, cleanup := setupTestSystemService(t, mockExecutor)
+	defer cleanup()
+
+	app := db.NewApp("test-app", "Test application", "version: '3'\nservices:\n  web:\n    image: nginx:latest")
+	app.Status = "running"
+	if err := database.CreateApp(app); err != nil {
+		t.Fatalf("Failed to create app: %v", err)
+	}
+
+	mockExecutor.SetMockOutput("docker", []string{"compose", "-f", "docker-compose.yml", "ps", "-q"}, []byte("container-123\n"))
+	mockExecutor.SetMockOutput("docker", []string{"stats", "--no-trunc", "--format", "{{.Container}}|{{.Name}}|{{.CPUPerc}}|{{.MemUsage}}|{{.MemPerc}}|{{.NetIO}}|{{.BlockIO}}", "container-123"},
+		[]byte("container-123|test-app-web-1|1.5%|100MiB / 2GiB|4.88%|1.2MB / 3.4MB|5.6MB / 7.8MB\n"))
+
+	var samples []*domain.AppStats
+	err := service.StreamAppStats(context.Background(), app.ID, time.Hour, func(stats *domain.AppStats) error {
+		samples = append(samples, stats)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if len(samples) != 1 || len(samples[0].Containers) != 1 {
+		t.Fatalf("Expected one sample with one container, got %+v", samples)
+	}
+	if samples[0].Containers[0].Name != "test-app-web-1" || samples[0].Status != "running" {
+		t.Errorf("Unexpected sample: %+v", samples[0])
+	}
+
+	// A missing app fails before any sample is sent
+	err = service.StreamAppStats(context.Background(), "non-existent-id", time.Hour, func(*domain.AppStats) error {
+		t.Error("Expected no samples for a missing app")
+		return nil
+	})
+	if !domain.IsNotFoundError(err) {
+		t.Errorf("Expected not found error, got %v", err)
+	}
+}
+
 func TestSystemService_GetAppLogs(t *testing.T) {
 	mockExecutor := docker.NewMockCommandExecutor()
 	service, database, cleanup := setupTestSystemService(t, mockExecutor)
@@ -406,3 +452,116 @@ func TestSystemService_DeleteContainer_DockerError(t *testing.T) {
 
 // Note: GetSystemStats tests would require mocking the system.Collector which is more complex.
 // For now, we focus on testing the service methods that use Docker commands directly.
+
+func TestSystemService_SearchLogs(t *testing.T) {
+	mockExecutor := docker.NewMockCommandExecutor()
+	service, database, cleanup := setupTestSystemService(t, mockExecutor)
+	defer cleanup()
+
+	ctx := context.Background()
+
+	app := db.NewApp("test-app", "Test application", "version: '3'\nservices:\n  web:\n    image: nginx:latest")
+	app.Status = "running"
+	app.NodeID = "test-node-id"
+	if err := database.CreateApp(app); err != nil {
+		t.Fatalf("Failed to create app: %v", err)
+	}
+
+	logs := "web-1  | 2024-01-15T10:00:00Z starting\n" +
+		"web-1  | 2024-01-15T10:00:01Z connecting to db\n" +
+		"web-1  | 2024-01-15T10:00:02Z ERROR connection refused\n" +
+		"web-1  | 2024-01-15T10:00:03Z retrying\n" +
+		"web-1  | 2024-01-15T10:00:04Z error again\n"
+	mockExecutor.SetMockOutput("docker", docker.ComposeLogsSinceCommand(2000, "15m")[1:], []byte(logs))
+
+	matches, err := service.SearchLogs(ctx, domain.LogSearchRequest{Query: "error", Since: "15m", ContextLines: 1}, []string{"test-node-id"})
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	if len(matches) != 2 {
+		t.Fatalf("Expected 2 matches, got %d", len(matches))
+	}
+
+	// Newest first
+	if matches[0].Line != "error again" || matches[1].Line != "ERROR connection refused" {
+		t.Errorf("Unexpected match order: %q, %q", matches[0].Line, matches[1].Line)
+	}
+	if matches[1].AppName != "test-app" || matches[1].Service != "web" || matches[1].NodeID != "test-node-id" {
+		t.Errorf("Match not tagged correctly: %+v", matches[1])
+	}
+	if len(matches[1].Before) != 1 || matches[1].Before[0] != "connecting to db" {
+		t.Errorf("Expected one line of context before, got %v", matches[1].Before)
+	}
+	if len(matches[0].After) != 0 {
+		t.Errorf("Expected no context after last line, got %v", matches[0].After)
+	}
+}
+
+func TestSystemService_SearchLogs_Validation(t *testing.T) {
+	service, _, cleanup := setupTestSystemService(t, docker.NewMockCommandExecutor())
+	defer cleanup()
+
+	ctx := context.Background()
+
+	if _, err := service.SearchLogs(ctx, domain.LogSearchRequest{Query: "  "}, nil); !domain.IsValidationError(err) {
+		t.Errorf("Expected validation error for empty query, got %v", err)
+	}
+
+	if _, err := service.SearchLogs(ctx, domain.LogSearchRequest{Query: "x", Since: "yesterday"}, nil); !domain.IsValidationError(err) {
+		t.Errorf("Expected validation error for invalid since, got %v", err)
+	}
+}
+
+func TestSystemService_UpdateLogSettings(t *testing.T) {
+	service, database, cleanup := setupTestSystemService(t, docker.NewMockCommandExecutor())
+	defer cleanup()
+	defer logger.SetJSON(false)
+	defer logger.ApplyLevel("", "")
+
+	ctx := context.Background()
+	useJSON := true
+
+	settings, err := service.UpdateLogSettings(ctx, "", domain.UpdateLogSettingsRequest{Level: "debug", JSON: &useJSON})
+	if err != nil {
+		t.Fatalf("UpdateLogSettings: %v", err)
+	}
+	if settings.NodeID != "test-node-id" || settings.Level != "debug" || !settings.JSON {
+		t.Errorf("Expected debug JSON logging on this node, got %+v", settings)
+	}
+
+	if _, err := service.UpdateLogSettings(ctx, "", domain.UpdateLogSettingsRequest{Level: "verbose"}); !domain.IsValidationError(err) {
+		t.Errorf("Expected validation error for unknown level, got %v", err)
+	}
+	if _, err := service.UpdateLogSettings(ctx, "", domain.UpdateLogSettingsRequest{}); !domain.IsValidationError(err) {
+		t.Errorf("Expected validation error for empty request, got %v", err)
+	}
+	if _, err := service.UpdateLogSettings(ctx, "missing-node", domain.UpdateLogSettingsRequest{Level: "info"}); !domain.IsNotFoundError(err) {
+		t.Errorf("Expected not found error for unknown node, got %v", err)
+	}
+
+	// Other nodes are changed through the node client
+	var got domain.UpdateLogSettingsRequest
+	remote := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPut || r.URL.Path != "/api/system/log-level" || r.Header.Get("X-Node-ID") != "remote-node" {
+			t.Errorf("Unexpected request %s %s", r.Method, r.URL.Path)
+		}
+		_ = json.NewDecoder(r.Body).Decode(&got)
+		_ = json.NewEncoder(w).Encode(domain.LogSettings{NodeID: "remote-node", Level: got.Level})
+	}))
+	defer remote.Close()
+	if err := database.CreateNode(db.NewNodeWithID("remote-node", "remote", remote.URL, "remote-key", false)); err != nil {
+		t.Fatalf("CreateNode: %v", err)
+	}
+
+	settings, err = service.UpdateLogSettings(ctx, "remote-node", domain.UpdateLogSettingsRequest{Level: "warn"})
+	if err != nil {
+		t.Fatalf("UpdateLogSettings remote: %v", err)
+	}
+	if got.Level != "warn" || settings.NodeID != "remote-node" || settings.Level != "warn" {
+		t.Errorf("Expected the remote node to get level warn, sent %+v and got %+v", got, settings)
+	}
+	if logger.Level() != slog.LevelDebug {
+		t.Errorf("Expected this node's level to stay debug, got %v", logger.Level())
+	}
+}
@@ -0,0 +1,230 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/selfhostly/internal/constants"
+	"github.com/selfhostly/internal/db"
+	"github.com/selfhostly/internal/docker"
+	"github.com/selfhostly/internal/domain"
+)
+
+// taskService manages recurring tasks of the apps on this node. Runs are app_run jobs, so they
+// queue behind other operations on the app and their history outlives job cleanup.
+type taskService struct {
+	database      *db.DB
+	dockerManager *docker.Manager
+	logger        *slog.Logger
+}
+
+// NewTaskService creates a new task service
+func NewTaskService(database *db.DB, dockerManager *docker.Manager, logger *slog.Logger) domain.TaskService {
+	return &taskService{
+		database:      database,
+		dockerManager: dockerManager,
+		logger:        logger,
+	}
+}
+
+// ListTasks returns an app's recurring tasks
+func (s *taskService) ListTasks(ctx context.Context, appID string) ([]*db.AppTask, error) {
+	if _, err := s.database.GetApp(appID); err != nil {
+		return nil, domain.WrapAppNotFound(appID, err)
+	}
+	tasks, err := s.database.GetTasksByAppID(appID)
+	if err != nil {
+		return nil, domain.WrapDatabaseOperation("get tasks", err)
+	}
+	return tasks, nil
+}
+
+// CreateTask adds a recurring task after checking its command, schedule and service
+func (s *taskService) CreateTask(ctx context.Context, appID string, req domain.CreateTaskRequest) (*db.AppTask, error) {
+	app, err := s.database.GetApp(appID)
+	if err != nil {
+		return nil, domain.WrapAppNotFound(appID, err)
+	}
+
+	task := db.NewAppTask(appID, strings.TrimSpace(req.Name), req.Service, req.Command, req.Mode, req.Cron, req.Timezone, req.TimeoutSeconds)
+	if req.Enabled != nil {
+		task.Enabled = *req.Enabled
+	}
+	if err := s.validateTask(app, task); err != nil {
+		return nil, err
+	}
+
+	if err := s.database.CreateTask(task); err != nil {
+		return nil, domain.WrapDatabaseOperation("create task", err)
+	}
+
+	s.logger.InfoContext(ctx, "task created", "appID", appID, "taskID", task.ID, "name", task.Name, "cron", task.Cron)
+	return task, nil
+}
+
+// UpdateTask changes a recurring task's definition, schedule or enabled state
+func (s *taskService) UpdateTask(ctx context.Context, appID, taskID string, req domain.UpdateTaskRequest) (*db.AppTask, error) {
+	app, err := s.database.GetApp(appID)
+	if err != nil {
+		return nil, domain.WrapAppNotFound(appID, err)
+	}
+	task, err := s.getTask(appID, taskID)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Name != nil {
+		task.Name = strings.TrimSpace(*req.Name)
+	}
+	if req.Service != nil {
+		task.Service = *req.Service
+	}
+	if req.Command != nil {
+		task.Command = req.Command
+	}
+	if req.Mode != nil {
+		task.Mode = *req.Mode
+	}
+	if req.Cron != nil {
+		task.Cron = *req.Cron
+	}
+	if req.Timezone != nil {
+		task.Timezone = *req.Timezone
+	}
+	if req.TimeoutSeconds != nil {
+		task.TimeoutSeconds = *req.TimeoutSeconds
+	}
+	if req.Enabled != nil {
+		task.Enabled = *req.Enabled
+	}
+	if err := s.validateTask(app, task); err != nil {
+		return nil, err
+	}
+	task.UpdatedAt = time.Now()
+
+	if err := s.database.UpdateTask(task); err != nil {
+		return nil, domain.WrapDatabaseOperation("update task", err)
+	}
+
+	s.logger.InfoContext(ctx, "task updated", "appID", appID, "taskID", taskID, "enabled", task.Enabled)
+	return task, nil
+}
+
+// DeleteTask removes a recurring task and its run history
+func (s *taskService) DeleteTask(ctx context.Context, appID, taskID string) error {
+	if err := s.database.DeleteTask(appID, taskID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return domain.WrapTaskNotFound(taskID, err)
+		}
+		return domain.WrapDatabaseOperation("delete task", err)
+	}
+	s.logger.InfoContext(ctx, "task deleted", "appID", appID, "taskID", taskID)
+	return nil
+}
+
+// GetTaskRuns returns a task's recent runs, newest first
+func (s *taskService) GetTaskRuns(ctx context.Context, appID, taskID string) ([]*db.AppTaskRun, error) {
+	if _, err := s.getTask(appID, taskID); err != nil {
+		return nil, err
+	}
+	runs, err := s.database.GetTaskRuns(taskID, constants.TaskRunHistoryLimit)
+	if err != nil {
+		return nil, domain.WrapDatabaseOperation("get task runs", err)
+	}
+	return runs, nil
+}
+
+// RunTaskAsync queues a run of a task now, whether or not it is enabled
+func (s *taskService) RunTaskAsync(ctx context.Context, appID, taskID string) (*db.Job, error) {
+	task, err := s.getTask(appID, taskID)
+	if err != nil {
+		return nil, err
+	}
+	job, err := createRunJob(s.database, appID, taskRunRequest(task), task.ID)
+	if err != nil {
+		return nil, err
+	}
+	s.logger.InfoContext(ctx, "created task run job", "appID", appID, "taskID", taskID, "jobID", job.ID)
+	return job, nil
+}
+
+// CreateTaskJob queues a scheduled run of a task, unless its previous run hasn't finished
+func (s *taskService) CreateTaskJob(ctx context.Context, taskID string) error {
+	task, err := s.database.GetTaskByID(taskID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return domain.WrapTaskNotFound(taskID, err)
+		}
+		return fmt.Errorf("failed to get task: %w", err)
+	}
+	if !task.Enabled {
+		return nil
+	}
+
+	active, err := s.database.GetActiveTaskJob(taskID)
+	if err != nil {
+		s.logger.WarnContext(ctx, "failed to check for a running task job", "taskID", taskID, "error", err)
+	}
+	if active != nil {
+		s.logger.WarnContext(ctx, "skipping scheduled task run, previous run still active", "appID", task.AppID, "taskID", taskID, "jobID", active.ID)
+		return nil
+	}
+
+	job, err := createRunJob(s.database, task.AppID, taskRunRequest(task), task.ID)
+	if err != nil {
+		return err
+	}
+	s.logger.InfoContext(ctx, "created scheduled task run job", "appID", task.AppID, "taskID", taskID, "jobID", job.ID)
+	return nil
+}
+
+// validateTask checks a task's fields, filling in the default mode and timezone
+func (s *taskService) validateTask(app *db.App, task *db.AppTask) error {
+	if task.Name == "" {
+		return domain.WrapValidationError("name", fmt.Errorf("name is required"))
+	}
+	req := taskRunRequest(task)
+	if err := normalizeRunCommand(&req); err != nil {
+		return err
+	}
+	task.Mode = req.Mode
+
+	if err := validateCronExpression(task.Cron); err != nil {
+		return domain.WrapValidationError("cron", err)
+	}
+	if task.Timezone == "" {
+		task.Timezone = "UTC"
+	}
+	if _, err := time.LoadLocation(task.Timezone); err != nil {
+		return domain.WrapValidationError("timezone", fmt.Errorf("invalid timezone: %w", err))
+	}
+
+	return checkAppService(s.dockerManager, app, task.Service)
+}
+
+// getTask loads one of an app's tasks
+func (s *taskService) getTask(appID, taskID string) (*db.AppTask, error) {
+	task, err := s.database.GetTask(appID, taskID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, domain.WrapTaskNotFound(taskID, err)
+		}
+		return nil, domain.WrapDatabaseOperation("get task", err)
+	}
+	return task, nil
+}
+
+// taskRunRequest is the one-off command a run of task executes
+func taskRunRequest(task *db.AppTask) domain.RunAppCommandRequest {
+	return domain.RunAppCommandRequest{
+		Service:        task.Service,
+		Command:        task.Command,
+		Mode:           task.Mode,
+		TimeoutSeconds: task.TimeoutSeconds,
+	}
+}
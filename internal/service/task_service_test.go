@@ -0,0 +1,147 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"path/filepath"
+	"testing"
+
+	"github.com/selfhostly/internal/constants"
+	"github.com/selfhostly/internal/db"
+	"github.com/selfhostly/internal/docker"
+	"github.com/selfhostly/internal/domain"
+)
+
+func setupTestTaskService(t *testing.T) (domain.TaskService, *db.DB, *db.App) {
+	t.Helper()
+	tmpDir := t.TempDir()
+
+	database, err := db.Init(filepath.Join(tmpDir, "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to initialize database: %v", err)
+	}
+	t.Cleanup(func() { database.Close() })
+
+	app := db.NewApp("task-app", "", "services:\n  web:\n    image: nginx:latest\n")
+	if err := database.CreateApp(app); err != nil {
+		t.Fatalf("Failed to create app: %v", err)
+	}
+
+	mockExecutor := docker.NewMockCommandExecutor()
+	mockExecutor.SetMockOutput("docker", []string{"compose", "-f", "docker-compose.yml", "config", "--services"}, []byte("web\n"))
+	dockerManager := docker.NewManagerWithExecutor(filepath.Join(tmpDir, "apps"), mockExecutor)
+	if err := dockerManager.CreateAppDirectory(app.Name, app.ComposeContent); err != nil {
+		t.Fatalf("Failed to create app directory: %v", err)
+	}
+
+	return NewTaskService(database, dockerManager, slog.Default()), database, app
+}
+
+func TestTaskService_CreateAndUpdate(t *testing.T) {
+	svc, _, app := setupTestTaskService(t)
+	ctx := context.Background()
+
+	valid := domain.CreateTaskRequest{Name: "cleanup", Service: "web", Command: []string{"php", "artisan", "schedule:run"}, Cron: "*/5 * * * *"}
+	invalid := map[string]func(req *domain.CreateTaskRequest){
+		"no name":         func(req *domain.CreateTaskRequest) { req.Name = " " },
+		"no command":      func(req *domain.CreateTaskRequest) { req.Command = nil },
+		"bad cron":        func(req *domain.CreateTaskRequest) { req.Cron = "every minute" },
+		"bad timezone":    func(req *domain.CreateTaskRequest) { req.Timezone = "Mars/Olympus" },
+		"bad timeout":     func(req *domain.CreateTaskRequest) { req.TimeoutSeconds = -1 },
+		"unknown service": func(req *domain.CreateTaskRequest) { req.Service = "worker" },
+	}
+	for name, mutate := range invalid {
+		req := valid
+		mutate(&req)
+		if _, err := svc.CreateTask(ctx, app.ID, req); !domain.IsValidationError(err) {
+			t.Errorf("%s: expected validation error, got %v", name, err)
+		}
+	}
+	if _, err := svc.CreateTask(ctx, "missing", valid); !domain.IsNotFoundError(err) {
+		t.Errorf("expected not found error for unknown app, got %v", err)
+	}
+
+	task, err := svc.CreateTask(ctx, app.ID, valid)
+	if err != nil {
+		t.Fatalf("CreateTask: %v", err)
+	}
+	if task.Mode != constants.RunModeRun || task.Timezone != "UTC" || !task.Enabled {
+		t.Errorf("expected defaults to be filled in, got %+v", task)
+	}
+
+	disabled := false
+	schedule := "0 3 * * *"
+	updated, err := svc.UpdateTask(ctx, app.ID, task.ID, domain.UpdateTaskRequest{Cron: &schedule, Enabled: &disabled})
+	if err != nil {
+		t.Fatalf("UpdateTask: %v", err)
+	}
+	if updated.Cron != schedule || updated.Enabled || len(updated.Command) != 3 {
+		t.Errorf("unexpected updated task %+v", updated)
+	}
+
+	tasks, err := svc.ListTasks(ctx, app.ID)
+	if err != nil || len(tasks) != 1 || tasks[0].Cron != schedule {
+		t.Fatalf("ListTasks = %+v, %v", tasks, err)
+	}
+
+	if err := svc.DeleteTask(ctx, app.ID, task.ID); err != nil {
+		t.Fatalf("DeleteTask: %v", err)
+	}
+	if err := svc.DeleteTask(ctx, app.ID, task.ID); !domain.IsNotFoundError(err) {
+		t.Errorf("expected not found error deleting twice, got %v", err)
+	}
+}
+
+func TestTaskService_RunJobs(t *testing.T) {
+	svc, database, app := setupTestTaskService(t)
+	ctx := context.Background()
+
+	task, err := svc.CreateTask(ctx, app.ID, domain.CreateTaskRequest{
+		Name: "backup", Service: "web", Command: []string{"backup.sh"}, Mode: constants.RunModeExec, Cron: "@daily", TimeoutSeconds: 60,
+	})
+	if err != nil {
+		t.Fatalf("CreateTask: %v", err)
+	}
+
+	job, err := svc.RunTaskAsync(ctx, app.ID, task.ID)
+	if err != nil {
+		t.Fatalf("RunTaskAsync: %v", err)
+	}
+	var payload struct {
+		TaskID         string `json:"task_id"`
+		Mode           string `json:"mode"`
+		TimeoutSeconds int    `json:"timeout_seconds"`
+	}
+	if job.Type != constants.JobTypeAppRun || job.Payload == nil {
+		t.Fatalf("unexpected job %+v", job)
+	}
+	if err := json.Unmarshal([]byte(*job.Payload), &payload); err != nil {
+		t.Fatalf("Failed to decode payload: %v", err)
+	}
+	if payload.TaskID != task.ID || payload.Mode != constants.RunModeExec || payload.TimeoutSeconds != 60 {
+		t.Errorf("unexpected payload %+v", payload)
+	}
+
+	// The manual run is still pending, so the scheduled one is skipped
+	if err := svc.CreateTaskJob(ctx, task.ID); err != nil {
+		t.Fatalf("CreateTaskJob: %v", err)
+	}
+	if jobs, _ := database.GetJobsByAppID(app.ID, 10); len(jobs) != 1 {
+		t.Errorf("expected the overlapping run to be skipped, got %d jobs", len(jobs))
+	}
+
+	if err := database.UpdateJobStatus(job.ID, constants.JobStatusCompleted, 100, nil); err != nil {
+		t.Fatalf("Failed to complete job: %v", err)
+	}
+	if err := svc.CreateTaskJob(ctx, task.ID); err != nil {
+		t.Fatalf("CreateTaskJob: %v", err)
+	}
+	if jobs, _ := database.GetJobsByAppID(app.ID, 10); len(jobs) != 2 {
+		t.Errorf("expected a scheduled run to be queued, got %d jobs", len(jobs))
+	}
+
+	if err := svc.CreateTaskJob(ctx, "missing"); !domain.IsNotFoundError(err) {
+		t.Errorf("expected not found error for unknown task, got %v", err)
+	}
+}
@@ -0,0 +1,170 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sort"
+
+	"github.com/google/uuid"
+	"github.com/selfhostly/internal/config"
+	"github.com/selfhostly/internal/constants"
+	"github.com/selfhostly/internal/db"
+	"github.com/selfhostly/internal/domain"
+	"github.com/selfhostly/internal/platform"
+	"github.com/selfhostly/internal/version"
+)
+
+// telemetryService builds and sends opt-in anonymous usage reports
+type telemetryService struct {
+	database   *db.DB
+	config     *config.Config
+	httpClient *http.Client
+	logger     *slog.Logger
+}
+
+// NewTelemetryService creates a new telemetry service
+func NewTelemetryService(database *db.DB, cfg *config.Config, logger *slog.Logger) domain.TelemetryService {
+	return &telemetryService{
+		database:   database,
+		config:     cfg,
+		httpClient: &http.Client{Timeout: constants.TelemetryReportTimeout},
+		logger:     logger,
+	}
+}
+
+// Preview returns exactly the report that would be sent, whether or not telemetry is enabled
+func (s *telemetryService) Preview(ctx context.Context) (*domain.TelemetryReport, error) {
+	settings, err := s.database.GetSettings()
+	if err != nil {
+		return nil, domain.WrapDatabaseOperation("get settings", err)
+	}
+	return s.buildReport(settings)
+}
+
+// SendReport posts a usage report to the configured endpoint. It does nothing unless telemetry
+// has been enabled in settings and an endpoint is configured.
+func (s *telemetryService) SendReport(ctx context.Context) error {
+	settings, err := s.database.GetSettings()
+	if err != nil {
+		return domain.WrapDatabaseOperation("get settings", err)
+	}
+	if !settings.TelemetryEnabled {
+		return nil
+	}
+	if s.config.TelemetryEndpoint == "" {
+		s.logger.DebugContext(ctx, "telemetry enabled but TELEMETRY_ENDPOINT is not set, skipping report")
+		return nil
+	}
+
+	report, err := s.buildReport(settings)
+	if err != nil {
+		return err
+	}
+	body, err := json.Marshal(report)
+	if err != nil {
+		return fmt.Errorf("failed to encode telemetry report: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.config.TelemetryEndpoint, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create telemetry request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send telemetry report: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("telemetry endpoint returned status %d", resp.StatusCode)
+	}
+
+	s.logger.InfoContext(ctx, "telemetry report sent", "nodeCount", report.NodeCount, "appCount", report.AppCount)
+	return nil
+}
+
+// buildReport collects the report contents. The install ID is generated the first time a report is
+// built with telemetry on; until then previews leave it empty, so nothing identifies an install
+// that never opted in.
+func (s *telemetryService) buildReport(settings *db.Settings) (*domain.TelemetryReport, error) {
+	if settings.TelemetryID == "" && settings.TelemetryEnabled {
+		settings.TelemetryID = uuid.New().String()
+		if err := s.database.UpdateSettings(settings); err != nil {
+			return nil, domain.WrapDatabaseOperation("save telemetry id", err)
+		}
+	}
+
+	nodes, err := s.database.GetAllNodes()
+	if err != nil {
+		return nil, domain.WrapDatabaseOperation("get nodes", err)
+	}
+	apps, err := s.database.GetAllAppsMetadata()
+	if err != nil {
+		return nil, domain.WrapDatabaseOperation("get apps", err)
+	}
+
+	appCount := len(apps)
+	for _, n := range nodes {
+		if n.ID == s.config.Node.ID {
+			continue
+		}
+		cached, err := s.database.GetNodeAppCache(n.ID)
+		if err != nil {
+			return nil, domain.WrapDatabaseOperation("get cached apps", err)
+		}
+		appCount += len(cached)
+	}
+
+	providers := map[string]bool{}
+	for _, app := range apps {
+		switch app.TunnelMode {
+		case constants.TunnelModeCustom:
+			providers[settings.GetActiveProviderName()] = true
+		case constants.TunnelModeQuick:
+			providers["quick_tunnel"] = true
+		}
+	}
+	tunnelProviders := make([]string, 0, len(providers))
+	for p := range providers {
+		tunnelProviders = append(tunnelProviders, p)
+	}
+	sort.Strings(tunnelProviders)
+
+	nodeCount := len(nodes)
+	if nodeCount == 0 {
+		nodeCount = 1 // this node, before it has registered itself
+	}
+
+	return &domain.TelemetryReport{
+		InstallID:       settings.TelemetryID,
+		Version:         version.Get(),
+		OS:              platform.OS(),
+		Arch:            platform.Arch(),
+		NodeCount:       countBucket(nodeCount),
+		AppCount:        countBucket(appCount),
+		TunnelProviders: tunnelProviders,
+	}, nil
+}
+
+// countBucket coarsens a count into a range so reports stay anonymous
+func countBucket(n int) string {
+	switch {
+	case n <= 0:
+		return "0"
+	case n == 1:
+		return "1"
+	case n <= 5:
+		return "2-5"
+	case n <= 20:
+		return "6-20"
+	case n <= 50:
+		return "21-50"
+	default:
+		return "50+"
+	}
+}
@@ -0,0 +1,99 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+
+	"github.com/selfhostly/internal/config"
+	"github.com/selfhostly/internal/constants"
+	"github.com/selfhostly/internal/db"
+	"github.com/selfhostly/internal/domain"
+)
+
+func TestTelemetryService(t *testing.T) {
+	database, err := db.Init(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to initialize database: %v", err)
+	}
+	defer database.Close()
+
+	for i, mode := range []string{constants.TunnelModeCustom, constants.TunnelModeQuick, constants.TunnelModeNone} {
+		app := db.NewApp("app-"+string(rune('a'+i)), "", "services: {}")
+		app.TunnelMode = mode
+		if err := database.CreateApp(app); err != nil {
+			t.Fatalf("CreateApp: %v", err)
+		}
+	}
+
+	var received []domain.TelemetryReport
+	endpoint := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var report domain.TelemetryReport
+		if err := json.NewDecoder(r.Body).Decode(&report); err != nil {
+			t.Errorf("failed to decode report: %v", err)
+		}
+		received = append(received, report)
+	}))
+	defer endpoint.Close()
+
+	cfg := &config.Config{Node: config.NodeConfig{ID: "primary-node", IsPrimary: true}, TelemetryEndpoint: endpoint.URL}
+	svc := NewTelemetryService(database, cfg, slog.Default())
+	ctx := context.Background()
+
+	preview, err := svc.Preview(ctx)
+	if err != nil {
+		t.Fatalf("Preview: %v", err)
+	}
+	if preview.InstallID != "" || preview.NodeCount != "1" || preview.AppCount != "2-5" {
+		t.Errorf("unexpected preview %+v", preview)
+	}
+	if len(preview.TunnelProviders) != 2 || preview.TunnelProviders[0] != constants.ProviderCloudflare || preview.TunnelProviders[1] != "quick_tunnel" {
+		t.Errorf("unexpected tunnel providers %v", preview.TunnelProviders)
+	}
+
+	// Nothing is sent until telemetry is enabled
+	if err := svc.SendReport(ctx); err != nil {
+		t.Fatalf("SendReport: %v", err)
+	}
+	if len(received) != 0 {
+		t.Fatalf("expected no report while disabled, got %d", len(received))
+	}
+
+	settings, err := database.GetSettings()
+	if err != nil {
+		t.Fatalf("GetSettings: %v", err)
+	}
+	if settings.TelemetryID != "" {
+		t.Errorf("expected no install ID before telemetry is enabled, got %q", settings.TelemetryID)
+	}
+	settings.TelemetryEnabled = true
+	if err := database.UpdateSettings(settings); err != nil {
+		t.Fatalf("UpdateSettings: %v", err)
+	}
+
+	if err := svc.SendReport(ctx); err != nil {
+		t.Fatalf("SendReport: %v", err)
+	}
+	if len(received) != 1 {
+		t.Fatalf("expected 1 report, got %d", len(received))
+	}
+	if preview, err = svc.Preview(ctx); err != nil {
+		t.Fatalf("Preview: %v", err)
+	}
+	if received[0].InstallID == "" || received[0].InstallID != preview.InstallID || received[0].AppCount != preview.AppCount {
+		t.Errorf("sent report %+v differs from preview %+v", received[0], preview)
+	}
+}
+
+func TestCountBucket(t *testing.T) {
+	tests := map[int]string{0: "0", 1: "1", 3: "2-5", 20: "6-20", 21: "21-50", 51: "50+"}
+	for n, want := range tests {
+		if got := countBucket(n); got != want {
+			t.Errorf("countBucket(%d) = %q, want %q", n, got, want)
+		}
+	}
+}
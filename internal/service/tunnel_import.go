@@ -0,0 +1,174 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/selfhostly/internal/applock"
+	"github.com/selfhostly/internal/constants"
+	"github.com/selfhostly/internal/db"
+	"github.com/selfhostly/internal/docker"
+	"github.com/selfhostly/internal/domain"
+	"github.com/selfhostly/internal/tunnel"
+)
+
+// importedAppPlaceholderCompose is the compose of an app created around an imported tunnel when
+// the caller doesn't supply one. The tunnel keeps routing to whatever its ingress points at.
+const importedAppPlaceholderCompose = `# Created when importing an existing tunnel. Replace this service with
+# the services the tunnel's ingress rules point at.
+services:
+  placeholder:
+    image: busybox:latest
+    command: ["sleep", "infinity"]
+    restart: unless-stopped
+`
+
+// ImportTunnel adopts a tunnel that already exists in the provider account. The tunnel's token
+// and ingress are fetched from the provider and the app gets a tunnel sidecar for it, as if the
+// tunnel had been created here. Running apps are redeployed so the sidecar starts.
+func (s *appService) ImportTunnel(ctx context.Context, req domain.ImportTunnelRequest) (*db.App, error) {
+	req.TunnelID = strings.TrimSpace(req.TunnelID)
+	if req.TunnelID == "" {
+		return nil, domain.WrapValidationError("tunnel_id", fmt.Errorf("tunnel_id is required"))
+	}
+	if req.AppID == "" && strings.TrimSpace(req.AppName) == "" {
+		return nil, domain.WrapValidationError("app_id", fmt.Errorf("either app_id or app_name is required"))
+	}
+	s.logger.InfoContext(ctx, "importing tunnel", "tunnelID", req.TunnelID, "appID", req.AppID, "appName", req.AppName)
+
+	accountProvider, err := s.accountTunnelProvider()
+	if err != nil {
+		return nil, err
+	}
+	existing, err := s.database.GetCloudflareTunnelByTunnelID(req.TunnelID)
+	if err == nil {
+		return nil, domain.WrapTunnelInUse(req.TunnelID, fmt.Sprintf("app %s already uses it", existing.AppID))
+	}
+	if err != sql.ErrNoRows {
+		return nil, domain.WrapDatabaseOperation("get tunnel", err)
+	}
+
+	appID := req.AppID
+	if appID == "" {
+		composeContent := req.ComposeContent
+		if strings.TrimSpace(composeContent) == "" {
+			composeContent = importedAppPlaceholderCompose
+		}
+		shell, err := s.CreateApp(ctx, domain.CreateAppRequest{Name: req.AppName, ComposeContent: composeContent})
+		if err != nil {
+			return nil, err
+		}
+		app, err := s.attachImportedTunnel(ctx, accountProvider, shell.ID, req.TunnelID)
+		if err != nil {
+			s.removeImportShell(ctx, shell)
+			return nil, err
+		}
+		return app, nil
+	}
+	return s.attachImportedTunnel(ctx, accountProvider, appID, req.TunnelID)
+}
+
+// accountTunnelProvider returns the active tunnel provider if it can manage tunnels by ID
+func (s *appService) accountTunnelProvider() (tunnel.AccountProvider, error) {
+	settings, err := s.database.GetSettings()
+	if err != nil {
+		return nil, err
+	}
+	providerName := settings.GetActiveProviderName()
+	providerConfig, err := settings.GetProviderConfig(providerName)
+	if err != nil || providerConfig == nil {
+		return nil, fmt.Errorf("tunnel provider not configured: %w", err)
+	}
+	provider, err := s.providerRegistry.GetProvider(providerName, providerConfig)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create tunnel provider: %w", err)
+	}
+	accountProvider, ok := provider.(tunnel.AccountProvider)
+	if !ok {
+		return nil, domain.WrapValidationError("provider", tunnel.NewFeatureNotSupportedError(providerName, tunnel.FeatureAccount))
+	}
+	return accountProvider, nil
+}
+
+// attachImportedTunnel records the tunnel for the app and regenerates its tunnel sidecar
+func (s *appService) attachImportedTunnel(ctx context.Context, provider tunnel.AccountProvider, appID, tunnelID string) (*db.App, error) {
+	ctx, unlock, err := applock.Acquire(ctx, s.database, appID, "tunnel import")
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	app, err := s.database.GetApp(appID)
+	if err != nil {
+		return nil, domain.WrapAppNotFound(appID, err)
+	}
+	if app.NodeID != "" && app.NodeID != s.config.Node.ID {
+		return nil, fmt.Errorf("app belongs to node %s, not %s", app.NodeID, s.config.Node.ID)
+	}
+	if app.TunnelMode != "" {
+		return nil, domain.WrapValidationError("app_id", fmt.Errorf("app already has a %s tunnel; delete it before importing another", app.TunnelMode))
+	}
+
+	imported, err := provider.ImportTunnel(ctx, tunnelID, app.ID)
+	if err != nil {
+		if errors.Is(err, tunnel.ErrTunnelNotFound) {
+			return nil, domain.ErrTunnelNotFound
+		}
+		return nil, fmt.Errorf("failed to import tunnel %s: %w", tunnelID, err)
+	}
+
+	app.TunnelID = imported.TunnelID
+	app.TunnelToken = imported.TunnelToken
+	app.TunnelMode = constants.TunnelModeCustom
+	app.PublicURL = imported.PublicURL
+	app.TunnelDomain = strings.TrimPrefix(imported.PublicURL, "https://")
+	if containerProvider, ok := provider.(tunnel.ContainerProvider); ok {
+		if containerConfig := containerProvider.GetContainerConfig(imported.TunnelToken, app.Name); containerConfig != nil {
+			app.ComposeContent, app.TunnelCompose, err = docker.GenerateTunnelCompose(app.ComposeContent, app.ComposeFiles, app.Name, containerConfig)
+			if err != nil {
+				return nil, domain.WrapComposeInvalid(err)
+			}
+		}
+	}
+	app.UpdatedAt = time.Now()
+	if err := s.database.UpdateApp(app); err != nil {
+		return nil, domain.WrapDatabaseOperation("update app", err)
+	}
+
+	if !isAppUp(app.Status) {
+		// Stopped apps only need their files; the sidecar starts with the app
+		if err := s.dockerManager.WriteComposeFile(app.Name, app.ComposeContent); err != nil {
+			return nil, domain.WrapContainerOperationFailed("write compose file", err)
+		}
+		if err := s.dockerManager.WriteTunnelComposeFile(app.Name, app.TunnelCompose); err != nil {
+			return nil, domain.WrapContainerOperationFailed("write tunnel compose file", err)
+		}
+		s.logger.InfoContext(ctx, "tunnel imported", "app", app.Name, "tunnelID", tunnelID, "publicURL", app.PublicURL)
+		return app, nil
+	}
+
+	if _, err := s.UpdateAppContainers(ctx, app.ID, s.config.Node.ID); err != nil {
+		s.logger.WarnContext(ctx, "tunnel imported but UpdateAppContainers failed", "appID", app.ID, "error", err)
+	}
+	if err := s.dockerManager.ForceRecreateTunnel(app.Name); err != nil {
+		s.logger.WarnContext(ctx, "could not force-recreate tunnel container", "app", app.Name, "error", err)
+	}
+	s.logger.InfoContext(ctx, "tunnel imported", "app", app.Name, "tunnelID", tunnelID, "publicURL", app.PublicURL)
+	return app, nil
+}
+
+// removeImportShell deletes an app created for an import that then failed, so a retry can reuse
+// the name
+func (s *appService) removeImportShell(ctx context.Context, app *db.App) {
+	if err := s.database.DeleteApp(app.ID); err != nil {
+		s.logger.WarnContext(ctx, "failed to remove app created for tunnel import", "app", app.Name, "error", err)
+		return
+	}
+	if err := s.dockerManager.DeleteAppDirectory(app.Name); err != nil {
+		s.logger.WarnContext(ctx, "failed to remove app directory created for tunnel import", "app", app.Name, "error", err)
+	}
+}
@@ -0,0 +1,226 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/selfhostly/internal/constants"
+	"github.com/selfhostly/internal/db"
+	"github.com/selfhostly/internal/domain"
+	"github.com/selfhostly/internal/tunnel"
+)
+
+// nodeTunnelRecords is one node's tunnel records, or why they couldn't be read
+type nodeTunnelRecords struct {
+	node    *db.Node
+	tunnels []*db.CloudflareTunnel
+	err     error
+}
+
+// ListTunnelInventory merges the tunnel records of every node with the tunnels in the active
+// provider's account. A tunnel in the account that no node knows about is flagged as orphaned,
+// but only when every node answered and it has no running connectors.
+func (s *tunnelService) ListTunnelInventory(ctx context.Context) (*domain.TunnelInventory, error) {
+	provider, err := s.getActiveProvider()
+	if err != nil {
+		return nil, err
+	}
+	return s.tunnelInventory(ctx, provider)
+}
+
+func (s *tunnelService) tunnelInventory(ctx context.Context, provider tunnel.Provider) (*domain.TunnelInventory, error) {
+	nodes, err := s.database.GetAllNodes()
+	if err != nil {
+		return nil, domain.WrapDatabaseOperation("get nodes", err)
+	}
+
+	inventory := &domain.TunnelInventory{
+		Provider: provider.Name(),
+		Complete: true,
+		Tunnels:  []*domain.TunnelInventoryItem{},
+	}
+	items := make(map[string]*domain.TunnelInventoryItem)
+	itemFor := func(tunnelID string) *domain.TunnelInventoryItem {
+		item, ok := items[tunnelID]
+		if !ok {
+			item = &domain.TunnelInventoryItem{TunnelID: tunnelID}
+			items[tunnelID] = item
+			inventory.Tunnels = append(inventory.Tunnels, item)
+		}
+		return item
+	}
+
+	for _, records := range s.collectTunnelRecords(nodes) {
+		if records.err != nil {
+			s.logger.WarnContext(ctx, "tunnel inventory incomplete", "nodeID", records.node.ID, "nodeName", records.node.Name, "error", records.err)
+			inventory.Complete = false
+			inventory.UnreachableNodes = append(inventory.UnreachableNodes, records.node.ID)
+			continue
+		}
+		appNames := s.appNamesOnNode(records.node)
+		for _, t := range records.tunnels {
+			item := itemFor(t.TunnelID)
+			item.TunnelName = t.TunnelName
+			item.Status = t.Status
+			item.NodeID = records.node.ID
+			item.AppID = t.AppID
+			item.AppName = appNames[t.AppID]
+			item.PublicURL = t.PublicURL
+			item.Tracked = true
+			createdAt := t.CreatedAt
+			item.CreatedAt = &createdAt
+		}
+	}
+
+	// Apps can still point at a tunnel whose record is gone; that tunnel isn't orphaned either
+	apps, err := s.database.GetAllAppsMetadata()
+	if err != nil {
+		return nil, domain.WrapDatabaseOperation("list apps", err)
+	}
+	for _, app := range apps {
+		if app.TunnelID == "" || app.TunnelMode == constants.TunnelModeQuick {
+			continue
+		}
+		item := itemFor(app.TunnelID)
+		if !item.Tracked {
+			item.NodeID = app.NodeID
+			item.AppID = app.ID
+			item.AppName = app.Name
+			item.PublicURL = app.PublicURL
+			item.Tracked = true
+		}
+	}
+
+	if accountProvider, ok := provider.(tunnel.AccountProvider); ok {
+		accountTunnels, err := accountProvider.ListAccountTunnels(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list %s tunnels: %w", provider.DisplayName(), err)
+		}
+		inventory.AccountListed = true
+		for _, t := range accountTunnels {
+			item := itemFor(t.TunnelID)
+			item.InAccount = true
+			item.Status = t.Status
+			if item.TunnelName == "" {
+				item.TunnelName = t.TunnelName
+			}
+			if item.CreatedAt == nil && !t.CreatedAt.IsZero() {
+				createdAt := t.CreatedAt
+				item.CreatedAt = &createdAt
+			}
+		}
+	}
+
+	for _, item := range inventory.Tunnels {
+		item.Orphaned = item.InAccount && !item.Tracked && inventory.Complete && !tunnelHasConnectors(item.Status)
+		if item.Orphaned {
+			inventory.OrphanCount++
+		}
+	}
+
+	slices.SortFunc(inventory.Tunnels, func(a, b *domain.TunnelInventoryItem) int {
+		if a.Orphaned != b.Orphaned {
+			if a.Orphaned {
+				return -1
+			}
+			return 1
+		}
+		return strings.Compare(a.TunnelName, b.TunnelName)
+	})
+	return inventory, nil
+}
+
+// collectTunnelRecords reads the tunnel records of every node in parallel. Nodes already known
+// to be offline are reported as failed without being contacted.
+func (s *tunnelService) collectTunnelRecords(nodes []*db.Node) []*nodeTunnelRecords {
+	results := make([]*nodeTunnelRecords, len(nodes))
+	var wg sync.WaitGroup
+	for i, n := range nodes {
+		results[i] = &nodeTunnelRecords{node: n}
+		switch {
+		case n.ID == s.config.Node.ID:
+			results[i].tunnels, results[i].err = s.database.ListActiveCloudflareTunnels()
+		case n.Status == constants.NodeStatusOffline || n.Status == constants.NodeStatusUnreachable || n.Status == constants.NodeStatusLost:
+			results[i].err = fmt.Errorf("node is %s", n.Status)
+		default:
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				results[i].tunnels, results[i].err = s.nodeClient.GetTunnels(n)
+			}()
+		}
+	}
+	wg.Wait()
+	return results
+}
+
+// appNamesOnNode maps app IDs to names for a node, from the local database or the primary's
+// cached inventory of a remote node. Missing names are left blank.
+func (s *tunnelService) appNamesOnNode(n *db.Node) map[string]string {
+	names := make(map[string]string)
+	if n.ID == s.config.Node.ID {
+		apps, err := s.database.GetAllAppsMetadata()
+		if err == nil {
+			for _, app := range apps {
+				names[app.ID] = app.Name
+			}
+		}
+		return names
+	}
+	cached, err := s.database.GetNodeAppCache(n.ID)
+	if err == nil {
+		for _, app := range cached {
+			names[app.AppID] = app.Name
+		}
+	}
+	return names
+}
+
+// tunnelHasConnectors reports whether the provider sees connectors running for a tunnel, which
+// means something still serves traffic through it even if no app here claims it
+func tunnelHasConnectors(status string) bool {
+	return status == constants.TunnelStatusHealthy || status == constants.TunnelStatusDegraded
+}
+
+// DeleteOrphanedTunnel deletes a tunnel from the provider account, refusing unless the
+// inventory currently flags it as orphaned
+func (s *tunnelService) DeleteOrphanedTunnel(ctx context.Context, tunnelID string) error {
+	provider, err := s.getActiveProvider()
+	if err != nil {
+		return err
+	}
+	accountProvider, ok := provider.(tunnel.AccountProvider)
+	if !ok {
+		return domain.WrapValidationError("provider", fmt.Errorf("%w: %s cannot delete tunnels by ID", tunnel.ErrFeatureNotSupported, provider.DisplayName()))
+	}
+
+	inventory, err := s.tunnelInventory(ctx, provider)
+	if err != nil {
+		return err
+	}
+	idx := slices.IndexFunc(inventory.Tunnels, func(item *domain.TunnelInventoryItem) bool {
+		return item.TunnelID == tunnelID && item.InAccount
+	})
+	if idx < 0 {
+		return domain.ErrTunnelNotFound
+	}
+
+	item := inventory.Tunnels[idx]
+	switch {
+	case item.Tracked:
+		return domain.WrapTunnelInUse(tunnelID, fmt.Sprintf("app %s on node %s uses it; delete the app's tunnel instead", item.AppID, item.NodeID))
+	case !inventory.Complete:
+		return domain.WrapTunnelInUse(tunnelID, fmt.Sprintf("nodes %s did not respond, so it may belong to one of their apps", strings.Join(inventory.UnreachableNodes, ", ")))
+	case tunnelHasConnectors(item.Status):
+		return domain.WrapTunnelInUse(tunnelID, fmt.Sprintf("it has running connectors (status %s)", item.Status))
+	}
+
+	if err := accountProvider.DeleteAccountTunnel(ctx, tunnelID); err != nil {
+		return fmt.Errorf("failed to delete tunnel %s: %w", tunnelID, err)
+	}
+	s.logger.InfoContext(ctx, "deleted orphaned tunnel", "tunnelID", tunnelID, "tunnelName", item.TunnelName)
+	return nil
+}
@@ -3,11 +3,16 @@ package service
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"log/slog"
+	"path/filepath"
+	"slices"
 	"strings"
 	"time"
 
+	"github.com/selfhostly/internal/applock"
+	"github.com/selfhostly/internal/chaos"
 	"github.com/selfhostly/internal/cloudflare"
 	"github.com/selfhostly/internal/config"
 	"github.com/selfhostly/internal/constants"
@@ -18,6 +23,9 @@ import (
 	"github.com/selfhostly/internal/routing"
 	"github.com/selfhostly/internal/tunnel"
 	cloudflareProvider "github.com/selfhostly/internal/tunnel/providers/cloudflare"
+	fakeProvider "github.com/selfhostly/internal/tunnel/providers/fake"
+	frpProvider "github.com/selfhostly/internal/tunnel/providers/frp"
+	ngrokProvider "github.com/selfhostly/internal/tunnel/providers/ngrok"
 )
 
 // tunnelService implements the TunnelService interface
@@ -35,7 +43,7 @@ type tunnelService struct {
 
 // NewTunnelService creates a new tunnel service with provider registry
 func NewTunnelService(database *db.DB, dockerManager *docker.Manager, cfg *config.Config, logger *slog.Logger) domain.TunnelService {
-	nodeClient := node.NewClient()
+	nodeClient := node.NewClientWithTimeouts(cfg.Timeouts)
 	router := routing.NewNodeRouter(database, nodeClient, cfg.Node.ID, logger)
 	tunnelsAgg := routing.NewTunnelsAggregator(router, logger)
 
@@ -50,6 +58,25 @@ func NewTunnelService(database *db.DB, dockerManager *docker.Manager, cfg *confi
 		return cloudflareProvider.NewProvider(config)
 	})
 
+	// Register ngrok provider
+	registry.Register(constants.ProviderNgrok, func(config map[string]interface{}) (tunnel.Provider, error) {
+		config["database"] = database
+		config["logger"] = logger
+		return ngrokProvider.NewProvider(config)
+	})
+
+	// Register frp provider
+	registry.Register(constants.ProviderFrp, func(config map[string]interface{}) (tunnel.Provider, error) {
+		config["database"] = database
+		config["logger"] = logger
+		return frpProvider.NewProvider(config)
+	})
+
+	// Chaos mode simulates tunnels without provider credentials
+	if cfg.Chaos.Enabled {
+		registerFakeProvider(registry, database, cfg, logger)
+	}
+
 	return &tunnelService{
 		database:         database,
 		dockerManager:    dockerManager,
@@ -62,10 +89,22 @@ func NewTunnelService(database *db.DB, dockerManager *docker.Manager, cfg *confi
 	}
 }
 
+// registerFakeProvider registers the fake tunnel provider of chaos mode, failing and delaying
+// its operations with the process's shared injector
+func registerFakeProvider(registry *tunnel.Registry, database *db.DB, cfg *config.Config, logger *slog.Logger) {
+	injector := chaos.Shared(cfg.Chaos)
+	registry.Register(constants.ProviderFake, func(config map[string]interface{}) (tunnel.Provider, error) {
+		config["database"] = database
+		config["chaos"] = injector
+		config["logger"] = logger
+		return fakeProvider.NewProvider(config)
+	})
+}
+
 // NewTunnelServiceWithManager creates a new tunnel service with a custom tunnel manager (for testing)
 // DEPRECATED: Use NewTunnelService with provider registry instead
 func NewTunnelServiceWithManager(database *db.DB, cfg *config.Config, logger *slog.Logger, tunnelManager *cloudflare.TunnelManager) domain.TunnelService {
-	nodeClient := node.NewClient()
+	nodeClient := node.NewClientWithTimeouts(cfg.Timeouts)
 	router := routing.NewNodeRouter(database, nodeClient, cfg.Node.ID, logger)
 	tunnelsAgg := routing.NewTunnelsAggregator(router, logger)
 
@@ -266,6 +305,57 @@ func (a *cloudflareManagerAdapter) GetContainerConfig(tunnelToken string, appNam
 	}
 }
 
+// AccountProvider interface
+func (a *cloudflareManagerAdapter) ListAccountTunnels(ctx context.Context) ([]*tunnel.Tunnel, error) {
+	return cloudflareProvider.ListAccountTunnels(ctx, a.manager)
+}
+
+func (a *cloudflareManagerAdapter) DeleteAccountTunnel(ctx context.Context, tunnelID string) error {
+	return cloudflareProvider.DeleteAccountTunnel(ctx, a.manager, a.database, a.logger, tunnelID)
+}
+
+func (a *cloudflareManagerAdapter) ImportTunnel(ctx context.Context, tunnelID, appID string) (*tunnel.Tunnel, error) {
+	cfTunnel, err := a.manager.ImportTunnel(ctx, tunnelID, appID)
+	if err != nil {
+		if errors.Is(err, cloudflare.ErrNotFound) {
+			return nil, tunnel.ErrTunnelNotFound
+		}
+		return nil, err
+	}
+	return a.toGenericTunnel(cfTunnel), nil
+}
+
+// ZoneProvider interface
+func (a *cloudflareManagerAdapter) ListZones(ctx context.Context) ([]*tunnel.Zone, error) {
+	return cloudflareProvider.ListZones(ctx, a.manager)
+}
+
+func (a *cloudflareManagerAdapter) ListHostnames(ctx context.Context, zoneID string) ([]*tunnel.Hostname, error) {
+	return cloudflareProvider.ListHostnames(ctx, a.manager, zoneID)
+}
+
+// ReplicaProvider interface
+func (a *cloudflareManagerAdapter) MaxReplicas() int {
+	return cloudflareProvider.MaxTunnelReplicas
+}
+
+// ConnectorProvider interface
+func (a *cloudflareManagerAdapter) ListConnectors(ctx context.Context, appID string) ([]*tunnel.Connector, error) {
+	cfTunnel, err := a.database.GetCloudflareTunnelByAppID(appID)
+	if err != nil {
+		return nil, tunnel.ErrTunnelNotFound
+	}
+	return cloudflareProvider.ListConnectors(ctx, a.manager, cfTunnel.TunnelID)
+}
+
+func (a *cloudflareManagerAdapter) GetTraffic(ctx context.Context, appID string, since, until time.Time) (*tunnel.Traffic, error) {
+	cfTunnel, err := a.database.GetCloudflareTunnelByAppID(appID)
+	if err != nil {
+		return nil, tunnel.ErrTunnelNotFound
+	}
+	return cloudflareProvider.GetTraffic(ctx, a.manager, cfTunnel, since, until)
+}
+
 // Helper
 func (a *cloudflareManagerAdapter) toGenericTunnel(cfTunnel *db.CloudflareTunnel) *tunnel.Tunnel {
 	return &tunnel.Tunnel{
@@ -363,6 +453,132 @@ func (s *tunnelService) SyncTunnelStatus(ctx context.Context, appID string, node
 	return nil
 }
 
+// GetTunnelConnectorStatus reports which connectors serve an app's named tunnel and where they
+// connect to the provider's edge (local only)
+func (s *tunnelService) GetTunnelConnectorStatus(ctx context.Context, appID string, nodeID string) (*domain.TunnelConnectorStatus, error) {
+	s.logger.DebugContext(ctx, "getting tunnel connector status", "appID", appID, "nodeID", nodeID)
+
+	app, err := s.database.GetApp(appID)
+	if err != nil {
+		return nil, domain.WrapAppNotFound(appID, err)
+	}
+	if app.TunnelMode == constants.TunnelModeQuick {
+		return nil, domain.WrapValidationError("tunnel", fmt.Errorf("app uses a Quick Tunnel, which has no named tunnel to report connectors for"))
+	}
+
+	provider, err := s.getActiveProvider()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get provider: %w", err)
+	}
+	connectorProvider, ok := provider.(tunnel.ConnectorProvider)
+	if !ok {
+		return nil, domain.WrapValidationError("provider", tunnel.NewFeatureNotSupportedError(provider.Name(), tunnel.FeatureConnectors))
+	}
+
+	cfTunnel, err := s.database.GetCloudflareTunnelByAppID(appID)
+	if err != nil {
+		return nil, domain.ErrTunnelNotFound
+	}
+	connectors, err := connectorProvider.ListConnectors(ctx, appID)
+	if err != nil {
+		if errors.Is(err, tunnel.ErrTunnelNotFound) {
+			return nil, domain.ErrTunnelNotFound
+		}
+		return nil, fmt.Errorf("failed to list tunnel connectors: %w", err)
+	}
+
+	status := summarizeTunnelConnectors(connectors)
+	status.AppID = appID
+	status.Provider = provider.Name()
+	status.TunnelID = cfTunnel.TunnelID
+	status.TunnelName = cfTunnel.TunnelName
+	status.CheckedAt = time.Now()
+	return status, nil
+}
+
+// trafficPeriods are the ranges GetAppTraffic reports over, by the period clients pass
+var trafficPeriods = map[string]time.Duration{
+	"1h":  time.Hour,
+	"24h": 24 * time.Hour,
+	"7d":  7 * 24 * time.Hour,
+}
+
+// GetAppTraffic reports the HTTP traffic the provider's edge served for an app's tunnel hostnames
+// over period (1h, 24h or 7d; default 24h) (local only; gateway routes to this node)
+func (s *tunnelService) GetAppTraffic(ctx context.Context, appID string, nodeID string, period string) (*domain.AppTraffic, error) {
+	s.logger.DebugContext(ctx, "getting app traffic", "appID", appID, "nodeID", nodeID, "period", period)
+
+	if period == "" {
+		period = "24h"
+	}
+	duration, ok := trafficPeriods[period]
+	if !ok {
+		return nil, domain.WrapValidationError("period", fmt.Errorf("period must be 1h, 24h or 7d"))
+	}
+	app, err := s.database.GetApp(appID)
+	if err != nil {
+		return nil, domain.WrapAppNotFound(appID, err)
+	}
+	if app.TunnelMode == constants.TunnelModeQuick {
+		return nil, domain.WrapValidationError("tunnel", fmt.Errorf("app uses a Quick Tunnel, whose trycloudflare.com hostname has no analytics"))
+	}
+
+	provider, err := s.getActiveProvider()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get provider: %w", err)
+	}
+	trafficProvider, ok := provider.(tunnel.TrafficProvider)
+	if !ok {
+		return nil, domain.WrapValidationError("provider", tunnel.NewFeatureNotSupportedError(provider.Name(), tunnel.FeatureTraffic))
+	}
+
+	until := time.Now().UTC().Truncate(time.Minute)
+	traffic, err := trafficProvider.GetTraffic(ctx, appID, until.Add(-duration), until)
+	if err != nil {
+		if errors.Is(err, tunnel.ErrTunnelNotFound) {
+			return nil, domain.ErrTunnelNotFound
+		}
+		return nil, fmt.Errorf("failed to get traffic: %w", err)
+	}
+	return &domain.AppTraffic{AppID: appID, Provider: provider.Name(), Period: period, Traffic: traffic}, nil
+}
+
+// summarizeTunnelConnectors derives the state, edge locations and newest active connection of a
+// tunnel from its connectors
+func summarizeTunnelConnectors(connectors []*tunnel.Connector) *domain.TunnelConnectorStatus {
+	status := &domain.TunnelConnectorStatus{
+		State:          constants.TunnelConnectorStateNone,
+		ConnectorCount: len(connectors),
+		Locations:      []string{},
+		Connectors:     connectors,
+	}
+	if status.Connectors == nil {
+		status.Connectors = []*tunnel.Connector{}
+	}
+	if len(connectors) > 0 {
+		status.State = constants.TunnelConnectorStateReconnecting
+	}
+	for _, connector := range connectors {
+		if connector.IsConnected() {
+			status.State = constants.TunnelConnectorStateHealthy
+		}
+		for _, conn := range connector.Connections {
+			if conn.PendingReconnect {
+				continue
+			}
+			if conn.Location != "" && !slices.Contains(status.Locations, conn.Location) {
+				status.Locations = append(status.Locations, conn.Location)
+			}
+			if !conn.OpenedAt.IsZero() && (status.LastConnectedAt == nil || conn.OpenedAt.After(*status.LastConnectedAt)) {
+				openedAt := conn.OpenedAt
+				status.LastConnectedAt = &openedAt
+			}
+		}
+	}
+	slices.Sort(status.Locations)
+	return status
+}
+
 // UpdateTunnelIngress updates the ingress configuration for a tunnel (if supported) (local only)
 func (s *tunnelService) UpdateTunnelIngress(ctx context.Context, appID string, nodeID string, req domain.UpdateIngressRequest) error {
 	s.logger.InfoContext(ctx, "updating tunnel ingress", "appID", appID, "nodeID", nodeID)
@@ -374,13 +590,87 @@ func (s *tunnelService) UpdateTunnelIngress(ctx context.Context, appID string, n
 	if !ok {
 		return tunnel.NewFeatureNotSupportedError(provider.DisplayName(), tunnel.FeatureIngress)
 	}
+	sidecarProvider, ok := provider.(tunnel.SidecarIngressProvider)
+	if !ok || !sidecarProvider.IngressInSidecar() {
+		if err := ingressProvider.UpdateIngress(ctx, appID, req.IngressRules); err != nil {
+			return fmt.Errorf("failed to update ingress: %w", err)
+		}
+		s.logger.InfoContext(ctx, "tunnel ingress updated successfully", "appID", appID)
+		return nil
+	}
+
+	// The rules are part of the sidecar's config, so they are saved and the sidecar rewritten
+	// under the app lock, and the old rules put back if the rewrite fails
+	ctx, unlock, err := applock.Acquire(ctx, s.database, appID, "tunnel ingress")
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	current, err := provider.GetTunnelByAppID(ctx, appID)
+	if err != nil {
+		return fmt.Errorf("failed to update ingress: %w", err)
+	}
 	if err := ingressProvider.UpdateIngress(ctx, appID, req.IngressRules); err != nil {
 		return fmt.Errorf("failed to update ingress: %w", err)
 	}
+	if err := s.regenerateIngressSidecar(ctx, appID, sidecarProvider); err != nil {
+		if restoreErr := ingressProvider.UpdateIngress(ctx, appID, current.IngressRules); restoreErr != nil {
+			s.logger.ErrorContext(ctx, "failed to restore tunnel ingress rules", "appID", appID, "error", restoreErr)
+		} else if regenErr := s.regenerateIngressSidecar(ctx, appID, sidecarProvider); regenErr != nil {
+			s.logger.WarnContext(ctx, "failed to rewrite tunnel sidecar with restored rules", "appID", appID, "error", regenErr)
+		}
+		return err
+	}
 	s.logger.InfoContext(ctx, "tunnel ingress updated successfully", "appID", appID)
 	return nil
 }
 
+// regenerateIngressSidecar rewrites an app's tunnel sidecar after its rules changed, for
+// providers whose rules are part of the container's config, and restarts it if the app is running.
+// The caller holds the app lock.
+func (s *tunnelService) regenerateIngressSidecar(ctx context.Context, appID string, provider tunnel.SidecarIngressProvider) error {
+	app, err := s.database.GetApp(appID)
+	if err != nil {
+		return domain.WrapAppNotFound(appID, err)
+	}
+	containerConfig := provider.GetContainerConfig(app.TunnelToken, app.Name)
+	if containerConfig == nil {
+		return domain.WrapValidationError("provider", tunnel.NewFeatureNotSupportedError(provider.Name(), tunnel.FeatureContainer))
+	}
+	containerConfig.Replicas = docker.TunnelReplicas(app.TunnelComposeSource())
+	if t, err := provider.GetTunnelByAppID(ctx, appID); err == nil && t.PublicURL != "" {
+		app.PublicURL = t.PublicURL
+	}
+	if err := rewriteTunnelSidecar(s.database, s.dockerManager, app, containerConfig); err != nil {
+		return err
+	}
+	s.logger.InfoContext(ctx, "tunnel sidecar regenerated for new ingress rules", "app", app.Name, "appID", appID)
+	return nil
+}
+
+// CheckIngressTargets compares ingress rules with the services and ports in the app's compose (local only)
+func (s *tunnelService) CheckIngressTargets(ctx context.Context, appID string, rules []db.IngressRule) ([]domain.IngressWarning, error) {
+	app, err := s.database.GetApp(appID)
+	if err != nil {
+		return nil, domain.WrapAppNotFound(appID, err)
+	}
+	services := make([]string, len(rules))
+	for i, rule := range rules {
+		services[i] = rule.Service
+	}
+	warnings, err := docker.CheckIngressTargets(app.ComposeContent, app.ComposeOverride, app.ComposeFiles, services)
+	if err != nil {
+		return nil, domain.WrapComposeInvalid(err)
+	}
+	result := make([]domain.IngressWarning, len(warnings))
+	for i, w := range warnings {
+		s.logger.WarnContext(ctx, "ingress rule target not found in compose", "appID", appID, "rule", w.Rule, "service", w.Service, "reason", w.Message)
+		result[i] = domain.IngressWarning{Rule: w.Rule, Service: w.Service, Message: w.Message}
+	}
+	return result, nil
+}
+
 // CreateDNSRecord creates a DNS record for a tunnel (if supported) (local only)
 func (s *tunnelService) CreateDNSRecord(ctx context.Context, appID string, nodeID string, req domain.CreateDNSRequest) error {
 	s.logger.InfoContext(ctx, "creating DNS record", "appID", appID, "hostname", req.Hostname, "nodeID", nodeID)
@@ -406,6 +696,11 @@ func (s *tunnelService) CreateDNSRecord(ctx context.Context, appID string, nodeI
 // DeleteTunnel deletes a tunnel (local only)
 func (s *tunnelService) DeleteTunnel(ctx context.Context, appID string, nodeID string) error {
 	s.logger.InfoContext(ctx, "deleting tunnel", "appID", appID, "nodeID", nodeID)
+	ctx, unlock, err := applock.Acquire(ctx, s.database, appID, "tunnel deletion")
+	if err != nil {
+		return err
+	}
+	defer unlock()
 	
 	// Get app details for tunnel operations
 	app, getErr := s.database.GetApp(appID)
@@ -453,7 +748,7 @@ func (s *tunnelService) DeleteTunnel(ctx context.Context, appID string, nodeID s
 	return nil
 }
 
-// cleanupTunnelFromCompose removes the tunnel service from the compose file after successful tunnel deletion
+// cleanupTunnelFromCompose removes the tunnel sidecar from the app after successful tunnel deletion
 func (s *tunnelService) cleanupTunnelFromCompose(ctx context.Context, appID string) {
 	if s.dockerManager == nil {
 		return
@@ -465,25 +760,31 @@ func (s *tunnelService) cleanupTunnelFromCompose(ctx context.Context, appID stri
 		return
 	}
 	
-	// Remove tunnel service from compose
-	compose, parseErr := docker.ParseCompose([]byte(app.ComposeContent))
-	if parseErr != nil {
-		s.logger.WarnContext(ctx, "failed to parse compose for cleanup", "app_id", appID, "error", parseErr)
-		return
+	// Drop the generated tunnel sidecar
+	if app.TunnelCompose != "" {
+		app.TunnelCompose = ""
+		app.UpdatedAt = time.Now()
+		if updateErr := s.database.UpdateApp(app); updateErr != nil {
+			s.logger.WarnContext(ctx, "failed to clear tunnel compose after tunnel removal", "app_id", appID, "error", updateErr)
+			return
+		}
+		if writeErr := s.dockerManager.WriteTunnelComposeFile(app.Name, ""); writeErr != nil {
+			s.logger.WarnContext(ctx, "failed to remove tunnel compose file after tunnel removal", "app", app.Name, "error", writeErr)
+		}
 	}
 	
-	if !docker.RemoveTunnelService(compose) {
-		s.logger.InfoContext(ctx, "no tunnel service found in compose file (already removed)", "app_id", appID)
+	// Apps created before the sidecar had its own file carry the tunnel service inline
+	newContent, removed, stripErr := docker.StripTunnelService(app.ComposeContent)
+	if stripErr != nil {
+		s.logger.WarnContext(ctx, "failed to parse compose for cleanup", "app_id", appID, "error", stripErr)
 		return
 	}
 	
-	composeBytes, marshalErr := docker.MarshalComposeFile(compose)
-	if marshalErr != nil {
-		s.logger.WarnContext(ctx, "failed to marshal compose after tunnel removal", "app_id", appID, "error", marshalErr)
+	if !removed {
+		s.logger.InfoContext(ctx, "no tunnel service found in compose file (already removed)", "app_id", appID)
 		return
 	}
 	
-	newContent := string(composeBytes)
 	app.ComposeContent = newContent
 	app.UpdatedAt = time.Now()
 	if updateErr := s.database.UpdateApp(app); updateErr != nil {
@@ -496,6 +797,8 @@ func (s *tunnelService) cleanupTunnelFromCompose(ctx context.Context, appID stri
 	_ = s.database.MarkAllVersionsAsNotCurrent(appID)
 	reason := "Tunnel removed"
 	newVersion := db.NewComposeVersion(appID, latestVersion+1, newContent, &reason, nil)
+	newVersion.ComposeOverride = app.ComposeOverride
+	newVersion.ComposeFiles = app.ComposeFiles
 	_ = s.database.CreateComposeVersion(newVersion)
 	
 	// Write updated compose file
@@ -599,12 +902,19 @@ func (s *tunnelService) GetProviderFeatures(ctx context.Context, providerName st
 
 	// Convert Feature type to string for domain layer
 	featuresMap := map[string]bool{
-		"ingress":      features[tunnel.FeatureIngress],
-		"dns":          features[tunnel.FeatureDNS],
-		"status_sync":  features[tunnel.FeatureStatusSync],
-		"container":    features[tunnel.FeatureContainer],
-		"list":         features[tunnel.FeatureList],
-		"quick_tunnel": features[tunnel.FeatureQuickTunnel],
+		"ingress":           features[tunnel.FeatureIngress],
+		"dns":               features[tunnel.FeatureDNS],
+		"status_sync":       features[tunnel.FeatureStatusSync],
+		"container":         features[tunnel.FeatureContainer],
+		"list":              features[tunnel.FeatureList],
+		"quick_tunnel":      features[tunnel.FeatureQuickTunnel],
+		"health_check":      features[tunnel.FeatureHealthCheck],
+		"account":           features[tunnel.FeatureAccount],
+		"zones":             features[tunnel.FeatureZones],
+		"connectors":        features[tunnel.FeatureConnectors],
+		"replicas":          features[tunnel.FeatureReplicas],
+		"connector_options": features[tunnel.FeatureConnectorOptions],
+		"traffic":           features[tunnel.FeatureTraffic],
 	}
 
 	return &domain.ProviderFeatures{
@@ -615,6 +925,83 @@ func (s *tunnelService) GetProviderFeatures(ctx context.Context, providerName st
 	}, nil
 }
 
+// CheckProviderHealth verifies the active provider's API is reachable with the configured credentials.
+// Delegates to HealthCheckProvider if the active provider supports it.
+func (s *tunnelService) CheckProviderHealth(ctx context.Context) (string, error) {
+	provider, err := s.getActiveProvider()
+	if err != nil {
+		return "", err
+	}
+
+	healthProvider, ok := provider.(tunnel.HealthCheckProvider)
+	if !ok {
+		return provider.Name(), fmt.Errorf("%w: %s", tunnel.ErrFeatureNotSupported, tunnel.FeatureHealthCheck)
+	}
+
+	return provider.Name(), healthProvider.CheckHealth(ctx)
+}
+
+// zoneProvider returns the named provider, built from its saved configuration, if it can list zones
+func (s *tunnelService) zoneProvider(providerName string) (tunnel.ZoneProvider, error) {
+	var provider tunnel.Provider
+	if s.tunnelManager != nil {
+		// Tests inject a cloudflare manager instead of a registry
+		provider = newCloudflareProviderFromManager(s.tunnelManager, s.database, s.logger)
+	} else {
+		if s.providerRegistry == nil || !s.providerRegistry.IsRegistered(providerName) {
+			return nil, domain.WrapValidationError("provider", fmt.Errorf("%w: %s", tunnel.ErrProviderNotFound, providerName))
+		}
+		settings, err := s.database.GetSettings()
+		if err != nil {
+			return nil, fmt.Errorf("failed to get settings: %w", err)
+		}
+		providerConfig, err := settings.GetProviderConfig(providerName)
+		if err != nil {
+			return nil, domain.WrapValidationError("provider", fmt.Errorf("%w: %s", tunnel.ErrProviderNotConfigured, providerName))
+		}
+		if provider, err = s.providerRegistry.GetProvider(providerName, providerConfig); err != nil {
+			return nil, fmt.Errorf("failed to create provider %s: %w", providerName, err)
+		}
+	}
+
+	zoneProvider, ok := provider.(tunnel.ZoneProvider)
+	if !ok {
+		return nil, domain.WrapValidationError("provider", tunnel.NewFeatureNotSupportedError(providerName, tunnel.FeatureZones))
+	}
+	return zoneProvider, nil
+}
+
+// ListProviderZones lists the DNS zones the provider's credentials can see, sorted by name
+func (s *tunnelService) ListProviderZones(ctx context.Context, providerName string) ([]*tunnel.Zone, error) {
+	provider, err := s.zoneProvider(providerName)
+	if err != nil {
+		return nil, err
+	}
+	zones, err := provider.ListZones(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list %s zones: %w", provider.DisplayName(), err)
+	}
+	slices.SortFunc(zones, func(a, b *tunnel.Zone) int { return strings.Compare(a.Name, b.Name) })
+	return zones, nil
+}
+
+// ListZoneHostnames lists the DNS records in a zone, sorted by hostname
+func (s *tunnelService) ListZoneHostnames(ctx context.Context, providerName, zoneID string) ([]*tunnel.Hostname, error) {
+	provider, err := s.zoneProvider(providerName)
+	if err != nil {
+		return nil, err
+	}
+	hostnames, err := provider.ListHostnames(ctx, zoneID)
+	if err != nil {
+		if errors.Is(err, tunnel.ErrZoneNotFound) {
+			return nil, domain.WrapZoneNotFound(zoneID, err)
+		}
+		return nil, fmt.Errorf("failed to list hostnames in zone %s: %w", zoneID, err)
+	}
+	slices.SortFunc(hostnames, func(a, b *tunnel.Hostname) int { return strings.Compare(a.Name, b.Name) })
+	return hostnames, nil
+}
+
 // ExtractQuickTunnelURL extracts the public URL from a Quick Tunnel (local only).
 // Delegates to QuickTunnelProvider if the active provider supports it.
 func (s *tunnelService) ExtractQuickTunnelURL(ctx context.Context, appID string, nodeID string) (string, error) {
@@ -641,7 +1028,7 @@ func (s *tunnelService) ExtractQuickTunnelURL(ctx context.Context, appID string,
 
 	// Extract URL using provider's implementation
 	commandExecutor := s.dockerManager.GetCommandExecutor()
-	url, err := quickTunnelProvider.ExtractQuickTunnelURL(ctx, app.Name, app.ComposeContent, s.config.AppsDir, commandExecutor)
+	url, err := quickTunnelProvider.ExtractQuickTunnelURL(ctx, app.Name, app.TunnelComposeSource(), filepath.Dir(s.dockerManager.AppPath(app.Name)), commandExecutor)
 	if err != nil {
 		return "", fmt.Errorf("failed to extract Quick Tunnel URL: %w", err)
 	}
@@ -675,7 +1062,7 @@ func (s *tunnelService) CreateQuickTunnelConfig(targetService string, targetPort
 }
 
 // NextFreeQuickTunnelMetricsPort returns a host port in [2000, 2999]
-// that is not already used by any app's Quick Tunnel metrics. Used to avoid port conflicts when running multiple quick tunnels.
+// that is not already used by any app's Quick Tunnel metrics or reserved on this node. Used to avoid port conflicts when running multiple quick tunnels.
 func (s *tunnelService) NextFreeQuickTunnelMetricsPort() (int, error) {
 	const (
 		quickTunnelMetricsPortMin = 2000
@@ -689,10 +1076,19 @@ func (s *tunnelService) NextFreeQuickTunnelMetricsPort() (int, error) {
 
 	used := make(map[int]bool)
 	for _, app := range apps {
-		if p, ok := docker.ExtractQuickTunnelMetricsHostPort(app.ComposeContent); ok {
+		if p, ok := docker.ExtractQuickTunnelMetricsHostPort(app.TunnelComposeSource()); ok {
 			used[p] = true
 		}
 	}
+	reservations, err := s.database.GetPortReservations(s.config.Node.ID)
+	if err != nil {
+		return quickTunnelMetricsPortMin, fmt.Errorf("failed to get port reservations for port allocation: %w", err)
+	}
+	for _, reservation := range reservations {
+		if reservation.Protocol == "tcp" {
+			used[reservation.Port] = true
+		}
+	}
 
 	for p := quickTunnelMetricsPortMin; p <= quickTunnelMetricsPortMax; p++ {
 		if !used[p] {
@@ -6,11 +6,13 @@ import (
 	"log/slog"
 	"net/http"
 	"os"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/selfhostly/internal/cloudflare"
 	"github.com/selfhostly/internal/config"
+	"github.com/selfhostly/internal/constants"
 	"github.com/selfhostly/internal/db"
 	"github.com/selfhostly/internal/domain"
 )
@@ -310,6 +312,59 @@ func TestTunnelService_UpdateTunnelIngress_NotFound(t *testing.T) {
 	}
 }
 
+func TestTunnelService_UpdateTunnelIngress_SidecarRewriteFails(t *testing.T) {
+	_, database, _, cleanup := setupTestTunnelService(t)
+	defer cleanup()
+
+	settings, err := database.GetSettings()
+	if err != nil {
+		t.Fatalf("Failed to get settings: %v", err)
+	}
+	activeProvider := constants.ProviderFrp
+	settings.ActiveTunnelProvider = &activeProvider
+	if err := settings.SetProviderConfig(constants.ProviderFrp, map[string]interface{}{"server_addr": "vps.example.com", "domain": "example.com"}); err != nil {
+		t.Fatalf("Failed to set provider config: %v", err)
+	}
+	if err := database.UpdateSettings(settings); err != nil {
+		t.Fatalf("Failed to update settings: %v", err)
+	}
+	cfg := &config.Config{Node: config.NodeConfig{ID: "test-node-id", IsPrimary: true}}
+	service := NewTunnelService(database, nil, cfg, slog.Default())
+	ctx := context.Background()
+
+	// A compose that doesn't parse makes the sidecar rewrite fail after the rules are saved
+	app := db.NewApp("frp-app", "", "services: [")
+	app.NodeID = "test-node-id"
+	if err := database.CreateApp(app); err != nil {
+		t.Fatalf("Failed to create app: %v", err)
+	}
+	oldRules := []db.IngressRule{{Hostname: stringPtr("old.example.com"), Service: "http://web:80"}}
+	if err := database.CreateFrpTunnel(db.NewFrpTunnel(app.ID, app.Name, oldRules, "https://old.example.com")); err != nil {
+		t.Fatalf("Failed to create frp tunnel: %v", err)
+	}
+
+	req := domain.UpdateIngressRequest{IngressRules: []db.IngressRule{{Hostname: stringPtr("new.example.com"), Service: "http://web:80"}}}
+	if err := service.UpdateTunnelIngress(ctx, app.ID, "test-node-id", req); !domain.IsValidationError(err) {
+		t.Fatalf("Expected the sidecar rewrite to fail on the compose, got %v", err)
+	}
+	record, err := database.GetFrpTunnelByAppID(app.ID)
+	if err != nil {
+		t.Fatalf("Failed to get frp tunnel: %v", err)
+	}
+	if len(record.IngressRules) != 1 || *record.IngressRules[0].Hostname != "old.example.com" || record.PublicURL != "https://old.example.com" {
+		t.Errorf("Expected the old rules to be restored, got %+v", record)
+	}
+
+	// While another operation holds the app, the rules are not saved at all
+	_, acquired, err := database.TryAcquireAppLock(app.ID, "other", "deploy", time.Minute)
+	if err != nil || !acquired {
+		t.Fatalf("Failed to lock app: %v", err)
+	}
+	if err := service.UpdateTunnelIngress(ctx, app.ID, "test-node-id", req); !domain.IsConflictError(err) {
+		t.Errorf("Expected a conflict error, got %v", err)
+	}
+}
+
 func TestTunnelService_CreateDNSRecord(t *testing.T) {
 	service, database, mockHTTPClient, cleanup := setupTestTunnelService(t)
 	defer cleanup()
@@ -526,7 +581,290 @@ func TestTunnelService_SyncTunnelStatus_NotFound(t *testing.T) {
 	}
 }
 
+func TestTunnelService_GetTunnelConnectorStatus(t *testing.T) {
+	service, database, mockClient, cleanup := setupTestTunnelService(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	app, _ := createTestAppWithTunnel(t, database)
+	connectionsURL := "https://api.cloudflare.com/client/v4/accounts/test-account-id/cfd_tunnel/tunnel-123/connections"
+	setConnectors := func(connectors []interface{}) {
+		if err := mockClient.SetJSONMockResponse(connectionsURL, http.StatusOK, map[string]interface{}{
+			"success": true,
+			"errors":  []interface{}{},
+			"result":  connectors,
+		}); err != nil {
+			t.Fatalf("Failed to set mock response: %v", err)
+		}
+	}
+
+	// A tunnel nothing runs exists but has no connector
+	setConnectors([]interface{}{})
+	status, err := service.GetTunnelConnectorStatus(ctx, app.ID, "test-node-id")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if status.State != constants.TunnelConnectorStateNone || status.ConnectorCount != 0 || status.TunnelID != "tunnel-123" {
+		t.Errorf("Expected no_connector for tunnel-123, got %+v", status)
+	}
+
+	setConnectors([]interface{}{
+		map[string]interface{}{"id": "c1", "version": "2025.1.0", "run_at": "2026-01-01T10:00:00Z", "conns": []interface{}{
+			map[string]interface{}{"id": "a", "colo_name": "fra08", "opened_at": "2026-01-01T10:00:05Z"},
+			map[string]interface{}{"id": "b", "colo_name": "ams01", "opened_at": "2026-01-01T10:00:07Z"},
+			map[string]interface{}{"id": "c", "colo_name": "lhr01", "opened_at": "2026-01-01T10:00:09Z", "is_pending_reconnect": true},
+		}},
+		map[string]interface{}{"id": "c2", "conns": []interface{}{
+			map[string]interface{}{"id": "d", "colo_name": "fra08", "opened_at": "2026-01-01T09:00:00Z"},
+		}},
+	})
+	status, err = service.GetTunnelConnectorStatus(ctx, app.ID, "test-node-id")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if status.State != constants.TunnelConnectorStateHealthy || status.ConnectorCount != 2 {
+		t.Errorf("Expected 2 healthy connectors, got %+v", status)
+	}
+	if strings.Join(status.Locations, ",") != "ams01,fra08" {
+		t.Errorf("Expected the active locations ams01,fra08, got %v", status.Locations)
+	}
+	if status.LastConnectedAt == nil || !status.LastConnectedAt.Equal(time.Date(2026, 1, 1, 10, 0, 7, 0, time.UTC)) {
+		t.Errorf("Expected the newest active connection at 10:00:07, got %v", status.LastConnectedAt)
+	}
+
+	// Connectors whose connections are all reconnecting are not healthy
+	setConnectors([]interface{}{
+		map[string]interface{}{"id": "c1", "conns": []interface{}{
+			map[string]interface{}{"id": "a", "colo_name": "fra08", "is_pending_reconnect": true},
+		}},
+	})
+	status, err = service.GetTunnelConnectorStatus(ctx, app.ID, "test-node-id")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if status.State != constants.TunnelConnectorStateReconnecting || len(status.Locations) != 0 {
+		t.Errorf("Expected reconnecting with no active location, got %+v", status)
+	}
+
+	// Quick Tunnels have no named tunnel to ask about
+	app.TunnelMode = constants.TunnelModeQuick
+	if err := database.UpdateApp(app); err != nil {
+		t.Fatalf("Failed to update app: %v", err)
+	}
+	if _, err := service.GetTunnelConnectorStatus(ctx, app.ID, "test-node-id"); !domain.IsValidationError(err) {
+		t.Errorf("Expected a validation error for a Quick Tunnel app, got %v", err)
+	}
+}
+
+func TestTunnelService_GetAppTraffic(t *testing.T) {
+	service, database, mockClient, cleanup := setupTestTunnelService(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	app, cfTunnel := createTestAppWithTunnel(t, database)
+	blog, api := "blog.example.com", "api.example.com"
+	cfTunnel.IngressRules = &[]db.IngressRule{{Hostname: &blog, Service: "http://web:80"}, {Hostname: &api, Service: "http://web:8080"}, {Service: "http_status:404"}}
+	if err := database.UpdateCloudflareTunnel(cfTunnel); err != nil {
+		t.Fatalf("Failed to update tunnel: %v", err)
+	}
+	if err := mockClient.SetJSONMockResponse("https://api.cloudflare.com/client/v4/zones?per_page=50&page=1", http.StatusOK, map[string]interface{}{
+		"success": true,
+		"result":  []interface{}{map[string]interface{}{"id": "zone-1", "name": "example.com"}},
+	}); err != nil {
+		t.Fatalf("Failed to set mock response: %v", err)
+	}
+	if err := mockClient.SetJSONMockResponse("https://api.cloudflare.com/client/v4/graphql", http.StatusOK, map[string]interface{}{
+		"data": map[string]interface{}{"viewer": map[string]interface{}{"zones": []interface{}{map[string]interface{}{
+			"hosts": []interface{}{
+				map[string]interface{}{"count": 120, "sum": map[string]interface{}{"edgeResponseBytes": 2048, "visits": 30}, "dimensions": map[string]interface{}{"clientRequestHTTPHost": blog}},
+				map[string]interface{}{"count": 30, "sum": map[string]interface{}{"edgeResponseBytes": 1024, "visits": 4}, "dimensions": map[string]interface{}{"clientRequestHTTPHost": api}},
+			},
+			"countries": []interface{}{map[string]interface{}{"count": 150, "dimensions": map[string]interface{}{"clientCountryName": "NL"}}},
+			"statuses":  []interface{}{map[string]interface{}{"count": 150, "dimensions": map[string]interface{}{"edgeResponseStatus": 200}}},
+		}}}},
+	}); err != nil {
+		t.Fatalf("Failed to set mock response: %v", err)
+	}
+
+	traffic, err := service.GetAppTraffic(ctx, app.ID, "test-node-id", "")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if traffic.Period != "24h" || traffic.Until.Sub(traffic.Since) != 24*time.Hour {
+		t.Errorf("Expected the default 24h period, got %s from %v to %v", traffic.Period, traffic.Since, traffic.Until)
+	}
+	if traffic.Requests != 150 || traffic.Bytes != 3072 || traffic.Visits != 34 || len(traffic.Hostnames) != 2 {
+		t.Errorf("Expected totals across both hostnames, got %+v", traffic.Traffic)
+	}
+	if len(traffic.Countries) != 1 || traffic.Countries[0].Key != "NL" {
+		t.Errorf("Expected NL as the top country, got %+v", traffic.Countries)
+	}
+
+	if _, err := service.GetAppTraffic(ctx, app.ID, "test-node-id", "30d"); !domain.IsValidationError(err) {
+		t.Errorf("Expected an unknown period to be refused, got %v", err)
+	}
+	app.TunnelMode = constants.TunnelModeQuick
+	if err := database.UpdateApp(app); err != nil {
+		t.Fatalf("Failed to update app: %v", err)
+	}
+	if _, err := service.GetAppTraffic(ctx, app.ID, "test-node-id", "1h"); !domain.IsValidationError(err) {
+		t.Errorf("Expected a Quick Tunnel app to be refused, got %v", err)
+	}
+}
+
 // Helper function to create string pointer
 func stringPtr(s string) *string {
 	return &s
 }
+
+func TestTunnelService_TunnelInventory(t *testing.T) {
+	service, database, mockHTTPClient, cleanup := setupTestTunnelService(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	app, _ := createTestAppWithTunnel(t, database)
+
+	mockHTTPClient.SetJSONMockResponse(
+		"https://api.cloudflare.com/client/v4/accounts/test-account-id/cfd_tunnel?is_deleted=false&per_page=100&page=1",
+		http.StatusOK,
+		map[string]interface{}{
+			"success": true,
+			"result": []map[string]interface{}{
+				{"id": "tunnel-123", "name": "test-app", "status": "healthy"},
+				{"id": "orphan-1", "name": "old-blog", "status": "down"},
+				{"id": "busy-1", "name": "homelab", "status": "healthy"},
+			},
+			"result_info": map[string]interface{}{"page": 1, "per_page": 100, "total_pages": 1, "count": 3},
+		},
+	)
+	mockHTTPClient.SetJSONMockResponse(
+		"https://api.cloudflare.com/client/v4/accounts/test-account-id/cfd_tunnel/orphan-1?cascade=true",
+		http.StatusOK,
+		map[string]interface{}{"success": true},
+	)
+
+	inventory, err := service.ListTunnelInventory(ctx)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if !inventory.AccountListed || !inventory.Complete || inventory.OrphanCount != 1 {
+		t.Fatalf("Unexpected inventory %+v", inventory)
+	}
+	items := make(map[string]*domain.TunnelInventoryItem)
+	for _, item := range inventory.Tunnels {
+		items[item.TunnelID] = item
+	}
+	if item := items["tunnel-123"]; item == nil || !item.Tracked || item.Orphaned || item.AppName != app.Name || item.Status != "healthy" {
+		t.Errorf("Expected tunnel-123 linked to %s, got %+v", app.Name, item)
+	}
+	if item := items["orphan-1"]; item == nil || !item.Orphaned {
+		t.Errorf("Expected orphan-1 to be orphaned, got %+v", item)
+	}
+	if item := items["busy-1"]; item == nil || item.Orphaned {
+		t.Errorf("Expected busy-1 with running connectors not to be orphaned, got %+v", item)
+	}
+
+	if err := service.DeleteOrphanedTunnel(ctx, "tunnel-123"); !domain.IsConflictError(err) {
+		t.Errorf("Expected a conflict deleting a tunnel an app uses, got %v", err)
+	}
+	if err := service.DeleteOrphanedTunnel(ctx, "busy-1"); !domain.IsConflictError(err) {
+		t.Errorf("Expected a conflict deleting a tunnel with connectors, got %v", err)
+	}
+	if err := service.DeleteOrphanedTunnel(ctx, "missing"); !domain.IsNotFoundError(err) {
+		t.Errorf("Expected not found for an unknown tunnel, got %v", err)
+	}
+	if err := service.DeleteOrphanedTunnel(ctx, "orphan-1"); err != nil {
+		t.Fatalf("Expected orphan-1 to be deleted, got %v", err)
+	}
+	if !mockHTTPClient.AssertRequestMade("DELETE", "https://api.cloudflare.com/client/v4/accounts/test-account-id/cfd_tunnel/orphan-1?cascade=true") {
+		t.Error("Expected DELETE request for orphan-1")
+	}
+	if mockHTTPClient.GetRequestCount("DELETE", "https://api.cloudflare.com/client/v4/accounts/test-account-id/cfd_tunnel/tunnel-123?cascade=true") != 0 {
+		t.Error("Expected tunnel-123 to be left alone")
+	}
+}
+
+func TestTunnelService_TunnelInventory_OfflineNode(t *testing.T) {
+	service, database, mockHTTPClient, cleanup := setupTestTunnelService(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	offline := db.NewNode("offline-node", "http://offline:8080", "key", false)
+	offline.Status = "offline"
+	if err := database.CreateNode(offline); err != nil {
+		t.Fatalf("Failed to create node: %v", err)
+	}
+
+	mockHTTPClient.SetJSONMockResponse(
+		"https://api.cloudflare.com/client/v4/accounts/test-account-id/cfd_tunnel?is_deleted=false&per_page=100&page=1",
+		http.StatusOK,
+		map[string]interface{}{
+			"success": true,
+			"result":  []map[string]interface{}{{"id": "orphan-1", "name": "old-blog", "status": "down"}},
+		},
+	)
+
+	inventory, err := service.ListTunnelInventory(ctx)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if inventory.Complete || inventory.OrphanCount != 0 || len(inventory.UnreachableNodes) != 1 {
+		t.Errorf("Expected no orphans while a node is offline, got %+v", inventory)
+	}
+	if err := service.DeleteOrphanedTunnel(ctx, "orphan-1"); !domain.IsConflictError(err) {
+		t.Errorf("Expected a conflict while a node is offline, got %v", err)
+	}
+}
+
+func TestTunnelService_ZonesAndHostnames(t *testing.T) {
+	service, _, mockHTTPClient, cleanup := setupTestTunnelService(t)
+	defer cleanup()
+
+	ctx := context.Background()
+	mockHTTPClient.SetJSONMockResponse(
+		"https://api.cloudflare.com/client/v4/zones?per_page=50&page=1",
+		http.StatusOK,
+		map[string]interface{}{
+			"success": true,
+			"result": []map[string]interface{}{
+				{"id": "zone-2", "name": "example.org", "status": "active"},
+				{"id": "zone-1", "name": "example.com", "status": "active"},
+			},
+		},
+	)
+	mockHTTPClient.SetJSONMockResponse(
+		"https://api.cloudflare.com/client/v4/zones/zone-1/dns_records?per_page=100&page=1",
+		http.StatusOK,
+		map[string]interface{}{
+			"success": true,
+			"result": []map[string]interface{}{
+				{"id": "r1", "type": "CNAME", "name": "www.example.com", "content": "tunnel-123.cfargotunnel.com"},
+				{"id": "r2", "type": "A", "name": "example.com", "content": "203.0.113.10"},
+			},
+		},
+	)
+
+	zones, err := service.ListProviderZones(ctx, "cloudflare")
+	if err != nil {
+		t.Fatalf("ListProviderZones: %v", err)
+	}
+	if len(zones) != 2 || zones[0].Name != "example.com" {
+		t.Errorf("Expected zones sorted by name, got %+v", zones)
+	}
+
+	hostnames, err := service.ListZoneHostnames(ctx, "cloudflare", "zone-1")
+	if err != nil {
+		t.Fatalf("ListZoneHostnames: %v", err)
+	}
+	if len(hostnames) != 2 || hostnames[0].Name != "example.com" || hostnames[0].TunnelID != "" {
+		t.Fatalf("Expected the apex A record first with no tunnel, got %+v", hostnames)
+	}
+	if hostnames[1].TunnelID != "tunnel-123" {
+		t.Errorf("Expected www to route to tunnel-123, got %q", hostnames[1].TunnelID)
+	}
+
+	// Unmocked URLs return 404
+	if _, err := service.ListZoneHostnames(ctx, "cloudflare", "zone-missing"); !domain.IsNotFoundError(err) {
+		t.Errorf("Expected not found for an unknown zone, got %v", err)
+	}
+}
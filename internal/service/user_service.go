@@ -0,0 +1,296 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log/slog"
+	netmail "net/mail"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/selfhostly/internal/config"
+	"github.com/selfhostly/internal/constants"
+	"github.com/selfhostly/internal/db"
+	"github.com/selfhostly/internal/domain"
+	"github.com/selfhostly/internal/mail"
+)
+
+// userService invites users and tells who may sign in with which role. Users in
+// GITHUB_ALLOWED_USERS are admins; everyone else needs the account an invitation creates.
+type userService struct {
+	database *db.DB
+	config   *config.Config
+	logger   *slog.Logger
+
+	rolesSynced atomic.Bool // Whether the primary's users reached this node since it started
+}
+
+// NewUserService creates a new user service
+func NewUserService(database *db.DB, cfg *config.Config, logger *slog.Logger) domain.UserService {
+	return &userService{
+		database: database,
+		config:   cfg,
+		logger:   logger,
+	}
+}
+
+// ListUsers returns the accounts of invited users; allowed users have none
+func (s *userService) ListUsers(ctx context.Context) ([]*db.User, error) {
+	users, err := s.database.GetUsers()
+	if err != nil {
+		return nil, domain.WrapDatabaseOperation("get users", err)
+	}
+	return users, nil
+}
+
+// DeleteUser deletes an invited user's account and revokes their sessions, so they are signed
+// out everywhere
+func (s *userService) DeleteUser(ctx context.Context, name string) error {
+	name = strings.ToLower(name)
+	if err := s.database.DeleteUser(name); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return domain.WrapUserNotFound(name, err)
+		}
+		return domain.WrapDatabaseOperation("delete user", err)
+	}
+	revoked, err := s.database.RevokeUserSessions(name, time.Now())
+	if err != nil {
+		s.logger.WarnContext(ctx, "failed to revoke sessions of deleted user", "user", name, "error", err)
+	}
+	s.logger.InfoContext(ctx, "user deleted", "user", name, "sessionsRevoked", revoked)
+	return nil
+}
+
+// Invite creates a single-use invite link for the requested role. The link is emailed when an
+// email is given and SMTP is set up; it is returned either way so it can be shared by hand.
+func (s *userService) Invite(ctx context.Context, req domain.InviteUserRequest) (*domain.IssuedInvitation, error) {
+	if err := s.requireInvitations(); err != nil {
+		return nil, err
+	}
+	role := req.Role
+	if role == "" {
+		role = constants.UserRoleViewer
+	}
+	if role != constants.UserRoleAdmin && role != constants.UserRoleViewer {
+		return nil, domain.WrapValidationError("role", fmt.Errorf("role must be %s or %s", constants.UserRoleAdmin, constants.UserRoleViewer))
+	}
+	email := strings.TrimSpace(req.Email)
+	if email != "" {
+		addr, err := netmail.ParseAddress(email)
+		if err != nil {
+			return nil, domain.WrapValidationError("email", fmt.Errorf("invalid email address: %w", err))
+		}
+		email = addr.Address
+	}
+	ttl := constants.InvitationDefaultTTL
+	if req.TTLHours != 0 {
+		ttl = time.Duration(req.TTLHours) * time.Hour
+		if req.TTLHours < 0 || ttl > constants.InvitationMaxTTL {
+			return nil, domain.WrapValidationError("ttl_hours", fmt.Errorf("ttl_hours must be between 1 and %d", int(constants.InvitationMaxTTL.Hours())))
+		}
+	}
+
+	token, err := newSecretToken()
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate invite token: %w", err)
+	}
+	invitation := db.NewInvitation(hashSecretToken(token), email, role, req.InvitedBy, ttl)
+	if err := s.database.CreateInvitation(invitation); err != nil {
+		return nil, domain.WrapDatabaseOperation("create invitation", err)
+	}
+
+	issued := &domain.IssuedInvitation{
+		Invitation: invitation,
+		Token:      token,
+		URL:        strings.TrimRight(req.BaseURL, "/") + constants.InvitePath + token,
+	}
+	if email != "" {
+		if err := s.sendInvitation(ctx, issued); err != nil {
+			s.logger.WarnContext(ctx, "invitation not emailed", "invitationID", invitation.ID, "error", err)
+			issued.EmailError = err.Error()
+		} else {
+			issued.Emailed = true
+		}
+	}
+
+	s.logger.InfoContext(ctx, "user invited", "invitationID", invitation.ID, "role", role, "invitedBy", req.InvitedBy, "emailed", issued.Emailed, "expiresAt", invitation.ExpiresAt)
+	return issued, nil
+}
+
+// ListInvitations returns every invitation, newest first
+func (s *userService) ListInvitations(ctx context.Context) ([]*db.Invitation, error) {
+	invitations, err := s.database.GetInvitations()
+	if err != nil {
+		return nil, domain.WrapDatabaseOperation("get invitations", err)
+	}
+	return invitations, nil
+}
+
+// RevokeInvitation revokes an invitation that hasn't been accepted yet
+func (s *userService) RevokeInvitation(ctx context.Context, id string) error {
+	if err := s.database.RevokeInvitation(id, time.Now()); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return domain.WrapInvitationNotFound(err)
+		}
+		return domain.WrapDatabaseOperation("revoke invitation", err)
+	}
+	s.logger.InfoContext(ctx, "invitation revoked", "invitationID", id)
+	return nil
+}
+
+// AcceptInvitation creates the account of the signed-in GitHub user holding the invite token. A
+// user who already has access is refused, leaving the invitation for whoever it was meant for.
+func (s *userService) AcceptInvitation(ctx context.Context, token, userName string) (*db.User, error) {
+	name := strings.ToLower(userName)
+	if name == "" {
+		return nil, domain.WrapValidationError("user", fmt.Errorf("sign in with GitHub to accept an invitation"))
+	}
+
+	invitation, err := s.database.GetInvitationByTokenHash(hashSecretToken(token))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, domain.WrapInvitationNotFound(err)
+	}
+	if err != nil {
+		return nil, domain.WrapDatabaseOperation("get invitation", err)
+	}
+	if invitation.Status != constants.InvitationStatusPending {
+		return nil, domain.WrapInvitationNotFound(nil)
+	}
+	if _, ok := s.UserRole(ctx, name); ok {
+		return nil, domain.WrapUserExists(name)
+	}
+
+	user := db.NewInvitedUser(name, invitation)
+	if err := s.database.AcceptInvitation(invitation.ID, user); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, domain.WrapInvitationNotFound(err)
+		}
+		return nil, domain.WrapDatabaseOperation("accept invitation", err)
+	}
+
+	s.logger.InfoContext(ctx, "invitation accepted", "invitationID", invitation.ID, "user", name, "role", user.Role, "invitedBy", invitation.CreatedBy)
+	return user, nil
+}
+
+// UserRole returns admin for users in GITHUB_ALLOWED_USERS and the account's role for invited
+// users. GitHub usernames are case-insensitive.
+func (s *userService) UserRole(ctx context.Context, userName string) (string, bool) {
+	name := strings.ToLower(userName)
+	for _, allowed := range s.config.Auth.GitHub.AllowedUsers {
+		if name == strings.ToLower(allowed) {
+			return constants.UserRoleAdmin, true
+		}
+	}
+
+	user, err := s.database.GetUser(name)
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			s.logger.WarnContext(ctx, "failed to get user", "user", name, "error", err)
+		}
+		return "", false
+	}
+	return user.Role, true
+}
+
+// UserRoles returns the role of every user who may sign in: admin for users in
+// GITHUB_ALLOWED_USERS, and the account's role for invited users
+func (s *userService) UserRoles(ctx context.Context) (map[string]string, error) {
+	users, err := s.database.GetUsers()
+	if err != nil {
+		return nil, domain.WrapDatabaseOperation("get users", err)
+	}
+	roles := make(map[string]string, len(users)+len(s.config.Auth.GitHub.AllowedUsers))
+	for _, user := range users {
+		roles[user.Username] = user.Role
+	}
+	for _, allowed := range s.config.Auth.GitHub.AllowedUsers {
+		roles[strings.ToLower(allowed)] = constants.UserRoleAdmin
+	}
+	return roles, nil
+}
+
+// ApplyRolesFromPrimary replaces this node's users with the primary's, so users the primary
+// removed or gave another role lose their old role here too. The primary never takes users from
+// another node.
+func (s *userService) ApplyRolesFromPrimary(ctx context.Context, roles map[string]string) error {
+	if s.config.Node.IsPrimary {
+		return domain.WrapValidationError("user", fmt.Errorf("the primary's users are invited on it, not synced from another node"))
+	}
+	users := make([]*db.User, 0, len(roles))
+	for name, role := range roles {
+		if role != constants.UserRoleAdmin && role != constants.UserRoleViewer {
+			s.logger.WarnContext(ctx, "ignoring user with an unknown role from the primary", "user", name, "role", role)
+			continue
+		}
+		users = append(users, db.NewSyncedUser(strings.ToLower(name), role))
+	}
+	if err := s.database.ReplaceUsers(users); err != nil {
+		return domain.WrapDatabaseOperation("sync users", err)
+	}
+	if !s.rolesSynced.Swap(true) {
+		s.logger.InfoContext(ctx, "users synced from the primary", "count", len(users))
+	}
+	return nil
+}
+
+// RolesSynced reports whether the primary's users reached this node since it started
+func (s *userService) RolesSynced() bool {
+	return s.rolesSynced.Load()
+}
+
+// sendInvitation emails the invite link through the SMTP settings
+func (s *userService) sendInvitation(ctx context.Context, issued *domain.IssuedInvitation) error {
+	settings, err := s.database.GetSettings()
+	if err != nil {
+		return fmt.Errorf("failed to get settings: %w", err)
+	}
+	cfg := mail.ConfigFromSettings(settings)
+	if !cfg.Configured() {
+		return fmt.Errorf("SMTP isn't set up; share the link instead")
+	}
+
+	inviter := "An admin"
+	if issued.CreatedBy != "" {
+		inviter = issued.CreatedBy
+	}
+	return mail.Send(ctx, cfg, mail.Message{
+		To:      []string{issued.Email},
+		Subject: "You're invited to Selfhostly",
+		Body: fmt.Sprintf("%s invited you to Selfhostly as %s.\n\nOpen this link and sign in with GitHub to accept:\n\n%s\n\nThe link works once and expires on %s.",
+			inviter, issued.Role, issued.URL, issued.ExpiresAt.Format(time.RFC1123)),
+	})
+}
+
+// requireInvitations refuses invitations where they can't be used: accounts are kept on the
+// primary, and only mean something while auth is on
+func (s *userService) requireInvitations() error {
+	if !s.config.Node.IsPrimary {
+		return domain.WrapValidationError("invitation", fmt.Errorf("users are invited on the primary node"))
+	}
+	if !s.config.Auth.Enabled {
+		return domain.WrapValidationError("invitation", fmt.Errorf("everyone has access while AUTH_ENABLED is off"))
+	}
+	return nil
+}
+
+// newSecretToken returns a random token for links that work without signing in, such as invite
+// links and status pages
+func newSecretToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// hashSecretToken returns the hash secret tokens are stored and looked up by
+func hashSecretToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
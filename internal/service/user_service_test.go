@@ -0,0 +1,165 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/selfhostly/internal/config"
+	"github.com/selfhostly/internal/constants"
+	"github.com/selfhostly/internal/db"
+	"github.com/selfhostly/internal/domain"
+)
+
+func setupTestUserService(t *testing.T) domain.UserService {
+	database, err := db.Init(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to initialize database: %v", err)
+	}
+	t.Cleanup(func() { database.Close() })
+
+	cfg := &config.Config{}
+	cfg.Node.IsPrimary = true
+	cfg.Auth.Enabled = true
+	cfg.Auth.GitHub.AllowedUsers = []string{"Alice"}
+	return NewUserService(database, cfg, slog.Default())
+}
+
+func TestUserService_Invitations(t *testing.T) {
+	service := setupTestUserService(t)
+	ctx := context.Background()
+
+	if role, ok := service.UserRole(ctx, "alice"); !ok || role != constants.UserRoleAdmin {
+		t.Errorf("Expected allowed users to be admins, got %q, %v", role, ok)
+	}
+	if _, ok := service.UserRole(ctx, "bob"); ok {
+		t.Error("Expected a user without an account to have no access")
+	}
+
+	issued, err := service.Invite(ctx, domain.InviteUserRequest{Email: "Bob <bob@example.com>", BaseURL: "https://selfhostly.example.com/", InvitedBy: "alice"})
+	if err != nil {
+		t.Fatalf("Invite: %v", err)
+	}
+	if issued.Role != constants.UserRoleViewer || issued.Email != "bob@example.com" {
+		t.Errorf("Unexpected invitation %+v", issued.Invitation)
+	}
+	if issued.URL != "https://selfhostly.example.com/api/invite/"+issued.Token {
+		t.Errorf("Unexpected invite link %s", issued.URL)
+	}
+	if issued.Emailed || !strings.Contains(issued.EmailError, "SMTP") {
+		t.Errorf("Expected the link not to be emailed without SMTP, got %v, %q", issued.Emailed, issued.EmailError)
+	}
+
+	// An allowed user can't use up the invitation
+	if _, err := service.AcceptInvitation(ctx, issued.Token, "ALICE"); !domain.IsConflictError(err) {
+		t.Errorf("Expected an allowed user to be refused, got %v", err)
+	}
+	user, err := service.AcceptInvitation(ctx, issued.Token, "Bob")
+	if err != nil {
+		t.Fatalf("AcceptInvitation: %v", err)
+	}
+	if user.Username != "bob" || user.Role != constants.UserRoleViewer || user.InvitedBy != "alice" {
+		t.Errorf("Unexpected user %+v", user)
+	}
+	if role, ok := service.UserRole(ctx, "BOB"); !ok || role != constants.UserRoleViewer {
+		t.Errorf("Expected bob to be a viewer, got %q, %v", role, ok)
+	}
+
+	// Invitations work once
+	if _, err := service.AcceptInvitation(ctx, issued.Token, "carol"); !domain.IsNotFoundError(err) {
+		t.Errorf("Expected a used invitation to be refused, got %v", err)
+	}
+	if _, err := service.AcceptInvitation(ctx, "not-a-token", "carol"); !domain.IsNotFoundError(err) {
+		t.Errorf("Expected an unknown token to be refused, got %v", err)
+	}
+
+	revoked, err := service.Invite(ctx, domain.InviteUserRequest{Role: constants.UserRoleAdmin})
+	if err != nil {
+		t.Fatalf("Invite: %v", err)
+	}
+	if err := service.RevokeInvitation(ctx, revoked.ID); err != nil {
+		t.Fatalf("RevokeInvitation: %v", err)
+	}
+	if _, err := service.AcceptInvitation(ctx, revoked.Token, "carol"); !domain.IsNotFoundError(err) {
+		t.Errorf("Expected a revoked invitation to be refused, got %v", err)
+	}
+
+	invitations, _ := service.ListInvitations(ctx)
+	if len(invitations) != 2 || invitations[0].Status != constants.InvitationStatusRevoked || invitations[1].Status != constants.InvitationStatusAccepted {
+		t.Errorf("Unexpected invitations %+v", invitations)
+	}
+
+	if err := service.DeleteUser(ctx, "Bob"); err != nil {
+		t.Fatalf("DeleteUser: %v", err)
+	}
+	if _, ok := service.UserRole(ctx, "bob"); ok {
+		t.Error("Expected a deleted user to lose access")
+	}
+	if err := service.DeleteUser(ctx, "bob"); !domain.IsNotFoundError(err) {
+		t.Errorf("Expected deleting again to be not found, got %v", err)
+	}
+}
+
+func TestUserService_InviteValidation(t *testing.T) {
+	service := setupTestUserService(t)
+	ctx := context.Background()
+
+	invalid := []domain.InviteUserRequest{
+		{Role: "owner"},
+		{Email: "not an address"},
+		{TTLHours: 24 * 31},
+	}
+	for _, req := range invalid {
+		if _, err := service.Invite(ctx, req); !domain.IsValidationError(err) {
+			t.Errorf("Expected %+v to be refused, got %v", req, err)
+		}
+	}
+}
+
+func TestUserService_ApplyRolesFromPrimary(t *testing.T) {
+	primary := setupTestUserService(t)
+	ctx := context.Background()
+	if err := primary.ApplyRolesFromPrimary(ctx, map[string]string{"bob": constants.UserRoleAdmin}); !domain.IsValidationError(err) {
+		t.Errorf("Expected the primary to refuse users from another node, got %v", err)
+	}
+	roles, err := primary.UserRoles(ctx)
+	if err != nil {
+		t.Fatalf("UserRoles: %v", err)
+	}
+	if len(roles) != 1 || roles["alice"] != constants.UserRoleAdmin {
+		t.Errorf("Expected allowed users among the roles, got %v", roles)
+	}
+
+	database, err := db.Init(filepath.Join(t.TempDir(), "secondary.db"))
+	if err != nil {
+		t.Fatalf("Failed to initialize database: %v", err)
+	}
+	t.Cleanup(func() { database.Close() })
+	secondary := NewUserService(database, &config.Config{}, slog.Default())
+
+	if secondary.RolesSynced() {
+		t.Error("Expected no users to be synced before the first heartbeat")
+	}
+	if err := secondary.ApplyRolesFromPrimary(ctx, map[string]string{"Bob": constants.UserRoleAdmin, "carol": constants.UserRoleViewer}); err != nil {
+		t.Fatalf("ApplyRolesFromPrimary: %v", err)
+	}
+	if !secondary.RolesSynced() {
+		t.Error("Expected users to be synced")
+	}
+	if role, ok := secondary.UserRole(ctx, "bob"); !ok || role != constants.UserRoleAdmin {
+		t.Errorf("Expected bob to be an admin, got %q, %v", role, ok)
+	}
+
+	// A demoted user loses their old role, and a removed one their access
+	if err := secondary.ApplyRolesFromPrimary(ctx, map[string]string{"bob": constants.UserRoleViewer}); err != nil {
+		t.Fatalf("ApplyRolesFromPrimary: %v", err)
+	}
+	if role, ok := secondary.UserRole(ctx, "bob"); !ok || role != constants.UserRoleViewer {
+		t.Errorf("Expected bob to be demoted to viewer, got %q, %v", role, ok)
+	}
+	if _, ok := secondary.UserRole(ctx, "carol"); ok {
+		t.Error("Expected a user removed on the primary to lose access")
+	}
+}
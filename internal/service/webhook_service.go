@@ -0,0 +1,229 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"slices"
+	"time"
+
+	"github.com/selfhostly/internal/constants"
+	"github.com/selfhostly/internal/db"
+	"github.com/selfhostly/internal/domain"
+	"github.com/selfhostly/internal/webhook"
+)
+
+// webhookService manages per-app lifecycle webhooks stored on this node
+type webhookService struct {
+	database   *db.DB
+	dispatcher *webhook.Dispatcher
+	logger     *slog.Logger
+}
+
+// NewWebhookService creates a new webhook service that sends test deliveries through dispatcher
+func NewWebhookService(database *db.DB, dispatcher *webhook.Dispatcher, logger *slog.Logger) domain.WebhookService {
+	return &webhookService{
+		database:   database,
+		dispatcher: dispatcher,
+		logger:     logger,
+	}
+}
+
+// ListWebhooks returns an app's webhooks. Secrets are omitted; they are only shown on creation.
+func (s *webhookService) ListWebhooks(ctx context.Context, appID string) ([]*db.AppWebhook, error) {
+	if _, err := s.database.GetApp(appID); err != nil {
+		return nil, domain.WrapAppNotFound(appID, err)
+	}
+	webhooks, err := s.database.GetWebhooksByAppID(appID)
+	if err != nil {
+		return nil, domain.WrapDatabaseOperation("get webhooks", err)
+	}
+	for _, w := range webhooks {
+		w.Secret = ""
+	}
+	return webhooks, nil
+}
+
+// CreateWebhook adds a webhook with a freshly generated signing secret, which is returned once
+func (s *webhookService) CreateWebhook(ctx context.Context, appID string, req domain.CreateWebhookRequest) (*db.AppWebhook, error) {
+	if _, err := s.database.GetApp(appID); err != nil {
+		return nil, domain.WrapAppNotFound(appID, err)
+	}
+	if err := validateWebhookURL("url", req.URL); err != nil {
+		return nil, err
+	}
+	events, err := normalizeWebhookEvents(req.Events)
+	if err != nil {
+		return nil, err
+	}
+
+	secret, err := webhook.GenerateSecret()
+	if err != nil {
+		return nil, err
+	}
+	hook := db.NewAppWebhook(appID, req.URL, secret, events)
+	hook.Digest, hook.QuietHoursStart, hook.QuietHoursEnd = req.Digest, req.QuietHoursStart, req.QuietHoursEnd
+	if req.Timezone != "" {
+		hook.Timezone = req.Timezone
+	}
+	if err := validateWebhookHolding(hook); err != nil {
+		return nil, err
+	}
+	if err := s.database.CreateWebhook(hook); err != nil {
+		return nil, domain.WrapDatabaseOperation("create webhook", err)
+	}
+
+	s.logger.InfoContext(ctx, "webhook created", "appID", appID, "webhookID", hook.ID, "events", events)
+	return hook, nil
+}
+
+// UpdateWebhook changes a webhook's URL, events, enabled state, digest mode or quiet hours
+func (s *webhookService) UpdateWebhook(ctx context.Context, appID, webhookID string, req domain.UpdateWebhookRequest) (*db.AppWebhook, error) {
+	hook, err := s.getWebhook(appID, webhookID)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.URL != nil {
+		if err := validateWebhookURL("url", *req.URL); err != nil {
+			return nil, err
+		}
+		hook.URL = *req.URL
+	}
+	if req.Events != nil {
+		if hook.Events, err = normalizeWebhookEvents(req.Events); err != nil {
+			return nil, err
+		}
+	}
+	if req.Enabled != nil {
+		hook.Enabled = *req.Enabled
+	}
+	if req.Digest != nil {
+		hook.Digest = *req.Digest
+	}
+	if req.QuietHoursStart != nil {
+		hook.QuietHoursStart = *req.QuietHoursStart
+	}
+	if req.QuietHoursEnd != nil {
+		hook.QuietHoursEnd = *req.QuietHoursEnd
+	}
+	if req.Timezone != nil {
+		hook.Timezone = *req.Timezone
+	}
+	if err := validateWebhookHolding(hook); err != nil {
+		return nil, err
+	}
+	hook.UpdatedAt = time.Now()
+
+	if err := s.database.UpdateWebhook(hook); err != nil {
+		return nil, domain.WrapDatabaseOperation("update webhook", err)
+	}
+
+	s.logger.InfoContext(ctx, "webhook updated", "appID", appID, "webhookID", webhookID, "enabled", hook.Enabled)
+	hook.Secret = ""
+	return hook, nil
+}
+
+// DeleteWebhook removes a webhook
+func (s *webhookService) DeleteWebhook(ctx context.Context, appID, webhookID string) error {
+	if err := s.database.DeleteWebhook(appID, webhookID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return domain.WrapWebhookNotFound(webhookID, err)
+		}
+		return domain.WrapDatabaseOperation("delete webhook", err)
+	}
+	s.logger.InfoContext(ctx, "webhook deleted", "appID", appID, "webhookID", webhookID)
+	return nil
+}
+
+// TestWebhook sends a "test" event to the webhook and waits for the outcome, which is returned
+// as the webhook's last delivery fields. A failed delivery is not an error.
+func (s *webhookService) TestWebhook(ctx context.Context, appID, webhookID string) (*db.AppWebhook, error) {
+	app, err := s.database.GetApp(appID)
+	if err != nil {
+		return nil, domain.WrapAppNotFound(appID, err)
+	}
+	hook, err := s.getWebhook(appID, webhookID)
+	if err != nil {
+		return nil, err
+	}
+
+	payload := webhook.NewPayload(app, s.dispatcher.NodeID(), constants.WebhookEventTest, "Test delivery")
+	if err := s.dispatcher.Deliver(ctx, hook, payload); err != nil {
+		s.logger.InfoContext(ctx, "test webhook delivery failed", "appID", appID, "webhookID", webhookID, "error", err)
+	}
+
+	if hook, err = s.getWebhook(appID, webhookID); err != nil {
+		return nil, err
+	}
+	hook.Secret = ""
+	return hook, nil
+}
+
+// getWebhook loads one of an app's webhooks, including its secret
+func (s *webhookService) getWebhook(appID, webhookID string) (*db.AppWebhook, error) {
+	hook, err := s.database.GetWebhook(appID, webhookID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, domain.WrapWebhookNotFound(webhookID, err)
+		}
+		return nil, domain.WrapDatabaseOperation("get webhook", err)
+	}
+	return hook, nil
+}
+
+// validateWebhookURL requires the URL in field to be an absolute http(s) URL
+func validateWebhookURL(field, raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return domain.WrapValidationError(field, err)
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return domain.WrapValidationError(field, fmt.Errorf("must be an absolute http or https URL"))
+	}
+	return nil
+}
+
+// validateWebhookHolding checks the webhook's digest mode, quiet hours and their timezone
+func validateWebhookHolding(hook *db.AppWebhook) error {
+	if _, ok := webhook.DigestIntervals[hook.Digest]; hook.Digest != "" && !ok {
+		return domain.WrapValidationError("digest", fmt.Errorf("unknown digest mode %q (expected %s or %s)",
+			hook.Digest, constants.WebhookDigestHourly, constants.WebhookDigestDaily))
+	}
+	if (hook.QuietHoursStart == "") != (hook.QuietHoursEnd == "") {
+		return domain.WrapValidationError("quiet_hours_end", fmt.Errorf("quiet hours need both a start and an end"))
+	}
+	if _, err := time.Parse(webhook.QuietHoursLayout, hook.QuietHoursStart); hook.QuietHoursStart != "" && err != nil {
+		return domain.WrapValidationError("quiet_hours_start", fmt.Errorf("must be a time of day as HH:MM"))
+	}
+	if _, err := time.Parse(webhook.QuietHoursLayout, hook.QuietHoursEnd); hook.QuietHoursEnd != "" && err != nil {
+		return domain.WrapValidationError("quiet_hours_end", fmt.Errorf("must be a time of day as HH:MM"))
+	}
+	if hook.Timezone == "" {
+		hook.Timezone = "UTC"
+	}
+	if _, err := time.LoadLocation(hook.Timezone); err != nil {
+		return domain.WrapValidationError("timezone", err)
+	}
+	return nil
+}
+
+// normalizeWebhookEvents checks every event is known and drops duplicates
+func normalizeWebhookEvents(events []string) ([]string, error) {
+	if len(events) == 0 {
+		return nil, domain.WrapValidationError("events", fmt.Errorf("at least one event is required"))
+	}
+	result := make([]string, 0, len(events))
+	for _, e := range events {
+		if !slices.Contains(webhook.Events, e) {
+			return nil, domain.WrapValidationError("events", fmt.Errorf("unknown event %q (expected one of %v)", e, webhook.Events))
+		}
+		if !slices.Contains(result, e) {
+			result = append(result, e)
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,114 @@
+package service
+
+import (
+	"context"
+	"log/slog"
+	"path/filepath"
+	"testing"
+
+	"github.com/selfhostly/internal/constants"
+	"github.com/selfhostly/internal/db"
+	"github.com/selfhostly/internal/domain"
+	"github.com/selfhostly/internal/webhook"
+)
+
+func TestWebhookService(t *testing.T) {
+	database, err := db.Init(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to initialize database: %v", err)
+	}
+	defer database.Close()
+
+	app := db.NewApp("hooked-app", "", "services:\n  web:\n    image: nginx\n")
+	if err := database.CreateApp(app); err != nil {
+		t.Fatalf("Failed to create app: %v", err)
+	}
+
+	svc := NewWebhookService(database, webhook.NewDispatcher(database, "", slog.Default()), slog.Default())
+	ctx := context.Background()
+
+	invalid := []domain.CreateWebhookRequest{
+		{URL: "ftp://example.com/hook", Events: []string{constants.WebhookEventStart}},
+		{URL: "/relative", Events: []string{constants.WebhookEventStart}},
+		{URL: "https://example.com/hook", Events: nil},
+		{URL: "https://example.com/hook", Events: []string{constants.WebhookEventTest}},
+		{URL: "https://example.com/hook", Events: []string{constants.WebhookEventStart}, Digest: "weekly"},
+		{URL: "https://example.com/hook", Events: []string{constants.WebhookEventStart}, QuietHoursStart: "22:00"},
+		{URL: "https://example.com/hook", Events: []string{constants.WebhookEventStart}, QuietHoursStart: "22:00", QuietHoursEnd: "7am"},
+		{URL: "https://example.com/hook", Events: []string{constants.WebhookEventStart}, QuietHoursStart: "22:00", QuietHoursEnd: "07:00", Timezone: "Mars/Olympus"},
+	}
+	for _, req := range invalid {
+		if _, err := svc.CreateWebhook(ctx, app.ID, req); !domain.IsValidationError(err) {
+			t.Errorf("CreateWebhook(%+v): expected validation error, got %v", req, err)
+		}
+	}
+	if _, err := svc.CreateWebhook(ctx, "missing", domain.CreateWebhookRequest{URL: "https://example.com", Events: []string{"start"}}); !domain.IsNotFoundError(err) {
+		t.Errorf("expected not found error for unknown app, got %v", err)
+	}
+
+	hook, err := svc.CreateWebhook(ctx, app.ID, domain.CreateWebhookRequest{
+		URL:    "https://example.com/hook",
+		Events: []string{constants.WebhookEventStart, constants.WebhookEventCrash, constants.WebhookEventStart},
+	})
+	if err != nil {
+		t.Fatalf("CreateWebhook: %v", err)
+	}
+	if hook.Secret == "" {
+		t.Error("expected the secret to be returned on creation")
+	}
+	if len(hook.Events) != 2 || !hook.Enabled || hook.Digest != "" || hook.Timezone != "UTC" {
+		t.Errorf("unexpected webhook %+v", hook)
+	}
+
+	list, err := svc.ListWebhooks(ctx, app.ID)
+	if err != nil {
+		t.Fatalf("ListWebhooks: %v", err)
+	}
+	if len(list) != 1 || list[0].Secret != "" {
+		t.Fatalf("expected one webhook without its secret, got %+v", list)
+	}
+
+	disabled := false
+	updated, err := svc.UpdateWebhook(ctx, app.ID, hook.ID, domain.UpdateWebhookRequest{
+		Events:  []string{constants.WebhookEventUpdate},
+		Enabled: &disabled,
+	})
+	if err != nil {
+		t.Fatalf("UpdateWebhook: %v", err)
+	}
+	if updated.Enabled || len(updated.Events) != 1 || updated.Events[0] != constants.WebhookEventUpdate || updated.URL != hook.URL {
+		t.Errorf("unexpected updated webhook %+v", updated)
+	}
+	stored, err := database.GetWebhook(app.ID, hook.ID)
+	if err != nil {
+		t.Fatalf("GetWebhook: %v", err)
+	}
+	if stored.Secret != hook.Secret {
+		t.Error("expected update to keep the secret")
+	}
+
+	digest, start, end, timezone := constants.WebhookDigestDaily, "22:00", "07:00", "Europe/Berlin"
+	updated, err = svc.UpdateWebhook(ctx, app.ID, hook.ID, domain.UpdateWebhookRequest{
+		Digest: &digest, QuietHoursStart: &start, QuietHoursEnd: &end, Timezone: &timezone,
+	})
+	if err != nil {
+		t.Fatalf("UpdateWebhook: %v", err)
+	}
+	if updated.Digest != digest || updated.QuietHoursStart != start || updated.QuietHoursEnd != end || updated.Timezone != timezone {
+		t.Errorf("expected digest mode and quiet hours to be saved, got %+v", updated)
+	}
+	none := ""
+	if _, err := svc.UpdateWebhook(ctx, app.ID, hook.ID, domain.UpdateWebhookRequest{QuietHoursEnd: &none}); !domain.IsValidationError(err) {
+		t.Errorf("expected validation error for quiet hours without an end, got %v", err)
+	}
+
+	if err := svc.DeleteWebhook(ctx, app.ID, hook.ID); err != nil {
+		t.Fatalf("DeleteWebhook: %v", err)
+	}
+	if err := svc.DeleteWebhook(ctx, app.ID, hook.ID); !domain.IsNotFoundError(err) {
+		t.Errorf("expected not found error deleting twice, got %v", err)
+	}
+	if _, err := svc.UpdateWebhook(ctx, app.ID, hook.ID, domain.UpdateWebhookRequest{}); !domain.IsNotFoundError(err) {
+		t.Errorf("expected not found error updating a deleted webhook, got %v", err)
+	}
+}
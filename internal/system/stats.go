@@ -11,8 +11,11 @@ import (
 
 	"github.com/selfhostly/internal/db"
 	"github.com/selfhostly/internal/docker"
+	"github.com/selfhostly/internal/platform"
 	"github.com/shirou/gopsutil/v3/cpu"
 	"github.com/shirou/gopsutil/v3/disk"
+	"github.com/shirou/gopsutil/v3/host"
+	"github.com/shirou/gopsutil/v3/load"
 	"github.com/shirou/gopsutil/v3/mem"
 )
 
@@ -20,20 +23,30 @@ import (
 type SystemStats struct {
 	NodeID     string          `json:"node_id"`
 	NodeName   string          `json:"node_name"`
+	OS         string          `json:"os"`   // Host operating system (runtime.GOOS)
+	Arch       string          `json:"arch"` // Host CPU architecture (runtime.GOARCH), e.g. amd64, arm64
 	CPU        CPUStats        `json:"cpu"`
 	Memory     MemoryStats     `json:"memory"`
 	Disk       DiskStats       `json:"disk"`
 	Docker     DockerStats     `json:"docker"`
-	Containers []ContainerInfo `json:"containers"`
+	// TemperatureCelsius is the hottest sensor reading; nil where the host exposes no sensors
+	TemperatureCelsius *float64        `json:"temperature_celsius,omitempty"`
+	Containers         []ContainerInfo `json:"containers"`
 	Timestamp  time.Time       `json:"timestamp"`
 	Error      string          `json:"error,omitempty"` // Error message if stats couldn't be fetched
 	Status     string          `json:"status"`          // "online", "offline", or "error"
+
+	// StorageRoots is disk usage of each directory the node creates apps under
+	StorageRoots []StorageRootStats `json:"storage_roots,omitempty"`
 }
 
 // CPUStats represents CPU usage statistics
 type CPUStats struct {
 	UsagePercent float64 `json:"usage_percent"`
 	Cores        int     `json:"cores"`
+	Load1        float64 `json:"load_1"` // Load averages; zero where the OS has none (Windows)
+	Load5        float64 `json:"load_5"`
+	Load15       float64 `json:"load_15"`
 }
 
 // MemoryStats represents memory usage statistics
@@ -94,12 +107,13 @@ type Collector struct {
 	nodeName        string // The name of this node
 }
 
-// NewCollector creates a new system stats collector
+// NewCollector creates a new system stats collector that runs docker through the manager's
+// executor, so it sees the same docker as the manager (the stub one in chaos mode)
 func NewCollector(appsDir string, dockerManager *docker.Manager, database *db.DB, nodeID, nodeName string) *Collector {
 	return &Collector{
 		appsDir:         appsDir,
 		dockerManager:   dockerManager,
-		commandExecutor: docker.NewRealCommandExecutor(),
+		commandExecutor: dockerManager.GetCommandExecutor(),
 		database:        database,
 		nodeID:          nodeID,
 		nodeName:        nodeName,
@@ -131,10 +145,11 @@ func (c *Collector) GetSystemStats() (*SystemStats, error) {
 	var diskStats DiskStats
 	var dockerStats DockerStats
 	var containers []ContainerInfo
+	var temperature *float64
 
 	// Use sync.WaitGroup for proper synchronization
 	var wg sync.WaitGroup
-	wg.Add(5)
+	wg.Add(6)
 
 	go func() {
 		defer wg.Done()
@@ -148,7 +163,7 @@ func (c *Collector) GetSystemStats() (*SystemStats, error) {
 
 	go func() {
 		defer wg.Done()
-		diskStats = c.getDiskStats("/")
+		diskStats = c.getDiskStats(platform.DiskRoot(c.appsDir))
 	}()
 
 	go func() {
@@ -161,12 +176,19 @@ func (c *Collector) GetSystemStats() (*SystemStats, error) {
 		containers = c.getAllContainerStats(nodeID)
 	}()
 
+	go func() {
+		defer wg.Done()
+		temperature = c.getTemperature()
+	}()
+
 	// Wait for all goroutines to complete
 	wg.Wait()
 
 	stats := &SystemStats{
 		NodeID:     nodeID,
 		NodeName:   nodeName,
+		OS:         platform.OS(),
+		Arch:       platform.Arch(),
 		CPU:        cpuStats,
 		Memory:     memStats,
 		Disk:       diskStats,
@@ -175,6 +197,8 @@ func (c *Collector) GetSystemStats() (*SystemStats, error) {
 		Timestamp:  time.Now(),
 		Status:     "online", // Node is online since we successfully collected stats
 	}
+	stats.TemperatureCelsius = temperature
+	stats.StorageRoots = c.getStorageRootStats()
 
 	slog.Debug("system statistics collected successfully",
 		"cpu_usage", cpuStats.UsagePercent,
@@ -215,10 +239,28 @@ func (c *Collector) getCPUStats() CPUStats {
 		usagePercent = percentages[0]
 	}
 
-	return CPUStats{
+	stats := CPUStats{
 		UsagePercent: usagePercent,
 		Cores:        cores,
 	}
+	if avg, err := load.Avg(); err == nil {
+		stats.Load1, stats.Load5, stats.Load15 = avg.Load1, avg.Load5, avg.Load15
+	}
+	return stats
+}
+
+// getTemperature returns the hottest sensor reading, or nil when there are no readable sensors
+// (most VMs, containers without /sys, Windows)
+func (c *Collector) getTemperature() *float64 {
+	// Some sensors failing to read still yields the others, with a warnings error
+	sensors, _ := host.SensorsTemperatures()
+	var hottest *float64
+	for _, sensor := range sensors {
+		if t := sensor.Temperature; t > 0 && (hottest == nil || t > *hottest) {
+			hottest = &t
+		}
+	}
+	return hottest
 }
 
 // getMemoryStats retrieves memory usage statistics
@@ -574,7 +616,7 @@ func (c *Collector) getManagedAppsMap() map[string]bool {
 
 	// Use database as source of truth for managed apps
 	if c.database != nil {
-		apps, err := c.database.GetAllApps()
+		apps, err := c.database.GetAllAppsMetadata()
 		if err == nil {
 			for _, app := range apps {
 				managedApps[app.Name] = true
@@ -0,0 +1,50 @@
+package system
+
+import (
+	"log/slog"
+
+	"github.com/selfhostly/internal/constants"
+)
+
+// StorageRootStats represents disk usage of one storage root
+type StorageRootStats struct {
+	Name string    `json:"name"`
+	Apps int       `json:"apps"` // Apps on this node created under the root
+	Disk DiskStats `json:"disk"`
+}
+
+// getStorageRootStats reports disk usage of each storage root, with how many apps it holds
+func (c *Collector) getStorageRootStats() []StorageRootStats {
+	if c.dockerManager == nil {
+		return nil
+	}
+
+	appsPerRoot := make(map[string]int)
+	if c.database != nil {
+		apps, err := c.database.GetAllAppsMetadata()
+		if err != nil {
+			slog.Warn("failed to get apps for storage root stats", "error", err)
+		}
+		for _, app := range apps {
+			if app.NodeID != c.nodeID {
+				continue
+			}
+			root := app.StorageRoot
+			if root == "" {
+				root = constants.DefaultStorageRoot
+			}
+			appsPerRoot[root]++
+		}
+	}
+
+	roots := c.dockerManager.StorageRoots()
+	stats := make([]StorageRootStats, 0, len(roots))
+	for _, root := range roots {
+		stats = append(stats, StorageRootStats{
+			Name: root.Name,
+			Apps: appsPerRoot[root.Name],
+			Disk: c.getDiskStats(root.Path),
+		})
+	}
+	return stats
+}
@@ -0,0 +1,38 @@
+package system
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/selfhostly/internal/constants"
+	"github.com/selfhostly/internal/db"
+	"github.com/selfhostly/internal/docker"
+)
+
+func TestCollector_GetStorageRootStats(t *testing.T) {
+	collector, database, cleanup := setupTestCollector(t, docker.NewMockCommandExecutor())
+	defer cleanup()
+
+	hddDir := t.TempDir()
+	collector.dockerManager.SetStorageRoots(map[string]string{"hdd": hddDir})
+
+	for i, root := range []string{"", "hdd", "hdd"} {
+		app := db.NewApp(fmt.Sprintf("app-%d", i), "", "services: {}\n")
+		app.NodeID = collector.nodeID
+		app.StorageRoot = root
+		if err := database.CreateApp(app); err != nil {
+			t.Fatalf("Failed to create app: %v", err)
+		}
+	}
+
+	stats := collector.getStorageRootStats()
+	if len(stats) != 2 {
+		t.Fatalf("Expected 2 storage roots, got %+v", stats)
+	}
+	if stats[0].Name != constants.DefaultStorageRoot || stats[0].Apps != 1 {
+		t.Errorf("Unexpected default root stats %+v", stats[0])
+	}
+	if stats[1].Name != "hdd" || stats[1].Apps != 2 || stats[1].Disk.Path != hddDir || stats[1].Disk.Total == 0 {
+		t.Errorf("Unexpected hdd root stats %+v", stats[1])
+	}
+}
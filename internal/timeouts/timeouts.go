@@ -0,0 +1,184 @@
+package timeouts
+
+import (
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// Class groups API operations by how long they are expected to take
+type Class string
+
+const (
+	Read            Class = "read"             // Lists, lookups and stats
+	Logs            Class = "logs"             // Container log fetches and log search
+	ContainerUpdate Class = "container_update" // Start, stop, restart and other container changes
+	ImagePull       Class = "image_pull"       // Creates, redeploys and updates that may pull images
+	Stream          Class = "stream"           // Server-sent event streams such as live app stats and /api/events
+)
+
+// Config holds the timeout for each operation class. It is shared by the gateway, which bounds
+// proxied requests, and the node client, which bounds inter-node calls.
+type Config struct {
+	Read            time.Duration
+	Logs            time.Duration
+	ContainerUpdate time.Duration
+	ImagePull       time.Duration
+	Stream          time.Duration
+}
+
+// Default returns the built-in timeouts
+func Default() Config {
+	return Config{
+		Read:            15 * time.Second,
+		Logs:            60 * time.Second,
+		ContainerUpdate: 90 * time.Second,
+		ImagePull:       10 * time.Minute,
+		Stream:          10 * time.Minute,
+	}
+}
+
+// LoadFromEnv reads TIMEOUT_READ_SEC, TIMEOUT_LOGS_SEC, TIMEOUT_CONTAINER_UPDATE_SEC,
+// TIMEOUT_IMAGE_PULL_SEC and TIMEOUT_STREAM_SEC, keeping the default for anything unset or invalid
+func LoadFromEnv() Config {
+	cfg := Default()
+	cfg.Read = durationFromEnv("TIMEOUT_READ_SEC", cfg.Read)
+	cfg.Logs = durationFromEnv("TIMEOUT_LOGS_SEC", cfg.Logs)
+	cfg.ContainerUpdate = durationFromEnv("TIMEOUT_CONTAINER_UPDATE_SEC", cfg.ContainerUpdate)
+	cfg.ImagePull = durationFromEnv("TIMEOUT_IMAGE_PULL_SEC", cfg.ImagePull)
+	cfg.Stream = durationFromEnv("TIMEOUT_STREAM_SEC", cfg.Stream)
+	return cfg
+}
+
+// Live holds a Config that can be swapped while requests are in flight, so a config reload
+// reaches clients that were built at startup
+type Live struct {
+	current atomic.Pointer[Config]
+}
+
+// NewLive returns a Live starting at c
+func NewLive(c Config) *Live {
+	l := &Live{}
+	l.Store(c)
+	return l
+}
+
+// Load returns the current timeouts; a nil Live yields the defaults
+func (l *Live) Load() Config {
+	if l == nil {
+		return Default()
+	}
+	return *l.current.Load()
+}
+
+// Store replaces the current timeouts
+func (l *Live) Store(c Config) {
+	l.current.Store(&c)
+}
+
+// ChangedEnv lists the environment variables whose timeouts differ between old and next
+func ChangedEnv(old, next Config) []string {
+	var changed []string
+	for _, t := range []struct {
+		env       string
+		old, next time.Duration
+	}{
+		{"TIMEOUT_READ_SEC", old.Read, next.Read},
+		{"TIMEOUT_LOGS_SEC", old.Logs, next.Logs},
+		{"TIMEOUT_CONTAINER_UPDATE_SEC", old.ContainerUpdate, next.ContainerUpdate},
+		{"TIMEOUT_IMAGE_PULL_SEC", old.ImagePull, next.ImagePull},
+		{"TIMEOUT_STREAM_SEC", old.Stream, next.Stream},
+	} {
+		if t.old != t.next {
+			changed = append(changed, t.env)
+		}
+	}
+	return changed
+}
+
+// For returns the timeout for class, falling back to the default when it is not set
+func (c Config) For(class Class) time.Duration {
+	var d, fallback time.Duration
+	switch class {
+	case Logs:
+		d, fallback = c.Logs, Default().Logs
+	case ContainerUpdate:
+		d, fallback = c.ContainerUpdate, Default().ContainerUpdate
+	case ImagePull:
+		d, fallback = c.ImagePull, Default().ImagePull
+	case Stream:
+		d, fallback = c.Stream, Default().Stream
+	default:
+		d, fallback = c.Read, Default().Read
+	}
+	if d <= 0 {
+		return fallback
+	}
+	return d
+}
+
+// Max returns the longest configured timeout
+func (c Config) Max() time.Duration {
+	longest := c.For(Read)
+	for _, class := range []Class{Logs, ContainerUpdate, ImagePull, Stream} {
+		if d := c.For(class); d > longest {
+			longest = d
+		}
+	}
+	return longest
+}
+
+// ForRequest returns the timeout for an API request
+func (c Config) ForRequest(method, path string) time.Duration {
+	return c.For(Classify(method, path))
+}
+
+// Classify maps an API request to its operation class
+func Classify(method, path string) Class {
+	// Streams stay open until the client leaves or the stream timeout ends them
+	if strings.HasSuffix(path, "/stream") || path == "/api/events" {
+		return Stream
+	}
+	if strings.HasSuffix(path, "/logs") || strings.HasPrefix(path, "/api/logs/") {
+		return Logs
+	}
+
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return Read
+	}
+
+	// Creating, redeploying, updating or rolling back an app runs compose up and may pull images
+	if path == "/api/apps" && method == http.MethodPost {
+		return ImagePull
+	}
+	if rest, ok := strings.CutPrefix(path, "/api/apps/"); ok {
+		parts := strings.Split(rest, "/")
+		switch {
+		case len(parts) == 1 && method == http.MethodPut:
+			return ImagePull
+		case len(parts) == 2 && parts[1] == "update":
+			return ImagePull
+		case len(parts) == 4 && parts[1] == "compose" && parts[2] == "rollback":
+			return ImagePull
+		}
+	}
+
+	return ContainerUpdate
+}
+
+// durationFromEnv parses key as a whole number of seconds
+func durationFromEnv(key string, fallback time.Duration) time.Duration {
+	v := os.Getenv(key)
+	if v == "" {
+		return fallback
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil || n <= 0 {
+		return fallback
+	}
+	return time.Duration(n) * time.Second
+}
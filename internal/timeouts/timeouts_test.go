@@ -0,0 +1,76 @@
+package timeouts
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		method string
+		path   string
+		want   Class
+	}{
+		{http.MethodGet, "/api/apps", Read},
+		{http.MethodGet, "/api/apps/app-1/stats", Read},
+		{http.MethodGet, "/api/apps/app-1/logs", Logs},
+		{http.MethodGet, "/api/apps/app-1/stats/stream", Stream},
+		{http.MethodGet, "/api/events", Stream},
+		{http.MethodGet, "/api/logs/search", Logs},
+		{http.MethodPost, "/api/apps", ImagePull},
+		{http.MethodPut, "/api/apps/app-1", ImagePull},
+		{http.MethodPost, "/api/apps/app-1/update", ImagePull},
+		{http.MethodPost, "/api/apps/app-1/compose/rollback/3", ImagePull},
+		{http.MethodPost, "/api/apps/app-1/start", ContainerUpdate},
+		{http.MethodDelete, "/api/apps/app-1", ContainerUpdate},
+		{http.MethodPost, "/api/system/containers/abc/restart", ContainerUpdate},
+		{http.MethodPut, "/api/settings", ContainerUpdate},
+	}
+
+	for _, tt := range tests {
+		if got := Classify(tt.method, tt.path); got != tt.want {
+			t.Errorf("Classify(%s, %s) = %s, want %s", tt.method, tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestLoadFromEnv(t *testing.T) {
+	t.Setenv("TIMEOUT_READ_SEC", "5")
+	t.Setenv("TIMEOUT_LOGS_SEC", "invalid")
+	t.Setenv("TIMEOUT_IMAGE_PULL_SEC", "1800")
+
+	cfg := LoadFromEnv()
+	if cfg.Read != 5*time.Second {
+		t.Errorf("expected read timeout 5s, got %v", cfg.Read)
+	}
+	if cfg.Logs != Default().Logs {
+		t.Errorf("expected invalid logs timeout to keep the default, got %v", cfg.Logs)
+	}
+	if cfg.ContainerUpdate != Default().ContainerUpdate {
+		t.Errorf("expected unset container update timeout to keep the default, got %v", cfg.ContainerUpdate)
+	}
+	if cfg.Max() != 30*time.Minute {
+		t.Errorf("expected max timeout 30m, got %v", cfg.Max())
+	}
+}
+
+func TestFor_ZeroValueUsesDefaults(t *testing.T) {
+	var cfg Config
+	if got := cfg.For(ImagePull); got != Default().ImagePull {
+		t.Errorf("expected default image pull timeout, got %v", got)
+	}
+}
+
+func TestLive(t *testing.T) {
+	var unset *Live
+	if got := unset.Load(); got != Default() {
+		t.Errorf("expected nil Live to load the defaults, got %+v", got)
+	}
+
+	live := NewLive(Config{Read: time.Second})
+	live.Store(Config{Read: 2 * time.Second})
+	if got := live.Load().For(Read); got != 2*time.Second {
+		t.Errorf("expected stored read timeout 2s, got %v", got)
+	}
+}
@@ -0,0 +1,131 @@
+package trash
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// archiveSuffix marks files in the trash directory that Purge may remove
+const archiveSuffix = ".tar.gz"
+
+// Archive writes a gzipped tarball of srcDir into trashDir and returns its path. The archive is
+// named <name>-<timestamp>.tar.gz and only appears once it has been written completely.
+func Archive(srcDir, trashDir, name string) (string, error) {
+	if err := os.MkdirAll(trashDir, 0750); err != nil {
+		return "", fmt.Errorf("failed to create trash directory: %w", err)
+	}
+
+	archivePath := filepath.Join(trashDir, fmt.Sprintf("%s-%s%s", name, time.Now().UTC().Format("20060102T150405Z"), archiveSuffix))
+	tmp, err := os.CreateTemp(trashDir, "."+name+"-*.partial")
+	if err != nil {
+		return "", fmt.Errorf("failed to create archive: %w", err)
+	}
+	defer os.Remove(tmp.Name()) // No-op once renamed
+
+	if err := writeTarGz(tmp, srcDir); err != nil {
+		tmp.Close()
+		return "", fmt.Errorf("failed to archive %s: %w", srcDir, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return "", fmt.Errorf("failed to write archive: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), archivePath); err != nil {
+		return "", fmt.Errorf("failed to finalize archive: %w", err)
+	}
+	return archivePath, nil
+}
+
+// writeTarGz streams srcDir into w, with paths relative to srcDir's parent so the archive
+// extracts into a directory named after the app
+func writeTarGz(w io.Writer, srcDir string) error {
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	base := filepath.Dir(srcDir)
+
+	err := filepath.WalkDir(srcDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		var link string
+		if info.Mode()&os.ModeSymlink != 0 {
+			if link, err = os.Readlink(path); err != nil {
+				return err
+			}
+		}
+		header, err := tar.FileInfoHeader(info, link)
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(base, path)
+		if err != nil {
+			return err
+		}
+		header.Name = filepath.ToSlash(rel)
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = io.Copy(tw, f)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+// Purge removes archives in trashDir last modified more than ttl before now and returns their
+// paths. A missing trash directory is not an error.
+func Purge(trashDir string, ttl time.Duration, now time.Time) ([]string, error) {
+	entries, err := os.ReadDir(trashDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read trash directory: %w", err)
+	}
+
+	var removed []string
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), archiveSuffix) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		if now.Sub(info.ModTime()) <= ttl {
+			continue
+		}
+		path := filepath.Join(trashDir, entry.Name())
+		if err := os.Remove(path); err != nil {
+			return removed, fmt.Errorf("failed to remove %s: %w", path, err)
+		}
+		removed = append(removed, path)
+	}
+	return removed, nil
+}
@@ -0,0 +1,106 @@
+package trash
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestArchive(t *testing.T) {
+	appDir := filepath.Join(t.TempDir(), "blog")
+	if err := os.MkdirAll(filepath.Join(appDir, "data"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(appDir, "docker-compose.yml"), []byte("services: {}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(appDir, "data", "db.sqlite"), []byte("important"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	trashDir := filepath.Join(t.TempDir(), "trash")
+	archivePath, err := Archive(appDir, trashDir, "blog")
+	if err != nil {
+		t.Fatalf("Archive: %v", err)
+	}
+	if filepath.Dir(archivePath) != trashDir || !strings.HasPrefix(filepath.Base(archivePath), "blog-") {
+		t.Errorf("Unexpected archive path %s", archivePath)
+	}
+
+	f, err := os.Open(archivePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tr := tar.NewReader(gz)
+	contents := make(map[string]string)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(tr)
+		contents[header.Name] = string(body)
+	}
+
+	if contents["blog/data/db.sqlite"] != "important" {
+		t.Errorf("Expected bind-mounted data in the archive, got entries %v", contents)
+	}
+	if _, ok := contents["blog/docker-compose.yml"]; !ok {
+		t.Errorf("Expected compose file in the archive, got entries %v", contents)
+	}
+
+	leftovers, _ := filepath.Glob(filepath.Join(trashDir, ".*partial"))
+	if len(leftovers) != 0 {
+		t.Errorf("Expected no partial files left behind, got %v", leftovers)
+	}
+}
+
+func TestPurge(t *testing.T) {
+	trashDir := t.TempDir()
+	now := time.Now()
+
+	old := filepath.Join(trashDir, "old-app-20200101T000000Z.tar.gz")
+	recent := filepath.Join(trashDir, "new-app-20200101T000000Z.tar.gz")
+	unrelated := filepath.Join(trashDir, "notes.txt")
+	for _, path := range []string{old, recent, unrelated} {
+		if err := os.WriteFile(path, []byte("x"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	weekAgo := now.Add(-8 * 24 * time.Hour)
+	for _, path := range []string{old, unrelated} {
+		if err := os.Chtimes(path, weekAgo, weekAgo); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	removed, err := Purge(trashDir, 7*24*time.Hour, now)
+	if err != nil {
+		t.Fatalf("Purge: %v", err)
+	}
+	if len(removed) != 1 || removed[0] != old {
+		t.Errorf("Expected only %s to be purged, got %v", old, removed)
+	}
+	for _, path := range []string{recent, unrelated} {
+		if _, err := os.Stat(path); err != nil {
+			t.Errorf("Expected %s to be kept: %v", path, err)
+		}
+	}
+
+	if _, err := Purge(filepath.Join(trashDir, "missing"), time.Hour, now); err != nil {
+		t.Errorf("Expected a missing trash directory to be ignored, got %v", err)
+	}
+}
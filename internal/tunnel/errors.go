@@ -9,6 +9,9 @@ var (
 	// ErrTunnelNotFound is returned when a tunnel doesn't exist for the given app
 	ErrTunnelNotFound = errors.New("tunnel not found")
 
+	// ErrZoneNotFound is returned when a DNS zone doesn't exist or the credentials can't see it
+	ErrZoneNotFound = errors.New("zone not found")
+
 	// ErrProviderNotFound is returned when trying to get a provider that isn't registered
 	ErrProviderNotFound = errors.New("tunnel provider not found")
 
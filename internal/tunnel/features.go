@@ -24,6 +24,28 @@ const (
 	// FeatureQuickTunnel indicates the provider supports Quick Tunnels
 	// (temporary tunnels without API registration, e.g., Cloudflare's trycloudflare.com)
 	FeatureQuickTunnel Feature = "quick_tunnel"
+
+	// FeatureHealthCheck indicates the provider can verify its API is reachable
+	FeatureHealthCheck Feature = "health_check"
+
+	// FeatureAccount indicates the provider can list and delete every tunnel in its account
+	FeatureAccount Feature = "account"
+
+	// FeatureZones indicates the provider can list DNS zones and their hostnames
+	FeatureZones Feature = "zones"
+
+	// FeatureConnectors indicates the provider can report a tunnel's connected connectors
+	FeatureConnectors Feature = "connectors"
+
+	// FeatureReplicas indicates the provider's tunnels can be run by several containers at once
+	FeatureReplicas Feature = "replicas"
+
+	// FeatureConnectorOptions indicates the provider's tunnel containers take per-tunnel options
+	// such as the edge protocol
+	FeatureConnectorOptions Feature = "connector_options"
+
+	// FeatureTraffic indicates the provider can report the HTTP traffic served for an app's hostnames
+	FeatureTraffic Feature = "traffic"
 )
 
 // SupportsFeature checks if a provider implements a specific feature
@@ -64,6 +86,34 @@ func SupportsFeature(p Provider, feature Feature) bool {
 		_, ok := p.(ListProvider)
 		return ok
 
+	case FeatureHealthCheck:
+		_, ok := p.(HealthCheckProvider)
+		return ok
+
+	case FeatureAccount:
+		_, ok := p.(AccountProvider)
+		return ok
+
+	case FeatureZones:
+		_, ok := p.(ZoneProvider)
+		return ok
+
+	case FeatureConnectors:
+		_, ok := p.(ConnectorProvider)
+		return ok
+
+	case FeatureReplicas:
+		_, ok := p.(ReplicaProvider)
+		return ok
+
+	case FeatureConnectorOptions:
+		_, ok := p.(ConnectorOptionsProvider)
+		return ok
+
+	case FeatureTraffic:
+		_, ok := p.(TrafficProvider)
+		return ok
+
 	default:
 		return false
 	}
@@ -73,11 +123,18 @@ func SupportsFeature(p Provider, feature Feature) bool {
 // This is useful for API responses to inform clients about provider capabilities.
 func GetSupportedFeatures(p Provider) map[Feature]bool {
 	return map[Feature]bool{
-		FeatureIngress:     SupportsFeature(p, FeatureIngress),
-		FeatureDNS:         SupportsFeature(p, FeatureDNS),
-		FeatureStatusSync:  SupportsFeature(p, FeatureStatusSync),
-		FeatureContainer:   SupportsFeature(p, FeatureContainer),
-		FeatureList:        SupportsFeature(p, FeatureList),
-		FeatureQuickTunnel: SupportsFeature(p, FeatureQuickTunnel),
+		FeatureIngress:          SupportsFeature(p, FeatureIngress),
+		FeatureDNS:              SupportsFeature(p, FeatureDNS),
+		FeatureStatusSync:       SupportsFeature(p, FeatureStatusSync),
+		FeatureContainer:        SupportsFeature(p, FeatureContainer),
+		FeatureList:             SupportsFeature(p, FeatureList),
+		FeatureQuickTunnel:      SupportsFeature(p, FeatureQuickTunnel),
+		FeatureHealthCheck:      SupportsFeature(p, FeatureHealthCheck),
+		FeatureAccount:          SupportsFeature(p, FeatureAccount),
+		FeatureZones:            SupportsFeature(p, FeatureZones),
+		FeatureConnectors:       SupportsFeature(p, FeatureConnectors),
+		FeatureReplicas:         SupportsFeature(p, FeatureReplicas),
+		FeatureConnectorOptions: SupportsFeature(p, FeatureConnectorOptions),
+		FeatureTraffic:          SupportsFeature(p, FeatureTraffic),
 	}
 }
@@ -2,6 +2,7 @@ package tunnel
 
 import (
 	"context"
+	"time"
 )
 
 // Provider defines the core interface that ALL tunnel providers must implement.
@@ -57,6 +58,19 @@ type IngressProvider interface {
 	UpdateIngress(ctx context.Context, appID string, rules interface{}) error
 }
 
+// SidecarIngressProvider defines the interface for providers whose ingress rules are rendered
+// into the tunnel container's config instead of being kept by the provider's API, so an app's
+// sidecar has to be regenerated for new rules to take effect.
+//
+// Example: frpc registers a proxy for each rule in the config it is started with.
+type SidecarIngressProvider interface {
+	IngressProvider
+	ContainerProvider
+
+	// IngressInSidecar reports whether UpdateIngress changes what GetContainerConfig returns
+	IngressInSidecar() bool
+}
+
 // DNSProvider defines the interface for providers that can manage DNS records.
 // Not all providers have DNS management capabilities.
 //
@@ -99,6 +113,62 @@ type ContainerProvider interface {
 	GetContainerConfig(tunnelToken string, appName string) *ContainerConfig
 }
 
+// ConnectorProvider defines the interface for providers that can report which connectors are
+// connected for a tunnel, so callers can tell a tunnel whose container is serving from one that
+// merely exists.
+//
+// Example: Cloudflare lists the cloudflared instances connected to each of its tunnels.
+type ConnectorProvider interface {
+	Provider
+
+	// ListConnectors returns the connectors currently connected for an app's tunnel; an empty
+	// list means no container is running the tunnel. Returns ErrTunnelNotFound if the app has
+	// no tunnel.
+	ListConnectors(ctx context.Context, appID string) ([]*Connector, error)
+}
+
+// ReplicaProvider defines the interface for providers whose tunnels can be served by several
+// connectors at once, so the tunnel container can be replicated and one restarting doesn't take
+// the app offline.
+//
+// Example: Cloudflare balances a tunnel's traffic across every cloudflared running its token.
+type ReplicaProvider interface {
+	Provider
+
+	// MaxReplicas returns how many connectors may run one tunnel at the same time.
+	MaxReplicas() int
+}
+
+// TrafficProvider defines the interface for providers that report the HTTP traffic their edge
+// served for the hostnames routed to an app's tunnel.
+type TrafficProvider interface {
+	Provider
+
+	// GetTraffic returns the traffic an app's tunnel hostnames received between since and until.
+	// Returns ErrTunnelNotFound if the app has no tunnel.
+	GetTraffic(ctx context.Context, appID string, since, until time.Time) (*Traffic, error)
+}
+
+// ConnectorOptionsProvider defines the interface for providers whose tunnel container can be
+// tuned per tunnel. Options are saved with the app's tunnel and rendered into the container
+// config whenever it is regenerated.
+//
+// Example: cloudflared's --protocol, --edge-ip-version, --post-quantum and --loglevel flags.
+type ConnectorOptionsProvider interface {
+	ContainerProvider
+
+	// GetConnectorOptions returns the options saved for an app's tunnel, or nil if none are set.
+	// Returns ErrTunnelNotFound if the app has no tunnel.
+	GetConnectorOptions(ctx context.Context, appID string) (*ConnectorOptions, error)
+
+	// SetConnectorOptions validates and saves options for an app's tunnel; nil clears them.
+	// Returns an error wrapping ErrInvalidConfiguration if an option has an unsupported value.
+	SetConnectorOptions(ctx context.Context, appID string, options *ConnectorOptions) error
+
+	// ApplyConnectorOptions renders options into a config returned by GetContainerConfig.
+	ApplyConnectorOptions(config *ContainerConfig, options *ConnectorOptions)
+}
+
 // ListProvider defines the interface for providers that can list all tunnels.
 // This is optional because some providers might not support efficient listing.
 type ListProvider interface {
@@ -109,6 +179,48 @@ type ListProvider interface {
 	ListTunnels(ctx context.Context, nodeIDs []string) ([]*Tunnel, error)
 }
 
+// AccountProvider defines the interface for providers that can see every tunnel in the
+// configured account, including ones no app record points at any more.
+type AccountProvider interface {
+	Provider
+
+	// ListAccountTunnels returns all tunnels that exist in the provider account, whether or
+	// not this platform created them. Deleted tunnels are not included.
+	ListAccountTunnels(ctx context.Context) ([]*Tunnel, error)
+
+	// DeleteAccountTunnel deletes a tunnel from the provider account by its provider ID,
+	// along with any DNS records pointing at it and any local record of it.
+	DeleteAccountTunnel(ctx context.Context, tunnelID string) error
+
+	// ImportTunnel adopts an existing account tunnel for an app: it fetches the tunnel's
+	// credentials and routing rules and records the tunnel as the app's own. Returns
+	// ErrTunnelNotFound if the account has no such tunnel.
+	ImportTunnel(ctx context.Context, tunnelID, appID string) (*Tunnel, error)
+}
+
+// ZoneProvider defines the interface for providers that can list the DNS zones and
+// existing hostnames available for routing, so clients can offer them before submitting
+// ingress rules.
+type ZoneProvider interface {
+	Provider
+
+	// ListZones returns the DNS zones the configured credentials can see.
+	ListZones(ctx context.Context) ([]*Zone, error)
+
+	// ListHostnames returns the DNS records in a zone.
+	ListHostnames(ctx context.Context, zoneID string) ([]*Hostname, error)
+}
+
+// HealthCheckProvider defines the interface for providers that can verify their
+// API is reachable and the configured credentials are accepted.
+type HealthCheckProvider interface {
+	Provider
+
+	// CheckHealth makes a lightweight authenticated call to the provider's API.
+	// Returns an error if the API can't be reached or rejects the credentials.
+	CheckHealth(ctx context.Context) error
+}
+
 // QuickTunnelProvider defines the interface for providers that support Quick Tunnels
 // (temporary tunnels without API registration, e.g., Cloudflare's trycloudflare.com).
 //
@@ -3,9 +3,10 @@ package cloudflare
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"log/slog"
-	"os"
+	"slices"
 	"strings"
 	"time"
 
@@ -13,6 +14,7 @@ import (
 	"github.com/selfhostly/internal/constants"
 	"github.com/selfhostly/internal/db"
 	"github.com/selfhostly/internal/docker"
+	"github.com/selfhostly/internal/platform"
 	"github.com/selfhostly/internal/tunnel"
 )
 
@@ -327,6 +329,116 @@ func (p *Provider) GetContainerConfig(tunnelToken string, appName string) *tunne
 	}
 }
 
+// ============================================================================
+// ReplicaProvider Interface
+// ============================================================================
+
+// MaxTunnelReplicas is Cloudflare's limit on cloudflared replicas running one tunnel
+const MaxTunnelReplicas = 25
+
+// MaxReplicas returns how many cloudflared instances may run one named tunnel. Quick Tunnels are
+// not covered: each cloudflared started with --url gets its own trycloudflare.com hostname.
+func (p *Provider) MaxReplicas() int {
+	return MaxTunnelReplicas
+}
+
+// ============================================================================
+// ConnectorOptionsProvider Interface
+// ============================================================================
+
+// Values cloudflared accepts for the connector options it exposes ("" leaves the flag unset)
+var (
+	tunnelProtocols      = []string{"auto", "quic", "http2"}
+	tunnelEdgeIPVersions = []string{"auto", "4", "6"}
+	tunnelLogLevels      = []string{"debug", "info", "warn", "error", "fatal"}
+)
+
+// GetConnectorOptions returns the cloudflared flags saved for an app's tunnel.
+func (p *Provider) GetConnectorOptions(ctx context.Context, appID string) (*tunnel.ConnectorOptions, error) {
+	cfTunnel, err := p.database.GetCloudflareTunnelByAppID(appID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, tunnel.ErrTunnelNotFound
+		}
+		return nil, fmt.Errorf("failed to get tunnel: %w", err)
+	}
+	if cfTunnel.Options == nil {
+		return nil, nil
+	}
+	options := tunnel.ConnectorOptions(*cfTunnel.Options)
+	return &options, nil
+}
+
+// SetConnectorOptions validates and saves cloudflared flags on an app's tunnel record.
+func (p *Provider) SetConnectorOptions(ctx context.Context, appID string, options *tunnel.ConnectorOptions) error {
+	if err := validateConnectorOptions(options); err != nil {
+		return err
+	}
+	cfTunnel, err := p.database.GetCloudflareTunnelByAppID(appID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return tunnel.ErrTunnelNotFound
+		}
+		return fmt.Errorf("failed to get tunnel: %w", err)
+	}
+	cfTunnel.Options = nil
+	if options != nil && *options != (tunnel.ConnectorOptions{}) {
+		stored := db.TunnelOptions(*options)
+		cfTunnel.Options = &stored
+	}
+	if err := p.database.UpdateCloudflareTunnel(cfTunnel); err != nil {
+		return fmt.Errorf("failed to save tunnel options: %w", err)
+	}
+	return nil
+}
+
+// ApplyConnectorOptions renders options as cloudflared flags, which belong between "tunnel" and
+// "run" (e.g. "tunnel --protocol http2 run").
+func (p *Provider) ApplyConnectorOptions(config *tunnel.ContainerConfig, options *tunnel.ConnectorOptions) {
+	if config == nil || options == nil {
+		return
+	}
+	command := []string{"tunnel"}
+	if options.Protocol != "" {
+		command = append(command, "--protocol", options.Protocol)
+	}
+	if options.EdgeIPVersion != "" {
+		command = append(command, "--edge-ip-version", options.EdgeIPVersion)
+	}
+	if options.PostQuantum {
+		command = append(command, "--post-quantum")
+	}
+	if options.LogLevel != "" {
+		command = append(command, "--loglevel", options.LogLevel)
+	}
+	config.Command = append(command, "run")
+}
+
+// validateConnectorOptions rejects values cloudflared would refuse to start with
+func validateConnectorOptions(options *tunnel.ConnectorOptions) error {
+	if options == nil {
+		return nil
+	}
+	for _, option := range []struct {
+		name    string
+		value   string
+		allowed []string
+	}{
+		{"protocol", options.Protocol, tunnelProtocols},
+		{"edge_ip_version", options.EdgeIPVersion, tunnelEdgeIPVersions},
+		{"log_level", options.LogLevel, tunnelLogLevels},
+	} {
+		if option.value != "" && !slices.Contains(option.allowed, option.value) {
+			return fmt.Errorf("%w: %s must be one of %s", tunnel.ErrInvalidConfiguration, option.name, strings.Join(option.allowed, ", "))
+		}
+	}
+	// Post-quantum key agreement is only available over QUIC
+	if options.PostQuantum && options.Protocol == "http2" {
+		return fmt.Errorf("%w: post_quantum requires the quic protocol", tunnel.ErrInvalidConfiguration)
+	}
+	return nil
+}
+
 // ============================================================================
 // QuickTunnelProvider Interface
 // ============================================================================
@@ -451,20 +563,7 @@ func (p *Provider) buildDefaultEndpoints(port int) []string {
 
 // isRunningInDocker checks if the current process is running inside a Docker container.
 func (p *Provider) isRunningInDocker() bool {
-	// Check for /.dockerenv file (Docker sets this)
-	if _, err := os.Stat("/.dockerenv"); err == nil {
-		return true
-	}
-	
-	// Check /proc/self/cgroup for docker (Linux)
-	if _, err := os.ReadFile("/proc/self/cgroup"); err == nil {
-		cgroup, err := os.ReadFile("/proc/self/cgroup")
-		if err == nil && strings.Contains(string(cgroup), "docker") {
-			return true
-		}
-	}
-	
-	return false
+	return platform.IsRunningInContainer()
 }
 
 // GetQuickTunnelContainerConfig returns the Docker container configuration for a Quick Tunnel
@@ -518,6 +617,239 @@ func (p *Provider) ListTunnels(ctx context.Context, nodeIDs []string) ([]*tunnel
 	return genericTunnels, nil
 }
 
+// ============================================================================
+// HealthCheckProvider Interface
+// ============================================================================
+
+// CheckHealth verifies the Cloudflare API accepts the configured token and account.
+func (p *Provider) CheckHealth(ctx context.Context) error {
+	return p.manager.ApiManager.VerifyCredentials(ctx)
+}
+
+// ============================================================================
+// AccountProvider Interface
+// ============================================================================
+
+// ListAccountTunnels returns every tunnel in the Cloudflare account, with the
+// connector status Cloudflare reports (healthy, degraded, down or inactive).
+func (p *Provider) ListAccountTunnels(ctx context.Context) ([]*tunnel.Tunnel, error) {
+	return ListAccountTunnels(ctx, p.manager)
+}
+
+// DeleteAccountTunnel deletes a tunnel and its DNS records from the Cloudflare account,
+// then drops this node's record of it if there is one.
+func (p *Provider) DeleteAccountTunnel(ctx context.Context, tunnelID string) error {
+	return DeleteAccountTunnel(ctx, p.manager, p.database, p.logger, tunnelID)
+}
+
+// ImportTunnel records an existing Cloudflare tunnel as the app's tunnel, along with its connector
+// token and remotely managed ingress rules. The public URL is the first ingress hostname.
+func (p *Provider) ImportTunnel(ctx context.Context, tunnelID, appID string) (*tunnel.Tunnel, error) {
+	p.logger.InfoContext(ctx, "importing cloudflare tunnel", "tunnel_id", tunnelID, "app_id", appID)
+
+	cfTunnel, err := p.manager.ImportTunnel(ctx, tunnelID, appID)
+	if err != nil {
+		if errors.Is(err, cloudflare.ErrNotFound) {
+			return nil, tunnel.ErrTunnelNotFound
+		}
+		return nil, err
+	}
+
+	p.logger.InfoContext(ctx, "cloudflare tunnel imported", "tunnel_id", tunnelID, "app_id", appID, "public_url", cfTunnel.PublicURL)
+	return p.toGenericTunnel(cfTunnel, cfTunnel.PublicURL), nil
+}
+
+// ============================================================================
+// ConnectorProvider Interface
+// ============================================================================
+
+// ListConnectors returns the cloudflared instances connected for an app's tunnel.
+func (p *Provider) ListConnectors(ctx context.Context, appID string) ([]*tunnel.Connector, error) {
+	cfTunnel, err := p.database.GetCloudflareTunnelByAppID(appID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, tunnel.ErrTunnelNotFound
+		}
+		return nil, fmt.Errorf("failed to get tunnel: %w", err)
+	}
+	return ListConnectors(ctx, p.manager, cfTunnel.TunnelID)
+}
+
+// ============================================================================
+// TrafficProvider Interface
+// ============================================================================
+
+// GetTraffic returns the requests, bandwidth and visits Cloudflare's edge served for the
+// hostnames in an app's ingress rules. Wildcard hostnames and hostnames in zones the API token
+// can't see are reported as untracked.
+func (p *Provider) GetTraffic(ctx context.Context, appID string, since, until time.Time) (*tunnel.Traffic, error) {
+	cfTunnel, err := p.database.GetCloudflareTunnelByAppID(appID)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, tunnel.ErrTunnelNotFound
+		}
+		return nil, fmt.Errorf("failed to get tunnel: %w", err)
+	}
+	return GetTraffic(ctx, p.manager, cfTunnel, since, until)
+}
+
+// ============================================================================
+// ZoneProvider Interface
+// ============================================================================
+
+// ListZones returns the zones the API token can see.
+func (p *Provider) ListZones(ctx context.Context) ([]*tunnel.Zone, error) {
+	return ListZones(ctx, p.manager)
+}
+
+// ListHostnames returns the DNS records in a zone, marking CNAMEs that route to a tunnel.
+func (p *Provider) ListHostnames(ctx context.Context, zoneID string) ([]*tunnel.Hostname, error) {
+	return ListHostnames(ctx, p.manager, zoneID)
+}
+
+// ============================================================================
+// Shared Helpers
+// ============================================================================
+// These work on a cloudflare.TunnelManager alone, so Provider and the tunnel service's adapter
+// for its legacy TunnelManager share one implementation.
+
+// ListConnectors returns the cloudflared instances connected to a tunnel.
+func ListConnectors(ctx context.Context, manager *cloudflare.TunnelManager, tunnelID string) ([]*tunnel.Connector, error) {
+	cfConnectors, err := manager.ApiManager.ListConnections(ctx, tunnelID)
+	if err != nil {
+		return nil, err
+	}
+
+	connectors := make([]*tunnel.Connector, 0, len(cfConnectors))
+	for _, c := range cfConnectors {
+		connector := &tunnel.Connector{ID: c.ID, Version: c.Version, Connections: []*tunnel.Connection{}}
+		if !c.RunAt.IsZero() {
+			runAt := c.RunAt
+			connector.StartedAt = &runAt
+		}
+		for _, conn := range c.Connections {
+			connector.Connections = append(connector.Connections, &tunnel.Connection{
+				ID:               conn.ID,
+				Location:         conn.ColoName,
+				OriginIP:         conn.OriginIP,
+				OpenedAt:         conn.OpenedAt,
+				PendingReconnect: conn.IsPendingReconnect,
+			})
+		}
+		connectors = append(connectors, connector)
+	}
+	return connectors, nil
+}
+
+// GetTraffic returns the traffic Cloudflare's edge served for the hostnames in a tunnel's ingress rules.
+func GetTraffic(ctx context.Context, manager *cloudflare.TunnelManager, cfTunnel *db.CloudflareTunnel, since, until time.Time) (*tunnel.Traffic, error) {
+	var rules []db.IngressRule
+	if cfTunnel.IngressRules != nil {
+		rules = *cfTunnel.IngressRules
+	}
+	cfTraffic, err := manager.ApiManager.GetIngressTraffic(ctx, rules, since, until)
+	if err != nil {
+		return nil, err
+	}
+
+	traffic := &tunnel.Traffic{
+		Since:              since,
+		Until:              until,
+		Hostnames:          make([]*tunnel.HostnameTraffic, 0, len(cfTraffic.Hosts)),
+		Countries:          make([]*tunnel.TrafficCount, 0, len(cfTraffic.Countries)),
+		StatusCodes:        make([]*tunnel.TrafficCount, 0, len(cfTraffic.StatusCodes)),
+		UntrackedHostnames: cfTraffic.Untracked,
+	}
+	for _, host := range cfTraffic.Hosts {
+		traffic.Hostnames = append(traffic.Hostnames, &tunnel.HostnameTraffic{Hostname: host.Hostname, Requests: host.Requests, Bytes: host.Bytes, Visits: host.Visits})
+		traffic.Requests += host.Requests
+		traffic.Bytes += host.Bytes
+		traffic.Visits += host.Visits
+	}
+	for _, group := range cfTraffic.Countries {
+		traffic.Countries = append(traffic.Countries, &tunnel.TrafficCount{Key: group.Key, Requests: group.Requests})
+	}
+	for _, group := range cfTraffic.StatusCodes {
+		traffic.StatusCodes = append(traffic.StatusCodes, &tunnel.TrafficCount{Key: group.Key, Requests: group.Requests})
+	}
+	return traffic, nil
+}
+
+// ListAccountTunnels returns every tunnel in the manager's Cloudflare account.
+func ListAccountTunnels(ctx context.Context, manager *cloudflare.TunnelManager) ([]*tunnel.Tunnel, error) {
+	cfTunnels, err := manager.ApiManager.ListAccountTunnels(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list account tunnels: %w", err)
+	}
+
+	tunnels := make([]*tunnel.Tunnel, 0, len(cfTunnels))
+	for _, cfTunnel := range cfTunnels {
+		tunnels = append(tunnels, &tunnel.Tunnel{
+			ProviderType: constants.ProviderCloudflare,
+			TunnelID:     cfTunnel.ID,
+			TunnelName:   cfTunnel.Name,
+			Status:       cfTunnel.Status,
+			CreatedAt:    cfTunnel.CreatedAt,
+		})
+	}
+	return tunnels, nil
+}
+
+// DeleteAccountTunnel deletes a tunnel and its DNS records from the manager's Cloudflare account,
+// then drops this node's record of it if there is one.
+func DeleteAccountTunnel(ctx context.Context, manager *cloudflare.TunnelManager, database *db.DB, logger *slog.Logger, tunnelID string) error {
+	logger.InfoContext(ctx, "deleting cloudflare account tunnel", "tunnel_id", tunnelID)
+
+	if err := manager.ApiManager.DeleteTunnel(tunnelID); err != nil {
+		return fmt.Errorf("failed to delete tunnel: %w", err)
+	}
+
+	cfTunnel, err := database.GetCloudflareTunnelByTunnelID(tunnelID)
+	if err != nil {
+		return nil
+	}
+	if err := database.DeleteCloudflareTunnel(cfTunnel.AppID); err != nil {
+		logger.WarnContext(ctx, "failed to delete tunnel record", "tunnel_id", tunnelID, "error", err)
+	}
+	return nil
+}
+
+// ListZones returns the zones the manager's API token can see.
+func ListZones(ctx context.Context, manager *cloudflare.TunnelManager) ([]*tunnel.Zone, error) {
+	cfZones, err := manager.ApiManager.ListZones(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	zones := make([]*tunnel.Zone, 0, len(cfZones))
+	for _, z := range cfZones {
+		zones = append(zones, &tunnel.Zone{ID: z.ID, Name: z.Name, Status: z.Status})
+	}
+	return zones, nil
+}
+
+// ListHostnames returns the DNS records in a zone, marking CNAMEs that route to a tunnel.
+func ListHostnames(ctx context.Context, manager *cloudflare.TunnelManager, zoneID string) ([]*tunnel.Hostname, error) {
+	records, err := manager.ApiManager.ListDNSRecords(ctx, zoneID)
+	if err != nil {
+		if errors.Is(err, cloudflare.ErrNotFound) {
+			return nil, tunnel.ErrZoneNotFound
+		}
+		return nil, err
+	}
+
+	hostnames := make([]*tunnel.Hostname, 0, len(records))
+	for _, r := range records {
+		hostnames = append(hostnames, &tunnel.Hostname{
+			Name:     r.Name,
+			Type:     r.Type,
+			Target:   r.Content,
+			TunnelID: cloudflare.TunnelIDFromTarget(r.Content),
+		})
+	}
+	return hostnames, nil
+}
+
 // ============================================================================
 // Helper Methods
 // ============================================================================
@@ -554,3 +886,4 @@ func (p *Provider) toGenericTunnel(cfTunnel *db.CloudflareTunnel, publicURL stri
 		UpdatedAt:    cfTunnel.UpdatedAt,
 	}
 }
+
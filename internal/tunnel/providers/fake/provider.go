@@ -0,0 +1,139 @@
+// Package fake is a tunnel provider for chaos mode. It calls no external API: tunnels are derived
+// from the app they belong to, and every operation goes through the chaos injector's latency and
+// failures, named "tunnel_<operation>" for CHAOS_FAIL_OPERATIONS.
+package fake
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/selfhostly/internal/chaos"
+	"github.com/selfhostly/internal/constants"
+	"github.com/selfhostly/internal/db"
+	"github.com/selfhostly/internal/tunnel"
+)
+
+// tunnelIDPrefix marks the tunnel IDs this provider hands out
+const tunnelIDPrefix = "fake-"
+
+// Provider is the fake tunnel provider. It supports ingress, DNS, containers and connectors so
+// the flows that depend on them can be exercised.
+type Provider struct {
+	database *db.DB
+	injector *chaos.Injector
+	logger   *slog.Logger
+}
+
+// NewProvider creates a fake provider. This is the factory function registered with the tunnel
+// registry in chaos mode; config carries the injected "database", "chaos" and "logger".
+func NewProvider(config map[string]interface{}) (tunnel.Provider, error) {
+	database, ok := config["database"].(*db.DB)
+	if !ok || database == nil {
+		return nil, fmt.Errorf("%w: database is required", tunnel.ErrInvalidConfiguration)
+	}
+	injector, ok := config["chaos"].(*chaos.Injector)
+	if !ok || injector == nil {
+		return nil, fmt.Errorf("%w: chaos injector is required", tunnel.ErrInvalidConfiguration)
+	}
+	logger, ok := config["logger"].(*slog.Logger)
+	if !ok {
+		logger = slog.Default()
+	}
+	return &Provider{database: database, injector: injector, logger: logger}, nil
+}
+
+// CreateTunnel pretends to create a tunnel, reachable at a placeholder URL
+func (p *Provider) CreateTunnel(ctx context.Context, opts tunnel.CreateOptions) (*tunnel.Tunnel, error) {
+	if err := p.injector.Do(ctx, "tunnel_create"); err != nil {
+		return nil, fmt.Errorf("failed to create fake tunnel: %w", err)
+	}
+	p.logger.InfoContext(ctx, "fake tunnel created", "app_id", opts.AppID, "name", opts.Name)
+	return p.toTunnel(opts.AppID, opts.Name), nil
+}
+
+// GetTunnelByAppID returns the tunnel of an app whose tunnel this provider created
+func (p *Provider) GetTunnelByAppID(ctx context.Context, appID string) (*tunnel.Tunnel, error) {
+	if err := p.injector.Do(ctx, "tunnel_get"); err != nil {
+		return nil, err
+	}
+	app, err := p.database.GetApp(appID)
+	if err != nil || !strings.HasPrefix(app.TunnelID, tunnelIDPrefix) {
+		return nil, tunnel.ErrTunnelNotFound
+	}
+	return p.toTunnel(app.ID, app.Name), nil
+}
+
+// DeleteTunnel pretends to delete an app's tunnel
+func (p *Provider) DeleteTunnel(ctx context.Context, appID string) error {
+	if err := p.injector.Do(ctx, "tunnel_delete"); err != nil {
+		return fmt.Errorf("failed to delete fake tunnel: %w", err)
+	}
+	p.logger.InfoContext(ctx, "fake tunnel deleted", "app_id", appID)
+	return nil
+}
+
+// CleanupOrphanedTunnels has nothing to clean up: fake tunnels only exist through their apps
+func (p *Provider) CleanupOrphanedTunnels(ctx context.Context) error {
+	return p.injector.Do(ctx, "tunnel_cleanup")
+}
+
+// Name returns the provider's identifier
+func (p *Provider) Name() string {
+	return constants.ProviderFake
+}
+
+// DisplayName returns the provider's human-readable name
+func (p *Provider) DisplayName() string {
+	return "Fake Tunnel (chaos mode)"
+}
+
+// UpdateIngress accepts any ingress rules
+func (p *Provider) UpdateIngress(ctx context.Context, appID string, rules interface{}) error {
+	return p.injector.Do(ctx, "tunnel_ingress")
+}
+
+// CreateDNSRecord pretends to point a hostname at the tunnel
+func (p *Provider) CreateDNSRecord(ctx context.Context, appID string, opts tunnel.DNSOptions) error {
+	return p.injector.Do(ctx, "tunnel_dns")
+}
+
+// GetContainerConfig returns an idle sidecar, which the stub docker executor never runs anyway
+func (p *Provider) GetContainerConfig(tunnelToken string, appName string) *tunnel.ContainerConfig {
+	return &tunnel.ContainerConfig{
+		Image:       "busybox:latest",
+		Command:     []string{"sleep", "infinity"},
+		Environment: map[string]string{"TUNNEL_TOKEN": tunnelToken},
+	}
+}
+
+// ListConnectors reports one connector for every fake tunnel, as if its sidecar were connected
+func (p *Provider) ListConnectors(ctx context.Context, appID string) ([]*tunnel.Connector, error) {
+	if _, err := p.GetTunnelByAppID(ctx, appID); err != nil {
+		return nil, err
+	}
+	started := time.Now().UTC()
+	return []*tunnel.Connector{{
+		ID:          tunnelIDPrefix + "connector-" + appID,
+		Version:     "chaos",
+		StartedAt:   &started,
+		Connections: []*tunnel.Connection{{ID: tunnelIDPrefix + "connection-" + appID, Location: "local", OpenedAt: started}},
+	}}, nil
+}
+
+// toTunnel builds the tunnel an app gets from this provider
+func (p *Provider) toTunnel(appID, name string) *tunnel.Tunnel {
+	return &tunnel.Tunnel{
+		ID:           tunnelIDPrefix + appID,
+		AppID:        appID,
+		ProviderType: constants.ProviderFake,
+		TunnelID:     tunnelIDPrefix + appID,
+		TunnelName:   name,
+		TunnelToken:  tunnelIDPrefix + "token-" + appID,
+		PublicURL:    fmt.Sprintf("https://%s.chaos.invalid", strings.ToLower(name)),
+		Status:       "active",
+		IsActive:     true,
+	}
+}
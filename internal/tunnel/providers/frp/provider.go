@@ -0,0 +1,353 @@
+// Package frp is a tunnel provider for users who run their own VPS. Apps are served by the frp
+// server (frps) there: each app's frpc sidecar connects out to it and registers an HTTP proxy per
+// ingress rule, so nothing has to be opened on the network the apps run in. The rules each
+// sidecar is started with are kept in the frp_tunnels table.
+package frp
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/selfhostly/internal/constants"
+	"github.com/selfhostly/internal/db"
+	"github.com/selfhostly/internal/tunnel"
+)
+
+// agentImage is the frp client the sidecar runs; TOML configs need frp 0.52 or later
+const agentImage = "fatedier/frpc:v0.61.0"
+
+// defaultServerPort is frps's default bindPort
+const defaultServerPort = 7000
+
+// configEnv passes the client config to the sidecar, whose entrypoint writes it to the file frpc
+// reads; frpc can't take its config from the environment itself
+const configEnv = "FRPC_CONFIG"
+
+// orphanGracePeriod keeps CleanupOrphanedTunnels away from tunnels whose app is still being
+// created; tunnels are recorded just before their app
+const orphanGracePeriod = 10 * time.Minute
+
+// Provider is the frp tunnel provider. Ingress rules become the sidecar's proxies, so changing
+// them regenerates the sidecar instead of calling an API; there are no DNS records to manage.
+type Provider struct {
+	serverAddr string
+	serverPort int
+	authToken  string
+	domain     string
+	database   *db.DB
+	logger     *slog.Logger
+}
+
+// NewProvider creates an frp provider. This is the factory function registered with the tunnel
+// registry; config carries "server_addr", an optional "server_port", "auth_token" and "domain",
+// and the injected "database" and "logger".
+//
+// auth_token is the server's auth.token. domain is the server's subDomainHost: rules without a
+// hostname are served at <app>.<domain>, and without one every rule needs a hostname.
+func NewProvider(config map[string]interface{}) (tunnel.Provider, error) {
+	serverAddr, _ := config["server_addr"].(string)
+	serverAddr = strings.TrimSpace(serverAddr)
+	if serverAddr == "" {
+		return nil, fmt.Errorf("%w: server_addr is required", tunnel.ErrInvalidConfiguration)
+	}
+	serverPort, err := configPort(config["server_port"])
+	if err != nil {
+		return nil, err
+	}
+	authToken, _ := config["auth_token"].(string)
+	domain, _ := config["domain"].(string)
+	database, ok := config["database"].(*db.DB)
+	if !ok || database == nil {
+		return nil, fmt.Errorf("%w: database is required", tunnel.ErrInvalidConfiguration)
+	}
+	logger, ok := config["logger"].(*slog.Logger)
+	if !ok {
+		logger = slog.Default()
+	}
+	return &Provider{
+		serverAddr: serverAddr,
+		serverPort: serverPort,
+		authToken:  authToken,
+		domain:     strings.Trim(strings.ToLower(strings.TrimSpace(domain)), "."),
+		database:   database,
+		logger:     logger,
+	}, nil
+}
+
+// configPort reads server_port, which settings may hold as a string or a number
+func configPort(value interface{}) (int, error) {
+	var raw string
+	switch v := value.(type) {
+	case nil:
+		return defaultServerPort, nil
+	case string:
+		raw = strings.TrimSpace(v)
+	case float64:
+		raw = strconv.FormatFloat(v, 'f', -1, 64)
+	case int:
+		raw = strconv.Itoa(v)
+	}
+	if raw == "" {
+		return defaultServerPort, nil
+	}
+	port, err := strconv.Atoi(raw)
+	if err != nil || port < 1 || port > 65535 {
+		return 0, fmt.Errorf("%w: server_port must be a port number, got %v", tunnel.ErrInvalidConfiguration, value)
+	}
+	return port, nil
+}
+
+// CreateTunnel records the proxies an app's sidecar will register. opts.AdditionalConfig gives
+// the app's "ingress_rules" or, without any, the "upstream" to forward to (e.g. http://web:80)
+// and the "hostname" to serve it at.
+func (p *Provider) CreateTunnel(ctx context.Context, opts tunnel.CreateOptions) (*tunnel.Tunnel, error) {
+	rules, _ := opts.AdditionalConfig["ingress_rules"].([]db.IngressRule)
+	if len(rules) == 0 {
+		if upstream, _ := opts.AdditionalConfig["upstream"].(string); upstream != "" {
+			rule := db.IngressRule{Service: upstream}
+			if hostname, _ := opts.AdditionalConfig["hostname"].(string); hostname != "" {
+				rule.Hostname = &hostname
+			}
+			rules = []db.IngressRule{rule}
+		}
+	}
+	if len(rules) == 0 {
+		return nil, fmt.Errorf("%w: frp needs a service to forward to; add an ingress rule such as http://web:80", tunnel.ErrInvalidConfiguration)
+	}
+	record := db.NewFrpTunnel(opts.AppID, opts.Name, rules, "")
+	proxies, err := p.proxies(record.TunnelName, record.ID, rules)
+	if err != nil {
+		return nil, err
+	}
+	record.PublicURL = p.publicURL(proxies)
+	if err := p.database.CreateFrpTunnel(record); err != nil {
+		return nil, fmt.Errorf("failed to save frp tunnel: %w", err)
+	}
+
+	p.logger.InfoContext(ctx, "frp tunnel created", "app_id", opts.AppID, "proxies", len(proxies), "public_url", record.PublicURL)
+	return p.toTunnel(record), nil
+}
+
+// GetTunnelByAppID returns an app's frp tunnel
+func (p *Provider) GetTunnelByAppID(ctx context.Context, appID string) (*tunnel.Tunnel, error) {
+	record, err := p.database.GetFrpTunnelByAppID(appID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, tunnel.ErrTunnelNotFound
+		}
+		return nil, fmt.Errorf("failed to get frp tunnel: %w", err)
+	}
+	return p.toTunnel(record), nil
+}
+
+// UpdateIngress replaces the rules of an app's tunnel. They take effect when the sidecar is
+// regenerated, which callers do for providers that implement tunnel.SidecarIngressProvider.
+func (p *Provider) UpdateIngress(ctx context.Context, appID string, rules interface{}) error {
+	ingressRules, ok := rules.([]db.IngressRule)
+	if !ok {
+		return fmt.Errorf("%w: expected ingress rules, got %T", tunnel.ErrInvalidConfiguration, rules)
+	}
+	record, err := p.database.GetFrpTunnelByAppID(appID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return tunnel.ErrTunnelNotFound
+		}
+		return fmt.Errorf("failed to get frp tunnel: %w", err)
+	}
+	proxies, err := p.proxies(record.TunnelName, record.ID, ingressRules)
+	if err != nil {
+		return err
+	}
+	if err := p.database.UpdateFrpTunnelIngress(appID, ingressRules, p.publicURL(proxies)); err != nil {
+		return fmt.Errorf("failed to save frp tunnel rules: %w", err)
+	}
+
+	p.logger.InfoContext(ctx, "frp tunnel rules updated", "app_id", appID, "proxies", len(proxies))
+	return nil
+}
+
+// IngressInSidecar reports that rules are part of the sidecar's config
+func (p *Provider) IngressInSidecar() bool {
+	return true
+}
+
+// DeleteTunnel forgets an app's tunnel; its proxies went away with the sidecar
+func (p *Provider) DeleteTunnel(ctx context.Context, appID string) error {
+	if err := p.database.DeleteFrpTunnel(appID); err != nil {
+		return fmt.Errorf("failed to delete frp tunnel: %w", err)
+	}
+	p.logger.InfoContext(ctx, "frp tunnel deleted", "app_id", appID)
+	return nil
+}
+
+// CleanupOrphanedTunnels forgets the tunnels of apps that no longer exist. The server drops a
+// client's proxies when it disconnects, so there is nothing to remove there.
+func (p *Provider) CleanupOrphanedTunnels(ctx context.Context) error {
+	orphans, err := p.database.GetFrpTunnelsWithoutApp(time.Now().Add(-orphanGracePeriod))
+	if err != nil {
+		return fmt.Errorf("failed to list frp tunnels: %w", err)
+	}
+	for _, record := range orphans {
+		if err := p.database.DeleteFrpTunnel(record.AppID); err != nil {
+			return fmt.Errorf("failed to delete frp tunnel: %w", err)
+		}
+		p.logger.InfoContext(ctx, "removed orphaned frp tunnel", "app_id", record.AppID, "tunnel_name", record.TunnelName)
+	}
+	return nil
+}
+
+// Name returns the provider's identifier
+func (p *Provider) Name() string {
+	return constants.ProviderFrp
+}
+
+// DisplayName returns the provider's human-readable name
+func (p *Provider) DisplayName() string {
+	return "frp"
+}
+
+// GetContainerConfig returns the frpc sidecar for a tunnel; tunnelToken is the tunnel's ID, as
+// CreateTunnel hands it out. Returns nil if the tunnel is unknown.
+func (p *Provider) GetContainerConfig(tunnelToken string, appName string) *tunnel.ContainerConfig {
+	record, err := p.database.GetFrpTunnel(tunnelToken)
+	if err != nil {
+		p.logger.Warn("no frp tunnel to run a client for", "app", appName, "error", err)
+		return nil
+	}
+	proxies, err := p.proxies(record.TunnelName, record.ID, record.IngressRules)
+	if err != nil {
+		p.logger.Warn("frp tunnel has invalid rules", "app", appName, "error", err)
+		return nil
+	}
+
+	// Compose interpolates the file, so $ is doubled wherever it must reach the container
+	return &tunnel.ContainerConfig{
+		Image:       agentImage,
+		Entrypoint:  []string{"/bin/sh", "-c", `printf '%s' "$$` + configEnv + `" > /tmp/frpc.toml && exec frpc -c /tmp/frpc.toml`},
+		Environment: map[string]string{configEnv: strings.ReplaceAll(p.clientConfig(proxies), "$", "$$")},
+	}
+}
+
+// proxy is one HTTP proxy the sidecar registers on the server
+type proxy struct {
+	name         string
+	localIP      string
+	localPort    int
+	customDomain string
+	subdomain    string
+	location     string
+}
+
+// proxies maps ingress rules to the sidecar's proxies. A rule is served at its hostname, or at
+// <app>.<domain> without one, and its path becomes a location prefix. Catch-all rules such as
+// http_status:404 are left out, since the server answers unknown hosts itself. Proxy names must
+// be unique on the server, so they carry the tunnel's ID.
+func (p *Provider) proxies(tunnelName, tunnelID string, rules []db.IngressRule) ([]proxy, error) {
+	var proxies []proxy
+	seen := make(map[string]bool)
+	for i, rule := range rules {
+		if strings.HasPrefix(rule.Service, "http_status:") {
+			continue
+		}
+		u, err := url.Parse(rule.Service)
+		if err != nil || u.Scheme != "http" || u.Hostname() == "" {
+			return nil, fmt.Errorf("%w: rule %d: frp forwards to http:// services, got %q", tunnel.ErrInvalidConfiguration, i+1, rule.Service)
+		}
+		port := 80
+		if u.Port() != "" {
+			if port, err = strconv.Atoi(u.Port()); err != nil {
+				return nil, fmt.Errorf("%w: rule %d: invalid port in %q", tunnel.ErrInvalidConfiguration, i+1, rule.Service)
+			}
+		}
+
+		px := proxy{
+			name:      fmt.Sprintf("%s-%s-%d", strings.ToLower(tunnelName), strings.SplitN(tunnelID, "-", 2)[0], i+1),
+			localIP:   u.Hostname(),
+			localPort: port,
+		}
+		switch {
+		case rule.Hostname != nil && *rule.Hostname != "":
+			px.customDomain = strings.ToLower(*rule.Hostname)
+		case p.domain != "":
+			px.subdomain = strings.ToLower(tunnelName)
+		default:
+			return nil, fmt.Errorf("%w: rule %d needs a hostname, or set the server's domain to serve apps at <app>.<domain>", tunnel.ErrInvalidConfiguration, i+1)
+		}
+		if rule.Path != nil && *rule.Path != "" {
+			px.location = "/" + strings.TrimPrefix(*rule.Path, "/")
+		}
+
+		key := px.host(p.domain) + px.location
+		if seen[key] {
+			return nil, fmt.Errorf("%w: rule %d serves %s like an earlier rule", tunnel.ErrInvalidConfiguration, i+1, key)
+		}
+		seen[key] = true
+		proxies = append(proxies, px)
+	}
+	if len(proxies) == 0 {
+		return nil, fmt.Errorf("%w: frp needs a service to forward to; add an ingress rule such as http://web:80", tunnel.ErrInvalidConfiguration)
+	}
+	return proxies, nil
+}
+
+// host returns the hostname a proxy is served at
+func (px proxy) host(domain string) string {
+	if px.customDomain != "" {
+		return px.customDomain
+	}
+	return px.subdomain + "." + domain
+}
+
+// publicURL is the app's URL: the first proxy's host. The server, or a proxy in front of it,
+// is expected to terminate TLS.
+func (p *Provider) publicURL(proxies []proxy) string {
+	return "https://" + proxies[0].host(p.domain)
+}
+
+// clientConfig renders frpc's TOML config. The client keeps retrying while the server is
+// unreachable instead of exiting, as the other sidecars do.
+func (p *Provider) clientConfig(proxies []proxy) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "serverAddr = %q\nserverPort = %d\nloginFailExit = false\n", p.serverAddr, p.serverPort)
+	if p.authToken != "" {
+		fmt.Fprintf(&b, "auth.token = %q\n", p.authToken)
+	}
+	for _, px := range proxies {
+		fmt.Fprintf(&b, "\n[[proxies]]\nname = %q\ntype = \"http\"\nlocalIP = %q\nlocalPort = %d\n", px.name, px.localIP, px.localPort)
+		if px.customDomain != "" {
+			fmt.Fprintf(&b, "customDomains = [%q]\n", px.customDomain)
+		} else {
+			fmt.Fprintf(&b, "subdomain = %q\n", px.subdomain)
+		}
+		if px.location != "" {
+			fmt.Fprintf(&b, "locations = [%q]\n", px.location)
+		}
+	}
+	return b.String()
+}
+
+// toTunnel converts a stored frp tunnel to the generic form
+func (p *Provider) toTunnel(record *db.FrpTunnel) *tunnel.Tunnel {
+	return &tunnel.Tunnel{
+		ID:           record.ID,
+		AppID:        record.AppID,
+		ProviderType: constants.ProviderFrp,
+		TunnelID:     record.ID,
+		TunnelName:   record.TunnelName,
+		TunnelToken:  record.ID,
+		PublicURL:    record.PublicURL,
+		Status:       record.Status,
+		IsActive:     record.Status == constants.TunnelStatusActive,
+		IngressRules: record.IngressRules,
+		Metadata:     map[string]interface{}{"server_addr": p.serverAddr, "ingress_rules": record.IngressRules},
+		CreatedAt:    record.CreatedAt,
+		UpdatedAt:    record.UpdatedAt,
+	}
+}
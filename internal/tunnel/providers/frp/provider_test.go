@@ -0,0 +1,150 @@
+package frp
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/selfhostly/internal/db"
+	"github.com/selfhostly/internal/tunnel"
+)
+
+func setupTestProvider(t *testing.T, domain string) (*Provider, *db.DB) {
+	t.Helper()
+	database, err := db.Init(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to initialize database: %v", err)
+	}
+	t.Cleanup(func() { database.Close() })
+
+	config := map[string]interface{}{"server_addr": "vps.example.com", "server_port": float64(7001), "auth_token": "frp$ecret", "domain": domain, "database": database}
+	provider, err := NewProvider(config)
+	if err != nil {
+		t.Fatalf("NewProvider: %v", err)
+	}
+	return provider.(*Provider), database
+}
+
+func strPtr(s string) *string { return &s }
+
+func TestNewProvider_Config(t *testing.T) {
+	if _, err := NewProvider(map[string]interface{}{"database": &db.DB{}}); !errors.Is(err, tunnel.ErrInvalidConfiguration) {
+		t.Errorf("expected invalid configuration without server_addr, got %v", err)
+	}
+	if _, err := NewProvider(map[string]interface{}{"server_addr": "vps", "server_port": "http", "database": &db.DB{}}); !errors.Is(err, tunnel.ErrInvalidConfiguration) {
+		t.Errorf("expected invalid configuration with a bad port, got %v", err)
+	}
+	provider, err := NewProvider(map[string]interface{}{"server_addr": "vps", "server_port": "", "database": &db.DB{}})
+	if err != nil || provider.(*Provider).serverPort != defaultServerPort {
+		t.Errorf("expected the default port, got %+v, %v", provider, err)
+	}
+}
+
+func TestProvider_CreateTunnel(t *testing.T) {
+	p, _ := setupTestProvider(t, "Apps.Example.com.")
+	ctx := context.Background()
+
+	if _, err := p.CreateTunnel(ctx, tunnel.CreateOptions{AppID: "app-0", Name: "blog"}); !errors.Is(err, tunnel.ErrInvalidConfiguration) {
+		t.Errorf("expected a tunnel without rules or upstream to be refused, got %v", err)
+	}
+	tcpRules := []db.IngressRule{{Service: "tcp://db:5432"}}
+	if _, err := p.CreateTunnel(ctx, tunnel.CreateOptions{AppID: "app-0", Name: "db", AdditionalConfig: map[string]interface{}{"ingress_rules": tcpRules}}); !errors.Is(err, tunnel.ErrInvalidConfiguration) {
+		t.Errorf("expected a non-HTTP service to be refused, got %v", err)
+	}
+
+	// Without rules, the upstream is served at <app>.<domain>
+	created, err := p.CreateTunnel(ctx, tunnel.CreateOptions{AppID: "app-1", Name: "Blog", AdditionalConfig: map[string]interface{}{"upstream": "http://web:80"}})
+	if err != nil {
+		t.Fatalf("CreateTunnel: %v", err)
+	}
+	if created.PublicURL != "https://blog.apps.example.com" || created.TunnelToken != created.ID || !created.IsActive {
+		t.Errorf("unexpected tunnel %+v", created)
+	}
+
+	// The first rule's hostname is the public URL
+	rules := []db.IngressRule{
+		{Hostname: strPtr("Wiki.example.org"), Service: "http://wiki:3000"},
+		{Hostname: strPtr("wiki.example.org"), Service: "http://api:8080", Path: strPtr("api")},
+		{Service: "http_status:404"},
+	}
+	withRules, err := p.CreateTunnel(ctx, tunnel.CreateOptions{AppID: "app-2", Name: "wiki", AdditionalConfig: map[string]interface{}{"ingress_rules": rules, "upstream": "http://wiki:3000"}})
+	if err != nil {
+		t.Fatalf("CreateTunnel: %v", err)
+	}
+	if withRules.PublicURL != "https://wiki.example.org" {
+		t.Errorf("expected the first hostname as URL, got %q", withRules.PublicURL)
+	}
+
+	if err := p.DeleteTunnel(ctx, "app-1"); err != nil {
+		t.Fatalf("DeleteTunnel: %v", err)
+	}
+	if _, err := p.GetTunnelByAppID(ctx, "app-1"); !errors.Is(err, tunnel.ErrTunnelNotFound) {
+		t.Errorf("expected tunnel not found after delete, got %v", err)
+	}
+}
+
+func TestProvider_ProxiesNeedHostnameWithoutDomain(t *testing.T) {
+	p, _ := setupTestProvider(t, "")
+	_, err := p.CreateTunnel(context.Background(), tunnel.CreateOptions{AppID: "app-1", Name: "blog", AdditionalConfig: map[string]interface{}{"upstream": "http://web:80"}})
+	if !errors.Is(err, tunnel.ErrInvalidConfiguration) {
+		t.Errorf("expected a rule without hostname to be refused without a domain, got %v", err)
+	}
+
+	duplicate := []db.IngressRule{
+		{Hostname: strPtr("blog.example.com"), Service: "http://web:80"},
+		{Hostname: strPtr("blog.example.com"), Service: "http://other:80"},
+	}
+	_, err = p.CreateTunnel(context.Background(), tunnel.CreateOptions{AppID: "app-1", Name: "blog", AdditionalConfig: map[string]interface{}{"ingress_rules": duplicate}})
+	if !errors.Is(err, tunnel.ErrInvalidConfiguration) {
+		t.Errorf("expected two rules for the same host to be refused, got %v", err)
+	}
+}
+
+func TestProvider_GetContainerConfig(t *testing.T) {
+	p, _ := setupTestProvider(t, "apps.example.com")
+	ctx := context.Background()
+
+	rules := []db.IngressRule{{Hostname: strPtr("blog.example.com"), Service: "http://web:80", Path: strPtr("/admin")}}
+	created, err := p.CreateTunnel(ctx, tunnel.CreateOptions{AppID: "app-1", Name: "blog", AdditionalConfig: map[string]interface{}{"ingress_rules": rules}})
+	if err != nil {
+		t.Fatalf("CreateTunnel: %v", err)
+	}
+
+	config := p.GetContainerConfig(created.TunnelToken, "blog")
+	if config == nil {
+		t.Fatal("expected a container config")
+	}
+	if config.Image != agentImage || len(config.Entrypoint) != 3 || !strings.Contains(config.Entrypoint[2], "$$"+configEnv) {
+		t.Errorf("unexpected container config %+v", config)
+	}
+	clientConfig := config.Environment[configEnv]
+	for _, want := range []string{
+		`serverAddr = "vps.example.com"`, `serverPort = 7001`, `auth.token = "frp$$ecret"`,
+		`localIP = "web"`, `localPort = 80`, `customDomains = ["blog.example.com"]`, `locations = ["/admin"]`,
+	} {
+		if !strings.Contains(clientConfig, want) {
+			t.Errorf("expected client config to contain %s, got\n%s", want, clientConfig)
+		}
+	}
+
+	// New rules change the config the sidecar is regenerated with
+	if err := p.UpdateIngress(ctx, "app-1", []db.IngressRule{{Service: "http://web:8080"}}); err != nil {
+		t.Fatalf("UpdateIngress: %v", err)
+	}
+	clientConfig = p.GetContainerConfig(created.TunnelToken, "blog").Environment[configEnv]
+	if !strings.Contains(clientConfig, `subdomain = "blog"`) || !strings.Contains(clientConfig, `localPort = 8080`) || strings.Contains(clientConfig, "customDomains") {
+		t.Errorf("expected the new rule in the client config, got\n%s", clientConfig)
+	}
+	if got, _ := p.GetTunnelByAppID(ctx, "app-1"); got.PublicURL != "https://blog.apps.example.com" {
+		t.Errorf("expected the public URL to follow the rules, got %q", got.PublicURL)
+	}
+	if err := p.UpdateIngress(ctx, "app-2", []db.IngressRule{{Service: "http://web:80"}}); !errors.Is(err, tunnel.ErrTunnelNotFound) {
+		t.Errorf("expected tunnel not found for an unknown app, got %v", err)
+	}
+
+	if config := p.GetContainerConfig("unknown", "blog"); config != nil {
+		t.Errorf("expected no container for an unknown tunnel, got %+v", config)
+	}
+}
@@ -0,0 +1,175 @@
+// Package ngrok is a tunnel provider for users without a Cloudflare account. It calls no API: an
+// ngrok agent sidecar started with the account's auth token brings each app's endpoint up, and
+// the endpoint goes away when the agent stops. What each agent is started with is kept in the
+// ngrok_tunnels table.
+package ngrok
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/selfhostly/internal/constants"
+	"github.com/selfhostly/internal/db"
+	"github.com/selfhostly/internal/tunnel"
+)
+
+// agentImage is the ngrok agent the sidecar runs
+const agentImage = "ngrok/ngrok:latest"
+
+// orphanGracePeriod keeps CleanupOrphanedTunnels away from tunnels whose app is still being
+// created; tunnels are recorded just before their app
+const orphanGracePeriod = 10 * time.Minute
+
+// Provider is the ngrok tunnel provider. It supports containers only: an endpoint forwards to
+// a single upstream, so there are no ingress rules or DNS records to manage.
+type Provider struct {
+	authToken string
+	domain    string
+	database  *db.DB
+	logger    *slog.Logger
+}
+
+// NewProvider creates an ngrok provider. This is the factory function registered with the tunnel
+// registry; config carries "auth_token", an optional "domain" and the injected "database" and
+// "logger".
+//
+// With a domain (one reserved in ngrok with a wildcard, e.g. apps.example.com), each app is served
+// at <app>.<domain>. Without one, ngrok assigns a random URL each time the agent starts, which
+// only the agent's logs and the ngrok dashboard show.
+func NewProvider(config map[string]interface{}) (tunnel.Provider, error) {
+	authToken, ok := config["auth_token"].(string)
+	if !ok || authToken == "" {
+		return nil, fmt.Errorf("%w: auth_token is required", tunnel.ErrInvalidConfiguration)
+	}
+	domain, _ := config["domain"].(string)
+	database, ok := config["database"].(*db.DB)
+	if !ok || database == nil {
+		return nil, fmt.Errorf("%w: database is required", tunnel.ErrInvalidConfiguration)
+	}
+	logger, ok := config["logger"].(*slog.Logger)
+	if !ok {
+		logger = slog.Default()
+	}
+	return &Provider{
+		authToken: authToken,
+		domain:    strings.Trim(strings.ToLower(strings.TrimSpace(domain)), "."),
+		database:  database,
+		logger:    logger,
+	}, nil
+}
+
+// CreateTunnel records the endpoint an app's agent will bring up. opts.AdditionalConfig must name
+// the "upstream" to forward to (e.g. http://web:80) and may give a "hostname" to serve it at,
+// which takes precedence over <app>.<domain>.
+func (p *Provider) CreateTunnel(ctx context.Context, opts tunnel.CreateOptions) (*tunnel.Tunnel, error) {
+	upstream, _ := opts.AdditionalConfig["upstream"].(string)
+	if upstream == "" {
+		return nil, fmt.Errorf("%w: ngrok needs a service to forward to; add an ingress rule such as http://web:80", tunnel.ErrInvalidConfiguration)
+	}
+
+	publicURL := ""
+	if hostname, _ := opts.AdditionalConfig["hostname"].(string); hostname != "" {
+		publicURL = "https://" + strings.ToLower(hostname)
+	} else if p.domain != "" {
+		publicURL = fmt.Sprintf("https://%s.%s", strings.ToLower(opts.Name), p.domain)
+	}
+
+	record := db.NewNgrokTunnel(opts.AppID, opts.Name, upstream, publicURL)
+	if err := p.database.CreateNgrokTunnel(record); err != nil {
+		return nil, fmt.Errorf("failed to save ngrok tunnel: %w", err)
+	}
+
+	p.logger.InfoContext(ctx, "ngrok tunnel created", "app_id", opts.AppID, "upstream", upstream, "public_url", publicURL)
+	return p.toTunnel(record), nil
+}
+
+// GetTunnelByAppID returns an app's ngrok tunnel
+func (p *Provider) GetTunnelByAppID(ctx context.Context, appID string) (*tunnel.Tunnel, error) {
+	record, err := p.database.GetNgrokTunnelByAppID(appID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, tunnel.ErrTunnelNotFound
+		}
+		return nil, fmt.Errorf("failed to get ngrok tunnel: %w", err)
+	}
+	return p.toTunnel(record), nil
+}
+
+// DeleteTunnel forgets an app's tunnel; its endpoint already went away with the agent
+func (p *Provider) DeleteTunnel(ctx context.Context, appID string) error {
+	if err := p.database.DeleteNgrokTunnel(appID); err != nil {
+		return fmt.Errorf("failed to delete ngrok tunnel: %w", err)
+	}
+	p.logger.InfoContext(ctx, "ngrok tunnel deleted", "app_id", appID)
+	return nil
+}
+
+// CleanupOrphanedTunnels forgets the tunnels of apps that no longer exist. Nothing exists on the
+// ngrok side without a running agent, so there is nothing to remove there.
+func (p *Provider) CleanupOrphanedTunnels(ctx context.Context) error {
+	orphans, err := p.database.GetNgrokTunnelsWithoutApp(time.Now().Add(-orphanGracePeriod))
+	if err != nil {
+		return fmt.Errorf("failed to list ngrok tunnels: %w", err)
+	}
+	for _, record := range orphans {
+		if err := p.database.DeleteNgrokTunnel(record.AppID); err != nil {
+			return fmt.Errorf("failed to delete ngrok tunnel: %w", err)
+		}
+		p.logger.InfoContext(ctx, "removed orphaned ngrok tunnel", "app_id", record.AppID, "tunnel_name", record.TunnelName)
+	}
+	return nil
+}
+
+// Name returns the provider's identifier
+func (p *Provider) Name() string {
+	return constants.ProviderNgrok
+}
+
+// DisplayName returns the provider's human-readable name
+func (p *Provider) DisplayName() string {
+	return "ngrok"
+}
+
+// GetContainerConfig returns the agent sidecar for a tunnel; tunnelToken is the tunnel's ID, as
+// CreateTunnel hands it out. The account's auth token is passed to the agent, never stored with
+// the app. Returns nil if the tunnel is unknown.
+func (p *Provider) GetContainerConfig(tunnelToken string, appName string) *tunnel.ContainerConfig {
+	record, err := p.database.GetNgrokTunnel(tunnelToken)
+	if err != nil {
+		p.logger.Warn("no ngrok tunnel to run an agent for", "app", appName, "error", err)
+		return nil
+	}
+
+	command := []string{"http", record.Upstream, "--log", "stdout"}
+	if record.PublicURL != "" {
+		command = append(command, "--url", record.PublicURL)
+	}
+	return &tunnel.ContainerConfig{
+		Image:       agentImage,
+		Command:     command,
+		Environment: map[string]string{"NGROK_AUTHTOKEN": p.authToken},
+	}
+}
+
+// toTunnel converts a stored ngrok tunnel to the generic form
+func (p *Provider) toTunnel(record *db.NgrokTunnel) *tunnel.Tunnel {
+	return &tunnel.Tunnel{
+		ID:           record.ID,
+		AppID:        record.AppID,
+		ProviderType: constants.ProviderNgrok,
+		TunnelID:     record.ID,
+		TunnelName:   record.TunnelName,
+		TunnelToken:  record.ID,
+		PublicURL:    record.PublicURL,
+		Status:       record.Status,
+		IsActive:     record.Status == constants.TunnelStatusActive,
+		Metadata:     map[string]interface{}{"upstream": record.Upstream},
+		CreatedAt:    record.CreatedAt,
+		UpdatedAt:    record.UpdatedAt,
+	}
+}
@@ -0,0 +1,99 @@
+package ngrok
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/selfhostly/internal/db"
+	"github.com/selfhostly/internal/tunnel"
+)
+
+func setupTestProvider(t *testing.T, domain string) (*Provider, *db.DB) {
+	t.Helper()
+	database, err := db.Init(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to initialize database: %v", err)
+	}
+	t.Cleanup(func() { database.Close() })
+
+	provider, err := NewProvider(map[string]interface{}{"auth_token": "ngrok-secret", "domain": domain, "database": database})
+	if err != nil {
+		t.Fatalf("NewProvider: %v", err)
+	}
+	return provider.(*Provider), database
+}
+
+func TestNewProvider_RequiresAuthToken(t *testing.T) {
+	_, err := NewProvider(map[string]interface{}{"database": &db.DB{}})
+	if !errors.Is(err, tunnel.ErrInvalidConfiguration) {
+		t.Errorf("expected invalid configuration without auth_token, got %v", err)
+	}
+}
+
+func TestProvider_CreateTunnel(t *testing.T) {
+	p, _ := setupTestProvider(t, "Apps.Example.com.")
+	ctx := context.Background()
+
+	if _, err := p.CreateTunnel(ctx, tunnel.CreateOptions{AppID: "app-0", Name: "blog"}); !errors.Is(err, tunnel.ErrInvalidConfiguration) {
+		t.Errorf("expected a tunnel without upstream to be refused, got %v", err)
+	}
+
+	created, err := p.CreateTunnel(ctx, tunnel.CreateOptions{AppID: "app-1", Name: "Blog", AdditionalConfig: map[string]interface{}{"upstream": "http://web:80"}})
+	if err != nil {
+		t.Fatalf("CreateTunnel: %v", err)
+	}
+	if created.PublicURL != "https://blog.apps.example.com" || created.TunnelToken != created.ID || !created.IsActive {
+		t.Errorf("unexpected tunnel %+v", created)
+	}
+
+	// An ingress hostname takes precedence over the domain
+	withHost, err := p.CreateTunnel(ctx, tunnel.CreateOptions{AppID: "app-2", Name: "wiki", AdditionalConfig: map[string]interface{}{"upstream": "http://wiki:3000", "hostname": "wiki.example.org"}})
+	if err != nil {
+		t.Fatalf("CreateTunnel: %v", err)
+	}
+	if withHost.PublicURL != "https://wiki.example.org" {
+		t.Errorf("expected the ingress hostname as URL, got %q", withHost.PublicURL)
+	}
+
+	got, err := p.GetTunnelByAppID(ctx, "app-1")
+	if err != nil || got.ID != created.ID {
+		t.Fatalf("GetTunnelByAppID: %+v, %v", got, err)
+	}
+	if err := p.DeleteTunnel(ctx, "app-1"); err != nil {
+		t.Fatalf("DeleteTunnel: %v", err)
+	}
+	if _, err := p.GetTunnelByAppID(ctx, "app-1"); !errors.Is(err, tunnel.ErrTunnelNotFound) {
+		t.Errorf("expected tunnel not found after delete, got %v", err)
+	}
+}
+
+func TestProvider_GetContainerConfig(t *testing.T) {
+	p, _ := setupTestProvider(t, "")
+	ctx := context.Background()
+
+	created, err := p.CreateTunnel(ctx, tunnel.CreateOptions{AppID: "app-1", Name: "blog", AdditionalConfig: map[string]interface{}{"upstream": "http://web:80"}})
+	if err != nil {
+		t.Fatalf("CreateTunnel: %v", err)
+	}
+	if created.PublicURL != "" {
+		t.Errorf("expected no URL without a domain, got %q", created.PublicURL)
+	}
+
+	config := p.GetContainerConfig(created.TunnelToken, "blog")
+	if config == nil {
+		t.Fatal("expected a container config")
+	}
+	if config.Image != agentImage || config.Environment["NGROK_AUTHTOKEN"] != "ngrok-secret" {
+		t.Errorf("unexpected container config %+v", config)
+	}
+	if !slices.Equal(config.Command, []string{"http", "http://web:80", "--log", "stdout"}) {
+		t.Errorf("unexpected command %v", config.Command)
+	}
+
+	if config := p.GetContainerConfig("unknown", "blog"); config != nil {
+		t.Errorf("expected no container for an unknown tunnel, got %+v", config)
+	}
+}
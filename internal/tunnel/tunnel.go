@@ -64,6 +64,78 @@ type Tunnel struct {
 	UpdatedAt time.Time `json:"updated_at"`
 }
 
+// Zone is a DNS zone the provider can route hostnames in
+type Zone struct {
+	// ID is the provider's identifier for the zone
+	ID string `json:"id"`
+
+	// Name is the zone's apex domain (e.g., "example.com")
+	Name string `json:"name"`
+
+	// Status is the provider's zone status (e.g., "active", "pending")
+	Status string `json:"status"`
+}
+
+// Hostname is an existing DNS record in a zone. Ingress editors use these to warn about
+// hostnames that already point somewhere else.
+type Hostname struct {
+	// Name is the fully qualified hostname
+	Name string `json:"name"`
+
+	// Type is the DNS record type (e.g., "CNAME", "A")
+	Type string `json:"type"`
+
+	// Target is the record's content (an address or another hostname)
+	Target string `json:"target"`
+
+	// TunnelID is set when the record routes to one of this provider's tunnels
+	TunnelID string `json:"tunnel_id,omitempty"`
+}
+
+// Connector is a tunnel container (e.g. a cloudflared instance) that is running a tunnel's
+// credentials and connected to the provider's edge
+type Connector struct {
+	// ID is the provider's identifier for the connector
+	ID string `json:"id"`
+
+	// Version is the connector software's version
+	Version string `json:"version,omitempty"`
+
+	// StartedAt is when the connector process started
+	StartedAt *time.Time `json:"started_at,omitempty"`
+
+	// Connections are the connector's connections to the provider's edge
+	Connections []*Connection `json:"connections"`
+}
+
+// Connection is one of a connector's connections to an edge location
+type Connection struct {
+	// ID is the provider's identifier for the connection
+	ID string `json:"id"`
+
+	// Location is the edge location the connection terminates at (e.g., "ams01")
+	Location string `json:"location"`
+
+	// OriginIP is the address the connector connected from
+	OriginIP string `json:"origin_ip,omitempty"`
+
+	// OpenedAt is when the connection was established
+	OpenedAt time.Time `json:"opened_at"`
+
+	// PendingReconnect is set while the connection is being re-established
+	PendingReconnect bool `json:"pending_reconnect"`
+}
+
+// IsConnected reports whether the connector has at least one established connection
+func (c *Connector) IsConnected() bool {
+	for _, conn := range c.Connections {
+		if !conn.PendingReconnect {
+			return true
+		}
+	}
+	return false
+}
+
 // ContainerConfig defines the Docker container configuration for a tunnel provider.
 // This is used to inject the tunnel sidecar container into an application's
 // docker-compose file.
@@ -76,6 +148,10 @@ type ContainerConfig struct {
 	// Command is the command to run in the container (e.g., ["tunnel", "run"])
 	Command []string
 
+	// Entrypoint overrides the image's entrypoint when set, e.g. to write a config file from the
+	// environment before starting the agent
+	Entrypoint []string
+
 	// Environment contains environment variables for the container
 	// Typically includes authentication tokens and configuration
 	Environment map[string]string
@@ -89,4 +165,63 @@ type ContainerConfig struct {
 
 	// Ports are optional port mappings (e.g., ["2000:2000"] for Quick Tunnel metrics)
 	Ports []string
+
+	// Replicas is how many copies of the container run the tunnel; 0 or 1 runs a single,
+	// named container
+	Replicas int
+}
+
+// Traffic is the HTTP traffic that reached an app through its tunnel's hostnames over a time range
+type Traffic struct {
+	// Since and Until bound the time range the traffic was counted over
+	Since time.Time `json:"since"`
+	Until time.Time `json:"until"`
+
+	// Requests, Bytes and Visits total the traffic across every hostname
+	Requests int64 `json:"requests"`
+	Bytes    int64 `json:"bytes"`
+	Visits   int64 `json:"visits"`
+
+	// Hostnames breaks the totals down per routed hostname
+	Hostnames []*HostnameTraffic `json:"hostnames"`
+
+	// Countries are the client countries sending the most requests
+	Countries []*TrafficCount `json:"countries"`
+
+	// StatusCodes are the response status codes returned most often
+	StatusCodes []*TrafficCount `json:"status_codes"`
+
+	// UntrackedHostnames are routed hostnames the provider has no analytics for, e.g. wildcards
+	// or hostnames in zones the credentials can't read
+	UntrackedHostnames []string `json:"untracked_hostnames"`
+}
+
+// HostnameTraffic is the traffic one hostname received
+type HostnameTraffic struct {
+	Hostname string `json:"hostname"`
+	Requests int64  `json:"requests"`
+	Bytes    int64  `json:"bytes"`  // Bytes served to clients
+	Visits   int64  `json:"visits"` // Requests that started a visit to the site
+}
+
+// TrafficCount is the number of requests sharing one value, e.g. a country or status code
+type TrafficCount struct {
+	Key      string `json:"key"`
+	Requests int64  `json:"requests"`
+}
+
+// ConnectorOptions tune how a tunnel's container connects to the provider's edge, e.g. forcing
+// HTTP/2 on networks that block QUIC. Empty fields keep the provider's default.
+type ConnectorOptions struct {
+	// Protocol is the transport to the edge (Cloudflare: auto, quic or http2)
+	Protocol string `json:"protocol,omitempty"`
+
+	// EdgeIPVersion is the IP version used to reach the edge (auto, 4 or 6)
+	EdgeIPVersion string `json:"edge_ip_version,omitempty"`
+
+	// PostQuantum requires post-quantum key agreement with the edge
+	PostQuantum bool `json:"post_quantum,omitempty"`
+
+	// LogLevel is the connector's log verbosity (debug, info, warn, error or fatal)
+	LogLevel string `json:"log_level,omitempty"`
 }
@@ -1,8 +1,12 @@
 package validation
 
 import (
+	"errors"
+	"slices"
 	"strings"
 	"testing"
+
+	"github.com/selfhostly/internal/constants"
 )
 
 func TestValidateComposeSecurity_Privileged(t *testing.T) {
@@ -900,3 +904,112 @@ func contains(s, substr string) bool {
 	return len(s) >= len(substr) && (s == substr || len(substr) == 0 || 
 		(len(s) > 0 && len(substr) > 0 && strings.Contains(s, substr)))
 }
+
+func TestValidateComposeOverrideWithConfig(t *testing.T) {
+	base := `services:
+  web:
+    image: nginx:latest
+`
+	tests := []struct {
+		name      string
+		override  string
+		shouldErr bool
+		errMsg    string
+	}{
+		{
+			name: "override adds resource limits",
+			override: `services:
+  web:
+    mem_limit: 256m
+`,
+			shouldErr: false,
+		},
+		{
+			name: "override cannot enable privileged mode",
+			override: `services:
+  web:
+    privileged: true
+`,
+			shouldErr: true,
+			errMsg:    "privileged mode is not allowed",
+		},
+		{
+			name:      "invalid yaml",
+			override:  "services: [",
+			shouldErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateComposeOverrideWithConfig(base, tt.override, nil)
+			if tt.shouldErr && err == nil {
+				t.Fatalf("expected error, got nil")
+			}
+			if !tt.shouldErr && err != nil {
+				t.Fatalf("expected no error, got %v", err)
+			}
+			if tt.errMsg != "" && !strings.Contains(err.Error(), tt.errMsg) {
+				t.Errorf("expected error containing %q, got %v", tt.errMsg, err)
+			}
+		})
+	}
+}
+
+func TestValidateComposeSecurity_Policies(t *testing.T) {
+	compose := `
+services:
+  vpn:
+    image: wireguard
+    network_mode: host
+    cap_add:
+      - SYS_ADMIN
+  tv:
+    image: jellyfin
+    devices:
+      - /dev/dri:/dev/dri
+`
+	approval := map[string]string{
+		constants.ComposePolicyHostNetwork: constants.ComposePolicyApproval,
+		constants.ComposePolicyCapAdd:      constants.ComposePolicyApproval,
+		constants.ComposePolicyDevices:     constants.ComposePolicyAllow,
+	}
+
+	// Blocked by default
+	if err := ValidateComposeContentWithConfig(compose, &SecurityConfig{}); err == nil || !strings.Contains(err.Error(), "is not allowed") {
+		t.Errorf("expected the compose to be blocked, got %v", err)
+	}
+
+	// Every pending approval is reported at once
+	err := ValidateComposeContentWithConfig(compose, &SecurityConfig{Policies: approval})
+	var approvalErr *PolicyApprovalError
+	if !errors.As(err, &approvalErr) {
+		t.Fatalf("expected a policy approval error, got %v", err)
+	}
+	if !slices.Equal(approvalErr.Policies, []string{constants.ComposePolicyCapAdd, constants.ComposePolicyHostNetwork}) {
+		t.Errorf("unexpected policies to approve %v", approvalErr.Policies)
+	}
+
+	// Partly approved still needs the rest
+	err = ValidateComposeContentWithConfig(compose, &SecurityConfig{Policies: approval, ApprovedPolicies: []string{constants.ComposePolicyHostNetwork}})
+	if !errors.As(err, &approvalErr) || !slices.Equal(approvalErr.Policies, []string{constants.ComposePolicyCapAdd}) {
+		t.Errorf("expected cap_add to still need approval, got %v", err)
+	}
+
+	if err := ValidateComposeContentWithConfig(compose, &SecurityConfig{Policies: approval, ApprovedPolicies: []string{constants.ComposePolicyHostNetwork, constants.ComposePolicyCapAdd}}); err != nil {
+		t.Errorf("expected the approved compose to pass, got %v", err)
+	}
+
+	// A blocked setting is refused even when others only need approval
+	approval[constants.ComposePolicyDevices] = constants.ComposePolicyBlock
+	err = ValidateComposeContentWithConfig(compose, &SecurityConfig{Policies: approval})
+	if err == nil || errors.As(err, &approvalErr) || !strings.Contains(err.Error(), "device access is not allowed") {
+		t.Errorf("expected the devices to be blocked outright, got %v", err)
+	}
+
+	// Policies never open host PID mode
+	pid := "services:\n  app:\n    image: alpine\n    pid: host\n"
+	if err := ValidateComposeContentWithConfig(pid, &SecurityConfig{Policies: map[string]string{constants.ComposePolicyPrivileged: constants.ComposePolicyAllow}}); err == nil {
+		t.Error("expected pid: host to stay blocked")
+	}
+}
@@ -3,11 +3,19 @@ package validation
 import (
 	"errors"
 	"fmt"
+	"net/netip"
+	"path"
 	"path/filepath"
 	"regexp"
+	"slices"
+	"sort"
 	"strings"
+	"time"
 
+	"github.com/distribution/reference"
+	"github.com/selfhostly/internal/constants"
 	"github.com/selfhostly/internal/docker"
+	"github.com/selfhostly/internal/secretstore"
 )
 
 var (
@@ -17,11 +25,32 @@ var (
 	// containerIDRegex validates Docker container IDs (12 or 64 character hex strings)
 	// Note: Docker IDs are lowercase hex, but we accept uppercase for flexibility
 	containerIDRegex = regexp.MustCompile(`^[a-fA-F0-9]{12,64}$`)
+
+	// logSinceRelativeRegex validates relative durations accepted by "docker logs --since" (e.g. 30s, 15m, 2h)
+	logSinceRelativeRegex = regexp.MustCompile(`^[0-9]+(s|m|h)$`)
+
+	// labelRegex validates label keys and values: alphanumeric at both ends, with '-', '_', '.'
+	// and '/' allowed in between
+	labelRegex = regexp.MustCompile(`^[a-zA-Z0-9]([a-zA-Z0-9._/-]*[a-zA-Z0-9])?$`)
 )
 
 // SecurityConfig holds security validation configuration
 type SecurityConfig struct {
 	AllowedVolumePaths []string
+
+	// BindMountPolicy is "denylist" (the default: any host path except the protected ones and /home)
+	// or "allowlist" (only the app directory and paths under AllowedVolumePaths)
+	BindMountPolicy string
+
+	// AllowedProtectedMounts are protected host paths, such as the Docker socket, that apps may
+	// still bind-mount. Each is matched exactly, never as a parent of the mounted path.
+	AllowedProtectedMounts []string
+
+	// Policies sets each compose policy (privileged, host_network, cap_add, devices) to block,
+	// approval or allow; a missing policy blocks. ApprovedPolicies are the ones an admin approved
+	// for the change being validated.
+	Policies         map[string]string
+	ApprovedPolicies []string
 }
 
 // defaultSecurityConfig is used when no config is provided
@@ -98,6 +127,12 @@ func ValidateComposeContent(content string) error {
 
 // ValidateComposeContentWithConfig validates Docker Compose file content with custom security config
 func ValidateComposeContentWithConfig(content string, securityConfig *SecurityConfig) error {
+	return ValidateComposeContentWithFiles(content, nil, securityConfig)
+}
+
+// ValidateComposeContentWithFiles validates Docker Compose file content together with the app's
+// extra compose files, so services pulled in with include: or extends: get the same checks
+func ValidateComposeContentWithFiles(content string, files map[string]string, securityConfig *SecurityConfig) error {
 	// Check if empty
 	if len(content) == 0 {
 		return errors.New("compose file content cannot be empty")
@@ -110,7 +145,7 @@ func ValidateComposeContentWithConfig(content string, securityConfig *SecurityCo
 	}
 	
 	// Parse and validate the compose file structure
-	compose, err := docker.ParseCompose([]byte(content))
+	compose, err := docker.ParseComposeWithFiles([]byte(content), files)
 	if err != nil {
 		// If it's already a ComposeParseError, return it as-is
 		var parseErr *docker.ComposeParseError
@@ -121,16 +156,114 @@ func ValidateComposeContentWithConfig(content string, securityConfig *SecurityCo
 		return fmt.Errorf("invalid compose file: %w", err)
 	}
 	
+	return validateParsedCompose(compose, securityConfig)
+}
+
+// ValidateComposeOverrideWithConfig validates a compose override file by merging it onto the
+// base compose content and validating the result, so overrides get the same security checks
+func ValidateComposeOverrideWithConfig(baseContent, overrideContent string, securityConfig *SecurityConfig) error {
+	return ValidateComposeOverrideWithFiles(baseContent, overrideContent, nil, securityConfig)
+}
+
+// ValidateComposeOverrideWithFiles is ValidateComposeOverrideWithConfig for apps with extra
+// compose files
+func ValidateComposeOverrideWithFiles(baseContent, overrideContent string, files map[string]string, securityConfig *SecurityConfig) error {
+	maxSize := 1 << 20 // 1MB
+	if len(overrideContent) > maxSize {
+		return fmt.Errorf("compose override file too large: %d bytes (maximum %d bytes)", len(overrideContent), maxSize)
+	}
+
+	compose, err := docker.ParseComposeWithOverride([]byte(baseContent), []byte(overrideContent), files)
+	if err != nil {
+		var parseErr *docker.ComposeParseError
+		if errors.As(err, &parseErr) {
+			return err
+		}
+		return fmt.Errorf("invalid compose override file: %w", err)
+	}
+
+	return validateParsedCompose(compose, securityConfig)
+}
+
+// ValidateComposeFiles validates the names and sizes of an app's extra compose files. Names are
+// paths relative to the app directory ending in .yml or .yaml; the files selfhostly manages
+// itself can't be replaced. Their content is checked when the compose that references them is
+// validated.
+func ValidateComposeFiles(files map[string]string) error {
+	if len(files) > 20 {
+		return errors.New("an app can have at most 20 compose files")
+	}
+	reserved := map[string]bool{
+		docker.ComposeFileName:         true,
+		docker.ComposeOverrideFileName: true,
+		docker.ComposeTunnelFileName:   true,
+		docker.ComposeLoggingFileName:  true,
+		docker.VectorConfigFileName:    true,
+	}
+	maxSize := 1 << 20 // 1MB
+	for name, content := range files {
+		if name == "" {
+			return errors.New("compose file name cannot be empty")
+		}
+		if strings.Contains(name, "\\") || filepath.IsAbs(name) || path.Clean(name) != name || name == ".." || strings.HasPrefix(name, "../") {
+			return fmt.Errorf("compose file %q must be a clean path relative to the app directory", name)
+		}
+		if ext := path.Ext(name); ext != ".yml" && ext != ".yaml" {
+			return fmt.Errorf("compose file %q must end in .yml or .yaml", name)
+		}
+		if reserved[name] {
+			return fmt.Errorf("compose file %q is managed by selfhostly", name)
+		}
+		if len(content) > maxSize {
+			return fmt.Errorf("compose file %q too large: %d bytes (maximum %d bytes)", name, len(content), maxSize)
+		}
+	}
+	return nil
+}
+
+// ValidateEnvTemplate checks an app's managed .env template: KEY=VALUE lines whose {{ }}
+// expressions render and whose secret references (vault://, op://, sops://) are well formed
+func ValidateEnvTemplate(content string) error {
+	maxSize := 64 << 10 // 64KB
+	if len(content) > maxSize {
+		return fmt.Errorf("env template too large: %d bytes (maximum %d bytes)", len(content), maxSize)
+	}
+	rendered, err := docker.RenderEnvTemplate(content, docker.EnvTemplateData{}, "", "")
+	if err != nil {
+		return err
+	}
+	for i, line := range strings.Split(rendered, "\n") {
+		key, value, _ := strings.Cut(strings.TrimSpace(line), "=")
+		if strings.HasPrefix(key, "#") || !secretstore.IsRef(value) {
+			continue
+		}
+		if _, err := secretstore.ParseRef(value); err != nil {
+			return fmt.Errorf("line %d: %w", i+1, err)
+		}
+	}
+	return nil
+}
+
+// validateParsedCompose runs structural and security checks on a parsed compose file
+func validateParsedCompose(compose *docker.ComposeFile, securityConfig *SecurityConfig) error {
 	// Validate that services don't reference undefined networks
 	if err := validateServiceNetworks(compose); err != nil {
 		return fmt.Errorf("network validation failed: %w", err)
 	}
+	if err := validateNetworkAddresses(compose); err != nil {
+		return fmt.Errorf("network validation failed: %w", err)
+	}
 	
 	// Validate that services don't reference undefined volumes
 	if err := validateServiceVolumes(compose); err != nil {
 		return fmt.Errorf("volume validation failed: %w", err)
 	}
 	
+	// Reject image references docker would refuse to pull
+	if err := validateServiceImages(compose); err != nil {
+		return fmt.Errorf("image validation failed: %w", err)
+	}
+	
 	// Use default config if none provided
 	if securityConfig == nil {
 		securityConfig = defaultSecurityConfig
@@ -175,6 +308,78 @@ func validateServiceNetworks(compose *docker.ComposeFile) error {
 	return nil
 }
 
+// validateNetworkAddresses checks custom subnets and the static addresses services take in them.
+// Docker only assigns a fixed ipv4_address or ipv6_address on a network with a user configured
+// subnet, and fails the deploy halfway when two containers claim the same one.
+func validateNetworkAddresses(compose *docker.ComposeFile) error {
+	subnets := make(map[string][]netip.Prefix)
+	for networkName, network := range compose.Networks {
+		if network.IPAM == nil {
+			continue
+		}
+		for _, pool := range network.IPAM.Config {
+			if pool.Subnet == "" {
+				continue
+			}
+			subnet, err := netip.ParsePrefix(pool.Subnet)
+			if err != nil {
+				return fmt.Errorf("network %q has an invalid subnet %q", networkName, pool.Subnet)
+			}
+			if pool.Gateway != "" {
+				if gateway, err := netip.ParseAddr(pool.Gateway); err != nil || !subnet.Contains(gateway) {
+					return fmt.Errorf("network %q has gateway %q outside of its subnet %s", networkName, pool.Gateway, pool.Subnet)
+				}
+			}
+			if pool.IPRange != "" {
+				if ipRange, err := netip.ParsePrefix(pool.IPRange); err != nil || !subnet.Contains(ipRange.Addr()) || ipRange.Bits() < subnet.Bits() {
+					return fmt.Errorf("network %q has ip_range %q outside of its subnet %s", networkName, pool.IPRange, pool.Subnet)
+				}
+			}
+			subnets[networkName] = append(subnets[networkName], subnet)
+		}
+	}
+
+	serviceNames := make([]string, 0, len(compose.Services))
+	for name := range compose.Services {
+		serviceNames = append(serviceNames, name)
+	}
+	sort.Strings(serviceNames)
+
+	taken := make(map[string]string) // network/address → service
+	for _, serviceName := range serviceNames {
+		service := compose.Services[serviceName]
+		for _, networkName := range service.Networks {
+			settings := service.NetworkSettings[networkName]
+			for _, address := range []string{settings.IPv4Address, settings.IPv6Address} {
+				if address == "" {
+					continue
+				}
+				ip, err := netip.ParseAddr(address)
+				if err != nil {
+					return fmt.Errorf("service %q has an invalid address %q on network %q", serviceName, address, networkName)
+				}
+				network := compose.Networks[networkName]
+				if network.External {
+					// Its subnet is configured outside of this compose file
+					continue
+				}
+				if len(subnets[networkName]) == 0 {
+					return fmt.Errorf("service %q sets a static address on network %q, which needs a custom subnet (networks: { %q: { ipam: { config: [{ subnet: ... }] } } })", serviceName, networkName, networkName)
+				}
+				if !slices.ContainsFunc(subnets[networkName], func(subnet netip.Prefix) bool { return subnet.Contains(ip) }) {
+					return fmt.Errorf("service %q address %s is outside of the subnets of network %q", serviceName, address, networkName)
+				}
+				key := networkName + "/" + ip.String()
+				if other, ok := taken[key]; ok {
+					return fmt.Errorf("services %q and %q both use address %s on network %q", other, serviceName, address, networkName)
+				}
+				taken[key] = serviceName
+			}
+		}
+	}
+	return nil
+}
+
 // validateServiceVolumes ensures all named volumes referenced by services are defined
 func validateServiceVolumes(compose *docker.ComposeFile) error {
 	// Get all defined volumes
@@ -218,6 +423,34 @@ func validateServiceVolumes(compose *docker.ComposeFile) error {
 	return nil
 }
 
+// validateServiceImages ensures every service image is a well-formed reference
+func validateServiceImages(compose *docker.ComposeFile) error {
+	for serviceName, service := range compose.Services {
+		if service.Image == "" {
+			continue // build-only services have no image to validate
+		}
+		if err := ValidateImageReference(service.Image); err != nil {
+			return fmt.Errorf("service %q: %w", serviceName, err)
+		}
+	}
+	return nil
+}
+
+// ValidateImageReference checks that an image reference (e.g. "nginx:1.25", "ghcr.io/org/app@sha256:...")
+// is syntactically valid. References still containing variable placeholders are left to docker.
+func ValidateImageReference(image string) error {
+	if strings.Contains(image, "$") {
+		return nil
+	}
+	if strings.TrimSpace(image) != image {
+		return fmt.Errorf("invalid image reference %q: must not contain whitespace", image)
+	}
+	if _, err := reference.ParseNormalizedNamed(image); err != nil {
+		return fmt.Errorf("invalid image reference %q: %w", image, err)
+	}
+	return nil
+}
+
 // validateComposeSecurity validates Docker Compose file for security vulnerabilities (backward compatibility)
 func validateComposeSecurity(compose *docker.ComposeFile) error {
 	return validateComposeSecurityWithConfig(compose, defaultSecurityConfig)
@@ -232,11 +465,17 @@ func validateComposeSecurity(compose *docker.ComposeFile) error {
 // - Use host network/PID/IPC namespaces
 // - Add dangerous Linux capabilities
 // - Disable security features
+//
+// Privileged mode, host networking, dangerous capabilities and devices can be let through by the
+// compose policies in securityConfig.
 func validateComposeSecurityWithConfig(compose *docker.ComposeFile, securityConfig *SecurityConfig) error {
+	policies := &policyCheck{config: securityConfig}
 	for serviceName, service := range compose.Services {
 		// Block privileged mode - grants full host access
 		if service.Privileged {
-			return fmt.Errorf("service %q: privileged mode is not allowed for security reasons (can escape container and access host)", serviceName)
+			if err := policies.check(constants.ComposePolicyPrivileged, fmt.Errorf("service %q: privileged mode is not allowed for security reasons (can escape container and access host)", serviceName)); err != nil {
+				return err
+			}
 		}
 		
 		// Validate volume mounts for dangerous host paths
@@ -248,7 +487,9 @@ func validateComposeSecurityWithConfig(compose *docker.ComposeFile, securityConf
 		
 		// Block device access - can access hardware and potentially escape container
 		if len(service.Devices) > 0 {
-			return fmt.Errorf("service %q: device access is not allowed for security reasons (devices: %v)", serviceName, service.Devices)
+			if err := policies.check(constants.ComposePolicyDevices, fmt.Errorf("service %q: device access is not allowed for security reasons (devices: %v)", serviceName, service.Devices)); err != nil {
+				return err
+			}
 		}
 		
 		// Validate tmpfs mounts for dangerous paths
@@ -260,7 +501,9 @@ func validateComposeSecurityWithConfig(compose *docker.ComposeFile, securityConf
 		
 		// Block host network mode - bypasses network isolation
 		if service.NetworkMode == "host" {
-			return fmt.Errorf("service %q: network_mode 'host' is not allowed for security reasons (bypasses network isolation)", serviceName)
+			if err := policies.check(constants.ComposePolicyHostNetwork, fmt.Errorf("service %q: network_mode 'host' is not allowed for security reasons (bypasses network isolation)", serviceName)); err != nil {
+				return err
+			}
 		}
 		
 		// Block host PID namespace - allows access to all host processes
@@ -275,7 +518,7 @@ func validateComposeSecurityWithConfig(compose *docker.ComposeFile, securityConf
 		
 		// Validate Linux capabilities - block dangerous capability additions
 		if len(service.CapAdd) > 0 {
-			if err := validateCapabilities(serviceName, service.CapAdd); err != nil {
+			if err := policies.check(constants.ComposePolicyCapAdd, validateCapabilities(serviceName, service.CapAdd)); err != nil {
 				return err
 			}
 		}
@@ -292,6 +535,19 @@ func validateComposeSecurityWithConfig(compose *docker.ComposeFile, securityConf
 			return fmt.Errorf("service %q: custom cgroup_parent is not allowed for security reasons", serviceName)
 		}
 	}
+
+	// Named volumes the local driver bind-mounts from the host get the same checks
+	for volumeName, volume := range compose.Volumes {
+		if err := validateVolumeDriverSecurity(volumeName, volume, securityConfig); err != nil {
+			return err
+		}
+	}
+
+	// Only once nothing is refused outright, so one approval covers the whole change
+	if policies.pending != nil {
+		sort.Strings(policies.pending.Reasons)
+		return policies.pending
+	}
 	
 	return nil
 }
@@ -309,89 +565,167 @@ func validateVolumeSecurityWithConfig(serviceName, volumeSpec string, securityCo
 		// Invalid format, will be caught by Docker - allow it through
 		return nil
 	}
-	
-	hostPath := strings.TrimSpace(parts[0])
-	
-	// Skip named volumes (don't start with / or ./ or ../)
-	if !strings.HasPrefix(hostPath, "/") && 
-	   !strings.HasPrefix(hostPath, "./") && 
-	   !strings.HasPrefix(hostPath, "../") {
-		return nil // Named volume, safe
+
+	return validateHostPath(fmt.Sprintf("service %q", serviceName), strings.TrimSpace(parts[0]), securityConfig)
+}
+
+// validateVolumeDriverSecurity checks a top-level named volume that the local driver bind-mounts
+// from the host (driver_opts o: bind, device: <path>), which is a bind mount under another name
+func validateVolumeDriverSecurity(volumeName string, volume docker.Volume, securityConfig *SecurityConfig) error {
+	device := strings.TrimSpace(volume.DriverOpts["device"])
+	if device == "" || !slices.Contains(strings.Split(volume.DriverOpts["o"], ","), "bind") {
+		return nil
 	}
-	
+	if !strings.HasPrefix(device, "/") {
+		return fmt.Errorf("volume %q: bind device %q must be an absolute path", volumeName, device)
+	}
+	return validateHostPath(fmt.Sprintf("volume %q", volumeName), device, securityConfig)
+}
+
+// validateHostPath checks a host path about to be bind-mounted against the protected paths and the
+// bind mount policy. subject names what mounts it in errors, e.g. service "web".
+func validateHostPath(subject, hostPath string, securityConfig *SecurityConfig) error {
+	allowlist := securityConfig.BindMountPolicy == constants.BindMountPolicyAllowlist
+
+	// Relative paths are bind mounts inside the app directory (compose-go turns ./data into data)
+	// unless they climb out of it; anything else without a leading / is a named volume
+	if !strings.HasPrefix(hostPath, "/") {
+		if !strings.HasPrefix(hostPath, "./") && !strings.HasPrefix(hostPath, "../") && hostPath != ".." {
+			return nil // Named volume, safe
+		}
+		cleaned := filepath.Clean(hostPath)
+		if allowlist && (cleaned == ".." || strings.HasPrefix(cleaned, "../")) {
+			return fmt.Errorf("%s: mounting %q is not allowed (bind mounts must stay inside the app directory or under ALLOWED_VOLUME_PATHS)", subject, hostPath)
+		}
+		return nil
+	}
+
 	// Clean the path to resolve any .. or . components
 	cleanedPath := filepath.Clean(hostPath)
-	
-	// List of critical paths that should NEVER be mounted (even if whitelisted)
-	// These are checked first before any whitelist
-	criticalPaths := []struct {
-		path   string
-		reason string
-	}{
-		{"/var/run/docker.sock", "grants full Docker control and host access"},
-		{"/var/run/docker", "grants access to Docker runtime"},
-		{"/run/docker.sock", "grants full Docker control and host access"},
-		{"/", "grants access to entire host filesystem"},
-		{"/root", "grants access to root user's home directory"},
-		{"/etc", "grants access to system configuration files"},
-		{"/boot", "grants access to boot partition"},
-		{"/sys", "grants access to kernel interfaces"},
-		{"/proc", "grants access to process information"},
-		{"/dev", "grants access to device files"},
-		{"/host", "commonly used to mount root filesystem"},
-		{"/var/lib/docker", "Docker internal storage"},
-		{"/var/lib/kubelet", "Kubernetes internal storage"},
-		{"/var/lib/rancher", "Rancher internal storage"},
-	}
-	
-	// Check critical paths first (cannot be overridden by whitelist)
-	for _, critical := range criticalPaths {
-		// Exact match
-		if cleanedPath == critical.path {
-			return fmt.Errorf("service %q: mounting %q is not allowed (%s)", serviceName, hostPath, critical.reason)
+
+	// Critical paths can't be mounted even when under an allowed path; only an exact entry in
+	// ALLOWED_PROTECTED_MOUNTS lets one through, e.g. the Docker socket for a reverse proxy
+	for _, critical := range protectedHostPaths {
+		if cleanedPath != critical.path && !strings.HasPrefix(cleanedPath, critical.path+"/") {
+			continue
 		}
-		
-		// Prefix match (e.g., /root/anything)
-		if strings.HasPrefix(cleanedPath, critical.path+"/") {
-			return fmt.Errorf("service %q: mounting paths under %q is not allowed (%s)", serviceName, critical.path, critical.reason)
+		if containsCleanPath(securityConfig.AllowedProtectedMounts, cleanedPath) {
+			return nil
+		}
+		if cleanedPath == critical.path {
+			return fmt.Errorf("%s: mounting %q is not allowed (%s)", subject, hostPath, critical.reason)
 		}
+		return fmt.Errorf("%s: mounting paths under %q is not allowed (%s)", subject, critical.path, critical.reason)
 	}
-	
+
 	// Check if path is in the whitelist (allowed paths)
 	// Whitelist can only override non-critical path restrictions (like /home)
-	isWhitelisted := false
 	for _, allowedPath := range securityConfig.AllowedVolumePaths {
 		allowedPath = strings.TrimSpace(allowedPath)
 		if allowedPath == "" {
 			continue
 		}
-		
+
 		// Clean the allowed path as well
 		cleanedAllowed := filepath.Clean(allowedPath)
-		
+
 		// Check if the host path is exactly the allowed path or a subdirectory of it
 		if cleanedPath == cleanedAllowed || strings.HasPrefix(cleanedPath+"/", cleanedAllowed+"/") {
-			isWhitelisted = true
-			break
+			return nil
 		}
 	}
-	
-	// If whitelisted, allow it (critical paths already blocked above)
-	if isWhitelisted {
-		return nil
+
+	// Under the allowlist policy nothing else on the host may be mounted
+	if allowlist {
+		return fmt.Errorf("%s: mounting %q is not allowed (BIND_MOUNT_POLICY=allowlist only permits paths under ALLOWED_VOLUME_PATHS)", subject, hostPath)
 	}
-	
+
 	// Block mounting from /home (contains user data and SSH keys)
 	// This can be overridden by whitelist (unlike critical paths above)
 	if strings.HasPrefix(cleanedPath, "/home/") {
-		return fmt.Errorf("service %q: mounting /home paths is not allowed (contains sensitive user data). Use ALLOWED_VOLUME_PATHS environment variable to whitelist specific paths", serviceName)
+		return fmt.Errorf("%s: mounting /home paths is not allowed (contains sensitive user data). Use ALLOWED_VOLUME_PATHS environment variable to whitelist specific paths", subject)
 	}
-	
+
 	// Allow other paths (e.g., /data, /mnt, /opt, specific app directories)
 	// These are typically safe for application data
 	return nil
 }
 
+// protectedHostPaths should never be bind-mounted, nor any path under them
+var protectedHostPaths = []struct {
+	path   string
+	reason string
+}{
+	{"/var/run/docker.sock", "grants full Docker control and host access"},
+	{"/var/run/docker", "grants access to Docker runtime"},
+	{"/run/docker.sock", "grants full Docker control and host access"},
+	{"/", "grants access to entire host filesystem"},
+	{"/root", "grants access to root user's home directory"},
+	{"/etc", "grants access to system configuration files"},
+	{"/boot", "grants access to boot partition"},
+	{"/sys", "grants access to kernel interfaces"},
+	{"/proc", "grants access to process information"},
+	{"/dev", "grants access to device files"},
+	{"/host", "commonly used to mount root filesystem"},
+	{"/var/lib/docker", "Docker internal storage"},
+	{"/var/lib/kubelet", "Kubernetes internal storage"},
+	{"/var/lib/rancher", "Rancher internal storage"},
+}
+
+// containsCleanPath reports whether paths lists path exactly, ignoring trailing slashes
+func containsCleanPath(paths []string, path string) bool {
+	for _, p := range paths {
+		if p = strings.TrimSpace(p); p != "" && filepath.Clean(p) == path {
+			return true
+		}
+	}
+	return false
+}
+
+// PolicyApprovalError reports compose settings that a policy in "approval" mode only lets through
+// once an admin approves them for the change, by listing the policies in approve_policies
+type PolicyApprovalError struct {
+	Policies []string // Policies the change needs approved
+	Reasons  []string // What in the compose needs them, one entry per service and setting
+}
+
+func (e *PolicyApprovalError) Error() string {
+	return fmt.Sprintf("%s; approve with approve_policies: [%s]", strings.Join(e.Reasons, "; "), strings.Join(e.Policies, ", "))
+}
+
+// policyCheck applies the compose policies to settings that are refused by default, collecting
+// the approvals still needed rather than stopping at the first
+type policyCheck struct {
+	config  *SecurityConfig
+	pending *PolicyApprovalError
+}
+
+// check returns violation unless the policy allows it, or needs approval and the change has it.
+// A nil violation passes.
+func (p *policyCheck) check(policy string, violation error) error {
+	if violation == nil {
+		return nil
+	}
+	switch p.config.Policies[policy] {
+	case constants.ComposePolicyAllow:
+		return nil
+	case constants.ComposePolicyApproval:
+		if slices.Contains(p.config.ApprovedPolicies, policy) {
+			return nil
+		}
+		if p.pending == nil {
+			p.pending = &PolicyApprovalError{}
+		}
+		if !slices.Contains(p.pending.Policies, policy) {
+			p.pending.Policies = append(p.pending.Policies, policy)
+			sort.Strings(p.pending.Policies)
+		}
+		p.pending.Reasons = append(p.pending.Reasons, strings.Replace(violation.Error(), "is not allowed", "requires admin approval", 1))
+		return nil
+	}
+	return violation
+}
+
 // validateTmpfsSecurity checks if a tmpfs mount is dangerous
 func validateTmpfsSecurity(serviceName, tmpfsSpec string) error {
 	// Parse tmpfs spec: "/path" or "/path:options"
@@ -493,3 +827,62 @@ func ValidateDescription(description string) error {
 	
 	return nil
 }
+
+// ValidateLogSince validates a "since" value for log queries
+// Accepts a relative duration (e.g. "15m", "2h") or an RFC3339 timestamp
+func ValidateLogSince(since string) error {
+	if since == "" {
+		return nil
+	}
+	if logSinceRelativeRegex.MatchString(since) {
+		return nil
+	}
+	if _, err := time.Parse(time.RFC3339, since); err == nil {
+		return nil
+	}
+	return errors.New("since must be a relative duration (e.g. 15m, 2h) or an RFC3339 timestamp")
+}
+
+// ValidateLabels validates an app's labels
+func ValidateLabels(labels map[string]string) error {
+	if len(labels) > 32 {
+		return errors.New("an app can have at most 32 labels")
+	}
+	for key, value := range labels {
+		if err := ValidateLabelKey(key); err != nil {
+			return err
+		}
+		if err := ValidateLabelValue(value); err != nil {
+			return fmt.Errorf("label %q: %w", key, err)
+		}
+	}
+	return nil
+}
+
+// ValidateLabelKey validates a label key
+func ValidateLabelKey(key string) error {
+	if key == "" {
+		return errors.New("label key cannot be empty")
+	}
+	if len(key) > 63 {
+		return fmt.Errorf("label key %q must be 63 characters or less", key)
+	}
+	if !labelRegex.MatchString(key) {
+		return fmt.Errorf("label key %q must start and end with a letter or number and contain only letters, numbers, '-', '_', '.' and '/'", key)
+	}
+	return nil
+}
+
+// ValidateLabelValue validates a label value; empty values are allowed
+func ValidateLabelValue(value string) error {
+	if value == "" {
+		return nil
+	}
+	if len(value) > 63 {
+		return errors.New("label value must be 63 characters or less")
+	}
+	if !labelRegex.MatchString(value) {
+		return errors.New("label value must start and end with a letter or number and contain only letters, numbers, '-', '_', '.' and '/'")
+	}
+	return nil
+}
@@ -204,3 +204,161 @@ func TestValidateDescription(t *testing.T) {
 	}
 }
 
+
+func TestValidateLabels(t *testing.T) {
+	tests := []struct {
+		name      string
+		labels    map[string]string
+		shouldErr bool
+	}{
+		// Valid labels
+		{"none", nil, false},
+		{"simple", map[string]string{"env": "prod", "team": "media"}, false},
+		{"prefixed key", map[string]string{"example.com/tier": "backend"}, false},
+		{"empty value", map[string]string{"critical": ""}, false},
+
+		// Invalid labels
+		{"empty key", map[string]string{"": "prod"}, true},
+		{"key with space", map[string]string{"my env": "prod"}, true},
+		{"value ends with dash", map[string]string{"env": "prod-"}, true},
+		{"value too long", map[string]string{"env": strings.Repeat("a", 64)}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateLabels(tt.labels)
+			if tt.shouldErr && err == nil {
+				t.Error("expected error but got none")
+			}
+			if !tt.shouldErr && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestValidateComposeFiles(t *testing.T) {
+	tests := []struct {
+		name      string
+		files     map[string]string
+		shouldErr bool
+	}{
+		// Valid files
+		{"none", nil, false},
+		{"top level", map[string]string{"common.yml": "services: {}"}, false},
+		{"subdirectory", map[string]string{"db/compose.yaml": "services: {}"}, false},
+
+		// Invalid files
+		{"absolute", map[string]string{"/etc/compose.yml": ""}, true},
+		{"parent directory", map[string]string{"../other/compose.yml": ""}, true},
+		{"unclean", map[string]string{"./common.yml": ""}, true},
+		{"not yaml", map[string]string{"app.env": ""}, true},
+		{"reserved", map[string]string{"docker-compose.override.yml": ""}, true},
+		{"too large", map[string]string{"big.yml": strings.Repeat("a", 1<<20+1)}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateComposeFiles(tt.files)
+			if tt.shouldErr && err == nil {
+				t.Error("expected error but got none")
+			}
+			if !tt.shouldErr && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestValidateComposeContentWithFiles(t *testing.T) {
+	compose := "include:\n  - extra.yml\nservices:\n  web:\n    image: nginx:latest\n"
+
+	// Included services get the same security checks as the main file
+	unsafe := map[string]string{"extra.yml": "services:\n  backup:\n    image: alpine:latest\n    volumes:\n      - /etc:/host-etc\n"}
+	if err := ValidateComposeContentWithFiles(compose, unsafe, nil); err == nil {
+		t.Error("expected a bind mount of /etc in an included file to be rejected")
+	}
+
+	safe := map[string]string{"extra.yml": "services:\n  backup:\n    image: alpine:latest\n    volumes:\n      - ./backups:/backups\n"}
+	if err := ValidateComposeContentWithFiles(compose, safe, nil); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestValidateComposeContent_StaticAddresses(t *testing.T) {
+	const lan = "networks:\n  lan:\n    ipam:\n      config:\n        - subnet: 172.28.0.0/24\n"
+	tests := []struct {
+		name     string
+		services string
+		networks string
+		wantErr  string
+	}{
+		{"address in subnet", "  dns:\n    image: pihole/pihole:latest\n    networks:\n      lan:\n        ipv4_address: 172.28.0.53\n", lan, ""},
+		{"address outside subnet", "  dns:\n    image: pihole/pihole:latest\n    networks:\n      lan:\n        ipv4_address: 10.0.0.53\n", lan, "outside of the subnets"},
+		{"network without subnet", "  dns:\n    image: pihole/pihole:latest\n    networks:\n      lan:\n        ipv4_address: 172.28.0.53\n", "networks:\n  lan: {}\n", "needs a custom subnet"},
+		{"duplicate address", "  a:\n    image: nginx:latest\n    networks:\n      lan:\n        ipv4_address: 172.28.0.10\n  b:\n    image: nginx:latest\n    networks:\n      lan:\n        ipv4_address: 172.28.0.10\n", lan, "both use address"},
+		{"invalid subnet", "  web:\n    image: nginx:latest\n    networks: [lan]\n", "networks:\n  lan:\n    ipam:\n      config:\n        - subnet: 172.28.0.0/33\n", "invalid subnet"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateComposeContent("services:\n" + tt.services + tt.networks)
+			if tt.wantErr == "" && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Errorf("expected an error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestValidateImageReference(t *testing.T) {
+	tests := []struct {
+		name      string
+		image     string
+		shouldErr bool
+	}{
+		// Valid references
+		{"official image", "nginx", false},
+		{"tagged image", "nginx:1.25-alpine", false},
+		{"registry with port", "registry.local:5000/team/app:v2", false},
+		{"digest pinned", "nginx@sha256:" + strings.Repeat("a", 64), false},
+		{"variable placeholder", "${IMAGE}:latest", false},
+
+		// Invalid references
+		{"uppercase repository", "Nginx:latest", true},
+		{"empty tag", "nginx:", true},
+		{"whitespace", "nginx latest", true},
+		{"bad digest", "nginx@sha256:abc", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateImageReference(tt.image)
+			if tt.shouldErr && err == nil {
+				t.Error("expected error but got none")
+			}
+			if !tt.shouldErr && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestValidateEnvTemplate(t *testing.T) {
+	if err := ValidateEnvTemplate("# comment\nPASSWORD={{ randAlphaNum 32 }}\nURL=https://{{ .BaseDomain }}\n"); err != nil {
+		t.Errorf("ValidateEnvTemplate() error = %v", err)
+	}
+	if err := ValidateEnvTemplate("PASSWORD={{ randAlphaNum 32 }"); err == nil {
+		t.Error("expected an error for an unterminated template")
+	}
+	if err := ValidateEnvTemplate(strings.Repeat("A=b\n", 20000)); err == nil {
+		t.Error("expected an error for an oversized template")
+	}
+	if err := ValidateEnvTemplate("DB_PASSWORD=vault://secret/myapp#password\nAPI_KEY=op://Homelab/API/credential\n"); err != nil {
+		t.Errorf("ValidateEnvTemplate() error = %v", err)
+	}
+	if err := ValidateEnvTemplate("DB_PASSWORD=vault://secret/myapp\n"); err == nil {
+		t.Error("expected an error for a secret reference without a key")
+	}
+}
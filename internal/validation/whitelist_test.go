@@ -3,6 +3,8 @@ package validation
 import (
 	"strings"
 	"testing"
+
+	"github.com/selfhostly/internal/constants"
 )
 
 func TestVolumeWhitelist(t *testing.T) {
@@ -217,3 +219,86 @@ services:
 		}
 	})
 }
+
+func TestBindMountPolicy(t *testing.T) {
+	compose := func(volumes string) string {
+		return "services:\n  app:\n    image: nginx\n    volumes:\n" + volumes
+	}
+
+	tests := []struct {
+		name           string
+		composeContent string
+		config         SecurityConfig
+		errorContains  string // empty when validation should pass
+	}{
+		{
+			name:           "allowlist permits the app directory",
+			composeContent: compose("      - ./data:/data\n      - cache:/cache\nvolumes:\n  cache: {}\n"),
+			config:         SecurityConfig{BindMountPolicy: constants.BindMountPolicyAllowlist},
+		},
+		{
+			name:           "allowlist permits allowed paths",
+			composeContent: compose("      - /mnt/media/movies:/movies\n"),
+			config:         SecurityConfig{BindMountPolicy: constants.BindMountPolicyAllowlist, AllowedVolumePaths: []string{"/mnt/media"}},
+		},
+		{
+			name:           "allowlist refuses other host paths",
+			composeContent: compose("      - /opt/other:/data\n"),
+			config:         SecurityConfig{BindMountPolicy: constants.BindMountPolicyAllowlist, AllowedVolumePaths: []string{"/mnt/media"}},
+			errorContains:  "BIND_MOUNT_POLICY=allowlist",
+		},
+		{
+			name:           "allowlist refuses climbing out of the app directory",
+			composeContent: compose("      - ../other-app/data:/data\n"),
+			config:         SecurityConfig{BindMountPolicy: constants.BindMountPolicyAllowlist},
+			errorContains:  "must stay inside the app directory",
+		},
+		{
+			name:           "denylist still permits other host paths",
+			composeContent: compose("      - /opt/other:/data\n"),
+			config:         SecurityConfig{BindMountPolicy: constants.BindMountPolicyDenylist},
+		},
+		{
+			name:           "protected mount allowed when listed exactly",
+			composeContent: compose("      - /var/run/docker.sock:/var/run/docker.sock:ro\n"),
+			config:         SecurityConfig{BindMountPolicy: constants.BindMountPolicyAllowlist, AllowedProtectedMounts: []string{"/var/run/docker.sock"}},
+		},
+		{
+			name:           "protected mount not opened by a parent entry",
+			composeContent: compose("      - /etc/ssl/certs:/certs:ro\n"),
+			config:         SecurityConfig{AllowedProtectedMounts: []string{"/etc"}},
+			errorContains:  "mounting paths under \"/etc\" is not allowed",
+		},
+		{
+			name:           "root filesystem refused",
+			composeContent: compose("      - /:/host\n"),
+			config:         SecurityConfig{AllowedVolumePaths: []string{"/"}},
+			errorContains:  "grants access to entire host filesystem",
+		},
+		{
+			name:           "named volume bound to a protected path refused",
+			composeContent: compose("      - certs:/certs\nvolumes:\n  certs:\n    driver: local\n    driver_opts:\n      type: none\n      o: bind\n      device: /etc/ssl\n"),
+			errorContains:  "volume \"certs\": mounting paths under \"/etc\"",
+		},
+		{
+			name:           "named volume bound outside the allowlist refused",
+			composeContent: compose("      - media:/media\nvolumes:\n  media:\n    driver_opts:\n      o: bind\n      type: none\n      device: /srv/media\n"),
+			config:         SecurityConfig{BindMountPolicy: constants.BindMountPolicyAllowlist},
+			errorContains:  "volume \"media\"",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := tt.config
+			err := ValidateComposeContentWithConfig(tt.composeContent, &config)
+			if tt.errorContains == "" {
+				if err != nil {
+					t.Errorf("expected validation to pass, but got error: %v", err)
+				}
+			} else if err == nil || !strings.Contains(err.Error(), tt.errorContains) {
+				t.Errorf("expected error to contain %q, but got: %v", tt.errorContains, err)
+			}
+		})
+	}
+}
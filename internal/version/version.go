@@ -0,0 +1,71 @@
+// Package version reports the build version of the server and gateway binaries.
+package version
+
+import (
+	"fmt"
+	"net/http"
+	"runtime/debug"
+	"strconv"
+)
+
+// Version is set at build time with
+// -ldflags "-X github.com/selfhostly/internal/version.Version=v1.2.3"
+var Version = ""
+
+// Get returns the build version, falling back to the module version recorded by the Go
+// toolchain and then to "dev" for local builds
+func Get() string {
+	if Version != "" {
+		return Version
+	}
+	if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "" && info.Main.Version != "(devel)" {
+		return info.Main.Version
+	}
+	return "dev"
+}
+
+// APIVersion is the version of the API nodes use to talk to each other and of the database
+// schema behind it. Bump it when a change would break a node still running the previous build.
+const APIVersion = 1
+
+// MinAPIVersion is the oldest API version of another node this build can safely send writes to
+const MinAPIVersion = 1
+
+// Headers carrying the sender's versions on heartbeats and health checks
+const (
+	Header    = "X-Selfhostly-Version"
+	APIHeader = "X-Selfhostly-API-Version"
+)
+
+// SetHeaders stamps this build's versions on h
+func SetHeaders(h http.Header) {
+	h.Set(Header, Get())
+	h.Set(APIHeader, strconv.Itoa(APIVersion))
+}
+
+// FromHeaders reads the versions a peer stamped on h; apiVersion is 0 when it sent none
+func FromHeaders(h http.Header) (version string, apiVersion int) {
+	apiVersion, _ = strconv.Atoi(h.Get(APIHeader))
+	return h.Get(Header), apiVersion
+}
+
+// Compatible reports whether a node at apiVersion can safely take writes from this build. Nodes
+// that never reported a version (0) predate version reporting and are not.
+func Compatible(apiVersion int) bool {
+	return apiVersion >= MinAPIVersion && apiVersion <= APIVersion
+}
+
+// SkewWarning explains why a node at nodeVersion/apiVersion is incompatible with this build, or
+// returns "" when it is compatible
+func SkewWarning(nodeVersion string, apiVersion int) string {
+	switch {
+	case Compatible(apiVersion):
+		return ""
+	case apiVersion == 0:
+		return fmt.Sprintf("node has not reported its version; upgrade it to %s", Get())
+	case apiVersion < MinAPIVersion:
+		return fmt.Sprintf("node runs %s (API v%d), older than this build supports (API v%d); upgrade it to %s", nodeVersion, apiVersion, MinAPIVersion, Get())
+	default:
+		return fmt.Sprintf("node runs %s (API v%d), newer than this build (API v%d); upgrade this node to match", nodeVersion, apiVersion, APIVersion)
+	}
+}
@@ -0,0 +1,40 @@
+package version
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestHeaders(t *testing.T) {
+	h := http.Header{}
+	SetHeaders(h)
+	v, api := FromHeaders(h)
+	if v != Get() || api != APIVersion {
+		t.Errorf("expected %s/%d from headers, got %s/%d", Get(), APIVersion, v, api)
+	}
+
+	if _, api := FromHeaders(http.Header{}); api != 0 {
+		t.Errorf("expected API version 0 without headers, got %d", api)
+	}
+}
+
+func TestSkewWarning(t *testing.T) {
+	tests := []struct {
+		apiVersion int
+		want       string
+	}{
+		{APIVersion, ""},
+		{0, "has not reported"},
+		{APIVersion + 1, "newer than this build"},
+	}
+	for _, tt := range tests {
+		got := SkewWarning("v9.9.9", tt.apiVersion)
+		if (tt.want == "") != (got == "") || !strings.Contains(got, tt.want) {
+			t.Errorf("SkewWarning(API v%d) = %q, want it to contain %q", tt.apiVersion, got, tt.want)
+		}
+		if Compatible(tt.apiVersion) != (tt.want == "") {
+			t.Errorf("Compatible(%d) disagrees with SkewWarning", tt.apiVersion)
+		}
+	}
+}
@@ -0,0 +1,427 @@
+// Package webhook delivers signed JSON payloads to per-app webhook URLs when app lifecycle
+// events (start, stop, update, crash, task_failed) occur, and node alerts to the alerts webhook.
+// Webhooks can batch non-critical events into hourly or daily digests and hold them during quiet
+// hours; critical events are always sent as they happen.
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/selfhostly/internal/constants"
+	"github.com/selfhostly/internal/db"
+	"github.com/selfhostly/internal/events"
+)
+
+// Headers sent with every delivery
+const (
+	HeaderEvent     = "X-Selfhostly-Event"
+	HeaderDelivery  = "X-Selfhostly-Delivery"
+	HeaderTimestamp = "X-Selfhostly-Timestamp"
+	HeaderSignature = "X-Selfhostly-Signature"
+)
+
+// Events lists the lifecycle events a webhook can subscribe to
+var Events = []string{
+	constants.WebhookEventStart,
+	constants.WebhookEventStop,
+	constants.WebhookEventUpdate,
+	constants.WebhookEventCrash,
+	constants.WebhookEventTaskFailed,
+}
+
+// CriticalEvents are sent as they happen, even to webhooks in digest mode or quiet hours
+var CriticalEvents = []string{
+	constants.WebhookEventCrash,
+	constants.WebhookEventTaskFailed,
+}
+
+// DigestIntervals maps each digest mode to how long a webhook batches events before sending them
+var DigestIntervals = map[string]time.Duration{
+	constants.WebhookDigestHourly: time.Hour,
+	constants.WebhookDigestDaily:  24 * time.Hour,
+}
+
+// QuietHoursLayout is the HH:MM layout of a webhook's quiet hours
+const QuietHoursLayout = "15:04"
+
+// Payload is the JSON body of a delivery
+type Payload struct {
+	ID        string    `json:"id"` // Delivery ID, unchanged across retries so receivers can dedupe
+	Event     string    `json:"event"`
+	Timestamp time.Time `json:"timestamp"`
+	NodeID    string    `json:"node_id"`
+	App       App       `json:"app"`
+	Message   string    `json:"message,omitempty"`
+}
+
+// App describes the app an event happened to
+type App struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	Status    string `json:"status"`
+	PublicURL string `json:"public_url,omitempty"`
+}
+
+// NewPayload builds the payload for an event on app
+func NewPayload(app *db.App, nodeID, event, message string) Payload {
+	return Payload{
+		ID:        uuid.New().String(),
+		Event:     event,
+		Timestamp: time.Now().UTC(),
+		NodeID:    nodeID,
+		App: App{
+			ID:        app.ID,
+			Name:      app.Name,
+			Status:    app.Status,
+			PublicURL: app.PublicURL,
+		},
+		Message: message,
+	}
+}
+
+// DigestPayload is the JSON body of a digest delivery, with the held events oldest first
+type DigestPayload struct {
+	ID        string    `json:"id"`
+	Event     string    `json:"event"` // Always digest
+	Timestamp time.Time `json:"timestamp"`
+	NodeID    string    `json:"node_id"`
+	Events    []Payload `json:"events"`
+}
+
+// AlertPayload is the JSON body of a node alert delivery
+type AlertPayload struct {
+	ID        string        `json:"id"`
+	Event     string        `json:"event"` // node_alert.firing or node_alert.resolved
+	Timestamp time.Time     `json:"timestamp"`
+	Alert     *db.NodeAlert `json:"alert"`
+	NodeName  string        `json:"node_name"`
+	Message   string        `json:"message"`
+}
+
+// NewAlertPayload builds the payload for an alert on the node named nodeName
+func NewAlertPayload(alert *db.NodeAlert, nodeName, event, message string) AlertPayload {
+	return AlertPayload{
+		ID:        uuid.New().String(),
+		Event:     event,
+		Timestamp: time.Now().UTC(),
+		Alert:     alert,
+		NodeName:  nodeName,
+		Message:   message,
+	}
+}
+
+// GenerateSecret returns a random signing secret
+func GenerateSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate webhook secret: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// Sign returns the X-Selfhostly-Signature value for body sent at timestamp (unix seconds):
+// "sha256=" followed by the hex HMAC-SHA256 of "<timestamp>.<body>" keyed with secret
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify reports whether signature matches body and timestamp, for receivers written in Go
+func Verify(secret string, timestamp int64, body []byte, signature string) bool {
+	return hmac.Equal([]byte(Sign(secret, timestamp, body)), []byte(signature))
+}
+
+// InQuietHours reports whether t falls in the webhook's quiet hours, which wrap past midnight when
+// they end earlier in the day than they start (e.g. 22:00 to 07:00)
+func InQuietHours(webhook *db.AppWebhook, t time.Time) bool {
+	start, err := time.Parse(QuietHoursLayout, webhook.QuietHoursStart)
+	if err != nil {
+		return false
+	}
+	end, err := time.Parse(QuietHoursLayout, webhook.QuietHoursEnd)
+	if err != nil || start.Equal(end) {
+		return false
+	}
+	if location, err := time.LoadLocation(webhook.Timezone); err == nil {
+		t = t.In(location)
+	}
+
+	minute := t.Hour()*60 + t.Minute()
+	from, to := start.Hour()*60+start.Minute(), end.Hour()*60+end.Minute()
+	if from < to {
+		return minute >= from && minute < to
+	}
+	return minute >= from || minute < to
+}
+
+// Holds reports whether webhook holds event back at t, rather than sending it right away
+func Holds(webhook *db.AppWebhook, event string, t time.Time) bool {
+	if slices.Contains(CriticalEvents, event) {
+		return false
+	}
+	return webhook.Digest != "" || InQuietHours(webhook, t)
+}
+
+// Dispatcher sends webhook deliveries and records their outcome on the webhook
+type Dispatcher struct {
+	database   *db.DB
+	nodeID     string
+	httpClient *http.Client
+	backoff    time.Duration
+	logger     *slog.Logger
+}
+
+// NewDispatcher creates a dispatcher that stamps payloads with nodeID
+func NewDispatcher(database *db.DB, nodeID string, logger *slog.Logger) *Dispatcher {
+	return &Dispatcher{
+		database:   database,
+		nodeID:     nodeID,
+		httpClient: &http.Client{Timeout: constants.WebhookDeliveryTimeout},
+		backoff:    constants.WebhookRetryBackoff,
+		logger:     logger,
+	}
+}
+
+// NodeID returns the node ID stamped on payloads
+func (d *Dispatcher) NodeID() string {
+	return d.nodeID
+}
+
+// Fire publishes event to event stream clients and delivers it to each of the app's webhooks
+// subscribed to it, in the background. Webhooks that hold the event back store it for
+// FlushHeldEvents instead. Delivery failures are logged and recorded on the webhook but never
+// returned, so a receiver that is down can't fail the operation that triggered the event. A nil
+// dispatcher does nothing.
+func (d *Dispatcher) Fire(ctx context.Context, app *db.App, event, message string) {
+	if d == nil {
+		return
+	}
+	events.Publish(events.Event{
+		Type:  events.TypeNotification,
+		AppID: app.ID,
+		Data:  events.Notification{Event: event, Subject: app.Name, Message: message},
+	})
+
+	webhooks, err := d.database.GetWebhooksByAppID(app.ID)
+	if err != nil {
+		d.logger.WarnContext(ctx, "failed to load app webhooks", "app", app.Name, "appID", app.ID, "event", event, "error", err)
+		return
+	}
+
+	payload := NewPayload(app, d.nodeID, event, message)
+	ctx = context.WithoutCancel(ctx)
+	for _, w := range webhooks {
+		if !w.Subscribes(event) {
+			continue
+		}
+		if Holds(w, event, payload.Timestamp) {
+			err := d.hold(w, payload)
+			if err == nil {
+				continue
+			}
+			// Sending the event early beats losing it
+			d.logger.WarnContext(ctx, "failed to hold webhook event, sending it now", "webhookID", w.ID, "event", event, "error", err)
+		}
+		go func(w *db.AppWebhook) {
+			_ = d.Deliver(ctx, w, payload)
+		}(w)
+	}
+}
+
+// hold stores payload until webhook's digest is due or its quiet hours end
+func (d *Dispatcher) hold(webhook *db.AppWebhook, payload Payload) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to encode webhook payload: %w", err)
+	}
+	return d.database.HoldWebhookEvent(&db.WebhookHeldEvent{
+		ID:        payload.ID,
+		WebhookID: webhook.ID,
+		Payload:   string(body),
+		CreatedAt: payload.Timestamp,
+	})
+}
+
+// FlushHeldEvents sends the events webhooks hold once they are due: a webhook in digest mode gets
+// them as one digest when its oldest event has waited the digest interval, and any other webhook
+// gets them one by one when its quiet hours end. Nothing is sent during quiet hours. Held events
+// are deleted once their delivery has been attempted, like events sent right away; the events of
+// a webhook that has since been disabled are dropped.
+func (d *Dispatcher) FlushHeldEvents(ctx context.Context, now time.Time) error {
+	webhooks, err := d.database.GetWebhooksWithHeldEvents()
+	if err != nil {
+		return fmt.Errorf("failed to load webhooks with held events: %w", err)
+	}
+
+	for _, w := range webhooks {
+		if w.Enabled && InQuietHours(w, now) {
+			continue
+		}
+		held, err := d.database.GetHeldWebhookEvents(w.ID)
+		if err != nil {
+			return fmt.Errorf("failed to load held events of webhook %s: %w", w.ID, err)
+		}
+		if len(held) == 0 {
+			continue
+		}
+		if interval, ok := DigestIntervals[w.Digest]; ok && w.Enabled && now.Sub(held[0].CreatedAt) < interval {
+			continue
+		}
+
+		ids := make([]string, 0, len(held))
+		payloads := make([]Payload, 0, len(held))
+		for _, event := range held {
+			ids = append(ids, event.ID)
+			var payload Payload
+			if err := json.Unmarshal([]byte(event.Payload), &payload); err != nil {
+				d.logger.WarnContext(ctx, "dropping unreadable held webhook event", "webhookID", w.ID, "eventID", event.ID, "error", err)
+				continue
+			}
+			payloads = append(payloads, payload)
+		}
+
+		if w.Enabled && len(payloads) > 0 {
+			if w.Digest != "" {
+				_ = d.DeliverDigest(ctx, w, payloads)
+			} else {
+				for _, payload := range payloads {
+					_ = d.Deliver(ctx, w, payload)
+				}
+			}
+		}
+		if err := d.database.DeleteHeldWebhookEvents(ids); err != nil {
+			return fmt.Errorf("failed to delete held events of webhook %s: %w", w.ID, err)
+		}
+	}
+	return nil
+}
+
+// Deliver sends payload to webhook, retrying failed attempts with backoff, and records the final
+// outcome. It returns the error of the last attempt.
+func (d *Dispatcher) Deliver(ctx context.Context, webhook *db.AppWebhook, payload Payload) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to encode webhook payload: %w", err)
+	}
+	return d.deliverRecorded(ctx, webhook, payload.Event, payload.ID, body)
+}
+
+// DeliverDigest sends payloads to webhook as one digest delivery, like Deliver
+func (d *Dispatcher) DeliverDigest(ctx context.Context, webhook *db.AppWebhook, payloads []Payload) error {
+	digest := DigestPayload{
+		ID:        uuid.New().String(),
+		Event:     constants.WebhookEventDigest,
+		Timestamp: time.Now().UTC(),
+		NodeID:    d.nodeID,
+		Events:    payloads,
+	}
+	body, err := json.Marshal(digest)
+	if err != nil {
+		return fmt.Errorf("failed to encode webhook digest: %w", err)
+	}
+	return d.deliverRecorded(ctx, webhook, digest.Event, digest.ID, body)
+}
+
+// deliverRecorded sends body to webhook and records the outcome on it
+func (d *Dispatcher) deliverRecorded(ctx context.Context, webhook *db.AppWebhook, event, deliveryID string, body []byte) error {
+	logger := d.logger.With("webhookID", webhook.ID, "appID", webhook.AppID)
+	status, err := d.deliver(ctx, logger, webhook.URL, webhook.Secret, event, deliveryID, body)
+
+	var deliveryError *string
+	if err != nil {
+		msg := err.Error()
+		deliveryError = &msg
+	}
+	if recordErr := d.database.RecordWebhookDelivery(webhook.ID, status, deliveryError, time.Now()); recordErr != nil {
+		d.logger.WarnContext(ctx, "failed to record webhook delivery", "webhookID", webhook.ID, "error", recordErr)
+	}
+	return err
+}
+
+// DeliverAlert sends a node alert to url, signed with secret, retrying failed attempts with
+// backoff. There is no webhook record to update; failures are logged and returned.
+func (d *Dispatcher) DeliverAlert(ctx context.Context, url, secret string, payload AlertPayload) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to encode alert payload: %w", err)
+	}
+	logger := d.logger.With("alertID", payload.Alert.ID, "nodeID", payload.Alert.NodeID)
+	_, err = d.deliver(ctx, logger, url, secret, payload.Event, payload.ID, body)
+	return err
+}
+
+// deliver posts body to url until an attempt succeeds or WebhookMaxAttempts have failed, and
+// returns the status and error of the last attempt
+func (d *Dispatcher) deliver(ctx context.Context, logger *slog.Logger, url, secret, event, deliveryID string, body []byte) (int, error) {
+	backoff := d.backoff
+	for attempt := 1; ; attempt++ {
+		status, err := d.send(ctx, url, secret, event, deliveryID, body)
+		if err == nil {
+			logger.DebugContext(ctx, "webhook delivered", "event", event, "status", status)
+			return status, nil
+		}
+		logger.WarnContext(ctx, "webhook delivery failed", "event", event, "attempt", attempt, "status", status, "error", err)
+		if attempt == constants.WebhookMaxAttempts || !wait(ctx, backoff) {
+			return status, err
+		}
+		backoff *= 2
+	}
+}
+
+// send makes a single delivery attempt, treating any non-2xx response as a failure
+func (d *Dispatcher) send(ctx context.Context, url, secret, event, deliveryID string, body []byte) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return 0, fmt.Errorf("failed to create webhook request: %w", err)
+	}
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "selfhostly-webhook")
+	req.Header.Set(HeaderEvent, event)
+	req.Header.Set(HeaderDelivery, deliveryID)
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(timestamp, 10))
+	if secret != "" {
+		req.Header.Set(HeaderSignature, Sign(secret, timestamp, body))
+	}
+
+	resp, err := d.httpClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("receiver returned status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// wait sleeps for d, returning false if ctx is cancelled first
+func wait(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
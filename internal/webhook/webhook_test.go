@@ -0,0 +1,323 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/selfhostly/internal/constants"
+	"github.com/selfhostly/internal/db"
+)
+
+func setupDispatcher(t *testing.T) (*Dispatcher, *db.DB, *db.App) {
+	t.Helper()
+	database, err := db.Init(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatalf("Failed to initialize database: %v", err)
+	}
+	t.Cleanup(func() { database.Close() })
+
+	app := db.NewApp("hooked-app", "", "services:\n  web:\n    image: nginx\n")
+	if err := database.CreateApp(app); err != nil {
+		t.Fatalf("Failed to create app: %v", err)
+	}
+
+	d := NewDispatcher(database, "node-1", slog.Default())
+	d.backoff = time.Millisecond
+	return d, database, app
+}
+
+func TestSignAndVerify(t *testing.T) {
+	body := []byte(`{"event":"start"}`)
+	sig := Sign("secret", 1700000000, body)
+
+	if !Verify("secret", 1700000000, body, sig) {
+		t.Error("Expected signature to verify")
+	}
+	if Verify("other", 1700000000, body, sig) {
+		t.Error("Expected signature with a different secret to fail")
+	}
+	if Verify("secret", 1700000001, body, sig) {
+		t.Error("Expected signature with a different timestamp to fail")
+	}
+	if Verify("secret", 1700000000, []byte(`{"event":"stop"}`), sig) {
+		t.Error("Expected signature over a different body to fail")
+	}
+}
+
+func TestDeliver_SignsPayloadAndRecordsSuccess(t *testing.T) {
+	d, database, app := setupDispatcher(t)
+
+	var received Payload
+	var verified bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		ts, _ := strconv.ParseInt(r.Header.Get(HeaderTimestamp), 10, 64)
+		verified = Verify("s3cret", ts, body, r.Header.Get(HeaderSignature))
+		_ = json.Unmarshal(body, &received)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	hook := db.NewAppWebhook(app.ID, server.URL, "s3cret", []string{constants.WebhookEventStart})
+	if err := database.CreateWebhook(hook); err != nil {
+		t.Fatalf("CreateWebhook: %v", err)
+	}
+
+	payload := NewPayload(app, "node-1", constants.WebhookEventStart, "")
+	if err := d.Deliver(context.Background(), hook, payload); err != nil {
+		t.Fatalf("Deliver: %v", err)
+	}
+
+	if !verified {
+		t.Error("Expected receiver to verify the signature")
+	}
+	if received.Event != constants.WebhookEventStart || received.App.ID != app.ID || received.NodeID != "node-1" {
+		t.Errorf("Unexpected payload: %+v", received)
+	}
+
+	stored, err := database.GetWebhook(app.ID, hook.ID)
+	if err != nil {
+		t.Fatalf("GetWebhook: %v", err)
+	}
+	if stored.LastStatus != http.StatusNoContent || stored.LastError != nil || stored.LastDeliveredAt == nil {
+		t.Errorf("Expected successful delivery to be recorded, got status=%d error=%v", stored.LastStatus, stored.LastError)
+	}
+}
+
+func TestDeliver_RetriesThenRecordsFailure(t *testing.T) {
+	d, database, app := setupDispatcher(t)
+
+	var attempts atomic.Int32
+	deliveryIDs := make(map[string]bool)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		attempts.Add(1)
+		deliveryIDs[r.Header.Get(HeaderDelivery)] = true
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	hook := db.NewAppWebhook(app.ID, server.URL, "s3cret", []string{constants.WebhookEventCrash})
+	if err := database.CreateWebhook(hook); err != nil {
+		t.Fatalf("CreateWebhook: %v", err)
+	}
+
+	err := d.Deliver(context.Background(), hook, NewPayload(app, "node-1", constants.WebhookEventCrash, ""))
+	if err == nil {
+		t.Fatal("Expected delivery to fail")
+	}
+	if got := attempts.Load(); got != constants.WebhookMaxAttempts {
+		t.Errorf("Expected %d attempts, got %d", constants.WebhookMaxAttempts, got)
+	}
+	if len(deliveryIDs) != 1 {
+		t.Errorf("Expected retries to reuse the delivery ID, got %d IDs", len(deliveryIDs))
+	}
+
+	stored, err := database.GetWebhook(app.ID, hook.ID)
+	if err != nil {
+		t.Fatalf("GetWebhook: %v", err)
+	}
+	if stored.LastStatus != http.StatusBadGateway || stored.LastError == nil {
+		t.Errorf("Expected failed delivery to be recorded, got status=%d error=%v", stored.LastStatus, stored.LastError)
+	}
+}
+
+func TestFire_OnlySubscribedEnabledWebhooks(t *testing.T) {
+	d, database, app := setupDispatcher(t)
+
+	events := make(chan string, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		events <- r.URL.Path
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	subscribed := db.NewAppWebhook(app.ID, server.URL+"/subscribed", "a", []string{constants.WebhookEventStop})
+	other := db.NewAppWebhook(app.ID, server.URL+"/other-event", "b", []string{constants.WebhookEventStart})
+	disabled := db.NewAppWebhook(app.ID, server.URL+"/disabled", "c", []string{constants.WebhookEventStop})
+	disabled.Enabled = false
+	for _, h := range []*db.AppWebhook{subscribed, other, disabled} {
+		if err := database.CreateWebhook(h); err != nil {
+			t.Fatalf("CreateWebhook: %v", err)
+		}
+	}
+
+	d.Fire(context.Background(), app, constants.WebhookEventStop, "")
+
+	select {
+	case path := <-events:
+		if path != "/subscribed" {
+			t.Errorf("Expected delivery to /subscribed, got %s", path)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected a delivery")
+	}
+	select {
+	case path := <-events:
+		t.Errorf("Unexpected delivery to %s", path)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestInQuietHours(t *testing.T) {
+	at := func(clock string) time.Time {
+		t, _ := time.Parse(time.RFC3339, "2026-01-01T"+clock+":00Z")
+		return t
+	}
+	tests := []struct {
+		name       string
+		start, end string
+		timezone   string
+		t          time.Time
+		want       bool
+	}{
+		{"inside same-day window", "09:00", "17:00", "UTC", at("12:00"), true},
+		{"end is exclusive", "09:00", "17:00", "UTC", at("17:00"), false},
+		{"before midnight in wrapping window", "22:00", "07:00", "UTC", at("23:30"), true},
+		{"after midnight in wrapping window", "22:00", "07:00", "UTC", at("03:00"), true},
+		{"outside wrapping window", "22:00", "07:00", "UTC", at("12:00"), false},
+		{"in the webhook's timezone", "22:00", "07:00", "America/New_York", at("04:00"), true}, // 23:00 in New York
+		{"no quiet hours", "", "", "UTC", at("03:00"), false},
+		{"empty window", "03:00", "03:00", "UTC", at("03:00"), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hook := &db.AppWebhook{QuietHoursStart: tt.start, QuietHoursEnd: tt.end, Timezone: tt.timezone}
+			if got := InQuietHours(hook, tt.t); got != tt.want {
+				t.Errorf("InQuietHours = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFire_HoldsNonCriticalEventsForDigest(t *testing.T) {
+	d, database, app := setupDispatcher(t)
+
+	var received []DigestPayload
+	var mu sync.Mutex
+	events := make(chan string, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var digest DigestPayload
+		_ = json.NewDecoder(r.Body).Decode(&digest)
+		mu.Lock()
+		received = append(received, digest)
+		mu.Unlock()
+		events <- r.Header.Get(HeaderEvent)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	hook := db.NewAppWebhook(app.ID, server.URL, "s3cret", []string{constants.WebhookEventStop, constants.WebhookEventUpdate, constants.WebhookEventCrash})
+	hook.Digest = constants.WebhookDigestHourly
+	if err := database.CreateWebhook(hook); err != nil {
+		t.Fatalf("CreateWebhook: %v", err)
+	}
+
+	ctx := context.Background()
+	d.Fire(ctx, app, constants.WebhookEventStop, "")
+	d.Fire(ctx, app, constants.WebhookEventUpdate, "")
+	d.Fire(ctx, app, constants.WebhookEventCrash, "")
+
+	// Crashes are critical, so only the crash is sent right away
+	select {
+	case event := <-events:
+		if event != constants.WebhookEventCrash {
+			t.Errorf("Expected the crash to be delivered, got %s", event)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected a delivery")
+	}
+	held, err := database.GetHeldWebhookEvents(hook.ID)
+	if err != nil {
+		t.Fatalf("GetHeldWebhookEvents: %v", err)
+	}
+	if len(held) != 2 {
+		t.Fatalf("Expected 2 held events, got %d", len(held))
+	}
+
+	// Not due until the oldest event has waited an hour
+	if err := d.FlushHeldEvents(ctx, time.Now()); err != nil {
+		t.Fatalf("FlushHeldEvents: %v", err)
+	}
+	select {
+	case event := <-events:
+		t.Fatalf("Unexpected delivery of %s before the digest is due", event)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	if err := d.FlushHeldEvents(ctx, time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("FlushHeldEvents: %v", err)
+	}
+	if event := <-events; event != constants.WebhookEventDigest {
+		t.Fatalf("Expected a digest, got %s", event)
+	}
+	mu.Lock()
+	digest := received[len(received)-1]
+	mu.Unlock()
+	if len(digest.Events) != 2 || digest.Events[0].Event != constants.WebhookEventStop || digest.Events[1].Event != constants.WebhookEventUpdate {
+		t.Errorf("Expected the digest to hold stop then update, got %+v", digest.Events)
+	}
+	if held, _ := database.GetHeldWebhookEvents(hook.ID); len(held) != 0 {
+		t.Errorf("Expected held events to be cleared after the digest, got %d", len(held))
+	}
+}
+
+func TestFlushHeldEvents_WaitsForQuietHoursToEnd(t *testing.T) {
+	d, database, app := setupDispatcher(t)
+
+	events := make(chan string, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		events <- r.Header.Get(HeaderEvent)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	now := time.Now().UTC()
+	hook := db.NewAppWebhook(app.ID, server.URL, "s3cret", []string{constants.WebhookEventUpdate})
+	hook.QuietHoursStart = now.Add(-time.Hour).Format(QuietHoursLayout)
+	hook.QuietHoursEnd = now.Add(time.Hour).Format(QuietHoursLayout)
+	if err := database.CreateWebhook(hook); err != nil {
+		t.Fatalf("CreateWebhook: %v", err)
+	}
+
+	ctx := context.Background()
+	d.Fire(ctx, app, constants.WebhookEventUpdate, "new image")
+	if err := d.FlushHeldEvents(ctx, now); err != nil {
+		t.Fatalf("FlushHeldEvents: %v", err)
+	}
+	select {
+	case event := <-events:
+		t.Fatalf("Unexpected delivery of %s during quiet hours", event)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	// Held events are sent as they were once the window ends
+	if err := d.FlushHeldEvents(ctx, now.Add(2*time.Hour)); err != nil {
+		t.Fatalf("FlushHeldEvents: %v", err)
+	}
+	select {
+	case event := <-events:
+		if event != constants.WebhookEventUpdate {
+			t.Errorf("Expected the held update, got %s", event)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected the held event after quiet hours")
+	}
+	if held, _ := database.GetHeldWebhookEvents(hook.ID); len(held) != 0 {
+		t.Errorf("Expected held events to be cleared, got %d", len(held))
+	}
+}
+
+func TestFire_NilDispatcher(t *testing.T) {
+	var d *Dispatcher
+	d.Fire(context.Background(), &db.App{ID: "x"}, constants.WebhookEventStart, "")
+}
@@ -0,0 +1,507 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// ListApps lists apps across nodes
+func (c *Client) ListApps(ctx context.Context, opts ListAppsOptions) ([]*App, error) {
+	query := nodeIDsQuery(opts.NodeIDs)
+	if opts.Selector != "" {
+		query.Set("selector", opts.Selector)
+	}
+	if opts.Reconcile {
+		query.Set("reconcile", "true")
+	}
+	if len(opts.Fields) > 0 {
+		query.Set("fields", strings.Join(opts.Fields, ","))
+	}
+	var apps []*App
+	err := c.do(ctx, request{method: http.MethodGet, path: "/api/apps", query: query}, &apps)
+	return apps, err
+}
+
+// CreateApp creates an app on req.NodeID, or on the primary when it is empty
+func (c *Client) CreateApp(ctx context.Context, req CreateAppRequest) (*App, error) {
+	var app App
+	if err := c.do(ctx, request{method: http.MethodPost, path: "/api/apps", body: req}, &app); err != nil {
+		return nil, err
+	}
+	return &app, nil
+}
+
+// CreateAppAsync queues the app's creation as a background job on req.NodeID (the primary when
+// it is empty) and returns without waiting for it
+func (c *Client) CreateAppAsync(ctx context.Context, req CreateAppRequest) (*JobAccepted, error) {
+	var accepted JobAccepted
+	query := url.Values{"async": {"true"}}
+	if err := c.do(ctx, request{method: http.MethodPost, path: "/api/apps", query: query, body: req}, &accepted); err != nil {
+		return nil, err
+	}
+	return &accepted, nil
+}
+
+// GetApp returns an app with its schedule
+func (c *Client) GetApp(ctx context.Context, appID, nodeID string) (*App, error) {
+	var app App
+	if err := c.do(ctx, request{method: http.MethodGet, path: appPath(appID, ""), query: nodeQuery(nodeID)}, &app); err != nil {
+		return nil, err
+	}
+	return &app, nil
+}
+
+// UpdateApp changes an app's name, description, compose files or labels. Containers keep
+// running the old compose until UpdateAppContainers.
+func (c *Client) UpdateApp(ctx context.Context, appID, nodeID string, req UpdateAppRequest) (*App, error) {
+	var app App
+	if err := c.do(ctx, request{method: http.MethodPut, path: appPath(appID, ""), query: nodeQuery(nodeID), body: req}, &app); err != nil {
+		return nil, err
+	}
+	return &app, nil
+}
+
+// DeleteApp deletes an app with its containers and tunnel
+func (c *Client) DeleteApp(ctx context.Context, appID, nodeID string, opts DeleteAppOptions) (*DeleteAppResult, error) {
+	query := nodeQuery(nodeID)
+	setBool(query, "archive", opts.Archive)
+	setBool(query, "dry_run", opts.DryRun)
+	setBool(query, "force", opts.Force)
+	setBool(query, "remove_volumes", opts.RemoveVolumes)
+	if len(opts.SkipSteps) > 0 {
+		query.Set("skip", strings.Join(opts.SkipSteps, ","))
+	}
+	var result DeleteAppResult
+	if err := c.do(ctx, request{method: http.MethodDelete, path: appPath(appID, ""), query: query}, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// StartApp starts an app's containers
+func (c *Client) StartApp(ctx context.Context, appID, nodeID string) (*App, error) {
+	var app App
+	if err := c.do(ctx, request{method: http.MethodPost, path: appPath(appID, "/start"), query: nodeQuery(nodeID)}, &app); err != nil {
+		return nil, err
+	}
+	return &app, nil
+}
+
+// StopApp stops an app's containers
+func (c *Client) StopApp(ctx context.Context, appID, nodeID string) (*App, error) {
+	var app App
+	if err := c.do(ctx, request{method: http.MethodPost, path: appPath(appID, "/stop"), query: nodeQuery(nodeID)}, &app); err != nil {
+		return nil, err
+	}
+	return &app, nil
+}
+
+// UpdateAppContainers pulls the app's images and recreates its containers in a background job
+func (c *Client) UpdateAppContainers(ctx context.Context, appID, nodeID string) (*JobAccepted, error) {
+	return c.startJob(ctx, appPath(appID, "/update"), nodeID, nil)
+}
+
+// RunAppCommand runs a one-off command in one of an app's services in a background job. The
+// finished job's Result is a CommandResult with the exit code and output.
+func (c *Client) RunAppCommand(ctx context.Context, appID, nodeID string, req RunAppCommandRequest) (*JobAccepted, error) {
+	return c.startJob(ctx, appPath(appID, "/run"), nodeID, req)
+}
+
+// GetAppLogs returns an app's recent container logs as plain text, for one service or all
+func (c *Client) GetAppLogs(ctx context.Context, appID, nodeID, service string) (string, error) {
+	query := nodeQuery(nodeID)
+	if service != "" {
+		query.Set("service", service)
+	}
+	var logs []byte
+	err := c.do(ctx, request{method: http.MethodGet, path: appPath(appID, "/logs"), query: query}, &logs)
+	return string(logs), err
+}
+
+// ListAppServices returns the names of an app's compose services
+func (c *Client) ListAppServices(ctx context.Context, appID, nodeID string) ([]string, error) {
+	var services []string
+	err := c.do(ctx, request{method: http.MethodGet, path: appPath(appID, "/services"), query: nodeQuery(nodeID)}, &services)
+	return services, err
+}
+
+// RestartAppService restarts one compose service of an app
+func (c *Client) RestartAppService(ctx context.Context, appID, nodeID, service string) error {
+	return c.do(ctx, request{method: http.MethodPost, path: appPath(appID, "/services/"+escape(service)+"/restart"), query: nodeQuery(nodeID)}, nil)
+}
+
+// GetAppStats returns an app's current resource usage
+func (c *Client) GetAppStats(ctx context.Context, appID, nodeID string) (*AppStats, error) {
+	var stats AppStats
+	if err := c.do(ctx, request{method: http.MethodGet, path: appPath(appID, "/stats"), query: nodeQuery(nodeID)}, &stats); err != nil {
+		return nil, err
+	}
+	return &stats, nil
+}
+
+// GetAppContainerHealth returns the exit codes and restart counts of an app's containers, as the
+// crash monitor sees them
+func (c *Client) GetAppContainerHealth(ctx context.Context, appID, nodeID string) (*AppContainerHealth, error) {
+	var health AppContainerHealth
+	if err := c.do(ctx, request{method: http.MethodGet, path: appPath(appID, "/health"), query: nodeQuery(nodeID)}, &health); err != nil {
+		return nil, err
+	}
+	return &health, nil
+}
+
+// GetQuickTunnelURL returns the trycloudflare.com URL of an app's Quick Tunnel
+func (c *Client) GetQuickTunnelURL(ctx context.Context, appID, nodeID string) (string, error) {
+	var resp struct {
+		URL string `json:"url"`
+	}
+	err := c.do(ctx, request{method: http.MethodGet, path: appPath(appID, "/quick-tunnel-url"), query: nodeQuery(nodeID)}, &resp)
+	return resp.URL, err
+}
+
+// CreateQuickTunnel adds a Quick Tunnel to service:port of an app that has no tunnel, in a
+// background job
+func (c *Client) CreateQuickTunnel(ctx context.Context, appID, nodeID, service string, port int) (*JobAccepted, error) {
+	body := map[string]interface{}{"service": service, "port": port}
+	return c.startJob(ctx, appPath(appID, "/quick-tunnel"), nodeID, body)
+}
+
+// GetAppSchedule returns an app's start/stop schedule; nil when it has none
+func (c *Client) GetAppSchedule(ctx context.Context, appID, nodeID string) (*AppSchedule, error) {
+	var schedule *AppSchedule
+	err := c.do(ctx, request{method: http.MethodGet, path: appPath(appID, "/schedule"), query: nodeQuery(nodeID)}, &schedule)
+	return schedule, err
+}
+
+// SetAppSchedule creates or replaces an app's start/stop schedule
+func (c *Client) SetAppSchedule(ctx context.Context, appID, nodeID string, req ScheduleRequest) (*AppSchedule, error) {
+	var schedule AppSchedule
+	if err := c.do(ctx, request{method: http.MethodPost, path: appPath(appID, "/schedule"), query: nodeQuery(nodeID), body: req}, &schedule); err != nil {
+		return nil, err
+	}
+	return &schedule, nil
+}
+
+// DeleteAppSchedule removes an app's schedule
+func (c *Client) DeleteAppSchedule(ctx context.Context, appID, nodeID string) error {
+	return c.do(ctx, request{method: http.MethodDelete, path: appPath(appID, "/schedule"), query: nodeQuery(nodeID)}, nil)
+}
+
+// TestAppSchedule validates cron expressions without saving them and returns when they would
+// next fire
+func (c *Client) TestAppSchedule(ctx context.Context, appID, nodeID string, req ScheduleRequest) (*ScheduleNextRuns, error) {
+	var runs ScheduleNextRuns
+	if err := c.do(ctx, request{method: http.MethodPost, path: appPath(appID, "/schedule/test"), query: nodeQuery(nodeID), body: req}, &runs); err != nil {
+		return nil, err
+	}
+	return &runs, nil
+}
+
+// GetAppScheduleNextRuns returns when an app's saved schedule next starts and stops it
+func (c *Client) GetAppScheduleNextRuns(ctx context.Context, appID, nodeID string) (*ScheduleNextRuns, error) {
+	var runs ScheduleNextRuns
+	if err := c.do(ctx, request{method: http.MethodGet, path: appPath(appID, "/schedule/next-runs"), query: nodeQuery(nodeID)}, &runs); err != nil {
+		return nil, err
+	}
+	return &runs, nil
+}
+
+// ListAppWebhooks lists an app's lifecycle webhooks
+func (c *Client) ListAppWebhooks(ctx context.Context, appID, nodeID string) ([]*AppWebhook, error) {
+	var webhooks []*AppWebhook
+	err := c.do(ctx, request{method: http.MethodGet, path: appPath(appID, "/webhooks"), query: nodeQuery(nodeID)}, &webhooks)
+	return webhooks, err
+}
+
+// CreateAppWebhook adds a lifecycle webhook. The returned webhook carries its signing secret,
+// which later reads leave out.
+func (c *Client) CreateAppWebhook(ctx context.Context, appID, nodeID string, req CreateWebhookRequest) (*AppWebhook, error) {
+	var webhook AppWebhook
+	if err := c.do(ctx, request{method: http.MethodPost, path: appPath(appID, "/webhooks"), query: nodeQuery(nodeID), body: req}, &webhook); err != nil {
+		return nil, err
+	}
+	return &webhook, nil
+}
+
+// UpdateAppWebhook changes a webhook's URL, events or enabled state
+func (c *Client) UpdateAppWebhook(ctx context.Context, appID, nodeID, webhookID string, req UpdateWebhookRequest) (*AppWebhook, error) {
+	var webhook AppWebhook
+	if err := c.do(ctx, request{method: http.MethodPut, path: appPath(appID, "/webhooks/"+escape(webhookID)), query: nodeQuery(nodeID), body: req}, &webhook); err != nil {
+		return nil, err
+	}
+	return &webhook, nil
+}
+
+// DeleteAppWebhook removes a webhook
+func (c *Client) DeleteAppWebhook(ctx context.Context, appID, nodeID, webhookID string) error {
+	return c.do(ctx, request{method: http.MethodDelete, path: appPath(appID, "/webhooks/"+escape(webhookID)), query: nodeQuery(nodeID)}, nil)
+}
+
+// TestAppWebhook sends a test delivery and returns the webhook with its outcome recorded
+func (c *Client) TestAppWebhook(ctx context.Context, appID, nodeID, webhookID string) (*AppWebhook, error) {
+	var webhook AppWebhook
+	if err := c.do(ctx, request{method: http.MethodPost, path: appPath(appID, "/webhooks/"+escape(webhookID)+"/test"), query: nodeQuery(nodeID)}, &webhook); err != nil {
+		return nil, err
+	}
+	return &webhook, nil
+}
+
+// ListAppTasks lists an app's recurring tasks
+func (c *Client) ListAppTasks(ctx context.Context, appID, nodeID string) ([]*AppTask, error) {
+	var tasks []*AppTask
+	err := c.do(ctx, request{method: http.MethodGet, path: appPath(appID, "/tasks"), query: nodeQuery(nodeID)}, &tasks)
+	return tasks, err
+}
+
+// CreateAppTask adds a recurring task to an app
+func (c *Client) CreateAppTask(ctx context.Context, appID, nodeID string, req CreateTaskRequest) (*AppTask, error) {
+	var task AppTask
+	if err := c.do(ctx, request{method: http.MethodPost, path: appPath(appID, "/tasks"), query: nodeQuery(nodeID), body: req}, &task); err != nil {
+		return nil, err
+	}
+	return &task, nil
+}
+
+// UpdateAppTask changes a recurring task's command, schedule or enabled state
+func (c *Client) UpdateAppTask(ctx context.Context, appID, nodeID, taskID string, req UpdateTaskRequest) (*AppTask, error) {
+	var task AppTask
+	if err := c.do(ctx, request{method: http.MethodPut, path: appPath(appID, "/tasks/"+escape(taskID)), query: nodeQuery(nodeID), body: req}, &task); err != nil {
+		return nil, err
+	}
+	return &task, nil
+}
+
+// DeleteAppTask removes a recurring task and its run history
+func (c *Client) DeleteAppTask(ctx context.Context, appID, nodeID, taskID string) error {
+	return c.do(ctx, request{method: http.MethodDelete, path: appPath(appID, "/tasks/"+escape(taskID)), query: nodeQuery(nodeID)}, nil)
+}
+
+// ListAppTaskRuns returns a recurring task's recent runs, newest first
+func (c *Client) ListAppTaskRuns(ctx context.Context, appID, nodeID, taskID string) ([]*AppTaskRun, error) {
+	var runs []*AppTaskRun
+	err := c.do(ctx, request{method: http.MethodGet, path: appPath(appID, "/tasks/"+escape(taskID)+"/runs"), query: nodeQuery(nodeID)}, &runs)
+	return runs, err
+}
+
+// RunAppTask runs a recurring task now, in a background job
+func (c *Client) RunAppTask(ctx context.Context, appID, nodeID, taskID string) (*JobAccepted, error) {
+	return c.startJob(ctx, appPath(appID, "/tasks/"+escape(taskID)+"/run"), nodeID, nil)
+}
+
+// ListComposeVersions lists an app's compose versions, newest first
+func (c *Client) ListComposeVersions(ctx context.Context, appID, nodeID string) ([]*ComposeVersion, error) {
+	var versions []*ComposeVersion
+	err := c.do(ctx, request{method: http.MethodGet, path: appPath(appID, "/compose/versions"), query: nodeQuery(nodeID)}, &versions)
+	return versions, err
+}
+
+// GetComposeVersion returns one compose version of an app
+func (c *Client) GetComposeVersion(ctx context.Context, appID, nodeID string, version int) (*ComposeVersion, error) {
+	var v ComposeVersion
+	if err := c.do(ctx, request{method: http.MethodGet, path: appPath(appID, "/compose/versions/"+strconv.Itoa(version)), query: nodeQuery(nodeID)}, &v); err != nil {
+		return nil, err
+	}
+	return &v, nil
+}
+
+// GetComposeIntegrity reports whether an app's docker-compose.yml was edited on disk outside of its versions
+func (c *Client) GetComposeIntegrity(ctx context.Context, appID, nodeID string) (*ComposeIntegrity, error) {
+	var integrity ComposeIntegrity
+	if err := c.do(ctx, request{method: http.MethodGet, path: appPath(appID, "/compose/integrity"), query: nodeQuery(nodeID)}, &integrity); err != nil {
+		return nil, err
+	}
+	return &integrity, nil
+}
+
+// RestoreComposeFile rewrites an app's edited docker-compose.yml from its current version
+func (c *Client) RestoreComposeFile(ctx context.Context, appID, nodeID string) (*ComposeIntegrity, error) {
+	var integrity ComposeIntegrity
+	if err := c.do(ctx, request{method: http.MethodPost, path: appPath(appID, "/compose/restore"), query: nodeQuery(nodeID)}, &integrity); err != nil {
+		return nil, err
+	}
+	return &integrity, nil
+}
+
+// RollbackApp restores a compose version as a new version
+func (c *Client) RollbackApp(ctx context.Context, appID, nodeID string, version int, req RollbackRequest) (*RollbackResult, error) {
+	var result RollbackResult
+	if err := c.do(ctx, request{method: http.MethodPost, path: appPath(appID, "/compose/rollback/"+strconv.Itoa(version)), query: nodeQuery(nodeID), body: req}, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// ListAppSnapshots returns an app's snapshots, newest first
+func (c *Client) ListAppSnapshots(ctx context.Context, appID, nodeID string) ([]*AppSnapshot, error) {
+	var snapshots []*AppSnapshot
+	err := c.do(ctx, request{method: http.MethodGet, path: appPath(appID, "/snapshots"), query: nodeQuery(nodeID)}, &snapshots)
+	return snapshots, err
+}
+
+// CreateAppSnapshot captures an app's compose files, .env and image digests under a name
+func (c *Client) CreateAppSnapshot(ctx context.Context, appID, nodeID string, req CreateSnapshotRequest) (*AppSnapshot, error) {
+	var snapshot AppSnapshot
+	if err := c.do(ctx, request{method: http.MethodPost, path: appPath(appID, "/snapshots"), query: nodeQuery(nodeID), body: req}, &snapshot); err != nil {
+		return nil, err
+	}
+	return &snapshot, nil
+}
+
+// GetAppSnapshot returns one of an app's snapshots
+func (c *Client) GetAppSnapshot(ctx context.Context, appID, nodeID, snapshotID string) (*AppSnapshot, error) {
+	var snapshot AppSnapshot
+	if err := c.do(ctx, request{method: http.MethodGet, path: appPath(appID, "/snapshots/"+escape(snapshotID)), query: nodeQuery(nodeID)}, &snapshot); err != nil {
+		return nil, err
+	}
+	return &snapshot, nil
+}
+
+// DeleteAppSnapshot removes one of an app's snapshots
+func (c *Client) DeleteAppSnapshot(ctx context.Context, appID, nodeID, snapshotID string) error {
+	return c.do(ctx, request{method: http.MethodDelete, path: appPath(appID, "/snapshots/"+escape(snapshotID)), query: nodeQuery(nodeID)}, nil)
+}
+
+// RestoreAppSnapshot restores a snapshot as a new compose version with its images pinned
+func (c *Client) RestoreAppSnapshot(ctx context.Context, appID, nodeID, snapshotID string, req RestoreSnapshotRequest) (*RestoreSnapshotResult, error) {
+	var result RestoreSnapshotResult
+	if err := c.do(ctx, request{method: http.MethodPost, path: appPath(appID, "/snapshots/"+escape(snapshotID)+"/restore"), query: nodeQuery(nodeID), body: req}, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// GetAppLogForwarding returns whether an app's container logs are forwarded
+func (c *Client) GetAppLogForwarding(ctx context.Context, appID, nodeID string) (*AppLogForwarding, error) {
+	var forwarding AppLogForwarding
+	if err := c.do(ctx, request{method: http.MethodGet, path: appPath(appID, "/log-forwarding"), query: nodeQuery(nodeID)}, &forwarding); err != nil {
+		return nil, err
+	}
+	return &forwarding, nil
+}
+
+// SetAppLogForwarding turns forwarding of an app's container logs on or off
+func (c *Client) SetAppLogForwarding(ctx context.Context, appID, nodeID string, enabled bool) (*AppLogForwarding, error) {
+	var forwarding AppLogForwarding
+	body := SetLogForwardingRequest{Enabled: &enabled}
+	if err := c.do(ctx, request{method: http.MethodPut, path: appPath(appID, "/log-forwarding"), query: nodeQuery(nodeID), body: body}, &forwarding); err != nil {
+		return nil, err
+	}
+	return &forwarding, nil
+}
+
+// GetAppStatusPage returns an app's status page; its link is only returned when it is enabled
+func (c *Client) GetAppStatusPage(ctx context.Context, appID, nodeID string) (*AppStatusPage, error) {
+	var page AppStatusPage
+	if err := c.do(ctx, request{method: http.MethodGet, path: appPath(appID, "/status-page"), query: nodeQuery(nodeID)}, &page); err != nil {
+		return nil, err
+	}
+	return &page, nil
+}
+
+// EnableAppStatusPage gives an app a public status page, or a new link that replaces the old one
+func (c *Client) EnableAppStatusPage(ctx context.Context, appID, nodeID string) (*IssuedStatusPage, error) {
+	var page IssuedStatusPage
+	if err := c.do(ctx, request{method: http.MethodPost, path: appPath(appID, "/status-page"), query: nodeQuery(nodeID)}, &page); err != nil {
+		return nil, err
+	}
+	return &page, nil
+}
+
+// DisableAppStatusPage deletes an app's status page, so its link stops working
+func (c *Client) DisableAppStatusPage(ctx context.Context, appID, nodeID string) error {
+	return c.do(ctx, request{method: http.MethodDelete, path: appPath(appID, "/status-page"), query: nodeQuery(nodeID)}, nil)
+}
+
+// PreviewCompose returns the compose an app would be deployed with, variables substituted and the
+// tunnel sidecar merged in. Non-empty fields of req preview unsaved content; nothing is saved.
+func (c *Client) PreviewCompose(ctx context.Context, appID, nodeID string, req ComposePreviewRequest) (*ComposePreview, error) {
+	var preview ComposePreview
+	if err := c.do(ctx, request{method: http.MethodPost, path: appPath(appID, "/compose/preview"), query: nodeQuery(nodeID), body: req}, &preview); err != nil {
+		return nil, err
+	}
+	return &preview, nil
+}
+
+// ListAppJobs returns an app's most recent jobs
+func (c *Client) ListAppJobs(ctx context.Context, appID, nodeID string) ([]*Job, error) {
+	var jobs []*Job
+	err := c.do(ctx, request{method: http.MethodGet, path: appPath(appID, "/jobs"), query: nodeQuery(nodeID)}, &jobs)
+	return jobs, err
+}
+
+// ListJobs returns recent jobs of every node, newest first and tagged with their node
+func (c *Client) ListJobs(ctx context.Context, opts ListJobsOptions) ([]*Job, error) {
+	query := nodeIDsQuery(opts.NodeIDs)
+	if opts.Status != "" {
+		query.Set("status", opts.Status)
+	}
+	if opts.Type != "" {
+		query.Set("type", opts.Type)
+	}
+	if opts.Limit > 0 {
+		query.Set("limit", strconv.Itoa(opts.Limit))
+	}
+	var jobs []*Job
+	err := c.do(ctx, request{method: http.MethodGet, path: "/api/jobs", query: query}, &jobs)
+	return jobs, err
+}
+
+// GetJob returns a background job on the node that runs it
+func (c *Client) GetJob(ctx context.Context, jobID, nodeID string) (*Job, error) {
+	var job Job
+	if err := c.do(ctx, request{method: http.MethodGet, path: "/api/jobs/" + escape(jobID), query: nodeQuery(nodeID)}, &job); err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// SearchLogs searches the container logs of many apps at once
+func (c *Client) SearchLogs(ctx context.Context, opts LogSearchOptions) ([]*LogSearchMatch, error) {
+	query := nodeIDsQuery(opts.NodeIDs)
+	query.Set("q", opts.Query)
+	if len(opts.Apps) > 0 {
+		query.Set("apps", strings.Join(opts.Apps, ","))
+	}
+	if opts.Since != "" {
+		query.Set("since", opts.Since)
+	}
+	if opts.ContextLines > 0 {
+		query.Set("context", strconv.Itoa(opts.ContextLines))
+	}
+	var matches []*LogSearchMatch
+	err := c.do(ctx, request{method: http.MethodGet, path: "/api/logs/search", query: query}, &matches)
+	return matches, err
+}
+
+// Apply creates, updates and optionally prunes apps to match manifest. With dryRun the result
+// only lists the planned actions.
+func (c *Client) Apply(ctx context.Context, manifest AppManifest, dryRun bool) (*ApplyResult, error) {
+	query := url.Values{}
+	setBool(query, "dry_run", dryRun)
+	var result ApplyResult
+	if err := c.do(ctx, request{method: http.MethodPost, path: "/api/apply", query: query, body: manifest}, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// startJob posts to an endpoint that answers with a background job
+func (c *Client) startJob(ctx context.Context, path, nodeID string, body interface{}) (*JobAccepted, error) {
+	var accepted JobAccepted
+	if err := c.do(ctx, request{method: http.MethodPost, path: path, query: nodeQuery(nodeID), body: body}, &accepted); err != nil {
+		return nil, err
+	}
+	return &accepted, nil
+}
+
+func appPath(appID, suffix string) string {
+	return "/api/apps/" + escape(appID) + suffix
+}
+
+func setBool(query url.Values, key string, value bool) {
+	if value {
+		query.Set(key, "true")
+	}
+}
@@ -0,0 +1,277 @@
+// Package client is a Go client for the selfhostly REST API. It talks to the gateway, or to the
+// primary in single-node setups, and is kept in-tree so it changes together with the server.
+//
+//	c := client.New("https://selfhostly.example.com", client.WithToken(jwt))
+//	apps, err := c.ListApps(ctx, client.ListAppsOptions{Selector: "env=prod"})
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/selfhostly/internal/reqsign"
+)
+
+// Client calls the selfhostly API. It is safe for concurrent use.
+type Client struct {
+	baseURL    string
+	httpClient *http.Client
+	headers    http.Header
+	retry      RetryPolicy
+	signingKey string // node API key requests are signed with; see WithNodeCredentials
+}
+
+// RetryPolicy controls how failed requests are retried. Only GET, HEAD, PUT and DELETE requests
+// are retried, since repeating them is safe; a POST is sent once.
+type RetryPolicy struct {
+	MaxAttempts int           // Total attempts per request, including the first; 1 disables retries
+	MinBackoff  time.Duration // Wait before the first retry, doubled for each one after
+	MaxBackoff  time.Duration
+}
+
+// DefaultRetryPolicy makes three attempts, waiting 250ms and then 500ms between them
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{MaxAttempts: 3, MinBackoff: 250 * time.Millisecond, MaxBackoff: 2 * time.Second}
+}
+
+// Option configures a Client
+type Option func(*Client)
+
+// WithHTTPClient sends requests through hc instead of a client with a 2 minute timeout
+func WithHTTPClient(hc *http.Client) Option {
+	return func(c *Client) { c.httpClient = hc }
+}
+
+// WithToken authenticates as a user with the JWT issued at GitHub login, sent as a bearer token,
+// which the gateway checks as it does the UI's JWT cookie
+func WithToken(jwt string) Option {
+	return func(c *Client) { c.headers.Set("Authorization", "Bearer "+jwt) }
+}
+
+// WithAPIToken authenticates with a personal access token created under /api/tokens, as the
+// user who created it. It works through the gateway and with any node; tokens are checked by the
+// primary.
+func WithAPIToken(token string) Option {
+	return func(c *Client) { c.headers.Set("Authorization", "Bearer "+token) }
+}
+
+// WithNodeCredentials authenticates as a registered node. Requests are then scoped to the node
+// the client talks to, as they are for node-to-node calls, and signed with the API key.
+func WithNodeCredentials(nodeID, apiKey string) Option {
+	return func(c *Client) {
+		c.headers.Set("X-Node-ID", nodeID)
+		c.headers.Set("X-Node-API-Key", apiKey)
+		c.signingKey = apiKey
+	}
+}
+
+// WithRetryPolicy replaces DefaultRetryPolicy
+func WithRetryPolicy(p RetryPolicy) Option {
+	return func(c *Client) { c.retry = p }
+}
+
+// New returns a client for the API at baseURL, e.g. "https://selfhostly.example.com"
+func New(baseURL string, opts ...Option) *Client {
+	c := &Client{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		httpClient: &http.Client{Timeout: 2 * time.Minute},
+		headers:    http.Header{},
+		retry:      DefaultRetryPolicy(),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// APIError is a non-2xx response. Message and Details come from the API's error body.
+type APIError struct {
+	StatusCode int
+	Message    string `json:"error"`
+	Details    string `json:"details,omitempty"`
+}
+
+func (e *APIError) Error() string {
+	msg := e.Message
+	if msg == "" {
+		msg = http.StatusText(e.StatusCode)
+	}
+	if e.Details != "" {
+		msg += ": " + e.Details
+	}
+	return fmt.Sprintf("selfhostly: %d %s", e.StatusCode, msg)
+}
+
+// IsNotFound reports whether err is a 404 from the API
+func IsNotFound(err error) bool { return hasStatus(err, http.StatusNotFound) }
+
+// IsConflict reports whether err is a 409 from the API, e.g. an app locked by another operation
+// or a write to a node running an incompatible version
+func IsConflict(err error) bool { return hasStatus(err, http.StatusConflict) }
+
+// IsLocked reports whether err is a 423 from the API: an admin has locked operations
+func IsLocked(err error) bool { return hasStatus(err, http.StatusLocked) }
+
+func hasStatus(err error, status int) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.StatusCode == status
+}
+
+// request is one API call
+type request struct {
+	method string
+	path   string
+	query  url.Values
+	body   interface{} // JSON-encoded, unless it is a []byte sent as is
+	// okStatuses are error statuses whose body is still decoded into the result, e.g. the 503 of
+	// an unhealthy platform health report
+	okStatuses []int
+}
+
+// do sends req, retrying where the policy allows, and decodes a JSON response into out when
+// out is non-nil. *[]byte receives the raw body.
+func (c *Client) do(ctx context.Context, req request, out interface{}) error {
+	var body []byte
+	switch b := req.body.(type) {
+	case nil:
+	case []byte:
+		body = b
+	default:
+		var err error
+		if body, err = json.Marshal(b); err != nil {
+			return fmt.Errorf("selfhostly: encode request: %w", err)
+		}
+	}
+
+	attempts := max(c.retry.MaxAttempts, 1)
+	if req.method == http.MethodPost {
+		attempts = 1
+	}
+	backoff := c.retry.MinBackoff
+
+	for attempt := 1; ; attempt++ {
+		resp, err := c.send(ctx, req, body)
+		retryable := err != nil || (isRetryableStatus(resp.StatusCode) && !slices.Contains(req.okStatuses, resp.StatusCode))
+		if attempt >= attempts || !retryable || ctx.Err() != nil {
+			if err != nil {
+				return err
+			}
+			defer resp.Body.Close()
+			return decodeResponse(resp, req.okStatuses, out)
+		}
+		if resp != nil {
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, c.retry.MaxBackoff)
+	}
+}
+
+func (c *Client) send(ctx context.Context, req request, body []byte) (*http.Response, error) {
+	httpReq, err := c.newRequest(ctx, req, body)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		return nil, fmt.Errorf("selfhostly: %s %s: %w", req.method, req.path, err)
+	}
+	return resp, nil
+}
+
+func (c *Client) newRequest(ctx context.Context, req request, body []byte) (*http.Request, error) {
+	u := c.baseURL + req.path
+	if len(req.query) > 0 {
+		u += "?" + req.query.Encode()
+	}
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, req.method, u, reader)
+	if err != nil {
+		return nil, fmt.Errorf("selfhostly: %w", err)
+	}
+	for key, values := range c.headers {
+		httpReq.Header[key] = values
+	}
+	if body != nil {
+		httpReq.Header.Set("Content-Type", "application/json")
+	}
+	httpReq.Header.Set("Accept", "application/json")
+	// Each attempt is signed afresh, so a retry doesn't reuse a nonce
+	if c.signingKey != "" {
+		if err := reqsign.Sign(httpReq, c.signingKey); err != nil {
+			return nil, fmt.Errorf("selfhostly: signing request: %w", err)
+		}
+	}
+	return httpReq, nil
+}
+
+// isRetryableStatus reports whether a response means the server or a proxy in front of it was
+// briefly unavailable
+func isRetryableStatus(status int) bool {
+	switch status {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+func decodeResponse(resp *http.Response, okStatuses []int, out interface{}) error {
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("selfhostly: read response: %w", err)
+	}
+	if resp.StatusCode >= 300 && !slices.Contains(okStatuses, resp.StatusCode) {
+		apiErr := &APIError{StatusCode: resp.StatusCode}
+		_ = json.Unmarshal(data, apiErr)
+		return apiErr
+	}
+	switch out := out.(type) {
+	case nil:
+		return nil
+	case *[]byte:
+		*out = data
+		return nil
+	default:
+		if err := json.Unmarshal(data, out); err != nil {
+			return fmt.Errorf("selfhostly: decode response: %w", err)
+		}
+		return nil
+	}
+}
+
+// nodeQuery is the query most app-scoped calls need: the node that hosts the app
+func nodeQuery(nodeID string) url.Values {
+	return url.Values{"node_id": {nodeID}}
+}
+
+// nodeIDsQuery limits a list to some nodes; all nodes when empty
+func nodeIDsQuery(nodeIDs []string) url.Values {
+	query := url.Values{}
+	if len(nodeIDs) > 0 {
+		query.Set("node_ids", strings.Join(nodeIDs, ","))
+	}
+	return query
+}
+
+// escape escapes an ID for use as a path segment
+func escape(segment string) string {
+	return url.PathEscape(segment)
+}
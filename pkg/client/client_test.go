@@ -0,0 +1,284 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/selfhostly/internal/config"
+	"github.com/selfhostly/internal/db"
+	httpserver "github.com/selfhostly/internal/http"
+	"github.com/selfhostly/internal/timeouts"
+)
+
+// setupTestServer serves a single-node primary with auth disabled
+func setupTestServer(t *testing.T) *Client {
+	t.Helper()
+
+	database, err := db.Init(filepath.Join(t.TempDir(), "selfhostly.db"))
+	if err != nil {
+		t.Fatalf("Failed to initialize database: %v", err)
+	}
+	t.Cleanup(func() { database.Close() })
+
+	cfg := &config.Config{
+		Environment: "test",
+		AppsDir:     t.TempDir(),
+		Node: config.NodeConfig{
+			ID:        "test-node-id",
+			Name:      "test-node",
+			IsPrimary: true,
+			APIKey:    "test-api-key",
+		},
+		Timeouts: timeouts.NewLive(timeouts.Default()),
+	}
+	if err := database.InitNode(cfg); err != nil {
+		t.Fatalf("Failed to initialize node: %v", err)
+	}
+
+	ts := httptest.NewServer(httpserver.NewServer(cfg, database).Handler())
+	t.Cleanup(ts.Close)
+	return New(ts.URL)
+}
+
+func TestClient_AgainstServer(t *testing.T) {
+	c := setupTestServer(t)
+	ctx := context.Background()
+
+	health, err := c.Health(ctx)
+	if err != nil {
+		t.Fatalf("Health: %v", err)
+	}
+	if health.Status != "healthy" || health.APIVersion == 0 {
+		t.Errorf("Unexpected health response: %+v", health)
+	}
+
+	node, err := c.GetCurrentNode(ctx)
+	if err != nil {
+		t.Fatalf("GetCurrentNode: %v", err)
+	}
+	if !node.IsPrimary {
+		t.Errorf("Expected the current node to be the primary")
+	}
+
+	nodes, err := c.ListNodes(ctx)
+	if err != nil {
+		t.Fatalf("ListNodes: %v", err)
+	}
+	if len(nodes) != 1 || nodes[0].ID != node.ID {
+		t.Errorf("Expected only the current node, got %+v", nodes)
+	}
+
+	metrics, err := c.GetNodeMetrics(ctx, node.ID, time.Time{})
+	if err != nil {
+		t.Fatalf("GetNodeMetrics: %v", err)
+	}
+	if metrics.NodeID != node.ID || len(metrics.Metrics) != 0 {
+		t.Errorf("Expected no samples yet, got %+v", metrics)
+	}
+	if alerts, err := c.ListNodeAlerts(ctx, node.ID, true); err != nil || len(alerts) != 0 {
+		t.Errorf("Expected no alerts, got %+v (%v)", alerts, err)
+	}
+	if _, err := c.GetNodeMetrics(ctx, "missing", time.Time{}); !IsNotFound(err) {
+		t.Errorf("Expected not found for an unknown node, got %v", err)
+	}
+	if logs, err := c.GetNodeServerLogs(ctx, node.ID, NodeServerLogsOptions{Level: "warn"}); err != nil || len(logs) != 0 {
+		t.Errorf("Expected no shipped logs, got %+v (%v)", logs, err)
+	}
+	if _, err := c.GetNodeServerLogs(ctx, node.ID, NodeServerLogsOptions{Level: "loud"}); !hasStatus(err, http.StatusBadRequest) {
+		t.Errorf("Expected a bad request for an unknown level, got %v", err)
+	}
+
+	apps, err := c.ListApps(ctx, ListAppsOptions{Selector: "env=prod", Fields: []string{"id", "name", "status"}})
+	if err != nil {
+		t.Fatalf("ListApps: %v", err)
+	}
+	if len(apps) != 0 {
+		t.Errorf("Expected no apps, got %d", len(apps))
+	}
+
+	if _, err := c.GetApp(ctx, "missing", node.ID); !IsNotFound(err) {
+		t.Errorf("Expected a not found error for a missing app, got %v", err)
+	}
+
+	if _, err := c.ListAppSnapshots(ctx, "missing", node.ID); !IsNotFound(err) {
+		t.Errorf("Expected a not found error for a missing app's snapshots, got %v", err)
+	}
+
+	if _, err := c.SetAppLogForwarding(ctx, "missing", node.ID, true); !IsNotFound(err) {
+		t.Errorf("Expected a not found error for a missing app's log forwarding, got %v", err)
+	}
+
+	err = c.StreamAppStats(ctx, "missing", node.ID, 0, func(*AppStats) error { return nil })
+	if !IsNotFound(err) {
+		t.Errorf("Expected a not found error for a missing app's stats stream, got %v", err)
+	}
+
+	dbStats, err := c.GetDatabaseStats(ctx, "")
+	if err != nil {
+		t.Fatalf("GetDatabaseStats: %v", err)
+	}
+	if dbStats.PageCount == 0 || dbStats.AutoVacuum != "incremental" || dbStats.LastMaintenance != nil {
+		t.Errorf("Unexpected database stats %+v", dbStats)
+	}
+
+	settings, err := c.GetSettings(ctx)
+	if err != nil {
+		t.Fatalf("GetSettings: %v", err)
+	}
+	if settings.ID == "" {
+		t.Errorf("Expected settings to have an ID")
+	}
+
+	theme := "dark"
+	if _, err := c.UpdatePreferences(ctx, UpdatePreferencesRequest{Theme: &theme}); err != nil {
+		t.Fatalf("UpdatePreferences: %v", err)
+	}
+	prefs, err := c.GetPreferences(ctx)
+	if err != nil {
+		t.Fatalf("GetPreferences: %v", err)
+	}
+	if prefs.Theme != "dark" || prefs.RefreshInterval == 0 {
+		t.Errorf("Expected the saved theme and the default refresh interval, got %+v", prefs)
+	}
+
+	if _, err := c.LockOperations(ctx, "backup window"); err != nil {
+		t.Fatalf("LockOperations: %v", err)
+	}
+	if _, err := c.CreateApp(ctx, CreateAppRequest{Name: "blocked", ComposeContent: "services:\n  web:\n    image: nginx\n"}); !IsLocked(err) {
+		t.Errorf("Expected app changes to be refused while operations are locked, got %v", err)
+	}
+	if _, err := c.ListApps(ctx, ListAppsOptions{}); err != nil {
+		t.Errorf("Expected reads to work while operations are locked, got %v", err)
+	}
+	if status, err := c.UnlockOperations(ctx); err != nil || status.Locked {
+		t.Fatalf("UnlockOperations: %+v %v", status, err)
+	}
+
+	if _, err := c.ListFeatureFlags(ctx); err != nil {
+		t.Fatalf("ListFeatureFlags: %v", err)
+	}
+
+	result, err := c.Apply(ctx, AppManifest{Apps: []ManifestApp{{
+		Name:    "web",
+		Compose: "services:\n  web:\n    image: nginx\n",
+		Labels:  map[string]string{"env": "prod"},
+	}}}, true)
+	if err != nil {
+		t.Fatalf("Apply: %v", err)
+	}
+	if !result.DryRun || len(result.Actions) != 1 || result.Actions[0].Action != "create" {
+		t.Errorf("Expected a dry run creating one app, got %+v", result)
+	}
+}
+
+func TestClient_InvalidRequest(t *testing.T) {
+	c := setupTestServer(t)
+
+	_, err := c.ListApps(context.Background(), ListAppsOptions{Selector: "env in (prod"})
+	apiErr, ok := err.(*APIError)
+	if !ok {
+		t.Fatalf("Expected an APIError, got %v", err)
+	}
+	if apiErr.StatusCode != http.StatusBadRequest || apiErr.Message == "" {
+		t.Errorf("Unexpected error: %+v", apiErr)
+	}
+
+	_, err = c.ListApps(context.Background(), ListAppsOptions{Fields: []string{"id", "compose"}})
+	if apiErr, ok := err.(*APIError); !ok || apiErr.StatusCode != http.StatusBadRequest {
+		t.Errorf("Expected a bad request for an unknown field, got %v", err)
+	}
+}
+
+func TestClient_Retries(t *testing.T) {
+	var calls atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{"status":"healthy"}`))
+	}))
+	defer ts.Close()
+
+	c := New(ts.URL, WithRetryPolicy(RetryPolicy{MaxAttempts: 3, MinBackoff: time.Millisecond, MaxBackoff: time.Millisecond}))
+
+	if _, err := c.Health(context.Background()); err != nil {
+		t.Fatalf("Expected the retry to succeed, got %v", err)
+	}
+	if calls.Load() != 2 {
+		t.Errorf("Expected 2 attempts, got %d", calls.Load())
+	}
+
+	// POST is not safe to repeat
+	calls.Store(0)
+	if _, err := c.RunConsistencyAudit(context.Background()); err == nil {
+		t.Errorf("Expected the 503 to be returned")
+	}
+	if calls.Load() != 1 {
+		t.Errorf("Expected 1 attempt for a POST, got %d", calls.Load())
+	}
+}
+
+func TestClient_StreamAppStats(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("interval") != "5" {
+			t.Errorf("Expected interval=5, got %q", r.URL.RawQuery)
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Write([]byte("event:stats\ndata:{\"app_name\":\"web\",\"total_cpu_percent\":1.5}\n\n"))
+		w.Write([]byte("event:stats\ndata:{\"app_name\":\"web\",\"total_cpu_percent\":2.5}\n\n"))
+		w.Write([]byte("event:error\ndata:{\"error\":\"Stats stream failed\"}\n\n"))
+	}))
+	defer ts.Close()
+
+	var samples []*AppStats
+	err := New(ts.URL).StreamAppStats(context.Background(), "app-1", "node-1", 5*time.Second, func(stats *AppStats) error {
+		samples = append(samples, stats)
+		return nil
+	})
+	if len(samples) != 2 || samples[1].TotalCPUPercent != 2.5 {
+		t.Errorf("Expected two samples, got %+v", samples)
+	}
+	apiErr, ok := err.(*APIError)
+	if !ok || apiErr.Message != "Stats stream failed" {
+		t.Errorf("Expected the error event to end the stream, got %v", err)
+	}
+}
+
+func TestClient_AuthHeaders(t *testing.T) {
+	var got http.Header
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Clone()
+		w.Write([]byte(`{}`))
+	}))
+	defer ts.Close()
+
+	if _, err := New(ts.URL, WithToken("jwt")).Me(context.Background()); err != nil {
+		t.Fatalf("Me: %v", err)
+	}
+	if got.Get("Authorization") != "Bearer jwt" {
+		t.Errorf("Expected the token as a bearer token, got %q", got.Get("Authorization"))
+	}
+
+	if _, err := New(ts.URL, WithAPIToken("shp_token")).Me(context.Background()); err != nil {
+		t.Fatalf("Me: %v", err)
+	}
+	if got.Get("Authorization") != "Bearer shp_token" {
+		t.Errorf("Expected the API token as a bearer token, got %q", got.Get("Authorization"))
+	}
+
+	if _, err := New(ts.URL, WithNodeCredentials("node-1", "key")).GetCurrentNode(context.Background()); err != nil {
+		t.Fatalf("GetCurrentNode: %v", err)
+	}
+	if got.Get("X-Node-ID") != "node-1" || got.Get("X-Node-API-Key") != "key" {
+		t.Errorf("Expected node credentials, got %v", got)
+	}
+	if got.Get("X-Selfhostly-Signature") == "" || got.Get("X-Selfhostly-Nonce") == "" {
+		t.Errorf("Expected the request to be signed, got %v", got)
+	}
+}
@@ -0,0 +1,190 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// ListNodes lists the nodes of the cluster
+func (c *Client) ListNodes(ctx context.Context) ([]*Node, error) {
+	var nodes []*Node
+	err := c.do(ctx, request{method: http.MethodGet, path: "/api/nodes"}, &nodes)
+	return nodes, err
+}
+
+// RegisterNode adds a secondary node
+func (c *Client) RegisterNode(ctx context.Context, req RegisterNodeRequest) (*Node, error) {
+	var node Node
+	if err := c.do(ctx, request{method: http.MethodPost, path: "/api/nodes", body: req}, &node); err != nil {
+		return nil, err
+	}
+	return &node, nil
+}
+
+// GetNode returns one node
+func (c *Client) GetNode(ctx context.Context, nodeID string) (*Node, error) {
+	var node Node
+	if err := c.do(ctx, request{method: http.MethodGet, path: nodePath(nodeID, "")}, &node); err != nil {
+		return nil, err
+	}
+	return &node, nil
+}
+
+// UpdateNode changes a node's name, endpoint or API key
+func (c *Client) UpdateNode(ctx context.Context, nodeID string, req UpdateNodeRequest) (*Node, error) {
+	var node Node
+	if err := c.do(ctx, request{method: http.MethodPut, path: nodePath(nodeID, ""), body: req}, &node); err != nil {
+		return nil, err
+	}
+	return &node, nil
+}
+
+// DeleteNode removes a secondary node from the cluster. It fails with 409 while apps are still
+// deployed on the node; use RemoveNode for those.
+func (c *Client) DeleteNode(ctx context.Context, nodeID string) error {
+	return c.do(ctx, request{method: http.MethodDelete, path: nodePath(nodeID, "")}, nil)
+}
+
+// RemoveNode removes a secondary node and deals with its apps by req.Strategy in a background job
+// on the primary. The finished job's Result is a RemoveNodeResult with the outcome per app.
+func (c *Client) RemoveNode(ctx context.Context, nodeID string, req RemoveNodeRequest) (*JobAccepted, error) {
+	query := url.Values{"strategy": {req.Strategy}}
+	if req.TargetNodeID != "" {
+		query.Set("target_node_id", req.TargetNodeID)
+	}
+	var accepted JobAccepted
+	if err := c.do(ctx, request{method: http.MethodDelete, path: nodePath(nodeID, ""), query: query}, &accepted); err != nil {
+		return nil, err
+	}
+	return &accepted, nil
+}
+
+// PingNode returns nil when the primary can reach the node
+func (c *Client) PingNode(ctx context.Context, nodeID string) error {
+	return c.do(ctx, request{method: http.MethodGet, path: nodePath(nodeID, "/health")}, nil)
+}
+
+// CheckNode runs a health check on a node now and returns its updated status
+func (c *Client) CheckNode(ctx context.Context, nodeID string) (*NodeCheck, error) {
+	var check NodeCheck
+	if err := c.do(ctx, request{method: http.MethodPost, path: nodePath(nodeID, "/check")}, &check); err != nil {
+		return nil, err
+	}
+	return &check, nil
+}
+
+// ListNodeOperations lists the operations queued for an offline node, optionally only those
+// with status
+func (c *Client) ListNodeOperations(ctx context.Context, nodeID, status string) ([]*QueuedOperation, error) {
+	query := url.Values{}
+	if status != "" {
+		query.Set("status", status)
+	}
+	var ops []*QueuedOperation
+	err := c.do(ctx, request{method: http.MethodGet, path: nodePath(nodeID, "/operations"), query: query}, &ops)
+	return ops, err
+}
+
+// QueueNodeOperation queues an operation to replay when an offline node reconnects
+func (c *Client) QueueNodeOperation(ctx context.Context, nodeID string, req QueueOperationRequest) (*QueuedOperationResult, error) {
+	var result QueuedOperationResult
+	if err := c.do(ctx, request{method: http.MethodPost, path: nodePath(nodeID, "/operations"), body: req}, &result); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// CancelNodeOperation removes a queued operation that has not been replayed yet
+func (c *Client) CancelNodeOperation(ctx context.Context, nodeID, opID string) error {
+	return c.do(ctx, request{method: http.MethodDelete, path: nodePath(nodeID, "/operations/"+escape(opID))}, nil)
+}
+
+// GetNodeMetrics returns a node's metrics history since the given time, oldest first. A zero
+// since returns the last 24 hours.
+func (c *Client) GetNodeMetrics(ctx context.Context, nodeID string, since time.Time) (*NodeMetrics, error) {
+	query := url.Values{}
+	if !since.IsZero() {
+		query.Set("since", since.UTC().Format(time.RFC3339))
+	}
+	var metrics NodeMetrics
+	if err := c.do(ctx, request{method: http.MethodGet, path: nodePath(nodeID, "/metrics"), query: query}, &metrics); err != nil {
+		return nil, err
+	}
+	return &metrics, nil
+}
+
+// ListNodeAlerts lists a node's most recent metric alerts, newest first, optionally only those
+// still active
+func (c *Client) ListNodeAlerts(ctx context.Context, nodeID string, activeOnly bool) ([]*NodeAlert, error) {
+	query := url.Values{}
+	setBool(query, "active", activeOnly)
+	var list struct {
+		Alerts []*NodeAlert `json:"alerts"`
+	}
+	err := c.do(ctx, request{method: http.MethodGet, path: nodePath(nodeID, "/alerts"), query: query}, &list)
+	return list.Alerts, err
+}
+
+// NodeServerLogsOptions filters GetNodeServerLogs
+type NodeServerLogsOptions struct {
+	Since time.Time // Zero returns every line still kept
+	Level string    // Minimum level: debug, info, warn or error
+	Limit int       // Newest lines returned; zero uses the server's default
+}
+
+// GetNodeServerLogs returns the server log lines a secondary shipped to the primary, oldest first
+func (c *Client) GetNodeServerLogs(ctx context.Context, nodeID string, opts NodeServerLogsOptions) ([]*NodeLog, error) {
+	query := url.Values{}
+	if !opts.Since.IsZero() {
+		query.Set("since", opts.Since.UTC().Format(time.RFC3339))
+	}
+	if opts.Level != "" {
+		query.Set("level", opts.Level)
+	}
+	if opts.Limit > 0 {
+		query.Set("limit", strconv.Itoa(opts.Limit))
+	}
+	var list struct {
+		Logs []*NodeLog `json:"logs"`
+	}
+	err := c.do(ctx, request{method: http.MethodGet, path: nodePath(nodeID, "/server-logs"), query: query}, &list)
+	return list.Logs, err
+}
+
+// ListGatewayTokens lists the tokens issued for the gateway's node registry fetch, newest first
+func (c *Client) ListGatewayTokens(ctx context.Context) ([]*GatewayToken, error) {
+	var tokens []*GatewayToken
+	err := c.do(ctx, request{method: http.MethodGet, path: "/api/gateway/tokens"}, &tokens)
+	return tokens, err
+}
+
+// IssueGatewayToken issues a token for the gateway. The token is only returned here; set it as the
+// gateway's GATEWAY_REGISTRY_TOKEN.
+func (c *Client) IssueGatewayToken(ctx context.Context, req IssueGatewayTokenRequest) (*IssuedGatewayToken, error) {
+	var issued IssuedGatewayToken
+	if err := c.do(ctx, request{method: http.MethodPost, path: "/api/gateway/tokens", body: req}, &issued); err != nil {
+		return nil, err
+	}
+	return &issued, nil
+}
+
+// RevokeGatewayToken revokes a gateway token before it expires
+func (c *Client) RevokeGatewayToken(ctx context.Context, tokenID string) error {
+	return c.do(ctx, request{method: http.MethodDelete, path: "/api/gateway/tokens/" + escape(tokenID)}, nil)
+}
+
+// GetCurrentNode returns the node the client talks to
+func (c *Client) GetCurrentNode(ctx context.Context) (*Node, error) {
+	var node Node
+	if err := c.do(ctx, request{method: http.MethodGet, path: "/api/node/info"}, &node); err != nil {
+		return nil, err
+	}
+	return &node, nil
+}
+
+func nodePath(nodeID, suffix string) string {
+	return "/api/nodes/" + escape(nodeID) + suffix
+}
@@ -0,0 +1,88 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// StreamAppStats calls fn with each sample of an app's live stats stream until ctx is done, fn
+// returns an error or the server ends the stream, which it does after its stream timeout. A zero
+// interval uses the server's default. Streams are not retried.
+func (c *Client) StreamAppStats(ctx context.Context, appID, nodeID string, interval time.Duration, fn func(*AppStats) error) error {
+	query := nodeQuery(nodeID)
+	if interval > 0 {
+		query.Set("interval", strconv.Itoa(int(interval.Seconds())))
+	}
+	return c.stream(ctx, appPath(appID, "/stats/stream"), query, func(event string, data []byte) error {
+		if event != "stats" {
+			return nil
+		}
+		var stats AppStats
+		if err := json.Unmarshal(data, &stats); err != nil {
+			return fmt.Errorf("selfhostly: decode stats event: %w", err)
+		}
+		return fn(&stats)
+	})
+}
+
+// stream reads a server-sent event stream, passing each event to fn. An "error" event ends the
+// stream with an *APIError.
+func (c *Client) stream(ctx context.Context, path string, query url.Values, fn func(event string, data []byte) error) error {
+	httpReq, err := c.newRequest(ctx, request{method: http.MethodGet, path: path, query: query}, nil)
+	if err != nil {
+		return err
+	}
+	httpReq.Header.Set("Accept", "text/event-stream")
+
+	// The client's timeout covers reading the whole body, which a stream never finishes
+	hc := *c.httpClient
+	hc.Timeout = 0
+	resp, err := hc.Do(httpReq)
+	if err != nil {
+		return fmt.Errorf("selfhostly: GET %s: %w", path, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return decodeResponse(resp, nil, nil)
+	}
+
+	var event string
+	var data []byte
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 1<<20)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case line == "":
+			if data != nil {
+				if event == "error" {
+					apiErr := &APIError{StatusCode: resp.StatusCode}
+					_ = json.Unmarshal(data, apiErr)
+					return apiErr
+				}
+				if err := fn(event, data); err != nil {
+					return err
+				}
+			}
+			event, data = "", nil
+		case strings.HasPrefix(line, "event:"):
+			event = strings.TrimSpace(strings.TrimPrefix(line, "event:"))
+		case strings.HasPrefix(line, "data:"):
+			if data != nil {
+				data = append(data, '\n')
+			}
+			data = append(data, strings.TrimPrefix(strings.TrimPrefix(line, "data:"), " ")...)
+		}
+	}
+	if err := scanner.Err(); err != nil && ctx.Err() == nil {
+		return fmt.Errorf("selfhostly: read stream: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,409 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+)
+
+// Health checks that the server is up. It needs no credentials.
+func (c *Client) Health(ctx context.Context) (*ServerHealth, error) {
+	var health ServerHealth
+	if err := c.do(ctx, request{method: http.MethodGet, path: "/api/health"}, &health); err != nil {
+		return nil, err
+	}
+	return &health, nil
+}
+
+// Me returns the signed-in user
+func (c *Client) Me(ctx context.Context) (*User, error) {
+	var user User
+	if err := c.do(ctx, request{method: http.MethodGet, path: "/api/me"}, &user); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// GetPreferences returns the signed-in user's preferences (shared by everyone while auth is off)
+func (c *Client) GetPreferences(ctx context.Context) (*UserPreferences, error) {
+	var prefs UserPreferences
+	if err := c.do(ctx, request{method: http.MethodGet, path: "/api/me/preferences"}, &prefs); err != nil {
+		return nil, err
+	}
+	return &prefs, nil
+}
+
+// ListSessions returns the signed-in user's active sessions, with the current one marked
+func (c *Client) ListSessions(ctx context.Context) ([]*Session, error) {
+	var sessions []*Session
+	if err := c.do(ctx, request{method: http.MethodGet, path: "/api/me/sessions"}, &sessions); err != nil {
+		return nil, err
+	}
+	return sessions, nil
+}
+
+// RevokeSession signs one of the signed-in user's sessions out
+func (c *Client) RevokeSession(ctx context.Context, id string) error {
+	return c.do(ctx, request{method: http.MethodDelete, path: "/api/me/sessions/" + escape(id)}, nil)
+}
+
+// UpdatePreferences saves the preferences set in req, leaving the others as they are
+func (c *Client) UpdatePreferences(ctx context.Context, req UpdatePreferencesRequest) (*UserPreferences, error) {
+	var prefs UserPreferences
+	if err := c.do(ctx, request{method: http.MethodPut, path: "/api/me/preferences", body: req}, &prefs); err != nil {
+		return nil, err
+	}
+	return &prefs, nil
+}
+
+// GetOperationsLock returns whether an admin has locked app changes
+func (c *Client) GetOperationsLock(ctx context.Context) (*OperationsLockStatus, error) {
+	var status OperationsLockStatus
+	if err := c.do(ctx, request{method: http.MethodGet, path: "/api/system/operations-lock"}, &status); err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
+
+// LockOperations blocks app changes on every node until UnlockOperations; reads keep working.
+// Send it to the primary.
+func (c *Client) LockOperations(ctx context.Context, reason string) (*OperationsLockStatus, error) {
+	var status OperationsLockStatus
+	if err := c.do(ctx, request{method: http.MethodPut, path: "/api/system/operations-lock", body: LockOperationsRequest{Reason: reason}}, &status); err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
+
+// UnlockOperations lets app changes through again on every node
+func (c *Client) UnlockOperations(ctx context.Context) (*OperationsLockStatus, error) {
+	var status OperationsLockStatus
+	if err := c.do(ctx, request{method: http.MethodDelete, path: "/api/system/operations-lock"}, &status); err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
+
+// GetSettings returns the global settings
+func (c *Client) GetSettings(ctx context.Context) (*Settings, error) {
+	var settings Settings
+	if err := c.do(ctx, request{method: http.MethodGet, path: "/api/settings"}, &settings); err != nil {
+		return nil, err
+	}
+	return &settings, nil
+}
+
+// UpdateSettings changes the global settings
+func (c *Client) UpdateSettings(ctx context.Context, req UpdateSettingsRequest) (*Settings, error) {
+	var settings Settings
+	if err := c.do(ctx, request{method: http.MethodPut, path: "/api/settings", body: req}, &settings); err != nil {
+		return nil, err
+	}
+	return &settings, nil
+}
+
+// PreviewTelemetry returns the report that would be sent if telemetry were enabled
+func (c *Client) PreviewTelemetry(ctx context.Context) (*TelemetryReport, error) {
+	var report TelemetryReport
+	if err := c.do(ctx, request{method: http.MethodGet, path: "/api/settings/telemetry/preview"}, &report); err != nil {
+		return nil, err
+	}
+	return &report, nil
+}
+
+// ListFeatureFlags lists the feature flags and whether each is enabled
+func (c *Client) ListFeatureFlags(ctx context.Context) ([]*FeatureFlag, error) {
+	var flags []*FeatureFlag
+	err := c.do(ctx, request{method: http.MethodGet, path: "/api/settings/features"}, &flags)
+	return flags, err
+}
+
+// SetFeatureFlag switches a feature flag on or off
+func (c *Client) SetFeatureFlag(ctx context.Context, name string, enabled bool) (*FeatureFlag, error) {
+	var flag FeatureFlag
+	body := map[string]bool{"enabled": enabled}
+	if err := c.do(ctx, request{method: http.MethodPut, path: "/api/settings/features/" + escape(name), body: body}, &flag); err != nil {
+		return nil, err
+	}
+	return &flag, nil
+}
+
+// GetSettingsSchema describes the settings sections and their fields
+func (c *Client) GetSettingsSchema(ctx context.Context) ([]*SettingsSectionSchema, error) {
+	var resp struct {
+		Sections []*SettingsSectionSchema `json:"sections"`
+	}
+	err := c.do(ctx, request{method: http.MethodGet, path: "/api/settings/schema"}, &resp)
+	return resp.Sections, err
+}
+
+// GetSettingsSection returns one settings section's values
+func (c *Client) GetSettingsSection(ctx context.Context, section string) (*SettingsSection, error) {
+	var s SettingsSection
+	if err := c.do(ctx, request{method: http.MethodGet, path: "/api/settings/" + escape(section)}, &s); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// UpdateSettingsSection saves the given fields of a settings section, leaving the others as they are
+func (c *Client) UpdateSettingsSection(ctx context.Context, section string, values map[string]interface{}) (*SettingsSection, error) {
+	var s SettingsSection
+	if err := c.do(ctx, request{method: http.MethodPut, path: "/api/settings/" + escape(section), body: values}, &s); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// SendTestEmail sends a test email to to through the saved SMTP settings
+func (c *Client) SendTestEmail(ctx context.Context, to string) error {
+	body := map[string]string{"to": to}
+	return c.do(ctx, request{method: http.MethodPost, path: "/api/settings/smtp/test", body: body}, nil)
+}
+
+// GetSettingsHistory returns the recent changes to a settings section
+func (c *Client) GetSettingsHistory(ctx context.Context, section string) (*SettingsHistory, error) {
+	var history SettingsHistory
+	if err := c.do(ctx, request{method: http.MethodGet, path: "/api/settings/" + escape(section) + "/history"}, &history); err != nil {
+		return nil, err
+	}
+	return &history, nil
+}
+
+// GetSystemStats returns resource usage and containers of nodeIDs, or of all nodes when empty
+func (c *Client) GetSystemStats(ctx context.Context, nodeIDs []string) ([]*SystemStats, error) {
+	var stats []*SystemStats
+	err := c.do(ctx, request{method: http.MethodGet, path: "/api/system/stats", query: nodeIDsQuery(nodeIDs)}, &stats)
+	return stats, err
+}
+
+// GetPlatformHealth returns the health of the server's subsystems. An unhealthy report is
+// returned as is rather than as an error.
+func (c *Client) GetPlatformHealth(ctx context.Context) (*HealthReport, error) {
+	var report HealthReport
+	req := request{method: http.MethodGet, path: "/api/system/health", okStatuses: []int{http.StatusServiceUnavailable}}
+	if err := c.do(ctx, req, &report); err != nil {
+		return nil, err
+	}
+	return &report, nil
+}
+
+// GetConsistencyAudit returns the latest consistency audit of the database
+func (c *Client) GetConsistencyAudit(ctx context.Context) (*AuditReport, error) {
+	var report *AuditReport
+	err := c.do(ctx, request{method: http.MethodGet, path: "/api/system/audit"}, &report)
+	return report, err
+}
+
+// RunConsistencyAudit audits the database now
+func (c *Client) RunConsistencyAudit(ctx context.Context) (*AuditReport, error) {
+	var report AuditReport
+	if err := c.do(ctx, request{method: http.MethodPost, path: "/api/system/audit"}, &report); err != nil {
+		return nil, err
+	}
+	return &report, nil
+}
+
+// GetDatabaseStats returns the size and fragmentation of a node's database, the primary's when
+// nodeID is empty
+func (c *Client) GetDatabaseStats(ctx context.Context, nodeID string) (*DatabaseStats, error) {
+	var query url.Values
+	if nodeID != "" {
+		query = nodeQuery(nodeID)
+	}
+	var stats DatabaseStats
+	if err := c.do(ctx, request{method: http.MethodGet, path: "/api/system/db/stats", query: query}, &stats); err != nil {
+		return nil, err
+	}
+	return &stats, nil
+}
+
+// ReloadConfig re-reads the server's reloadable configuration
+func (c *Client) ReloadConfig(ctx context.Context) (*ConfigReload, error) {
+	var reload ConfigReload
+	if err := c.do(ctx, request{method: http.MethodPost, path: "/api/system/reload"}, &reload); err != nil {
+		return nil, err
+	}
+	return &reload, nil
+}
+
+// GetLogSettings returns a node's log level and per-subsystem overrides
+func (c *Client) GetLogSettings(ctx context.Context, nodeID string) (*LogSettings, error) {
+	var settings LogSettings
+	if err := c.do(ctx, request{method: http.MethodGet, path: "/api/system/log-level", query: optionalNodeQuery(nodeID)}, &settings); err != nil {
+		return nil, err
+	}
+	return &settings, nil
+}
+
+// UpdateLogSettings changes a node's log level without a restart
+func (c *Client) UpdateLogSettings(ctx context.Context, nodeID string, req UpdateLogSettingsRequest) (*LogSettings, error) {
+	var settings LogSettings
+	if err := c.do(ctx, request{method: http.MethodPut, path: "/api/system/log-level", query: optionalNodeQuery(nodeID), body: req}, &settings); err != nil {
+		return nil, err
+	}
+	return &settings, nil
+}
+
+// GenerateSecret returns a random password or key, e.g. for a compose file or .env template
+func (c *Client) GenerateSecret(ctx context.Context, req GenerateSecretRequest) (*GeneratedSecret, error) {
+	var secret GeneratedSecret
+	if err := c.do(ctx, request{method: http.MethodPost, path: "/api/utils/generate-secret", body: req}, &secret); err != nil {
+		return nil, err
+	}
+	return &secret, nil
+}
+
+// RestartContainer restarts a container on a node
+func (c *Client) RestartContainer(ctx context.Context, containerID, nodeID string) error {
+	return c.do(ctx, request{method: http.MethodPost, path: containerPath(containerID, "/restart"), query: optionalNodeQuery(nodeID)}, nil)
+}
+
+// StopContainer stops a container on a node
+func (c *Client) StopContainer(ctx context.Context, containerID, nodeID string) error {
+	return c.do(ctx, request{method: http.MethodPost, path: containerPath(containerID, "/stop"), query: optionalNodeQuery(nodeID)}, nil)
+}
+
+// DeleteContainer removes a container on a node
+func (c *Client) DeleteContainer(ctx context.Context, containerID, nodeID string) error {
+	return c.do(ctx, request{method: http.MethodDelete, path: containerPath(containerID, ""), query: optionalNodeQuery(nodeID)}, nil)
+}
+
+// ListApprovals returns a node's destructive operations awaiting, or given, a second confirmation;
+// status "pending" limits them to those awaiting it. An empty nodeID is the primary.
+func (c *Client) ListApprovals(ctx context.Context, nodeID, status string) ([]*Approval, error) {
+	query := optionalNodeQuery(nodeID)
+	if status != "" {
+		if query == nil {
+			query = url.Values{}
+		}
+		query.Set("status", status)
+	}
+	var approvals []*Approval
+	if err := c.do(ctx, request{method: http.MethodGet, path: "/api/approvals", query: query}, &approvals); err != nil {
+		return nil, err
+	}
+	return approvals, nil
+}
+
+// ConfirmApproval confirms a pending approval on a node, which runs the operation
+func (c *Client) ConfirmApproval(ctx context.Context, approvalID, nodeID string) error {
+	return c.do(ctx, request{method: http.MethodPost, path: "/api/approvals/" + escape(approvalID) + "/confirm", query: optionalNodeQuery(nodeID)}, nil)
+}
+
+// RejectApproval turns a pending approval on a node down
+func (c *Client) RejectApproval(ctx context.Context, approvalID, nodeID string) (*Approval, error) {
+	var approval Approval
+	if err := c.do(ctx, request{method: http.MethodPost, path: "/api/approvals/" + escape(approvalID) + "/reject", query: optionalNodeQuery(nodeID)}, &approval); err != nil {
+		return nil, err
+	}
+	return &approval, nil
+}
+
+// ListQuotas returns every user's app quota, including the default ("*")
+func (c *Client) ListQuotas(ctx context.Context) ([]*UserQuota, error) {
+	var quotas []*UserQuota
+	if err := c.do(ctx, request{method: http.MethodGet, path: "/api/quotas"}, &quotas); err != nil {
+		return nil, err
+	}
+	return quotas, nil
+}
+
+// SetQuota creates or replaces a user's app quota; user "*" sets the default
+func (c *Client) SetQuota(ctx context.Context, user string, req SetQuotaRequest) (*UserQuota, error) {
+	var quota UserQuota
+	if err := c.do(ctx, request{method: http.MethodPut, path: "/api/quotas/" + escape(user), body: req}, &quota); err != nil {
+		return nil, err
+	}
+	return &quota, nil
+}
+
+// DeleteQuota removes a user's app quota, leaving them on the default
+func (c *Client) DeleteQuota(ctx context.Context, user string) error {
+	return c.do(ctx, request{method: http.MethodDelete, path: "/api/quotas/" + escape(user)}, nil)
+}
+
+// GetQuotaUsage returns what a user's apps use against their quota; "" for the signed-in user
+func (c *Client) GetQuotaUsage(ctx context.Context, user string) (*QuotaUsage, error) {
+	var query url.Values
+	if user != "" {
+		query = url.Values{"user": {user}}
+	}
+	var usage QuotaUsage
+	if err := c.do(ctx, request{method: http.MethodGet, path: "/api/quotas/usage", query: query}, &usage); err != nil {
+		return nil, err
+	}
+	return &usage, nil
+}
+
+// ListPortReservations returns the host ports reserved on a node, ordered by port
+func (c *Client) ListPortReservations(ctx context.Context, nodeID string) ([]*PortReservation, error) {
+	var reservations []*PortReservation
+	if err := c.do(ctx, request{method: http.MethodGet, path: "/api/ports", query: optionalNodeQuery(nodeID)}, &reservations); err != nil {
+		return nil, err
+	}
+	return reservations, nil
+}
+
+// ReservePort reserves a host port on a node by hand; port 0 picks the lowest free one
+func (c *Client) ReservePort(ctx context.Context, nodeID string, req ReservePortRequest) (*PortReservation, error) {
+	var reservation PortReservation
+	if err := c.do(ctx, request{method: http.MethodPost, path: "/api/ports", query: optionalNodeQuery(nodeID), body: req}, &reservation); err != nil {
+		return nil, err
+	}
+	return &reservation, nil
+}
+
+// ReleasePort deletes a manual port reservation on a node
+func (c *Client) ReleasePort(ctx context.Context, reservationID, nodeID string) error {
+	return c.do(ctx, request{method: http.MethodDelete, path: "/api/ports/" + escape(reservationID), query: optionalNodeQuery(nodeID)}, nil)
+}
+
+// ListUsers returns the invited users and the allowed users
+func (c *Client) ListUsers(ctx context.Context) (*UserList, error) {
+	var users UserList
+	if err := c.do(ctx, request{method: http.MethodGet, path: "/api/users"}, &users); err != nil {
+		return nil, err
+	}
+	return &users, nil
+}
+
+// DeleteUser deletes an invited user's account
+func (c *Client) DeleteUser(ctx context.Context, name string) error {
+	return c.do(ctx, request{method: http.MethodDelete, path: "/api/users/" + escape(name)}, nil)
+}
+
+// InviteUser creates a single-use invite link; its token and URL are only returned this once
+func (c *Client) InviteUser(ctx context.Context, req InviteUserRequest) (*IssuedInvitation, error) {
+	var invitation IssuedInvitation
+	if err := c.do(ctx, request{method: http.MethodPost, path: "/api/users/invite", body: req}, &invitation); err != nil {
+		return nil, err
+	}
+	return &invitation, nil
+}
+
+// ListInvitations returns every invitation with its status, newest first
+func (c *Client) ListInvitations(ctx context.Context) ([]*Invitation, error) {
+	var invitations []*Invitation
+	if err := c.do(ctx, request{method: http.MethodGet, path: "/api/users/invitations"}, &invitations); err != nil {
+		return nil, err
+	}
+	return invitations, nil
+}
+
+// RevokeInvitation revokes an invitation that hasn't been accepted
+func (c *Client) RevokeInvitation(ctx context.Context, id string) error {
+	return c.do(ctx, request{method: http.MethodDelete, path: "/api/users/invitations/" + escape(id)}, nil)
+}
+
+func containerPath(containerID, suffix string) string {
+	return "/api/system/containers/" + escape(containerID) + suffix
+}
+
+// optionalNodeQuery targets nodeID, or the node the client talks to when it is empty
+func optionalNodeQuery(nodeID string) url.Values {
+	if nodeID == "" {
+		return nil
+	}
+	return nodeQuery(nodeID)
+}
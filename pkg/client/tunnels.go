@@ -0,0 +1,179 @@
+package client
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+)
+
+// ListTunnelProviders lists the tunnel providers and which one is active
+func (c *Client) ListTunnelProviders(ctx context.Context) (*TunnelProviders, error) {
+	var providers TunnelProviders
+	if err := c.do(ctx, request{method: http.MethodGet, path: "/api/tunnels/providers"}, &providers); err != nil {
+		return nil, err
+	}
+	return &providers, nil
+}
+
+// GetProviderFeatures returns what a tunnel provider supports
+func (c *Client) GetProviderFeatures(ctx context.Context, provider string) (*ProviderFeatures, error) {
+	var features ProviderFeatures
+	if err := c.do(ctx, request{method: http.MethodGet, path: providerPath(provider, "/features")}, &features); err != nil {
+		return nil, err
+	}
+	return &features, nil
+}
+
+// ListProviderZones lists the DNS zones a tunnel provider can route hostnames in
+func (c *Client) ListProviderZones(ctx context.Context, provider string) (*ProviderZones, error) {
+	var zones ProviderZones
+	if err := c.do(ctx, request{method: http.MethodGet, path: providerPath(provider, "/zones")}, &zones); err != nil {
+		return nil, err
+	}
+	return &zones, nil
+}
+
+// ListZoneHostnames lists the DNS records in one of a provider's zones
+func (c *Client) ListZoneHostnames(ctx context.Context, provider, zoneID string) (*ZoneHostnames, error) {
+	var hostnames ZoneHostnames
+	if err := c.do(ctx, request{method: http.MethodGet, path: providerPath(provider, "/zones/"+escape(zoneID)+"/hostnames")}, &hostnames); err != nil {
+		return nil, err
+	}
+	return &hostnames, nil
+}
+
+// ListTunnels lists the active tunnels on nodeIDs, or on all nodes when empty
+func (c *Client) ListTunnels(ctx context.Context, nodeIDs []string) (*TunnelList, error) {
+	var list TunnelList
+	if err := c.do(ctx, request{method: http.MethodGet, path: "/api/tunnels", query: nodeIDsQuery(nodeIDs)}, &list); err != nil {
+		return nil, err
+	}
+	return &list, nil
+}
+
+// GetTunnelInventory compares the tunnels in the provider account with the apps using them,
+// across all nodes
+func (c *Client) GetTunnelInventory(ctx context.Context) (*TunnelInventory, error) {
+	var inventory TunnelInventory
+	query := url.Values{"inventory": {"true"}}
+	if err := c.do(ctx, request{method: http.MethodGet, path: "/api/tunnels", query: query}, &inventory); err != nil {
+		return nil, err
+	}
+	return &inventory, nil
+}
+
+// DeleteOrphanedTunnel deletes a tunnel no app uses any more from the provider account
+func (c *Client) DeleteOrphanedTunnel(ctx context.Context, tunnelID string) error {
+	return c.do(ctx, request{method: http.MethodDelete, path: "/api/tunnels/" + escape(tunnelID)}, nil)
+}
+
+// ImportTunnel adopts a tunnel that already exists in the provider account, for an existing app
+// or a new one, and returns that app
+func (c *Client) ImportTunnel(ctx context.Context, nodeID string, req ImportTunnelRequest) (*App, error) {
+	var app App
+	if err := c.do(ctx, request{method: http.MethodPost, path: "/api/tunnels/import", query: nodeQuery(nodeID), body: req}, &app); err != nil {
+		return nil, err
+	}
+	return &app, nil
+}
+
+// GetAppTunnel returns an app's tunnel mode and public URL
+func (c *Client) GetAppTunnel(ctx context.Context, appID, nodeID string) (*AppTunnel, error) {
+	var tunnel AppTunnel
+	if err := c.do(ctx, request{method: http.MethodGet, path: appTunnelPath(appID, ""), query: nodeQuery(nodeID)}, &tunnel); err != nil {
+		return nil, err
+	}
+	return &tunnel, nil
+}
+
+// GetAppTunnelStatus returns which connectors serve an app's named tunnel and whether any is
+// connected
+func (c *Client) GetAppTunnelStatus(ctx context.Context, appID, nodeID string) (*TunnelConnectorStatus, error) {
+	var status TunnelConnectorStatus
+	if err := c.do(ctx, request{method: http.MethodGet, path: appPath(appID, "/tunnel/status"), query: nodeQuery(nodeID)}, &status); err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
+
+// GetAppTraffic returns the requests, bandwidth and visits served for an app's tunnel hostnames over
+// period (1h, 24h or 7d; empty for 24h)
+func (c *Client) GetAppTraffic(ctx context.Context, appID, nodeID, period string) (*AppTraffic, error) {
+	query := nodeQuery(nodeID)
+	if period != "" {
+		query.Set("period", period)
+	}
+	var traffic AppTraffic
+	if err := c.do(ctx, request{method: http.MethodGet, path: appPath(appID, "/traffic"), query: query}, &traffic); err != nil {
+		return nil, err
+	}
+	return &traffic, nil
+}
+
+// CreateAppTunnel creates a named tunnel for an app that has none, in a background job
+func (c *Client) CreateAppTunnel(ctx context.Context, appID, nodeID string, rules []IngressRule) (*JobAccepted, error) {
+	return c.startJob(ctx, appTunnelPath(appID, ""), nodeID, ingressRulesBody(rules))
+}
+
+// SwitchAppToCustomTunnel replaces an app's Quick Tunnel with a named tunnel, in a background job
+func (c *Client) SwitchAppToCustomTunnel(ctx context.Context, appID, nodeID string, rules []IngressRule) (*JobAccepted, error) {
+	return c.startJob(ctx, appTunnelPath(appID, "/switch-to-custom"), nodeID, ingressRulesBody(rules))
+}
+
+// SyncAppTunnel refreshes an app's tunnel status from the provider
+func (c *Client) SyncAppTunnel(ctx context.Context, appID, nodeID string) error {
+	return c.do(ctx, request{method: http.MethodPost, path: appTunnelPath(appID, "/sync"), query: nodeQuery(nodeID)}, nil)
+}
+
+// SetAppTunnelReplicas sets how many containers run an app's named tunnel
+func (c *Client) SetAppTunnelReplicas(ctx context.Context, appID, nodeID string, replicas int) error {
+	body := map[string]int{"replicas": replicas}
+	return c.do(ctx, request{method: http.MethodPut, path: appTunnelPath(appID, "/replicas"), query: nodeQuery(nodeID), body: body}, nil)
+}
+
+// SetAppTunnelOptions saves connector options (e.g. protocol http2 where QUIC is blocked) on an
+// app's named tunnel and restarts its tunnel containers with them
+func (c *Client) SetAppTunnelOptions(ctx context.Context, appID, nodeID string, options TunnelOptions) error {
+	return c.do(ctx, request{method: http.MethodPut, path: appTunnelPath(appID, "/options"), query: nodeQuery(nodeID), body: options}, nil)
+}
+
+// UpdateAppTunnelIngress replaces the ingress rules of an app's named tunnel
+func (c *Client) UpdateAppTunnelIngress(ctx context.Context, appID, nodeID string, req UpdateIngressRequest) (*IngressUpdate, error) {
+	var update IngressUpdate
+	if err := c.do(ctx, request{method: http.MethodPut, path: appTunnelPath(appID, "/ingress"), query: nodeQuery(nodeID), body: req}, &update); err != nil {
+		return nil, err
+	}
+	return &update, nil
+}
+
+// CreateAppDNSRecord routes hostname to an app's named tunnel
+func (c *Client) CreateAppDNSRecord(ctx context.Context, appID, nodeID, hostname string) error {
+	body := map[string]string{"hostname": hostname}
+	return c.do(ctx, request{method: http.MethodPost, path: appTunnelPath(appID, "/dns"), query: nodeQuery(nodeID), body: body}, nil)
+}
+
+// DeleteAppTunnel removes an app's tunnel in a background job
+func (c *Client) DeleteAppTunnel(ctx context.Context, appID, nodeID string, opts DeleteTunnelOptions) (*JobAccepted, error) {
+	query := nodeQuery(nodeID)
+	setBool(query, "quick_tunnel", opts.QuickTunnelFallback)
+	var accepted JobAccepted
+	if err := c.do(ctx, request{method: http.MethodDelete, path: appTunnelPath(appID, ""), query: query}, &accepted); err != nil {
+		return nil, err
+	}
+	return &accepted, nil
+}
+
+func providerPath(provider, suffix string) string {
+	return "/api/tunnels/providers/" + escape(provider) + suffix
+}
+
+func appTunnelPath(appID, suffix string) string {
+	return "/api/tunnels/apps/" + escape(appID) + suffix
+}
+
+func ingressRulesBody(rules []IngressRule) interface{} {
+	if len(rules) == 0 {
+		return nil
+	}
+	return map[string][]IngressRule{"ingress_rules": rules}
+}
@@ -0,0 +1,360 @@
+package client
+
+import (
+	"time"
+
+	"github.com/selfhostly/internal/db"
+	"github.com/selfhostly/internal/docker"
+	"github.com/selfhostly/internal/domain"
+	"github.com/selfhostly/internal/system"
+	"github.com/selfhostly/internal/tunnel"
+)
+
+// Types the server encodes directly are aliases of its own, so the client can't drift from the
+// API it wraps. Responses the handlers assemble themselves are declared below.
+type (
+	App                      = db.App
+	AppSchedule              = db.AppSchedule
+	AppWebhook               = db.AppWebhook
+	AppTask                  = db.AppTask
+	AppTaskRun               = db.AppTaskRun
+	ComposeVersion           = db.ComposeVersion
+	AppSnapshot              = db.AppSnapshot
+	CloudflareTunnel         = db.CloudflareTunnel
+	IngressRule              = db.IngressRule
+	Job                      = db.Job
+	QueuedOperation          = db.QueuedOperation
+	SettingsChange           = db.SettingsChange
+	UserPreferences          = db.UserPreferences
+	OperationsLock           = db.OperationsLock
+	Approval                 = db.Approval
+	UserQuota                = db.UserQuota
+	PortReservation          = db.PortReservation
+	UserAccount              = db.User
+	Invitation               = db.Invitation
+	Session                  = db.Session
+	AppStatusPage            = db.AppStatusPage
+	NodeMetric               = db.NodeMetric
+	NodeAlert                = db.NodeAlert
+	NodeLog                  = db.NodeLog
+	GatewayToken             = db.GatewayToken
+	DatabaseStats            = db.Stats
+	MaintenanceRun           = db.MaintenanceRun
+	CreateAppRequest         = domain.CreateAppRequest
+	UpdateAppRequest         = domain.UpdateAppRequest
+	ComposePreviewRequest    = domain.ComposePreviewRequest
+	ComposeIntegrity         = domain.ComposeIntegrity
+	DeleteAppStep            = domain.DeleteAppStep
+	AppStats                 = domain.AppStats
+	AppContainerHealth       = domain.AppContainerHealth
+	ContainerHealth          = domain.ContainerHealth
+	ScheduleNextRuns         = domain.ScheduleNextRuns
+	CreateWebhookRequest     = domain.CreateWebhookRequest
+	UpdateWebhookRequest     = domain.UpdateWebhookRequest
+	CreateTaskRequest        = domain.CreateTaskRequest
+	UpdateTaskRequest        = domain.UpdateTaskRequest
+	CreateSnapshotRequest    = domain.CreateSnapshotRequest
+	AppLogForwarding         = domain.AppLogForwarding
+	IssuedStatusPage         = domain.IssuedStatusPage
+	AppStatusReport          = domain.AppStatusReport
+	SetLogForwardingRequest  = domain.SetLogForwardingRequest
+	UpdatePreferencesRequest = domain.UpdatePreferencesRequest
+	OperationsLockStatus     = domain.OperationsLockStatus
+	LockOperationsRequest    = domain.LockOperationsRequest
+	SetQuotaRequest          = domain.SetQuotaRequest
+	QuotaUsage               = domain.QuotaUsage
+	ReservePortRequest       = domain.ReservePortRequest
+	InviteUserRequest        = domain.InviteUserRequest
+	IssuedInvitation         = domain.IssuedInvitation
+	LogSearchMatch           = domain.LogSearchMatch
+	AppManifest              = domain.AppManifest
+	ManifestApp              = domain.ManifestApp
+	ApplyResult              = domain.ApplyResult
+	ApplyAction              = domain.ApplyAction
+	ProviderInfo             = domain.ProviderInfo
+	ProviderFeatures         = domain.ProviderFeatures
+	TunnelInventory          = domain.TunnelInventory
+	TunnelConnectorStatus    = domain.TunnelConnectorStatus
+	AppTraffic               = domain.AppTraffic
+	ImportTunnelRequest      = domain.ImportTunnelRequest
+	UpdateIngressRequest     = domain.UpdateIngressRequest
+	TelemetryReport          = domain.TelemetryReport
+	FeatureFlag              = domain.FeatureFlag
+	SettingsSectionSchema    = domain.SettingsSectionSchema
+	SettingsSection          = domain.SettingsSection
+	HealthReport             = domain.HealthReport
+	AuditReport              = domain.AuditReport
+	ConfigReload             = domain.ConfigReload
+	LogSettings              = domain.LogSettings
+	UpdateLogSettingsRequest = domain.UpdateLogSettingsRequest
+	RegisterNodeRequest      = domain.RegisterNodeRequest
+	UpdateNodeRequest        = domain.UpdateNodeRequest
+	QueueOperationRequest    = domain.QueueOperationRequest
+	RemoveNodeRequest        = domain.RemoveNodeRequest
+	RemoveNodeResult         = domain.RemoveNodeResult
+	RemovedNodeApp           = domain.RemovedNodeApp
+	IssueGatewayTokenRequest = domain.IssueGatewayTokenRequest
+	IssuedGatewayToken       = domain.IssuedGatewayToken
+	GenerateSecretRequest    = domain.GenerateSecretRequest
+	GeneratedSecret          = domain.GeneratedSecret
+	RunAppCommandRequest     = domain.RunAppCommandRequest
+	SystemStats              = system.SystemStats
+	Zone                     = tunnel.Zone
+	Hostname                 = tunnel.Hostname
+	TunnelOptions            = tunnel.ConnectorOptions
+	IngressWarning           = domain.IngressWarning
+	ComposePreview           = domain.ComposePreview
+	ComposeVariable          = domain.ComposeVariable
+	CommandResult            = docker.CommandResult
+)
+
+// ServerHealth is the unauthenticated liveness response of /api/health
+type ServerHealth struct {
+	Status     string `json:"status"`
+	Service    string `json:"service"`
+	Version    string `json:"version"`
+	APIVersion int    `json:"api_version"`
+}
+
+// User is the signed-in user
+type User struct {
+	ID      string `json:"id"`
+	Name    string `json:"name"`
+	Picture string `json:"picture"`
+	Role    string `json:"role,omitempty"` // admin or viewer; empty for tokens issued before roles
+}
+
+// Message is the acknowledgement returned by operations that have nothing else to report
+type Message struct {
+	Message string `json:"message"`
+}
+
+// JobAccepted is the 202 response of operations that run as a background job. Poll GetJob with
+// JobID to follow it.
+type JobAccepted struct {
+	JobID   string `json:"job_id"`
+	AppID   string `json:"app_id,omitempty"`
+	Status  string `json:"status"`
+	Message string `json:"message"`
+}
+
+// DeleteAppOptions controls DeleteApp
+type DeleteAppOptions struct {
+	Archive       bool     // Keep a tarball of the app directory in the trash
+	DryRun        bool     // Report what would be removed without removing anything
+	SkipSteps     []string // Cleanup step IDs to leave out, e.g. "tunnel"
+	RemoveVolumes bool     // Also remove the app's named volumes, which are kept by default
+	Force         bool     // Delete the app record even if cleaning up its containers, tunnel etc. fails
+}
+
+// DeleteTunnelOptions controls DeleteAppTunnel
+type DeleteTunnelOptions struct {
+	QuickTunnelFallback bool // Replace the tunnel with a Quick Tunnel to the service its ingress pointed at
+}
+
+// DeleteAppResult reports what deleting an app removed and left behind
+type DeleteAppResult struct {
+	Message          string           `json:"message"`
+	AppID            string           `json:"appID"`
+	DryRun           bool             `json:"dry_run,omitempty"`
+	ArchivePath      string           `json:"archive_path,omitempty"`
+	ArchiveExpiresAt *time.Time       `json:"archive_expires_at,omitempty"`
+	Steps            []*DeleteAppStep `json:"steps"`
+	Approval         *Approval        `json:"approval,omitempty"` // Set instead of Steps while the deletion awaits confirmation (APPROVAL_REQUIRED)
+}
+
+// ScheduleRequest sets an app's start/stop schedule; Timezone defaults to UTC
+type ScheduleRequest struct {
+	StartCron string `json:"start_cron"`
+	StopCron  string `json:"stop_cron"`
+	Timezone  string `json:"timezone,omitempty"`
+	Enabled   bool   `json:"enabled"`
+}
+
+// RollbackRequest controls RollbackApp. Containers are recreated from the restored version
+// unless RestartContainers is false.
+type RollbackRequest struct {
+	ChangeReason      *string `json:"change_reason,omitempty"`
+	RestartContainers *bool   `json:"restart_containers,omitempty"`
+}
+
+// RollbackResult is the compose version a rollback created and, when containers are being
+// updated, the job doing it
+type RollbackResult struct {
+	Message     string          `json:"message"`
+	NewVersion  *ComposeVersion `json:"new_version"`
+	FromVersion int             `json:"from_version"`
+	JobID       string          `json:"job_id,omitempty"`
+	Job         *Job            `json:"job,omitempty"`
+	App         *App            `json:"app"`
+}
+
+// RestoreSnapshotRequest controls RestoreAppSnapshot. The app is redeployed from the snapshot
+// unless RestartContainers is false.
+type RestoreSnapshotRequest struct {
+	RestartContainers *bool `json:"restart_containers,omitempty"`
+}
+
+// RestoreSnapshotResult is the compose version a snapshot restore created and, when containers
+// are being updated, the job doing it
+type RestoreSnapshotResult struct {
+	Message    string          `json:"message"`
+	NewVersion *ComposeVersion `json:"new_version"`
+	JobID      string          `json:"job_id,omitempty"`
+	Job        *Job            `json:"job,omitempty"`
+	App        *App            `json:"app"`
+}
+
+// LogSearchOptions narrows SearchLogs
+type LogSearchOptions struct {
+	Query        string
+	Apps         []string // App IDs or names; all apps when empty
+	Since        string   // Relative duration ("15m") or RFC3339 timestamp
+	ContextLines int
+	NodeIDs      []string // All nodes when empty
+}
+
+// ListJobsOptions narrows ListJobs
+type ListJobsOptions struct {
+	Status  string   // pending, running, completed or failed; all when empty
+	Type    string   // e.g. "app_update"; all when empty
+	Limit   int      // Most jobs to return; the server's default when 0
+	NodeIDs []string // All nodes when empty
+}
+
+// ListAppsOptions narrows ListApps
+type ListAppsOptions struct {
+	NodeIDs   []string // All nodes when empty
+	Selector  string   // Label selector, e.g. "env=prod,team!=media"
+	Reconcile bool     // Check stored statuses against docker
+	Fields    []string // Only these app fields are returned (e.g. "id", "name", "status"); all when empty
+}
+
+// TunnelProviders lists the tunnel providers and which one is active
+type TunnelProviders struct {
+	Providers []ProviderInfo `json:"providers"`
+	Active    string         `json:"active"`
+}
+
+// ProviderZones lists the DNS zones a tunnel provider can route hostnames in
+type ProviderZones struct {
+	Provider string  `json:"provider"`
+	Zones    []*Zone `json:"zones"`
+}
+
+// ZoneHostnames lists the DNS records in a zone
+type ZoneHostnames struct {
+	ZoneID    string      `json:"zone_id"`
+	Hostnames []*Hostname `json:"hostnames"`
+}
+
+// TunnelList is the active tunnels on the requested nodes
+type TunnelList struct {
+	Tunnels []*CloudflareTunnel `json:"tunnels"`
+	Count   int                 `json:"count"`
+}
+
+// AppTunnel is an app's tunnel mode and public URL, with its named tunnel when it has one
+type AppTunnel struct {
+	Tunnel     *AppTunnelDetails `json:"tunnel"`
+	AppID      string            `json:"app_id"`
+	TunnelMode string            `json:"tunnel_mode"`
+	NodeID     string            `json:"node_id"`
+	PublicURL  string            `json:"public_url"`
+}
+
+// AppTunnelDetails describes an app's named tunnel
+type AppTunnelDetails struct {
+	ID           string         `json:"id"`
+	AppID        string         `json:"app_id"`
+	TunnelID     string         `json:"tunnel_id"`
+	TunnelName   string         `json:"tunnel_name"`
+	Status       string         `json:"status"`
+	IsActive     bool           `json:"is_active"`
+	PublicURL    string         `json:"public_url"`
+	IngressRules []IngressRule  `json:"ingress_rules"`
+	CreatedAt    time.Time      `json:"created_at"`
+	UpdatedAt    time.Time      `json:"updated_at"`
+	LastSyncedAt *time.Time     `json:"last_synced_at"`
+	ErrorDetails string         `json:"error_details"`
+	Replicas     int            `json:"replicas"` // Containers running the tunnel
+	Options      *TunnelOptions `json:"options"`  // Connector flags; nil keeps the provider's defaults
+}
+
+// IngressUpdate is the saved rules, with warnings for rules that point at services the app's
+// compose doesn't define
+type IngressUpdate struct {
+	Message      string           `json:"message"`
+	IngressRules []IngressRule    `json:"ingress_rules"`
+	Warnings     []IngressWarning `json:"warnings"`
+}
+
+// Settings are the global settings; secrets in TunnelProviderConfig are masked
+type Settings struct {
+	ID                   string    `json:"id"`
+	AutoStartApps        bool      `json:"auto_start_apps"`
+	ActiveTunnelProvider string    `json:"active_tunnel_provider"`
+	TunnelProviderConfig string    `json:"tunnel_provider_config"`
+	TelemetryEnabled     bool      `json:"telemetry_enabled"`
+	UpdatedAt            time.Time `json:"updated_at"`
+}
+
+// UpdateSettingsRequest changes the global settings. Empty provider fields and a nil
+// TelemetryEnabled are left unchanged.
+type UpdateSettingsRequest struct {
+	AutoStartApps        bool   `json:"auto_start_apps"`
+	ActiveTunnelProvider string `json:"active_tunnel_provider,omitempty"`
+	TunnelProviderConfig string `json:"tunnel_provider_config,omitempty"`
+	TelemetryEnabled     *bool  `json:"telemetry_enabled,omitempty"`
+}
+
+// SettingsHistory is the recent changes to one settings section
+type SettingsHistory struct {
+	Section string            `json:"section"`
+	History []*SettingsChange `json:"history"`
+}
+
+// UserList is the accounts of invited users, and the users in GITHUB_ALLOWED_USERS, who have none
+type UserList struct {
+	Users        []*UserAccount `json:"users"`
+	AllowedUsers []string       `json:"allowed_users"`
+}
+
+// Node is a node of the cluster. API keys are never returned.
+type Node struct {
+	ID             string     `json:"id"`
+	Name           string     `json:"name"`
+	APIEndpoint    string     `json:"api_endpoint"`
+	IsPrimary      bool       `json:"is_primary"`
+	Status         string     `json:"status"`
+	LastSeen       *time.Time `json:"last_seen"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+	Version        string     `json:"version"`
+	APIVersion     int        `json:"api_version"`
+	VersionWarning string     `json:"version_warning,omitempty"`
+	// ActiveAlerts are the node's metrics currently over their alert threshold
+	ActiveAlerts []*NodeAlert `json:"active_alerts,omitempty"`
+}
+
+// QueuedOperationResult is the response to QueueNodeOperation
+type QueuedOperationResult struct {
+	Message   string           `json:"message"`
+	Queued    bool             `json:"queued"`
+	Operation *QueuedOperation `json:"operation"`
+}
+
+// NodeCheck is the outcome of a manual node health check, with the node's updated status. Error
+// is set when the node could not be reached.
+type NodeCheck struct {
+	Message string `json:"message"`
+	Node    *Node  `json:"node"`
+	Error   string `json:"error,omitempty"`
+}
+
+// NodeMetrics is a node's metrics history, sampled once a minute
+type NodeMetrics struct {
+	NodeID  string        `json:"node_id"`
+	Since   time.Time     `json:"since"`
+	Metrics []*NodeMetric `json:"metrics"`
+}
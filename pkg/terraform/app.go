@@ -0,0 +1,181 @@
+package terraform
+
+import (
+	"context"
+
+	"github.com/selfhostly/pkg/client"
+)
+
+// AppModel is the plan and state of a selfhostly_app resource
+type AppModel struct {
+	ID                 string            `tfsdk:"id"`
+	NodeID             string            `tfsdk:"node_id"`
+	Name               string            `tfsdk:"name"`
+	Description        string            `tfsdk:"description"`
+	ComposeContent     string            `tfsdk:"compose_content"`
+	ComposeOverride    string            `tfsdk:"compose_override"`
+	ComposeFiles       map[string]string `tfsdk:"compose_files"`
+	EnvTemplate        string            `tfsdk:"env_template"`
+	Labels             map[string]string `tfsdk:"labels"`
+	StorageRoot        string            `tfsdk:"storage_root"`
+	ApprovePolicies    []string          `tfsdk:"approve_policies"`
+	TunnelMode         string            `tfsdk:"tunnel_mode"`
+	QuickTunnelService string            `tfsdk:"quick_tunnel_service"`
+	QuickTunnelPort    int64             `tfsdk:"quick_tunnel_port"`
+	Status             string            `tfsdk:"status"`
+	PublicURL          string            `tfsdk:"public_url"`
+	Owner              string            `tfsdk:"owner"`
+}
+
+// AppSchema describes AppModel
+var AppSchema = []Attribute{
+	{Name: "id", Type: TypeString, Computed: true, Description: "App ID."},
+	{Name: "node_id", Type: TypeString, Optional: true, Computed: true, ForceNew: true, Description: "Node to deploy the app on. Defaults to the primary."},
+	{Name: "name", Type: TypeString, Required: true, ForceNew: true, Description: "App name; the app directory is named after it."},
+	{Name: "description", Type: TypeString, Optional: true},
+	{Name: "compose_content", Type: TypeString, Required: true, Description: "docker-compose.yml content."},
+	{Name: "compose_override", Type: TypeString, Optional: true, Description: "docker-compose.override.yml content; empty removes the file."},
+	{Name: "compose_files", Type: TypeStringMap, Optional: true, Description: "Extra compose files for include: and extends:, by path relative to the app directory."},
+	{Name: "env_template", Type: TypeString, Optional: true, Description: "Managed .env template; empty removes it. The rendered file holds generated secrets and is never read back."},
+	{Name: "labels", Type: TypeStringMap, Optional: true},
+	{Name: "storage_root", Type: TypeString, Optional: true, ForceNew: true, Description: "Named storage root from STORAGE_ROOTS."},
+	{Name: "approve_policies", Type: TypeStringList, Optional: true, WriteOnly: true, Description: "Compose policies in approval mode to approve; only takes effect for an admin."},
+	{Name: "tunnel_mode", Type: TypeString, Optional: true, ForceNew: true, Description: "custom, quick or empty for no tunnel."},
+	{Name: "quick_tunnel_service", Type: TypeString, Optional: true, WriteOnly: true, Description: "Service a quick tunnel points at; set on create."},
+	{Name: "quick_tunnel_port", Type: TypeNumber, Optional: true, WriteOnly: true, Description: "Port a quick tunnel points at; set on create."},
+	{Name: "status", Type: TypeString, Computed: true},
+	{Name: "public_url", Type: TypeString, Computed: true},
+	{Name: "owner", Type: TypeString, Computed: true},
+}
+
+// AppDeleteOptions are the provider-level options for deleting apps, both off by default as in
+// the API
+type AppDeleteOptions struct {
+	Archive       bool // Keep a tarball of the app directory in the trash
+	RemoveVolumes bool // Also remove the app's named volumes
+}
+
+// AppResource manages apps
+type AppResource struct {
+	client *client.Client
+	delete AppDeleteOptions
+}
+
+// NewAppResource creates the selfhostly_app resource
+func NewAppResource(c *client.Client, opts AppDeleteOptions) *AppResource {
+	return &AppResource{client: c, delete: opts}
+}
+
+// Create creates the planned app and waits for it, rather than queueing a job to poll
+func (r *AppResource) Create(ctx context.Context, plan AppModel) (AppModel, error) {
+	app, err := r.client.CreateApp(ctx, client.CreateAppRequest{
+		Name:               plan.Name,
+		Description:        plan.Description,
+		ComposeContent:     plan.ComposeContent,
+		ComposeOverride:    plan.ComposeOverride,
+		NodeID:             plan.NodeID,
+		TunnelMode:         plan.TunnelMode,
+		QuickTunnelService: plan.QuickTunnelService,
+		QuickTunnelPort:    int(plan.QuickTunnelPort),
+		Labels:             plan.Labels,
+		ComposeFiles:       plan.ComposeFiles,
+		EnvTemplate:        plan.EnvTemplate,
+		StorageRoot:        plan.StorageRoot,
+		ApprovePolicies:    plan.ApprovePolicies,
+	})
+	if err != nil {
+		return plan, err
+	}
+	return appModel(app, plan), nil
+}
+
+// Read refreshes state from the app, returning nil when it no longer exists so it is removed
+// from state
+func (r *AppResource) Read(ctx context.Context, state AppModel) (*AppModel, error) {
+	app, err := r.client.GetApp(ctx, state.ID, state.NodeID)
+	if err != nil {
+		if client.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	model := appModel(app, state)
+	return &model, nil
+}
+
+// Update saves the planned compose, override, extra files, .env template and labels. Empty values
+// are sent as such, so removing them from the configuration removes them from the app.
+func (r *AppResource) Update(ctx context.Context, plan, state AppModel) (AppModel, error) {
+	composeFiles, labels := plan.ComposeFiles, plan.Labels
+	if composeFiles == nil {
+		composeFiles = map[string]string{}
+	}
+	if labels == nil {
+		labels = map[string]string{}
+	}
+	app, err := r.client.UpdateApp(ctx, state.ID, state.NodeID, client.UpdateAppRequest{
+		Name:            plan.Name,
+		Description:     plan.Description,
+		ComposeContent:  plan.ComposeContent,
+		ComposeOverride: &plan.ComposeOverride,
+		Labels:          labels,
+		ComposeFiles:    composeFiles,
+		EnvTemplate:     &plan.EnvTemplate,
+		ApprovePolicies: plan.ApprovePolicies,
+	})
+	if err != nil {
+		return state, err
+	}
+	return appModel(app, plan), nil
+}
+
+// Delete deletes the app with its containers and tunnel. An app that is already gone counts as
+// deleted; a deletion waiting for approval returns a PendingApprovalError.
+func (r *AppResource) Delete(ctx context.Context, state AppModel) error {
+	result, err := r.client.DeleteApp(ctx, state.ID, state.NodeID, client.DeleteAppOptions{
+		Archive:       r.delete.Archive,
+		RemoveVolumes: r.delete.RemoveVolumes,
+	})
+	if err != nil {
+		if client.IsNotFound(err) {
+			return nil
+		}
+		return err
+	}
+	if result.Approval != nil {
+		return &PendingApprovalError{ApprovalID: result.Approval.ID}
+	}
+	return nil
+}
+
+// ImportState returns the state to Read for an import ID of the form <node_id>/<app_id>
+func (r *AppResource) ImportState(id string) (AppModel, error) {
+	nodeID, appID, err := parseImportID(id)
+	if err != nil {
+		return AppModel{}, err
+	}
+	return AppModel{ID: appID, NodeID: nodeID}, nil
+}
+
+// appModel is the state for app, keeping the write-only attributes of prior
+func appModel(app *client.App, prior AppModel) AppModel {
+	return AppModel{
+		ID:                 app.ID,
+		NodeID:             app.NodeID,
+		Name:               app.Name,
+		Description:        app.Description,
+		ComposeContent:     app.ComposeContent,
+		ComposeOverride:    app.ComposeOverride,
+		ComposeFiles:       app.ComposeFiles,
+		EnvTemplate:        app.EnvTemplate,
+		Labels:             app.Labels,
+		StorageRoot:        app.StorageRoot,
+		ApprovePolicies:    prior.ApprovePolicies,
+		TunnelMode:         app.TunnelMode,
+		QuickTunnelService: prior.QuickTunnelService,
+		QuickTunnelPort:    prior.QuickTunnelPort,
+		Status:             app.Status,
+		PublicURL:          app.PublicURL,
+		Owner:              app.Owner,
+	}
+}
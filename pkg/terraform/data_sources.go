@@ -0,0 +1,95 @@
+package terraform
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/selfhostly/pkg/client"
+)
+
+// NodeModel is the selfhostly_node data source, looked up by name
+type NodeModel struct {
+	Name        string `tfsdk:"name"`
+	ID          string `tfsdk:"id"`
+	APIEndpoint string `tfsdk:"api_endpoint"`
+	IsPrimary   bool   `tfsdk:"is_primary"`
+	Status      string `tfsdk:"status"`
+	Version     string `tfsdk:"version"`
+}
+
+// NodeSchema describes NodeModel. Nodes register themselves, so there is no node resource: one
+// would have to keep node API keys in state.
+var NodeSchema = []Attribute{
+	{Name: "name", Type: TypeString, Required: true},
+	{Name: "id", Type: TypeString, Computed: true},
+	{Name: "api_endpoint", Type: TypeString, Computed: true},
+	{Name: "is_primary", Type: TypeBool, Computed: true},
+	{Name: "status", Type: TypeString, Computed: true},
+	{Name: "version", Type: TypeString, Computed: true},
+}
+
+// NodeDataSource reads a node of the cluster
+type NodeDataSource struct {
+	client *client.Client
+}
+
+// NewNodeDataSource creates the selfhostly_node data source
+func NewNodeDataSource(c *client.Client) *NodeDataSource {
+	return &NodeDataSource{client: c}
+}
+
+// Read looks up the node named config.Name
+func (d *NodeDataSource) Read(ctx context.Context, config NodeModel) (NodeModel, error) {
+	nodes, err := d.client.ListNodes(ctx)
+	if err != nil {
+		return config, err
+	}
+	for _, node := range nodes {
+		if node.Name == config.Name {
+			return NodeModel{
+				Name:        node.Name,
+				ID:          node.ID,
+				APIEndpoint: node.APIEndpoint,
+				IsPrimary:   node.IsPrimary,
+				Status:      node.Status,
+				Version:     node.Version,
+			}, nil
+		}
+	}
+	return config, fmt.Errorf("no node named %q", config.Name)
+}
+
+// AppsModel is the selfhostly_apps data source
+type AppsModel struct {
+	Selector string     `tfsdk:"selector"`
+	Apps     []AppModel `tfsdk:"apps"`
+}
+
+// AppsSchema describes AppsModel; each app has the attributes of AppSchema
+var AppsSchema = []Attribute{
+	{Name: "selector", Type: TypeString, Optional: true, Description: "Label selector, e.g. env=prod,team!=media."},
+	{Name: "apps", Type: TypeObjectList, Computed: true},
+}
+
+// AppsDataSource lists apps on every node
+type AppsDataSource struct {
+	client *client.Client
+}
+
+// NewAppsDataSource creates the selfhostly_apps data source
+func NewAppsDataSource(c *client.Client) *AppsDataSource {
+	return &AppsDataSource{client: c}
+}
+
+// Read lists the apps matching config.Selector, or every app when it is empty
+func (d *AppsDataSource) Read(ctx context.Context, config AppsModel) (AppsModel, error) {
+	apps, err := d.client.ListApps(ctx, client.ListAppsOptions{Selector: config.Selector})
+	if err != nil {
+		return config, err
+	}
+	config.Apps = make([]AppModel, 0, len(apps))
+	for _, app := range apps {
+		config.Apps = append(config.Apps, appModel(app, AppModel{}))
+	}
+	return config, nil
+}
@@ -0,0 +1,118 @@
+// Package terraform implements the resources and data sources of the selfhostly Terraform and
+// OpenTofu provider on top of pkg/client. It has no plugin SDK dependency: the provider binary
+// declares the schemas below with terraform-plugin-framework and copies plan and state values to
+// and from the models here, so everything that talks to the API is built and tested with the
+// server it calls. docs/TERRAFORM_PROVIDER.md describes the resource model.
+package terraform
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/selfhostly/pkg/client"
+)
+
+// Environment variables read when the provider block leaves the matching attribute unset
+const (
+	EnvEndpoint = "SELFHOSTLY_ENDPOINT"
+	EnvToken    = "SELFHOSTLY_TOKEN"
+)
+
+// AttributeType is the Terraform type of a schema attribute
+type AttributeType string
+
+// Attribute types used by the schemas
+const (
+	TypeString     AttributeType = "string"
+	TypeNumber     AttributeType = "number"
+	TypeBool       AttributeType = "bool"
+	TypeStringMap  AttributeType = "map(string)"
+	TypeStringList AttributeType = "list(string)"
+	TypeObjectList AttributeType = "list(object)" // Ingress rules, as their JSON fields
+)
+
+// Attribute describes one attribute of a resource or data source. Name matches the tfsdk tag of
+// the model field holding its value.
+type Attribute struct {
+	Name        string
+	Type        AttributeType
+	Required    bool
+	Optional    bool
+	Computed    bool
+	ForceNew    bool // Changing it replaces the resource
+	Sensitive   bool
+	WriteOnly   bool // Sent to the API but never read back, so state keeps the configured value
+	Description string
+}
+
+// ProviderConfig is the provider block
+type ProviderConfig struct {
+	Endpoint string `tfsdk:"endpoint"` // Gateway or primary URL
+	Token    string `tfsdk:"token"`    // API token (shp_...); empty when auth is disabled
+}
+
+// ProviderSchema describes ProviderConfig
+var ProviderSchema = []Attribute{
+	{Name: "endpoint", Type: TypeString, Optional: true, Description: "URL of the gateway, or of the primary in single-node setups. Defaults to " + EnvEndpoint + "."},
+	{Name: "token", Type: TypeString, Optional: true, Sensitive: true, Description: "API token. Defaults to " + EnvToken + "; not needed with AUTH_ENABLED=false."},
+}
+
+// ResourceSchemas are the provider's resources by type name
+var ResourceSchemas = map[string][]Attribute{
+	"selfhostly_app":            AppSchema,
+	"selfhostly_tunnel_ingress": TunnelIngressSchema,
+}
+
+// DataSourceSchemas are the provider's data sources by type name
+var DataSourceSchemas = map[string][]Attribute{
+	"selfhostly_node": NodeSchema,
+	"selfhostly_apps": AppsSchema,
+}
+
+// NewClient returns the API client for cfg, falling back to EnvEndpoint and EnvToken
+func NewClient(cfg ProviderConfig) (*client.Client, error) {
+	endpoint := cfg.Endpoint
+	if endpoint == "" {
+		endpoint = os.Getenv(EnvEndpoint)
+	}
+	if endpoint == "" {
+		return nil, fmt.Errorf("endpoint is required (or set %s)", EnvEndpoint)
+	}
+	token := cfg.Token
+	if token == "" {
+		token = os.Getenv(EnvToken)
+	}
+
+	var opts []client.Option
+	if token != "" {
+		opts = append(opts, client.WithAPIToken(token))
+	}
+	return client.New(endpoint, opts...), nil
+}
+
+// PendingApprovalError is returned by deletes that APPROVAL_REQUIRED turned into an approval. The
+// resource stays in state, so the next apply deletes it again once the approval is confirmed.
+type PendingApprovalError struct {
+	ApprovalID string
+}
+
+func (e *PendingApprovalError) Error() string {
+	return fmt.Sprintf("deletion awaits approval %s; confirm it and apply again", e.ApprovalID)
+}
+
+// IsPendingApproval reports whether err is a PendingApprovalError
+func IsPendingApproval(err error) bool {
+	var pending *PendingApprovalError
+	return errors.As(err, &pending)
+}
+
+// parseImportID splits an import ID of the form <node_id>/<app_id>
+func parseImportID(id string) (nodeID, appID string, err error) {
+	nodeID, appID, ok := strings.Cut(id, "/")
+	if !ok || nodeID == "" || appID == "" || strings.Contains(appID, "/") {
+		return "", "", fmt.Errorf("import ID %q must be <node_id>/<app_id>", id)
+	}
+	return nodeID, appID, nil
+}
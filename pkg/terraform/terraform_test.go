@@ -0,0 +1,232 @@
+package terraform
+
+import (
+	"context"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/selfhostly/internal/chaos"
+	"github.com/selfhostly/internal/config"
+	"github.com/selfhostly/internal/db"
+	httpserver "github.com/selfhostly/internal/http"
+	"github.com/selfhostly/internal/timeouts"
+	"github.com/selfhostly/pkg/client"
+)
+
+// setupTestServer serves a single-node primary with auth disabled and docker simulated
+func setupTestServer(t *testing.T) *client.Client {
+	t.Helper()
+
+	database, err := db.Init(filepath.Join(t.TempDir(), "selfhostly.db"))
+	if err != nil {
+		t.Fatalf("Failed to initialize database: %v", err)
+	}
+	t.Cleanup(func() { database.Close() })
+
+	cfg := &config.Config{
+		Environment: "test",
+		AppsDir:     t.TempDir(),
+		Node: config.NodeConfig{
+			ID:        "test-node-id",
+			Name:      "test-node",
+			IsPrimary: true,
+			APIKey:    "test-api-key",
+		},
+		Timeouts: timeouts.NewLive(timeouts.Default()),
+		Chaos:    chaos.Config{Enabled: true},
+	}
+	if err := database.InitNode(cfg); err != nil {
+		t.Fatalf("Failed to initialize node: %v", err)
+	}
+
+	ts := httptest.NewServer(httpserver.NewServer(cfg, database).Handler())
+	t.Cleanup(ts.Close)
+
+	c, err := NewClient(ProviderConfig{Endpoint: ts.URL})
+	if err != nil {
+		t.Fatalf("NewClient: %v", err)
+	}
+	return c
+}
+
+// TestSchemasMatchModels keeps every schema attribute backed by a model field and vice versa, so
+// the provider binary can copy values by tfsdk tag
+func TestSchemasMatchModels(t *testing.T) {
+	models := map[string]interface{}{
+		"provider":                  ProviderConfig{},
+		"selfhostly_app":            AppModel{},
+		"selfhostly_tunnel_ingress": TunnelIngressModel{},
+		"selfhostly_node":           NodeModel{},
+		"selfhostly_apps":           AppsModel{},
+	}
+	schemas := map[string][]Attribute{"provider": ProviderSchema}
+	for name, schema := range ResourceSchemas {
+		schemas[name] = schema
+	}
+	for name, schema := range DataSourceSchemas {
+		schemas[name] = schema
+	}
+
+	for name, schema := range schemas {
+		tags := map[string]bool{}
+		modelType := reflect.TypeOf(models[name])
+		for i := 0; i < modelType.NumField(); i++ {
+			tags[modelType.Field(i).Tag.Get("tfsdk")] = true
+		}
+		for _, attr := range schema {
+			if !tags[attr.Name] {
+				t.Errorf("%s: attribute %s has no model field", name, attr.Name)
+			}
+			if attr.Required && (attr.Optional || attr.Computed) || !attr.Required && !attr.Optional && !attr.Computed {
+				t.Errorf("%s: attribute %s must be either required or optional and/or computed", name, attr.Name)
+			}
+			delete(tags, attr.Name)
+		}
+		for tag := range tags {
+			t.Errorf("%s: model field %s is not in the schema", name, tag)
+		}
+	}
+}
+
+func TestAppResource_Lifecycle(t *testing.T) {
+	c := setupTestServer(t)
+	ctx := context.Background()
+	r := NewAppResource(c, AppDeleteOptions{})
+
+	plan := AppModel{
+		Name:            "blog",
+		Description:     "Personal blog",
+		ComposeContent:  "services:\n  web:\n    image: nginx:1.27\n",
+		ComposeOverride: "services:\n  web:\n    restart: always\n",
+		Labels:          map[string]string{"env": "prod"},
+		ApprovePolicies: []string{"devices"},
+	}
+	state, err := r.Create(ctx, plan)
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+	if state.ID == "" || state.NodeID != "test-node-id" || state.Status == "" {
+		t.Errorf("Expected computed attributes to be set, got %+v", state)
+	}
+	if state.ComposeOverride != plan.ComposeOverride || state.Labels["env"] != "prod" || len(state.ApprovePolicies) != 1 {
+		t.Errorf("Expected state to match the plan, got %+v", state)
+	}
+
+	// An imported app reads back to the same state, apart from write-only attributes
+	imported, err := r.ImportState(state.NodeID + "/" + state.ID)
+	if err != nil {
+		t.Fatalf("ImportState: %v", err)
+	}
+	read, err := r.Read(ctx, imported)
+	if err != nil || read == nil {
+		t.Fatalf("Read: %+v %v", read, err)
+	}
+	if read.Name != "blog" || read.ComposeContent != plan.ComposeContent || read.Labels["env"] != "prod" {
+		t.Errorf("Unexpected imported state %+v", read)
+	}
+
+	apps, err := NewAppsDataSource(c).Read(ctx, AppsModel{Selector: "env=prod"})
+	if err != nil {
+		t.Fatalf("AppsDataSource.Read: %v", err)
+	}
+	if len(apps.Apps) != 1 || apps.Apps[0].ID != state.ID {
+		t.Errorf("Expected the app to be labelled env=prod, got %+v", apps.Apps)
+	}
+
+	// Dropping the override and labels from the configuration removes them from the app
+	plan = state
+	plan.ComposeContent = "services:\n  web:\n    image: nginx:1.28\n"
+	plan.ComposeOverride = ""
+	plan.Labels = nil
+	state, err = r.Update(ctx, plan, state)
+	if err != nil {
+		t.Fatalf("Update: %v", err)
+	}
+	if state.ComposeContent != plan.ComposeContent || state.ComposeOverride != "" || len(state.Labels) != 0 {
+		t.Errorf("Expected the update to be applied, got %+v", state)
+	}
+
+	apps, err = NewAppsDataSource(c).Read(ctx, AppsModel{Selector: "env=prod"})
+	if err != nil {
+		t.Fatalf("AppsDataSource.Read: %v", err)
+	}
+	if len(apps.Apps) != 0 {
+		t.Errorf("Expected no apps labelled env=prod after the update, got %d", len(apps.Apps))
+	}
+
+	if err := r.Delete(ctx, state); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if read, err := r.Read(ctx, state); err != nil || read != nil {
+		t.Errorf("Expected a deleted app to leave state, got %+v %v", read, err)
+	}
+	if err := r.Delete(ctx, state); err != nil {
+		t.Errorf("Expected deleting a deleted app to succeed, got %v", err)
+	}
+}
+
+func TestTunnelIngressResource_AppWithoutTunnel(t *testing.T) {
+	c := setupTestServer(t)
+	ctx := context.Background()
+
+	app, err := NewAppResource(c, AppDeleteOptions{}).Create(ctx, AppModel{
+		Name:           "no-tunnel",
+		ComposeContent: "services:\n  web:\n    image: nginx\n",
+	})
+	if err != nil {
+		t.Fatalf("Create: %v", err)
+	}
+
+	r := NewTunnelIngressResource(c)
+	state, err := r.ImportState(app.NodeID + "/" + app.ID)
+	if err != nil {
+		t.Fatalf("ImportState: %v", err)
+	}
+	if read, err := r.Read(ctx, state); err != nil || read != nil {
+		t.Errorf("Expected an app without a tunnel to have no ingress state, got %+v %v", read, err)
+	}
+	if _, err := r.Create(ctx, state); err == nil {
+		t.Error("Expected ingress without rules to be refused")
+	}
+}
+
+func TestNodeDataSource(t *testing.T) {
+	c := setupTestServer(t)
+	d := NewNodeDataSource(c)
+
+	node, err := d.Read(context.Background(), NodeModel{Name: "test-node"})
+	if err != nil {
+		t.Fatalf("Read: %v", err)
+	}
+	if node.ID != "test-node-id" || !node.IsPrimary {
+		t.Errorf("Unexpected node %+v", node)
+	}
+	if _, err := d.Read(context.Background(), NodeModel{Name: "missing"}); err == nil {
+		t.Error("Expected an unknown node name to fail")
+	}
+}
+
+func TestParseImportID(t *testing.T) {
+	for _, id := range []string{"", "app-id", "/app-id", "node-id/", "node-id/app/extra"} {
+		if _, _, err := parseImportID(id); err == nil {
+			t.Errorf("parseImportID(%q): expected an error", id)
+		}
+	}
+	nodeID, appID, err := parseImportID("node-id/app-id")
+	if err != nil || nodeID != "node-id" || appID != "app-id" {
+		t.Errorf("parseImportID: got %q %q %v", nodeID, appID, err)
+	}
+}
+
+func TestNewClient_RequiresEndpoint(t *testing.T) {
+	t.Setenv(EnvEndpoint, "")
+	if _, err := NewClient(ProviderConfig{}); err == nil {
+		t.Error("Expected an error without an endpoint")
+	}
+	t.Setenv(EnvEndpoint, "https://selfhostly.example.com")
+	if _, err := NewClient(ProviderConfig{}); err != nil {
+		t.Errorf("Expected the endpoint to be read from %s, got %v", EnvEndpoint, err)
+	}
+}
@@ -0,0 +1,93 @@
+package terraform
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/selfhostly/pkg/client"
+)
+
+// TunnelIngressModel is the plan and state of a selfhostly_tunnel_ingress resource
+type TunnelIngressModel struct {
+	AppID        string               `tfsdk:"app_id"`
+	NodeID       string               `tfsdk:"node_id"`
+	IngressRules []client.IngressRule `tfsdk:"ingress_rules"`
+	Hostname     string               `tfsdk:"hostname"`
+	TargetDomain string               `tfsdk:"target_domain"`
+}
+
+// TunnelIngressSchema describes TunnelIngressModel
+var TunnelIngressSchema = []Attribute{
+	{Name: "app_id", Type: TypeString, Required: true, ForceNew: true},
+	{Name: "node_id", Type: TypeString, Required: true, ForceNew: true},
+	{Name: "ingress_rules", Type: TypeObjectList, Required: true, Description: "Rules with hostname, service and optional path and originRequest."},
+	{Name: "hostname", Type: TypeString, Optional: true, WriteOnly: true, Description: "Hostname to create a DNS record for."},
+	{Name: "target_domain", Type: TypeString, Optional: true, WriteOnly: true, Description: "Zone to create the DNS record in."},
+}
+
+// TunnelIngressResource manages the ingress rules of an app's custom tunnel. The tunnel itself
+// belongs to the app, which creates it with tunnel_mode = "custom" and removes it on delete.
+type TunnelIngressResource struct {
+	client *client.Client
+}
+
+// NewTunnelIngressResource creates the selfhostly_tunnel_ingress resource
+func NewTunnelIngressResource(c *client.Client) *TunnelIngressResource {
+	return &TunnelIngressResource{client: c}
+}
+
+// Create saves the planned rules
+func (r *TunnelIngressResource) Create(ctx context.Context, plan TunnelIngressModel) (TunnelIngressModel, error) {
+	return r.save(ctx, plan)
+}
+
+// Read refreshes the rules from the app's tunnel, returning nil when the app or its tunnel is gone
+func (r *TunnelIngressResource) Read(ctx context.Context, state TunnelIngressModel) (*TunnelIngressModel, error) {
+	tunnel, err := r.client.GetAppTunnel(ctx, state.AppID, state.NodeID)
+	if err != nil {
+		if client.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if tunnel.Tunnel == nil {
+		return nil, nil
+	}
+	state.IngressRules = tunnel.Tunnel.IngressRules
+	return &state, nil
+}
+
+// Update saves the planned rules
+func (r *TunnelIngressResource) Update(ctx context.Context, plan, _ TunnelIngressModel) (TunnelIngressModel, error) {
+	return r.save(ctx, plan)
+}
+
+// Delete only removes the resource from state; the tunnel keeps its rules until the app is deleted
+func (r *TunnelIngressResource) Delete(context.Context, TunnelIngressModel) error {
+	return nil
+}
+
+// ImportState returns the state to Read for an import ID of the form <node_id>/<app_id>
+func (r *TunnelIngressResource) ImportState(id string) (TunnelIngressModel, error) {
+	nodeID, appID, err := parseImportID(id)
+	if err != nil {
+		return TunnelIngressModel{}, err
+	}
+	return TunnelIngressModel{AppID: appID, NodeID: nodeID}, nil
+}
+
+func (r *TunnelIngressResource) save(ctx context.Context, plan TunnelIngressModel) (TunnelIngressModel, error) {
+	if len(plan.IngressRules) == 0 {
+		return plan, fmt.Errorf("ingress_rules needs at least one rule")
+	}
+	update, err := r.client.UpdateAppTunnelIngress(ctx, plan.AppID, plan.NodeID, client.UpdateIngressRequest{
+		IngressRules: plan.IngressRules,
+		Hostname:     plan.Hostname,
+		TargetDomain: plan.TargetDomain,
+	})
+	if err != nil {
+		return plan, err
+	}
+	plan.IngressRules = update.IngressRules
+	return plan, nil
+}
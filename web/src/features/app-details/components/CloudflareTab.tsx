@@ -6,10 +6,11 @@ import { Dialog, DialogContent, DialogHeader, DialogTitle, DialogFooter, DialogD
 import { Input } from '@/shared/components/ui/Input'
 import ConfirmationDialog from '@/shared/components/ui/ConfirmationDialog'
 import { RefreshCw, AlertCircle, AlertTriangle, CheckCircle2, Copy, Globe, Shield, ArrowRight, Clock, Trash2, Server, PlusCircle } from 'lucide-react'
-import { useTunnel, useSyncTunnel, useDeleteTunnel, useCreateTunnelForApp, useCreateQuickTunnelForApp, useSwitchAppToCustomTunnel } from '@/shared/services/api'
+import { useTunnel, useTunnelStatus, useSetTunnelReplicas, useSetTunnelOptions, useSyncTunnel, useDeleteTunnel, useCreateTunnelForApp, useCreateQuickTunnelForApp, useSwitchAppToCustomTunnel } from '@/shared/services/api'
 import { useToast } from '@/shared/components/ui/Toast'
 import { IngressConfiguration } from '@/features/cloudflare/IngressConfiguration'
-import type { IngressRule, TunnelByAppResponse } from '@/shared/types/api'
+import TrafficCard from './TrafficCard'
+import type { IngressRule, TunnelByAppResponse, TunnelOptions } from '@/shared/types/api'
 
 interface CloudflareTabProps {
     appId: string;
@@ -24,7 +25,10 @@ function isNoTunnelResponse(r: unknown): r is TunnelByAppResponse & { tunnel: nu
 
 function CloudflareTab({ appId, nodeId }: CloudflareTabProps) {
     const { data: tunnel, isLoading, error, refetch } = useTunnel(appId, nodeId)
+    const { data: connectorStatus } = useTunnelStatus(appId, nodeId, !!tunnel && !isNoTunnelResponse(tunnel))
     const syncTunnel = useSyncTunnel()
+    const setTunnelReplicas = useSetTunnelReplicas()
+    const setTunnelOptions = useSetTunnelOptions()
     const deleteTunnel = useDeleteTunnel()
     const createTunnel = useCreateTunnelForApp()
     const createQuickTunnel = useCreateQuickTunnelForApp()
@@ -43,6 +47,8 @@ function CloudflareTab({ appId, nodeId }: CloudflareTabProps) {
     const [quickTunnelService, setQuickTunnelService] = useState('')
     const [quickTunnelPort, setQuickTunnelPort] = useState<number | string>(80)
     const [quickTunnelFormError, setQuickTunnelFormError] = useState<string | null>(null)
+    const [replicasInput, setReplicasInput] = useState<number | string>('')
+    const [optionsInput, setOptionsInput] = useState<TunnelOptions | null>(null)
 
     const handleSync = () => {
         syncTunnel.mutate({ appId, nodeId }, {
@@ -56,6 +62,30 @@ function CloudflareTab({ appId, nodeId }: CloudflareTabProps) {
         })
     }
 
+    const handleSetReplicas = (replicas: number) => {
+        setTunnelReplicas.mutate({ appId, nodeId, replicas }, {
+            onSuccess: () => {
+                toast.success('Replicas Updated', `${replicas} container${replicas === 1 ? '' : 's'} now run this tunnel`)
+                setReplicasInput('')
+            },
+            onError: (error) => {
+                toast.error('Update Failed', error.message)
+            }
+        })
+    }
+
+    const handleSetOptions = (options: TunnelOptions) => {
+        setTunnelOptions.mutate({ appId, nodeId, options }, {
+            onSuccess: () => {
+                toast.success('Options Updated', 'cloudflared restarted with the new options')
+                setOptionsInput(null)
+            },
+            onError: (error) => {
+                toast.error('Update Failed', error.message)
+            }
+        })
+    }
+
     const handleDelete = () => {
         setShowDeleteDialog(true)
     }
@@ -630,6 +660,8 @@ function CloudflareTab({ appId, nodeId }: CloudflareTabProps) {
     }
 
     const tunnelData = tunnel.tunnel!
+    const tunnelOptions = optionsInput ?? tunnelData.options ?? {}
+    const updateTunnelOptions = (change: TunnelOptions) => setOptionsInput({ ...tunnelOptions, ...change })
     const tabs = [
         { id: 'overview' as TunnelTab, label: 'Overview', icon: Globe },
         { id: 'ingress' as TunnelTab, label: 'Ingress Rules', icon: Shield },
@@ -726,9 +758,104 @@ function CloudflareTab({ appId, nodeId }: CloudflareTabProps) {
                                     </div>
                                 </div>
                             </div>
+
+                            {/* Containers running the tunnel; several keep it up while one restarts */}
+                            <div className="pt-4 border-t text-sm">
+                                <p className="text-muted-foreground">Replicas</p>
+                                <div className="flex items-center gap-2 mt-1">
+                                    <Input
+                                        type="number"
+                                        min={1}
+                                        className="w-24"
+                                        value={replicasInput === '' ? tunnelData.replicas ?? 1 : replicasInput}
+                                        onChange={(e) => setReplicasInput(e.target.value)}
+                                    />
+                                    <Button
+                                        size="sm"
+                                        variant="outline"
+                                        disabled={replicasInput === '' || Number(replicasInput) < 1 || setTunnelReplicas.isPending}
+                                        onClick={() => handleSetReplicas(Number(replicasInput))}
+                                    >
+                                        Save
+                                    </Button>
+                                </div>
+                            </div>
+
+                            {/* cloudflared flags, e.g. HTTP/2 for networks that block QUIC */}
+                            <div className="pt-4 border-t text-sm">
+                                <p className="text-muted-foreground">Connector Options</p>
+                                <div className="flex flex-wrap items-center gap-2 mt-1">
+                                    <select
+                                        aria-label="Protocol"
+                                        value={tunnelOptions.protocol ?? ''}
+                                        onChange={(e) => updateTunnelOptions({ protocol: e.target.value as TunnelOptions['protocol'] })}
+                                        className="flex h-9 rounded-md border border-input bg-background px-2 py-1 text-sm ring-offset-background focus-visible:outline-none focus-visible:ring-2 focus-visible:ring-ring"
+                                    >
+                                        <option value="">Protocol: default</option>
+                                        <option value="auto">Protocol: auto</option>
+                                        <option value="quic">Protocol: QUIC</option>
+                                        <option value="http2">Protocol: HTTP/2</option>
+                                    </select>
+                                    <select
+                                        aria-label="Edge IP version"
+                                        value={tunnelOptions.edge_ip_version ?? ''}
+                                        onChange={(e) => updateTunnelOptions({ edge_ip_version: e.target.value as TunnelOptions['edge_ip_version'] })}
+                                        className="flex h-9 rounded-md border border-input bg-background px-2 py-1 text-sm ring-offset-background focus-visible:outline-none focus-visible:ring-2 focus-visible:ring-ring"
+                                    >
+                                        <option value="">Edge IP: default</option>
+                                        <option value="auto">Edge IP: auto</option>
+                                        <option value="4">Edge IP: IPv4</option>
+                                        <option value="6">Edge IP: IPv6</option>
+                                    </select>
+                                    <select
+                                        aria-label="Log level"
+                                        value={tunnelOptions.log_level ?? ''}
+                                        onChange={(e) => updateTunnelOptions({ log_level: e.target.value as TunnelOptions['log_level'] })}
+                                        className="flex h-9 rounded-md border border-input bg-background px-2 py-1 text-sm ring-offset-background focus-visible:outline-none focus-visible:ring-2 focus-visible:ring-ring"
+                                    >
+                                        <option value="">Log level: default</option>
+                                        {(['debug', 'info', 'warn', 'error', 'fatal'] as const).map(level => (
+                                            <option key={level} value={level}>Log level: {level}</option>
+                                        ))}
+                                    </select>
+                                    <label className="flex items-center gap-1">
+                                        <input
+                                            type="checkbox"
+                                            checked={!!tunnelOptions.post_quantum}
+                                            onChange={(e) => updateTunnelOptions({ post_quantum: e.target.checked })}
+                                        />
+                                        Post-quantum
+                                    </label>
+                                    <Button
+                                        size="sm"
+                                        variant="outline"
+                                        disabled={optionsInput === null || setTunnelOptions.isPending}
+                                        onClick={() => handleSetOptions(tunnelOptions)}
+                                    >
+                                        Save
+                                    </Button>
+                                </div>
+                            </div>
+
+                            {/* Connectors reported by Cloudflare */}
+                            {connectorStatus && (
+                                <div className="pt-4 border-t text-sm">
+                                    <p className="text-muted-foreground">Connectors</p>
+                                    <p className="font-medium mt-1">
+                                        {connectorStatus.state === 'healthy' &&
+                                            `${connectorStatus.connector_count} connected via ${connectorStatus.locations.join(', ')}`}
+                                        {connectorStatus.state === 'reconnecting' &&
+                                            `${connectorStatus.connector_count} reconnecting to Cloudflare`}
+                                        {connectorStatus.state === 'no_connector' &&
+                                            'None running: the tunnel exists but no cloudflared is connected to it'}
+                                    </p>
+                                </div>
+                            )}
                         </CardContent>
                     </Card>
 
+                    <TrafficCard appId={appId} nodeId={nodeId} />
+
                     {/* Action Cards */}
                     <div className="grid grid-cols-1 md:grid-cols-3 gap-4">
                         <Card className="card-hover">
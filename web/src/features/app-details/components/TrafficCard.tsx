@@ -0,0 +1,104 @@
+import { useState } from 'react'
+import { Card, CardHeader, CardTitle, CardContent } from '@/shared/components/ui/Card'
+import { Button } from '@/shared/components/ui/Button'
+import { Activity } from 'lucide-react'
+import { useAppTraffic } from '@/shared/services/api'
+import type { TrafficPeriod } from '@/shared/types/api'
+
+interface TrafficCardProps {
+    appId: string;
+    nodeId: string;
+}
+
+const periods: TrafficPeriod[] = ['1h', '24h', '7d']
+
+function formatBytes(bytes: number): string {
+    if (bytes === 0) return '0 B'
+    const k = 1024
+    const sizes = ['B', 'KB', 'MB', 'GB', 'TB']
+    const i = Math.floor(Math.log(bytes) / Math.log(k))
+    return `${(bytes / Math.pow(k, i)).toFixed(2)} ${sizes[i]}`
+}
+
+// Requests, bandwidth and visitors Cloudflare served for the app's tunnel hostnames
+function TrafficCard({ appId, nodeId }: TrafficCardProps) {
+    const [period, setPeriod] = useState<TrafficPeriod>('24h')
+    const { data: traffic, isLoading, error } = useAppTraffic(appId, nodeId, period)
+
+    return (
+        <Card>
+            <CardHeader className="flex flex-row items-center justify-between">
+                <CardTitle className="flex items-center gap-2">
+                    <Activity className="h-5 w-5" />
+                    Traffic
+                </CardTitle>
+                <div className="flex gap-1">
+                    {periods.map(p => (
+                        <Button key={p} size="sm" variant={p === period ? 'default' : 'outline'} onClick={() => setPeriod(p)}>
+                            {p}
+                        </Button>
+                    ))}
+                </div>
+            </CardHeader>
+            <CardContent className="space-y-4 text-sm">
+                {isLoading && <p className="text-muted-foreground">Loading traffic...</p>}
+                {error && <p className="text-muted-foreground">Traffic unavailable: {error.message}</p>}
+                {traffic && (
+                    <>
+                        <div className="grid grid-cols-3 gap-4">
+                            <div>
+                                <p className="text-muted-foreground">Requests</p>
+                                <p className="font-medium mt-1">{traffic.requests.toLocaleString()}</p>
+                            </div>
+                            <div>
+                                <p className="text-muted-foreground">Bandwidth</p>
+                                <p className="font-medium mt-1">{formatBytes(traffic.bytes)}</p>
+                            </div>
+                            <div>
+                                <p className="text-muted-foreground">Visits</p>
+                                <p className="font-medium mt-1">{traffic.visits.toLocaleString()}</p>
+                            </div>
+                        </div>
+
+                        {traffic.hostnames.length > 0 && (
+                            <div className="pt-4 border-t">
+                                <p className="text-muted-foreground">By hostname</p>
+                                {traffic.hostnames.map(h => (
+                                    <p key={h.hostname} className="font-medium mt-1">
+                                        {h.hostname}: {h.requests.toLocaleString()} requests, {formatBytes(h.bytes)}
+                                    </p>
+                                ))}
+                            </div>
+                        )}
+
+                        {traffic.countries.length > 0 && (
+                            <div className="pt-4 border-t">
+                                <p className="text-muted-foreground">Top countries</p>
+                                <p className="font-medium mt-1">
+                                    {traffic.countries.map(c => `${c.key} (${c.requests.toLocaleString()})`).join(', ')}
+                                </p>
+                            </div>
+                        )}
+
+                        {traffic.status_codes.length > 0 && (
+                            <div className="pt-4 border-t">
+                                <p className="text-muted-foreground">Status codes</p>
+                                <p className="font-medium mt-1">
+                                    {traffic.status_codes.map(s => `${s.key} (${s.requests.toLocaleString()})`).join(', ')}
+                                </p>
+                            </div>
+                        )}
+
+                        {traffic.untracked_hostnames.length > 0 && (
+                            <p className="text-muted-foreground">
+                                No analytics for {traffic.untracked_hostnames.join(', ')} (wildcard, or a zone the API token can't read)
+                            </p>
+                        )}
+                    </>
+                )}
+            </CardContent>
+        </Card>
+    )
+}
+
+export default TrafficCard
@@ -0,0 +1,267 @@
+import { useState } from 'react';
+import { Webhook, Plus, Trash2, Send, CheckCircle2, AlertCircle, KeyRound, Moon } from 'lucide-react';
+import { Card, CardHeader, CardTitle, CardContent } from '@/shared/components/ui/Card';
+import { Button } from '@/shared/components/ui/Button';
+import { Input } from '@/shared/components/ui/Input';
+import { Checkbox } from '@/shared/components/ui';
+import { Badge } from '@/shared/components/ui/Badge';
+import ConfirmationDialog from '@/shared/components/ui/ConfirmationDialog';
+import {
+  useAppWebhooks,
+  useCreateAppWebhook,
+  useUpdateAppWebhook,
+  useDeleteAppWebhook,
+  useTestAppWebhook,
+} from '@/shared/services/api';
+import { useToast } from '@/shared/components/ui/Toast';
+import { AppWebhook, WebhookDigest, WebhookEvent } from '@/shared/types/api';
+
+const EVENTS: { id: WebhookEvent; label: string }[] = [
+  { id: 'start', label: 'Start' },
+  { id: 'stop', label: 'Stop' },
+  { id: 'update', label: 'Update' },
+  { id: 'crash', label: 'Crash' },
+  { id: 'task_failed', label: 'Task failed' },
+];
+
+const DIGESTS: { id: WebhookDigest; label: string }[] = [
+  { id: '', label: 'Send events as they happen' },
+  { id: 'hourly', label: 'Hourly digest' },
+  { id: 'daily', label: 'Daily digest' },
+];
+
+interface WebhookEditorProps {
+  appId: string;
+  nodeId: string;
+}
+
+export function WebhookEditor({ appId, nodeId }: WebhookEditorProps) {
+  const { data: webhooks, isLoading } = useAppWebhooks(appId, nodeId);
+  const createWebhook = useCreateAppWebhook(appId, nodeId);
+  const updateWebhook = useUpdateAppWebhook(appId, nodeId);
+  const deleteWebhook = useDeleteAppWebhook(appId, nodeId);
+  const testWebhook = useTestAppWebhook(appId, nodeId);
+  const { toast } = useToast();
+
+  const [url, setUrl] = useState('');
+  const [events, setEvents] = useState<WebhookEvent[]>(['start', 'stop', 'update', 'crash']);
+  const [digest, setDigest] = useState<WebhookDigest>('');
+  const [quietStart, setQuietStart] = useState('');
+  const [quietEnd, setQuietEnd] = useState('');
+  const [newSecret, setNewSecret] = useState<string | null>(null);
+  const [deleteTarget, setDeleteTarget] = useState<AppWebhook | null>(null);
+
+  const toggleEvent = (event: WebhookEvent, checked: boolean) => {
+    setEvents(checked ? [...events, event] : events.filter((e) => e !== event));
+  };
+
+  const handleCreate = () => {
+    const quietHours = quietStart && quietEnd
+      ? { quiet_hours_start: quietStart, quiet_hours_end: quietEnd, timezone: Intl.DateTimeFormat().resolvedOptions().timeZone }
+      : {};
+    createWebhook.mutate({ url, events, digest, ...quietHours }, {
+      onSuccess: (webhook) => {
+        setUrl('');
+        setDigest('');
+        setQuietStart('');
+        setQuietEnd('');
+        setNewSecret(webhook.secret ?? null);
+        toast.success('Webhook added', 'Copy the signing secret now; it will not be shown again');
+      },
+      onError: (error: any) => {
+        toast.error('Failed to add webhook', error.message);
+      },
+    });
+  };
+
+  const handleTest = (webhook: AppWebhook) => {
+    testWebhook.mutate(webhook.id, {
+      onSuccess: (result) => {
+        if (result.last_error) {
+          toast.error('Test delivery failed', result.last_error);
+        } else {
+          toast.success('Test delivery sent', `Receiver answered ${result.last_status}`);
+        }
+      },
+      onError: (error: any) => {
+        toast.error('Failed to send test delivery', error.message);
+      },
+    });
+  };
+
+  const handleDelete = () => {
+    if (!deleteTarget) return;
+    deleteWebhook.mutate(deleteTarget.id, {
+      onSuccess: () => {
+        toast.success('Webhook deleted', 'The webhook will no longer be notified');
+        setDeleteTarget(null);
+      },
+      onError: (error: any) => {
+        toast.error('Failed to delete webhook', error.message);
+      },
+    });
+  };
+
+  if (isLoading) {
+    return (
+      <Card>
+        <CardContent className="flex items-center justify-center p-12">
+          <div className="animate-spin rounded-full h-8 w-8 border-b-2 border-primary" />
+        </CardContent>
+      </Card>
+    );
+  }
+
+  return (
+    <Card>
+      <CardHeader>
+        <div className="flex items-center gap-3">
+          <Webhook className="h-5 w-5 text-muted-foreground" />
+          <div>
+            <CardTitle>Webhooks</CardTitle>
+            <p className="text-sm text-muted-foreground mt-1">
+              POST a signed JSON payload to a URL when this application starts, stops, updates or crashes
+            </p>
+          </div>
+        </div>
+      </CardHeader>
+
+      <CardContent className="space-y-6">
+        {newSecret && (
+          <div className="flex items-start gap-3 p-3 rounded-lg bg-muted text-sm">
+            <KeyRound className="h-4 w-4 mt-0.5 flex-shrink-0" />
+            <div className="min-w-0 space-y-1">
+              <p className="font-medium">Signing secret</p>
+              <p className="text-muted-foreground">
+                Deliveries carry an <code>X-Selfhostly-Signature</code> header: the HMAC-SHA256 of
+                {' '}<code>{'<X-Selfhostly-Timestamp>.<body>'}</code> keyed with this secret.
+              </p>
+              <code className="block break-all select-all">{newSecret}</code>
+            </div>
+            <Button variant="outline" size="sm" onClick={() => setNewSecret(null)}>
+              Done
+            </Button>
+          </div>
+        )}
+
+        {webhooks && webhooks.length > 0 && (
+          <div className="space-y-3">
+            {webhooks.map((webhook) => (
+              <div key={webhook.id} className="flex flex-col sm:flex-row sm:items-center gap-3 p-3 border rounded-lg">
+                <Checkbox
+                  checked={webhook.enabled}
+                  disabled={updateWebhook.isPending}
+                  onCheckedChange={(checked) =>
+                    updateWebhook.mutate({ webhookId: webhook.id, data: { enabled: checked as boolean } })
+                  }
+                  className="shrink-0"
+                />
+                <div className="flex-1 min-w-0 space-y-1.5">
+                  <p className="text-sm font-medium break-all">{webhook.url}</p>
+                  <div className="flex flex-wrap items-center gap-1.5">
+                    {webhook.events.map((event) => (
+                      <Badge key={event} variant="secondary">{event}</Badge>
+                    ))}
+                    {webhook.digest && <Badge variant="outline">{webhook.digest} digest</Badge>}
+                    {webhook.quiet_hours_start && (
+                      <Badge variant="outline" className="gap-1">
+                        <Moon className="h-3 w-3" />
+                        {webhook.quiet_hours_start}–{webhook.quiet_hours_end} {webhook.timezone}
+                      </Badge>
+                    )}
+                    {webhook.last_delivered_at && (
+                      <span className="flex items-center gap-1 text-xs text-muted-foreground ml-1">
+                        {webhook.last_error ? (
+                          <AlertCircle className="h-3.5 w-3.5 text-destructive" />
+                        ) : (
+                          <CheckCircle2 className="h-3.5 w-3.5 text-green-600 dark:text-green-400" />
+                        )}
+                        {webhook.last_error ?? `HTTP ${webhook.last_status}`} ·{' '}
+                        {new Date(webhook.last_delivered_at).toLocaleString()}
+                      </span>
+                    )}
+                  </div>
+                </div>
+                <div className="flex items-center gap-2">
+                  <Button
+                    variant="outline"
+                    size="sm"
+                    onClick={() => handleTest(webhook)}
+                    disabled={testWebhook.isPending}
+                  >
+                    <Send className="h-4 w-4 mr-2" />
+                    Test
+                  </Button>
+                  <Button variant="destructive" size="sm" onClick={() => setDeleteTarget(webhook)}>
+                    <Trash2 className="h-4 w-4" />
+                  </Button>
+                </div>
+              </div>
+            ))}
+          </div>
+        )}
+
+        <div className="space-y-3 p-3 border-2 border-dashed rounded-lg">
+          <label className="text-sm font-medium" htmlFor="webhook-url">Add webhook</label>
+          <Input
+            id="webhook-url"
+            type="url"
+            placeholder="https://example.com/hooks/deploy"
+            value={url}
+            onChange={(e) => setUrl(e.target.value)}
+          />
+          <div className="flex flex-wrap gap-4">
+            {EVENTS.map((event) => (
+              <label key={event.id} className="flex items-center gap-2 text-sm cursor-pointer select-none">
+                <Checkbox
+                  checked={events.includes(event.id)}
+                  onCheckedChange={(checked) => toggleEvent(event.id, checked as boolean)}
+                />
+                {event.label}
+              </label>
+            ))}
+          </div>
+          <div className="flex flex-col sm:flex-row sm:items-center gap-3">
+            <select
+              value={digest}
+              onChange={(e) => setDigest(e.target.value as WebhookDigest)}
+              className="flex h-10 rounded-md border border-input bg-background px-3 py-2 text-sm ring-offset-background focus-visible:outline-none focus-visible:ring-2 focus-visible:ring-ring"
+            >
+              {DIGESTS.map((option) => (
+                <option key={option.id} value={option.id}>{option.label}</option>
+              ))}
+            </select>
+            <div className="flex items-center gap-2 text-sm">
+              <span className="text-muted-foreground whitespace-nowrap">Quiet hours</span>
+              <Input type="time" value={quietStart} onChange={(e) => setQuietStart(e.target.value)} className="w-32" />
+              <span className="text-muted-foreground">to</span>
+              <Input type="time" value={quietEnd} onChange={(e) => setQuietEnd(e.target.value)} className="w-32" />
+            </div>
+          </div>
+          <p className="text-xs text-muted-foreground">
+            Digests and quiet hours hold back start, stop and update events; crashes and failed tasks are always sent right away.
+          </p>
+          <Button
+            size="sm"
+            onClick={handleCreate}
+            disabled={!url || events.length === 0 || !quietStart !== !quietEnd || createWebhook.isPending}
+          >
+            <Plus className="h-4 w-4 mr-2" />
+            Add Webhook
+          </Button>
+        </div>
+      </CardContent>
+
+      <ConfirmationDialog
+        open={!!deleteTarget}
+        onOpenChange={(open) => !open && setDeleteTarget(null)}
+        title="Delete Webhook"
+        description={`Stop notifying ${deleteTarget?.url ?? ''} about this application?`}
+        confirmText="Delete"
+        onConfirm={handleDelete}
+        variant="destructive"
+        isLoading={deleteWebhook.isPending}
+      />
+    </Card>
+  );
+}
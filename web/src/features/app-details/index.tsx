@@ -6,7 +6,8 @@ import { useNavigate } from 'react-router-dom'
 import { useToast } from '@/shared/components/ui/Toast'
 import { Card, CardHeader, CardTitle, CardContent } from '@/shared/components/ui/Card'
 import ConfirmationDialog from '@/shared/components/ui/ConfirmationDialog'
-import { Terminal, Settings, Cloud, Info, AlertTriangle, Clock } from 'lucide-react'
+import { Checkbox } from '@/shared/components/ui/Checkbox'
+import { Terminal, Settings, Cloud, Info, AlertTriangle, Clock, Webhook } from 'lucide-react'
 import { Button } from '@/shared/components/ui/Button'
 import LogViewer from './components/LogViewer'
 import ComposeEditor from './components/ComposeEditor'
@@ -16,8 +17,9 @@ import AppBreadcrumb from '@/shared/components/layout/Breadcrumb'
 import { AppDetailsSkeleton } from '@/shared/components/ui/Skeleton'
 import AppOverview from './components/AppOverview'
 import { ScheduleEditor } from './components/ScheduleEditor'
+import { WebhookEditor } from './components/WebhookEditor'
 
-type TabType = 'overview' | 'compose' | 'logs' | 'cloudflare' | 'schedule'
+type TabType = 'overview' | 'compose' | 'logs' | 'cloudflare' | 'schedule' | 'webhooks'
 
 function AppDetails() {
     const { id } = useParams<{ id: string }>()
@@ -77,6 +79,9 @@ function AppDetails() {
 
     // State for confirmation dialog
     const [showDeleteDialog, setShowDeleteDialog] = useState(false)
+    const [archiveOnDelete, setArchiveOnDelete] = useState(false)
+    const [removeVolumesOnDelete, setRemoveVolumesOnDelete] = useState(false)
+    const [forceDelete, setForceDelete] = useState(false)
 
     const handleDelete = () => {
         if (app) {
@@ -92,11 +97,13 @@ function AppDetails() {
             toast.info('Deleting app', `Deleting "${appName}"...`)
 
             // Trigger deletion
-            deleteApp.mutate({ id: app.id, nodeId: app.node_id }, {
-                onSuccess: () => {
+            deleteApp.mutate({ id: app.id, nodeId: app.node_id, archive: archiveOnDelete, removeVolumes: removeVolumesOnDelete, force: forceDelete }, {
+                onSuccess: (result) => {
                     // Remove from local store on success
                     useAppStore.getState().removeApp(app.id)
-                    toast.success('App deleted', `"${appName}" has been deleted successfully`)
+                    toast.success('App deleted', result.archive_path
+                        ? `"${appName}" has been deleted. Its directory was archived to ${result.archive_path}`
+                        : `"${appName}" has been deleted successfully`)
                     // Redirect to dashboard after deletion
                     navigate('/apps')
                 },
@@ -170,6 +177,7 @@ function AppDetails() {
         { id: 'logs' as TabType, label: 'Logs', icon: Terminal },
         { id: 'cloudflare' as TabType, label: 'Cloudflare', icon: Cloud },
         { id: 'schedule' as TabType, label: 'Schedule', icon: Clock },
+        { id: 'webhooks' as TabType, label: 'Webhooks', icon: Webhook },
     ]
 
     return (
@@ -364,6 +372,16 @@ function AppDetails() {
                             <ScheduleEditor appId={app.id} nodeId={app.node_id} />
                         )
                     )}
+                    {activeTab === 'webhooks' && (
+                        !app.node_id ? (
+                            <div className="flex items-center justify-center min-h-[200px] text-muted-foreground">
+                                <AlertTriangle className="h-5 w-5 mr-2" />
+                                Unable to load webhooks: node_id is missing
+                            </div>
+                        ) : (
+                            <WebhookEditor appId={app.id} nodeId={app.node_id} />
+                        )
+                    )}
                 </CardContent>
             </Card>
 
@@ -379,7 +397,67 @@ function AppDetails() {
                     onConfirm={confirmDelete}
                     isLoading={deleteApp.isPending}
                     variant="destructive"
-                />
+                >
+                    <div className="space-y-3">
+                        <div className="flex gap-3 items-start">
+                            <Checkbox
+                                id="archive_app_directory"
+                                checked={archiveOnDelete}
+                                onCheckedChange={(checked) => setArchiveOnDelete(checked as boolean)}
+                                className="mt-0.5 shrink-0"
+                            />
+                            <div className="flex-1">
+                                <label
+                                    htmlFor="archive_app_directory"
+                                    className="text-sm font-medium cursor-pointer select-none leading-tight block"
+                                >
+                                    Archive app directory to trash
+                                </label>
+                                <p className="text-sm text-muted-foreground mt-1">
+                                    Keeps a copy of the compose file and bind-mounted data that can be restored until the trash is purged.
+                                </p>
+                            </div>
+                        </div>
+                        <div className="flex gap-3 items-start">
+                            <Checkbox
+                                id="remove_docker_volumes"
+                                checked={removeVolumesOnDelete}
+                                onCheckedChange={(checked) => setRemoveVolumesOnDelete(checked as boolean)}
+                                className="mt-0.5 shrink-0"
+                            />
+                            <div className="flex-1">
+                                <label
+                                    htmlFor="remove_docker_volumes"
+                                    className="text-sm font-medium cursor-pointer select-none leading-tight block"
+                                >
+                                    Remove Docker volumes
+                                </label>
+                                <p className="text-sm text-muted-foreground mt-1">
+                                    Also deletes the app's named volumes and the data in them. They are kept otherwise.
+                                </p>
+                            </div>
+                        </div>
+                        <div className="flex gap-3 items-start">
+                            <Checkbox
+                                id="force_delete"
+                                checked={forceDelete}
+                                onCheckedChange={(checked) => setForceDelete(checked as boolean)}
+                                className="mt-0.5 shrink-0"
+                            />
+                            <div className="flex-1">
+                                <label
+                                    htmlFor="force_delete"
+                                    className="text-sm font-medium cursor-pointer select-none leading-tight block"
+                                >
+                                    Force delete
+                                </label>
+                                <p className="text-sm text-muted-foreground mt-1">
+                                    Removes the app even if its containers won't stop, its tunnel can't be deleted or its node is offline. Anything left behind must be cleaned up by hand.
+                                </p>
+                            </div>
+                        </div>
+                    </div>
+                </ConfirmationDialog>
             )}
         </div>
     )
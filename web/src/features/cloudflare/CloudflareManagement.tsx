@@ -19,6 +19,7 @@ import { useNodeContext } from '@/shared/contexts/NodeContext'
 import { useAppStore } from '@/shared/stores/app-store'
 import { useState } from 'react'
 import TunnelsListView from './components/TunnelsListView'
+import OrphanedTunnelsPanel from './components/OrphanedTunnelsPanel'
 
 type SortField = 'name' | 'status' | 'created' | 'updated'
 type SortOrder = 'asc' | 'desc'
@@ -363,6 +364,8 @@ function CloudflareManagement() {
                         />
                     </div>
                 )}
+
+                <OrphanedTunnelsPanel />
             </div>
         </div>
     )
@@ -6,7 +6,8 @@ import { Badge } from '@/shared/components/ui/Badge'
 import { Plus, Trash2, Save, AlertCircle, CheckCircle, Globe } from 'lucide-react'
 import { useQueryClient } from '@tanstack/react-query'
 import { useUpdateTunnelIngress, useCreateTunnelDNSRecord } from '@/shared/services/api'
-import type { IngressRule } from '@/shared/types/api'
+import type { IngressRule, IngressWarning } from '@/shared/types/api'
+import HostnameField from './components/HostnameField'
 
 interface IngressConfigurationProps {
     appId: string;
@@ -17,7 +18,7 @@ interface IngressConfigurationProps {
     onSave?: (rules: IngressRule[], hostname?: string) => void;
 }
 
-export function IngressConfiguration({ appId, nodeId, existingIngress = [], existingHostname: _existingHostname = '', tunnelID: _tunnelID, onSave }: IngressConfigurationProps) {
+export function IngressConfiguration({ appId, nodeId, existingIngress = [], existingHostname: _existingHostname = '', tunnelID, onSave }: IngressConfigurationProps) {
     const queryClient = useQueryClient()
     // Handle null/undefined values in existing ingress rules
     const safeIngress = existingIngress || []
@@ -30,6 +31,7 @@ export function IngressConfiguration({ appId, nodeId, existingIngress = [], exis
     const [isSaving, setIsSaving] = useState(false)
     const [saveError, setSaveError] = useState<string | null>(null)
     const [saveSuccess, setSaveSuccess] = useState(false)
+    const [targetWarnings, setTargetWarnings] = useState<IngressWarning[]>([])
 
     const updateTunnelIngressMutation = useUpdateTunnelIngress()
     const createDNSRecordMutation = useCreateTunnelDNSRecord()
@@ -94,9 +96,10 @@ export function IngressConfiguration({ appId, nodeId, existingIngress = [], exis
                 targetDomain: undefined
             },
             {
-                onSuccess: () => {
+                onSuccess: (data) => {
                     setSaveSuccess(true)
                     setSaveError(null)
+                    setTargetWarnings(data.warnings ?? [])
 
                     // Immediately invalidate tunnel query for instant UI feedback (with nodeId)
                     queryClient.invalidateQueries({ queryKey: ['cloudflare', 'tunnel', appId, nodeId] })
@@ -176,6 +179,20 @@ export function IngressConfiguration({ appId, nodeId, existingIngress = [], exis
                         </div>
                     )}
 
+                    {targetWarnings.length > 0 && (
+                        <div className="bg-amber-50 dark:bg-amber-900/20 border border-amber-200 dark:border-amber-800 rounded-lg p-3 space-y-1">
+                            <div className="flex items-center gap-2 text-sm font-medium text-amber-800 dark:text-amber-300">
+                                <AlertCircle className="h-4 w-4 text-amber-500" />
+                                Some rules don't match your compose file and will return 502 errors
+                            </div>
+                            <ul className="text-xs text-amber-700 dark:text-amber-400 list-disc pl-8">
+                                {targetWarnings.map(w => (
+                                    <li key={w.rule}>Rule {w.rule + 1} ({w.service}): {w.message}</li>
+                                ))}
+                            </ul>
+                        </div>
+                    )}
+
                     {/* Info Banner */}
                     <div className="border rounded-lg p-4 bg-blue-50 dark:bg-blue-900/10 border-blue-200 dark:border-blue-900/30">
                         <div className="flex items-start gap-3">
@@ -229,18 +246,13 @@ export function IngressConfiguration({ appId, nodeId, existingIngress = [], exis
                                             Hostname
                                             {rule.hostname && <Globe className="h-3 w-3 text-green-500" />}
                                         </label>
-                                        <Input
+                                        <HostnameField
                                             placeholder="vertsh.localnest.de"
                                             value={rule.hostname || ''}
-                                            onChange={(e: React.ChangeEvent<HTMLInputElement>) => updateRule(index, 'hostname', e.target.value || undefined)}
+                                            onChange={(hostname) => updateRule(index, 'hostname', hostname || undefined)}
+                                            emptyHint="Optional - uses tunnel URL if empty"
+                                            tunnelId={tunnelID}
                                         />
-                                        <p className="text-xs text-muted-foreground mt-1">
-                                            {rule.hostname
-                                                ? <span className="text-green-600 dark:text-green-400 flex items-center gap-1">
-                                                    <CheckCircle className="h-3 w-3" /> DNS record will be created
-                                                </span>
-                                                : 'Optional - uses tunnel URL if empty'}
-                                        </p>
                                     </div>
 
                                     <div>
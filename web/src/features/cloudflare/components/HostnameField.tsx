@@ -0,0 +1,84 @@
+import React, { useId } from 'react'
+import { Input } from '@/shared/components/ui/Input'
+import { AlertCircle, CheckCircle } from 'lucide-react'
+import { useProviders, useProviderZones, useZoneHostnames } from '@/shared/services/api'
+import type { ProviderZone } from '@/shared/types/api'
+
+interface HostnameFieldProps {
+    value: string;
+    onChange: (hostname: string) => void;
+    placeholder?: string;
+    emptyHint: string;
+    // The app's tunnel, if it has one; records already routed to it aren't conflicts
+    tunnelId?: string;
+}
+
+// zoneFor returns the most specific zone the hostname belongs to
+function zoneFor(hostname: string, zones: ProviderZone[]): ProviderZone | undefined {
+    return zones
+        .filter(z => hostname === z.name || hostname.endsWith(`.${z.name}`))
+        .sort((a, b) => b.name.length - a.name.length)[0]
+}
+
+// HostnameField is a hostname input that suggests the provider's zones and checks the hostname
+// against the zone's existing DNS records, so conflicts show up before the rules are saved.
+// Without zone access (provider not configured or token lacks zone read) it is a plain input.
+export default function HostnameField({ value, onChange, placeholder, emptyHint, tunnelId }: HostnameFieldProps) {
+    const listId = useId()
+    const { data: providers } = useProviders()
+    const provider = providers?.active ?? ''
+    const { data: zonesData, isError: zonesError } = useProviderZones(provider)
+    const zones = zonesData?.zones ?? []
+
+    const hostname = value.trim().toLowerCase()
+    const zone = hostname ? zoneFor(hostname, zones) : undefined
+    const { data: hostnamesData } = useZoneHostnames(provider, zone?.id)
+    const record = hostnamesData?.hostnames.find(h => h.name === hostname)
+
+    // Suggest "<label>.<zone>" while the user is still typing the first label
+    const label = hostname.includes('.') ? '' : hostname
+    const suggestions = zones.map(z => (label ? `${label}.${z.name}` : z.name))
+
+    let status: React.ReactNode = emptyHint
+    if (hostname && (zonesError || !zonesData)) {
+        status = <span className="text-green-600 dark:text-green-400 flex items-center gap-1">
+            <CheckCircle className="h-3 w-3" /> DNS record will be created
+        </span>
+    } else if (hostname && !zone) {
+        status = <span className="text-amber-600 dark:text-amber-400 flex items-center gap-1">
+            <AlertCircle className="h-3 w-3" /> No zone in your account matches this hostname
+        </span>
+    } else if (record && record.tunnel_id && record.tunnel_id === tunnelId) {
+        status = <span className="text-green-600 dark:text-green-400 flex items-center gap-1">
+            <CheckCircle className="h-3 w-3" /> Already routed to this tunnel
+        </span>
+    } else if (record) {
+        status = <span className="text-red-600 dark:text-red-400 flex items-center gap-1">
+            <AlertCircle className="h-3 w-3 flex-shrink-0" />
+            {record.tunnel_id
+                ? 'Already routed to another tunnel'
+                : `Already has a ${record.type} record (${record.target})`}
+        </span>
+    } else if (hostname) {
+        status = <span className="text-green-600 dark:text-green-400 flex items-center gap-1">
+            <CheckCircle className="h-3 w-3" /> DNS record will be created in {zone?.name}
+        </span>
+    }
+
+    return (
+        <>
+            <Input
+                placeholder={placeholder}
+                value={value}
+                list={zones.length > 0 ? listId : undefined}
+                onChange={(e: React.ChangeEvent<HTMLInputElement>) => onChange(e.target.value)}
+            />
+            {zones.length > 0 && (
+                <datalist id={listId}>
+                    {suggestions.map(s => <option key={s} value={s} />)}
+                </datalist>
+            )}
+            <p className="text-xs text-muted-foreground mt-1">{status}</p>
+        </>
+    )
+}
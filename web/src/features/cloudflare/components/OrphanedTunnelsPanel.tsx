@@ -0,0 +1,211 @@
+import { useState } from 'react'
+import { Card, CardContent } from '@/shared/components/ui/Card'
+import { Button } from '@/shared/components/ui/Button'
+import { Input } from '@/shared/components/ui/Input'
+import ConfirmationDialog from '@/shared/components/ui/ConfirmationDialog'
+import { useToast } from '@/shared/components/ui/Toast'
+import { AlertCircle, CheckCircle2, Download, Loader2, Search, Trash2 } from 'lucide-react'
+import { useApps, useCurrentNode, useDeleteOrphanedTunnel, useImportTunnel, useTunnelInventory } from '@/shared/services/api'
+import type { TunnelInventoryItem } from '@/shared/types/api'
+
+// OrphanedTunnelsPanel scans the provider account for tunnels no app uses and lets the user import
+// them into an app, or delete the orphaned ones. The scan calls the provider API, so it only runs
+// on request.
+function OrphanedTunnelsPanel() {
+    const { toast } = useToast()
+    const [scanRequested, setScanRequested] = useState(false)
+    const [tunnelToDelete, setTunnelToDelete] = useState<TunnelInventoryItem | null>(null)
+    const { data: inventory, isFetching, error, refetch } = useTunnelInventory(scanRequested)
+    const deleteTunnel = useDeleteOrphanedTunnel()
+    const [tunnelToImport, setTunnelToImport] = useState<TunnelInventoryItem | null>(null)
+    const [importAppId, setImportAppId] = useState('')
+    const [importAppName, setImportAppName] = useState('')
+    const { data: apps } = useApps()
+    const { data: currentNode } = useCurrentNode()
+    const importTunnel = useImportTunnel()
+
+    const unmanaged = inventory?.tunnels.filter(t => t.in_account && !t.tracked) ?? []
+    const appsWithoutTunnel = apps?.filter(a => !a.tunnel_mode) ?? []
+
+    const openImport = (t: TunnelInventoryItem) => {
+        setImportAppId('')
+        setImportAppName(t.tunnel_name)
+        setTunnelToImport(t)
+    }
+
+    const confirmImport = () => {
+        if (!tunnelToImport) return
+        const target = appsWithoutTunnel.find(a => a.id === importAppId)
+        const nodeId = target?.node_id ?? currentNode?.id
+        if (!nodeId) return
+        importTunnel.mutate(
+            target
+                ? { nodeId, tunnel_id: tunnelToImport.tunnel_id, app_id: target.id }
+                : { nodeId, tunnel_id: tunnelToImport.tunnel_id, app_name: importAppName.trim() },
+            {
+                onSuccess: (app) => {
+                    toast.success('Tunnel imported', `"${tunnelToImport.tunnel_name}" now belongs to ${app.name}`)
+                    setTunnelToImport(null)
+                },
+                onError: (err) => toast.error('Failed to import tunnel', err.message),
+            }
+        )
+    }
+
+    const confirmDelete = () => {
+        if (!tunnelToDelete) return
+        const { tunnel_id, tunnel_name } = tunnelToDelete
+        deleteTunnel.mutate(tunnel_id, {
+            onSuccess: () => toast.success('Tunnel deleted', `"${tunnel_name}" was removed from your account`),
+            onError: (err) => toast.error('Failed to delete tunnel', err.message),
+        })
+        setTunnelToDelete(null)
+    }
+
+    return (
+        <Card className="border-2">
+            <CardContent className="p-4 sm:p-6 space-y-4">
+                <div className="flex items-start justify-between gap-3 flex-wrap">
+                    <div className="space-y-1">
+                        <p className="text-sm font-semibold">Unmanaged tunnels</p>
+                        <p className="text-xs sm:text-sm text-muted-foreground">
+                            Tunnels in your account that no app uses. Import one to manage it here, or delete it if it was left behind. Tunnels with running connectors can't be deleted.
+                        </p>
+                    </div>
+                    <Button
+                        variant="outline"
+                        size="sm"
+                        onClick={() => (scanRequested ? refetch() : setScanRequested(true))}
+                        disabled={isFetching}
+                        className="button-press h-9 text-xs sm:text-sm"
+                    >
+                        {isFetching
+                            ? <Loader2 className="h-4 w-4 mr-1.5 animate-spin" />
+                            : <Search className="h-4 w-4 mr-1.5" />}
+                        {scanRequested ? 'Rescan' : 'Scan account'}
+                    </Button>
+                </div>
+
+                {error && (
+                    <div className="flex items-center gap-2 text-sm text-red-600 dark:text-red-400">
+                        <AlertCircle className="h-4 w-4 flex-shrink-0" />
+                        {error.message}
+                    </div>
+                )}
+
+                {inventory && !inventory.account_listed && (
+                    <p className="text-sm text-muted-foreground">
+                        The active tunnel provider can't list its account, so unmanaged tunnels can't be detected.
+                    </p>
+                )}
+
+                {inventory && !inventory.complete && (
+                    <div className="flex items-start gap-2 text-sm text-amber-600 dark:text-amber-400">
+                        <AlertCircle className="h-4 w-4 flex-shrink-0 mt-0.5" />
+                        Some nodes didn't respond ({inventory.unreachable_nodes?.join(', ')}), so no tunnel is flagged as orphaned until they're back.
+                    </div>
+                )}
+
+                {inventory && inventory.account_listed && inventory.complete && unmanaged.length === 0 && (
+                    <div className="flex items-center gap-2 text-sm text-muted-foreground">
+                        <CheckCircle2 className="h-4 w-4 text-green-500" />
+                        Every tunnel in your account belongs to an app.
+                    </div>
+                )}
+
+                {unmanaged.length > 0 && (
+                    <ul className="divide-y rounded-lg border-2">
+                        {unmanaged.map(t => (
+                            <li key={t.tunnel_id} className="flex items-center justify-between gap-3 p-3">
+                                <div className="min-w-0">
+                                    <p className="text-sm font-medium truncate">{t.tunnel_name || t.tunnel_id}</p>
+                                    <p className="text-xs text-muted-foreground truncate">
+                                        {t.tunnel_id} · {t.status}
+                                        {t.created_at && ` · created ${new Date(t.created_at).toLocaleDateString()}`}
+                                    </p>
+                                </div>
+                                <div className="flex gap-2 flex-shrink-0">
+                                    <Button
+                                        variant="outline"
+                                        size="sm"
+                                        onClick={() => openImport(t)}
+                                        disabled={importTunnel.isPending}
+                                        className="button-press h-8 text-xs"
+                                    >
+                                        <Download className="h-3.5 w-3.5 mr-1" />
+                                        Import
+                                    </Button>
+                                    {t.orphaned && (
+                                        <Button
+                                            variant="outline"
+                                            size="sm"
+                                            onClick={() => setTunnelToDelete(t)}
+                                            disabled={deleteTunnel.isPending}
+                                            className="button-press h-8 text-xs text-red-600"
+                                        >
+                                            <Trash2 className="h-3.5 w-3.5 mr-1" />
+                                            Delete
+                                        </Button>
+                                    )}
+                                </div>
+                            </li>
+                        ))}
+                    </ul>
+                )}
+            </CardContent>
+
+            <ConfirmationDialog
+                open={tunnelToDelete !== null}
+                onOpenChange={(open) => !open && setTunnelToDelete(null)}
+                title="Delete Orphaned Tunnel"
+                description={`Delete "${tunnelToDelete?.tunnel_name}" and its DNS records from your account? This cannot be undone.`}
+                confirmText="Delete"
+                onConfirm={confirmDelete}
+                isLoading={deleteTunnel.isPending}
+                variant="destructive"
+            />
+
+            <ConfirmationDialog
+                open={tunnelToImport !== null}
+                onOpenChange={(open) => !open && setTunnelToImport(null)}
+                title="Import Tunnel"
+                description={`Manage "${tunnelToImport?.tunnel_name}" from an app. Its token and ingress rules are copied from your account.`}
+                confirmText="Import"
+                onConfirm={confirmImport}
+                isLoading={importTunnel.isPending}
+            >
+                <div className="space-y-3">
+                    <div>
+                        <label htmlFor="import-app" className="block text-sm font-medium mb-1">App</label>
+                        <select
+                            id="import-app"
+                            value={importAppId}
+                            onChange={(e) => setImportAppId(e.target.value)}
+                            className="flex h-10 w-full rounded-md border border-input bg-background px-3 py-2 text-sm ring-offset-background focus-visible:outline-none focus-visible:ring-2 focus-visible:ring-ring"
+                        >
+                            <option value="">Create a new app</option>
+                            {appsWithoutTunnel.map(a => (
+                                <option key={a.id} value={a.id}>{a.name}{a.node_name ? ` (${a.node_name})` : ''}</option>
+                            ))}
+                        </select>
+                    </div>
+                    {!importAppId && (
+                        <div>
+                            <label htmlFor="import-app-name" className="block text-sm font-medium mb-1">New app name</label>
+                            <Input
+                                id="import-app-name"
+                                value={importAppName}
+                                onChange={(e) => setImportAppName(e.target.value)}
+                            />
+                            <p className="text-xs text-muted-foreground mt-1">
+                                The app starts with a placeholder service; edit its compose file to add the services the tunnel routes to.
+                            </p>
+                        </div>
+                    )}
+                </div>
+            </ConfirmationDialog>
+        </Card>
+    )
+}
+
+export default OrphanedTunnelsPanel
@@ -60,6 +60,7 @@ function TunnelsListView({ tunnels, apps: appsProp }: TunnelsListViewProps) {
 
     const [tunnelToDelete, setTunnelToDelete] = useState<{ id: string; name: string; nodeId?: string } | null>(null)
     const [loadingTunnelIds, setLoadingTunnelIds] = useState<Set<string>>(new Set())
+    const [quickTunnelFallback, setQuickTunnelFallback] = useState(false)
 
     // Copy to clipboard helper
     const copyToClipboard = async (text: string, label: string) => {
@@ -228,6 +229,7 @@ function TunnelsListView({ tunnels, apps: appsProp }: TunnelsListViewProps) {
                     name: tunnel.tunnel_name,
                     nodeId: app?.node_id 
                 })
+                setQuickTunnelFallback(false)
             },
             variant: 'destructive',
             loading: (tunnel) => loadingTunnelIds.has(tunnel.app_id) && deleteTunnel.isPending
@@ -258,9 +260,11 @@ function TunnelsListView({ tunnels, apps: appsProp }: TunnelsListViewProps) {
         console.log('Deleting tunnel:', { appId: tunnelToDelete.id, nodeId, name: tunnelToDelete.name })
         setLoadingTunnelIds(prev => new Set(prev).add(tunnelToDelete.id))
         
-        deleteTunnel.mutate({ appId: tunnelToDelete.id, nodeId }, {
+        deleteTunnel.mutate({ appId: tunnelToDelete.id, nodeId, quickTunnelFallback }, {
             onSuccess: () => {
-                toast.success('Tunnel Deleted', `${tunnelToDelete.name} tunnel has been removed`)
+                toast.success('Tunnel Deleted', quickTunnelFallback
+                    ? `${tunnelToDelete.name} tunnel is being replaced with a Quick Tunnel`
+                    : `${tunnelToDelete.name} tunnel has been removed`)
                 setTunnelToDelete(null)
                 setLoadingTunnelIds(prev => {
                     const next = new Set(prev)
@@ -364,7 +368,17 @@ function TunnelsListView({ tunnels, apps: appsProp }: TunnelsListViewProps) {
                 onConfirm={confirmDelete}
                 isLoading={deleteTunnel.isPending}
                 variant="destructive"
-            />
+            >
+                <label className="flex items-start gap-2 text-sm text-muted-foreground">
+                    <input
+                        type="checkbox"
+                        className="mt-0.5"
+                        checked={quickTunnelFallback}
+                        onChange={(e) => setQuickTunnelFallback(e.target.checked)}
+                    />
+                    Replace it with a Quick Tunnel to the same service so the app stays reachable (at a new trycloudflare.com URL)
+                </label>
+            </ConfirmationDialog>
         </>
     )
 }
@@ -3,6 +3,7 @@ import { Button } from '@/shared/components/ui/Button'
 import { Input } from '@/shared/components/ui/Input'
 import { Plus, Trash2, Globe, CheckCircle, AlertCircle } from 'lucide-react'
 import type { IngressRule } from '@/shared/types/api'
+import HostnameField from '@/features/cloudflare/components/HostnameField'
 
 interface IngressRulesEditorProps {
     value: IngressRule[];
@@ -92,20 +93,12 @@ export default function IngressRulesEditor({ value, onChange }: IngressRulesEdit
                                     Hostname (Optional)
                                     {rule.hostname && <Globe className="h-3 w-3 text-green-500" />}
                                 </label>
-                                <Input
+                                <HostnameField
                                     placeholder="app.yourdomain.com"
                                     value={rule.hostname || ''}
-                                    onChange={(e: React.ChangeEvent<HTMLInputElement>) =>
-                                        updateRule(index, 'hostname', e.target.value || null)
-                                    }
+                                    onChange={(hostname) => updateRule(index, 'hostname', hostname || null)}
+                                    emptyHint="Leave empty for default tunnel URL"
                                 />
-                                <p className="text-xs text-muted-foreground mt-1">
-                                    {rule.hostname
-                                        ? <span className="text-green-600 dark:text-green-400 flex items-center gap-1">
-                                            <CheckCircle className="h-3 w-3" /> DNS record will be created
-                                        </span>
-                                        : 'Leave empty for default tunnel URL'}
-                                </p>
                             </div>
 
                             <div>
@@ -3,9 +3,12 @@ import { useAppStore } from '@/shared/stores/app-store'
 import { Card, CardHeader, CardTitle, CardContent } from '@/shared/components/ui/Card'
 import { Button } from '@/shared/components/ui/Button'
 import ConfirmationDialog from '@/shared/components/ui/ConfirmationDialog'
+import { Checkbox } from '@/shared/components/ui/Checkbox'
+import { Avatar } from '@/shared/components/ui/Avatar'
+import { getInitials } from '@/shared/lib/avatar'
 import { Play, Pause, RefreshCw, Trash2, ExternalLink, Clock, Search, Loader2, MoreVertical, TrendingUp } from 'lucide-react'
 import { useNavigate } from 'react-router-dom'
-import { useDeleteApp, useStartApp, useStopApp, useUpdateAppContainers } from '@/shared/services/api'
+import { appIconUrl, useDeleteApp, useStartApp, useStopApp, useUpdateAppContainers } from '@/shared/services/api'
 import { useToast } from '@/shared/components/ui/Toast'
 import { SimpleDropdown, SimpleDropdownItem } from '@/shared/components/ui/SimpleDropdown'
 import type { App } from '@/shared/types/api'
@@ -31,6 +34,9 @@ function AppList({ filteredApps }: AppListProps) {
     // State for confirmation dialog and deletion tracking
     const [appToDelete, setAppToDelete] = useState<AppToDelete | null>(null)
     const [deletingAppId, setDeletingAppId] = useState<string | null>(null)
+    const [archiveOnDelete, setArchiveOnDelete] = useState(false)
+    const [removeVolumesOnDelete, setRemoveVolumesOnDelete] = useState(false)
+    const [forceDelete, setForceDelete] = useState(false)
 
     // Handle delete with confirmation dialog
     const handleDelete = (appId: string, appName: string) => {
@@ -58,11 +64,13 @@ function AppList({ filteredApps }: AppListProps) {
             toast.info('Deleting app', `Deleting "${appName}"...`)
 
             // Then trigger the actual deletion
-            deleteApp.mutate({ id: appId, nodeId: app.node_id }, {
-                onSuccess: () => {
+            deleteApp.mutate({ id: appId, nodeId: app.node_id, archive: archiveOnDelete, removeVolumes: removeVolumesOnDelete, force: forceDelete }, {
+                onSuccess: (result) => {
                     // Optimistically remove from local store on success
                     useAppStore.getState().removeApp(appId)
-                    toast.success('App deleted', `"${appName}" has been deleted successfully`)
+                    toast.success('App deleted', result.archive_path
+                        ? `"${appName}" has been deleted. Its directory was archived to ${result.archive_path}`
+                        : `"${appName}" has been deleted successfully`)
                     setDeletingAppId(null)
                 },
                 onError: (error) => {
@@ -84,6 +92,8 @@ function AppList({ filteredApps }: AppListProps) {
                 return 'bg-gray-100 text-gray-800 dark:bg-gray-700 dark:text-gray-200'
             case 'updating':
                 return 'bg-blue-100 text-blue-800 dark:bg-blue-900 dark:text-blue-200'
+            case 'degraded':
+                return 'bg-amber-100 text-amber-800 dark:bg-amber-900 dark:text-amber-200'
             case 'error':
                 return 'bg-red-100 text-red-800 dark:bg-red-900 dark:text-red-200'
             default:
@@ -97,6 +107,8 @@ function AppList({ filteredApps }: AppListProps) {
                 return <div className="w-2 h-2 bg-green-500 rounded-full animate-pulse"></div>
             case 'updating':
                 return <div className="w-2 h-2 bg-blue-500 rounded-full animate-spin"></div>
+            case 'degraded':
+                return <div className="w-2 h-2 bg-amber-500 rounded-full animate-pulse"></div>
             case 'error':
                 return <div className="w-2 h-2 bg-red-500 rounded-full"></div>
             default:
@@ -193,7 +205,20 @@ function AppList({ filteredApps }: AppListProps) {
                                 <CardHeader className="pb-3 p-4 sm:p-5 space-y-2">
                                     {/* Title Row */}
                                     <div className="flex items-start justify-between gap-2">
-                                        <CardTitle className="text-base sm:text-lg lg:text-xl font-bold truncate flex-1 pr-2">{app.name}</CardTitle>
+                                        <div className="flex items-center gap-2 min-w-0 flex-1">
+                                            <Avatar
+                                                src={app.node_id ? appIconUrl(app.id, app.node_id) : undefined}
+                                                name={app.name}
+                                                size="sm"
+                                                className="shrink-0"
+                                                fallback={
+                                                    <div className="h-full w-full rounded-full flex items-center justify-center text-xs font-medium bg-muted text-muted-foreground">
+                                                        {getInitials(app.name)}
+                                                    </div>
+                                                }
+                                            />
+                                            <CardTitle className="text-base sm:text-lg lg:text-xl font-bold truncate flex-1 pr-2">{app.name}</CardTitle>
+                                        </div>
 
                                         {/* Desktop Actions Menu */}
                                         <div onClick={(e) => e.stopPropagation()} className="hidden sm:block">
@@ -269,6 +294,14 @@ function AppList({ filteredApps }: AppListProps) {
                                                 Quick Tunnel
                                             </span>
                                         )}
+                                        {app.stale && (
+                                            <span
+                                                className="px-2.5 py-1 rounded-md text-xs font-medium bg-gray-100 text-gray-700 dark:bg-gray-800 dark:text-gray-300"
+                                                title={app.synced_at ? `Node unreachable; last synced ${new Date(app.synced_at).toLocaleString()}` : 'Node unreachable'}
+                                            >
+                                                Stale
+                                            </span>
+                                        )}
                                         {app.public_url && (
                                             <a
                                                 href={app.public_url}
@@ -295,7 +328,7 @@ function AppList({ filteredApps }: AppListProps) {
 
                                     {app.status === 'error' && app.error_message && (
                                         <div className="text-xs text-red-600 dark:text-red-400 p-2.5 bg-red-50 dark:bg-red-900/20 rounded border-l-2 border-red-500">
-                                            <span className="font-semibold">Error:</span> {app.error_message}
+                                            <span className="font-semibold">Error:</span> {app.error_message.split('\n')[0]}
                                         </div>
                                     )}
                                 </CardContent>
@@ -384,7 +417,67 @@ function AppList({ filteredApps }: AppListProps) {
                 onConfirm={confirmDelete}
                 isLoading={deleteApp.isPending}
                 variant="destructive"
-            />
+            >
+                <div className="space-y-3">
+                    <div className="flex gap-3 items-start">
+                        <Checkbox
+                            id="archive_app_directory"
+                            checked={archiveOnDelete}
+                            onCheckedChange={(checked) => setArchiveOnDelete(checked as boolean)}
+                            className="mt-0.5 shrink-0"
+                        />
+                        <div className="flex-1">
+                            <label
+                                htmlFor="archive_app_directory"
+                                className="text-sm font-medium cursor-pointer select-none leading-tight block"
+                            >
+                                Archive app directory to trash
+                            </label>
+                            <p className="text-sm text-muted-foreground mt-1">
+                                Keeps a copy of the compose file and bind-mounted data that can be restored until the trash is purged.
+                            </p>
+                        </div>
+                    </div>
+                    <div className="flex gap-3 items-start">
+                        <Checkbox
+                            id="remove_docker_volumes"
+                            checked={removeVolumesOnDelete}
+                            onCheckedChange={(checked) => setRemoveVolumesOnDelete(checked as boolean)}
+                            className="mt-0.5 shrink-0"
+                        />
+                        <div className="flex-1">
+                            <label
+                                htmlFor="remove_docker_volumes"
+                                className="text-sm font-medium cursor-pointer select-none leading-tight block"
+                            >
+                                Remove Docker volumes
+                            </label>
+                            <p className="text-sm text-muted-foreground mt-1">
+                                Also deletes the app's named volumes and the data in them. They are kept otherwise.
+                            </p>
+                        </div>
+                    </div>
+                    <div className="flex gap-3 items-start">
+                        <Checkbox
+                            id="force_delete"
+                            checked={forceDelete}
+                            onCheckedChange={(checked) => setForceDelete(checked as boolean)}
+                            className="mt-0.5 shrink-0"
+                        />
+                        <div className="flex-1">
+                            <label
+                                htmlFor="force_delete"
+                                className="text-sm font-medium cursor-pointer select-none leading-tight block"
+                            >
+                                Force delete
+                            </label>
+                            <p className="text-sm text-muted-foreground mt-1">
+                                Removes the app even if its containers won't stop, its tunnel can't be deleted or its node is offline. Anything left behind must be cleaned up by hand.
+                            </p>
+                        </div>
+                    </div>
+                </div>
+            </ConfirmationDialog>
         </>
     )
 }
@@ -18,6 +18,9 @@ const computeHealthScore = (app: App): { score: number; label: string; color: st
     if (app.status === 'error') {
         return { score: 0, label: 'Critical', color: 'text-red-600 dark:text-red-400' }
     }
+    if (app.status === 'degraded') {
+        return { score: 40, label: 'Degraded', color: 'text-amber-600 dark:text-amber-400' }
+    }
     if (app.status === 'stopped') {
         return { score: 50, label: 'Stopped', color: 'text-gray-600 dark:text-gray-400' }
     }
@@ -385,13 +388,13 @@ function AppListView({ filteredApps }: AppListViewProps) {
     // Expandable content
     const expandableContent = (app: App) => (
         <div className="space-y-3">
-            {app.status === 'error' && app.error_message && (
+            {(app.status === 'error' || app.status === 'degraded') && app.error_message && (
                 <div className="p-3 bg-red-50 dark:bg-red-900/20 border border-red-200 dark:border-red-800 rounded-md">
                     <div className="flex items-start gap-2">
                         <XCircle className="h-4 w-4 text-red-600 dark:text-red-400 mt-0.5 flex-shrink-0" />
                         <div>
                             <p className="text-sm font-semibold text-red-800 dark:text-red-300">Error Message</p>
-                            <p className="text-xs text-red-700 dark:text-red-400 mt-1">{app.error_message}</p>
+                            <pre className="text-xs text-red-700 dark:text-red-400 mt-1 whitespace-pre-wrap break-all max-h-64 overflow-auto">{app.error_message}</pre>
                         </div>
                     </div>
                 </div>
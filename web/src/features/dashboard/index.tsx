@@ -10,6 +10,12 @@ import { Button } from '@/shared/components/ui/Button'
 import { useNodeContext } from '@/shared/contexts/NodeContext'
 import type { App } from '@/shared/types/api'
 
+// The fields the app cards and table show; compose content is left out of the list response
+const APP_LIST_FIELDS = [
+    'id', 'name', 'description', 'status', 'error_message', 'node_id', 'public_url', 'tunnel_mode',
+    'schedule', 'stale', 'synced_at', 'created_at', 'updated_at',
+] as const satisfies readonly (keyof App)[]
+
 type SortOption = 'name' | 'date' | 'status'
 type FilterStatus = 'all' | 'running' | 'stopped' | 'updating' | 'error'
 type ViewMode = 'grid' | 'list'
@@ -18,7 +24,7 @@ function Dashboard() {
     // Get global node context
     const { selectedNodeIds } = useNodeContext()
 
-    const { data: apps, isLoading, error } = useApps(selectedNodeIds)
+    const { data: apps, isLoading, error } = useApps(selectedNodeIds, APP_LIST_FIELDS)
     const setApps = useAppStore((state) => state.setApps)
     const queryClient = useQueryClient()
 
@@ -0,0 +1,73 @@
+import React, { useEffect, useState } from 'react'
+import { Card, CardHeader, CardTitle, CardContent } from '@/shared/components/ui/Card'
+import { Button } from '@/shared/components/ui/Button'
+import { Input } from '@/shared/components/ui/Input'
+import { useToast } from '@/shared/components/ui/Toast'
+import { useSettingsSchema, useSettingsSection, useUpdateSettingsSection } from '@/shared/services/api'
+
+const labels: Record<string, string> = {
+    history_keep_count: 'Job history per app',
+    stale_threshold_minutes: 'Stale job threshold (minutes)',
+}
+
+// JobSettingsCard edits the jobs settings section. Field limits and descriptions come from the
+// settings schema, so the server stays the single source of validation.
+function JobSettingsCard() {
+    const { toast } = useToast()
+    const { data: schema } = useSettingsSchema()
+    const { data: section } = useSettingsSection('jobs')
+    const updateSection = useUpdateSettingsSection('jobs')
+    const [values, setValues] = useState<Record<string, string>>({})
+
+    const fields = schema?.sections.find(s => s.name === 'jobs')?.fields ?? []
+
+    useEffect(() => {
+        if (section) {
+            setValues(Object.fromEntries(Object.entries(section.values).map(([k, v]) => [k, String(v)])))
+        }
+    }, [section])
+
+    const save = () => {
+        const payload = Object.fromEntries(fields.map(f => [f.name, Number(values[f.name])]))
+        updateSection.mutate(payload, {
+            onSuccess: () => toast.success('Job settings saved'),
+            onError: (err) => toast.error('Failed to save job settings', err.message),
+        })
+    }
+
+    return (
+        <Card>
+            <CardHeader className="pb-2 sm:pb-2">
+                <CardTitle>Job Queue</CardTitle>
+                <p className="text-sm text-muted-foreground mt-2">
+                    How long background job records are kept. Secondary nodes pick changes up when they next sync settings.
+                </p>
+            </CardHeader>
+            <CardContent className="pt-0 space-y-4">
+                {fields.map(field => (
+                    <div key={field.name}>
+                        <label htmlFor={`jobs_${field.name}`} className="block text-sm font-medium mb-1">
+                            {labels[field.name] ?? field.name}
+                        </label>
+                        <Input
+                            id={`jobs_${field.name}`}
+                            type="number"
+                            min={field.min}
+                            max={field.max}
+                            value={values[field.name] ?? ''}
+                            onChange={(e: React.ChangeEvent<HTMLInputElement>) => setValues({ ...values, [field.name]: e.target.value })}
+                        />
+                        <p className="text-xs text-muted-foreground mt-1">
+                            {field.description} ({field.min}–{field.max})
+                        </p>
+                    </div>
+                ))}
+                <Button onClick={save} disabled={updateSection.isPending || fields.length === 0}>
+                    {updateSection.isPending ? 'Saving...' : 'Save Job Settings'}
+                </Button>
+            </CardContent>
+        </Card>
+    )
+}
+
+export default JobSettingsCard
@@ -1,12 +1,16 @@
 import { useState, useEffect } from 'react'
-import { useSettings, useUpdateSettings, useProviders, useProviderFeatures } from '@/shared/services/api'
+import { useSettings, useUpdateSettings, useProviders, useProviderFeatures, useTelemetryPreview, useFeatureFlags, useUpdateFeatureFlag } from '@/shared/services/api'
 import { Card, CardHeader, CardTitle, CardContent } from '@/shared/components/ui/Card'
 import { Button } from '@/shared/components/ui/Button'
 import { Checkbox } from '@/shared/components/ui'
 import { Badge } from '@/shared/components/ui/Badge'
 import AppBreadcrumb from '@/shared/components/layout/Breadcrumb'
+import JobSettingsCard from './components/JobSettingsCard'
 import { CheckCircle2, AlertCircle, Network, Shield } from 'lucide-react'
 
+// Provider config fields holding secrets, which the API returns masked
+const tokenFields = ['api_token', 'auth_token']
+
 function Settings() {
     const { data: settings, isLoading: settingsLoading } = useSettings()
     const { data: providersData, isLoading: providersLoading } = useProviders()
@@ -16,13 +20,19 @@ function Settings() {
     const [providerConfig, setProviderConfig] = useState<Record<string, any>>({})
     const [maskedTokens, setMaskedTokens] = useState<Record<string, string>>({}) // Store masked tokens for placeholders
     const [autoStartApps, setAutoStartApps] = useState(false)
+    const [telemetryEnabled, setTelemetryEnabled] = useState(false)
+    const [showTelemetryPreview, setShowTelemetryPreview] = useState(false)
 
     const { data: providerFeatures } = useProviderFeatures(selectedProvider)
+    const { data: telemetryPreview, isLoading: telemetryPreviewLoading } = useTelemetryPreview(showTelemetryPreview)
+    const { data: featureFlags } = useFeatureFlags()
+    const updateFeatureFlag = useUpdateFeatureFlag()
 
     // Initialize form state from settings
     useEffect(() => {
         if (settings) {
             setAutoStartApps(settings.auto_start_apps)
+            setTelemetryEnabled(settings.telemetry_enabled)
 
             // Set active provider
             const activeProvider = settings.active_tunnel_provider || 'cloudflare'
@@ -36,15 +46,17 @@ function Settings() {
                     const cleaned = { ...parsed }
                     const masked: Record<string, string> = {}
                     Object.keys(cleaned).forEach(provider => {
-                        if (cleaned[provider]?.api_token?.includes('****')) {
-                            // Store masked token for placeholder
-                            masked[provider] = cleaned[provider].api_token
-                            // Clear masked token from config (user needs to enter new token)
-                            cleaned[provider] = {
-                                ...cleaned[provider],
-                                api_token: ''
+                        tokenFields.forEach(field => {
+                            if (cleaned[provider]?.[field]?.includes('****')) {
+                                // Store masked token for placeholder
+                                masked[provider] = cleaned[provider][field]
+                                // Clear masked token from config (user needs to enter new token)
+                                cleaned[provider] = {
+                                    ...cleaned[provider],
+                                    [field]: ''
+                                }
                             }
-                        }
+                        })
                     })
                     setMaskedTokens(masked)
                     setProviderConfig(cleaned || {})
@@ -97,13 +109,15 @@ function Settings() {
     const handleSaveProvider = () => {
         // Clean up masked tokens before saving - don't send tokens that contain "****"
         const cleanedConfig = { ...providerConfig }
-        if (cleanedConfig[selectedProvider]?.api_token?.includes('****')) {
-            // Remove masked token - backend will keep existing token
-            cleanedConfig[selectedProvider] = {
-                ...cleanedConfig[selectedProvider],
-                api_token: ''
+        tokenFields.forEach(field => {
+            if (cleanedConfig[selectedProvider]?.[field]?.includes('****')) {
+                // Remove masked token - backend will keep existing token
+                cleanedConfig[selectedProvider] = {
+                    ...cleanedConfig[selectedProvider],
+                    [field]: ''
+                }
             }
-        }
+        })
 
         const configToSave = {
             active_tunnel_provider: selectedProvider,
@@ -154,6 +168,112 @@ function Settings() {
                     </div>
                 )
 
+            case 'ngrok':
+                return (
+                    <div className="space-y-4">
+                        <div>
+                            <label htmlFor="ngrok_auth_token" className="block text-sm font-medium mb-2">
+                                Auth Token *
+                            </label>
+                            <input
+                                id="ngrok_auth_token"
+                                type="password"
+                                value={currentProviderConfig.auth_token || ''}
+                                onChange={(e) => handleConfigChange('auth_token', e.target.value)}
+                                className="w-full px-3 py-2 border border-input bg-background text-foreground rounded-md focus:outline-none focus:ring-2 focus:ring-ring placeholder:text-muted-foreground"
+                                placeholder={maskedTokens[selectedProvider] || "Enter ngrok auth token"}
+                            />
+                            <p className="text-xs text-muted-foreground mt-1">
+                                Find this under Your Authtoken in the ngrok dashboard
+                            </p>
+                        </div>
+                        <div>
+                            <label htmlFor="ngrok_domain" className="block text-sm font-medium mb-2">
+                                Domain
+                            </label>
+                            <input
+                                id="ngrok_domain"
+                                type="text"
+                                value={currentProviderConfig.domain || ''}
+                                onChange={(e) => handleConfigChange('domain', e.target.value)}
+                                className="w-full px-3 py-2 border border-input bg-background text-foreground rounded-md focus:outline-none focus:ring-2 focus:ring-ring placeholder:text-muted-foreground"
+                                placeholder="apps.example.com"
+                            />
+                            <p className="text-xs text-muted-foreground mt-1">
+                                A wildcard domain reserved in ngrok; apps are served at &lt;app&gt;.&lt;domain&gt;. Leave empty for a random URL per app
+                            </p>
+                        </div>
+                    </div>
+                )
+
+            case 'frp':
+                return (
+                    <div className="space-y-4">
+                        <div>
+                            <label htmlFor="frp_server_addr" className="block text-sm font-medium mb-2">
+                                Server Address *
+                            </label>
+                            <input
+                                id="frp_server_addr"
+                                type="text"
+                                value={currentProviderConfig.server_addr || ''}
+                                onChange={(e) => handleConfigChange('server_addr', e.target.value)}
+                                className="w-full px-3 py-2 border border-input bg-background text-foreground rounded-md focus:outline-none focus:ring-2 focus:ring-ring placeholder:text-muted-foreground"
+                                placeholder="vps.example.com"
+                            />
+                            <p className="text-xs text-muted-foreground mt-1">
+                                The host running your frp server (frps)
+                            </p>
+                        </div>
+                        <div>
+                            <label htmlFor="frp_server_port" className="block text-sm font-medium mb-2">
+                                Server Port
+                            </label>
+                            <input
+                                id="frp_server_port"
+                                type="text"
+                                inputMode="numeric"
+                                value={currentProviderConfig.server_port ?? ''}
+                                onChange={(e) => handleConfigChange('server_port', e.target.value)}
+                                className="w-full px-3 py-2 border border-input bg-background text-foreground rounded-md focus:outline-none focus:ring-2 focus:ring-ring placeholder:text-muted-foreground"
+                                placeholder="7000"
+                            />
+                            <p className="text-xs text-muted-foreground mt-1">
+                                The server's bindPort
+                            </p>
+                        </div>
+                        <div>
+                            <label htmlFor="frp_auth_token" className="block text-sm font-medium mb-2">
+                                Auth Token
+                            </label>
+                            <input
+                                id="frp_auth_token"
+                                type="password"
+                                value={currentProviderConfig.auth_token || ''}
+                                onChange={(e) => handleConfigChange('auth_token', e.target.value)}
+                                className="w-full px-3 py-2 border border-input bg-background text-foreground rounded-md focus:outline-none focus:ring-2 focus:ring-ring placeholder:text-muted-foreground"
+                                placeholder={maskedTokens[selectedProvider] || "The server's auth.token"}
+                            />
+                        </div>
+                        <div>
+                            <label htmlFor="frp_domain" className="block text-sm font-medium mb-2">
+                                Domain
+                            </label>
+                            <input
+                                id="frp_domain"
+                                type="text"
+                                value={currentProviderConfig.domain || ''}
+                                onChange={(e) => handleConfigChange('domain', e.target.value)}
+                                className="w-full px-3 py-2 border border-input bg-background text-foreground rounded-md focus:outline-none focus:ring-2 focus:ring-ring placeholder:text-muted-foreground"
+                                placeholder="apps.example.com"
+                            />
+                            <p className="text-xs text-muted-foreground mt-1">
+                                The server's subDomainHost; ingress rules without a hostname are served at &lt;app&gt;.&lt;domain&gt;
+                            </p>
+                        </div>
+                    </div>
+                )
+
             default:
                 return (
                     <div className="text-sm text-muted-foreground">
@@ -337,6 +457,108 @@ function Settings() {
                         </div>
                     </CardContent>
                 </Card>
+
+                <JobSettingsCard />
+
+                {/* Usage Telemetry */}
+                <Card>
+                    <CardHeader className="pb-2 sm:pb-2">
+                        <CardTitle>Usage Telemetry</CardTitle>
+                        <p className="text-sm text-muted-foreground mt-2">
+                            Help prioritize development by sharing coarse, anonymous usage stats once a day
+                        </p>
+                    </CardHeader>
+                    <CardContent className="pt-0">
+                        <div className="space-y-4">
+                            <div className="flex gap-3 items-start">
+                                <Checkbox
+                                    id="telemetry_enabled"
+                                    checked={telemetryEnabled}
+                                    onCheckedChange={(checked) => {
+                                        const newValue = checked as boolean
+                                        setTelemetryEnabled(newValue)
+
+                                        // Auto-save the setting
+                                        updateSettings.mutate({
+                                            auto_start_apps: autoStartApps,
+                                            telemetry_enabled: newValue
+                                        })
+                                    }}
+                                    className="mt-0.5 shrink-0"
+                                />
+                                <div className="flex-1 pt-0.5">
+                                    <label
+                                        htmlFor="telemetry_enabled"
+                                        className="text-sm font-medium cursor-pointer select-none leading-tight block"
+                                    >
+                                        Send anonymous usage reports
+                                    </label>
+                                    <p className="text-sm text-muted-foreground mt-1.5 leading-relaxed">
+                                        Reports include the version, OS, bucketed node and app counts, and tunnel providers in use. No names, hostnames, URLs or credentials are sent.
+                                    </p>
+                                </div>
+                            </div>
+
+                            <div>
+                                <Button
+                                    variant="outline"
+                                    size="sm"
+                                    onClick={() => setShowTelemetryPreview(!showTelemetryPreview)}
+                                >
+                                    {showTelemetryPreview ? 'Hide report preview' : 'Preview report'}
+                                </Button>
+                                {showTelemetryPreview && (
+                                    <pre className="mt-3 rounded-md bg-muted p-3 text-xs overflow-x-auto">
+                                        {telemetryPreviewLoading
+                                            ? 'Loading...'
+                                            : JSON.stringify(telemetryPreview, null, 2)}
+                                    </pre>
+                                )}
+                            </div>
+                        </div>
+                    </CardContent>
+                </Card>
+
+                {/* Experimental Features */}
+                <Card>
+                    <CardHeader className="pb-2 sm:pb-2">
+                        <CardTitle>Experimental Features</CardTitle>
+                        <p className="text-sm text-muted-foreground mt-2">
+                            Unfinished features that are off by default. Enable them at your own risk.
+                        </p>
+                    </CardHeader>
+                    <CardContent className="pt-0">
+                        <div className="space-y-4">
+                            {featureFlags?.map((flag) => (
+                                <div key={flag.name} className="flex gap-3 items-start">
+                                    <Checkbox
+                                        id={`feature_${flag.name}`}
+                                        checked={flag.enabled}
+                                        disabled={flag.source === 'env' || updateFeatureFlag.isPending}
+                                        onCheckedChange={(checked) =>
+                                            updateFeatureFlag.mutate({ name: flag.name, enabled: checked as boolean })
+                                        }
+                                        className="mt-0.5 shrink-0"
+                                    />
+                                    <div className="flex-1 pt-0.5">
+                                        <label
+                                            htmlFor={`feature_${flag.name}`}
+                                            className="text-sm font-medium cursor-pointer select-none leading-tight flex items-center gap-2"
+                                        >
+                                            <code>{flag.name}</code>
+                                            {flag.source === 'env' && (
+                                                <Badge variant="secondary">Set by {flag.env_var}</Badge>
+                                            )}
+                                        </label>
+                                        <p className="text-sm text-muted-foreground mt-1.5 leading-relaxed">
+                                            {flag.description}
+                                        </p>
+                                    </div>
+                                </div>
+                            ))}
+                        </div>
+                    </CardContent>
+                </Card>
             </div>
         </div>
     )
@@ -1,12 +1,16 @@
 import { useState, useEffect } from 'react';
 import Sidebar from './Sidebar';
 import Header from './Header';
+import { useEventStream } from '@/shared/hooks/useEventStream';
 
 interface MainLayoutProps {
   children: React.ReactNode;
 }
 
 function MainLayout({ children }: MainLayoutProps) {
+  // Refresh apps, jobs and nodes as the server reports changes
+  useEventStream();
+
   const [isSidebarOpen, setIsSidebarOpen] = useState(false);
   const [isSidebarCollapsed, setIsSidebarCollapsed] = useState(() => {
     // Load collapsed state from localStorage
@@ -10,6 +10,7 @@ import {
     DialogDescription,
 } from './Dialog'
 import { Button } from './Button'
+import type { ReactNode } from 'react'
 
 interface ConfirmationDialogProps {
     open: boolean
@@ -21,6 +22,7 @@ interface ConfirmationDialogProps {
     onConfirm: () => void
     isLoading?: boolean
     variant?: 'default' | 'destructive'
+    children?: ReactNode
 }
 
 function ConfirmationDialog({
@@ -33,6 +35,7 @@ function ConfirmationDialog({
     onConfirm,
     isLoading = false,
     variant = 'default',
+    children,
 }: ConfirmationDialogProps) {
     return (
         <Dialog open={open} onOpenChange={onOpenChange}>
@@ -43,6 +46,7 @@ function ConfirmationDialog({
                         <DialogTitle>{title}</DialogTitle>
                         <DialogDescription>{description}</DialogDescription>
                     </DialogHeader>
+                    {children}
                     <DialogFooter className="flex justify-end space-x-2">
                         <DialogClose asChild>
                             <Button variant="outline" disabled={isLoading}>
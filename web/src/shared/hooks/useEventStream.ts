@@ -0,0 +1,47 @@
+import { useEffect } from 'react'
+import { useQueryClient } from '@tanstack/react-query'
+import type { Job, ServerEvent, ServerEventType } from '../types/api'
+
+/**
+ * Keeps cached queries fresh from the server's event stream (GET /api/events) instead of polling:
+ * app status changes, job progress and node health transitions refetch the queries showing them,
+ * and a resync (events were missed) refetches everything. EventSource reconnects by itself when
+ * the stream closes.
+ */
+export function useEventStream(onEvent?: (event: ServerEvent) => void) {
+  const queryClient = useQueryClient()
+
+  useEffect(() => {
+    const source = new EventSource('/api/events', { withCredentials: true })
+
+    const handle = (type: ServerEventType) => (message: MessageEvent<string>) => {
+      const event = JSON.parse(message.data) as ServerEvent
+      switch (type) {
+        case 'app_status':
+          queryClient.invalidateQueries({ queryKey: ['apps'] })
+          queryClient.invalidateQueries({ queryKey: ['app', event.app_id] })
+          break
+        case 'job': {
+          const job = event.data as Job
+          queryClient.setQueryData(['job', job.id, event.node_id], job)
+          queryClient.invalidateQueries({ queryKey: ['jobs'] })
+          break
+        }
+        case 'node_health':
+          queryClient.invalidateQueries({ queryKey: ['nodes'] })
+          break
+      }
+      onEvent?.(event)
+    }
+
+    const types: ServerEventType[] = ['app_status', 'job', 'node_health', 'notification']
+    const listeners = types.map((type) => [type, handle(type)] as const)
+    listeners.forEach(([type, listener]) => source.addEventListener(type, listener))
+    source.addEventListener('resync', () => queryClient.invalidateQueries())
+
+    return () => {
+      listeners.forEach(([type, listener]) => source.removeEventListener(type, listener))
+      source.close()
+    }
+  }, [queryClient, onEvent])
+}
@@ -11,21 +11,63 @@ import type {
   UpdateAppRequest,
   Settings,
   UpdateSettingsRequest,
+  TelemetryReport,
+  FeatureFlag,
+  SettingsSection,
+  SettingsSectionSchema,
+  UserPreferences,
+  UpdatePreferencesRequest,
+  OperationsLockStatus,
+  Approval,
+  ApprovalStatus,
+  UserQuota,
+  SetQuotaRequest,
+  QuotaUsage,
+  PortReservation,
+  ReservePortRequest,
+  UserRole,
+  UserAccount,
+  UserList,
+  Invitation,
+  Session,
+  APIToken,
+  CreateAPITokenRequest,
+  IssuedAPIToken,
+  InviteUserRequest,
+  IssuedInvitation,
   CloudflareTunnelResponse,
+  TunnelInventory,
+  TunnelConnectorStatus,
+  TunnelOptions,
+  AppTraffic,
+  TrafficPeriod,
+  ImportTunnelRequest,
   TunnelByAppResponse,
   ComposeVersion,
+  ComposeIntegrity,
   RollbackRequest,
   SystemStats,
   Node,
   RegisterNodeRequest,
   UpdateNodeRequest,
+  QueuedOperation,
   ProviderFeatures,
+  ProviderZone,
+  IngressWarning,
+  ZoneHostname,
   TunnelProvidersResponse,
   Job,
   JobResponse,
   AppSchedule,
   UpdateScheduleRequest,
   ScheduleNextRuns,
+  AppWebhook,
+  AppStatusPage,
+  IssuedStatusPage,
+  CreateWebhookRequest,
+  UpdateWebhookRequest,
+  DeleteAppResponse,
+  CleanupStepId,
 } from '../types/api';
 
 interface IngressRule {
@@ -41,10 +83,13 @@ export interface User {
   id: string;
   name: string;
   picture?: string;
+  role?: UserRole; // Viewers can only read
 }
 
 // Apps API
-export function useApps(nodeIdsOverride?: string[]) {
+// fields limits the response to those app fields (leaving out compose content); the query is
+// cached separately from the full list
+export function useApps(nodeIdsOverride?: string[], fields?: readonly (keyof App)[]) {
   const { selectedNodeIds: globalNodeIds } = useNodeContext();
   
   // Use override if provided, otherwise use global context
@@ -52,19 +97,20 @@ export function useApps(nodeIdsOverride?: string[]) {
   
   // Build query key with node filter
   const queryKey = nodeIds && nodeIds.length > 0 
-    ? ['apps', { nodeIds }] 
-    : ['apps'];
+    ? ['apps', { nodeIds, fields }] 
+    : fields ? ['apps', { fields }] : ['apps'];
   
   return useQuery<App[]>({
     queryKey,
     queryFn: () => {
+      const params: Record<string, string> = fields ? { fields: fields.join(',') } : {};
       // Build node_ids parameter
       if (nodeIds && nodeIds.length > 0) {
         const nodeIdsParam = nodeIds.join(',');
-        return apiClient.get<App[]>('/api/apps', { node_ids: nodeIdsParam });
+        return apiClient.get<App[]>('/api/apps', { ...params, node_ids: nodeIdsParam });
       }
       // Default: fetch from all nodes
-      return apiClient.get<App[]>('/api/apps', { node_ids: 'all' });
+      return apiClient.get<App[]>('/api/apps', { ...params, node_ids: 'all' });
     },
   });
 }
@@ -79,6 +125,11 @@ export function useApp(id: string, nodeId: string) {
   });
 }
 
+// Image URL of an app's icon; it 404s when none was found, so callers need a fallback
+export function appIconUrl(appId: string, nodeId: string) {
+  return `/api/apps/${encodeURIComponent(appId)}/icon?node_id=${encodeURIComponent(nodeId)}`;
+}
+
 export function useCreateApp() {
   const queryClient = useQueryClient();
   
@@ -90,6 +141,19 @@ export function useCreateApp() {
   });
 }
 
+// Queues the creation as a job on data.node_id instead of waiting for it
+export function useCreateAppAsync() {
+  const queryClient = useQueryClient();
+
+  return useMutation({
+    mutationFn: (data: CreateAppRequest) => apiClient.post<JobResponse, CreateAppRequest>('/api/apps?async=true', data),
+    onSuccess: () => {
+      queryClient.invalidateQueries({ queryKey: ['apps'] });
+      queryClient.invalidateQueries({ queryKey: ['jobs'] });
+    },
+  });
+}
+
 export function useUpdateApp(id: string, nodeId: string) {
   const queryClient = useQueryClient();
   
@@ -108,8 +172,13 @@ export function useDeleteApp() {
   const queryClient = useQueryClient();
   
   return useMutation({
-    mutationFn: ({ id, nodeId }: { id: string; nodeId: string }) => {
-      return apiClient.delete<{ message: string; appID: string }>(`/api/apps/${id}?node_id=${nodeId}`);
+    mutationFn: ({ id, nodeId, archive, skip, removeVolumes, force }: { id: string; nodeId: string; archive?: boolean; skip?: CleanupStepId[]; removeVolumes?: boolean; force?: boolean }) => {
+      const params: Record<string, string | boolean> = { node_id: nodeId };
+      if (archive) params.archive = true;
+      if (force) params.force = true;
+      if (removeVolumes) params.remove_volumes = true;
+      if (skip?.length) params.skip = skip.join(',');
+      return apiClient.delete<DeleteAppResponse>(`/api/apps/${id}`, params);
     },
     // Optimistic update - remove from cache immediately
     onMutate: async ({ id }) => {
@@ -330,13 +399,33 @@ export function useComposeVersion(appId: string, version: number, nodeId: string
   });
 }
 
+export function useComposeIntegrity(appId: string, nodeId: string) {
+  return useQuery<ComposeIntegrity>({
+    queryKey: ['compose-integrity', appId, nodeId],
+    queryFn: () => apiClient.get<ComposeIntegrity>(`/api/apps/${appId}/compose/integrity?node_id=${nodeId}`),
+    enabled: !!appId && !!nodeId,
+  });
+}
+
+// Rewrites an edited docker-compose.yml from the current version; the edited file is kept as a .bak
+export function useRestoreComposeFile(appId: string, nodeId: string) {
+  const queryClient = useQueryClient();
+
+  return useMutation({
+    mutationFn: () => apiClient.post<ComposeIntegrity>(`/api/apps/${appId}/compose/restore?node_id=${nodeId}`),
+    onSuccess: () => {
+      queryClient.invalidateQueries({ queryKey: ['compose-integrity', appId] });
+    },
+  });
+}
+
 export function useRollbackToVersion(appId: string, nodeId: string) {
   const queryClient = useQueryClient();
   
   return useMutation({
     mutationFn: ({ version, change_reason }: { version: number; change_reason?: string }) => {
       const body: RollbackRequest = change_reason ? { change_reason } : {};
-      return apiClient.post<{ message: string; app: App; new_version: ComposeVersion }>(`/api/apps/${appId}/compose/rollback/${version}?node_id=${nodeId}`, body);
+      return apiClient.post<{ message: string; app: App; new_version: ComposeVersion; job_id?: string }>(`/api/apps/${appId}/compose/rollback/${version}?node_id=${nodeId}`, body);
     },
     onSuccess: () => {
       // Invalidate related queries
@@ -366,6 +455,68 @@ export function useUpdateSettings() {
   });
 }
 
+export function useSettingsSchema() {
+  return useQuery<{ sections: SettingsSectionSchema[] }>({
+    queryKey: ['settings-schema'],
+    queryFn: () => apiClient.get<{ sections: SettingsSectionSchema[] }>('/api/settings/schema'),
+    staleTime: Infinity,
+  });
+}
+
+export function useSettingsSection(section: string) {
+  return useQuery<SettingsSection>({
+    queryKey: ['settings-section', section],
+    queryFn: () => apiClient.get<SettingsSection>(`/api/settings/${section}`),
+  });
+}
+
+export function useUpdateSettingsSection(section: string) {
+  const queryClient = useQueryClient();
+
+  return useMutation({
+    mutationFn: (values: Record<string, unknown>) =>
+      apiClient.put<SettingsSection, Record<string, unknown>>(`/api/settings/${section}`, values),
+    onSuccess: () => {
+      queryClient.invalidateQueries({ queryKey: ['settings-section', section] });
+      queryClient.invalidateQueries({ queryKey: ['settings'] });
+    },
+  });
+}
+
+export function useSendTestEmail() {
+  return useMutation({
+    mutationFn: (to: string) =>
+      apiClient.post<{ message: string; to: string }, { to: string }>('/api/settings/smtp/test', { to }),
+  });
+}
+
+export function useTelemetryPreview(enabled: boolean) {
+  return useQuery<TelemetryReport>({
+    queryKey: ['telemetry-preview'],
+    queryFn: () => apiClient.get<TelemetryReport>('/api/settings/telemetry/preview'),
+    enabled,
+  });
+}
+
+export function useFeatureFlags() {
+  return useQuery<FeatureFlag[]>({
+    queryKey: ['feature-flags'],
+    queryFn: () => apiClient.get<FeatureFlag[]>('/api/settings/features'),
+  });
+}
+
+export function useUpdateFeatureFlag() {
+  const queryClient = useQueryClient();
+
+  return useMutation({
+    mutationFn: ({ name, enabled }: { name: string; enabled: boolean }) =>
+      apiClient.put<FeatureFlag, { enabled: boolean }>(`/api/settings/features/${name}`, { enabled }),
+    onSuccess: () => {
+      queryClient.invalidateQueries({ queryKey: ['feature-flags'] });
+    },
+  });
+}
+
 // Auth API - GitHub OAuth via go-pkgz/auth
 // Auth endpoints:
 //   - GET /auth/github/login - Redirects to GitHub for OAuth
@@ -398,6 +549,261 @@ export function useCurrentUser() {
   });
 }
 
+// Preferences are kept per user on the server, so they follow the user across browsers
+export function usePreferences() {
+  return useQuery<UserPreferences>({
+    queryKey: ['preferences'],
+    queryFn: () => apiClient.get<UserPreferences>('/api/me/preferences'),
+    staleTime: 5 * 60 * 1000, // 5 minutes
+  });
+}
+
+export function useUpdatePreferences() {
+  const queryClient = useQueryClient();
+
+  return useMutation({
+    mutationFn: (data: UpdatePreferencesRequest) =>
+      apiClient.put<UserPreferences, UpdatePreferencesRequest>('/api/me/preferences', data),
+    onSuccess: (prefs) => {
+      queryClient.setQueryData(['preferences'], prefs);
+    },
+  });
+}
+
+// While locked, app changes are refused with 423 on every node; polled so a banner can show it
+export function useOperationsLock() {
+  return useQuery<OperationsLockStatus>({
+    queryKey: ['operations-lock'],
+    queryFn: () => apiClient.get<OperationsLockStatus>('/api/system/operations-lock'),
+    refetchInterval: 30000,
+  });
+}
+
+export function useSetOperationsLock() {
+  const queryClient = useQueryClient();
+
+  return useMutation({
+    mutationFn: (reason: string | null) =>
+      reason === null
+        ? apiClient.delete<OperationsLockStatus>('/api/system/operations-lock')
+        : apiClient.put<OperationsLockStatus, { reason: string }>('/api/system/operations-lock', { reason }),
+    onSuccess: (status) => {
+      queryClient.setQueryData(['operations-lock'], status);
+    },
+  });
+}
+
+// Approvals live on the node that runs the operation; nodeId omitted is the primary
+export function useApprovals(nodeId?: string, status?: ApprovalStatus) {
+  const params = new URLSearchParams();
+  if (nodeId) params.set('node_id', nodeId);
+  if (status) params.set('status', status);
+  const query = params.toString();
+
+  return useQuery<Approval[]>({
+    queryKey: ['approvals', nodeId, status],
+    queryFn: () => apiClient.get<Approval[]>(`/api/approvals${query ? `?${query}` : ''}`),
+    refetchInterval: 30000,
+  });
+}
+
+// Confirming runs the operation; rejecting turns it down (or withdraws it, for the requester)
+export function useDecideApproval() {
+  const queryClient = useQueryClient();
+
+  return useMutation({
+    mutationFn: ({ id, nodeId, decision }: { id: string; nodeId?: string; decision: 'confirm' | 'reject' }) =>
+      apiClient.post<unknown>(`/api/approvals/${id}/${decision}${nodeId ? `?node_id=${nodeId}` : ''}`),
+    onSuccess: () => {
+      queryClient.invalidateQueries({ queryKey: ['approvals'] });
+      queryClient.invalidateQueries({ queryKey: ['apps'] });
+      queryClient.invalidateQueries({ queryKey: ['nodes'] });
+    },
+  });
+}
+
+// Per-user app quotas, kept on the primary
+export function useQuotas() {
+  return useQuery<UserQuota[]>({
+    queryKey: ['quotas'],
+    queryFn: () => apiClient.get<UserQuota[]>('/api/quotas'),
+  });
+}
+
+// What a user's apps use against their quota; the signed-in user's when user is omitted
+export function useQuotaUsage(user?: string) {
+  return useQuery<QuotaUsage>({
+    queryKey: ['quotas', 'usage', user],
+    queryFn: () => apiClient.get<QuotaUsage>(`/api/quotas/usage${user ? `?user=${encodeURIComponent(user)}` : ''}`),
+  });
+}
+
+export function useSetQuota() {
+  const queryClient = useQueryClient();
+
+  return useMutation({
+    mutationFn: ({ user, data }: { user: string; data: SetQuotaRequest }) =>
+      apiClient.put<UserQuota, SetQuotaRequest>(`/api/quotas/${encodeURIComponent(user)}`, data),
+    onSuccess: () => {
+      queryClient.invalidateQueries({ queryKey: ['quotas'] });
+    },
+  });
+}
+
+export function useDeleteQuota() {
+  const queryClient = useQueryClient();
+
+  return useMutation({
+    mutationFn: (user: string) => apiClient.delete<unknown>(`/api/quotas/${encodeURIComponent(user)}`),
+    onSuccess: () => {
+      queryClient.invalidateQueries({ queryKey: ['quotas'] });
+    },
+  });
+}
+
+// Host ports reserved on a node
+export function usePortReservations(nodeId: string) {
+  return useQuery<PortReservation[]>({
+    queryKey: ['ports', nodeId],
+    queryFn: () => apiClient.get<PortReservation[]>(`/api/ports?node_id=${nodeId}`),
+    enabled: !!nodeId,
+  });
+}
+
+export function useReservePort() {
+  const queryClient = useQueryClient();
+
+  return useMutation({
+    mutationFn: ({ nodeId, data }: { nodeId: string; data: ReservePortRequest }) =>
+      apiClient.post<PortReservation, ReservePortRequest>(`/api/ports?node_id=${nodeId}`, data),
+    onSuccess: (_, { nodeId }) => {
+      queryClient.invalidateQueries({ queryKey: ['ports', nodeId] });
+    },
+  });
+}
+
+export function useReleasePort() {
+  const queryClient = useQueryClient();
+
+  return useMutation({
+    mutationFn: ({ id, nodeId }: { id: string; nodeId: string }) =>
+      apiClient.delete<unknown>(`/api/ports/${id}?node_id=${nodeId}`),
+    onSuccess: (_, { nodeId }) => {
+      queryClient.invalidateQueries({ queryKey: ['ports', nodeId] });
+    },
+  });
+}
+
+// Invited users and their invitations, kept on the primary
+export function useUsers() {
+  return useQuery<UserList>({
+    queryKey: ['users'],
+    queryFn: () => apiClient.get<UserList>('/api/users'),
+  });
+}
+
+export function useDeleteUser() {
+  const queryClient = useQueryClient();
+
+  return useMutation({
+    mutationFn: (name: string) => apiClient.delete<unknown>(`/api/users/${encodeURIComponent(name)}`),
+    onSuccess: () => {
+      queryClient.invalidateQueries({ queryKey: ['users'] });
+    },
+  });
+}
+
+export function useInvitations() {
+  return useQuery<Invitation[]>({
+    queryKey: ['users', 'invitations'],
+    queryFn: () => apiClient.get<Invitation[]>('/api/users/invitations'),
+  });
+}
+
+// The invite link is only returned here, once
+export function useInviteUser() {
+  const queryClient = useQueryClient();
+
+  return useMutation({
+    mutationFn: (data: InviteUserRequest) => apiClient.post<IssuedInvitation, InviteUserRequest>('/api/users/invite', data),
+    onSuccess: () => {
+      queryClient.invalidateQueries({ queryKey: ['users', 'invitations'] });
+    },
+  });
+}
+
+export function useRevokeInvitation() {
+  const queryClient = useQueryClient();
+
+  return useMutation({
+    mutationFn: (id: string) => apiClient.delete<unknown>(`/api/users/invitations/${encodeURIComponent(id)}`),
+    onSuccess: () => {
+      queryClient.invalidateQueries({ queryKey: ['users', 'invitations'] });
+    },
+  });
+}
+
+export function useSessions() {
+  return useQuery<Session[]>({
+    queryKey: ['sessions'],
+    queryFn: () => apiClient.get<Session[]>('/api/me/sessions'),
+  });
+}
+
+// Revoking the current session signs out
+export function useRevokeSession() {
+  const queryClient = useQueryClient();
+
+  return useMutation({
+    mutationFn: (id: string) => apiClient.delete<unknown>(`/api/me/sessions/${encodeURIComponent(id)}`),
+    onSuccess: () => {
+      queryClient.invalidateQueries({ queryKey: ['sessions'] });
+    },
+  });
+}
+
+export function useAPITokens() {
+  return useQuery<APIToken[]>({
+    queryKey: ['api-tokens'],
+    queryFn: () => apiClient.get<APIToken[]>('/api/tokens'),
+  });
+}
+
+// The response carries the token, which isn't shown again
+export function useCreateAPIToken() {
+  const queryClient = useQueryClient();
+
+  return useMutation({
+    mutationFn: (data: CreateAPITokenRequest) => apiClient.post<IssuedAPIToken, CreateAPITokenRequest>('/api/tokens', data),
+    onSuccess: () => {
+      queryClient.invalidateQueries({ queryKey: ['api-tokens'] });
+    },
+  });
+}
+
+export function useRenameAPIToken() {
+  const queryClient = useQueryClient();
+
+  return useMutation({
+    mutationFn: ({ id, name }: { id: string; name: string }) =>
+      apiClient.put<APIToken, { name: string }>(`/api/tokens/${encodeURIComponent(id)}`, { name }),
+    onSuccess: () => {
+      queryClient.invalidateQueries({ queryKey: ['api-tokens'] });
+    },
+  });
+}
+
+export function useRevokeAPIToken() {
+  const queryClient = useQueryClient();
+
+  return useMutation({
+    mutationFn: (id: string) => apiClient.delete<unknown>(`/api/tokens/${encodeURIComponent(id)}`),
+    onSuccess: () => {
+      queryClient.invalidateQueries({ queryKey: ['api-tokens'] });
+    },
+  });
+}
+
 // ============================================================================
 // Provider-Agnostic Tunnel Hooks
 // ============================================================================
@@ -419,6 +825,28 @@ export function useProviderFeatures(provider: string) {
   });
 }
 
+// List the DNS zones a provider can route hostnames in (for hostname pickers)
+export function useProviderZones(provider: string) {
+  return useQuery({
+    queryKey: ['tunnels', 'providers', provider, 'zones'],
+    queryFn: () => apiClient.get<{ provider: string; zones: ProviderZone[] }>(`/api/tunnels/providers/${provider}/zones`),
+    enabled: !!provider,
+    staleTime: 5 * 60 * 1000,
+    retry: false,
+  });
+}
+
+// List existing DNS records in a zone, to flag hostnames that already point elsewhere
+export function useZoneHostnames(provider: string, zoneId: string | undefined) {
+  return useQuery({
+    queryKey: ['tunnels', 'providers', provider, 'zones', zoneId, 'hostnames'],
+    queryFn: () => apiClient.get<{ zone_id: string; hostnames: ZoneHostname[] }>(`/api/tunnels/providers/${provider}/zones/${zoneId}/hostnames`),
+    enabled: !!provider && !!zoneId,
+    staleTime: 60 * 1000,
+    retry: false,
+  });
+}
+
 // List all tunnels (provider-agnostic)
 export function useTunnels(nodeIds?: string[]) {
   return useQuery({
@@ -433,6 +861,45 @@ export function useTunnels(nodeIds?: string[]) {
   });
 }
 
+// Tunnel inventory across all nodes and the provider account, with orphaned tunnels flagged.
+// Calls the provider API, so it only runs when enabled.
+export function useTunnelInventory(enabled: boolean) {
+  return useQuery({
+    queryKey: ['tunnels', 'inventory'],
+    queryFn: () => apiClient.get<TunnelInventory>('/api/tunnels', { inventory: 'true' }),
+    enabled,
+  });
+}
+
+// Delete an orphaned tunnel (one no app uses) from the provider account
+export function useDeleteOrphanedTunnel() {
+  const queryClient = useQueryClient();
+
+  return useMutation({
+    mutationFn: (tunnelId: string) => {
+      return apiClient.delete<{ tunnel_id: string; message: string }>(`/api/tunnels/${tunnelId}`);
+    },
+    onSuccess: () => {
+      queryClient.invalidateQueries({ queryKey: ['tunnels', 'inventory'] });
+    },
+  });
+}
+
+// Import a tunnel that already exists in the provider account into an app on the given node
+export function useImportTunnel() {
+  const queryClient = useQueryClient();
+
+  return useMutation({
+    mutationFn: ({ nodeId, ...body }: ImportTunnelRequest & { nodeId: string }) => {
+      return apiClient.post<App, ImportTunnelRequest>(`/api/tunnels/import?node_id=${nodeId}`, body);
+    },
+    onSuccess: () => {
+      queryClient.invalidateQueries({ queryKey: ['apps'] });
+      queryClient.invalidateQueries({ queryKey: ['tunnels'] });
+    },
+  });
+}
+
 // Get tunnel for specific app (provider-agnostic). When no tunnel, returns 200 with tunnel: null and app_id, tunnel_mode, node_id.
 export function useTunnel(appId: string, nodeId: string) {
   return useQuery({
@@ -444,6 +911,24 @@ export function useTunnel(appId: string, nodeId: string) {
   });
 }
 
+// Connector status of an app's named tunnel: healthy, reconnecting, or no_connector when nothing runs it
+export function useTunnelStatus(appId: string, nodeId: string, enabled = true) {
+  return useQuery({
+    queryKey: ['tunnels', 'app', appId, nodeId, 'status'],
+    queryFn: () => apiClient.get<TunnelConnectorStatus>(`/api/apps/${appId}/tunnel/status?node_id=${nodeId}`),
+    enabled: enabled && !!appId && !!nodeId,
+  });
+}
+
+// HTTP traffic (requests, bandwidth, visits, top countries and status codes) for an app's tunnel hostnames
+export function useAppTraffic(appId: string, nodeId: string, period: TrafficPeriod = '24h', enabled = true) {
+  return useQuery({
+    queryKey: ['app', appId, nodeId, 'traffic', period],
+    queryFn: () => apiClient.get<AppTraffic>(`/api/apps/${appId}/traffic?node_id=${nodeId}&period=${period}`),
+    enabled: enabled && !!appId && !!nodeId,
+  });
+}
+
 // Create named (custom domain) tunnel for an app that has none
 export function useCreateTunnelForApp() {
   const queryClient = useQueryClient();
@@ -524,7 +1009,7 @@ export function useUpdateTunnelIngress() {
       hostname?: string; 
       targetDomain?: string;
     }) => {
-      return apiClient.put<{ message: string }>(`/api/tunnels/apps/${appId}/ingress?node_id=${nodeId}`, {
+      return apiClient.put<{ message: string; warnings?: IngressWarning[] }>(`/api/tunnels/apps/${appId}/ingress?node_id=${nodeId}`, {
         ingress_rules: ingressRules,
         hostname,
         target_domain: targetDomain,
@@ -538,6 +1023,34 @@ export function useUpdateTunnelIngress() {
   });
 }
 
+// Set how many containers run an app's named tunnel (provider must support replicas)
+export function useSetTunnelReplicas() {
+  const queryClient = useQueryClient();
+
+  return useMutation({
+    mutationFn: ({ appId, nodeId, replicas }: { appId: string; nodeId: string; replicas: number }) => {
+      return apiClient.put<{ message: string; app_id: string; replicas: number }>(`/api/tunnels/apps/${appId}/replicas?node_id=${nodeId}`, { replicas });
+    },
+    onSuccess: (_, variables) => {
+      queryClient.invalidateQueries({ queryKey: ['tunnels', 'app', variables.appId] });
+    },
+  });
+}
+
+// Save connector options (e.g. protocol http2 where QUIC is blocked) on an app's named tunnel
+export function useSetTunnelOptions() {
+  const queryClient = useQueryClient();
+
+  return useMutation({
+    mutationFn: ({ appId, nodeId, options }: { appId: string; nodeId: string; options: TunnelOptions }) => {
+      return apiClient.put<{ message: string; app_id: string; options: TunnelOptions }>(`/api/tunnels/apps/${appId}/options?node_id=${nodeId}`, options);
+    },
+    onSuccess: (_, variables) => {
+      queryClient.invalidateQueries({ queryKey: ['tunnels', 'app', variables.appId] });
+    },
+  });
+}
+
 // Create DNS record (provider-agnostic, may return 501 if not supported)
 export function useCreateTunnelDNSRecord() {
   const queryClient = useQueryClient();
@@ -559,8 +1072,9 @@ export function useDeleteTunnel() {
   const queryClient = useQueryClient();
 
   return useMutation({
-    mutationFn: ({ appId, nodeId }: { appId: string; nodeId: string }) => {
-      return apiClient.delete<JobResponse>(`/api/tunnels/apps/${appId}?node_id=${nodeId}`);
+    mutationFn: ({ appId, nodeId, quickTunnelFallback }: { appId: string; nodeId: string; quickTunnelFallback?: boolean }) => {
+      const fallback = quickTunnelFallback ? '&quick_tunnel=true' : '';
+      return apiClient.delete<JobResponse>(`/api/tunnels/apps/${appId}?node_id=${nodeId}${fallback}`);
     },
     onSuccess: (_, variables) => {
       // Invalidate jobs query so AppActions picks up the new job and shows progress
@@ -723,6 +1237,27 @@ export function useNodeHealth(id: string) {
   });
 }
 
+// Operations queued on the primary while a node is offline
+export function useNodeOperations(id: string, status?: QueuedOperation['status']) {
+  return useQuery<QueuedOperation[]>({
+    queryKey: ['node-operations', id, status],
+    queryFn: () =>
+      apiClient.get<QueuedOperation[]>(`/api/nodes/${id}/operations${status ? `?status=${status}` : ''}`),
+    enabled: !!id,
+  });
+}
+
+export function useCancelNodeOperation(id: string) {
+  const queryClient = useQueryClient();
+  return useMutation({
+    mutationFn: (opId: string) =>
+      apiClient.delete<{ message: string; operationID: string }>(`/api/nodes/${id}/operations/${opId}`),
+    onSuccess: () => {
+      queryClient.invalidateQueries({ queryKey: ['node-operations', id] });
+    },
+  });
+}
+
 // Get current node info
 export function useCurrentNode() {
   return useQuery<Node>({
@@ -745,6 +1280,25 @@ export function useJob(jobId: string | null, nodeId: string | null) {
   });
 }
 
+// Get recent jobs of every selected node, newest first and tagged with their node
+export function useJobs(filters: { status?: Job['status']; type?: string; limit?: number } = {}) {
+  const { selectedNodeIds } = useNodeContext();
+  const nodeIds = selectedNodeIds && selectedNodeIds.length > 0 ? selectedNodeIds.join(',') : 'all';
+
+  return useQuery<Job[]>({
+    queryKey: ['jobs', { nodeIds, ...filters }],
+    queryFn: () => {
+      const params: Record<string, string | number> = { node_ids: nodeIds };
+      if (filters.status) params.status = filters.status;
+      if (filters.type) params.type = filters.type;
+      if (filters.limit) params.limit = filters.limit;
+      return apiClient.get<Job[]>('/api/jobs', params);
+    },
+    refetchInterval: (query) =>
+      query.state.data?.some(job => job.status === 'pending' || job.status === 'running') ? 5000 : false,
+  });
+}
+
 // Get recent jobs for an app
 export function useAppJobs(appId: string, nodeId: string) {
   return useQuery<Job[]>({
@@ -820,6 +1374,92 @@ export function useTestSchedule() {
   });
 }
 
+// Webhook API
+export function useAppWebhooks(appId: string, nodeId: string) {
+  return useQuery<AppWebhook[]>({
+    queryKey: ['app-webhooks', appId, nodeId],
+    queryFn: () => apiClient.get<AppWebhook[]>(`/api/apps/${appId}/webhooks`, { node_id: nodeId }),
+    enabled: !!appId && !!nodeId,
+  });
+}
+
+export function useCreateAppWebhook(appId: string, nodeId: string) {
+  const queryClient = useQueryClient();
+  return useMutation<AppWebhook, Error, CreateWebhookRequest>({
+    mutationFn: (data) =>
+      apiClient.post<AppWebhook, CreateWebhookRequest>(`/api/apps/${appId}/webhooks`, data, { node_id: nodeId }),
+    onSuccess: () => {
+      queryClient.invalidateQueries({ queryKey: ['app-webhooks', appId] });
+    },
+  });
+}
+
+export function useUpdateAppWebhook(appId: string, nodeId: string) {
+  const queryClient = useQueryClient();
+  return useMutation<AppWebhook, Error, { webhookId: string; data: UpdateWebhookRequest }>({
+    mutationFn: ({ webhookId, data }) =>
+      apiClient.put<AppWebhook, UpdateWebhookRequest>(`/api/apps/${appId}/webhooks/${webhookId}?node_id=${nodeId}`, data),
+    onSuccess: () => {
+      queryClient.invalidateQueries({ queryKey: ['app-webhooks', appId] });
+    },
+  });
+}
+
+export function useDeleteAppWebhook(appId: string, nodeId: string) {
+  const queryClient = useQueryClient();
+  return useMutation<{ message: string }, Error, string>({
+    mutationFn: (webhookId) =>
+      apiClient.delete<{ message: string }>(`/api/apps/${appId}/webhooks/${webhookId}`, { node_id: nodeId }),
+    onSuccess: () => {
+      queryClient.invalidateQueries({ queryKey: ['app-webhooks', appId] });
+    },
+  });
+}
+
+export function useTestAppWebhook(appId: string, nodeId: string) {
+  const queryClient = useQueryClient();
+  return useMutation<AppWebhook, Error, string>({
+    mutationFn: (webhookId) =>
+      apiClient.post<AppWebhook>(`/api/apps/${appId}/webhooks/${webhookId}/test`, undefined, { node_id: nodeId }),
+    onSuccess: () => {
+      queryClient.invalidateQueries({ queryKey: ['app-webhooks', appId] });
+    },
+  });
+}
+
+// 404s while the app has no status page
+export function useAppStatusPage(appId: string, nodeId: string) {
+  return useQuery<AppStatusPage>({
+    queryKey: ['app-status-page', appId, nodeId],
+    queryFn: () => apiClient.get<AppStatusPage>(`/api/apps/${appId}/status-page`, { node_id: nodeId }),
+    enabled: !!appId && !!nodeId,
+    retry: false,
+  });
+}
+
+// Enabling again replaces the link; the new one is only returned here, once
+export function useEnableAppStatusPage(appId: string, nodeId: string) {
+  const queryClient = useQueryClient();
+  return useMutation<IssuedStatusPage, Error, void>({
+    mutationFn: () =>
+      apiClient.post<IssuedStatusPage>(`/api/apps/${appId}/status-page`, undefined, { node_id: nodeId }),
+    onSuccess: () => {
+      queryClient.invalidateQueries({ queryKey: ['app-status-page', appId] });
+    },
+  });
+}
+
+export function useDisableAppStatusPage(appId: string, nodeId: string) {
+  const queryClient = useQueryClient();
+  return useMutation<{ message: string }, Error, void>({
+    mutationFn: () =>
+      apiClient.delete<{ message: string }>(`/api/apps/${appId}/status-page`, { node_id: nodeId }),
+    onSuccess: () => {
+      queryClient.invalidateQueries({ queryKey: ['app-status-page', appId] });
+    },
+  });
+}
+
 export function useScheduleNextRuns(appId: string, nodeId: string) {
   return useQuery<ScheduleNextRuns>({
     queryKey: ['schedule-next-runs', appId, nodeId],
@@ -4,10 +4,27 @@ export interface Node {
   api_endpoint: string;
   // api_key is excluded from API responses for security - never exposed to frontend
   is_primary: boolean;
-  status: 'online' | 'offline' | 'unreachable';
+  status: 'online' | 'offline' | 'unreachable' | 'lost';
   last_seen?: string;
   created_at: string;
   updated_at: string;
+  // How far the node's clock was from the primary's at its last heartbeat; positive is ahead
+  clock_skew_ms?: number;
+  clock_skew_warning?: string;
+}
+
+export interface QueuedOperation {
+  id: string;
+  node_id: string;
+  method: string;
+  path: string;
+  raw_query?: string;
+  body?: string;
+  status: 'pending' | 'completed' | 'failed';
+  response_status?: number;
+  error_message?: string;
+  created_at: string;
+  updated_at: string;
 }
 
 export interface App {
@@ -19,14 +36,21 @@ export interface App {
   tunnel_id: string;
   tunnel_domain: string;
   public_url: string;
-  status: 'running' | 'stopped' | 'updating' | 'error' | 'pending';
+  status: 'running' | 'stopped' | 'updating' | 'error' | 'pending' | 'degraded'; // degraded = running, but crash-looping or OOM-killed
   error_message: string;
   node_id: string;
   node_name?: string; // For display purposes (added by backend)
   tunnel_mode?: '' | 'custom' | 'quick'; // '' = none, custom = named tunnel, quick = trycloudflare.com
+  labels?: Record<string, string>; // Matched by ?selector= on the apps list
+  compose_files?: Record<string, string>; // Extra compose files for include: and extends:, by path in the app directory
+  env_template?: string; // Managed .env template
+  storage_root?: string; // Named storage root holding the app directory; absent is the default root
+  owner?: string; // Signed-in user who created the app
   created_at: string;
   updated_at: string;
   schedule?: AppSchedule; // Optional schedule for this app
+  stale?: boolean; // Served from the primary's cache because the node is unreachable
+  synced_at?: string; // When the node last reported this app (stale apps only)
 }
 
 export interface AppSchedule {
@@ -40,6 +64,78 @@ export interface AppSchedule {
   updated_at: string;
 }
 
+export type WebhookEvent = 'start' | 'stop' | 'update' | 'crash' | 'task_failed';
+
+// Batches non-critical events into one delivery; empty sends them as they happen
+export type WebhookDigest = '' | 'hourly' | 'daily';
+
+// Per-app lifecycle webhook; secret is only present in the response that created it
+export interface AppWebhook {
+  id: string;
+  app_id: string;
+  url: string;
+  secret?: string;
+  events: WebhookEvent[];
+  enabled: boolean;
+  digest: WebhookDigest;
+  quiet_hours_start: string; // HH:MM in timezone, empty for none
+  quiet_hours_end: string;
+  timezone: string;
+  last_status: number;
+  last_error?: string;
+  last_delivered_at?: string;
+  created_at: string;
+  updated_at: string;
+}
+
+export interface CreateWebhookRequest {
+  url: string;
+  events: WebhookEvent[];
+  digest?: WebhookDigest;
+  quiet_hours_start?: string;
+  quiet_hours_end?: string;
+  timezone?: string;
+}
+
+export interface UpdateWebhookRequest {
+  url?: string;
+  events?: WebhookEvent[];
+  enabled?: boolean;
+  digest?: WebhookDigest;
+  quiet_hours_start?: string;
+  quiet_hours_end?: string;
+  timezone?: string;
+}
+
+// Public status page of an app; its link is only shown when it is enabled
+export interface AppStatusPage {
+  app_id: string;
+  created_by?: string;
+  created_at: string;
+}
+
+export interface IssuedStatusPage extends AppStatusPage {
+  token: string;
+  url: string;
+}
+
+export type PublicAppStatus = 'operational' | 'degraded' | 'down' | 'maintenance' | 'stopped';
+
+// What a status page shows, served at its public link
+export interface AppStatusReport {
+  app: string;
+  status: PublicAppStatus;
+  since: string;
+  uptime_percent: number;
+  uptime_window_days: number;
+  last_incident?: {
+    status: 'degraded' | 'down';
+    started_at: string;
+    resolved_at?: string; // Unset while ongoing
+  };
+  generated_at: string;
+}
+
 export interface ScheduleNextRuns {
   app_id: string;
   next_start?: string; // ISO date string
@@ -61,7 +157,7 @@ export interface TimezoneOption {
 
 export interface Job {
   id: string;
-  type: 'app_create' | 'app_update' | 'tunnel_create' | 'tunnel_delete' | 'quick_tunnel';
+  type: 'app_create' | 'app_update' | 'tunnel_create' | 'tunnel_delete' | 'quick_tunnel' | 'app_run' | 'node_remove';
   app_id: string;
   status: 'pending' | 'running' | 'completed' | 'failed';
   payload?: string;
@@ -73,6 +169,9 @@ export interface Job {
   completed_at?: string;
   created_at: string;
   updated_at: string;
+  priority: number; // Claim order among pending jobs: 1 interactive, 0 normal, -1 background
+  node_id?: string;
+  node_name?: string;
 }
 
 export interface JobResponse {
@@ -90,6 +189,8 @@ export interface CreateAppRequest {
   tunnel_mode?: '' | 'custom' | 'quick';
   quick_tunnel_service?: string; // Required when tunnel_mode='quick'
   quick_tunnel_port?: number; // Required when tunnel_mode='quick'
+  storage_root?: string; // One of the node's storage_roots; omitted uses the default root
+  approve_policies?: ComposePolicy[]; // Compose policies in approval mode the admin approves for this app
 }
 
 export interface RegisterNodeRequest {
@@ -109,6 +210,172 @@ export interface UpdateAppRequest {
   name?: string;
   description?: string;
   compose_content?: string;
+  approve_policies?: ComposePolicy[]; // Compose policies in approval mode the admin approves for this change
+}
+
+// Privileged compose settings governed by the compose_policies settings section
+export type ComposePolicy = 'privileged' | 'host_network' | 'cap_add' | 'devices';
+
+export type CleanupStepId = 'containers' | 'volumes' | 'networks' | 'tunnel' | 'archive' | 'directory' | 'database';
+
+export interface DeleteAppStep {
+  id: CleanupStepId;
+  name: string;
+  success: boolean;
+  skipped?: boolean;
+  error?: string;
+  detail?: string;
+  resources?: { kind: string; name: string }[];
+}
+
+export interface DeleteAppResponse {
+  message: string;
+  appID: string;
+  dry_run?: boolean;
+  archive_path?: string;
+  archive_expires_at?: string;
+  steps: DeleteAppStep[];
+  approval?: Approval; // Set instead of steps while the deletion awaits confirmation
+}
+
+export type ApprovalOperation = 'delete_app' | 'delete_tunnel' | 'delete_orphaned_tunnel' | 'remove_node';
+export type ApprovalStatus = 'pending' | 'confirmed' | 'rejected' | 'expired';
+
+// A destructive operation awaiting, or given, a second admin's confirmation (APPROVAL_REQUIRED)
+export interface Approval {
+  id: string;
+  operation: ApprovalOperation;
+  resource_id: string; // App, tunnel or node ID
+  params?: Record<string, string>;
+  status: ApprovalStatus;
+  requested_by: string;
+  requested_at: string;
+  expires_at: string;
+  decided_by?: string;
+  decided_at?: string;
+  error?: string; // Why the confirmed operation failed
+}
+
+// Caps on one user's apps across the cluster; 0 leaves a limit off. User '*' is the default.
+export interface UserQuota {
+  user_name: string;
+  max_apps: number;
+  max_memory_mb: number; // Sum of deploy.resources.reservations.memory
+  max_cpus: number; // Sum of deploy.resources.reservations.cpus
+  max_tunnels: number;
+  updated_at: string;
+}
+
+export type SetQuotaRequest = Omit<UserQuota, 'user_name' | 'updated_at'>;
+
+export interface QuotaUsage {
+  user_name: string;
+  quota: UserQuota | null; // The user's quota, else the default; null when neither is set
+  apps: number;
+  memory_mb: number;
+  cpus: number;
+  tunnels: number;
+  incomplete?: boolean; // Some apps couldn't be counted, e.g. their node is offline
+}
+
+export type PortReservationSource = 'compose' | 'quick_tunnel_metrics' | 'manual';
+
+// A host port held on a node, by an app's compose or by hand
+export interface PortReservation {
+  id: string;
+  node_id: string;
+  port: number;
+  protocol: 'tcp' | 'udp';
+  app_id?: string; // Unset for a manual reservation of something selfhostly doesn't run
+  source: PortReservationSource;
+  description?: string;
+  created_at: string;
+}
+
+export interface ReservePortRequest {
+  port: number; // 0 picks the lowest free port
+  protocol?: 'tcp' | 'udp';
+  app_id?: string;
+  description: string;
+}
+
+export type UserRole = 'admin' | 'viewer';
+
+// Account of an invited GitHub user; users in GITHUB_ALLOWED_USERS have none
+export interface UserAccount {
+  id: string;
+  username: string;
+  role: UserRole;
+  email?: string;
+  invited_by?: string;
+  created_at: string;
+}
+
+export interface UserList {
+  users: UserAccount[];
+  allowed_users: string[] | null;
+}
+
+export type InvitationStatus = 'pending' | 'accepted' | 'expired' | 'revoked';
+
+export interface Invitation {
+  id: string;
+  email?: string;
+  role: UserRole;
+  created_by?: string;
+  expires_at: string;
+  accepted_by?: string;
+  accepted_at?: string;
+  revoked_at?: string;
+  created_at: string;
+  status: InvitationStatus;
+}
+
+export interface InviteUserRequest {
+  email?: string;
+  role?: UserRole; // Defaults to viewer
+  ttl_hours?: number; // Defaults to 168 (7 days), at most 720
+}
+
+// The token and link are only returned when the invitation is created
+export interface IssuedInvitation extends Invitation {
+  token: string;
+  url: string;
+  emailed: boolean;
+  email_error?: string;
+}
+
+// A signed-in device; user agent and IP are of the last request seen
+export interface Session {
+  id: string;
+  user_name: string;
+  user_agent?: string;
+  ip?: string;
+  created_at: string;
+  last_seen_at: string;
+  current: boolean; // The session this request was made with
+}
+
+// Personal access token for scripts and CI; the token itself is only in IssuedAPIToken
+export interface APIToken {
+  id: string;
+  name: string;
+  user_name: string;
+  token_prefix: string; // Start of the token, to tell tokens apart
+  read_only: boolean;
+  expires_at?: string; // Unset never expires
+  last_used_at?: string;
+  created_at: string;
+}
+
+export interface CreateAPITokenRequest {
+  name: string;
+  read_only?: boolean;
+  expires_in_days?: number; // 0 or unset never expires; at most 365
+}
+
+export interface IssuedAPIToken extends APIToken {
+  token: string; // Only shown this once
 }
 
 export interface Settings {
@@ -116,9 +383,79 @@ export interface Settings {
   active_tunnel_provider?: string;
   tunnel_provider_config?: string; // JSON string with masked tokens
   auto_start_apps: boolean;
+  telemetry_enabled: boolean;
+  updated_at: string;
+}
+
+// Documented field of a typed settings section (GET /api/settings/schema)
+export interface SettingsField {
+  name: string;
+  type: 'bool' | 'int' | 'string' | 'object';
+  description: string;
+  default?: unknown;
+  min?: number;
+  max?: number;
+  enum?: string[];
+  secret?: boolean; // Returned masked; send the masked value back to keep it
+  fields?: SettingsField[];
+}
+
+export interface SettingsSectionSchema {
+  name: string;
+  description: string;
+  fields: SettingsField[];
+}
+
+// Current values of one settings section (GET/PUT /api/settings/:section)
+export interface SettingsSection {
+  section: string;
+  values: Record<string, unknown>;
   updated_at: string;
 }
 
+// The current user's UI preferences (GET/PUT /api/me/preferences)
+export interface UserPreferences {
+  user_id: string;
+  default_node_id: string; // Empty for all nodes
+  table_columns: Record<string, string[]>; // Table name -> visible column keys, in order
+  refresh_interval: number; // Seconds; 0 turns auto-refresh off
+  theme: 'system' | 'light' | 'dark';
+  updated_at?: string;
+}
+
+// Only the fields sent are changed
+export type UpdatePreferencesRequest = Partial<Pick<UserPreferences, 'default_node_id' | 'table_columns' | 'refresh_interval' | 'theme'>>;
+
+// Admin lock blocking app changes on every node (GET/PUT/DELETE /api/system/operations-lock)
+export interface OperationsLockStatus {
+  locked: boolean;
+  lock?: {
+    reason: string;
+    locked_by?: string;
+    locked_at: string;
+  };
+}
+
+// Experimental feature flag; source "env" means FEATURE_<NAME> pins it and it can't be toggled here
+export interface FeatureFlag {
+  name: string;
+  description: string;
+  enabled: boolean;
+  source: 'default' | 'settings' | 'env';
+  env_var: string;
+}
+
+// Anonymous usage report, exactly as sent when telemetry is enabled
+export interface TelemetryReport {
+  install_id: string;
+  version: string;
+  os: string;
+  arch: string;
+  node_count: string; // Bucketed, e.g. "2-5"
+  app_count: string;
+  tunnel_providers: string[];
+}
+
 export interface IngressRule {
   hostname?: string | null;
   service: string;
@@ -130,6 +467,7 @@ export interface UpdateSettingsRequest {
   active_tunnel_provider?: string;
   tunnel_provider_config?: string;
   auto_start_apps?: boolean;
+  telemetry_enabled?: boolean;
 }
 
 export interface CloudflareTunnel {
@@ -145,6 +483,16 @@ export interface CloudflareTunnel {
   updated_at: string;
   last_synced_at?: string;
   error_details?: string;
+  replicas?: number; // containers running the tunnel
+  options?: TunnelOptions | null; // cloudflared flags; null keeps cloudflared's defaults
+}
+
+/** PUT /api/tunnels/apps/:appId/options - connector flags saved with a named tunnel; empty values keep the default */
+export interface TunnelOptions {
+  protocol?: '' | 'auto' | 'quic' | 'http2';
+  edge_ip_version?: '' | 'auto' | '4' | '6';
+  post_quantum?: boolean;
+  log_level?: '' | 'debug' | 'info' | 'warn' | 'error' | 'fatal';
 }
 
 /** GET /api/tunnels/apps/:appId - single envelope for primary and secondary; tunnel is null when no named tunnel (e.g. Quick Tunnel or none) */
@@ -161,6 +509,97 @@ export interface CloudflareTunnelResponse {
   count: number;
 }
 
+// Every tunnel across nodes and the provider account (GET /api/tunnels?inventory=true)
+export interface TunnelInventoryItem {
+  tunnel_id: string;
+  tunnel_name: string;
+  status: string;
+  node_id?: string;
+  app_id?: string;
+  app_name?: string;
+  public_url?: string;
+  tracked: boolean;
+  in_account: boolean;
+  orphaned: boolean;
+  created_at?: string;
+}
+
+export interface TunnelInventory {
+  provider: string;
+  account_listed: boolean;
+  complete: boolean;
+  unreachable_nodes?: string[];
+  orphan_count: number;
+  tunnels: TunnelInventoryItem[];
+}
+
+export interface TunnelConnection {
+  id: string;
+  location: string;
+  origin_ip?: string;
+  opened_at: string;
+  pending_reconnect: boolean;
+}
+
+export interface TunnelConnector {
+  id: string;
+  version?: string;
+  started_at?: string;
+  connections: TunnelConnection[];
+}
+
+// Connector status of an app's named tunnel (GET /api/apps/:id/tunnel/status)
+export interface TunnelConnectorStatus {
+  app_id: string;
+  provider: string;
+  tunnel_id: string;
+  tunnel_name: string;
+  state: 'healthy' | 'reconnecting' | 'no_connector';
+  connector_count: number;
+  locations: string[];
+  last_connected_at?: string;
+  connectors: TunnelConnector[];
+  checked_at: string;
+}
+
+/** GET /api/apps/:id/traffic - HTTP traffic served for an app's tunnel hostnames over a period */
+export type TrafficPeriod = '1h' | '24h' | '7d';
+
+export interface HostnameTraffic {
+  hostname: string;
+  requests: number;
+  bytes: number;
+  visits: number;
+}
+
+export interface TrafficCount {
+  key: string; // country name or response status code
+  requests: number;
+}
+
+export interface AppTraffic {
+  app_id: string;
+  provider: string;
+  period: TrafficPeriod;
+  since: string;
+  until: string;
+  requests: number;
+  bytes: number;
+  visits: number;
+  hostnames: HostnameTraffic[];
+  countries: TrafficCount[];
+  status_codes: TrafficCount[];
+  untracked_hostnames: string[]; // wildcards or hostnames in zones the API token can't read
+}
+
+// Adopts an existing tunnel: app_id attaches it to an app without a tunnel, app_name creates a new app around it
+export interface ImportTunnelRequest {
+  tunnel_id: string;
+  app_id?: string;
+  app_name?: string;
+  compose_content?: string;
+}
+
 // New provider-agnostic tunnel types
 export interface TunnelProvider {
   name: string;
@@ -178,9 +617,34 @@ export interface ProviderFeatures {
     status_sync: boolean;
     container: boolean;
     list: boolean;
+    connectors?: boolean;
+    replicas?: boolean;
+    connector_options?: boolean;
+    traffic?: boolean;
   };
 }
 
+// An ingress rule whose service URL matches no service or port in the app's compose
+export interface IngressWarning {
+  rule: number;
+  service: string;
+  message: string;
+}
+
+export interface ProviderZone {
+  id: string;
+  name: string;
+  status: string;
+}
+
+// An existing DNS record; tunnel_id is set when it already routes to a tunnel
+export interface ZoneHostname {
+  name: string;
+  type: string;
+  target: string;
+  tunnel_id?: string;
+}
+
 export interface TunnelProvidersResponse {
   providers: TunnelProvider[];
   active: string;
@@ -220,10 +684,21 @@ export interface ComposeVersion {
   is_current: boolean;
   created_at: string;
   rolled_back_from?: number | null;
+  checksum: string;
+}
+
+// Whether the app's docker-compose.yml on disk still matches its current compose version
+export interface ComposeIntegrity {
+  app_id: string;
+  version: number;
+  expected_checksum: string;
+  disk_checksum?: string;
+  modified: boolean;
 }
 
 export interface RollbackRequest {
   change_reason?: string;
+  restart_containers?: boolean;
 }
 
 export interface AppStats {
@@ -261,6 +736,13 @@ export interface SystemStats {
   timestamp: string;
   error?: string; // Error message if stats couldn't be fetched
   status: 'online' | 'offline' | 'error'; // Node connectivity status
+  storage_roots?: StorageRootStats[]; // Disk usage of each directory the node creates apps under
+}
+
+export interface StorageRootStats {
+  name: string;
+  apps: number; // Apps on the node created under this root
+  disk: DiskStats;
 }
 
 export interface CPUStats {
@@ -311,3 +793,37 @@ export interface ContainerInfo {
   created_at: string;
   restart_count: number;
 }
+
+// Server-sent events from GET /api/events
+export type ServerEventType = 'app_status' | 'job' | 'node_health' | 'notification';
+
+export interface ServerEvent<T = unknown> {
+  id: number;
+  type: ServerEventType;
+  node_id: string;
+  app_id?: string;
+  at: string;
+  data: T;
+}
+
+export interface AppStatusEvent {
+  app_id: string;
+  app_name: string;
+  from: string;
+  to: string;
+  reason?: string;
+  at: string;
+}
+
+export interface NodeHealthEvent {
+  node_id: string;
+  node_name: string;
+  from: string;
+  to: string;
+}
+
+export interface NotificationEvent {
+  event: string;
+  subject: string;
+  message?: string;
+}